@@ -0,0 +1,22 @@
+package api
+
+import "context"
+
+// Caller of API method: client of HTTP request
+type Caller struct {
+	Address string // IP address of client (respects trusted proxies), empty if unknown
+	Local   bool   // call by local admin socket: trusted without token
+}
+
+type callerKey struct{}
+
+// WithCaller returns context of API method invoked by caller.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom context (zero value if unknown).
+func CallerFrom(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
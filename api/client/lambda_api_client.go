@@ -19,9 +19,12 @@ type LambdaAPIClient struct {
 	sequence uint64
 }
 
-// Upload content from .tar.gz archive to app and call Install handler (if defined)
-func (impl *LambdaAPIClient) Upload(ctx context.Context, token *api.Token, uid string, tarGz []byte) (reply bool, err error) {
-	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Upload", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, tarGz)
+/*
+Upload content from .tar.gz or .zip archive (detected by content) to app and call Install handler (if defined).
+Unknown format is error with code 415
+*/
+func (impl *LambdaAPIClient) Upload(ctx context.Context, token *api.Token, uid string, archive []byte) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Upload", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, archive)
 	return
 }
 
@@ -31,6 +34,30 @@ func (impl *LambdaAPIClient) Download(ctx context.Context, token *api.Token, uid
 	return
 }
 
+// Upload content as single read-only .zip bundle to app and returns bundle hash
+func (impl *LambdaAPIClient) UploadBundle(ctx context.Context, token *api.Token, uid string, zip []byte) (reply string, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.UploadBundle", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, zip)
+	return
+}
+
+// Hash of app content (hash of bundle for bundled app)
+func (impl *LambdaAPIClient) ContentHash(ctx context.Context, token *api.Token, uid string) (reply string, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.ContentHash", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+// Upload content as Upload does and return hash of received archive and content hash of app signed by token
+func (impl *LambdaAPIClient) UploadVerified(ctx context.Context, token *api.Token, uid string, archive []byte, nonce string) (reply *api.ContentProof, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.UploadVerified", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, archive, nonce)
+	return
+}
+
+// Hash of app content (as ContentHash) signed by token for spot checks
+func (impl *LambdaAPIClient) SignedContentHash(ctx context.Context, token *api.Token, uid string, nonce string) (reply *api.ContentProof, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.SignedContentHash", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, nonce)
+	return
+}
+
 // Push single file to app
 func (impl *LambdaAPIClient) Push(ctx context.Context, token *api.Token, uid string, file string, content []byte) (reply bool, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Push", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, file, content)
@@ -61,9 +88,58 @@ func (impl *LambdaAPIClient) Info(ctx context.Context, token *api.Token, uid str
 	return
 }
 
-// Update application manifest
-func (impl *LambdaAPIClient) Update(ctx context.Context, token *api.Token, uid string, manifest types.Manifest) (reply *application.Definition, err error) {
-	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Update", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, manifest)
+/*
+Update application manifest. Invalid manifest is error with code 422 and problems of fields
+(list of field and message) as data. Declared aliases are bound to the lambda, aliases removed from manifest
+are released. Aliases bound to other lambdas are error with code 409 and conflicts as data unless forceAliases
+is set (aliases are moved to the lambda)
+*/
+func (impl *LambdaAPIClient) Update(ctx context.Context, token *api.Token, uid string, manifest types.Manifest, forceAliases bool) (reply *application.Definition, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Update", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, manifest, forceAliases)
+	return
+}
+
+/*
+Apply partial change of manifest (merge patch and assignments of fields) to selected lambdas. Every patched
+manifest is validated and applied the same way as by Update (aliases are not forced): failure of one lambda does
+not abort the batch and is reported in its result. Dry run reports changes without apply
+*/
+func (impl *LambdaAPIClient) BulkUpdate(ctx context.Context, token *api.Token, request api.BulkUpdate) (reply *api.BulkReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.BulkUpdate", atomic.AddUint64(&impl.sequence, 1), &reply, token, request)
+	return
+}
+
+// Environment variables of application from manifest (as is, references are not resolved)
+func (impl *LambdaAPIClient) Environment(ctx context.Context, token *api.Token, uid string) (reply *api.Environment, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Environment", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+/*
+Set and remove environment variables of application without changing the rest of manifest. Returns updated
+environment. Applied for the next invocation
+*/
+func (impl *LambdaAPIClient) SetEnvironment(ctx context.Context, token *api.Token, uid string, set api.Environment, unset []string) (reply *api.Environment, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.SetEnvironment", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, set, unset)
+	return
+}
+
+/*
+Variables of environment of invocations of application: runtime defaults and global environment of server merged
+with environment of manifest (manifest wins). References are resolved except references to secrets. Variables of
+server process and request are not included
+*/
+func (impl *LambdaAPIClient) MergedEnvironment(ctx context.Context, token *api.Token, uid string) (reply []application.EnvValue, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.MergedEnvironment", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+/*
+Set (passwords are hashed by server) and remove users of basic authentication of application without changing
+the rest of manifest. Authentication is removed with the last user. Returns updated usernames
+*/
+func (impl *LambdaAPIClient) SetBasicAuth(ctx context.Context, token *api.Token, uid string, set api.Passwords, unset []string) (reply []string, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.SetBasicAuth", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, set, unset)
 	return
 }
 
@@ -91,18 +167,39 @@ func (impl *LambdaAPIClient) Stats(ctx context.Context, token *api.Token, uid st
 	return
 }
 
-// Actions available for the app
+// Records of the app in time range from the newest (UID of query is replaced by UID of the app)
+func (impl *LambdaAPIClient) StatsRange(ctx context.Context, token *api.Token, uid string, query stats.Query) (reply []stats.Record, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.StatsRange", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, query)
+	return
+}
+
+/*
+Calls, errors by category and latency percentiles of the app by buckets of time range (UID of query is replaced
+by UID of the app, zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+*/
+func (impl *LambdaAPIClient) StatsAggregate(ctx context.Context, token *api.Token, uid string, query stats.Query, bucket types.JsonDuration, group string) (reply *stats.Aggregation, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.StatsAggregate", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, query, bucket, group)
+	return
+}
+
+// Actions available for the app: actions of manifest and targets of Makefile
 func (impl *LambdaAPIClient) Actions(ctx context.Context, token *api.Token, uid string) (reply []string, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Actions", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
 	return
 }
 
-// Invoke action in the app (if make installed)
+// Invoke action in the app (action of manifest or make target, if make installed)
 func (impl *LambdaAPIClient) Invoke(ctx context.Context, token *api.Token, uid string, action string) (reply string, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Invoke", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, action)
 	return
 }
 
+// Invoke action in the app with time limit (0 means no limit) and return output with exit code even for failed action
+func (impl *LambdaAPIClient) InvokeAction(ctx context.Context, token *api.Token, uid string, action string, timeLimit types.JsonDuration) (reply *api.ActionResult, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.InvokeAction", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, action, timeLimit)
+	return
+}
+
 // Make link/alias for app
 func (impl *LambdaAPIClient) Link(ctx context.Context, token *api.Token, uid string, alias string) (reply *application.Definition, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Link", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, alias)
@@ -114,3 +211,150 @@ func (impl *LambdaAPIClient) Unlink(ctx context.Context, token *api.Token, alias
 	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Unlink", atomic.AddUint64(&impl.sequence, 1), &reply, token, alias)
 	return
 }
+
+// Effective runtime settings (umask, locale, timezone) of the app
+func (impl *LambdaAPIClient) Doctor(ctx context.Context, token *api.Token, uid string) (reply *application.Diagnostic, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Doctor", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+// Captured requests of the app from the newest without bodies (see types.Manifest.Capture)
+func (impl *LambdaAPIClient) Requests(ctx context.Context, token *api.Token, uid string) (reply []application.CapturedRequest, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Requests", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+// Captured request of the app with body
+func (impl *LambdaAPIClient) CapturedRequest(ctx context.Context, token *api.Token, uid string, id string) (reply *application.CapturedRequest, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.CapturedRequest", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, id)
+	return
+}
+
+// Invoke the app by captured request again and return new result. Truncated requests can not be replayed
+func (impl *LambdaAPIClient) Replay(ctx context.Context, token *api.Token, uid string, id string) (reply *api.ReplayResult, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Replay", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, id)
+	return
+}
+
+/*
+Enable or disable the app without removing it. Disabled app responds 503 to public requests (by UID and
+aliases), its schedules are paused and queued requests are held till it is enabled. State survives restarts
+*/
+func (impl *LambdaAPIClient) SetEnabled(ctx context.Context, token *api.Token, uid string, enabled bool) (reply *application.Definition, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.SetEnabled", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, enabled)
+	return
+}
+
+// Generate slug alias from name of the app (previous slug is kept as alias)
+func (impl *LambdaAPIClient) RegenerateSlug(ctx context.Context, token *api.Token, uid string) (reply *application.Definition, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.RegenerateSlug", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+// Reset fired alert rules of the app (all rules if rule is empty) and re-enable it
+func (impl *LambdaAPIClient) ResetAlerts(ctx context.Context, token *api.Token, uid string, rule string) (reply []application.AlertStatus, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.ResetAlerts", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, rule)
+	return
+}
+
+/*
+Fetch tracked git branch of the app (see types.Manifest.Git) and deploy new commit (force - deploy even the same
+or failed commit). Failed deploy keeps the previous version and is returned as status with error. Error with code
+404 if the app does not track git, 409 if deploy is in progress
+*/
+func (impl *LambdaAPIClient) GitDeploy(ctx context.Context, token *api.Token, uid string, force bool) (reply *application.GitStatus, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.GitDeploy", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, force)
+	return
+}
+
+/*
+Short-lived (5 minutes) grant of export of the app by another server (see Export and ProjectAPI.Transfer)
+with hash of exported content. Values of environment variables are exported only if secrets is set
+*/
+func (impl *LambdaAPIClient) GrantExport(ctx context.Context, token *api.Token, uid string, secrets bool) (reply *api.TransferGrant, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.GrantExport", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, secrets)
+	return
+}
+
+// Export the app by token of grant (see GrantExport) instead of login token: manifest, aliases and content
+func (impl *LambdaAPIClient) Export(ctx context.Context, grant string, uid string) (reply *api.LambdaExport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Export", atomic.AddUint64(&impl.sequence, 1), &reply, grant, uid)
+	return
+}
+
+/*
+Freeze all files of the app, including dependencies installed by actions and files ignored by .cgiignore, to
+content-addressed artifact kept by server: freeze of the same files returns the same artifact. Old artifacts are
+pruned by retention of server
+*/
+func (impl *LambdaAPIClient) Freeze(ctx context.Context, token *api.Token, uid string) (reply *application.FrozenArtifact, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Freeze", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+// Frozen artifacts of the app from the newest
+func (impl *LambdaAPIClient) FrozenArtifacts(ctx context.Context, token *api.Token, uid string) (reply []application.FrozenArtifact, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.FrozenArtifacts", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+/*
+Download frozen artifact of the app (empty id - the newest) as .tar.gz archive. Unknown artifact is error with
+code 404
+*/
+func (impl *LambdaAPIClient) DownloadFrozen(ctx context.Context, token *api.Token, uid string, id string) (reply []byte, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.DownloadFrozen", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, id)
+	return
+}
+
+/*
+Replace all files of the app by frozen artifact (empty id - the newest) without builds and network: manifest of
+artifact is applied. Unknown artifact is error with code 404
+*/
+func (impl *LambdaAPIClient) Thaw(ctx context.Context, token *api.Token, uid string, id string) (reply *application.FrozenArtifact, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Thaw", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, id)
+	return
+}
+
+/*
+Upload content as Upload does and return version of the app annotated by message (upload of the same files
+returns the same version). Versions are also taken by every upload and update of manifest
+*/
+func (impl *LambdaAPIClient) UploadVersion(ctx context.Context, token *api.Token, uid string, archive []byte, message string) (reply *application.LambdaVersion, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.UploadVersion", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, archive, message)
+	return
+}
+
+// Versions of the app (files and manifest) from the newest. Old versions are pruned by retention of server
+func (impl *LambdaAPIClient) Versions(ctx context.Context, token *api.Token, uid string) (reply []application.LambdaVersion, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Versions", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+/*
+Restore files and manifest of version of the app (ID or unique prefix) and run build action (empty - build if
+defined by Makefile of version) before files are replaced: failed build keeps the app. Unknown version is error
+with code 404
+*/
+func (impl *LambdaAPIClient) Rollback(ctx context.Context, token *api.Token, uid string, rollback application.VersionRollback) (reply *application.RollbackReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Rollback", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, rollback)
+	return
+}
+
+// Log files of the app (full stdout and stderr of invocations if logs are enabled by manifest) from the oldest
+func (impl *LambdaAPIClient) LogFiles(ctx context.Context, token *api.Token, uid string) (reply []application.LogFile, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.LogFiles", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
+
+// Download log file of the app by name (see LogFiles). Unknown file is error with code 404
+func (impl *LambdaAPIClient) DownloadLog(ctx context.Context, token *api.Token, uid string, name string) (reply []byte, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.DownloadLog", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, name)
+	return
+}
+
+// The last lines (zero - 100) of current log file of the app
+func (impl *LambdaAPIClient) TailLog(ctx context.Context, token *api.Token, uid string, lines int) (reply string, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.TailLog", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, lines)
+	return
+}
@@ -5,8 +5,11 @@ import (
 	client "github.com/reddec/jsonrpc2/client"
 	api "github.com/reddec/trusted-cgi/api"
 	application "github.com/reddec/trusted-cgi/application"
+	openapi "github.com/reddec/trusted-cgi/application/openapi"
 	stats "github.com/reddec/trusted-cgi/stats"
+	types "github.com/reddec/trusted-cgi/types"
 	"sync/atomic"
+	"time"
 )
 
 func DefaultProjectAPI() *ProjectAPIClient {
@@ -36,6 +39,15 @@ func (impl *ProjectAPIClient) SetEnvironment(ctx context.Context, token *api.Tok
 	return
 }
 
+/*
+Set and remove variables of global environment without changing the rest. Returns updated global environment.
+Applied for the next invocation of every lambda
+*/
+func (impl *ProjectAPIClient) UpdateEnvironment(ctx context.Context, token *api.Token, set api.Environment, unset []string) (reply *api.Environment, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.UpdateEnvironment", atomic.AddUint64(&impl.sequence, 1), &reply, token, set, unset)
+	return
+}
+
 // Get all templates without filtering
 func (impl *ProjectAPIClient) AllTemplates(ctx context.Context, token *api.Token) (reply []*api.TemplateStatus, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.AllTemplates", atomic.AddUint64(&impl.sequence, 1), &reply, token)
@@ -60,20 +72,245 @@ func (impl *ProjectAPIClient) Stats(ctx context.Context, token *api.Token, limit
 	return
 }
 
+// Global records in time range (optionally of one lambda) from the newest
+func (impl *ProjectAPIClient) StatsRange(ctx context.Context, token *api.Token, query stats.Query) (reply []stats.Record, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.StatsRange", atomic.AddUint64(&impl.sequence, 1), &reply, token, query)
+	return
+}
+
+/*
+Global calls, errors by category and latency percentiles (optionally of one lambda) by buckets of time range
+(zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+*/
+func (impl *ProjectAPIClient) StatsAggregate(ctx context.Context, token *api.Token, query stats.Query, bucket types.JsonDuration, group string) (reply *stats.Aggregation, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.StatsAggregate", atomic.AddUint64(&impl.sequence, 1), &reply, token, query, bucket, group)
+	return
+}
+
+/*
+Usage of storage of stats: records, size, the oldest record, retention and the last pruning (behind - pruning
+doesn't keep up with limits)
+*/
+func (impl *ProjectAPIClient) StatsUsage(ctx context.Context, token *api.Token) (reply *stats.Usage, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.StatsUsage", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+Change retention of stats without restart: server limits and limits of lambdas by UID (zero - not limited).
+Records over new limits are pruned in background
+*/
+func (impl *ProjectAPIClient) SetStatsRetention(ctx context.Context, token *api.Token, retention stats.Retention) (reply *stats.Usage, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.SetStatsRetention", atomic.AddUint64(&impl.sequence, 1), &reply, token, retention)
+	return
+}
+
 // Create new app (lambda)
 func (impl *ProjectAPIClient) Create(ctx context.Context, token *api.Token) (reply *application.Definition, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Create", atomic.AddUint64(&impl.sequence, 1), &reply, token)
 	return
 }
 
-// Create new app/lambda/function using pre-defined template
+/*
+Create new app/lambda/function using pre-defined template. Invalid manifest is error with code 422 (see Update)
+Resolved outputs of template (ex: example of call) are in outputs field
+*/
 func (impl *ProjectAPIClient) CreateFromTemplate(ctx context.Context, token *api.Token, templateName string) (reply *application.Definition, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.CreateFromTemplate", atomic.AddUint64(&impl.sequence, 1), &reply, token, templateName)
 	return
 }
 
-// Create new app/lambda/function using remote Git repo
+// Create new app/lambda/function using remote Git repo. Invalid manifest is error with code 422 (see Update)
 func (impl *ProjectAPIClient) CreateFromGit(ctx context.Context, token *api.Token, repo string) (reply *application.Definition, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.CreateFromGit", atomic.AddUint64(&impl.sequence, 1), &reply, token, repo)
 	return
 }
+
+/*
+Create new app/lambda/function from template (empty - default manifest) with name and optional slug alias
+Resolved outputs of template are in outputs field
+*/
+func (impl *ProjectAPIClient) CreateWithOptions(ctx context.Context, token *api.Token, options api.CreateOptions) (reply *application.Definition, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.CreateWithOptions", atomic.AddUint64(&impl.sequence, 1), &reply, token, options)
+	return
+}
+
+/*
+Duplicate lambda on server: files (streamed), manifest and environment are copied to new lambda without shared
+state, optionally without aliases, schedules or references to secrets. Aliases bound to other lambdas (ex: to the
+source) are not declared by copy: they are in warning of copy
+*/
+func (impl *ProjectAPIClient) Duplicate(ctx context.Context, token *api.Token, uid string, options application.DuplicateOptions) (reply *application.Definition, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Duplicate", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, options)
+	return
+}
+
+// Server information: version, capabilities and effective configuration (same as `trusted-cgi info`)
+func (impl *ProjectAPIClient) Capabilities(ctx context.Context, token *api.Token) (reply *api.ServerInfo, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Capabilities", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+Capacity report: usage of lambdas aggregated over window (zero - one hour) by stats records, disk usage, queue
+backlogs and naive projection of headroom
+*/
+func (impl *ProjectAPIClient) Capacity(ctx context.Context, token *api.Token, window types.JsonDuration) (reply *application.CapacityReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Capacity", atomic.AddUint64(&impl.sequence, 1), &reply, token, window)
+	return
+}
+
+// Status of replication if server is read-only mirror of primary server (disabled status otherwise)
+func (impl *ProjectAPIClient) Mirror(ctx context.Context, token *api.Token) (reply *application.MirrorStatus, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Mirror", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+// Promote read-only mirror to primary: replication is stopped and mutating API is enabled
+func (impl *ProjectAPIClient) Promote(ctx context.Context, token *api.Token) (reply *application.MirrorStatus, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Promote", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+Administrative changes (deploys, manifest edits, aliases, policies, users and settings) in time range
+[since, until) (zero until - till now) grouped by lambda. Page is defined by offset and limit (zero - 100) of changes
+*/
+func (impl *ProjectAPIClient) Changes(ctx context.Context, token *api.Token, since time.Time, until time.Time, offset int, limit int) (reply *application.ChangesReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Changes", atomic.AddUint64(&impl.sequence, 1), &reply, token, since, until, offset, limit)
+	return
+}
+
+/*
+Audit log: changes and failed authentications matched by query (time range with zero until - till now, lambda
+by UID or alias, kind) in order of recording. Page is defined by offset and limit (zero - 100)
+*/
+func (impl *ProjectAPIClient) Audit(ctx context.Context, token *api.Token, query application.ChangeQuery, offset int, limit int) (reply *application.ChangesPage, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Audit", atomic.AddUint64(&impl.sequence, 1), &reply, token, query, offset, limit)
+	return
+}
+
+/*
+Active security profile and options of lambdas different from its defaults (including violations of mandatory
+rules by manifests uploaded before the profile)
+*/
+func (impl *ProjectAPIClient) Security(ctx context.Context, token *api.Token) (reply *application.SecurityReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Security", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+Canonical public invocation paths of lambdas (by UID, slug, aliases and linked queues) ordered by UID, for
+generation of reverse-proxy configuration
+*/
+func (impl *ProjectAPIClient) Routes(ctx context.Context, token *api.Token) (reply []application.LambdaRoutes, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Routes", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+OpenAPI 3.1 document of public routes of lambdas (by UID, slug and aliases) generated by current manifests:
+methods, content types and input schema of requests, output headers of responses. Lambda is UID of the only
+lambda in document (empty - all lambdas). Public URL is base URL of server seen by client (ignored if server has
+configured one, empty - document without servers)
+*/
+func (impl *ProjectAPIClient) OpenAPI(ctx context.Context, token *api.Token, lambda string, publicURL string) (reply *openapi.Document, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.OpenAPI", atomic.AddUint64(&impl.sequence, 1), &reply, token, lambda, publicURL)
+	return
+}
+
+/*
+Full-text search of lambdas by names, descriptions, aliases and labels: lambdas matching all words of query
+(word matches the same word or beginning of longer word) ordered by relevance with highlighted matched words.
+Number of hits is limited (zero - 20)
+*/
+func (impl *ProjectAPIClient) Search(ctx context.Context, token *api.Token, query string, limit int) (reply *application.SearchResult, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Search", atomic.AddUint64(&impl.sequence, 1), &reply, token, query, limit)
+	return
+}
+
+/*
+Transfer app from another server: export is pulled from source by grant (see LambdaAPI.GrantExport) and content
+is verified by hash of grant. App keeps UID, manifest (including schedules) and aliases which are free, nothing
+is created on failure. Existent UID is error with code 409
+*/
+func (impl *ProjectAPIClient) Transfer(ctx context.Context, token *api.Token, request api.TransferRequest) (reply *api.TransferReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Transfer", atomic.AddUint64(&impl.sequence, 1), &reply, token, request)
+	return
+}
+
+// Progress of running or recently finished transfer by ID of request
+func (impl *ProjectAPIClient) TransferProgress(ctx context.Context, token *api.Token, id string) (reply *api.TransferProgress, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.TransferProgress", atomic.AddUint64(&impl.sequence, 1), &reply, token, id)
+	return
+}
+
+/*
+Secrets of server store ordered by name: metadata and lambdas which environment references them (values are
+never returned). Disabled store is error
+*/
+func (impl *ProjectAPIClient) Secrets(ctx context.Context, token *api.Token) (reply []application.Secret, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Secrets", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+// Set value of secret of server store (created if not exists). Value is write-only
+func (impl *ProjectAPIClient) SetSecret(ctx context.Context, token *api.Token, name string, value string) (reply *application.Secret, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.SetSecret", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, value)
+	return
+}
+
+// Remove secret of server store. Lambdas which still reference it are in result: their variables become empty
+func (impl *ProjectAPIClient) RemoveSecret(ctx context.Context, token *api.Token, name string) (reply *application.Secret, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.RemoveSecret", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+/*
+Recent attempts of deliveries of lifecycle events to webhooks of server and lambdas from the newest, including
+dropped events (zero attempt). Number of attempts is limited (zero - all kept attempts)
+*/
+func (impl *ProjectAPIClient) WebhookDeliveries(ctx context.Context, token *api.Token, limit int) (reply []application.WebhookDelivery, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.WebhookDeliveries", atomic.AddUint64(&impl.sequence, 1), &reply, token, limit)
+	return
+}
+
+/*
+Backup of server as .tar.gz archive: lambdas (content, manifests, aliases, state), policies, queues, API tokens
+(hashes of values, unless excluded), sealed secrets and settings
+*/
+func (impl *ProjectAPIClient) Backup(ctx context.Context, token *api.Token, options application.BackupOptions) (reply []byte, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Backup", atomic.AddUint64(&impl.sequence, 1), &reply, token, options)
+	return
+}
+
+/*
+Restore server from backup archive (see Backup): items which do not exist are created, existent items are
+conflicts handled by options (skip by default, fail is error with code 409 and conflicts in data). Dry run
+returns plan without changes
+*/
+func (impl *ProjectAPIClient) Restore(ctx context.Context, token *api.Token, archive []byte, options application.RestoreOptions) (reply *application.RestoreReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Restore", atomic.AddUint64(&impl.sequence, 1), &reply, token, archive, options)
+	return
+}
+
+/*
+Import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent lambda
+(by UID). Archive is extracted to staged copy, manifest of archive (or template.json) is applied and post-clone
+action is run there: failed import does not change lambda. Archive over upload limit is error with code 413,
+unknown format - 415, invalid manifest - 422
+*/
+func (impl *ProjectAPIClient) ImportURL(ctx context.Context, token *api.Token, request application.ArtifactImport) (reply *application.ArtifactReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.ImportURL", atomic.AddUint64(&impl.sequence, 1), &reply, token, request)
+	return
+}
+
+/*
+Reload files edited on disk out of server: project config, security profile, manifests and changed files of all
+lambdas (empty UID) or manifest and files of one lambda. Invalid files are not applied and reported in items,
+the last good state is kept. For setups without watcher of files
+*/
+func (impl *ProjectAPIClient) Reload(ctx context.Context, token *api.Token, uid string) (reply *application.ReloadReport, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Reload", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
+	return
+}
@@ -46,3 +46,72 @@ func (impl *QueuesAPIClient) Assign(ctx context.Context, token *api.Token, name
 	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Assign", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, lambda)
 	return
 }
+
+/*
+Oldest message of queue without consuming it: headers and chain of lambdas which produced message (body is
+not returned)
+*/
+func (impl *QueuesAPIClient) Inspect(ctx context.Context, token *api.Token, name string) (reply *application.QueueMessage, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Inspect", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+// Return quarantined (corrupted) messages of queue to the end of queue, returns number of returned messages
+func (impl *QueuesAPIClient) RetryQuarantined(ctx context.Context, token *api.Token, name string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.RetryQuarantined", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+// Remove quarantined (corrupted) messages of queue, returns number of removed messages
+func (impl *QueuesAPIClient) PurgeQuarantined(ctx context.Context, token *api.Token, name string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.PurgeQuarantined", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+/*
+Pending asynchronous invocations (including in-flight) in order of acceptance, empty if asynchronous
+invocations are disabled
+*/
+func (impl *QueuesAPIClient) Async(ctx context.Context, token *api.Token) (reply []application.AsyncInvocation, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Async", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+Remove pending asynchronous invocations by tickets (empty - all pending), in-flight invocations are not
+interrupted. Returns number of removed invocations
+*/
+func (impl *QueuesAPIClient) PurgeAsync(ctx context.Context, token *api.Token, tickets []string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.PurgeAsync", atomic.AddUint64(&impl.sequence, 1), &reply, token, tickets)
+	return
+}
+
+/*
+Dead letters of lambda (queued requests not processed after all attempts) from the newest, body is not
+returned
+*/
+func (impl *QueuesAPIClient) DeadLetters(ctx context.Context, token *api.Token, lambda string) (reply []application.DeadLetter, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.DeadLetters", atomic.AddUint64(&impl.sequence, 1), &reply, token, lambda)
+	return
+}
+
+// Dead letter of lambda with body
+func (impl *QueuesAPIClient) DeadLetter(ctx context.Context, token *api.Token, lambda string, id string) (reply *application.DeadLetter, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.DeadLetter", atomic.AddUint64(&impl.sequence, 1), &reply, token, lambda, id)
+	return
+}
+
+// Remove dead letters of lambda by IDs (empty - all), returns number of removed letters
+func (impl *QueuesAPIClient) RemoveDeadLetters(ctx context.Context, token *api.Token, lambda string, ids []string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.RemoveDeadLetters", atomic.AddUint64(&impl.sequence, 1), &reply, token, lambda, ids)
+	return
+}
+
+/*
+Put dead letters of lambda by IDs (empty - all) back to their queues and remove them, returns number of
+returned letters
+*/
+func (impl *QueuesAPIClient) RedriveDeadLetters(ctx context.Context, token *api.Token, lambda string, ids []string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.RedriveDeadLetters", atomic.AddUint64(&impl.sequence, 1), &reply, token, lambda, ids)
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	client "github.com/reddec/jsonrpc2/client"
 	api "github.com/reddec/trusted-cgi/api"
+	types "github.com/reddec/trusted-cgi/types"
 	"sync/atomic"
 )
 
@@ -27,3 +28,48 @@ func (impl *UserAPIClient) ChangePassword(ctx context.Context, token *api.Token,
 	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.ChangePassword", atomic.AddUint64(&impl.sequence, 1), &reply, token, password)
 	return
 }
+
+/*
+Create long-lived API token limited by scope (lambdas and operations) with time to live (zero - never expires).
+Value of token is returned only once
+*/
+func (impl *UserAPIClient) CreateToken(ctx context.Context, token *api.Token, name string, scope api.Scope, ttl types.JsonDuration) (reply *api.TokenInfo, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.CreateToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, scope, ttl)
+	return
+}
+
+/*
+Issue replacement of API token with the same name, scope and time to live. Replaced token is valid for overlap
+(zero - one hour) to switch automation without downtime
+*/
+func (impl *UserAPIClient) RotateToken(ctx context.Context, token *api.Token, id string, overlap types.JsonDuration) (reply *api.TokenInfo, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.RotateToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, id, overlap)
+	return
+}
+
+// API tokens with scopes and expiration (without values). Tokens expired more than 7 days ago are removed
+func (impl *UserAPIClient) Tokens(ctx context.Context, token *api.Token) (reply []api.TokenInfo, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.Tokens", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+// Revoke API token by ID
+func (impl *UserAPIClient) RevokeToken(ctx context.Context, token *api.Token, id string) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.RevokeToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, id)
+	return
+}
+
+// Accounts and client addresses with failed logins, including locked out ones
+func (impl *UserAPIClient) Lockouts(ctx context.Context, token *api.Token) (reply []api.Lockout, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.Lockouts", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+/*
+Clear lockout and failed logins of account or client address (empty - all), returns number of cleared subjects.
+Could be called by local admin socket without token (ex: admin account is locked out)
+*/
+func (impl *UserAPIClient) Unlock(ctx context.Context, token *api.Token, subject string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "UserAPI.Unlock", atomic.AddUint64(&impl.sequence, 1), &reply, token, subject)
+	return
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ArchiveHash is SHA-256 (hex) of uploaded archive as it is sent by client and received by server
+func ArchiveHash(archive []byte) string {
+	sum := sha256.Sum256(archive)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign proof by session token
+func (cp *ContentProof) Sign(token *Token) {
+	cp.Signature = cp.signature(token)
+}
+
+// Verify signature of proof by session token
+func (cp *ContentProof) Verify(token *Token) bool {
+	return hmac.Equal([]byte(cp.signature(token)), []byte(cp.Signature))
+}
+
+func (cp *ContentProof) signature(token *Token) string {
+	mac := hmac.New(sha256.New, []byte(token.Data))
+	for _, field := range []string{"trusted-cgi content proof", cp.UID, cp.Archive, cp.Hash, cp.Nonce} {
+		mac.Write([]byte(field))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	jsonrpc2 "github.com/reddec/jsonrpc2"
 	api "github.com/reddec/trusted-cgi/api"
+	application "github.com/reddec/trusted-cgi/application"
+	stats "github.com/reddec/trusted-cgi/stats"
 	types "github.com/reddec/trusted-cgi/types"
 )
 
@@ -16,7 +18,7 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		var args struct {
 			Arg0 *api.Token `json:"token"`
 			Arg1 string     `json:"uid"`
-			Arg2 []byte     `json:"tarGz"`
+			Arg2 []byte     `json:"archive"`
 		}
 		var err error
 		if positional {
@@ -55,6 +57,94 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		return wrap.Download(ctx, args.Arg0, args.Arg1)
 	})
 
+	router.RegisterFunc("LambdaAPI.UploadBundle", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 []byte     `json:"zip"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.UploadBundle(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.ContentHash", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.ContentHash(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.UploadVerified", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 []byte     `json:"archive"`
+			Arg3 string     `json:"nonce"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.UploadVerified(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("LambdaAPI.SignedContentHash", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"nonce"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.SignedContentHash(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
 	router.RegisterFunc("LambdaAPI.Push", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -169,10 +259,120 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 			Arg0 *api.Token     `json:"token"`
 			Arg1 string         `json:"uid"`
 			Arg2 types.Manifest `json:"manifest"`
+			Arg3 bool           `json:"forceAliases"`
 		}
 		var err error
 		if positional {
-			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Update(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("LambdaAPI.BulkUpdate", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token     `json:"token"`
+			Arg1 api.BulkUpdate `json:"request"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.BulkUpdate(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.Environment", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Environment(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.SetEnvironment", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token      `json:"token"`
+			Arg1 string          `json:"uid"`
+			Arg2 api.Environment `json:"set"`
+			Arg3 []string        `json:"unset"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.SetEnvironment(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("LambdaAPI.MergedEnvironment", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.MergedEnvironment(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.SetBasicAuth", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token    `json:"token"`
+			Arg1 string        `json:"uid"`
+			Arg2 api.Passwords `json:"set"`
+			Arg3 []string      `json:"unset"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
 		} else {
 			err = json.Unmarshal(params, &args)
 		}
@@ -183,7 +383,7 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		if err != nil {
 			return nil, err
 		}
-		return wrap.Update(ctx, args.Arg0, args.Arg1, args.Arg2)
+		return wrap.SetBasicAuth(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
 	})
 
 	router.RegisterFunc("LambdaAPI.CreateFile", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
@@ -276,6 +476,52 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		return wrap.Stats(ctx, args.Arg0, args.Arg1, args.Arg2)
 	})
 
+	router.RegisterFunc("LambdaAPI.StatsRange", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token  `json:"token"`
+			Arg1 string      `json:"uid"`
+			Arg2 stats.Query `json:"query"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.StatsRange(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.StatsAggregate", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 string             `json:"uid"`
+			Arg2 stats.Query        `json:"query"`
+			Arg3 types.JsonDuration `json:"bucket"`
+			Arg4 string             `json:"group"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3, &args.Arg4)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.StatsAggregate(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3, args.Arg4)
+	})
+
 	router.RegisterFunc("LambdaAPI.Actions", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -319,6 +565,29 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		return wrap.Invoke(ctx, args.Arg0, args.Arg1, args.Arg2)
 	})
 
+	router.RegisterFunc("LambdaAPI.InvokeAction", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 string             `json:"uid"`
+			Arg2 string             `json:"action"`
+			Arg3 types.JsonDuration `json:"timeLimit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.InvokeAction(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
 	router.RegisterFunc("LambdaAPI.Link", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -362,5 +631,434 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		return wrap.Unlink(ctx, args.Arg0, args.Arg1)
 	})
 
-	return []string{"LambdaAPI.Upload", "LambdaAPI.Download", "LambdaAPI.Push", "LambdaAPI.Pull", "LambdaAPI.Remove", "LambdaAPI.Files", "LambdaAPI.Info", "LambdaAPI.Update", "LambdaAPI.CreateFile", "LambdaAPI.RemoveFile", "LambdaAPI.RenameFile", "LambdaAPI.Stats", "LambdaAPI.Actions", "LambdaAPI.Invoke", "LambdaAPI.Link", "LambdaAPI.Unlink"}
+	router.RegisterFunc("LambdaAPI.Doctor", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Doctor(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.Requests", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Requests(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.CapturedRequest", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.CapturedRequest(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.Replay", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Replay(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.SetEnabled", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 bool       `json:"enabled"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.SetEnabled(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.RegenerateSlug", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.RegenerateSlug(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.ResetAlerts", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"rule"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.ResetAlerts(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.GitDeploy", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 bool       `json:"force"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.GitDeploy(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.GrantExport", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 bool       `json:"secrets"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.GrantExport(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.Export", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 string `json:"grant"`
+			Arg1 string `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Export(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.Freeze", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Freeze(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.FrozenArtifacts", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.FrozenArtifacts(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.DownloadFrozen", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.DownloadFrozen(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.Thaw", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Thaw(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.UploadVersion", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 []byte     `json:"archive"`
+			Arg3 string     `json:"message"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.UploadVersion(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("LambdaAPI.Versions", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Versions(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.Rollback", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token                  `json:"token"`
+			Arg1 string                      `json:"uid"`
+			Arg2 application.VersionRollback `json:"rollback"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Rollback(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.LogFiles", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.LogFiles(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("LambdaAPI.DownloadLog", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.DownloadLog(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.TailLog", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 int        `json:"lines"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.TailLog(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	return []string{"LambdaAPI.Upload", "LambdaAPI.Download", "LambdaAPI.UploadBundle", "LambdaAPI.ContentHash", "LambdaAPI.UploadVerified", "LambdaAPI.SignedContentHash", "LambdaAPI.Push", "LambdaAPI.Pull", "LambdaAPI.Remove", "LambdaAPI.Files", "LambdaAPI.Info", "LambdaAPI.Update", "LambdaAPI.BulkUpdate", "LambdaAPI.Environment", "LambdaAPI.SetEnvironment", "LambdaAPI.MergedEnvironment", "LambdaAPI.SetBasicAuth", "LambdaAPI.CreateFile", "LambdaAPI.RemoveFile", "LambdaAPI.RenameFile", "LambdaAPI.Stats", "LambdaAPI.StatsRange", "LambdaAPI.StatsAggregate", "LambdaAPI.Actions", "LambdaAPI.Invoke", "LambdaAPI.InvokeAction", "LambdaAPI.Link", "LambdaAPI.Unlink", "LambdaAPI.Doctor", "LambdaAPI.Requests", "LambdaAPI.CapturedRequest", "LambdaAPI.Replay", "LambdaAPI.SetEnabled", "LambdaAPI.RegenerateSlug", "LambdaAPI.ResetAlerts", "LambdaAPI.GitDeploy", "LambdaAPI.GrantExport", "LambdaAPI.Export", "LambdaAPI.Freeze", "LambdaAPI.FrozenArtifacts", "LambdaAPI.DownloadFrozen", "LambdaAPI.Thaw", "LambdaAPI.UploadVersion", "LambdaAPI.Versions", "LambdaAPI.Rollback", "LambdaAPI.LogFiles", "LambdaAPI.DownloadLog", "LambdaAPI.TailLog"}
 }
@@ -6,6 +6,10 @@ import (
 	"encoding/json"
 	jsonrpc2 "github.com/reddec/jsonrpc2"
 	api "github.com/reddec/trusted-cgi/api"
+	application "github.com/reddec/trusted-cgi/application"
+	stats "github.com/reddec/trusted-cgi/stats"
+	types "github.com/reddec/trusted-cgi/types"
+	"time"
 )
 
 func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandler interface {
@@ -73,6 +77,28 @@ func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandle
 		return wrap.SetEnvironment(ctx, args.Arg0, args.Arg1)
 	})
 
+	router.RegisterFunc("ProjectAPI.UpdateEnvironment", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token      `json:"token"`
+			Arg1 api.Environment `json:"set"`
+			Arg2 []string        `json:"unset"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.UpdateEnvironment(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
 	router.RegisterFunc("ProjectAPI.AllTemplates", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -154,6 +180,91 @@ func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandle
 		return wrap.Stats(ctx, args.Arg0, args.Arg1)
 	})
 
+	router.RegisterFunc("ProjectAPI.StatsRange", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token  `json:"token"`
+			Arg1 stats.Query `json:"query"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.StatsRange(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.StatsAggregate", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 stats.Query        `json:"query"`
+			Arg2 types.JsonDuration `json:"bucket"`
+			Arg3 string             `json:"group"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.StatsAggregate(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("ProjectAPI.StatsUsage", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.StatsUsage(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.SetStatsRetention", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token      `json:"token"`
+			Arg1 stats.Retention `json:"retention"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.SetStatsRetention(ctx, args.Arg0, args.Arg1)
+	})
+
 	router.RegisterFunc("ProjectAPI.Create", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -216,5 +327,471 @@ func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandle
 		return wrap.CreateFromGit(ctx, args.Arg0, args.Arg1)
 	})
 
-	return []string{"ProjectAPI.Config", "ProjectAPI.SetUser", "ProjectAPI.SetEnvironment", "ProjectAPI.AllTemplates", "ProjectAPI.List", "ProjectAPI.Templates", "ProjectAPI.Stats", "ProjectAPI.Create", "ProjectAPI.CreateFromTemplate", "ProjectAPI.CreateFromGit"}
+	router.RegisterFunc("ProjectAPI.CreateWithOptions", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token        `json:"token"`
+			Arg1 api.CreateOptions `json:"options"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.CreateWithOptions(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Duplicate", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token                   `json:"token"`
+			Arg1 string                       `json:"uid"`
+			Arg2 application.DuplicateOptions `json:"options"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Duplicate(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("ProjectAPI.Capabilities", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Capabilities(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.Capacity", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 types.JsonDuration `json:"window"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Capacity(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Mirror", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Mirror(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.Promote", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Promote(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.Changes", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 time.Time  `json:"since"`
+			Arg2 time.Time  `json:"until"`
+			Arg3 int        `json:"offset"`
+			Arg4 int        `json:"limit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3, &args.Arg4)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Changes(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3, args.Arg4)
+	})
+
+	router.RegisterFunc("ProjectAPI.Audit", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token              `json:"token"`
+			Arg1 application.ChangeQuery `json:"query"`
+			Arg2 int                     `json:"offset"`
+			Arg3 int                     `json:"limit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Audit(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("ProjectAPI.Security", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Security(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.Routes", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Routes(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.OpenAPI", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"lambda"`
+			Arg2 string     `json:"publicURL"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.OpenAPI(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("ProjectAPI.Search", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"query"`
+			Arg2 int        `json:"limit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Search(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("ProjectAPI.Transfer", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token          `json:"token"`
+			Arg1 api.TransferRequest `json:"request"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Transfer(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.TransferProgress", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.TransferProgress(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Secrets", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Secrets(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("ProjectAPI.SetSecret", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 string     `json:"value"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.SetSecret(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("ProjectAPI.RemoveSecret", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.RemoveSecret(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.WebhookDeliveries", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 int        `json:"limit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.WebhookDeliveries(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Backup", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token                `json:"token"`
+			Arg1 application.BackupOptions `json:"options"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Backup(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Restore", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token                 `json:"token"`
+			Arg1 []byte                     `json:"archive"`
+			Arg2 application.RestoreOptions `json:"options"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Restore(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("ProjectAPI.ImportURL", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token                 `json:"token"`
+			Arg1 application.ArtifactImport `json:"request"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.ImportURL(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Reload", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Reload(ctx, args.Arg0, args.Arg1)
+	})
+
+	return []string{"ProjectAPI.Config", "ProjectAPI.SetUser", "ProjectAPI.SetEnvironment", "ProjectAPI.UpdateEnvironment", "ProjectAPI.AllTemplates", "ProjectAPI.List", "ProjectAPI.Templates", "ProjectAPI.Stats", "ProjectAPI.StatsRange", "ProjectAPI.StatsAggregate", "ProjectAPI.StatsUsage", "ProjectAPI.SetStatsRetention", "ProjectAPI.Create", "ProjectAPI.CreateFromTemplate", "ProjectAPI.CreateFromGit", "ProjectAPI.CreateWithOptions", "ProjectAPI.Duplicate", "ProjectAPI.Capabilities", "ProjectAPI.Capacity", "ProjectAPI.Mirror", "ProjectAPI.Promote", "ProjectAPI.Changes", "ProjectAPI.Audit", "ProjectAPI.Security", "ProjectAPI.Routes", "ProjectAPI.OpenAPI", "ProjectAPI.Search", "ProjectAPI.Transfer", "ProjectAPI.TransferProgress", "ProjectAPI.Secrets", "ProjectAPI.SetSecret", "ProjectAPI.RemoveSecret", "ProjectAPI.WebhookDeliveries", "ProjectAPI.Backup", "ProjectAPI.Restore", "ProjectAPI.ImportURL", "ProjectAPI.Reload"}
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	jsonrpc2 "github.com/reddec/jsonrpc2"
 	api "github.com/reddec/trusted-cgi/api"
+	types "github.com/reddec/trusted-cgi/types"
 )
 
 func RegisterUserAPI(router *jsonrpc2.Router, wrap api.UserAPI, typeHandler interface {
@@ -50,5 +51,132 @@ func RegisterUserAPI(router *jsonrpc2.Router, wrap api.UserAPI, typeHandler inte
 		return wrap.ChangePassword(ctx, args.Arg0, args.Arg1)
 	})
 
-	return []string{"UserAPI.Login", "UserAPI.ChangePassword"}
+	router.RegisterFunc("UserAPI.CreateToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 string             `json:"name"`
+			Arg2 api.Scope          `json:"scope"`
+			Arg3 types.JsonDuration `json:"ttl"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.CreateToken(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("UserAPI.RotateToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 string             `json:"id"`
+			Arg2 types.JsonDuration `json:"overlap"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.RotateToken(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("UserAPI.Tokens", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Tokens(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("UserAPI.RevokeToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.RevokeToken(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("UserAPI.Lockouts", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Lockouts(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("UserAPI.Unlock", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"subject"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Unlock(ctx, args.Arg0, args.Arg1)
+	})
+
+	return []string{"UserAPI.Login", "UserAPI.ChangePassword", "UserAPI.CreateToken", "UserAPI.RotateToken", "UserAPI.Tokens", "UserAPI.RevokeToken", "UserAPI.Lockouts", "UserAPI.Unlock"}
 }
@@ -4,14 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/openapi"
 	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/types"
+	"time"
 )
 
 // JWT wrapper , should be unmarshalled from string
 type Token struct {
 	Login string `json:"-"` // parsed by validator
-	Data  string `json:"-"` // raw JWT
+	Data  string `json:"-"` // raw JWT or API token (see UserAPI.CreateToken)
+	Scope *Scope `json:"-"` // parsed by validator, nil - full access
 }
 
 func (t *Token) UnmarshalJSON(bytes []byte) error {
@@ -34,21 +37,195 @@ type TemplateStatus struct {
 }
 
 type Settings struct {
-	User        string            `json:"user"`                  // effective user (user for run apps)
-	PublicKey   string            `json:"public_key,omitempty"`  // optional public RSA key for SSH
-	Environment map[string]string `json:"environment,omitempty"` // global environment
+	User        string                   `json:"user"`                  // effective user (user for run apps)
+	PublicKey   string                   `json:"public_key,omitempty"`  // optional public RSA key for SSH
+	Environment map[string]string        `json:"environment,omitempty"` // global environment
+	Runtime     types.Runtime            `json:"runtime"`               // default umask and locale for lambdas
+	Builds      application.BuildsConfig `json:"builds"`                // default limits and concurrency of actions
+	AutoSlug    bool                     `json:"auto_slug"`             // slug alias is generated from name for new apps
 }
 
+// Options of app creation
+type CreateOptions struct {
+	Template string `json:"template,omitempty"` // name of template (empty - default manifest)
+	Name     string `json:"name,omitempty"`     // name of app (overrides name of template)
+	Slug     *bool  `json:"slug,omitempty"`     // generate slug alias from name (null - server default)
+	// public base URL of server seen by client to resolve outputs of template (ignored if server has configured one)
+	PublicURL string `json:"public_url,omitempty"`
+}
+
+// Server information: build version, enabled capabilities and effective configuration (secrets redacted)
+type ServerInfo struct {
+	Version      string              `json:"version"`
+	Capabilities []string            `json:"capabilities"`
+	Config       []ConfigValue       `json:"config"`
+	Upload       *types.UploadLimits `json:"upload,omitempty"` // effective limits of uploaded content
+}
+
+// Effective configuration value with provenance
+type ConfigValue struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // flag, env, file:<path>, option or default
+}
+
+// Result of action invocation
+type ActionResult struct {
+	Output   string             `json:"output"`           // combined stdout and stderr
+	Stderr   string             `json:"stderr,omitempty"` // tail of stderr (not more than application.StderrTail bytes)
+	ExitCode int                `json:"exit_code"`        // exit code of make, -1 if action was not finished (killed, timeout)
+	Error    string             `json:"error,omitempty"`  // invocation error (empty for successful invocation)
+	Duration types.JsonDuration `json:"duration"`
+	// position in queue of builds when action was submitted (zero - started immediately)
+	QueuePosition int                `json:"queue_position,omitempty"`
+	Queued        types.JsonDuration `json:"queued,omitempty"`         // time spent in queue of builds (included in duration)
+	LimitExceeded string             `json:"limit_exceeded,omitempty"` // memory or time if action was killed by build limit
+}
+
+// API token (see UserAPI.CreateToken)
+type TokenInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Scope   Scope     `json:"scope"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"` // zero - never expires
+	// ID of token which replaced the token by rotation (see UserAPI.RotateToken)
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	Token      string `json:"token,omitempty"` // value of token, only on creation
+}
+
+// Tracked failed logins of account or client address (see UserAPI.Lockouts)
+type Lockout struct {
+	Subject     string    `json:"subject"`                // login or IP address of client
+	Kind        string    `json:"kind"`                   // login or address
+	Failures    int       `json:"failures"`               // failed logins in a row
+	LockedUntil time.Time `json:"locked_until,omitempty"` // zero - not locked out
+}
+
+// Result of replay of captured request (see LambdaAPI.Replay)
+type ReplayResult struct {
+	ID       string             `json:"id"`               // request ID of replay
+	Output   []byte             `json:"output"`           // response of lambda
+	Stderr   string             `json:"stderr,omitempty"` // tail of stderr (not more than application.StderrTail bytes)
+	Error    string             `json:"error,omitempty"`  // invocation error (empty for successful invocation)
+	Duration types.JsonDuration `json:"duration"`
+}
+
+// Content hash of lambda signed by server with session token (see Sign), so client could detect content modified
+// in transit and forged or replayed replies
+type ContentProof struct {
+	UID       string `json:"uid"`
+	Archive   string `json:"archive,omitempty"` // SHA-256 of received archive (hex), only for upload
+	Hash      string `json:"hash"`              // content hash of lambda after operation (see LambdaAPI.ContentHash)
+	Nonce     string `json:"nonce"`             // random value of client
+	Signature string `json:"signature"`         // HMAC-SHA256 of fields above by token (hex)
+}
+
+// Grant of export of app by another server (see LambdaAPI.GrantExport)
+type TransferGrant struct {
+	UID     string    `json:"uid"`
+	Token   string    `json:"token"`   // short-lived token of export limited to the app
+	Hash    string    `json:"hash"`    // SHA-256 of exported content archive (hex, see ArchiveHash)
+	Secrets bool      `json:"secrets"` // values of environment variables are exported
+	Expires time.Time `json:"expires"`
+}
+
+// Exported app (see LambdaAPI.Export)
+type LambdaExport struct {
+	UID      string         `json:"uid"`
+	Manifest types.Manifest `json:"manifest"` // values of environment variables are empty without granted secrets
+	Aliases  []string       `json:"aliases,omitempty"`
+	Content  []byte         `json:"content"` // .tar.gz archive of content without manifest files
+}
+
+// Request of transfer of app from another server (see ProjectAPI.Transfer)
+type TransferRequest struct {
+	ID     string        `json:"id,omitempty"` // chosen by client to track progress (empty - not tracked)
+	Source string        `json:"source"`       // base URL of source server reachable from destination
+	Grant  TransferGrant `json:"grant"`
+}
+
+// Result of transfer of app
+type TransferReport struct {
+	Definition *application.Definition `json:"definition"`
+	Skipped    []string                `json:"skipped,omitempty"` // aliases bound to other apps on destination
+}
+
+// Stages of transfer of app
+const (
+	TransferDownload = "download" // export is pulled from source
+	TransferVerify   = "verify"   // hash of content is checked
+	TransferImport   = "import"   // app is created
+	TransferDone     = "done"
+	TransferFailed   = "failed" // nothing is created, see error
+)
+
+// Progress of transfer
+type TransferProgress struct {
+	ID    string `json:"id"`
+	Stage string `json:"stage"`           // see Transfer* constants
+	Size  int64  `json:"size,omitempty"`  // size of received content archive
+	Error string `json:"error,omitempty"` // reason of failed transfer
+}
+
+// Environment variables: global or lambda
 type Environment struct {
-	Environment map[string]string `json:"environment,omitempty"` // global environment
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+// Users of basic authentication with plain passwords: passwords are hashed by server and never stored as is
+type Passwords struct {
+	Users map[string]string `json:"users,omitempty"` // username => password
+}
+
+// Bulk change of manifests of selected lambdas (see LambdaAPI.BulkUpdate): exactly one selector should be set
+type BulkUpdate struct {
+	Patch  json.RawMessage `json:"patch,omitempty"`   // JSON merge patch of manifest (RFC 7396, see types.PatchManifest)
+	Set    []string        `json:"set,omitempty"`     // assignments field=value applied after patch (see types.FieldPatch)
+	UIDs   []string        `json:"uids,omitempty"`    // selected lambdas by UID
+	Label  string          `json:"label,omitempty"`   // selected lambdas by label
+	All    bool            `json:"all,omitempty"`     // all lambdas
+	DryRun bool            `json:"dry_run,omitempty"` // report changes without apply
+}
+
+// Change of manifest field by bulk update, values are in JSON
+type FieldChange struct {
+	Field string `json:"field"`          // path to the field (ex: output_headers.X-Frame-Options)
+	From  string `json:"from,omitempty"` // old value (empty - added)
+	To    string `json:"to,omitempty"`   // new value (empty - removed)
+}
+
+// Result of bulk update for single lambda
+type BulkResult struct {
+	UID     string        `json:"uid"`
+	Name    string        `json:"name,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"` // changed fields of manifest (empty - manifest is not changed)
+	Applied bool          `json:"applied,omitempty"` // changed manifest is applied
+	Error   string        `json:"error,omitempty"`   // reason why manifest is not applied (ex: invalid manifest)
+}
+
+// Result of bulk update ordered by UID
+type BulkReport struct {
+	DryRun  bool         `json:"dry_run,omitempty"`
+	Results []BulkResult `json:"results"`
+	Failed  int          `json:"failed,omitempty"` // number of lambdas with errors
 }
 
 // API for lambdas
 type LambdaAPI interface {
-	// Upload content from .tar.gz archive to app and call Install handler (if defined)
-	Upload(ctx context.Context, token *Token, uid string, tarGz []byte) (bool, error)
+	// Upload content from .tar.gz or .zip archive (detected by content) to app and call Install handler (if defined).
+	// Unknown format is error with code 415
+	Upload(ctx context.Context, token *Token, uid string, archive []byte) (bool, error)
 	// Download content as .tar.gz archive from app
 	Download(ctx context.Context, token *Token, uid string) ([]byte, error)
+	// Upload content as single read-only .zip bundle to app and returns bundle hash
+	UploadBundle(ctx context.Context, token *Token, uid string, zip []byte) (string, error)
+	// Hash of app content (hash of bundle for bundled app)
+	ContentHash(ctx context.Context, token *Token, uid string) (string, error)
+	// Upload content as Upload does and return hash of received archive and content hash of app signed by token
+	UploadVerified(ctx context.Context, token *Token, uid string, archive []byte, nonce string) (*ContentProof, error)
+	// Hash of app content (as ContentHash) signed by token for spot checks
+	SignedContentHash(ctx context.Context, token *Token, uid string, nonce string) (*ContentProof, error)
 	// Push single file to app
 	Push(ctx context.Context, token *Token, uid string, file string, content []byte) (bool, error)
 	// Pull single file from app
@@ -59,8 +236,27 @@ type LambdaAPI interface {
 	Files(ctx context.Context, token *Token, uid string, dir string) ([]types.File, error)
 	// Info about application
 	Info(ctx context.Context, token *Token, uid string) (*application.Definition, error)
-	// Update application manifest
-	Update(ctx context.Context, token *Token, uid string, manifest types.Manifest) (*application.Definition, error)
+	// Update application manifest. Invalid manifest is error with code 422 and problems of fields
+	// (list of field and message) as data. Declared aliases are bound to the lambda, aliases removed from manifest
+	// are released. Aliases bound to other lambdas are error with code 409 and conflicts as data unless forceAliases
+	// is set (aliases are moved to the lambda)
+	Update(ctx context.Context, token *Token, uid string, manifest types.Manifest, forceAliases bool) (*application.Definition, error)
+	// Apply partial change of manifest (merge patch and assignments of fields) to selected lambdas. Every patched
+	// manifest is validated and applied the same way as by Update (aliases are not forced): failure of one lambda does
+	// not abort the batch and is reported in its result. Dry run reports changes without apply
+	BulkUpdate(ctx context.Context, token *Token, request BulkUpdate) (*BulkReport, error)
+	// Environment variables of application from manifest (as is, references are not resolved)
+	Environment(ctx context.Context, token *Token, uid string) (*Environment, error)
+	// Set and remove environment variables of application without changing the rest of manifest. Returns updated
+	// environment. Applied for the next invocation
+	SetEnvironment(ctx context.Context, token *Token, uid string, set Environment, unset []string) (*Environment, error)
+	// Variables of environment of invocations of application: runtime defaults and global environment of server merged
+	// with environment of manifest (manifest wins). References are resolved except references to secrets. Variables of
+	// server process and request are not included
+	MergedEnvironment(ctx context.Context, token *Token, uid string) ([]application.EnvValue, error)
+	// Set (passwords are hashed by server) and remove users of basic authentication of application without changing
+	// the rest of manifest. Authentication is removed with the last user. Returns updated usernames
+	SetBasicAuth(ctx context.Context, token *Token, uid string, set Passwords, unset []string) ([]string, error)
 	// Create file or directory inside app
 	CreateFile(ctx context.Context, token *Token, uid string, path string, dir bool) (bool, error)
 	// Remove file or directory
@@ -69,14 +265,72 @@ type LambdaAPI interface {
 	RenameFile(ctx context.Context, token *Token, uid string, oldPath, newPath string) (bool, error)
 	// Stats for the app
 	Stats(ctx context.Context, token *Token, uid string, limit int) ([]stats.Record, error)
-	// Actions available for the app
+	// Records of the app in time range from the newest (UID of query is replaced by UID of the app)
+	StatsRange(ctx context.Context, token *Token, uid string, query stats.Query) ([]stats.Record, error)
+	// Calls, errors by category and latency percentiles of the app by buckets of time range (UID of query is replaced
+	// by UID of the app, zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+	StatsAggregate(ctx context.Context, token *Token, uid string, query stats.Query, bucket types.JsonDuration, group string) (*stats.Aggregation, error)
+	// Actions available for the app: actions of manifest and targets of Makefile
 	Actions(ctx context.Context, token *Token, uid string) ([]string, error)
-	// Invoke action in the app (if make installed)
+	// Invoke action in the app (action of manifest or make target, if make installed)
 	Invoke(ctx context.Context, token *Token, uid string, action string) (string, error)
+	// Invoke action in the app with time limit (0 means no limit) and return output with exit code even for failed action
+	InvokeAction(ctx context.Context, token *Token, uid string, action string, timeLimit types.JsonDuration) (*ActionResult, error)
 	// Make link/alias for app
 	Link(ctx context.Context, token *Token, uid string, alias string) (*application.Definition, error)
 	// Remove link
 	Unlink(ctx context.Context, token *Token, alias string) (*application.Definition, error)
+	// Effective runtime settings (umask, locale, timezone) of the app
+	Doctor(ctx context.Context, token *Token, uid string) (*application.Diagnostic, error)
+	// Captured requests of the app from the newest without bodies (see types.Manifest.Capture)
+	Requests(ctx context.Context, token *Token, uid string) ([]application.CapturedRequest, error)
+	// Captured request of the app with body
+	CapturedRequest(ctx context.Context, token *Token, uid string, id string) (*application.CapturedRequest, error)
+	// Invoke the app by captured request again and return new result. Truncated requests can not be replayed
+	Replay(ctx context.Context, token *Token, uid string, id string) (*ReplayResult, error)
+	// Enable or disable the app without removing it. Disabled app responds 503 to public requests (by UID and
+	// aliases), its schedules are paused and queued requests are held till it is enabled. State survives restarts
+	SetEnabled(ctx context.Context, token *Token, uid string, enabled bool) (*application.Definition, error)
+	// Generate slug alias from name of the app (previous slug is kept as alias)
+	RegenerateSlug(ctx context.Context, token *Token, uid string) (*application.Definition, error)
+	// Reset fired alert rules of the app (all rules if rule is empty) and re-enable it
+	ResetAlerts(ctx context.Context, token *Token, uid string, rule string) ([]application.AlertStatus, error)
+	// Fetch tracked git branch of the app (see types.Manifest.Git) and deploy new commit (force - deploy even the same
+	// or failed commit). Failed deploy keeps the previous version and is returned as status with error. Error with code
+	// 404 if the app does not track git, 409 if deploy is in progress
+	GitDeploy(ctx context.Context, token *Token, uid string, force bool) (*application.GitStatus, error)
+	// Short-lived (5 minutes) grant of export of the app by another server (see Export and ProjectAPI.Transfer)
+	// with hash of exported content. Values of environment variables are exported only if secrets is set
+	GrantExport(ctx context.Context, token *Token, uid string, secrets bool) (*TransferGrant, error)
+	// Export the app by token of grant (see GrantExport) instead of login token: manifest, aliases and content
+	Export(ctx context.Context, grant string, uid string) (*LambdaExport, error)
+	// Freeze all files of the app, including dependencies installed by actions and files ignored by .cgiignore, to
+	// content-addressed artifact kept by server: freeze of the same files returns the same artifact. Old artifacts are
+	// pruned by retention of server
+	Freeze(ctx context.Context, token *Token, uid string) (*application.FrozenArtifact, error)
+	// Frozen artifacts of the app from the newest
+	FrozenArtifacts(ctx context.Context, token *Token, uid string) ([]application.FrozenArtifact, error)
+	// Download frozen artifact of the app (empty id - the newest) as .tar.gz archive. Unknown artifact is error with
+	// code 404
+	DownloadFrozen(ctx context.Context, token *Token, uid string, id string) ([]byte, error)
+	// Replace all files of the app by frozen artifact (empty id - the newest) without builds and network: manifest of
+	// artifact is applied. Unknown artifact is error with code 404
+	Thaw(ctx context.Context, token *Token, uid string, id string) (*application.FrozenArtifact, error)
+	// Upload content as Upload does and return version of the app annotated by message (upload of the same files
+	// returns the same version). Versions are also taken by every upload and update of manifest
+	UploadVersion(ctx context.Context, token *Token, uid string, archive []byte, message string) (*application.LambdaVersion, error)
+	// Versions of the app (files and manifest) from the newest. Old versions are pruned by retention of server
+	Versions(ctx context.Context, token *Token, uid string) ([]application.LambdaVersion, error)
+	// Restore files and manifest of version of the app (ID or unique prefix) and run build action (empty - build if
+	// defined by Makefile of version) before files are replaced: failed build keeps the app. Unknown version is error
+	// with code 404
+	Rollback(ctx context.Context, token *Token, uid string, rollback application.VersionRollback) (*application.RollbackReport, error)
+	// Log files of the app (full stdout and stderr of invocations if logs are enabled by manifest) from the oldest
+	LogFiles(ctx context.Context, token *Token, uid string) ([]application.LogFile, error)
+	// Download log file of the app by name (see LogFiles). Unknown file is error with code 404
+	DownloadLog(ctx context.Context, token *Token, uid string, name string) ([]byte, error)
+	// The last lines (zero - 100) of current log file of the app
+	TailLog(ctx context.Context, token *Token, uid string, lines int) (string, error)
 }
 
 // API for global project
@@ -87,6 +341,9 @@ type ProjectAPI interface {
 	SetUser(ctx context.Context, token *Token, user string) (*Settings, error)
 	// Change global environment
 	SetEnvironment(ctx context.Context, token *Token, env Environment) (*Settings, error)
+	// Set and remove variables of global environment without changing the rest. Returns updated global environment.
+	// Applied for the next invocation of every lambda
+	UpdateEnvironment(ctx context.Context, token *Token, set Environment, unset []string) (*Environment, error)
 	// Get all templates without filtering
 	AllTemplates(ctx context.Context, token *Token) ([]*TemplateStatus, error)
 	// List available apps (lambdas) in a project
@@ -95,12 +352,93 @@ type ProjectAPI interface {
 	Templates(ctx context.Context, token *Token) ([]*Template, error)
 	// Global last records
 	Stats(ctx context.Context, token *Token, limit int) ([]stats.Record, error)
+	// Global records in time range (optionally of one lambda) from the newest
+	StatsRange(ctx context.Context, token *Token, query stats.Query) ([]stats.Record, error)
+	// Global calls, errors by category and latency percentiles (optionally of one lambda) by buckets of time range
+	// (zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+	StatsAggregate(ctx context.Context, token *Token, query stats.Query, bucket types.JsonDuration, group string) (*stats.Aggregation, error)
+	// Usage of storage of stats: records, size, the oldest record, retention and the last pruning (behind - pruning
+	// doesn't keep up with limits)
+	StatsUsage(ctx context.Context, token *Token) (*stats.Usage, error)
+	// Change retention of stats without restart: server limits and limits of lambdas by UID (zero - not limited).
+	// Records over new limits are pruned in background
+	SetStatsRetention(ctx context.Context, token *Token, retention stats.Retention) (*stats.Usage, error)
 	// Create new app (lambda)
 	Create(ctx context.Context, token *Token) (*application.Definition, error)
-	// Create new app/lambda/function using pre-defined template
+	// Create new app/lambda/function using pre-defined template. Invalid manifest is error with code 422 (see Update)
+	// Resolved outputs of template (ex: example of call) are in outputs field
 	CreateFromTemplate(ctx context.Context, token *Token, templateName string) (*application.Definition, error)
-	// Create new app/lambda/function using remote Git repo
+	// Create new app/lambda/function using remote Git repo. Invalid manifest is error with code 422 (see Update)
 	CreateFromGit(ctx context.Context, token *Token, repo string) (*application.Definition, error)
+	// Create new app/lambda/function from template (empty - default manifest) with name and optional slug alias
+	// Resolved outputs of template are in outputs field
+	CreateWithOptions(ctx context.Context, token *Token, options CreateOptions) (*application.Definition, error)
+	// Duplicate lambda on server: files (streamed), manifest and environment are copied to new lambda without shared
+	// state, optionally without aliases, schedules or references to secrets. Aliases bound to other lambdas (ex: to the
+	// source) are not declared by copy: they are in warning of copy
+	Duplicate(ctx context.Context, token *Token, uid string, options application.DuplicateOptions) (*application.Definition, error)
+	// Server information: version, capabilities and effective configuration (same as `trusted-cgi info`)
+	Capabilities(ctx context.Context, token *Token) (*ServerInfo, error)
+	// Capacity report: usage of lambdas aggregated over window (zero - one hour) by stats records, disk usage, queue
+	// backlogs and naive projection of headroom
+	Capacity(ctx context.Context, token *Token, window types.JsonDuration) (*application.CapacityReport, error)
+	// Status of replication if server is read-only mirror of primary server (disabled status otherwise)
+	Mirror(ctx context.Context, token *Token) (*application.MirrorStatus, error)
+	// Promote read-only mirror to primary: replication is stopped and mutating API is enabled
+	Promote(ctx context.Context, token *Token) (*application.MirrorStatus, error)
+	// Administrative changes (deploys, manifest edits, aliases, policies, users and settings) in time range
+	// [since, until) (zero until - till now) grouped by lambda. Page is defined by offset and limit (zero - 100) of changes
+	Changes(ctx context.Context, token *Token, since, until time.Time, offset, limit int) (*application.ChangesReport, error)
+	// Audit log: changes and failed authentications matched by query (time range with zero until - till now, lambda
+	// by UID or alias, kind) in order of recording. Page is defined by offset and limit (zero - 100)
+	Audit(ctx context.Context, token *Token, query application.ChangeQuery, offset, limit int) (*application.ChangesPage, error)
+	// Active security profile and options of lambdas different from its defaults (including violations of mandatory
+	// rules by manifests uploaded before the profile)
+	Security(ctx context.Context, token *Token) (*application.SecurityReport, error)
+	// Canonical public invocation paths of lambdas (by UID, slug, aliases and linked queues) ordered by UID, for
+	// generation of reverse-proxy configuration
+	Routes(ctx context.Context, token *Token) ([]application.LambdaRoutes, error)
+	// OpenAPI 3.1 document of public routes of lambdas (by UID, slug and aliases) generated by current manifests:
+	// methods, content types and input schema of requests, output headers of responses. Lambda is UID of the only
+	// lambda in document (empty - all lambdas). Public URL is base URL of server seen by client (ignored if server has
+	// configured one, empty - document without servers)
+	OpenAPI(ctx context.Context, token *Token, lambda string, publicURL string) (*openapi.Document, error)
+	// Full-text search of lambdas by names, descriptions, aliases and labels: lambdas matching all words of query
+	// (word matches the same word or beginning of longer word) ordered by relevance with highlighted matched words.
+	// Number of hits is limited (zero - 20)
+	Search(ctx context.Context, token *Token, query string, limit int) (*application.SearchResult, error)
+	// Transfer app from another server: export is pulled from source by grant (see LambdaAPI.GrantExport) and content
+	// is verified by hash of grant. App keeps UID, manifest (including schedules) and aliases which are free, nothing
+	// is created on failure. Existent UID is error with code 409
+	Transfer(ctx context.Context, token *Token, request TransferRequest) (*TransferReport, error)
+	// Progress of running or recently finished transfer by ID of request
+	TransferProgress(ctx context.Context, token *Token, id string) (*TransferProgress, error)
+	// Secrets of server store ordered by name: metadata and lambdas which environment references them (values are
+	// never returned). Disabled store is error
+	Secrets(ctx context.Context, token *Token) ([]application.Secret, error)
+	// Set value of secret of server store (created if not exists). Value is write-only
+	SetSecret(ctx context.Context, token *Token, name string, value string) (*application.Secret, error)
+	// Remove secret of server store. Lambdas which still reference it are in result: their variables become empty
+	RemoveSecret(ctx context.Context, token *Token, name string) (*application.Secret, error)
+	// Recent attempts of deliveries of lifecycle events to webhooks of server and lambdas from the newest, including
+	// dropped events (zero attempt). Number of attempts is limited (zero - all kept attempts)
+	WebhookDeliveries(ctx context.Context, token *Token, limit int) ([]application.WebhookDelivery, error)
+	// Backup of server as .tar.gz archive: lambdas (content, manifests, aliases, state), policies, queues, API tokens
+	// (hashes of values, unless excluded), sealed secrets and settings
+	Backup(ctx context.Context, token *Token, options application.BackupOptions) ([]byte, error)
+	// Restore server from backup archive (see Backup): items which do not exist are created, existent items are
+	// conflicts handled by options (skip by default, fail is error with code 409 and conflicts in data). Dry run
+	// returns plan without changes
+	Restore(ctx context.Context, token *Token, archive []byte, options application.RestoreOptions) (*application.RestoreReport, error)
+	// Import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent lambda
+	// (by UID). Archive is extracted to staged copy, manifest of archive (or template.json) is applied and post-clone
+	// action is run there: failed import does not change lambda. Archive over upload limit is error with code 413,
+	// unknown format - 415, invalid manifest - 422
+	ImportURL(ctx context.Context, token *Token, request application.ArtifactImport) (*application.ArtifactReport, error)
+	// Reload files edited on disk out of server: project config, security profile, manifests and changed files of all
+	// lambdas (empty UID) or manifest and files of one lambda. Invalid files are not applied and reported in items,
+	// the last good state is kept. For setups without watcher of files
+	Reload(ctx context.Context, token *Token, uid string) (*application.ReloadReport, error)
 }
 
 // User/admin profile API
@@ -109,6 +447,21 @@ type UserAPI interface {
 	Login(ctx context.Context, login, password string) (*Token, error)
 	// Change password for the user
 	ChangePassword(ctx context.Context, token *Token, password string) (bool, error)
+	// Create long-lived API token limited by scope (lambdas and operations) with time to live (zero - never expires).
+	// Value of token is returned only once
+	CreateToken(ctx context.Context, token *Token, name string, scope Scope, ttl types.JsonDuration) (*TokenInfo, error)
+	// Issue replacement of API token with the same name, scope and time to live. Replaced token is valid for overlap
+	// (zero - one hour) to switch automation without downtime
+	RotateToken(ctx context.Context, token *Token, id string, overlap types.JsonDuration) (*TokenInfo, error)
+	// API tokens with scopes and expiration (without values). Tokens expired more than 7 days ago are removed
+	Tokens(ctx context.Context, token *Token) ([]TokenInfo, error)
+	// Revoke API token by ID
+	RevokeToken(ctx context.Context, token *Token, id string) (bool, error)
+	// Accounts and client addresses with failed logins, including locked out ones
+	Lockouts(ctx context.Context, token *Token) ([]Lockout, error)
+	// Clear lockout and failed logins of account or client address (empty - all), returns number of cleared subjects.
+	// Could be called by local admin socket without token (ex: admin account is locked out)
+	Unlock(ctx context.Context, token *Token, subject string) (int, error)
 }
 
 // API for managing queues
@@ -123,6 +476,29 @@ type QueuesAPI interface {
 	List(ctx context.Context, token *Token) ([]application.Queue, error)
 	// Assign lambda to queue (re-link)
 	Assign(ctx context.Context, token *Token, name string, lambda string) (bool, error)
+	// Oldest message of queue without consuming it: headers and chain of lambdas which produced message (body is
+	// not returned)
+	Inspect(ctx context.Context, token *Token, name string) (*application.QueueMessage, error)
+	// Return quarantined (corrupted) messages of queue to the end of queue, returns number of returned messages
+	RetryQuarantined(ctx context.Context, token *Token, name string) (int, error)
+	// Remove quarantined (corrupted) messages of queue, returns number of removed messages
+	PurgeQuarantined(ctx context.Context, token *Token, name string) (int, error)
+	// Pending asynchronous invocations (including in-flight) in order of acceptance, empty if asynchronous
+	// invocations are disabled
+	Async(ctx context.Context, token *Token) ([]application.AsyncInvocation, error)
+	// Remove pending asynchronous invocations by tickets (empty - all pending), in-flight invocations are not
+	// interrupted. Returns number of removed invocations
+	PurgeAsync(ctx context.Context, token *Token, tickets []string) (int, error)
+	// Dead letters of lambda (queued requests not processed after all attempts) from the newest, body is not
+	// returned
+	DeadLetters(ctx context.Context, token *Token, lambda string) ([]application.DeadLetter, error)
+	// Dead letter of lambda with body
+	DeadLetter(ctx context.Context, token *Token, lambda string, id string) (*application.DeadLetter, error)
+	// Remove dead letters of lambda by IDs (empty - all), returns number of removed letters
+	RemoveDeadLetters(ctx context.Context, token *Token, lambda string, ids []string) (int, error)
+	// Put dead letters of lambda by IDs (empty - all) back to their queues and remove them, returns number of
+	// returned letters
+	RedriveDeadLetters(ctx context.Context, token *Token, lambda string, ids []string) (int, error)
 }
 
 // API for managing policies
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Operations of scoped API tokens
+const (
+	OpUpload         = "upload"          // deploy and read content of lambda
+	OpInvoke         = "invoke"          // invoke actions and captured requests of lambda
+	OpReadStats      = "read-stats"      // stats, diagnostic and dead letters of lambda
+	OpManageSchedule = "manage-schedule" // change schedules (cron) in manifest of lambda
+	OpAdmin          = "admin"           // any operation including the operations above
+)
+
+// Operations of scoped API tokens in order of documentation
+var Operations = []string{OpUpload, OpInvoke, OpReadStats, OpManageSchedule, OpAdmin}
+
+// Scope of API token: lambdas and operations allowed by the token. Login tokens have no scope (full access)
+type Scope struct {
+	Lambdas    []string `json:"lambdas,omitempty"` // UIDs of allowed lambdas, empty - all lambdas
+	Operations []string `json:"operations"`        // allowed operations (see Operations)
+}
+
+// Error of operation not allowed by scope of token
+type ScopeError struct {
+	Operation string // missing operation, empty - lambda is not in scope
+	Lambda    string // UID of lambda, empty - operation over all lambdas
+}
+
+func (se *ScopeError) Error() string {
+	msg := "token has no scope"
+	if se.Operation != "" {
+		msg += " " + se.Operation
+	}
+	if se.Lambda == "" {
+		return msg + " for all lambdas"
+	}
+	return msg + " for lambda " + se.Lambda
+}
+
+// Normalize scope (sorted, without duplicates) and check operations
+func (s *Scope) Normalize() error {
+	if len(s.Operations) == 0 {
+		return errors.New("at least one operation should be allowed")
+	}
+	for _, op := range s.Operations {
+		if !knownOperation(op) {
+			return fmt.Errorf("unknown operation %q, expected one of %s", op, strings.Join(Operations, ", "))
+		}
+	}
+	for _, uid := range s.Lambdas {
+		if uid == "" {
+			return errors.New("empty UID of lambda")
+		}
+	}
+	s.Operations = unique(s.Operations)
+	s.Lambdas = unique(s.Lambdas)
+	return nil
+}
+
+// Allows checks that operation is allowed for lambda by UID (empty - for all lambdas). Empty operation is any
+// operation: lambda should be in scope
+func (s *Scope) Allows(op string, lambda string) error {
+	if !s.HasLambda(lambda) {
+		return &ScopeError{Operation: op, Lambda: lambda}
+	}
+	if op == "" {
+		return nil
+	}
+	for _, allowed := range s.Operations {
+		if allowed == op || allowed == OpAdmin {
+			return nil
+		}
+	}
+	return &ScopeError{Operation: op, Lambda: lambda}
+}
+
+// HasLambda checks that lambda by UID (empty - all lambdas) is in scope
+func (s *Scope) HasLambda(lambda string) bool {
+	if len(s.Lambdas) == 0 {
+		return true
+	}
+	if lambda == "" {
+		return false
+	}
+	for _, uid := range s.Lambdas {
+		if uid == lambda {
+			return true
+		}
+	}
+	return false
+}
+
+func knownOperation(op string) bool {
+	for _, known := range Operations {
+		if known == op {
+			return true
+		}
+	}
+	return false
+}
+
+func unique(values []string) []string {
+	var set = make(map[string]bool, len(values))
+	var ans = make([]string, 0, len(values))
+	for _, value := range values {
+		if !set[value] {
+			set[value] = true
+			ans = append(ans, value)
+		}
+	}
+	sort.Strings(ans)
+	return ans
+}
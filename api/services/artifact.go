@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var errArtifactsDisabled = errors.New("import of lambdas by URL is not available")
+
+// SetArtifacts enables import of lambdas from archives by URL (by default - disabled).
+func (srv *projectSrv) SetArtifacts(artifacts application.Artifacts) {
+	srv.artifacts = artifacts
+}
+
+func (srv *projectSrv) ImportURL(ctx context.Context, token *api.Token, request application.ArtifactImport) (*application.ArtifactReport, error) {
+	if srv.artifacts == nil {
+		return nil, errArtifactsDisabled
+	}
+	report, err := srv.artifacts.Import(ctx, request)
+	if errors.Is(err, application.ErrUnsupportedArchive) {
+		return nil, &jsonrpc2.Error{Code: 415, Message: err.Error()}
+	} else if errors.Is(err, types.ErrUploadLimit) {
+		return nil, &jsonrpc2.Error{Code: 413, Message: err.Error()}
+	} else if errors.Is(err, application.ErrDiskQuota) {
+		return nil, &jsonrpc2.Error{Code: 507, Message: err.Error()}
+	} else if err != nil {
+		return nil, validationError(err)
+	}
+	var def *application.Definition
+	if report.Created {
+		def, err = srv.created(ctx, token, report.UID, nil, nil, "", "lambda imported from "+request.URL)
+	} else if def, err = srv.cases.Platform().FindByUID(report.UID); err == nil {
+		srv.hooks.Deployed(ctx, application.DeployEvent{UID: def.UID, Kind: application.DeployImport, Hash: report.SHA256})
+		record(srv.journal, token, lambdaChange(def, application.ChangeDeploy, "content imported from "+request.URL))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// startup action outlives the request
+	srv.cases.Platform().Start(context.Background(), def.Lambda)
+	return report, nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+var errBackupsDisabled = errors.New("backup of server is not available")
+
+// SetBackups enables backup and restore of server (by default - disabled).
+func (srv *projectSrv) SetBackups(backups application.Backups) {
+	srv.backups = backups
+}
+
+func (srv *projectSrv) Backup(ctx context.Context, token *api.Token, options application.BackupOptions) ([]byte, error) {
+	if srv.backups == nil {
+		return nil, errBackupsDisabled
+	}
+	var archive bytes.Buffer
+	if err := srv.backups.Backup(&archive, options); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	summary := "backup of server created"
+	if options.NoTokens {
+		summary += " without tokens"
+	}
+	if options.Frozen {
+		summary += " with frozen artifacts"
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeTransfer, Summary: summary})
+	return archive.Bytes(), nil
+}
+
+func (srv *projectSrv) Restore(ctx context.Context, token *api.Token, archive []byte, options application.RestoreOptions) (*application.RestoreReport, error) {
+	if srv.backups == nil {
+		return nil, errBackupsDisabled
+	}
+	report, err := srv.backups.Restore(ctx, bytes.NewReader(archive), options)
+	if errors.Is(err, application.ErrRestoreConflict) {
+		return nil, &jsonrpc2.Error{Code: 409, Message: err.Error(), Data: report.Conflicts()}
+	}
+	if report == nil || report.DryRun {
+		return report, err
+	}
+	var restored int
+	for _, item := range report.Items {
+		if item.Kind != application.RestoreLambda || item.Action == application.RestoreSkip {
+			continue
+		}
+		def, err := srv.cases.Platform().FindByUID(item.Target)
+		if err != nil {
+			continue
+		}
+		restored++
+		srv.hooks.Deployed(ctx, application.DeployEvent{UID: def.UID, Kind: application.DeployCreate})
+		record(srv.journal, token, lambdaChange(def, application.ChangeCreate, "lambda restored from backup"))
+		// startup action outlives the request
+		srv.cases.Platform().Start(context.Background(), def.Lambda)
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeTransfer, Summary: fmt.Sprintf("server restored from backup of %s: %d lambdas", report.Header.Created.Format("2006-01-02 15:04:05"), restored)})
+	return report, err
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// maximum number of lambdas updated in parallel by bulk update
+const bulkWorkers = 4
+
+func (srv *lambdaSrv) BulkUpdate(ctx context.Context, token *api.Token, request api.BulkUpdate) (*api.BulkReport, error) {
+	var patches []json.RawMessage
+	if len(request.Patch) > 0 {
+		patches = append(patches, request.Patch)
+	}
+	if len(request.Set) > 0 {
+		patch, err := types.FieldPatch(request.Set)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("patch or assignments of fields should be set")
+	}
+	// malformed patch (ex: unknown field) is the same for all lambdas
+	for _, patch := range patches {
+		if _, err := types.PatchManifest(types.Manifest{}, patch); err != nil {
+			return nil, err
+		}
+	}
+	uids, err := srv.bulkSelection(request)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &api.BulkReport{DryRun: request.DryRun, Results: make([]api.BulkResult, len(uids))}
+	var wg sync.WaitGroup
+	var slots = make(chan struct{}, bulkWorkers)
+	for i, uid := range uids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(result *api.BulkResult, uid string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			*result = srv.bulkApply(ctx, token, uid, patches, request.DryRun)
+		}(&report.Results[i], uid)
+	}
+	wg.Wait()
+	for _, result := range report.Results {
+		if result.Error != "" {
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// UIDs of lambdas selected by exactly one selector of request, ordered
+func (srv *lambdaSrv) bulkSelection(request api.BulkUpdate) ([]string, error) {
+	var selectors int
+	for _, set := range []bool{len(request.UIDs) > 0, request.Label != "", request.All} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return nil, errors.New("exactly one of UIDs, label or all should be set")
+	}
+	var uids []string
+	if len(request.UIDs) > 0 {
+		var seen = make(map[string]bool)
+		for _, uid := range request.UIDs {
+			if !seen[uid] {
+				seen[uid] = true
+				uids = append(uids, uid)
+			}
+		}
+	} else {
+		for _, def := range srv.cases.Platform().List() {
+			if request.All || hasLabel(def.Manifest.Labels, request.Label) {
+				uids = append(uids, def.UID)
+			}
+		}
+	}
+	sort.Strings(uids)
+	return uids, nil
+}
+
+func (srv *lambdaSrv) bulkApply(ctx context.Context, token *api.Token, uid string, patches []json.RawMessage, dryRun bool) api.BulkResult {
+	result := api.BulkResult{UID: uid}
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	previous := fn.Lambda.Manifest()
+	result.Name = previous.Name
+	manifest := previous
+	for _, patch := range patches {
+		if manifest, err = types.PatchManifest(manifest, patch); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	changes, err := types.DiffManifest(previous, manifest)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, change := range changes {
+		result.Changes = append(result.Changes, api.FieldChange{Field: change.Field, From: jsonValue(change.From), To: jsonValue(change.To)})
+	}
+	if len(changes) == 0 {
+		return result
+	}
+	if dryRun {
+		if err := manifest.Validate(); err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	if _, err := srv.update(ctx, token, fn, manifest, false); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Applied = true
+	return result
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, item := range labels {
+		if item == label {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON of value of field (empty - absent field)
+func jsonValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+var errFreezerDisabled = errors.New("frozen artifacts are not available")
+
+// SetFreezer enables frozen artifacts of lambdas (by default - disabled).
+func (srv *lambdaSrv) SetFreezer(freezer application.Freezer) {
+	srv.freezer = freezer
+}
+
+func (srv *lambdaSrv) Freeze(ctx context.Context, token *api.Token, uid string) (*application.FrozenArtifact, error) {
+	if srv.freezer == nil {
+		return nil, errFreezerDisabled
+	}
+	return srv.freezer.Freeze(uid)
+}
+
+func (srv *lambdaSrv) FrozenArtifacts(ctx context.Context, token *api.Token, uid string) ([]application.FrozenArtifact, error) {
+	if srv.freezer == nil {
+		return nil, errFreezerDisabled
+	}
+	if _, err := srv.cases.Platform().FindByUID(uid); err != nil {
+		return nil, err
+	}
+	return srv.freezer.Artifacts(uid), nil
+}
+
+func (srv *lambdaSrv) DownloadFrozen(ctx context.Context, token *api.Token, uid string, id string) ([]byte, error) {
+	if srv.freezer == nil {
+		return nil, errFreezerDisabled
+	}
+	var archive bytes.Buffer
+	if err := srv.freezer.Download(uid, id, &archive); err != nil {
+		return nil, frozenError(err)
+	}
+	return archive.Bytes(), nil
+}
+
+func (srv *lambdaSrv) Thaw(ctx context.Context, token *api.Token, uid string, id string) (*application.FrozenArtifact, error) {
+	if srv.freezer == nil {
+		return nil, errFreezerDisabled
+	}
+	artifact, err := srv.freezer.Thaw(uid, id)
+	if err != nil {
+		return nil, frozenError(err)
+	}
+	def, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	srv.hooks.Deployed(ctx, application.DeployEvent{UID: uid, Kind: application.DeployThaw, Hash: artifact.ID})
+	record(srv.journal, token, lambdaChange(def, application.ChangeDeploy, "files restored from frozen artifact "+artifact.ID))
+	// startup action outlives the request
+	srv.cases.Platform().Start(context.Background(), def.Lambda)
+	return artifact, nil
+}
+
+func frozenError(err error) error {
+	if errors.Is(err, application.ErrFrozenNotFound) {
+		return &jsonrpc2.Error{Code: 404, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// record change in journal (if enabled) on behalf of user of token
+func record(journal application.Journal, token *api.Token, change application.Change) {
+	if journal == nil {
+		return
+	}
+	if token != nil {
+		change.Actor = token.Login
+	}
+	journal.Record(change)
+}
+
+// change of lambda with the current name
+func lambdaChange(def *application.Definition, kind string, summary string) application.Change {
+	return application.Change{
+		Kind:    kind,
+		Lambda:  def.UID,
+		Name:    def.Manifest.Name,
+		Summary: summary,
+	}
+}
@@ -3,35 +3,108 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/reddec/jsonrpc2"
 
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/journal"
 	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/types"
 )
 
-func NewLambdaSrv(cases application.Cases, tracker stats.Reader) *lambdaSrv {
+func NewLambdaSrv(cases application.Cases, tracker stats.Reader, alerts application.Alerts, hooks *application.Hooks, journal application.Journal) *lambdaSrv {
 	return &lambdaSrv{
-		cases:   cases,
-		tracker: tracker,
+		cases:       cases,
+		tracker:     tracker,
+		alerts:      alerts,
+		hooks:       hooks,
+		journal:     journal,
+		grantSecret: []byte(uuid.New().String()),
 	}
 }
 
+// SetGitDeploys enables deploys of lambdas from git (by default - lambdas are not deployed from git).
+func (srv *lambdaSrv) SetGitDeploys(deploys application.GitDeploys) {
+	srv.deploys = deploys
+}
+
 type lambdaSrv struct {
-	cases   application.Cases
-	tracker stats.Reader
+	cases    application.Cases
+	tracker  stats.Reader
+	alerts   application.Alerts     // optional
+	hooks    *application.Hooks     // optional lifecycle hooks
+	journal  application.Journal    // optional journal of changes
+	deploys  application.GitDeploys // optional deploys from git
+	freezer  application.Freezer    // optional frozen artifacts
+	versions application.Versions   // optional versions of lambdas
+	envLock  sync.Mutex             // serializes changes of environment and users of basic auth
+	// secret of grants of export (see GrantExport): grants are not valid after restart
+	grantSecret []byte
+}
+
+func (srv *lambdaSrv) Upload(ctx context.Context, token *api.Token, uid string, archive []byte) (bool, error) {
+	_, err := srv.upload(ctx, token, uid, archive, false)
+	return err == nil, err
+}
+
+func (srv *lambdaSrv) UploadVerified(ctx context.Context, token *api.Token, uid string, archive []byte, nonce string) (*api.ContentProof, error) {
+	if nonce == "" {
+		return nil, fmt.Errorf("nonce is required")
+	}
+	hash, err := srv.upload(ctx, token, uid, archive, true)
+	if err != nil {
+		return nil, err
+	}
+	proof := &api.ContentProof{UID: uid, Archive: api.ArchiveHash(archive), Hash: hash, Nonce: nonce}
+	proof.Sign(token)
+	return proof, nil
 }
 
-func (srv *lambdaSrv) Upload(ctx context.Context, token *api.Token, uid string, tarGz []byte) (bool, error) {
+// set content of lambda from archive and start it. Content hash (if requested) is computed before startup action,
+// which could change files
+func (srv *lambdaSrv) upload(ctx context.Context, token *api.Token, uid string, archive []byte, hashed bool) (string, error) {
 	fn, err := srv.cases.Platform().FindByUID(uid)
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	err = fn.Lambda.SetContent(bytes.NewReader(tarGz))
+	previous := fn.Lambda.Manifest()
+	err = fn.Lambda.SetContent(bytes.NewReader(archive))
+	if errors.Is(err, application.ErrUnsupportedArchive) {
+		return "", &jsonrpc2.Error{Code: 415, Message: err.Error()}
+	} else if errors.Is(err, types.ErrUploadLimit) {
+		return "", &jsonrpc2.Error{Code: 413, Message: err.Error()}
+	} else if errors.Is(err, application.ErrDiskQuota) {
+		return "", &jsonrpc2.Error{Code: 507, Message: err.Error()}
+	} else if err != nil {
+		return "", err
+	}
+	srv.hooks.Deployed(ctx, application.DeployEvent{UID: uid, Kind: application.DeployUpload})
+	record(srv.journal, token, lambdaChange(fn, application.ChangeDeploy, "content uploaded"))
+	bound, err := srv.cases.Platform().BindAliases(uid, previous.Aliases, fn.Lambda.Manifest().Aliases, false)
 	if err != nil {
-		return false, err
+		return "", validationError(fmt.Errorf("content uploaded, aliases are not bound: %w", err))
 	}
-	return true, nil
+	srv.recordAliases(token, bound, fn.Aliases, previous.Aliases, fn.Lambda.Manifest().Aliases)
+	var hash string
+	if hashed {
+		hash, err = fn.Lambda.ContentHash()
+		if err != nil {
+			return "", fmt.Errorf("content uploaded, hash content: %w", err)
+		}
+	}
+	srv.snapshot(token, uid)
+	// startup action outlives the request
+	srv.cases.Platform().Start(context.Background(), fn.Lambda)
+	return hash, nil
 }
 
 func (srv *lambdaSrv) Download(ctx context.Context, token *api.Token, uid string) ([]byte, error) {
@@ -44,13 +117,54 @@ func (srv *lambdaSrv) Download(ctx context.Context, token *api.Token, uid string
 	return out.Bytes(), err
 }
 
+func (srv *lambdaSrv) UploadBundle(ctx context.Context, token *api.Token, uid string, zip []byte) (string, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return "", err
+	}
+	hash, err := fn.Lambda.SetBundle(bytes.NewReader(zip))
+	if err != nil {
+		return "", err
+	}
+	srv.hooks.Deployed(ctx, application.DeployEvent{UID: uid, Kind: application.DeployBundle, Hash: hash})
+	record(srv.journal, token, lambdaChange(fn, application.ChangeDeploy, "bundle "+hash+" uploaded"))
+	srv.snapshot(token, uid)
+	srv.cases.Platform().Start(context.Background(), fn.Lambda)
+	return hash, nil
+}
+
+func (srv *lambdaSrv) ContentHash(ctx context.Context, token *api.Token, uid string) (string, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return "", err
+	}
+	return fn.Lambda.ContentHash()
+}
+
+func (srv *lambdaSrv) SignedContentHash(ctx context.Context, token *api.Token, uid string, nonce string) (*api.ContentProof, error) {
+	if nonce == "" {
+		return nil, fmt.Errorf("nonce is required")
+	}
+	hash, err := srv.ContentHash(ctx, token, uid)
+	if err != nil {
+		return nil, err
+	}
+	proof := &api.ContentProof{UID: uid, Hash: hash, Nonce: nonce}
+	proof.Sign(token)
+	return proof, nil
+}
+
 func (srv *lambdaSrv) Push(ctx context.Context, token *api.Token, uid string, file string, content []byte) (bool, error) {
 	fn, err := srv.cases.Platform().FindByUID(uid)
 	if err != nil {
 		return false, err
 	}
 	err = fn.Lambda.WriteFile(file, bytes.NewReader(content))
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, lambdaChange(fn, application.ChangeDeploy, "file "+file+" pushed"))
+	return true, nil
 }
 
 func (srv *lambdaSrv) Pull(ctx context.Context, token *api.Token, uid string, file string) ([]byte, error) {
@@ -64,8 +178,16 @@ func (srv *lambdaSrv) Pull(ctx context.Context, token *api.Token, uid string, fi
 }
 
 func (srv *lambdaSrv) Remove(ctx context.Context, token *api.Token, uid string) (bool, error) {
-	err := srv.cases.Remove(uid)
-	return err == nil, err
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return false, err
+	}
+	err = srv.cases.Remove(uid)
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, lambdaChange(fn, application.ChangeRemove, "lambda removed"))
+	return true, nil
 }
 
 func (srv *lambdaSrv) Files(ctx context.Context, token *api.Token, uid string, dir string) ([]types.File, error) {
@@ -81,23 +203,179 @@ func (srv *lambdaSrv) Info(ctx context.Context, token *api.Token, uid string) (*
 	if err != nil {
 		return nil, err
 	}
+	fn.Modified, err = fn.Lambda.Modified()
+	if err != nil {
+		return nil, fmt.Errorf("get modification time: %w", err)
+	}
+	fillLiveStatus(srv.cases, srv.alerts, fn)
+	if srv.deploys != nil {
+		fn.Git = srv.deploys.Status(uid)
+	}
 	return fn, nil
 }
 
-func (srv *lambdaSrv) Update(ctx context.Context, token *api.Token, uid string, manifest types.Manifest) (*application.Definition, error) {
+// fill live status of schedules, linked queues and alert rules (if alerts are enabled)
+func fillLiveStatus(cases application.Cases, alerts application.Alerts, def *application.Definition) {
+	def.Schedules = def.Lambda.Schedules()
+	if def.Disabled {
+		// schedules of disabled lambda are paused
+		for i := range def.Schedules {
+			def.Schedules[i].Enabled = false
+			def.Schedules[i].Next = time.Time{}
+		}
+	}
+	if alerts != nil {
+		def.Alerts = alerts.Status(def.UID)
+	}
+	if usage, err := def.Lambda.DiskUsage(); err == nil {
+		def.Disk = &usage
+	}
+	for _, q := range cases.Queues().Find(def.UID) {
+		status, err := cases.Queues().Status(q.Name)
+		if err != nil {
+			continue // removed concurrently
+		}
+		def.Queues = append(def.Queues, *status)
+	}
+}
+
+func (srv *lambdaSrv) Update(ctx context.Context, token *api.Token, uid string, manifest types.Manifest, forceAliases bool) (*application.Definition, error) {
 	fn, err := srv.cases.Platform().FindByUID(uid)
 	if err != nil {
 		return nil, err
 	}
+	return srv.update(ctx, token, fn, manifest, forceAliases)
+}
+
+// validate and apply manifest of lambda: bind declared aliases, notify hooks and record changes
+func (srv *lambdaSrv) update(ctx context.Context, token *api.Token, fn *application.Definition, manifest types.Manifest, forceAliases bool) (*application.Definition, error) {
+	uid := fn.UID
 	if err := manifest.Validate(); err != nil {
+		return nil, validationError(err)
+	}
+	if err := srv.cases.Platform().CheckChain(uid, manifest.Then); err != nil {
+		return nil, validationError(err)
+	}
+	if err := srv.cases.Migrated(uid); err != nil {
 		return nil, err
 	}
+	previous := fn.Lambda.Manifest()
+	bound, err := srv.cases.Platform().BindAliases(uid, previous.Aliases, manifest.Aliases, forceAliases)
+	if err != nil {
+		return nil, validationError(err)
+	}
 	err = fn.Lambda.SetManifest(manifest)
 	if err != nil {
+		// violation of security profile: aliases which were free before are restored
+		_, _ = srv.cases.Platform().BindAliases(uid, manifest.Aliases, previous.Aliases, false)
+		return nil, validationError(err)
+	}
+	srv.hooks.ManifestChanged(ctx, application.ManifestChange{UID: uid, Previous: previous, Current: manifest})
+	fn.Manifest = manifest
+	srv.recordManifest(token, fn, previous)
+	srv.recordAliases(token, bound, fn.Aliases, previous.Aliases, manifest.Aliases)
+	srv.snapshot(token, uid)
+	// renamed lambda keeps slug until it is regenerated
+	return srv.cases.Platform().FindByUID(uid)
+}
+
+func (srv *lambdaSrv) Environment(ctx context.Context, token *api.Token, uid string) (*api.Environment, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return &api.Environment{Environment: fn.Lambda.Manifest().Environment}, nil
+}
+
+func (srv *lambdaSrv) SetEnvironment(ctx context.Context, token *api.Token, uid string, set api.Environment, unset []string) (*api.Environment, error) {
+	srv.envLock.Lock()
+	defer srv.envLock.Unlock()
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.cases.Migrated(uid); err != nil {
+		return nil, err
+	}
+	manifest := fn.Lambda.Manifest()
+	var env = make(map[string]string, len(manifest.Environment)+len(set.Environment))
+	for k, v := range manifest.Environment {
+		env[k] = v
+	}
+	for k, v := range set.Environment {
+		env[k] = v
+	}
+	for _, k := range unset {
+		delete(env, k)
+	}
+	if err := types.ValidateEnvironment(env); err != nil {
 		return nil, err
 	}
+	previous := manifest
+	manifest.Environment = env
+	if err := fn.Lambda.SetManifest(manifest); err != nil {
+		return nil, err
+	}
+	srv.hooks.ManifestChanged(ctx, application.ManifestChange{UID: uid, Previous: previous, Current: manifest})
 	fn.Manifest = manifest
-	return fn, nil
+	srv.recordManifest(token, fn, previous)
+	return &api.Environment{Environment: env}, nil
+}
+
+func (srv *lambdaSrv) MergedEnvironment(ctx context.Context, token *api.Token, uid string) ([]application.EnvValue, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return srv.cases.Platform().MergedEnvironment(fn.Lambda), nil
+}
+
+func (srv *lambdaSrv) SetBasicAuth(ctx context.Context, token *api.Token, uid string, set api.Passwords, unset []string) ([]string, error) {
+	srv.envLock.Lock()
+	defer srv.envLock.Unlock()
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.cases.Migrated(uid); err != nil {
+		return nil, err
+	}
+	manifest := fn.Lambda.Manifest()
+	var auth types.BasicAuth
+	if manifest.BasicAuth != nil {
+		auth = *manifest.BasicAuth
+	}
+	var users = make(map[string]string, len(auth.Users)+len(set.Users))
+	for name, hash := range auth.Users {
+		users[name] = hash
+	}
+	for name, password := range set.Users {
+		hash, err := types.HashPassword(password)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", name, err)
+		}
+		users[name] = hash
+	}
+	for _, name := range unset {
+		delete(users, name)
+	}
+	auth.Users = users
+	previous := manifest
+	if len(users) == 0 {
+		manifest.BasicAuth = nil
+	} else {
+		manifest.BasicAuth = &auth
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	if err := fn.Lambda.SetManifest(manifest); err != nil {
+		return nil, err
+	}
+	srv.hooks.ManifestChanged(ctx, application.ManifestChange{UID: uid, Previous: previous, Current: manifest})
+	fn.Manifest = manifest
+	srv.recordManifest(token, fn, previous)
+	return auth.Usernames(), nil
 }
 
 func (srv *lambdaSrv) CreateFile(ctx context.Context, token *api.Token, uid string, path string, dir bool) (bool, error) {
@@ -110,7 +388,11 @@ func (srv *lambdaSrv) CreateFile(ctx context.Context, token *api.Token, uid stri
 	} else {
 		err = fn.Lambda.WriteFile(path, bytes.NewBufferString(""))
 	}
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, lambdaChange(fn, application.ChangeDeploy, "file "+path+" created"))
+	return true, nil
 }
 
 func (srv *lambdaSrv) RemoveFile(ctx context.Context, token *api.Token, uid string, path string) (bool, error) {
@@ -119,7 +401,11 @@ func (srv *lambdaSrv) RemoveFile(ctx context.Context, token *api.Token, uid stri
 		return false, err
 	}
 	err = fn.Lambda.RemoveFile(path)
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, lambdaChange(fn, application.ChangeDeploy, "file "+path+" removed"))
+	return true, nil
 }
 
 func (srv *lambdaSrv) RenameFile(ctx context.Context, token *api.Token, uid string, oldPath, newPath string) (bool, error) {
@@ -128,13 +414,27 @@ func (srv *lambdaSrv) RenameFile(ctx context.Context, token *api.Token, uid stri
 		return false, err
 	}
 	err = fn.Lambda.RenameFile(oldPath, newPath)
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, lambdaChange(fn, application.ChangeDeploy, "file "+oldPath+" renamed to "+newPath))
+	return true, nil
 }
 
 func (srv *lambdaSrv) Stats(ctx context.Context, token *api.Token, uid string, limit int) ([]stats.Record, error) {
 	return srv.tracker.LastByUID(uid, limit)
 }
 
+func (srv *lambdaSrv) StatsRange(ctx context.Context, token *api.Token, uid string, query stats.Query) ([]stats.Record, error) {
+	query.UID = uid
+	return srv.tracker.Range(query)
+}
+
+func (srv *lambdaSrv) StatsAggregate(ctx context.Context, token *api.Token, uid string, query stats.Query, bucket types.JsonDuration, group string) (*stats.Aggregation, error) {
+	query.UID = uid
+	return stats.Aggregate(srv.tracker, query, time.Duration(bucket), group)
+}
+
 func (srv *lambdaSrv) Actions(ctx context.Context, token *api.Token, uid string) ([]string, error) {
 	fn, err := srv.cases.Platform().FindByUID(uid)
 	if err != nil {
@@ -153,10 +453,212 @@ func (srv *lambdaSrv) Invoke(ctx context.Context, token *api.Token, uid string,
 	return out.String(), err
 }
 
+func (srv *lambdaSrv) InvokeAction(ctx context.Context, token *api.Token, uid string, action string, timeLimit types.JsonDuration) (*api.ActionResult, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	var report application.BuildReport
+	var usage application.Usage
+	started := time.Now()
+	err = srv.cases.Platform().Do(application.WithUsage(application.WithBuildReport(ctx, &report), &usage), fn.Lambda, action, time.Duration(timeLimit), &out)
+	result := &api.ActionResult{
+		Output:        out.String(),
+		Stderr:        string(usage.Stderr),
+		Duration:      types.JsonDuration(time.Since(started)),
+		QueuePosition: report.Position,
+		Queued:        types.JsonDuration(report.Queued),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		var limitErr *application.LimitError
+		if errors.As(err, &limitErr) {
+			result.LimitExceeded = limitErr.Limit
+			result.ExitCode = -1
+		}
+	}
+	return result, nil
+}
+
 func (srv *lambdaSrv) Link(ctx context.Context, token *api.Token, uid string, alias string) (*application.Definition, error) {
-	return srv.cases.Platform().Link(uid, alias)
+	if err := srv.cases.Migrated(uid); err != nil {
+		return nil, err
+	}
+	def, err := srv.cases.Platform().Link(uid, alias)
+	if err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, lambdaChange(def, application.ChangeAlias, "alias "+alias+" linked"))
+	return def, nil
+}
+
+func (srv *lambdaSrv) Requests(ctx context.Context, token *api.Token, uid string) ([]application.CapturedRequest, error) {
+	captures, err := srv.captures(uid)
+	if err != nil {
+		return nil, err
+	}
+	return captures.List(uid)
+}
+
+func (srv *lambdaSrv) CapturedRequest(ctx context.Context, token *api.Token, uid string, id string) (*application.CapturedRequest, error) {
+	captures, err := srv.captures(uid)
+	if err != nil {
+		return nil, err
+	}
+	return captures.Get(uid, id)
+}
+
+func (srv *lambdaSrv) Replay(ctx context.Context, token *api.Token, uid string, id string) (*api.ReplayResult, error) {
+	captured, err := srv.CapturedRequest(ctx, token, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	if captured.Truncated {
+		return nil, fmt.Errorf("captured request %s is truncated (%d bytes): it can not be replayed", id, captured.Size)
+	}
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	req := captured.Request.WithBody(ioutil.NopCloser(bytes.NewReader(captured.Body)))
+	req.ID = types.OriginRequestID(types.ReplayRequestPrefix, req.ID)
+	var out bytes.Buffer
+	var usage application.Usage
+	started := time.Now()
+	err = srv.cases.Platform().Invoke(application.WithUsage(ctx, &usage), fn.Lambda, *req, &out)
+	result := &api.ReplayResult{
+		ID:       req.ID,
+		Output:   out.Bytes(),
+		Stderr:   string(usage.Stderr),
+		Duration: types.JsonDuration(time.Since(started)),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+func (srv *lambdaSrv) GitDeploy(ctx context.Context, token *api.Token, uid string, force bool) (*application.GitStatus, error) {
+	if srv.deploys == nil {
+		return nil, errors.New("deploys from git are not enabled")
+	}
+	status, err := srv.deploys.Sync(ctx, uid, force)
+	if errors.Is(err, application.ErrNotTracked) {
+		return nil, &jsonrpc2.Error{Code: 404, Message: err.Error()}
+	} else if errors.Is(err, application.ErrDeployRunning) {
+		return nil, &jsonrpc2.Error{Code: 409, Message: err.Error(), Data: status}
+	}
+	return status, err
+}
+
+func (srv *lambdaSrv) captures(uid string) (application.Captures, error) {
+	if _, err := srv.cases.Platform().FindByUID(uid); err != nil {
+		return nil, err
+	}
+	captures := srv.cases.Captures()
+	if captures == nil {
+		return nil, errors.New("requests are not captured by server")
+	}
+	return captures, nil
+}
+
+func (srv *lambdaSrv) SetEnabled(ctx context.Context, token *api.Token, uid string, enabled bool) (*application.Definition, error) {
+	def, err := srv.cases.Platform().SetEnabled(uid, enabled)
+	if err != nil {
+		return nil, err
+	}
+	reason := "disabled"
+	if enabled {
+		reason = "enabled"
+	}
+	record(srv.journal, token, lambdaChange(def, application.ChangeState, reason))
+	return def, nil
+}
+
+func (srv *lambdaSrv) RegenerateSlug(ctx context.Context, token *api.Token, uid string) (*application.Definition, error) {
+	if err := srv.cases.Migrated(uid); err != nil {
+		return nil, err
+	}
+	def, err := srv.cases.Platform().GenerateSlug(uid)
+	if err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, lambdaChange(def, application.ChangeAlias, "slug "+def.Slug+" generated"))
+	return def, nil
 }
 
 func (srv *lambdaSrv) Unlink(ctx context.Context, token *api.Token, alias string) (*application.Definition, error) {
-	return srv.cases.Platform().Unlink(alias)
+	def, err := srv.cases.Platform().Unlink(alias)
+	if err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, lambdaChange(def, application.ChangeAlias, "alias "+alias+" unlinked"))
+	return def, nil
+}
+
+func (srv *lambdaSrv) Doctor(ctx context.Context, token *api.Token, uid string) (*application.Diagnostic, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	diag := srv.cases.Platform().Diagnose(fn.Lambda)
+	return &diag, nil
+}
+
+func (srv *lambdaSrv) ResetAlerts(ctx context.Context, token *api.Token, uid string, rule string) ([]application.AlertStatus, error) {
+	if srv.alerts == nil {
+		return nil, fmt.Errorf("alerts are not enabled")
+	}
+	return srv.alerts.Reset(uid, rule, token.Login)
+}
+
+// record manifest edits and schedule changes of lambda with updated manifest
+func (srv *lambdaSrv) recordManifest(token *api.Token, def *application.Definition, previous types.Manifest) {
+	if srv.journal == nil {
+		return
+	}
+	changes, err := journal.ManifestChanges(previous, def.Manifest)
+	if err != nil {
+		log.Println("[ERROR]", "describe manifest changes of", def.UID+":", err)
+		return
+	}
+	for _, change := range changes {
+		change.Lambda = def.UID
+		change.Name = def.Manifest.Name
+		record(srv.journal, token, change)
+	}
+}
+
+// journal changes of links by declared aliases: before - aliases of lambda before binding
+func (srv *lambdaSrv) recordAliases(token *api.Token, def *application.Definition, before types.JsonStringSet, previous, current []string) {
+	for _, alias := range current {
+		if !before.Has(alias) && def.Aliases.Has(alias) {
+			record(srv.journal, token, lambdaChange(def, application.ChangeAlias, "alias "+alias+" bound by manifest"))
+		}
+	}
+	for _, alias := range previous {
+		if before.Has(alias) && !def.Aliases.Has(alias) {
+			record(srv.journal, token, lambdaChange(def, application.ChangeAlias, "alias "+alias+" released by manifest"))
+		}
+	}
+}
+
+// invalid manifest as RPC error 422 with problems of fields as data, aliases of manifest bound to other lambdas as
+// RPC error 409 with conflicts as data, other errors as-is
+func validationError(err error) error {
+	var conflict *application.AliasConflictError
+	if errors.As(err, &conflict) {
+		return &jsonrpc2.Error{Code: 409, Message: err.Error(), Data: conflict.Conflicts}
+	}
+	var invalid *types.ValidationError
+	if !errors.As(err, &invalid) {
+		return err
+	}
+	return &jsonrpc2.Error{Code: 422, Message: err.Error(), Data: invalid.Fields}
 }
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/jsonrpc2"
+	"github.com/reddec/trusted-cgi/api"
+)
+
+const (
+	lockoutLogin   = "login"
+	lockoutAddress = "address"
+)
+
+// maximum number of tracked subjects before subjects without recent failures are forgotten
+const maxTrackedLogins = 4096
+
+// LoginLimits is protection of login against brute-force: failed logins are counted per account (any login, even
+// unknown) and per client address. Every attempt after DelayAfter failures in a row is delayed by Delay doubled per
+// failure (not more than MaxDelay), after Attempts failures the subject is locked out for Lockout. Failures older than
+// Lockout are forgotten, successful login resets counters of account and address
+type LoginLimits struct {
+	DelayAfter int           // failures before delay (zero - every failure delays the next attempt)
+	Delay      time.Duration // initial delay (zero - attempts are not delayed)
+	MaxDelay   time.Duration // maximum delay
+	Attempts   int           // failures before lockout (zero - never locked out)
+	Lockout    time.Duration // time of lockout and of memory of failures
+}
+
+var DefaultLoginLimits = LoginLimits{DelayAfter: 3, Delay: 500 * time.Millisecond, MaxDelay: 8 * time.Second, Attempts: 10, Lockout: 15 * time.Minute}
+
+func (ll LoginLimits) Validate() error {
+	if ll.DelayAfter < 0 || ll.Attempts < 0 {
+		return fmt.Errorf("number of failed logins should not be negative")
+	}
+	if ll.Delay < 0 || ll.MaxDelay < 0 {
+		return fmt.Errorf("delay of login should not be negative")
+	}
+	if ll.Attempts > 0 && ll.Lockout <= 0 {
+		return fmt.Errorf("time of lockout should be positive")
+	}
+	return nil
+}
+
+// delay of attempt after failures
+func (ll LoginLimits) delay(failures int) time.Duration {
+	if ll.Delay <= 0 || failures == 0 || failures < ll.DelayAfter {
+		return 0
+	}
+	delay := ll.Delay
+	for i := ll.DelayAfter; i < failures && (ll.MaxDelay <= 0 || delay < ll.MaxDelay); i++ {
+		delay *= 2
+	}
+	if ll.MaxDelay > 0 && delay > ll.MaxDelay {
+		delay = ll.MaxDelay
+	}
+	return delay
+}
+
+type loginSubject struct {
+	kind    string
+	subject string
+}
+
+type loginFailures struct {
+	failures    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// in-memory counters of failed logins by subjects
+type loginGuard struct {
+	limits   LoginLimits
+	lock     sync.Mutex
+	subjects map[loginSubject]*loginFailures
+}
+
+func newLoginGuard(limits LoginLimits) *loginGuard {
+	return &loginGuard{limits: limits, subjects: make(map[loginSubject]*loginFailures)}
+}
+
+func (lg *loginGuard) setLimits(limits LoginLimits) {
+	lg.lock.Lock()
+	defer lg.lock.Unlock()
+	lg.limits = limits
+}
+
+// subjects of login attempt: account and client address (if known)
+func loginSubjects(login, address string) []loginSubject {
+	subjects := []loginSubject{{kind: lockoutLogin, subject: login}}
+	if address != "" {
+		subjects = append(subjects, loginSubject{kind: lockoutAddress, subject: address})
+	}
+	return subjects
+}
+
+// check attempt: locked out attempt is rejected immediately, otherwise attempt is delayed by failures of subjects.
+// Error does not depend on kind of locked subject, so it does not reveal existence of account
+func (lg *loginGuard) check(ctx context.Context, login, address string) error {
+	now := time.Now()
+	var lockedUntil time.Time
+	var failures int
+	lg.lock.Lock()
+	limits := lg.limits
+	for _, key := range loginSubjects(login, address) {
+		state := lg.current(key, now)
+		if state == nil {
+			continue
+		}
+		if state.lockedUntil.After(lockedUntil) {
+			lockedUntil = state.lockedUntil
+		}
+		if state.failures > failures {
+			failures = state.failures
+		}
+	}
+	lg.lock.Unlock()
+	if !lockedUntil.IsZero() {
+		return lockedError(lockedUntil)
+	}
+	delay := limits.delay(failures)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// count failed attempt, returns subjects locked out by the attempt
+func (lg *loginGuard) fail(login, address string) []api.Lockout {
+	now := time.Now()
+	lg.lock.Lock()
+	defer lg.lock.Unlock()
+	if len(lg.subjects) >= maxTrackedLogins {
+		lg.prune(now)
+	}
+	var locked []api.Lockout
+	for _, key := range loginSubjects(login, address) {
+		state := lg.current(key, now)
+		if state == nil {
+			state = &loginFailures{}
+			lg.subjects[key] = state
+		}
+		state.failures++
+		state.last = now
+		if lg.limits.Attempts > 0 && state.failures >= lg.limits.Attempts && state.lockedUntil.IsZero() {
+			state.lockedUntil = now.Add(lg.limits.Lockout)
+			locked = append(locked, state.info(key))
+		}
+	}
+	return locked
+}
+
+// reset counters of subjects after successful login
+func (lg *loginGuard) reset(login, address string) {
+	lg.lock.Lock()
+	defer lg.lock.Unlock()
+	for _, key := range loginSubjects(login, address) {
+		delete(lg.subjects, key)
+	}
+}
+
+// tracked subjects sorted by kind and subject
+func (lg *loginGuard) list() []api.Lockout {
+	now := time.Now()
+	lg.lock.Lock()
+	defer lg.lock.Unlock()
+	var ans = make([]api.Lockout, 0, len(lg.subjects))
+	for key := range lg.subjects {
+		if state := lg.current(key, now); state != nil {
+			ans = append(ans, state.info(key))
+		}
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		if ans[i].Kind != ans[j].Kind {
+			return ans[i].Kind < ans[j].Kind
+		}
+		return ans[i].Subject < ans[j].Subject
+	})
+	return ans
+}
+
+// clear counters of login or address (empty - all), returns number of cleared subjects
+func (lg *loginGuard) clear(subject string) int {
+	lg.lock.Lock()
+	defer lg.lock.Unlock()
+	var cleared int
+	for key := range lg.subjects {
+		if subject == "" || key.subject == subject {
+			delete(lg.subjects, key)
+			cleared++
+		}
+	}
+	return cleared
+}
+
+// state of subject without expired lockout and forgotten failures (nil if not tracked). Must be called under lock
+func (lg *loginGuard) current(key loginSubject, now time.Time) *loginFailures {
+	state, ok := lg.subjects[key]
+	if !ok {
+		return nil
+	}
+	if lg.expired(state, now) {
+		delete(lg.subjects, key)
+		return nil
+	}
+	return state
+}
+
+func (lg *loginGuard) expired(state *loginFailures, now time.Time) bool {
+	if !state.lockedUntil.IsZero() {
+		return !now.Before(state.lockedUntil)
+	}
+	return lg.limits.Lockout > 0 && now.Sub(state.last) >= lg.limits.Lockout
+}
+
+// forget expired subjects, then subjects without lockout if still too many. Must be called under lock
+func (lg *loginGuard) prune(now time.Time) {
+	for key, state := range lg.subjects {
+		if lg.expired(state, now) {
+			delete(lg.subjects, key)
+		}
+	}
+	for key, state := range lg.subjects {
+		if len(lg.subjects) < maxTrackedLogins {
+			break
+		}
+		if state.lockedUntil.IsZero() {
+			delete(lg.subjects, key)
+		}
+	}
+}
+
+func (lf *loginFailures) info(key loginSubject) api.Lockout {
+	return api.Lockout{Subject: key.subject, Kind: key.kind, Failures: lf.failures, LockedUntil: lf.lockedUntil}
+}
+
+func lockedError(until time.Time) error {
+	return &jsonrpc2.Error{
+		Code:    429,
+		Message: "too many failed logins, try again after " + until.UTC().Format(time.RFC3339),
+	}
+}
+
+// human-readable subjects of lockouts
+func describeLockouts(list []api.Lockout) string {
+	var parts = make([]string, 0, len(list))
+	for _, item := range list {
+		parts = append(parts, item.Kind+" "+item.Subject)
+	}
+	return strings.Join(parts, ", ")
+}
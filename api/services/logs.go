@@ -0,0 +1,43 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+func (srv *lambdaSrv) LogFiles(ctx context.Context, token *api.Token, uid string) ([]application.LogFile, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return fn.Lambda.LogFiles()
+}
+
+func (srv *lambdaSrv) DownloadLog(ctx context.Context, token *api.Token, uid string, name string) ([]byte, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := fn.Lambda.ReadLog(name, &out); errors.Is(err, application.ErrLogNotFound) {
+		return nil, &jsonrpc2.Error{Code: 404, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (srv *lambdaSrv) TailLog(ctx context.Context, token *api.Token, uid string, lines int) (string, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return "", err
+	}
+	tail, err := fn.Lambda.TailLog(lines)
+	return string(tail), err
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	passwordSaltSize = 16
+	passwordKeySize  = 32
+)
+
+// PasswordHashing is parameters of argon2id hashes of admin password. Parameters are embedded in stored hash, so
+// changed parameters are applied to stored password by the next successful login
+type PasswordHashing struct {
+	Time    uint32 // number of passes over memory
+	Memory  uint32 // memory in KiB
+	Threads uint8  // degree of parallelism
+}
+
+// DefaultPasswordHashing is the second recommended option of RFC 9106 (for hosts without 2 GiB per login)
+var DefaultPasswordHashing = PasswordHashing{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+func (ph PasswordHashing) Validate() error {
+	if ph.Time == 0 {
+		return fmt.Errorf("number of passes of password hashing should be positive")
+	}
+	if ph.Threads == 0 {
+		return fmt.Errorf("number of threads of password hashing should be positive")
+	}
+	if ph.Memory < 8*uint32(ph.Threads) {
+		return fmt.Errorf("memory of password hashing should be at least 8 KiB per thread")
+	}
+	return nil
+}
+
+// hash of password in PHC string format: $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+func (ph PasswordHashing) hash(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, ph.Time, ph.Memory, ph.Threads, passwordKeySize)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, ph.Memory, ph.Time, ph.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// check password by hash in PHC string format (see PasswordHashing.hash) in constant time, parameters of hash are
+// returned to detect outdated hashes
+func verifyPassword(password, encoded string) (bool, PasswordHashing, error) {
+	var params PasswordHashing
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return false, params, fmt.Errorf("password hash is not argon2id")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, params, fmt.Errorf("unsupported version of argon2id hash: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return false, params, fmt.Errorf("parse parameters of argon2id hash: %w", err)
+	}
+	if err := params.Validate(); err != nil {
+		return false, params, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, params, fmt.Errorf("decode salt of argon2id hash: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, params, fmt.Errorf("decode key of argon2id hash: %w", err)
+	}
+	if len(key) == 0 {
+		return false, params, fmt.Errorf("empty key of argon2id hash")
+	}
+	actual := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1, params, nil
+}
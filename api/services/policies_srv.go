@@ -6,12 +6,13 @@ import (
 	"github.com/reddec/trusted-cgi/application"
 )
 
-func NewPoliciesSrv(policies application.Policies) *policiesSrv {
-	return &policiesSrv{policies: policies}
+func NewPoliciesSrv(policies application.Policies, journal application.Journal) *policiesSrv {
+	return &policiesSrv{policies: policies, journal: journal}
 }
 
 type policiesSrv struct {
 	policies application.Policies
+	journal  application.Journal // optional journal of changes
 }
 
 func (srv *policiesSrv) List(ctx context.Context, token *api.Token) ([]application.Policy, error) {
@@ -19,25 +20,46 @@ func (srv *policiesSrv) List(ctx context.Context, token *api.Token) ([]applicati
 }
 
 func (srv *policiesSrv) Create(ctx context.Context, token *api.Token, policy string, definition application.PolicyDefinition) (*application.Policy, error) {
-	return srv.policies.Create(policy, definition)
+	created, err := srv.policies.Create(policy, definition)
+	if err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangePolicy, Summary: "policy " + policy + " created"})
+	return created, nil
 }
 
 func (srv *policiesSrv) Remove(ctx context.Context, token *api.Token, policy string) (bool, error) {
 	err := srv.policies.Remove(policy)
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangePolicy, Summary: "policy " + policy + " removed"})
+	return true, nil
 }
 
 func (srv *policiesSrv) Update(ctx context.Context, token *api.Token, policy string, definition application.PolicyDefinition) (bool, error) {
 	err := srv.policies.Update(policy, definition)
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangePolicy, Summary: "policy " + policy + " updated"})
+	return true, nil
 }
 
 func (srv *policiesSrv) Apply(ctx context.Context, token *api.Token, lambda string, policy string) (bool, error) {
 	err := srv.policies.Apply(lambda, policy)
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangePolicy, Lambda: lambda, Summary: "policy " + policy + " applied"})
+	return true, nil
 }
 
 func (srv *policiesSrv) Clear(ctx context.Context, token *api.Token, lambda string) (bool, error) {
 	err := srv.policies.Clear(lambda)
-	return err == nil, err
+	if err != nil {
+		return false, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangePolicy, Lambda: lambda, Summary: "policy cleared"})
+	return true, nil
 }
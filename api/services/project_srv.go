@@ -2,41 +2,143 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/capacity"
+	"github.com/reddec/trusted-cgi/application/journal"
+	"github.com/reddec/trusted-cgi/application/openapi"
 	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-func NewProjectSrv(cases application.Cases, tracker stats.Reader) *projectSrv {
+// Default window of capacity report
+const defaultCapacityWindow = time.Hour
+
+func NewProjectSrv(cases application.Cases, tracker stats.Reader, alerts application.Alerts, info *api.ServerInfo, capacity *capacity.Reporter, hooks *application.Hooks, mirror application.Mirror, journal application.Journal, search application.Search, publicURL string) *projectSrv {
 	return &projectSrv{
-		cases:   cases,
-		tracker: tracker,
+		cases:     cases,
+		tracker:   tracker,
+		alerts:    alerts,
+		info:      info,
+		capacity:  capacity,
+		hooks:     hooks,
+		mirror:    mirror,
+		journal:   journal,
+		search:    search,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		transfers: newTransfers(),
 	}
 }
 
 type projectSrv struct {
-	cases   application.Cases
-	tracker stats.Reader // for stats
+	cases    application.Cases
+	tracker  stats.Reader         // for stats
+	alerts   application.Alerts   // optional status of alert rules
+	info     *api.ServerInfo      // optional server information
+	capacity *capacity.Reporter   // optional capacity reporter
+	hooks    *application.Hooks   // optional lifecycle hooks
+	mirror   application.Mirror   // optional replication of primary
+	journal  application.Journal  // optional journal of changes
+	search   application.Search   // optional full-text index of lambdas
+	webhooks application.Webhooks // optional deliveries of lifecycle events
+	backups  application.Backups  // optional backup and restore of server
+	// configured public base URL of server for outputs of templates (empty - by client, see api.CreateOptions)
+	publicURL string
+	transfers *transfers // progress of transfers from other servers
+	// optional import of lambdas from archives by URL
+	artifacts application.Artifacts
+	reloader  application.Reloader // optional reload of files edited on disk
+	envLock   sync.Mutex           // serializes changes of global environment
+}
+
+// SetWebhooks enables history of deliveries of lifecycle events (by default - empty history).
+func (srv *projectSrv) SetWebhooks(webhooks application.Webhooks) {
+	srv.webhooks = webhooks
 }
 
 func (srv *projectSrv) Create(ctx context.Context, token *api.Token) (*application.Definition, error) {
 	uid, err := srv.cases.Create(ctx)
 	if err != nil {
-		return nil, err
+		return nil, validationError(err)
 	}
-	return srv.cases.Platform().FindByUID(uid)
+	return srv.created(ctx, token, uid, nil, nil, "", "lambda created")
 }
 
 func (srv *projectSrv) CreateFromGit(ctx context.Context, token *api.Token, repo string) (*application.Definition, error) {
 	uid, err := srv.cases.CreateFromGit(ctx, repo)
 	if err != nil {
-		return nil, err
+		return nil, validationError(err)
 	}
-	return srv.cases.Platform().FindByUID(uid)
+	return srv.created(ctx, token, uid, nil, nil, "", "lambda created from "+repo)
 }
 
 func (srv *projectSrv) CreateFromTemplate(ctx context.Context, token *api.Token, templateName string) (*application.Definition, error) {
+	tpl, err := srv.template(ctx, templateName)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := srv.cases.CreateFromTemplate(ctx, *tpl)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	return srv.created(ctx, token, uid, nil, tpl, "", "lambda created from template "+templateName)
+}
+
+func (srv *projectSrv) CreateWithOptions(ctx context.Context, token *api.Token, options api.CreateOptions) (*application.Definition, error) {
+	var tpl = templates.Template{}
+	if options.Template != "" {
+		found, err := srv.template(ctx, options.Template)
+		if err != nil {
+			return nil, err
+		}
+		tpl = *found
+	}
+	if options.Name != "" {
+		tpl.Manifest.Name = options.Name
+	}
+	if options.Slug != nil && *options.Slug && application.Slug(tpl.Manifest.Name) == "" {
+		return nil, fmt.Errorf("name %q has no latin letters or digits for slug", tpl.Manifest.Name)
+	}
+	uid, err := srv.cases.CreateFromTemplate(ctx, tpl)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	summary := "lambda created"
+	if options.Template != "" {
+		summary += " from template " + options.Template
+	}
+	return srv.created(ctx, token, uid, options.Slug, &tpl, options.PublicURL, summary)
+}
+
+func (srv *projectSrv) Duplicate(ctx context.Context, token *api.Token, uid string, options application.DuplicateOptions) (*application.Definition, error) {
+	copyUID, skipped, err := srv.cases.Duplicate(ctx, uid, options)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	if len(skipped) > 0 {
+		if def, err := srv.cases.Platform().FindByUID(copyUID); err == nil {
+			def.Lambda.SetWarning("aliases bound to other lambdas are not declared by copy: " + strings.Join(skipped, ", "))
+		}
+	}
+	def, err := srv.created(ctx, token, copyUID, nil, nil, "", "lambda duplicated from "+uid)
+	if err != nil {
+		return nil, err
+	}
+	// startup action outlives the request
+	srv.cases.Platform().Start(context.Background(), def.Lambda)
+	return def, nil
+}
+
+// available template by name
+func (srv *projectSrv) template(ctx context.Context, templateName string) (*templates.Template, error) {
 	possible, err := srv.cases.Templates()
 	if err != nil {
 		return nil, err
@@ -48,11 +150,50 @@ func (srv *projectSrv) CreateFromTemplate(ctx context.Context, token *api.Token,
 	if !tpl.IsAvailable(ctx) {
 		return nil, fmt.Errorf("template %s is not supported", templateName)
 	}
-	uid, err := srv.cases.CreateFromTemplate(ctx, *tpl)
+	return tpl, nil
+}
+
+// definition of created lambda with slug generated from name if requested (nil - by server setting) and resolved
+// outputs of template (if any). Lambda without name has no slug by server setting
+func (srv *projectSrv) created(ctx context.Context, token *api.Token, uid string, slug *bool, tpl *templates.Template, publicURL string, summary string) (*application.Definition, error) {
+	def, err := srv.cases.Platform().FindByUID(uid)
 	if err != nil {
 		return nil, err
 	}
-	return srv.cases.Platform().FindByUID(uid)
+	srv.hooks.Deployed(ctx, application.DeployEvent{UID: uid, Kind: application.DeployCreate})
+	record(srv.journal, token, lambdaChange(def, application.ChangeCreate, summary))
+	generate := srv.cases.Platform().Config().AutoSlug
+	if slug != nil {
+		generate = *slug
+	}
+	if generate && application.Slug(def.Manifest.Name) != "" {
+		def, err = srv.cases.Platform().GenerateSlug(uid)
+		if err != nil {
+			return nil, fmt.Errorf("lambda %s created, but slug is not generated: %w", uid, err)
+		}
+	}
+	if tpl != nil {
+		srv.resolveOutputs(def, tpl, publicURL)
+	}
+	return def, nil
+}
+
+// fill outputs of template for created lambda: by slug or the first declared alias and configured public URL (or
+// public URL of client). Problems are logged: lambda is already created
+func (srv *projectSrv) resolveOutputs(def *application.Definition, tpl *templates.Template, publicURL string) {
+	if srv.publicURL != "" {
+		publicURL = srv.publicURL
+	}
+	alias := def.Slug
+	if alias == "" && len(def.Manifest.Aliases) > 0 {
+		alias = def.Manifest.Aliases[0]
+	}
+	outputs, err := tpl.ResolveOutputs(templates.NewOutputValues(def.UID, alias, strings.TrimSuffix(publicURL, "/")))
+	if err != nil {
+		log.Println("[WARN]", "outputs of template for lambda", def.UID+":", err)
+		return
+	}
+	def.Outputs = outputs
 }
 
 func (srv *projectSrv) Config(ctx context.Context, token *api.Token) (*api.Settings, error) {
@@ -61,6 +202,9 @@ func (srv *projectSrv) Config(ctx context.Context, token *api.Token) (*api.Setti
 		User:        srv.cases.Platform().Config().User,
 		PublicKey:   string(pk),
 		Environment: srv.cases.Platform().Config().Environment,
+		Runtime:     srv.cases.Platform().Config().Runtime,
+		Builds:      srv.cases.Platform().Config().Builds,
+		AutoSlug:    srv.cases.Platform().Config().AutoSlug,
 	}, nil
 }
 
@@ -69,14 +213,50 @@ func (srv *projectSrv) SetEnvironment(ctx context.Context, token *api.Token, env
 	if err != nil {
 		return nil, err
 	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeSettings, Summary: "global environment changed"})
 	return srv.Config(ctx, token)
 }
 
+func (srv *projectSrv) UpdateEnvironment(ctx context.Context, token *api.Token, set api.Environment, unset []string) (*api.Environment, error) {
+	srv.envLock.Lock()
+	defer srv.envLock.Unlock()
+	config := srv.cases.Platform().Config()
+	var env = make(map[string]string, len(config.Environment)+len(set.Environment))
+	for k, v := range config.Environment {
+		env[k] = v
+	}
+	var changed []string
+	for k, v := range set.Environment {
+		env[k] = v
+		changed = append(changed, k)
+	}
+	for _, k := range unset {
+		delete(env, k)
+	}
+	if err := types.ValidateEnvironment(env); err != nil {
+		return nil, err
+	}
+	if err := srv.cases.Platform().SetConfig(config.WithEnv(env)); err != nil {
+		return nil, err
+	}
+	sort.Strings(changed)
+	var summary []string
+	if len(changed) > 0 {
+		summary = append(summary, "set "+strings.Join(changed, ", "))
+	}
+	if len(unset) > 0 {
+		summary = append(summary, "unset "+strings.Join(unset, ", "))
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeSettings, Summary: "global environment changed: " + strings.Join(summary, "; ")})
+	return &api.Environment{Environment: env}, nil
+}
+
 func (srv *projectSrv) SetUser(ctx context.Context, token *api.Token, user string) (*api.Settings, error) {
 	err := srv.cases.Platform().SetConfig(srv.cases.Platform().Config().WithUser(user))
 	if err != nil {
 		return nil, err
 	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeSettings, Summary: "effective user changed to " + user})
 	return srv.Config(ctx, token)
 }
 
@@ -98,7 +278,64 @@ func (srv *projectSrv) AllTemplates(ctx context.Context, token *api.Token) ([]*a
 }
 
 func (srv *projectSrv) List(ctx context.Context, token *api.Token) ([]application.Definition, error) {
-	return srv.cases.Platform().List(), nil
+	list := srv.cases.Platform().List()
+	for i := range list {
+		modified, err := list[i].Lambda.Modified()
+		if err != nil {
+			return nil, fmt.Errorf("get modification time of %s: %w", list[i].UID, err)
+		}
+		list[i].Modified = modified
+		fillLiveStatus(srv.cases, srv.alerts, &list[i])
+	}
+	return list, nil
+}
+
+func (srv *projectSrv) Search(ctx context.Context, token *api.Token, query string, limit int) (*application.SearchResult, error) {
+	if srv.search == nil {
+		return nil, fmt.Errorf("search is not available")
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit should not be negative")
+	}
+	result := srv.search.Search(query, limit)
+	return &result, nil
+}
+
+func (srv *projectSrv) Routes(ctx context.Context, token *api.Token) ([]application.LambdaRoutes, error) {
+	list := srv.cases.Platform().List()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UID < list[j].UID
+	})
+	var ans = make([]application.LambdaRoutes, 0, len(list))
+	for _, def := range list {
+		ans = append(ans, application.RoutesOf(def, srv.cases.Queues().Find(def.UID)))
+	}
+	return ans, nil
+}
+
+func (srv *projectSrv) OpenAPI(ctx context.Context, token *api.Token, lambda string, publicURL string) (*openapi.Document, error) {
+	if srv.publicURL != "" {
+		publicURL = srv.publicURL
+	}
+	options := openapi.Options{ServerURL: publicURL}
+	if srv.info != nil {
+		options.Version = srv.info.Version
+	}
+	var list []application.Definition
+	if lambda != "" {
+		def, err := srv.cases.Platform().FindByUID(lambda)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *def)
+		options.Title = def.Manifest.Name
+	} else {
+		list = srv.cases.Platform().List()
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].UID < list[j].UID
+		})
+	}
+	return openapi.Generate(list, options), nil
 }
 
 func (srv *projectSrv) Templates(ctx context.Context, token *api.Token) ([]*api.Template, error) {
@@ -121,3 +358,141 @@ func (srv *projectSrv) Templates(ctx context.Context, token *api.Token) ([]*api.
 func (srv *projectSrv) Stats(ctx context.Context, token *api.Token, limit int) ([]stats.Record, error) {
 	return srv.tracker.Last(limit)
 }
+
+func (srv *projectSrv) StatsRange(ctx context.Context, token *api.Token, query stats.Query) ([]stats.Record, error) {
+	return srv.tracker.Range(query)
+}
+
+func (srv *projectSrv) StatsAggregate(ctx context.Context, token *api.Token, query stats.Query, bucket types.JsonDuration, group string) (*stats.Aggregation, error) {
+	return stats.Aggregate(srv.tracker, query, time.Duration(bucket), group)
+}
+
+var errRetentionDisabled = errors.New("retention of stats is not available")
+
+func (srv *projectSrv) StatsUsage(ctx context.Context, token *api.Token) (*stats.Usage, error) {
+	retainer, ok := srv.tracker.(stats.Retainer)
+	if !ok {
+		return nil, errRetentionDisabled
+	}
+	usage := retainer.Usage()
+	return &usage, nil
+}
+
+func (srv *projectSrv) SetStatsRetention(ctx context.Context, token *api.Token, retention stats.Retention) (*stats.Usage, error) {
+	retainer, ok := srv.tracker.(stats.Retainer)
+	if !ok {
+		return nil, errRetentionDisabled
+	}
+	if err := retainer.SetRetention(retention); err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeSettings, Summary: "retention of stats changed"})
+	usage := retainer.Usage()
+	return &usage, nil
+}
+
+func (srv *projectSrv) Capabilities(ctx context.Context, token *api.Token) (*api.ServerInfo, error) {
+	if srv.info == nil {
+		return nil, fmt.Errorf("server information is not available")
+	}
+	info := *srv.info
+	upload := srv.cases.Platform().Profile().Upload.Effective()
+	info.Upload = &upload
+	return &info, nil
+}
+
+func (srv *projectSrv) Capacity(ctx context.Context, token *api.Token, window types.JsonDuration) (*application.CapacityReport, error) {
+	if srv.capacity == nil {
+		return nil, fmt.Errorf("capacity report is not available")
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("window should not be negative")
+	}
+	if window == 0 {
+		window = types.JsonDuration(defaultCapacityWindow)
+	}
+	return srv.capacity.Report(ctx, time.Duration(window))
+}
+
+func (srv *projectSrv) Changes(ctx context.Context, token *api.Token, since, until time.Time, offset, limit int) (*application.ChangesReport, error) {
+	if srv.journal == nil {
+		return nil, fmt.Errorf("journal of changes is not available")
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+	if until.Before(since) {
+		return nil, fmt.Errorf("until should not be before since")
+	}
+	return journal.Report(srv.journal, since, until, offset, limit)
+}
+
+func (srv *projectSrv) Audit(ctx context.Context, token *api.Token, query application.ChangeQuery, offset, limit int) (*application.ChangesPage, error) {
+	if srv.journal == nil {
+		return nil, fmt.Errorf("journal of changes is not available")
+	}
+	if query.Until.IsZero() {
+		query.Until = time.Now()
+	}
+	if query.Until.Before(query.Since) {
+		return nil, fmt.Errorf("until should not be before since")
+	}
+	if query.Lambda != "" {
+		// removed lambdas are found by UID only
+		if def, err := srv.cases.Platform().FindByLink(query.Lambda); err == nil {
+			query.Lambda = def.UID
+		}
+	}
+	return journal.Page(srv.journal, query, offset, limit)
+}
+
+func (srv *projectSrv) Security(ctx context.Context, token *api.Token) (*application.SecurityReport, error) {
+	profile := srv.cases.Platform().Profile()
+	var report = &application.SecurityReport{Profile: profile, Lambdas: make([]application.LambdaDeviations, 0)}
+	for _, def := range srv.cases.Platform().List() {
+		deviations := profile.Deviations(def.Manifest)
+		if len(deviations) == 0 {
+			continue
+		}
+		for _, deviation := range deviations {
+			if deviation.Violation {
+				report.Violations++
+			}
+		}
+		report.Lambdas = append(report.Lambdas, application.LambdaDeviations{
+			UID:        def.UID,
+			Name:       def.Manifest.Name,
+			Deviations: deviations,
+		})
+	}
+	sort.Slice(report.Lambdas, func(i, j int) bool {
+		return report.Lambdas[i].UID < report.Lambdas[j].UID
+	})
+	return report, nil
+}
+
+func (srv *projectSrv) Mirror(ctx context.Context, token *api.Token) (*application.MirrorStatus, error) {
+	if srv.mirror == nil {
+		return &application.MirrorStatus{}, nil
+	}
+	status := srv.mirror.Status()
+	return &status, nil
+}
+
+func (srv *projectSrv) Promote(ctx context.Context, token *api.Token) (*application.MirrorStatus, error) {
+	if srv.mirror == nil {
+		return nil, fmt.Errorf("server is not a mirror")
+	}
+	status, err := srv.mirror.Promote()
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (srv *projectSrv) WebhookDeliveries(ctx context.Context, token *api.Token, limit int) ([]application.WebhookDelivery, error) {
+	if srv.webhooks == nil {
+		return []application.WebhookDelivery{}, nil
+	}
+	return srv.webhooks.Deliveries(limit), nil
+}
@@ -6,12 +6,13 @@ import (
 	"github.com/reddec/trusted-cgi/application"
 )
 
-func NewQueuesSrv(queues application.Queues) *queuesSrv {
-	return &queuesSrv{queues: queues}
+func NewQueuesSrv(queues application.Queues, async application.AsyncQueue) *queuesSrv {
+	return &queuesSrv{queues: queues, async: async}
 }
 
 type queuesSrv struct {
 	queues application.Queues
+	async  application.AsyncQueue // optional asynchronous invocations
 }
 
 func (srv *queuesSrv) Create(ctx context.Context, token *api.Token, queue application.Queue) (*application.Queue, error) {
@@ -35,3 +36,45 @@ func (srv *queuesSrv) Assign(ctx context.Context, token *api.Token, name string,
 	err := srv.queues.Assign(name, lambda)
 	return err == nil, err
 }
+
+func (srv *queuesSrv) Inspect(ctx context.Context, token *api.Token, name string) (*application.QueueMessage, error) {
+	return srv.queues.Inspect(name)
+}
+
+func (srv *queuesSrv) RetryQuarantined(ctx context.Context, token *api.Token, name string) (int, error) {
+	return srv.queues.RetryQuarantined(name)
+}
+
+func (srv *queuesSrv) PurgeQuarantined(ctx context.Context, token *api.Token, name string) (int, error) {
+	return srv.queues.PurgeQuarantined(name)
+}
+
+func (srv *queuesSrv) Async(ctx context.Context, token *api.Token) ([]application.AsyncInvocation, error) {
+	if srv.async == nil {
+		return []application.AsyncInvocation{}, nil
+	}
+	return srv.async.Pending(), nil
+}
+
+func (srv *queuesSrv) PurgeAsync(ctx context.Context, token *api.Token, tickets []string) (int, error) {
+	if srv.async == nil {
+		return 0, nil
+	}
+	return srv.async.Purge(tickets)
+}
+
+func (srv *queuesSrv) DeadLetters(ctx context.Context, token *api.Token, lambda string) ([]application.DeadLetter, error) {
+	return srv.queues.DeadLetters(lambda)
+}
+
+func (srv *queuesSrv) DeadLetter(ctx context.Context, token *api.Token, lambda string, id string) (*application.DeadLetter, error) {
+	return srv.queues.DeadLetter(lambda, id)
+}
+
+func (srv *queuesSrv) RemoveDeadLetters(ctx context.Context, token *api.Token, lambda string, ids []string) (int, error) {
+	return srv.queues.RemoveDeadLetters(lambda, ids)
+}
+
+func (srv *queuesSrv) RedriveDeadLetters(ctx context.Context, token *api.Token, lambda string, ids []string) (int, error) {
+	return srv.queues.RedriveDeadLetters(lambda, ids)
+}
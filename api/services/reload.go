@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+var errReloadDisabled = errors.New("reload of files is not available")
+
+// SetReloader enables reload of files edited on disk by API (by default - disabled).
+func (srv *projectSrv) SetReloader(reloader application.Reloader) {
+	srv.reloader = reloader
+}
+
+func (srv *projectSrv) Reload(ctx context.Context, token *api.Token, uid string) (*application.ReloadReport, error) {
+	if srv.reloader == nil {
+		return nil, errReloadDisabled
+	}
+	if uid != "" {
+		if _, err := srv.cases.Platform().FindByUID(uid); err != nil {
+			return nil, err
+		}
+	}
+	// changes are recorded by reloader
+	return srv.reloader.Reload(uid, token.Login)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var errSecretsDisabled = errors.New("store of secrets is disabled")
+
+func (srv *projectSrv) Secrets(ctx context.Context, token *api.Token) ([]application.Secret, error) {
+	store := srv.cases.Platform().Secrets()
+	if store == nil {
+		return nil, errSecretsDisabled
+	}
+	usage := srv.secretUsage()
+	list := store.List()
+	for i := range list {
+		list[i].Lambdas = usage[list[i].Name]
+	}
+	return list, nil
+}
+
+func (srv *projectSrv) SetSecret(ctx context.Context, token *api.Token, name string, value string) (*application.Secret, error) {
+	store := srv.cases.Platform().Secrets()
+	if store == nil {
+		return nil, errSecretsDisabled
+	}
+	secret, err := store.Set(name, value)
+	if err != nil {
+		return nil, err
+	}
+	secret.Lambdas = srv.secretUsage()[name]
+	record(srv.journal, token, application.Change{Kind: application.ChangeSecret, Summary: "secret " + name + " set"})
+	return secret, nil
+}
+
+func (srv *projectSrv) RemoveSecret(ctx context.Context, token *api.Token, name string) (*application.Secret, error) {
+	store := srv.cases.Platform().Secrets()
+	if store == nil {
+		return nil, errSecretsDisabled
+	}
+	var removed *application.Secret
+	for _, secret := range store.List() {
+		if secret.Name == name {
+			removed = &secret
+			break
+		}
+	}
+	if removed == nil {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	if _, err := store.Remove(name); err != nil {
+		return nil, err
+	}
+	removed.Lambdas = srv.secretUsage()[name]
+	if len(removed.Lambdas) > 0 {
+		log.Println("[WARN]", "removed secret", name, "is still referenced by lambdas:", strings.Join(removed.Lambdas, ", "))
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeSecret, Summary: "secret " + name + " removed"})
+	return removed, nil
+}
+
+// UIDs of lambdas (sorted) by names of secrets referenced in their environment
+func (srv *projectSrv) secretUsage() map[string][]string {
+	var usage = make(map[string][]string)
+	for _, def := range srv.cases.Platform().List() {
+		for _, name := range types.SecretReferences(def.Manifest.Environment) {
+			usage[name] = append(usage[name], def.UID)
+		}
+	}
+	for _, uids := range usage {
+		sort.Strings(uids)
+	}
+	return usage
+}
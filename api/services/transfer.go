@@ -0,0 +1,269 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/api/client"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+const (
+	exportGrantLifeTime = 5 * time.Minute
+	exportScope         = "export"
+	transferKeep        = time.Minute // progress of finished transfer is kept for polling
+)
+
+func (srv *lambdaSrv) GrantExport(ctx context.Context, token *api.Token, uid string, secrets bool) (*api.TransferGrant, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := exportContent(fn.Lambda)
+	if err != nil {
+		return nil, fmt.Errorf("export content: %w", err)
+	}
+	now := time.Now()
+	expires := now.Add(exportGrantLifeTime)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iat":     now.Unix(),
+		"exp":     expires.Unix(),
+		"user":    token.Login,
+		"scope":   exportScope,
+		"uid":     uid,
+		"secrets": secrets,
+	}).SignedString(srv.grantSecret)
+	if err != nil {
+		return nil, fmt.Errorf("sign grant: %w", err)
+	}
+	summary := "export granted"
+	if secrets {
+		summary += " with secrets"
+	}
+	record(srv.journal, token, lambdaChange(fn, application.ChangeTransfer, summary))
+	return &api.TransferGrant{UID: uid, Token: signed, Hash: api.ArchiveHash(archive), Secrets: secrets, Expires: expires}, nil
+}
+
+func (srv *lambdaSrv) Export(ctx context.Context, grant string, uid string) (*api.LambdaExport, error) {
+	login, secrets, err := srv.parseGrant(grant, uid)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := exportContent(fn.Lambda)
+	if err != nil {
+		return nil, fmt.Errorf("export content: %w", err)
+	}
+	manifest := fn.Lambda.Manifest()
+	if !secrets && len(manifest.Environment) > 0 {
+		env := make(map[string]string, len(manifest.Environment))
+		for name := range manifest.Environment {
+			env[name] = ""
+		}
+		manifest.Environment = env
+	}
+	aliases := make([]string, 0, len(fn.Aliases))
+	for alias := range fn.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	record(srv.journal, &api.Token{Login: login}, lambdaChange(fn, application.ChangeTransfer, "exported to another server"))
+	return &api.LambdaExport{UID: uid, Manifest: manifest, Aliases: aliases, Content: archive}, nil
+}
+
+// check signature, expiration and scope of grant. Returns login of user who issued grant
+func (srv *lambdaSrv) parseGrant(grant string, uid string) (string, bool, error) {
+	parsed, err := jwt.Parse(grant, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return srv.grantSecret, nil
+	})
+	if err != nil {
+		return "", false, &jsonrpc2.Error{Code: 403, Message: fmt.Sprintf("grant validation failed: %s", err)}
+	}
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	if claims["scope"] != exportScope || claims["uid"] != uid {
+		return "", false, &jsonrpc2.Error{Code: 403, Message: "grant validation failed: grant is not for export of " + uid}
+	}
+	login, _ := claims["user"].(string)
+	secrets, _ := claims["secrets"].(bool)
+	return login, secrets, nil
+}
+
+// content of lambda as .tar.gz archive without manifest files: manifest is exported separately. Archive of the same
+// content is the same, so it could be verified by hash
+func exportContent(fn application.Lambda) ([]byte, error) {
+	var content bytes.Buffer
+	if err := fn.Content(&content); err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(&content)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	zipped := gzip.NewWriter(&out)
+	writer := tar.NewWriter(zipped)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name := path.Clean(header.Name); name == internal.ManifestFile || name == internal.ManifestYAML {
+			continue
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(writer, reader); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := zipped.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (srv *projectSrv) Transfer(ctx context.Context, token *api.Token, request api.TransferRequest) (*api.TransferReport, error) {
+	if request.Source == "" || request.Grant.UID == "" || request.Grant.Token == "" || request.Grant.Hash == "" {
+		return nil, fmt.Errorf("source and grant are required")
+	}
+	if _, err := srv.cases.Platform().FindByUID(request.Grant.UID); err == nil {
+		return nil, &jsonrpc2.Error{Code: 409, Message: fmt.Sprintf("%s: %s", application.ErrLambdaExists, request.Grant.UID)}
+	}
+	if err := srv.transfers.start(request.ID); err != nil {
+		return nil, err
+	}
+	report, err := srv.transfer(ctx, token, request)
+	srv.transfers.finish(request.ID, err)
+	return report, err
+}
+
+func (srv *projectSrv) transfer(ctx context.Context, token *api.Token, request api.TransferRequest) (*api.TransferReport, error) {
+	source := &client.LambdaAPIClient{BaseURL: strings.TrimRight(request.Source, "/") + "/u/"}
+	export, err := source.Export(ctx, request.Grant.Token, request.Grant.UID)
+	if err != nil {
+		return nil, fmt.Errorf("export from source: %w", err)
+	}
+	srv.transfers.update(request.ID, api.TransferVerify, int64(len(export.Content)))
+	if export.UID != request.Grant.UID {
+		return nil, fmt.Errorf("source exported %s instead of %s", export.UID, request.Grant.UID)
+	}
+	if hash := api.ArchiveHash(export.Content); hash != request.Grant.Hash {
+		return nil, fmt.Errorf("hash of received content %s differs from hash of grant %s: content is changed after grant or damaged", hash, request.Grant.Hash)
+	}
+	srv.transfers.update(request.ID, api.TransferImport, int64(len(export.Content)))
+	skipped, err := srv.cases.Import(ctx, export.UID, export.Manifest, bytes.NewReader(export.Content), export.Aliases)
+	if errors.Is(err, application.ErrLambdaExists) {
+		return nil, &jsonrpc2.Error{Code: 409, Message: err.Error()}
+	} else if err != nil {
+		return nil, validationError(err)
+	}
+	def, err := srv.cases.Platform().FindByUID(export.UID)
+	if err != nil {
+		return nil, err
+	}
+	srv.hooks.Deployed(ctx, application.DeployEvent{UID: def.UID, Kind: application.DeployCreate})
+	record(srv.journal, token, lambdaChange(def, application.ChangeTransfer, "lambda transferred from "+request.Source))
+	// startup action outlives the request
+	srv.cases.Platform().Start(context.Background(), def.Lambda)
+	return &api.TransferReport{Definition: def, Skipped: skipped}, nil
+}
+
+func (srv *projectSrv) TransferProgress(ctx context.Context, token *api.Token, id string) (*api.TransferProgress, error) {
+	progress, ok := srv.transfers.get(id)
+	if !ok {
+		return nil, fmt.Errorf("transfer %s not found", id)
+	}
+	return progress, nil
+}
+
+// progress of transfers by ID of request. Transfers without ID are not tracked
+type transfers struct {
+	lock sync.Mutex
+	byID map[string]*api.TransferProgress
+}
+
+func newTransfers() *transfers {
+	return &transfers{byID: make(map[string]*api.TransferProgress)}
+}
+
+func (ts *transfers) start(id string) error {
+	if id == "" {
+		return nil
+	}
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	if _, exists := ts.byID[id]; exists {
+		return fmt.Errorf("transfer %s already exists", id)
+	}
+	ts.byID[id] = &api.TransferProgress{ID: id, Stage: api.TransferDownload}
+	return nil
+}
+
+func (ts *transfers) update(id string, stage string, size int64) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	if progress, ok := ts.byID[id]; ok {
+		progress.Stage = stage
+		progress.Size = size
+	}
+}
+
+// mark transfer as done or failed and forget it after transferKeep
+func (ts *transfers) finish(id string, err error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	progress, ok := ts.byID[id]
+	if !ok {
+		return
+	}
+	progress.Stage = api.TransferDone
+	if err != nil {
+		progress.Stage = api.TransferFailed
+		progress.Error = err.Error()
+	}
+	time.AfterFunc(transferKeep, func() {
+		ts.lock.Lock()
+		defer ts.lock.Unlock()
+		delete(ts.byID, id)
+	})
+}
+
+func (ts *transfers) get(id string) (*api.TransferProgress, bool) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	progress, ok := ts.byID[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *progress
+	return &snapshot, true
+}
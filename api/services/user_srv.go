@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +19,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/reddec/jsonrpc2"
 	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
 )
 
 const (
@@ -22,8 +27,15 @@ const (
 	defaultLogin    = "admin"
 )
 
-func CreateUserSrv(configFile string, initialPassword string) (*userSrv, error) {
-	if srv, err := LoadUserSrv(configFile); err == nil {
+// CreateUserSrv loads admin account from file or creates it by initial password. Passwords are hashed by argon2id with
+// hashing parameters, stored legacy hashes (salted SHA-512) and hashes by other parameters are replaced on successful
+// login
+func CreateUserSrv(configFile string, initialPassword string, hashing PasswordHashing, journal application.Journal) (*userSrv, error) {
+	if err := hashing.Validate(); err != nil {
+		return nil, err
+	}
+	if srv, err := LoadUserSrv(configFile, hashing); err == nil {
+		srv.journal = journal
 		return srv, nil
 	}
 
@@ -33,7 +45,10 @@ func CreateUserSrv(configFile string, initialPassword string) (*userSrv, error)
 			LifeTime: defaultLifeTime,
 			Admin:    defaultLogin,
 		},
-		secret: uuid.New().String(),
+		secret:  uuid.New().String(),
+		hashing: hashing,
+		journal: journal,
+		guard:   newLoginGuard(DefaultLoginLimits),
 	}
 	err := os.MkdirAll(filepath.Dir(configFile), 0755)
 	if err != nil {
@@ -45,7 +60,7 @@ func CreateUserSrv(configFile string, initialPassword string) (*userSrv, error)
 	return srv, err
 }
 
-func LoadUserSrv(configFile string) (*userSrv, error) {
+func LoadUserSrv(configFile string, hashing PasswordHashing) (*userSrv, error) {
 	var cfg userConfig
 	err := cfg.ReadFile(configFile)
 	if err != nil {
@@ -55,6 +70,8 @@ func LoadUserSrv(configFile string) (*userSrv, error) {
 		configFile: configFile,
 		config:     cfg,
 		secret:     uuid.New().String(),
+		hashing:    hashing,
+		guard:      newLoginGuard(DefaultLoginLimits),
 	}, nil
 }
 
@@ -62,20 +79,47 @@ type userSrv struct {
 	configFile string
 	config     userConfig
 	secret     string
+	hashing    PasswordHashing
+	journal    application.Journal // optional journal of changes
+	guard      *loginGuard         // failed logins by accounts and client addresses
 	lock       sync.RWMutex
 }
 
+// SetLoginLimits replaces protection of login against brute-force (by default - DefaultLoginLimits).
+func (srv *userSrv) SetLoginLimits(limits LoginLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	srv.guard.setLimits(limits)
+	return nil
+}
+
 func (srv *userSrv) Login(ctx context.Context, login, password string) (*api.Token, error) {
+	address := api.CallerFrom(ctx).Address
+	if err := srv.guard.check(ctx, login, address); err != nil {
+		return nil, err
+	}
+	// password is hashed without lock: hashing is slow by design
 	srv.lock.RLock()
-	defer srv.lock.RUnlock()
-	err := srv.config.ValidateUser(login, password)
+	stored := srv.config
+	srv.lock.RUnlock()
+	outdated, err := stored.ValidateUser(login, password, srv.hashing)
 	if err != nil {
+		srv.rejected(login, "login of "+login+" failed: invalid password or login")
+		if locked := srv.guard.fail(login, address); len(locked) > 0 {
+			log.Println("[WARN]", "login locked out:", describeLockouts(locked))
+			srv.rejected(login, "locked out after failed logins: "+describeLockouts(locked))
+		}
 		return nil, err
 	}
+	srv.guard.reset(login, address)
+	if outdated {
+		srv.rehash(stored, password)
+	}
 	now := time.Now()
 	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iat":  now.Unix(),
-		"exp":  now.Add(srv.config.LifeTime).Unix(),
+		"exp":  now.Add(stored.LifeTime).Unix(),
 		"user": login,
 	})
 	v, err := tok.SignedString([]byte(srv.secret))
@@ -86,19 +130,54 @@ func (srv *userSrv) ChangePassword(ctx context.Context, token *api.Token, passwo
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
 
-	salt := uuid.New().String()
-	data := sha512.Sum512([]byte(password + salt))
-	srv.config.Salt = salt
-	srv.config.Hash = data[:]
+	hash, err := srv.hashing.hash(password)
+	if err != nil {
+		return false, err
+	}
+	previous := srv.config
+	srv.config.Password, srv.config.Salt, srv.config.Hash = hash, "", nil
+
+	err = srv.config.WriteFile(srv.configFile)
+	if err != nil {
+		srv.config = previous
+		return false, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeUser, Summary: "password of " + srv.config.Admin + " changed"})
+	return true, nil
+}
 
-	err := srv.config.WriteFile(srv.configFile)
-	return err == nil, err
+func (srv *userSrv) Lockouts(ctx context.Context, token *api.Token) ([]api.Lockout, error) {
+	return srv.guard.list(), nil
+}
+
+func (srv *userSrv) Unlock(ctx context.Context, token *api.Token, subject string) (int, error) {
+	cleared := srv.guard.clear(subject)
+	if cleared > 0 {
+		summary := "lockouts of all accounts and addresses cleared"
+		if subject != "" {
+			summary = "lockout of " + subject + " cleared"
+		}
+		change := application.Change{Kind: application.ChangeUser, Summary: summary}
+		if token == nil && api.CallerFrom(ctx).Local {
+			change.Actor = "local"
+		}
+		record(srv.journal, token, change)
+	}
+	return cleared, nil
 }
 
 func (srv *userSrv) ValidateToken(ctx context.Context, token *api.Token) error {
+	if token == nil && api.CallerFrom(ctx).Local {
+		// local admin socket is accessible only by owner of server files
+		return nil
+	}
 	if token == nil {
+		srv.rejected("", "call without token rejected")
 		return fmt.Errorf("token not provided")
 	}
+	if strings.HasPrefix(token.Data, apiTokenPrefix) {
+		return srv.validateAPIToken(token)
+	}
 	claims, err := jwt.Parse(token.Data, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -106,7 +185,12 @@ func (srv *userSrv) ValidateToken(ctx context.Context, token *api.Token) error {
 		return []byte(srv.secret), nil
 	})
 
+	var validation *jwt.ValidationError
+	if errors.As(err, &validation) && validation.Errors&jwt.ValidationErrorExpired != 0 {
+		return expiredError(time.Time{})
+	}
 	if err != nil {
+		srv.rejected("", "invalid login token rejected")
 		return &jsonrpc2.Error{
 			Code:    403,
 			Message: fmt.Sprintf("token validation failed: %s", err),
@@ -121,6 +205,7 @@ func (srv *userSrv) ValidateToken(ctx context.Context, token *api.Token) error {
 		}
 	}
 	if token.Login == "" {
+		srv.rejected("", "login token without login rejected")
 		return &jsonrpc2.Error{
 			Code:    1403,
 			Message: fmt.Sprintf("token validation failed: no login in payload"),
@@ -130,11 +215,44 @@ func (srv *userSrv) ValidateToken(ctx context.Context, token *api.Token) error {
 	return nil
 }
 
+// replace validated password hash by argon2id hash with current parameters, unless password was changed after
+// validation. Failed replacement is not a failure of login: old hash is still valid
+func (srv *userSrv) rehash(validated userConfig, password string) {
+	hash, err := srv.hashing.hash(password)
+	if err != nil {
+		log.Println("[WARN]", "rehash admin password:", err)
+		return
+	}
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if srv.config.Password != validated.Password || !bytes.Equal(srv.config.Hash, validated.Hash) {
+		return
+	}
+	previous := srv.config
+	srv.config.Password, srv.config.Salt, srv.config.Hash = hash, "", nil
+	if err := srv.config.WriteFile(srv.configFile); err != nil {
+		srv.config = previous
+		log.Println("[WARN]", "save rehashed admin password:", err)
+		return
+	}
+	if validated.Password == "" {
+		log.Println("admin password migrated to argon2id")
+	}
+}
+
+// record failed authentication with attempted login (if known). Expired login tokens are not recorded: they are
+// renewed by clients
+func (srv *userSrv) rejected(actor, summary string) {
+	record(srv.journal, nil, application.Change{Kind: application.ChangeAuth, Actor: actor, Summary: summary})
+}
+
 type userConfig struct {
-	Admin    string        `json:"admin"`     // login for admin authorization
-	Salt     string        `json:"salt"`      // password salt
-	Hash     []byte        `json:"hash"`      // password hash
-	LifeTime time.Duration `json:"life_time"` // life time for JWT
+	Admin    string        `json:"admin"`              // login for admin authorization
+	Password string        `json:"password,omitempty"` // argon2id hash of password in PHC string format
+	Salt     string        `json:"salt,omitempty"`     // salt of legacy hash of password (before argon2id)
+	Hash     []byte        `json:"hash,omitempty"`     // legacy hash of password: SHA-512 of password and salt
+	LifeTime time.Duration `json:"life_time"`          // life time for JWT
+	Tokens   []storedToken `json:"tokens,omitempty"`   // API tokens
 }
 
 func (uc *userConfig) ReadFile(filename string) error {
@@ -157,10 +275,26 @@ func (uc *userConfig) WriteFile(filename string) error {
 	return enc.Encode(uc)
 }
 
-func (uc *userConfig) ValidateUser(login, password string) error {
-	data := sha512.Sum512([]byte(password + uc.Salt))
-	if bytes.Compare(data[:], uc.Hash) != 0 || uc.Admin != login {
-		return fmt.Errorf("password or login is invalid")
+// ValidateUser checks login and password in constant time (password is hashed even for unknown login). Successful
+// validation reports whether stored hash is outdated: legacy or by other hashing parameters
+func (uc *userConfig) ValidateUser(login, password string, hashing PasswordHashing) (bool, error) {
+	validLogin := subtle.ConstantTimeCompare([]byte(uc.Admin), []byte(login)) == 1
+	var validPassword, outdated bool
+	if uc.Password != "" {
+		var params PasswordHashing
+		var err error
+		validPassword, params, err = verifyPassword(password, uc.Password)
+		if err != nil {
+			log.Println("[ERROR]", "verify admin password:", err)
+		}
+		outdated = params != hashing
+	} else {
+		data := sha512.Sum512([]byte(password + uc.Salt))
+		validPassword = len(uc.Hash) > 0 && subtle.ConstantTimeCompare(data[:], uc.Hash) == 1
+		outdated = true
 	}
-	return nil
+	if !validPassword || !validLogin {
+		return false, fmt.Errorf("password or login is invalid")
+	}
+	return outdated, nil
 }
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"crypto/sha512"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/jsonrpc2"
+	"github.com/reddec/trusted-cgi/api"
+)
+
+var testHashing = PasswordHashing{Time: 1, Memory: 8 * 1024, Threads: 1}
+
+func TestUserSrv_legacyLogin(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.json")
+	legacy := sha512.Sum512([]byte("secret" + "some-salt"))
+	cfg := userConfig{Admin: "admin", Salt: "some-salt", Hash: legacy[:], LifeTime: time.Hour}
+	require.NoError(t, cfg.WriteFile(file))
+
+	srv, err := CreateUserSrv(file, "initial", testHashing, nil)
+	require.NoError(t, err)
+	_, err = srv.Login(context.Background(), "admin", "initial")
+	assert.Error(t, err, "initial password should not replace existent account")
+	_, err = srv.Login(context.Background(), "admin", "wrong")
+	assert.Error(t, err)
+
+	var migrated userConfig
+	require.NoError(t, migrated.ReadFile(file))
+	assert.Empty(t, migrated.Password, "failed login should not migrate hash")
+
+	token, err := srv.Login(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	require.NoError(t, srv.ValidateToken(context.Background(), token))
+
+	migrated = userConfig{}
+	require.NoError(t, migrated.ReadFile(file))
+	assert.True(t, strings.HasPrefix(migrated.Password, "$argon2id$v=19$m=8192,t=1,p=1$"), migrated.Password)
+	assert.Empty(t, migrated.Salt)
+	assert.Empty(t, migrated.Hash)
+	assert.Equal(t, time.Hour, migrated.LifeTime)
+
+	reloaded, err := CreateUserSrv(file, "initial", testHashing, nil)
+	require.NoError(t, err)
+	_, err = reloaded.Login(context.Background(), "admin", "secret")
+	assert.NoError(t, err)
+	_, err = reloaded.Login(context.Background(), "admin", "wrong")
+	assert.Error(t, err)
+}
+
+func TestUserSrv_rehash(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.json")
+	_, err := CreateUserSrv(file, "secret", testHashing, nil)
+	require.NoError(t, err)
+	var stored userConfig
+	require.NoError(t, stored.ReadFile(file))
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$v=19$m=8192,t=1,p=1$"), stored.Password)
+	original := stored.Password
+
+	stronger := PasswordHashing{Time: 2, Memory: 16 * 1024, Threads: 2}
+	srv, err := CreateUserSrv(file, "secret", stronger, nil)
+	require.NoError(t, err)
+	_, err = srv.Login(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	stored = userConfig{}
+	require.NoError(t, stored.ReadFile(file))
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$v=19$m=16384,t=2,p=2$"), stored.Password)
+
+	// hash with current parameters is kept
+	rehashed := stored.Password
+	_, err = srv.Login(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	require.NoError(t, stored.ReadFile(file))
+	assert.Equal(t, rehashed, stored.Password)
+	assert.NotEqual(t, original, stored.Password)
+}
+
+func TestUserSrv_changePassword(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.json")
+	srv, err := CreateUserSrv(file, "secret", testHashing, nil)
+	require.NoError(t, err)
+	_, err = srv.ChangePassword(context.Background(), nil, "changed")
+	require.NoError(t, err)
+	_, err = srv.Login(context.Background(), "admin", "secret")
+	assert.Error(t, err)
+	_, err = srv.Login(context.Background(), "admin", "changed")
+	assert.NoError(t, err)
+}
+
+func TestUserConfig_ValidateUserTiming(t *testing.T) {
+	hash, err := testHashing.hash("secret")
+	require.NoError(t, err)
+	cfg := userConfig{Admin: "admin", Password: hash}
+	measure := func(login, password string) time.Duration {
+		const attempts = 5
+		started := time.Now()
+		for i := 0; i < attempts; i++ {
+			_, _ = cfg.ValidateUser(login, password, testHashing)
+		}
+		return time.Since(started) / attempts
+	}
+	_, err = cfg.ValidateUser("admin", "secret", testHashing)
+	require.NoError(t, err)
+	valid := measure("admin", "secret")
+	// rejection should not be faster than hashing: wrong password and unknown login are hashed as valid ones
+	assert.Greater(t, int64(measure("admin", "wrong")), int64(valid/2))
+	assert.Greater(t, int64(measure("root", "secret")), int64(valid/2))
+	assert.Greater(t, int64(measure("", "")), int64(valid/2))
+}
+
+func TestVerifyPassword(t *testing.T) {
+	hash, err := testHashing.hash("secret")
+	require.NoError(t, err)
+	ok, params, err := verifyPassword("secret", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, testHashing, params)
+	ok, _, err = verifyPassword("Secret", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	other, err := testHashing.hash("secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salt should be random")
+
+	for _, invalid := range []string{
+		"",
+		"$2a$10$abcdefghijklmnopqrstuv",
+		"$argon2i$v=19$m=8192,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=8192,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA$",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!$a2V5",
+	} {
+		_, _, err := verifyPassword("secret", invalid)
+		assert.Error(t, err, invalid)
+	}
+	assert.Error(t, PasswordHashing{Time: 1, Memory: 8, Threads: 4}.Validate())
+	assert.NoError(t, DefaultPasswordHashing.Validate())
+}
+
+func TestUserSrv_lockout(t *testing.T) {
+	srv, err := CreateUserSrv(filepath.Join(t.TempDir(), "server.json"), "secret", testHashing, nil)
+	require.NoError(t, err)
+	require.NoError(t, srv.SetLoginLimits(LoginLimits{Attempts: 3, Lockout: time.Hour}))
+	ctx := api.WithCaller(context.Background(), api.Caller{Address: "10.0.0.1"})
+	other := api.WithCaller(context.Background(), api.Caller{Address: "10.0.0.2"})
+
+	for i := 0; i < 3; i++ {
+		_, err = srv.Login(ctx, "admin", "wrong")
+		assert.EqualError(t, err, "password or login is invalid")
+	}
+	var locked *jsonrpc2.Error
+	_, err = srv.Login(ctx, "admin", "secret")
+	require.ErrorAs(t, err, &locked, "valid password should be rejected while locked out")
+	assert.Equal(t, 429, locked.Code)
+	_, err = srv.Login(other, "admin", "secret")
+	assert.ErrorAs(t, err, &locked, "account should be locked out from any address")
+	_, err = srv.Login(ctx, "root", "secret")
+	assert.ErrorAs(t, err, &locked, "address should be locked out for any login")
+
+	list, err := srv.Lockouts(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, api.Lockout{Subject: "10.0.0.1", Kind: "address", Failures: 3, LockedUntil: list[0].LockedUntil}, list[0])
+	assert.Equal(t, "admin", list[1].Subject)
+	assert.False(t, list[1].LockedUntil.IsZero())
+
+	cleared, err := srv.Unlock(ctx, nil, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cleared)
+	_, err = srv.Login(other, "admin", "secret")
+	assert.NoError(t, err)
+	_, err = srv.Login(ctx, "admin", "secret")
+	assert.ErrorAs(t, err, &locked, "address is still locked out")
+
+	cleared, err = srv.Unlock(ctx, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cleared)
+	_, err = srv.Login(ctx, "admin", "wrong")
+	assert.Error(t, err)
+	_, err = srv.Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	list, err = srv.Lockouts(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, list, "successful login should reset failures")
+}
+
+func TestUserSrv_localCaller(t *testing.T) {
+	srv, err := CreateUserSrv(filepath.Join(t.TempDir(), "server.json"), "secret", testHashing, nil)
+	require.NoError(t, err)
+	assert.Error(t, srv.ValidateToken(context.Background(), nil))
+	assert.NoError(t, srv.ValidateToken(api.WithCaller(context.Background(), api.Caller{Local: true}), nil))
+}
+
+func TestLoginLimits_delay(t *testing.T) {
+	limits := LoginLimits{DelayAfter: 2, Delay: time.Second, MaxDelay: 5 * time.Second}
+	for failures, delay := range []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		assert.Equal(t, delay, limits.delay(failures), failures)
+	}
+	assert.Zero(t, LoginLimits{DelayAfter: 2}.delay(10))
+	assert.Error(t, LoginLimits{Attempts: 3}.Validate())
+	assert.NoError(t, DefaultLoginLimits.Validate())
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	apiTokenPrefix         = "tcgi_"            // prefix of API tokens (see CreateToken) to distinguish them from JWT
+	defaultRotationOverlap = time.Hour          // time when replaced token is still valid after rotation
+	tokenGracePeriod       = 7 * 24 * time.Hour // time to keep expired tokens: they are reported as expired, not unknown
+)
+
+func (srv *userSrv) CreateToken(ctx context.Context, token *api.Token, name string, scope api.Scope, ttl types.JsonDuration) (*api.TokenInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &jsonrpc2.Error{Code: 422, Message: "name of token is required"}
+	}
+	if err := scope.Normalize(); err != nil {
+		return nil, &jsonrpc2.Error{Code: 422, Message: "invalid scope: " + err.Error()}
+	}
+	if ttl < 0 {
+		return nil, &jsonrpc2.Error{Code: 422, Message: "negative time to live of token"}
+	}
+	stored, value, err := newToken(name, scope, time.Duration(ttl))
+	if err != nil {
+		return nil, err
+	}
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	tokens := append(collectTokens(srv.config.Tokens, time.Now()), stored)
+	if err := srv.saveTokens(tokens); err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeUser, Summary: "token " + name + " created with scope " + describeScope(scope) + describeExpiry(stored.Expires)})
+	info := stored.info()
+	info.Token = value
+	return &info, nil
+}
+
+func (srv *userSrv) RotateToken(ctx context.Context, token *api.Token, id string, overlap types.JsonDuration) (*api.TokenInfo, error) {
+	if overlap < 0 {
+		return nil, &jsonrpc2.Error{Code: 422, Message: "negative overlap of tokens"}
+	}
+	if overlap == 0 {
+		overlap = types.JsonDuration(defaultRotationOverlap)
+	}
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	now := time.Now()
+	tokens := collectTokens(srv.config.Tokens, now)
+	idx := findToken(tokens, id)
+	if idx < 0 {
+		return nil, &jsonrpc2.Error{Code: 404, Message: "token " + id + " not found"}
+	}
+	old := tokens[idx]
+	if old.expired(now) {
+		return nil, &jsonrpc2.Error{Code: 409, Message: "token " + id + " is expired"}
+	}
+	var ttl time.Duration
+	if !old.Expires.IsZero() {
+		ttl = old.Expires.Sub(old.Created)
+	}
+	replacement, value, err := newToken(old.Name, old.Scope, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if deadline := now.Add(time.Duration(overlap)); old.Expires.IsZero() || deadline.Before(old.Expires) {
+		old.Expires = deadline
+	}
+	old.ReplacedBy = replacement.ID
+	tokens[idx] = old
+	if err := srv.saveTokens(append(tokens, replacement)); err != nil {
+		return nil, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeUser, Summary: "token " + old.Name + " rotated, replaced token" + describeExpiry(old.Expires)})
+	info := replacement.info()
+	info.Token = value
+	return &info, nil
+}
+
+func (srv *userSrv) Tokens(ctx context.Context, token *api.Token) ([]api.TokenInfo, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	tokens := collectTokens(srv.config.Tokens, time.Now())
+	if len(tokens) != len(srv.config.Tokens) {
+		if err := srv.saveTokens(tokens); err != nil {
+			return nil, err
+		}
+	}
+	var ans = make([]api.TokenInfo, 0, len(tokens))
+	for _, stored := range tokens {
+		ans = append(ans, stored.info())
+	}
+	return ans, nil
+}
+
+func (srv *userSrv) RevokeToken(ctx context.Context, token *api.Token, id string) (bool, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	tokens := collectTokens(srv.config.Tokens, time.Now())
+	idx := findToken(tokens, id)
+	if idx < 0 {
+		return false, &jsonrpc2.Error{Code: 404, Message: "token " + id + " not found"}
+	}
+	revoked := tokens[idx]
+	if err := srv.saveTokens(append(tokens[:idx], tokens[idx+1:]...)); err != nil {
+		return false, err
+	}
+	record(srv.journal, token, application.Change{Kind: application.ChangeUser, Summary: "token " + revoked.Name + " revoked"})
+	return true, nil
+}
+
+// ExportTokens is stored API tokens for backup: values are not kept, only hashes, so restored tokens have the same
+// values. Each token is JSON object with id and name
+func (srv *userSrv) ExportTokens() ([]json.RawMessage, error) {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	tokens := collectTokens(srv.config.Tokens, time.Now())
+	var ans = make([]json.RawMessage, 0, len(tokens))
+	for _, stored := range tokens {
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, data)
+	}
+	return ans, nil
+}
+
+// ImportTokens adds stored API tokens from backup (see ExportTokens). Tokens with existent IDs are error
+func (srv *userSrv) ImportTokens(exported []json.RawMessage) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	tokens := collectTokens(srv.config.Tokens, time.Now())
+	for _, data := range exported {
+		var stored storedToken
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("decode token: %w", err)
+		}
+		if stored.ID == "" || len(stored.Hash) == 0 {
+			return fmt.Errorf("token without ID or hash")
+		}
+		if findToken(tokens, stored.ID) >= 0 {
+			return fmt.Errorf("token %s already exists", stored.ID)
+		}
+		if err := stored.Scope.Normalize(); err != nil {
+			return fmt.Errorf("scope of token %s: %w", stored.ID, err)
+		}
+		tokens = append(tokens, stored)
+	}
+	return srv.saveTokens(tokens)
+}
+
+// API tokens are valid till revoked or expired
+func (srv *userSrv) validateAPIToken(token *api.Token) error {
+	hash := tokenHash(token.Data)
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	for _, stored := range srv.config.Tokens {
+		if subtle.ConstantTimeCompare(stored.Hash, hash) != 1 {
+			continue
+		}
+		if stored.expired(time.Now()) {
+			srv.rejected("token:"+stored.Name, "expired API token "+stored.Name+" rejected")
+			return expiredError(stored.Expires)
+		}
+		scope := stored.Scope
+		token.Login = "token:" + stored.Name
+		token.Scope = &scope
+		return nil
+	}
+	srv.rejected("", "unknown API token rejected")
+	return &jsonrpc2.Error{
+		Code:    403,
+		Message: "token validation failed: unknown API token",
+	}
+}
+
+// replace tokens in config (config is not changed on error)
+func (srv *userSrv) saveTokens(tokens []storedToken) error {
+	previous := srv.config.Tokens
+	srv.config.Tokens = tokens
+	if err := srv.config.WriteFile(srv.configFile); err != nil {
+		srv.config.Tokens = previous
+		return err
+	}
+	return nil
+}
+
+// API token, only hash of value is kept
+type storedToken struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Hash       []byte    `json:"hash"` // SHA-512 of value
+	Scope      api.Scope `json:"scope"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires,omitempty"`     // zero - never expires
+	ReplacedBy string    `json:"replaced_by,omitempty"` // ID of replacement by rotation
+}
+
+func (st storedToken) info() api.TokenInfo {
+	return api.TokenInfo{ID: st.ID, Name: st.Name, Scope: st.Scope, Created: st.Created, Expires: st.Expires, ReplacedBy: st.ReplacedBy}
+}
+
+func (st storedToken) expired(now time.Time) bool {
+	return !st.Expires.IsZero() && !now.Before(st.Expires)
+}
+
+func newToken(name string, scope api.Scope, ttl time.Duration) (storedToken, string, error) {
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return storedToken{}, "", err
+	}
+	value := apiTokenPrefix + hex.EncodeToString(secret[:])
+	now := time.Now()
+	stored := storedToken{
+		ID:      uuid.New().String(),
+		Name:    name,
+		Hash:    tokenHash(value),
+		Scope:   scope,
+		Created: now,
+	}
+	if ttl > 0 {
+		stored.Expires = now.Add(ttl)
+	}
+	return stored, value, nil
+}
+
+// copy of tokens without tokens expired longer than grace period
+func collectTokens(tokens []storedToken, now time.Time) []storedToken {
+	var ans = make([]storedToken, 0, len(tokens))
+	for _, stored := range tokens {
+		if !stored.expired(now.Add(-tokenGracePeriod)) {
+			ans = append(ans, stored)
+		}
+	}
+	return ans
+}
+
+func findToken(tokens []storedToken, id string) int {
+	for i, stored := range tokens {
+		if stored.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// distinct error of expired token (zero time - unknown time of expiration)
+func expiredError(expires time.Time) error {
+	err := &jsonrpc2.Error{
+		Code:    401,
+		Message: "token validation failed: token expired",
+	}
+	if !expires.IsZero() {
+		err.Message += " at " + expires.UTC().Format(time.RFC3339)
+		err.Data = map[string]time.Time{"expired": expires}
+	}
+	return err
+}
+
+func tokenHash(value string) []byte {
+	sum := sha512.Sum512([]byte(value))
+	return sum[:]
+}
+
+func describeScope(scope api.Scope) string {
+	lambdas := "all lambdas"
+	if len(scope.Lambdas) > 0 {
+		lambdas = strings.Join(scope.Lambdas, ",")
+	}
+	return strings.Join(scope.Operations, ",") + " on " + lambdas
+}
+
+func describeExpiry(expires time.Time) string {
+	if expires.IsZero() {
+		return ""
+	}
+	return ", expires at " + expires.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+var errVersionsDisabled = errors.New("versions of lambdas are not available")
+
+// SetVersions enables versions of lambdas taken by uploads and updates of manifests (by default - disabled).
+func (srv *lambdaSrv) SetVersions(versions application.Versions) {
+	srv.versions = versions
+}
+
+func (srv *lambdaSrv) UploadVersion(ctx context.Context, token *api.Token, uid string, archive []byte, message string) (*application.LambdaVersion, error) {
+	if srv.versions == nil {
+		return nil, errVersionsDisabled
+	}
+	if _, err := srv.upload(ctx, token, uid, archive, false); err != nil {
+		return nil, err
+	}
+	// the same files as snapshot of upload: version is annotated
+	return srv.versions.Snapshot(uid, author(token), message)
+}
+
+func (srv *lambdaSrv) Versions(ctx context.Context, token *api.Token, uid string) ([]application.LambdaVersion, error) {
+	if srv.versions == nil {
+		return nil, errVersionsDisabled
+	}
+	if _, err := srv.cases.Platform().FindByUID(uid); err != nil {
+		return nil, err
+	}
+	return srv.versions.Versions(uid), nil
+}
+
+func (srv *lambdaSrv) Rollback(ctx context.Context, token *api.Token, uid string, rollback application.VersionRollback) (*application.RollbackReport, error) {
+	if srv.versions == nil {
+		return nil, errVersionsDisabled
+	}
+	report, err := srv.versions.Rollback(ctx, uid, rollback)
+	if errors.Is(err, application.ErrVersionNotFound) {
+		return nil, &jsonrpc2.Error{Code: 404, Message: err.Error()}
+	} else if errors.Is(err, application.ErrDiskQuota) {
+		return nil, &jsonrpc2.Error{Code: 507, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	def, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	srv.hooks.Deployed(ctx, application.DeployEvent{UID: uid, Kind: application.DeployRollback, Hash: report.Version.ID})
+	record(srv.journal, token, lambdaChange(def, application.ChangeDeploy, "files restored from version "+report.Version.ID))
+	// startup action outlives the request
+	srv.cases.Platform().Start(context.Background(), def.Lambda)
+	return report, nil
+}
+
+// snapshot lambda as the newest version (if versions are enabled) after deploy: failed snapshot does not fail deploy
+func (srv *lambdaSrv) snapshot(token *api.Token, uid string) {
+	if srv.versions == nil {
+		return
+	}
+	if _, err := srv.versions.Snapshot(uid, author(token), ""); err != nil {
+		log.Println("[WARN]", "snapshot version of lambda", uid, "-", err)
+	}
+}
+
+func author(token *api.Token) string {
+	if token == nil {
+		return ""
+	}
+	return token.Login
+}
@@ -0,0 +1,386 @@
+// Package alerts evaluates alert rules of lambdas (defined in manifest) by the stream of invocation records and
+// takes actions of fired rules: lambda could be marked as degraded, disabled or protected by circuit breaker.
+// State of rules is kept in memory.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	maxSamples      = 10000       // per lambda, the oldest samples are dropped
+	maxEvents       = 20          // per rule, the oldest events are dropped
+	notifyTimeLimit = time.Minute // time limit of notification action
+)
+
+// Authors of state changes (except manual reset which is done by user)
+const (
+	byRule    = "rule"
+	byCoolOff = "cool-off"
+	byProbe   = "probe"
+)
+
+// Minimal required platform features
+type Platform interface {
+	FindByUID(uid string) (*application.Definition, error)
+	FindByLink(link string) (*application.Definition, error)
+	Config() application.Config
+}
+
+// New alert rules evaluator. Notification actions are stopped by the context
+func New(ctx context.Context, platform Platform) *alertManager {
+	return &alertManager{
+		ctx:      ctx,
+		platform: platform,
+		now:      time.Now,
+		lambdas:  map[string]*lambdaState{},
+	}
+}
+
+type alertManager struct {
+	ctx      context.Context
+	platform Platform
+	now      func() time.Time
+	lock     sync.Mutex
+	lambdas  map[string]*lambdaState
+}
+
+type sample struct {
+	at       time.Time
+	duration time.Duration
+	failed   bool
+}
+
+type lambdaState struct {
+	uid     string
+	lambda  application.Lambda
+	samples []sample
+	rules   []*ruleState // in order of manifest
+}
+
+type ruleState struct {
+	rule     types.Alert
+	state    string
+	since    time.Time
+	reason   string
+	until    time.Time // end of cool-off
+	from     time.Time // samples before the last reset are not evaluated
+	failures int       // consecutive failures since the last reset
+	probe    time.Time // start of probe request (half-open only), zero - not started
+	events   []application.AlertEvent
+}
+
+func (am *alertManager) Track(record stats.Record) {
+	if record.Rejected {
+		return
+	}
+	def, err := am.find(record)
+	if err != nil {
+		return // not a lambda (queue) or unknown lambda
+	}
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	ls := am.sync(def)
+	if ls == nil {
+		return
+	}
+	now := am.now()
+	current := sample{at: now, duration: record.End.Sub(record.Begin), failed: record.Failed()}
+	ls.add(current, now)
+	for _, rs := range ls.rules {
+		am.expire(ls, rs, now)
+		if current.failed {
+			rs.failures++
+		} else {
+			rs.failures = 0
+		}
+		switch rs.state {
+		case application.AlertOK:
+			if reason := ls.check(rs, now); reason != "" {
+				am.change(ls, rs, now, application.AlertFiring, reason, byRule)
+			}
+		case application.AlertHalfOpen:
+			if rs.probe.IsZero() {
+				continue
+			}
+			if current.failed {
+				am.change(ls, rs, now, application.AlertFiring, "probe failed: "+record.Err, byProbe)
+			} else {
+				am.change(ls, rs, now, application.AlertOK, "", byProbe)
+			}
+		}
+	}
+}
+
+func (am *alertManager) Allow(uid string) (time.Time, error) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	ls, ok := am.lambdas[uid]
+	if !ok {
+		return time.Time{}, nil
+	}
+	now := am.now()
+	var probes []*ruleState
+	for _, rs := range ls.rules {
+		reaction := rs.rule.Reaction()
+		if reaction != types.AlertDisable && reaction != types.AlertBreak {
+			continue
+		}
+		am.expire(ls, rs, now)
+		switch rs.state {
+		case application.AlertFiring:
+			if reaction == types.AlertBreak {
+				return rs.until, fmt.Errorf("circuit of alert %s is open: %s", rs.rule.Name, rs.reason)
+			}
+			return rs.until, fmt.Errorf("lambda is disabled by alert %s: %s", rs.rule.Name, rs.reason)
+		case application.AlertHalfOpen:
+			if !rs.probe.IsZero() && now.Sub(rs.probe) < rs.rule.CoolOffPeriod() {
+				return time.Time{}, fmt.Errorf("circuit of alert %s is half-open: probe request is in progress", rs.rule.Name)
+			}
+			probes = append(probes, rs)
+		}
+	}
+	// the request is the probe of all half-open circuits
+	for _, rs := range probes {
+		rs.probe = now
+	}
+	return time.Time{}, nil
+}
+
+func (am *alertManager) Status(uid string) []application.AlertStatus {
+	def, err := am.platform.FindByUID(uid)
+	if err != nil {
+		return nil
+	}
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	ls := am.sync(def)
+	if ls == nil {
+		return nil
+	}
+	now := am.now()
+	for _, rs := range ls.rules {
+		am.expire(ls, rs, now)
+	}
+	return ls.status()
+}
+
+func (am *alertManager) Reset(uid string, rule string, by string) ([]application.AlertStatus, error) {
+	def, err := am.platform.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	ls := am.sync(def)
+	if ls == nil {
+		if rule != "" {
+			return nil, fmt.Errorf("unknown alert %s", rule)
+		}
+		return nil, nil
+	}
+	now := am.now()
+	var found bool
+	for _, rs := range ls.rules {
+		if rule != "" && rs.rule.Name != rule {
+			continue
+		}
+		found = true
+		if rs.state != application.AlertOK {
+			am.change(ls, rs, now, application.AlertOK, "manual reset", by)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown alert %s", rule)
+	}
+	return ls.status(), nil
+}
+
+// lambda of the record: by link (requests by link have alias) or by UID
+func (am *alertManager) find(record stats.Record) (*application.Definition, error) {
+	if record.Request.Alias != "" {
+		return am.platform.FindByLink(record.Request.Alias)
+	}
+	return am.platform.FindByUID(record.UID)
+}
+
+// update rules of lambda by manifest: state of removed rules is dropped. Returns nil if lambda has no rules
+func (am *alertManager) sync(def *application.Definition) *lambdaState {
+	rules := def.Lambda.Manifest().Alerts
+	if len(rules) == 0 {
+		delete(am.lambdas, def.UID)
+		return nil
+	}
+	ls, ok := am.lambdas[def.UID]
+	if !ok {
+		ls = &lambdaState{uid: def.UID}
+		am.lambdas[def.UID] = ls
+	}
+	ls.lambda = def.Lambda
+	var known = make(map[string]*ruleState, len(ls.rules))
+	for _, rs := range ls.rules {
+		known[rs.rule.Name] = rs
+	}
+	var updated = make([]*ruleState, 0, len(rules))
+	for _, rule := range rules {
+		rs, ok := known[rule.Name]
+		if !ok {
+			rs = &ruleState{state: application.AlertOK}
+		}
+		rs.rule = rule
+		updated = append(updated, rs)
+	}
+	ls.rules = updated
+	return ls
+}
+
+// end cool-off of fired rule: circuit breaker lets probe request through, other rules are reset
+func (am *alertManager) expire(ls *lambdaState, rs *ruleState, now time.Time) {
+	if rs.state != application.AlertFiring || rs.until.IsZero() || now.Before(rs.until) {
+		return
+	}
+	if rs.rule.Reaction() == types.AlertBreak {
+		am.change(ls, rs, now, application.AlertHalfOpen, "", byCoolOff)
+	} else {
+		am.change(ls, rs, now, application.AlertOK, "", byCoolOff)
+	}
+}
+
+// move rule to the state: audit, notify and reset of evaluation (except half-open, it keeps reason)
+func (am *alertManager) change(ls *lambdaState, rs *ruleState, now time.Time, state string, reason string, by string) {
+	event := application.AlertEvent{Time: now, From: rs.state, To: state, Reason: reason, By: by}
+	rs.events = append(rs.events, event)
+	if len(rs.events) > maxEvents {
+		rs.events = rs.events[len(rs.events)-maxEvents:]
+	}
+	rs.state = state
+	rs.since = now
+	rs.probe = time.Time{}
+	rs.until = time.Time{}
+	switch state {
+	case application.AlertFiring:
+		rs.reason = reason
+		if coolOff := rs.rule.CoolOffPeriod(); coolOff > 0 {
+			rs.until = now.Add(coolOff)
+		}
+	case application.AlertOK:
+		rs.reason = ""
+		rs.from = now
+		rs.failures = 0
+	}
+	log.Println("[AUDIT]", "alert", rs.rule.Name, "of lambda", ls.uid, "changed from", event.From, "to", event.To, "by", by, reason)
+	if rs.rule.Notify != "" {
+		am.notify(ls, rs.rule, event)
+	}
+}
+
+// invoke notification action in background
+func (am *alertManager) notify(ls *lambdaState, rule types.Alert, event application.AlertEvent) {
+	var env = make(map[string]string)
+	for k, v := range am.platform.Config().Environment {
+		env[k] = v
+	}
+	env["ALERT_NAME"] = rule.Name
+	env["ALERT_ACTION"] = rule.Reaction()
+	env["ALERT_STATE"] = event.To
+	env["ALERT_PREVIOUS_STATE"] = event.From
+	env["ALERT_REASON"] = event.Reason
+	env["ALERT_LAMBDA"] = ls.uid
+	lambda := ls.lambda
+	go func() {
+		err := lambda.Do(am.ctx, rule.Notify, notifyTimeLimit, env, ioutil.Discard)
+		if err != nil {
+			log.Println("[ERROR]", "notify alert", rule.Name, "of lambda", ls.uid, "by action", rule.Notify+":", err)
+		}
+	}()
+}
+
+// add sample and drop samples outside of the longest window
+func (ls *lambdaState) add(current sample, now time.Time) {
+	var longest time.Duration
+	for _, rs := range ls.rules {
+		if window := rs.rule.SlidingWindow(); window > longest {
+			longest = window
+		}
+	}
+	ls.samples = append(ls.samples, current)
+	cut := sort.Search(len(ls.samples), func(i int) bool {
+		return !ls.samples[i].at.Before(now.Add(-longest))
+	})
+	if n := len(ls.samples) - maxSamples; n > cut {
+		cut = n
+	}
+	ls.samples = ls.samples[cut:]
+}
+
+// condition of rule which is met, empty if none
+func (ls *lambdaState) check(rs *ruleState, now time.Time) string {
+	rule := rs.rule
+	if rule.ConsecutiveFailures > 0 && rs.failures >= rule.ConsecutiveFailures {
+		return fmt.Sprintf("%d consecutive failures", rs.failures)
+	}
+	window := rule.SlidingWindow()
+	from := now.Add(-window)
+	if rs.from.After(from) {
+		from = rs.from
+	}
+	start := sort.Search(len(ls.samples), func(i int) bool {
+		return !ls.samples[i].at.Before(from)
+	})
+	samples := ls.samples[start:]
+	if len(samples) < rule.MinimalCalls() {
+		return ""
+	}
+	if rule.ErrorRate > 0 {
+		var failed int
+		for _, s := range samples {
+			if s.failed {
+				failed++
+			}
+		}
+		if rate := float64(failed) / float64(len(samples)); rate >= rule.ErrorRate {
+			return fmt.Sprintf("error rate %.2f over %v (%d of %d calls failed)", rate, window, failed, len(samples))
+		}
+	}
+	if rule.LatencyP95 > 0 {
+		var durations = make([]time.Duration, len(samples))
+		for i, s := range samples {
+			durations[i] = s.duration
+		}
+		sort.Slice(durations, func(i, j int) bool {
+			return durations[i] < durations[j]
+		})
+		p95 := durations[int(math.Ceil(0.95*float64(len(durations))))-1]
+		if p95 > time.Duration(rule.LatencyP95) {
+			return fmt.Sprintf("latency p95 %v over %v exceeds %v", p95, window, time.Duration(rule.LatencyP95))
+		}
+	}
+	return ""
+}
+
+func (ls *lambdaState) status() []application.AlertStatus {
+	var ans = make([]application.AlertStatus, 0, len(ls.rules))
+	for _, rs := range ls.rules {
+		ans = append(ans, application.AlertStatus{
+			Name:   rs.rule.Name,
+			Action: rs.rule.Reaction(),
+			State:  rs.state,
+			Since:  rs.since,
+			Reason: rs.reason,
+			Until:  rs.until,
+			Events: append([]application.AlertEvent{}, rs.events...),
+		})
+	}
+	return ans
+}
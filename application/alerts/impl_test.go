@@ -0,0 +1,228 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type mockPlatform struct {
+	def *application.Definition
+}
+
+func (mp *mockPlatform) FindByUID(uid string) (*application.Definition, error) {
+	if uid != mp.def.UID {
+		return nil, fmt.Errorf("unknown lambda %s", uid)
+	}
+	return mp.def, nil
+}
+
+func (mp *mockPlatform) FindByLink(link string) (*application.Definition, error) {
+	if !mp.def.Aliases[link] {
+		return nil, fmt.Errorf("unknown link %s", link)
+	}
+	return mp.def, nil
+}
+
+func (mp *mockPlatform) Config() application.Config {
+	return application.Config{Environment: map[string]string{"GLOBAL": "yes"}}
+}
+
+type testEnv struct {
+	dir   string
+	now   time.Time
+	fn    application.Lambda
+	alert *alertManager
+}
+
+func newTestEnv(t *testing.T, rules ...types.Alert) *testEnv {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	fn, err := lambda.DummyPublic(dir, "echo")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Alerts = rules
+	require.NoError(t, fn.SetManifest(manifest))
+
+	env := &testEnv{dir: dir, now: time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC), fn: fn}
+	env.alert = New(context.Background(), &mockPlatform{def: &application.Definition{
+		UID:     "a1b2",
+		Aliases: types.JsonStringSet{"api": true},
+		Lambda:  fn,
+	}})
+	env.alert.now = func() time.Time { return env.now }
+	return env
+}
+
+// invocation took the duration and failed if err is not empty
+func (env *testEnv) call(duration time.Duration, err string) {
+	env.now = env.now.Add(time.Second)
+	env.alert.Track(stats.Record{UID: "a1b2", Begin: env.now.Add(-duration), End: env.now, Err: err})
+}
+
+func (env *testEnv) status(t *testing.T) application.AlertStatus {
+	list := env.alert.Status("a1b2")
+	require.Len(t, list, 1)
+	return list[0]
+}
+
+func TestAlerts_consecutiveFailures(t *testing.T) {
+	env := newTestEnv(t, types.Alert{Name: "crash", ConsecutiveFailures: 3, Action: types.AlertDisable})
+	env.call(0, "failed")
+	env.call(0, "failed")
+	env.call(0, "")
+	env.call(0, "failed")
+	env.call(0, "failed")
+	_, err := env.alert.Allow("a1b2")
+	assert.NoError(t, err, "failures are not consecutive")
+
+	env.call(0, "failed")
+	status := env.status(t)
+	assert.Equal(t, application.AlertFiring, status.State)
+	assert.Equal(t, "3 consecutive failures", status.Reason)
+	assert.True(t, status.Until.IsZero(), "manual reset only")
+
+	until, err := env.alert.Allow("a1b2")
+	assert.Error(t, err)
+	assert.True(t, until.IsZero())
+	env.now = env.now.Add(24 * time.Hour)
+	_, err = env.alert.Allow("a1b2")
+	assert.Error(t, err, "disabled until manual reset")
+
+	list, err := env.alert.Reset("a1b2", "", "admin")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, application.AlertOK, list[0].State)
+	assert.Equal(t, []application.AlertEvent{
+		{Time: env.now.Add(-24 * time.Hour), From: application.AlertOK, To: application.AlertFiring, Reason: "3 consecutive failures", By: "rule"},
+		{Time: env.now, From: application.AlertFiring, To: application.AlertOK, Reason: "manual reset", By: "admin"},
+	}, list[0].Events)
+	_, err = env.alert.Allow("a1b2")
+	assert.NoError(t, err)
+
+	env.call(0, "failed")
+	assert.Equal(t, application.AlertOK, env.status(t).State, "failures before reset are not counted")
+
+	_, err = env.alert.Reset("a1b2", "unknown", "admin")
+	assert.Error(t, err)
+}
+
+func TestAlerts_errorRate(t *testing.T) {
+	env := newTestEnv(t, types.Alert{
+		Name:      "errors",
+		ErrorRate: 0.5,
+		Window:    types.JsonDuration(time.Minute),
+		MinCalls:  4,
+		Action:    types.AlertDegrade,
+		CoolOff:   types.JsonDuration(10 * time.Minute),
+	})
+	env.call(0, "failed")
+	env.call(0, "failed")
+	env.call(0, "failed")
+	assert.Equal(t, application.AlertOK, env.status(t).State, "not enough calls")
+
+	env.now = env.now.Add(time.Minute) // failures are out of window
+	env.call(0, "")
+	env.call(0, "failed")
+	env.call(0, "")
+	env.call(0, "")
+	assert.Equal(t, application.AlertOK, env.status(t).State)
+	env.call(0, "failed")
+	env.call(0, "failed") // 3 of 6
+	status := env.status(t)
+	assert.Equal(t, application.AlertFiring, status.State)
+	assert.Equal(t, "error rate 0.50 over 1m0s (3 of 6 calls failed)", status.Reason)
+	assert.Equal(t, env.now.Add(10*time.Minute), status.Until)
+	_, err := env.alert.Allow("a1b2")
+	assert.NoError(t, err, "degraded lambda serves requests")
+
+	env.now = env.now.Add(10 * time.Minute)
+	status = env.status(t)
+	assert.Equal(t, application.AlertOK, status.State, "re-enabled after cool-off")
+	assert.Equal(t, "cool-off", status.Events[len(status.Events)-1].By)
+}
+
+func TestAlerts_latency(t *testing.T) {
+	env := newTestEnv(t, types.Alert{Name: "slow", LatencyP95: types.JsonDuration(time.Second), MinCalls: 20})
+	for i := 0; i < 19; i++ {
+		env.call(100*time.Millisecond, "")
+	}
+	env.call(2*time.Second, "")
+	assert.Equal(t, application.AlertOK, env.status(t).State, "single slow call is within 5%")
+	env.call(2*time.Second, "")
+	status := env.status(t)
+	assert.Equal(t, application.AlertFiring, status.State)
+	assert.Equal(t, types.AlertNotify, status.Action)
+	assert.Equal(t, "latency p95 2s over 5m0s exceeds 1s", status.Reason)
+}
+
+func TestAlerts_circuitBreaker(t *testing.T) {
+	env := newTestEnv(t, types.Alert{Name: "breaker", ConsecutiveFailures: 2, Action: types.AlertBreak})
+	env.call(0, "failed")
+	env.call(0, "failed")
+	until, err := env.alert.Allow("a1b2")
+	assert.EqualError(t, err, "circuit of alert breaker is open: 2 consecutive failures")
+	assert.Equal(t, env.now.Add(types.DefaultAlertCoolOff), until)
+
+	env.now = env.now.Add(types.DefaultAlertCoolOff)
+	_, err = env.alert.Allow("a1b2")
+	assert.NoError(t, err, "probe")
+	assert.Equal(t, application.AlertHalfOpen, env.status(t).State)
+	_, err = env.alert.Allow("a1b2")
+	assert.Error(t, err, "only one probe")
+
+	env.call(0, "timeout")
+	status := env.status(t)
+	assert.Equal(t, application.AlertFiring, status.State, "failed probe opens circuit")
+	assert.Equal(t, "probe failed: timeout", status.Reason)
+
+	env.now = env.now.Add(types.DefaultAlertCoolOff)
+	_, err = env.alert.Allow("a1b2")
+	require.NoError(t, err)
+	env.call(0, "")
+	assert.Equal(t, application.AlertOK, env.status(t).State, "successful probe closes circuit")
+	_, err = env.alert.Allow("a1b2")
+	assert.NoError(t, err)
+}
+
+func TestAlerts_records(t *testing.T) {
+	env := newTestEnv(t, types.Alert{Name: "crash", ConsecutiveFailures: 2})
+	env.alert.Track(stats.Record{UID: "api", Request: types.Request{Alias: "api"}, Err: "failed"})
+	env.alert.Track(stats.Record{UID: "a1b2", Err: "forbidden", Rejected: true})
+	env.alert.Track(stats.Record{UID: "queue", Err: "failed"})
+	assert.Equal(t, application.AlertOK, env.status(t).State, "rejected and unknown records are ignored")
+	env.alert.Track(stats.Record{UID: "a1b2", Err: "failed"})
+	assert.Equal(t, application.AlertFiring, env.status(t).State, "records by link are tracked")
+
+	manifest := env.fn.Manifest()
+	manifest.Alerts = nil
+	require.NoError(t, env.fn.SetManifest(manifest))
+	assert.Empty(t, env.alert.Status("a1b2"))
+	_, err := env.alert.Allow("a1b2")
+	assert.NoError(t, err)
+}
+
+func TestAlerts_notify(t *testing.T) {
+	env := newTestEnv(t, types.Alert{Name: "crash", ConsecutiveFailures: 1, Notify: "alert"})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(env.dir, "Makefile"),
+		[]byte("alert:\n\techo \"$$ALERT_NAME $$ALERT_STATE $$ALERT_LAMBDA $$GLOBAL\" >> alerts.log\n"), 0755))
+	env.call(0, "failed")
+	assert.Eventually(t, func() bool {
+		data, _ := ioutil.ReadFile(filepath.Join(env.dir, "alerts.log"))
+		return strings.TrimSpace(string(data)) == "crash firing a1b2 yes"
+	}, 5*time.Second, 10*time.Millisecond)
+}
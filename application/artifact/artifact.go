@@ -0,0 +1,231 @@
+// Package artifact imports lambdas from archives by URL (ex: release artifacts of functions). Archive is extracted to
+// staged copy of lambda in internal.ImportDir of project directory, manifest of archive is applied and post-clone
+// action is run there: new lambda is created (or existent replaced) only after all steps succeeded.
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// New importer of lambdas located in project directory by UID. Staged copy is moved to lambda, so staging directory
+// is in the project directory
+func New(platform application.Platform, project string) *Importer {
+	return &Importer{
+		platform: platform,
+		project:  project,
+		dir:      filepath.Join(project, internal.ImportDir),
+	}
+}
+
+// Importer of lambdas from archives by URL
+type Importer struct {
+	Client   *http.Client // client of downloads (nil - http.DefaultClient)
+	MaxSize  int64        // maximum size of downloaded archive (zero - maximum size of upload by security profile)
+	platform application.Platform
+	project  string
+	dir      string
+}
+
+func (im *Importer) Import(ctx context.Context, request application.ArtifactImport) (*application.ArtifactReport, error) {
+	if u, err := url.Parse(request.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("URL of archive should be absolute http or https URL: %q", request.URL)
+	}
+	expected := strings.ToLower(strings.TrimSpace(request.SHA256))
+	if raw, err := hex.DecodeString(expected); err != nil || (expected != "" && len(raw) != sha256.Size) {
+		return nil, fmt.Errorf("invalid SHA-256 %q: expected 64 hex digits", request.SHA256)
+	}
+	var def *application.Definition
+	if request.UID != "" {
+		found, err := im.platform.FindByUID(request.UID)
+		if err != nil {
+			return nil, err
+		}
+		def = found
+	}
+	data, err := im.download(ctx, request.URL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	report := &application.ArtifactReport{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	if expected != "" && expected != report.SHA256 {
+		return nil, fmt.Errorf("SHA-256 of archive %s does not match expected %s", report.SHA256, expected)
+	}
+
+	if err := os.MkdirAll(im.dir, 0700); err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	temp, err := ioutil.TempDir(im.dir, "import-")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	// staged copy is moved to lambda on success
+	defer os.RemoveAll(temp)
+	stage := filepath.Join(temp, "lambda")
+	staged, err := im.stage(def, stage)
+	if err != nil {
+		return nil, err
+	}
+	if err := staged.SetContent(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("extract archive: %w", err)
+	}
+	report.Action, err = im.apply(staged, stage, request.Action)
+	if err != nil {
+		return nil, err
+	}
+	if report.Action != "" {
+		var usage application.Usage
+		if err := im.platform.Do(application.WithUsage(ctx, &usage), staged, report.Action, 0, nil); err != nil {
+			if stderr := strings.TrimSpace(string(usage.Stderr)); stderr != "" {
+				return nil, fmt.Errorf("invoke post-clone %s: %w, stderr: %s", report.Action, err, stderr)
+			}
+			return nil, fmt.Errorf("invoke post-clone %s: %w", report.Action, err)
+		}
+	}
+
+	if def == nil {
+		report.UID, report.Created = uuid.New().String(), true
+		return report, im.create(report.UID, stage)
+	}
+	report.UID = def.UID
+	previous := def.Lambda.Manifest()
+	if err := def.Lambda.Replace(stage); err != nil {
+		return nil, fmt.Errorf("replace files of lambda: %w", err)
+	}
+	if _, err := im.platform.BindAliases(def.UID, previous.Aliases, def.Lambda.Manifest().Aliases, false); err != nil {
+		report.Warning = "archive is imported, aliases are not bound: " + err.Error()
+		def.Lambda.SetWarning(report.Warning)
+	}
+	return report, nil
+}
+
+// staged copy of lambda (empty for new lambda) with settings of platform. Files which are not in archive (ex: data of
+// lambda) are kept as by upload
+func (im *Importer) stage(def *application.Definition, stage string) (application.Lambda, error) {
+	if def != nil {
+		if err := internal.CopyDir(filepath.Join(im.project, def.UID), stage); err != nil {
+			return nil, fmt.Errorf("copy lambda to staging: %w", err)
+		}
+	} else {
+		if err := os.Mkdir(stage, 0755); err != nil {
+			return nil, fmt.Errorf("create staged lambda: %w", err)
+		}
+		if err := (&types.Manifest{}).SaveAs(filepath.Join(stage, internal.ManifestFile)); err != nil {
+			return nil, fmt.Errorf("write manifest: %w", err)
+		}
+	}
+	staged, err := lambda.FromDir(stage)
+	if err != nil {
+		return nil, fmt.Errorf("load staged copy: %w", err)
+	}
+	// content is extracted after setup by platform: upload limits of security profile are applied
+	if err := im.platform.Prepare(staged); err != nil {
+		return nil, fmt.Errorf("prepare staged copy: %w", err)
+	}
+	return staged, nil
+}
+
+// apply manifest of archive: manifest of template metadata (archived template) or manifest file. Returns post-clone
+// action: requested or declared by template
+func (im *Importer) apply(staged application.Lambda, stage string, action string) (string, error) {
+	metadata := filepath.Join(stage, templates.MetadataFile)
+	manifest := staged.Manifest()
+	if _, err := os.Stat(metadata); err == nil {
+		tpl, err := templates.Read(metadata)
+		if err != nil {
+			return "", fmt.Errorf("read %s of archive: %w", templates.MetadataFile, err)
+		}
+		if err := os.Remove(metadata); err != nil {
+			return "", fmt.Errorf("remove %s: %w", templates.MetadataFile, err)
+		}
+		manifest = tpl.Manifest
+		if action == "" {
+			action = tpl.PostClone
+		}
+	}
+	if err := manifest.Validate(); err != nil {
+		return "", fmt.Errorf("invalid manifest of archive: %w", err)
+	}
+	if err := staged.SetManifest(manifest); err != nil {
+		return "", fmt.Errorf("apply manifest of archive: %w", err)
+	}
+	return action, nil
+}
+
+// move staged copy to new lambda and add it to platform
+func (im *Importer) create(uid string, stage string) error {
+	path := filepath.Join(im.project, uid)
+	if err := os.Rename(stage, path); err != nil {
+		return fmt.Errorf("move staged lambda: %w", err)
+	}
+	fn, err := lambda.FromDir(path)
+	if err != nil {
+		_ = os.RemoveAll(path)
+		return fmt.Errorf("load imported lambda: %w", err)
+	}
+	if err := im.platform.Add(uid, fn); err != nil {
+		_ = os.RemoveAll(path)
+		return fmt.Errorf("add imported lambda to platform: %w", err)
+	}
+	if _, err := im.platform.BindAliases(uid, nil, fn.Manifest().Aliases, false); err != nil {
+		im.platform.Remove(uid)
+		_ = os.RemoveAll(path)
+		return fmt.Errorf("bind aliases: %w", err)
+	}
+	return nil
+}
+
+// download archive not bigger than maximum size
+func (im *Importer) download(ctx context.Context, location string) ([]byte, error) {
+	limit := im.MaxSize
+	if limit <= 0 {
+		limit = im.platform.Profile().Upload.Effective().Size
+	}
+	client := im.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download archive: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download archive: unexpected status %s", res.Status)
+	}
+	if res.ContentLength > limit {
+		return nil, fmt.Errorf("%w: archive of %d bytes is bigger than maximum size %d bytes", types.ErrUploadLimit, res.ContentLength, limit)
+	}
+	// one byte over the limit is enough to detect overflow
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("download archive: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: archive is bigger than maximum size %d bytes", types.ErrUploadLimit, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,178 @@
+package artifact_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/artifact"
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const uid = testutil.UID
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serve archives by path
+func serve(t *testing.T, archives map[string][]byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		data, ok := archives[request.URL.Path]
+		if !ok {
+			http.NotFound(writer, request)
+			return
+		}
+		_, _ = writer.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func setup(t *testing.T) (string, application.Platform, *artifact.Importer) {
+	dir, plato := testutil.Platform(t, types.Manifest{Run: []string{"cat", "version.txt"}})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "version.txt"), []byte("v1"), 0644))
+	return dir, plato, artifact.New(plato, dir)
+}
+
+func TestImporter_Import(t *testing.T) {
+	release := tarGz(t, map[string]string{
+		"manifest.json": `{"name":"report","run":["cat","built.txt"],"aliases":["report"]}`,
+		"Makefile":      "build:\n\tcat version.txt > built.txt\n",
+		"version.txt":   "v2",
+	})
+	srv := serve(t, map[string][]byte{
+		"/release.tar.gz": release,
+		"/release.zip":    zipped(t, map[string]string{"manifest.json": `{"run":["cat","version.txt"]}`, "version.txt": "v3"}),
+	})
+	dir, plato, importer := setup(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "data.txt"), []byte("kept"), 0644))
+	ctx := context.Background()
+
+	report, err := importer.Import(ctx, application.ArtifactImport{URL: srv.URL + "/release.tar.gz", SHA256: digest(release), UID: uid, Action: "build"})
+	require.NoError(t, err)
+	assert.Equal(t, uid, report.UID)
+	assert.False(t, report.Created)
+	assert.Equal(t, digest(release), report.SHA256)
+	assert.Equal(t, int64(len(release)), report.Size)
+	assert.Empty(t, report.Warning)
+	assert.Equal(t, "v2", testutil.Invoke(t, plato, uid))
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "report", def.Manifest.Name)
+	assert.True(t, def.Aliases.Has("report"))
+	assert.FileExists(t, filepath.Join(dir, uid, "data.txt"))
+
+	report, err = importer.Import(ctx, application.ArtifactImport{URL: srv.URL + "/release.zip"})
+	require.NoError(t, err)
+	assert.True(t, report.Created)
+	assert.NotEqual(t, uid, report.UID)
+	assert.Equal(t, "v3", testutil.Invoke(t, plato, report.UID))
+	assert.NoFileExists(t, filepath.Join(dir, report.UID, "data.txt"))
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, ".imports"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "staged copies are removed")
+}
+
+func TestImporter_template(t *testing.T) {
+	srv := serve(t, map[string][]byte{
+		"/template.tar.gz": tarGz(t, map[string]string{
+			"template.json": `{"description":"archived template","post_clone":"install","manifest":{"run":["cat","installed.txt"]}}`,
+			"Makefile":      "install:\n\techo installed > installed.txt\n",
+		}),
+	})
+	dir, plato, importer := setup(t)
+
+	report, err := importer.Import(context.Background(), application.ArtifactImport{URL: srv.URL + "/template.tar.gz"})
+	require.NoError(t, err)
+	assert.Equal(t, "install", report.Action)
+	assert.Equal(t, "installed\n", testutil.Invoke(t, plato, report.UID))
+	assert.NoFileExists(t, filepath.Join(dir, report.UID, "template.json"))
+}
+
+func TestImporter_failed(t *testing.T) {
+	traversal := tarGz(t, map[string]string{"../escape.txt": "outside", "version.txt": "v2"})
+	broken := tarGz(t, map[string]string{"Makefile": "build:\n\techo broken build >&2; exit 1\n", "version.txt": "v2"})
+	invalid := tarGz(t, map[string]string{"manifest.json": `{"run":["cat","version.txt"],"time_limit":-1}`, "version.txt": "v2"})
+	srv := serve(t, map[string][]byte{
+		"/traversal.tar.gz": traversal,
+		"/broken.tar.gz":    broken,
+		"/invalid.tar.gz":   invalid,
+		"/text.txt":         []byte("not an archive"),
+	})
+	dir, plato, importer := setup(t)
+	importer.MaxSize = 1024
+	ctx := context.Background()
+
+	cases := map[string]application.ArtifactImport{
+		"sha256 mismatch":  {URL: srv.URL + "/broken.tar.gz", SHA256: digest(invalid), UID: uid},
+		"invalid sha256":   {URL: srv.URL + "/broken.tar.gz", SHA256: "abc", UID: uid},
+		"not found":        {URL: srv.URL + "/missing.tar.gz", UID: uid},
+		"not http":         {URL: "file:///etc/passwd", UID: uid},
+		"unknown lambda":   {URL: srv.URL + "/broken.tar.gz", UID: "22222222-2222-2222-2222-222222222222"},
+		"path traversal":   {URL: srv.URL + "/traversal.tar.gz", UID: uid},
+		"failed action":    {URL: srv.URL + "/broken.tar.gz", UID: uid, Action: "build"},
+		"invalid manifest": {URL: srv.URL + "/invalid.tar.gz", UID: uid},
+		"not an archive":   {URL: srv.URL + "/text.txt", UID: uid},
+	}
+	for name, request := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := importer.Import(ctx, request)
+			assert.Error(t, err)
+			assert.Equal(t, "v1", testutil.Invoke(t, plato, uid), "lambda is not changed")
+		})
+	}
+	_, err := importer.Import(ctx, application.ArtifactImport{URL: srv.URL + "/broken.tar.gz", UID: uid, Action: "build"})
+	assert.Contains(t, err.Error(), "broken build", "stderr of action")
+	_, err = importer.Import(ctx, application.ArtifactImport{URL: srv.URL + "/text.txt", UID: uid})
+	assert.ErrorIs(t, err, application.ErrUnsupportedArchive)
+	assert.NoFileExists(t, filepath.Join(dir, "escape.txt"))
+
+	importer.MaxSize = 10
+	_, err = importer.Import(ctx, application.ArtifactImport{URL: srv.URL + "/broken.tar.gz", UID: uid})
+	assert.ErrorIs(t, err, types.ErrUploadLimit)
+	assert.Len(t, plato.List(), 1, "no lambdas are created")
+}
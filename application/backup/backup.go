@@ -0,0 +1,668 @@
+// Package backup writes whole server (lambdas, policies, queues, API tokens, sealed secrets and settings) to single
+// .tar.gz archive and restores it on another (or the same) server. Archive has header with version of format:
+//
+//	backup.json                   header (see application.BackupHeader)
+//	settings.json                 global environment, effective user, runtime defaults, builds, auto slug
+//	lambdas/<uid>/lambda.json     manifest, links (aliases not declared by manifest) and state
+//	lambdas/<uid>/content.tar.gz  content of lambda
+//	lambdas/<uid>/frozen.tar.gz   the newest frozen artifact of lambda (optional, see application.Freezer)
+//	policies.json                 policies with lambdas
+//	queues.json                   queues with target lambdas
+//	tokens.json                   API tokens, only hashes of values (optional)
+//	secrets.json                  secrets of server store sealed by the key of store (optional)
+//
+// Restore creates only items which do not exist, so it could be repeated; items which exist are conflicts
+// (see application.RestoreOptions).
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Names of files of archive
+const (
+	HeaderFile   = "backup.json"
+	SettingsFile = "settings.json"
+	PoliciesFile = "policies.json"
+	QueuesFile   = "queues.json"
+	TokensFile   = "tokens.json"
+	SecretsFile  = "secrets.json"
+	LambdasDir   = "lambdas"
+	lambdaFile   = "lambda.json"
+	contentFile  = "content.tar.gz"
+	frozenFile   = "frozen.tar.gz"
+)
+
+// Stored API tokens (see services.userSrv)
+type Tokens interface {
+	// Stored tokens, each is JSON object with id and name
+	ExportTokens() ([]json.RawMessage, error)
+	// Add stored tokens, existent IDs are error
+	ImportTokens(tokens []json.RawMessage) error
+}
+
+// Store of secrets which could be backed up (see secrets.Store)
+type Sealed interface {
+	application.Secrets
+	// Snapshot of store with sealed values
+	Snapshot() ([]byte, error)
+	// Values of snapshot by key of store
+	Unseal(snapshot []byte) (map[string]string, error)
+}
+
+// New backup of server. Tokens are not backed up till Tokens field is set
+func New(cases application.Cases, policies application.Policies) *Backup {
+	return &Backup{cases: cases, policies: policies}
+}
+
+// Backup and restore of server
+type Backup struct {
+	Tokens   Tokens              // optional API tokens
+	Version  string              // version of server in header
+	Freezer  application.Freezer // optional frozen artifacts: included by option and restored instead of content
+	cases    application.Cases
+	policies application.Policies
+}
+
+// settings of server in archive
+type settings struct {
+	User        string                   `json:"user,omitempty"`
+	Environment map[string]string        `json:"environment,omitempty"`
+	Runtime     types.Runtime            `json:"runtime"`
+	Builds      application.BuildsConfig `json:"builds"`
+	AutoSlug    bool                     `json:"auto_slug,omitempty"`
+}
+
+// lambda in archive
+type lambda struct {
+	UID      string         `json:"uid"`
+	Manifest types.Manifest `json:"manifest"`
+	Links    []string       `json:"links,omitempty"` // aliases not declared by manifest
+	Disabled bool           `json:"disabled,omitempty"`
+}
+
+func (bk *Backup) Backup(out io.Writer, options application.BackupOptions) error {
+	platform := bk.cases.Platform()
+	config := platform.Config()
+	list := platform.List()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UID < list[j].UID
+	})
+	archive := newWriter(out)
+	header := application.BackupHeader{
+		Format:  application.BackupFormat,
+		Version: bk.Version,
+		Created: time.Now(),
+		Lambdas: len(list),
+	}
+	var tokens []json.RawMessage
+	if bk.Tokens != nil && !options.NoTokens {
+		exported, err := bk.Tokens.ExportTokens()
+		if err != nil {
+			return fmt.Errorf("export tokens: %w", err)
+		}
+		tokens, header.Tokens = exported, true
+	}
+	var snapshot []byte
+	if store, ok := platform.Secrets().(Sealed); ok {
+		data, err := store.Snapshot()
+		if err != nil {
+			return fmt.Errorf("snapshot of secrets: %w", err)
+		}
+		snapshot, header.Secrets = data, true
+	}
+
+	archive.json(HeaderFile, &header)
+	archive.json(SettingsFile, &settings{
+		User:        config.User,
+		Environment: config.Environment,
+		Runtime:     config.Runtime,
+		Builds:      config.Builds,
+		AutoSlug:    config.AutoSlug,
+	})
+	for _, def := range list {
+		if err := bk.backupLambda(archive, def, config.Disabled[def.UID], options.Frozen); err != nil {
+			return fmt.Errorf("backup lambda %s: %w", def.UID, err)
+		}
+	}
+	policies := bk.policies.List()
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].ID < policies[j].ID
+	})
+	archive.json(PoliciesFile, policies)
+	queues := bk.cases.Queues().List()
+	sort.Slice(queues, func(i, j int) bool {
+		return queues[i].Name < queues[j].Name
+	})
+	archive.json(QueuesFile, queues)
+	if header.Tokens {
+		archive.json(TokensFile, tokens)
+	}
+	if header.Secrets {
+		archive.file(SecretsFile, snapshot)
+	}
+	return archive.Close()
+}
+
+func (bk *Backup) backupLambda(archive *writer, def application.Definition, disabled bool, frozen bool) error {
+	var content bytes.Buffer
+	if err := def.Lambda.Content(&content); err != nil {
+		return fmt.Errorf("content: %w", err)
+	}
+	manifest := def.Lambda.Manifest()
+	declared := make(map[string]bool, len(manifest.Aliases))
+	for _, alias := range manifest.Aliases {
+		declared[alias] = true
+	}
+	item := &lambda{UID: def.UID, Manifest: manifest, Disabled: disabled}
+	for alias := range def.Aliases {
+		if !declared[alias] {
+			item.Links = append(item.Links, alias)
+		}
+	}
+	sort.Strings(item.Links)
+	dir := path.Join(LambdasDir, def.UID)
+	archive.json(path.Join(dir, lambdaFile), item)
+	archive.file(path.Join(dir, contentFile), content.Bytes())
+	if !frozen || bk.Freezer == nil {
+		return nil
+	}
+	if artifacts := bk.Freezer.Artifacts(def.UID); len(artifacts) > 0 {
+		var artifact bytes.Buffer
+		if err := bk.Freezer.Download(def.UID, artifacts[0].ID, &artifact); err != nil {
+			return fmt.Errorf("frozen artifact: %w", err)
+		}
+		archive.file(path.Join(dir, frozenFile), artifact.Bytes())
+	}
+	return nil
+}
+
+// content of archive
+type backupContent struct {
+	header   application.BackupHeader
+	settings settings
+	lambdas  []lambda
+	contents map[string][]byte // UID -> content
+	frozen   map[string][]byte // UID -> frozen artifact
+	policies []application.Policy
+	queues   []application.Queue
+	tokens   []json.RawMessage
+	secrets  []byte // snapshot
+}
+
+func (bk *Backup) Restore(ctx context.Context, archive io.Reader, options application.RestoreOptions) (*application.RestoreReport, error) {
+	switch options.Conflict {
+	case "":
+		options.Conflict = application.ConflictSkip
+	case application.ConflictSkip, application.ConflictFail, application.ConflictNewUID:
+	default:
+		return nil, fmt.Errorf("unknown handling of conflicts %q", options.Conflict)
+	}
+	content, err := readBackup(archive)
+	if err != nil {
+		return nil, err
+	}
+	plan := bk.plan(content, options)
+	report := &application.RestoreReport{DryRun: options.DryRun, Header: content.header, Items: plan.items}
+	if conflicts := report.Conflicts(); len(conflicts) > 0 && options.Conflict == application.ConflictFail {
+		return report, fmt.Errorf("%w: %d conflicts, the first is %s %s", application.ErrRestoreConflict, len(conflicts), conflicts[0].Kind, conflicts[0].Name)
+	}
+	if options.DryRun {
+		return report, nil
+	}
+	return report, bk.apply(ctx, content, plan)
+}
+
+// planned restore
+type plan struct {
+	items    []application.RestoreItem
+	settings *application.Config // new config (nil - not changed)
+	secrets  map[string]string   // secrets to set
+	lambdas  []int               // indexes of items of lambdas to create
+	uids     map[string]string   // UID in backup -> UID of restored lambda
+	policies []application.Policy
+	queues   []application.Queue
+	tokens   []json.RawMessage
+}
+
+func (p *plan) add(kind, name, action, target string) *application.RestoreItem {
+	p.items = append(p.items, application.RestoreItem{Kind: kind, Name: name, Action: action, Target: target})
+	return &p.items[len(p.items)-1]
+}
+
+func (p *plan) conflict(kind, name, target, reason string) {
+	item := p.add(kind, name, application.RestoreSkip, target)
+	item.Conflict, item.Reason = true, reason
+}
+
+func (p *plan) skip(kind, name, reason string) {
+	p.add(kind, name, application.RestoreSkip, "").Reason = reason
+}
+
+// items of backup which do not exist. Order of items is order of restore
+func (bk *Backup) plan(content *backupContent, options application.RestoreOptions) *plan {
+	platform := bk.cases.Platform()
+	p := &plan{uids: make(map[string]string)}
+
+	// settings
+	config := platform.Config()
+	changed := false
+	names := make([]string, 0, len(content.settings.Environment))
+	for name := range content.settings.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make(map[string]string, len(config.Environment))
+	for name, value := range config.Environment {
+		env[name] = value
+	}
+	for _, name := range names {
+		value := content.settings.Environment[name]
+		current, exists := env[name]
+		switch {
+		case exists && current == value:
+		case exists:
+			p.conflict(application.RestoreSetting, "environment."+name, "", "variable has another value")
+		default:
+			p.add(application.RestoreSetting, "environment."+name, application.RestoreCreate, "")
+			env[name], changed = value, true
+		}
+	}
+	config.Environment = env
+	setting := func(name string, current, restored interface{}, apply func()) {
+		switch {
+		case reflect.DeepEqual(current, restored):
+		case reflect.ValueOf(current).IsZero():
+			p.add(application.RestoreSetting, name, application.RestoreCreate, "")
+			apply()
+			changed = true
+		default:
+			p.conflict(application.RestoreSetting, name, "", "setting has another value")
+		}
+	}
+	setting("user", config.User, content.settings.User, func() { config.User = content.settings.User })
+	setting("runtime", config.Runtime, content.settings.Runtime, func() { config.Runtime = content.settings.Runtime })
+	setting("builds", config.Builds, content.settings.Builds, func() { config.Builds = content.settings.Builds })
+	setting("auto_slug", config.AutoSlug, content.settings.AutoSlug, func() { config.AutoSlug = content.settings.AutoSlug })
+	if changed {
+		p.settings = &config
+	}
+
+	// secrets before lambdas: references of environment are resolved by restored lambdas
+	if content.secrets != nil {
+		bk.planSecrets(p, content.secrets)
+	}
+
+	// lambdas with aliases
+	bound := make(map[string]bool) // aliases bound by restored lambdas
+	for _, item := range content.lambdas {
+		uid := item.UID
+		action := application.RestoreCreate
+		if _, err := platform.FindByUID(uid); err == nil {
+			if options.Conflict != application.ConflictNewUID {
+				p.conflict(application.RestoreLambda, item.UID, "", "lambda with the same UID exists")
+				continue
+			}
+			uid, action = uuid.New().String(), application.RestoreRename
+		}
+		p.uids[item.UID] = uid
+		p.lambdas = append(p.lambdas, len(p.items))
+		restored := p.add(application.RestoreLambda, item.UID, action, uid)
+		if frozen, ok := content.frozen[item.UID]; ok && bk.Freezer != nil {
+			sum := sha256.Sum256(frozen)
+			restored.Frozen = hex.EncodeToString(sum[:])
+		}
+		for _, alias := range append(append([]string{}, item.Manifest.Aliases...), item.Links...) {
+			if bound[alias] {
+				continue
+			}
+			if def, err := platform.FindByLink(alias); err == nil {
+				p.conflict(application.RestoreAlias, alias, def.UID, "alias is bound to another lambda")
+				continue
+			}
+			bound[alias] = true
+			p.add(application.RestoreAlias, alias, application.RestoreCreate, uid)
+		}
+	}
+
+	// policies of restored lambdas
+	for _, policy := range content.policies {
+		if _, err := bk.policies.Get(policy.ID); err == nil {
+			p.conflict(application.RestorePolicy, policy.ID, "", "policy exists")
+			continue
+		}
+		lambdas := make(types.JsonStringSet)
+		for uid := range policy.Lambdas {
+			if restored, ok := p.uids[uid]; ok {
+				lambdas[restored] = true
+			}
+		}
+		policy.Lambdas = lambdas
+		p.policies = append(p.policies, policy)
+		p.add(application.RestorePolicy, policy.ID, application.RestoreCreate, "")
+	}
+
+	// queues of restored or existent lambdas
+	queues := bk.cases.Queues()
+	for _, queue := range content.queues {
+		if _, err := queues.Get(queue.Name); err == nil {
+			p.conflict(application.RestoreQueue, queue.Name, queue.Target, "queue exists")
+			continue
+		}
+		if restored, ok := p.uids[queue.Target]; ok {
+			queue.Target = restored
+		} else if _, err := platform.FindByUID(queue.Target); err != nil {
+			p.skip(application.RestoreQueue, queue.Name, "target lambda "+queue.Target+" is not restored")
+			continue
+		}
+		p.queues = append(p.queues, queue)
+		p.add(application.RestoreQueue, queue.Name, application.RestoreCreate, queue.Target)
+	}
+
+	// tokens
+	if content.tokens != nil && !options.NoTokens {
+		bk.planTokens(p, content.tokens)
+	}
+	return p
+}
+
+func (bk *Backup) planSecrets(p *plan, snapshot []byte) {
+	var names struct {
+		Secrets map[string]json.RawMessage `json:"secrets"`
+	}
+	_ = json.Unmarshal(snapshot, &names)
+	var sorted = make([]string, 0, len(names.Secrets))
+	for name := range names.Secrets {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	store, ok := bk.cases.Platform().Secrets().(Sealed)
+	if !ok {
+		for _, name := range sorted {
+			p.skip(application.RestoreSecret, name, "store of secrets is disabled")
+		}
+		return
+	}
+	values, err := store.Unseal(snapshot)
+	if err != nil {
+		for _, name := range sorted {
+			p.skip(application.RestoreSecret, name, err.Error())
+		}
+		return
+	}
+	existent := make(map[string]bool)
+	for _, secret := range store.List() {
+		existent[secret.Name] = true
+	}
+	p.secrets = make(map[string]string)
+	for _, name := range sorted {
+		if existent[name] {
+			p.conflict(application.RestoreSecret, name, "", "secret exists")
+			continue
+		}
+		p.secrets[name] = values[name]
+		p.add(application.RestoreSecret, name, application.RestoreCreate, "")
+	}
+}
+
+func (bk *Backup) planTokens(p *plan, tokens []json.RawMessage) {
+	type identity struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if bk.Tokens == nil {
+		for _, data := range tokens {
+			var token identity
+			_ = json.Unmarshal(data, &token)
+			p.skip(application.RestoreToken, token.ID, "API tokens are not restored by the server")
+		}
+		return
+	}
+	existent := make(map[string]bool)
+	if current, err := bk.Tokens.ExportTokens(); err == nil {
+		for _, data := range current {
+			var token identity
+			_ = json.Unmarshal(data, &token)
+			existent[token.ID] = true
+		}
+	}
+	for _, data := range tokens {
+		var token identity
+		if err := json.Unmarshal(data, &token); err != nil || token.ID == "" {
+			p.skip(application.RestoreToken, "", "invalid token")
+			continue
+		}
+		if existent[token.ID] {
+			p.conflict(application.RestoreToken, token.ID, token.Name, "token exists")
+			continue
+		}
+		p.tokens = append(p.tokens, data)
+		p.add(application.RestoreToken, token.ID, application.RestoreCreate, token.Name)
+	}
+}
+
+// restore planned items. Lambdas which could not be imported (ex: manifest is not valid for the server) are skipped
+func (bk *Backup) apply(ctx context.Context, content *backupContent, p *plan) error {
+	platform := bk.cases.Platform()
+	if p.settings != nil {
+		if err := platform.SetConfig(*p.settings); err != nil {
+			return fmt.Errorf("restore settings: %w", err)
+		}
+	}
+	names := make([]string, 0, len(p.secrets))
+	for name := range p.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := platform.Secrets().Set(name, p.secrets[name]); err != nil {
+			return fmt.Errorf("restore secret %s: %w", name, err)
+		}
+	}
+	byUID := make(map[string]*lambda, len(content.lambdas))
+	for i := range content.lambdas {
+		byUID[content.lambdas[i].UID] = &content.lambdas[i]
+	}
+	for _, idx := range p.lambdas {
+		record := &p.items[idx]
+		item := byUID[record.Name]
+		_, err := bk.cases.Import(ctx, record.Target, item.Manifest, bytes.NewReader(content.contents[item.UID]), item.Links)
+		if err != nil {
+			record.Action, record.Reason = application.RestoreSkip, err.Error()
+			continue
+		}
+		if frozen, ok := content.frozen[item.UID]; ok {
+			bk.thaw(record, frozen)
+		}
+		if item.Disabled {
+			if _, err := platform.SetEnabled(record.Target, false); err != nil {
+				return fmt.Errorf("disable lambda %s: %w", record.Target, err)
+			}
+		}
+	}
+	for _, policy := range p.policies {
+		if _, err := bk.policies.Create(policy.ID, policy.Definition); err != nil {
+			return fmt.Errorf("restore policy %s: %w", policy.ID, err)
+		}
+		for uid := range policy.Lambdas {
+			if _, err := platform.FindByUID(uid); err != nil {
+				continue // import failed
+			}
+			if err := bk.policies.Apply(uid, policy.ID); err != nil {
+				return fmt.Errorf("apply policy %s to %s: %w", policy.ID, uid, err)
+			}
+		}
+	}
+	for _, queue := range p.queues {
+		if _, err := platform.FindByUID(queue.Target); err != nil {
+			continue // import failed
+		}
+		if err := bk.cases.Queues().Add(queue); err != nil {
+			return fmt.Errorf("restore queue %s: %w", queue.Name, err)
+		}
+	}
+	if len(p.tokens) > 0 {
+		if err := bk.Tokens.ImportTokens(p.tokens); err != nil {
+			return fmt.Errorf("restore tokens: %w", err)
+		}
+	}
+	return nil
+}
+
+// replace files of restored lambda by frozen artifact, so dependencies are not installed again. Lambda keeps restored
+// content if artifact could not be used
+func (bk *Backup) thaw(record *application.RestoreItem, archive []byte) {
+	if bk.Freezer == nil {
+		record.Reason = "frozen artifact is not restored: frozen artifacts are disabled"
+		return
+	}
+	artifact, err := bk.Freezer.Add(record.Target, bytes.NewReader(archive))
+	if err == nil {
+		_, err = bk.Freezer.Thaw(record.Target, artifact.ID)
+	}
+	if err != nil {
+		record.Frozen, record.Reason = "", "frozen artifact is not restored: "+err.Error()
+	}
+}
+
+func readBackup(archive io.Reader) (*backupContent, error) {
+	zipped, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("read backup: %w", err)
+	}
+	defer zipped.Close()
+	files := make(map[string][]byte)
+	reader := tar.NewReader(zipped)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read backup: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("read %s of backup: %w", header.Name, err)
+		}
+		files[path.Clean(header.Name)] = data
+	}
+	content := &backupContent{contents: make(map[string][]byte), frozen: make(map[string][]byte)}
+	data, ok := files[HeaderFile]
+	if !ok {
+		return nil, fmt.Errorf("not a backup: %s is missing", HeaderFile)
+	}
+	if err := json.Unmarshal(data, &content.header); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", HeaderFile, err)
+	}
+	if content.header.Format < 1 || content.header.Format > application.BackupFormat {
+		return nil, fmt.Errorf("unsupported format %d of backup (supported up to %d)", content.header.Format, application.BackupFormat)
+	}
+	for name, out := range map[string]interface{}{SettingsFile: &content.settings, PoliciesFile: &content.policies, QueuesFile: &content.queues, TokensFile: &content.tokens} {
+		if data, ok := files[name]; ok {
+			if err := json.Unmarshal(data, out); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", name, err)
+			}
+		}
+	}
+	if data, ok := files[SecretsFile]; ok {
+		content.secrets = data
+	}
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, LambdasDir+"/") && path.Base(name) == lambdaFile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var item lambda
+		if err := json.Unmarshal(files[name], &item); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", name, err)
+		}
+		if path.Dir(name) != path.Join(LambdasDir, item.UID) {
+			return nil, fmt.Errorf("UID %s of %s does not match location", item.UID, name)
+		}
+		data, ok := files[path.Join(path.Dir(name), contentFile)]
+		if !ok {
+			return nil, fmt.Errorf("content of lambda %s is missing", item.UID)
+		}
+		content.lambdas = append(content.lambdas, item)
+		content.contents[item.UID] = data
+		if data, ok := files[path.Join(path.Dir(name), frozenFile)]; ok {
+			content.frozen[item.UID] = data
+		}
+	}
+	if len(content.lambdas) != content.header.Lambdas {
+		return nil, fmt.Errorf("backup has %d lambdas instead of %d: archive is damaged", len(content.lambdas), content.header.Lambdas)
+	}
+	return content, nil
+}
+
+// writer of archive, the first error is reported by Close
+type writer struct {
+	zipped *gzip.Writer
+	tar    *tar.Writer
+	time   time.Time
+	err    error
+}
+
+func newWriter(out io.Writer) *writer {
+	zipped := gzip.NewWriter(out)
+	return &writer{zipped: zipped, tar: tar.NewWriter(zipped), time: time.Now()}
+}
+
+func (w *writer) json(name string, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		w.fail(fmt.Errorf("encode %s: %w", name, err))
+		return
+	}
+	w.file(name, data)
+}
+
+func (w *writer) file(name string, data []byte) {
+	if w.err != nil {
+		return
+	}
+	err := w.tar.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0600, Size: int64(len(data)), ModTime: w.time})
+	if err == nil {
+		_, err = w.tar.Write(data)
+	}
+	w.fail(err)
+}
+
+func (w *writer) fail(err error) {
+	if w.err == nil && err != nil {
+		w.err = err
+	}
+}
+
+func (w *writer) Close() error {
+	w.fail(w.tar.Close())
+	w.fail(w.zipped.Close())
+	return w.err
+}
@@ -0,0 +1,132 @@
+package builds
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// caps of cgroup, zero values are not applied
+type caps struct {
+	CPU       float64 // cores
+	Memory    int64   // bytes
+	Processes int
+}
+
+func (c caps) defined() bool {
+	return c.CPU > 0 || c.Memory > 0 || c.Processes > 0
+}
+
+// Guard of resource limits of lambda invocation or worker. Limits are applied the same way as for actions.
+func (pool *Pool) Guard(uid string, limits types.ResourceLimits, cmd *exec.Cmd) (application.ResourceGuard, error) {
+	return pool.guard(uid, types.BuildLimits{}, limits, cmd)
+}
+
+// Unenforced resource limits: CPU requires cgroup, other limits fall back to rlimits if cgroup is not usable.
+func (pool *Pool) Unenforced(limits types.ResourceLimits) []string {
+	pool.lock.Lock()
+	controllers := pool.controllers
+	pool.lock.Unlock()
+	var ans []string
+	if limits.CPUPercent > 0 && !controllers["cpu"] {
+		ans = append(ans, types.ResourceCPU+" (requires delegated cgroup v2 with cpu controller)")
+	}
+	if limits.MemoryMB > 0 && !controllers["memory"] && !internal.RlimitSupported {
+		ans = append(ans, types.ResourceMemory+" (requires delegated cgroup v2 with memory controller)")
+	}
+	if limits.Processes > 0 && !controllers["pids"] && !internal.RlimitSupported {
+		ans = append(ans, types.ResourceProcesses+" (requires delegated cgroup v2 with pids controller)")
+	}
+	if limits.OpenFiles > 0 && !internal.RlimitSupported {
+		ans = append(ans, types.ResourceOpenFiles+" (rlimits are not supported)")
+	}
+	return ans
+}
+
+// prepare command for caps of build and resource limits (the lowest wins): cgroup is attached to command if
+// controllers are available, memory and processes limits fall back to rlimits otherwise
+func (pool *Pool) guard(uid string, build types.BuildLimits, resources types.ResourceLimits, cmd *exec.Cmd) (*guard, error) {
+	pool.lock.Lock()
+	root, controllers := pool.config.Cgroup, pool.controllers
+	pool.lock.Unlock()
+
+	g := &guard{uid: uid, rlimits: internal.Rlimits{OpenFiles: resources.OpenFiles}}
+	limits := caps{CPU: lowest(build.CPU, resources.CPU()), Memory: lowest(build.Memory, resources.Memory()), Processes: resources.Processes}
+	var skipped []string
+	if limits.CPU > 0 && !controllers["cpu"] {
+		limits.CPU = 0
+		skipped = append(skipped, "CPU")
+	}
+	if limits.Memory > 0 && !controllers["memory"] {
+		limits.Memory = 0
+		g.rlimits.Data = resources.Memory()
+		if build.Memory > 0 {
+			skipped = append(skipped, "memory")
+		}
+	}
+	if limits.Processes > 0 && !controllers["pids"] {
+		limits.Processes = 0
+		g.rlimits.Processes = resources.Processes
+	}
+	if len(skipped) > 0 {
+		log.Println("[WARN]", "cgroup is not configured or not supported,", strings.Join(skipped, " and "), "caps of lambda", uid, "are not applied")
+	}
+	if limits.defined() {
+		group, err := newCgroup(root, fmt.Sprintf("%s-%d", uid, atomic.AddUint64(&pool.seq, 1)), limits)
+		if err != nil {
+			return nil, fmt.Errorf("prepare cgroup: %w", err)
+		}
+		group.Attach(cmd)
+		g.group = group
+	}
+	return g, nil
+}
+
+type guard struct {
+	uid     string
+	group   *cgroup // nil - without cgroup
+	rlimits internal.Rlimits
+}
+
+func (g *guard) Started(cmd *exec.Cmd) {
+	if g.rlimits == (internal.Rlimits{}) {
+		return
+	}
+	if err := internal.SetRlimits(cmd.Process.Pid, g.rlimits); err != nil {
+		log.Println("[WARN]", "resource limits of lambda", g.uid, "are not applied:", err)
+	}
+}
+
+func (g *guard) Finish(err error) error {
+	if g.release() && err != nil {
+		return application.ErrMemoryLimit
+	}
+	return err
+}
+
+// remove cgroup and report whether any process was killed by memory cap
+func (g *guard) release() bool {
+	if g.group == nil {
+		return false
+	}
+	oom := g.group.OOMKilled()
+	g.group.Remove()
+	return oom
+}
+
+// the lowest of defined (positive) values, zero - not defined
+func lowest[T int64 | float64](a, b T) T {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	return min(a, b)
+}
@@ -0,0 +1,147 @@
+package builds
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	cpuPeriod      = 100000   // period of CPU quota in microseconds
+	minCPUQuota    = 1000     // minimal CPU quota in microseconds
+	removeAttempts = 20       // attempts to remove cgroup while killed processes are exiting
+	probeName      = ".probe" // cgroup created by probe of delegated root
+)
+
+// check that cgroups could be created in delegated root and return controllers available for them
+func probeCgroup(root string) (map[string]bool, error) {
+	dir := filepath.Join(root, probeName)
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+	defer os.Remove(dir)
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return nil, err
+	}
+	var controllers = make(map[string]bool)
+	for _, name := range strings.Fields(string(data)) {
+		controllers[name] = true
+	}
+	return controllers, nil
+}
+
+// cgroup v2 of single action
+type cgroup struct {
+	dir string
+	fd  *os.File
+}
+
+// create child cgroup of delegated root with caps
+func newCgroup(root, name string, limits caps) (*cgroup, error) {
+	dir := filepath.Join(root, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	group := &cgroup{dir: dir}
+	if err := group.setup(limits); err != nil {
+		group.Remove()
+		return nil, err
+	}
+	return group, nil
+}
+
+func (cg *cgroup) setup(limits caps) error {
+	if limits.CPU > 0 {
+		quota := int64(limits.CPU * cpuPeriod)
+		if quota < minCPUQuota {
+			quota = minCPUQuota
+		}
+		if err := cg.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			return err
+		}
+	}
+	if limits.Memory > 0 {
+		if err := cg.write("memory.max", strconv.FormatInt(limits.Memory, 10)); err != nil {
+			return err
+		}
+		// otherwise memory over cap is swapped out instead of kill
+		if err := cg.write("memory.swap.max", "0"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if limits.Processes > 0 {
+		if err := cg.write("pids.max", strconv.Itoa(limits.Processes)); err != nil {
+			return err
+		}
+	}
+	fd, err := os.Open(cg.dir)
+	if err != nil {
+		return err
+	}
+	cg.fd = fd
+	return nil
+}
+
+func (cg *cgroup) write(name, value string) error {
+	if err := os.WriteFile(filepath.Join(cg.dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+	return nil
+}
+
+// Attach command to cgroup: process is started inside cgroup
+func (cg *cgroup) Attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cg.fd.Fd())
+}
+
+// OOMKilled returns true if any process of cgroup was killed by memory cap
+func (cg *cgroup) OOMKilled() bool {
+	data, err := os.ReadFile(filepath.Join(cg.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, _ := strconv.Atoi(fields[1])
+			return count > 0
+		}
+	}
+	return false
+}
+
+// Remove cgroup. Processes left in background are killed
+func (cg *cgroup) Remove() {
+	if cg.fd != nil {
+		_ = cg.fd.Close()
+	}
+	_ = cg.write("cgroup.kill", "1")
+	var err error
+	for i := 0; i < removeAttempts; i++ {
+		if err = os.Remove(cg.dir); err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Println("[WARN]", "remove cgroup", cg.dir, "-", err)
+}
+
+// set niceness of process group (processes are started in own group)
+func setNice(pid int, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PGRP, pid, nice)
+}
@@ -0,0 +1,51 @@
+package builds_test
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/builds"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestPool_nice(t *testing.T) {
+	pool := builds.New()
+	var out bytes.Buffer
+	err := pool.Run(context.Background(), "nice", &types.BuildLimits{Nice: 7}, nil, 0, func(ctx context.Context) *exec.Cmd {
+		// nested process is started after niceness applied
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "sleep 0.1; nice")
+		cmd.Stdout = &out
+		internal.SetFlags(cmd)
+		return cmd
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "7", strings.TrimSpace(out.String()))
+}
+
+func TestPool_resources(t *testing.T) {
+	pool := builds.New()
+	pool.Configure(application.BuildsConfig{Cgroup: "/not/existent/cgroup"})
+	var out bytes.Buffer
+	limits := &types.ResourceLimits{OpenFiles: 32, Processes: 1000, MemoryMB: 256}
+	err := pool.Run(context.Background(), "rlimits", nil, limits, 0, func(ctx context.Context) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "sleep 0.1; ulimit -n; ulimit -d")
+		cmd.Stdout = &out
+		internal.SetFlags(cmd)
+		return cmd
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "32\n262144\n", out.String(), "rlimits without usable cgroup")
+
+	assert.Empty(t, pool.Unenforced(*limits))
+	unenforced := pool.Unenforced(types.ResourceLimits{CPUPercent: 50})
+	require.Len(t, unenforced, 1)
+	assert.Contains(t, unenforced[0], "cpu_percent")
+}
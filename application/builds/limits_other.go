@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package builds
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+type cgroup struct{}
+
+func probeCgroup(root string) (map[string]bool, error) {
+	return nil, fmt.Errorf("cgroups are supported only on Linux")
+}
+
+func newCgroup(root, name string, limits caps) (*cgroup, error) {
+	return nil, fmt.Errorf("cgroups are supported only on Linux")
+}
+
+func (cg *cgroup) Attach(cmd *exec.Cmd) {}
+
+func (cg *cgroup) OOMKilled() bool { return false }
+
+func (cg *cgroup) Remove() {}
+
+func setNice(pid int, nice int) error {
+	return fmt.Errorf("niceness is supported only on Linux")
+}
@@ -0,0 +1,178 @@
+package builds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// New pool of builds without limits. Use Configure to apply server settings.
+func New() *Pool {
+	return &Pool{}
+}
+
+// Pool of concurrent builds (actions). Actions over maximum are waiting in FIFO queue.
+type Pool struct {
+	lock    sync.Mutex
+	config  application.BuildsConfig
+	running int
+	queue   []*waiter
+	seq     uint64 // sequence of cgroups
+
+	probed      string          // delegated cgroup of controllers
+	controllers map[string]bool // controllers available in delegated cgroup (nil - cgroup is not usable)
+}
+
+type waiter struct {
+	ready chan struct{}
+}
+
+// Configure pool by server settings. Waiting builds are started if maximum increased. New delegated cgroup is probed:
+// cgroup without permissions or controllers is not used.
+func (pool *Pool) Configure(config application.BuildsConfig) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	pool.config = config
+	if config.Cgroup != pool.probed {
+		pool.probed, pool.controllers = config.Cgroup, nil
+		if config.Cgroup != "" {
+			controllers, err := probeCgroup(config.Cgroup)
+			if err != nil {
+				log.Println("[WARN]", "cgroup", config.Cgroup, "is not usable, CPU, memory and processes caps are not applied:", err)
+			} else {
+				pool.controllers = controllers
+			}
+		}
+	}
+	pool.dispatch()
+}
+
+// Limits of action: server defaults overridden by lambda
+func (pool *Pool) Limits(overrides *types.BuildLimits) application.BuildLimits {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	limits := pool.config.Limits
+	if overrides != nil {
+		limits = limits.Override(*overrides)
+	}
+	return application.BuildLimits{
+		BuildLimits: limits,
+		Cgroup:      pool.controllers != nil && limits.Cgroup(),
+	}
+}
+
+// Run command in pool with limits. Returns *application.LimitError if action killed by memory cap or time limit.
+func (pool *Pool) Run(ctx context.Context, uid string, overrides *types.BuildLimits, resources *types.ResourceLimits, timeLimit time.Duration, command func(ctx context.Context) *exec.Cmd) error {
+	submitted := time.Now()
+	position, err := pool.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("wait in queue of builds: %w", err)
+	}
+	defer pool.release()
+
+	limits := pool.Limits(overrides)
+	if report := application.BuildReportFrom(ctx); report != nil {
+		report.Position = position
+		if position > 0 {
+			report.Queued = time.Since(submitted)
+		}
+		report.Limits = limits.BuildLimits
+	}
+
+	if timeLimit > 0 {
+		cctx, cancel := context.WithTimeout(ctx, timeLimit)
+		defer cancel()
+		ctx = cctx
+	}
+	var resourceLimits types.ResourceLimits
+	if resources != nil {
+		resourceLimits = *resources
+	}
+	cmd := command(ctx)
+	guard, err := pool.guard(uid, limits.BuildLimits, resourceLimits, cmd)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		guard.release()
+		return err
+	}
+	guard.Started(cmd)
+	if limits.Nice > 0 {
+		if err := setNice(cmd.Process.Pid, limits.Nice); err != nil {
+			log.Println("[WARN]", "set niceness of lambda", uid, "action:", err)
+		}
+	}
+	err = cmd.Wait()
+	oom := guard.release()
+	if err == nil {
+		return nil
+	}
+	if oom {
+		return &application.LimitError{Limit: "memory", Err: err}
+	}
+	if timeLimit > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &application.LimitError{Limit: "time", Err: err}
+	}
+	return err
+}
+
+// acquire slot for build. Returns position in queue (zero - without waiting)
+func (pool *Pool) acquire(ctx context.Context) (int, error) {
+	pool.lock.Lock()
+	if len(pool.queue) == 0 && pool.free() {
+		pool.running++
+		pool.lock.Unlock()
+		return 0, nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	pool.queue = append(pool.queue, w)
+	position := len(pool.queue)
+	pool.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return position, nil
+	case <-ctx.Done():
+	}
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for i, item := range pool.queue {
+		if item == w {
+			pool.queue = append(pool.queue[:i], pool.queue[i+1:]...)
+			return position, ctx.Err()
+		}
+	}
+	// slot granted concurrently with cancel
+	pool.running--
+	pool.dispatch()
+	return position, ctx.Err()
+}
+
+func (pool *Pool) release() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	pool.running--
+	pool.dispatch()
+}
+
+// start waiting builds while there are free slots. Should be called under lock
+func (pool *Pool) dispatch() {
+	for len(pool.queue) > 0 && pool.free() {
+		w := pool.queue[0]
+		pool.queue = pool.queue[1:]
+		pool.running++
+		close(w.ready)
+	}
+}
+
+func (pool *Pool) free() bool {
+	return pool.config.MaxConcurrent <= 0 || pool.running < pool.config.MaxConcurrent
+}
@@ -0,0 +1,99 @@
+package builds_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/builds"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func command(name string, args ...string) func(ctx context.Context) *exec.Cmd {
+	return func(ctx context.Context) *exec.Cmd {
+		return exec.CommandContext(ctx, name, args...)
+	}
+}
+
+func TestPool_queue(t *testing.T) {
+	pool := builds.New()
+	pool.Configure(application.BuildsConfig{MaxConcurrent: 1})
+
+	first := make(chan error, 1)
+	go func() {
+		first <- pool.Run(context.Background(), "first", nil, nil, 0, command("sleep", "0.3"))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	var report application.BuildReport
+	err := pool.Run(application.WithBuildReport(context.Background(), &report), "second", nil, nil, 0, command("true"))
+	require.NoError(t, err)
+	require.NoError(t, <-first)
+	assert.Equal(t, 1, report.Position)
+	assert.True(t, report.Queued >= 100*time.Millisecond, "queued %v", report.Queued)
+
+	// without waiting
+	report = application.BuildReport{}
+	err = pool.Run(application.WithBuildReport(context.Background(), &report), "third", nil, nil, 0, command("true"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Position)
+}
+
+func TestPool_cancelInQueue(t *testing.T) {
+	pool := builds.New()
+	pool.Configure(application.BuildsConfig{MaxConcurrent: 1})
+
+	first := make(chan error, 1)
+	go func() {
+		first <- pool.Run(context.Background(), "first", nil, nil, 0, command("sleep", "0.3"))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := pool.Run(ctx, "second", nil, nil, 0, command("true"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, <-first)
+
+	// slot of cancelled build is not leaked
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.Run(context.Background(), "third", nil, nil, 0, command("true"))
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("build is not started")
+	}
+}
+
+func TestPool_timeLimit(t *testing.T) {
+	pool := builds.New()
+	err := pool.Run(context.Background(), "slow", nil, nil, 100*time.Millisecond, command("sleep", "5"))
+	var limitErr *application.LimitError
+	require.True(t, errors.As(err, &limitErr), "error %v", err)
+	assert.Equal(t, "time", limitErr.Limit)
+}
+
+func TestPool_limits(t *testing.T) {
+	pool := builds.New()
+	pool.Configure(application.BuildsConfig{Limits: types.BuildLimits{Nice: 5, Memory: 1024}})
+
+	limits := pool.Limits(&types.BuildLimits{Nice: 10})
+	assert.Equal(t, 10, limits.Nice)
+	assert.Equal(t, int64(1024), limits.Memory)
+	assert.False(t, limits.Cgroup, "cgroup is not configured")
+
+	// caps without cgroup are not applied, but action is executed
+	var report application.BuildReport
+	err := pool.Run(application.WithBuildReport(context.Background(), &report), "nice", &types.BuildLimits{Nice: 10}, nil, 0, command("true"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, report.Limits.Nice)
+}
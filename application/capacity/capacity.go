@@ -0,0 +1,315 @@
+// Package capacity computes capacity report of server by already collected data: stats records (including resource
+// usage of invocations), live counters of platform and queues, and disk usage of lambdas and stores.
+package capacity
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	maxRecords    = 100000          // stats records used for report
+	DefaultBudget = 5 * time.Second // time budget of disk usage measurement
+)
+
+var errBudget = errors.New("time budget of disk usage exceeded")
+
+// Minimal required platform features
+type Platform interface {
+	List() []application.Definition
+	Running() map[string]int
+	Config() application.Config
+}
+
+// Minimal required queues features
+type Queues interface {
+	List() []application.Queue
+	Status(queue string) (*application.QueueStatus, error)
+}
+
+// Store of server data measured in report (besides lambdas)
+type Store struct {
+	Name string
+	Path string
+}
+
+// New reporter. Lambdas are located in directory by UID.
+func New(platform Platform, queues Queues, tracker stats.Reader, dir string, stores ...Store) *Reporter {
+	return &Reporter{
+		platform: platform,
+		queues:   queues,
+		tracker:  tracker,
+		dir:      dir,
+		stores:   stores,
+		Budget:   DefaultBudget,
+	}
+}
+
+// Reporter of capacity
+type Reporter struct {
+	Budget   time.Duration // time budget of disk usage measurement, not measured items are reported as -1
+	platform Platform
+	queues   Queues
+	tracker  stats.Reader
+	dir      string
+	stores   []Store
+}
+
+// Report of capacity with usage aggregated in window. Disk usage is measured within time budget (or context
+// deadline), so report is produced in bounded time regardless of number and size of lambdas.
+func (rp *Reporter) Report(ctx context.Context, window time.Duration) (*application.CapacityReport, error) {
+	now := time.Now()
+	deadline := now.Add(rp.Budget)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	records, err := rp.tracker.Last(maxRecords)
+	if err != nil {
+		return nil, err
+	}
+	report := &application.CapacityReport{
+		Generated: now,
+		Window:    types.JsonDuration(window),
+		Lambdas:   []application.LambdaCapacity{},
+		Stores:    []application.StoreUsage{},
+		Queues:    []application.QueueStatus{},
+	}
+	usage, covered := aggregate(records, now.Add(-window), now)
+	report.Records = usage.records
+	report.Covered = types.JsonDuration(now.Sub(covered))
+	report.Peak = usage.total.peak
+
+	running := rp.platform.Running()
+	backlog := make(map[string]int64)
+	for _, q := range rp.queues.List() {
+		status, err := rp.queues.Status(q.Name)
+		if err != nil {
+			continue // removed concurrently
+		}
+		report.Queues = append(report.Queues, *status)
+		backlog[q.Target] += status.Depth
+	}
+
+	for _, def := range rp.platform.List() {
+		item := application.LambdaCapacity{
+			UID:     def.UID,
+			Name:    def.Manifest.Name,
+			Running: running[def.UID],
+			Backlog: backlog[def.UID],
+		}
+		if u, ok := usage.byUID[def.UID]; ok {
+			item.Invocations = u.invocations
+			item.Peak = u.peak
+			item.Busy = types.JsonDuration(u.busy)
+			item.CPU = types.JsonDuration(u.cpu)
+			item.MaxRSS = u.maxRSS
+		}
+		report.Running += item.Running
+		report.Lambdas = append(report.Lambdas, item)
+	}
+	sort.Slice(report.Lambdas, func(i, j int) bool {
+		a, b := report.Lambdas[i], report.Lambdas[j]
+		if a.CPU != b.CPU {
+			return a.CPU > b.CPU
+		}
+		if a.Busy != b.Busy {
+			return a.Busy > b.Busy
+		}
+		return a.UID < b.UID
+	})
+
+	var lambdasSize int64
+	for i := range report.Lambdas {
+		item := &report.Lambdas[i]
+		item.Disk = diskUsage(filepath.Join(rp.dir, item.UID), deadline)
+		if item.Disk < 0 {
+			report.Partial = true
+			lambdasSize = -1
+		} else if lambdasSize >= 0 {
+			lambdasSize += item.Disk
+		}
+	}
+	report.Stores = append(report.Stores, application.StoreUsage{Name: "lambdas", Path: rp.dir, Size: lambdasSize})
+	for _, store := range rp.stores {
+		size := diskUsage(store.Path, deadline)
+		if size < 0 {
+			report.Partial = true
+		}
+		report.Stores = append(report.Stores, application.StoreUsage{Name: store.Name, Path: store.Path, Size: size})
+	}
+
+	report.Projection = project(report, rp.platform.Config().Builds, time.Duration(report.Covered))
+	return report, nil
+}
+
+type lambdaUsage struct {
+	invocations int
+	peak        int
+	busy        time.Duration
+	cpu         time.Duration
+	maxRSS      int64
+	events      []event // start and end of invocations for peak concurrency
+}
+
+type event struct {
+	at    time.Time
+	delta int
+}
+
+type usage struct {
+	records int
+	total   lambdaUsage
+	byUID   map[string]*lambdaUsage
+}
+
+// aggregate records finished after the moment. Rejected and coalesced records are not invoked processes and
+// skipped. Returns beginning of the covered time: the moment or the oldest record if cache doesn't reach it (now
+// if there are no records).
+func aggregate(records []stats.Record, since, now time.Time) (*usage, time.Time) {
+	var ans = &usage{byUID: make(map[string]*lambdaUsage)}
+	covered := now
+	reached := false
+	for _, record := range records {
+		if record.End.Before(since) {
+			reached = true
+			continue
+		}
+		if record.Begin.Before(covered) {
+			covered = record.Begin
+		}
+		ans.records++
+		if record.Rejected || record.Coalesced || !record.End.After(record.Begin) {
+			continue
+		}
+		item, ok := ans.byUID[record.UID]
+		if !ok {
+			item = &lambdaUsage{}
+			ans.byUID[record.UID] = item
+		}
+		weight := record.Weight()
+		for _, u := range []*lambdaUsage{item, &ans.total} {
+			u.invocations += weight
+			u.busy += record.End.Sub(record.Begin) * time.Duration(weight)
+			u.cpu += record.CPU * time.Duration(weight)
+			if record.MaxRSS > u.maxRSS {
+				u.maxRSS = record.MaxRSS
+			}
+			u.events = append(u.events, event{at: record.Begin, delta: 1}, event{at: record.End, delta: -1})
+		}
+	}
+	if reached || covered.Before(since) {
+		covered = since
+	}
+	ans.total.peak = peak(ans.total.events)
+	for _, item := range ans.byUID {
+		item.peak = peak(item.events)
+		item.events = nil
+	}
+	ans.total.events = nil
+	return ans, covered
+}
+
+// maximum of concurrent intervals; end of interval is processed before start at the same moment
+func peak(events []event) int {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+	var current, max int
+	for _, e := range events {
+		current += e.delta
+		if current > max {
+			max = current
+		}
+	}
+	return max
+}
+
+// naive projection: how many more lambdas with average usage of active lambda fit in free CPU and memory
+func project(report *application.CapacityReport, builds application.BuildsConfig, covered time.Duration) application.CapacityProjection {
+	projection := application.CapacityProjection{
+		CPUs:        runtime.NumCPU(),
+		Memory:      hostMemory(),
+		MoreLambdas: -1,
+	}
+	var cpu time.Duration
+	for _, item := range report.Lambdas {
+		if item.Invocations == 0 {
+			continue
+		}
+		projection.ActiveLambdas++
+		cpu += time.Duration(item.CPU)
+		peak := item.Peak
+		if peak < 1 {
+			peak = 1
+		}
+		projection.PeakMemory += item.MaxRSS * int64(peak)
+	}
+	if covered > 0 {
+		projection.CPULoad = cpu.Seconds() / covered.Seconds()
+	}
+	if builds.MaxConcurrent > 0 {
+		projection.BuildsCPU = float64(builds.MaxConcurrent) * builds.Limits.CPU
+		projection.BuildsMemory = int64(builds.MaxConcurrent) * builds.Limits.Memory
+	}
+	if projection.ActiveLambdas == 0 {
+		return projection
+	}
+	active := float64(projection.ActiveLambdas)
+	if perLambda := projection.CPULoad / active; perLambda > 0 {
+		free := float64(projection.CPUs) - projection.BuildsCPU - projection.CPULoad
+		projection.MoreLambdas = fit(free, perLambda)
+		projection.Limit = "cpu"
+	}
+	if perLambda := float64(projection.PeakMemory) / active; perLambda > 0 && projection.Memory > 0 {
+		free := float64(projection.Memory - projection.BuildsMemory - projection.PeakMemory)
+		if more := fit(free, perLambda); projection.MoreLambdas < 0 || more < projection.MoreLambdas {
+			projection.MoreLambdas = more
+			projection.Limit = "memory"
+		}
+	}
+	return projection
+}
+
+func fit(free, perLambda float64) int {
+	if free <= 0 {
+		return 0
+	}
+	return int(math.Min(math.Floor(free/perLambda), math.MaxInt32))
+}
+
+// total size of regular files in directory (or size of file). Returns -1 if deadline reached. Not existent and
+// unreadable entries are skipped, symbolic links are not followed
+func diskUsage(path string, deadline time.Time) int64 {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // not existent or unreadable: skipped
+		}
+		if time.Now().After(deadline) {
+			return errBudget
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errBudget) {
+		return -1
+	}
+	return size
+}
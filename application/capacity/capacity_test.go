@@ -0,0 +1,145 @@
+package capacity_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/capacity"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type fakePlatform struct {
+	lambdas []application.Definition
+	running map[string]int
+	config  application.Config
+}
+
+func (fp *fakePlatform) List() []application.Definition { return fp.lambdas }
+func (fp *fakePlatform) Running() map[string]int        { return fp.running }
+func (fp *fakePlatform) Config() application.Config     { return fp.config }
+
+type fakeQueues map[string]application.QueueStatus
+
+func (fq fakeQueues) List() []application.Queue {
+	var ans []application.Queue
+	for name := range fq {
+		ans = append(ans, application.Queue{Name: name, Target: "a"})
+	}
+	return ans
+}
+
+func (fq fakeQueues) Status(queue string) (*application.QueueStatus, error) {
+	status := fq[queue]
+	return &status, nil
+}
+
+type fakeStats []stats.Record
+
+func (fs fakeStats) LastByUID(uid string, limit int) ([]stats.Record, error) { return nil, nil }
+func (fs fakeStats) Range(query stats.Query) ([]stats.Record, error)         { return nil, nil }
+func (fs fakeStats) Last(limit int) ([]stats.Record, error)                  { return fs, nil }
+func (fs fakeStats) Scan(query stats.Query, handler func(record *stats.Record) bool) error {
+	return nil
+}
+
+func TestReporter_Report(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "static"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "static", "index.html"), make([]byte, 100), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "manifest.json"), make([]byte, 20), 0644))
+
+	now := time.Now()
+	at := func(offset time.Duration) time.Time { return now.Add(-time.Minute + offset) }
+	records := fakeStats{
+		// newest first
+		{UID: "b", Begin: at(20 * time.Second), End: at(30 * time.Second), CPU: time.Second, MaxRSS: 1 << 20, Rate: 10},
+		{UID: "a", Begin: at(5 * time.Second), End: at(6 * time.Second), Coalesced: true},
+		{UID: "a", Begin: at(3 * time.Second), End: at(8 * time.Second), CPU: 2 * time.Second, MaxRSS: 1 << 10},
+		{UID: "a", Begin: at(0), End: at(5 * time.Second), CPU: 3 * time.Second, MaxRSS: 2 << 10},
+		{UID: "a", Begin: at(0), End: at(time.Second), Rejected: true},
+		{UID: "a", Begin: now.Add(-time.Hour), End: now.Add(-time.Hour + time.Second), CPU: time.Hour},
+	}
+	platform := &fakePlatform{
+		lambdas: []application.Definition{
+			{UID: "a", Manifest: types.Manifest{Name: "app"}},
+			{UID: "b"},
+			{UID: "c"},
+		},
+		running: map[string]int{"a": 2},
+	}
+	platform.config.Builds = application.BuildsConfig{MaxConcurrent: 2, Limits: types.BuildLimits{CPU: 0.5, Memory: 1 << 20}}
+	queues := fakeQueues{"jobs": {Name: "jobs", Depth: 7}}
+
+	report, err := capacity.New(platform, queues, records, dir, capacity.Store{Name: "stats", Path: filepath.Join(dir, "missing")}).Report(context.Background(), 2*time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, report.Records, "old record is out of window")
+	assert.Equal(t, 2, report.Running)
+	assert.Equal(t, 2, report.Peak)
+	assert.Equal(t, types.JsonDuration(2*time.Minute), report.Covered, "older record reaches window")
+	assert.False(t, report.Partial)
+	require.Len(t, report.Lambdas, 3)
+
+	b, a, c := report.Lambdas[0], report.Lambdas[1], report.Lambdas[2]
+	assert.Equal(t, "b", b.UID, "sorted by CPU")
+	assert.Equal(t, 10, b.Invocations, "weighted by sampling rate")
+	assert.Equal(t, types.JsonDuration(10*time.Second), b.CPU)
+	assert.Equal(t, types.JsonDuration(100*time.Second), b.Busy)
+
+	assert.Equal(t, "a", a.UID)
+	assert.Equal(t, "app", a.Name)
+	assert.Equal(t, 2, a.Invocations, "rejected and coalesced are not invocations")
+	assert.Equal(t, 2, a.Peak)
+	assert.Equal(t, 2, a.Running)
+	assert.Equal(t, types.JsonDuration(5*time.Second), a.CPU)
+	assert.Equal(t, int64(2<<10), a.MaxRSS)
+	assert.Equal(t, int64(120), a.Disk)
+	assert.Equal(t, int64(7), a.Backlog)
+
+	assert.Equal(t, 0, c.Invocations)
+	assert.Equal(t, int64(0), c.Disk, "not existent directory")
+
+	assert.Equal(t, []application.StoreUsage{
+		{Name: "lambdas", Path: dir, Size: 120},
+		{Name: "stats", Path: filepath.Join(dir, "missing"), Size: 0},
+	}, report.Stores)
+	assert.Len(t, report.Queues, 1)
+
+	projection := report.Projection
+	assert.Equal(t, 2, projection.ActiveLambdas)
+	assert.InDelta(t, 15.0/120, projection.CPULoad, 0.001)
+	assert.Equal(t, 1.0, projection.BuildsCPU)
+	assert.Equal(t, int64(2<<20), projection.BuildsMemory)
+	assert.Equal(t, int64(1<<20+2*(2<<10)), projection.PeakMemory)
+	assert.True(t, projection.MoreLambdas >= 0)
+	assert.NotEmpty(t, projection.Limit)
+}
+
+func TestReporter_budget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a"), 0755))
+
+	platform := &fakePlatform{lambdas: []application.Definition{{UID: "a"}}}
+	reporter := capacity.New(platform, fakeQueues{}, fakeStats{}, dir)
+	reporter.Budget = -time.Second
+	report, err := reporter.Report(context.Background(), time.Hour)
+	require.NoError(t, err)
+	assert.True(t, report.Partial)
+	assert.Equal(t, int64(-1), report.Lambdas[0].Disk)
+	assert.Equal(t, int64(-1), report.Stores[0].Size)
+	assert.Equal(t, -1, report.Projection.MoreLambdas, "no active lambdas")
+	assert.Equal(t, types.JsonDuration(0), report.Covered, "no records")
+}
@@ -0,0 +1,12 @@
+package capacity
+
+import "syscall"
+
+// total memory of host in bytes (zero - unknown)
+func hostMemory() int64 {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return int64(info.Totalram) * int64(info.Unit)
+}
@@ -0,0 +1,5 @@
+//go:build !linux
+
+package capacity
+
+func hostMemory() int64 { return 0 }
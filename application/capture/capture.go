@@ -0,0 +1,164 @@
+// Package capture keeps the newest requests of lambdas with bodies for debugging and replay: one directory per
+// lambda, one JSON file per request. Number of requests per lambda is bounded by manifest of lambda.
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+const suffix = ".json"
+
+// New store of captured requests in directory
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Store of captured requests in directory
+type Store struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// Add captured request of lambda with body. ID and time of capture (if not set) are assigned by store. The oldest
+// requests over limit (zero - unlimited) are removed
+func (s *Store) Add(request application.CapturedRequest, body []byte, limit int) error {
+	if err := checkLambda(request.Lambda); err != nil {
+		return err
+	}
+	request.ID = uuid.New().String()
+	if request.Captured.IsZero() {
+		request.Captured = time.Now()
+	}
+	request.Body = body
+	request.Request.Body = nil
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dir := filepath.Join(s.dir, request.Lambda)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := internal.AtomicWriteJson(filepath.Join(dir, request.ID+suffix), request); err != nil {
+		return fmt.Errorf("save captured request: %w", err)
+	}
+	if limit <= 0 {
+		return nil
+	}
+	requests, err := s.list(request.Lambda)
+	if err != nil {
+		return err
+	}
+	for i := limit; i < len(requests); i++ {
+		if err := s.remove(request.Lambda, requests[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List of captured requests of lambda from the newest without bodies
+func (s *Store) List(lambda string) ([]application.CapturedRequest, error) {
+	if err := checkLambda(lambda); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	requests, err := s.list(lambda)
+	if err != nil {
+		return nil, err
+	}
+	for i := range requests {
+		requests[i].Body = nil
+	}
+	return requests, nil
+}
+
+// Get captured request of lambda with body
+func (s *Store) Get(lambda string, id string) (*application.CapturedRequest, error) {
+	if err := checkLambda(lambda); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid ID of captured request: %w", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.read(lambda, id)
+}
+
+// Purge all captured requests of lambda
+func (s *Store) Purge(lambda string) error {
+	if err := checkLambda(lambda); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return os.RemoveAll(filepath.Join(s.dir, lambda))
+}
+
+// requests of lambda from the newest with bodies, unreadable files are skipped
+func (s *Store) list(lambda string) ([]application.CapturedRequest, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.dir, lambda))
+	if os.IsNotExist(err) {
+		return []application.CapturedRequest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var requests = make([]application.CapturedRequest, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		request, err := s.read(lambda, strings.TrimSuffix(entry.Name(), suffix))
+		if err != nil {
+			log.Println("[WARN]", "captured requests: skip", filepath.Join(lambda, entry.Name()), "-", err)
+			continue
+		}
+		requests = append(requests, *request)
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].Captured.After(requests[j].Captured)
+	})
+	return requests, nil
+}
+
+func (s *Store) read(lambda string, id string) (*application.CapturedRequest, error) {
+	var request application.CapturedRequest
+	if err := internal.ReadJson(s.file(lambda, id), &request); err != nil {
+		return nil, err
+	}
+	if request.ID != id {
+		return nil, errors.New("ID of captured request does not match name of file")
+	}
+	return &request, nil
+}
+
+func (s *Store) remove(lambda string, id string) error {
+	return os.Remove(s.file(lambda, id))
+}
+
+func (s *Store) file(lambda string, id string) string {
+	return filepath.Join(s.dir, lambda, id+suffix)
+}
+
+// lambda is used as name of directory
+func checkLambda(lambda string) error {
+	if lambda == "" || lambda == "." || lambda == ".." || strings.ContainsAny(lambda, `/\`) {
+		return fmt.Errorf("invalid lambda %q", lambda)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package capture_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/capture"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := capture.New(dir)
+	now := time.Now()
+	for i, payload := range []string{"first", "second", "third"} {
+		err := store.Add(application.CapturedRequest{
+			Lambda:   "echo",
+			Captured: now.Add(time.Duration(i) * time.Second),
+			Size:     int64(len(payload)),
+			Request:  types.Request{Method: "POST", Path: "/a/echo", Headers: map[string]string{"X-Index": payload}},
+		}, []byte(payload), 2)
+		require.NoError(t, err)
+	}
+
+	// the oldest is removed by limit
+	requests, err := store.List("echo")
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "third", requests[0].Request.Headers["X-Index"])
+	assert.Equal(t, "second", requests[1].Request.Headers["X-Index"])
+	assert.Empty(t, requests[0].Body)
+
+	request, err := store.Get("echo", requests[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(request.Body))
+	assert.Equal(t, "POST", request.Request.Method)
+
+	_, err = store.Get("echo", "../../etc/passwd")
+	assert.Error(t, err)
+	_, err = store.List("../echo")
+	assert.Error(t, err)
+
+	empty, err := store.List("other")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	require.NoError(t, store.Purge("echo"))
+	requests, err = store.List("echo")
+	require.NoError(t, err)
+	assert.Empty(t, requests)
+}
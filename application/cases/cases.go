@@ -3,20 +3,42 @@ package cases
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/templates"
 	"github.com/reddec/trusted-cgi/types"
 )
 
+// New use-cases with lambdas from the directory. Legacy state of lambdas is migrated, lambdas which could not be
+// migrated are loaded but rejects changes (see Migrated)
 func New(platform application.Platform, queues application.Queues, policies application.Policies, dir, templateDir string) (*casesImpl, error) {
+	cs, err := Load(platform, queues, policies, dir, templateDir)
+	if err != nil {
+		return nil, err
+	}
+	report, err := cs.Migrate(false)
+	if err != nil {
+		return nil, fmt.Errorf("migrate legacy state: %w", err)
+	}
+	logMigration(report)
+	return cs, nil
+}
+
+// Load use-cases with lambdas from the directory without migration of legacy state
+func Load(platform application.Platform, queues application.Queues, policies application.Policies, dir, templateDir string) (*casesImpl, error) {
 	aTemplateDir, err := filepath.Abs(templateDir)
 	if err != nil {
 		return nil, fmt.Errorf("resolve template dir: %w", err)
@@ -26,24 +48,31 @@ func New(platform application.Platform, queues application.Queues, policies appl
 		return nil, fmt.Errorf("resolve root dir: %w", err)
 	}
 	cs := &casesImpl{
-		directory:     aDir,
-		templatesDir:  aTemplateDir,
-		platform:      platform,
-		queues:        queues,
-		policies:      policies,
-		lastScheduler: time.Now(), // avoid running scheduled tasks immediately
+		directory:    aDir,
+		templatesDir: aTemplateDir,
+		platform:     platform,
+		queues:       queues,
+		policies:     policies,
+		scheduler:    scheduler.New(scheduler.System()), // avoid running scheduled tasks immediately
+		started:      time.Now(),
 	}
 	return cs, cs.Scan()
 }
 
 type casesImpl struct {
 	sshLoader
-	lastScheduler time.Time
+	scheduler     *scheduler.Tracker
+	state         *scheduler.State // optional
+	started       time.Time        // start of scheduler: runs before are missed during downtime
+	reevaluations uint64           // schedules evaluated after clock jumps
 	directory     string
 	templatesDir  string
 	platform      application.Platform
 	queues        application.Queues
 	policies      application.Policies
+	captures      application.Captures // optional
+	migrationLock sync.RWMutex
+	unmigrated    map[string]string // lambda UID -> reason why legacy state is not migrated
 }
 
 func (impl *casesImpl) Scan() error {
@@ -63,10 +92,6 @@ func (impl *casesImpl) Scan() error {
 			if err != nil {
 				return fmt.Errorf("add lambda %s to index: %w", uid, err)
 			}
-			err = impl.applyMigration(uid, path, fn)
-			if err != nil {
-				return fmt.Errorf("apply migration for lambda %s: %w", uid, err)
-			}
 		}
 	}
 	return nil
@@ -90,11 +115,21 @@ func (impl *casesImpl) CreateFromGit(ctx context.Context, repo string) (string,
 		_ = os.RemoveAll(path)
 		return uid, fmt.Errorf("clone repo: %w", err)
 	}
+	manifest := fn.Manifest()
+	if err := manifest.Validate(); err != nil {
+		_ = os.RemoveAll(path)
+		return uid, fmt.Errorf("invalid manifest: %w", err)
+	}
 	err = impl.platform.Add(uid, fn)
 	if err != nil {
 		_ = os.RemoveAll(path)
 		return uid, fmt.Errorf("add cloned lambda to platform: %w", err)
 	}
+	if _, err := impl.platform.BindAliases(uid, nil, manifest.Aliases, false); err != nil {
+		impl.platform.Remove(uid)
+		_ = os.RemoveAll(path)
+		return uid, fmt.Errorf("bind aliases: %w", err)
+	}
 	return uid, nil
 }
 
@@ -102,6 +137,9 @@ func (impl *casesImpl) CreateFromTemplate(ctx context.Context, template template
 	uid := uuid.New().String()
 	path := filepath.Join(impl.directory, uid)
 
+	if err := template.Manifest.Validate(); err != nil {
+		return uid, fmt.Errorf("invalid manifest: %w", err)
+	}
 	err := os.MkdirAll(path, 0755)
 	if err != nil {
 		return uid, fmt.Errorf("create working directory: %w", err)
@@ -117,6 +155,23 @@ func (impl *casesImpl) CreateFromTemplate(ctx context.Context, template template
 		_ = os.RemoveAll(path)
 		return uid, fmt.Errorf("add new lambda to platform: %w", err)
 	}
+	if _, err := impl.platform.BindAliases(uid, nil, template.Manifest.Aliases, false); err != nil {
+		impl.platform.Remove(uid)
+		_ = os.RemoveAll(path)
+		return uid, fmt.Errorf("bind aliases: %w", err)
+	}
+	if template.PostClone != "" {
+		var usage application.Usage
+		err = impl.platform.Do(application.WithUsage(ctx, &usage), fn, template.PostClone, 0, nil)
+		if err != nil {
+			impl.platform.Remove(uid)
+			_ = os.RemoveAll(path)
+			if stderr := strings.TrimSpace(string(usage.Stderr)); stderr != "" {
+				return uid, fmt.Errorf("invoke post-clone %s: %w, stderr: %s", template.PostClone, err, stderr)
+			}
+			return uid, fmt.Errorf("invoke post-clone %s: %w", template.PostClone, err)
+		}
+	}
 	return uid, nil
 }
 
@@ -126,16 +181,245 @@ func (impl *casesImpl) Create(ctx context.Context) (string, error) {
 	})
 }
 
+func (impl *casesImpl) Import(ctx context.Context, uid string, manifest types.Manifest, content io.Reader, aliases []string) ([]string, error) {
+	if _, err := uuid.Parse(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID %q: %w", uid, err)
+	}
+	if _, err := impl.platform.FindByUID(uid); err == nil {
+		return nil, fmt.Errorf("%w: %s", application.ErrLambdaExists, uid)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	// aliases bound to other lambdas are skipped, the rest are kept in declaration or linked
+	var links, skipped []string
+	var free = make(map[string]bool)
+	for _, alias := range append(append([]string{}, manifest.Aliases...), aliases...) {
+		if _, seen := free[alias]; seen {
+			continue
+		}
+		_, err := impl.platform.FindByLink(alias)
+		free[alias] = err != nil
+		if err == nil {
+			skipped = append(skipped, alias)
+		}
+	}
+	var declared []string
+	for _, alias := range manifest.Aliases {
+		if free[alias] {
+			declared = append(declared, alias)
+			free[alias] = false // bound by declaration
+		}
+	}
+	for _, alias := range aliases {
+		if free[alias] {
+			links = append(links, alias)
+			free[alias] = false
+		}
+	}
+	manifest.Aliases = declared
+
+	path := filepath.Join(impl.directory, uid)
+	if err := os.Mkdir(path, 0755); os.IsExist(err) {
+		return nil, fmt.Errorf("%w: %s (directory)", application.ErrLambdaExists, uid)
+	} else if err != nil {
+		return nil, fmt.Errorf("create working directory: %w", err)
+	}
+	if err := manifest.SaveAs(filepath.Join(path, internal.ManifestFile)); err != nil {
+		_ = os.RemoveAll(path)
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+	fn, err := lambda.FromDir(path)
+	if err != nil {
+		_ = os.RemoveAll(path)
+		return nil, fmt.Errorf("create lambda: %w", err)
+	}
+	if err := impl.platform.Add(uid, fn); err != nil {
+		_ = os.RemoveAll(path)
+		return nil, fmt.Errorf("add new lambda to platform: %w", err)
+	}
+	undo := func() {
+		impl.platform.Remove(uid)
+		_ = os.RemoveAll(path)
+	}
+	// content is extracted after setup by platform: upload limits of security profile are applied
+	if err := fn.SetContent(content); err != nil {
+		undo()
+		return nil, fmt.Errorf("set content: %w", err)
+	}
+	if err := fn.SetManifest(manifest); err != nil {
+		undo()
+		return nil, fmt.Errorf("set manifest: %w", err)
+	}
+	if _, err := impl.platform.BindAliases(uid, nil, manifest.Aliases, false); err != nil {
+		undo()
+		return nil, fmt.Errorf("bind aliases: %w", err)
+	}
+	for _, alias := range links {
+		if _, err := impl.platform.Link(uid, alias); err != nil {
+			// bound concurrently
+			skipped = append(skipped, alias)
+		}
+	}
+	return skipped, nil
+}
+
+func (impl *casesImpl) Duplicate(ctx context.Context, uid string, options application.DuplicateOptions) (string, []string, error) {
+	source, err := impl.platform.FindByUID(uid)
+	if err != nil {
+		return "", nil, err
+	}
+	manifest := source.Lambda.Manifest()
+	if options.Name != "" {
+		manifest.Name = options.Name
+	}
+	if options.NoSchedules {
+		manifest.Cron = nil
+	}
+	if options.NoSecrets && len(manifest.Environment) > 0 {
+		var env = make(map[string]string, len(manifest.Environment))
+		for key, value := range manifest.Environment {
+			if _, ok := types.SecretReference(value); !ok {
+				env[key] = value
+			}
+		}
+		manifest.Environment = env
+	}
+	var declared, skipped []string
+	if !options.NoAliases {
+		for _, alias := range manifest.Aliases {
+			if _, err := impl.platform.FindByLink(alias); err == nil {
+				skipped = append(skipped, alias)
+			} else {
+				declared = append(declared, alias)
+			}
+		}
+	}
+	manifest.Aliases = declared
+	if err := manifest.Validate(); err != nil {
+		return "", nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	copyUID := uuid.New().String()
+	path := filepath.Join(impl.directory, copyUID)
+	// files are streamed one by one: copy does not share files (ex: data of lambda) with source
+	if err := internal.CopyDir(filepath.Join(impl.directory, uid), path); err != nil {
+		_ = os.RemoveAll(path)
+		return "", nil, fmt.Errorf("copy files: %w", err)
+	}
+	fn, err := lambda.FromDir(path)
+	if err != nil {
+		_ = os.RemoveAll(path)
+		return "", nil, fmt.Errorf("load copy: %w", err)
+	}
+	if err := impl.platform.Add(copyUID, fn); err != nil {
+		_ = os.RemoveAll(path)
+		return "", nil, fmt.Errorf("add copy to platform: %w", err)
+	}
+	undo := func() {
+		impl.platform.Remove(copyUID)
+		_ = os.RemoveAll(path)
+	}
+	if err := fn.SetManifest(manifest); err != nil {
+		undo()
+		return "", nil, fmt.Errorf("set manifest: %w", err)
+	}
+	if _, err := impl.platform.BindAliases(copyUID, nil, manifest.Aliases, false); err != nil {
+		undo()
+		return "", nil, fmt.Errorf("bind aliases: %w", err)
+	}
+	return copyUID, skipped, nil
+}
+
 func (impl *casesImpl) Platform() application.Platform {
 	return impl.platform
 }
 
+// SetSchedulerState kept between restarts to catch up missed runs (nil - missed runs are not known). Should be set
+// before the first run of scheduled actions
+func (impl *casesImpl) SetSchedulerState(state *scheduler.State) {
+	impl.state = state
+}
+
 func (impl *casesImpl) RunScheduledActions(ctx context.Context) {
-	now := time.Now()
-	last := impl.lastScheduler
-	impl.lastScheduler = now
+	window := impl.scheduler.Check()
+	if window.Jump != 0 {
+		log.Println("[WARN]", "clock jump", window.Jump, "detected, re-evaluate schedules")
+	}
+	list := impl.platform.List()
+	for _, fn := range list {
+		if fn.Disabled {
+			// paused: runs of disabled lambda are not caught up after enabling
+			continue
+		}
+		if window.Jump != 0 {
+			atomic.AddUint64(&impl.reevaluations, uint64(len(fn.Lambda.Manifest().Cron)))
+		}
+		impl.platform.DoScheduled(ctx, fn.Lambda, window, impl.fired(fn.UID))
+	}
+	impl.checked(window.To, list)
+	if err := lambda.CleanBundles(impl.directory); err != nil {
+		log.Println("[ERROR]", "failed clean unused bundles:", err)
+	}
+}
+
+func (impl *casesImpl) SkipScheduledActions() {
+	window := impl.scheduler.Check()
+	impl.checked(window.To, impl.platform.List())
+}
+
+func (impl *casesImpl) CatchUpScheduledActions(ctx context.Context) {
+	if impl.state == nil {
+		return
+	}
+	list := impl.platform.List()
+	for _, fn := range list {
+		if fn.Disabled {
+			continue
+		}
+		uid := fn.UID
+		since := func(key string) time.Time {
+			return impl.state.Since(uid, key)
+		}
+		impl.platform.CatchUpScheduled(ctx, fn.Lambda, impl.started, since, impl.fired(uid))
+	}
+	impl.checked(impl.started, list)
+}
+
+// save fire time of schedules of lambda to state (if set)
+func (impl *casesImpl) fired(uid string) func(key string, at time.Time) {
+	if impl.state == nil {
+		return nil
+	}
+	return func(key string, at time.Time) {
+		if err := impl.state.Fired(uid, key, at); err != nil {
+			log.Println("[ERROR]", "failed save fire time of schedule:", err)
+		}
+	}
+}
+
+// save time of evaluation of schedules to state (if set), fire times of removed lambdas are forgotten
+func (impl *casesImpl) checked(at time.Time, list []application.Definition) {
+	if impl.state == nil {
+		return
+	}
+	uids := make([]string, 0, len(list))
+	for _, fn := range list {
+		uids = append(uids, fn.UID)
+	}
+	if err := impl.state.Checked(at, uids); err != nil {
+		log.Println("[ERROR]", "failed save state of scheduler:", err)
+	}
+}
+
+// Counters of scheduler: detected clock jumps and schedules re-evaluated after jumps
+func (impl *casesImpl) SchedulerCounters() (clockJumps, reevaluations uint64) {
+	return impl.scheduler.Jumps(), atomic.LoadUint64(&impl.reevaluations)
+}
+
+func (impl *casesImpl) StartLambdas(ctx context.Context) {
 	for _, fn := range impl.platform.List() {
-		fn.Lambda.DoScheduled(ctx, last, impl.platform.Config().Environment) // FIXME: too much access into platform internals
+		impl.platform.Start(ctx, fn.Lambda)
 	}
 }
 
@@ -161,9 +445,24 @@ func (impl *casesImpl) Remove(uid string) error {
 	if err != nil {
 		log.Println("[ERROR]", "failed clear linked policy for lambda", uid, ":", err)
 	}
+	// payloads could contain sensitive data, so they don't outlive lambda
+	if impl.captures != nil {
+		if err := impl.captures.Purge(uid); err != nil {
+			log.Println("[ERROR]", "failed purge captured requests of lambda", uid, ":", err)
+		}
+	}
 	return fn.Lambda.Remove()
 }
 
 func (impl *casesImpl) Queues() application.Queues {
 	return impl.queues
 }
+
+// SetCaptures store of requests captured by server (nil - requests are not captured)
+func (impl *casesImpl) SetCaptures(captures application.Captures) {
+	impl.captures = captures
+}
+
+func (impl *casesImpl) Captures() application.Captures {
+	return impl.captures
+}
@@ -0,0 +1,71 @@
+package cases_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/cases"
+	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/application/policy"
+)
+
+func TestCases_Duplicate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeLegacy(t, dir, legacyUID, `{"name":"shop","run":["cat","data.txt"],"aliases":["shop"],"cron":[{"cron":"@hourly","action":"sync"}],`+
+		`"environment":{"MODE":"prod","STRIPE_KEY":"@secret:stripe"}}`)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, legacyUID, "state"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, legacyUID, "state", "data.txt"), []byte("source"), 0644))
+	policies, err := policy.New(policy.FileConfig(filepath.Join(dir, "policies.json")))
+	require.NoError(t, err)
+	basePlatform, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	useCases, err := cases.New(basePlatform, nil, policies, dir, filepath.Join(dir, ".templates"))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	copyUID, skipped, err := useCases.Duplicate(ctx, legacyUID, application.DuplicateOptions{Name: "shop staging"})
+	require.NoError(t, err)
+	assert.NotEqual(t, legacyUID, copyUID)
+	assert.Equal(t, []string{"shop"}, skipped, "alias is bound to source")
+	def, err := basePlatform.FindByUID(copyUID)
+	require.NoError(t, err)
+	assert.Equal(t, "shop staging", def.Manifest.Name)
+	assert.Empty(t, def.Manifest.Aliases)
+	assert.Len(t, def.Manifest.Cron, 1)
+	assert.Equal(t, map[string]string{"MODE": "prod", "STRIPE_KEY": "@secret:stripe"}, def.Manifest.Environment)
+
+	// files are not shared
+	copied := filepath.Join(dir, copyUID, "state", "data.txt")
+	require.FileExists(t, copied)
+	require.NoError(t, ioutil.WriteFile(copied, []byte("copy"), 0644))
+	data, err := ioutil.ReadFile(filepath.Join(dir, legacyUID, "state", "data.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "source", string(data))
+	source, err := basePlatform.FindByUID(legacyUID)
+	require.NoError(t, err)
+	assert.Equal(t, "shop", source.Manifest.Name)
+	assert.True(t, source.Aliases.Has("shop"))
+
+	copyUID, skipped, err = useCases.Duplicate(ctx, legacyUID, application.DuplicateOptions{NoAliases: true, NoSchedules: true, NoSecrets: true})
+	require.NoError(t, err)
+	assert.Empty(t, skipped)
+	def, err = basePlatform.FindByUID(copyUID)
+	require.NoError(t, err)
+	assert.Equal(t, "shop", def.Manifest.Name)
+	assert.Empty(t, def.Manifest.Cron)
+	assert.Equal(t, map[string]string{"MODE": "prod"}, def.Manifest.Environment)
+
+	_, _, err = useCases.Duplicate(ctx, conflictUID, application.DuplicateOptions{})
+	assert.Error(t, err)
+	assert.Len(t, basePlatform.List(), 3)
+}
@@ -2,14 +2,25 @@ package cases
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/types"
 )
 
+// directory (in project dir) for original manifests of migrated lambdas
+const migrationBackupDir = ".migration-backup"
+
+// fields of manifest moved to server links and policies. Aliases are kept in manifest as declared aliases: they are
+// legacy only while not linked
+var legacyFields = []string{"aliases", "allowed_ip", "allowed_origin", "public", "tokens"}
+
 type legacyManifestPart struct {
 	Aliases       types.JsonStringSet `json:"aliases"`
 	AllowedIP     types.JsonStringSet `json:"allowed_ip,omitempty"`     // limit incoming connections from list of IP
@@ -22,44 +33,248 @@ func (lmr *legacyManifestPart) hasPolicy() bool {
 	return len(lmr.AllowedIP) > 0 || len(lmr.AllowedOrigin) > 0 || len(lmr.Tokens) > 0
 }
 
-func (lmr *legacyManifestPart) Read(file string) error {
-	f, err := os.Open(file)
-	if err != nil {
-		return err
+func (lmr *legacyManifestPart) policy() application.PolicyDefinition {
+	return application.PolicyDefinition{
+		AllowedIP:     lmr.AllowedIP,
+		AllowedOrigin: lmr.AllowedOrigin,
+		Public:        lmr.Public,
+		Tokens:        lmr.Tokens,
 	}
-	defer f.Close()
-	return json.NewDecoder(f).Decode(lmr)
 }
 
-func (impl *casesImpl) applyMigration(uid, path string, fn application.Lambda) error {
-	var m legacyManifestPart
-	err := m.Read(filepath.Join(path, internal.ManifestFile))
+// sorted aliases
+func (lmr *legacyManifestPart) aliases() []string {
+	var ans = make([]string, 0, len(lmr.Aliases))
+	for alias := range lmr.Aliases {
+		ans = append(ans, alias)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// read legacy fields of manifest, returns false if there are no legacy fields (or no manifest)
+func (lmr *legacyManifestPart) Read(file string) (bool, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
 	if err != nil {
-		return err
+		return false, err
 	}
-	for alias := range m.Aliases {
-		_, err = impl.platform.Link(uid, alias)
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false, err
+	}
+	var found bool
+	for _, name := range legacyFields {
+		if _, ok := fields[name]; ok {
+			found = true
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, lmr)
+}
+
+func (impl *casesImpl) Migrate(dryRun bool) (*application.MigrationReport, error) {
+	impl.migrationLock.Lock()
+	defer impl.migrationLock.Unlock()
+	report := &application.MigrationReport{DryRun: dryRun}
+	report.Before.Links = len(impl.platform.Config().Links)
+	report.Before.Policies = len(impl.policies.List())
+	report.After.Links = report.Before.Links
+	report.After.Policies = report.Before.Policies
+
+	var unmigrated = make(map[string]string)
+	defs := impl.platform.List()
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].UID < defs[j].UID
+	})
+	for _, def := range defs {
+		var legacy legacyManifestPart
+		ok, err := legacy.Read(filepath.Join(impl.directory, def.UID, internal.ManifestFile))
 		if err != nil {
+			return nil, fmt.Errorf("read manifest of lambda %s: %w", def.UID, err)
+		}
+		if !ok || (!legacy.hasPolicy() && linkedAliases(impl.platform.Config().Links, legacy.aliases())) {
+			continue
+		}
+		countLegacy(&report.Before, &legacy)
+		plan, newLinks, newPolicy := impl.planMigration(def, &legacy)
+		report.Lambdas = append(report.Lambdas, *plan)
+		if !plan.Migratable() {
+			countLegacy(&report.After, &legacy)
+			unmigrated[def.UID] = plan.Issues[0].Item + ": " + plan.Issues[0].Reason
+			continue
+		}
+		if dryRun {
+			report.After.Links += newLinks
+			if newPolicy {
+				report.After.Policies++
+			}
+			continue
+		}
+		if err := impl.applyMigration(def, &legacy, plan); err != nil {
+			return nil, fmt.Errorf("apply migration for lambda %s: %w", def.UID, err)
+		}
+	}
+	if !dryRun {
+		report.After.Links = len(impl.platform.Config().Links)
+		report.After.Policies = len(impl.policies.List())
+		impl.unmigrated = unmigrated
+	}
+	return report, nil
+}
+
+func (impl *casesImpl) Migrated(uid string) error {
+	impl.migrationLock.RLock()
+	defer impl.migrationLock.RUnlock()
+	reason, ok := impl.unmigrated[uid]
+	if !ok {
+		return nil
+	}
+	return fmt.Errorf("lambda %s has legacy state which is not migrated (%s): resolve it and run migration", uid, reason)
+}
+
+// plan migration of lambda, returns number of links and new policy to be created
+func (impl *casesImpl) planMigration(def application.Definition, legacy *legacyManifestPart) (*application.LambdaMigration, int, bool) {
+	plan := &application.LambdaMigration{
+		UID:    def.UID,
+		Backup: filepath.Join(impl.directory, migrationBackupDir, def.UID, internal.ManifestFile),
+	}
+	var newLinks int
+	links := impl.platform.Config().Links
+	for _, alias := range legacy.aliases() {
+		target, linked := links[alias]
+		switch {
+		case !application.LinkNameReg.MatchString(alias):
+			plan.Issues = append(plan.Issues, application.MigrationIssue{Item: "alias " + alias, Reason: "invalid name"})
+			continue
+		case linked && target != def.UID:
+			plan.Issues = append(plan.Issues, application.MigrationIssue{Item: "alias " + alias, Reason: "already linked to lambda " + target})
+			continue
+		case !linked:
+			newLinks++
+		}
+		plan.Aliases = append(plan.Aliases, alias)
+	}
+	if !legacy.hasPolicy() {
+		return plan, newLinks, false
+	}
+	plan.Policy = def.UID + "-" + def.Manifest.Name
+	var newPolicy = true
+	if existent, err := impl.policies.Get(plan.Policy); err == nil {
+		// created by interrupted migration
+		newPolicy = false
+		if !samePolicy(existent.Definition, legacy.policy()) {
+			plan.Issues = append(plan.Issues, application.MigrationIssue{Item: "policy " + plan.Policy, Reason: "policy with the same name but different definition already exists"})
+		}
+	}
+	if applied, err := impl.policies.Find(def.UID); err == nil && applied.ID != plan.Policy {
+		plan.Issues = append(plan.Issues, application.MigrationIssue{Item: "policy " + plan.Policy, Reason: "lambda already has policy " + applied.ID})
+	}
+	return plan, newLinks, newPolicy
+}
+
+// apply planned migration. Each step could be repeated if migration was interrupted
+func (impl *casesImpl) applyMigration(def application.Definition, legacy *legacyManifestPart, plan *application.LambdaMigration) error {
+	if err := backupFile(filepath.Join(impl.directory, def.UID, internal.ManifestFile), plan.Backup); err != nil {
+		return fmt.Errorf("backup manifest: %w", err)
+	}
+	for _, alias := range plan.Aliases {
+		if _, err := impl.platform.Link(def.UID, alias); err != nil {
 			return err
 		}
 	}
-	if !m.hasPolicy() {
-		return fn.SetManifest(fn.Manifest())
+	if plan.Policy != "" {
+		if _, err := impl.policies.Get(plan.Policy); err != nil {
+			if _, err := impl.policies.Create(plan.Policy, legacy.policy()); err != nil {
+				return err
+			}
+		}
+		if err := impl.policies.Apply(def.UID, plan.Policy); err != nil {
+			return err
+		}
+	}
+	// manifest is saved without legacy fields (aliases are kept as declared)
+	return def.Lambda.SetManifest(def.Lambda.Manifest())
+}
+
+func logMigration(report *application.MigrationReport) {
+	for _, lambda := range report.Lambdas {
+		if lambda.Migratable() {
+			log.Println("legacy state of lambda", lambda.UID, "migrated, original manifest saved to", lambda.Backup)
+			continue
+		}
+		for _, issue := range lambda.Issues {
+			log.Println("[WARN] legacy state of lambda", lambda.UID, "is not migrated:", issue.Item, "-", issue.Reason)
+		}
+	}
+}
+
+// all aliases are linked (to any lambda)
+func linkedAliases(links map[string]string, aliases []string) bool {
+	for _, alias := range aliases {
+		if _, ok := links[alias]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func countLegacy(counts *application.MigrationCounts, legacy *legacyManifestPart) {
+	counts.LegacyLambdas++
+	counts.LegacyAliases += len(legacy.Aliases)
+	if legacy.hasPolicy() {
+		counts.LegacyPolicies++
 	}
+}
 
-	policy := application.PolicyDefinition{
-		AllowedIP:     m.AllowedIP,
-		AllowedOrigin: m.AllowedOrigin,
-		Public:        m.Public,
-		Tokens:        m.Tokens,
+// copy file to backup location if backup not yet exists (keep original on repeated migration)
+func backupFile(src, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return nil
 	}
-	p, err := impl.policies.Create(uid+"-"+fn.Manifest().Name, policy)
+	data, err := ioutil.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	err = impl.policies.Apply(uid, p.ID)
-	if err != nil {
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
 		return err
 	}
-	return fn.SetManifest(fn.Manifest())
+	return os.Rename(tmp, dest)
+}
+
+func samePolicy(a, b application.PolicyDefinition) bool {
+	if a.Public != b.Public || !sameSet(a.AllowedIP, b.AllowedIP) || len(a.AllowedNetworks) != len(b.AllowedNetworks) || !sameSet(a.AllowedOrigin, b.AllowedOrigin) || len(a.Tokens) != len(b.Tokens) {
+		return false
+	}
+	for i, network := range a.AllowedNetworks {
+		if b.AllowedNetworks[i] != network {
+			return false
+		}
+	}
+	for token, title := range a.Tokens {
+		if other, ok := b.Tokens[token]; !ok || other != title {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSet(a, b types.JsonStringSet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if !b.Has(key) {
+			return false
+		}
+	}
+	return true
 }
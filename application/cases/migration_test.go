@@ -0,0 +1,87 @@
+package cases_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/cases"
+	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/application/policy"
+)
+
+const (
+	legacyUID   = "11111111-1111-1111-1111-111111111111"
+	conflictUID = "22222222-2222-2222-2222-222222222222"
+)
+
+func writeLegacy(t *testing.T, dir, uid, manifest string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, uid), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "manifest.json"), []byte(manifest), 0755))
+}
+
+func TestCases_Migrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	const legacyManifest = `{"name":"shop","run":["cat"],"aliases":["shop"],"tokens":{"t1":"ci"}}`
+	writeLegacy(t, dir, legacyUID, legacyManifest)
+	writeLegacy(t, dir, conflictUID, `{"name":"other","run":["cat"],"aliases":["taken","free"]}`)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "project.json"), []byte(`{"links":{"taken":"`+legacyUID+`"}}`), 0755))
+
+	policies, err := policy.New(policy.FileConfig(filepath.Join(dir, "policies.json")))
+	require.NoError(t, err)
+	// interrupted migration: policy already created
+	_, err = policies.Create(legacyUID+"-shop", application.PolicyDefinition{Tokens: map[string]string{"t1": "ci"}})
+	require.NoError(t, err)
+	basePlatform, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	useCases, err := cases.Load(basePlatform, nil, policies, dir, filepath.Join(dir, ".templates"))
+	require.NoError(t, err)
+
+	plan, err := useCases.Migrate(true)
+	require.NoError(t, err)
+	assert.True(t, plan.DryRun)
+	assert.Equal(t, application.MigrationCounts{LegacyLambdas: 2, LegacyAliases: 3, LegacyPolicies: 1, Links: 1, Policies: 1}, plan.Before)
+	assert.Equal(t, application.MigrationCounts{LegacyLambdas: 1, LegacyAliases: 2, Links: 2, Policies: 1}, plan.After)
+	require.Len(t, plan.Lambdas, 2)
+	assert.True(t, plan.Lambdas[0].Migratable())
+	assert.Equal(t, []string{"shop"}, plan.Lambdas[0].Aliases)
+	assert.Equal(t, legacyUID+"-shop", plan.Lambdas[0].Policy)
+	assert.False(t, plan.Lambdas[1].Migratable())
+	assert.Equal(t, "alias taken", plan.Lambdas[1].Issues[0].Item)
+	assert.NoError(t, useCases.Migrated(conflictUID), "dry run should not affect state")
+	_, err = os.Stat(plan.Lambdas[0].Backup)
+	assert.True(t, os.IsNotExist(err), "dry run should not make backup")
+
+	report, err := useCases.Migrate(false)
+	require.NoError(t, err)
+	assert.Equal(t, plan.After, report.After)
+	def, err := basePlatform.FindByLink("shop")
+	require.NoError(t, err)
+	assert.Equal(t, legacyUID, def.UID)
+	applied, err := policies.Find(legacyUID)
+	require.NoError(t, err)
+	assert.Equal(t, legacyUID+"-shop", applied.ID)
+	backup, err := ioutil.ReadFile(report.Lambdas[0].Backup)
+	require.NoError(t, err)
+	assert.Equal(t, legacyManifest, string(backup))
+	assert.Error(t, useCases.Migrated(conflictUID))
+	assert.NoError(t, useCases.Migrated(legacyUID))
+	assert.Equal(t, []string{"shop"}, def.Lambda.Manifest().Aliases, "aliases are kept as declared")
+
+	// repeated migration skips migrated lambdas and keeps unmigratable unchanged
+	again, err := useCases.Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, again.Lambdas, 1)
+	assert.Equal(t, conflictUID, again.Lambdas[0].UID)
+	assert.Equal(t, again.Before, again.After)
+	_, err = basePlatform.FindByLink("free")
+	assert.Error(t, err, "unmigratable lambda should not be partially migrated")
+}
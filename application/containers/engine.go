@@ -0,0 +1,80 @@
+// Package containers runs invocations in containers by CLI of Docker compatible engine (docker or podman). The CLI
+// talks to socket of the engine (ex: DOCKER_HOST or CONTAINER_HOST of the server environment), attached CLI
+// process has output and exit code of the container, so it is handled the same way as native process.
+package containers
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Default CLI of engine
+const DefaultBinary = "docker"
+
+// time to remove container after client is killed
+const removeTimeout = 30 * time.Second
+
+// New engine by CLI binary (empty - DefaultBinary)
+func New(binary string) *Engine {
+	if binary == "" {
+		binary = DefaultBinary
+	}
+	return &Engine{binary: binary}
+}
+
+// Engine of containers by CLI
+type Engine struct {
+	binary string
+}
+
+func (e *Engine) Command(ctx context.Context, run application.ContainerRun) *exec.Cmd {
+	return exec.CommandContext(ctx, e.binary, Args(run)...)
+}
+
+func (e *Engine) Remove(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, e.binary, "rm", "-f", name).Run()
+}
+
+func (e *Engine) Pull(ctx context.Context, image string, out io.Writer) error {
+	cmd := exec.CommandContext(ctx, e.binary, "pull", image)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// Args of CLI to run container attached to stdin and stdout. Signals of client are passed to container
+func Args(run application.ContainerRun) []string {
+	args := []string{"run", "--rm", "-i", "--sig-proxy=true", "--name", run.Name,
+		"-v", run.Dir + ":" + types.ContainerDir + ":ro", "-w", run.WorkDir}
+	for _, mount := range run.Container.Mounts {
+		volume := filepath.Join(run.Dir, mount.Source) + ":" + mount.Target
+		if !mount.Writable {
+			volume += ":ro"
+		}
+		args = append(args, "-v", volume)
+	}
+	if !run.Network {
+		args = append(args, "--network", "none")
+	}
+	if run.User != "" {
+		args = append(args, "--user", run.User)
+	}
+	for _, name := range run.Env {
+		args = append(args, "-e", name)
+	}
+	command := run.Command
+	if len(run.Container.Entrypoint) > 0 {
+		args = append(args, "--entrypoint", run.Container.Entrypoint[0])
+		command = append(append([]string{}, run.Container.Entrypoint[1:]...), command...)
+	}
+	args = append(args, run.Container.Image)
+	return append(args, command...)
+}
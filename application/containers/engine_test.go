@@ -0,0 +1,35 @@
+package containers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/containers"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestArgs(t *testing.T) {
+	run := application.ContainerRun{
+		Name:    "trusted-cgi-app-1",
+		Dir:     "/srv/app",
+		WorkDir: "/lambda/src",
+		Container: types.Container{
+			Image:      "python:3",
+			Entrypoint: []string{"python3", "-u"},
+			Mounts:     []types.Mount{{Source: "data", Target: "/data", Writable: true}, {Source: "conf", Target: "/etc/app"}},
+		},
+		Command: []string{"main.py"},
+		Env:     []string{"MODE"},
+		User:    "1000:1000",
+	}
+	assert.Equal(t, []string{"run", "--rm", "-i", "--sig-proxy=true", "--name", "trusted-cgi-app-1",
+		"-v", "/srv/app:/lambda:ro", "-w", "/lambda/src",
+		"-v", "/srv/app/data:/data", "-v", "/srv/app/conf:/etc/app:ro",
+		"--network", "none", "--user", "1000:1000", "-e", "MODE",
+		"--entrypoint", "python3", "python:3", "-u", "main.py"}, containers.Args(run))
+
+	run = application.ContainerRun{Name: "n", Dir: "/srv/app", WorkDir: "/lambda", Container: types.Container{Image: "alpine"}, Network: true}
+	assert.Equal(t, []string{"run", "--rm", "-i", "--sig-proxy=true", "--name", "n", "-v", "/srv/app:/lambda:ro", "-w", "/lambda", "alpine"}, containers.Args(run))
+}
@@ -0,0 +1,250 @@
+// Package deadletter keeps queued requests which were not processed after all attempts: one directory per lambda,
+// one JSON file per letter. Number of letters per lambda is bounded and old letters are pruned by retention.
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+const (
+	suffix        = ".json"
+	pruneInterval = 10 * time.Minute
+)
+
+// New store of dead letters in directory. Not more than limit (zero - unlimited) letters are kept per lambda: the
+// oldest are removed by new letters. Letters older than retention (zero - kept forever) are removed by Prune
+func New(dir string, limit int, retention time.Duration) *Store {
+	return &Store{dir: dir, limit: limit, retention: retention}
+}
+
+// Store of dead letters in directory
+type Store struct {
+	dir       string
+	limit     int
+	retention time.Duration
+	lock      sync.Mutex
+}
+
+// Add letter of lambda with request body. ID and time of failure (if not set) are assigned by store
+func (s *Store) Add(letter application.DeadLetter, body []byte) error {
+	if err := checkLambda(letter.Lambda); err != nil {
+		return err
+	}
+	letter.ID = uuid.New().String()
+	if letter.Failed.IsZero() {
+		letter.Failed = time.Now()
+	}
+	letter.Size = int64(len(body))
+	letter.Body = body
+	letter.Request.Body = nil
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dir := filepath.Join(s.dir, letter.Lambda)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := internal.AtomicWriteJson(filepath.Join(dir, letter.ID+suffix), letter); err != nil {
+		return fmt.Errorf("save dead letter: %w", err)
+	}
+	if s.limit <= 0 {
+		return nil
+	}
+	letters, err := s.list(letter.Lambda)
+	if err != nil {
+		return err
+	}
+	for i := s.limit; i < len(letters); i++ {
+		if err := s.remove(letter.Lambda, letters[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List of letters of lambda from the newest without bodies
+func (s *Store) List(lambda string) ([]application.DeadLetter, error) {
+	if err := checkLambda(lambda); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	letters, err := s.list(lambda)
+	if err != nil {
+		return nil, err
+	}
+	for i := range letters {
+		letters[i].Body = nil
+	}
+	return letters, nil
+}
+
+// Get letter of lambda with body
+func (s *Store) Get(lambda string, id string) (*application.DeadLetter, error) {
+	if err := checkLambda(lambda); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid ID of dead letter: %w", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.read(lambda, id)
+}
+
+// Remove letters of lambda by IDs (empty - all). Unknown IDs are ignored. Returns number of removed letters
+func (s *Store) Remove(lambda string, ids []string) (int, error) {
+	if err := checkLambda(lambda); err != nil {
+		return 0, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(ids) == 0 {
+		letters, err := s.list(lambda)
+		if err != nil {
+			return 0, err
+		}
+		for _, letter := range letters {
+			ids = append(ids, letter.ID)
+		}
+	}
+	var removed int
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return removed, fmt.Errorf("invalid ID of dead letter: %w", err)
+		}
+		err := s.remove(lambda, id)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Prune letters of all lambdas older than retention. Returns number of removed letters
+func (s *Store) Prune() (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	lambdas, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	deadline := time.Now().Add(-s.retention)
+	var removed int
+	for _, entry := range lambdas {
+		if !entry.IsDir() {
+			continue
+		}
+		letters, err := s.list(entry.Name())
+		if err != nil {
+			return removed, err
+		}
+		for _, letter := range letters {
+			if !letter.Failed.Before(deadline) {
+				continue
+			}
+			if err := s.remove(entry.Name(), letter.ID); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		_ = os.Remove(filepath.Join(s.dir, entry.Name())) // removed only if empty
+	}
+	return removed, nil
+}
+
+// Run pruning periodically till context done
+func (s *Store) Run(ctx context.Context) {
+	t := time.NewTicker(pruneInterval)
+	defer t.Stop()
+	for {
+		if n, err := s.Prune(); err != nil {
+			log.Println("[ERROR]", "dead letters: prune -", err)
+		} else if n > 0 {
+			log.Println("dead letters: pruned", n, "letters older than", s.retention)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// letters of lambda from the newest with bodies, unreadable files are skipped
+func (s *Store) list(lambda string) ([]application.DeadLetter, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.dir, lambda))
+	if os.IsNotExist(err) {
+		return []application.DeadLetter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var letters = make([]application.DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		letter, err := s.read(lambda, strings.TrimSuffix(entry.Name(), suffix))
+		if err != nil {
+			log.Println("[WARN]", "dead letters: skip", filepath.Join(lambda, entry.Name()), "-", err)
+			continue
+		}
+		letters = append(letters, *letter)
+	}
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].Failed.After(letters[j].Failed)
+	})
+	return letters, nil
+}
+
+func (s *Store) read(lambda string, id string) (*application.DeadLetter, error) {
+	var letter application.DeadLetter
+	if err := internal.ReadJson(s.file(lambda, id), &letter); err != nil {
+		return nil, err
+	}
+	if letter.ID != id {
+		return nil, errors.New("ID of dead letter does not match name of file")
+	}
+	return &letter, nil
+}
+
+func (s *Store) remove(lambda string, id string) error {
+	return os.Remove(s.file(lambda, id))
+}
+
+func (s *Store) file(lambda string, id string) string {
+	return filepath.Join(s.dir, lambda, id+suffix)
+}
+
+// lambda is used as name of directory
+func checkLambda(lambda string) error {
+	if lambda == "" || lambda == "." || lambda == ".." || strings.ContainsAny(lambda, `/\`) {
+		return fmt.Errorf("invalid lambda %q", lambda)
+	}
+	return nil
+}
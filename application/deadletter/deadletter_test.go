@@ -0,0 +1,78 @@
+package deadletter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/deadletter"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := deadletter.New(dir, 2, time.Hour)
+	now := time.Now()
+	for i, payload := range []string{"first", "second", "third"} {
+		err := store.Add(application.DeadLetter{
+			Lambda:   "echo",
+			Queue:    "queue-1",
+			Error:    "exit status 1",
+			Attempts: 3,
+			Failed:   now.Add(time.Duration(i) * time.Second),
+			Request:  types.Request{Method: "POST", Path: "/q/queue-1"},
+		}, []byte(payload))
+		require.NoError(t, err)
+	}
+
+	// the oldest is removed by limit
+	letters, err := store.List("echo")
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, int64(len("third")), letters[0].Size)
+	assert.Equal(t, int64(len("second")), letters[1].Size)
+	assert.Empty(t, letters[0].Body)
+	assert.Equal(t, "queue-1", letters[0].Queue)
+	assert.Equal(t, 3, letters[0].Attempts)
+
+	letter, err := store.Get("echo", letters[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(letter.Body))
+	assert.Equal(t, "POST", letter.Request.Method)
+
+	_, err = store.Get("echo", "../../etc/passwd")
+	assert.Error(t, err)
+	_, err = store.List("../echo")
+	assert.Error(t, err)
+
+	n, err := store.Remove("echo", []string{letters[1].ID, "00000000-0000-0000-0000-000000000000"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	others, err := store.List("other")
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	// pruned by retention
+	require.NoError(t, store.Add(application.DeadLetter{Lambda: "old", Queue: "queue-2", Failed: now.Add(-2 * time.Hour)}, nil))
+	n, err = store.Prune()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoDirExists(t, filepath.Join(dir, "old"))
+	letters, err = store.List("echo")
+	require.NoError(t, err)
+	assert.Len(t, letters, 1)
+
+	n, err = store.Remove("echo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -0,0 +1,223 @@
+// Package freezer keeps frozen artifacts of lambdas: archives of fully built lambdas (with dependencies installed by
+// actions like npm install or pip install) which are restored byte-identical without builds and network. Archives
+// are content-addressed by SHA-256 and shared by lambdas:
+//
+//	index.json                artifacts of lambdas from the newest
+//	blobs/<sha256>.tar.gz     archives of artifacts
+package freezer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// Number of artifacts of lambda kept by default
+const DefaultKeep = 5
+
+const (
+	indexFile = "index.json"
+	blobsDir  = "blobs"
+	blobExt   = ".tar.gz"
+)
+
+// New store of frozen artifacts of lambdas of platform in directory
+func New(platform application.Platform, dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, blobsDir), 0700); err != nil {
+		return nil, fmt.Errorf("create directory of frozen artifacts: %w", err)
+	}
+	st := &Store{platform: platform, dir: dir, index: make(map[string][]application.FrozenArtifact)}
+	if err := internal.ReadJson(filepath.Join(dir, indexFile), &st.index); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read index of frozen artifacts: %w", err)
+	}
+	return st, nil
+}
+
+// Store of frozen artifacts
+type Store struct {
+	Keep     int // artifacts of lambda kept by pruning (zero - DefaultKeep, negative - all)
+	platform application.Platform
+	dir      string
+	lock     sync.Mutex
+	index    map[string][]application.FrozenArtifact // UID -> artifacts from the newest
+}
+
+func (st *Store) Freeze(uid string) (*application.FrozenArtifact, error) {
+	def, err := st.platform.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return st.store(uid, func(out io.Writer) error {
+		return def.Lambda.Freeze(out)
+	})
+}
+
+func (st *Store) Add(uid string, archive io.Reader) (*application.FrozenArtifact, error) {
+	if _, err := st.platform.FindByUID(uid); err != nil {
+		return nil, err
+	}
+	return st.store(uid, func(out io.Writer) error {
+		_, err := io.Copy(out, archive)
+		return err
+	})
+}
+
+func (st *Store) Artifacts(uid string) []application.FrozenArtifact {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return append([]application.FrozenArtifact{}, st.index[uid]...)
+}
+
+func (st *Store) Download(uid string, id string, out io.Writer) error {
+	f, _, err := st.open(uid, id)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(out, f)
+	return err
+}
+
+func (st *Store) Thaw(uid string, id string) (*application.FrozenArtifact, error) {
+	def, err := st.platform.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	f, artifact, err := st.open(uid, id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// damaged archive is detected before files of lambda are replaced
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, fmt.Errorf("read artifact: %w", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != artifact.ID {
+		return nil, fmt.Errorf("artifact %s is damaged: hash of archive is %s", artifact.ID, sum)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	previous := def.Lambda.Manifest()
+	if err := def.Lambda.Thaw(f); err != nil {
+		return nil, fmt.Errorf("replace files of lambda: %w", err)
+	}
+	if _, err := st.platform.BindAliases(uid, previous.Aliases, def.Lambda.Manifest().Aliases, false); err != nil {
+		def.Lambda.SetWarning("frozen artifact " + artifact.ID + " is restored, aliases are not bound: " + err.Error())
+	}
+	return &artifact, nil
+}
+
+// archive of artifact of lambda (empty ID - the newest)
+func (st *Store) open(uid string, id string) (*os.File, application.FrozenArtifact, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	for _, artifact := range st.index[uid] {
+		if id == "" || artifact.ID == id {
+			f, err := os.Open(st.blob(artifact.ID))
+			return f, artifact, err
+		}
+	}
+	return nil, application.FrozenArtifact{}, fmt.Errorf("%w: %s of %s", application.ErrFrozenNotFound, id, uid)
+}
+
+// write archive to blob named by hash and add it as the newest artifact of lambda
+func (st *Store) store(uid string, write func(out io.Writer) error) (*application.FrozenArtifact, error) {
+	tmp, err := ioutil.TempFile(filepath.Join(st.dir, blobsDir), "freeze-")
+	if err != nil {
+		return nil, fmt.Errorf("create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	err = write(io.MultiWriter(tmp, hash))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("write archive: %w", err)
+	}
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	artifact := application.FrozenArtifact{ID: hex.EncodeToString(hash.Sum(nil)), UID: uid, Size: info.Size(), Created: time.Now()}
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	artifacts := st.index[uid]
+	if len(artifacts) > 0 && artifacts[0].ID == artifact.ID {
+		newest := artifacts[0]
+		return &newest, nil
+	}
+	if _, err := os.Stat(st.blob(artifact.ID)); os.IsNotExist(err) {
+		if err := os.Rename(tmp.Name(), st.blob(artifact.ID)); err != nil {
+			return nil, fmt.Errorf("save archive: %w", err)
+		}
+	}
+	updated := []application.FrozenArtifact{artifact}
+	for _, item := range artifacts {
+		if item.ID != artifact.ID {
+			updated = append(updated, item)
+		}
+	}
+	st.index[uid] = updated
+	if err := st.prune(); err != nil {
+		return nil, err
+	}
+	return &artifact, nil
+}
+
+// remove artifacts over retention and artifacts of removed lambdas, save index and remove unused archives. Should be
+// called under lock
+func (st *Store) prune() error {
+	keep := st.Keep
+	if keep == 0 {
+		keep = DefaultKeep
+	}
+	used := make(map[string]bool)
+	for uid, artifacts := range st.index {
+		if _, err := st.platform.FindByUID(uid); err != nil {
+			delete(st.index, uid)
+			continue
+		}
+		if keep > 0 && len(artifacts) > keep {
+			st.index[uid] = artifacts[:keep]
+		}
+		for _, artifact := range st.index[uid] {
+			used[artifact.ID] = true
+		}
+	}
+	if err := internal.AtomicWriteJson(filepath.Join(st.dir, indexFile), st.index); err != nil {
+		return fmt.Errorf("save index of frozen artifacts: %w", err)
+	}
+	blobs, err := ioutil.ReadDir(filepath.Join(st.dir, blobsDir))
+	if err != nil {
+		return fmt.Errorf("list frozen artifacts: %w", err)
+	}
+	for _, blob := range blobs {
+		id := strings.TrimSuffix(blob.Name(), blobExt)
+		if id == blob.Name() || used[id] {
+			continue // in progress or used
+		}
+		if err := os.Remove(filepath.Join(st.dir, blobsDir, blob.Name())); err != nil {
+			log.Println("[WARN] remove frozen artifact", id, "-", err)
+		}
+	}
+	return nil
+}
+
+func (st *Store) blob(id string) string {
+	return filepath.Join(st.dir, blobsDir, id+blobExt)
+}
@@ -0,0 +1,136 @@
+package freezer_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/freezer"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const uid = testutil.UID
+
+func setup(t *testing.T) (string, application.Platform, *freezer.Store) {
+	dir, plato := testutil.Platform(t, types.Manifest{Run: []string{"venv/bin/python", "main.py"}})
+	root := filepath.Join(dir, uid)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "venv", "bin"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "main.py"), []byte("print(1)"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "venv", "bin", "activate"), []byte("#!/bin/sh"), 0755))
+	require.NoError(t, os.Symlink("/usr/bin/python3", filepath.Join(root, "venv", "bin", "python")))
+	store, err := freezer.New(plato, filepath.Join(dir, ".frozen"))
+	require.NoError(t, err)
+	return dir, plato, store
+}
+
+func TestStore_Freeze(t *testing.T) {
+	dir, plato, store := setup(t)
+	root := filepath.Join(dir, uid)
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "main.py"), modified, modified))
+
+	first, err := store.Freeze(uid)
+	require.NoError(t, err)
+	assert.Equal(t, uid, first.UID)
+	var archive bytes.Buffer
+	require.NoError(t, store.Download(uid, first.ID, &archive))
+	sum := sha256.Sum256(archive.Bytes())
+	assert.Equal(t, hex.EncodeToString(sum[:]), first.ID)
+	assert.Equal(t, int64(archive.Len()), first.Size)
+
+	again, err := store.Freeze(uid)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "unchanged lambda is the same artifact")
+	assert.Len(t, store.Artifacts(uid), 1)
+
+	// changes after freeze are reverted by thaw
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "main.py"), []byte("print(2)"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "extra.txt"), []byte("extra"), 0644))
+	second, err := store.Freeze(uid)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, []string{second.ID, first.ID}, ids(store.Artifacts(uid)))
+
+	thawed, err := store.Thaw(uid, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, thawed.ID)
+	data, err := ioutil.ReadFile(filepath.Join(root, "main.py"))
+	require.NoError(t, err)
+	assert.Equal(t, "print(1)", string(data))
+	assert.NoFileExists(t, filepath.Join(root, "extra.txt"))
+	info, err := os.Stat(filepath.Join(root, "main.py"))
+	require.NoError(t, err)
+	assert.True(t, modified.Equal(info.ModTime()), info.ModTime())
+	info, err = os.Stat(filepath.Join(root, "venv", "bin", "activate"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(root, "venv", "bin", "python"))
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/bin/python3", link)
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"venv/bin/python", "main.py"}, def.Manifest.Run)
+
+	// thawed files are frozen to the same archive
+	refrozen, err := store.Freeze(uid)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, refrozen.ID)
+	assert.Equal(t, []string{first.ID, second.ID}, ids(store.Artifacts(uid)))
+
+	_, err = store.Thaw(uid, "unknown")
+	assert.True(t, errors.Is(err, application.ErrFrozenNotFound), err)
+}
+
+func TestStore_Prune(t *testing.T) {
+	dir, plato, store := setup(t)
+	store.Keep = 2
+	for _, content := range []string{"v1", "v2", "v3"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "main.py"), []byte(content), 0644))
+		_, err := store.Freeze(uid)
+		require.NoError(t, err)
+	}
+	artifacts := store.Artifacts(uid)
+	assert.Len(t, artifacts, 2)
+	blobs, err := filepath.Glob(filepath.Join(dir, ".frozen", "blobs", "*"))
+	require.NoError(t, err)
+	assert.Len(t, blobs, 2)
+
+	// index survives restart
+	reopened, err := freezer.New(plato, filepath.Join(dir, ".frozen"))
+	require.NoError(t, err)
+	assert.Equal(t, ids(artifacts), ids(reopened.Artifacts(uid)))
+
+	// artifacts of removed lambdas are pruned by the next freeze
+	other := filepath.Join(dir, "22222222-2222-2222-2222-222222222222")
+	require.NoError(t, os.MkdirAll(other, 0755))
+	fn, err := lambda.DummyPublic(other, "cat")
+	require.NoError(t, err)
+	require.NoError(t, plato.Add(filepath.Base(other), fn))
+	plato.Remove(uid)
+	_, err = store.Freeze(filepath.Base(other))
+	require.NoError(t, err)
+	assert.Empty(t, store.Artifacts(uid))
+	blobs, err = filepath.Glob(filepath.Join(dir, ".frozen", "blobs", "*"))
+	require.NoError(t, err)
+	assert.Len(t, blobs, 1)
+}
+
+func ids(artifacts []application.FrozenArtifact) []string {
+	var list []string
+	for _, artifact := range artifacts {
+		list = append(list, artifact.ID)
+	}
+	return list
+}
@@ -0,0 +1,539 @@
+// Package gitdeploy deploys lambdas from tracked branches of git repositories (see types.GitSource). Branch is
+// fetched to clone of repository kept for lambda; new commit is extracted over staged copy of lambda (honoring
+// .cgiignore of repository), manifest of repository is applied and build action is run in the staged copy. Lambda is
+// replaced by the staged copy only after successful build, so failed deploy keeps the previous version serving.
+package gitdeploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Actor of journal changes by deploys
+const Actor = "git"
+
+// Locations in directory of deployer
+const (
+	stateFile = "state.json"
+	reposDir  = "repos"  // bare clones by UID
+	stagesDir = "stages" // staged copies of lambdas by UID
+	keysDir   = "keys"   // deploy keys of running fetches by UID
+)
+
+// Polling of git sources with interval
+const pollInterval = time.Second
+
+// Observer of failed deploys (ex: lifecycle webhooks)
+type Observer interface {
+	DeployFailed(uid string, status application.GitStatus)
+}
+
+// New deployer of lambdas located in project directory by UID. Clones, staged copies and state of deploys are kept in
+// internal.GitDeployDir of project directory: staged copy is moved to lambda, so it should be on the same file system
+func New(platform application.Platform, project string) (*Deployer, error) {
+	dp := &Deployer{
+		platform: platform,
+		project:  project,
+		dir:      filepath.Join(project, internal.GitDeployDir),
+		ctx:      context.Background(),
+		states:   make(map[string]*state),
+	}
+	if err := os.MkdirAll(dp.dir, 0700); err != nil {
+		return nil, fmt.Errorf("create directory of deploys: %w", err)
+	}
+	var saved map[string]application.GitStatus
+	if err := internal.ReadJson(filepath.Join(dp.dir, stateFile), &saved); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read state of deploys: %w", err)
+	}
+	for uid, status := range saved {
+		status.Running = false
+		dp.states[uid] = &state{status: status}
+	}
+	return dp, nil
+}
+
+// Deployer of lambdas from git
+type Deployer struct {
+	SSHKey   string              // private key file of server used without deploy key of lambda (empty - defaults of git)
+	Journal  application.Journal // optional journal of changes
+	Hooks    *application.Hooks  // optional lifecycle hooks
+	Observer Observer            // optional observer of failed deploys
+	platform application.Platform
+	project  string
+	dir      string
+	ctx      context.Context // context of background deploys
+	lock     sync.Mutex
+	states   map[string]*state
+	saveLock sync.Mutex
+}
+
+type state struct {
+	status  application.GitStatus
+	running bool
+	pending bool // deploy triggered while running: repeated after
+}
+
+// Start polling of git sources with interval till context is done. Triggered deploys use the same context
+func (dp *Deployer) Start(ctx context.Context) {
+	dp.lock.Lock()
+	dp.ctx = ctx
+	dp.lock.Unlock()
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				dp.poll(ctx)
+			}
+		}
+	}()
+}
+
+// Trigger deploy of lambda in background (ex: by ping of repository). If deploy is running, it is repeated after
+func (dp *Deployer) Trigger(uid string) {
+	dp.lock.Lock()
+	ctx := dp.ctx
+	dp.lock.Unlock()
+	go dp.background(ctx, uid)
+}
+
+func (dp *Deployer) Status(uid string) *application.GitStatus {
+	def, err := dp.platform.FindByUID(uid)
+	if err != nil || def.Manifest.Git == nil {
+		return nil
+	}
+	status := dp.status(uid)
+	status.Repo, status.Branch = def.Manifest.Git.Repo, def.Manifest.Git.Branch
+	return &status
+}
+
+func (dp *Deployer) Sync(ctx context.Context, uid string, force bool) (*application.GitStatus, error) {
+	return dp.sync(ctx, uid, force, false)
+}
+
+func (dp *Deployer) sync(ctx context.Context, uid string, force bool, background bool) (*application.GitStatus, error) {
+	def, err := dp.platform.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	source := def.Lambda.Manifest().Git
+	if source == nil {
+		return nil, fmt.Errorf("%w: %s", application.ErrNotTracked, uid)
+	}
+	dp.lock.Lock()
+	st := dp.state(uid)
+	if st.running {
+		st.pending = st.pending || background
+		status := st.status
+		dp.lock.Unlock()
+		return &status, fmt.Errorf("%w: %s", application.ErrDeployRunning, uid)
+	}
+	st.running = true
+	st.status.Repo, st.status.Branch, st.status.Running = source.Repo, source.Branch, true
+	previous := st.status
+	dp.lock.Unlock()
+
+	status := dp.run(ctx, def, *source, previous, force)
+	status.Running = false
+
+	dp.lock.Lock()
+	st.status, st.running = status, false
+	again := st.pending
+	st.pending = false
+	dp.lock.Unlock()
+	dp.save()
+
+	if status.Error != "" {
+		log.Println("[WARN]", "deploy of lambda", uid, "from git:", status.Error)
+		if dp.Observer != nil && (force || status.Error != previous.Error || status.Failed != previous.Failed) {
+			dp.Observer.DeployFailed(uid, status)
+		}
+	}
+	if again {
+		go dp.background(ctx, uid)
+	}
+	return &status, nil
+}
+
+func (dp *Deployer) background(ctx context.Context, uid string) {
+	_, err := dp.sync(ctx, uid, false, true)
+	if err != nil && !errors.Is(err, application.ErrDeployRunning) {
+		log.Println("[WARN]", "deploy of lambda", uid, "from git:", err)
+	}
+}
+
+// deploy lambdas which are due by interval of git source, forget state of removed lambdas
+func (dp *Deployer) poll(ctx context.Context) {
+	var tracked = make(map[string]bool)
+	for _, def := range dp.platform.List() {
+		source := def.Manifest.Git
+		if source == nil {
+			continue
+		}
+		tracked[def.UID] = true
+		if source.Interval <= 0 {
+			continue
+		}
+		dp.lock.Lock()
+		st := dp.state(def.UID)
+		due := !st.running && time.Since(st.status.Checked) >= time.Duration(source.Interval)
+		dp.lock.Unlock()
+		if due {
+			go dp.background(ctx, def.UID)
+		}
+	}
+	dp.lock.Lock()
+	var removed []string
+	for uid, st := range dp.states {
+		if !tracked[uid] && !st.running {
+			delete(dp.states, uid)
+			removed = append(removed, uid)
+		}
+	}
+	dp.lock.Unlock()
+	for _, uid := range removed {
+		_ = os.RemoveAll(filepath.Join(dp.dir, reposDir, uid))
+	}
+	if len(removed) > 0 {
+		dp.save()
+	}
+}
+
+// fetch branch and deploy new commit, status is updated by result
+func (dp *Deployer) run(ctx context.Context, def *application.Definition, source types.GitSource, status application.GitStatus, force bool) application.GitStatus {
+	status.Checked = time.Now()
+	commit, err := dp.fetch(ctx, def.UID, source)
+	if err != nil {
+		status.Error, status.Output = "fetch: "+err.Error(), ""
+		return status
+	}
+	if !force && commit == status.Commit {
+		status.Failed, status.Error, status.Output = "", "", ""
+		return status
+	}
+	if !force && commit == status.Failed {
+		// broken commit is not rebuilt by every poll
+		return status
+	}
+	output, err := dp.deploy(ctx, def, source, commit)
+	if err != nil {
+		status.Failed, status.Error, status.Output = commit, err.Error(), output
+		return status
+	}
+	status.Commit, status.Deployed = commit, time.Now()
+	status.Failed, status.Error, status.Output = "", "", ""
+	return status
+}
+
+// fetch the last commit of branch to bare clone. Returns hash of commit
+func (dp *Deployer) fetch(ctx context.Context, uid string, source types.GitSource) (string, error) {
+	repo := filepath.Join(dp.dir, reposDir, uid)
+	if _, err := os.Stat(filepath.Join(repo, "HEAD")); os.IsNotExist(err) {
+		if err := os.MkdirAll(repo, 0700); err != nil {
+			return "", fmt.Errorf("create clone: %w", err)
+		}
+		if _, err := git(ctx, nil, "init", "--bare", "-q", repo); err != nil {
+			return "", fmt.Errorf("init clone: %w", err)
+		}
+	}
+	env, cleanup, err := dp.sshEnv(uid, source)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	if _, err := git(ctx, env, "-C", repo, "fetch", "-q", "--depth", "1", "--no-tags", "--", source.Repo, source.Ref()); err != nil {
+		return "", err
+	}
+	commit, err := git(ctx, nil, "-C", repo, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// environment of git with SSH key: deploy key of lambda from secrets or key of server
+func (dp *Deployer) sshEnv(uid string, source types.GitSource) ([]string, func(), error) {
+	key, cleanup := dp.SSHKey, func() {}
+	if source.Key != "" {
+		secrets := dp.platform.Secrets()
+		if secrets == nil {
+			return nil, cleanup, fmt.Errorf("deploy key %s: store of secrets is disabled", source.Key)
+		}
+		value, ok := secrets.Value(source.Key)
+		if !ok {
+			return nil, cleanup, fmt.Errorf("deploy key: unknown secret %s", source.Key)
+		}
+		dir := filepath.Join(dp.dir, keysDir)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, cleanup, fmt.Errorf("deploy key: %w", err)
+		}
+		key = filepath.Join(dir, uid)
+		// ssh rejects key without trailing new line
+		if err := os.WriteFile(key, []byte(strings.TrimSpace(value)+"\n"), 0600); err != nil {
+			return nil, cleanup, fmt.Errorf("deploy key: %w", err)
+		}
+		cleanup = func() { _ = os.Remove(key) }
+	}
+	if key == "" {
+		return nil, cleanup, nil
+	}
+	quoted := "'" + strings.ReplaceAll(key, "'", `'\''`) + "'"
+	return []string{"GIT_SSH_COMMAND=ssh -i " + quoted + " -o IdentitiesOnly=yes -o BatchMode=yes -o StrictHostKeyChecking=accept-new"}, cleanup, nil
+}
+
+// build commit in staged copy of lambda and replace lambda by it. Returns the end of output of failed build
+func (dp *Deployer) deploy(ctx context.Context, def *application.Definition, source types.GitSource, commit string) (string, error) {
+	stage := filepath.Join(dp.dir, stagesDir, def.UID)
+	if err := os.RemoveAll(stage); err != nil {
+		return "", fmt.Errorf("clean staged copy: %w", err)
+	}
+	// moved to lambda on success
+	defer os.RemoveAll(stage)
+	// files which are not in repository (ex: data of lambda) are kept, as by upload
+	if err := internal.CopyDir(filepath.Join(dp.project, def.UID), stage); err != nil {
+		return "", fmt.Errorf("copy lambda to staging: %w", err)
+	}
+	staged, err := lambda.FromDir(stage)
+	if err != nil {
+		return "", fmt.Errorf("load staged copy: %w", err)
+	}
+	if err := dp.platform.Prepare(staged); err != nil {
+		return "", fmt.Errorf("prepare staged copy: %w", err)
+	}
+	content, err := dp.archive(ctx, def.UID, source, commit)
+	if err != nil {
+		return "", fmt.Errorf("archive commit: %w", err)
+	}
+	if err := staged.SetContent(bytes.NewReader(content)); err != nil {
+		return "", fmt.Errorf("extract commit: %w", err)
+	}
+	// tracking is defined by lambda: git source of manifest of repository is ignored
+	manifest := staged.Manifest()
+	manifest.Git = &source
+	if err := manifest.Validate(); err != nil {
+		return "", fmt.Errorf("invalid manifest of repository: %w", err)
+	}
+	if err := staged.SetManifest(manifest); err != nil {
+		return "", fmt.Errorf("apply manifest of repository: %w", err)
+	}
+	if source.Action != "" {
+		var output tail
+		if err := dp.platform.Do(ctx, staged, source.Action, 0, &output); err != nil {
+			return output.String(), fmt.Errorf("build action %s: %w", source.Action, err)
+		}
+	}
+
+	previous := def.Lambda.Manifest()
+	if err := def.Lambda.Replace(stage); err != nil {
+		return "", fmt.Errorf("replace files of lambda: %w", err)
+	}
+	current := def.Lambda.Manifest()
+	if _, err := dp.platform.BindAliases(def.UID, previous.Aliases, current.Aliases, false); err != nil {
+		def.Lambda.SetWarning("commit " + short(commit) + " is deployed, aliases are not bound: " + err.Error())
+	}
+	dp.Hooks.Deployed(ctx, application.DeployEvent{UID: def.UID, Kind: application.DeployGit, Hash: commit})
+	if dp.Journal != nil {
+		dp.Journal.Record(application.Change{
+			Actor:   Actor,
+			Kind:    application.ChangeDeploy,
+			Lambda:  def.UID,
+			Name:    current.Name,
+			Summary: "commit " + short(commit) + " of " + source.Ref() + " deployed from " + source.Repo,
+		})
+	}
+	// startup action outlives the deploy
+	dp.platform.Start(context.Background(), def.Lambda)
+	return "", nil
+}
+
+// .tar.gz of directory of lambda in commit without files matched by .cgiignore of the directory
+func (dp *Deployer) archive(ctx context.Context, uid string, source types.GitSource, commit string) ([]byte, error) {
+	repo := filepath.Join(dp.dir, reposDir, uid)
+	tree := commit
+	if dir := source.Subdir(); dir != "" {
+		tree += ":" + dir
+	}
+	var ignore []string
+	if content, err := git(ctx, nil, "-C", repo, "show", commit+":"+path.Join(source.Subdir(), internal.CGIIgnore)); err == nil {
+		ignore = strings.Split(content, "\n")
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", repo, "archive", "--format=tar", tree)
+	internal.SetFlags(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	data, err := filterTar(stdout, ignore)
+	// drain output: process should not be blocked on failed read
+	_, _ = io.Copy(io.Discard, stdout)
+	if werr := cmd.Wait(); werr != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", werr, msg)
+		}
+		return nil, werr
+	}
+	return data, err
+}
+
+func (dp *Deployer) state(uid string) *state {
+	st, ok := dp.states[uid]
+	if !ok {
+		st = &state{}
+		dp.states[uid] = st
+	}
+	return st
+}
+
+func (dp *Deployer) status(uid string) application.GitStatus {
+	dp.lock.Lock()
+	defer dp.lock.Unlock()
+	if st, ok := dp.states[uid]; ok {
+		return st.status
+	}
+	return application.GitStatus{}
+}
+
+func (dp *Deployer) save() {
+	dp.saveLock.Lock()
+	defer dp.saveLock.Unlock()
+	dp.lock.Lock()
+	var snapshot = make(map[string]application.GitStatus, len(dp.states))
+	for uid, st := range dp.states {
+		snapshot[uid] = st.status
+	}
+	dp.lock.Unlock()
+	if err := internal.AtomicWriteJson(filepath.Join(dp.dir, stateFile), snapshot); err != nil {
+		log.Println("[ERROR]", "save state of deploys from git:", err)
+	}
+}
+
+func git(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	internal.SetFlags(cmd)
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// .tar.gz of regular files and directories of tar stream without ignored entries. Other types (ex: symlinks) are
+// rejected as by upload
+func filterTar(src io.Reader, ignore []string) ([]byte, error) {
+	var out bytes.Buffer
+	zipped := gzip.NewWriter(&out)
+	writer := tar.NewWriter(zipped)
+	reader := tar.NewReader(src)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean(header.Name), "./")
+		if name == "." || header.Typeflag == tar.TypeXGlobalHeader || ignored(name, ignore) {
+			continue
+		}
+		if header.Typeflag != tar.TypeDir && header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unsupported type of file %s (exclude it by %s)", name, internal.CGIIgnore)
+		}
+		header.Name = name
+		if err := writer.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(writer, reader); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := zipped.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// name (slash separated) is matched by pattern of ignore file as by tar --exclude-from of upload: by path from the
+// root or by any element (ex: node_modules, *.pyc). Files inside ignored directories are ignored too
+func ignored(name string, patterns []string) bool {
+	parts := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		for i, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, strings.Join(parts[:i+1], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// the end of output of build
+type tail struct {
+	lock sync.Mutex
+	data []byte
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.data = append(t.data, p...)
+	if len(t.data) > application.StderrTail {
+		t.data = t.data[len(t.data)-application.StderrTail:]
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return string(t.data)
+}
+
+func short(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
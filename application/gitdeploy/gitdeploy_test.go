@@ -0,0 +1,209 @@
+package gitdeploy_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/gitdeploy"
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const uid = testutil.UID
+
+type memJournal struct {
+	lock    sync.Mutex
+	changes []application.Change
+}
+
+func (mj *memJournal) Record(change application.Change) {
+	mj.lock.Lock()
+	defer mj.lock.Unlock()
+	mj.changes = append(mj.changes, change)
+}
+
+func (mj *memJournal) Changes(query application.ChangeQuery, offset, limit int) ([]application.Change, int, error) {
+	mj.lock.Lock()
+	defer mj.lock.Unlock()
+	return append([]application.Change{}, mj.changes...), len(mj.changes), nil
+}
+
+type failures struct {
+	lock     sync.Mutex
+	statuses []application.GitStatus
+}
+
+func (fs *failures) DeployFailed(uid string, status application.GitStatus) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	fs.statuses = append(fs.statuses, status)
+}
+
+func (fs *failures) list() []application.GitStatus {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	return append([]application.GitStatus{}, fs.statuses...)
+}
+
+// repository with lambda in subdirectory fn
+type repository struct {
+	t   *testing.T
+	dir string
+}
+
+func newRepository(t *testing.T) *repository {
+	repo := &repository{t: t, dir: testutil.TempDir(t)}
+	repo.git("init", "-q", "-b", "main")
+	return repo
+}
+
+func (repo *repository) git(args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", repo.dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(repo.t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// commit files (path -> content) and return hash of commit
+func (repo *repository) commit(files map[string]string) string {
+	for name, content := range files {
+		file := filepath.Join(repo.dir, filepath.FromSlash(name))
+		require.NoError(repo.t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(repo.t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	repo.git("add", "-A")
+	repo.git("commit", "-q", "-m", "change")
+	return repo.git("rev-parse", "HEAD")
+}
+
+func setup(t *testing.T, source *types.GitSource) (string, application.Platform, *gitdeploy.Deployer) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("deploy requires git")
+	}
+	dir, plato := testutil.Platform(t, types.Manifest{Run: []string{"cat", "built.txt"}, Git: source})
+	deployer, err := gitdeploy.New(plato, dir)
+	require.NoError(t, err)
+	return dir, plato, deployer
+}
+
+const makefile = "build:\n\tcat version.txt > built.txt\n"
+
+func TestDeployer_Sync(t *testing.T) {
+	repo := newRepository(t)
+	first := repo.commit(map[string]string{
+		"README.md":          "not a lambda",
+		"fn/manifest.json":   `{"name":"from-git","run":["cat","built.txt"],"aliases":["app"]}`,
+		"fn/Makefile":        makefile,
+		"fn/version.txt":     "v1",
+		"fn/secret.txt":      "ignored",
+		"fn/cache/state.txt": "ignored",
+		"fn/.cgiignore":      "# local files\nsecret.txt\ncache/\n",
+	})
+	source := &types.GitSource{Repo: repo.dir, Branch: "main", Dir: "fn", Action: "build"}
+	dir, plato, deployer := setup(t, source)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "data.txt"), []byte("kept"), 0644))
+	journal := &memJournal{}
+	observer := &failures{}
+	deployer.Journal = journal
+	deployer.Observer = observer
+	ctx := context.Background()
+
+	status, err := deployer.Sync(ctx, uid, false)
+	require.NoError(t, err)
+	assert.Empty(t, status.Error)
+	assert.Equal(t, first, status.Commit)
+	assert.False(t, status.Deployed.IsZero())
+	assert.Equal(t, "v1", testutil.Invoke(t, plato, uid))
+
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "from-git", def.Manifest.Name)
+	assert.Equal(t, source, def.Manifest.Git, "tracking is kept")
+	assert.True(t, def.Aliases.Has("app"))
+	assert.FileExists(t, filepath.Join(dir, uid, "data.txt"))
+	assert.NoFileExists(t, filepath.Join(dir, uid, "secret.txt"))
+	assert.NoDirExists(t, filepath.Join(dir, uid, "cache"))
+	assert.NoFileExists(t, filepath.Join(dir, uid, "README.md"))
+	changes, _, _ := journal.Changes(application.ChangeQuery{}, 0, 0)
+	require.Len(t, changes, 1)
+	assert.Equal(t, gitdeploy.Actor, changes[0].Actor)
+	assert.Equal(t, application.ChangeDeploy, changes[0].Kind)
+
+	// the same commit is not deployed again
+	status, err = deployer.Sync(ctx, uid, false)
+	require.NoError(t, err)
+	assert.Equal(t, first, status.Commit)
+	changes, _, _ = journal.Changes(application.ChangeQuery{}, 0, 0)
+	assert.Len(t, changes, 1)
+
+	// failed build keeps previous version
+	broken := repo.commit(map[string]string{"fn/version.txt": "v2", "fn/Makefile": "build:\n\techo broken build >&2; exit 1\n"})
+	status, err = deployer.Sync(ctx, uid, false)
+	require.NoError(t, err)
+	assert.Equal(t, first, status.Commit)
+	assert.Equal(t, broken, status.Failed)
+	assert.Contains(t, status.Error, "build")
+	assert.Contains(t, status.Output, "broken build")
+	assert.Equal(t, "v1", testutil.Invoke(t, plato, uid))
+	require.Len(t, observer.list(), 1)
+	assert.Equal(t, broken, observer.list()[0].Failed)
+
+	// failed commit is not rebuilt by every sync
+	status, err = deployer.Sync(ctx, uid, false)
+	require.NoError(t, err)
+	assert.Equal(t, broken, status.Failed)
+	assert.Len(t, observer.list(), 1)
+
+	fixed := repo.commit(map[string]string{"fn/Makefile": makefile})
+	status, err = deployer.Sync(ctx, uid, false)
+	require.NoError(t, err)
+	assert.Equal(t, fixed, status.Commit)
+	assert.Empty(t, status.Failed)
+	assert.Empty(t, status.Error)
+	assert.Equal(t, "v2", testutil.Invoke(t, plato, uid))
+
+	// state is kept between restarts
+	restarted, err := gitdeploy.New(plato, dir)
+	require.NoError(t, err)
+	saved := restarted.Status(uid)
+	require.NotNil(t, saved)
+	assert.Equal(t, fixed, saved.Commit)
+	assert.Equal(t, repo.dir, saved.Repo)
+	assert.Equal(t, "main", saved.Branch)
+}
+
+func TestDeployer_notTracked(t *testing.T) {
+	_, _, deployer := setup(t, nil)
+	_, err := deployer.Sync(context.Background(), uid, false)
+	assert.ErrorIs(t, err, application.ErrNotTracked)
+	assert.Nil(t, deployer.Status(uid))
+}
+
+func TestDeployer_unsupportedFiles(t *testing.T) {
+	repo := newRepository(t)
+	repo.commit(map[string]string{"manifest.json": `{"run":["cat","built.txt"]}`, "built.txt": "v1"})
+	require.NoError(t, os.Symlink("built.txt", filepath.Join(repo.dir, "link")))
+	repo.commit(nil)
+	_, plato, deployer := setup(t, &types.GitSource{Repo: repo.dir})
+
+	status, err := deployer.Sync(context.Background(), uid, false)
+	require.NoError(t, err)
+	assert.Contains(t, status.Error, ".cgiignore")
+	assert.Empty(t, status.Commit)
+
+	repo.commit(map[string]string{".cgiignore": "link\n"})
+	status, err = deployer.Sync(context.Background(), uid, false)
+	require.NoError(t, err)
+	assert.Empty(t, status.Error)
+	assert.Equal(t, "v1", testutil.Invoke(t, plato, uid))
+}
@@ -0,0 +1,184 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Default time limit of single hook call
+const DefaultHookTimeout = 5 * time.Second
+
+// Lifecycle hooks for embedders: callbacks are called synchronously (the operation waits for the hook), but within
+// time limit. Panic or timeout of hook is recovered, logged and reported to OnError, the operation continues as if
+// hook is not set (request is not rejected). All callbacks are optional, nil hooks are allowed.
+type Hooks struct {
+	// Before invocation of lambda by public request (including put to queue): after built-in checks (method,
+	// policies, content type, alerts), but before mutation of request and invocation. Non-nil rejection stops the
+	// request. Hook must not read body of request
+	OnBeforeInvoke func(ctx context.Context, event InvokeEvent) *Rejection
+	// Result record of every public request (including rejected), after it is tracked by stats, metrics and alerts
+	OnAfterInvoke func(ctx context.Context, record stats.Record)
+	// Lambda is deployed by API: created or content (archive, bundle) uploaded
+	OnDeploy func(ctx context.Context, event DeployEvent)
+	// Manifest of lambda is changed by API
+	OnManifestChange func(ctx context.Context, event ManifestChange)
+	// Public request failed (not rejected) or another hook failed
+	OnError func(ctx context.Context, event ErrorEvent)
+	// Time limit of single hook call. Zero - DefaultHookTimeout
+	Timeout time.Duration
+}
+
+// Public request to lambda before invocation
+type InvokeEvent struct {
+	UID      string         // lambda UID
+	Queue    string         // queue name if request is put to queue
+	Request  *types.Request // request as-is (before mutation)
+	Manifest types.Manifest // lambda manifest
+}
+
+// Rejection of request by hook
+type Rejection struct {
+	Status     int           // HTTP status code, zero - 403 (Forbidden)
+	Reason     string        // message for client and record
+	RetryAfter time.Duration // optional Retry-After header
+}
+
+func (r *Rejection) Error() string {
+	if r.Reason == "" {
+		return "rejected by hook"
+	}
+	return r.Reason
+}
+
+// HTTP status code of rejection
+func (r *Rejection) StatusCode() int {
+	if r.Status == 0 {
+		return http.StatusForbidden
+	}
+	return r.Status
+}
+
+// Kinds of deployment
+const (
+	DeployCreate   = "create"   // lambda created (empty, from template or from git)
+	DeployUpload   = "upload"   // content uploaded as archive
+	DeployBundle   = "bundle"   // content uploaded as bundle
+	DeployGit      = "git"      // commit of tracked branch deployed from git
+	DeployImport   = "import"   // content imported from archive by URL
+	DeployThaw     = "thaw"     // files restored from frozen artifact
+	DeployRollback = "rollback" // files restored from version
+)
+
+// Deployed lambda
+type DeployEvent struct {
+	UID  string // lambda UID
+	Kind string // see Deploy* constants
+	Hash string // hash of bundle, deployed commit (git), SHA-256 of imported archive, ID of frozen artifact or version
+}
+
+// Changed manifest of lambda
+type ManifestChange struct {
+	UID      string
+	Previous types.Manifest
+	Current  types.Manifest
+}
+
+// Failed request or hook
+type ErrorEvent struct {
+	UID  string // lambda (or queue for requests to queue) UID, could be empty
+	Hook string // name of failed hook, empty for failed request
+	Err  error
+}
+
+// BeforeInvoke calls OnBeforeInvoke if set. Failed hook doesn't reject request.
+func (h *Hooks) BeforeInvoke(ctx context.Context, event InvokeEvent) *Rejection {
+	if h == nil || h.OnBeforeInvoke == nil {
+		return nil
+	}
+	var result = make(chan *Rejection, 1)
+	err := h.call(ctx, "OnBeforeInvoke", func(ctx context.Context) {
+		result <- h.OnBeforeInvoke(ctx, event)
+	})
+	if err != nil {
+		h.failed(ctx, event.UID, "OnBeforeInvoke", err)
+		return nil
+	}
+	return <-result
+}
+
+// AfterInvoke calls OnAfterInvoke if set.
+func (h *Hooks) AfterInvoke(ctx context.Context, record stats.Record) {
+	if h == nil || h.OnAfterInvoke == nil {
+		return
+	}
+	if err := h.call(ctx, "OnAfterInvoke", func(ctx context.Context) { h.OnAfterInvoke(ctx, record) }); err != nil {
+		h.failed(ctx, record.UID, "OnAfterInvoke", err)
+	}
+}
+
+// Deployed calls OnDeploy if set.
+func (h *Hooks) Deployed(ctx context.Context, event DeployEvent) {
+	if h == nil || h.OnDeploy == nil {
+		return
+	}
+	if err := h.call(ctx, "OnDeploy", func(ctx context.Context) { h.OnDeploy(ctx, event) }); err != nil {
+		h.failed(ctx, event.UID, "OnDeploy", err)
+	}
+}
+
+// ManifestChanged calls OnManifestChange if set.
+func (h *Hooks) ManifestChanged(ctx context.Context, event ManifestChange) {
+	if h == nil || h.OnManifestChange == nil {
+		return
+	}
+	if err := h.call(ctx, "OnManifestChange", func(ctx context.Context) { h.OnManifestChange(ctx, event) }); err != nil {
+		h.failed(ctx, event.UID, "OnManifestChange", err)
+	}
+}
+
+// Error calls OnError if set.
+func (h *Hooks) Error(ctx context.Context, event ErrorEvent) {
+	if h == nil || h.OnError == nil {
+		return
+	}
+	if err := h.call(ctx, "OnError", func(ctx context.Context) { h.OnError(ctx, event) }); err != nil {
+		log.Println("[ERROR] hooks:", err)
+	}
+}
+
+func (h *Hooks) failed(ctx context.Context, uid string, hook string, err error) {
+	log.Println("[ERROR] hooks:", err)
+	h.Error(ctx, ErrorEvent{UID: uid, Hook: hook, Err: err})
+}
+
+// call hook with time limit and recover panic. Hook which is not finished in time keeps running in background
+func (h *Hooks) call(ctx context.Context, name string, hook func(ctx context.Context)) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var done = make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("hook %s panic: %v", name, r)
+			}
+		}()
+		hook(ctx)
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("hook %s: %w", name, ctx.Err())
+	}
+}
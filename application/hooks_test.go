@@ -0,0 +1,60 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+func TestHooks_nil(t *testing.T) {
+	var hooks *application.Hooks
+	assert.Nil(t, hooks.BeforeInvoke(context.Background(), application.InvokeEvent{UID: "x"}))
+	hooks.AfterInvoke(context.Background(), stats.Record{})
+	hooks.Deployed(context.Background(), application.DeployEvent{})
+	hooks.ManifestChanged(context.Background(), application.ManifestChange{})
+	hooks.Error(context.Background(), application.ErrorEvent{})
+}
+
+func TestHooks_timeout(t *testing.T) {
+	var failed []application.ErrorEvent
+	hooks := &application.Hooks{
+		Timeout: 50 * time.Millisecond,
+		OnBeforeInvoke: func(ctx context.Context, event application.InvokeEvent) *application.Rejection {
+			<-ctx.Done()
+			time.Sleep(50 * time.Millisecond) // ignores context
+			return &application.Rejection{}
+		},
+		OnDeploy: func(ctx context.Context, event application.DeployEvent) {
+			panic("oops")
+		},
+		OnError: func(ctx context.Context, event application.ErrorEvent) {
+			failed = append(failed, event)
+			panic("error in OnError is only logged")
+		},
+	}
+	started := time.Now()
+	assert.Nil(t, hooks.BeforeInvoke(context.Background(), application.InvokeEvent{UID: "x"}), "timed out hook doesn't reject")
+	assert.Less(t, time.Since(started), 100*time.Millisecond)
+	hooks.Deployed(context.Background(), application.DeployEvent{UID: "y"})
+
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, "x", failed[0].UID)
+		assert.Equal(t, "OnBeforeInvoke", failed[0].Hook)
+		assert.True(t, errors.Is(failed[0].Err, context.DeadlineExceeded))
+		assert.Equal(t, "y", failed[1].UID)
+		assert.Equal(t, "OnDeploy", failed[1].Hook)
+		assert.Contains(t, failed[1].Err.Error(), "panic: oops")
+	}
+}
+
+func TestRejection(t *testing.T) {
+	assert.Equal(t, 403, (&application.Rejection{}).StatusCode())
+	assert.Equal(t, "rejected by hook", (&application.Rejection{}).Error())
+	assert.Equal(t, 429, (&application.Rejection{Status: 429, Reason: "quota"}).StatusCode())
+}
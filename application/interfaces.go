@@ -3,9 +3,13 @@ package application
 import (
 	"context"
 	"io"
+	"io/fs"
+	"os/exec"
 	"regexp"
 	"time"
 
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/templates"
 	"github.com/reddec/trusted-cgi/types"
 )
@@ -25,18 +29,67 @@ type FileSystem interface {
 	RenameFile(src, dest string) error
 	// Pack content of lambda to tar.gz
 	Content(tarball io.Writer) error
-	// Set content of lambda from tar.gz and apply changes (re-index)
-	SetContent(tarball io.Reader) error
+	// Set content of lambda from tar.gz or zip (detected by content) and apply changes (re-index).
+	// Unknown format is ErrUnsupportedArchive
+	SetContent(archive io.Reader) error
+	// Replace all files of lambda by directory (ex: staged copy) and apply changes (re-index). Directory is moved,
+	// so it should be on the same file system. Files of lambda are kept if replace fails
+	Replace(dir string) error
+	// Set content of lambda from zip bundle (served without extraction, read-only) and apply changes (re-index).
+	// Returns hash of the bundle
+	SetBundle(bundle io.Reader) (string, error)
+	// Hash of lambda content (hash of bundle for bundled lambda)
+	ContentHash() (string, error)
+	// Time of the last change of lambda files (including manifest and bundle pointer)
+	Modified() (time.Time, error)
+	// Size of lambda directory and disk quota of manifest. Size is cached for lambda.DiskUsageTTL and measured again
+	// after change of files by server
+	DiskUsage() (DiskUsage, error)
+	// Fingerprint of code files by names, sizes and modification times (except ignored by .cgiignore and manifest):
+	// cheap check of files edited on disk. Hash of bundle for bundled lambda
+	Fingerprint() (string, error)
+	// Drop state built from files (ex: code edited on disk): workers are restarted by the next invocation, revision
+	// is changed, so cached responses are not served
+	Invalidate()
+	// Pack all files of lambda, including ignored by .cgiignore (ex: dependencies installed by actions), to tar.gz with
+	// symlinks and modification times. Bundled lambda could not be frozen
+	Freeze(tarball io.Writer) error
+	// Replace all files of lambda by archive of Freeze and apply changes (re-index). Archive is produced by server, so
+	// upload limits are not applied
+	Thaw(tarball io.Reader) error
+	// Log files of lambda from the oldest (see types.Manifest.Logs). Log files are kept out of lambda content, so they
+	// survive replace of files, and are removed with lambda
+	LogFiles() ([]LogFile, error)
+	// Copy content of log file to output. Unknown file is ErrLogNotFound
+	ReadLog(name string, output io.Writer) error
+	// The last lines of current log file (empty - no log files)
+	TailLog(lines int) ([]byte, error)
 }
 
 // Lambda functions
 type Actions interface {
-	// List of actions defined in Makefile as targets
+	// List of actions defined in manifest and in Makefile as targets
 	Actions() ([]string, error)
-	// Do target defined in Makefile. Time limit, global env and out can be nil.
+	// Do action defined in manifest or target defined in Makefile (action of manifest wins). Time limit, global env
+	// and out can be nil.
 	Do(ctx context.Context, name string, timeLimit time.Duration, globalEnv map[string]string, out io.Writer) error
-	// Do scheduled actions based on last run
-	DoScheduled(ctx context.Context, lastRun time.Time, globalEnv map[string]string)
+	// Do scheduled actions due in the window of evaluated time. Failed runs are retried in background by retry
+	// policy of manifest
+	DoScheduled(ctx context.Context, window scheduler.Window, globalEnv map[string]string, hooks ScheduleHooks)
+	// Catch up scheduled actions missed since the time returned by since (by schedule key, zero - unknown) till
+	// until by policy of schedule (missed). Catch-up runs are sequential in background
+	CatchUpScheduled(ctx context.Context, until time.Time, since func(key string) time.Time, globalEnv map[string]string, hooks ScheduleHooks)
+	// Start startup action (on_start) in background if defined. Blocking startup delays invocations until finished
+	Start(ctx context.Context, globalEnv map[string]string)
+	// Live status of scheduled actions: next fire time and result of the last run
+	Schedules() []ScheduleStatus
+}
+
+// Output of lambda process which is processed after finish (ex: chained to queue)
+type Output interface {
+	io.Writer
+	// Process output after finish of process with result. Returns result of processing
+	Close(err error) error
 }
 
 type Invokable interface {
@@ -60,16 +113,82 @@ type Lambda interface {
 	Invokable
 	// Manifest configuration
 	Manifest() types.Manifest
-	// Update manifest and apply changes (re-index)
+	// Manifest with defaults and mandatory rules of security profile applied (used for invocations)
+	Effective() types.Manifest
+	// Update manifest and apply changes (re-index). Manifest which relaxes mandatory rules of security profile is
+	// *types.ValidationError
 	SetManifest(manifest types.Manifest) error
+	// Apply manifest which is already in manifest file (edited on disk) without saving. Manifest which relaxes
+	// mandatory rules of security profile is *types.ValidationError
+	ApplyManifest(manifest types.Manifest) error
+	// Persistent warning (empty - no problems), cleared by the next change of manifest or content
+	Warning() string
+	// Set persistent warning (ex: edit of manifest file is rejected)
+	SetWarning(warning string)
+	// Input schema of manifest compiled when manifest is applied or loaded (nil - not defined)
+	InputSchema() *types.InputSchema
+	// Revision of lambda in memory: changed by every change of files, content, manifest and settings by server and
+	// after actions. Files edited on disk out of server change it only by Invalidate
+	Revision() uint64
 	// Running credentials
 	Credentials() *types.Credential
 	// Update credentials (could be null) (and apply ownership for files if needed)
 	SetCredentials(creds *types.Credential) error
+	// Update server-level runtime defaults (umask, locale). Manifest values have higher priority
+	SetDefaults(defaults types.Runtime)
+	// Update server-level security profile (and apply ownership for files if read-only content is changed)
+	SetProfile(profile types.SecurityProfile) error
+	// Update server-level executor of actions (nil - actions are executed directly without limits)
+	SetBuilder(builder Builder)
+	// Update server-level store of secrets referenced by environment (nil - references are resolved to empty values)
+	SetSecrets(secrets Secrets)
+	// Update server-level engine of containers (nil - lambdas with container are not invoked). Image of container is
+	// pulled in background
+	SetContainers(containers Containers)
+	// Effective runtime settings with provided global environment
+	Diagnose(globalEnv map[string]string) Diagnostic
+	// Variables of environment of invocations defined by runtime defaults, provided global environment and manifest
+	// (ordered by name). Manifest wins on conflict
+	MergedEnvironment(globalEnv map[string]string) []EnvValue
+	// Value with resolved references ${NAME} to environment of invocation (server, global, runtime and manifest
+	// variables), unknown references are empty. Ex: secret of webhook signature
+	Expand(value string, globalEnv map[string]string) string
+	// Availability problems of lambda (empty - available): missing run command, failed startup action with degraded
+	// policy, failed checks of manifest (see types.Manifest.Check). Checks are run with global environment
+	Health(ctx context.Context, globalEnv map[string]string) []string
+	// Pass static file by path of request inside lambda (see Manifest.StaticDirs) to handler, lambda is locked till
+	// handler returns. Not mapped, hidden, out of static directory files and manifest are fs.ErrNotExist
+	ServeStatic(requestPath string, handler func(content io.ReadSeeker, info fs.FileInfo) error) error
 	// Remove lambda
 	Remove() error
 }
 
+// Engine of containers of invocations (see types.Manifest.Container)
+type Containers interface {
+	// Command of invocation in container: process of engine client with output and exit code of container. Container
+	// is removed after exit
+	Command(ctx context.Context, run ContainerRun) *exec.Cmd
+	// Force removal of container by name (ex: client is killed by time limit)
+	Remove(name string) error
+	// Pull image, progress is written to out
+	Pull(ctx context.Context, image string, out io.Writer) error
+}
+
+// Executor of actions (make targets) with shared pool of concurrent builds and resource limits
+type Builder interface {
+	// Run command of lambda action: waits for free slot in pool, creates command, applies build limits (overrides server
+	// defaults) and resource limits of lambda (could be nil) and waits for finish. Time limit (zero is infinity) is
+	// applied after waiting in queue
+	Run(ctx context.Context, uid string, overrides *types.BuildLimits, resources *types.ResourceLimits, timeLimit time.Duration, command func(ctx context.Context) *exec.Cmd) error
+	// Effective limits with lambda overrides
+	Limits(overrides *types.BuildLimits) BuildLimits
+	// Apply resource limits of lambda to command of invocation or worker before start (cgroup is attached). Guard
+	// should be notified when process is started and finished
+	Guard(uid string, limits types.ResourceLimits, cmd *exec.Cmd) (ResourceGuard, error)
+	// Resource limits which could not be enforced by server with reasons (empty - all defined limits are enforced)
+	Unenforced(limits types.ResourceLimits) []string
+}
+
 // Platform should index lambda, keep shared info (like env) and apply global configuration
 //
 // Highlights:
@@ -83,8 +202,12 @@ type Platform interface {
 	Credentials() *types.Credential
 	// Platform configuration
 	Config() Config
-	// Update and apply new configuration
+	// Update and apply new configuration. Configuration without user fails if security profile requires it
 	SetConfig(config Config) error
+	// Security profile of server
+	Profile() types.SecurityProfile
+	// Update security profile and apply it to all lambdas. Profile which requires user to run lambdas fails without it
+	SetProfile(profile types.SecurityProfile) error
 	// List of all lambdas manifests (unordered) with UID and aliases
 	List() []Definition
 	// Get lambda by UID (if indexed)
@@ -93,42 +216,237 @@ type Platform interface {
 	FindByLink(link string) (*Definition, error)
 	// Make link to target UID. Could fail if no target UID exists or link already bound to another lambda. Returns definition of lambda
 	Link(targetUID string, linkName string) (*Definition, error)
+	// Generate unique DNS-safe slug from name of lambda and link it. Previous slug is kept as link. Slug is not
+	// changed if it is already generated from the same name. Returns definition of lambda
+	GenerateSlug(uid string) (*Definition, error)
 	// Remove link by name. Returns old linked lambda or null
 	Unlink(linkName string) (*Definition, error)
+	// Enable or disable lambda (state is kept in config). Disabled lambda is not removed: it is not invoked by HTTP,
+	// schedules are paused and queued requests are held till lambda is enabled. Returns definition of lambda
+	SetEnabled(uid string, enabled bool) (*Definition, error)
+	// Bind aliases declared by manifest (current) to lambda and release aliases removed from declaration (previous).
+	// Alias bound to another lambda is conflict (*AliasConflictError) unless force is set: then it is moved to the
+	// lambda. Nothing is changed on conflict. Returns definition of lambda
+	BindAliases(uid string, previous, current []string, force bool) (*Definition, error)
+	// Check steps of chain (see Manifest.Then) of lambda as if they are applied: targets should exist, chains through
+	// lambda should not loop and should not be longer than types.DefaultChainHops. Problems are *types.ValidationError
+	CheckChain(uid string, steps []types.ChainStep) error
 	// Put existent lambda to platform, index it and apply.
 	Add(uid string, lambda Lambda) error
 	// Remove existent lambda from platform and index (doesn't call underlying Remove() method)
 	Remove(uid string)
 	// Invoke lambda with platform global environment and logs results to tracker (if set)
 	Invoke(ctx context.Context, lambda Invokable, request types.Request, out io.Writer) error
+	// Number of invocations in progress by UID (only lambdas with running invocations)
+	Running() map[string]int
 	// Same as Find + Invoke, but caller has no control on NotFound error
 	InvokeByUID(ctx context.Context, uid string, request types.Request, out io.Writer) error
 	// Do lambda action target defined in Makefile with platform global environment. Time limit and out can be nil
 	Do(ctx context.Context, lambda Lambda, action string, timeLimit time.Duration, out io.Writer) error
+	// Do lambda scheduled actions due in the window with platform global environment. Fired is called (if set) for
+	// every fired schedule
+	DoScheduled(ctx context.Context, lambda Lambda, window scheduler.Window, fired func(key string, at time.Time))
+	// Catch up lambda scheduled actions missed during downtime with platform global environment
+	CatchUpScheduled(ctx context.Context, lambda Lambda, until time.Time, since func(key string) time.Time, fired func(key string, at time.Time))
+	// Effective lambda settings with platform global environment
+	Diagnose(lambda Lambda) Diagnostic
+	// Variables of environment of lambda invocations merged with platform global environment
+	MergedEnvironment(lambda Lambda) []EnvValue
+	// Value with resolved references to environment of lambda with platform global environment
+	Expand(lambda Lambda, value string) string
+	// Store of secrets referenced by environment of lambdas (nil - disabled)
+	Secrets() Secrets
+	// Set store of secrets (nil - disabled) and apply it to all lambdas
+	SetSecrets(secrets Secrets)
+	// Availability problems of lambda (empty - available) with platform global environment
+	Health(ctx context.Context, lambda Lambda) []string
+	// Start lambda startup action (on_start) in background with platform global environment
+	Start(ctx context.Context, lambda Lambda)
+	// Apply settings of platform (security profile, credentials, runtime defaults, builds, secrets) to lambda which is
+	// not added to platform, ex: staged copy of lambda
+	Prepare(lambda Lambda) error
 }
 
 // High-level use-cases
 type Cases interface {
-	// Create new lambda from remote Git repository. Will work only if SSH key set
+	// Create new lambda from remote Git repository. Will work only if SSH key set. Invalid manifest of repository is
+	// *types.ValidationError
 	CreateFromGit(ctx context.Context, repo string) (string, error)
-	// Create new lambda using provided template
+	// Create new lambda using provided template. Invalid manifest of template is *types.ValidationError
 	CreateFromTemplate(ctx context.Context, template templates.Template) (string, error)
 	// Create empty lambda
 	Create(ctx context.Context) (string, error)
+	// Create lambda with the UID (ErrLambdaExists if UID is used) from content archive and manifest (manifest of
+	// content is replaced), ex: lambda transferred from another server. Aliases (declared or links) are bound if they
+	// are free, skipped aliases are returned. Nothing is left on failure
+	Import(ctx context.Context, uid string, manifest types.Manifest, content io.Reader, aliases []string) ([]string, error)
+	// Duplicate lambda to new lambda: files are copied (nothing is shared with source except read-only bundle), manifest
+	// is adjusted by options. Declared aliases bound to other lambdas (ex: to the source) are not declared by copy,
+	// skipped aliases are returned. Nothing is left on failure. Returns UID of copy
+	Duplicate(ctx context.Context, uid string, options DuplicateOptions) (string, []string, error)
 	// Remove lamdba from index and definition
 	Remove(uid string) error
 	// Get underlying platform
 	Platform() Platform
 	// Get underlying queues manager
 	Queues() Queues
+	// Store of captured requests (nil - requests are not captured)
+	Captures() Captures
 	// Run scheduled actions from all lambda. Saves last run
 	RunScheduledActions(ctx context.Context)
+	// Skip scheduled actions due since the last run: they are not run later (read-only mirror)
+	SkipScheduledActions()
+	// Catch up scheduled actions missed while server was down (by policy of schedules). Should be called once when
+	// server starts, before the first run of scheduled actions
+	CatchUpScheduledActions(ctx context.Context)
+	// Start startup actions (on_start) of all lambdas. Should be called once when server starts
+	StartLambdas(ctx context.Context)
 	// List of all templates without availability check
 	Templates() (map[string]*templates.Template, error)
 	// Content of SSH public key if set
 	PublicSSHKey() ([]byte, error)
+	// Migrate legacy state of lambdas (aliases and access restrictions in manifest) to server links and policies.
+	// Dry run only plans migration. Could be repeated: already migrated parts are skipped
+	Migrate(dryRun bool) (*MigrationReport, error)
+	// Check that lambda has no legacy state left after migration. Changes of such lambda (manifest, aliases)
+	// are rejected, otherwise legacy state will be lost
+	Migrated(uid string) error
+}
+
+// Replication of primary server to read-only mirror (standby)
+type Mirror interface {
+	// Base URL of primary server if server is read-only mirror, empty otherwise (including promoted mirror)
+	Primary() string
+	// Status of replication
+	Status() MirrorStatus
+	// Promote mirror to primary: replication is stopped and mutating API is enabled. Promotion is persistent
+	Promote() (MirrorStatus, error)
+}
+
+// Delivery of lifecycle events to webhooks of server and lambdas
+type Webhooks interface {
+	// Recent delivery attempts from the newest (zero limit - all kept attempts)
+	Deliveries(limit int) []WebhookDelivery
 }
 
+// Deploys of lambdas from tracked branches of git repositories (see types.GitSource)
+type GitDeploys interface {
+	// Fetch tracked branch of lambda and deploy new commit (force - deploy even the same or failed commit). Returns
+	// status after attempt, ErrNotTracked if lambda has no git source, ErrDeployRunning if deploy is in progress
+	Sync(ctx context.Context, uid string, force bool) (*GitStatus, error)
+	// Status of deploys of lambda (nil - lambda does not track git)
+	Status(uid string) *GitStatus
+	// Deploy lambda in background (ex: by ping of repository). Deploy is repeated after the running one
+	Trigger(uid string)
+}
+
+// Backup of whole server as single archive and restore of it on another (or the same) server
+type Backups interface {
+	// Write .tar.gz archive of lambdas (content, manifests, aliases, state), policies, queues, API tokens, sealed
+	// secrets and settings
+	Backup(out io.Writer, options BackupOptions) error
+	// Restore items of archive which do not exist (see RestoreOptions.Conflict). Report of created and skipped items is
+	// returned also with error: items before failure are restored
+	Restore(ctx context.Context, archive io.Reader, options RestoreOptions) (*RestoreReport, error)
+}
+
+// Reload of files edited on disk out of server: manifests, project config, security profile and code of lambdas
+type Reloader interface {
+	// Reload project config, security profile, manifests and changed files of all lambdas (empty uid) or manifest and
+	// files of one lambda (files are reloaded regardless of changes). Changes are recorded by actor. Invalid files
+	// are not applied and reported: the last good state is kept
+	Reload(uid string, actor string) (*ReloadReport, error)
+}
+
+// Import of lambdas from archives by URL (ex: release artifacts)
+type Artifacts interface {
+	// Download archive, extract it to staged copy of lambda (new or updated), apply manifest of archive and run
+	// post-clone action. Lambda is created or replaced only if all steps succeeded: failed import changes nothing
+	Import(ctx context.Context, request ArtifactImport) (*ArtifactReport, error)
+}
+
+// Frozen artifacts of lambdas: archives of fully built lambdas which are restored without builds and network
+type Freezer interface {
+	// Freeze all files of lambda (see FileSystem.Freeze) to artifact. Freeze of the same files returns the same
+	// artifact; old artifacts of lambda are pruned by retention
+	Freeze(uid string) (*FrozenArtifact, error)
+	// Add archive of Freeze (ex: from backup) as the newest artifact of lambda
+	Add(uid string, archive io.Reader) (*FrozenArtifact, error)
+	// Artifacts of lambda from the newest
+	Artifacts(uid string) []FrozenArtifact
+	// Write archive of artifact of lambda. Unknown artifact is ErrFrozenNotFound
+	Download(uid string, id string, out io.Writer) error
+	// Replace all files of lambda by artifact (empty ID - the newest) and apply manifest of artifact. Unknown artifact
+	// is ErrFrozenNotFound
+	Thaw(uid string, id string) (*FrozenArtifact, error)
+}
+
+// Versions of lambdas: content-addressed snapshots of files and manifest taken on deploys and restored by rollback
+type Versions interface {
+	// Snapshot files of lambda (except ignored by .cgiignore) as the newest version by author with optional message.
+	// Snapshot of the same files as the newest version returns it (annotated by message if set); old versions are
+	// pruned by retention
+	Snapshot(uid string, author string, message string) (*LambdaVersion, error)
+	// Versions of lambda from the newest
+	Versions(uid string) []LambdaVersion
+	// Restore files and manifest of version in staged copy of lambda, run build action there and replace files of
+	// lambda by staged copy. Failed build keeps files of lambda. Unknown version is ErrVersionNotFound
+	Rollback(ctx context.Context, uid string, rollback VersionRollback) (*RollbackReport, error)
+}
+
+// Warm-up of lambdas on server start: availability of every lambda is verified, absent environment of lambdas with
+// warm-up in manifest is rebuilt by action. Failures are reported, they don't stop server or other lambdas
+type WarmUp interface {
+	// Report of warm-up (running or finished)
+	Report() WarmUpReport
+}
+
+// Alert rules of lambdas (defined in manifest) evaluated by invocation records
+type Alerts interface {
+	// Evaluate rules of lambda by record of invocation (every record, without sampling)
+	Track(record stats.Record)
+	// Check that lambda accepts requests: error if lambda is disabled by alert or circuit is open. Returns
+	// time when lambda could be re-enabled automatically (zero - unknown or manual)
+	Allow(uid string) (time.Time, error)
+	// Live status of alert rules of lambda
+	Status(uid string) []AlertStatus
+	// Reset fired rules of lambda (all if rule is empty) and re-enable it. By is the login of user
+	Reset(uid string, rule string, by string) ([]AlertStatus, error)
+}
+
+// Persistent journal of administrative changes (audit log)
+type Journal interface {
+	// Record change. ID and time (if not set) are assigned by journal, change is written to disk before return,
+	// failures are logged
+	Record(change Change)
+	// Changes matched by query from the oldest with offset and limit (zero - all). Returns total number of matched
+	// changes
+	Changes(query ChangeQuery, offset, limit int) ([]Change, int, error)
+}
+
+// Full-text index of lambdas by names, descriptions, aliases and labels
+type Search interface {
+	// Lambdas matching all words of query (word matches the same word or beginning of longer word) ordered by
+	// relevance, hits are limited (zero - default limit). Index is updated by changes of lambdas before search
+	Search(query string, limit int) SearchResult
+}
+
+// Encrypted store of named secrets referenced by environment of lambdas (see types.SecretReference). Values are
+// write-only for API: only names and metadata are listed
+type Secrets interface {
+	// Metadata of secrets ordered by name
+	List() []Secret
+	// Set value of secret (created if not exists)
+	Set(name, value string) (*Secret, error)
+	// Remove secret, false if not exists
+	Remove(name string) (bool, error)
+	// Decrypted value of secret
+	Value(name string) (string, bool)
+}
+
+// Link (alias) name limitations
+var LinkNameReg = types.AliasNameReg
+
 // Queue name limitations
 var QueueNameReg = regexp.MustCompile(`^[a-z0-9A-Z-]{3,64}$`)
 
@@ -148,6 +466,44 @@ type Queues interface {
 	Find(targetLambda string) []Queue
 	// Get queue by ID or return ErrNotExists
 	Get(queue string) (*Queue, error)
+	// Live status of queue (maintained counters, cheap)
+	Status(queue string) (*QueueStatus, error)
+	// Oldest message of queue without consuming it (body is not read)
+	Inspect(queue string) (*QueueMessage, error)
+	// Return quarantined (corrupted) messages to the end of queue. Returns number of returned messages
+	RetryQuarantined(queue string) (int, error)
+	// Remove quarantined (corrupted) messages. Returns number of removed messages
+	PurgeQuarantined(queue string) (int, error)
+	// Dead letters of lambda (requests not processed after all attempts) from the newest, body is not returned
+	DeadLetters(lambda string) ([]DeadLetter, error)
+	// Dead letter of lambda with body
+	DeadLetter(lambda string, id string) (*DeadLetter, error)
+	// Remove dead letters of lambda by IDs (empty - all). Returns number of removed letters
+	RemoveDeadLetters(lambda string, ids []string) (int, error)
+	// Put dead letters of lambda by IDs (empty - all) back to their queues and remove them. Returns number of
+	// returned letters
+	RedriveDeadLetters(lambda string, ids []string) (int, error)
+}
+
+// Store of the newest requests of lambdas captured for debugging (see types.Manifest.Capture)
+type Captures interface {
+	// Add captured request of lambda with body. ID is assigned by store, only the newest limit requests of lambda are kept
+	Add(request CapturedRequest, body []byte, limit int) error
+	// Captured requests of lambda from the newest, body is not returned
+	List(lambda string) ([]CapturedRequest, error)
+	// Captured request of lambda with body
+	Get(lambda string, id string) (*CapturedRequest, error)
+	// Remove all captured requests of lambda (ex: lambda is removed)
+	Purge(lambda string) error
+}
+
+// Queue of asynchronous invocations
+type AsyncQueue interface {
+	// Pending invocations (including in-flight) in order of acceptance
+	Pending() []AsyncInvocation
+	// Remove pending invocations by tickets (empty - all pending), in-flight invocations are not interrupted. Returns
+	// number of removed invocations
+	Purge(tickets []string) (int, error)
 }
 
 type Validator interface {
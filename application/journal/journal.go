@@ -0,0 +1,189 @@
+// Package journal keeps administrative changes of server (deploys, manifest edits, aliases, policies, users,
+// settings and failed authentications) in append-only file of JSON lines (rotated by size) and groups changes of time
+// range into reports.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// maximum size of single record in file
+const maxLine = 1024 * 1024
+
+// Open journal in file (created on the first change). ID of changes continues the sequence of existing file and
+// its rotated files
+func New(file string) (*Journal, error) {
+	j := &Journal{file: file}
+	err := j.scan(func(change application.Change) bool {
+		j.lastID = change.ID
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	return j, nil
+}
+
+// Journal of changes in JSON lines file. File over MaxSize is rotated to file.1 (previous file.1 to file.2 and so on),
+// rotated files over Backups are removed
+type Journal struct {
+	Observer func(change application.Change) // optional, called for every recorded change (with ID and time) under lock
+	MaxSize  int64                           // size of file in bytes to rotate (zero - not rotated)
+	Backups  int                             // number of rotated files to keep
+	file     string
+	lock     sync.Mutex
+	lastID   int64
+}
+
+// Record change to file. Failure of write is logged
+func (j *Journal) Record(change application.Change) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.lastID++
+	change.ID = j.lastID
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	if err := j.append(change); err != nil {
+		log.Println("[ERROR]", "journal: record change", change.ID, "-", err)
+	}
+	if j.Observer != nil {
+		j.Observer(change)
+	}
+}
+
+// Changes matched by query from the oldest (including rotated files) with offset and limit (zero - all)
+func (j *Journal) Changes(query application.ChangeQuery, offset, limit int) ([]application.Change, int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	var ans []application.Change
+	var total int
+	err := j.scan(func(change application.Change) bool {
+		if !query.Matches(change) {
+			return true
+		}
+		if total >= offset && (limit <= 0 || len(ans) < limit) {
+			ans = append(ans, change)
+		}
+		total++
+		return true
+	})
+	return ans, total, err
+}
+
+// change is synced to disk: record of successful operation should survive crash right after response
+func (j *Journal) append(change application.Change) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := os.MkdirAll(filepath.Dir(j.file), 0755); err != nil {
+		return err
+	}
+	if err := j.rotate(int64(len(data))); err != nil {
+		return fmt.Errorf("rotate: %w", err)
+	}
+	f, err := os.OpenFile(j.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rotate non-empty file if it will be over limit with the next record
+func (j *Journal) rotate(next int64) error {
+	if j.MaxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(j.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+next <= j.MaxSize {
+		return nil
+	}
+	if j.Backups <= 0 {
+		return os.Remove(j.file)
+	}
+	if err := os.Remove(j.backup(j.Backups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := j.Backups - 1; i > 0; i-- {
+		if err := os.Rename(j.backup(i), j.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(j.file, j.backup(1))
+}
+
+func (j *Journal) backup(n int) string {
+	return j.file + "." + strconv.Itoa(n)
+}
+
+// read changes from rotated files (from the oldest) and file till handler returns false
+func (j *Journal) scan(handler func(change application.Change) bool) error {
+	var files []string
+	for n := 1; ; n++ {
+		if _, err := os.Stat(j.backup(n)); err != nil {
+			break
+		}
+		files = append([]string{j.backup(n)}, files...)
+	}
+	files = append(files, j.file)
+	for _, file := range files {
+		next, err := scanFile(file, handler)
+		if err != nil {
+			return err
+		}
+		if !next {
+			break
+		}
+	}
+	return nil
+}
+
+// read changes from file till handler returns false (result is false too). Damaged lines (ex: cut by power loss)
+// are skipped
+func scanFile(file string, handler func(change application.Change) bool) (bool, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for scanner.Scan() {
+		var change application.Change
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			continue
+		}
+		if !handler(change) {
+			return false, nil
+		}
+	}
+	return true, scanner.Err()
+}
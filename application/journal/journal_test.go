@@ -0,0 +1,180 @@
+package journal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/journal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestJournal_pagination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "changes.jsonl")
+
+	begin := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	changes, err := journal.New(file)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		changes.Record(application.Change{Time: begin.Add(time.Duration(i) * time.Hour), Actor: "admin", Kind: application.ChangePolicy, Summary: "policy updated"})
+	}
+
+	// sequence continues after reopen
+	changes, err = journal.New(file)
+	require.NoError(t, err)
+	changes.Record(application.Change{Time: begin.Add(10 * time.Hour), Actor: "admin", Kind: application.ChangeUser, Summary: "password changed"})
+
+	page, total, err := changes.Changes(application.ChangeQuery{Since: begin.Add(2 * time.Hour), Until: begin.Add(9 * time.Hour)}, 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 7, total)
+	require.Len(t, page, 3)
+	assert.Equal(t, int64(3), page[0].ID)
+
+	report, err := journal.Report(changes, begin, begin.Add(24*time.Hour), 8, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 11, report.Total)
+	assert.Equal(t, 10, report.Next)
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, "2 policy changes by admin", report.Groups[0].Summary)
+
+	report, err = journal.Report(changes, begin, begin.Add(24*time.Hour), report.Next, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Next, "last page")
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, int64(11), report.Groups[0].Changes[0].ID)
+	assert.Equal(t, "1 user change by admin", report.Groups[0].Summary)
+}
+
+func TestJournal_rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "changes.jsonl")
+
+	begin := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	changes, err := journal.New(file)
+	require.NoError(t, err)
+	changes.MaxSize, changes.Backups = 400, 2
+	for i := 0; i < 12; i++ {
+		change := application.Change{Time: begin.Add(time.Duration(i) * time.Hour), Actor: "admin", Kind: application.ChangeDeploy, Lambda: "a", Summary: "content uploaded"}
+		if i%3 == 0 {
+			change.Kind, change.Lambda, change.Actor, change.Summary = application.ChangeAuth, "", "root", "login of root failed"
+		}
+		changes.Record(change)
+	}
+	_, err = os.Stat(file + ".2")
+	require.NoError(t, err)
+	_, err = os.Stat(file + ".3")
+	assert.True(t, os.IsNotExist(err), "only 2 rotated files are kept")
+
+	// sequence continues from rotated files
+	changes, err = journal.New(file)
+	require.NoError(t, err)
+	all, total, err := changes.Changes(application.ChangeQuery{}, 0, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	assert.Equal(t, len(all), total)
+	assert.Less(t, total, 12, "the oldest changes are removed")
+	assert.Equal(t, int64(12), all[len(all)-1].ID)
+	for i := 1; i < len(all); i++ {
+		assert.Equal(t, all[i-1].ID+1, all[i].ID, "changes are ordered across files")
+	}
+
+	page, err := journal.Page(changes, application.ChangeQuery{Kind: application.ChangeAuth, Since: begin.Add(6 * time.Hour)}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, int64(7), page.Changes[0].ID)
+	assert.Equal(t, int64(10), page.Changes[1].ID)
+	page, err = journal.Page(changes, application.ChangeQuery{Lambda: "a", Since: begin.Add(6 * time.Hour), Until: begin.Add(9 * time.Hour)}, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, 1, page.Next)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, int64(8), page.Changes[0].ID)
+}
+
+func TestReport_createdAndRemoved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	changes, err := journal.New(filepath.Join(dir, "changes.jsonl"))
+	require.NoError(t, err)
+
+	begin := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	record := func(minute int, actor, kind, uid, name string) {
+		changes.Record(application.Change{Time: begin.Add(time.Duration(minute) * time.Minute), Actor: actor, Kind: kind, Lambda: uid, Name: name, Summary: kind})
+	}
+	record(1, "admin", application.ChangeCreate, "a1", "")
+	record(2, "ci", application.ChangeDeploy, "b2", "worker")
+	record(3, "admin", application.ChangeSettings, "", "")
+	record(4, "admin", application.ChangeManifest, "a1", "tmp")
+	record(5, "ci", application.ChangeDeploy, "a1", "tmp")
+	record(6, "ci", application.ChangeDeploy, "a1", "tmp")
+	record(7, "admin", application.ChangeRemove, "a1", "tmp")
+
+	report, err := journal.Report(changes, begin, begin.Add(time.Hour), 0, 0)
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 3)
+
+	created := report.Groups[0]
+	assert.Equal(t, "a1", created.Lambda)
+	assert.Equal(t, "tmp", created.Name, "the latest known name")
+	assert.True(t, created.Created)
+	assert.True(t, created.Removed)
+	assert.Len(t, created.Changes, 5)
+	assert.Equal(t, "created, 2 deploys, 1 manifest edit, removed by admin, ci", created.Summary)
+
+	assert.Equal(t, "b2", report.Groups[1].Lambda)
+	assert.False(t, report.Groups[1].Created)
+	assert.Equal(t, "", report.Groups[2].Lambda, "server-wide changes are the last")
+}
+
+func TestManifestChanges(t *testing.T) {
+	previous := types.Manifest{Name: "hello", Run: []string{"python3", "app.py"}}
+	current := previous
+	current.Name = "world"
+	current.MaxConcurrency = 2
+	current.Cron = []types.Schedule{{Cron: "@hourly", Action: "update"}}
+
+	changes, err := journal.ManifestChanges(previous, current)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, application.ChangeSchedule, changes[0].Kind)
+	assert.Equal(t, application.ChangeManifest, changes[1].Kind)
+	assert.Equal(t, "manifest changed: max_concurrency, name", changes[1].Summary)
+	hash, err := current.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, changes[1].Revision)
+	assert.NotEqual(t, changes[1].Previous, changes[1].Revision)
+
+	changes, err = journal.ManifestChanges(current, current)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestJournal_observer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	changes, err := journal.New(filepath.Join(dir, "changes.jsonl"))
+	require.NoError(t, err)
+	var observed []application.Change
+	changes.Observer = func(change application.Change) {
+		observed = append(observed, change)
+	}
+	changes.Record(application.Change{Actor: "admin", Kind: application.ChangeUser, Summary: "password changed"})
+	require.Len(t, observed, 1)
+	assert.Equal(t, int64(1), observed[0].ID)
+	assert.False(t, observed[0].Time.IsZero())
+	assert.Equal(t, "password changed", observed[0].Summary)
+}
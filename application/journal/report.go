@@ -0,0 +1,237 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Default number of changes on page of report
+const DefaultPageSize = 100
+
+// order of kinds in summary of group with singular and plural nouns (created and removed are flags of group)
+var kindNouns = []struct {
+	kind             string
+	singular, plural string
+}{
+	{application.ChangeDeploy, "deploy", "deploys"},
+	{application.ChangeManifest, "manifest edit", "manifest edits"},
+	{application.ChangeSchedule, "schedule change", "schedule changes"},
+	{application.ChangeAlias, "alias change", "alias changes"},
+	{application.ChangePolicy, "policy change", "policy changes"},
+	{application.ChangeUser, "user change", "user changes"},
+	{application.ChangeSettings, "settings change", "settings changes"},
+	{application.ChangeTransfer, "transfer", "transfers"},
+	{application.ChangeSecret, "secret change", "secret changes"},
+	{application.ChangeAuth, "failed authentication", "failed authentications"},
+}
+
+// Report of changes in time range [since, until) grouped by lambda. Page is defined by offset and limit of changes
+// (zero limit - DefaultPageSize), groups are built by changes of page: lambda created and removed in range has both
+// flags if both changes are on the same page
+func Report(journal application.Journal, since, until time.Time, offset, limit int) (*application.ChangesReport, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	changes, total, err := journal.Changes(application.ChangeQuery{Since: since, Until: until}, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	report := &application.ChangesReport{
+		Since:  since,
+		Until:  until,
+		Total:  total,
+		Offset: offset,
+		Groups: Group(changes),
+	}
+	if next := offset + len(changes); next < total {
+		report.Next = next
+	}
+	return report, nil
+}
+
+// Page of changes matched by query (zero limit - DefaultPageSize)
+func Page(journal application.Journal, query application.ChangeQuery, offset, limit int) (*application.ChangesPage, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	changes, total, err := journal.Changes(query, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = make([]application.Change, 0)
+	}
+	page := &application.ChangesPage{
+		Query:   query,
+		Total:   total,
+		Offset:  offset,
+		Changes: changes,
+	}
+	if next := offset + len(changes); next < total {
+		page.Next = next
+	}
+	return page, nil
+}
+
+// Group changes by lambda in order of the first change, server-wide changes are the last group. Group has the latest
+// known name of lambda and human-readable summary
+func Group(changes []application.Change) []application.ChangeGroup {
+	var ans = make([]application.ChangeGroup, 0)
+	var server *application.ChangeGroup
+	var index = make(map[string]int)
+	for _, change := range changes {
+		var g *application.ChangeGroup
+		if change.Lambda == "" {
+			if server == nil {
+				server = &application.ChangeGroup{}
+			}
+			g = server
+		} else if i, ok := index[change.Lambda]; ok {
+			g = &ans[i]
+		} else {
+			index[change.Lambda] = len(ans)
+			ans = append(ans, application.ChangeGroup{Lambda: change.Lambda})
+			g = &ans[len(ans)-1]
+		}
+		if change.Name != "" {
+			g.Name = change.Name
+		}
+		switch change.Kind {
+		case application.ChangeCreate:
+			g.Created = true
+		case application.ChangeRemove:
+			g.Removed = true
+		}
+		g.Changes = append(g.Changes, change)
+	}
+	if server != nil {
+		ans = append(ans, *server)
+	}
+	for i := range ans {
+		ans[i].Summary = summarize(ans[i])
+	}
+	return ans
+}
+
+// summary of group: flags, number of changes by kind and actors
+func summarize(g application.ChangeGroup) string {
+	var counts = make(map[string]int)
+	var actors []string
+	var seen = make(map[string]bool)
+	for _, change := range g.Changes {
+		counts[change.Kind]++
+		if change.Actor != "" && !seen[change.Actor] {
+			seen[change.Actor] = true
+			actors = append(actors, change.Actor)
+		}
+	}
+	var parts []string
+	if g.Created {
+		parts = append(parts, "created")
+	}
+	for _, noun := range kindNouns {
+		switch n := counts[noun.kind]; {
+		case n == 1:
+			parts = append(parts, "1 "+noun.singular)
+		case n > 1:
+			parts = append(parts, fmt.Sprint(n, " ", noun.plural))
+		}
+	}
+	if g.Removed {
+		parts = append(parts, "removed")
+	}
+	text := strings.Join(parts, ", ")
+	if len(actors) > 0 {
+		text += " by " + strings.Join(actors, ", ")
+	}
+	return text
+}
+
+// Manifest changes between revisions: edit of fields (except cron) and change of schedules. Actor and lambda are not
+// filled
+func ManifestChanges(previous, current types.Manifest) ([]application.Change, error) {
+	previousHash, err := previous.Hash()
+	if err != nil {
+		return nil, err
+	}
+	currentHash, err := current.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if previousHash == currentHash {
+		return nil, nil
+	}
+	fields, err := changedFields(previous, current)
+	if err != nil {
+		return nil, err
+	}
+	var ans []application.Change
+	var edited []string
+	for _, field := range fields {
+		if field == "cron" {
+			ans = append(ans, application.Change{
+				Kind:     application.ChangeSchedule,
+				Summary:  fmt.Sprintf("schedules changed: %d → %d", len(previous.Cron), len(current.Cron)),
+				Previous: previousHash,
+				Revision: currentHash,
+			})
+			continue
+		}
+		edited = append(edited, field)
+	}
+	if len(edited) > 0 {
+		ans = append(ans, application.Change{
+			Kind:     application.ChangeManifest,
+			Summary:  "manifest changed: " + strings.Join(edited, ", "),
+			Previous: previousHash,
+			Revision: currentHash,
+		})
+	}
+	return ans, nil
+}
+
+// top-level fields of manifests with different JSON values, sorted by name
+func changedFields(previous, current types.Manifest) ([]string, error) {
+	before, err := fieldsOf(previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := fieldsOf(current)
+	if err != nil {
+		return nil, err
+	}
+	var ans []string
+	for name, value := range after {
+		if string(before[name]) != string(value) {
+			ans = append(ans, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			ans = append(ans, name)
+		}
+	}
+	sort.Strings(ans)
+	return ans, nil
+}
+
+func fieldsOf(manifest types.Manifest) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	return fields, json.Unmarshal(data, &fields)
+}
@@ -0,0 +1,327 @@
+package lambda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	zipEmpty  = []byte("PK\x05\x06") // archive without files
+)
+
+// extract .tar.gz or .zip archive (format is detected by content) to the directory. Returns names (slash separated,
+// relative to the directory) of extracted files. Entries are checked by limits before extraction, so nothing is
+// written if archive is over limits
+func extractArchive(content io.Reader, dest string, limits types.UploadLimits, quota int64) (map[string]bool, error) {
+	data, err := readArchive(content, limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEntries(data, limits); err != nil {
+		return nil, err
+	}
+	if quota > 0 {
+		if err := checkArchiveQuota(data, dest, quota); err != nil {
+			return nil, err
+		}
+	}
+	out, err := newExtractor(dest)
+	if err != nil {
+		return nil, err
+	}
+	return out.extracted, walkArchive(data, out)
+}
+
+// CheckArchive (.tar.gz or .zip) by upload limits without extraction: the same checks as server does before
+// extraction of uploaded content
+func CheckArchive(content io.Reader, limits types.UploadLimits) error {
+	data, err := readArchive(content, limits)
+	if err != nil {
+		return err
+	}
+	return checkEntries(data, limits)
+}
+
+// space of archive for headers of entry (tar header and padding of content)
+const entryOverhead = 1024
+
+// archive is read to memory: zip requires random access (central directory is at the end) and all entries are
+// checked before extraction. Archive could not be bigger than extracted files with headers of entries
+func readArchive(content io.Reader, limits types.UploadLimits) ([]byte, error) {
+	limits = limits.Effective()
+	maxSize := limits.Size + int64(limits.Files+1)*entryOverhead
+	data, err := ioutil.ReadAll(io.LimitReader(content, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: archive is bigger than maximum size %d bytes; exclude files from upload by .cgiignore", types.ErrUploadLimit, maxSize)
+	}
+	return data, nil
+}
+
+func checkEntries(data []byte, limits types.UploadLimits) error {
+	checker := &limitsChecker{counter: limits.Counter()}
+	if err := walkArchive(data, checker); err != nil {
+		return err
+	}
+	return checker.err()
+}
+
+// receiver of archive entries
+type entryWriter interface {
+	dir(name string, mode os.FileMode) error
+	file(name string, mode os.FileMode, size int64, content io.Reader) error
+}
+
+func walkArchive(data []byte, out entryWriter) error {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return untarFiles(gz, out)
+	case bytes.HasPrefix(data, zipMagic), bytes.HasPrefix(data, zipEmpty):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("%w: %v", application.ErrUnsupportedArchive, err)
+		}
+		return unzipContent(archive, out)
+	default:
+		return application.ErrUnsupportedArchive
+	}
+}
+
+func untarFiles(src io.Reader, out entryWriter) error {
+	reader := tar.NewReader(src)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, tar.ErrHeader) {
+			return fmt.Errorf("%w: gzip content is not tar archive", application.ErrUnsupportedArchive)
+		} else if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = out.dir(header.Name, header.FileInfo().Mode())
+		case tar.TypeReg:
+			err = out.file(header.Name, header.FileInfo().Mode(), header.Size, reader)
+		default:
+			err = fmt.Errorf("unsupported type of file %s %v", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extract zip archive as content of lambda. Unlike bundles, names with backslashes (archives created on Windows) are
+// normalized
+func unzipContent(archive *zip.Reader, out entryWriter) error {
+	for _, file := range archive.File {
+		var err error
+		name := strings.ReplaceAll(file.Name, "\\", "/")
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			err = out.dir(name, mode)
+		case mode.IsRegular():
+			err = unzipEntry(name, mode, file, out)
+		default:
+			err = fmt.Errorf("unsupported type of file %s %v", file.Name, mode.Type())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unzipEntry(name string, mode os.FileMode, file *zip.File, out entryWriter) error {
+	content, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	defer content.Close()
+	return out.file(name, mode, int64(file.UncompressedSize64), content)
+}
+
+// checker of archive entries by limits without writing. Entries over limit of files are counted till the end of
+// archive to report by how much the limit is exceeded
+type limitsChecker struct {
+	counter  *types.UploadCounter
+	entries  int
+	exceeded *types.UploadLimitError // limit of files
+}
+
+func (lc *limitsChecker) dir(name string, mode os.FileMode) error {
+	_, err := lc.entry(name)
+	return err
+}
+
+func (lc *limitsChecker) file(name string, mode os.FileMode, size int64, content io.Reader) error {
+	rel, err := lc.entry(name)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	if lc.exceeded != nil {
+		return nil
+	}
+	return lc.counter.File(rel, size)
+}
+
+func (lc *limitsChecker) entry(name string) (string, error) {
+	rel, err := entryName(name)
+	if err != nil || rel == "" {
+		return rel, err
+	}
+	lc.entries++
+	if lc.exceeded != nil {
+		return rel, nil
+	}
+	err = lc.counter.Entry(rel)
+	var limit *types.UploadLimitError
+	if errors.As(err, &limit) && limit.Limit == types.LimitFiles {
+		lc.exceeded = limit
+		return rel, nil
+	}
+	return rel, err
+}
+
+func (lc *limitsChecker) err() error {
+	if lc.exceeded == nil {
+		return nil
+	}
+	lc.exceeded.Value = int64(lc.entries)
+	return lc.exceeded
+}
+
+// writer of archive entries checked by limits. Files are written only inside the root, existing symlinks are
+// replaced, not followed. File is written to temporary name and renamed, so running process never sees (and never
+// executes) partially written file
+type extractor struct {
+	root      string          // real path of destination
+	extracted map[string]bool // names of written files
+}
+
+func newExtractor(dest string) (*extractor, error) {
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &extractor{root: root, extracted: make(map[string]bool)}, nil
+}
+
+func (ex *extractor) dir(name string, mode os.FileMode) error {
+	location, err := ex.location(name)
+	if err != nil || location == ex.root {
+		return err
+	}
+	if err := os.MkdirAll(location, mode.Perm()|0700); err != nil {
+		return fmt.Errorf("create dir %s: %w", name, err)
+	}
+	return nil
+}
+
+func (ex *extractor) file(name string, mode os.FileMode, size int64, content io.Reader) error {
+	location, err := ex.location(name)
+	if err != nil {
+		return err
+	}
+	if location == ex.root {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	rel, _ := entryName(name)
+	ex.extracted[rel] = true
+	if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return fmt.Errorf("create dir %s: %w", name, err)
+	}
+	// hidden temporary file in the same directory: rename is atomic and symlink at location is replaced
+	temp := filepath.Join(filepath.Dir(location), "."+filepath.Base(location)+".upload-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	f, err := os.OpenFile(temp, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("create file %s: %w", name, err)
+	}
+	// size is checked by limits, content should not be bigger
+	n, err := io.Copy(f, io.LimitReader(content, size+1))
+	if err == nil && n > size {
+		err = fmt.Errorf("content is bigger than declared size %d bytes", size)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(temp)
+		return fmt.Errorf("finish file %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("close file %s: %w", name, err)
+	}
+	if err := os.Rename(temp, location); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("replace file %s: %w", name, err)
+	}
+	return nil
+}
+
+// location of entry inside root. Parent directories should not lead out of the root by symlinks
+func (ex *extractor) location(name string) (string, error) {
+	rel, err := entryName(name)
+	if err != nil {
+		return "", err
+	}
+	location := filepath.Join(ex.root, filepath.FromSlash(rel))
+	for parent := filepath.Dir(location); len(parent) > len(ex.root); parent = filepath.Dir(parent) {
+		real, err := filepath.EvalSymlinks(parent)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if real != ex.root && !strings.HasPrefix(real, ex.root+string(filepath.Separator)) {
+			return "", fmt.Errorf("path %q is out of lambda by symlink", name)
+		}
+		break
+	}
+	return location, nil
+}
+
+// normalize name of archive entry to slash-separated path relative to the root (empty is the root). Absolute paths
+// (also with drive letter) and paths out of the root are rejected
+func entryName(name string) (string, error) {
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || (len(name) >= 2 && name[1] == ':') {
+		return "", fmt.Errorf("absolute path %q in archive", name)
+	}
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", nil
+	}
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("path %q is out of archive root", name)
+	}
+	return clean, nil
+}
@@ -28,7 +28,11 @@ func DummyPublic(path string, bin string, args ...string) (*localLambda, error)
 // Load lambda definition from directory
 func FromDir(path string) (*localLambda, error) {
 	ll := &localLambda{rootDir: path}
-	return ll, ll.reindex()
+	if err := ll.reindex(); err != nil {
+		return ll, err
+	}
+	ll.cleanScratch()
+	return ll, nil
 }
 
 // Clone lambda definition to directory and load
@@ -46,33 +50,21 @@ func FromGit(ctx context.Context, privateKey, repo string, path string) (*localL
 	return FromDir(path)
 }
 
+// Create lambda from template in directory and load. Post-clone action is not invoked: it should be done by platform
+// after lambda is added (with credentials and limits of actions)
 func FromTemplate(ctx context.Context, template templates.Template, path string) (*localLambda, error) {
-	err := template.Manifest.SaveAs(filepath.Join(path, internal.ManifestFile))
-	if err != nil {
-		return nil, fmt.Errorf("write manifest: %w", err)
-	}
-	for fileName, content := range template.Files {
-		destFile := filepath.Join(path, fileName)
-		err := os.MkdirAll(filepath.Dir(destFile), 0755)
-		if err != nil {
-			return nil, fmt.Errorf("create file %s directory: %w", fileName, err)
-		}
-		err = os.WriteFile(destFile, []byte(content), 0755)
-		if err != nil {
-			return nil, fmt.Errorf("write file %s content: %w", fileName, err)
-		}
+	manifest := template.Manifest
+	if len(manifest.Check) == 0 {
+		// lambda is checked the same way as availability of template
+		manifest.Check = template.Check
 	}
-
-	lambda, err := FromDir(path)
+	err := manifest.SaveAs(filepath.Join(path, internal.ManifestFile))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("write manifest: %w", err)
 	}
-	if template.PostClone != "" {
-		err := lambda.Do(ctx, template.PostClone, 0, nil, nil)
-		if err != nil {
-			return nil, fmt.Errorf("invoke post-clone %s: %w", template.PostClone, err)
-		}
+	if err := template.Materialize(ctx, path); err != nil {
+		return nil, fmt.Errorf("materialize template files: %w", err)
 	}
-	return lambda, nil
 
+	return FromDir(path)
 }
@@ -1,126 +1,736 @@
 package lambda
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/types"
 )
 
 type localLambda struct {
-	rootDir   string
-	staticDir string
-	uid       string
-	manifest  types.Manifest
-	creds     *types.Credential
-	lock      sync.RWMutex
+	rootDir     string
+	staticDir   string
+	uid         string
+	manifest    types.Manifest
+	creds       *types.Credential
+	defaults    types.Runtime
+	profile     types.SecurityProfile // security profile of server (zero - default)
+	builder     application.Builder   // executor of actions (nil - without limits)
+	secrets     application.Secrets   // store of referenced secrets (nil - references are empty)
+	bundle      *zip.ReadCloser       // opened bundle if lambda is bundled
+	bundleHash  string
+	lock        sync.RWMutex
+	startup     *startupState // last startup (on_start) action
+	startLock   sync.Mutex
+	runs        map[string]scheduledRun // last runs of scheduled actions by cron and action
+	runsLock    sync.Mutex
+	start       func(cmd *exec.Cmd) error // starter of invocation process (nil - cmd.Start)
+	warning     string                    // problem of lambda till the next change of manifest or content
+	schema      *types.InputSchema        // compiled input schema of manifest (nil - not defined)
+	runAs       *types.Credential         // resolved account of manifest (nil - not set or not applied)
+	runAsErr    error                     // account of manifest could not be applied: invocations and actions fail
+	gate        buildGate                 // serialization of build actions with invocations
+	workers     *workerPool               // pool of worker mode (nil - not started)
+	workersLock sync.Mutex
+	containers  application.Containers // engine of containers (nil - lambda with container is not invoked)
+	pull        *imagePull             // pull of image of container (nil - lambda without container)
+	pullLock    sync.Mutex
+	revision    atomic.Uint64 // changed by every change of content, manifest or settings (see Revision)
+	disk        diskMeter     // cached size of lambda directory (see DiskUsage)
+	logs        logFiles      // persistent log files of invocations (see LogFiles)
 }
 
 func (local *localLambda) UID() string { return local.uid }
 
+func (local *localLambda) Revision() uint64 { return local.revision.Load() }
+
 func (local *localLambda) Manifest() types.Manifest {
 	local.lock.RLock()
 	defer local.lock.RUnlock()
 	return local.manifest
 }
 
+func (local *localLambda) Effective() types.Manifest {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	return local.profile.Apply(local.manifest)
+}
+
 func (local *localLambda) SetManifest(manifest types.Manifest) error {
+	return local.applyManifest(manifest, true)
+}
+
+func (local *localLambda) ApplyManifest(manifest types.Manifest) error {
+	return local.applyManifest(manifest, false)
+}
+
+func (local *localLambda) Warning() string {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	if local.warning == "" && local.runAsErr != nil {
+		return "run as is not applied: " + local.runAsErr.Error()
+	}
+	if local.warning == "" {
+		return local.limitsWarning()
+	}
+	return local.warning
+}
+
+func (local *localLambda) InputSchema() *types.InputSchema {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	return local.schema
+}
+
+func (local *localLambda) SetWarning(warning string) {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.warning = warning
+}
+
+func (local *localLambda) applyManifest(manifest types.Manifest, save bool) error {
 	local.lock.Lock()
 	defer local.lock.Unlock()
-	err := manifest.SaveAs(local.manifestFile())
+	if err := local.profile.Check(manifest); err != nil {
+		return err
+	}
+	schema, err := manifest.CompileInputSchema()
+	if err != nil {
+		return fmt.Errorf("compile input schema: %w", err)
+	}
+	runAs, err := resolveRunAs(manifest, local.profile)
 	if err != nil {
-		return fmt.Errorf("save manifest: %w", err)
+		return err
 	}
+	if save {
+		if err := manifest.SaveAs(local.manifestFile()); err != nil {
+			return fmt.Errorf("save manifest: %w", err)
+		}
+	}
+	local.stopWorkers()
+	local.revision.Add(1)
+	local.warning = ""
+	local.schema = schema
+	readOnly, runner := local.readOnly(), local.runner()
 	local.manifest = manifest
-	return nil
+	local.runAs, local.runAsErr = runAs, nil
+	local.warnLimits()
+	local.pullImage()
+	if local.readOnly() != readOnly || !local.runner().Equal(runner) {
+		if err := local.applyFilesOwner(); err != nil {
+			return err
+		}
+	}
+	return local.updateStaticDir()
 }
 
 func (local *localLambda) Credentials() *types.Credential {
 	local.lock.RLock()
 	defer local.lock.RUnlock()
-	return local.creds
+	return local.runner()
 }
 
 func (local *localLambda) SetCredentials(creds *types.Credential) error {
 	local.lock.Lock()
 	defer local.lock.Unlock()
-	if !creds.Equal(local.creds) {
-		local.creds = creds
+	local.stopWorkers()
+	local.revision.Add(1)
+	runner := local.runner()
+	local.creds = creds
+	if !local.runner().Equal(runner) {
 		return local.applyFilesOwner()
 	}
 	return nil
 }
 
+func (local *localLambda) SetDefaults(defaults types.Runtime) {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.revision.Add(1)
+	local.defaults = defaults
+}
+
+func (local *localLambda) SetProfile(profile types.SecurityProfile) error {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.stopWorkers()
+	local.revision.Add(1)
+	readOnly := local.readOnly()
+	local.profile = profile
+	if local.readOnly() != readOnly {
+		if err := local.applyFilesOwner(); err != nil {
+			return err
+		}
+	}
+	// allowed accounts could be changed
+	return local.applyRunAs()
+}
+
+// content is not writable by processes (by manifest or security profile). Should be called under lock
+func (local *localLambda) readOnly() bool {
+	return *local.profile.Apply(local.manifest).ReadOnly
+}
+
+// isolate process by security options of effective manifest. Should be called under lock
+func (local *localLambda) sandbox(cmd *exec.Cmd, manifest types.Manifest) {
+	if !*manifest.Network {
+		internal.DenyNetwork(cmd)
+	}
+	if local.runAsErr != nil && cmd.Err == nil {
+		cmd.Err = fmt.Errorf("run as is not applied: %w", local.runAsErr)
+	}
+	// process of server user could write anything
+	if *manifest.ReadOnly && local.runner() == nil && cmd.Err == nil {
+		cmd.Err = errors.New("read-only content requires user to run lambda")
+	}
+}
+
+func (local *localLambda) SetBuilder(builder application.Builder) {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.builder = builder
+	local.warnLimits()
+}
+
+func (local *localLambda) SetSecrets(secrets application.Secrets) {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.secrets = secrets
+}
+
+func (local *localLambda) Diagnose(globalEnv map[string]string) application.Diagnostic {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	return application.Diagnostic{
+		Runtime: local.runtime(globalEnv),
+		Secrets: local.secretsStatus(globalEnv),
+		Startup: local.startupStatus(),
+		Builds:  local.buildLimits(),
+		Env:     local.envStatus(globalEnv),
+		// container status is updated by pull: not under lock of lambda
+		Container: local.containerStatus(),
+	}
+}
+
+// effective limits of actions. Should be called under lock
+func (local *localLambda) buildLimits() application.BuildLimits {
+	if local.builder == nil {
+		var limits application.BuildLimits
+		if local.manifest.BuildLimits != nil {
+			limits.BuildLimits = *local.manifest.BuildLimits
+		}
+		return limits
+	}
+	return local.builder.Limits(local.manifest.BuildLimits)
+}
+
+// effective runtime: server defaults < global environment < manifest runtime < manifest environment
+func (local *localLambda) runtime(globalEnv map[string]string) types.Runtime {
+	rt := local.defaults.Override(envRuntime(globalEnv)).Override(local.manifest.Runtime())
+	return rt.Override(envRuntime(local.manifest.Environment))
+}
+
+// base environment for any child process with the same priority as effective runtime
+func (local *localLambda) environment(globalEnv map[string]string) []string {
+	var environments = os.Environ()
+	for k, v := range local.defaults.Environment() {
+		environments = append(environments, k+"="+v)
+	}
+	for k, v := range globalEnv {
+		environments = append(environments, k+"="+v)
+	}
+	for k, v := range local.manifest.Runtime().Environment() {
+		environments = append(environments, k+"="+v)
+	}
+	return environments
+}
+
+// environment of manifest with resolved references to base environment (server, global and runtime), to other
+// manifest variables and to secrets of server store. Should be called under lock
+func (local *localLambda) manifestEnvironment(base []string) map[string]string {
+	var values = make(map[string]string, len(base))
+	for _, kv := range base {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			values[k] = v
+		}
+	}
+	return types.ExpandEnvironment(local.resolveSecrets(local.manifest.Environment), func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	})
+}
+
+func (local *localLambda) MergedEnvironment(globalEnv map[string]string) []application.EnvValue {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	var byName = make(map[string]*application.EnvValue)
+	var names []string
+	define := func(source string, env map[string]string) {
+		for k, v := range env {
+			item, ok := byName[k]
+			if !ok {
+				item = &application.EnvValue{Name: k}
+				byName[k] = item
+				names = append(names, k)
+			} else {
+				item.Overridden = append(item.Overridden, item.Source)
+			}
+			item.Value, item.Source = v, source
+		}
+	}
+	define(application.EnvSourceRuntime, local.defaults.Environment())
+	define(application.EnvSourceGlobal, globalEnv)
+	lambdaEnv := local.manifest.Runtime().Environment()
+	for k, v := range local.manifestEnvironment(local.environment(globalEnv)) {
+		lambdaEnv[k] = v
+	}
+	// values of secrets are not revealed
+	for k, v := range local.manifest.Environment {
+		if _, ok := types.SecretReference(v); ok {
+			lambdaEnv[k] = v
+		}
+	}
+	define(application.EnvSourceLambda, lambdaEnv)
+	sort.Strings(names)
+	var ans = make([]application.EnvValue, 0, len(names))
+	for _, name := range names {
+		ans = append(ans, *byName[name])
+	}
+	return ans
+}
+
+func (local *localLambda) Expand(value string, globalEnv map[string]string) string {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	base := local.environment(globalEnv)
+	return types.ExpandValue(value, envLookup(base, local.manifestEnvironment(base)))
+}
+
+// lookup of variable of lambda environment: resolved manifest variables, then base environment (server, global and
+// runtime)
+func envLookup(base []string, manifest map[string]string) func(name string) (string, bool) {
+	return func(name string) (string, bool) {
+		if v, ok := manifest[name]; ok {
+			return v, true
+		}
+		// the latest definition wins as in environment of process
+		for i := len(base) - 1; i >= 0; i-- {
+			if k, v, ok := strings.Cut(base[i], "="); ok && k == name {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// manifest with expanded references in run command and work dir by lambda environment (see Manifest.ExpandRun).
+// Variables from request are not used: client can't change arguments. Should be called under lock
+func (local *localLambda) expandRun(manifest types.Manifest, globalEnv map[string]string) (types.Manifest, error) {
+	if !manifest.ExpandRun {
+		return manifest, nil
+	}
+	base := local.environment(globalEnv)
+	lookup := envLookup(base, local.manifestEnvironment(base))
+	run := make([]string, len(manifest.Run))
+	for i, arg := range manifest.Run {
+		expanded, err := types.ExpandArgument(arg, lookup)
+		if err != nil {
+			return manifest, fmt.Errorf("expand run[%d]: %w", i, err)
+		}
+		run[i] = expanded
+	}
+	workDir, err := types.ExpandArgument(manifest.WorkDir, lookup)
+	if err != nil {
+		return manifest, fmt.Errorf("expand work dir: %w", err)
+	}
+	manifest.Run = run
+	manifest.WorkDir = workDir
+	return manifest, nil
+}
+
 func (local *localLambda) Invoke(ctx context.Context, request types.Request, response io.Writer, globalEnv map[string]string) error {
+	if err := local.awaitStartup(ctx); err != nil {
+		_ = request.Body.Close()
+		return err
+	}
+	leave, err := local.enterGate(ctx)
+	if err != nil {
+		_ = request.Body.Close()
+		return err
+	}
+	defer leave()
 	local.lock.RLock()
 	defer local.lock.RUnlock()
 	defer request.Body.Close()
 
-	if local.staticDir != "" && request.Method == http.MethodGet {
+	// HEAD should get the same headers as GET
+	if local.staticDir != "" && (request.Method == http.MethodGet || request.Method == http.MethodHead) {
 		return local.serveStaticFile(request, response)
 	}
 
-	if len(local.manifest.Run) == 0 {
+	// command of image is run if run is not defined
+	if len(local.manifest.Run) == 0 && local.manifest.Container == nil {
 		return fmt.Errorf("run is not defined in manifest")
 	}
 
-	if local.manifest.Method != "" && local.manifest.Method != local.manifest.Method {
-		return fmt.Errorf("method not allowed")
+	if err := local.manifest.AcceptMethod(request.Method); err != nil {
+		return err
+	}
+	if err := local.checkQuota(local.manifest.DiskQuota(), false); err != nil {
+		return err
 	}
 
-	if local.manifest.TimeLimit > 0 {
-		cctx, cancel := context.WithTimeout(ctx, time.Duration(local.manifest.TimeLimit))
+	manifest, err := local.expandRun(local.profile.Apply(local.manifest), globalEnv)
+	if err != nil {
+		return err
+	}
+	if limit := manifest.InvocationLimit(); limit > 0 {
+		cctx, cancel := context.WithTimeout(ctx, limit)
 		defer cancel()
+		defer markTimeout(cctx)
 		ctx = cctx
 	}
 
 	var input io.Reader = request.Body
 
-	if local.manifest.MaximumPayload > 0 {
-		input = io.LimitReader(input, local.manifest.MaximumPayload)
+	if boundary, ok := types.MultipartBoundary(request.Headers["Content-Type"]); ok && manifest.DecodeMultipart {
+		form, formDir, err := local.decodeForm(ctx, input, request.Body, boundary, manifest)
+		if err != nil {
+			return err
+		}
+		// process is finished (also killed by time limit) before removal
+		defer os.RemoveAll(formDir)
+		input = bytes.NewReader(form)
 	}
 
-	cmd := exec.CommandContext(ctx, local.manifest.Run[0], local.manifest.Run[1:]...)
-	cmd.Dir = local.rootDir
+	// original body is limited while it's decoded
+	payloadLimit := manifest.MaximumPayload
+	var rawFormFile string
+	if types.IsURLEncodedForm(request.Headers["Content-Type"]) && manifest.DecodeForm {
+		values, raw, err := decodeURLEncoded(ctx, input, request.Body, manifest)
+		if err != nil {
+			return err
+		}
+		form, err := valuesJSON(values)
+		if err != nil {
+			return fmt.Errorf("encode form: %w", err)
+		}
+		request.Form = withFormFields(request.Form, values)
+		if manifest.RawFormEnv != "" {
+			rawFormFile, err = local.spoolPayload(ctx, bytes.NewReader(raw), request.Body, int64(len(raw)))
+			if err != nil {
+				return err
+			}
+			// process is finished (also killed by time limit) before removal
+			defer os.Remove(rawFormFile)
+		}
+		input = bytes.NewReader(form)
+		payloadLimit = 0
+	}
+
+	if local.manifest.QueryToBody {
+		body, err := queryBody(input, request.Query(), manifest.MaximumPayload)
+		if err != nil {
+			return err
+		}
+		input = body
+	}
+
+	var payloadFile string
+	if manifest.PayloadAsFile {
+		payloadFile, err = local.spoolPayload(ctx, input, request.Body, manifest.FilePayloadLimit())
+		if err != nil {
+			return err
+		}
+		// process is finished (also killed by time limit) before removal
+		defer os.Remove(payloadFile)
+		input = bytes.NewReader(nil)
+	}
+
+	// process is killed as soon as body or output crosses the limit: the rest is not read
+	ctx, abort := context.WithCancel(ctx)
+	defer abort()
+	payload := &limitedReader{Reader: input, limit: payloadLimit, abort: abort}
+	input = payload
+
+	workDir, err := local.workDir(manifest.WorkDir)
+	if err != nil {
+		return fmt.Errorf("prepare work dir: %w", err)
+	}
+	output := &limitedWriter{Writer: response, limit: manifest.MaximumResponse, abort: abort}
+
+	if manifest.Worker() {
+		return local.invokeWorker(ctx, request, payload, output, workDir, manifest, globalEnv)
+	}
+
+	runtime := local.runtime(globalEnv)
+	var environments = local.environment(globalEnv)
+	manifestEnv := local.manifestEnvironment(environments)
+	// variables with values from request are subject of oversized policy
+	var fromRequest = make(map[string]bool)
+	addRequestEnv := func(name, value string) {
+		environments = append(environments, name+"="+value)
+		fromRequest[name] = true
+	}
+	local.requestEnvironment(request, addRequestEnv)
+	if deadline, ok := ctx.Deadline(); ok && local.manifest.DeadlineEnv != "" {
+		environments = append(environments, local.manifest.DeadlineEnv+"="+deadline.Format(time.RFC3339Nano))
+	}
+	for k, v := range manifestEnv {
+		environments = append(environments, k+"="+v)
+		delete(fromRequest, k)
+	}
+	if payloadFile != "" {
+		environments = append(environments, types.PayloadFileEnv+"="+payloadFile)
+	}
+	if rawFormFile != "" {
+		environments = append(environments, manifest.RawFormEnv+"="+rawFormFile)
+	}
+	spawning := time.Now() // preparation of command (ex: container) is part of spawn
+	cmd, container, err := local.command(ctx, manifest, workDir, environments)
+	if err != nil {
+		return err
+	}
+	defer local.removeContainer(ctx, container)
+	cmd.Dir = workDir
 	cmd.Stdin = input
-	cmd.Stdout = response
-	cmd.Stderr = os.Stderr
-	internal.SetCreds(cmd, local.creds)
+	cmd.Stdout = output
+	var stderrOut io.Writer = os.Stderr
+	if logs := local.invocationLogs(manifest, request.ID); logs != nil {
+		defer logs.Close()
+		cmd.Stdout = io.MultiWriter(output, logs.stdout)
+		stderrOut = io.MultiWriter(os.Stderr, logs.stderr)
+	}
+	var stderr func()
+	cmd.Stderr, stderr = captureStderr(ctx, stderrOut)
 	internal.SetFlags(cmd)
-	var environments = os.Environ()
-	for header, mapped := range globalEnv {
-		environments = append(environments, header+"="+mapped)
+	internal.SetGracePeriod(cmd, manifest.Grace())
+	internal.SetUmask(cmd, runtime.Umask)
+	cmd.Env = environments
+	secrets, err := local.prepareSecrets(cmd, globalEnv)
+	if err != nil {
+		return fmt.Errorf("prepare secrets: %w", err)
+	}
+	defer secrets.Close()
+	if err := limitEnvironment(cmd, runtime.Env(), fromRequest); err != nil {
+		return err
+	}
+	guard, err := local.limit(cmd, manifest)
+	if err != nil {
+		return fmt.Errorf("apply limits: %w", err)
+	}
+	err = local.startProcess(ctx, cmd)
+	if err != nil {
+		_ = guard.Finish(err)
+		return fmt.Errorf("run failed: %w", explainStart(cmd, err))
+	}
+	started := time.Now()
+	guard.Started(cmd)
+	secrets.Started()
+	err = guard.Finish(cmd.Wait())
+	stderr()
+	if usage := application.UsageFrom(ctx); usage != nil && cmd.ProcessState != nil {
+		usage.CPU = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		usage.MaxRSS = internal.MaxRSS(cmd.ProcessState)
+		usage.Spawn = started.Sub(spawning)
+		usage.Runtime = time.Since(started)
+		if !output.first.IsZero() {
+			usage.FirstByte = output.first.Sub(started)
+		}
+		if usage.Signal = internal.ExitSignal(cmd.ProcessState); usage.Signal == "" {
+			usage.ExitCode = cmd.ProcessState.ExitCode()
+		}
+	}
+	if payload.exceeded {
+		return fmt.Errorf("%w: request exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, payload.limit)
+	}
+	if output.exceeded {
+		return fmt.Errorf("%w: response exceeds maximum response (%d bytes)", application.ErrResponseTooLarge, output.limit)
+	}
+	if err != nil {
+		return fmt.Errorf("run failed: %w", err)
+	}
+	return nil
+}
+
+// pass variables with values from request (CGI convention and mappings of manifest) in order of priority: later
+// values replace earlier. Should be called under lock
+func (local *localLambda) requestEnvironment(request types.Request, add func(name, value string)) {
+	add("QUERY_STRING", request.Query())
+	if request.ID != "" {
+		add(types.RequestIDEnv, request.ID)
+	}
+	add("PATH_INFO", request.PathInfo())
+	if ip := types.AddressIP(request.RemoteAddress); ip != nil {
+		add("REMOTE_ADDR", ip.String())
 	}
 	for header, mapped := range local.manifest.InputHeaders {
-		environments = append(environments, mapped+"="+request.Headers[header])
+		add(mapped, request.Headers[header])
 	}
 	for query, mapped := range local.manifest.Query {
-		environments = append(environments, mapped+"="+request.Form[query])
+		add(mapped, request.Form[query])
 	}
 	if local.manifest.MethodEnv != "" {
-		environments = append(environments, local.manifest.MethodEnv+"="+request.Method)
+		add(local.manifest.MethodEnv, request.Method)
 	}
 	if local.manifest.PathEnv != "" {
-		environments = append(environments, local.manifest.PathEnv+"="+request.Path)
+		add(local.manifest.PathEnv, request.Path)
 	}
-	for k, v := range local.manifest.Environment {
-		environments = append(environments, k+"="+v)
+	if local.manifest.PublicURLEnv != "" {
+		add(local.manifest.PublicURLEnv, request.PublicURL)
 	}
-	cmd.Env = environments
-	err := cmd.Run()
+	if local.manifest.AliasEnv != "" {
+		add(local.manifest.AliasEnv, request.Alias)
+	}
+	// user is authenticated by settings of alias (if overridden)
+	access := local.manifest.ForAlias(request.Alias)
+	if access.BasicAuth != nil && request.User != "" {
+		add(access.BasicAuth.Variable(), request.User)
+	}
+	if policy := access.JWT; policy != nil {
+		if request.User != "" {
+			add(policy.Variable(), request.User)
+		}
+		for claim, variable := range policy.Env {
+			if value, ok := request.Claims[claim]; ok {
+				add(variable, value)
+			}
+		}
+	}
+}
+
+// writer limited by number of bytes (zero - unlimited). Writes beyond the limit fail and abort invocation, so process
+// which ignores closed output is killed as well
+type limitedWriter struct {
+	io.Writer
+	limit    int64
+	written  int64
+	exceeded bool
+	abort    func()
+	first    time.Time // the first byte of output (zero - no output)
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.first.IsZero() && len(p) > 0 {
+		lw.first = time.Now()
+	}
+	if lw.limit > 0 && lw.written+int64(len(p)) > lw.limit {
+		if !lw.exceeded {
+			lw.exceeded = true
+			lw.abort()
+		}
+		n, _ := lw.Writer.Write(p[:lw.limit-lw.written])
+		lw.written += int64(n)
+		return n, io.ErrShortWrite
+	}
+	n, err := lw.Writer.Write(p)
+	lw.written += int64(n)
+	return n, err
+}
+
+// invocation limited by context is reported as timed out in usage (if collected) once deadline is exceeded
+func markTimeout(ctx context.Context) {
+	if usage := application.UsageFrom(ctx); usage != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		usage.TimedOut = true
+	}
+}
+
+// stderr of process is copied to output and its tail is kept in usage (if collected) by returned function after
+// process finished
+func captureStderr(ctx context.Context, out io.Writer) (io.Writer, func()) {
+	usage := application.UsageFrom(ctx)
+	if usage == nil {
+		return out, func() {}
+	}
+	tail := &tailBuffer{limit: application.StderrTail}
+	return io.MultiWriter(out, tail), func() { usage.Stderr = tail.Bytes() }
+}
+
+// writer serialized by lock: for output shared by stdout and stderr of process which are copied concurrently
+type lockedWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	return lw.writer.Write(p)
+}
+
+// reader limited by number of bytes (zero - unlimited). Reading beyond the limit fails with ErrPayloadTooLarge and
+// aborts invocation, unlike io.LimitReader which silently cuts the body
+type limitedReader struct {
+	io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+	abort    func()
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.exceeded {
+		return 0, application.ErrPayloadTooLarge
+	}
+	if lr.limit > 0 && int64(len(p)) > lr.limit-lr.read+1 {
+		p = p[:lr.limit-lr.read+1] // one byte over the limit is enough to detect overflow
+	}
+	n, err := lr.Reader.Read(p)
+	lr.read += int64(n)
+	if lr.limit > 0 && lr.read > lr.limit {
+		lr.exceeded = true
+		lr.abort()
+		return n - int(lr.read-lr.limit), application.ErrPayloadTooLarge
+	}
+	return n, err
+}
+
+// body of request or, if body is empty, query parameters as JSON object (repeated keys as arrays)
+func queryBody(body io.Reader, query string, maxPayload int64) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	if _, err := buffered.Peek(1); err != io.EOF {
+		return buffered, nil
+	}
+	if maxPayload > 0 && int64(len(query)) > maxPayload {
+		return nil, fmt.Errorf("%w: query exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, maxPayload)
+	}
+	values, err := url.ParseQuery(query)
 	if err != nil {
-		return fmt.Errorf("run failed: %w", err)
+		return nil, fmt.Errorf("parse query: %w", err)
+	}
+	data, err := valuesJSON(values)
+	if err != nil {
+		return nil, fmt.Errorf("encode query: %w", err)
+	}
+	if maxPayload > 0 && int64(len(data)) > maxPayload {
+		return nil, fmt.Errorf("%w: query exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, maxPayload)
+	}
+	return bytes.NewReader(data), nil
+}
+
+func envRuntime(env map[string]string) types.Runtime {
+	return types.Runtime{
+		Lang:  env["LANG"],
+		LcAll: env["LC_ALL"],
+		TZ:    env["TZ"],
 	}
-	return nil
 }
 
 func (local *localLambda) serveStaticFile(request types.Request, response io.Writer) error {
@@ -131,39 +741,75 @@ func (local *localLambda) serveStaticFile(request types.Request, response io.Wri
 }
 
 func (local *localLambda) Remove() error {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.stopWorkers()
+	local.closeBundle()
+	local.cleanScratch()
+	local.removeLogs()
 	return os.RemoveAll(local.rootDir)
 }
 
 func (local *localLambda) reindex() error {
+	local.stopWorkers()
+	local.revision.Add(1)
 	err := local.reloadManifest()
 	if err != nil {
 		return fmt.Errorf("reload manifest: %w", err)
 	}
+	local.warning = ""
+	local.schema, err = local.manifest.CompileInputSchema()
+	if err != nil {
+		// manifest edited out of API: requests are not validated till fixed
+		local.warning = "input schema is not applied: " + err.Error()
+	}
+	if err := local.applyRunAs(); err != nil {
+		return fmt.Errorf("apply owner of run as: %w", err)
+	}
 	root, err := filepath.Abs(local.rootDir)
 	if err != nil {
 		return fmt.Errorf("get root dir: %w", err)
 	}
-	if local.manifest.Static != "" {
-		staticDir, err := filepath.Abs(filepath.Join(root, local.manifest.Static))
-		if err != nil {
-			return fmt.Errorf("get static dir: %w", err)
-		}
-		local.staticDir = staticDir
-	} else {
-		local.staticDir = ""
-	}
 	local.rootDir = root
 	local.uid = filepath.Base(root)
+	if err := local.updateStaticDir(); err != nil {
+		return err
+	}
+	return local.reloadBundle()
+}
+
+func (local *localLambda) updateStaticDir() error {
+	if local.manifest.Static == "" {
+		local.staticDir = ""
+		return nil
+	}
+	staticDir, err := filepath.Abs(filepath.Join(local.rootDir, local.manifest.Static))
+	if err != nil {
+		return fmt.Errorf("get static dir: %w", err)
+	}
+	local.staticDir = staticDir
 	return nil
 }
 
+// manifest file in the existing format (manifest.yaml or manifest.json). Ambiguity is reported by reloadManifest
 func (local *localLambda) manifestFile() string {
-	return filepath.Join(local.rootDir, internal.ManifestFile)
+	file, _ := internal.FindManifest(local.rootDir)
+	return file
+}
+
+// path (absolute) is manifest file in any format
+func (local *localLambda) isManifest(path string) bool {
+	rel, err := filepath.Rel(local.rootDir, path)
+	return err == nil && internal.IsManifest(filepath.ToSlash(rel))
 }
 
 func (local *localLambda) reloadManifest() error {
+	file, err := internal.FindManifest(local.rootDir)
+	if err != nil {
+		return err
+	}
 	var mf types.Manifest
-	err := mf.LoadFrom(local.manifestFile())
+	err = mf.LoadFrom(file)
 	if err != nil {
 		return err
 	}
@@ -174,7 +820,8 @@ func (local *localLambda) reloadManifest() error {
 func (local *localLambda) readIgnore() ([]string, error) {
 	content, err := os.ReadFile(filepath.Join(local.rootDir, internal.CGIIgnore))
 	if err == nil {
-		return strings.Split(string(content), "\n"), nil
+		// ignore file could be edited on Windows
+		return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), nil
 	}
 	if os.IsNotExist(err) {
 		return nil, nil
@@ -190,7 +837,13 @@ func (local *localLambda) writeStaticFile(path string, out io.Writer) error {
 	if !isLocal {
 		return fmt.Errorf("attempt to access file out of the jail")
 	}
-	f, err := os.Open(destPath)
+	var f io.ReadCloser
+	var err error
+	if local.bundle != nil {
+		f, err = local.openBundled(destPath)
+	} else {
+		f, err = os.Open(destPath)
+	}
 	if err != nil {
 		return err
 	}
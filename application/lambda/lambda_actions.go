@@ -3,84 +3,471 @@ package lambda
 import (
 	"bufio"
 	"context"
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/scheduler"
 	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
 	"github.com/robfig/cron"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
 var targetsPattern = regexp.MustCompile(`^([\d\w-/]+)\s*:\s*[\d\w-/\s]*$`)
 
-// List Make actions (if Makefile defined)
+// List actions: actions of manifest and then targets of Makefile (if defined) which are not overridden by manifest
 func (local *localLambda) Actions() ([]string, error) {
-	makefile := filepath.Join(local.rootDir, "Makefile")
-	f, err := os.Open(makefile)
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	var ans = make([]string, 0, len(local.manifest.Actions))
+	for name := range local.manifest.Actions {
+		ans = append(ans, name)
+	}
+	sort.Strings(ans)
+	f, err := local.open("Makefile")
 	if os.IsNotExist(err) {
-		return []string{}, nil
+		return ans, nil
 	} else if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	var ans = make([]string, 0)
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		matches := targetsPattern.FindAllStringSubmatch(scanner.Text(), -1)
 		if len(matches) < 1 || len(matches[0]) != 2 {
 			continue
 		}
+		if _, defined := local.manifest.Actions[matches[0][1]]; defined {
+			continue
+		}
 		ans = append(ans, matches[0][1])
 	}
 	return ans, nil
 }
 
-// Invoke action by name (make target)
+// Invoke action by name (action of manifest or make target). Invocation of startup action updates startup status
 func (local *localLambda) Do(ctx context.Context, name string, timeLimit time.Duration, globalEnv map[string]string, out io.Writer) error {
+	if startup := local.startupDefinition(); startup != nil && startup.Action == name {
+		return local.runStartup(ctx, local.beginStartup(name), *startup, globalEnv, out)
+	}
+	return local.do(ctx, name, timeLimit, globalEnv, out)
+}
+
+func (local *localLambda) do(ctx context.Context, name string, timeLimit time.Duration, globalEnv map[string]string, out io.Writer) error {
+	// action could change files of lambda
+	defer local.revision.Add(1)
 	if out == nil {
 		out = os.Stderr
 	}
-	if timeLimit > 0 {
+	environments := local.environment(globalEnv)
+	for k, v := range local.manifestEnvironment(environments) {
+		environments = append(environments, k+"="+v)
+	}
+
+	content, err := local.contentDir()
+	if err != nil {
+		return fmt.Errorf("prepare work dir: %w", err)
+	}
+	manifest, err := local.expandRun(local.Effective(), globalEnv)
+	if err != nil {
+		return fmt.Errorf("prepare work dir: %w", err)
+	}
+	workDir, err := resolveWorkDir(content, manifest.WorkDir)
+	if err != nil {
+		return fmt.Errorf("prepare work dir: %w", err)
+	}
+	// action of manifest wins over target of Makefile with the same name
+	steps, defined := manifest.Actions[name]
+	if !defined {
+		// Makefile is always in the lambda directory
+		args := []string{"make", name}
+		if workDir != content {
+			args = []string{"make", "-f", filepath.Join(content, "Makefile"), name}
+		}
+		steps = []types.Step{{Run: args}}
+	}
+
+	// build is gated right before start of the first step (after waiting in queue of builds)
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+	// output and tail of stderr are shared by all steps
+	shared := &lockedWriter{writer: out}
+	errOut, stderr := captureStderr(ctx, shared)
+	defer stderr()
+	command := func(args []string) func(ctx context.Context) *exec.Cmd {
+		return func(ctx context.Context) *exec.Cmd {
+			cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+			cmd.Dir = workDir
+			cmd.Stdout = shared
+			cmd.Stderr = errOut
+			internal.SetCreds(cmd, local.runner())
+			internal.SetFlags(cmd)
+			internal.SetGracePeriod(cmd, manifest.Grace())
+			local.sandbox(cmd, manifest)
+			internal.SetUmask(cmd, local.runtime(globalEnv).Umask)
+			cmd.Env = environments
+			if manifest.Gated(name) && release == nil && cmd.Err == nil {
+				release, cmd.Err = local.gate.build(ctx, manifest.GateTimeout())
+			}
+			return cmd
+		}
+	}
+
+	local.lock.RLock()
+	builder, limits := local.builder, local.manifest.BuildLimits
+	local.lock.RUnlock()
+	if defined && timeLimit > 0 {
+		// time limit of action bounds all steps
 		cctx, cancel := context.WithTimeout(ctx, timeLimit)
 		defer cancel()
 		ctx = cctx
 	}
-	environments := os.Environ()
-	for k, v := range globalEnv {
-		environments = append(environments, k+"="+v)
+	for i, step := range steps {
+		stepLimit := step.Limit(timeLimit)
+		if builder != nil {
+			err = builder.Run(ctx, local.uid, limits, manifest.Limits, stepLimit, command(step.Run))
+		} else {
+			err = runLimited(ctx, stepLimit, command(step.Run))
+		}
+		if err != nil && defined {
+			err = fmt.Errorf("step %d of action %s: %w", i, name, err)
+		}
+		if err != nil {
+			break
+		}
 	}
-	for k, v := range local.manifest.Environment {
-		environments = append(environments, k+"="+v)
+	local.disk.reset()
+	if err != nil {
+		return err
+	}
+	// action which leaves lambda over disk quota fails (ex: dependencies installed by post-clone)
+	return local.checkQuota(manifest.DiskQuota(), true)
+}
+
+// run command in time limit (zero - not limited)
+func runLimited(ctx context.Context, timeLimit time.Duration, command func(ctx context.Context) *exec.Cmd) error {
+	if timeLimit > 0 {
+		cctx, cancel := context.WithTimeout(ctx, timeLimit)
+		defer cancel()
+		ctx = cctx
 	}
+	return command(ctx).Run()
+}
 
-	cmd := exec.CommandContext(ctx, "make", name)
-	cmd.Dir = local.rootDir
-	cmd.Stdout = out
-	cmd.Stderr = out
-	internal.SetCreds(cmd, local.creds)
-	internal.SetFlags(cmd)
-	cmd.Env = environments
+type scheduledRun struct {
+	started time.Time
+	err     error
+}
 
-	return cmd.Run()
+// run by schedule: all attempts share request ID
+type fire struct {
+	plan    types.Schedule
+	id      string
+	started time.Time
+	missed  time.Time // occurrence missed during downtime (catch-up run), zero - regular run
 }
 
-func (local *localLambda) DoScheduled(ctx context.Context, lastRun time.Time, globalEnv map[string]string) {
-	now := time.Now()
-	for _, plan := range local.manifest.Cron {
+func (local *localLambda) DoScheduled(ctx context.Context, window scheduler.Window, globalEnv map[string]string, hooks application.ScheduleHooks) {
+	manifest := local.Manifest()
+	for _, plan := range manifest.Cron {
+		if plan.Disabled {
+			continue
+		}
 		sched, err := cron.Parse(plan.Cron)
 		if err != nil {
 			log.Println(plan.Cron, "-", err)
 			continue
 		}
-		if !sched.Next(lastRun).After(now) {
-			err = local.Do(ctx, plan.Action, time.Duration(plan.TimeLimit), globalEnv, nil)
-			if err != nil {
-				log.Println(plan.Cron, plan.Action, err)
+		location, err := plan.Location()
+		if err != nil {
+			log.Println(plan.Label(), "-", err)
+			continue
+		}
+		if window.In(location).Due(sched, plan.SkipMissed()) {
+			started := time.Now()
+			if hooks.Fired != nil {
+				hooks.Fired(runKey(plan), started)
+			}
+			local.fire(ctx, fire{plan: plan, id: types.NewRequestID(types.CronRequestPrefix), started: started}, manifest.Retry, globalEnv, hooks)
+		}
+	}
+}
+
+func (local *localLambda) CatchUpScheduled(ctx context.Context, until time.Time, since func(key string) time.Time, globalEnv map[string]string, hooks application.ScheduleHooks) {
+	manifest := local.Manifest()
+	for _, plan := range manifest.Cron {
+		limit := plan.CatchUpLimit()
+		if plan.Disabled || (limit == 0 && hooks.Missed == nil) {
+			continue
+		}
+		sched, err := cron.Parse(plan.Cron)
+		if err != nil {
+			log.Println(plan.Cron, "-", err)
+			continue
+		}
+		location, err := plan.Location()
+		if err != nil {
+			log.Println(plan.Label(), "-", err)
+			continue
+		}
+		from := since(runKey(plan))
+		if from.IsZero() {
+			continue // never evaluated: nothing is known about missed runs
+		}
+		var missed []time.Time
+		for next := sched.Next(from.In(location)); next.Before(until) && len(missed) < types.MaxCatchUpRuns; next = sched.Next(next) {
+			missed = append(missed, next)
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		if hooks.Missed != nil {
+			hooks.Missed(plan, missed)
+		}
+		if limit == 0 {
+			continue
+		}
+		if len(missed) > limit {
+			missed = missed[:limit]
+		}
+		// saved before runs: restart during catch-up doesn't repeat runs
+		if hooks.Fired != nil {
+			hooks.Fired(runKey(plan), until)
+		}
+		log.Println(plan.Label(), "of", local.uid, "- catch up", len(missed), "runs missed since", from.Format(time.RFC3339))
+		go func(plan types.Schedule) {
+			// missed runs of schedule are sequential, in order of occurrences
+			for _, occurrence := range missed {
+				if ctx.Err() != nil {
+					return
+				}
+				local.fire(ctx, fire{plan: plan, id: types.NewRequestID(types.CronRequestPrefix), started: time.Now(), missed: occurrence}, manifest.Retry, globalEnv, hooks)
+			}
+		}(plan)
+	}
+}
+
+// the first attempt of run, failed run is retried in background by retry policy (if set)
+func (local *localLambda) fire(ctx context.Context, run fire, policy *types.Retry, globalEnv map[string]string, hooks application.ScheduleHooks) {
+	var attempts int // not numbered without retry policy
+	if policy != nil {
+		attempts = policy.Total()
+	}
+	err := local.attemptScheduled(ctx, run, 1, attempts, globalEnv, hooks)
+	local.recordRun(run.plan, scheduledRun{started: run.started, err: err})
+	if err != nil && policy != nil {
+		// scheduler is not blocked by backoff
+		go local.retryScheduled(ctx, run, err, policy, globalEnv, hooks)
+	}
+}
+
+// retry failed scheduled run by policy till success, run failed after all attempts is kept as dead letter. Retries
+// are abandoned if context is done
+func (local *localLambda) retryScheduled(ctx context.Context, run fire, err error, policy *types.Retry, globalEnv map[string]string, hooks application.ScheduleHooks) {
+	attempts := policy.Total()
+	for attempt := 2; attempt <= attempts && err != nil; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(policy.Delay(attempt - 1)):
+		}
+		err = local.attemptScheduled(ctx, run, attempt, attempts, globalEnv, hooks)
+		local.recordRun(run.plan, scheduledRun{started: run.started, err: err})
+	}
+	if err == nil || hooks.Failed == nil {
+		return
+	}
+	letter := application.DeadLetter{
+		Lambda:   local.uid,
+		Schedule: run.plan.Label(),
+		Error:    err.Error(),
+		Attempts: attempts,
+		First:    run.started,
+		Failed:   time.Now(),
+	}
+	var body []byte
+	if run.plan.Invocation() {
+		body, err = local.scheduledLetter(run, &letter)
+		if err != nil {
+			log.Println("[WARN]", run.plan.Label(), "request", run.id, "- read payload of dead letter -", err)
+		}
+	}
+	hooks.Failed(letter, body)
+}
+
+// request of scheduled invocation with payload for dead letter
+func (local *localLambda) scheduledLetter(run fire, letter *application.DeadLetter) ([]byte, error) {
+	req, err := local.scheduledRequest(run)
+	if err != nil {
+		return nil, err
+	}
+	defer req.Body.Close()
+	letter.Request = *req
+	return ioutil.ReadAll(req.Body)
+}
+
+// single attempt of scheduled run (from 1) out of attempts (zero - no retry policy, attempt is not numbered).
+// Invocation is recorded
+func (local *localLambda) attemptScheduled(ctx context.Context, run fire, attempt, attempts int, globalEnv map[string]string, hooks application.ScheduleHooks) error {
+	var out application.Output
+	if hooks.Output != nil {
+		out = hooks.Output()
+	}
+	var usage application.Usage
+	record := stats.Record{UID: local.uid, Begin: time.Now(), CatchUp: !run.missed.IsZero(), Trigger: stats.TriggerSchedule}
+	ctx = application.WithUsage(ctx, &usage)
+	var err error
+	if out != nil {
+		err = out.Close(local.runScheduled(ctx, run, globalEnv, io.MultiWriter(os.Stderr, out), &record))
+	} else {
+		err = local.runScheduled(ctx, run, globalEnv, nil, &record)
+	}
+	if err != nil {
+		log.Println(run.plan.Label(), "request", run.id, "attempt", attempt, "-", err)
+	}
+	if !run.plan.Invocation() || hooks.Record == nil {
+		return err
+	}
+	record.End = time.Now()
+	record.CPU = usage.CPU
+	record.MaxRSS = usage.MaxRSS
+	record.Stderr = usage.Stderr
+	usage.Timing(&record)
+	if attempts > 0 {
+		record.Attempt = attempt
+	}
+	if err != nil {
+		record.Err = err.Error()
+		record.Retried = attempt < attempts
+	}
+	hooks.Record(record)
+	return err
+}
+
+// run scheduled action or invoke lambda with payload of schedule (POST to root path). Request ID (and missed
+// occurrence of catch-up run) is passed to action by environment as well. Request of invocation (without body) is set
+// to record (optional)
+func (local *localLambda) runScheduled(ctx context.Context, run fire, globalEnv map[string]string, out io.Writer, record *stats.Record) error {
+	if !run.plan.Invocation() {
+		env := make(map[string]string, len(globalEnv)+2)
+		for k, v := range globalEnv {
+			env[k] = v
+		}
+		env[types.RequestIDEnv] = run.id
+		if !run.missed.IsZero() {
+			env[types.CatchUpEnv] = run.missed.Format(time.RFC3339)
+		}
+		return local.Do(ctx, run.plan.Action, time.Duration(run.plan.TimeLimit), env, out)
+	}
+	if out == nil {
+		out = os.Stderr
+	}
+	if run.plan.TimeLimit > 0 {
+		cctx, cancel := context.WithTimeout(ctx, time.Duration(run.plan.TimeLimit))
+		defer cancel()
+		ctx = cctx
+	}
+	req, err := local.scheduledRequest(run)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		record.Request = *req
+		record.Request.Body = nil
+	}
+	return local.Invoke(ctx, *req, out, globalEnv)
+}
+
+// request of scheduled invocation with payload of schedule (POST to root path)
+func (local *localLambda) scheduledRequest(run fire) (*types.Request, error) {
+	var body io.ReadCloser = io.NopCloser(strings.NewReader(run.plan.Payload))
+	if run.plan.PayloadFile != "" {
+		local.lock.RLock()
+		f, err := local.open(run.plan.PayloadFile)
+		local.lock.RUnlock()
+		if err != nil {
+			return nil, fmt.Errorf("open payload: %w", err)
+		}
+		body = f
+	}
+	headers := map[string]string{types.ScheduleHeader: run.plan.Label()}
+	if !run.missed.IsZero() {
+		headers[types.CatchUpHeader] = run.missed.Format(time.RFC3339)
+	}
+	return &types.Request{
+		ID:      run.id,
+		Method:  http.MethodPost,
+		URL:     "/",
+		Path:    "/",
+		Form:    map[string]string{},
+		Headers: headers,
+		Body:    body,
+	}, nil
+}
+
+// Live status of scheduled actions
+func (local *localLambda) Schedules() []application.ScheduleStatus {
+	local.lock.RLock()
+	plans := append([]types.Schedule(nil), local.manifest.Cron...)
+	local.lock.RUnlock()
+	local.runsLock.Lock()
+	defer local.runsLock.Unlock()
+	now := time.Now()
+	var ans = make([]application.ScheduleStatus, 0, len(plans))
+	for _, plan := range plans {
+		status := application.ScheduleStatus{
+			Name:   plan.Name,
+			Cron:   plan.Cron,
+			TZ:     plan.TZ,
+			Action: plan.Action,
+		}
+		if sched, err := cron.Parse(plan.Cron); err == nil && !plan.Disabled {
+			if location, err := plan.Location(); err == nil {
+				status.Enabled = true
+				status.Next = scheduler.Next(sched, now.In(location))
 			}
 		}
+		if run, ok := local.runs[runKey(plan)]; ok {
+			status.LastRun = run.started
+			status.LastResult = application.ScheduleResultOK
+			if run.err != nil {
+				status.LastResult = application.ScheduleResultError
+				status.LastError = run.err.Error()
+			}
+		}
+		ans = append(ans, status)
+	}
+	return ans
+}
+
+func (local *localLambda) recordRun(plan types.Schedule, run scheduledRun) {
+	local.runsLock.Lock()
+	defer local.runsLock.Unlock()
+	if local.runs == nil {
+		local.runs = make(map[string]scheduledRun)
+	}
+	local.runs[runKey(plan)] = run
+}
+
+// named entries keep status while edited, unnamed are identified by content
+func runKey(plan types.Schedule) string {
+	if plan.Name != "" {
+		return plan.Name
 	}
+	return plan.Cron + "\x00" + plan.Action + "\x00" + plan.Payload + "\x00" + plan.PayloadFile
 }
@@ -0,0 +1,146 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// delays between attempts to spawn process while executable is busy (ETXTBSY): file is still opened for writing
+var textBusyBackoff = []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond, 160 * time.Millisecond}
+
+// gate between build actions and invocations of lambda (see types.BuildGate): build waits till in-flight invocations
+// are finished, new invocations wait till build is done. Zero value is opened gate
+type buildGate struct {
+	lock     sync.Mutex
+	running  int           // in-flight invocations
+	building bool          // build is running or waits for in-flight invocations
+	changed  chan struct{} // closed on every release (nil - nobody waits)
+}
+
+// enter gate by invocation: waits not longer than timeout while lambda is being built
+func (bg *buildGate) enter(ctx context.Context, timeout time.Duration) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		bg.lock.Lock()
+		if !bg.building {
+			bg.running++
+			bg.lock.Unlock()
+			return bg.leave, nil
+		}
+		changed := bg.unsafeChanged()
+		bg.lock.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: not finished in %v", application.ErrBuilding, timeout)
+		}
+	}
+}
+
+func (bg *buildGate) leave() {
+	bg.lock.Lock()
+	defer bg.lock.Unlock()
+	bg.running--
+	bg.unsafeNotify()
+}
+
+// close gate by build: waits for other builds, then new invocations are blocked and build waits not longer than
+// timeout till in-flight invocations are finished
+func (bg *buildGate) build(ctx context.Context, timeout time.Duration) (func(), error) {
+	for {
+		bg.lock.Lock()
+		if !bg.building {
+			bg.building = true
+			bg.lock.Unlock()
+			break
+		}
+		changed := bg.unsafeChanged()
+		bg.lock.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for other build: %w", ctx.Err())
+		}
+	}
+	drain, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		bg.lock.Lock()
+		if bg.running == 0 {
+			bg.lock.Unlock()
+			return bg.done, nil
+		}
+		changed := bg.unsafeChanged()
+		bg.lock.Unlock()
+		select {
+		case <-changed:
+		case <-drain.Done():
+			bg.done()
+			return nil, fmt.Errorf("in-flight invocations are not finished in %v, build is not started", timeout)
+		}
+	}
+}
+
+func (bg *buildGate) done() {
+	bg.lock.Lock()
+	defer bg.lock.Unlock()
+	bg.building = false
+	bg.unsafeNotify()
+}
+
+// channel closed on the next change of state. Should be called under lock
+func (bg *buildGate) unsafeChanged() <-chan struct{} {
+	if bg.changed == nil {
+		bg.changed = make(chan struct{})
+	}
+	return bg.changed
+}
+
+// Should be called under lock
+func (bg *buildGate) unsafeNotify() {
+	if bg.changed != nil {
+		close(bg.changed)
+		bg.changed = nil
+	}
+}
+
+// enter build gate by invocation with timeout of manifest
+func (local *localLambda) enterGate(ctx context.Context) (func(), error) {
+	local.lock.RLock()
+	timeout := local.manifest.GateTimeout()
+	local.lock.RUnlock()
+	return local.gate.enter(ctx, timeout)
+}
+
+// start process of invocation. Executable which is still opened for writing (ETXTBSY) is retried with bounded
+// backoff
+func (local *localLambda) startProcess(ctx context.Context, cmd *exec.Cmd) error {
+	err := local.spawn(cmd)
+	for _, delay := range textBusyBackoff {
+		if !errors.Is(err, syscall.ETXTBSY) {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		err = local.spawn(cmd)
+	}
+	return err
+}
+
+func (local *localLambda) spawn(cmd *exec.Cmd) error {
+	if local.start != nil {
+		return local.start(cmd)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,401 @@
+package lambda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// minimal age of unused bundle before removal: protects bundles which are uploaded but not yet activated
+const bundleGCGrace = time.Hour
+
+var errBundled = errors.New("bundled lambda is read-only")
+
+type bundlePointer struct {
+	Hash string `json:"hash"` // SHA-256 of bundle archive
+}
+
+// Set content of lambda from zip archive. Archive is stored as-is in the shared bundles storage and files are served
+// directly from it. Manifest from archive (if exists) replaces current manifest.
+func (local *localLambda) SetBundle(bundle io.Reader) (string, error) {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	storage := local.bundlesDir()
+	err := os.MkdirAll(storage, 0755)
+	if err != nil {
+		return "", fmt.Errorf("create bundles dir: %w", err)
+	}
+	hash, err := saveBundle(storage, bundle)
+	if err != nil {
+		return "", fmt.Errorf("save bundle: %w", err)
+	}
+	archive, err := zip.OpenReader(bundleFile(storage, hash))
+	if err != nil {
+		return "", fmt.Errorf("open bundle: %w", err)
+	}
+	defer archive.Close()
+	manifest, err := readManifestFrom(archive)
+	if err == nil {
+		err = manifest.SaveAs(local.manifestFile())
+		if err != nil {
+			return "", fmt.Errorf("save manifest: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("read bundled manifest: %w", err)
+	}
+	// switch is atomic: single rename of pointer file
+	err = internal.AtomicWriteJson(local.bundlePointerFile(), &bundlePointer{Hash: hash})
+	if err != nil {
+		return "", fmt.Errorf("save bundle pointer: %w", err)
+	}
+	return hash, local.reindex()
+}
+
+// Hash of the lambda content. For bundled lambda it's hash of the bundle, for regular lambda it's hash of
+// names and content of files (except ignored).
+func (local *localLambda) ContentHash() (string, error) {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	if local.bundle != nil {
+		return local.bundleHash, nil
+	}
+	ignore, err := local.readIgnore()
+	if err != nil {
+		return "", err
+	}
+	return hashFiles(local.rootDir, ignore)
+}
+
+// Remove unused bundles and extracted directories from shared storage in project directory.
+// Bundle is used if any lambda in the project directory points to it.
+func CleanBundles(projectDir string) error {
+	storage := filepath.Join(projectDir, internal.BundlesDir)
+	list, err := ioutil.ReadDir(storage)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("list bundles: %w", err)
+	}
+	pointers, err := filepath.Glob(filepath.Join(projectDir, "*", internal.BundlePointer))
+	if err != nil {
+		return fmt.Errorf("find bundle pointers: %w", err)
+	}
+	var used = make(map[string]bool)
+	for _, file := range pointers {
+		var pointer bundlePointer
+		if err := internal.ReadJson(file, &pointer); err != nil {
+			return fmt.Errorf("read bundle pointer %s: %w", file, err)
+		}
+		used[pointer.Hash] = true
+	}
+	for _, item := range list {
+		hash := item.Name()
+		if !item.IsDir() {
+			hash = hash[:len(hash)-len(filepath.Ext(hash))]
+		}
+		if used[hash] || time.Since(item.ModTime()) < bundleGCGrace {
+			continue
+		}
+		err := removeReadOnly(filepath.Join(storage, item.Name()))
+		if err != nil {
+			return fmt.Errorf("remove bundle %s: %w", item.Name(), err)
+		}
+	}
+	return nil
+}
+
+// directory with lambda files for processes: extracted bundle or root dir
+func (local *localLambda) contentDir() (string, error) {
+	if local.bundle == nil {
+		return local.rootDir, nil
+	}
+	return extractBundle(local.bundlesDir(), local.bundleHash, &local.bundle.Reader)
+}
+
+// working directory of processes: content directory or work dir of manifest inside it
+func (local *localLambda) workDir(dir string) (string, error) {
+	content, err := local.contentDir()
+	if err != nil {
+		return "", err
+	}
+	return resolveWorkDir(content, dir)
+}
+
+// resolve work dir (relative) inside content directory. Work dir should be existent directory which does not lead out
+// of content by symlinks
+func resolveWorkDir(content, workDir string) (string, error) {
+	if workDir == "" {
+		return content, nil
+	}
+	if !filepath.IsLocal(workDir) {
+		return "", fmt.Errorf("work dir %q is not relative path inside lambda", workDir)
+	}
+	root, err := filepath.EvalSymlinks(content)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join(root, workDir))
+	if err != nil {
+		return "", fmt.Errorf("work dir %s: %w", workDir, err)
+	}
+	if !strings.HasPrefix(dir, root+string(filepath.Separator)) && dir != root {
+		return "", fmt.Errorf("work dir %s is out of lambda by symlink", workDir)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("work dir %s is not a directory", workDir)
+	}
+	return dir, nil
+}
+
+// open file relative to lambda root
+func (local *localLambda) open(name string) (io.ReadCloser, error) {
+	if local.bundle != nil {
+		return local.bundle.Open(name)
+	}
+	return os.Open(filepath.Join(local.rootDir, name))
+}
+
+// open file from bundle by absolute path inside lambda
+func (local *localLambda) openBundled(path string) (io.ReadCloser, error) {
+	name, err := local.bundlePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return local.bundle.Open(name)
+}
+
+// convert absolute path inside lambda to the archive path
+func (local *localLambda) bundlePath(path string) (string, error) {
+	rel, err := filepath.Rel(local.rootDir, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (local *localLambda) reloadBundle() error {
+	var pointer bundlePointer
+	err := internal.ReadJson(local.bundlePointerFile(), &pointer)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read bundle pointer: %w", err)
+	}
+	if pointer.Hash == local.bundleHash {
+		return nil
+	}
+	local.closeBundle()
+	if pointer.Hash == "" {
+		return nil
+	}
+	archive, err := zip.OpenReader(bundleFile(local.bundlesDir(), pointer.Hash))
+	if err != nil {
+		return fmt.Errorf("open bundle: %w", err)
+	}
+	local.bundle = archive
+	local.bundleHash = pointer.Hash
+	return nil
+}
+
+func (local *localLambda) closeBundle() {
+	if local.bundle != nil {
+		_ = local.bundle.Close()
+	}
+	local.bundle = nil
+	local.bundleHash = ""
+}
+
+// switch lambda back to the regular (non-bundled) mode
+func (local *localLambda) unbundle() error {
+	err := os.Remove(local.bundlePointerFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	local.closeBundle()
+	return nil
+}
+
+func (local *localLambda) bundlesDir() string {
+	return filepath.Join(filepath.Dir(local.rootDir), internal.BundlesDir)
+}
+
+func (local *localLambda) bundlePointerFile() string {
+	return filepath.Join(local.rootDir, internal.BundlePointer)
+}
+
+func bundleFile(storage, hash string) string {
+	return filepath.Join(storage, hash+".zip")
+}
+
+func saveBundle(storage string, content io.Reader) (string, error) {
+	tmp, err := ioutil.TempFile(storage, "")
+	if err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), content)
+	_ = tmp.Close()
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	err = os.Rename(tmp.Name(), bundleFile(storage, hash))
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return hash, nil
+}
+
+// extract bundle to the read-only directory named by hash (once)
+func extractBundle(storage string, hash string, archive *zip.Reader) (string, error) {
+	dest := filepath.Join(storage, hash)
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+	tmp, err := ioutil.TempDir(storage, hash+"-")
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %w", err)
+	}
+	err = unzipFiles(archive, tmp)
+	if err == nil {
+		err = os.Chmod(tmp, 0555)
+	}
+	if err != nil {
+		_ = removeReadOnly(tmp)
+		return "", fmt.Errorf("extract bundle: %w", err)
+	}
+	err = os.Rename(tmp, dest)
+	if err != nil {
+		// concurrent extraction of the same bundle
+		_ = removeReadOnly(tmp)
+		if _, statErr := os.Stat(dest); statErr == nil {
+			return dest, nil
+		}
+		return "", fmt.Errorf("move extracted bundle: %w", err)
+	}
+	return dest, nil
+}
+
+func unzipFiles(archive *zip.Reader, dest string) error {
+	var dirs []string
+	for _, file := range archive.File {
+		if !fs.ValidPath(strings.TrimSuffix(file.Name, "/")) {
+			return fmt.Errorf("invalid file name %s", file.Name)
+		}
+		path := filepath.Join(dest, filepath.FromSlash(file.Name))
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("create dir %s: %w", file.Name, err)
+			}
+			dirs = append(dirs, path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("create dir %s: %w", file.Name, err)
+		}
+		if err := unzipFile(file, path); err != nil {
+			return fmt.Errorf("extract file %s: %w", file.Name, err)
+		}
+	}
+	// directories are read-only only after all files are extracted
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], 0555); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unzipFile(file *zip.File, path string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, (file.Mode()&0555)|0444)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// remove directory with read-only sub-directories
+func removeReadOnly(path string) error {
+	_ = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			_ = os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+// repack zip archive to tar.gz
+func zipToTarGz(archive *zip.Reader, out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	defer gz.Close()
+	writer := tar.NewWriter(gz)
+	defer writer.Close()
+	for _, file := range archive.File {
+		header, err := tar.FileInfoHeader(file.FileInfo(), "")
+		if err != nil {
+			return err
+		}
+		header.Name = file.Name
+		err = writer.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		src, err := file.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, src)
+		_ = src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifest of bundle in any format (manifest.yaml or manifest.json)
+func readManifestFrom(fileSystem fs.FS) (types.Manifest, error) {
+	name := internal.ManifestFile
+	if _, err := fs.Stat(fileSystem, internal.ManifestYAML); err == nil {
+		if _, err := fs.Stat(fileSystem, internal.ManifestFile); err == nil {
+			return types.Manifest{}, internal.ErrAmbiguousManifest
+		}
+		name = internal.ManifestYAML
+	}
+	f, err := fileSystem.Open(name)
+	if err != nil {
+		return types.Manifest{}, err
+	}
+	defer f.Close()
+	return types.DecodeManifest(name, f)
+}
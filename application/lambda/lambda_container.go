@@ -0,0 +1,196 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var errNoContainers = errors.New("containers are not enabled on server")
+
+func (local *localLambda) SetContainers(containers application.Containers) {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.containers = containers
+	local.pullImage()
+}
+
+// command of invocation: native process or client of container engine with environment of invocation (server process
+// variables are not passed to container). Should be called under lock
+func (local *localLambda) command(ctx context.Context, manifest types.Manifest, workDir string, env []string) (*exec.Cmd, string, error) {
+	if manifest.Container == nil {
+		cmd := exec.CommandContext(ctx, manifest.Run[0], manifest.Run[1:]...)
+		internal.SetCreds(cmd, local.runner())
+		local.sandbox(cmd, manifest)
+		return cmd, "", nil
+	}
+	if local.containers == nil {
+		return nil, "", errNoContainers
+	}
+	content, err := local.contentDir()
+	if err != nil {
+		return nil, "", fmt.Errorf("prepare content: %w", err)
+	}
+	rel, err := filepath.Rel(content, workDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("work dir in container: %w", err)
+	}
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return nil, "", fmt.Errorf("generate name of container: %w", err)
+	}
+	run := application.ContainerRun{
+		Name:      "trusted-cgi-" + local.uid + "-" + hex.EncodeToString(suffix[:]),
+		Container: *manifest.Container,
+		Dir:       content,
+		WorkDir:   path.Join(types.ContainerDir, filepath.ToSlash(rel)),
+		Command:   manifest.Run,
+		Env:       containerEnv(env),
+		Network:   manifest.Container.Network && *manifest.Network,
+	}
+	if *manifest.ReadOnly {
+		run.Container.Mounts = make([]types.Mount, 0, len(manifest.Container.Mounts))
+		for _, mount := range manifest.Container.Mounts {
+			mount.Writable = false
+			run.Container.Mounts = append(run.Container.Mounts, mount)
+		}
+	}
+	if runner := local.runner(); runner != nil {
+		run.User = strconv.Itoa(runner.User) + ":" + strconv.Itoa(runner.Group)
+	}
+	return local.containers.Command(ctx, run), run.Name, nil
+}
+
+// container is removed by engine after exit, but it outlives client killed by time limit
+func (local *localLambda) removeContainer(ctx context.Context, name string) {
+	if name == "" || ctx.Err() == nil {
+		return
+	}
+	if err := local.containers.Remove(name); err != nil {
+		log.Println("[WARN] lambda", local.uid, "remove container", name, "-", err)
+	}
+}
+
+// names of variables of invocation except variables of server process
+func containerEnv(env []string) []string {
+	var server = make(map[string]bool)
+	for _, kv := range os.Environ() {
+		server[kv] = true
+	}
+	var seen = make(map[string]bool)
+	var names []string
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if server[kv] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// state of pull of image of container
+type imagePull struct {
+	status application.ContainerStatus
+	cancel context.CancelFunc
+}
+
+// pull image of container of manifest in background, the previous pull is canceled. Should be called under lock
+func (local *localLambda) pullImage() {
+	local.pullLock.Lock()
+	defer local.pullLock.Unlock()
+	if local.pull != nil {
+		local.pull.cancel()
+		local.pull = nil
+	}
+	if local.containers == nil || local.manifest.Container == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	image := local.manifest.Container.Image
+	pull := &imagePull{cancel: cancel, status: application.ContainerStatus{Image: image, State: application.ImagePulling, Updated: time.Now()}}
+	local.pull = pull
+	go func(containers application.Containers) {
+		defer cancel()
+		err := containers.Pull(ctx, image, &pullProgress{update: func(line string) {
+			local.pullLock.Lock()
+			defer local.pullLock.Unlock()
+			pull.status.Progress, pull.status.Updated = line, time.Now()
+		}})
+		if ctx.Err() != nil {
+			return
+		}
+		local.pullLock.Lock()
+		defer local.pullLock.Unlock()
+		pull.status.State, pull.status.Updated = application.ImageReady, time.Now()
+		if err != nil {
+			log.Println("[ERROR] lambda", local.uid, "pull image", image, "-", err)
+			pull.status.State, pull.status.Error = application.ImageFailed, err.Error()
+		}
+	}(local.containers)
+}
+
+// status of image of container (nil - lambda without container)
+func (local *localLambda) containerStatus() *application.ContainerStatus {
+	local.pullLock.Lock()
+	defer local.pullLock.Unlock()
+	if local.pull == nil {
+		return nil
+	}
+	status := local.pull.status
+	return &status
+}
+
+// problems of container: engine is not enabled or image is not pulled
+func (local *localLambda) containerProblems(manifest types.Manifest) []string {
+	if manifest.Container == nil {
+		return nil
+	}
+	local.lock.RLock()
+	enabled := local.containers != nil
+	local.lock.RUnlock()
+	if !enabled {
+		return []string{errNoContainers.Error()}
+	}
+	if status := local.containerStatus(); status != nil && status.State == application.ImageFailed {
+		return []string{fmt.Sprintf("pull image %s: %s", status.Image, status.Error)}
+	}
+	return nil
+}
+
+// reports the last non-empty line of progress (engines overwrite lines by \r on terminal)
+type pullProgress struct {
+	update func(line string)
+	buffer bytes.Buffer
+}
+
+func (pp *pullProgress) Write(data []byte) (int, error) {
+	pp.buffer.Write(data)
+	for {
+		content := pp.buffer.Bytes()
+		idx := bytes.IndexAny(content, "\r\n")
+		if idx < 0 {
+			return len(data), nil
+		}
+		if line := strings.TrimSpace(string(content[:idx])); line != "" {
+			pp.update(line)
+		}
+		pp.buffer.Next(idx + 1)
+	}
+}
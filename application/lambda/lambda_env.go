@@ -0,0 +1,137 @@
+package lambda
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// number of the biggest contributors in reports about environment size
+const largestContributors = 3
+
+// bytes of pointer in argv and envp arrays
+const pointerSize = strconv.IntSize / 8
+
+// maximum size of single argument or variable accepted by the kernel (MAX_ARG_STRLEN)
+const kernelMaxString = 32 * 4096
+
+// size of argument or variable as it is counted by the kernel: content, terminating zero and pointer
+func execSize(value string) int64 {
+	return int64(len(value)) + 1 + pointerSize
+}
+
+// environment as it will be passed to the process: later variables override former ones
+func effectiveEnv(env []string) []string {
+	var index = make(map[string]int, len(env))
+	var ans = make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[name]; ok {
+			ans[i] = kv
+			continue
+		}
+		index[name] = len(ans)
+		ans = append(ans, kv)
+	}
+	return ans
+}
+
+// total size of arguments and effective environment with the biggest contributors
+func envUsage(args, env []string) (int64, []application.EnvVariable) {
+	var total int64
+	var items = make([]application.EnvVariable, 0, len(args)+len(env))
+	for i, arg := range args {
+		size := execSize(arg)
+		total += size
+		items = append(items, application.EnvVariable{Name: "argv[" + strconv.Itoa(i) + "]", Size: size})
+	}
+	for _, kv := range effectiveEnv(env) {
+		name, _, _ := strings.Cut(kv, "=")
+		size := execSize(kv)
+		total += size
+		items = append(items, application.EnvVariable{Name: name, Size: size})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Size > items[j].Size
+	})
+	if len(items) > largestContributors {
+		items = items[:largestContributors]
+	}
+	return total, items
+}
+
+func describeLargest(items []application.EnvVariable) string {
+	var parts = make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprintf("%s (%d bytes)", item.Name, item.Size))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// apply limits to arguments and environment of process before start. Variables from request bigger than the limit
+// are truncated or rejected by policy, other arguments and variables are rejected only if the kernel will not accept
+// them. Total size over the limit is rejected as well
+func limitEnvironment(cmd *exec.Cmd, limits types.EnvLimits, fromRequest map[string]bool) error {
+	for i, arg := range cmd.Args {
+		if len(arg) >= kernelMaxString {
+			return fmt.Errorf("%w: argument argv[%d] is %d bytes, kernel limit is %d bytes", application.ErrEnvironmentTooBig, i, len(arg), kernelMaxString)
+		}
+	}
+	maxVariable := limits.VariableLimit()
+	cmd.Env = effectiveEnv(cmd.Env)
+	for i, kv := range cmd.Env {
+		name, _, _ := strings.Cut(kv, "=")
+		switch {
+		case !fromRequest[name] && len(kv) >= kernelMaxString:
+			return fmt.Errorf("%w: variable %s is %d bytes, kernel limit is %d bytes", application.ErrEnvironmentTooBig, name, len(kv), kernelMaxString)
+		case !fromRequest[name] || int64(len(kv)) <= maxVariable:
+		case limits.Truncate():
+			cmd.Env[i] = kv[:maxVariable]
+		default:
+			return fmt.Errorf("%w: variable %s from request is %d bytes, limit is %d bytes", application.ErrEnvironmentTooBig, name, len(kv), maxVariable)
+		}
+	}
+	size, largest := envUsage(cmd.Args, cmd.Env)
+	if limit := limits.SizeLimit(); size > limit {
+		return fmt.Errorf("%w: arguments and environment are %d bytes, limit is %d bytes, largest: %s", application.ErrEnvironmentTooBig, size, limit, describeLargest(largest))
+	}
+	return nil
+}
+
+// explain failed start of process: the kernel rejects too big arguments and environment with E2BIG
+func explainStart(cmd *exec.Cmd, err error) error {
+	if !errors.Is(err, syscall.E2BIG) {
+		return err
+	}
+	size, largest := envUsage(cmd.Args, cmd.Env)
+	return fmt.Errorf("%w: kernel rejected arguments and environment of %d bytes (%w), largest: %s", application.ErrEnvironmentTooBig, size, err, describeLargest(largest))
+}
+
+// size of arguments and environment of invocation without variables from request. Should be called under lock
+func (local *localLambda) envStatus(globalEnv map[string]string) application.EnvStatus {
+	limits := local.runtime(globalEnv).Env()
+	env := local.environment(globalEnv)
+	for k, v := range local.manifestEnvironment(env) {
+		env = append(env, k+"="+v)
+	}
+	size, largest := envUsage(local.manifest.Run, env)
+	oversized := types.OversizedDeny
+	if limits.Truncate() {
+		oversized = types.OversizedTruncate
+	}
+	return application.EnvStatus{
+		Size:        size,
+		Limit:       limits.SizeLimit(),
+		Headroom:    limits.SizeLimit() - size,
+		MaxVariable: limits.VariableLimit(),
+		Oversized:   oversized,
+		Largest:     largest,
+	}
+}
@@ -0,0 +1,198 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/url"
+	"os"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// decode multipart body to JSON object: text fields as strings, file parts as temporary files in scratch directory
+// (see types.FormFile), values of repeated fields are arrays. Text fields are limited by maximum payload, files - by
+// maximum form file each and by file payload in total; slow body is interrupted by done context. Should be called
+// under lock. Returns JSON and directory of files which should be removed after the process exits
+func (local *localLambda) decodeForm(ctx context.Context, body io.Reader, closer io.Closer, boundary string, manifest types.Manifest) ([]byte, string, error) {
+	scratch, err := local.prepareScratch()
+	if err != nil {
+		return nil, "", err
+	}
+	dir, err := ioutil.TempDir(scratch, "form-")
+	if err != nil {
+		return nil, "", fmt.Errorf("create form directory: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = closer.Close()
+	})
+	data, err := local.readForm(dir, multipart.NewReader(body, boundary), manifest)
+	stop()
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("receive form: %w", ctx.Err())
+	}
+	if err == nil && manifest.MaximumPayload > 0 && int64(len(data)) > manifest.MaximumPayload {
+		err = fmt.Errorf("%w: decoded form exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, manifest.MaximumPayload)
+	}
+	if runner := local.runner(); err == nil && runner != nil {
+		err = os.Chown(dir, runner.User, runner.Group)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, "", err
+	}
+	return data, dir, nil
+}
+
+func (local *localLambda) readForm(dir string, reader *multipart.Reader, manifest types.Manifest) ([]byte, error) {
+	var values = make(map[string][]interface{})
+	var text, files int64 // received bytes of text fields and of files
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", application.ErrMalformedForm, err)
+		}
+		name := part.FormName()
+		if name == "" {
+			continue // not a form field
+		}
+		if part.FileName() == "" {
+			value, err := readField(part, manifest.MaximumPayload, &text)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = append(values[name], value)
+			continue
+		}
+		file, err := local.saveFormFile(dir, part, manifest, &files)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = append(values[name], file)
+	}
+	var object = make(map[string]interface{}, len(values))
+	for name, items := range values {
+		if len(items) == 1 {
+			object[name] = items[0]
+		} else {
+			object[name] = items
+		}
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("encode form: %w", err)
+	}
+	return data, nil
+}
+
+// read value of text field, received bytes of all text fields are not more than limit (zero - unlimited)
+func readField(part *multipart.Part, limit int64, received *int64) (string, error) {
+	var input io.Reader = part
+	if limit > 0 {
+		// one byte over the limit is enough to detect overflow
+		input = io.LimitReader(part, limit-*received+1)
+	}
+	value, err := ioutil.ReadAll(input)
+	if err != nil {
+		return "", fmt.Errorf("%w: read field %s: %v", application.ErrMalformedForm, part.FormName(), err)
+	}
+	*received += int64(len(value))
+	if limit > 0 && *received > limit {
+		return "", fmt.Errorf("%w: form fields exceed maximum payload (%d bytes)", application.ErrPayloadTooLarge, limit)
+	}
+	return string(value), nil
+}
+
+// save file part to temporary file readable by lambda user, received bytes of all files are not more than file payload
+func (local *localLambda) saveFormFile(dir string, part *multipart.Part, manifest types.Manifest, received *int64) (*types.FormFile, error) {
+	limit, total := manifest.FormFileLimit(), manifest.FilePayloadLimit()
+	if rest := total - *received; rest < limit {
+		limit = rest
+	}
+	f, err := ioutil.TempFile(dir, "file-")
+	if err != nil {
+		return nil, fmt.Errorf("create form file: %w", err)
+	}
+	size, err := io.Copy(f, io.LimitReader(part, limit+1))
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		return nil, fmt.Errorf("save form file: %w", closeErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: read file %s: %v", application.ErrMalformedForm, part.FormName(), err)
+	}
+	*received += size
+	if size > limit && limit < manifest.FormFileLimit() {
+		return nil, fmt.Errorf("%w: form files exceed maximum file payload (%d bytes)", application.ErrPayloadTooLarge, total)
+	} else if size > limit {
+		return nil, fmt.Errorf("%w: form file %s exceeds maximum form file (%d bytes)", application.ErrPayloadTooLarge, part.FormName(), limit)
+	}
+	if runner := local.runner(); runner != nil {
+		if err := os.Chown(f.Name(), runner.User, runner.Group); err != nil {
+			return nil, fmt.Errorf("set owner of form file: %w", err)
+		}
+	}
+	return &types.FormFile{
+		Path:        f.Name(),
+		Filename:    part.FileName(),
+		ContentType: part.Header.Get("Content-Type"),
+		Size:        size,
+	}, nil
+}
+
+// read form-urlencoded body limited by form payload limit; slow body is interrupted by done context. Returns decoded
+// values and original body
+func decodeURLEncoded(ctx context.Context, body io.Reader, closer io.Closer, manifest types.Manifest) (url.Values, []byte, error) {
+	limit := manifest.FormPayloadLimit()
+	stop := context.AfterFunc(ctx, func() {
+		_ = closer.Close()
+	})
+	// one byte over the limit is enough to detect overflow
+	raw, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	stop()
+	if err != nil && ctx.Err() != nil {
+		return nil, nil, fmt.Errorf("receive form: %w", ctx.Err())
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("receive form: %w", err)
+	}
+	if int64(len(raw)) > limit {
+		return nil, nil, fmt.Errorf("%w: form exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, limit)
+	}
+	values, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", application.ErrMalformedForm, err)
+	}
+	return values, raw, nil
+}
+
+// fields of decoded form are mapped by query of manifest as well, query of URL has priority
+func withFormFields(form map[string]string, values url.Values) map[string]string {
+	var ans = make(map[string]string, len(form)+len(values))
+	for key, items := range values {
+		ans[key] = items[0]
+	}
+	for key, value := range form {
+		ans[key] = value
+	}
+	return ans
+}
+
+// JSON object of values: single value as string, repeated values as array
+func valuesJSON(values url.Values) ([]byte, error) {
+	var object = make(map[string]interface{}, len(values))
+	for key, items := range values {
+		if len(items) == 1 {
+			object[key] = items[0]
+		} else {
+			object[key] = items
+		}
+	}
+	return json.Marshal(object)
+}
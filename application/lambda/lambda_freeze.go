@@ -0,0 +1,200 @@
+package lambda
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errFreezeBundle = errors.New("bundled lambda could not be frozen: bundle is already immutable")
+
+func (local *localLambda) Freeze(tarball io.Writer) error {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	if local.bundle != nil {
+		return errFreezeBundle
+	}
+	gz := gzip.NewWriter(tarball)
+	if err := tarTree(local.rootDir, gz); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (local *localLambda) Thaw(tarball io.Reader) error {
+	local.lock.RLock()
+	staged := local.rootDir + ".thawed-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	local.lock.RUnlock()
+	if err := os.Mkdir(staged, 0755); err != nil {
+		return fmt.Errorf("create staged copy: %w", err)
+	}
+	if err := untarTree(tarball, staged); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+	if err := local.Replace(staged); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	return local.applyFilesOwner()
+}
+
+// pack all files of directory with symlinks (as links) and modification times of files and directories. Walk order is lexical, ownership is
+// not kept (files belong to user of lambda after thaw). Sockets and devices are skipped
+func tarTree(dir string, out io.Writer) error {
+	writer := tar.NewWriter(out)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		var link string
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		if link != "" {
+			// time of symlink could not be restored (changes follow symlink), so it is not kept for the same archive
+			header.ModTime = time.Unix(0, 0)
+		}
+		header.Format = tar.FormatPAX // keeps sub-second modification time
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(writer, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// extract archive of tarTree to new directory. Symlinks are restored as is (ex: interpreter of virtual environment),
+// but entries are never written through them
+func untarTree(tarball io.Reader, dest string) error {
+	gz, err := gzip.NewReader(tarball)
+	if err != nil {
+		return fmt.Errorf("read frozen archive: %w", err)
+	}
+	defer gz.Close()
+	reader := tar.NewReader(gz)
+	links := make(map[string]bool)
+	type dirTime struct {
+		location string
+		modified time.Time
+	}
+	var dirs []dirTime
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("read frozen archive: %w", err)
+		}
+		name, err := entryName(header.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		if links[name] {
+			return fmt.Errorf("entry %s replaces symlink", name)
+		}
+		for parent := path.Dir(name); parent != "."; parent = path.Dir(parent) {
+			if links[parent] {
+				return fmt.Errorf("entry %s is inside symlink %s", name, parent)
+			}
+		}
+		location := filepath.Join(dest, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+			return fmt.Errorf("create dir of %s: %w", name, err)
+		}
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(location, mode.Perm()|0700); err != nil {
+				return fmt.Errorf("create dir %s: %w", name, err)
+			}
+			dirs = append(dirs, dirTime{location: location, modified: header.ModTime})
+			continue
+		case tar.TypeSymlink:
+			if strings.TrimSpace(header.Linkname) == "" {
+				return fmt.Errorf("empty target of symlink %s", name)
+			}
+			if err := os.Symlink(header.Linkname, location); err != nil {
+				return fmt.Errorf("create symlink %s: %w", name, err)
+			}
+			links[name] = true
+			continue
+		case tar.TypeReg:
+			if err := writeFrozenFile(location, mode, reader); err != nil {
+				return fmt.Errorf("write %s: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("unsupported type of file %s %v", header.Name, header.Typeflag)
+		}
+		if err := os.Chtimes(location, header.ModTime, header.ModTime); err != nil {
+			return fmt.Errorf("set time of %s: %w", name, err)
+		}
+	}
+	// times of directories are changed by their entries
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].location, dirs[i].modified, dirs[i].modified); err != nil {
+			return fmt.Errorf("set time of %s: %w", dirs[i].location, err)
+		}
+	}
+	return nil
+}
+
+func writeFrozenFile(location string, mode os.FileMode, content io.Reader) error {
+	f, err := os.OpenFile(location, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// mode is masked by umask on create
+	return os.Chmod(location, mode.Perm())
+}
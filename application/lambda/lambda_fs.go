@@ -2,23 +2,29 @@ package lambda
 
 import (
 	"compress/gzip"
-	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/types"
 )
 
 func (local *localLambda) ListFiles(path string) ([]types.File, error) {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
 	path, isLocal := local.resolvePath(local.rootDir, path)
 	if !isLocal {
 		return nil, fmt.Errorf("non-local file")
 	}
-	list, err := ioutil.ReadDir(path)
+	list, err := local.readDir(path)
 	if err != nil {
 		return nil, err
 	}
@@ -33,11 +39,20 @@ func (local *localLambda) ListFiles(path string) ([]types.File, error) {
 }
 
 func (local *localLambda) ReadFile(path string, output io.Writer) error {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
 	path, isLocal := local.resolvePath(local.rootDir, path)
 	if !isLocal {
 		return fmt.Errorf("non-local file")
 	}
-	f, err := os.Open(path)
+	var f io.ReadCloser
+	var err error
+	if local.bundle != nil && !local.isManifest(path) {
+		f, err = local.openBundled(path)
+	} else {
+		// manifest for bundled lambda is always outside of bundle
+		f, err = os.Open(path)
+	}
 	if err != nil {
 		return err
 	}
@@ -51,14 +66,23 @@ func (local *localLambda) WriteFile(path string, input io.Reader) error {
 	if !isLocal {
 		return fmt.Errorf("non-local file")
 	}
-	if path == local.manifestFile() {
-		var manifest types.Manifest
-		err := json.NewDecoder(input).Decode(&manifest)
+	if local.isManifest(path) {
+		// format is defined by written file, manifest is saved in the existing format
+		manifest, err := types.DecodeManifest(path, input)
 		if err != nil {
 			return fmt.Errorf("parse manifest: %w", err)
 		}
 		return local.SetManifest(manifest)
 	}
+	if local.isBundled() {
+		return errBundled
+	}
+	manifest := local.Manifest()
+	if err := local.checkQuota(manifest.DiskQuota(), true); err != nil {
+		return err
+	}
+	defer local.disk.reset()
+	defer local.revision.Add(1)
 	f, err := os.Create(path)
 	if err != nil {
 		return err
@@ -68,11 +92,11 @@ func (local *localLambda) WriteFile(path string, input io.Reader) error {
 	if err != nil {
 		return err
 	}
-	creds := local.creds
-	if creds == nil {
-		return nil
+	info, err := f.Stat()
+	if err != nil {
+		return err
 	}
-	return os.Chown(path, creds.User, creds.Group)
+	return local.applyOwner(path, info)
 }
 
 func (local *localLambda) EnsureDir(path string) error {
@@ -80,6 +104,9 @@ func (local *localLambda) EnsureDir(path string) error {
 	if !isLocal {
 		return fmt.Errorf("non-local file")
 	}
+	if local.isBundled() {
+		return errBundled
+	}
 	err := os.MkdirAll(path, 0700)
 	if err != nil {
 		return err
@@ -95,6 +122,11 @@ func (local *localLambda) RemoveFile(path string) error {
 	if !local.isRemovable(path) {
 		return fmt.Errorf("non-removable file")
 	}
+	if local.isBundled() {
+		return errBundled
+	}
+	defer local.disk.reset()
+	defer local.revision.Add(1)
 	return os.RemoveAll(path)
 }
 
@@ -110,15 +142,22 @@ func (local *localLambda) RenameFile(src, dest string) error {
 	if srcPath == destPath {
 		return nil
 	}
+	if local.isBundled() {
+		return errBundled
+	}
 	if !local.isRemovable(srcPath) {
 		return fmt.Errorf("non-removable file")
 	}
+	defer local.revision.Add(1)
 	return os.Rename(srcPath, destPath)
 }
 
 func (local *localLambda) Content(tarball io.Writer) error {
 	local.lock.RLock()
 	defer local.lock.RUnlock()
+	if local.bundle != nil {
+		return zipToTarGz(&local.bundle.Reader, tarball)
+	}
 	ignore, err := local.readIgnore()
 	if err != nil {
 		return err
@@ -128,17 +167,27 @@ func (local *localLambda) Content(tarball io.Writer) error {
 	return tarFiles(local.rootDir, gz, ignore)
 }
 
-func (local *localLambda) SetContent(tarball io.Reader) error {
+func (local *localLambda) SetContent(archive io.Reader) error {
 	local.lock.Lock()
 	defer local.lock.Unlock()
-	gz, err := gzip.NewReader(tarball)
+	extracted, err := extractArchive(archive, local.rootDir, local.profile.Upload, local.manifest.DiskQuota())
 	if err != nil {
 		return err
 	}
-	defer gz.Close()
-	err = untarFiles(gz, local.rootDir)
+	local.disk.reset()
+	// manifest from content replaces manifest in other format
+	if extracted[internal.ManifestFile] != extracted[internal.ManifestYAML] {
+		stale := internal.ManifestYAML
+		if extracted[internal.ManifestYAML] {
+			stale = internal.ManifestFile
+		}
+		if err := os.Remove(filepath.Join(local.rootDir, stale)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove replaced manifest: %w", err)
+		}
+	}
+	err = local.unbundle()
 	if err != nil {
-		return err
+		return fmt.Errorf("switch from bundle: %w", err)
 	}
 	err = local.applyFilesOwner()
 	if err != nil {
@@ -147,18 +196,136 @@ func (local *localLambda) SetContent(tarball io.Reader) error {
 	return local.reindex()
 }
 
+func (local *localLambda) Replace(dir string) error {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	if err := checkDirQuota(dir, replacementQuota(dir, local.manifest)); err != nil {
+		return err
+	}
+	defer local.disk.reset()
+	previous := local.rootDir + ".replaced-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := os.Rename(local.rootDir, previous); err != nil {
+		return fmt.Errorf("move files of lambda: %w", err)
+	}
+	if err := os.Rename(dir, local.rootDir); err != nil {
+		_ = os.Rename(previous, local.rootDir)
+		return fmt.Errorf("move replacement: %w", err)
+	}
+	// bundle is kept by pointer file of replacement (if any)
+	local.closeBundle()
+	if err := local.reindex(); err != nil {
+		_ = os.Rename(local.rootDir, dir)
+		_ = os.Rename(previous, local.rootDir)
+		if rerr := local.reindex(); rerr != nil {
+			log.Println("[ERROR]", "restore lambda", local.uid, "after failed replace:", rerr)
+		}
+		return err
+	}
+	if err := os.RemoveAll(previous); err != nil {
+		log.Println("[WARN]", "remove replaced files of lambda", local.uid, "-", err)
+	}
+	return nil
+}
+
+func (local *localLambda) Modified() (time.Time, error) {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	var last time.Time
+	err := filepath.Walk(local.rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+		return nil
+	})
+	return last, err
+}
+
+func (local *localLambda) Fingerprint() (string, error) {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	if local.bundle != nil {
+		return local.bundleHash, nil
+	}
+	ignore, err := local.readIgnore()
+	if err != nil {
+		return "", err
+	}
+	return fingerprintFiles(local.rootDir, ignore)
+}
+
+func (local *localLambda) Invalidate() {
+	local.lock.Lock()
+	defer local.lock.Unlock()
+	local.stopWorkers()
+	local.revision.Add(1)
+}
+
+func (local *localLambda) isBundled() bool {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	return local.bundle != nil
+}
+
+// list directory content from bundle or from file system
+func (local *localLambda) readDir(path string) ([]os.FileInfo, error) {
+	if local.bundle == nil {
+		return ioutil.ReadDir(path)
+	}
+	name, err := local.bundlePath(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(local.bundle, name)
+	if err != nil {
+		return nil, err
+	}
+	var ans = make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, info)
+	}
+	return ans, nil
+}
+
 func (local *localLambda) applyFilesOwner() error {
-	if local.creds == nil {
+	if local.runner() == nil {
 		return nil
 	}
 	return filepath.Walk(local.rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		return os.Chown(path, local.creds.User, local.creds.Group)
+		return local.applyOwner(path, info)
 	})
 }
 
+// files belong to user of lambda or, if content is read-only, to server with group of user which could read (and
+// traverse) but not write them
+func (local *localLambda) applyOwner(path string, info os.FileInfo) error {
+	creds := local.runner()
+	if creds == nil {
+		return nil
+	}
+	if !local.readOnly() {
+		// symlink (ex: interpreter of virtual environment) could point out of lambda
+		return os.Lchown(path, creds.User, creds.Group)
+	}
+	if err := os.Lchown(path, os.Getuid(), creds.Group); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	mode := info.Mode().Perm()
+	return os.Chmod(path, mode&^0020|(mode&0500)>>3)
+}
+
 func (local *localLambda) resolvePath(rootDir string, path string) (string, bool) {
 	path = filepath.Join(rootDir, path)
 	abs, err := filepath.Abs(path)
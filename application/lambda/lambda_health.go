@@ -0,0 +1,107 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// time limit of one availability check of manifest
+const healthCheckTimeout = 10 * time.Second
+
+func (local *localLambda) Health(ctx context.Context, globalEnv map[string]string) []string {
+	manifest, expandErr := local.expandRun(local.Effective(), globalEnv)
+	var problems []string
+	if status := local.startupStatus(); status != nil && status.Degraded {
+		problems = append(problems, fmt.Sprintf("startup action %s failed: %s", status.Action, status.Error))
+	}
+	problems = append(problems, local.missingSecrets()...)
+	problems = append(problems, local.containerProblems(manifest)...)
+	if expandErr != nil {
+		return append(problems, expandErr.Error())
+	}
+	if len(manifest.Run) == 0 && len(manifest.Check) == 0 && manifest.WarmUpMarker == "" {
+		return problems
+	}
+	workDir, err := local.workDir(manifest.WorkDir)
+	if err != nil {
+		return append(problems, fmt.Sprintf("prepare work dir: %v", err))
+	}
+	if manifest.WarmUpMarker != "" {
+		if err := local.checkMarker(manifest.WarmUpMarker); err != nil {
+			problems = append(problems, fmt.Sprintf("warm-up marker %s: %v", manifest.WarmUpMarker, err))
+		}
+	}
+	// command of container is in image
+	if len(manifest.Run) > 0 && manifest.Container == nil {
+		if err := checkBinary(workDir, manifest.Run[0]); err != nil {
+			problems = append(problems, fmt.Sprintf("run command %s: %v", manifest.Run[0], err))
+		}
+	}
+	if len(manifest.Check) == 0 {
+		return problems
+	}
+	environments := local.environment(globalEnv)
+	for k, v := range local.manifestEnvironment(environments) {
+		environments = append(environments, k+"="+v)
+	}
+	runner := local.Credentials()
+	for _, check := range manifest.Check {
+		if lookup, err := internal.LookupCheck(check); lookup {
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("check %s: %v", strings.Join(check, " "), err))
+			}
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		cmd := exec.CommandContext(cctx, check[0], check[1:]...)
+		cmd.Dir = workDir
+		cmd.Env = environments
+		internal.SetCreds(cmd, runner)
+		internal.SetFlags(cmd)
+		local.sandbox(cmd, manifest)
+		err := cmd.Run()
+		cancel()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("check %s: %v", strings.Join(check, " "), err))
+		}
+	}
+	return problems
+}
+
+// marker of environment exists in lambda directory
+func (local *localLambda) checkMarker(marker string) error {
+	content, err := local.contentDir()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(filepath.Join(content, filepath.FromSlash(marker)))
+	return err
+}
+
+// executable file of command: relative to working directory if path has separator (slash on any platform), otherwise
+// in PATH
+func checkBinary(workDir string, name string) error {
+	if filepath.Base(name) == name {
+		_, err := exec.LookPath(name)
+		return err
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(workDir, name)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !internal.IsExecutable(info) {
+		return errors.New("not an executable file")
+	}
+	return nil
+}
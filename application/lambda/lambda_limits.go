@@ -0,0 +1,50 @@
+package lambda
+
+import (
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// apply resource limits of manifest to command of invocation or worker. Should be called under lock
+func (local *localLambda) limit(cmd *exec.Cmd, manifest types.Manifest) (application.ResourceGuard, error) {
+	if manifest.Limits == nil || local.builder == nil {
+		return unlimited{}, nil
+	}
+	return local.builder.Guard(local.uid, *manifest.Limits, cmd)
+}
+
+// problem of resource limits of manifest which could not be enforced (empty - no problems). Should be called under lock
+func (local *localLambda) limitsWarning() string {
+	if local.manifest.Limits == nil {
+		return ""
+	}
+	defined := local.manifest.Limits.Defined()
+	if len(defined) == 0 {
+		return ""
+	}
+	if local.builder == nil {
+		return "resource limits are not enforced: " + strings.Join(defined, ", ") + " (no executor of limits)"
+	}
+	if unenforced := local.builder.Unenforced(*local.manifest.Limits); len(unenforced) > 0 {
+		return "resource limits are not enforced: " + strings.Join(unenforced, ", ")
+	}
+	return ""
+}
+
+// log resource limits which could not be enforced. Should be called under lock
+func (local *localLambda) warnLimits() {
+	if warning := local.limitsWarning(); warning != "" {
+		log.Println("[WARN]", "lambda", local.uid, "-", warning)
+	}
+}
+
+// process without resource limits
+type unlimited struct{}
+
+func (unlimited) Started(cmd *exec.Cmd) {}
+
+func (unlimited) Finish(err error) error { return err }
@@ -0,0 +1,322 @@
+package lambda
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// DefaultTailLines is number of lines of current log file returned by TailLog without limit
+const DefaultTailLines = 100
+
+const (
+	logExt        = ".log"
+	logNameFormat = "20060102T150405.000000000Z" // names of log files are sorted by time of creation
+	maxLogLine    = 64 * 1024                    // longer output without new line is split to several lines
+	tailChunk     = 64 * 1024
+)
+
+func (local *localLambda) LogFiles() ([]application.LogFile, error) {
+	dir := local.logsDir()
+	names, err := logNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ans = make([]application.LogFile, 0, len(names))
+	for i, name := range names {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue // rotated concurrently
+		} else if err != nil {
+			return nil, err
+		}
+		ans = append(ans, application.LogFile{Name: name, Size: info.Size(), Modified: info.ModTime(), Current: i == len(names)-1})
+	}
+	return ans, nil
+}
+
+func (local *localLambda) ReadLog(name string, output io.Writer) error {
+	if !isLogName(name) {
+		return fmt.Errorf("%w: %s", application.ErrLogNotFound, name)
+	}
+	f, err := os.Open(filepath.Join(local.logsDir(), name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", application.ErrLogNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(output, f)
+	return err
+}
+
+func (local *localLambda) TailLog(lines int) ([]byte, error) {
+	if lines <= 0 {
+		lines = DefaultTailLines
+	}
+	dir := local.logsDir()
+	names, err := logNames(dir)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, names[len(names)-1]))
+	if os.IsNotExist(err) {
+		return nil, nil // rotated concurrently
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tailLines(f, lines)
+}
+
+// directory of log files out of lambda content: logs survive replace of files and are removed with lambda
+func (local *localLambda) logsDir() string {
+	return filepath.Join(filepath.Dir(local.rootDir), internal.LogsDir, filepath.Base(local.rootDir))
+}
+
+// close current log file and remove log files of removed lambda
+func (local *localLambda) removeLogs() {
+	local.logs.close()
+	if err := os.RemoveAll(local.logsDir()); err != nil {
+		log.Println("[WARN]", "remove log files of lambda", local.uid, "-", err)
+	}
+}
+
+// streams of output of invocation to log files (nil - logs are disabled by manifest)
+func (local *localLambda) invocationLogs(manifest types.Manifest, requestID string) *invocationLogs {
+	if manifest.Logs == nil || !manifest.Logs.Enabled {
+		return nil
+	}
+	if requestID == "" {
+		requestID = "-"
+	}
+	return &invocationLogs{
+		stdout: local.logStream(*manifest.Logs, requestID+" stdout"),
+		stderr: local.logStream(*manifest.Logs, requestID+" stderr"),
+	}
+}
+
+func (local *localLambda) logStream(settings types.LogFiles, prefix string) *logStream {
+	return &logStream{files: &local.logs, dir: local.logsDir(), uid: local.uid, settings: settings, prefix: prefix}
+}
+
+// log streams of stdout and stderr of invocation
+type invocationLogs struct {
+	stdout *logStream
+	stderr *logStream
+}
+
+// write incomplete lines after process finished
+func (il *invocationLogs) Close() {
+	il.stdout.flush()
+	il.stderr.flush()
+}
+
+// writer of output stream to log files line by line: line is prefixed by time, request ID and stream. Failed write is
+// reported once and does not fail invocation
+type logStream struct {
+	files    *logFiles
+	dir      string
+	uid      string
+	settings types.LogFiles
+	prefix   string
+	line     []byte // incomplete line
+	failed   bool
+}
+
+func (ls *logStream) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			ls.line = append(ls.line, p...)
+			if len(ls.line) >= maxLogLine {
+				ls.flush()
+			}
+			break
+		}
+		ls.line = append(ls.line, p[:i]...)
+		ls.write()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// write incomplete line (if any)
+func (ls *logStream) flush() {
+	if len(ls.line) > 0 {
+		ls.write()
+	}
+}
+
+func (ls *logStream) write() {
+	record := make([]byte, 0, len(ls.line)+len(ls.prefix)+len(time.RFC3339Nano)+3)
+	record = time.Now().UTC().AppendFormat(record, time.RFC3339Nano)
+	record = append(record, ' ')
+	record = append(record, ls.prefix...)
+	record = append(record, ' ')
+	record = append(record, ls.line...)
+	record = append(record, '\n')
+	ls.line = ls.line[:0]
+	if err := ls.files.write(ls.dir, ls.settings, record); err != nil && !ls.failed {
+		ls.failed = true
+		log.Println("[WARN]", "write log file of lambda", ls.uid, "-", err)
+	}
+}
+
+// log files of lambda rotated by size: record which doesn't fit current file starts the next file, the oldest files
+// over limit are removed. Size is checked by every record, so single invocation could not grow logs over limits
+type logFiles struct {
+	lock sync.Mutex
+	file *os.File // current file (nil - not opened)
+	size int64    // size of current file
+}
+
+func (lf *logFiles) write(dir string, settings types.LogFiles, record []byte) error {
+	lf.lock.Lock()
+	defer lf.lock.Unlock()
+	rotate := lf.file != nil && lf.size > 0 && lf.size+int64(len(record)) > settings.MaxSize()
+	if rotate {
+		lf.closeFile()
+	}
+	if lf.file == nil {
+		if err := lf.open(dir, settings, !rotate, int64(len(record))); err != nil {
+			return err
+		}
+	}
+	n, err := lf.file.Write(record)
+	lf.size += int64(n)
+	return err
+}
+
+// open current file: the newest file is continued (ex: after restart) if record fits it, otherwise the next file is
+// created. Should be called under lock
+func (lf *logFiles) open(dir string, settings types.LogFiles, resume bool, need int64) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create directory of log files: %w", err)
+	}
+	names, err := logNames(dir)
+	if err != nil {
+		return err
+	}
+	var name string
+	if resume && len(names) > 0 {
+		if info, err := os.Stat(filepath.Join(dir, names[len(names)-1])); err == nil && info.Size()+need <= settings.MaxSize() {
+			name, lf.size = names[len(names)-1], info.Size()
+		}
+	}
+	if name == "" {
+		name, lf.size = time.Now().UTC().Format(logNameFormat)+logExt, 0
+		names = append(names, name)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	lf.file = f
+	for len(names) > settings.Files() {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil && !os.IsNotExist(err) {
+			log.Println("[WARN]", "remove rotated log file", names[0], "-", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+func (lf *logFiles) close() {
+	lf.lock.Lock()
+	defer lf.lock.Unlock()
+	lf.closeFile()
+}
+
+func (lf *logFiles) closeFile() {
+	if lf.file == nil {
+		return
+	}
+	if err := lf.file.Close(); err != nil {
+		log.Println("[WARN]", "close log file -", err)
+	}
+	lf.file, lf.size = nil, 0
+}
+
+// names of log files in directory from the oldest
+func logNames(dir string) ([]string, error) {
+	list, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("list log files: %w", err)
+	}
+	var names []string
+	for _, item := range list {
+		if item.Mode().IsRegular() && isLogName(item.Name()) {
+			names = append(names, item.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isLogName(name string) bool {
+	stamp := strings.TrimSuffix(name, logExt)
+	if stamp == name {
+		return false
+	}
+	_, err := time.Parse(logNameFormat, stamp)
+	return err == nil
+}
+
+// the last lines of file, read from the end by chunks
+func tailLines(f *os.File, lines int) ([]byte, error) {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for offset > 0 {
+		size := int64(tailChunk)
+		if size > offset {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return nil, err
+		}
+		data = append(chunk, data...)
+		// the last new line terminates the last line
+		if bytes.Count(data[:len(data)-1], []byte{'\n'}) >= lines {
+			break
+		}
+	}
+	return lastLines(data, lines), nil
+}
+
+// the last lines of data (trailing new line is kept)
+func lastLines(data []byte, lines int) []byte {
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			lines--
+			if lines == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,83 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// directory of temporary files of invocations (ex: spooled payload) out of lambda content
+func (local *localLambda) scratchDir() string {
+	return filepath.Join(filepath.Dir(local.rootDir), internal.ScratchDir, filepath.Base(local.rootDir))
+}
+
+// remove temporary files left by previous run of server (ex: crash during invocation)
+func (local *localLambda) cleanScratch() {
+	if err := os.RemoveAll(local.scratchDir()); err != nil {
+		log.Println("[WARN]", "clean scratch directory of lambda", local.uid, "-", err)
+	}
+}
+
+// create scratch directory owned by lambda user. Should be called under lock
+func (local *localLambda) prepareScratch() (string, error) {
+	dir := local.scratchDir()
+	if err := os.MkdirAll(filepath.Dir(dir), 0711); err != nil {
+		return "", fmt.Errorf("create scratch directory: %w", err)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("create scratch directory: %w", err)
+	}
+	if runner := local.runner(); runner != nil {
+		if err := os.Chown(dir, runner.User, runner.Group); err != nil {
+			return "", fmt.Errorf("set owner of scratch directory: %w", err)
+		}
+	}
+	return dir, nil
+}
+
+// stream request body to temporary file in scratch directory readable by lambda user. Body over the limit is rejected
+// with ErrPayloadTooLarge; slow body is interrupted by done context. Should be called under lock. Returns path of file
+// which should be removed after the process exits
+func (local *localLambda) spoolPayload(ctx context.Context, body io.Reader, closer io.Closer, limit int64) (string, error) {
+	dir, err := local.prepareScratch()
+	if err != nil {
+		return "", err
+	}
+	runner := local.runner()
+	f, err := ioutil.TempFile(dir, "payload-")
+	if err != nil {
+		return "", fmt.Errorf("create payload file: %w", err)
+	}
+	name := f.Name()
+	stop := context.AfterFunc(ctx, func() {
+		_ = closer.Close()
+	})
+	// one byte over the limit is enough to detect overflow
+	size, err := io.Copy(f, io.LimitReader(body, limit+1))
+	stop()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > limit {
+		err = fmt.Errorf("%w: request exceeds maximum file payload (%d bytes)", application.ErrPayloadTooLarge, limit)
+	} else if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("receive payload: %w", ctx.Err())
+	} else if err != nil {
+		err = fmt.Errorf("receive payload: %w", err)
+	}
+	if err == nil && runner != nil {
+		err = os.Chown(name, runner.User, runner.Group)
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
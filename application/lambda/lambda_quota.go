@@ -0,0 +1,183 @@
+package lambda
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// DiskUsageTTL is maximum age of measured size of lambda directory: older size is measured again
+const DiskUsageTTL = 30 * time.Second
+
+func (local *localLambda) DiskUsage() (application.DiskUsage, error) {
+	size, measured, err := local.disk.usage(local.rootDir, true)
+	if err != nil {
+		return application.DiskUsage{}, err
+	}
+	manifest := local.Manifest()
+	return application.DiskUsage{Size: size, Quota: manifest.DiskQuota(), Measured: measured}, nil
+}
+
+// invocation of lambda over disk quota fails. Stale size is measured again in background (wait - measured now)
+func (local *localLambda) checkQuota(quota int64, wait bool) error {
+	if quota <= 0 {
+		return nil
+	}
+	size, _, err := local.disk.usage(local.rootDir, wait)
+	if err != nil {
+		log.Println("[WARN]", "measure disk usage of lambda", local.uid, "-", err)
+		return nil
+	}
+	if size > quota {
+		return quotaError(size, quota)
+	}
+	return nil
+}
+
+// replacement of lambda files (staged copy) should fit disk quota
+func checkDirQuota(dir string, quota int64) error {
+	if quota <= 0 {
+		return nil
+	}
+	size, err := dirSize(dir)
+	if err != nil {
+		return fmt.Errorf("measure size of %s: %w", dir, err)
+	}
+	if size > quota {
+		return quotaError(size, quota)
+	}
+	return nil
+}
+
+// size of directory after extraction of archive should fit disk quota: extracted files replace existing ones
+func checkArchiveQuota(data []byte, dest string, quota int64) error {
+	size, err := dirSize(dest)
+	if err != nil {
+		return fmt.Errorf("measure size of lambda: %w", err)
+	}
+	growth := &growthCounter{root: dest}
+	if err := walkArchive(data, growth); err != nil {
+		return err
+	}
+	if size+growth.delta > quota {
+		return quotaError(size+growth.delta, quota)
+	}
+	return nil
+}
+
+func quotaError(size, quota int64) error {
+	return fmt.Errorf("%w: size of lambda directory %d bytes is over quota %d bytes", application.ErrDiskQuota, size, quota)
+}
+
+// cached size of lambda directory
+type diskMeter struct {
+	lock      sync.Mutex
+	size      int64
+	measured  time.Time // zero - not measured or reset
+	measuring bool      // measurement in background
+}
+
+// size of directory not older than DiskUsageTTL. Not measured size is always measured synchronously, stale size is
+// measured synchronously (wait) or in background (stale size is returned)
+func (dm *diskMeter) usage(dir string, wait bool) (int64, time.Time, error) {
+	dm.lock.Lock()
+	size, measured, measuring := dm.size, dm.measured, dm.measuring
+	fresh := !measured.IsZero() && time.Since(measured) < DiskUsageTTL
+	if !fresh && !wait && !measured.IsZero() && !measuring {
+		dm.measuring = true
+		go dm.measure(dir)
+	}
+	dm.lock.Unlock()
+	if fresh || (!wait && !measured.IsZero()) {
+		return size, measured, nil
+	}
+	return dm.measure(dir)
+}
+
+func (dm *diskMeter) measure(dir string) (int64, time.Time, error) {
+	started := time.Now()
+	size, err := dirSize(dir)
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	dm.measuring = false
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if started.After(dm.measured) {
+		dm.size, dm.measured = size, started
+	}
+	return dm.size, dm.measured, nil
+}
+
+// drop cached size after change of files by server: the next check measures size
+func (dm *diskMeter) reset() {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	dm.measured = time.Time{}
+}
+
+// total size of regular files in directory, symbolic links are not followed. Files removed during walk are skipped
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// growth of directory by extraction of archive entries: size of entry minus size of replaced file
+type growthCounter struct {
+	root  string
+	delta int64
+}
+
+func (gc *growthCounter) dir(name string, mode os.FileMode) error { return nil }
+
+func (gc *growthCounter) file(name string, mode os.FileMode, size int64, content io.Reader) error {
+	rel, err := entryName(name)
+	if err != nil {
+		return err
+	}
+	gc.delta += size
+	if info, err := os.Lstat(filepath.Join(gc.root, filepath.FromSlash(rel))); err == nil && info.Mode().IsRegular() {
+		gc.delta -= info.Size()
+	}
+	return nil
+}
+
+// quota of replacement: manifest of staged copy (if any), otherwise quota of current manifest
+func replacementQuota(dir string, current types.Manifest) int64 {
+	file, err := internal.FindManifest(dir)
+	if err != nil {
+		return current.DiskQuota()
+	}
+	var manifest types.Manifest
+	if err := manifest.LoadFrom(file); err != nil {
+		return current.DiskQuota()
+	}
+	return manifest.DiskQuota()
+}
@@ -0,0 +1,66 @@
+package lambda
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// credentials of processes and owner of files: account of manifest (see types.Manifest.RunAs) or user of server.
+// Nil if account of manifest could not be applied. Should be called under lock
+func (local *localLambda) runner() *types.Credential {
+	if local.manifest.RunAs != nil {
+		return local.runAs
+	}
+	return local.creds
+}
+
+// resolve account of manifest by security profile. Files belong to the account if runner changed. Account which
+// could not be applied (ex: edited manifest) fails invocations and actions instead of running them by user of
+// server. Should be called under lock
+func (local *localLambda) applyRunAs() error {
+	previous := local.runner()
+	local.runAs, local.runAsErr = resolveRunAs(local.manifest, local.profile)
+	if local.runner().Equal(previous) {
+		return nil
+	}
+	return local.applyFilesOwner()
+}
+
+// credentials of account of manifest, nil if not set
+func resolveRunAs(manifest types.Manifest, profile types.SecurityProfile) (*types.Credential, error) {
+	runAs := manifest.RunAs
+	if runAs == nil {
+		return nil, nil
+	}
+	if err := profile.AllowRunAs(runAs); err != nil {
+		return nil, err
+	}
+	if !internal.CanSwitchUser() {
+		return nil, fmt.Errorf("run as %s requires server running as root", runAs)
+	}
+	info, err := user.Lookup(runAs.User)
+	if err != nil {
+		return nil, fmt.Errorf("run as %s: %w", runAs, err)
+	}
+	gid := info.Gid
+	if runAs.Group != "" {
+		group, err := user.LookupGroup(runAs.Group)
+		if err != nil {
+			return nil, fmt.Errorf("run as %s: %w", runAs, err)
+		}
+		gid = group.Gid
+	}
+	uidNum, err := strconv.Atoi(info.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("run as %s: uid %s: %w", runAs, info.Uid, err)
+	}
+	gidNum, err := strconv.Atoi(gid)
+	if err != nil {
+		return nil, fmt.Errorf("run as %s: gid %s: %w", runAs, gid, err)
+	}
+	return &types.Credential{User: uidNum, Group: gidNum}, nil
+}
@@ -0,0 +1,215 @@
+package lambda
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// directory of secret files: tmpfs (never written to disk) if available
+const secretsDir = "/dev/shm"
+
+// names of secret variables from global and lambda environment (sorted): by rule of manifest and references to
+// secrets of server store
+func (local *localLambda) secretKeys(globalEnv map[string]string) []string {
+	var keys []string
+	var seen = make(map[string]bool)
+	for _, env := range []map[string]string{globalEnv, local.manifest.Environment} {
+		for k := range env {
+			if !seen[k] && (local.manifest.Secrets.IsSecret(k) || isReference(env[k])) {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copy of environment with values of referenced secrets of server store. Secret values are literal: ${ is escaped.
+// Unknown secret is empty (see missingSecrets). Should be called under lock
+func (local *localLambda) resolveSecrets(env map[string]string) map[string]string {
+	var resolved map[string]string
+	for k, v := range env {
+		name, ok := types.SecretReference(v)
+		if !ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = make(map[string]string, len(env))
+			for k, v := range env {
+				resolved[k] = v
+			}
+		}
+		var value string
+		if local.secrets != nil {
+			value, _ = local.secrets.Value(name)
+		}
+		resolved[k] = strings.ReplaceAll(value, "${", "$${")
+	}
+	if resolved == nil {
+		return env
+	}
+	return resolved
+}
+
+// problems of variables which reference unknown secrets (or secrets without store)
+func (local *localLambda) missingSecrets() []string {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	var keys []string
+	for k, v := range local.manifest.Environment {
+		if isReference(v) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var problems []string
+	for _, k := range keys {
+		name, _ := types.SecretReference(local.manifest.Environment[k])
+		if local.secrets == nil {
+			problems = append(problems, fmt.Sprintf("variable %s references secret %s, but store of secrets is disabled", k, name))
+		} else if _, found := local.secrets.Value(name); !found {
+			problems = append(problems, fmt.Sprintf("variable %s references unknown secret %s", k, name))
+		}
+	}
+	return problems
+}
+
+func (local *localLambda) secretsStatus(globalEnv map[string]string) application.SecretsStatus {
+	keys := local.secretKeys(globalEnv)
+	if keys == nil {
+		keys = []string{}
+	}
+	return application.SecretsStatus{
+		Delivery: local.manifest.Secrets.Mode(),
+		Env:      local.manifest.Secrets.Variable(),
+		Keys:     keys,
+	}
+}
+
+// Delivery of secrets to single invocation. Started should be called after process start, Close - after process
+// finished (or if process not started).
+type secretsDelivery struct {
+	data   []byte
+	reader *os.File // fd mode: read end of pipe (inherited by process)
+	writer *os.File // fd mode: write end of pipe
+	file   string   // file mode: temporary file
+}
+
+// remove secret variables from environment of command (the last value is used, like exec does) and prepare
+// delivery by descriptor or file. Environment mode does nothing.
+func (local *localLambda) prepareSecrets(cmd *exec.Cmd, globalEnv map[string]string) (*secretsDelivery, error) {
+	mode := local.manifest.Secrets.Mode()
+	if mode == types.SecretsEnv {
+		return &secretsDelivery{}, nil
+	}
+	var secret = make(map[string]bool)
+	for _, k := range local.secretKeys(globalEnv) {
+		secret[k] = true
+	}
+	var values = make(map[string]string)
+	var environments = make([]string, 0, len(cmd.Env))
+	for _, kv := range cmd.Env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && secret[parts[0]] {
+			values[parts[0]] = parts[1]
+			continue
+		}
+		environments = append(environments, kv)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &secretsDelivery{data: data}
+	variable := local.manifest.Secrets.Variable()
+	switch mode {
+	case types.SecretsFD:
+		delivery.reader, delivery.writer, err = os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("create pipe: %w", err)
+		}
+		cmd.ExtraFiles = append(cmd.ExtraFiles, delivery.reader)
+		// extra files start after stdin, stdout and stderr
+		environments = append(environments, variable+"="+strconv.Itoa(2+len(cmd.ExtraFiles)))
+	case types.SecretsFile:
+		delivery.file, err = writeSecretsFile(data, local.runner())
+		if err != nil {
+			return nil, err
+		}
+		environments = append(environments, variable+"="+delivery.file)
+	}
+	cmd.Env = environments
+	return delivery, nil
+}
+
+// process started: parent's copy of pipe read end is closed and secrets are written to the pipe
+func (sd *secretsDelivery) Started() {
+	if sd.reader == nil {
+		return
+	}
+	_ = sd.reader.Close()
+	sd.reader = nil
+	writer := sd.writer
+	sd.writer = nil
+	// write in background: pipe buffer could be smaller than secrets, write fails when process closes pipe
+	go func() {
+		defer writer.Close()
+		_, _ = writer.Write(sd.data)
+	}()
+}
+
+// release pipe (if process not started) and remove secret file
+func (sd *secretsDelivery) Close() {
+	if sd.reader != nil {
+		_ = sd.reader.Close()
+	}
+	if sd.writer != nil {
+		_ = sd.writer.Close()
+	}
+	if sd.file != "" {
+		_ = os.Remove(sd.file)
+	}
+}
+
+// write secrets to temporary file readable only by owner (lambda user if credentials set)
+func writeSecretsFile(data []byte, creds *types.Credential) (string, error) {
+	dir := os.TempDir()
+	if stat, err := os.Stat(secretsDir); err == nil && stat.IsDir() {
+		dir = secretsDir
+	}
+	f, err := ioutil.TempFile(dir, "trusted-cgi-secrets-")
+	if err != nil {
+		return "", fmt.Errorf("create secrets file: %w", err)
+	}
+	name := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(name, 0400)
+	}
+	if err == nil && creds != nil {
+		err = os.Chown(name, creds.User, creds.Group)
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return "", fmt.Errorf("write secrets file: %w", err)
+	}
+	return name, nil
+}
+
+func isReference(value string) bool {
+	_, ok := types.SecretReference(value)
+	return ok
+}
@@ -0,0 +1,160 @@
+package lambda
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// maximum size of kept output of startup action
+const startupOutputLimit = 4096
+
+type startupState struct {
+	done     chan struct{} // closed when action finished
+	action   string
+	started  time.Time
+	finished time.Time
+	err      error
+	output   tailBuffer
+}
+
+// Start startup action (on_start) in background if defined.
+func (local *localLambda) Start(ctx context.Context, globalEnv map[string]string) {
+	startup := local.startupDefinition()
+	if startup == nil {
+		local.startLock.Lock()
+		local.startup = nil
+		local.startLock.Unlock()
+		return
+	}
+	state := local.beginStartup(startup.Action)
+	go func() {
+		_ = local.runStartup(ctx, state, *startup, globalEnv, nil)
+	}()
+}
+
+func (local *localLambda) startupDefinition() *types.Startup {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	if local.manifest.OnStart == nil {
+		return nil
+	}
+	startup := *local.manifest.OnStart
+	return &startup
+}
+
+func (local *localLambda) beginStartup(action string) *startupState {
+	state := &startupState{
+		done:    make(chan struct{}),
+		action:  action,
+		started: time.Now(),
+		output:  tailBuffer{limit: startupOutputLimit},
+	}
+	local.startLock.Lock()
+	local.startup = state
+	local.startLock.Unlock()
+	return state
+}
+
+func (local *localLambda) runStartup(ctx context.Context, state *startupState, startup types.Startup, globalEnv map[string]string, out io.Writer) error {
+	var output io.Writer = &state.output
+	if out != nil {
+		output = io.MultiWriter(out, &state.output)
+	}
+	err := local.do(ctx, startup.Action, time.Duration(startup.TimeLimit), globalEnv, output)
+	local.startLock.Lock()
+	state.err = err
+	state.finished = time.Now()
+	local.startLock.Unlock()
+	close(state.done)
+
+	scanner := bufio.NewScanner(strings.NewReader(state.output.String()))
+	for scanner.Scan() {
+		log.Println("[on_start]", local.uid, scanner.Text())
+	}
+	if err != nil {
+		log.Println("[ERROR]", "startup action", startup.Action, "of lambda", local.uid, "failed:", err)
+	} else {
+		log.Println("[on_start]", local.uid, "action", startup.Action, "finished in", state.finished.Sub(state.started))
+	}
+	return err
+}
+
+// wait for blocking startup action. Returns error if startup failed with degraded policy
+func (local *localLambda) awaitStartup(ctx context.Context) error {
+	startup := local.startupDefinition()
+	if startup == nil || !startup.Blocking {
+		return nil
+	}
+	local.startLock.Lock()
+	state := local.startup
+	local.startLock.Unlock()
+	if state == nil {
+		return nil
+	}
+	select {
+	case <-state.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if state.err != nil && startup.OnFailure == types.OnFailureDegraded {
+		return fmt.Errorf("lambda is degraded - startup action %s failed: %w", state.action, state.err)
+	}
+	return nil
+}
+
+func (local *localLambda) startupStatus() *application.StartupStatus {
+	local.startLock.Lock()
+	defer local.startLock.Unlock()
+	state := local.startup
+	if state == nil {
+		return nil
+	}
+	status := &application.StartupStatus{
+		Action:   state.action,
+		Running:  state.finished.IsZero(),
+		Started:  state.started,
+		Finished: state.finished,
+		Output:   state.output.String(),
+	}
+	if state.err != nil {
+		status.Error = state.err.Error()
+		status.Degraded = local.manifest.OnStart != nil && local.manifest.OnStart.OnFailure == types.OnFailureDegraded
+	}
+	return status
+}
+
+// keeps only last written bytes
+type tailBuffer struct {
+	lock  sync.Mutex
+	limit int
+	data  []byte
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	tb.data = append(tb.data, p...)
+	if extra := len(tb.data) - tb.limit; extra > 0 {
+		tb.data = append(tb.data[:0], tb.data[extra:]...)
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	return string(tb.Bytes())
+}
+
+func (tb *tailBuffer) Bytes() []byte {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	return append([]byte(nil), tb.data...)
+}
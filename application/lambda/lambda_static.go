@@ -0,0 +1,103 @@
+package lambda
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+func (local *localLambda) ServeStatic(requestPath string, handler func(content io.ReadSeeker, info fs.FileInfo) error) error {
+	local.lock.RLock()
+	defer local.lock.RUnlock()
+	dir, file, ok := local.manifest.StaticFile(requestPath)
+	if !ok || file == "" {
+		return fs.ErrNotExist
+	}
+	if local.bundle != nil {
+		return local.serveBundledStatic(file, handler)
+	}
+	location, err := local.jailedPath(file, dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fs.ErrNotExist
+	}
+	return handler(f, info)
+}
+
+// real path (symlinks are resolved) of file inside lambda. The file should be inside static directory and should not
+// be hidden or manifest
+func (local *localLambda) jailedPath(file, staticDir string) (string, error) {
+	root, err := filepath.EvalSymlinks(local.rootDir)
+	if err != nil {
+		return "", err
+	}
+	jail, err := filepath.EvalSymlinks(filepath.Join(local.rootDir, staticDir))
+	if err != nil {
+		return "", err
+	}
+	location, err := filepath.EvalSymlinks(filepath.Join(local.rootDir, file))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(location, jail+string(filepath.Separator)) || !isServable(root, location) {
+		return "", fs.ErrNotExist
+	}
+	return location, nil
+}
+
+func (local *localLambda) serveBundledStatic(file string, handler func(content io.ReadSeeker, info fs.FileInfo) error) error {
+	name := path.Clean(file)
+	if internal.IsManifest(name) || !fs.ValidPath(name) {
+		return fs.ErrNotExist
+	}
+	f, err := local.bundle.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fs.ErrNotExist
+	}
+	// entries of archive are not seekable
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return handler(bytes.NewReader(content), info)
+}
+
+// file inside lambda root which is not manifest and has no hidden segments
+func isServable(root, location string) bool {
+	rel, err := filepath.Rel(root, location)
+	if err != nil || internal.IsManifest(filepath.ToSlash(rel)) {
+		return false
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+	return true
+}
@@ -2,17 +2,33 @@ package lambda
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/builds"
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -114,6 +130,37 @@ func TestLocalLambda_Content(t *testing.T) {
 	assert.Equal(t, "xxx", ll2.manifest.Name)
 }
 
+func TestLocalLambda_ContentIgnore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "cache"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "src", "app.py"), []byte("print(1)"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "src", "cache", "app.pyc"), []byte("compiled"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "debug.log"), []byte("log"), 0644))
+	// edited on Windows
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, internal.CGIIgnore), []byte("*.log\r\nsrc/cache/*\r\n"), 0644))
+
+	ll := localLambda{rootDir: dir}
+	require.NoError(t, ll.SetManifest(types.Manifest{Name: "xxx"}))
+	var buffer bytes.Buffer
+	require.NoError(t, ll.Content(&buffer))
+
+	gz, err := gzip.NewReader(&buffer)
+	require.NoError(t, err)
+	reader := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{internal.CGIIgnore, internal.ManifestFile, "src", "src/app.py", "src/cache"}, names, "names are slash-separated")
+}
+
 func TestLocalLambda_Invoke(t *testing.T) {
 	d, err := ioutil.TempDir("", "test-lambda-")
 	if !assert.NoError(t, err) {
@@ -191,15 +238,1950 @@ func TestStaticFile(t *testing.T) {
 	})
 }
 
-func testRequest(fn application.Invokable, method string, path string, payload []byte) ([]byte, error) {
-	timeout, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+func TestLocalLambda_Runtime(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", "touch created && echo -n $LANG $TZ")
+	require.NoError(t, err)
+	fn.SetDefaults(types.Runtime{Umask: "0022", Lang: "C", TZ: "Europe/Berlin"})
+
+	manifest := fn.Manifest()
+	manifest.Umask = "0077"
+	manifest.TZ = "UTC"
+	require.NoError(t, fn.SetManifest(manifest))
+
+	content, err := testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "C UTC", string(content))
+
+	info, err := os.Stat(filepath.Join(d, "created"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	diag := fn.Diagnose(map[string]string{"LANG": "en_US.UTF-8"})
+	assert.Equal(t, types.Runtime{Umask: "0077", Lang: "en_US.UTF-8", TZ: "UTC"}, diag.Runtime)
+
+	manifest.Environment = map[string]string{"TZ": "Asia/Tokyo"}
+	require.NoError(t, fn.SetManifest(manifest))
+	content, err = testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "C Asia/Tokyo", string(content))
+}
+
+func TestLocalLambda_Startup(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat", "warmed")
+	require.NoError(t, err)
+	makefile := "warm:\n\tsleep 0.2 && echo warming && echo -n ok > warmed\n\nfail:\n\texit 1\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte(makefile), 0755))
+
+	manifest := fn.Manifest()
+	manifest.OnStart = &types.Startup{Action: "warm", Blocking: true, OnFailure: types.OnFailureDegraded}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	fn.Start(context.Background(), nil)
+	// blocking startup: request waits until cache warmed
+	content, err := testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(content))
+
+	status := fn.Diagnose(nil).Startup
+	require.NotNil(t, status)
+	assert.False(t, status.Running)
+	assert.False(t, status.Degraded)
+	assert.Contains(t, status.Output, "warming")
+
+	manifest.OnStart.Action = "fail"
+	require.NoError(t, fn.SetManifest(manifest))
+	// re-run on demand
+	err = fn.Do(context.Background(), "fail", 0, nil, nil)
+	assert.Error(t, err)
+	status = fn.Diagnose(nil).Startup
+	require.NotNil(t, status)
+	assert.True(t, status.Degraded)
+	assert.NotEmpty(t, status.Error)
+	_, err = testRequest(fn, http.MethodPost, "/", nil)
+	assert.Error(t, err)
+
+	// ignore policy serves as usual
+	manifest.OnStart.OnFailure = types.OnFailureIgnore
+	require.NoError(t, fn.SetManifest(manifest))
+	content, err = testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(content))
+	assert.False(t, fn.Diagnose(nil).Startup.Degraded)
+}
+
+func TestLocalLambda_Schedules(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat")
+	require.NoError(t, err)
+	makefile := "ok:\n\techo ok\n\nfail:\n\texit 1\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte(makefile), 0755))
+
+	manifest := fn.Manifest()
+	manifest.Cron = []types.Schedule{
+		{Cron: "@every 1s", Action: "ok"},
+		{Cron: "@every 1s", Action: "fail"},
+		{Cron: "@every 2s", Action: "ok", Disabled: true},
+	}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	status := fn.Schedules()
+	require.Len(t, status, 3)
+	assert.True(t, status[0].Enabled)
+	assert.False(t, status[0].Next.IsZero())
+	assert.Empty(t, status[0].LastResult)
+	assert.False(t, status[2].Enabled)
+	assert.True(t, status[2].Next.IsZero())
+
+	now := time.Now()
+	fn.DoScheduled(context.Background(), scheduler.Window{From: now.Add(-time.Minute), To: now, Expected: now}, nil, application.ScheduleHooks{})
+	status = fn.Schedules()
+	require.Len(t, status, 3)
+	assert.Equal(t, application.ScheduleResultOK, status[0].LastResult)
+	assert.False(t, status[0].LastRun.IsZero())
+	assert.Equal(t, application.ScheduleResultError, status[1].LastResult)
+	assert.NotEmpty(t, status[1].LastError)
+	// disabled schedule is not executed
+	assert.Empty(t, status[2].LastResult)
+}
+
+func TestLocalLambda_ScheduledInvocation(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `cat; echo " $SCHEDULE $REQUEST_ID"`)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "payload.json"), []byte(`{"report":"weekly"}`), 0644))
+
+	manifest := fn.Manifest()
+	manifest.InputHeaders = map[string]string{types.ScheduleHeader: "SCHEDULE"}
+	manifest.Cron = []types.Schedule{
+		{Name: "daily", Cron: "@daily", Payload: `{"report":"daily"}`},
+		{Cron: "@weekly", PayloadFile: "payload.json"},
+		{Cron: "@daily", PayloadFile: "missing.json"},
+	}
+	require.NoError(t, fn.SetManifest(manifest))
 
 	var out bytes.Buffer
-	err := fn.Invoke(timeout, types.Request{
-		Method: method,
-		Path:   path,
-		Body:   io.NopCloser(bytes.NewReader(payload)),
-	}, &out, nil)
-	return out.Bytes(), err
+	require.NoError(t, fn.runScheduled(context.Background(), fire{plan: manifest.Cron[0], id: "cron-1"}, nil, &out, nil))
+	assert.Equal(t, `{"report":"daily"} daily cron-1`+"\n", out.String())
+
+	out.Reset()
+	require.NoError(t, fn.runScheduled(context.Background(), fire{plan: manifest.Cron[1], id: "cron-2"}, nil, &out, nil))
+	assert.Equal(t, `{"report":"weekly"} @weekly cron-2`+"\n", out.String())
+
+	now := time.Now()
+	fn.DoScheduled(context.Background(), scheduler.Window{From: now.Add(-8 * 24 * time.Hour), To: now, Expected: now}, nil, application.ScheduleHooks{})
+	status := fn.Schedules()
+	require.Len(t, status, 3)
+	assert.Equal(t, "daily", status[0].Name)
+	assert.Equal(t, application.ScheduleResultOK, status[0].LastResult)
+	assert.Equal(t, application.ScheduleResultOK, status[1].LastResult)
+	assert.Equal(t, application.ScheduleResultError, status[2].LastResult)
+	assert.Contains(t, status[2].LastError, "open payload")
+}
+
+func TestLocalLambda_ScheduledRetry(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `cat > /dev/null; exit 1`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Cron = []types.Schedule{{Name: "sync", Cron: "@daily", Payload: "ping"}}
+	manifest.Retry = &types.Retry{Attempts: 2, Backoff: types.JsonDuration(time.Millisecond)}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	records := make(chan stats.Record, 2)
+	letters := make(chan application.DeadLetter, 1)
+	var body []byte
+	now := time.Now()
+	fn.DoScheduled(context.Background(), scheduler.Window{From: now.Add(-48 * time.Hour), To: now, Expected: now}, nil, application.ScheduleHooks{
+		Record: func(record stats.Record) {
+			records <- record
+		},
+		Failed: func(letter application.DeadLetter, payload []byte) {
+			body = payload
+			letters <- letter
+		},
+	})
+
+	first, second := <-records, <-records
+	assert.Equal(t, 1, first.Attempt)
+	assert.True(t, first.Retried)
+	assert.False(t, first.Failed())
+	assert.Equal(t, 2, second.Attempt)
+	assert.True(t, second.Failed())
+	assert.Equal(t, "sync", second.Request.Headers[types.ScheduleHeader])
+
+	letter := <-letters
+	assert.Equal(t, "sync", letter.Schedule)
+	assert.Empty(t, letter.Queue)
+	assert.Equal(t, 2, letter.Attempts)
+	assert.Equal(t, http.MethodPost, letter.Request.Method)
+	assert.Equal(t, "ping", string(body))
+	assert.Equal(t, application.ScheduleResultError, fn.Schedules()[0].LastResult)
+}
+
+func TestLocalLambda_CatchUpScheduled(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `cat > /dev/null`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Cron = []types.Schedule{
+		{Name: "all", Cron: "@hourly", Missed: types.MissedRunAll},
+		{Name: "once", Cron: "@hourly", Missed: types.MissedRunOnce},
+		{Name: "skip", Cron: "@hourly"},
+		{Name: "unknown", Cron: "@hourly", Missed: types.MissedRunAll},
+	}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	until := time.Now().Truncate(time.Hour).Add(time.Minute)
+	since := func(key string) time.Time {
+		if key == runKey(manifest.Cron[3]) {
+			return time.Time{}
+		}
+		return until.Add(-3*time.Hour - 30*time.Minute)
+	}
+	records := make(chan stats.Record, 5)
+	fired := map[string]time.Time{}
+	missed := map[string]int{}
+	fn.CatchUpScheduled(context.Background(), until, since, nil, application.ScheduleHooks{
+		Record: func(record stats.Record) {
+			records <- record
+		},
+		Fired: func(key string, at time.Time) {
+			fired[key] = at
+		},
+		Missed: func(schedule types.Schedule, runs []time.Time) {
+			missed[schedule.Name] = len(runs)
+		},
+	})
+	assert.Equal(t, map[string]int{"all": 4, "once": 4, "skip": 4}, missed, "missed runs are reported regardless of policy")
+	assert.Len(t, fired, 2)
+	assert.Equal(t, until, fired[runKey(manifest.Cron[0])])
+	assert.Equal(t, until, fired[runKey(manifest.Cron[1])])
+
+	counts := map[string]int{}
+	for i := 0; i < 5; i++ {
+		record := <-records
+		assert.True(t, record.CatchUp)
+		assert.NotEmpty(t, record.Request.Headers[types.CatchUpHeader])
+		counts[record.Request.Headers[types.ScheduleHeader]]++
+	}
+	assert.Equal(t, map[string]int{"all": 4, "once": 1}, counts)
+}
+
+func TestLocalLambda_DoTimeLimit(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte("slow:\n\tsleep 5\n"), 0755))
+
+	started := time.Now()
+	err = fn.Do(context.Background(), "slow", 200*time.Millisecond, nil, ioutil.Discard)
+	assert.Error(t, err)
+	// nested processes should be killed too
+	assert.Less(t, int64(time.Since(started)), int64(2*time.Second))
+}
+
+func TestLocalLambda_Stderr(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	// only tail is kept
+	fn, err := DummyPublic(d, "sh", "-c", "head -c 5000 /dev/zero | tr '\\0' x >&2; echo done >&2; echo out")
+	require.NoError(t, err)
+	var usage application.Usage
+	var out bytes.Buffer
+	err = fn.Invoke(application.WithUsage(context.Background(), &usage), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, &out, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", out.String())
+	assert.Len(t, usage.Stderr, application.StderrTail)
+	assert.True(t, strings.HasSuffix(string(usage.Stderr), "xxxdone\n"))
+
+	// action output is combined, stderr is also separated
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte("build:\n\t@echo info\n\t@echo oops >&2\n"), 0755))
+	usage = application.Usage{}
+	out.Reset()
+	require.NoError(t, fn.Do(application.WithUsage(context.Background(), &usage), "build", 0, nil, &out))
+	assert.Contains(t, out.String(), "info\n")
+	assert.Contains(t, out.String(), "oops\n")
+	assert.Equal(t, "oops\n", string(usage.Stderr))
+}
+
+func TestLocalLambda_ManifestActions(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte("build:\n\t@echo make\nlint:\n\t@echo lint\n"), 0755))
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"GREETING": "hello"}
+	manifest.Actions = map[string][]types.Step{
+		"build": {
+			{Run: []string{"sh", "-c", "echo \"$GREETING\" > built; echo step1"}},
+			{Run: []string{"sh", "-c", "cat built; echo warn >&2"}},
+		},
+		"fail": {
+			{Run: []string{"sh", "-c", "echo before; exit 3"}},
+			{Run: []string{"touch", "after"}},
+		},
+		"slow": {{Run: []string{"sleep", "5"}, Timeout: types.JsonDuration(200 * time.Millisecond)}},
+	}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	actions, err := fn.Actions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build", "fail", "slow", "lint"}, actions, "actions of manifest override targets of Makefile")
+
+	// steps are run one by one in lambda directory with environment of lambda
+	var usage application.Usage
+	var out bytes.Buffer
+	require.NoError(t, fn.Do(application.WithUsage(context.Background(), &usage), "build", 0, nil, &out))
+	assert.Contains(t, out.String(), "step1\nhello\n")
+	assert.Contains(t, out.String(), "warn\n")
+	assert.Equal(t, "warn\n", string(usage.Stderr))
+
+	out.Reset()
+	require.NoError(t, fn.Do(context.Background(), "lint", 0, nil, &out))
+	assert.Equal(t, "lint\n", out.String())
+
+	// the first failed step fails action with exit code of the step
+	out.Reset()
+	err = fn.Do(context.Background(), "fail", 0, nil, &out)
+	var exitErr *exec.ExitError
+	require.True(t, errors.As(err, &exitErr), err)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Equal(t, "before\n", out.String())
+	assert.NoFileExists(t, filepath.Join(d, "after"))
+
+	started := time.Now()
+	assert.Error(t, fn.Do(context.Background(), "slow", 0, nil, ioutil.Discard))
+	assert.Less(t, int64(time.Since(started)), int64(2*time.Second), "step is limited by timeout")
+
+	manifest.Actions["slow"][0].Timeout = 0
+	require.NoError(t, fn.SetManifest(manifest))
+	started = time.Now()
+	assert.Error(t, fn.Do(context.Background(), "slow", 200*time.Millisecond, nil, ioutil.Discard))
+	assert.Less(t, int64(time.Since(started)), int64(2*time.Second), "action is limited by time limit")
+}
+
+func TestLocalLambda_Logs(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	root := filepath.Join(d, "fn")
+	require.NoError(t, os.Mkdir(root, 0755))
+
+	// runaway output of single invocation is rotated
+	fn, err := DummyPublic(root, "sh", "-c", "head -c 3000000 /dev/zero | tr '\\0' x; echo; echo done >&2; echo out")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Logs = &types.LogFiles{Enabled: true, MaxSizeMB: 1, MaxFiles: 2}
+	require.NoError(t, fn.SetManifest(manifest))
+	var out bytes.Buffer
+	require.NoError(t, fn.Invoke(context.Background(), types.Request{ID: "req-1", Body: ioutil.NopCloser(bytes.NewReader(nil))}, &out, nil))
+	assert.Equal(t, 3000000+len("\nout\n"), out.Len(), "response is not changed")
+
+	files, err := fn.LogFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		assert.LessOrEqual(t, file.Size, int64(1024*1024))
+	}
+	assert.False(t, files[0].Current)
+	assert.True(t, files[1].Current)
+
+	tail, err := fn.TailLog(2)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(tail), "\n"), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Contains(t, line, " req-1 ")
+	}
+
+	var content bytes.Buffer
+	require.NoError(t, fn.ReadLog(files[1].Name, &content))
+	assert.True(t, bytes.HasSuffix(content.Bytes(), tail))
+	// streams are copied concurrently: order of lines of different streams is not defined
+	assert.Contains(t, content.String(), " req-1 stderr done\n")
+	assert.Contains(t, content.String(), " req-1 stdout out\n")
+	assert.ErrorIs(t, fn.ReadLog("../fn/manifest.json", &content), application.ErrLogNotFound)
+	assert.ErrorIs(t, fn.ReadLog("20000101T000000.000000000Z.log", &content), application.ErrLogNotFound)
+
+	// logs are disabled by default
+	manifest.Logs = nil
+	require.NoError(t, fn.SetManifest(manifest))
+	_, err = testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	after, err := fn.LogFiles()
+	require.NoError(t, err)
+	assert.Equal(t, files[1].Size, after[1].Size)
+
+	require.NoError(t, fn.Remove())
+	assert.NoDirExists(t, filepath.Join(d, internal.LogsDir, "fn"), "logs are removed with lambda")
+}
+
+func TestLocalLambda_MaximumResponse(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.MaximumResponse = 5
+	require.NoError(t, fn.SetManifest(manifest))
+
+	out, err := testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(out))
+
+	out, err = testRequest(fn, http.MethodPost, "/", bytes.Repeat([]byte("x"), 1<<20))
+	assert.ErrorIs(t, err, application.ErrResponseTooLarge)
+	assert.ErrorContains(t, err, "response exceeds maximum response (5 bytes)")
+	assert.Equal(t, "xxxxx", string(out))
+
+	// process which ignores closed output is killed
+	manifest.Run = []string{"/bin/sh", "-c", `trap "" PIPE; while true; do echo x; done`}
+	require.NoError(t, fn.SetManifest(manifest))
+	started := time.Now()
+	_, err = testRequest(fn, http.MethodPost, "/", nil)
+	assert.ErrorIs(t, err, application.ErrResponseTooLarge)
+	assert.Less(t, int64(time.Since(started)), int64(500*time.Millisecond))
+}
+
+func TestLocalLambda_ServeStatic(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	require.NoError(t, os.MkdirAll(filepath.Join(d, "public", "css"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(d, "public", "index.html"), []byte("index page"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(d, "public", "css", "app.css"), []byte("body {}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(d, "public", ".env"), []byte("SECRET=1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(d, "private.txt"), []byte("private"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(d, "private.txt"), filepath.Join(d, "public", "escape.txt")))
+
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.StaticDirs = map[string]string{"/assets/": "public", "/": "."}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	read := func(path string) (string, error) {
+		var content string
+		err := fn.ServeStatic(path, func(reader io.ReadSeeker, info fs.FileInfo) error {
+			data, err := ioutil.ReadAll(reader)
+			content = string(data)
+			return err
+		})
+		return content, err
+	}
+
+	content, err := read("/assets/css/app.css")
+	require.NoError(t, err)
+	assert.Equal(t, "body {}", content)
+	content, err = read("/assets")
+	require.NoError(t, err)
+	assert.Equal(t, "index page", content)
+	content, err = read("/private.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "private", content)
+
+	for _, path := range []string{"/assets/.env", "/assets/escape.txt", "/assets/css", "/manifest.json", "/assets/../manifest.json", "/public/.env", "/missing"} {
+		_, err = read(path)
+		assert.ErrorIs(t, err, fs.ErrNotExist, path)
+	}
+
+	manifest.StaticDirs = nil
+	require.NoError(t, fn.SetManifest(manifest))
+	_, err = read("/assets/css/app.css")
+	assert.ErrorIs(t, err, fs.ErrNotExist, "disabled by default")
+}
+
+func TestLocalLambda_SetBundle(t *testing.T) {
+	project, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer removeReadOnly(project)
+	d := filepath.Join(project, "lambda")
+	require.NoError(t, os.MkdirAll(d, 0755))
+
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range map[string]string{
+		"manifest.json":     `{"run": ["/bin/sh", "run.sh"], "static": "static"}`,
+		"run.sh":            "echo -n bundled",
+		"static/index.html": "index page",
+	} {
+		f, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	hash, err := fn.SetBundle(bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+	contentHash, err := fn.ContentHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, contentHash)
+	assert.Equal(t, "static", fn.Manifest().Static)
+
+	content, err := testRequest(fn, http.MethodPost, "/f", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "bundled", string(content))
+
+	content, err = testRequest(fn, http.MethodGet, "/f/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "index page", string(content))
+
+	manifest := fn.Manifest()
+	manifest.StaticDirs = map[string]string{"/": "."}
+	require.NoError(t, fn.SetManifest(manifest))
+	require.NoError(t, fn.ServeStatic("/static/", func(reader io.ReadSeeker, info fs.FileInfo) error {
+		data, err := ioutil.ReadAll(reader)
+		assert.Equal(t, "index page", string(data))
+		return err
+	}))
+	assert.ErrorIs(t, fn.ServeStatic("/manifest.json", nil), fs.ErrNotExist, "manifest is not served from bundle")
+
+	var out bytes.Buffer
+	require.NoError(t, fn.ReadFile("run.sh", &out))
+	assert.Equal(t, "echo -n bundled", out.String())
+	assert.Error(t, fn.WriteFile("run.sh", bytes.NewBufferString("echo")))
+
+	_, err = os.Stat(filepath.Join(project, ".bundles", hash))
+	assert.NoError(t, err, "bundle should be extracted for execution")
+
+	require.NoError(t, CleanBundles(project))
+	_, err = os.Stat(filepath.Join(project, ".bundles", hash+".zip"))
+	assert.NoError(t, err, "used bundle should not be removed")
+}
+
+func TestLocalLambda_SetContentZip(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+
+	data := zipFiles(t, map[string]string{
+		"manifest.json":      `{"name": "zipped", "run": ["cat", "-"]}`,
+		"static\\index.html": "index page",
+		"./a/../run.sh":      "echo",
+	})
+	require.NoError(t, fn.SetContent(bytes.NewReader(data)))
+	assert.Equal(t, "zipped", fn.Manifest().Name)
+	text, err := ioutil.ReadFile(filepath.Join(d, "static", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "index page", string(text), "backslashes are separators")
+	assert.FileExists(t, filepath.Join(d, "run.sh"))
+}
+
+func TestLocalLambda_Replace(t *testing.T) {
+	project, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(project)
+	d := filepath.Join(project, "lambda")
+	require.NoError(t, os.MkdirAll(d, 0755))
+	fn, err := DummyPublic(d, "cat", "old.txt")
+	require.NoError(t, err)
+	require.NoError(t, fn.WriteFile("old.txt", bytes.NewBufferString("old")))
+
+	staged := filepath.Join(project, "staged")
+	require.NoError(t, os.MkdirAll(staged, 0755))
+	manifest := fn.Manifest()
+	manifest.Name = "replaced"
+	manifest.Run = []string{"cat", "new.txt"}
+	require.NoError(t, manifest.SaveAs(filepath.Join(staged, internal.ManifestFile)))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(staged, "new.txt"), []byte("new"), 0644))
+
+	require.NoError(t, fn.Replace(staged))
+	assert.Equal(t, "replaced", fn.Manifest().Name)
+	assert.NoFileExists(t, filepath.Join(d, "old.txt"))
+	assert.NoDirExists(t, staged)
+	var out bytes.Buffer
+	require.NoError(t, fn.Invoke(context.Background(), types.Request{Body: ioutil.NopCloser(&bytes.Buffer{})}, &out, nil))
+	assert.Equal(t, "new", out.String())
+	entries, err := ioutil.ReadDir(project)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "replaced files are removed")
+
+	// invalid replacement keeps files of lambda
+	broken := filepath.Join(project, "broken")
+	require.NoError(t, os.MkdirAll(broken, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(broken, internal.ManifestFile), []byte("{"), 0644))
+	require.Error(t, fn.Replace(broken))
+	assert.Equal(t, "replaced", fn.Manifest().Name)
+	assert.FileExists(t, filepath.Join(d, "new.txt"))
+	assert.FileExists(t, filepath.Join(broken, internal.ManifestFile))
+}
+
+func TestLocalLambda_ManifestYAML(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+
+	data := zipFiles(t, map[string]string{
+		"manifest.yaml": "name: yaml\nrun: [cat, \"-\"]\ntime_limit: 2s\n",
+	})
+	require.NoError(t, fn.SetContent(bytes.NewReader(data)))
+	assert.Equal(t, "yaml", fn.Manifest().Name)
+	assert.Equal(t, types.JsonDuration(2*time.Second), fn.Manifest().TimeLimit)
+	assert.NoFileExists(t, filepath.Join(d, "manifest.json"), "replaced manifest is removed")
+
+	manifest := fn.Manifest()
+	manifest.Name = "updated"
+	require.NoError(t, fn.SetManifest(manifest))
+	var saved types.Manifest
+	require.NoError(t, saved.LoadFrom(filepath.Join(d, "manifest.yaml")), "existing format is kept")
+	assert.Equal(t, "updated", saved.Name)
+
+	require.NoError(t, os.WriteFile(filepath.Join(d, "manifest.json"), []byte(`{"run": ["cat"]}`), 0644))
+	_, err = FromDir(d)
+	assert.ErrorIs(t, err, internal.ErrAmbiguousManifest)
+}
+
+func TestLocalLambda_InputSchema(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+	assert.Nil(t, fn.InputSchema())
+
+	manifest := fn.Manifest()
+	manifest.InputSchema = []byte(`{"type": "object"}`)
+	require.NoError(t, fn.SetManifest(manifest))
+	require.NotNil(t, fn.InputSchema(), "compiled when manifest is applied")
+	assert.NotEmpty(t, fn.InputSchema().Validate([]byte(`[]`)))
+
+	manifest.InputSchema = []byte(`{"type": 1}`)
+	assert.Error(t, fn.SetManifest(manifest))
+	assert.NotNil(t, fn.InputSchema(), "invalid schema is not applied")
+
+	// edited out of API: requests are not validated, problem is reported as warning
+	require.NoError(t, manifest.SaveAs(filepath.Join(d, "manifest.json")))
+	fn, err = FromDir(d)
+	require.NoError(t, err)
+	assert.Nil(t, fn.InputSchema())
+	assert.Contains(t, fn.Warning(), "input schema is not applied")
+}
+
+func TestLocalLambda_SetContentRejected(t *testing.T) {
+	project, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(project)
+	d := filepath.Join(project, "lambda")
+	require.NoError(t, os.MkdirAll(d, 0755))
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+
+	var symlink bytes.Buffer
+	writer := zip.NewWriter(&symlink)
+	header := &zip.FileHeader{Name: "link"}
+	header.SetMode(os.ModeSymlink | 0777)
+	f, err := writer.CreateHeader(header)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("/etc/passwd"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	for name, data := range map[string][]byte{
+		"absolute":      zipFiles(t, map[string]string{"/tmp/evil": "x"}),
+		"drive letter":  zipFiles(t, map[string]string{"C:\\evil": "x"}),
+		"traversal":     zipFiles(t, map[string]string{"..\\evil": "x"}),
+		"symlink":       symlink.Bytes(),
+		"tar traversal": tarGzFile(t, "../evil", "x"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := fn.SetContent(bytes.NewReader(data))
+			assert.Error(t, err)
+			assert.NotErrorIs(t, err, application.ErrUnsupportedArchive)
+			assert.NoFileExists(t, filepath.Join(project, "evil"))
+		})
+	}
+
+	t.Run("symlinked directory", func(t *testing.T) {
+		outside := filepath.Join(project, "outside")
+		require.NoError(t, os.MkdirAll(outside, 0755))
+		require.NoError(t, os.Symlink(outside, filepath.Join(d, "data")))
+		assert.Error(t, fn.SetContent(bytes.NewReader(zipFiles(t, map[string]string{"data/evil": "x"}))))
+		assert.NoFileExists(t, filepath.Join(outside, "evil"))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.ErrorIs(t, fn.SetContent(bytes.NewBufferString("plain text")), application.ErrUnsupportedArchive)
+		assert.ErrorIs(t, fn.SetContent(http.NoBody), application.ErrUnsupportedArchive)
+	})
+}
+
+func TestLocalLambda_SetContentLimits(t *testing.T) {
+	limits := types.UploadLimits{Files: 3, Size: 10, Depth: 3, FileSize: 6}
+	for name, testCase := range map[string]struct {
+		limit   string
+		allowed []byte
+		over    []byte
+		message string
+	}{
+		"files": {
+			limit:   types.LimitFiles,
+			allowed: tarGzFiles(t, "a", "1", "b", "2", "c", "3"),
+			over:    tarGzFiles(t, "a", "1", "b", "2", "c", "3", "over", "4", "over2", "5"),
+			message: "archive has 5 files and directories, maximum is 3 (2 over)",
+		},
+		"zip files": {
+			limit:   types.LimitFiles,
+			allowed: zipFiles(t, map[string]string{"a": "1", "b": "2", "c": "3"}),
+			over:    zipFiles(t, map[string]string{"a": "1", "b": "2", "c": "3", "over": "4"}),
+			message: "archive has 4 files and directories, maximum is 3 (1 over)",
+		},
+		"depth": {
+			limit:   types.LimitDepth,
+			allowed: tarGzFiles(t, "a/b/c", "1"),
+			over:    tarGzFiles(t, "a/b/c/over", "1"),
+			message: "path a/b/c/over has depth 4, maximum is 3 (1 over)",
+		},
+		"file size": {
+			limit:   types.LimitFileSize,
+			allowed: tarGzFiles(t, "a", "123456"),
+			over:    tarGzFiles(t, "over", "1234567"),
+			message: "file over has 7 bytes, maximum is 6 (1 over)",
+		},
+		"size": {
+			limit:   types.LimitSize,
+			allowed: tarGzFiles(t, "a", "12345", "b", "12345"),
+			over:    tarGzFiles(t, "a", "12345", "over", "123456"),
+			message: "extracted files have at least 11 bytes with over, maximum is 10 (1 over)",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, err := ioutil.TempDir("", "")
+			require.NoError(t, err)
+			defer os.RemoveAll(d)
+			fn, err := DummyPublic(d, "cat", "-")
+			require.NoError(t, err)
+			require.NoError(t, fn.SetProfile(types.SecurityProfile{Upload: limits}))
+
+			assert.NoError(t, CheckArchive(bytes.NewReader(testCase.allowed), limits))
+			require.NoError(t, fn.SetContent(bytes.NewReader(testCase.allowed)), "exactly at limit")
+
+			assert.Error(t, CheckArchive(bytes.NewReader(testCase.over), limits))
+			err = fn.SetContent(bytes.NewReader(testCase.over))
+			var limit *types.UploadLimitError
+			require.ErrorAs(t, err, &limit)
+			assert.ErrorIs(t, err, types.ErrUploadLimit)
+			assert.Equal(t, testCase.limit, limit.Limit)
+			assert.Contains(t, err.Error(), testCase.message)
+			assert.Contains(t, err.Error(), ".cgiignore")
+			assert.NoFileExists(t, filepath.Join(d, "over"), "nothing is extracted")
+			assert.NoDirExists(t, filepath.Join(d, "a", "b", "c"), "nothing is extracted")
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		assert.Equal(t, types.UploadLimits{Files: types.DefaultUploadFiles, Size: types.DefaultUploadSize, Depth: types.DefaultUploadDepth, FileSize: types.DefaultUploadSize}, types.UploadLimits{}.Effective())
+		assert.Error(t, CheckArchive(bytes.NewReader(tarGzFiles(t, strings.Repeat("a/", types.DefaultUploadDepth)+"over", "1")), types.UploadLimits{}))
+	})
+}
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range files {
+		f, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return archive.Bytes()
+}
+
+func tarGzFile(t *testing.T, name, content string) []byte {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	writer := tar.NewWriter(gz)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, gz.Close())
+	return archive.Bytes()
+}
+
+// tar.gz archive of files by pairs of name and content
+func tarGzFiles(t *testing.T, files ...string) []byte {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	writer := tar.NewWriter(gz)
+	for i := 0; i+1 < len(files); i += 2 {
+		name, content := files[i], files[i+1]
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := writer.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, gz.Close())
+	return archive.Bytes()
+}
+
+func testRequest(fn application.Invokable, method string, path string, payload []byte) ([]byte, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var out bytes.Buffer
+	err := fn.Invoke(timeout, types.Request{
+		Method: method,
+		Path:   path,
+		Body:   io.NopCloser(bytes.NewReader(payload)),
+	}, &out, nil)
+	return out.Bytes(), err
+}
+
+func TestLocalLambda_Secrets(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	// secrets are read from file or descriptor, environment should not contain them
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `if [ -n "$SECRETS_FD" ]; then eval "cat <&$SECRETS_FD"; elif [ -n "$SECRETS_FILE" ]; then cat "$SECRETS_FILE"; fi; echo; echo "$API_TOKEN|$DB_SECRET|$MODE"`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"DB_SECRET": "db", "MODE": "prod"}
+	require.NoError(t, fn.SetManifest(manifest))
+	globalEnv := map[string]string{"API_TOKEN": "api"}
+
+	invoke := func() string {
+		var out bytes.Buffer
+		err := fn.Invoke(context.Background(), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, &out, globalEnv)
+		require.NoError(t, err)
+		return out.String()
+	}
+
+	assert.Equal(t, "\napi|db|prod\n", invoke())
+	assert.Equal(t, application.SecretsStatus{Delivery: types.SecretsEnv, Keys: []string{"API_TOKEN", "DB_SECRET"}}, fn.Diagnose(globalEnv).Secrets)
+
+	manifest.Secrets = &types.Secrets{Delivery: types.SecretsFD}
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Equal(t, `{"API_TOKEN":"api","DB_SECRET":"db"}`+"\n||prod\n", invoke())
+	assert.Equal(t, application.SecretsStatus{Delivery: types.SecretsFD, Env: types.DefaultSecretsFDEnv, Keys: []string{"API_TOKEN", "DB_SECRET"}}, fn.Diagnose(globalEnv).Secrets)
+
+	manifest.Secrets = &types.Secrets{Delivery: types.SecretsFile, Keys: []string{"DB_SECRET"}}
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Equal(t, `{"DB_SECRET":"db"}`+"\napi||prod\n", invoke())
+
+	// secret file is removed after invocation
+	files, err := filepath.Glob(filepath.Join(secretsDir, "trusted-cgi-secrets-*"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestLocalLambda_EnvironmentReferences(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `echo "$DB_URL|$LITERAL"`)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte("url:\n\t@echo \"$$DB_URL\"\n"), 0755))
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"DB_URL": "pg://app:${DB_SECRET}@${DB_HOST}/app", "DB_HOST": "db", "LITERAL": "$${DB_HOST}"}
+	require.NoError(t, fn.SetManifest(manifest))
+	globalEnv := map[string]string{"DB_SECRET": "pass", "DB_HOST": "global"}
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, &out, globalEnv)
+	require.NoError(t, err)
+	assert.Equal(t, "pg://app:pass@db/app|${DB_HOST}\n", out.String())
+
+	out.Reset()
+	require.NoError(t, fn.Do(context.Background(), "url", 0, globalEnv, &out))
+	assert.Equal(t, "pg://app:pass@db/app\n", out.String())
+}
+
+func TestLocalLambda_MergedEnvironment(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `echo "$PROXY"`)
+	require.NoError(t, err)
+	fn.SetDefaults(types.Runtime{TZ: "UTC"})
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"PROXY": "http://${PROXY_HOST}:3128", "API_KEY": "@secret:api"}
+	require.NoError(t, fn.SetManifest(manifest))
+	globalEnv := map[string]string{"PROXY": "global", "PROXY_HOST": "squid", "TZ": "Europe/Berlin"}
+
+	assert.Equal(t, []application.EnvValue{
+		{Name: "API_KEY", Value: "@secret:api", Source: application.EnvSourceLambda},
+		{Name: "PROXY", Value: "http://squid:3128", Source: application.EnvSourceLambda, Overridden: []string{application.EnvSourceGlobal}},
+		{Name: "PROXY_HOST", Value: "squid", Source: application.EnvSourceGlobal},
+		{Name: "TZ", Value: "Europe/Berlin", Source: application.EnvSourceGlobal, Overridden: []string{application.EnvSourceRuntime}},
+	}, fn.MergedEnvironment(globalEnv))
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, &out, globalEnv)
+	require.NoError(t, err)
+	assert.Equal(t, "http://squid:3128\n", out.String(), "value of lambda is seen by process")
+}
+
+func TestLocalLambda_WorkDir(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `basename "$(pwd)"`)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(d, "app"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte("where:\n\t@basename \"$$(pwd)\"\n"), 0755))
+	manifest := fn.Manifest()
+	manifest.WorkDir = "app"
+	require.NoError(t, fn.SetManifest(manifest))
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "app\n", out.String())
+
+	out.Reset()
+	require.NoError(t, fn.Do(context.Background(), "where", 0, nil, &out))
+	assert.Equal(t, "app\n", out.String())
+
+	require.NoError(t, os.Symlink(os.TempDir(), filepath.Join(d, "outside")))
+	manifest.WorkDir = "outside"
+	require.NoError(t, fn.SetManifest(manifest))
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, nil)
+	assert.ErrorContains(t, err, "out of lambda")
+
+	manifest.WorkDir = "../"
+	require.NoError(t, fn.SetManifest(manifest))
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, nil)
+	assert.ErrorContains(t, err, "not relative path inside lambda")
+}
+
+func TestLocalLambda_ExpandRun(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "${SHELL_BIN:-/bin/sh}", "-c", `echo "$$(basename "$$(pwd)") $$0 $$1"`, "${GREETING}", "--token=${API_TOKEN}")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(d, "app"), 0755))
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"GREETING": "hello ${USER_NAME}", "DIR": "app"}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, map[string]string{"USER_NAME": "reddec"})
+	assert.ErrorContains(t, err, "fork/exec ${SHELL_BIN:-/bin/sh}", "not expanded without opt-in")
+
+	manifest.ExpandRun = true
+	manifest.WorkDir = "${DIR}"
+	require.NoError(t, fn.SetManifest(manifest))
+	out.Reset()
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, map[string]string{"USER_NAME": "reddec"})
+	assert.EqualError(t, err, "expand run[4]: variable API_TOKEN is not set")
+	assert.Empty(t, out.String())
+
+	out.Reset()
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, map[string]string{"USER_NAME": "reddec", "API_TOKEN": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "app hello reddec --token=x\n", out.String())
+	assert.Contains(t, fn.Health(context.Background(), nil), "expand run[4]: variable API_TOKEN is not set")
+
+	// expanded work dir is checked as well
+	manifest.Environment["DIR"] = "../"
+	require.NoError(t, fn.SetManifest(manifest))
+	err = fn.Invoke(context.Background(), types.Request{Body: http.NoBody}, &out, map[string]string{"API_TOKEN": "x"})
+	assert.ErrorContains(t, err, "not relative path inside lambda")
+}
+
+func TestLocalLambda_Usage(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done")
+	require.NoError(t, err)
+	var usage application.Usage
+	err = fn.Invoke(application.WithUsage(context.Background(), &usage), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, ioutil.Discard, nil)
+	require.NoError(t, err)
+	assert.True(t, usage.CPU > 0, "cpu %v", usage.CPU)
+	if runtime.GOOS == "linux" {
+		assert.True(t, usage.MaxRSS > 0)
+	}
+}
+
+func TestLocalLambda_Timing(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", "sleep 0.1; echo hi; sleep 0.1; exit 3")
+	require.NoError(t, err)
+	var usage application.Usage
+	err = fn.Invoke(application.WithUsage(context.Background(), &usage), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, ioutil.Discard, nil)
+	require.Error(t, err)
+	assert.Greater(t, usage.Spawn, time.Duration(0))
+	assert.GreaterOrEqual(t, usage.FirstByte, 100*time.Millisecond)
+	assert.GreaterOrEqual(t, usage.Runtime, 200*time.Millisecond)
+	assert.Less(t, usage.FirstByte, usage.Runtime)
+	assert.Equal(t, 3, usage.ExitCode)
+	assert.Empty(t, usage.Signal)
+
+	// killed by time limit: signal instead of exit code, no output
+	fn, err = DummyPublic(d, "sleep", "5")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.TimeLimit = types.JsonDuration(100 * time.Millisecond)
+	require.NoError(t, fn.SetManifest(manifest))
+	usage = application.Usage{}
+	err = fn.Invoke(application.WithUsage(context.Background(), &usage), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, ioutil.Discard, nil)
+	require.Error(t, err)
+	assert.NotEmpty(t, usage.Signal)
+	assert.Zero(t, usage.ExitCode)
+	assert.Zero(t, usage.FirstByte)
+}
+
+func TestLocalLambda_Query(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `echo "$QUERY_STRING|$PATH_INFO"; cat -`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.QueryToBody = true
+	manifest.MaximumPayload = 64
+	require.NoError(t, fn.SetManifest(manifest))
+
+	invoke := func(url, path, body string) (string, error) {
+		var out bytes.Buffer
+		err := fn.Invoke(context.Background(), types.Request{
+			URL:  url,
+			Path: path,
+			Body: ioutil.NopCloser(bytes.NewBufferString(body)),
+		}, &out, nil)
+		return out.String(), err
+	}
+
+	out, err := invoke("/a/xyz/users/1?user=42&limit=10&tag=a&tag=b", "xyz/users/1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "user=42&limit=10&tag=a&tag=b|/users/1\n"+`{"limit":"10","tag":["a","b"],"user":"42"}`, out)
+
+	out, err = invoke("/a/xyz?user=42", "xyz", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, "user=42|\npayload", out, "non-empty body is passed as-is")
+
+	_, err = invoke("/a/xyz?name="+strings.Repeat("x", 64), "xyz", "")
+	assert.ErrorIs(t, err, application.ErrPayloadTooLarge, "query should respect maximum payload")
+}
+
+func TestLocalLambda_MaximumPayload(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `body=$(cat); printf %s "$body"`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.MaximumPayload = 16
+	require.NoError(t, fn.SetManifest(manifest))
+	invoke := func(body io.Reader) (string, error) {
+		var out bytes.Buffer
+		err := fn.Invoke(context.Background(), types.Request{Body: ioutil.NopCloser(body)}, &out, nil)
+		return out.String(), err
+	}
+
+	out, err := invoke(strings.NewReader(strings.Repeat("x", 16)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 16), out)
+
+	// endless body: process is aborted as soon as the limit is crossed
+	body := &countingReader{}
+	out, err = invoke(body)
+	assert.ErrorIs(t, err, application.ErrPayloadTooLarge)
+	assert.Empty(t, out, "body is not cut silently")
+	assert.Equal(t, int64(17), body.n)
+}
+
+// endless body of "x"
+type countingReader struct {
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	cr.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestLocalLambda_EnvLimits(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `printf %s "$TOKEN"`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.InputHeaders = map[string]string{"X-Token": "TOKEN"}
+	require.NoError(t, fn.SetManifest(manifest))
+	invoke := func(token string) (string, error) {
+		var out bytes.Buffer
+		err := fn.Invoke(context.Background(), types.Request{
+			Headers: map[string]string{"X-Token": token},
+			Body:    http.NoBody,
+		}, &out, nil)
+		return out.String(), err
+	}
+
+	fn.SetDefaults(types.Runtime{EnvLimits: &types.EnvLimits{MaxVariable: 64}})
+	_, err = invoke(strings.Repeat("x", 100))
+	assert.ErrorIs(t, err, application.ErrEnvironmentTooBig)
+	assert.Contains(t, err.Error(), "variable TOKEN from request is 106 bytes, limit is 64 bytes")
+
+	fn.SetDefaults(types.Runtime{EnvLimits: &types.EnvLimits{MaxVariable: 64, Oversized: types.OversizedTruncate}})
+	out, err := invoke(strings.Repeat("x", 100))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 58), out, "truncated to limit with name")
+
+	manifest.Environment = map[string]string{"BIG": strings.Repeat("y", 30000)}
+	require.NoError(t, fn.SetManifest(manifest))
+	fn.SetDefaults(types.Runtime{})
+	size := fn.Diagnose(nil).Env.Size
+	fn.SetDefaults(types.Runtime{EnvLimits: &types.EnvLimits{MaxSize: size + 500}})
+	diag := fn.Diagnose(nil)
+	assert.Equal(t, int64(500), diag.Env.Headroom)
+	assert.Equal(t, "BIG", diag.Env.Largest[0].Name)
+	assert.Equal(t, types.OversizedDeny, diag.Env.Oversized)
+
+	_, err = invoke(strings.Repeat("x", 1000))
+	assert.ErrorIs(t, err, application.ErrEnvironmentTooBig)
+	assert.Contains(t, err.Error(), "largest: BIG (30013 bytes), TOKEN (1015 bytes)")
+}
+
+func TestLocalLambda_EnvKernelLimit(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"CERTIFICATE": strings.Repeat("c", 1000)}
+	require.NoError(t, fn.SetManifest(manifest))
+	fn.start = func(cmd *exec.Cmd) error {
+		return &fs.PathError{Op: "fork/exec", Path: cmd.Path, Err: syscall.E2BIG}
+	}
+
+	_, err = testRequest(fn, http.MethodPost, "", nil)
+	assert.ErrorIs(t, err, application.ErrEnvironmentTooBig)
+	assert.ErrorIs(t, err, syscall.E2BIG)
+	assert.Contains(t, err.Error(), "largest: CERTIFICATE (1021 bytes)")
+}
+
+func TestLocalLambda_TextBusy(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "echo", "started")
+	require.NoError(t, err)
+	var attempts int
+	fn.start = func(cmd *exec.Cmd) error {
+		attempts++
+		if attempts < 3 {
+			return &fs.PathError{Op: "fork/exec", Path: cmd.Path, Err: syscall.ETXTBSY}
+		}
+		return cmd.Start()
+	}
+	out, err := testRequest(fn, http.MethodPost, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "started\n", string(out))
+	assert.Equal(t, 3, attempts)
+
+	fn.start = func(cmd *exec.Cmd) error {
+		return &fs.PathError{Op: "fork/exec", Path: cmd.Path, Err: syscall.ETXTBSY}
+	}
+	_, err = testRequest(fn, http.MethodPost, "", nil)
+	assert.ErrorIs(t, err, syscall.ETXTBSY, "retries are bounded")
+}
+
+func TestLocalLambda_BuildGate(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "sleep", "0.3")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte("build:\n\tsleep 0.3\nlint:\n\ttrue\n"), 0755))
+	manifest := fn.Manifest()
+	manifest.BuildGate = &types.BuildGate{Timeout: types.JsonDuration(100 * time.Millisecond)}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	// build doesn't start while invocation is running longer than timeout
+	invoked := make(chan error, 1)
+	go func() {
+		_, err := testRequest(fn, http.MethodPost, "", nil)
+		invoked <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	err = fn.Do(context.Background(), "build", 0, nil, ioutil.Discard)
+	assert.ErrorContains(t, err, "in-flight invocations are not finished in 100ms")
+	assert.NoError(t, fn.Do(context.Background(), "lint", 0, nil, ioutil.Discard), "other actions are not gated")
+	require.NoError(t, <-invoked)
+
+	// invocation doesn't wait for build longer than timeout
+	built := make(chan error, 1)
+	go func() {
+		built <- fn.Do(context.Background(), "build", 0, nil, ioutil.Discard)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err = testRequest(fn, http.MethodPost, "", nil)
+	assert.ErrorIs(t, err, application.ErrBuilding)
+	require.NoError(t, <-built)
+}
+
+func TestLocalLambda_BuildUnderLoad(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	// build rewrites executable in place with pause in the middle
+	const app = "#!/bin/sh\necho complete\n"
+	const makefile = "build:\n\t@printf '#!/bin/sh\\n' > app\n\t@sleep 0.02\n\t@printf 'echo complete\\n' >> app\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "app"), []byte(app), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "Makefile"), []byte(makefile), 0755))
+	fn, err := DummyPublic(d, "./app")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var invocations int
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				out, err := testRequest(fn, http.MethodPost, "", nil)
+				if !assert.NoError(t, err) || !assert.Equal(t, "complete\n", string(out), "partially written file is executed") {
+					return
+				}
+				lock.Lock()
+				invocations++
+				lock.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, fn.Do(context.Background(), "build", 0, nil, ioutil.Discard))
+	}
+	cancel()
+	wg.Wait()
+	assert.NotZero(t, invocations)
+}
+
+func TestLocalLambda_DeadlineEnv(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `printf "$DEADLINE"`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.DeadlineEnv = "DEADLINE"
+	require.NoError(t, fn.SetManifest(manifest))
+
+	invoke := func() string {
+		var out bytes.Buffer
+		require.NoError(t, fn.Invoke(context.Background(), types.Request{Body: ioutil.NopCloser(bytes.NewReader(nil))}, &out, nil))
+		return out.String()
+	}
+	assert.Empty(t, invoke(), "not set without time limit")
+
+	manifest.TimeLimit = types.JsonDuration(time.Minute)
+	require.NoError(t, fn.SetManifest(manifest))
+	deadline, err := time.Parse(time.RFC3339Nano, invoke())
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+}
+
+func TestLocalLambda_SecurityProfile(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat")
+	require.NoError(t, err)
+	require.NoError(t, fn.SetProfile(types.SecurityProfile{Name: types.ProfileCustom, MaximumResponse: 5, Mandatory: []string{types.RuleMaximumResponse}}))
+	assert.Equal(t, int64(5), fn.Effective().MaximumResponse, "default of profile")
+
+	manifest := fn.Manifest()
+	manifest.MaximumResponse = 10
+	err = fn.SetManifest(manifest)
+	var invalid *types.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "maximum_response", invalid.Fields[0].Field)
+	assert.Contains(t, invalid.Fields[0].Message, "mandatory rule maximum_response of security profile custom")
+	assert.Zero(t, fn.Manifest().MaximumResponse, "manifest is not changed")
+
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello world"))
+	assert.ErrorContains(t, err, "response exceeds maximum response (5 bytes)")
+
+	// content can't be protected from server user
+	require.NoError(t, fn.SetProfile(types.SecurityProfile{Name: types.ProfileCustom, ReadOnly: true}))
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	assert.ErrorContains(t, err, "read-only content requires user to run lambda")
+}
+
+func TestLocalLambda_ReadOnly(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Getuid() != 0 {
+		t.Skip("ownership of files requires root on linux")
+	}
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	require.NoError(t, os.Chmod(d, 0755))
+
+	fn, err := DummyPublic(d, "sh", "-c", "echo changed > data.txt")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "data.txt"), []byte("original"), 0644))
+	require.NoError(t, fn.SetCredentials(&types.Credential{User: 65534, Group: 65534}))
+	require.NoError(t, fn.SetProfile(types.StrictProfile()))
+
+	info, err := os.Stat(filepath.Join(d, "data.txt"))
+	require.NoError(t, err)
+	uid, gid := fileOwner(info)
+	assert.Equal(t, uint32(0), uid)
+	assert.Equal(t, uint32(65534), gid)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), "group could read only")
+
+	_, err = testRequest(fn, http.MethodPost, "/", nil)
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skip("network namespaces are not permitted:", err)
+	}
+	assert.Error(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(d, "data.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+
+	// manifest could relax non-mandatory read-only
+	profile := types.StrictProfile()
+	profile.Mandatory = nil
+	require.NoError(t, fn.SetProfile(profile))
+	manifest := fn.Manifest()
+	writable := false
+	manifest.ReadOnly = &writable
+	require.NoError(t, fn.SetManifest(manifest))
+	info, err = os.Stat(filepath.Join(d, "data.txt"))
+	require.NoError(t, err)
+	uid, _ = fileOwner(info)
+	assert.Equal(t, uint32(65534), uid)
+}
+
+func TestLocalLambda_RunAs(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Getuid() != 0 {
+		t.Skip("switching user requires root on linux")
+	}
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	require.NoError(t, os.Chmod(d, 0755))
+
+	fn, err := DummyPublic(d, "id", "-u")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "data.txt"), []byte("data"), 0644))
+
+	manifest := fn.Manifest()
+	manifest.RunAs = &types.RunAs{User: "nobody"}
+	err = fn.SetManifest(manifest)
+	var invalid *types.ValidationError
+	require.ErrorAs(t, err, &invalid, "account is not allowed")
+	assert.Equal(t, "run_as", invalid.Fields[0].Field)
+	assert.Nil(t, fn.Manifest().RunAs, "manifest is not changed")
+
+	require.NoError(t, fn.SetProfile(types.SecurityProfile{Name: types.ProfileCustom, RunAsUsers: []string{"nobody"}}))
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Equal(t, &types.Credential{User: 65534, Group: 65534}, fn.Credentials())
+
+	info, err := os.Stat(filepath.Join(d, "data.txt"))
+	require.NoError(t, err)
+	uid, _ := fileOwner(info)
+	assert.Equal(t, uint32(65534), uid, "files belong to account")
+
+	out, err := testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "65534", strings.TrimSpace(string(out)))
+
+	// account is not allowed anymore: no fallback to user of server
+	require.NoError(t, fn.SetProfile(types.SecurityProfile{Name: types.ProfileCustom}))
+	assert.Contains(t, fn.Warning(), "user nobody is not allowed")
+	_, err = testRequest(fn, http.MethodPost, "/", nil)
+	assert.ErrorContains(t, err, "run as is not applied")
+}
+
+func TestLocalLambda_DenyNetwork(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Getuid() != 0 {
+		t.Skip("network namespace requires root on linux")
+	}
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat", "/proc/net/dev")
+	require.NoError(t, err)
+	require.NoError(t, fn.SetProfile(types.SecurityProfile{Name: types.ProfileCustom, DenyNetwork: true}))
+
+	out, err := testRequest(fn, http.MethodPost, "/", nil)
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skip("network namespaces are not permitted:", err)
+	}
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.Len(t, lines, 3, "headers and loopback only")
+	assert.Contains(t, lines[2], "lo:")
+}
+
+// worker replies with process ID, number of served requests, query and body. Special bodies: fail - reply with code
+// 3, exit - exit without reply, hang - never reply, slow - reply after delay
+const testWorker = `
+import base64, json, os, sys, time
+
+served = 0
+for line in sys.stdin:
+    request = json.loads(line)
+    served += 1
+    body = base64.b64decode(request['body']).decode()
+    if body == 'exit':
+        sys.exit(1)
+    if body == 'hang':
+        time.sleep(60)
+    if body == 'slow':
+        time.sleep(0.3)
+    output = '%d %d %s %s' % (os.getpid(), served, request['env']['QUERY_STRING'], body)
+    reply = {'id': request['id'], 'output': base64.b64encode(output.encode()).decode(), 'code': 3 if body == 'fail' else 0}
+    print(json.dumps(reply), flush=True)
+`
+
+func TestLocalLambda_Worker(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("worker requires python3")
+	}
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(d, "worker.py"), []byte(testWorker), 0755))
+
+	fn, err := DummyPublic(d, "python3", "worker.py")
+	require.NoError(t, err)
+	defer fn.stopWorkers()
+	manifest := fn.Manifest()
+	manifest.Mode = types.ModeWorker
+	manifest.TimeLimit = types.JsonDuration(5 * time.Second)
+	require.NoError(t, fn.SetManifest(manifest))
+
+	invoke := func(url, body string) ([]string, application.Usage, error) {
+		var out bytes.Buffer
+		var usage application.Usage
+		err := fn.Invoke(application.WithUsage(context.Background(), &usage), types.Request{
+			Method: http.MethodPost,
+			URL:    url,
+			Body:   ioutil.NopCloser(strings.NewReader(body)),
+		}, &out, nil)
+		return strings.Fields(out.String()), usage, err
+	}
+
+	// process is kept between requests
+	first, usage, err := invoke("/?a=1", "hello")
+	require.NoError(t, err)
+	assert.Greater(t, usage.Spawn, time.Duration(0), "worker is started by the first request")
+	require.Len(t, first, 4)
+	assert.Equal(t, []string{"1", "a=1", "hello"}, first[1:])
+	second, usage, err := invoke("/?b=2", "again")
+	require.NoError(t, err)
+	assert.Equal(t, []string{first[0], "2", "b=2", "again"}, second)
+	assert.Zero(t, usage.Spawn, "warm worker is not spawned")
+	assert.Greater(t, usage.Runtime, time.Duration(0))
+	assert.Equal(t, usage.Runtime, usage.FirstByte, "reply is complete output")
+
+	// failed reply is exit code of invocation
+	out, usage, err := invoke("/", "fail")
+	var coded interface{ ExitCode() int }
+	require.ErrorAs(t, err, &coded)
+	assert.Equal(t, 3, coded.ExitCode())
+	assert.Equal(t, []string{first[0], "3", "fail"}, out, "output of failed reply is passed")
+	assert.Equal(t, 3, usage.ExitCode)
+
+	// exited worker is restarted by the next request
+	_, _, err = invoke("/", "exit")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "worker exited")
+	restarted, _, err := invoke("/", "hello")
+	require.NoError(t, err)
+	assert.NotEqual(t, first[0], restarted[0])
+	assert.Equal(t, "1", restarted[1])
+
+	// worker which doesn't reply in time limit is killed
+	manifest.TimeLimit = types.JsonDuration(300 * time.Millisecond)
+	require.NoError(t, fn.SetManifest(manifest))
+	_, _, err = invoke("/", "hang")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "worker does not reply")
+	replaced, _, err := invoke("/", "hello")
+	require.NoError(t, err)
+	assert.NotEqual(t, restarted[0], replaced[0])
+	assert.Equal(t, "1", replaced[1])
+
+	// pool of workers serves concurrent requests
+	manifest.TimeLimit = types.JsonDuration(5 * time.Second)
+	manifest.Workers = 2
+	require.NoError(t, fn.SetManifest(manifest))
+	var wg sync.WaitGroup
+	var pids = make([]string, 2)
+	for i := range pids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, usage, err := invoke("/", "slow")
+			if assert.NoError(t, err) {
+				pids[i] = out[0]
+				assert.Greater(t, usage.Runtime, time.Duration(0))
+				assert.Equal(t, usage.Runtime, usage.FirstByte)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.NotEqual(t, pids[0], pids[1])
+}
+
+func TestLocalLambda_GracePeriod(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	// process handles SIGTERM and cleans up in grace period
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `trap 'echo cleanup; exit 1' TERM; sleep 30 & wait`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.TimeLimit = types.JsonDuration(200 * time.Millisecond)
+	manifest.GracePeriod = types.JsonDuration(5 * time.Second)
+	require.NoError(t, fn.SetManifest(manifest))
+	started := time.Now()
+	out, err := testRequest(fn, http.MethodPost, "/", nil)
+	assert.Error(t, err)
+	assert.Equal(t, "cleanup\n", string(out))
+	assert.Less(t, int64(time.Since(started)), int64(2*time.Second))
+
+	// process group which ignores SIGTERM is killed after grace period
+	manifest.Run = []string{"/bin/sh", "-c", `trap '' TERM; sleep 30 & sleep 30`}
+	manifest.GracePeriod = types.JsonDuration(300 * time.Millisecond)
+	require.NoError(t, fn.SetManifest(manifest))
+	started = time.Now()
+	_, err = testRequest(fn, http.MethodPost, "/", nil)
+	assert.Error(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(started)), int64(500*time.Millisecond))
+	assert.Less(t, int64(time.Since(started)), int64(3*time.Second))
+
+	// the same for client which is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	started = time.Now()
+	err = fn.Invoke(ctx, types.Request{Method: http.MethodPost, URL: "/", Body: ioutil.NopCloser(bytes.NewReader(nil))}, ioutil.Discard, nil)
+	assert.Error(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(started)), int64(500*time.Millisecond))
+	assert.Less(t, int64(time.Since(started)), int64(3*time.Second))
+}
+
+func TestLocalLambda_ResourceLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rlimits are supported only on linux")
+	}
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	// limits are applied right after start: nested commands see them
+	fn, err := DummyPublic(d, "/bin/sh", "-c", "sleep 0.1; ulimit -n; ulimit -d")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Limits = &types.ResourceLimits{OpenFiles: 64, MemoryMB: 512}
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Contains(t, fn.Warning(), "no executor of limits")
+
+	fn.SetBuilder(builds.New())
+	assert.Empty(t, fn.Warning())
+	out, err := testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "64\n524288\n", string(out))
+
+	// CPU requires cgroup
+	manifest.Limits.CPUPercent = 50
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Contains(t, fn.Warning(), "cpu_percent (requires delegated cgroup v2 with cpu controller)")
+}
+
+func TestLocalLambda_PayloadAsFile(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	dir := filepath.Join(d, "fn")
+	require.NoError(t, os.Mkdir(dir, 0755))
+
+	// body is not passed by stdin and not limited by maximum payload
+	fn, err := DummyPublic(dir, "/bin/sh", "-c", `wc -c < "$REQUEST_BODY_FILE"; wc -c; echo "$REQUEST_BODY_FILE"`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.MaximumPayload = 10
+	manifest.PayloadAsFile = true
+	manifest.MaximumFilePayload = 4096
+	require.NoError(t, fn.SetManifest(manifest))
+	out, err := testRequest(fn, http.MethodPost, "/", bytes.Repeat([]byte("x"), 4096))
+	require.NoError(t, err)
+	lines := strings.Fields(string(out))
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"4096", "0"}, lines[:2])
+	assert.Equal(t, fn.scratchDir(), filepath.Dir(lines[2]))
+	assert.NoFileExists(t, lines[2], "removed after exit")
+
+	// disk limit
+	_, err = testRequest(fn, http.MethodPost, "/", bytes.Repeat([]byte("x"), 4097))
+	assert.ErrorIs(t, err, application.ErrPayloadTooLarge)
+
+	// removed after kill by time limit
+	manifest.Run = []string{"/bin/sh", "-c", `echo "$REQUEST_BODY_FILE"; sleep 30`}
+	manifest.TimeLimit = types.JsonDuration(200 * time.Millisecond)
+	require.NoError(t, fn.SetManifest(manifest))
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	assert.Error(t, err)
+	left, err := os.ReadDir(fn.scratchDir())
+	require.NoError(t, err)
+	assert.Empty(t, left)
+
+	// left by crash: cleaned on load
+	require.NoError(t, os.WriteFile(filepath.Join(fn.scratchDir(), "payload-1"), nil, 0600))
+	_, err = FromDir(dir)
+	require.NoError(t, err)
+	assert.NoDirExists(t, fn.scratchDir())
+}
+
+func TestLocalLambda_DecodeMultipart(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	dir := filepath.Join(d, "fn")
+	require.NoError(t, os.Mkdir(dir, 0755))
+
+	fn, err := DummyPublic(dir, "/bin/sh")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Run = []string{"/bin/sh", "-c", `cat; echo; cat ` + fn.scratchDir() + `/form-*/file-* 2>/dev/null || true`}
+	manifest.DecodeMultipart = true
+	manifest.MaximumPayload = 1024
+	require.NoError(t, fn.SetManifest(manifest))
+
+	invoke := func(contentType string, body []byte) ([]byte, error) {
+		var out bytes.Buffer
+		err := fn.Invoke(context.Background(), types.Request{
+			Method:  http.MethodPost,
+			Path:    "/",
+			Headers: map[string]string{"Content-Type": contentType},
+			Body:    io.NopCloser(bytes.NewReader(body)),
+		}, &out, nil)
+		return out.Bytes(), err
+	}
+	form := func(files ...string) (string, []byte) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		require.NoError(t, writer.WriteField("tag", "a"))
+		require.NoError(t, writer.WriteField("tag", "b"))
+		require.NoError(t, writer.WriteField("title", "report"))
+		for i, content := range files {
+			part, err := writer.CreateFormFile(fmt.Sprint("doc", i), fmt.Sprint("report", i, ".txt"))
+			require.NoError(t, err)
+			_, _ = part.Write([]byte(content))
+		}
+		require.NoError(t, writer.Close())
+		return writer.FormDataContentType(), body.Bytes()
+	}
+	assertCleaned := func() {
+		left, err := os.ReadDir(fn.scratchDir())
+		require.NoError(t, err)
+		assert.Empty(t, left)
+	}
+
+	out, err := invoke(form("hello"))
+	require.NoError(t, err)
+	decoded, content, _ := strings.Cut(string(out), "\n")
+	var object struct {
+		Tag   []string       `json:"tag"`
+		Title string         `json:"title"`
+		Doc   types.FormFile `json:"doc0"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(decoded), &object))
+	assert.Equal(t, []string{"a", "b"}, object.Tag)
+	assert.Equal(t, "report", object.Title)
+	assert.Equal(t, "report0.txt", object.Doc.Filename)
+	assert.Equal(t, "application/octet-stream", object.Doc.ContentType)
+	assert.Equal(t, int64(5), object.Doc.Size)
+	assert.Equal(t, fn.scratchDir(), filepath.Dir(filepath.Dir(object.Doc.Path)))
+	assert.Equal(t, "hello", content)
+	assertCleaned()
+
+	// other bodies are passed as is
+	out, err = invoke("application/json", []byte(`{"tag":"a"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"tag\":\"a\"}\n", string(out))
+
+	// limit of one file
+	manifest.MaximumFormFile = 4
+	require.NoError(t, fn.SetManifest(manifest))
+	_, err = invoke(form("hello"))
+	assert.ErrorIs(t, err, application.ErrPayloadTooLarge)
+	assertCleaned()
+
+	// limit of all files
+	manifest.MaximumFormFile = 0
+	manifest.MaximumFilePayload = 8
+	require.NoError(t, fn.SetManifest(manifest))
+	_, err = invoke(form("hello", "world"))
+	if assert.ErrorIs(t, err, application.ErrPayloadTooLarge) {
+		assert.Contains(t, err.Error(), "form files exceed maximum file payload (8 bytes)")
+	}
+	assertCleaned()
+
+	// text fields are limited by maximum payload
+	manifest.MaximumPayload = 8
+	require.NoError(t, fn.SetManifest(manifest))
+	_, err = invoke(form())
+	assert.ErrorIs(t, err, application.ErrPayloadTooLarge)
+
+	// body is cut
+	_, err = invoke("multipart/form-data; boundary=xyz", []byte("--xyz\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nvalue"))
+	assert.ErrorIs(t, err, application.ErrMalformedForm)
+	assertCleaned()
+}
+
+func TestLocalLambda_DecodeForm(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	dir := filepath.Join(d, "fn")
+	require.NoError(t, os.Mkdir(dir, 0755))
+
+	fn, err := DummyPublic(dir, "/bin/sh")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Run = []string{"/bin/sh", "-c", `cat; echo; test -z "$RAW_BODY" || cat "$RAW_BODY"`}
+	manifest.DecodeForm = true
+	manifest.RawFormEnv = "RAW_BODY"
+	manifest.MaximumPayload = 32
+	require.NoError(t, fn.SetManifest(manifest))
+
+	invoke := func(contentType string, body string) (string, error) {
+		var out bytes.Buffer
+		err := fn.Invoke(context.Background(), types.Request{
+			Method:  http.MethodPost,
+			Path:    "/",
+			Headers: map[string]string{"Content-Type": contentType},
+			Body:    io.NopCloser(strings.NewReader(body)),
+		}, &out, nil)
+		return out.String(), err
+	}
+
+	out, err := invoke("application/x-www-form-urlencoded", "tag=a&tag=b&title=hello+world%21")
+	require.NoError(t, err)
+	decoded, raw, _ := strings.Cut(out, "\n")
+	assert.JSONEq(t, `{"tag":["a","b"],"title":"hello world!"}`, decoded)
+	assert.Equal(t, "tag=a&tag=b&title=hello+world%21", raw)
+	left, err := os.ReadDir(fn.scratchDir())
+	require.NoError(t, err)
+	assert.Empty(t, left)
+
+	// decoded form could be bigger than original body
+	out, err = invoke("application/x-www-form-urlencoded", "q="+strings.Repeat("<", 20))
+	require.NoError(t, err)
+	decoded, _, _ = strings.Cut(out, "\n")
+	assert.Greater(t, len(decoded), 32)
+	assert.JSONEq(t, `{"q":"`+strings.Repeat("<", 20)+`"}`, decoded)
+
+	// other bodies are passed as is
+	out, err = invoke("application/json", `{"tag":"a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"tag\":\"a\"}\n", out)
+
+	// original body is limited by maximum payload
+	_, err = invoke("application/x-www-form-urlencoded", "q="+strings.Repeat("x", 32))
+	assert.ErrorIs(t, err, application.ErrPayloadTooLarge)
+
+	_, err = invoke("application/x-www-form-urlencoded", "q=%zz")
+	assert.ErrorIs(t, err, application.ErrMalformedForm)
+
+	// fields of form are mapped by query
+	manifest.Run = []string{"/bin/sh", "-c", `printf %s "$TITLE"`}
+	manifest.Query = map[string]string{"title": "TITLE"}
+	require.NoError(t, fn.SetManifest(manifest))
+	out, err = invoke("application/x-www-form-urlencoded", "title=from+form")
+	require.NoError(t, err)
+	assert.Equal(t, "from form", out)
+}
+
+// runs native command of container run
+type fakeContainers struct {
+	lock    sync.Mutex
+	runs    []application.ContainerRun
+	removed []string
+	pulled  chan string
+}
+
+func (fc *fakeContainers) Command(ctx context.Context, run application.ContainerRun) *exec.Cmd {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.runs = append(fc.runs, run)
+	return exec.CommandContext(ctx, run.Command[0], run.Command[1:]...)
+}
+
+func (fc *fakeContainers) Remove(name string) error {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.removed = append(fc.removed, name)
+	return nil
+}
+
+func (fc *fakeContainers) Pull(ctx context.Context, image string, out io.Writer) error {
+	_, _ = io.WriteString(out, "pulling\r50%\rdownloaded "+image+"\n")
+	fc.pulled <- image
+	if image == "missing" {
+		return errors.New("not found")
+	}
+	return nil
+}
+
+func TestLocalLambda_Container(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "/bin/sh", "-c", `cat; echo " $MODE"; exit 3`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Environment = map[string]string{"MODE": "prod"}
+	manifest.Container = &types.Container{Image: "alpine:3", Mounts: []types.Mount{{Source: "data", Target: "/data", Writable: true}}}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	assert.ErrorIs(t, err, errNoContainers)
+	assert.NotEmpty(t, fn.Health(context.Background(), nil))
+
+	engine := &fakeContainers{pulled: make(chan string, 1)}
+	fn.SetContainers(engine)
+	assert.Equal(t, "alpine:3", <-engine.pulled)
+	require.Eventually(t, func() bool {
+		status := fn.Diagnose(nil).Container
+		return status != nil && status.State == application.ImageReady
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "downloaded alpine:3", fn.Diagnose(nil).Container.Progress)
+
+	out, err := testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	var coded interface{ ExitCode() int }
+	require.ErrorAs(t, err, &coded)
+	assert.Equal(t, 3, coded.ExitCode())
+	assert.Equal(t, "hello prod\n", string(out))
+	require.Len(t, engine.runs, 1)
+	run := engine.runs[0]
+	assert.Equal(t, types.ContainerDir, run.WorkDir)
+	assert.Contains(t, run.Env, "MODE")
+	assert.NotContains(t, run.Env, "PATH", "variables of server are not passed")
+	assert.Equal(t, !*fn.Effective().ReadOnly, run.Container.Mounts[0].Writable, "read-only lambda has read-only mounts")
+	assert.False(t, run.Network)
+	assert.Empty(t, engine.removed, "finished container is removed by engine")
+
+	// failed pull is problem of lambda
+	manifest = fn.Manifest()
+	manifest.Container.Image = "missing"
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Equal(t, "missing", <-engine.pulled)
+	require.Eventually(t, func() bool {
+		return fn.Diagnose(nil).Container.State == application.ImageFailed
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, fn.Health(context.Background(), nil), "pull image missing: not found")
+
+	// native runner is not changed
+	manifest.Container = nil
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Nil(t, fn.Diagnose(nil).Container)
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	require.ErrorAs(t, err, &coded)
+	assert.Len(t, engine.runs, 1)
+}
+
+func TestLocalLambda_DiskQuota(t *testing.T) {
+	d, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(d, "Makefile"), []byte("fill:\n\t@head -c 1100000 /dev/zero > data.bin\n"), 0755))
+	manifest := fn.Manifest()
+	manifest.DiskQuotaMB = 1
+	require.NoError(t, fn.SetManifest(manifest))
+
+	usage, err := fn.DiskUsage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), usage.Quota)
+	assert.Greater(t, usage.Size, int64(0))
+	assert.False(t, usage.Measured.IsZero())
+
+	// upload over quota is not extracted
+	err = fn.SetContent(bytes.NewReader(tarGzFiles(t, "data.bin", strings.Repeat("x", 1100000))))
+	assert.ErrorIs(t, err, application.ErrDiskQuota)
+	_, err = os.Stat(filepath.Join(d, "data.bin"))
+	assert.True(t, os.IsNotExist(err))
+	out, err := testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(out))
+
+	// action which leaves lambda over quota fails, invocations fail till files are removed
+	err = fn.Do(context.Background(), "fill", 0, nil, ioutil.Discard)
+	assert.ErrorIs(t, err, application.ErrDiskQuota)
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	assert.ErrorIs(t, err, application.ErrDiskQuota)
+	usage, err = fn.DiskUsage()
+	require.NoError(t, err)
+	assert.Greater(t, usage.Size, usage.Quota)
+
+	require.NoError(t, fn.RemoveFile("data.bin"))
+	_, err = testRequest(fn, http.MethodPost, "/", []byte("hello"))
+	assert.NoError(t, err)
 }
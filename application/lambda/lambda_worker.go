@@ -0,0 +1,392 @@
+package lambda
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Request to worker: one JSON object per line on stdin of worker
+type workerRequest struct {
+	ID            uint64            `json:"id"` // sequence number of request in worker, the same in reply
+	Method        string            `json:"method"`
+	URL           string            `json:"url"`
+	Path          string            `json:"path"`
+	Query         string            `json:"query"`
+	RemoteAddress string            `json:"remote_address"`
+	Headers       map[string]string `json:"headers"`
+	Env           map[string]string `json:"env"`  // variables of invocation which depends on request (in exec mode - environment)
+	Body          []byte            `json:"body"` // base64
+}
+
+// Reply of worker: one JSON object per line on stdout of worker
+type workerReply struct {
+	ID     uint64 `json:"id"`
+	Output []byte `json:"output"` // base64, the same as output of process in exec mode (with CGI headers if parsed)
+	Code   int    `json:"code"`   // exit code in exec mode: non-zero - failed invocation (see status_map)
+}
+
+// Invocation by worker failed with non-zero code of reply, like exited process
+type exitCodeError int
+
+func (ece exitCodeError) Error() string { return "exit status " + strconv.Itoa(int(ece)) }
+
+func (ece exitCodeError) ExitCode() int { return int(ece) }
+
+// invoke request by worker from pool: the whole body is read (up to maximum payload) and sent as one message, reply
+// is written to output. Worker which is exited, broken protocol or not replied till deadline of context is killed,
+// the next invocation starts new one. Should be called under lock
+func (local *localLambda) invokeWorker(ctx context.Context, request types.Request, payload *limitedReader, output *limitedWriter, workDir string, manifest types.Manifest, globalEnv map[string]string) error {
+	body, err := ioutil.ReadAll(payload)
+	if payload.exceeded {
+		return fmt.Errorf("%w: request exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, payload.limit)
+	}
+	if err != nil {
+		return fmt.Errorf("read request: %w", err)
+	}
+	runtime := local.runtime(globalEnv)
+	environments := local.environment(globalEnv)
+	manifestEnv := local.manifestEnvironment(environments)
+	for k, v := range manifestEnv {
+		environments = append(environments, k+"="+v)
+	}
+	message := workerRequest{
+		Method:        request.Method,
+		URL:           request.URL,
+		Path:          request.Path,
+		Query:         request.Query(),
+		RemoteAddress: request.RemoteAddress,
+		Headers:       request.Headers,
+		Env:           make(map[string]string),
+		Body:          body,
+	}
+	local.requestEnvironment(request, func(name, value string) {
+		message.Env[name] = value
+	})
+	if deadline, ok := ctx.Deadline(); ok && local.manifest.DeadlineEnv != "" {
+		message.Env[local.manifest.DeadlineEnv] = deadline.Format(time.RFC3339Nano)
+	}
+	for k := range manifestEnv {
+		delete(message.Env, k)
+	}
+
+	command := func() *exec.Cmd {
+		// worker outlives invocation: it is stopped by pool
+		cmd := exec.CommandContext(context.Background(), manifest.Run[0], manifest.Run[1:]...)
+		cmd.Dir = workDir
+		cmd.Stderr = os.Stderr
+		if manifest.Logs != nil && manifest.Logs.Enabled {
+			cmd.Stderr = io.MultiWriter(os.Stderr, local.logStream(*manifest.Logs, "worker stderr"))
+		}
+		cmd.Env = environments
+		internal.SetCreds(cmd, local.runner())
+		internal.SetFlags(cmd)
+		internal.SetGracePeriod(cmd, manifest.Grace())
+		local.sandbox(cmd, manifest)
+		internal.SetUmask(cmd, runtime.Umask)
+		return cmd
+	}
+	pool := local.workerPool(workerKey(command(), local.runner()), manifest.PoolSize())
+	var spawn time.Duration // warm worker is not spawned
+	wrk, err := pool.acquire(ctx, func() (*worker, error) {
+		spawning := time.Now()
+		defer func() { spawn = time.Since(spawning) }()
+		return local.startWorker(ctx, command(), manifest, runtime.Env(), globalEnv)
+	})
+	if err != nil {
+		return err
+	}
+	started := time.Now()
+	reply, err := wrk.exchange(ctx, &message)
+	pool.release(wrk, err == nil)
+	if usage := application.UsageFrom(ctx); usage != nil {
+		usage.Spawn = spawn
+		usage.Runtime = time.Since(started)
+		if reply != nil {
+			usage.ExitCode = reply.Code
+			if len(reply.Output) > 0 {
+				usage.FirstByte = usage.Runtime // reply is complete output
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("run failed: %w", err)
+	}
+	if logs := local.invocationLogs(manifest, request.ID); logs != nil {
+		_, _ = logs.stdout.Write(reply.Output)
+		logs.Close()
+	}
+	if _, err := output.Write(reply.Output); output.exceeded {
+		return fmt.Errorf("%w: response exceeds maximum response (%d bytes)", application.ErrResponseTooLarge, output.limit)
+	} else if err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	if reply.Code != 0 {
+		return fmt.Errorf("run failed: %w", exitCodeError(reply.Code))
+	}
+	return nil
+}
+
+// start worker process with secrets. Should be called under lock
+func (local *localLambda) startWorker(ctx context.Context, cmd *exec.Cmd, manifest types.Manifest, limits types.EnvLimits, globalEnv map[string]string) (*worker, error) {
+	secrets, err := local.prepareSecrets(cmd, globalEnv)
+	if err != nil {
+		return nil, fmt.Errorf("prepare secrets: %w", err)
+	}
+	if err := limitEnvironment(cmd, limits, nil); err != nil {
+		secrets.Close()
+		return nil, err
+	}
+	guard, err := local.limit(cmd, manifest)
+	if err != nil {
+		secrets.Close()
+		return nil, fmt.Errorf("apply limits: %w", err)
+	}
+	wrk, err := newWorker(cmd, func(cmd *exec.Cmd) error {
+		if err := local.startProcess(ctx, cmd); err != nil {
+			return err
+		}
+		guard.Started(cmd)
+		return nil
+	}, guard.Finish)
+	if err != nil {
+		_ = guard.Finish(err)
+		secrets.Close()
+		return nil, fmt.Errorf("start worker: %w", explainStart(cmd, err))
+	}
+	secrets.Started()
+	go func() {
+		<-wrk.exited
+		secrets.Close()
+	}()
+	return wrk, nil
+}
+
+// pool of lambda workers (created on first invocation) with the same command and environment, other pool replaces
+// current one. Workers of replaced pool are stopped when they are released
+func (local *localLambda) workerPool(key string, size int) *workerPool {
+	local.workersLock.Lock()
+	defer local.workersLock.Unlock()
+	if local.workers != nil && local.workers.key == key && local.workers.size == size {
+		return local.workers
+	}
+	if local.workers != nil {
+		local.workers.close()
+	}
+	local.workers = newWorkerPool(key, size)
+	return local.workers
+}
+
+// stop workers: the next invocation starts new ones (after change of manifest, content or security options)
+func (local *localLambda) stopWorkers() {
+	local.workersLock.Lock()
+	defer local.workersLock.Unlock()
+	if local.workers != nil {
+		local.workers.close()
+		local.workers = nil
+	}
+}
+
+// identity of worker started by command
+func workerKey(cmd *exec.Cmd, creds *types.Credential) string {
+	var parts = []string{cmd.Path, cmd.Dir}
+	if creds != nil {
+		parts = append(parts, strconv.Itoa(creds.User)+":"+strconv.Itoa(creds.Group))
+	}
+	if cmd.Err != nil {
+		parts = append(parts, cmd.Err.Error())
+	}
+	parts = append(parts, cmd.Args...)
+	parts = append(parts, cmd.Env...)
+	return strings.Join(parts, "\x00")
+}
+
+// Workers started by the same command. Number of workers is limited by size, workers are started on demand
+type workerPool struct {
+	key   string
+	size  int
+	slots chan struct{} // taken by busy workers
+	lock  sync.Mutex
+	idle  []*worker
+	done  bool
+}
+
+func newWorkerPool(key string, size int) *workerPool {
+	return &workerPool{key: key, size: size, slots: make(chan struct{}, size)}
+}
+
+// wait for free slot and take idle alive worker or start new one
+func (wp *workerPool) acquire(ctx context.Context, start func() (*worker, error)) (*worker, error) {
+	select {
+	case wp.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("wait for free worker: %w", ctx.Err())
+	}
+	wp.lock.Lock()
+	for len(wp.idle) > 0 {
+		wrk := wp.idle[len(wp.idle)-1]
+		wp.idle = wp.idle[:len(wp.idle)-1]
+		if wrk.alive() {
+			wp.lock.Unlock()
+			return wrk, nil
+		}
+		wrk.stop()
+	}
+	wp.lock.Unlock()
+	wrk, err := start()
+	if err != nil {
+		<-wp.slots
+		return nil, err
+	}
+	return wrk, nil
+}
+
+// return worker to pool. Unhealthy worker or worker of closed pool is stopped
+func (wp *workerPool) release(wrk *worker, healthy bool) {
+	wp.lock.Lock()
+	if healthy && !wp.done && wrk.alive() {
+		wp.idle = append(wp.idle, wrk)
+	} else {
+		wrk.stop()
+	}
+	wp.lock.Unlock()
+	<-wp.slots
+}
+
+// stop idle workers, busy workers are stopped on release
+func (wp *workerPool) close() {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.done = true
+	for _, wrk := range wp.idle {
+		wrk.stop()
+	}
+	wp.idle = nil
+}
+
+// running worker process
+type worker struct {
+	cmd      *exec.Cmd
+	stdin    *os.File
+	stdout   *os.File
+	reader   *bufio.Reader
+	seq      uint64
+	exited   chan struct{} // closed after exit of process
+	err      error         // result of process (valid after exited)
+	stopOnce sync.Once
+}
+
+// new worker by started command, finish is applied to result of process (ex: release resource limits)
+func newWorker(cmd *exec.Cmd, start func(cmd *exec.Cmd) error, finish func(err error) error) (*worker, error) {
+	// plain pipes instead of StdinPipe and StdoutPipe: start could be retried, stdout is closed by Wait before the
+	// last reply is read
+	input, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, output, err := os.Pipe()
+	if err != nil {
+		_ = input.Close()
+		_ = stdin.Close()
+		return nil, err
+	}
+	cmd.Stdin = input
+	cmd.Stdout = output
+	err = start(cmd)
+	_ = input.Close()
+	_ = output.Close()
+	if err != nil {
+		_ = stdin.Close()
+		_ = stdout.Close()
+		return nil, err
+	}
+	wrk := &worker{cmd: cmd, stdin: stdin, stdout: stdout, reader: bufio.NewReader(stdout), exited: make(chan struct{})}
+	go func() {
+		wrk.err = finish(cmd.Wait())
+		close(wrk.exited)
+	}()
+	return wrk, nil
+}
+
+func (w *worker) alive() bool {
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// send request and read reply. Worker should be stopped if exchange failed: protocol state is unknown
+func (w *worker) exchange(ctx context.Context, request *workerRequest) (*workerReply, error) {
+	w.seq++
+	request.ID = w.seq
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+	type result struct {
+		line []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if _, err := w.stdin.Write(append(data, '\n')); err != nil {
+			done <- result{err: fmt.Errorf("send request to worker: %w", err)}
+			return
+		}
+		line, err := w.reader.ReadBytes('\n')
+		done <- result{line: line, err: err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		w.stop()
+		return nil, fmt.Errorf("worker does not reply: %w", ctx.Err())
+	}
+	if errors.Is(res.err, io.EOF) || (res.err != nil && !w.alive()) {
+		w.stop() // stdout could be closed by alive process
+		<-w.exited
+		return nil, fmt.Errorf("worker exited: %v", w.err)
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	var reply workerReply
+	if err := json.Unmarshal(res.line, &reply); err != nil {
+		return nil, fmt.Errorf("invalid reply of worker: %w", err)
+	}
+	if reply.ID != request.ID {
+		return nil, fmt.Errorf("invalid reply of worker: reply to request %d, expected %d", reply.ID, request.ID)
+	}
+	return &reply, nil
+}
+
+// kill process (with the whole group, see internal.SetFlags) and release pipes
+func (w *worker) stop() {
+	w.stopOnce.Do(func() {
+		if w.alive() {
+			_ = w.cmd.Cancel()
+		}
+		_ = w.stdin.Close()
+		go func() {
+			<-w.exited
+			_ = w.stdout.Close()
+		}()
+	})
+}
@@ -0,0 +1,8 @@
+package lambda
+
+import "os"
+
+// files have no owner by IDs: tests of ownership are skipped on Windows
+func fileOwner(info os.FileInfo) (uid, gid uint32) {
+	return 0, 0
+}
@@ -2,10 +2,15 @@ package lambda
 
 import (
 	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+
+	"github.com/reddec/trusted-cgi/internal"
 )
 
 func tarFiles(dir string, out io.Writer, excludeGlob []string) error {
@@ -22,12 +27,9 @@ func tarFiles(dir string, out io.Writer, excludeGlob []string) error {
 		if rel == "." {
 			return nil
 		}
-		for _, pat := range excludeGlob {
-			if len(pat) != 0 {
-				if ok, _ := filepath.Match(pat, rel); ok {
-					return nil
-				}
-			}
+		rel = filepath.ToSlash(rel)
+		if excluded(rel, excludeGlob) {
+			return nil
 		}
 		header, err := tar.FileInfoHeader(info, "")
 		if err != nil {
@@ -51,50 +53,86 @@ func tarFiles(dir string, out io.Writer, excludeGlob []string) error {
 	})
 }
 
-func untarFiles(src io.Reader, dest string) error {
-	reader := tar.NewReader(src)
-	dirs := make(map[string]bool)
+// hash of relative names and content of files in directory (walk order is lexical, so hash is stable)
+func hashFiles(dir string, excludeGlob []string) (string, error) {
+	hasher := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if excluded(rel, excludeGlob) {
+			return nil
+		}
+		_, _ = fmt.Fprintf(hasher, "%s\x00%v\x00", rel, info.IsDir())
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(hasher, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
 
-	for {
-		header, err := reader.Next()
-		if err == io.EOF {
-			break
-		} else if err != nil {
+// hash of relative names, sizes and modification times of files in directory without reading of content. Manifest
+// files are skipped (applied separately), ignored directories are not walked
+func fingerprintFiles(dir string, excludeGlob []string) (string, error) {
+	hasher := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
 			return err
 		}
-		path := filepath.Join(dest, header.Name)
-		switch header.Typeflag {
-		case tar.TypeDir:
-			err = os.MkdirAll(path, header.FileInfo().Mode())
-			if err != nil {
-				return fmt.Errorf("create dir %s: %w", header.Name, err)
-			}
-			dirs[path] = true
-		case tar.TypeReg:
-			dir := filepath.Dir(path)
-			if !dirs[dir] {
-				err = os.MkdirAll(dir, header.FileInfo().Mode())
-				if err != nil {
-					return fmt.Errorf("create dir %s: %w", header.Name, err)
-				}
-				dirs[path] = true
-			}
-			f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode())
-			if err != nil {
-				return fmt.Errorf("create file %s: %w", header.Name, err)
-			}
-			_, err = io.Copy(f, reader)
-			if err != nil {
-				_ = f.Close()
-				return fmt.Errorf("finish file %s: %w", header.Name, err)
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." || internal.IsManifest(rel) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if excluded(rel, excludeGlob) {
+			if info.IsDir() {
+				return filepath.SkipDir
 			}
-			err = f.Close()
-			if err != nil {
-				return fmt.Errorf("close file %s: %w", header.Name, err)
+			return nil
+		}
+		if info.IsDir() {
+			_, _ = fmt.Fprintf(hasher, "%s\x00/\x00", rel)
+			return nil
+		}
+		_, _ = fmt.Fprintf(hasher, "%s\x00%d\x00%d\x00", rel, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// relative slash-separated name is matched by pattern of ignore file. Patterns are slash-separated as well (separators
+// of Windows are converted), so archives and hashes are the same on any platform
+func excluded(name string, excludeGlob []string) bool {
+	for _, pat := range excludeGlob {
+		if len(pat) != 0 {
+			if ok, _ := path.Match(filepath.ToSlash(pat), name); ok {
+				return true
 			}
-		default:
-			return fmt.Errorf("unsupported type of file %s %v", header.Name, header.Typeflag)
 		}
 	}
-	return nil
+	return false
 }
@@ -0,0 +1,523 @@
+// Package mirror replicates primary server to read-only standby (mirror). State of primary (content and manifests of
+// lambdas, links, policies and global environment) is pulled periodically by the admin API of primary: content of
+// lambda is downloaded only if its hash on primary or on mirror is changed since the last pull. Mirror serves
+// invocations, but rejects mutating API (see server.Server) until it is promoted to primary.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/api/client"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	DefaultInterval = 30 * time.Second // interval between synchronizations if interval is not set
+	notifyTimeLimit = time.Minute      // time limit of notification action
+)
+
+// Synchronization is stopped because mirror is promoted to primary
+var ErrPromoted = errors.New("mirror is promoted to primary")
+
+// Minimal required use-cases features
+type Cases interface {
+	Platform() application.Platform
+	Remove(uid string) error
+}
+
+// Admin API of primary server
+type Primary struct {
+	URL      string // base URL of primary server
+	login    string
+	password string
+	users    *client.UserAPIClient
+	lambdas  *client.LambdaAPIClient
+	project  *client.ProjectAPIClient
+	policies *client.PoliciesAPIClient
+}
+
+// NewPrimary connection to API of primary server by base URL and admin credentials (empty login - admin).
+func NewPrimary(url string, login, password string) *Primary {
+	if login == "" {
+		login = "admin"
+	}
+	endpoint := strings.TrimRight(url, "/") + "/u/"
+	return &Primary{
+		URL:      url,
+		login:    login,
+		password: password,
+		users:    &client.UserAPIClient{BaseURL: endpoint},
+		lambdas:  &client.LambdaAPIClient{BaseURL: endpoint},
+		project:  &client.ProjectAPIClient{BaseURL: endpoint},
+		policies: &client.PoliciesAPIClient{BaseURL: endpoint},
+	}
+}
+
+// persisted state of mirror
+type state struct {
+	Primary  string    `json:"primary"`
+	Promoted time.Time `json:"promoted"`
+}
+
+// hashes of lambda content when it was pulled last time
+type pulled struct {
+	primary string
+	local   string
+}
+
+// New mirror of primary. New lambdas are created in directory by UID. Promotion is saved to state file: promoted
+// mirror doesn't replicate primary after restart until state file is removed.
+func New(cases Cases, policies application.Policies, dir string, primary *Primary, stateFile string) (*Mirror, error) {
+	mirror := &Mirror{
+		Interval:  DefaultInterval,
+		cases:     cases,
+		policies:  policies,
+		dir:       dir,
+		primary:   primary,
+		stateFile: stateFile,
+		synced:    make(map[string]pulled),
+	}
+	var saved state
+	err := internal.ReadJson(stateFile, &saved)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read mirror state: %w", err)
+	}
+	mirror.promoted = saved.Promoted
+	return mirror, nil
+}
+
+// Mirror of primary server
+type Mirror struct {
+	Interval  time.Duration // interval between synchronizations
+	Notify    string        // optional notification action "<lambda UID or link>:<action>" invoked when set of diverged objects changes
+	cases     Cases
+	policies  application.Policies
+	dir       string
+	primary   *Primary
+	stateFile string
+
+	lock     sync.RWMutex // status and promotion
+	promoted time.Time
+	status   application.MirrorStatus
+
+	syncLock sync.Mutex        // single synchronization at time
+	synced   map[string]pulled // lambda UID -> hashes of last pull
+}
+
+// Primary base URL if mirror is not promoted.
+func (mirror *Mirror) Primary() string {
+	mirror.lock.RLock()
+	defer mirror.lock.RUnlock()
+	if !mirror.promoted.IsZero() {
+		return ""
+	}
+	return mirror.primary.URL
+}
+
+// Status of replication.
+func (mirror *Mirror) Status() application.MirrorStatus {
+	mirror.lock.RLock()
+	defer mirror.lock.RUnlock()
+	status := mirror.status
+	status.Enabled = mirror.promoted.IsZero()
+	status.Primary = mirror.primary.URL
+	status.Promoted = mirror.promoted
+	status.Interval = types.JsonDuration(mirror.interval())
+	status.Diverged = append([]application.MirrorDivergence{}, mirror.status.Diverged...)
+	return status
+}
+
+// Promote mirror to primary. Synchronization in progress is stopped (changes already applied are kept). Repeated
+// promotion is allowed and keeps time of the first promotion.
+func (mirror *Mirror) Promote() (application.MirrorStatus, error) {
+	mirror.lock.Lock()
+	if mirror.promoted.IsZero() {
+		promoted := time.Now()
+		err := internal.AtomicWriteJson(mirror.stateFile, &state{Primary: mirror.primary.URL, Promoted: promoted})
+		if err != nil {
+			mirror.lock.Unlock()
+			return mirror.Status(), fmt.Errorf("save mirror state: %w", err)
+		}
+		mirror.promoted = promoted
+		log.Println("mirror of", mirror.primary.URL, "promoted to primary")
+	}
+	mirror.lock.Unlock()
+	// wait for synchronization in progress
+	mirror.syncLock.Lock()
+	mirror.syncLock.Unlock()
+	return mirror.Status(), nil
+}
+
+// Run synchronizations immediately and then by interval till context is done or mirror is promoted.
+func (mirror *Mirror) Run(ctx context.Context) {
+	t := time.NewTicker(mirror.interval())
+	defer t.Stop()
+	for {
+		err := mirror.Sync(ctx)
+		if errors.Is(err, ErrPromoted) {
+			return
+		}
+		if err != nil {
+			log.Println("[ERROR] mirror: synchronize with", mirror.primary.URL+":", err)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sync mirror with primary once. Errors of single objects are not returned, but reported as divergence in status.
+func (mirror *Mirror) Sync(ctx context.Context) error {
+	mirror.syncLock.Lock()
+	defer mirror.syncLock.Unlock()
+	if err := mirror.active(); err != nil {
+		return err
+	}
+	var report syncReport
+	err := mirror.sync(ctx, &report)
+	mirror.finish(ctx, &report, err)
+	return err
+}
+
+func (mirror *Mirror) interval() time.Duration {
+	if mirror.Interval <= 0 {
+		return DefaultInterval
+	}
+	return mirror.Interval
+}
+
+func (mirror *Mirror) active() error {
+	mirror.lock.RLock()
+	defer mirror.lock.RUnlock()
+	if !mirror.promoted.IsZero() {
+		return ErrPromoted
+	}
+	return nil
+}
+
+// result of single synchronization
+type syncReport struct {
+	lambdas  int
+	pulls    uint64
+	diverged []application.MirrorDivergence
+}
+
+func (sr *syncReport) diverge(object string, err error) {
+	sr.diverged = append(sr.diverged, application.MirrorDivergence{Object: object, Reason: err.Error()})
+}
+
+func (mirror *Mirror) sync(ctx context.Context, report *syncReport) error {
+	primary := mirror.primary
+	token, err := primary.users.Login(ctx, primary.login, primary.password)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	definitions, err := primary.project.List(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list lambdas: %w", err)
+	}
+	policies, err := primary.policies.List(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list policies: %w", err)
+	}
+	settings, err := primary.project.Config(ctx, token)
+	if err != nil {
+		return fmt.Errorf("get configuration: %w", err)
+	}
+	report.lambdas = len(definitions)
+	// policies are applied before lambdas are added: new lambda is never served without policy
+	mirror.syncPolicies(policies, report)
+
+	var exists = make(map[string]bool, len(definitions))
+	for _, def := range definitions {
+		if err := mirror.active(); err != nil {
+			return err
+		}
+		exists[def.UID] = true
+		pulledContent, err := mirror.syncLambda(ctx, token, def)
+		if err != nil {
+			delete(mirror.synced, def.UID)
+			report.diverge(def.UID, err)
+		}
+		if pulledContent {
+			report.pulls++
+		}
+	}
+	platform := mirror.cases.Platform()
+	for _, def := range platform.List() {
+		if exists[def.UID] {
+			continue
+		}
+		if err := mirror.active(); err != nil {
+			return err
+		}
+		delete(mirror.synced, def.UID)
+		if err := mirror.cases.Remove(def.UID); err != nil {
+			report.diverge(def.UID, fmt.Errorf("remove lambda: %w", err))
+		}
+	}
+	if err := mirror.active(); err != nil {
+		return err
+	}
+	mirror.syncLinks(definitions, report)
+
+	config := platform.Config()
+	if !sameEnvironment(config.Environment, settings.Environment) {
+		if err := platform.SetConfig(config.WithEnv(settings.Environment)); err != nil {
+			report.diverge("environment", fmt.Errorf("set global environment: %w", err))
+		}
+	}
+	return nil
+}
+
+// pull content of lambda if hash of content is changed on primary or on mirror since the last pull, then apply
+// manifest. Returns true if content was pulled
+func (mirror *Mirror) syncLambda(ctx context.Context, token *api.Token, def application.Definition) (bool, error) {
+	primaryHash, err := mirror.primary.lambdas.ContentHash(ctx, token, def.UID)
+	if err != nil {
+		return false, fmt.Errorf("get hash of content on primary: %w", err)
+	}
+	var fn application.Lambda
+	if local, err := mirror.cases.Platform().FindByUID(def.UID); err == nil {
+		fn = local.Lambda
+	} else {
+		fn, err = mirror.create(def)
+		if err != nil {
+			return false, fmt.Errorf("create lambda: %w", err)
+		}
+	}
+	localHash, err := fn.ContentHash()
+	if err != nil {
+		return false, fmt.Errorf("get hash of content on mirror: %w", err)
+	}
+	var pull = mirror.synced[def.UID] != pulled{primary: primaryHash, local: localHash}
+	if pull {
+		content, err := mirror.primary.lambdas.Download(ctx, token, def.UID)
+		if err != nil {
+			return false, fmt.Errorf("download content: %w", err)
+		}
+		if err := fn.SetContent(bytes.NewReader(content)); err != nil {
+			return false, fmt.Errorf("set content: %w", err)
+		}
+	}
+	if !sameManifest(fn.Manifest(), def.Manifest) {
+		if err := fn.SetManifest(def.Manifest); err != nil {
+			return pull, fmt.Errorf("set manifest: %w", err)
+		}
+	}
+	localHash, err = fn.ContentHash()
+	if err != nil {
+		return pull, fmt.Errorf("get hash of content on mirror: %w", err)
+	}
+	mirror.synced[def.UID] = pulled{primary: primaryHash, local: localHash}
+	if pull {
+		// startup action outlives the synchronization
+		mirror.cases.Platform().Start(context.Background(), fn)
+	}
+	return pull, nil
+}
+
+// create empty lambda with the same UID and manifest as on primary
+func (mirror *Mirror) create(def application.Definition) (application.Lambda, error) {
+	path := filepath.Join(mirror.dir, def.UID)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("create working directory: %w", err)
+	}
+	if err := def.Manifest.SaveAs(filepath.Join(path, internal.ManifestFile)); err != nil {
+		_ = os.RemoveAll(path)
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+	fn, err := lambda.FromDir(path)
+	if err != nil {
+		_ = os.RemoveAll(path)
+		return nil, err
+	}
+	if err := mirror.cases.Platform().Add(def.UID, fn); err != nil {
+		_ = os.RemoveAll(path)
+		return nil, fmt.Errorf("add lambda to platform: %w", err)
+	}
+	return fn, nil
+}
+
+// link aliases to the same lambdas as on primary and remove other links
+func (mirror *Mirror) syncLinks(definitions []application.Definition, report *syncReport) {
+	platform := mirror.cases.Platform()
+	var links = make(map[string]string)
+	for _, def := range definitions {
+		for alias := range def.Aliases {
+			links[alias] = def.UID
+		}
+	}
+	for _, def := range platform.List() {
+		for alias := range def.Aliases {
+			if links[alias] == def.UID {
+				delete(links, alias)
+				continue
+			}
+			if _, err := platform.Unlink(alias); err != nil {
+				report.diverge("link:"+alias, fmt.Errorf("unlink: %w", err))
+			}
+		}
+	}
+	var aliases = make([]string, 0, len(links))
+	for alias := range links {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if _, err := platform.Link(links[alias], alias); err != nil {
+			report.diverge("link:"+alias, fmt.Errorf("link to %s: %w", links[alias], err))
+		}
+	}
+}
+
+// create, update and apply policies as on primary and remove other policies
+func (mirror *Mirror) syncPolicies(policies []application.Policy, report *syncReport) {
+	var applied = make(map[string]bool)
+	var defined = make(map[string]bool)
+	for _, policy := range policies {
+		defined[policy.ID] = true
+		object := "policy:" + policy.ID
+		local, err := mirror.policies.Get(policy.ID)
+		if err != nil {
+			_, err = mirror.policies.Create(policy.ID, policy.Definition)
+		} else if !reflect.DeepEqual(local.Definition, policy.Definition) {
+			err = mirror.policies.Update(policy.ID, policy.Definition)
+		}
+		if err != nil {
+			report.diverge(object, err)
+			continue
+		}
+		for uid := range policy.Lambdas {
+			applied[uid] = true
+			if err := mirror.policies.Apply(uid, policy.ID); err != nil {
+				report.diverge(object, fmt.Errorf("apply to %s: %w", uid, err))
+			}
+		}
+	}
+	for _, local := range mirror.policies.List() {
+		if !defined[local.ID] {
+			if err := mirror.policies.Remove(local.ID); err != nil {
+				report.diverge("policy:"+local.ID, fmt.Errorf("remove: %w", err))
+			}
+			continue
+		}
+		for uid := range local.Lambdas {
+			if applied[uid] {
+				continue
+			}
+			if err := mirror.policies.Clear(uid); err != nil {
+				report.diverge("policy:"+local.ID, fmt.Errorf("clear from %s: %w", uid, err))
+			}
+		}
+	}
+}
+
+// save result of synchronization to status and notify if set of diverged objects is changed
+func (mirror *Mirror) finish(ctx context.Context, report *syncReport, err error) {
+	mirror.lock.Lock()
+	defer mirror.lock.Unlock()
+	status := &mirror.status
+	status.Syncs++
+	if errors.Is(err, ErrPromoted) {
+		return
+	}
+	if err != nil {
+		status.Failures++
+		status.LastError = err.Error()
+		return
+	}
+	previous := objects(status.Diverged)
+	status.LastError = ""
+	status.LastSync = time.Now()
+	status.Lambdas = report.lambdas
+	status.Pulls += report.pulls
+	status.Diverged = report.diverged
+	if current := objects(report.diverged); current != previous {
+		if current != "" {
+			log.Println("[WARN] mirror: diverged from", mirror.primary.URL+":", current)
+		} else {
+			log.Println("mirror: consistent with", mirror.primary.URL)
+		}
+		mirror.notify(ctx, report.diverged)
+	}
+}
+
+// invoke notification action in background
+func (mirror *Mirror) notify(ctx context.Context, diverged []application.MirrorDivergence) {
+	if mirror.Notify == "" {
+		return
+	}
+	ref, action, _ := strings.Cut(mirror.Notify, ":")
+	platform := mirror.cases.Platform()
+	def, err := platform.FindByUID(ref)
+	if err != nil {
+		def, err = platform.FindByLink(ref)
+	}
+	if err != nil {
+		log.Println("[ERROR] mirror: notification lambda", ref, "not found:", err)
+		return
+	}
+	var env = make(map[string]string)
+	for k, v := range platform.Config().Environment {
+		env[k] = v
+	}
+	var reasons = make([]string, 0, len(diverged))
+	for _, item := range diverged {
+		reasons = append(reasons, item.Object+": "+item.Reason)
+	}
+	env["MIRROR_PRIMARY"] = mirror.primary.URL
+	env["MIRROR_STATE"] = "consistent"
+	if len(diverged) > 0 {
+		env["MIRROR_STATE"] = "diverged"
+	}
+	env["MIRROR_DIVERGED"] = objects(diverged)
+	env["MIRROR_REASON"] = strings.Join(reasons, "; ")
+	fn := def.Lambda
+	go func() {
+		err := fn.Do(ctx, action, notifyTimeLimit, env, ioutil.Discard)
+		if err != nil {
+			log.Println("[ERROR] mirror: notify by action", action, "of lambda", ref+":", err)
+		}
+	}()
+}
+
+// sorted comma-separated list of diverged objects
+func objects(diverged []application.MirrorDivergence) string {
+	var list = make([]string, 0, len(diverged))
+	for _, item := range diverged {
+		list = append(list, item.Object)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func sameEnvironment(a, b map[string]string) bool {
+	return (len(a) == 0 && len(b) == 0) || reflect.DeepEqual(a, b)
+}
+
+// manifests are compared in serialized form: nil and empty collections are the same
+func sameManifest(a, b types.Manifest) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
@@ -0,0 +1,133 @@
+package mirror_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/mirror"
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestMirror_Sync(t *testing.T) {
+	ctx := context.Background()
+	primary := testutil.Instance(t)
+	api := httptest.NewServer(primary.Handler())
+	defer api.Close()
+	standby := testutil.Instance(t)
+
+	srv := primary.Server()
+	uid, err := srv.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Name: "echo", Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	_, err = srv.Platform.Link(uid, "echo")
+	require.NoError(t, err)
+	restricted, err := srv.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	_, err = srv.Policies.Create("private", application.PolicyDefinition{Tokens: map[string]string{"secret": "test"}})
+	require.NoError(t, err)
+	require.NoError(t, srv.Policies.Apply(restricted, "private"))
+	require.NoError(t, srv.Platform.SetConfig(srv.Platform.Config().WithEnv(map[string]string{"GREETING": "hi"})))
+
+	stateFile := filepath.Join(standby.Location, ".mirror.json")
+	replica, err := mirror.New(standby.Server().Cases, standby.Server().Policies, standby.Location, mirror.NewPrimary(api.URL, "admin", "admin"), stateFile)
+	require.NoError(t, err)
+	standby.Server().Mirror = replica
+	handler := standby.Handler()
+
+	require.NoError(t, replica.Sync(ctx))
+	status := replica.Status()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, 2, status.Lambdas)
+	assert.Equal(t, uint64(2), status.Pulls)
+	assert.Empty(t, status.Diverged)
+	assert.False(t, status.LastSync.IsZero())
+
+	rec := testutil.Call(handler, http.MethodPost, "/l/echo", "hello")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello", rec.Body.String())
+	rec = testutil.Call(handler, http.MethodPost, "/a/"+restricted, "hello")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "hi", standby.Server().Platform.Config().Environment["GREETING"])
+
+	t.Run("unchanged lambdas are not pulled", func(t *testing.T) {
+		require.NoError(t, replica.Sync(ctx))
+		assert.Equal(t, uint64(2), replica.Status().Pulls)
+	})
+
+	t.Run("changes of primary are pulled", func(t *testing.T) {
+		def, err := srv.Platform.FindByUID(uid)
+		require.NoError(t, err)
+		require.NoError(t, def.Lambda.WriteFile("data.txt", bytes.NewBufferString("v2")))
+		_, err = srv.Platform.Unlink("echo")
+		require.NoError(t, err)
+		_, err = srv.Platform.Link(uid, "echo2")
+		require.NoError(t, err)
+		require.NoError(t, srv.Cases.Remove(restricted))
+
+		require.NoError(t, replica.Sync(ctx))
+		assert.Equal(t, uint64(3), replica.Status().Pulls)
+		assert.Equal(t, 1, replica.Status().Lambdas)
+		local, err := standby.Server().Platform.FindByUID(uid)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		require.NoError(t, local.Lambda.ReadFile("data.txt", &out))
+		assert.Equal(t, "v2", out.String())
+		assert.True(t, local.Aliases.Has("echo2"))
+		assert.False(t, local.Aliases.Has("echo"))
+		_, err = standby.Server().Platform.FindByUID(restricted)
+		assert.Error(t, err)
+		assert.Empty(t, standby.Server().Policies.List()[0].Lambdas)
+	})
+
+	t.Run("changes of mirror are reverted", func(t *testing.T) {
+		local, err := standby.Server().Platform.FindByUID(uid)
+		require.NoError(t, err)
+		require.NoError(t, local.Lambda.WriteFile("data.txt", bytes.NewBufferString("local")))
+
+		require.NoError(t, replica.Sync(ctx))
+		assert.Equal(t, uint64(4), replica.Status().Pulls)
+		var out bytes.Buffer
+		require.NoError(t, local.Lambda.ReadFile("data.txt", &out))
+		assert.Equal(t, "v2", out.String())
+	})
+
+	t.Run("unavailable primary", func(t *testing.T) {
+		broken, err := mirror.New(standby.Server().Cases, standby.Server().Policies, standby.Location, mirror.NewPrimary(api.URL, "admin", "wrong"), stateFile)
+		require.NoError(t, err)
+		assert.Error(t, broken.Sync(ctx))
+		status := broken.Status()
+		assert.Equal(t, uint64(1), status.Failures)
+		assert.NotEmpty(t, status.LastError)
+	})
+
+	t.Run("read-only API", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/u/", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"ProjectAPI.Create","params":["token"]}`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, api.URL, rec.Header().Get("X-Primary"))
+	})
+
+	t.Run("promotion", func(t *testing.T) {
+		status, err := replica.Promote()
+		require.NoError(t, err)
+		assert.False(t, status.Enabled)
+		assert.False(t, status.Promoted.IsZero())
+		assert.Empty(t, replica.Primary())
+		assert.ErrorIs(t, replica.Sync(ctx), mirror.ErrPromoted)
+
+		restarted, err := mirror.New(standby.Server().Cases, standby.Server().Policies, standby.Location, mirror.NewPrimary(api.URL, "admin", "admin"), stateFile)
+		require.NoError(t, err)
+		assert.Empty(t, restarted.Primary())
+		assert.Equal(t, status.Promoted.Unix(), restarted.Status().Promoted.Unix())
+	})
+}
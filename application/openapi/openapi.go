@@ -0,0 +1,310 @@
+// Package openapi generates OpenAPI 3.1 document of public routes of lambdas by their manifests.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Version of OpenAPI specification of generated documents
+const Version = "3.1.0"
+
+// Methods of operations of lambdas without restriction of methods
+var anyMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Names of security schemes
+const (
+	schemeBasic  = "basic"
+	schemeBearer = "bearer"
+)
+
+// Document is OpenAPI document (only objects used by generator)
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag of operations of lambda
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operations of route by methods
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter of operation by query or header
+type Parameter struct {
+	Name   string          `json:"name"`
+	In     string          `json:"in"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type RequestBody struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Options of document
+type Options struct {
+	Title     string // title of document (empty - trusted-cgi)
+	Version   string // version of document (empty - dev)
+	ServerURL string // public base URL of server (empty - servers are not set, paths are relative to document)
+}
+
+// Generate document of routes by UID, slug and aliases of lambdas. Operations of alias follow policy of alias (see
+// types.Manifest.ForAlias). Lambdas without restriction of methods have operations of common methods. Sub-paths of
+// routes (served by the same lambda) and routes of queues are not included
+func Generate(defs []application.Definition, options Options) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: options.Title, Version: options.Version},
+		Paths:   make(map[string]PathItem),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "trusted-cgi"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "dev"
+	}
+	if options.ServerURL != "" {
+		doc.Servers = []Server{{URL: strings.TrimSuffix(options.ServerURL, "/")}}
+	}
+	var schemes = make(map[string]SecurityScheme)
+	var tags = make(map[string]bool)
+	for _, def := range defs {
+		manifest := def.Lambda.Effective()
+		tag := tagName(def.UID, manifest.Name, tags)
+		tags[tag] = true
+		doc.Tags = append(doc.Tags, Tag{Name: tag, Description: manifest.Description})
+		for _, route := range application.RoutesOf(def, nil).Routes {
+			alias := ""
+			if route.Kind != application.RouteUID {
+				alias = route.Name
+			}
+			doc.Paths[route.Path] = pathItem(route.Path, manifest.ForAlias(alias), alias, tag, schemes)
+		}
+	}
+	if len(schemes) > 0 {
+		doc.Components = &Components{SecuritySchemes: schemes}
+	}
+	return doc
+}
+
+// unique name of tag of lambda: name, name with UID for duplicated names, UID for lambda without name
+func tagName(uid, name string, used map[string]bool) string {
+	switch {
+	case name == "":
+		return uid
+	case used[name]:
+		return name + " (" + uid + ")"
+	default:
+		return name
+	}
+}
+
+func pathItem(path string, manifest types.Manifest, alias, tag string, schemes map[string]SecurityScheme) PathItem {
+	methods := manifest.AllowedMethods()
+	if len(methods) == 0 {
+		methods = anyMethods
+	}
+	var item PathItem
+	for _, method := range methods {
+		var target **Operation
+		switch method {
+		case http.MethodGet:
+			target = &item.Get
+		case http.MethodPut:
+			target = &item.Put
+		case http.MethodPost:
+			target = &item.Post
+		case http.MethodDelete:
+			target = &item.Delete
+		case http.MethodPatch:
+			target = &item.Patch
+		default:
+			// HEAD and OPTIONS are implied, other methods have no operation object
+			continue
+		}
+		*target = operation(path, method, manifest, alias, tag, schemes)
+	}
+	return item
+}
+
+func operation(path, method string, manifest types.Manifest, alias, tag string, schemes map[string]SecurityScheme) *Operation {
+	op := &Operation{
+		OperationID: strings.ToLower(method) + strings.ReplaceAll(path, "/", "-"),
+		Summary:     manifest.Name,
+		Description: manifest.Description,
+		Tags:        []string{tag},
+		Parameters:  parameters(manifest),
+		Responses:   responses(manifest),
+	}
+	if method != http.MethodGet && method != http.MethodDelete {
+		op.RequestBody = requestBody(manifest)
+	}
+	if manifest.BasicAuth != nil && manifest.BasicAuth.Protects(alias) {
+		schemes[schemeBasic] = SecurityScheme{Type: "http", Scheme: "basic"}
+		op.Security = []map[string][]string{{schemeBasic: {}}}
+		op.Responses["401"] = Response{Description: "missing or invalid credentials"}
+	} else if manifest.JWT != nil && manifest.JWT.Protects(alias) {
+		schemes[schemeBearer] = SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+		op.Security = []map[string][]string{{schemeBearer: {}}}
+		op.Responses["401"] = Response{Description: "missing or invalid token"}
+	}
+	return op
+}
+
+// parameters mapped to environment: query (and form) parameters and request headers
+func parameters(manifest types.Manifest) []Parameter {
+	var ans []Parameter
+	for _, name := range sortedKeys(manifest.Query) {
+		ans = append(ans, Parameter{Name: name, In: "query", Schema: stringSchema})
+	}
+	for _, name := range sortedKeys(manifest.InputHeaders) {
+		ans = append(ans, Parameter{Name: name, In: "header", Schema: stringSchema})
+	}
+	return ans
+}
+
+// body with accepted content types (any if not restricted), input schema is schema of JSON content types
+func requestBody(manifest types.Manifest) *RequestBody {
+	contentTypes := manifest.AcceptedContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = []string{"*/*"}
+		if len(manifest.InputSchema) > 0 {
+			contentTypes = append(contentTypes, "application/json")
+		}
+	}
+	body := &RequestBody{Content: make(map[string]MediaType, len(contentTypes))}
+	for _, contentType := range contentTypes {
+		var media MediaType
+		if len(manifest.InputSchema) > 0 && types.IsJSON(contentType) {
+			media.Schema = manifest.InputSchema
+		}
+		body.Content[contentType] = media
+	}
+	return body
+}
+
+// output of lambda by output headers (Content-Type is content of response) and statuses of rejected requests
+func responses(manifest types.Manifest) map[string]Response {
+	output := Response{Description: "output of lambda"}
+	contentType := "*/*"
+	if manifest.Streaming == types.StreamingSSE {
+		contentType = "text/event-stream"
+	}
+	for _, name := range sortedKeys(manifest.OutputHeaders) {
+		value := manifest.OutputHeaders[name]
+		if http.CanonicalHeaderKey(name) == "Content-Type" {
+			contentType = value
+			continue
+		}
+		if output.Headers == nil {
+			output.Headers = make(map[string]Header)
+		}
+		output.Headers[name] = Header{Schema: constSchema(value)}
+	}
+	output.Content = map[string]MediaType{contentType: {}}
+
+	ans := map[string]Response{"200": output}
+	for _, code := range manifest.StatusMap {
+		status := strconv.Itoa(code)
+		if _, ok := ans[status]; !ok {
+			ans[status] = Response{Description: http.StatusText(code) + " (by exit code of lambda)", Content: output.Content}
+		}
+	}
+	if manifest.PayloadLimit() > 0 {
+		ans["413"] = Response{Description: fmt.Sprintf("request body is bigger than %d bytes", manifest.PayloadLimit())}
+	}
+	if len(manifest.AcceptedContentTypes) > 0 {
+		ans["415"] = Response{Description: "content type of request body is not accepted"}
+	}
+	if len(manifest.InputSchema) > 0 {
+		ans["422"] = Response{Description: "JSON body does not match input schema", Content: map[string]MediaType{"application/json": {Schema: problemsSchema}}}
+	}
+	if manifest.RateLimit != nil || manifest.OverflowPolicy == types.OverflowReject {
+		ans["429"] = Response{Description: "too many requests"}
+	}
+	return ans
+}
+
+var (
+	stringSchema   = json.RawMessage(`{"type":"string"}`)
+	problemsSchema = json.RawMessage(`{"type":"array","items":{"type":"object","properties":{"path":{"type":"string"},"message":{"type":"string"}},"required":["path","message"]}}`)
+)
+
+func constSchema(value string) json.RawMessage {
+	data, _ := json.Marshal(map[string]string{"type": "string", "const": value})
+	return data
+}
+
+func sortedKeys(values map[string]string) []string {
+	var ans = make([]string, 0, len(values))
+	for key := range values {
+		ans = append(ans, key)
+	}
+	sort.Strings(ans)
+	return ans
+}
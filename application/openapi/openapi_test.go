@@ -0,0 +1,162 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/application/openapi"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// lambda with manifest modified by fn
+func testLambda(t *testing.T, uid string, aliases types.JsonStringSet, fn func(mf *types.Manifest)) application.Definition {
+	local, err := lambda.DummyPublic(t.TempDir(), "cat", "-")
+	require.NoError(t, err)
+	manifest := local.Manifest()
+	fn(&manifest)
+	require.NoError(t, local.SetManifest(manifest))
+	return application.Definition{UID: uid, Aliases: aliases, Manifest: manifest, Lambda: local}
+}
+
+func TestGenerate(t *testing.T) {
+	users := map[string]string{"alice": "$2a$10$Wc5p1aFYwynzcIOrXgcGi.JQdccyMHcLJH9/HOaQ202YEIHdLexgK"}
+	defs := []application.Definition{
+		testLambda(t, "a1", types.JsonStringSet{"orders": true, "public-orders": true}, func(mf *types.Manifest) {
+			mf.Name = "Orders"
+			mf.Description = "Create orders"
+			mf.Methods = []string{"POST"}
+			mf.AcceptedContentTypes = []string{"application/json", "text/*"}
+			mf.InputSchema = json.RawMessage(`{"type":"object","required":["item"],"properties":{"item":{"type":"string"}}}`)
+			mf.OutputHeaders = map[string]string{"Content-Type": "application/json", "X-Service": "orders"}
+			mf.StatusMap = map[int]int{3: 409}
+			mf.Query = map[string]string{"dry": "DRY_RUN"}
+			mf.BasicAuth = &types.BasicAuth{Users: users}
+			mf.AliasPolicies = map[string]*types.AliasPolicy{"public-orders": {Methods: []string{"GET"}, Unset: []string{types.AliasSettingBasicAuth}}}
+		}),
+		testLambda(t, "b2", nil, func(mf *types.Manifest) {
+			mf.Name = "Orders"
+			mf.Streaming = types.StreamingSSE
+		}),
+	}
+	doc := openapi.Generate(defs, openapi.Options{Version: "1.2.3", ServerURL: "https://cgi.example.com/"})
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	validate(t, data)
+
+	assert.Equal(t, []openapi.Server{{URL: "https://cgi.example.com"}}, doc.Servers)
+	assert.Equal(t, openapi.Info{Title: "trusted-cgi", Version: "1.2.3"}, doc.Info)
+	assert.Equal(t, []openapi.Tag{{Name: "Orders", Description: "Create orders"}, {Name: "Orders (b2)"}}, doc.Tags)
+	assert.Len(t, doc.Paths, 4)
+
+	byUID := doc.Paths["/a/a1"]
+	require.NotNil(t, byUID.Post)
+	assert.Nil(t, byUID.Get)
+	post := byUID.Post
+	assert.Equal(t, "post-a-a1", post.OperationID)
+	assert.Equal(t, "Orders", post.Summary)
+	assert.Equal(t, "Create orders", post.Description)
+	assert.Equal(t, []openapi.Parameter{{Name: "dry", In: "query", Schema: json.RawMessage(`{"type":"string"}`)}}, post.Parameters)
+	require.NotNil(t, post.RequestBody)
+	assert.JSONEq(t, string(defs[0].Manifest.InputSchema), string(post.RequestBody.Content["application/json"].Schema))
+	assert.Empty(t, post.RequestBody.Content["text/*"].Schema, "schema applies only to JSON")
+	assert.Contains(t, post.Responses["200"].Content, "application/json")
+	assert.JSONEq(t, `{"type":"string","const":"orders"}`, string(post.Responses["200"].Headers["X-Service"].Schema))
+	assert.NotContains(t, post.Responses["200"].Headers, "Content-Type")
+	for _, status := range []string{"401", "409", "415", "422"} {
+		assert.Contains(t, post.Responses, status)
+	}
+	assert.Equal(t, []map[string][]string{{"basic": {}}}, post.Security)
+	assert.NotNil(t, doc.Paths["/l/orders"].Post)
+
+	public := doc.Paths["/l/public-orders"]
+	assert.Nil(t, public.Post, "methods of alias policy")
+	require.NotNil(t, public.Get)
+	assert.Nil(t, public.Get.RequestBody)
+	assert.Empty(t, public.Get.Security, "basic auth is unset by alias policy")
+	assert.NotContains(t, public.Get.Responses, "401")
+
+	stream := doc.Paths["/a/b2"]
+	for _, op := range []*openapi.Operation{stream.Get, stream.Post, stream.Put, stream.Patch, stream.Delete} {
+		require.NotNil(t, op, "any method")
+		assert.Contains(t, op.Responses["200"].Content, "text/event-stream")
+		assert.Equal(t, []string{"Orders (b2)"}, op.Tags)
+	}
+	assert.NotNil(t, stream.Put.RequestBody)
+	assert.Contains(t, stream.Put.RequestBody.Content, "*/*")
+
+	single := openapi.Generate(defs[1:], openapi.Options{Title: "Stream"})
+	data, err = json.Marshal(single)
+	require.NoError(t, err)
+	validate(t, data)
+	assert.Empty(t, single.Servers)
+	assert.Nil(t, single.Components)
+	assert.Equal(t, "Stream", single.Info.Title)
+	assert.Equal(t, "dev", single.Info.Version)
+	assert.Len(t, single.Paths, 1)
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := jsonschema.Compile("testdata/schema.json")
+	require.NoError(t, err)
+	for _, invalid := range []string{
+		`{"openapi":"3.0.3","info":{"title":"x","version":"1"},"paths":{}}`,
+		`{"openapi":"3.1.0","info":{"title":"x"},"paths":{}}`,
+		`{"openapi":"3.1.0","info":{"title":"x","version":"1"},"paths":{"/a":{"get":{"responses":{}}}}}`,
+		`{"openapi":"3.1.0","info":{"title":"x","version":"1"},"paths":{"/a":{"get":{"responses":{"200":{}}}}}}`,
+		`{"openapi":"3.1.0","info":{"title":"x","version":"1"},"paths":{"/a":{"get":{"handler":"x","responses":{"200":{"description":"ok"}}}}}}`,
+	} {
+		var doc interface{}
+		require.NoError(t, json.Unmarshal([]byte(invalid), &doc))
+		assert.Error(t, schema.Validate(doc), invalid)
+	}
+}
+
+// validate document by JSON Schema of OpenAPI 3.1 documents, schemas in document are validated as JSON Schemas
+func validate(t *testing.T, data []byte) {
+	t.Helper()
+	schema, err := jsonschema.Compile("testdata/schema.json")
+	require.NoError(t, err)
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.NoError(t, schema.Validate(doc))
+
+	var parsed openapi.Document
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	for path, item := range parsed.Paths {
+		for _, op := range []*openapi.Operation{item.Get, item.Put, item.Post, item.Delete, item.Patch} {
+			if op == nil {
+				continue
+			}
+			var schemas []json.RawMessage
+			for _, param := range op.Parameters {
+				schemas = append(schemas, param.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					schemas = append(schemas, media.Schema)
+				}
+			}
+			for _, response := range op.Responses {
+				for _, media := range response.Content {
+					schemas = append(schemas, media.Schema)
+				}
+				for _, header := range response.Headers {
+					schemas = append(schemas, header.Schema)
+				}
+			}
+			for _, raw := range schemas {
+				if len(raw) == 0 {
+					continue
+				}
+				_, err := jsonschema.CompileString(path+".json", string(raw))
+				assert.NoError(t, err, path)
+			}
+		}
+	}
+}
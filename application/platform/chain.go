@@ -0,0 +1,94 @@
+package platform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Minimal required queues features
+type Queues interface {
+	Put(queue string, request *types.Request) error
+}
+
+// Optional extension of queues: store of scheduled runs not succeeded after all attempts of retry policy
+type DeadLetters interface {
+	AddDeadLetter(letter application.DeadLetter, body []byte) error
+}
+
+// Set queues for chaining of successful output of lambdas (see Manifest.OnSuccess). Nil disables chaining
+func (platform *platform) SetQueues(queues Queues) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	platform.queues = queues
+}
+
+// output of lambda for chaining or nil if lambda is not chained. Source is nil for scheduled actions
+func (platform *platform) chainOutput(uid string, source *types.Request) application.Output {
+	platform.lock.RLock()
+	defer platform.lock.RUnlock()
+	record, ok := platform.byUID[uid]
+	if !ok || platform.queues == nil {
+		return nil
+	}
+	config := record.lambda.Manifest().OnSuccess
+	if config == nil {
+		return nil
+	}
+	if source != nil {
+		cp := *source
+		cp.Body = nil
+		source = &cp
+	}
+	return &chainOutput{queues: platform.queues, uid: uid, config: *config, source: source}
+}
+
+// buffers output up to size limit and puts it to queue if process finished successfully
+type chainOutput struct {
+	queues   Queues
+	uid      string
+	config   types.Chaining
+	source   *types.Request
+	buffer   bytes.Buffer
+	exceeded bool
+}
+
+func (co *chainOutput) Write(data []byte) (int, error) {
+	if !co.exceeded && int64(co.buffer.Len()+len(data)) > co.config.Limit() {
+		co.exceeded = true
+		co.buffer.Reset()
+	}
+	if !co.exceeded {
+		co.buffer.Write(data)
+	}
+	return len(data), nil
+}
+
+// Failed put to queue is returned as error: invocation from queue is retried by queue settings
+func (co *chainOutput) Close(err error) error {
+	if err != nil {
+		return err
+	}
+	if co.exceeded {
+		log.Println("[WARN] chain: output of", co.uid, "exceeds", co.config.Limit(), "bytes and not enqueued to", co.config.Queue)
+		return nil
+	}
+	message, err := co.config.Message(co.uid, co.source, co.buffer.Bytes())
+	if errors.Is(err, types.ErrChainHops) {
+		log.Println("[WARN] chain: output of", co.uid, "not enqueued to", co.config.Queue+":", err)
+		return nil
+	}
+	if err != nil {
+		log.Println("[ERROR] chain: output of", co.uid, "not enqueued to", co.config.Queue+":", err)
+		return nil
+	}
+	if err := co.queues.Put(co.config.Queue, message); err != nil {
+		log.Println("[ERROR] chain: output of", co.uid, "not enqueued to", co.config.Queue+":", err)
+		return fmt.Errorf("enqueue output to %s: %w", co.config.Queue, err)
+	}
+	return nil
+}
@@ -4,18 +4,21 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/user"
-	"regexp"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/builds"
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/types"
 )
 
-var allowedName = regexp.MustCompile("^[a-zA-Z0-9._-]{1,255}$")
+var allowedName = application.LinkNameReg
 
 func New(configFile string) (*platform, error) {
 	var config application.Config
@@ -26,21 +29,41 @@ func New(configFile string) (*platform, error) {
 	pl := &platform{
 		configLocation: configFile,
 		config:         config,
+		builds:         builds.New(),
+		running:        make(map[string]int),
+		profile:        types.SecurityProfile{Name: types.ProfileDefault},
 	}
 	return pl, pl.SetConfig(config)
 }
 
+// Observer of scheduled runs of lambda missed during downtime (see application.ScheduleHooks.Missed)
+type MissedRuns interface {
+	Missed(uid string, schedule types.Schedule, runs []time.Time)
+}
+
 type platform struct {
 	creds          *types.Credential
 	lock           sync.RWMutex
 	config         application.Config
 	configLocation string
+	profile        types.SecurityProfile
+	builds         *builds.Pool
 	byUID          map[string]record
+	runningLock    sync.Mutex
+	running        map[string]int      // invocations in progress by UID
+	queues         Queues              // optional queues for chaining of output
+	recorder       stats.Recorder      // optional recorder of scheduled invocations and steps of chains
+	missed         MissedRuns          // optional observer of scheduled runs missed during downtime
+	secrets        application.Secrets // optional store of secrets referenced by environment of lambdas
+	// optional engine of containers of invocations (see types.Manifest.Container)
+	containers application.Containers
 }
 
 type record struct {
-	lambda  application.Lambda
-	aliases types.JsonStringSet
+	lambda   application.Lambda
+	aliases  types.JsonStringSet
+	slug     string // alias generated from name
+	disabled bool
 }
 
 func (platform *platform) Credentials() *types.Credential {
@@ -54,7 +77,17 @@ func (platform *platform) Config() application.Config {
 }
 
 func (platform *platform) SetConfig(config application.Config) error {
+	if err := config.Runtime.Validate(); err != nil {
+		return fmt.Errorf("validate runtime: %w", err)
+	}
+	if err := config.Builds.Validate(); err != nil {
+		return fmt.Errorf("validate builds: %w", err)
+	}
 	platform.lock.Lock()
+	if err := requireRunner(platform.profile, config.User); err != nil {
+		platform.lock.Unlock()
+		return err
+	}
 	creds, err := resolveUserCreds(config.User)
 	if err != nil {
 		platform.lock.Unlock()
@@ -70,9 +103,37 @@ func (platform *platform) SetConfig(config application.Config) error {
 	platform.lock.Unlock()
 	platform.lock.Lock()
 	defer platform.lock.Unlock()
+	platform.unsafeIndexLinks()
+	return platform.applyConfig()
+}
+
+func (platform *platform) Profile() types.SecurityProfile {
+	platform.lock.RLock()
+	defer platform.lock.RUnlock()
+	return platform.profile
+}
+
+func (platform *platform) SetProfile(profile types.SecurityProfile) error {
+	if err := profile.Validate(); err != nil {
+		return fmt.Errorf("validate security profile: %w", err)
+	}
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	if err := requireRunner(profile, platform.config.User); err != nil {
+		return err
+	}
+	platform.profile = profile
 	return platform.applyConfig()
 }
 
+// user to run lambdas is set if profile requires it
+func requireRunner(profile types.SecurityProfile, user string) error {
+	if profile.RequireRunner && user == "" {
+		return fmt.Errorf("security profile %s requires user to run lambdas", profile.Name)
+	}
+	return nil
+}
+
 func (platform *platform) Link(targetUID string, linkName string) (*application.Definition, error) {
 	if !allowedName.MatchString(targetUID) {
 		return nil, fmt.Errorf("target UID is not valid name - %s", allowedName.String())
@@ -98,19 +159,151 @@ func (platform *platform) Link(targetUID string, linkName string) (*application.
 	return target.toDefinition(targetUID), platform.unsafeSaveConfig()
 }
 
+func (platform *platform) GenerateSlug(uid string) (*application.Definition, error) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	target, ok := platform.byUID[uid]
+	if !ok {
+		return nil, fmt.Errorf("unknown target lambda %s", uid)
+	}
+	name := target.lambda.Manifest().Name
+	base := application.Slug(name)
+	if base == "" {
+		return nil, fmt.Errorf("name %q of lambda %s has no latin letters or digits for slug", name, uid)
+	}
+	if application.SlugMatches(target.slug, name) {
+		return target.toDefinition(uid), nil
+	}
+	var slug string
+	for n := 1; slug == ""; n++ {
+		candidate := application.SlugCandidate(base, n)
+		if linked, exists := platform.config.Links[candidate]; exists && linked != uid {
+			continue
+		}
+		if _, exists := platform.byUID[candidate]; exists && candidate != uid {
+			continue
+		}
+		slug = candidate
+	}
+	if platform.config.Links == nil {
+		platform.config.Links = make(map[string]string)
+	}
+	if platform.config.Slugs == nil {
+		platform.config.Slugs = make(map[string]string)
+	}
+	// previous slug is kept as alias
+	target.aliases.Set(slug)
+	target.slug = slug
+	platform.byUID[uid] = target
+	platform.config.Links[slug] = uid
+	platform.config.Slugs[uid] = slug
+	return target.toDefinition(uid), platform.unsafeSaveConfig()
+}
+
+func (platform *platform) SetEnabled(uid string, enabled bool) (*application.Definition, error) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	target, ok := platform.byUID[uid]
+	if !ok {
+		return nil, fmt.Errorf("unknown target lambda %s", uid)
+	}
+	if target.disabled == !enabled {
+		return target.toDefinition(uid), nil
+	}
+	if enabled {
+		delete(platform.config.Disabled, uid)
+	} else {
+		if platform.config.Disabled == nil {
+			platform.config.Disabled = make(map[string]bool)
+		}
+		platform.config.Disabled[uid] = true
+	}
+	target.disabled = !enabled
+	platform.byUID[uid] = target
+	return target.toDefinition(uid), platform.unsafeSaveConfig()
+}
+
 func (platform *platform) Unlink(linkName string) (*application.Definition, error) {
 	if !allowedName.MatchString(linkName) {
 		return nil, fmt.Errorf("link name is not valid name - %s", allowedName.String())
 	}
 	platform.lock.Lock()
 	defer platform.lock.Unlock()
+	uid := platform.unsafeUnlink(linkName)
+	target := platform.byUID[uid]
+	return target.toDefinition(uid), platform.unsafeSaveConfig()
+}
+
+func (platform *platform) BindAliases(uid string, previous, current []string, force bool) (*application.Definition, error) {
+	for _, alias := range current {
+		if !allowedName.MatchString(alias) {
+			return nil, fmt.Errorf("alias %s is not valid name - %s", alias, allowedName.String())
+		}
+	}
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	target, ok := platform.byUID[uid]
+	if !ok {
+		return nil, fmt.Errorf("unknown target lambda %s", uid)
+	}
+	var conflicts []application.AliasConflict
+	var declared = make(map[string]bool, len(current))
+	for _, alias := range current {
+		declared[alias] = true
+		linked, exists := platform.config.Links[alias]
+		if !exists || linked == uid {
+			continue
+		}
+		conflict := application.AliasConflict{Alias: alias, UID: linked}
+		if owner, ok := platform.byUID[linked]; ok {
+			conflict.Name = owner.lambda.Manifest().Name
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	if len(conflicts) > 0 && !force {
+		return nil, &application.AliasConflictError{Conflicts: conflicts}
+	}
+	var changed bool
+	// only aliases declared before are released: aliases linked by API are kept
+	for _, alias := range previous {
+		if !declared[alias] && platform.config.Links[alias] == uid {
+			platform.unsafeUnlink(alias)
+			changed = true
+		}
+	}
+	for _, alias := range current {
+		if platform.config.Links[alias] == uid {
+			continue
+		}
+		platform.unsafeUnlink(alias)
+		if platform.config.Links == nil {
+			platform.config.Links = make(map[string]string)
+		}
+		target.aliases.Set(alias)
+		platform.config.Links[alias] = uid
+		changed = true
+	}
+	target = platform.byUID[uid]
+	if !changed {
+		return target.toDefinition(uid), nil
+	}
+	return target.toDefinition(uid), platform.unsafeSaveConfig()
+}
+
+// remove link from config and from aliases (and slug) of linked lambda. Returns UID of linked lambda (if any)
+func (platform *platform) unsafeUnlink(linkName string) string {
 	uid, ok := platform.config.Links[linkName]
 	delete(platform.config.Links, linkName)
 	target, tOk := platform.byUID[uid]
 	if tOk && ok {
 		target.aliases.Del(linkName)
+		if target.slug == linkName {
+			target.slug = ""
+			platform.byUID[uid] = target
+			delete(platform.config.Slugs, uid)
+		}
 	}
-	return target.toDefinition(uid), platform.unsafeSaveConfig()
+	return uid
 }
 
 func (platform *platform) List() []application.Definition {
@@ -165,6 +358,10 @@ func (platform *platform) Add(uid string, lambda application.Lambda) error {
 			rec.aliases.Set(alias)
 		}
 	}
+	if slug := platform.config.Slugs[uid]; rec.aliases.Has(slug) {
+		rec.slug = slug
+	}
+	rec.disabled = platform.config.Disabled[uid]
 	platform.byUID[uid] = rec
 	platform.lock.Unlock()
 
@@ -187,6 +384,8 @@ func (platform *platform) Remove(uid string) {
 			delete(platform.config.Links, alias)
 		}
 	}
+	delete(platform.config.Slugs, uid)
+	delete(platform.config.Disabled, uid)
 	_ = platform.unsafeSaveConfig()
 }
 
@@ -200,32 +399,192 @@ func (platform *platform) InvokeByUID(ctx context.Context, uid string, request t
 }
 
 func (platform *platform) Invoke(ctx context.Context, lambda application.Invokable, request types.Request, out io.Writer) error {
-	return lambda.Invoke(ctx, request, out, platform.config.Environment)
+	uid := lambda.UID()
+	platform.trackRunning(uid, 1)
+	defer platform.trackRunning(uid, -1)
+	output := platform.output(uid, &request)
+	if output == nil {
+		return lambda.Invoke(ctx, request, out, platform.config.Environment)
+	}
+	return output.Close(lambda.Invoke(ctx, request, io.MultiWriter(out, output), platform.config.Environment))
+}
+
+func (platform *platform) Running() map[string]int {
+	platform.runningLock.Lock()
+	defer platform.runningLock.Unlock()
+	var ans = make(map[string]int, len(platform.running))
+	for uid, n := range platform.running {
+		ans[uid] = n
+	}
+	return ans
+}
+
+func (platform *platform) trackRunning(uid string, delta int) {
+	platform.runningLock.Lock()
+	defer platform.runningLock.Unlock()
+	platform.running[uid] += delta
+	if platform.running[uid] <= 0 {
+		delete(platform.running, uid)
+	}
 }
 
 func (platform *platform) Do(ctx context.Context, lambda application.Lambda, action string, timeLimit time.Duration, out io.Writer) error {
 	return lambda.Do(ctx, action, timeLimit, platform.config.Environment, out)
 }
 
+func (platform *platform) DoScheduled(ctx context.Context, lambda application.Lambda, window scheduler.Window, fired func(key string, at time.Time)) {
+	hooks := platform.scheduleHooks(lambda)
+	hooks.Fired = fired
+	lambda.DoScheduled(ctx, window, platform.config.Environment, hooks)
+}
+
+func (platform *platform) CatchUpScheduled(ctx context.Context, lambda application.Lambda, until time.Time, since func(key string) time.Time, fired func(key string, at time.Time)) {
+	hooks := platform.scheduleHooks(lambda)
+	hooks.Fired = fired
+	lambda.CatchUpScheduled(ctx, until, since, platform.config.Environment, hooks)
+}
+
+func (platform *platform) scheduleHooks(lambda application.Lambda) application.ScheduleHooks {
+	platform.lock.RLock()
+	recorder, queues, missed := platform.recorder, platform.queues, platform.missed
+	platform.lock.RUnlock()
+	hooks := application.ScheduleHooks{
+		Output: func() application.Output {
+			return platform.output(lambda.UID(), nil)
+		},
+	}
+	if recorder != nil {
+		hooks.Record = recorder.Track
+	}
+	if missed != nil {
+		hooks.Missed = func(schedule types.Schedule, runs []time.Time) {
+			missed.Missed(lambda.UID(), schedule, runs)
+		}
+	}
+	if dead, ok := queues.(DeadLetters); ok {
+		hooks.Failed = func(letter application.DeadLetter, body []byte) {
+			if err := dead.AddDeadLetter(letter, body); err != nil {
+				log.Println("[ERROR]", "keep dead letter of schedule", letter.Schedule, "of", letter.Lambda, "-", err)
+			}
+		}
+	}
+	return hooks
+}
+
+// Set recorder of scheduled invocations and invocations of steps of chains (nil - not recorded)
+func (platform *platform) SetRecorder(recorder stats.Recorder) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	platform.recorder = recorder
+}
+
+// Set observer of scheduled runs missed during downtime (nil - not observed)
+func (platform *platform) SetMissedRuns(observer MissedRuns) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	platform.missed = observer
+}
+
+// Set store of secrets referenced by environment of lambdas (nil - disabled) and apply it to all lambdas
+func (platform *platform) SetSecrets(secrets application.Secrets) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	platform.secrets = secrets
+	for _, record := range platform.byUID {
+		record.lambda.SetSecrets(secrets)
+	}
+}
+
+// Set engine of containers of invocations (nil - lambdas with container are not invoked) and apply it to all lambdas
+func (platform *platform) SetContainers(containers application.Containers) {
+	platform.lock.Lock()
+	defer platform.lock.Unlock()
+	platform.containers = containers
+	for _, record := range platform.byUID {
+		record.lambda.SetContainers(containers)
+	}
+}
+
+func (platform *platform) Secrets() application.Secrets {
+	platform.lock.RLock()
+	defer platform.lock.RUnlock()
+	return platform.secrets
+}
+
+func (platform *platform) Diagnose(lambda application.Lambda) application.Diagnostic {
+	return lambda.Diagnose(platform.config.Environment)
+}
+
+func (platform *platform) MergedEnvironment(lambda application.Lambda) []application.EnvValue {
+	return lambda.MergedEnvironment(platform.config.Environment)
+}
+
+func (platform *platform) Expand(lambda application.Lambda, value string) string {
+	return lambda.Expand(value, platform.config.Environment)
+}
+
+func (platform *platform) Health(ctx context.Context, lambda application.Lambda) []string {
+	return lambda.Health(ctx, platform.config.Environment)
+}
+
+func (platform *platform) Start(ctx context.Context, lambda application.Lambda) {
+	lambda.Start(ctx, platform.config.Environment)
+}
+
+func (platform *platform) Prepare(lambda application.Lambda) error {
+	return platform.setupLambda(lambda)
+}
+
 // apply configuration for lambda
 func (platform *platform) setupLambda(lambda application.Lambda) error {
+	// profile first: ownership of files depends on read-only content
+	if err := lambda.SetProfile(platform.profile); err != nil {
+		return fmt.Errorf("set security profile: %w", err)
+	}
 	err := lambda.SetCredentials(platform.creds)
 	if err != nil {
 		return fmt.Errorf("set credentials: %w", err)
 	}
+	lambda.SetDefaults(platform.config.Runtime)
+	lambda.SetBuilder(platform.builds)
+	lambda.SetSecrets(platform.secrets)
+	lambda.SetContainers(platform.containers)
 	return nil
 }
 
 func (platform *platform) applyConfig() error {
+	platform.builds.Configure(platform.config.Builds)
 	for uid, record := range platform.byUID {
+		if err := record.lambda.SetProfile(platform.profile); err != nil {
+			return fmt.Errorf("set security profile %s: %w", uid, err)
+		}
 		err := record.lambda.SetCredentials(platform.creds)
 		if err != nil {
 			return fmt.Errorf("set credentials %s: %w", uid, err)
 		}
+		record.lambda.SetDefaults(platform.config.Runtime)
 	}
 	return nil
 }
 
+// aliases, slugs and states of lambdas by config (ex: config is reloaded from file)
+func (platform *platform) unsafeIndexLinks() {
+	for uid, rec := range platform.byUID {
+		rec.aliases = make(types.JsonStringSet)
+		for alias, target := range platform.config.Links {
+			if target == uid {
+				rec.aliases.Set(alias)
+			}
+		}
+		rec.slug = ""
+		if slug := platform.config.Slugs[uid]; rec.aliases.Has(slug) {
+			rec.slug = slug
+		}
+		rec.disabled = platform.config.Disabled[uid]
+		platform.byUID[uid] = rec
+	}
+}
+
 func (platform *platform) unsafeSaveConfig() error {
 	err := platform.config.WriteFile(platform.configLocation)
 	if err != nil {
@@ -261,10 +620,16 @@ func (record *record) toDefinition(uid string) *application.Definition {
 	if record == nil {
 		return nil
 	}
+	manifest := record.lambda.Manifest()
 	return &application.Definition{
-		UID:      uid,
-		Aliases:  record.aliases.Dup(),
-		Manifest: record.lambda.Manifest(),
-		Lambda:   record.lambda,
+		UID:            uid,
+		Aliases:        record.aliases.Dup(),
+		Manifest:       manifest,
+		Lambda:         record.lambda,
+		Slug:           record.slug,
+		SlugOutdated:   record.slug != "" && application.Slug(manifest.Name) != "" && !application.SlugMatches(record.slug, manifest.Name),
+		Warning:        record.lambda.Warning(),
+		Disabled:       record.disabled,
+		AliasOverrides: manifest.AliasOverrides(record.aliases),
 	}
 }
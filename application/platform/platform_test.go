@@ -1,14 +1,25 @@
 package platform_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/application/lambda"
 	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 func TestPlatform_AddWithOldAliases(t *testing.T) {
@@ -72,3 +83,338 @@ func TestPlatform_AddWithOldAliases(t *testing.T) {
 		assert.Equal(t, byLink, byUID)
 	}
 }
+
+func TestPlatform_GenerateSlug(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "project.json")
+	plato, err := platform.New(configFile)
+	require.NoError(t, err)
+
+	named := func(uid, name string) {
+		dummy, err := lambda.DummyPublic(t.TempDir(), "cat", "-")
+		require.NoError(t, err)
+		manifest := dummy.Manifest()
+		manifest.Name = name
+		require.NoError(t, dummy.SetManifest(manifest))
+		require.NoError(t, plato.Add(uid, dummy))
+	}
+	named("first", "My App")
+	named("second", "my app!")
+	named("third", "My  App")
+
+	// deterministic on empty server: the first gets base slug, others - numeric suffixes in order of creation
+	for i, uid := range []string{"first", "second", "third"} {
+		def, err := plato.GenerateSlug(uid)
+		require.NoError(t, err)
+		assert.Equal(t, application.SlugCandidate("my-app", i+1), def.Slug)
+		assert.True(t, def.Aliases.Has(def.Slug))
+	}
+	// the same name - the same slug
+	def, err := plato.GenerateSlug("second")
+	require.NoError(t, err)
+	assert.Equal(t, "my-app-2", def.Slug)
+
+	// collision with manual alias
+	_, err = plato.Link("first", "shop")
+	require.NoError(t, err)
+	named("fourth", "Shop")
+	def, err = plato.GenerateSlug("fourth")
+	require.NoError(t, err)
+	assert.Equal(t, "shop-2", def.Slug)
+
+	// rename: slug is outdated until regenerated, old slug is kept as alias
+	fn, err := plato.FindByUID("third")
+	require.NoError(t, err)
+	manifest := fn.Manifest
+	manifest.Name = "Renamed"
+	require.NoError(t, fn.Lambda.SetManifest(manifest))
+	fn, err = plato.FindByUID("third")
+	require.NoError(t, err)
+	assert.True(t, fn.SlugOutdated)
+	assert.Equal(t, "my-app-3", fn.Slug)
+
+	def, err = plato.GenerateSlug("third")
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", def.Slug)
+	assert.False(t, def.SlugOutdated)
+	assert.True(t, def.Aliases.Has("my-app-3"))
+
+	// slug is restored after restart
+	reloaded, err := platform.New(configFile)
+	require.NoError(t, err)
+	require.NoError(t, reloaded.Add("third", fn.Lambda))
+	fn, err = reloaded.FindByUID("third")
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", fn.Slug)
+
+	// removed slug alias is not a slug anymore
+	_, err = plato.Unlink("renamed")
+	require.NoError(t, err)
+	fn, err = plato.FindByUID("third")
+	require.NoError(t, err)
+	assert.Empty(t, fn.Slug)
+
+	// name without suitable characters
+	named("fifth", "???")
+	_, err = plato.GenerateSlug("fifth")
+	assert.Error(t, err)
+}
+
+func TestPlatform_SetEnabled(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "project.json")
+	plato, err := platform.New(configFile)
+	require.NoError(t, err)
+	dummy, err := lambda.DummyPublic(t.TempDir(), "cat", "-")
+	require.NoError(t, err)
+	require.NoError(t, plato.Add("first", dummy))
+
+	def, err := plato.FindByUID("first")
+	require.NoError(t, err)
+	assert.False(t, def.Disabled, "enabled by default")
+
+	def, err = plato.SetEnabled("first", false)
+	require.NoError(t, err)
+	assert.True(t, def.Disabled)
+	_, err = plato.SetEnabled("unknown", false)
+	assert.Error(t, err)
+
+	// state is restored after restart
+	reloaded, err := platform.New(configFile)
+	require.NoError(t, err)
+	require.NoError(t, reloaded.Add("first", dummy))
+	def, err = reloaded.FindByUID("first")
+	require.NoError(t, err)
+	assert.True(t, def.Disabled)
+
+	def, err = reloaded.SetEnabled("first", true)
+	require.NoError(t, err)
+	assert.False(t, def.Disabled)
+	assert.Empty(t, reloaded.Config().Disabled)
+
+	// removed lambda is forgotten
+	_, err = plato.SetEnabled("first", false)
+	require.NoError(t, err)
+	plato.Remove("first")
+	assert.Empty(t, plato.Config().Disabled)
+}
+
+func TestPlatform_BindAliases(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "project.json")
+	plato, err := platform.New(configFile)
+	require.NoError(t, err)
+	for _, uid := range []string{"old", "new"} {
+		dummy, err := lambda.DummyPublic(t.TempDir(), "cat", "-")
+		require.NoError(t, err)
+		require.NoError(t, plato.Add(uid, dummy))
+	}
+	_, err = plato.Link("old", "manual")
+	require.NoError(t, err)
+	def, err := plato.BindAliases("old", nil, []string{"shop", "api"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, types.StringSet("manual", "shop", "api"), def.Aliases)
+
+	// redeployed lambda with the same declared aliases
+	_, err = plato.BindAliases("new", nil, []string{"shop", "free"}, false)
+	var conflict *application.AliasConflictError
+	require.True(t, errors.As(err, &conflict))
+	assert.Equal(t, []application.AliasConflict{{Alias: "shop", UID: "old"}}, conflict.Conflicts)
+	_, err = plato.FindByLink("free")
+	assert.Error(t, err, "nothing is bound on conflict")
+
+	def, err = plato.BindAliases("new", nil, []string{"shop", "free"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, types.StringSet("shop", "free"), def.Aliases)
+	old, err := plato.FindByUID("old")
+	require.NoError(t, err)
+	assert.Equal(t, types.StringSet("manual", "api"), old.Aliases)
+
+	// only released declared aliases are unlinked: alias moved to other lambda and manual alias are kept
+	def, err = plato.BindAliases("old", []string{"shop", "api"}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, types.StringSet("manual"), def.Aliases)
+	moved, err := plato.FindByLink("shop")
+	require.NoError(t, err)
+	assert.Equal(t, "new", moved.UID)
+
+	// links are saved
+	reloaded, err := platform.New(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"manual": "old", "shop": "new", "free": "new"}, reloaded.Config().Links)
+
+	_, err = plato.BindAliases("new", nil, []string{"bad alias"}, false)
+	assert.Error(t, err)
+}
+
+type fakeQueues struct {
+	err      error
+	messages []*types.Request
+	bodies   []string
+}
+
+func (fq *fakeQueues) Put(queue string, request *types.Request) error {
+	if fq.err != nil {
+		return fq.err
+	}
+	data, _ := ioutil.ReadAll(request.Body)
+	fq.messages = append(fq.messages, request)
+	fq.bodies = append(fq.bodies, queue+":"+string(data))
+	return nil
+}
+
+func TestPlatform_Chain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plato, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	queues := &fakeQueues{}
+	plato.SetQueues(queues)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a"), 0755))
+	fn, err := lambda.DummyPublic(filepath.Join(dir, "a"), "/bin/sh", "-c", `cat -; test "$MODE" != fail`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.OnSuccess = &types.Chaining{Queue: "next", MaxSize: 16, MaxHops: 2}
+	require.NoError(t, fn.SetManifest(manifest))
+	require.NoError(t, plato.Add("a", fn))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "Makefile"), []byte("tick:\n\t@echo tick\n"), 0755))
+
+	invoke := func(body string, chain ...string) error {
+		return plato.Invoke(context.Background(), fn, types.Request{
+			Chain: chain,
+			Body:  ioutil.NopCloser(bytes.NewBufferString(body)),
+		}, ioutil.Discard)
+	}
+
+	require.NoError(t, invoke("hello"))
+	require.NoError(t, invoke("hello", "b"))
+	require.NoError(t, invoke("hello", "b", "a"), "loop is bounded by hops")
+	require.NoError(t, invoke("too big output for chain"))
+	assert.Equal(t, []string{"next:hello", "next:hello"}, queues.bodies)
+	assert.Equal(t, []string{"a"}, queues.messages[0].Chain)
+	assert.Equal(t, []string{"b", "a"}, queues.messages[1].Chain)
+
+	now := time.Now()
+	manifest.Cron = []types.Schedule{{Cron: "* * * * * *", Action: "tick"}}
+	require.NoError(t, fn.SetManifest(manifest))
+	plato.DoScheduled(context.Background(), fn, scheduler.Window{From: now.Add(-time.Minute), To: now, Expected: now}, nil)
+	assert.Equal(t, []string{"next:hello", "next:hello", "next:tick\n"}, queues.bodies, "scheduled output is chained")
+
+	manifest.Environment = map[string]string{"MODE": "fail"}
+	require.NoError(t, fn.SetManifest(manifest))
+	assert.Error(t, invoke("hello"))
+	assert.Len(t, queues.bodies, 3, "failed output is not chained")
+
+	manifest.Environment = nil
+	require.NoError(t, fn.SetManifest(manifest))
+	queues.err = errors.New("queue is full")
+	assert.Error(t, invoke("hello"), "failed enqueue is returned for retry")
+}
+
+type recordsChan chan stats.Record
+
+func (rc recordsChan) Track(record stats.Record) {
+	rc <- record
+}
+
+func TestPlatform_Then(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plato, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	records := make(recordsChan, 1)
+	plato.SetRecorder(records)
+	add := func(uid string, script string, then ...types.ChainStep) application.Lambda {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, uid), 0755))
+		fn, err := lambda.DummyPublic(filepath.Join(dir, uid), "/bin/sh", "-c", script)
+		require.NoError(t, err)
+		manifest := fn.Manifest()
+		manifest.Then = then
+		require.NoError(t, fn.SetManifest(manifest))
+		require.NoError(t, plato.Add(uid, fn))
+		return fn
+	}
+	fetch := add("fetch", `cat -; test "$MODE" != fail`, types.ChainStep{Lambda: "notifier"}, types.ChainStep{Lambda: "alert", Condition: types.ThenOnError})
+	add("notify", `cat -`)
+	add("alert", `echo "alert: $(cat -)"`)
+	_, err = plato.Link("notify", "notifier")
+	require.NoError(t, err)
+
+	invoke := func() stats.Record {
+		require.NoError(t, plato.Invoke(context.Background(), fetch, types.Request{ID: "req-1", Body: ioutil.NopCloser(bytes.NewBufferString("hello"))}, ioutil.Discard))
+		select {
+		case record := <-records:
+			return record
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "step is not invoked")
+			return stats.Record{}
+		}
+	}
+	record := invoke()
+	assert.Equal(t, "notify", record.UID)
+	assert.Equal(t, "req-1", record.Request.ID)
+	assert.Equal(t, []string{"fetch"}, record.Request.Chain)
+	assert.Equal(t, "success", record.Request.Headers[types.ChainStatusHeader])
+	assert.Equal(t, "hello", string(record.Output))
+	assert.Empty(t, record.Err)
+
+	manifest := fetch.Manifest()
+	manifest.Environment = map[string]string{"MODE": "fail"}
+	require.NoError(t, fetch.SetManifest(manifest))
+	err = plato.Invoke(context.Background(), fetch, types.Request{ID: "req-2", Body: ioutil.NopCloser(bytes.NewBufferString("hello"))}, ioutil.Discard)
+	assert.Error(t, err)
+	record = <-records
+	assert.Equal(t, "alert", record.UID)
+	assert.Equal(t, "req-2", record.Request.ID)
+	assert.Equal(t, "error", record.Request.Headers[types.ChainStatusHeader])
+	assert.Equal(t, "alert: hello\n", string(record.Output))
+
+	var invalid *types.ValidationError
+	err = plato.CheckChain("notify", []types.ChainStep{{Lambda: "fetch"}})
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, err.Error(), "loop of chain: notify -> fetch -> notify")
+	assert.ErrorAs(t, plato.CheckChain("notify", []types.ChainStep{{Lambda: "missing"}}), &invalid)
+	assert.NoError(t, plato.CheckChain("notify", []types.ChainStep{{Lambda: "alert"}}))
+
+	// fetch -> notify -> n1 -> ... -> n7 is the longest allowed chain
+	previous := "notify"
+	for i := 1; i <= 7; i++ {
+		uid := fmt.Sprintf("n%d", i)
+		add(uid, `cat -`)
+		link, err := plato.FindByUID(previous)
+		require.NoError(t, err)
+		require.NoError(t, plato.CheckChain(previous, []types.ChainStep{{Lambda: uid}}))
+		manifest := link.Lambda.Manifest()
+		manifest.Then = []types.ChainStep{{Lambda: uid}}
+		require.NoError(t, link.Lambda.SetManifest(manifest))
+		previous = uid
+	}
+	add("n8", `cat -`)
+	err = plato.CheckChain(previous, []types.ChainStep{{Lambda: "n8"}})
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, err.Error(), "9 hops")
+}
+
+func TestPlatform_SetProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pl, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	assert.Equal(t, types.ProfileDefault, pl.Profile().Name)
+
+	err = pl.SetProfile(types.StrictProfile())
+	assert.EqualError(t, err, "security profile strict requires user to run lambdas")
+	assert.Equal(t, types.ProfileDefault, pl.Profile().Name, "profile is not changed")
+
+	require.NoError(t, pl.SetConfig(application.Config{User: "nobody"}))
+	require.NoError(t, pl.SetProfile(types.StrictProfile()))
+	assert.Equal(t, types.ProfileStrict, pl.Profile().Name)
+
+	err = pl.SetConfig(application.Config{})
+	assert.EqualError(t, err, "security profile strict requires user to run lambdas")
+	assert.Equal(t, "nobody", pl.Config().User)
+}
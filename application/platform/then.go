@@ -0,0 +1,289 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func (platform *platform) CheckChain(uid string, steps []types.ChainStep) error {
+	platform.lock.RLock()
+	defer platform.lock.RUnlock()
+	var problems []types.FieldError
+	for i, step := range steps {
+		if _, ok := platform.unsafeResolve(step.Lambda); !ok {
+			problems = append(problems, types.FieldError{Field: fmt.Sprintf("then[%d].lambda", i), Message: fmt.Sprintf("unknown lambda %q", step.Lambda)})
+		}
+	}
+	if len(problems) > 0 {
+		return &types.ValidationError{Fields: problems}
+	}
+	// only edges of the lambda are changed, so new loop passes through it
+	next := func(node string) []string {
+		var targets []string
+		nodeSteps := steps
+		if node != uid {
+			nodeSteps = platform.byUID[node].lambda.Manifest().Then
+		}
+		for _, step := range nodeSteps {
+			if target, ok := platform.unsafeResolve(step.Lambda); ok {
+				targets = append(targets, target)
+			}
+		}
+		return targets
+	}
+	prev := func(node string) []string {
+		var sources []string
+		for source := range platform.byUID {
+			if source == uid {
+				continue
+			}
+			for _, target := range next(source) {
+				if target == node {
+					sources = append(sources, source)
+					break
+				}
+			}
+		}
+		return sources
+	}
+	var loop []string
+	var longest func(node string, edges func(string) []string, path []string, depth map[string]int) int
+	longest = func(node string, edges func(string) []string, path []string, depth map[string]int) int {
+		if known, ok := depth[node]; ok {
+			return known
+		}
+		path = append(path, node)
+		var max int
+		for _, target := range edges(node) {
+			if target == uid || contains(path, target) {
+				if loop == nil {
+					loop = append(append([]string{}, path...), target)
+				}
+				continue
+			}
+			if hops := longest(target, edges, path, depth) + 1; hops > max {
+				max = hops
+			}
+		}
+		depth[node] = max
+		return max
+	}
+	downstream := longest(uid, next, nil, map[string]int{})
+	if loop != nil {
+		return &types.ValidationError{Fields: []types.FieldError{{Field: "then", Message: "loop of chain: " + strings.Join(loop, " -> ")}}}
+	}
+	upstream := longest(uid, prev, nil, map[string]int{})
+	if hops := upstream + downstream; hops > types.DefaultChainHops {
+		return &types.ValidationError{Fields: []types.FieldError{{Field: "then", Message: fmt.Sprintf("chain through lambda has %d hops, maximum is %d", hops, types.DefaultChainHops)}}}
+	}
+	return nil
+}
+
+func contains(items []string, item string) bool {
+	for _, value := range items {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
+
+// UID of lambda by UID or alias
+func (platform *platform) unsafeResolve(target string) (string, bool) {
+	if _, ok := platform.byUID[target]; ok {
+		return target, true
+	}
+	uid, ok := platform.config.Links[target]
+	if _, exists := platform.byUID[uid]; !ok || !exists {
+		return "", false
+	}
+	return uid, true
+}
+
+// output of lambda for chaining to queue and steps of chain or nil if lambda is not chained. Source is nil for
+// scheduled actions
+func (platform *platform) output(uid string, source *types.Request) application.Output {
+	queued, then := platform.chainOutput(uid, source), platform.thenOutput(uid, source)
+	if queued == nil {
+		return then
+	}
+	if then == nil {
+		return queued
+	}
+	return &multiOutput{queued: queued, then: then}
+}
+
+// output to queue and to steps of chain: steps see result of put to queue
+type multiOutput struct {
+	queued application.Output
+	then   application.Output
+}
+
+func (mo *multiOutput) Write(data []byte) (int, error) {
+	_, _ = mo.queued.Write(data)
+	return mo.then.Write(data)
+}
+
+func (mo *multiOutput) Close(err error) error {
+	return mo.then.Close(mo.queued.Close(err))
+}
+
+// output of lambda for steps of chain or nil if lambda has no steps. Source is nil for scheduled actions
+func (platform *platform) thenOutput(uid string, source *types.Request) application.Output {
+	platform.lock.RLock()
+	defer platform.lock.RUnlock()
+	record, ok := platform.byUID[uid]
+	if !ok {
+		return nil
+	}
+	steps := record.lambda.Manifest().Then
+	if len(steps) == 0 {
+		return nil
+	}
+	var output = &thenOutput{platform: platform, uid: uid, steps: steps}
+	if source != nil {
+		output.id = source.ID
+		output.chain = source.Chain
+	}
+	return output
+}
+
+// buffers output up to types.DefaultChainSize and invokes targets of matched steps in background after finish of
+// process
+type thenOutput struct {
+	platform *platform
+	uid      string
+	steps    []types.ChainStep
+	id       string
+	chain    []string
+	buffer   bytes.Buffer
+	exceeded bool
+}
+
+func (to *thenOutput) Write(data []byte) (int, error) {
+	if !to.exceeded && to.buffer.Len()+len(data) > types.DefaultChainSize {
+		to.exceeded = true
+		to.buffer.Reset()
+	}
+	if !to.exceeded {
+		to.buffer.Write(data)
+	}
+	return len(data), nil
+}
+
+// Targets are invoked in background: result of invocation is not changed by steps
+func (to *thenOutput) Close(err error) error {
+	var matched []types.ChainStep
+	for _, step := range to.steps {
+		if step.Matches(err) {
+			matched = append(matched, step)
+		}
+	}
+	if len(matched) == 0 {
+		return err
+	}
+	if to.exceeded {
+		log.Println("[WARN] chain: output of", to.uid, "exceeds", types.DefaultChainSize, "bytes and not passed to next steps")
+		return err
+	}
+	if len(to.chain) >= types.DefaultChainHops {
+		log.Println("[WARN] chain: output of", to.uid, "not passed to next steps:", fmt.Errorf("%w: %d", types.ErrChainHops, len(to.chain)))
+		return err
+	}
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	output := to.buffer.Bytes()
+	chain := append(append([]string{}, to.chain...), to.uid)
+	for _, step := range matched {
+		go to.platform.invokeStep(step.Lambda, &types.Request{
+			ID:     to.id,
+			Method: http.MethodPost,
+			Path:   "/",
+			Form:   map[string]string{},
+			Headers: map[string]string{
+				"Content-Type":          http.DetectContentType(output),
+				types.ChainSourceHeader: to.uid,
+				types.ChainStatusHeader: status,
+			},
+			Chain: chain,
+		}, output)
+	}
+	return err
+}
+
+// invoke target of step and record invocation as separate invocation of the same request
+func (platform *platform) invokeStep(target string, request *types.Request, payload []byte) {
+	platform.lock.RLock()
+	uid, ok := platform.unsafeResolve(target)
+	recorder := platform.recorder
+	platform.lock.RUnlock()
+	if !ok {
+		log.Println("[ERROR] chain: next step", target, "of", request.Headers[types.ChainSourceHeader], "is not found")
+		return
+	}
+	def, err := platform.FindByUID(uid)
+	if err != nil {
+		log.Println("[ERROR] chain: next step", target, "of", request.Headers[types.ChainSourceHeader], "-", err)
+		return
+	}
+	if def.Disabled {
+		log.Println("[WARN] chain: next step", target, "of", request.Headers[types.ChainSourceHeader], "is disabled")
+		return
+	}
+	request.URL = types.LambdaPath(uid)
+	request.Body = ioutil.NopCloser(bytes.NewReader(payload))
+
+	var usage application.Usage
+	var output = &prefixWriter{limit: stats.OutputPrefix}
+	record := stats.Record{UID: uid, Request: *request, Begin: time.Now(), Payload: int64(len(payload)), Trigger: stats.TriggerChain}
+	record.Request.Body = nil
+	err = platform.Invoke(application.WithUsage(context.Background(), &usage), def.Lambda, *request, output)
+	if err != nil {
+		log.Println("[ERROR] chain: next step", uid, "of", request.Headers[types.ChainSourceHeader], "request", request.ID, "-", err)
+	}
+	if recorder == nil {
+		return
+	}
+	record.End = time.Now()
+	record.Size = output.size
+	record.Output = output.prefix
+	record.CPU = usage.CPU
+	record.MaxRSS = usage.MaxRSS
+	record.Stderr = usage.Stderr
+	record.Timeout = usage.TimedOut
+	usage.Timing(&record)
+	if err != nil {
+		record.Err = err.Error()
+	}
+	recorder.Track(record)
+}
+
+// counts output and keeps prefix of it
+type prefixWriter struct {
+	limit  int
+	size   int64
+	prefix []byte
+}
+
+func (pw *prefixWriter) Write(data []byte) (int, error) {
+	pw.size += int64(len(data))
+	if left := pw.limit - len(pw.prefix); left > 0 {
+		if left > len(data) {
+			left = len(data)
+		}
+		pw.prefix = append(pw.prefix, data[:left]...)
+	}
+	return len(data), nil
+}
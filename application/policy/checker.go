@@ -12,6 +12,13 @@ func checkPolicy(policy application.PolicyDefinition, req *types.Request) error
 	if len(policy.AllowedIP) > 0 && !policy.AllowedIP.Has(host) {
 		return fmt.Errorf("IP restricted")
 	}
+	if len(policy.AllowedNetworks) > 0 {
+		// networks are validated on create and update of policy
+		networks, err := types.ParseNetworks(policy.AllowedNetworks)
+		if err != nil || !networks.ContainsAddress(req.RemoteAddress) {
+			return application.ErrNetworkRestricted
+		}
+	}
 	if len(policy.AllowedOrigin) > 0 && !policy.AllowedOrigin.Has(req.Headers["Origin"]) {
 		return fmt.Errorf("origin restricted")
 	}
@@ -24,3 +31,10 @@ func checkPolicy(policy application.PolicyDefinition, req *types.Request) error
 	}
 	return nil
 }
+
+func checkDefinition(definition application.PolicyDefinition) error {
+	if _, err := types.ParseNetworks(definition.AllowedNetworks); err != nil {
+		return fmt.Errorf("allowed networks: %w", err)
+	}
+	return nil
+}
@@ -54,6 +54,9 @@ func (policies *policiesImpl) List() []application.Policy {
 }
 
 func (policies *policiesImpl) Create(policy string, definition application.PolicyDefinition) (*application.Policy, error) {
+	if err := checkDefinition(definition); err != nil {
+		return nil, err
+	}
 	policies.lock.Lock()
 	defer policies.lock.Unlock()
 	_, exist := policies.policiesByID[policy]
@@ -84,6 +87,9 @@ func (policies *policiesImpl) Remove(policy string) error {
 }
 
 func (policies *policiesImpl) Update(policy string, definition application.PolicyDefinition) error {
+	if err := checkDefinition(definition); err != nil {
+		return err
+	}
 	policies.lock.Lock()
 	defer policies.lock.Unlock()
 	info, exist := policies.policiesByID[policy]
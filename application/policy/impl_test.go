@@ -83,6 +83,25 @@ func TestNew(t *testing.T) {
 		err = policy.Inspect("lambda-1", req)
 		assert.NoError(t, err)
 	})
+	t.Run("networks", func(t *testing.T) {
+		err := policy.Update("foo", application.PolicyDefinition{
+			AllowedNetworks: []string{"127.0.0.0/30", "fd00::/8"},
+			Public:          true,
+		})
+		assert.NoError(t, err)
+		req := mockRequest("hello")
+		assert.NoError(t, policy.Inspect("lambda-1", req))
+		req.RemoteAddress = "[fd00::1]:9992"
+		assert.NoError(t, policy.Inspect("lambda-1", req))
+		req.RemoteAddress = "127.0.0.5:9992"
+		assert.ErrorIs(t, policy.Inspect("lambda-1", req), application.ErrNetworkRestricted)
+	})
+	t.Run("invalid networks", func(t *testing.T) {
+		err := policy.Update("foo", application.PolicyDefinition{AllowedNetworks: []string{"127.0.0.0/40"}})
+		assert.Error(t, err)
+		_, err = policy.Create("bar", application.PolicyDefinition{AllowedNetworks: []string{"localhost"}})
+		assert.Error(t, err)
+	})
 }
 
 func mockRequest(payload string) *types.Request {
@@ -0,0 +1,192 @@
+package queuemanager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// SetRecorder of queued executions (nil - not recorded). Could be set after start of workers
+func (qm *queueManager) SetRecorder(recorder stats.Recorder) {
+	qm.recorder.Store(recorderRef{recorder: recorder})
+}
+
+type recorderRef struct {
+	recorder stats.Recorder
+}
+
+// number of attempts and delay after failed attempt (from 1): retry policy of target lambda or, if not defined,
+// retries of queue
+func (qm *queueManager) retries(definition application.Queue) (int, func(attempt int) time.Duration) {
+	if lambdas, ok := qm.platform.(Lambdas); ok {
+		if def, err := lambdas.FindByUID(definition.Target); err == nil && def.Manifest.Retry != nil {
+			return def.Manifest.Retry.Total(), def.Manifest.Retry.Delay
+		}
+	}
+	return definition.Retry + 1, func(int) time.Duration { return time.Duration(definition.Interval) }
+}
+
+// attempt of execution from 1 out of total (zero - not numbered)
+type attempt struct {
+	number int
+	total  int
+}
+
+// invoke target of queue by request after serialization of lambda (if required) and free slot of workers. Waiting
+// is part of duration of record, time in queue (till peek) is recorded separately
+func (qm *queueManager) execute(ctx context.Context, definition application.Queue, req *types.Request, try attempt) error {
+	record := stats.Record{
+		UID:     definition.Target,
+		Queue:   definition.Name,
+		Request: *req,
+		Begin:   time.Now(),
+		Trigger: stats.TriggerQueue,
+	}
+	if try.total > 1 {
+		record.Attempt = try.number
+	}
+	record.Request.Body = nil
+	if !req.Queued.IsZero() {
+		record.QueueWait = record.Begin.Sub(req.Queued)
+	}
+	release, err := qm.acquire(ctx, definition.Target)
+	if err != nil {
+		_ = req.Body.Close()
+		return err
+	}
+	defer release()
+	record.Wait = time.Since(record.Begin)
+
+	var usage application.Usage
+	body := &countingReader{ReadCloser: req.Body}
+	request := *req
+	request.Body = body
+	err = qm.platform.InvokeByUID(application.WithUsage(ctx, &usage), definition.Target, request, os.Stderr)
+	record.End = time.Now()
+	record.Payload = body.n
+	record.CPU = usage.CPU
+	record.MaxRSS = usage.MaxRSS
+	record.Stderr = usage.Stderr
+	record.Timeout = usage.TimedOut
+	usage.Timing(&record)
+	if err != nil {
+		record.Err = err.Error()
+		record.Retried = try.number < try.total
+	}
+	if ref, ok := qm.recorder.Load().(recorderRef); ok && ref.recorder != nil {
+		ref.recorder.Track(record)
+	}
+	return err
+}
+
+// requests are not processed while manager is draining (see Drain)
+var errDrained = errors.New("queues are draining")
+
+// wait for execution of serial lambda and free slot of workers (in this order: waiting for lambda doesn't hold slot).
+// Waiting is interrupted by drain of manager
+func (qm *queueManager) acquire(ctx context.Context, uid string) (func(), error) {
+	var serial chan struct{}
+	if qm.isSerial(uid) {
+		serial = qm.serialSlot(uid)
+		select {
+		case serial <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-qm.intake.Done():
+			return nil, errDrained
+		}
+	}
+	if qm.slots != nil {
+		select {
+		case qm.slots <- struct{}{}:
+		case <-ctx.Done():
+			if serial != nil {
+				<-serial
+			}
+			return nil, ctx.Err()
+		case <-qm.intake.Done():
+			if serial != nil {
+				<-serial
+			}
+			return nil, errDrained
+		}
+	}
+	return func() {
+		if qm.slots != nil {
+			<-qm.slots
+		}
+		if serial != nil {
+			<-serial
+		}
+	}, nil
+}
+
+// interval of checks of target lambda while its queued requests are held
+const holdCheckInterval = time.Second
+
+// wait while target lambda is disabled: peeked request is held in queue till lambda is enabled. Waiting is
+// interrupted by drain of manager
+func (qm *queueManager) hold(ctx, intake context.Context, uid string) error {
+	if qm.isEnabled(uid) {
+		return nil
+	}
+	t := time.NewTicker(holdCheckInterval)
+	defer t.Stop()
+	for !qm.isEnabled(uid) {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-intake.Done():
+			return errDrained
+		}
+	}
+	return nil
+}
+
+// unknown lambdas are not held: invocation fails as usual
+func (qm *queueManager) isEnabled(uid string) bool {
+	lambdas, ok := qm.platform.(Lambdas)
+	if !ok {
+		return true
+	}
+	def, err := lambdas.FindByUID(uid)
+	return err != nil || !def.Disabled
+}
+
+func (qm *queueManager) isSerial(uid string) bool {
+	lambdas, ok := qm.platform.(Lambdas)
+	if !ok {
+		return false
+	}
+	def, err := lambdas.FindByUID(uid)
+	return err == nil && def.Manifest.QueueSerial
+}
+
+func (qm *queueManager) serialSlot(uid string) chan struct{} {
+	qm.serialLock.Lock()
+	defer qm.serialLock.Unlock()
+	slot, ok := qm.serial[uid]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		qm.serial[uid] = slot
+	}
+	return slot
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
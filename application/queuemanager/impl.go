@@ -1,7 +1,9 @@
 package queuemanager
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -9,6 +11,7 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/reddec/trusted-cgi/application"
@@ -31,24 +34,58 @@ type Platform interface {
 
 type QueueFactory func(name string) (queue.Queue, error)
 
-func New(ctx context.Context, config Store, platform Platform, factory QueueFactory) (*queueManager, error) {
+// Store of requests which were not processed after all attempts (see deadletter.Store)
+type DeadLetters interface {
+	Add(letter application.DeadLetter, body []byte) error
+	List(lambda string) ([]application.DeadLetter, error)
+	Get(lambda string, id string) (*application.DeadLetter, error)
+	Remove(lambda string, ids []string) (int, error)
+}
+
+// Optional platform extension: manifests of lambdas for serialization of executions (see types.Manifest.QueueSerial),
+// retry policy (see types.Manifest.Retry) and state of lambdas (requests of disabled lambda are held)
+type Lambdas interface {
+	FindByUID(uid string) (*application.Definition, error)
+}
+
+// Options of queue manager, all are optional
+type Options struct {
+	DeadLetters DeadLetters // requests which were not processed after all attempts are kept (nil - dropped)
+	Workers     int         // maximum number of concurrent executions from all queues (zero - unlimited)
+}
+
+// New manager of queues. Every queue is processed by own worker one request at a time (in order of queue)
+func New(ctx context.Context, config Store, platform Platform, factory QueueFactory, options Options) (*queueManager, error) {
 	qm := &queueManager{
 		ctx:          ctx,
 		platform:     platform,
 		queues:       map[string]*queueDefinition{},
 		queueFactory: factory,
 		config:       config,
+		dead:         options.DeadLetters,
+		serial:       map[string]chan struct{}{},
+	}
+	qm.intake, qm.stopIntake = context.WithCancel(ctx)
+	if options.Workers > 0 {
+		qm.slots = make(chan struct{}, options.Workers)
 	}
 	return qm, qm.init()
 }
 
 type queueManager struct {
 	ctx          context.Context
+	intake       context.Context // done when new requests should not be processed (see Drain)
+	stopIntake   func()
 	lock         sync.RWMutex
 	platform     Platform
 	queues       map[string]*queueDefinition
 	queueFactory QueueFactory
 	config       Store
+	dead         DeadLetters   // optional
+	slots        chan struct{} // free slots of workers (nil - unlimited)
+	serialLock   sync.Mutex
+	serial       map[string]chan struct{} // executions of serial lambdas by UID
+	recorder     atomic.Value             // recorderRef
 	wg           sync.WaitGroup
 }
 
@@ -76,11 +113,32 @@ func (qm *queueManager) Put(queue string, request *types.Request) error {
 		return fmt.Errorf("queue %s does not exist", queue)
 	}
 	if q.MaxElementSize > 0 {
-		request.Body = ioutil.NopCloser(io.LimitReader(stream, q.MaxElementSize))
+		request.Body = ioutil.NopCloser(&elementReader{reader: stream, queue: queue, limit: q.MaxElementSize})
 	}
+	request.Queued = time.Now()
 	return q.queue.Put(qm.ctx, request)
 }
 
+// body of element over max element size fails put (element is not stored) instead of truncation
+type elementReader struct {
+	reader io.Reader
+	queue  string
+	limit  int64
+	read   int64
+}
+
+func (er *elementReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > er.limit-er.read+1 {
+		p = p[:er.limit-er.read+1] // one byte over the limit is enough to detect overflow
+	}
+	n, err := er.reader.Read(p)
+	er.read += int64(n)
+	if er.read > er.limit {
+		return 0, fmt.Errorf("%w: request exceeds maximum element size of queue %s (%d bytes)", application.ErrPayloadTooLarge, er.queue, er.limit)
+	}
+	return n, err
+}
+
 func (qm *queueManager) Add(queue application.Queue) error {
 	qm.lock.Lock()
 	defer qm.lock.Unlock()
@@ -107,7 +165,7 @@ func (qm *queueManager) addQueueUnsafe(queue application.Queue) error {
 
 	q = &queueDefinition{
 		Queue:  queue,
-		worker: startWorker(qm.ctx, back, queue, qm.platform, &qm.wg),
+		worker: qm.startWorker(back, queue),
 		queue:  back,
 	}
 	if qm.queues == nil {
@@ -144,7 +202,7 @@ func (qm *queueManager) Assign(queue string, targetLambda string) error {
 	q.worker.stop()
 	<-q.worker.done
 	q.Target = targetLambda
-	q.worker = startWorker(qm.ctx, q.queue, q.Queue, qm.platform, &qm.wg)
+	q.worker = qm.startWorker(q.queue, q.Queue)
 	return qm.config.SetQueues(qm.listUnsafe())
 }
 
@@ -186,10 +244,174 @@ func (qm *queueManager) Get(queue string) (*application.Queue, error) {
 	return &q.Queue, nil
 }
 
+func (qm *queueManager) Status(name string) (*application.QueueStatus, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("queue %s does not exist", name)
+	}
+	status := &application.QueueStatus{
+		Name:     q.Name,
+		Paused:   q.Target == "" || !qm.isEnabled(q.Target),
+		InFlight: atomic.LoadInt64(&q.worker.inFlight),
+	}
+	if stats, ok := q.queue.(queue.Stats); ok {
+		status.Depth = stats.Len()
+		if oldest := stats.Oldest(); !oldest.IsZero() {
+			status.Oldest = oldest
+			status.OldestAgeSeconds = time.Since(oldest).Seconds()
+		}
+	}
+	if quarantine, ok := q.queue.(queue.Quarantine); ok {
+		status.Quarantined = quarantine.Quarantined()
+	}
+	return status, nil
+}
+
+func (qm *queueManager) Inspect(name string) (*application.QueueMessage, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("queue %s does not exist", name)
+	}
+	inspector, ok := q.queue.(queue.Inspector)
+	if !ok {
+		return nil, fmt.Errorf("queue %s doesn't support inspection", name)
+	}
+	head, err := inspector.Head()
+	if err != nil {
+		return nil, fmt.Errorf("inspect queue %s: %w", name, err)
+	}
+	var quarantined int64
+	if quarantine, ok := q.queue.(queue.Quarantine); ok {
+		quarantined = quarantine.Quarantined()
+	}
+	if head == nil {
+		return &application.QueueMessage{Queue: name, Empty: true, Quarantined: quarantined}, nil
+	}
+	return &application.QueueMessage{Queue: name, Hops: len(head.Chain), Request: head, Quarantined: quarantined}, nil
+}
+
+func (qm *queueManager) RetryQuarantined(name string) (int, error) {
+	quarantine, err := qm.quarantine(name)
+	if err != nil {
+		return 0, err
+	}
+	return quarantine.RetryQuarantined()
+}
+
+func (qm *queueManager) PurgeQuarantined(name string) (int, error) {
+	quarantine, err := qm.quarantine(name)
+	if err != nil {
+		return 0, err
+	}
+	return quarantine.PurgeQuarantined()
+}
+
+func (qm *queueManager) quarantine(name string) (queue.Quarantine, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("queue %s does not exist", name)
+	}
+	quarantine, ok := q.queue.(queue.Quarantine)
+	if !ok {
+		return nil, fmt.Errorf("queue %s doesn't support quarantine", name)
+	}
+	return quarantine, nil
+}
+
+func (qm *queueManager) DeadLetters(lambda string) ([]application.DeadLetter, error) {
+	if qm.dead == nil {
+		return []application.DeadLetter{}, nil
+	}
+	return qm.dead.List(lambda)
+}
+
+func (qm *queueManager) DeadLetter(lambda string, id string) (*application.DeadLetter, error) {
+	if qm.dead == nil {
+		return nil, fmt.Errorf("dead letter %s: %w", id, os.ErrNotExist)
+	}
+	return qm.dead.Get(lambda, id)
+}
+
+func (qm *queueManager) RemoveDeadLetters(lambda string, ids []string) (int, error) {
+	if qm.dead == nil {
+		return 0, nil
+	}
+	return qm.dead.Remove(lambda, ids)
+}
+
+func (qm *queueManager) RedriveDeadLetters(lambda string, ids []string) (int, error) {
+	if qm.dead == nil {
+		return 0, nil
+	}
+	if len(ids) == 0 {
+		letters, err := qm.dead.List(lambda)
+		if err != nil {
+			return 0, err
+		}
+		for _, letter := range letters {
+			ids = append(ids, letter.ID)
+		}
+	}
+	var redriven int
+	for _, id := range ids {
+		letter, err := qm.dead.Get(lambda, id)
+		if err != nil {
+			return redriven, fmt.Errorf("redrive dead letter %s: %w", id, err)
+		}
+		if err := qm.redrive(letter); err != nil {
+			return redriven, fmt.Errorf("redrive dead letter %s: %w", id, err)
+		}
+		if _, err := qm.dead.Remove(lambda, []string{id}); err != nil {
+			return redriven, fmt.Errorf("remove redriven dead letter %s: %w", id, err)
+		}
+		redriven++
+	}
+	return redriven, nil
+}
+
+// AddDeadLetter keeps scheduled run not succeeded after all attempts (dropped if store is not set)
+func (qm *queueManager) AddDeadLetter(letter application.DeadLetter, body []byte) error {
+	if qm.dead == nil {
+		return nil
+	}
+	return qm.dead.Add(letter, body)
+}
+
+// put queued letter back to queue, scheduled invocation is invoked in background (scheduled actions are not redriven)
+func (qm *queueManager) redrive(letter *application.DeadLetter) error {
+	req := letter.Request.WithBody(ioutil.NopCloser(bytes.NewReader(letter.Body)))
+	if letter.Queue != "" {
+		return qm.Put(letter.Queue, req)
+	}
+	if req.Method == "" {
+		return fmt.Errorf("scheduled action %s could not be redriven", letter.Schedule)
+	}
+	qm.wg.Add(1)
+	go func() {
+		defer qm.wg.Done()
+		if err := qm.execute(qm.ctx, application.Queue{Target: letter.Lambda}, req, attempt{}); err != nil {
+			log.Println("queues: failed redriven invocation of", letter.Lambda, "by schedule", letter.Schedule, "request", req.ID, ":", err)
+		}
+	}()
+	return nil
+}
+
 func (qm *queueManager) Wait() {
 	qm.wg.Wait()
 }
 
+// Drain queues before shutdown: new requests are not peeked, requests waiting for retry or free slot of workers are
+// left in queues (not committed). In-flight executions are not interrupted, workers are stopped by context of manager
+func (qm *queueManager) Drain() {
+	qm.stopIntake()
+}
+
 type queueDefinition struct {
 	application.Queue
 	worker *worker
@@ -197,22 +419,36 @@ type queueDefinition struct {
 }
 
 type worker struct {
-	stop func()
-	done chan struct{}
+	stop     func()
+	done     chan struct{}
+	inFlight int64 // number of peeked but not committed messages
 }
 
-func startWorker(gctx context.Context, queue queue.Queue, definition application.Queue, plt Platform, wg *sync.WaitGroup) *worker {
-	ctx, cancel := context.WithCancel(gctx)
+func (qm *queueManager) startWorker(queue queue.Queue, definition application.Queue) *worker {
+	ctx, cancel := context.WithCancel(qm.ctx)
+	// intake is stopped by drain of manager or by worker
+	intake, stopIntake := context.WithCancel(qm.intake)
 	w := &worker{
 		stop: cancel,
 		done: make(chan struct{}),
 	}
-	wg.Add(1)
+	qm.wg.Add(1)
 	go func() {
-		defer wg.Done()
+		defer qm.wg.Done()
 		defer close(w.done)
+		defer context.AfterFunc(ctx, stopIntake)()
+		defer stopIntake()
+		if definition.Target == "" {
+			// stopped queue: keep messages till assignment
+			<-ctx.Done()
+			return
+		}
 		for {
-			err := doTask(ctx, plt, definition, queue)
+			err := qm.doTask(ctx, intake, definition, queue, &w.inFlight)
+			if errors.Is(err, errDrained) {
+				// not processed request is left in queue
+				return
+			}
 			if err != nil {
 				log.Println("queues: queue", definition.Name, "failed process task:", err)
 			}
@@ -222,6 +458,7 @@ func startWorker(gctx context.Context, queue queue.Queue, definition application
 			default:
 			}
 			err = queue.Commit(ctx)
+			atomic.StoreInt64(&w.inFlight, 0)
 			if err != nil {
 				log.Println("queues: failed commit - waiting", commitFailedDelay)
 				select {
@@ -236,31 +473,85 @@ func startWorker(gctx context.Context, queue queue.Queue, definition application
 	return w
 }
 
-func doTask(ctx context.Context, plt Platform, definition application.Queue, queue queue.Queue) error {
-	for i := 0; i <= definition.Retry; i++ {
-		req, err := queue.Peek(ctx)
+// process the next request of queue by attempts of retry policy. Waiting (peek, retry, free slot of workers) is
+// interrupted by intake (errDrained), execution - only by context
+func (qm *queueManager) doTask(ctx, intake context.Context, definition application.Queue, queue queue.Queue, inFlight *int64) error {
+	first := time.Now()
+	attempts, delay := qm.retries(definition)
+	var err error
+	for i := 0; i < attempts; i++ {
+		var req *types.Request
+		req, err = queue.Peek(intake)
+		if err == nil {
+			if err := qm.hold(ctx, intake, definition.Target); err != nil {
+				_ = req.Body.Close()
+				return err
+			}
+			atomic.StoreInt64(inFlight, 1)
+			// correlated with request which was enqueued
+			req.ID = types.OriginRequestID(types.QueueRequestPrefix, req.ID)
+		}
 
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-intake.Done():
+			if err == nil {
+				_ = req.Body.Close()
+			}
+			return errDrained
 		default:
 		}
 
 		if err != nil {
 			log.Println("queues: failed peek", definition.Name, ":", err)
-		} else if err = plt.InvokeByUID(ctx, definition.Target, *req, os.Stderr); err != nil {
-			log.Println("queues: failed invoke by uid", definition.Target, "from queue", definition.Name, ":", err)
+		} else if err = qm.execute(ctx, definition, req, attempt{number: i + 1, total: attempts}); errors.Is(err, errDrained) {
+			return err
+		} else if err != nil {
+			log.Println("queues: failed invoke by uid", definition.Target, "from queue", definition.Name, "request", req.ID, ":", err)
 		} else {
 			return nil
 		}
 
+		if i+1 == attempts {
+			break
+		}
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(time.Duration(definition.Interval)):
+		case <-intake.Done():
+			return errDrained
+		case <-time.After(delay(i + 1)):
+		}
+	}
+	if qm.dead != nil {
+		if err := keepDeadLetter(ctx, queue, qm.dead, application.DeadLetter{
+			Lambda:   definition.Target,
+			Queue:    definition.Name,
+			Error:    err.Error(),
+			Attempts: attempts,
+			First:    first,
+			Failed:   time.Now(),
+		}); err != nil {
+			log.Println("[ERROR]", "queues: keep dead letter from queue", definition.Name, "-", err)
 		}
 	}
 	return fmt.Errorf("failed to process task for queue %s after all attempts", definition.Name)
 }
 
+// save failed request (peeked again with body) to dead letters
+func keepDeadLetter(ctx context.Context, queue queue.Queue, dead DeadLetters, letter application.DeadLetter) error {
+	req, err := queue.Peek(ctx)
+	if err != nil {
+		return err
+	}
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	letter.Request = *req
+	return dead.Add(letter, body)
+}
+
 const commitFailedDelay = 3 * time.Second
@@ -3,16 +3,23 @@ package queuemanager_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/deadletter"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
 	"github.com/reddec/trusted-cgi/queue"
+	"github.com/reddec/trusted-cgi/queue/indir"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/types"
 )
 
@@ -30,7 +37,7 @@ func (mini *mockPlatform) InvokeByUID(ctx context.Context, uid string, request t
 }
 
 func TestNew(t *testing.T) {
-	var echoText string
+	var echoText, echoID string
 	var echoCh = make(chan struct{})
 	platform := &mockPlatform{
 		handlers: map[string]hf{
@@ -43,6 +50,7 @@ func TestNew(t *testing.T) {
 					return err
 				}
 				echoText = string(data)
+				echoID = request.ID
 				return nil
 			},
 		},
@@ -59,13 +67,15 @@ func TestNew(t *testing.T) {
 			Target: "greeter",
 		}), platform, func(name string) (queue.Queue, error) {
 			return inmemory.New(10), nil
-		})
+		}, queuemanager.Options{})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
-	err = qm.Put("queue-1", mockRequest("hello world"))
+	msg := mockRequest("hello world")
+	msg.ID = "abc"
+	err = qm.Put("queue-1", msg)
 	if err != nil {
 		t.Error(err)
 		return
@@ -75,6 +85,9 @@ func TestNew(t *testing.T) {
 	if echoText != "hello world" {
 		t.Error("corrupted message")
 	}
+	if echoID != "queue-abc" {
+		t.Error("request ID should be prefixed by origin but " + echoID)
+	}
 
 	err = qm.Put("queue-not-exists", mockRequest("test"))
 	if err == nil {
@@ -132,6 +145,410 @@ func TestNew(t *testing.T) {
 	qm.Wait()
 }
 
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	stored, err := indir.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{"first", "second", "third"} {
+		if err := stored.Put(context.Background(), mockRequest(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// power loss: empty and garbage files
+	if err := ioutil.WriteFile(filepath.Join(dir, "0.data"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "1.data"), []byte(`{"method": "POST"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var received = make(chan string, 3)
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"echo": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				data, err := ioutil.ReadAll(request.Body)
+				received <- string(data)
+				return err
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(application.Queue{Name: "queue-1", Target: "echo"}), platform, func(name string) (queue.Queue, error) {
+		return indir.New(dir)
+	}, queuemanager.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := <-received; text != "third" {
+		t.Error("should be third but", text)
+	}
+	status, err := qm.Status("queue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Quarantined != 2 {
+		t.Error("should be 2 quarantined but", status.Quarantined)
+	}
+
+	n, err := qm.PurgeQuarantined("queue-1")
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Error("should be 2 purged but", n)
+	}
+	message, err := qm.Inspect("queue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Quarantined != 0 {
+		t.Error("should be nothing quarantined but", message.Quarantined)
+	}
+	cancel()
+	qm.Wait()
+}
+
+func TestDeadLetters(t *testing.T) {
+	var fail int32 = 1
+	var attempts int32
+	var received = make(chan string, 1)
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"flaky": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				data, err := ioutil.ReadAll(request.Body)
+				if err != nil {
+					return err
+				}
+				if atomic.LoadInt32(&fail) == 1 {
+					atomic.AddInt32(&attempts, 1)
+					return errors.New("exit status 1")
+				}
+				received <- string(data)
+				return nil
+			},
+		},
+	}
+	dead := deadletter.New(t.TempDir(), 10, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(application.Queue{Name: "queue-1", Target: "flaky", Retry: 2}), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, queuemanager.Options{DeadLetters: dead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := qm.Put("queue-1", mockRequest("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	var letters []application.DeadLetter
+	for i := 0; i < 100 && len(letters) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		letters, err = qm.DeadLetters("flaky")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(letters) != 1 {
+		t.Fatal("should be 1 dead letter but", len(letters))
+	}
+	letter := letters[0]
+	if letter.Queue != "queue-1" || letter.Attempts != 3 || letter.Error != "exit status 1" || letter.Size != 5 {
+		t.Error("unexpected dead letter", letter)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Error("should be 3 attempts but", n)
+	}
+	if letter.First.After(letter.Failed) {
+		t.Error("first attempt should be before failure")
+	}
+	full, err := qm.DeadLetter("flaky", letter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(full.Body) != "hello" || full.Request.Path != "/sample/hello" {
+		t.Error("unexpected body or request of dead letter", string(full.Body), full.Request.Path)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	n, err := qm.RedriveDeadLetters("flaky", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Error("should be 1 redriven but", n)
+	}
+	if text := <-received; text != "hello" {
+		t.Error("should be hello but", text)
+	}
+	letters, err = qm.DeadLetters("flaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 0 {
+		t.Error("redriven letter should be removed")
+	}
+	cancel()
+	qm.Wait()
+}
+
+type serialPlatform struct {
+	mockPlatform
+	serial bool
+}
+
+func (sp *serialPlatform) FindByUID(uid string) (*application.Definition, error) {
+	return &application.Definition{UID: uid, Manifest: types.Manifest{QueueSerial: sp.serial}}, nil
+}
+
+type mockRecorder struct {
+	records chan stats.Record
+}
+
+func (mr *mockRecorder) Track(record stats.Record) {
+	mr.records <- record
+}
+
+func TestExecutionConcurrency(t *testing.T) {
+	cases := map[string]struct {
+		serial  bool
+		workers int
+		targets [2]string
+	}{
+		"serial lambda":  {serial: true, targets: [2]string{"stateful", "stateful"}},
+		"global workers": {workers: 1, targets: [2]string{"first", "second"}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var running, peak int32
+			handler := func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				_, err := ioutil.ReadAll(request.Body)
+				return err
+			}
+			platform := &serialPlatform{serial: c.serial, mockPlatform: mockPlatform{handlers: map[string]hf{
+				c.targets[0]: handler,
+				c.targets[1]: handler,
+			}}}
+			recorder := &mockRecorder{records: make(chan stats.Record, 6)}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			qm, err := queuemanager.New(ctx, queuemanager.Mock(
+				application.Queue{Name: "queue-1", Target: c.targets[0]},
+				application.Queue{Name: "queue-2", Target: c.targets[1]},
+			), platform, func(name string) (queue.Queue, error) {
+				return inmemory.New(10), nil
+			}, queuemanager.Options{Workers: c.workers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			qm.SetRecorder(recorder)
+			for i := 0; i < 3; i++ {
+				for _, name := range []string{"queue-1", "queue-2"} {
+					if err := qm.Put(name, mockRequest("hello")); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			var queued = map[string]int{}
+			for i := 0; i < 6; i++ {
+				record := <-recorder.records
+				queued[record.Queue]++
+				if record.Err != "" || record.Payload != 5 {
+					t.Error("unexpected record", record.Err, record.Payload)
+				}
+				if record.QueueWait <= 0 {
+					t.Error("queue wait should be recorded")
+				}
+			}
+			if queued["queue-1"] != 3 || queued["queue-2"] != 3 {
+				t.Error("should be 3 executions per queue but", queued)
+			}
+			if n := atomic.LoadInt32(&peak); n != 1 {
+				t.Error("should be at most 1 concurrent execution but", n)
+			}
+			cancel()
+			qm.Wait()
+		})
+	}
+}
+
+type retryPlatform struct {
+	mockPlatform
+	retry types.Retry
+}
+
+func (rp *retryPlatform) FindByUID(uid string) (*application.Definition, error) {
+	return &application.Definition{UID: uid, Manifest: types.Manifest{Retry: &rp.retry}}, nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var calls int32
+	platform := &retryPlatform{
+		retry: types.Retry{Attempts: 3, Backoff: types.JsonDuration(time.Millisecond)},
+		mockPlatform: mockPlatform{handlers: map[string]hf{
+			"flaky": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				if atomic.AddInt32(&calls, 1) < 3 {
+					return errors.New("upstream unavailable")
+				}
+				return nil
+			},
+		}},
+	}
+	recorder := &mockRecorder{records: make(chan stats.Record, 3)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// retry policy of lambda overrides retries of queue
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "queue-1", Target: "flaky", Retry: 10, Interval: types.JsonDuration(time.Hour)},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, queuemanager.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qm.SetRecorder(recorder)
+	if err := qm.Put("queue-1", mockRequest("hello")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		record := <-recorder.records
+		if record.Attempt != i {
+			t.Error("should be attempt", i, "but", record.Attempt)
+		}
+		if retried := i < 3; record.Retried != retried || (record.Err != "") != retried {
+			t.Error("attempt", i, "unexpected result", record.Retried, record.Err)
+		}
+		if record.Failed() {
+			t.Error("attempt", i, "should not be counted as error")
+		}
+	}
+	cancel()
+	qm.Wait()
+}
+
+func TestDrain(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	platform := &mockPlatform{handlers: map[string]hf{
+		"slow": func(request types.Request, out io.Writer) error {
+			defer request.Body.Close()
+			atomic.AddInt32(&calls, 1)
+			close(started)
+			<-release
+			return nil
+		},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "queue-1", Target: "slow"},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, queuemanager.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{"first", "second"} {
+		if err := qm.Put("queue-1", mockRequest(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	qm.Drain()
+	// in-flight execution is finished and committed, the next request is left in queue
+	close(release)
+	qm.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Error("should be executed once, but", n)
+	}
+	status, err := qm.Status("queue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Depth != 1 {
+		t.Error("one request should be left in queue, but", status.Depth)
+	}
+}
+
+type statePlatform struct {
+	mockPlatform
+	disabled int32
+}
+
+func (sp *statePlatform) FindByUID(uid string) (*application.Definition, error) {
+	return &application.Definition{UID: uid, Disabled: atomic.LoadInt32(&sp.disabled) == 1}, nil
+}
+
+func TestHoldDisabled(t *testing.T) {
+	executed := make(chan string, 2)
+	platform := &statePlatform{disabled: 1, mockPlatform: mockPlatform{handlers: map[string]hf{
+		"offline": func(request types.Request, out io.Writer) error {
+			defer request.Body.Close()
+			data, err := ioutil.ReadAll(request.Body)
+			executed <- string(data)
+			return err
+		},
+	}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "queue-1", Target: "offline"},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, queuemanager.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{"first", "second"} {
+		if err := qm.Put("queue-1", mockRequest(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-executed:
+		t.Fatal("request of disabled lambda should be held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	status, err := qm.Status("queue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Paused || status.Depth != 2 {
+		t.Error("queue should be paused with 2 requests, but", status.Paused, status.Depth)
+	}
+	// enabled: held requests are released in order
+	atomic.StoreInt32(&platform.disabled, 0)
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case payload := <-executed:
+			if payload != expected {
+				t.Error("should be", expected, "but", payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("held request is not released")
+		}
+	}
+	cancel()
+	qm.Wait()
+}
+
 func mockRequest(payload string) *types.Request {
 	return &types.Request{
 		Method:        "POST",
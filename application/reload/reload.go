@@ -0,0 +1,318 @@
+// Package reload applies state edited on disk (not by API): manifests and code files of lambdas, project config and
+// custom security profile. Files are validated and applied the same way as by API, invalid files keep the current
+// state. Reload is triggered by signal (SIGHUP), by watcher of files, by periodic scan or by API.
+package reload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/journal"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Sources of reload: actor of journal changes
+const (
+	SourceSignal     = "signal"     // SIGHUP
+	SourceFilesystem = "filesystem" // watcher of files or scan
+)
+
+// Minimal required platform features
+type Platform interface {
+	List() []application.Definition
+	FindByUID(uid string) (*application.Definition, error)
+	Config() application.Config
+	SetConfig(config application.Config) error
+	Profile() types.SecurityProfile
+	SetProfile(profile types.SecurityProfile) error
+	BindAliases(uid string, previous, current []string, force bool) (*application.Definition, error)
+}
+
+// New reloader. Lambdas are located in directory by UID, project config is in the same directory.
+func New(platform Platform, dir string) *Reloader {
+	return &Reloader{
+		platform: platform,
+		dir:      dir,
+		files:    make(map[string]filesState),
+		stamps:   make(map[string]string),
+	}
+}
+
+// Reloader of files edited on disk
+type Reloader struct {
+	Journal     application.Journal                   // optional journal of changes
+	ProfileFile string                                // file of custom security profile (empty - profile is not reloaded)
+	LoadProfile func() (types.SecurityProfile, error) // loader of security profile from ProfileFile with server overrides
+	platform    Platform
+	dir         string
+	lock        sync.Mutex
+	files       map[string]filesState // the last checked files of lambdas by UID
+	stamps      map[string]string     // the last scanned files by target (see Scan)
+}
+
+// state of files of lambda by the last check
+type filesState struct {
+	fingerprint string
+	revision    uint64 // revision of lambda: changed if files are changed by server (upload, actions)
+}
+
+// Reload project config, security profile, manifests and changed files of all lambdas. Problems are logged
+func (rl *Reloader) All(source string) {
+	report, _ := rl.Reload("", source)
+	for _, item := range report.Items {
+		if item.Error != "" {
+			log.Println("[WARN]", source, "reload of", item.Target+":", item.Error)
+		}
+	}
+}
+
+func (rl *Reloader) Reload(uid string, actor string) (*application.ReloadReport, error) {
+	var report application.ReloadReport
+	if uid != "" {
+		if _, err := rl.platform.FindByUID(uid); err != nil {
+			return nil, err
+		}
+		report.Items = append(report.Items, rl.lambda(uid, actor, true))
+		return &report, nil
+	}
+	applied, err := rl.config(actor)
+	report.Items = append(report.Items, reloadItem(application.ReloadConfig, applied, err))
+	if rl.ProfileFile != "" && rl.LoadProfile != nil {
+		applied, err = rl.profile(actor)
+		report.Items = append(report.Items, reloadItem(application.ReloadProfile, applied, err))
+	}
+	for _, def := range rl.platform.List() {
+		report.Items = append(report.Items, rl.lambda(def.UID, actor, false))
+	}
+	return &report, nil
+}
+
+// reload manifest and files of lambda (force - files are reloaded regardless of changes)
+func (rl *Reloader) lambda(uid string, actor string, force bool) application.ReloadItem {
+	applied, err := rl.manifest(uid, actor)
+	item := reloadItem(uid, applied, err)
+	files, err := rl.content(uid, actor, force)
+	item.Files = files
+	if err != nil && item.Error == "" {
+		item.Error = err.Error()
+	}
+	return item
+}
+
+func reloadItem(target string, applied bool, err error) application.ReloadItem {
+	item := application.ReloadItem{Target: target, Applied: applied}
+	if err != nil {
+		item.Error = err.Error()
+	}
+	return item
+}
+
+// Reload manifest of lambda from file. Invalid manifest (including aliases bound to other lambdas) is not applied
+// and kept as persistent warning of lambda until the next change of manifest or content
+func (rl *Reloader) Manifest(uid string, source string) error {
+	_, err := rl.manifest(uid, source)
+	return err
+}
+
+func (rl *Reloader) manifest(uid string, source string) (bool, error) {
+	def, err := rl.platform.FindByUID(uid)
+	if err != nil {
+		return false, err
+	}
+	previous := def.Lambda.Manifest()
+	manifest, err := rl.readManifest(uid)
+	var applied bool
+	if err == nil {
+		applied, err = rl.applyManifest(def, previous, manifest, source)
+	}
+	if err != nil {
+		def.Lambda.SetWarning("manifest file is not applied: " + err.Error())
+		return false, err
+	}
+	def.Lambda.SetWarning("")
+	return applied, nil
+}
+
+// Reload code files of lambda edited on disk: workers are restarted, cached responses are dropped. Files are compared
+// with the previous check (see Snapshot), the first check only remembers files. Files changed by server (upload,
+// actions) are already applied and not reloaded again. Returns true if files are reloaded
+func (rl *Reloader) Files(uid string, source string) (bool, error) {
+	return rl.content(uid, source, false)
+}
+
+// Snapshot remembers files of all lambdas, so the next reload applies only files changed since the snapshot
+func (rl *Reloader) Snapshot() {
+	for _, def := range rl.platform.List() {
+		if _, err := rl.content(def.UID, "", false); err != nil {
+			log.Println("[WARN]", "check files of lambda", def.UID+":", err)
+		}
+	}
+}
+
+func (rl *Reloader) content(uid string, source string, force bool) (bool, error) {
+	def, err := rl.platform.FindByUID(uid)
+	if err != nil {
+		return false, err
+	}
+	revision := def.Lambda.Revision()
+	fingerprint, err := def.Lambda.Fingerprint()
+	if err != nil {
+		return false, fmt.Errorf("check files: %w", err)
+	}
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	previous, known := rl.files[uid]
+	rl.files[uid] = filesState{fingerprint: fingerprint, revision: revision}
+	if !force && (!known || previous.fingerprint == fingerprint || previous.revision != revision) {
+		return false, nil
+	}
+	def.Lambda.Invalidate()
+	rl.files[uid] = filesState{fingerprint: fingerprint, revision: def.Lambda.Revision()}
+	log.Println("[AUDIT]", source, "reloaded files of lambda", uid)
+	rl.record(source, application.Change{Kind: application.ChangeDeploy, Lambda: uid, Name: def.Manifest.Name, Summary: "files reloaded from disk"})
+	return true, nil
+}
+
+// revision of lambda changed by applied manifest: files are not changed, so the next check of files should not take
+// it as change by server. Revision changed since the last check by server is kept
+func (rl *Reloader) revised(uid string, previous, current uint64) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	if state, known := rl.files[uid]; known && state.revision == previous {
+		state.revision = current
+		rl.files[uid] = state
+	}
+}
+
+func (rl *Reloader) readManifest(uid string) (types.Manifest, error) {
+	var manifest types.Manifest
+	file, err := internal.FindManifest(filepath.Join(rl.dir, uid))
+	if err != nil {
+		return manifest, err
+	}
+	if err := manifest.LoadFrom(file); err != nil {
+		return manifest, fmt.Errorf("read manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (rl *Reloader) applyManifest(def *application.Definition, previous, manifest types.Manifest, source string) (bool, error) {
+	changes, err := journal.ManifestChanges(previous, manifest)
+	if err != nil {
+		return false, err
+	}
+	if len(changes) == 0 {
+		// saved by API or not changed
+		return false, nil
+	}
+	bound, err := rl.platform.BindAliases(def.UID, previous.Aliases, manifest.Aliases, false)
+	if err != nil {
+		return false, err
+	}
+	revision := def.Lambda.Revision()
+	if err := def.Lambda.ApplyManifest(manifest); err != nil {
+		_, _ = rl.platform.BindAliases(def.UID, manifest.Aliases, previous.Aliases, false)
+		return false, err
+	}
+	rl.revised(def.UID, revision, def.Lambda.Revision())
+	log.Println("[AUDIT]", source, "applied manifest of lambda", def.UID)
+	for _, change := range changes {
+		change.Lambda = def.UID
+		change.Name = manifest.Name
+		rl.record(source, change)
+	}
+	for _, alias := range manifest.Aliases {
+		if !def.Aliases.Has(alias) && bound.Aliases.Has(alias) {
+			rl.record(source, application.Change{Kind: application.ChangeAlias, Lambda: def.UID, Name: manifest.Name, Summary: "alias " + alias + " bound by manifest"})
+		}
+	}
+	for _, alias := range previous.Aliases {
+		if def.Aliases.Has(alias) && !bound.Aliases.Has(alias) {
+			rl.record(source, application.Change{Kind: application.ChangeAlias, Lambda: def.UID, Name: manifest.Name, Summary: "alias " + alias + " released by manifest"})
+		}
+	}
+	return true, nil
+}
+
+// Reload project config (links, environment, runtime and builds settings) from file. Invalid config is not applied
+func (rl *Reloader) Config(source string) error {
+	_, err := rl.config(source)
+	return err
+}
+
+func (rl *Reloader) config(source string) (bool, error) {
+	var config application.Config
+	if err := config.ReadFile(filepath.Join(rl.dir, internal.ProjectManifest)); err != nil {
+		return false, fmt.Errorf("read config: %w", err)
+	}
+	for alias := range config.Links {
+		if !application.LinkNameReg.MatchString(alias) {
+			return false, fmt.Errorf("invalid link %q: should match %s", alias, application.LinkNameReg)
+		}
+	}
+	if same, err := sameJSON(config, rl.platform.Config()); err != nil || same {
+		return false, err
+	}
+	if err := rl.platform.SetConfig(config); err != nil {
+		return false, err
+	}
+	log.Println("[AUDIT]", source, "applied project config")
+	rl.record(source, application.Change{Kind: application.ChangeSettings, Summary: "project config reloaded"})
+	return true, nil
+}
+
+// Reload custom security profile (if set) from file. Invalid profile is not applied
+func (rl *Reloader) Profile(source string) error {
+	_, err := rl.profile(source)
+	return err
+}
+
+func (rl *Reloader) profile(source string) (bool, error) {
+	if rl.ProfileFile == "" || rl.LoadProfile == nil {
+		return false, nil
+	}
+	profile, err := rl.LoadProfile()
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(profile, rl.platform.Profile()) {
+		return false, nil
+	}
+	if err := rl.platform.SetProfile(profile); err != nil {
+		return false, err
+	}
+	log.Println("[AUDIT]", source, "applied security profile", rl.ProfileFile)
+	rl.record(source, application.Change{Kind: application.ChangeSettings, Summary: "security profile reloaded"})
+	return true, nil
+}
+
+// values are the same in JSON (empty and nil maps are equal)
+func sameJSON(a, b interface{}) (bool, error) {
+	first, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	second, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(first, second), nil
+}
+
+func (rl *Reloader) record(source string, change application.Change) {
+	if rl.Journal == nil {
+		return
+	}
+	change.Actor = source
+	rl.Journal.Record(change)
+}
@@ -0,0 +1,276 @@
+package reload_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/reload"
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const uid = testutil.UID
+
+type memJournal struct {
+	lock    sync.Mutex
+	changes []application.Change
+}
+
+func (mj *memJournal) Record(change application.Change) {
+	mj.lock.Lock()
+	defer mj.lock.Unlock()
+	mj.changes = append(mj.changes, change)
+}
+
+func (mj *memJournal) Changes(query application.ChangeQuery, offset, limit int) ([]application.Change, int, error) {
+	return mj.list(), len(mj.list()), nil
+}
+
+func (mj *memJournal) list() []application.Change {
+	mj.lock.Lock()
+	defer mj.lock.Unlock()
+	return append([]application.Change{}, mj.changes...)
+}
+
+func setup(t *testing.T) (string, application.Platform, *reload.Reloader, *memJournal) {
+	dir, plato := testutil.Platform(t, types.Manifest{Run: []string{"cat", "-"}})
+	reloader := reload.New(plato, dir)
+	changes := &memJournal{}
+	reloader.Journal = changes
+	return dir, plato, reloader, changes
+}
+
+func writeManifest(t *testing.T, dir string, content string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "manifest.json"), []byte(content), 0644))
+}
+
+func TestReloader_Manifest(t *testing.T) {
+	dir, plato, reloader, changes := setup(t)
+
+	writeManifest(t, dir, `{"name":"edited","run":["cat"],"aliases":["hook"]}`)
+	require.NoError(t, reloader.Manifest(uid, reload.SourceSignal))
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", def.Manifest.Name)
+	assert.True(t, def.Aliases.Has("hook"))
+	list := changes.list()
+	require.Len(t, list, 2)
+	assert.Equal(t, reload.SourceSignal, list[0].Actor)
+	assert.Equal(t, application.ChangeManifest, list[0].Kind)
+	assert.Equal(t, "alias hook bound by manifest", list[1].Summary)
+
+	// not changed - not applied again
+	require.NoError(t, reloader.Manifest(uid, reload.SourceSignal))
+	assert.Len(t, changes.list(), 2)
+
+	// invalid edit keeps applied manifest and warning till fixed
+	writeManifest(t, dir, `{"name":"broken","run":["cat"],"maximum_payload":-1}`)
+	assert.Error(t, reloader.Manifest(uid, reload.SourceSignal))
+	def, err = plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", def.Manifest.Name)
+	assert.Contains(t, def.Warning, "maximum payload should not be negative")
+	assert.Error(t, reloader.Manifest(uid, reload.SourceSignal))
+	def, err = plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.NotEmpty(t, def.Warning, "warning is persistent")
+
+	writeManifest(t, dir, `{"name":"fixed","run":["cat"]}`)
+	require.NoError(t, reloader.Manifest(uid, reload.SourceSignal))
+	def, err = plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "fixed", def.Manifest.Name)
+	assert.Empty(t, def.Warning)
+	assert.False(t, def.Aliases.Has("hook"), "alias removed from manifest is released")
+}
+
+func TestReloader_Config(t *testing.T) {
+	dir, plato, reloader, changes := setup(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "project.json"), []byte(`{"links":{"shop":"`+uid+`"},"environment":{"STAGE":"prod"}}`), 0644))
+	require.NoError(t, reloader.Config(reload.SourceSignal))
+	def, err := plato.FindByLink("shop")
+	require.NoError(t, err)
+	assert.Equal(t, uid, def.UID)
+	assert.Equal(t, "prod", plato.Config().Environment["STAGE"])
+	require.Len(t, changes.list(), 1)
+	assert.Equal(t, application.ChangeSettings, changes.list()[0].Kind)
+
+	require.NoError(t, reloader.Config(reload.SourceSignal))
+	assert.Len(t, changes.list(), 1, "saved config is not applied again")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "project.json"), []byte(`{"links":`), 0644))
+	assert.Error(t, reloader.Config(reload.SourceSignal))
+	assert.Equal(t, "prod", plato.Config().Environment["STAGE"])
+}
+
+func TestReloader_Watch(t *testing.T) {
+	dir, plato, reloader, changes := setup(t)
+	reloader.Snapshot()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- reloader.Watch(ctx, 50*time.Millisecond)
+	}()
+	time.Sleep(100 * time.Millisecond) // watcher is started
+
+	// editor writes temporary file and replaces manifest by rename
+	for i, name := range []string{"first", "second"} {
+		tmp := filepath.Join(dir, uid, ".manifest.json.swp")
+		require.NoError(t, ioutil.WriteFile(tmp, []byte(`{"name":"`+name+`","run":["cat"]}`), 0644))
+		require.NoError(t, os.Rename(tmp, filepath.Join(dir, uid, "manifest.json")))
+		assert.Eventually(t, func() bool {
+			def, err := plato.FindByUID(uid)
+			return err == nil && def.Manifest.Name == name
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Len(t, changes.list(), i+1, "manifest applied once")
+	}
+	assert.Equal(t, reload.SourceFilesystem, changes.list()[0].Actor)
+
+	writeManifest(t, dir, `{"run":[""]}`)
+	assert.Eventually(t, func() bool {
+		def, err := plato.FindByUID(uid)
+		return err == nil && def.Warning != ""
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// manifest and files are reloaded regardless of order of reloads
+	reloadedFiles := func() bool {
+		for _, change := range changes.list() {
+			if change.Kind == application.ChangeDeploy {
+				return true
+			}
+		}
+		return false
+	}
+	writeManifest(t, dir, `{"name":"third","run":["cat"]}`)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "main.sh"), []byte("echo edited"), 0755))
+	assert.Eventually(t, func() bool {
+		def, err := plato.FindByUID(uid)
+		return err == nil && def.Manifest.Name == "third" && reloadedFiles()
+	}, 5*time.Second, 20*time.Millisecond, "manifest and files are reloaded")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestReloader_Files(t *testing.T) {
+	dir, plato, reloader, changes := setup(t)
+	code := filepath.Join(dir, uid, "main.sh")
+	require.NoError(t, ioutil.WriteFile(code, []byte("echo first"), 0755))
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+
+	// the first check only remembers files
+	reloader.Snapshot()
+	revision := def.Lambda.Revision()
+	reloaded, err := reloader.Files(uid, reload.SourceSignal)
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	require.NoError(t, ioutil.WriteFile(code, []byte("echo second"), 0755))
+	reloaded, err = reloader.Files(uid, reload.SourceSignal)
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.NotEqual(t, revision, def.Lambda.Revision(), "cached responses are dropped")
+	list := changes.list()
+	require.Len(t, list, 1)
+	assert.Equal(t, application.ChangeDeploy, list[0].Kind)
+	assert.Equal(t, reload.SourceSignal, list[0].Actor)
+
+	// ignored files and manifest are not code
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, ".cgiignore"), []byte("*.log"), 0644))
+	reloaded, err = reloader.Files(uid, reload.SourceSignal)
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uid, "out.log"), []byte("log"), 0644))
+	writeManifest(t, dir, `{"name":"edited","run":["cat"]}`)
+	reloaded, err = reloader.Files(uid, reload.SourceSignal)
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// changed by server: already applied
+	require.NoError(t, ioutil.WriteFile(code, []byte("echo third"), 0755))
+	def.Lambda.Invalidate()
+	reloaded, err = reloader.Files(uid, reload.SourceSignal)
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Len(t, changes.list(), 2)
+
+	// manifest applied by reloader is not a change of files by server
+	writeManifest(t, dir, `{"name":"reloaded","run":["cat"]}`)
+	require.NoError(t, reloader.Manifest(uid, reload.SourceSignal))
+	require.NoError(t, ioutil.WriteFile(code, []byte("echo fourth"), 0755))
+	reloaded, err = reloader.Files(uid, reload.SourceSignal)
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir, plato, reloader, changes := setup(t)
+	reloader.Snapshot()
+
+	writeManifest(t, dir, `{"name":"edited","run":["cat"]}`)
+	report, err := reloader.Reload("", "admin")
+	require.NoError(t, err)
+	require.Len(t, report.Items, 2)
+	assert.Equal(t, application.ReloadItem{Target: application.ReloadConfig}, report.Items[0])
+	assert.Equal(t, application.ReloadItem{Target: uid, Applied: true}, report.Items[1])
+	require.NotEmpty(t, changes.list())
+	assert.Equal(t, "admin", changes.list()[0].Actor)
+
+	// malformed manifest keeps the last good one
+	writeManifest(t, dir, `{"name":`)
+	report, err = reloader.Reload(uid, "admin")
+	require.NoError(t, err)
+	require.Len(t, report.Items, 1)
+	assert.NotEmpty(t, report.Items[0].Error)
+	assert.False(t, report.Items[0].Applied)
+	assert.True(t, report.Items[0].Files, "files of requested lambda are reloaded regardless of changes")
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", def.Manifest.Name)
+	assert.Contains(t, def.Warning, "manifest file is not applied")
+
+	_, err = reloader.Reload("22222222-2222-2222-2222-222222222222", "admin")
+	assert.Error(t, err)
+}
+
+func TestReloader_Scan(t *testing.T) {
+	dir, plato, reloader, _ := setup(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, uid, "lib"), 0755))
+	code := filepath.Join(dir, uid, "lib", "util.sh")
+	require.NoError(t, ioutil.WriteFile(code, []byte("echo first"), 0644))
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- reloader.Scan(ctx, 20*time.Millisecond)
+	}()
+	time.Sleep(100 * time.Millisecond) // files are remembered
+
+	revision := def.Lambda.Revision()
+	require.NoError(t, ioutil.WriteFile(code, []byte("echo second"), 0644))
+	assert.Eventually(t, func() bool {
+		return def.Lambda.Revision() != revision
+	}, 5*time.Second, 20*time.Millisecond)
+
+	writeManifest(t, dir, `{"name":"scanned","run":["cat"]}`)
+	assert.Eventually(t, func() bool {
+		def, err := plato.FindByUID(uid)
+		return err == nil && def.Manifest.Name == "scanned"
+	}, 5*time.Second, 20*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
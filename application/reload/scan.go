@@ -0,0 +1,76 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// DefaultScanInterval is period of scan of files without watcher
+const DefaultScanInterval = 10 * time.Second
+
+// Scan manifests and files of lambdas (including subdirectories), project config and security profile file
+// periodically till context is done (zero interval - DefaultScanInterval). Files are compared by sizes and
+// modification times, so scan works where watcher of files is not available (ex: network file systems, exhausted
+// limits of watchers). The first scan only remembers files
+func (rl *Reloader) Scan(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultScanInterval
+	}
+	rl.scan(false)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			rl.scan(true)
+		}
+	}
+}
+
+// check files and reload changed (apply - false: only remember manifests, config and profile)
+func (rl *Reloader) scan(apply bool) {
+	rl.check(targetConfig, filepath.Join(rl.dir, internal.ProjectManifest), apply)
+	if rl.ProfileFile != "" {
+		rl.check(targetProfile, rl.ProfileFile, apply)
+	}
+	for _, def := range rl.platform.List() {
+		file, err := internal.FindManifest(filepath.Join(rl.dir, def.UID))
+		if err != nil {
+			file = "" // reported by reload of manifest
+		}
+		rl.check(def.UID, file, apply)
+		// files are compared with the previous check itself
+		rl.reload(def.UID + targetFiles)
+	}
+}
+
+// reload target if stamp of file is changed since the previous check
+func (rl *Reloader) check(target string, file string, apply bool) {
+	stamp := fileStamp(file)
+	rl.lock.Lock()
+	previous, known := rl.stamps[target]
+	rl.stamps[target] = stamp
+	rl.lock.Unlock()
+	if apply && known && previous != stamp {
+		rl.reload(target)
+	}
+}
+
+// size and modification time of file (empty - file doesn't exist)
+func fileStamp(file string) string {
+	if file == "" {
+		return ""
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprint(info.Size(), info.ModTime().UnixNano())
+}
@@ -0,0 +1,150 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// DefaultDebounce is time without events before edited file is reloaded
+const DefaultDebounce = 500 * time.Millisecond
+
+// targets of reload by file
+const (
+	targetConfig  = ":config"
+	targetProfile = ":profile"
+	targetFiles   = ":files" // suffix of lambda UID: code files of lambda
+)
+
+// Watch manifests and files of lambdas, project config and security profile file till context is done. Edits are
+// debounced: file is reloaded after debounce interval without events (zero - DefaultDebounce). Directories are watched
+// instead of files, so editors which replace files by rename are supported. Directories of new lambdas are watched as
+// well. Only files in root directory of lambda are watched: files in subdirectories are detected by Scan
+func (rl *Reloader) Watch(ctx context.Context, debounce time.Duration) error {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	dir, err := filepath.Abs(rl.dir)
+	if err != nil {
+		return fmt.Errorf("get project dir: %w", err)
+	}
+	var profileFile string
+	if rl.ProfileFile != "" {
+		profileFile, err = filepath.Abs(rl.ProfileFile)
+		if err != nil {
+			return fmt.Errorf("get security profile file: %w", err)
+		}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("watch %s: %w", dir, err)
+	}
+	if profileFile != "" && filepath.Dir(profileFile) != dir {
+		if err := watcher.Add(filepath.Dir(profileFile)); err != nil {
+			return fmt.Errorf("watch %s: %w", profileFile, err)
+		}
+	}
+	for _, def := range rl.platform.List() {
+		if err := watcher.Add(filepath.Join(dir, def.UID)); err != nil {
+			log.Println("[WARN]", "watch lambda", def.UID+":", err)
+		}
+	}
+	log.Println("watching files of", dir)
+
+	var timers = make(map[string]*time.Timer) // pending reloads by target
+	var fired = make(chan string)
+	defer func() {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Create != 0 && filepath.Dir(event.Name) == dir {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						log.Println("[WARN]", "watch", event.Name+":", err)
+					}
+				}
+			}
+			target := targetOf(event.Name, dir, profileFile)
+			if target == "" {
+				continue
+			}
+			if timer, ok := timers[target]; ok {
+				timer.Reset(debounce)
+				continue
+			}
+			timers[target] = time.AfterFunc(debounce, func() {
+				select {
+				case fired <- target:
+				case <-ctx.Done():
+				}
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("[WARN]", "watcher:", err)
+		case target := <-fired:
+			delete(timers, target)
+			rl.reload(target)
+		}
+	}
+}
+
+// target of reload (lambda UID, files of lambda or config) by changed file, empty for other files
+func targetOf(file string, dir string, profileFile string) string {
+	switch {
+	case file == profileFile:
+		return targetProfile
+	case file == filepath.Join(dir, internal.ProjectManifest):
+		return targetConfig
+	case filepath.Dir(filepath.Dir(file)) == dir && internal.IsManifest(filepath.Base(file)):
+		return filepath.Base(filepath.Dir(file))
+	case filepath.Dir(filepath.Dir(file)) == dir:
+		return filepath.Base(filepath.Dir(file)) + targetFiles
+	}
+	return ""
+}
+
+func (rl *Reloader) reload(target string) {
+	var err error
+	switch target {
+	case targetConfig:
+		err = rl.Config(SourceFilesystem)
+	case targetProfile:
+		err = rl.Profile(SourceFilesystem)
+	default:
+		uid := strings.TrimSuffix(target, targetFiles)
+		if _, found := rl.platform.FindByUID(uid); found != nil {
+			return // not a lambda (yet) or removed
+		}
+		if uid != target {
+			_, err = rl.Files(uid, SourceFilesystem)
+		} else {
+			err = rl.Manifest(uid, SourceFilesystem)
+		}
+	}
+	if err != nil {
+		log.Println("[WARN]", "edit of", target, "is not applied:", err)
+	}
+}
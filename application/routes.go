@@ -0,0 +1,71 @@
+package application
+
+import (
+	"sort"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Kinds of invocation routes
+const (
+	RouteUID   = "uid"   // by UID of lambda (always exists)
+	RouteSlug  = "slug"  // by slug generated from name
+	RouteAlias = "alias" // by alias (link)
+	RouteQueue = "queue" // by linked queue: request is put to queue and processed by lambda asynchronously
+)
+
+// Canonical public invocation path of lambda. Sub-paths of path are routed to the same lambda
+type Route struct {
+	Kind     string `json:"kind"`               // uid, slug, alias or queue
+	Name     string `json:"name"`               // UID, alias or name of queue
+	Path     string `json:"path"`               // path without trailing slash, ex: /l/hello
+	Declared bool   `json:"declared,omitempty"` // alias is declared in manifest (bound on apply)
+	Outdated bool   `json:"outdated,omitempty"` // slug doesn't match name of lambda anymore and could be regenerated
+}
+
+// Invocation routes of lambda
+type LambdaRoutes struct {
+	UID            string   `json:"uid"`
+	Name           string   `json:"name,omitempty"`
+	Methods        []string `json:"methods,omitempty"`         // allowed methods (empty - any)
+	MaximumPayload int64    `json:"maximum_payload,omitempty"` // limit of request body (zero - not set)
+	Routes         []Route  `json:"routes"`                    // route by UID first, then slug, aliases and queues
+}
+
+// RoutesOf lambda (by definition from platform) and queues linked to it
+func RoutesOf(def Definition, queues []Queue) LambdaRoutes {
+	manifest := def.Lambda.Effective()
+	var ans = LambdaRoutes{
+		UID:            def.UID,
+		Name:           manifest.Name,
+		Methods:        manifest.AllowedMethods(),
+		MaximumPayload: manifest.PayloadLimit(),
+		Routes:         []Route{{Kind: RouteUID, Name: def.UID, Path: types.LambdaPath(def.UID)}},
+	}
+	if def.Slug != "" && def.Aliases.Has(def.Slug) {
+		ans.Routes = append(ans.Routes, Route{Kind: RouteSlug, Name: def.Slug, Path: types.LinkPath(def.Slug), Outdated: def.SlugOutdated})
+	}
+	var declared = make(map[string]bool, len(manifest.Aliases))
+	for _, alias := range manifest.Aliases {
+		declared[alias] = true
+	}
+	var aliases = make([]string, 0, len(def.Aliases))
+	for alias := range def.Aliases {
+		if alias != def.Slug {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		ans.Routes = append(ans.Routes, Route{Kind: RouteAlias, Name: alias, Path: types.LinkPath(alias), Declared: declared[alias]})
+	}
+	var names = make([]string, 0, len(queues))
+	for _, queue := range queues {
+		names = append(names, queue.Name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ans.Routes = append(ans.Routes, Route{Kind: RouteQueue, Name: name, Path: types.QueuePath(name)})
+	}
+	return ans
+}
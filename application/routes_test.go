@@ -0,0 +1,45 @@
+package application_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestRoutesOf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fn, err := lambda.DummyPublic(dir, "cat", "-")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Name = "Renamed"
+	manifest.Aliases = []string{"hook"}
+	manifest.Methods = []string{"POST"}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	routes := application.RoutesOf(application.Definition{
+		UID:          "a1b2",
+		Aliases:      types.JsonStringSet{"hook": true, "legacy": true, "hello": true},
+		Lambda:       fn,
+		Slug:         "hello",
+		SlugOutdated: true,
+	}, []application.Queue{{Name: "jobs", Target: "a1b2"}, {Name: "batch", Target: "a1b2"}})
+	assert.Equal(t, "Renamed", routes.Name)
+	assert.Equal(t, []string{"POST"}, routes.Methods)
+	assert.Equal(t, []application.Route{
+		{Kind: application.RouteUID, Name: "a1b2", Path: "/a/a1b2"},
+		{Kind: application.RouteSlug, Name: "hello", Path: "/l/hello", Outdated: true},
+		{Kind: application.RouteAlias, Name: "hook", Path: "/l/hook", Declared: true},
+		{Kind: application.RouteAlias, Name: "legacy", Path: "/l/legacy"},
+		{Kind: application.RouteQueue, Name: "batch", Path: "/q/batch"},
+		{Kind: application.RouteQueue, Name: "jobs", Path: "/q/jobs"},
+	}, routes.Routes)
+}
@@ -0,0 +1,193 @@
+// Package scheduler decides which scheduled actions are due. Cron expressions are evaluated against local wall-clock
+// time (or wall-clock time of time zone of schedule), while progress of time is measured by monotonic clock: wall-clock steps (NTP corrections, suspend and resume)
+// and DST transitions neither fire schedules twice nor skip them silently.
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Differences between wall-clock and monotonic time smaller than tolerance are not jumps (NTP slew, timer jitter)
+const JumpTolerance = 2 * time.Second
+
+// Backward jumps up to the limit are corrections: wall-clock times already evaluated are not fired again until the
+// clock catches up. After larger backward jump schedules are re-evaluated from the new time.
+const MaxBackwardJump = 3 * time.Hour
+
+// Clock provides wall-clock and monotonic time
+type Clock interface {
+	// Wall-clock time in location of cron expressions
+	Now() time.Time
+	// Monotonic time since arbitrary moment: never jumps and does not count suspend
+	Monotonic() time.Duration
+}
+
+// System clock: local time and monotonic reading of the Go runtime
+func System() Clock {
+	return &systemClock{start: time.Now()}
+}
+
+type systemClock struct {
+	start time.Time
+}
+
+func (sc *systemClock) Now() time.Time { return time.Now().Round(0) }
+
+func (sc *systemClock) Monotonic() time.Duration { return time.Since(sc.start) }
+
+// Window of local wall-clock time evaluated by check. Times are floating (local time without zone, in UTC location),
+// so cron expressions are not affected by DST: time skipped by spring forward is missed, time repeated by fall back
+// is not evaluated twice.
+type Window struct {
+	From     time.Time     // exclusive
+	To       time.Time     // inclusive
+	Expected time.Time     // end of window by monotonic clock: later occurrences are missed runs
+	Jump     time.Duration // detected wall-clock jump (0 - no jump): positive - forward, negative - backward
+	check    *check        // check which produced window (nil - window is not bound to tracker)
+}
+
+// Due checks that schedule has occurrence in the window. Occurrences missed because of forward jump (or DST spring
+// forward) are run once unless skipped by policy.
+func (w Window) Due(sched cron.Schedule, skipMissed bool) bool {
+	next := sched.Next(w.From)
+	if next.After(w.To) {
+		return false
+	}
+	return !skipMissed || !next.After(w.Expected)
+}
+
+// In returns the same check as window of wall-clock time in the location (time zone of schedule). Every location has
+// own evaluated time, so DST transitions of the location are handled as for local time. Windows not produced by
+// tracker are returned as is.
+func (w Window) In(location *time.Location) Window {
+	if w.check == nil {
+		return w
+	}
+	return w.check.window(location)
+}
+
+// Tracker of evaluated time. Should be checked from one goroutine, counters could be read concurrently.
+type Tracker struct {
+	clock Clock
+	wall  time.Time     // wall-clock time of the last check
+	mono  time.Duration // monotonic time of the last check
+	jumps uint64
+	lock  sync.Mutex
+	zones map[string]*zone // by name of location
+}
+
+// evaluated time of location
+type zone struct {
+	location  *time.Location
+	watermark time.Time // floating time up to which schedules are evaluated
+}
+
+// single check of tracker: windows of locations are evaluated once
+type check struct {
+	tracker  *Tracker
+	previous time.Time // wall-clock time of the previous check
+	now      time.Time
+	elapsed  time.Duration // by monotonic clock
+	jump     time.Duration
+	windows  map[string]Window
+}
+
+// New tracker of evaluated time starting from now: schedules are not fired immediately
+func New(clock Clock) *Tracker {
+	now := clock.Now()
+	return &Tracker{
+		clock: clock,
+		wall:  now,
+		mono:  clock.Monotonic(),
+		zones: map[string]*zone{
+			now.Location().String(): {location: now.Location(), watermark: floating(now)},
+		},
+	}
+}
+
+// Check time passed since the previous check. Returned window is in location of clock, see Window.In for other
+// locations.
+func (tr *Tracker) Check() Window {
+	now := tr.clock.Now()
+	mono := tr.clock.Monotonic()
+	elapsed := mono - tr.mono
+	jump := now.Sub(tr.wall) - elapsed
+	if jump > -JumpTolerance && jump < JumpTolerance {
+		jump = 0
+	}
+	if jump != 0 {
+		atomic.AddUint64(&tr.jumps, 1)
+	}
+	current := &check{
+		tracker:  tr,
+		previous: tr.wall,
+		now:      now,
+		elapsed:  elapsed,
+		jump:     jump,
+		windows:  map[string]Window{},
+	}
+	tr.wall = now
+	tr.mono = mono
+	tr.lock.Lock()
+	zones := make([]*time.Location, 0, len(tr.zones))
+	for _, z := range tr.zones {
+		zones = append(zones, z.location)
+	}
+	tr.lock.Unlock()
+	// evaluated time of all known locations is moved, even if schedules of location are not checked right now
+	for _, location := range zones {
+		current.window(location)
+	}
+	return current.window(now.Location())
+}
+
+func (c *check) window(location *time.Location) Window {
+	tr := c.tracker
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+	name := location.String()
+	if window, ok := c.windows[name]; ok {
+		return window
+	}
+	z, ok := tr.zones[name]
+	if !ok {
+		// new location is evaluated from the previous check
+		z = &zone{location: location, watermark: floating(c.previous.In(location))}
+		tr.zones[name] = z
+	}
+	to := floating(c.now.In(location))
+	if c.jump < -MaxBackwardJump {
+		// the clock was wrong: start from the new time
+		z.watermark = to
+	}
+	window := Window{From: z.watermark, To: to, Expected: z.watermark.Add(c.elapsed), Jump: c.jump, check: c}
+	if window.Expected.After(to) {
+		window.Expected = to
+	}
+	if to.After(z.watermark) {
+		z.watermark = to
+	}
+	c.windows[name] = window
+	return window
+}
+
+// Number of detected wall-clock jumps
+func (tr *Tracker) Jumps() uint64 {
+	return atomic.LoadUint64(&tr.jumps)
+}
+
+// Next fire time of schedule after the moment (in location of the moment). Nonexistent local time (DST spring
+// forward) is normalized by time package.
+func Next(sched cron.Schedule, after time.Time) time.Time {
+	next := sched.Next(floating(after))
+	return time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), next.Second(), next.Nanosecond(), after.Location())
+}
+
+// local wall-clock reading of time as UTC time
+func floating(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
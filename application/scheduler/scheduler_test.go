@@ -0,0 +1,244 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/robfig/cron"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (fc *fakeClock) Now() time.Time { return fc.wall }
+
+func (fc *fakeClock) Monotonic() time.Duration { return fc.mono }
+
+// regular progress of time: both clocks move
+func (fc *fakeClock) advance(d time.Duration) {
+	fc.wall = fc.wall.Add(d)
+	fc.mono += d
+}
+
+// step of wall clock (NTP correction) or suspend (monotonic clock stopped)
+func (fc *fakeClock) step(d time.Duration) {
+	fc.wall = fc.wall.Add(d)
+}
+
+type simulation struct {
+	clock   *fakeClock
+	tracker *Tracker
+	sched   cron.Schedule
+	skip    bool
+	zone    *time.Location // time zone of schedule (nil - location of clock)
+	fired   []time.Time    // wall-clock times of checks which fired schedule
+}
+
+func newSimulation(t *testing.T, spec string, start time.Time) *simulation {
+	sched, err := cron.Parse(spec)
+	require.NoError(t, err)
+	clock := &fakeClock{wall: start, mono: time.Hour}
+	return &simulation{clock: clock, tracker: New(clock), sched: sched}
+}
+
+// check schedule every tick during the duration
+func (sim *simulation) run(duration, tick time.Duration) {
+	for passed := time.Duration(0); passed < duration; passed += tick {
+		sim.clock.advance(tick)
+		sim.check()
+	}
+}
+
+func (sim *simulation) check() Window {
+	window := sim.tracker.Check()
+	if sim.zone != nil {
+		window = window.In(sim.zone)
+	}
+	if window.Due(sim.sched, sim.skip) {
+		sim.fired = append(sim.fired, sim.clock.Now())
+	}
+	return window
+}
+
+func TestTracker_regular(t *testing.T) {
+	sim := newSimulation(t, "0 0 * * * *", time.Date(2020, 5, 1, 9, 59, 0, 0, time.UTC))
+	sim.run(3*time.Hour, 30*time.Second)
+	assert.Len(t, sim.fired, 3)
+	assert.Equal(t, time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC), sim.fired[0])
+	assert.Equal(t, uint64(0), sim.tracker.Jumps())
+}
+
+func TestTracker_notImmediately(t *testing.T) {
+	sim := newSimulation(t, "@every 1s", time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC))
+	sim.check()
+	assert.Empty(t, sim.fired)
+}
+
+func TestTracker_backwardStep(t *testing.T) {
+	sim := newSimulation(t, "0 0 10 * * *", time.Date(2020, 5, 1, 9, 59, 50, 0, time.UTC))
+	sim.run(30*time.Second, 30*time.Second) // 10:00:20 - fired
+	require.Len(t, sim.fired, 1)
+
+	sim.clock.step(-90 * time.Second) // NTP correction: 09:58:50
+	window := sim.check()
+	assert.True(t, window.Jump < 0)
+	assert.Equal(t, uint64(1), sim.tracker.Jumps())
+
+	sim.run(10*time.Minute, 30*time.Second) // 10:00 passes again
+	assert.Len(t, sim.fired, 1, "time already evaluated should not fire twice")
+}
+
+func TestTracker_largeBackwardStep(t *testing.T) {
+	// clock was a day ahead and corrected: schedules continue from the new time
+	sim := newSimulation(t, "0 0 * * * *", time.Date(2020, 5, 2, 9, 59, 50, 0, time.UTC))
+	sim.run(30*time.Second, 30*time.Second)
+	require.Len(t, sim.fired, 1)
+
+	sim.clock.step(-24 * time.Hour)
+	sim.check()
+	sim.run(3*time.Hour, 30*time.Second)
+	assert.Len(t, sim.fired, 4)
+	assert.Equal(t, time.Date(2020, 5, 1, 11, 0, 20, 0, time.UTC), sim.fired[1])
+}
+
+func TestTracker_leapSecond(t *testing.T) {
+	// leap second inserted by stepping clock back for one second at midnight
+	sim := newSimulation(t, "0 0 0 * * *", time.Date(2016, 12, 31, 23, 59, 58, 500000000, time.UTC))
+	sim.run(2*time.Second, time.Second) // 00:00:00.5 - fired
+	require.Len(t, sim.fired, 1)
+
+	sim.clock.step(-time.Second)
+	window := sim.check()
+	assert.Equal(t, time.Duration(0), window.Jump, "leap second is within tolerance")
+	sim.run(5*time.Second, time.Second)
+	assert.Len(t, sim.fired, 1)
+}
+
+func TestTracker_suspend(t *testing.T) {
+	start := time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	sim := newSimulation(t, "0 0 * * * *", start)
+	sim.clock.step(5 * time.Hour) // suspended: monotonic clock stopped
+	window := sim.check()
+	assert.Equal(t, 5*time.Hour, window.Jump)
+	assert.Len(t, sim.fired, 1, "missed runs are run once")
+	sim.run(30*time.Minute, 30*time.Second)
+	assert.Len(t, sim.fired, 2)
+
+	sim = newSimulation(t, "0 0 * * * *", start)
+	sim.skip = true
+	sim.clock.step(5 * time.Hour)
+	sim.check()
+	assert.Empty(t, sim.fired, "missed runs are skipped")
+	sim.run(30*time.Minute, 30*time.Second)
+	assert.Len(t, sim.fired, 1)
+	assert.Equal(t, time.Date(2020, 5, 1, 16, 0, 0, 0, time.UTC), sim.fired[0])
+}
+
+func TestTracker_forwardStepWithinTick(t *testing.T) {
+	// occurrence reached by regular progress is not a missed run
+	sim := newSimulation(t, "0 0 * * * *", time.Date(2020, 5, 1, 9, 59, 50, 0, time.UTC))
+	sim.skip = true
+	sim.clock.advance(30 * time.Second)
+	sim.clock.step(time.Minute)
+	window := sim.check()
+	assert.Equal(t, time.Minute, window.Jump)
+	assert.Len(t, sim.fired, 1)
+}
+
+func TestTracker_dstSpringForward(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	start := time.Date(2020, 3, 29, 1, 0, 0, 0, berlin)
+	// 02:30 does not exist: clock goes from 02:00 CET to 03:00 CEST
+
+	sim := newSimulation(t, "0 30 2 * * *", start)
+	sim.run(3*time.Hour, 30*time.Second)
+	require.Len(t, sim.fired, 1, "missed run is run once")
+	assert.Equal(t, time.Date(2020, 3, 29, 3, 0, 0, 0, berlin), sim.fired[0])
+	assert.Equal(t, uint64(0), sim.tracker.Jumps(), "DST is not a clock jump")
+
+	sim = newSimulation(t, "0 30 2 * * *", start)
+	sim.skip = true
+	sim.run(3*time.Hour, 30*time.Second)
+	assert.Empty(t, sim.fired)
+
+	sim = newSimulation(t, "0 0 * * * *", start)
+	sim.run(3*time.Hour, 30*time.Second)
+	assert.Len(t, sim.fired, 3) // 02:00 and 03:00 at once, 04:00, 05:00
+}
+
+func TestTracker_dstFallBack(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// 02:30 happens twice: clock goes from 03:00 CEST to 02:00 CET
+	sim := newSimulation(t, "0 30 2 * * *", time.Date(2020, 10, 25, 1, 0, 0, 0, berlin))
+	sim.run(4*time.Hour, 30*time.Second)
+	require.Len(t, sim.fired, 1)
+	assert.Equal(t, "2020-10-25 02:30:00 +0200 CEST", sim.fired[0].String())
+	assert.Equal(t, uint64(0), sim.tracker.Jumps(), "DST is not a clock jump")
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	sched, err := cron.Parse("0 30 2 * * *")
+	require.NoError(t, err)
+
+	next := Next(sched, time.Date(2020, 3, 29, 1, 0, 0, 0, berlin))
+	assert.Equal(t, time.Date(2020, 3, 29, 3, 30, 0, 0, berlin), next, "nonexistent time is normalized")
+
+	next = Next(sched, time.Date(2020, 10, 25, 2, 45, 0, 0, berlin))
+	assert.Equal(t, time.Date(2020, 10, 26, 2, 30, 0, 0, berlin), next, "repeated time is not fired twice")
+}
+
+func TestTracker_zoneSpringForward(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// server in UTC, schedule in Europe/Berlin: 02:30 does not exist on 2020-03-29
+	start := time.Date(2020, 3, 28, 23, 0, 0, 0, time.UTC) // 00:00 CET
+	sim := newSimulation(t, "0 30 2 * * *", start)
+	sim.zone = berlin
+	sim.run(4*time.Hour, 30*time.Second)
+	require.Len(t, sim.fired, 1, "missed run is run once")
+	assert.Equal(t, time.Date(2020, 3, 29, 1, 0, 0, 0, time.UTC), sim.fired[0], "at 03:00 CEST")
+
+	sim = newSimulation(t, "0 30 2 * * *", start)
+	sim.zone = berlin
+	sim.skip = true
+	sim.run(4*time.Hour, 30*time.Second)
+	assert.Empty(t, sim.fired)
+
+	// regular days are evaluated in the zone
+	sim = newSimulation(t, "0 30 2 * * *", start)
+	sim.zone = berlin
+	sim.run(26*time.Hour, 30*time.Second)
+	require.Len(t, sim.fired, 2)
+	assert.Equal(t, time.Date(2020, 3, 30, 0, 30, 0, 0, time.UTC), sim.fired[1], "at 02:30 CEST")
+}
+
+func TestTracker_zoneFallBack(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// 02:00-03:00 is repeated on 2020-10-25: from 03:00 CEST to 02:00 CET
+	sim := newSimulation(t, "0 30 2 * * *", time.Date(2020, 10, 24, 23, 0, 0, 0, time.UTC))
+	sim.zone = berlin
+	sim.run(4*time.Hour, 30*time.Second)
+	require.Len(t, sim.fired, 1, "repeated time is not evaluated twice")
+	assert.Equal(t, time.Date(2020, 10, 25, 0, 30, 0, 0, time.UTC), sim.fired[0], "at 02:30 CEST")
+}
+
+func TestNext_zone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	sched, err := cron.Parse("0 30 2 * * *")
+	require.NoError(t, err)
+	next := Next(sched, time.Date(2020, 3, 28, 23, 0, 0, 0, time.UTC).In(berlin))
+	// nonexistent 02:30 is normalized to 03:30 CEST
+	assert.Equal(t, time.Date(2020, 3, 29, 3, 30, 0, 0, berlin), next)
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// State of scheduler kept between restarts: time of the last check and the last fire time of every schedule. Runs
+// after both of them and before start of server were missed during downtime.
+type State struct {
+	file string
+	lock sync.Mutex
+	data stateData
+}
+
+type stateData struct {
+	Checked time.Time                       `json:"checked"`
+	Fired   map[string]map[string]time.Time `json:"fired"` // lambda UID -> key of schedule -> fire time
+}
+
+// LoadState of scheduler from file. Missing file is empty state: nothing was missed
+func LoadState(file string) (*State, error) {
+	state := &State{file: file, data: stateData{Fired: map[string]map[string]time.Time{}}}
+	err := internal.ReadJson(file, &state.data)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read scheduler state: %w", err)
+	}
+	if state.data.Fired == nil {
+		state.data.Fired = map[string]map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// Since returns time after which runs of schedule of lambda were not evaluated (zero - unknown)
+func (s *State) Since(uid, key string) time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	since := s.data.Checked
+	if fired := s.data.Fired[uid][key]; fired.After(since) {
+		since = fired
+	}
+	return since
+}
+
+// Fired schedule of lambda: saved immediately, so run is not repeated after restart
+func (s *State) Fired(uid, key string, at time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fired, ok := s.data.Fired[uid]
+	if !ok {
+		fired = map[string]time.Time{}
+		s.data.Fired[uid] = fired
+	}
+	fired[key] = at
+	return s.save()
+}
+
+// Checked schedules up to the moment. Fire times of lambdas not in the list are forgotten (nil - all are kept)
+func (s *State) Checked(at time.Time, uids []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Checked = at
+	if uids != nil {
+		known := make(map[string]bool, len(uids))
+		for _, uid := range uids {
+			known[uid] = true
+		}
+		for uid := range s.data.Fired {
+			if !known[uid] {
+				delete(s.data.Fired, uid)
+			}
+		}
+	}
+	return s.save()
+}
+
+func (s *State) save() error {
+	if err := internal.AtomicWriteJson(s.file, s.data); err != nil {
+		return fmt.Errorf("save scheduler state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "scheduler.json")
+
+	state, err := LoadState(file)
+	require.NoError(t, err)
+	assert.True(t, state.Since("fn", "daily").IsZero())
+
+	checked := time.Date(2030, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, state.Checked(checked, nil))
+	require.NoError(t, state.Fired("fn", "daily", checked.Add(time.Minute)))
+	require.NoError(t, state.Fired("removed", "daily", checked.Add(time.Minute)))
+	require.NoError(t, state.Checked(checked.Add(30*time.Second), []string{"fn"}))
+
+	restored, err := LoadState(file)
+	require.NoError(t, err)
+	// fired after check
+	assert.True(t, checked.Add(time.Minute).Equal(restored.Since("fn", "daily")))
+	assert.True(t, checked.Add(30*time.Second).Equal(restored.Since("fn", "hourly")))
+	// forgotten
+	assert.True(t, checked.Add(30*time.Second).Equal(restored.Since("removed", "daily")))
+	assert.NotContains(t, restored.data.Fired, "removed")
+}
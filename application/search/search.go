@@ -0,0 +1,424 @@
+// Package search keeps full-text index of lambdas over names, descriptions, aliases and labels. Index is updated
+// incrementally before each search: only lambdas changed since the last update (by fingerprint of indexed fields)
+// are re-indexed. Index is saved to file and rebuilt if the file is missing, corrupted or doesn't fit memory budget.
+package search
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// Default memory budget of index in bytes
+const DefaultBudget = 8 * 1024 * 1024
+
+// Default number of hits
+const DefaultLimit = 20
+
+// Version of index file format, file of other version is rebuilt
+const indexVersion = 1
+
+// Weights of fields: the same word in name is more relevant than in description
+const (
+	weightName        = 4
+	weightAlias       = 3
+	weightLabel       = 2
+	weightDescription = 1
+)
+
+// Weight of match by beginning of word relative to match of the whole word
+const prefixFactor = 0.5
+
+// Estimated memory of indexed document and of every term of document (entry of document and entry of posting)
+const (
+	documentOverhead = 128
+	termOverhead     = 96
+)
+
+// Source of lambdas (see application.Platform)
+type Source interface {
+	List() []application.Definition
+}
+
+// New index of lambdas of source saved to file (empty - index is not saved) with memory budget in bytes (zero -
+// DefaultBudget). Index is loaded from file, missing or corrupted file is rebuilt by lambdas of source.
+func New(source Source, file string, budget int64) *Index {
+	if budget <= 0 {
+		budget = DefaultBudget
+	}
+	idx := &Index{source: source, file: file, budget: budget}
+	idx.reset()
+	if file != "" {
+		if err := idx.load(); os.IsNotExist(err) {
+			log.Println("search index", file, "does not exist and will be built")
+		} else if err != nil {
+			log.Println("[WARN]", "search index", file, "will be rebuilt:", err)
+			idx.reset()
+		}
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.sync(source.List())
+	return idx
+}
+
+// Full-text index of lambdas
+type Index struct {
+	source   Source
+	file     string
+	budget   int64
+	lock     sync.Mutex
+	docs     map[string]*document          // by UID
+	postings map[string]map[string]float64 // weight of term by UID of lambda
+	terms    []string                      // sorted terms for search by beginning of word (nil - outdated)
+	size     int64                         // estimated memory of documents and postings
+}
+
+// indexed lambda
+type document struct {
+	UID         string             `json:"uid"`
+	Fingerprint string             `json:"fingerprint"`       // hash of indexed fields of lambda
+	Partial     bool               `json:"partial,omitempty"` // some fields are not indexed: index is over budget
+	Terms       map[string]float64 `json:"terms"`             // sum of weights of fields with term
+}
+
+func (doc *document) size() int64 {
+	size := int64(documentOverhead + len(doc.UID) + len(doc.Fingerprint))
+	for term := range doc.Terms {
+		size += int64(len(term) + termOverhead)
+	}
+	return size
+}
+
+// index file: checksum of compact JSON of documents detects corrupted documents
+type indexFile struct {
+	Version   int             `json:"version"`
+	Checksum  string          `json:"checksum"` // SHA-256 of documents
+	Documents json.RawMessage `json:"documents"`
+}
+
+// Search lambdas by words of query. See application.Search
+func (idx *Index) Search(query string, limit int) application.SearchResult {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	defs := idx.source.List()
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.sync(defs)
+
+	result := application.SearchResult{Query: query, Hits: []application.SearchHit{}}
+	for _, doc := range idx.docs {
+		result.Incomplete = result.Incomplete || doc.Partial
+	}
+	words := queryWords(query)
+	if len(words) == 0 {
+		return result
+	}
+	var scores map[string]float64
+	for _, word := range words {
+		matched := idx.match(word)
+		if scores == nil {
+			scores = matched
+			continue
+		}
+		for uid, score := range scores {
+			if weight, ok := matched[uid]; ok {
+				scores[uid] = score + weight
+			} else {
+				delete(scores, uid)
+			}
+		}
+	}
+
+	var byUID = make(map[string]application.Definition, len(defs))
+	for _, def := range defs {
+		byUID[def.UID] = def
+	}
+	for uid, score := range scores {
+		def, ok := byUID[uid]
+		if !ok {
+			continue
+		}
+		result.Hits = append(result.Hits, application.SearchHit{
+			UID:        uid,
+			Name:       def.Manifest.Name,
+			Score:      math.Round(score*100) / 100,
+			Highlights: highlights(def, words),
+		})
+	}
+	sort.Slice(result.Hits, func(i, j int) bool {
+		a, b := result.Hits[i], result.Hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.UID < b.UID
+	})
+	result.Total = len(result.Hits)
+	if len(result.Hits) > limit {
+		result.Hits = result.Hits[:limit]
+	}
+	return result
+}
+
+// score of lambdas by word: the best matched term (the same word or longer word with the same beginning) weighted
+// by rarity of term. Should be called under lock
+func (idx *Index) match(word string) map[string]float64 {
+	var scores = make(map[string]float64)
+	add := func(term string, factor float64) {
+		postings := idx.postings[term]
+		rarity := 1 + math.Log(float64(len(idx.docs))/float64(len(postings)))
+		for uid, weight := range postings {
+			scores[uid] = math.Max(scores[uid], weight*rarity*factor)
+		}
+	}
+	if _, ok := idx.postings[word]; ok {
+		add(word, 1)
+	}
+	if len([]rune(word)) < minPrefix {
+		return scores
+	}
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+	for i := sort.SearchStrings(idx.terms, word); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], word); i++ {
+		if idx.terms[i] != word {
+			add(idx.terms[i], prefixFactor)
+		}
+	}
+	return scores
+}
+
+// update index by lambdas: changed lambdas are re-indexed, removed lambdas are removed from index. Partially
+// indexed lambdas are re-indexed if memory is released. Index is saved if changed. Should be called under lock
+func (idx *Index) sync(defs []application.Definition) {
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].UID < defs[j].UID
+	})
+	var seen = make(map[string]bool, len(defs))
+	var changed []application.Definition
+	var fingerprints = make(map[string]string, len(defs))
+	for _, def := range defs {
+		seen[def.UID] = true
+		fingerprints[def.UID] = fingerprint(def)
+		if doc, ok := idx.docs[def.UID]; !ok || doc.Fingerprint != fingerprints[def.UID] {
+			changed = append(changed, def)
+		}
+	}
+	var released bool
+	for uid := range idx.docs {
+		if !seen[uid] {
+			idx.remove(uid)
+			released = true
+		}
+	}
+	for _, def := range changed {
+		if _, ok := idx.docs[def.UID]; ok {
+			idx.remove(def.UID)
+			released = true
+		}
+	}
+	if released {
+		for _, def := range defs {
+			if doc, ok := idx.docs[def.UID]; ok && doc.Partial {
+				idx.remove(def.UID)
+				changed = append(changed, def)
+			}
+		}
+	}
+	if len(changed) == 0 && !released {
+		return
+	}
+	var partial int
+	for _, def := range changed {
+		doc := idx.document(def, fingerprints[def.UID])
+		if doc.Partial {
+			partial++
+		}
+		idx.add(doc)
+	}
+	if partial > 0 {
+		log.Println("[WARN]", "search index is over memory budget", idx.budget, "bytes:", partial, "lambdas are indexed partially")
+	}
+	if err := idx.save(); err != nil {
+		log.Println("[WARN]", "save search index", idx.file, "-", err)
+	}
+}
+
+// index fields of lambda in order of weight while document fits memory budget. Should be called under lock
+func (idx *Index) document(def application.Definition, fingerprint string) *document {
+	doc := &document{UID: def.UID, Fingerprint: fingerprint, Terms: make(map[string]float64)}
+	fields := []struct {
+		weight float64
+		texts  []string
+	}{
+		{weightName, []string{def.Manifest.Name}},
+		{weightAlias, sortedAliases(def)},
+		{weightLabel, def.Manifest.Labels},
+		{weightDescription, []string{def.Manifest.Description}},
+	}
+	for _, field := range fields {
+		var terms = make(map[string]bool)
+		for _, text := range field.texts {
+			for _, token := range tokenize(text) {
+				terms[token.word] = true
+			}
+		}
+		var extra = make(map[string]float64, len(doc.Terms)+len(terms))
+		for term, weight := range doc.Terms {
+			extra[term] = weight
+		}
+		for term := range terms {
+			extra[term] += field.weight
+		}
+		candidate := &document{UID: doc.UID, Fingerprint: doc.Fingerprint, Terms: extra}
+		if idx.size+candidate.size() > idx.budget {
+			doc.Partial = true
+			break
+		}
+		doc.Terms = extra
+	}
+	return doc
+}
+
+// Should be called under lock
+func (idx *Index) add(doc *document) {
+	idx.docs[doc.UID] = doc
+	for term, weight := range doc.Terms {
+		postings, ok := idx.postings[term]
+		if !ok {
+			postings = make(map[string]float64)
+			idx.postings[term] = postings
+			idx.terms = nil
+		}
+		postings[doc.UID] = weight
+	}
+	idx.size += doc.size()
+}
+
+// Should be called under lock
+func (idx *Index) remove(uid string) {
+	doc, ok := idx.docs[uid]
+	if !ok {
+		return
+	}
+	for term := range doc.Terms {
+		postings := idx.postings[term]
+		delete(postings, uid)
+		if len(postings) == 0 {
+			delete(idx.postings, term)
+			idx.terms = nil
+		}
+	}
+	delete(idx.docs, uid)
+	idx.size -= doc.size()
+}
+
+func (idx *Index) reset() {
+	idx.docs = make(map[string]*document)
+	idx.postings = make(map[string]map[string]float64)
+	idx.terms = nil
+	idx.size = 0
+}
+
+// load documents from file. Corrupted file or file over memory budget is error
+func (idx *Index) load() error {
+	data, err := ioutil.ReadFile(idx.file)
+	if err != nil {
+		return err
+	}
+	var content indexFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("decode index: %w", err)
+	}
+	if content.Version != indexVersion {
+		return fmt.Errorf("unsupported version %d of index (supported %d)", content.Version, indexVersion)
+	}
+	var documents bytes.Buffer
+	if err := json.Compact(&documents, content.Documents); err != nil {
+		return fmt.Errorf("decode documents: %w", err)
+	}
+	if checksum(documents.Bytes()) != content.Checksum {
+		return errors.New("checksum mismatch: index is corrupted")
+	}
+	var docs []*document
+	if err := json.Unmarshal(content.Documents, &docs); err != nil {
+		return fmt.Errorf("decode documents: %w", err)
+	}
+	for _, doc := range docs {
+		if doc == nil || doc.UID == "" || idx.docs[doc.UID] != nil {
+			return errors.New("invalid document in index")
+		}
+		idx.add(doc)
+	}
+	if idx.size > idx.budget {
+		return fmt.Errorf("index (%d bytes) is over memory budget (%d bytes)", idx.size, idx.budget)
+	}
+	return nil
+}
+
+// save documents to file atomically (if file is set). Should be called under lock
+func (idx *Index) save() error {
+	if idx.file == "" {
+		return nil
+	}
+	var docs = make([]*document, 0, len(idx.docs))
+	for _, doc := range idx.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].UID < docs[j].UID
+	})
+	documents, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.file), 0755); err != nil {
+		return err
+	}
+	return internal.AtomicWriteJson(idx.file, &indexFile{Version: indexVersion, Checksum: checksum(documents), Documents: documents})
+}
+
+// hash of indexed fields of lambda
+func fingerprint(def application.Definition) string {
+	var parts = []string{def.Manifest.Name, def.Manifest.Description}
+	parts = append(parts, sortedAliases(def)...)
+	parts = append(parts, "")
+	parts = append(parts, def.Manifest.Labels...)
+	return checksum([]byte(strings.Join(parts, "\x00")))
+}
+
+func checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func sortedAliases(def application.Definition) []string {
+	var aliases = make([]string, 0, len(def.Aliases))
+	for alias := range def.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
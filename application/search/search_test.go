@@ -0,0 +1,156 @@
+package search_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/search"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type lambdas struct {
+	lock sync.Mutex
+	defs map[string]application.Definition
+}
+
+func (l *lambdas) List() []application.Definition {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var ans []application.Definition
+	for _, def := range l.defs {
+		ans = append(ans, def)
+	}
+	return ans
+}
+
+func (l *lambdas) set(uid string, manifest types.Manifest, aliases ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.defs == nil {
+		l.defs = make(map[string]application.Definition)
+	}
+	l.defs[uid] = application.Definition{UID: uid, Manifest: manifest, Aliases: types.StringSet(aliases...)}
+}
+
+func (l *lambdas) remove(uid string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.defs, uid)
+}
+
+func uids(result application.SearchResult) []string {
+	var ans = []string{}
+	for _, hit := range result.Hits {
+		ans = append(ans, hit.UID)
+	}
+	return ans
+}
+
+func TestIndex_Search(t *testing.T) {
+	var source lambdas
+	source.set("a1", types.Manifest{Name: "Jira sync", Description: "Copies issues from Jira to the tracker"}, "jira-sync")
+	source.set("b2", types.Manifest{Name: "Tracker export", Description: "Synchronization of tracker with Jira boards", Labels: []string{"jira"}})
+	source.set("c3", types.Manifest{Name: "Mail digest", Labels: []string{"sync"}})
+	idx := search.New(&source, "", 0)
+
+	result := idx.Search("jira sync", 0)
+	assert.Equal(t, []string{"a1", "b2"}, uids(result), "all words should match, name is more relevant")
+	assert.Equal(t, 2, result.Total)
+	assert.False(t, result.Incomplete)
+	assert.Greater(t, result.Hits[0].Score, result.Hits[1].Score)
+	assert.Equal(t, []application.SearchHighlight{
+		{Field: "name", Fragments: []application.SnippetFragment{{Text: "Jira", Match: true}, {Text: " "}, {Text: "sync", Match: true}}},
+		{Field: "alias", Fragments: []application.SnippetFragment{{Text: "jira", Match: true}, {Text: "-"}, {Text: "sync", Match: true}}},
+		{Field: "description", Fragments: []application.SnippetFragment{{Text: "Copies issues from "}, {Text: "Jira", Match: true}, {Text: " to the tracker"}}},
+	}, result.Hits[0].Highlights)
+	assert.Equal(t, []application.SearchHighlight{
+		{Field: "label", Fragments: []application.SnippetFragment{{Text: "jira", Match: true}}},
+		{Field: "description", Fragments: []application.SnippetFragment{{Text: "Synchronization", Match: true}, {Text: " of tracker with "}, {Text: "Jira", Match: true}, {Text: " boards"}}},
+	}, result.Hits[1].Highlights, "beginning of word is matched")
+
+	assert.Equal(t, []string{"a1", "c3", "b2"}, uids(idx.Search("SYNC", 0)), "whole word in label is more relevant than beginning of word in description")
+	assert.Equal(t, []string{"a1"}, uids(idx.Search("jira", 1)))
+	assert.Equal(t, 2, idx.Search("jira", 1).Total)
+	assert.Empty(t, uids(idx.Search("s", 0)), "single letter matches only the whole word")
+	assert.Empty(t, uids(idx.Search("  ", 0)))
+
+	// changes are indexed before search
+	source.set("c3", types.Manifest{Name: "Mail digest", Labels: []string{"mail"}})
+	source.remove("a1")
+	source.set("d4", types.Manifest{Name: "Jira sync v2"})
+	assert.Equal(t, []string{"d4", "b2"}, uids(idx.Search("jira sync", 0)))
+	assert.Equal(t, []string{"c3"}, uids(idx.Search("mail", 0)))
+}
+
+func TestIndex_snippet(t *testing.T) {
+	var source lambdas
+	description := strings.Repeat("lorem ipsum ", 20) + "dolor sit amet " + strings.Repeat("lorem ipsum ", 20)
+	source.set("a1", types.Manifest{Name: "long", Description: description})
+	idx := search.New(&source, "", 0)
+
+	result := idx.Search("dolor", 0)
+	require.Len(t, result.Hits, 1)
+	fragments := result.Hits[0].Highlights[0].Fragments
+	require.Len(t, fragments, 3)
+	assert.True(t, strings.HasPrefix(fragments[0].Text, "…"))
+	assert.Equal(t, application.SnippetFragment{Text: "dolor", Match: true}, fragments[1])
+	assert.True(t, strings.HasSuffix(fragments[2].Text, "…"))
+	assert.Less(t, len(fragments[0].Text+fragments[1].Text+fragments[2].Text), len(description))
+}
+
+func TestIndex_persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "index.json")
+
+	var source lambdas
+	source.set("a1", types.Manifest{Name: "Jira sync"})
+	idx := search.New(&source, file, 0)
+	assert.Equal(t, []string{"a1"}, uids(idx.Search("jira", 0)))
+	assert.FileExists(t, file)
+
+	// index is loaded and updated by changes made while server was stopped
+	source.set("b2", types.Manifest{Name: "Jira export"})
+	idx = search.New(&source, file, 0)
+	assert.Equal(t, []string{"b2", "a1"}, uids(idx.Search("jira", 0)), "ordered by name")
+
+	// corrupted index is rebuilt
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(file, []byte(strings.Replace(string(data), "jira", "mail", 1)), 0644))
+	idx = search.New(&source, file, 0)
+	assert.Equal(t, []string{"b2", "a1"}, uids(idx.Search("jira", 0)), "ordered by name")
+	assert.Empty(t, uids(idx.Search("mail", 0)))
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("{"), 0644))
+	idx = search.New(&source, file, 0)
+	assert.Equal(t, []string{"b2", "a1"}, uids(idx.Search("jira", 0)), "ordered by name")
+}
+
+func TestIndex_budget(t *testing.T) {
+	var source lambdas
+	source.set("a1", types.Manifest{Name: "Jira sync", Description: "tracker"})
+	source.set("b2", types.Manifest{Name: "Tracker export", Description: "Export of tracker boards"})
+	idx := search.New(&source, "", 900)
+
+	// fields are indexed by weight while index fits budget
+	result := idx.Search("tracker", 0)
+	assert.True(t, result.Incomplete)
+	assert.Equal(t, []string{"b2", "a1"}, uids(result))
+	assert.Empty(t, uids(idx.Search("boards", 0)), "description of the second lambda is not indexed")
+
+	// released memory is used by partially indexed lambdas
+	source.remove("a1")
+	result = idx.Search("boards", 0)
+	assert.False(t, result.Incomplete)
+	assert.Equal(t, []string{"b2"}, uids(result))
+}
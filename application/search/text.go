@@ -0,0 +1,164 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// Minimal length of word (in characters) to match beginning of longer words
+const minPrefix = 2
+
+// Maximum length of indexed term in characters, longer words are cut
+const maxTerm = 64
+
+// Length of description snippet and context before the first matched word (in characters)
+const (
+	snippetLength  = 160
+	snippetContext = 40
+)
+
+// word of text: lower-cased term and position in text (bytes)
+type token struct {
+	word       string
+	start, end int
+}
+
+// split text to words: sequences of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text + " " {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokens = append(tokens, token{word: term(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+func term(word string) string {
+	word = strings.ToLower(word)
+	if utf8.RuneCountInString(word) <= maxTerm {
+		return word
+	}
+	return string([]rune(word)[:maxTerm])
+}
+
+// unique words of query
+func queryWords(query string) []string {
+	var words []string
+	var seen = make(map[string]bool)
+	for _, token := range tokenize(query) {
+		if !seen[token.word] {
+			seen[token.word] = true
+			words = append(words, token.word)
+		}
+	}
+	return words
+}
+
+// word (term) is matched by query word as the same word or by beginning
+func matches(word string, words []string) bool {
+	for _, query := range words {
+		if word == query || (utf8.RuneCountInString(query) >= minPrefix && strings.HasPrefix(word, query)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matched fields of lambda with highlighted words
+func highlights(def application.Definition, words []string) []application.SearchHighlight {
+	var ans []application.SearchHighlight
+	add := func(field, text string, snippet bool) {
+		if fragments := highlight(text, words, snippet); fragments != nil {
+			ans = append(ans, application.SearchHighlight{Field: field, Fragments: fragments})
+		}
+	}
+	add("name", def.Manifest.Name, false)
+	for _, alias := range sortedAliases(def) {
+		add("alias", alias, false)
+	}
+	for _, label := range def.Manifest.Labels {
+		add("label", label, false)
+	}
+	add("description", def.Manifest.Description, true)
+	return ans
+}
+
+// split text to fragments with matched words (nil - nothing matched). Snippet is part of text around the first
+// matched word
+func highlight(text string, words []string, snippet bool) []application.SnippetFragment {
+	tokens := tokenize(text)
+	first := -1
+	for i, token := range tokens {
+		if matches(token.word, words) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	start, end := 0, len(text)
+	var prefix, suffix string
+	if snippet && utf8.RuneCountInString(text) > snippetLength {
+		start, end = snippetBounds(text, tokens, first)
+		if start > 0 {
+			prefix = "…"
+		}
+		if end < len(text) {
+			suffix = "…"
+		}
+	}
+	var fragments []application.SnippetFragment
+	plain := func(value string) {
+		if value == "" {
+			return
+		}
+		if n := len(fragments); n > 0 && !fragments[n-1].Match {
+			fragments[n-1].Text += value
+			return
+		}
+		fragments = append(fragments, application.SnippetFragment{Text: value})
+	}
+	plain(prefix)
+	pos := start
+	for _, token := range tokens {
+		if token.start < start || token.end > end || !matches(token.word, words) {
+			continue
+		}
+		plain(text[pos:token.start])
+		fragments = append(fragments, application.SnippetFragment{Text: text[token.start:token.end], Match: true})
+		pos = token.end
+	}
+	plain(text[pos:end])
+	plain(suffix)
+	return fragments
+}
+
+// bounds of snippet (bytes) by whole words: from context before the first matched word up to snippet length
+func snippetBounds(text string, tokens []token, first int) (int, int) {
+	start := tokens[first].start
+	for i := first; i >= 0 && utf8.RuneCountInString(text[tokens[i].start:tokens[first].start]) <= snippetContext; i-- {
+		start = tokens[i].start
+	}
+	end := tokens[first].end
+	for i := first; i < len(tokens) && utf8.RuneCountInString(text[start:tokens[i].end]) <= snippetLength; i++ {
+		end = tokens[i].end
+	}
+	if start == tokens[0].start {
+		start = 0
+	}
+	if end == tokens[len(tokens)-1].end {
+		end = len(text)
+	}
+	return start, end
+}
@@ -0,0 +1,236 @@
+// Package secrets is encrypted store of named secrets referenced by environment of lambdas (see
+// types.SecretReference). Values are sealed by AES-256-GCM with key derived by scrypt from key material of server
+// (flag or file) and random salt of store, so file of store (and backups of project directory) has no plain values.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// value sealed in store to detect wrong key on open
+const checkPlain = "trusted-cgi secrets"
+
+// ErrInvalidKey means that store was sealed by another key
+var ErrInvalidKey = errors.New("invalid key of secrets store")
+
+// Open store of secrets by key material. Missing file is empty store (created by the first secret). Key of existent
+// store is verified
+func Open(file string, key []byte) (*Store, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty key of secrets store")
+	}
+	store := &Store{file: file, key: key}
+	err := internal.ReadJson(file, &store.data)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read secrets store: %w", err)
+	}
+	if os.IsNotExist(err) {
+		store.data.Salt = make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, store.data.Salt); err != nil {
+			return nil, fmt.Errorf("generate salt: %w", err)
+		}
+	}
+	if store.data.Secrets == nil {
+		store.data.Secrets = make(map[string]*sealed)
+	}
+	store.aead, err = deriveCipher(key, store.data.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if store.data.Check == "" {
+		store.data.Check, err = store.seal("", checkPlain)
+		if err != nil {
+			return nil, err
+		}
+	} else if plain, err := store.open("", store.data.Check); err != nil || plain != checkPlain {
+		return nil, ErrInvalidKey
+	}
+	return store, nil
+}
+
+// Store of secrets in JSON file: only names and times are stored in plain text
+type Store struct {
+	file string
+	key  []byte // key material to open snapshots of other stores (see Unseal)
+	aead cipher.AEAD
+	lock sync.RWMutex
+	data storeData
+}
+
+type storeData struct {
+	Salt    []byte             `json:"salt"`  // salt of key derivation
+	Check   string             `json:"check"` // sealed known value
+	Secrets map[string]*sealed `json:"secrets"`
+}
+
+type sealed struct {
+	Value   string    `json:"value"` // base64 of nonce and ciphertext, name is authenticated data
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+func (st *Store) List() []application.Secret {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	var ans = make([]application.Secret, 0, len(st.data.Secrets))
+	for name, item := range st.data.Secrets {
+		ans = append(ans, application.Secret{Name: name, Created: item.Created, Updated: item.Updated})
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Name < ans[j].Name
+	})
+	return ans
+}
+
+func (st *Store) Set(name, value string) (*application.Secret, error) {
+	if !types.SecretNameReg.MatchString(name) {
+		return nil, fmt.Errorf("secret name is not valid name - %s", types.SecretNameReg.String())
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	text, err := st.seal(name, value)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	item, ok := st.data.Secrets[name]
+	if !ok {
+		item = &sealed{Created: now}
+		st.data.Secrets[name] = item
+	}
+	previous := *item
+	item.Value, item.Updated = text, now
+	if err := st.save(); err != nil {
+		if ok {
+			*item = previous
+		} else {
+			delete(st.data.Secrets, name)
+		}
+		return nil, err
+	}
+	return &application.Secret{Name: name, Created: item.Created, Updated: item.Updated}, nil
+}
+
+func (st *Store) Remove(name string) (bool, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	item, ok := st.data.Secrets[name]
+	if !ok {
+		return false, nil
+	}
+	delete(st.data.Secrets, name)
+	if err := st.save(); err != nil {
+		st.data.Secrets[name] = item
+		return false, err
+	}
+	return true, nil
+}
+
+func (st *Store) Value(name string) (string, bool) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	item, ok := st.data.Secrets[name]
+	if !ok {
+		return "", false
+	}
+	value, err := st.open(name, item.Value)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// Snapshot of store for backup: values are sealed as in file of store
+func (st *Store) Snapshot() ([]byte, error) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return json.Marshal(st.data)
+}
+
+// Unseal values of snapshot (see Snapshot) of this or another store by key material of this store. Snapshot sealed by
+// another key is ErrInvalidKey
+func (st *Store) Unseal(snapshot []byte) (map[string]string, error) {
+	var data storeData
+	if err := json.Unmarshal(snapshot, &data); err != nil {
+		return nil, fmt.Errorf("decode snapshot of secrets store: %w", err)
+	}
+	other := &Store{data: data}
+	var err error
+	other.aead, err = deriveCipher(st.key, data.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if plain, err := other.open("", data.Check); err != nil || plain != checkPlain {
+		return nil, ErrInvalidKey
+	}
+	var ans = make(map[string]string, len(data.Secrets))
+	for name, item := range data.Secrets {
+		value, err := other.open(name, item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("open secret %s: %w", name, err)
+		}
+		ans[name] = value
+	}
+	return ans, nil
+}
+
+// should be called under lock
+func (st *Store) save() error {
+	if err := internal.AtomicWriteJson(st.file, st.data); err != nil {
+		return fmt.Errorf("save secrets store: %w", err)
+	}
+	return nil
+}
+
+func (st *Store) seal(name, value string) (string, error) {
+	nonce := make([]byte, st.aead.NonceSize(), st.aead.NonceSize()+len(value)+st.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(st.aead.Seal(nonce, nonce, []byte(value), []byte(name))), nil
+}
+
+func (st *Store) open(name, text string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < st.aead.NonceSize() {
+		return "", fmt.Errorf("sealed value is too short")
+	}
+	nonce, ciphertext := data[:st.aead.NonceSize()], data[st.aead.NonceSize():]
+	plain, err := st.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func deriveCipher(key, salt []byte) (cipher.AEAD, error) {
+	derived, err := scrypt.Key(key, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
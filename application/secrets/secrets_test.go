@@ -0,0 +1,88 @@
+package secrets_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application/secrets"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "secrets.json")
+
+	store, err := secrets.Open(file, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.Empty(t, store.List())
+
+	_, err = store.Set("../key", "value")
+	assert.Error(t, err)
+	first, err := store.Set("stripe_key", "sk_live_123")
+	require.NoError(t, err)
+	second, err := store.Set("stripe_key", "sk_live_456")
+	require.NoError(t, err)
+	assert.Equal(t, first.Created, second.Created)
+	assert.False(t, second.Updated.Before(first.Updated))
+	_, err = store.Set("db.password", "hunter2")
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "sk_live")
+	assert.NotContains(t, string(content), "hunter2")
+
+	reopened, err := secrets.Open(file, []byte("passphrase"))
+	require.NoError(t, err)
+	list := reopened.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, "db.password", list[0].Name)
+	assert.Equal(t, "stripe_key", list[1].Name)
+	value, ok := reopened.Value("stripe_key")
+	assert.True(t, ok)
+	assert.Equal(t, "sk_live_456", value)
+
+	_, err = secrets.Open(file, []byte("another"))
+	assert.ErrorIs(t, err, secrets.ErrInvalidKey)
+
+	removed, err := reopened.Remove("stripe_key")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = reopened.Remove("stripe_key")
+	require.NoError(t, err)
+	assert.False(t, removed)
+	_, ok = reopened.Value("stripe_key")
+	assert.False(t, ok)
+}
+
+func TestStore_Unseal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source, err := secrets.Open(filepath.Join(dir, "source.json"), []byte("passphrase"))
+	require.NoError(t, err)
+	_, err = source.Set("stripe_key", "sk_live_123")
+	require.NoError(t, err)
+	snapshot, err := source.Snapshot()
+	require.NoError(t, err)
+	assert.NotContains(t, string(snapshot), "sk_live")
+
+	// store of another server with the same key (own salt)
+	destination, err := secrets.Open(filepath.Join(dir, "destination.json"), []byte("passphrase"))
+	require.NoError(t, err)
+	values, err := destination.Unseal(snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"stripe_key": "sk_live_123"}, values)
+
+	other, err := secrets.Open(filepath.Join(dir, "other.json"), []byte("other"))
+	require.NoError(t, err)
+	_, err = other.Unseal(snapshot)
+	assert.ErrorIs(t, err, secrets.ErrInvalidKey)
+}
@@ -0,0 +1,66 @@
+package application
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Maximum length of slug (DNS label)
+const MaxSlugLength = 63
+
+// Slug of name: lowercase latin letters and digits separated by single dashes, not longer than DNS label.
+// Empty if name has no latin letters or digits.
+func Slug(name string) string {
+	var out strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && out.Len() > 0 {
+				out.WriteByte('-')
+			}
+			dash = false
+			out.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	return trimSlug(out.String(), MaxSlugLength)
+}
+
+// SlugCandidate is the n-th candidate of unique slug: base itself for the first candidate, otherwise base with
+// numeric suffix (ex: app-2). Base is truncated to fit suffix.
+func SlugCandidate(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+	suffix := "-" + strconv.Itoa(n)
+	return trimSlug(base, MaxSlugLength-len(suffix)) + suffix
+}
+
+// SlugMatches returns true if slug is one of candidates of slug of the name.
+func SlugMatches(slug, name string) bool {
+	base := Slug(name)
+	if base == "" {
+		return false
+	}
+	if slug == base {
+		return true
+	}
+	idx := strings.LastIndexByte(slug, '-')
+	if idx < 0 {
+		return false
+	}
+	n, err := strconv.Atoi(slug[idx+1:])
+	if err != nil || n < 2 {
+		return false
+	}
+	return SlugCandidate(base, n) == slug
+}
+
+func trimSlug(slug string, size int) string {
+	if len(slug) > size {
+		slug = slug[:size]
+	}
+	return strings.TrimRight(slug, "-")
+}
@@ -0,0 +1,41 @@
+package application_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+func TestSlug(t *testing.T) {
+	assert.Equal(t, "my-app", application.Slug("My App"))
+	assert.Equal(t, "hello-world-2", application.Slug("  Hello, World! #2 "))
+	assert.Equal(t, "caf-bar", application.Slug("Café_Bar"))
+	assert.Equal(t, "", application.Slug("Кириллица"))
+	assert.Equal(t, "", application.Slug(""))
+
+	long := application.Slug(strings.Repeat("ab ", 40))
+	assert.Len(t, long, 62, "trailing dash of truncated slug is removed")
+	assert.False(t, strings.HasSuffix(long, "-"))
+}
+
+func TestSlugCandidate(t *testing.T) {
+	assert.Equal(t, "app", application.SlugCandidate("app", 1))
+	assert.Equal(t, "app-2", application.SlugCandidate("app", 2))
+
+	base := strings.Repeat("a", application.MaxSlugLength)
+	candidate := application.SlugCandidate(base, 10)
+	assert.Len(t, candidate, application.MaxSlugLength)
+	assert.True(t, strings.HasSuffix(candidate, "-10"))
+}
+
+func TestSlugMatches(t *testing.T) {
+	assert.True(t, application.SlugMatches("my-app", "My App"))
+	assert.True(t, application.SlugMatches("my-app-3", "My App"))
+	assert.False(t, application.SlugMatches("my-app-03", "My App"))
+	assert.False(t, application.SlugMatches("my-app-1", "My App"))
+	assert.False(t, application.SlugMatches("my-app", "Other"))
+	assert.False(t, application.SlugMatches("", ""))
+}
@@ -0,0 +1,134 @@
+package statuspage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Defaults of page
+const (
+	DefaultWindow   = time.Hour        // window of error rate
+	DefaultDegraded = 0.05             // error rate of degraded lambda
+	DefaultDown     = 0.5              // error rate of lambda which is down
+	DefaultRefresh  = 30 * time.Second // interval of snapshot refresh
+)
+
+// Page name limitations: part of URL path
+var NameReg = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Status page of group of lambdas selected by labels
+type Page struct {
+	Name     string             `json:"name"`               // served on /status/<name> (HTML) and /status/<name>.json
+	Title    string             `json:"title,omitempty"`    // caption of page (empty - name)
+	Enabled  bool               `json:"enabled"`            // disabled page is not found (404)
+	Labels   []string           `json:"labels"`             // lambdas with all labels are on the page
+	Window   types.JsonDuration `json:"window,omitempty"`   // window of error rate (zero - DefaultWindow)
+	Degraded float64            `json:"degraded,omitempty"` // minimal error rate of degraded lambda (zero - DefaultDegraded)
+	Down     float64            `json:"down,omitempty"`     // minimal error rate of lambda which is down (zero - DefaultDown)
+	Refresh  types.JsonDuration `json:"refresh,omitempty"`  // interval of snapshot refresh (zero - DefaultRefresh)
+}
+
+// configuration file of status pages
+type config struct {
+	Pages []Page `json:"pages"`
+}
+
+// Load and validate status pages from JSON file
+func Load(file string) ([]Page, error) {
+	var cfg config
+	if err := internal.ReadJson(file, &cfg); err != nil {
+		return nil, fmt.Errorf("read status pages: %w", err)
+	}
+	var seen = make(map[string]bool, len(cfg.Pages))
+	var problems []error
+	for i, page := range cfg.Pages {
+		if err := page.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("page #%d %s: %w", i, page.Name, err))
+		} else if seen[page.Name] {
+			problems = append(problems, fmt.Errorf("page #%d %s: duplicated name", i, page.Name))
+		}
+		seen[page.Name] = true
+	}
+	if err := errors.Join(problems...); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return cfg.Pages, nil
+}
+
+// Validate page options
+func (p *Page) Validate() error {
+	var problems []error
+	if !NameReg.MatchString(p.Name) {
+		problems = append(problems, errors.New("name should be lowercase latin letters, digits and dashes (up to 64 characters)"))
+	}
+	if len(p.Labels) == 0 {
+		problems = append(problems, errors.New("labels should be set: page without selector would expose all lambdas"))
+	}
+	if p.Window < 0 || p.Refresh < 0 {
+		problems = append(problems, errors.New("window and refresh should not be negative"))
+	}
+	if p.Degraded < 0 || p.Down < 0 || p.Down > 1 {
+		problems = append(problems, errors.New("thresholds of error rate should be between 0 and 1"))
+	} else if p.DegradedRate() > p.DownRate() {
+		problems = append(problems, errors.New("threshold of degraded should not be greater than threshold of down"))
+	}
+	return errors.Join(problems...)
+}
+
+// Window of error rate: window or default
+func (p *Page) WindowDuration() time.Duration {
+	if p.Window > 0 {
+		return time.Duration(p.Window)
+	}
+	return DefaultWindow
+}
+
+// Interval of snapshot refresh: refresh or default
+func (p *Page) RefreshInterval() time.Duration {
+	if p.Refresh > 0 {
+		return time.Duration(p.Refresh)
+	}
+	return DefaultRefresh
+}
+
+// Minimal error rate of degraded lambda: degraded or default
+func (p *Page) DegradedRate() float64 {
+	if p.Degraded > 0 {
+		return p.Degraded
+	}
+	return DefaultDegraded
+}
+
+// Minimal error rate of lambda which is down: down or default
+func (p *Page) DownRate() float64 {
+	if p.Down > 0 {
+		return p.Down
+	}
+	return DefaultDown
+}
+
+func (p *Page) caption() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return p.Name
+}
+
+// lambda has all labels of page
+func (p *Page) selects(manifest types.Manifest) bool {
+	var labels = make(map[string]bool, len(manifest.Labels))
+	for _, label := range manifest.Labels {
+		labels[label] = true
+	}
+	for _, label := range p.Labels {
+		if !labels[label] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,265 @@
+// Package statuspage serves public (unauthenticated) status pages of groups of lambdas selected by labels: health by
+// alert rules (including probes of circuit breakers), band of recent error rate and time of the last incident.
+// Pages are rendered to snapshots refreshed by interval, so requests are served from memory without access to
+// lambdas and stats. Snapshots contain only names of lambdas: no UIDs, aliases, errors or other internal details.
+package statuspage
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Maximum number of stats records of lambda used for error rate and the last incident
+const maxRecords = 10000
+
+// Statuses of lambda and page
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// Name of lambda without name on page
+const unnamed = "unnamed"
+
+//go:embed page.html
+var pageSource string
+
+var pageTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}).Parse(pageSource))
+
+// Minimal required platform features
+type Platform interface {
+	List() []application.Definition
+}
+
+// Minimal required alerts features
+type Alerts interface {
+	Status(uid string) []application.AlertStatus
+}
+
+// Public snapshot of status page
+type Snapshot struct {
+	Title     string    `json:"title"`
+	Status    string    `json:"status"` // the worst status of lambdas, ok for empty page
+	Generated time.Time `json:"generated"`
+	Refresh   int       `json:"refresh"` // interval of refresh in seconds
+	Services  []Service `json:"services"`
+}
+
+// Public status of lambda
+type Service struct {
+	Name         string     `json:"name"`
+	Status       string     `json:"status"`                  // the worst of health and errors
+	Health       string     `json:"health"`                  // by alert rules: down - lambda is disabled or circuit is open, degraded - other fired rules and probes of circuit
+	Errors       string     `json:"errors"`                  // band of error rate in window by thresholds of page
+	LastIncident *time.Time `json:"last_incident,omitempty"` // the last failed invocation or fired alert rule
+}
+
+// New status pages of lambdas. Disabled pages are not served. Alerts are optional.
+func New(platform Platform, tracker stats.Reader, alerts Alerts, pages []Page) *Pages {
+	ps := &Pages{platform: platform, tracker: tracker, alerts: alerts, pages: make(map[string]*page)}
+	for _, p := range pages {
+		if p.Enabled {
+			ps.pages[p.Name] = &page{Page: p}
+		}
+	}
+	return ps
+}
+
+// Status pages served by HTTP (mounted without prefix): <name> - HTML, <name>.json - JSON
+type Pages struct {
+	platform Platform
+	tracker  stats.Reader
+	alerts   Alerts
+	pages    map[string]*page // enabled pages by name
+}
+
+type page struct {
+	Page
+	lock     sync.RWMutex
+	html     []byte // rendered snapshot, nil - not rendered yet
+	document []byte
+}
+
+// Render snapshots of pages and refresh them by interval of page in background till context is done
+func (ps *Pages) Start(ctx context.Context) {
+	for _, p := range ps.pages {
+		ps.render(p, time.Now())
+		go func(p *page) {
+			t := time.NewTicker(p.RefreshInterval())
+			defer t.Stop()
+			for {
+				select {
+				case now := <-t.C:
+					ps.render(p, now)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(p)
+	}
+}
+
+func (ps *Pages) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, asJSON := strings.CutSuffix(strings.TrimPrefix(request.URL.Path, "/"), ".json")
+	p, ok := ps.pages[name]
+	if !ok {
+		http.NotFound(writer, request)
+		return
+	}
+	p.lock.RLock()
+	content, contentType := p.html, "text/html; charset=utf-8"
+	if asJSON {
+		content, contentType = p.document, "application/json"
+	}
+	p.lock.RUnlock()
+	if content == nil {
+		http.Error(writer, "status is not ready", http.StatusServiceUnavailable)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	writer.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(p.RefreshInterval()/time.Second)))
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(http.StatusOK)
+	if request.Method == http.MethodGet {
+		_, _ = writer.Write(content)
+	}
+}
+
+// render snapshot of page to HTML and JSON
+func (ps *Pages) render(p *page, now time.Time) {
+	snapshot := ps.Snapshot(&p.Page, now)
+	var html bytes.Buffer
+	if err := pageTemplate.Execute(&html, snapshot); err != nil {
+		log.Println("[ERROR]", "render status page", p.Name, "-", err)
+		return
+	}
+	document, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Println("[ERROR]", "render status page", p.Name, "-", err)
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.html = html.Bytes()
+	p.document = document
+}
+
+// Snapshot of page at the moment
+func (ps *Pages) Snapshot(p *Page, now time.Time) Snapshot {
+	snapshot := Snapshot{
+		Title:     p.caption(),
+		Status:    StatusOK,
+		Generated: now,
+		Refresh:   int(p.RefreshInterval() / time.Second),
+		Services:  []Service{},
+	}
+	for _, def := range ps.platform.List() {
+		if !p.selects(def.Manifest) {
+			continue
+		}
+		service := ps.service(p, def, now)
+		snapshot.Status = worst(snapshot.Status, service.Status)
+		snapshot.Services = append(snapshot.Services, service)
+	}
+	sort.SliceStable(snapshot.Services, func(i, j int) bool {
+		return snapshot.Services[i].Name < snapshot.Services[j].Name
+	})
+	return snapshot
+}
+
+func (ps *Pages) service(p *Page, def application.Definition, now time.Time) Service {
+	service := Service{Name: def.Manifest.Name, Health: StatusOK, Errors: StatusOK}
+	if service.Name == "" {
+		service.Name = unnamed
+	}
+	var incident time.Time
+	if ps.alerts != nil {
+		for _, alert := range ps.alerts.Status(def.UID) {
+			service.Health = worst(service.Health, health(alert))
+			for _, event := range alert.Events {
+				if event.To == application.AlertFiring && event.Time.After(incident) {
+					incident = event.Time
+				}
+			}
+		}
+	}
+	records, err := ps.tracker.LastByUID(def.UID, maxRecords)
+	if err != nil {
+		log.Println("[WARN]", "status page", p.Name, "- read stats of", def.UID, "-", err)
+	}
+	since := now.Add(-p.WindowDuration())
+	var invocations, failed int
+	for _, record := range records {
+		if record.Rejected {
+			continue // rejected by policy or content type of request: not a problem of lambda
+		}
+		if record.Failed() && record.End.After(incident) {
+			incident = record.End
+		}
+		if record.Begin.Before(since) {
+			continue
+		}
+		invocations += record.Weight()
+		if record.Failed() {
+			failed += record.Weight()
+		}
+	}
+	if invocations > 0 {
+		rate := float64(failed) / float64(invocations)
+		switch {
+		case rate >= p.DownRate():
+			service.Errors = StatusDown
+		case rate >= p.DegradedRate():
+			service.Errors = StatusDegraded
+		}
+	}
+	if !incident.IsZero() {
+		service.LastIncident = &incident
+	}
+	service.Status = worst(service.Health, service.Errors)
+	return service
+}
+
+// health of lambda by alert rule
+func health(alert application.AlertStatus) string {
+	switch {
+	case alert.State == application.AlertFiring && (alert.Action == types.AlertDisable || alert.Action == types.AlertBreak):
+		return StatusDown
+	case alert.State == application.AlertFiring || alert.State == application.AlertHalfOpen:
+		return StatusDegraded
+	default:
+		return StatusOK
+	}
+}
+
+var severity = map[string]int{StatusOK: 0, StatusDegraded: 1, StatusDown: 2}
+
+func worst(a, b string) string {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,156 @@
+package statuspage_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/statuspage"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type lambdas []application.Definition
+
+func (l lambdas) List() []application.Definition { return l }
+
+type alerts map[string][]application.AlertStatus
+
+func (a alerts) Status(uid string) []application.AlertStatus { return a[uid] }
+
+func TestPages_Snapshot(t *testing.T) {
+	now := time.Now()
+	platform := lambdas{
+		{UID: "a1", Manifest: types.Manifest{Name: "Billing API", Labels: []string{"billing", "public"}}},
+		{UID: "b2", Manifest: types.Manifest{Name: "Invoices", Labels: []string{"billing", "public"}}},
+		{UID: "c3", Manifest: types.Manifest{Name: "Payouts", Labels: []string{"billing", "public"}}},
+		{UID: "d4", Manifest: types.Manifest{Name: "Internal", Labels: []string{"billing"}}},
+	}
+	tracker := memlog.New(1024)
+	track := func(uid string, age time.Duration, failed bool) {
+		record := stats.Record{UID: uid, Begin: now.Add(-age), End: now.Add(-age)}
+		if failed {
+			record.Err = "exit status 1"
+		}
+		tracker.Track(record)
+	}
+	for i := 0; i < 10; i++ {
+		track("a1", time.Minute, false)
+		track("b2", time.Minute, i == 0)
+	}
+	track("a1", 2*time.Hour, true) // out of window, but incident
+	fired := now.Add(-time.Minute)
+	rules := alerts{"c3": {{Name: "errors", Action: types.AlertBreak, State: application.AlertFiring, Since: fired,
+		Events: []application.AlertEvent{{Time: fired, From: application.AlertOK, To: application.AlertFiring}}}}}
+
+	pages := statuspage.New(platform, tracker, rules, nil)
+	page := statuspage.Page{Name: "billing", Labels: []string{"billing", "public"}, Degraded: 0.1}
+	snapshot := pages.Snapshot(&page, now)
+	assert.Equal(t, "billing", snapshot.Title)
+	assert.Equal(t, statuspage.StatusDown, snapshot.Status, "the worst of lambdas")
+	require.Len(t, snapshot.Services, 3, "lambdas with all labels")
+
+	billing, invoices, payouts := snapshot.Services[0], snapshot.Services[1], snapshot.Services[2]
+	assert.Equal(t, "Billing API", billing.Name)
+	assert.Equal(t, statuspage.StatusOK, billing.Status)
+	require.NotNil(t, billing.LastIncident)
+	assert.True(t, billing.LastIncident.Equal(now.Add(-2*time.Hour)))
+
+	assert.Equal(t, statuspage.StatusDegraded, invoices.Errors, "error rate 0.1")
+	assert.Equal(t, statuspage.StatusOK, invoices.Health)
+	assert.Equal(t, statuspage.StatusDegraded, invoices.Status)
+
+	assert.Equal(t, statuspage.StatusDown, payouts.Health, "circuit is open")
+	assert.Equal(t, statuspage.StatusOK, payouts.Errors, "no invocations")
+	require.NotNil(t, payouts.LastIncident)
+	assert.True(t, payouts.LastIncident.Equal(fired))
+}
+
+func TestPages_ServeHTTP(t *testing.T) {
+	platform := lambdas{{UID: "e0ed902f-4a9c-4c29-870d-f343f330b6ab", Aliases: types.StringSet("secret-alias"),
+		Manifest: types.Manifest{Name: "Billing <API>", Labels: []string{"billing"}}}}
+	tracker := memlog.New(16)
+	tracker.Track(stats.Record{UID: platform[0].UID, Err: "connect to db.internal: refused", Begin: time.Now(), End: time.Now()})
+	pages := statuspage.New(platform, tracker, nil, []statuspage.Page{
+		{Name: "billing", Title: "Billing", Enabled: true, Labels: []string{"billing"}},
+		{Name: "hidden", Enabled: false, Labels: []string{"billing"}},
+	})
+	srv := httptest.NewServer(pages)
+	defer srv.Close()
+	get := func(path string) (*http.Response, string) {
+		res, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	res, _ := get("/billing")
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode, "snapshot is not rendered before start")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pages.Start(ctx)
+
+	res, body := get("/billing")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Equal(t, "public, max-age=30", res.Header.Get("Cache-Control"))
+	assert.Contains(t, body, "Billing &lt;API&gt;")
+	for _, internal := range []string{platform[0].UID, "secret-alias", "db.internal"} {
+		assert.NotContains(t, body, internal)
+	}
+
+	res, body = get("/billing.json")
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	var snapshot statuspage.Snapshot
+	require.NoError(t, json.Unmarshal([]byte(body), &snapshot))
+	assert.Equal(t, "Billing", snapshot.Title)
+	require.Len(t, snapshot.Services, 1)
+	assert.Equal(t, statuspage.StatusDown, snapshot.Services[0].Errors)
+	for _, internal := range []string{platform[0].UID, "secret-alias", "db.internal"} {
+		assert.NotContains(t, body, internal)
+	}
+
+	res, _ = get("/hidden")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "disabled page")
+	res, _ = get("/unknown.json")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	res, err := http.Post(srv.URL+"/billing", "text/plain", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "status.json")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"pages": [{"name": "billing", "enabled": true, "labels": ["billing"], "window": "15m"}]}`), 0644))
+	pages, err := statuspage.Load(file)
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.Equal(t, 15*time.Minute, pages[0].WindowDuration())
+	assert.Equal(t, statuspage.DefaultDown, pages[0].DownRate())
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"pages": [{"name": "Billing"}, {"name": "ok", "labels": ["a"], "degraded": 0.6}]}`), 0644))
+	_, err = statuspage.Load(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "page #0 Billing: name should be lowercase")
+	assert.Contains(t, err.Error(), "labels should be set")
+	assert.Contains(t, err.Error(), "page #1 ok: threshold of degraded should not be greater than threshold of down")
+}
@@ -1,23 +1,258 @@
 package application
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
+	"strings"
+	"time"
 
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/types"
 )
 
+// Uploaded content is neither .tar.gz nor .zip archive
+var ErrUnsupportedArchive = errors.New("unsupported archive format: expected .tar.gz or .zip")
+
+// Arguments and environment of process exceed limits (see types.EnvLimits) or rejected by the kernel (E2BIG)
+var ErrEnvironmentTooBig = errors.New("environment too big")
+
+// Output of lambda exceeds maximum response (see types.Manifest.MaximumResponse)
+var ErrResponseTooLarge = errors.New("response too large")
+
+// Invocation is not started because lambda is being built (see types.BuildGate)
+var ErrBuilding = errors.New("lambda is being built")
+
+// Request body exceeds maximum payload of lambda (see types.Manifest.MaximumPayload)
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// Size of lambda directory exceeds disk quota of lambda (see types.Manifest.DiskQuotaMB)
+var ErrDiskQuota = errors.New("disk quota exceeded")
+
+// Multipart or form-urlencoded body could not be decoded (see types.Manifest.DecodeMultipart, types.Manifest.DecodeForm)
+var ErrMalformedForm = errors.New("malformed form")
+
+// Process of lambda is killed by memory limit of cgroup (see types.ResourceLimits)
+var ErrMemoryLimit = errors.New("killed: memory limit")
+
+// Lambda with the same UID already exists (ex: transferred lambda)
+var ErrLambdaExists = errors.New("lambda already exists")
+
+// Restore is rejected because items of backup already exist (see ConflictFail)
+var ErrRestoreConflict = errors.New("items of backup already exist")
+
+// Lambda has no git source in manifest (see types.GitSource)
+var ErrNotTracked = errors.New("lambda does not track git repository")
+
+// Deploy of lambda from git is already in progress
+var ErrDeployRunning = errors.New("deploy is already running")
+
+// Lambda has no frozen artifact with requested ID
+var ErrFrozenNotFound = errors.New("frozen artifact not found")
+
+// Lambda has no version with requested ID (see Versions)
+var ErrVersionNotFound = errors.New("version not found")
+
+// Lambda has no log file with requested name
+var ErrLogNotFound = errors.New("log file not found")
+
+// Request is rejected because client is not in allowed networks of lambda or policy
+var ErrNetworkRestricted = errors.New("network restricted")
+
+// Alias declared in manifest is bound to another lambda
+type AliasConflict struct {
+	Alias string `json:"alias"`
+	UID   string `json:"uid"`            // lambda which alias is bound to
+	Name  string `json:"name,omitempty"` // name of the lambda
+}
+
+// Aliases declared in manifest are bound to other lambdas
+type AliasConflictError struct {
+	Conflicts []AliasConflict
+}
+
+func (ace *AliasConflictError) Error() string {
+	var parts = make([]string, 0, len(ace.Conflicts))
+	for _, conflict := range ace.Conflicts {
+		owner := conflict.UID
+		if conflict.Name != "" {
+			owner += " (" + conflict.Name + ")"
+		}
+		parts = append(parts, "alias "+conflict.Alias+" is bound to lambda "+owner)
+	}
+	return strings.Join(parts, ", ")
+}
+
 type Definition struct {
-	UID      string              `json:"uid"`
-	Aliases  types.JsonStringSet `json:"aliases"`
-	Manifest types.Manifest      `json:"manifest"`
-	Lambda   Lambda              `json:"-"`
+	UID       string              `json:"uid"`
+	Aliases   types.JsonStringSet `json:"aliases"`
+	Manifest  types.Manifest      `json:"manifest"`
+	Modified  time.Time           `json:"modified,omitempty"`  // last change of lambda files (filled only by API)
+	Schedules []ScheduleStatus    `json:"schedules,omitempty"` // live status of scheduled actions (filled only by API)
+	Queues    []QueueStatus       `json:"queues,omitempty"`    // live status of linked queues (filled only by API)
+	Alerts    []AlertStatus       `json:"alerts,omitempty"`    // live status of alert rules (filled only by API)
+	Lambda    Lambda              `json:"-"`
+
+	Slug         string `json:"slug,omitempty"`          // alias generated from name
+	SlugOutdated bool   `json:"slug_outdated,omitempty"` // name changed after slug was generated, slug could be regenerated
+	Warning      string `json:"warning,omitempty"`       // persistent problem of lambda, ex: rejected edit of manifest file
+	Disabled     bool   `json:"disabled"`                // lambda is taken offline: not invoked by HTTP, schedules are paused, queued requests are held
+	// access settings of lambda overridden by policies of bound aliases: alias => settings (see types.AliasPolicy)
+	AliasOverrides map[string][]string `json:"alias_overrides,omitempty"`
+	Git            *GitStatus          `json:"git,omitempty"`  // deploy from git source of manifest (filled only by API)
+	Disk           *DiskUsage          `json:"disk,omitempty"` // usage of lambda directory (filled only by API)
+
+	Outputs map[string]string `json:"outputs,omitempty"` // resolved outputs of template (filled only by API on creation from template)
+}
+
+// Size of lambda directory by cached measurement (see types.Manifest.DiskQuotaMB)
+type DiskUsage struct {
+	Size     int64     `json:"size"`            // size of regular files in bytes
+	Quota    int64     `json:"quota,omitempty"` // disk quota of manifest in bytes (zero - not limited)
+	Measured time.Time `json:"measured"`        // time of measurement
+}
+
+// Live status of scheduled action
+type ScheduleStatus struct {
+	Name       string    `json:"name,omitempty"`
+	Cron       string    `json:"cron"`
+	TZ         string    `json:"tz,omitempty"` // time zone of cron expression (empty - local time of server)
+	Action     string    `json:"action"`
+	Enabled    bool      `json:"enabled"`               // not disabled (as well as lambda), cron expression and time zone are valid
+	Next       time.Time `json:"next,omitempty"`        // next fire time in time zone of schedule (if enabled)
+	LastRun    time.Time `json:"last_run,omitempty"`    // start time of the last run since server start
+	LastResult string    `json:"last_result,omitempty"` // result of the last run: ok or error (empty - not run yet)
+	LastError  string    `json:"last_error,omitempty"`  // error of the last run
+}
+
+// Result of scheduled action
+const (
+	ScheduleResultOK    = "ok"
+	ScheduleResultError = "error"
+)
+
+// Hooks of scheduled runs, all are optional
+type ScheduleHooks struct {
+	Output func() Output                        // new output of every attempt (returned nil - output is logged)
+	Record func(record stats.Record)            // every attempt of scheduled invocation (actions are not recorded)
+	Failed func(letter DeadLetter, body []byte) // run not succeeded after all attempts of retry policy of lambda
+	Fired  func(key string, at time.Time)       // schedule (by key) fired, before the first attempt
+	// runs of schedule missed during downtime (not more than types.MaxCatchUpRuns), caught up or not by policy
+	Missed func(schedule types.Schedule, missed []time.Time)
+}
+
+// Live status of queue
+type QueueStatus struct {
+	Name             string    `json:"name"`
+	Depth            int64     `json:"depth"`              // stored messages including in-flight
+	InFlight         int64     `json:"in_flight"`          // messages being processed
+	Paused           bool      `json:"paused"`             // queue without target lambda or with disabled one doesn't process messages
+	Oldest           time.Time `json:"oldest,omitempty"`   // time when the oldest message was added (if known)
+	OldestAgeSeconds float64   `json:"oldest_age_seconds"` // age of the oldest message (zero if unknown)
+	Quarantined      int64     `json:"quarantined"`        // corrupted messages moved out of queue (see QueuesAPI.RetryQuarantined)
+}
+
+// Pending asynchronous invocation (body is not returned)
+type AsyncInvocation struct {
+	Ticket   string    `json:"ticket"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"` // invocation path without async prefix
+	Size     int64     `json:"size"` // size of request body in bytes
+	Accepted time.Time `json:"accepted"`
+	InFlight bool      `json:"in_flight,omitempty"` // invoked right now
+}
+
+// Queued or scheduled request which was not processed after all attempts (see Queue.Retry and Manifest.Retry)
+type DeadLetter struct {
+	ID       string        `json:"id"`
+	Lambda   string        `json:"lambda"`             // target lambda of queue at the moment of failure
+	Queue    string        `json:"queue"`              // empty for scheduled run
+	Schedule string        `json:"schedule,omitempty"` // label of schedule of failed scheduled run (see Schedule.Label)
+	Error    string        `json:"error"`              // error of the last attempt
+	Attempts int           `json:"attempts"`
+	First    time.Time     `json:"first"`          // start of the first attempt
+	Failed   time.Time     `json:"failed"`         // end of the last attempt
+	Size     int64         `json:"size"`           // size of request body in bytes
+	Request  types.Request `json:"request"`        // request without body: method, path, headers, chain
+	Body     []byte        `json:"body,omitempty"` // request body (returned only for single letter)
+}
+
+// Request of lambda by HTTP captured for debugging and replay (see types.Manifest.Capture)
+type CapturedRequest struct {
+	ID        string             `json:"id"`
+	Lambda    string             `json:"lambda"`
+	Captured  time.Time          `json:"captured"`            // start of invocation
+	Duration  types.JsonDuration `json:"duration"`            // duration of invocation
+	Error     string             `json:"error,omitempty"`     // error of invocation
+	Size      int64              `json:"size"`                // size of request body read by lambda in bytes
+	Truncated bool               `json:"truncated,omitempty"` // body is over limit of capture: kept partially and could not be replayed
+	Request   types.Request      `json:"request"`             // request without body as it was passed to lambda: method, path, headers, form
+	Body      []byte             `json:"body,omitempty"`      // captured body (returned only for single request)
+}
+
+// Oldest message of queue (inspection without consuming)
+type QueueMessage struct {
+	Queue       string         `json:"queue"`
+	Empty       bool           `json:"empty"`             // queue has no messages
+	Hops        int            `json:"hops"`              // length of chain of lambdas which produced message
+	Request     *types.Request `json:"request,omitempty"` // message without body: headers, chain of lambdas (UIDs)
+	Quarantined int64          `json:"quarantined"`       // corrupted messages moved out of queue
+}
+
+// States of alert rule
+const (
+	AlertOK       = "ok"        // conditions are not met
+	AlertFiring   = "firing"    // rule fired, action is taken (circuit is open for break)
+	AlertHalfOpen = "half-open" // circuit breaker after cool-off: one probe request is let through
+)
+
+// Live status of alert rule. State is kept in memory: rules are reset by server restart
+type AlertStatus struct {
+	Name   string       `json:"name"`
+	Action string       `json:"action"`           // notify, degrade, disable or break
+	State  string       `json:"state"`            // ok, firing or half-open
+	Since  time.Time    `json:"since,omitempty"`  // time of the last state change
+	Reason string       `json:"reason,omitempty"` // condition which fired the rule
+	Until  time.Time    `json:"until,omitempty"`  // end of cool-off (if fired), zero - manual reset only
+	Events []AlertEvent `json:"events"`           // recent state changes (audit), from oldest to newest
+}
+
+// Change of alert rule state
+type AlertEvent struct {
+	Time   time.Time `json:"time"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	By     string    `json:"by"` // rule, cool-off, probe or login of user for manual reset
 }
 
 type Config struct {
 	User        string            `json:"user"`                  // user that will be used for jobs
 	Environment map[string]string `json:"environment,omitempty"` // global environment
 	Links       map[string]string `json:"links,omitempty"`       // links (alias -> uid)
+	Runtime     types.Runtime     `json:"runtime"`               // default umask and locale for lambdas
+	Builds      BuildsConfig      `json:"builds"`                // execution of actions (make targets)
+	AutoSlug    bool              `json:"auto_slug,omitempty"`   // generate slug alias from name for new lambdas
+	Slugs       map[string]string `json:"slugs,omitempty"`       // current slug (uid -> alias)
+	Disabled    map[string]bool   `json:"disabled,omitempty"`    // lambdas taken offline (uid -> true), all are enabled by default
+}
+
+// Server-wide settings of actions (make targets) execution
+type BuildsConfig struct {
+	Limits        types.BuildLimits `json:"limits"`                   // default limits of actions, manifest values have higher priority
+	MaxConcurrent int               `json:"max_concurrent,omitempty"` // maximum number of concurrently running actions (zero - unlimited)
+	Cgroup        string            `json:"cgroup,omitempty"`         // delegated cgroup v2 directory for CPU and memory caps (empty - caps are not applied)
+}
+
+// Validate limits and concurrency.
+func (bc BuildsConfig) Validate() error {
+	if bc.MaxConcurrent < 0 {
+		return fmt.Errorf("maximum concurrent builds should not be negative")
+	}
+	return bc.Limits.Validate()
 }
 
 func (cfg Config) WithEnv(env map[string]string) Config {
@@ -25,6 +260,16 @@ func (cfg Config) WithEnv(env map[string]string) Config {
 	return cfg
 }
 
+func (cfg Config) WithRuntime(runtime types.Runtime) Config {
+	cfg.Runtime = runtime
+	return cfg
+}
+
+func (cfg Config) WithBuilds(builds BuildsConfig) Config {
+	cfg.Builds = builds
+	return cfg
+}
+
 func (cfg Config) WithUser(user string) Config {
 	cfg.User = user
 	return cfg
@@ -50,6 +295,181 @@ func (cfg *Config) ReadFile(file string) error {
 	return json.NewDecoder(f).Decode(cfg)
 }
 
+// Invocation in container (see Containers)
+type ContainerRun struct {
+	Name      string          // unique name of container
+	Container types.Container // settings of manifest
+	Dir       string          // content of lambda mounted to types.ContainerDir
+	WorkDir   string          // working directory in container
+	Command   []string        // command of container (empty - command of image)
+	Env       []string        // names of variables passed to container from environment of client
+	User      string          // uid:gid of processes in container (empty - user of image)
+	Network   bool            // access to network
+}
+
+// States of image of container
+const (
+	ImagePulling = "pulling"
+	ImageReady   = "ready"
+	ImageFailed  = "failed"
+)
+
+// Status of pull of image of container
+type ContainerStatus struct {
+	Image    string    `json:"image"`
+	State    string    `json:"state"`              // ImagePulling, ImageReady or ImageFailed
+	Progress string    `json:"progress,omitempty"` // the last line of progress of pull
+	Error    string    `json:"error,omitempty"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Effective settings of lambda as they will be applied to child process
+type Diagnostic struct {
+	Runtime types.Runtime  `json:"runtime"`           // effective umask and locale
+	Secrets SecretsStatus  `json:"secrets"`           // delivery of secret variables to invocations
+	Startup *StartupStatus `json:"startup,omitempty"` // status of the last startup (on_start) action
+	Builds  BuildLimits    `json:"builds"`            // effective limits of actions
+	Env     EnvStatus      `json:"env"`               // size of environment of invocations
+	// image of container of invocations (nil - native process)
+	Container *ContainerStatus `json:"container,omitempty"`
+}
+
+// Projected size of arguments and environment of invocation without variables from request
+type EnvStatus struct {
+	Size        int64         `json:"size"`              // bytes, including terminating zeros and pointers
+	Limit       int64         `json:"limit"`             // effective limit of total size
+	Headroom    int64         `json:"headroom"`          // space left for variables from request (negative - invocations fail)
+	MaxVariable int64         `json:"max_variable"`      // effective limit of single variable
+	Oversized   string        `json:"oversized"`         // policy of oversized variables from request: deny or truncate
+	Largest     []EnvVariable `json:"largest,omitempty"` // the biggest contributors
+}
+
+// Contributor to size of environment
+type EnvVariable struct {
+	Name string `json:"name"` // name of variable or argument (ex: argv[1])
+	Size int64  `json:"size"` // bytes
+}
+
+// Sources of variables of effective environment of lambda, from the lowest priority
+const (
+	EnvSourceRuntime = "runtime" // runtime defaults of server (locale, time zone)
+	EnvSourceGlobal  = "global"  // global environment of server
+	EnvSourceLambda  = "lambda"  // runtime settings and environment of manifest
+)
+
+// Variable of effective environment of lambda (without variables of server process and request)
+type EnvValue struct {
+	Name       string   `json:"name"`
+	Value      string   `json:"value"`                // resolved value, references to secrets are as is
+	Source     string   `json:"source"`               // source of value (see EnvSourceLambda)
+	Overridden []string `json:"overridden,omitempty"` // sources of values replaced by the value
+}
+
+// Effective limits of actions
+type BuildLimits struct {
+	types.BuildLimits
+	Cgroup bool `json:"cgroup"` // CPU and memory caps are applied
+}
+
+// Resource limits of single process applied by builder (see Builder.Guard)
+type ResourceGuard interface {
+	// Process is started: rlimits are applied
+	Started(cmd *exec.Cmd)
+	// Process is finished (or failed to start) with result of Wait: cgroup is removed with leftover processes, error
+	// is replaced by ErrMemoryLimit if process was killed by memory limit
+	Finish(err error) error
+}
+
+// Report of action execution by builder
+type BuildReport struct {
+	Position int               // position in queue of builds when action was submitted (zero - started immediately)
+	Queued   time.Duration     // time spent in queue
+	Limits   types.BuildLimits // effective limits
+}
+
+type buildReportKey struct{}
+
+// WithBuildReport returns context which collects report of action executed with it.
+func WithBuildReport(ctx context.Context, report *BuildReport) context.Context {
+	return context.WithValue(ctx, buildReportKey{}, report)
+}
+
+// BuildReportFrom context (nil if not collected).
+func BuildReportFrom(ctx context.Context) *BuildReport {
+	report, _ := ctx.Value(buildReportKey{}).(*BuildReport)
+	return report
+}
+
+// Resource usage of finished lambda process
+type Usage struct {
+	CPU       time.Duration // user and system CPU time
+	MaxRSS    int64         // maximum resident set size in bytes (zero - unknown)
+	Stderr    []byte        // tail of stderr (not more than StderrTail bytes), not collected for workers
+	TimedOut  bool          // invocation is not finished in time limit of manifest
+	Spawn     time.Duration // time to start process or new worker (zero - warm worker)
+	FirstByte time.Duration // time from start of process (or of request to worker) to the first byte of output
+	Runtime   time.Duration // time from start of process to its exit (worker - to its reply)
+	ExitCode  int           // exit code of process or code of worker reply
+	Signal    string        // signal which killed process (empty - exited)
+}
+
+// Timing copies breakdown of invocation and exit of process to record
+func (u *Usage) Timing(record *stats.Record) {
+	record.Spawn = u.Spawn
+	record.FirstByte = u.FirstByte
+	record.Runtime = u.Runtime
+	record.ExitCode = u.ExitCode
+	record.Signal = u.Signal
+}
+
+// Maximum size of stderr tail kept in usage
+const StderrTail = 4096
+
+type usageKey struct{}
+
+// WithUsage returns context which collects resource usage of lambda process invoked with it.
+func WithUsage(ctx context.Context, usage *Usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, usage)
+}
+
+// UsageFrom context (nil if not collected).
+func UsageFrom(ctx context.Context) *Usage {
+	usage, _ := ctx.Value(usageKey{}).(*Usage)
+	return usage
+}
+
+// Limits of action exceeded: action is killed
+type LimitError struct {
+	Limit string // memory or time
+	Err   error
+}
+
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("build limit exceeded (%s): %v", le.Limit, le.Err)
+}
+
+func (le *LimitError) Unwrap() error {
+	return le.Err
+}
+
+// Delivery of secret variables to invocations. Values are never reported
+type SecretsStatus struct {
+	Delivery string   `json:"delivery"`      // env, fd or file
+	Env      string   `json:"env,omitempty"` // variable with descriptor number or file path
+	Keys     []string `json:"keys"`          // names of secret variables
+}
+
+// Status of the startup (on_start) action
+type StartupStatus struct {
+	Action   string    `json:"action"`
+	Running  bool      `json:"running"`
+	Degraded bool      `json:"degraded"` // failed with degraded policy
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Output   string    `json:"output,omitempty"` // tail of combined output
+}
+
 type Queue struct {
 	Name           string             `json:"name"`
 	Target         string             `json:"target"`
@@ -58,11 +478,101 @@ type Queue struct {
 	Interval       types.JsonDuration `json:"interval"`         // delay between attempts
 }
 
+// Result (or plan for dry run) of migration of legacy state: aliases and access restrictions defined in lambda
+// manifest are moved to server links and policies
+type MigrationReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Before  MigrationCounts   `json:"before"`
+	After   MigrationCounts   `json:"after"`             // expected counts for dry run
+	Lambdas []LambdaMigration `json:"lambdas,omitempty"` // lambdas with legacy state
+}
+
+// Number of legacy entities and server objects
+type MigrationCounts struct {
+	LegacyLambdas  int `json:"legacy_lambdas"`  // lambdas with legacy fields in manifest
+	LegacyAliases  int `json:"legacy_aliases"`  // aliases defined in manifests
+	LegacyPolicies int `json:"legacy_policies"` // access restrictions defined in manifests
+	Links          int `json:"links"`           // server links (aliases)
+	Policies       int `json:"policies"`        // server policies
+}
+
+// Migration of single lambda. Lambda with issues is not migrated at all and keeps legacy manifest
+type LambdaMigration struct {
+	UID     string           `json:"uid"`
+	Aliases []string         `json:"aliases,omitempty"` // aliases to link
+	Policy  string           `json:"policy,omitempty"`  // policy to create (or reuse) and apply
+	Backup  string           `json:"backup,omitempty"`  // location of original manifest
+	Issues  []MigrationIssue `json:"issues,omitempty"`  // reasons why lambda could not be migrated
+}
+
+func (lm *LambdaMigration) Migratable() bool {
+	return len(lm.Issues) == 0
+}
+
+// Unmigratable item of legacy state
+type MigrationIssue struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// Capacity report of server: usage of lambdas aggregated by stats records in window, disk usage, queue backlogs and
+// naive projection of headroom. Aggregates of sampled records are weighted by sampling rate, peak concurrency is
+// estimated by recorded invocations only
+type CapacityReport struct {
+	Generated  time.Time          `json:"generated"`
+	Window     types.JsonDuration `json:"window"`
+	Covered    types.JsonDuration `json:"covered"`           // time covered by records: less than window if stats cache is overflowed
+	Records    int                `json:"records"`           // stats records in window
+	Partial    bool               `json:"partial,omitempty"` // disk usage of some lambdas or stores is not measured in time
+	Running    int                `json:"running"`           // invocations in progress
+	Peak       int                `json:"peak"`              // peak concurrent invocations in window
+	Lambdas    []LambdaCapacity   `json:"lambdas"`
+	Stores     []StoreUsage       `json:"stores"`
+	Queues     []QueueStatus      `json:"queues"`
+	Projection CapacityProjection `json:"projection"`
+}
+
+// Usage of lambda in window of capacity report
+type LambdaCapacity struct {
+	UID         string             `json:"uid"`
+	Name        string             `json:"name,omitempty"`
+	Invocations int                `json:"invocations"` // invoked processes (without rejected and coalesced requests)
+	Running     int                `json:"running"`     // invocations in progress
+	Peak        int                `json:"peak"`        // peak concurrent invocations in window
+	Busy        types.JsonDuration `json:"busy"`        // total wall-clock time of invocations
+	CPU         types.JsonDuration `json:"cpu"`         // total CPU time (user and system) of invocations
+	MaxRSS      int64              `json:"max_rss"`     // maximum resident set size of single invocation in bytes
+	Disk        int64              `json:"disk"`        // size of lambda directory in bytes (-1 - not measured)
+	Backlog     int64              `json:"backlog"`     // messages in linked queues
+}
+
+// Disk usage of server store (lambdas, queues, stats, templates)
+type StoreUsage struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Size int64  `json:"size"` // in bytes (-1 - not measured)
+}
+
+// Naive projection of capacity: average usage of active lambda compared with resources of host left after
+// invocations and reserved by configured builds concurrency and caps
+type CapacityProjection struct {
+	CPUs          int     `json:"cpus"`            // CPU cores of host
+	Memory        int64   `json:"memory"`          // total memory of host in bytes (zero - unknown)
+	CPULoad       float64 `json:"cpu_load"`        // average number of cores used by invocations in window
+	PeakMemory    int64   `json:"peak_memory"`     // memory of lambdas at peak: max RSS multiplied by peak concurrency
+	BuildsCPU     float64 `json:"builds_cpu"`      // cores reserved by builds: max concurrent builds by CPU cap (zero - not capped)
+	BuildsMemory  int64   `json:"builds_memory"`   // memory reserved by builds: max concurrent builds by memory cap (zero - not capped)
+	ActiveLambdas int     `json:"active_lambdas"`  // lambdas invoked in window
+	MoreLambdas   int     `json:"more_lambdas"`    // more lambdas with average usage which fit in free CPU and memory (-1 - unknown)
+	Limit         string  `json:"limit,omitempty"` // resource which limits projection: cpu or memory
+}
+
 type PolicyDefinition struct {
-	AllowedIP     types.JsonStringSet `json:"allowed_ip,omitempty"`     // limit incoming connections from list of IP
-	AllowedOrigin types.JsonStringSet `json:"allowed_origin,omitempty"` // limit incoming connections by origin header
-	Public        bool                `json:"public"`                   // if public, tokens are ignores
-	Tokens        map[string]string   `json:"tokens,omitempty"`         // limit request by value in Authorization header (token => title)
+	AllowedIP       types.JsonStringSet `json:"allowed_ip,omitempty"`       // limit incoming connections from list of IP
+	AllowedNetworks []string            `json:"allowed_networks,omitempty"` // limit incoming connections by networks of clients (CIDR, IPv4 or IPv6)
+	AllowedOrigin   types.JsonStringSet `json:"allowed_origin,omitempty"`   // limit incoming connections by origin header
+	Public          bool                `json:"public"`                     // if public, tokens are ignores
+	Tokens          map[string]string   `json:"tokens,omitempty"`           // limit request by value in Authorization header (token => title)
 }
 
 type Policy struct {
@@ -70,3 +580,364 @@ type Policy struct {
 	Definition PolicyDefinition    `json:"definition"`
 	Lambdas    types.JsonStringSet `json:"lambdas"`
 }
+
+// Attempt of delivery of lifecycle event to webhook
+type WebhookDelivery struct {
+	Event    string    `json:"event"`            // ID of event, the same for all attempts
+	Type     string    `json:"type"`             // type of event (see types.Event* constants)
+	Lambda   string    `json:"lambda,omitempty"` // UID of lambda of event
+	URL      string    `json:"url"`              // URL of webhook
+	Attempt  int       `json:"attempt"`          // number of attempt from 1 (zero - event dropped without attempt)
+	Time     time.Time `json:"time"`             // end of attempt
+	Status   int       `json:"status,omitempty"` // HTTP status of response (zero - no response)
+	Error    string    `json:"error,omitempty"`  // reason of failure, empty - delivered
+	Retry    time.Time `json:"retry,omitempty"`  // time of the next attempt after failure (zero - no more attempts)
+	Duration float64   `json:"duration_ms"`      // duration of attempt in milliseconds
+}
+
+// Status of deploys of lambda from git source (see types.GitSource)
+type GitStatus struct {
+	Repo     string    `json:"repo"`
+	Branch   string    `json:"branch,omitempty"`
+	Commit   string    `json:"commit,omitempty"`   // deployed commit (empty - not deployed yet)
+	Deployed time.Time `json:"deployed,omitempty"` // time of deploy of commit
+	Checked  time.Time `json:"checked,omitempty"`  // last fetch of branch
+	Failed   string    `json:"failed,omitempty"`   // commit which failed to deploy (empty - the last deploy succeeded)
+	Error    string    `json:"error,omitempty"`    // error of the last fetch or deploy (empty - no problems)
+	Output   string    `json:"output,omitempty"`   // the end of output of failed build
+	Running  bool      `json:"running,omitempty"`  // deploy is in progress
+}
+
+// Status of replication of primary server to read-only mirror
+type MirrorStatus struct {
+	Enabled   bool               `json:"enabled"`              // server is read-only mirror: replicates primary and rejects mutating API
+	Primary   string             `json:"primary,omitempty"`    // base URL of primary server
+	Promoted  time.Time          `json:"promoted,omitempty"`   // time of promotion to primary (zero - not promoted)
+	Interval  types.JsonDuration `json:"interval,omitempty"`   // interval between synchronizations
+	LastSync  time.Time          `json:"last_sync,omitempty"`  // end of the last successful synchronization
+	LastError string             `json:"last_error,omitempty"` // error of the last synchronization (empty - successful)
+	Lambdas   int                `json:"lambdas"`              // lambdas on primary by the last successful synchronization
+	Syncs     uint64             `json:"syncs"`                // finished synchronizations (including failed)
+	Failures  uint64             `json:"failures"`             // failed synchronizations: primary is not available or rejected request
+	Pulls     uint64             `json:"pulls"`                // contents of lambdas pulled from primary
+	Diverged  []MirrorDivergence `json:"diverged,omitempty"`   // objects which differ from primary after the last successful synchronization
+}
+
+// Object of mirror which differs from primary
+type MirrorDivergence struct {
+	Object string `json:"object"` // lambda UID, link:<name>, policy:<id> or environment
+	Reason string `json:"reason"`
+}
+
+// Kinds of administrative changes
+const (
+	ChangeCreate   = "create"   // lambda created
+	ChangeRemove   = "remove"   // lambda removed
+	ChangeDeploy   = "deploy"   // content of lambda uploaded or files changed
+	ChangeManifest = "manifest" // manifest of lambda edited (except schedules)
+	ChangeSchedule = "schedule" // scheduled actions (cron) of lambda changed
+	ChangeAlias    = "alias"    // link (alias) of lambda added or removed
+	ChangePolicy   = "policy"   // policy created, updated, removed, applied or cleared
+	ChangeUser     = "user"     // password of user changed or lockout of login cleared
+	ChangeSettings = "settings" // global settings (effective user, environment) changed
+	ChangeTransfer = "transfer" // lambda exported to or imported from another server
+	ChangeState    = "state"    // lambda enabled or disabled
+	ChangeSecret   = "secret"   // secret of server store set or removed (value is never recorded)
+	ChangeAuth     = "auth"     // failed authentication: wrong login or password, lockout, rejected token or SFTP key
+)
+
+// Administrative change recorded in journal
+type Change struct {
+	ID       int64     `json:"id"`                 // sequence number in journal (reference of change)
+	Time     time.Time `json:"time"`               // moment of change
+	Actor    string    `json:"actor"`              // login of API user, identity of SFTP key or source of reload of edited files (attempted login for failed authentication)
+	Kind     string    `json:"kind"`               // see Change* constants
+	Lambda   string    `json:"lambda,omitempty"`   // UID of changed lambda (empty for server-wide changes)
+	Name     string    `json:"name,omitempty"`     // name of lambda at the moment of change
+	Summary  string    `json:"summary"`            // human-readable description
+	Previous string    `json:"previous,omitempty"` // hash of manifest before change (see Manifest.Hash)
+	Revision string    `json:"revision,omitempty"` // hash of manifest after change
+}
+
+// Filter of changes in journal
+type ChangeQuery struct {
+	Since  time.Time `json:"since"`            // inclusive
+	Until  time.Time `json:"until"`            // exclusive, zero - without upper bound
+	Lambda string    `json:"lambda,omitempty"` // UID of lambda, empty - any lambda and server-wide changes
+	Kind   string    `json:"kind,omitempty"`   // see Change* constants, empty - any kind
+}
+
+// Matches change by time range, lambda and kind
+func (cq ChangeQuery) Matches(change Change) bool {
+	if change.Time.Before(cq.Since) || (!cq.Until.IsZero() && !change.Time.Before(cq.Until)) {
+		return false
+	}
+	return (cq.Lambda == "" || cq.Lambda == change.Lambda) && (cq.Kind == "" || cq.Kind == change.Kind)
+}
+
+// Page of changes by query in order of recording (audit log)
+type ChangesPage struct {
+	Query   ChangeQuery `json:"query"`
+	Total   int         `json:"total"`          // number of changes matched by query
+	Offset  int         `json:"offset"`         // offset of the first change on page
+	Next    int         `json:"next,omitempty"` // offset of the next page (zero - the last page)
+	Changes []Change    `json:"changes"`
+}
+
+// Page of changes in time range grouped by lambda
+type ChangesReport struct {
+	Since  time.Time     `json:"since"`
+	Until  time.Time     `json:"until"`
+	Total  int           `json:"total"`          // number of changes in range
+	Offset int           `json:"offset"`         // offset of the first change on page
+	Next   int           `json:"next,omitempty"` // offset of the next page (zero - the last page)
+	Groups []ChangeGroup `json:"groups"`         // lambdas in order of the first change, server-wide changes are the last group
+}
+
+// Changes of one lambda (or server-wide changes if lambda is empty) on page, from oldest to newest
+type ChangeGroup struct {
+	Lambda  string   `json:"lambda,omitempty"`  // UID of lambda
+	Name    string   `json:"name,omitempty"`    // the latest known name of lambda
+	Created bool     `json:"created,omitempty"` // lambda created in range
+	Removed bool     `json:"removed,omitempty"` // lambda removed in range
+	Summary string   `json:"summary"`           // ex: created, 2 deploys, 1 manifest edit by admin, ci
+	Changes []Change `json:"changes"`
+}
+
+// Active security profile of server and lambdas with options different from defaults of profile
+type SecurityReport struct {
+	Profile    types.SecurityProfile `json:"profile"`
+	Violations int                   `json:"violations"` // mandatory rules relaxed by manifests (profile values are used)
+	Lambdas    []LambdaDeviations    `json:"lambdas"`    // lambdas with deviations ordered by UID
+}
+
+// Options of lambda manifest different from defaults of security profile
+type LambdaDeviations struct {
+	UID        string            `json:"uid"`
+	Name       string            `json:"name,omitempty"`
+	Deviations []types.Deviation `json:"deviations"`
+}
+
+// Lambdas matched by full-text search ordered by relevance
+type SearchResult struct {
+	Query      string      `json:"query"`
+	Total      int         `json:"total"`                // number of matched lambdas (hits could be limited)
+	Incomplete bool        `json:"incomplete,omitempty"` // some lambdas are indexed partially: index is over memory budget
+	Hits       []SearchHit `json:"hits"`
+}
+
+// Lambda matched by search
+type SearchHit struct {
+	UID        string            `json:"uid"`
+	Name       string            `json:"name,omitempty"`
+	Score      float64           `json:"score"`      // relevance: higher is better
+	Highlights []SearchHighlight `json:"highlights"` // matched fields in order: name, aliases, labels, description
+}
+
+// Field of lambda with matched words highlighted
+type SearchHighlight struct {
+	Field     string            `json:"field"`     // name, alias, label or description
+	Fragments []SnippetFragment `json:"fragments"` // text of field (snippet around matched words for description)
+}
+
+// Part of highlighted text
+type SnippetFragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"` // text is matched word
+}
+
+// Metadata of secret of server store (value is never returned)
+type Secret struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Lambdas []string  `json:"lambdas,omitempty"` // UIDs of lambdas which environment references the secret
+}
+
+// Version of format of backup archive (see BackupHeader). Archives of newer formats are not restored
+const BackupFormat = 1
+
+// Options of backup of server
+type BackupOptions struct {
+	NoTokens bool `json:"no_tokens,omitempty"` // API tokens are not included
+	Frozen   bool `json:"frozen,omitempty"`    // the newest frozen artifacts of lambdas are included (see Freezer)
+}
+
+// Header of backup archive: format, origin and content
+type BackupHeader struct {
+	Format  int       `json:"format"`            // see BackupFormat
+	Version string    `json:"version,omitempty"` // version of server
+	Created time.Time `json:"created"`
+	Lambdas int       `json:"lambdas"`           // number of lambdas
+	Tokens  bool      `json:"tokens,omitempty"`  // API tokens are included
+	Secrets bool      `json:"secrets,omitempty"` // sealed secrets of server store are included (restored by the same key only)
+}
+
+// Handling of items of backup which already exist on restore (lambdas by UID, aliases, policies, queues, tokens,
+// secrets, global environment and settings)
+const (
+	ConflictSkip   = "skip"    // existent items are kept, items of backup are skipped
+	ConflictFail   = "fail"    // nothing is restored if any item exists
+	ConflictNewUID = "new-uid" // lambdas with existent UID are restored with new UID, other items are skipped
+)
+
+// Options of restore of server from backup
+type RestoreOptions struct {
+	DryRun   bool   `json:"dry_run,omitempty"`   // plan without changes
+	Conflict string `json:"conflict,omitempty"`  // see Conflict* constants (empty - ConflictSkip)
+	NoTokens bool   `json:"no_tokens,omitempty"` // API tokens of backup are not restored
+}
+
+// Kinds of restored items
+const (
+	RestoreLambda  = "lambda"
+	RestoreAlias   = "alias"
+	RestorePolicy  = "policy"
+	RestoreQueue   = "queue"
+	RestoreToken   = "token"
+	RestoreSecret  = "secret"
+	RestoreSetting = "setting" // variable of global environment (environment.NAME) or server setting
+)
+
+// Actions of restore by item
+const (
+	RestoreCreate = "create" // item is created
+	RestoreRename = "rename" // lambda is created with new UID
+	RestoreSkip   = "skip"   // item exists or could not be restored, see reason
+)
+
+// Result (or plan for dry run) of restore of server from backup
+type RestoreReport struct {
+	DryRun bool          `json:"dry_run"`
+	Header BackupHeader  `json:"header"`
+	Items  []RestoreItem `json:"items"`
+}
+
+// Conflicts of restore: items skipped because they exist
+func (rr *RestoreReport) Conflicts() []RestoreItem {
+	var ans []RestoreItem
+	for _, item := range rr.Items {
+		if item.Action == RestoreSkip && item.Conflict {
+			ans = append(ans, item)
+		}
+	}
+	return ans
+}
+
+// Restored item of backup
+type RestoreItem struct {
+	Kind     string `json:"kind"`               // see Restore* kinds
+	Name     string `json:"name"`               // UID of lambda, alias, ID of policy or token, name of queue, secret or setting
+	Action   string `json:"action"`             // see Restore* actions
+	Target   string `json:"target,omitempty"`   // UID of lambda of alias, queue or renamed lambda, name of token
+	Conflict bool   `json:"conflict,omitempty"` // item is skipped because it exists
+	Reason   string `json:"reason,omitempty"`   // reason of skipped item
+	Frozen   string `json:"frozen,omitempty"`   // ID of frozen artifact which files of lambda are restored from
+}
+
+// Import of lambda from archive (.tar.gz or .zip, ex: release artifact) by URL
+type ArtifactImport struct {
+	URL    string `json:"url"`              // http or https URL of archive
+	SHA256 string `json:"sha256,omitempty"` // expected SHA-256 of archive in hex (empty - not verified)
+	UID    string `json:"uid,omitempty"`    // updated lambda (empty - new lambda is created)
+	Action string `json:"action,omitempty"` // post-clone action run after extraction (empty - post_clone of template.json in archive, if any)
+}
+
+// Result of import of lambda from archive
+type ArtifactReport struct {
+	UID     string `json:"uid"`
+	Created bool   `json:"created,omitempty"` // new lambda is created
+	SHA256  string `json:"sha256"`            // SHA-256 of downloaded archive in hex
+	Size    int64  `json:"size"`              // size of downloaded archive
+	Action  string `json:"action,omitempty"`  // post-clone action which was run
+	Warning string `json:"warning,omitempty"` // lambda is imported with problem (ex: aliases of manifest are bound to other lambdas)
+}
+
+// Frozen artifact of lambda: archive of all files of lambda including installed dependencies (see Freezer)
+type FrozenArtifact struct {
+	ID      string    `json:"id"`   // SHA-256 of archive in hex: the same files are the same artifact
+	UID     string    `json:"uid"`  // lambda
+	Size    int64     `json:"size"` // size of archive in bytes
+	Created time.Time `json:"created"`
+}
+
+// Log file of lambda with output of invocations (see types.Manifest.Logs)
+type LogFile struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Current  bool      `json:"current,omitempty"` // file written by invocations (the newest)
+}
+
+// Report of warm-up of lambdas on server start (see WarmUp)
+type WarmUpReport struct {
+	Running  bool           `json:"running"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished,omitempty"`
+	Lambdas  []WarmUpResult `json:"lambdas,omitempty"` // finished lambdas in order of finish
+}
+
+// Warm-up of lambda: availability checks and rebuild of absent environment (see types.Manifest.WarmUp)
+type WarmUpResult struct {
+	UID       string             `json:"uid"`
+	Name      string             `json:"name,omitempty"`
+	Available bool               `json:"available"`
+	Problems  []string           `json:"problems,omitempty"` // problems after warm-up
+	Absent    []string           `json:"absent,omitempty"`   // problems which caused rebuild of environment
+	Action    string             `json:"action,omitempty"`   // action which rebuilt environment (empty - not rebuilt)
+	Error     string             `json:"error,omitempty"`    // failed rebuild
+	Duration  types.JsonDuration `json:"duration"`
+}
+
+// Version of lambda: snapshot of files and manifest taken on deploy (see Versions)
+type LambdaVersion struct {
+	ID      string    `json:"id"`                // SHA-256 of list of files (names, modes and hashes of content) in hex: the same files are the same version
+	UID     string    `json:"uid"`               // lambda
+	Created time.Time `json:"created"`           // time of snapshot
+	Author  string    `json:"author,omitempty"`  // login of API user who deployed the version
+	Message string    `json:"message,omitempty"` // annotation of version (ex: by upload)
+	Size    int64     `json:"size"`              // total size of files in bytes
+	Files   int       `json:"files"`             // number of files
+	Current bool      `json:"current,omitempty"` // version of the last snapshot or rollback of lambda
+}
+
+// Rollback of lambda to version
+type VersionRollback struct {
+	ID      string `json:"id"`                 // version or unique prefix of its ID
+	Action  string `json:"action,omitempty"`   // build action run in staged copy before files are replaced (empty - types.DefaultBuildAction if defined by Makefile of version)
+	NoBuild bool   `json:"no_build,omitempty"` // restore files without build action
+}
+
+// Result of rollback of lambda
+type RollbackReport struct {
+	Version LambdaVersion `json:"version"`           // restored version
+	Action  string        `json:"action,omitempty"`  // build action run in staged copy (empty - not run)
+	Warning string        `json:"warning,omitempty"` // files are restored with problem (ex: aliases are not bound)
+}
+
+// Options of duplicate of lambda: files, manifest and environment of source are copied to new lambda
+type DuplicateOptions struct {
+	Name        string `json:"name,omitempty"`         // name of copy (empty - name of source)
+	NoAliases   bool   `json:"no_aliases,omitempty"`   // aliases are not declared by copy
+	NoSchedules bool   `json:"no_schedules,omitempty"` // scheduled actions (cron) are not copied
+	NoSecrets   bool   `json:"no_secrets,omitempty"`   // environment variables which reference secrets of server store are not copied
+}
+
+// Targets of reload besides lambdas (see Reloader)
+const (
+	ReloadConfig  = "config"  // project config
+	ReloadProfile = "profile" // custom security profile
+)
+
+// Result of reload of files edited on disk
+type ReloadReport struct {
+	Items []ReloadItem `json:"items"`
+}
+
+// Reloaded target: project config, security profile or lambda
+type ReloadItem struct {
+	Target  string `json:"target"`            // UID of lambda or see Reload* targets
+	Applied bool   `json:"applied,omitempty"` // changed manifest, config or profile is applied
+	Files   bool   `json:"files,omitempty"`   // files of lambda are reloaded: workers are restarted, cached responses are dropped
+	Error   string `json:"error,omitempty"`   // file is not applied: the last good state is kept
+}
@@ -0,0 +1,544 @@
+// Package versions keeps versions of lambdas: snapshots of files and manifest (except ignored by .cgiignore) taken on
+// deploys. Files are content-addressed by SHA-256, so unchanged files are stored once for all versions and lambdas:
+//
+//	index.json                versions of lambdas from the newest
+//	trees/<id>.json           files of versions (ID is SHA-256 of list of files)
+//	blobs/<sha256>            content of files
+//
+// Rollback restores files of version in staged copy of lambda and runs build action there: lambda is replaced by the
+// staged copy only after successful build, so failed rollback keeps the current version serving.
+package versions
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Number of versions of lambda kept by default
+const DefaultKeep = 10
+
+const (
+	indexFile = "index.json"
+	treesDir  = "trees"
+	blobsDir  = "blobs"
+	stagesDir = "stages" // staged copies of rollbacks
+	treeExt   = ".json"
+)
+
+// New store of versions of lambdas of platform located in project directory by UID. Staged copy of rollback is moved
+// to lambda, so directory of store should be on the same file system as project
+func New(platform application.Platform, project string, dir string) (*Store, error) {
+	for _, sub := range []string{treesDir, blobsDir, stagesDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("create directory of versions: %w", err)
+		}
+	}
+	st := &Store{platform: platform, project: project, dir: dir, index: make(map[string][]application.LambdaVersion)}
+	if err := internal.ReadJson(filepath.Join(dir, indexFile), &st.index); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read index of versions: %w", err)
+	}
+	return st, nil
+}
+
+// Store of versions
+type Store struct {
+	Keep     int   // versions of lambda kept by pruning (zero - DefaultKeep, negative - all)
+	MaxSize  int64 // maximum total size of stored files in bytes: the oldest versions are pruned over it (zero - unlimited)
+	platform application.Platform
+	project  string
+	dir      string
+	lock     sync.Mutex
+	index    map[string][]application.LambdaVersion // UID -> versions from the newest
+}
+
+// file of version
+type entry struct {
+	Path   string      `json:"path"` // slash separated relative path
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size,omitempty"`
+	SHA256 string      `json:"sha256,omitempty"` // empty for directory
+}
+
+func (st *Store) Snapshot(uid string, author string, message string) (*application.LambdaVersion, error) {
+	def, err := st.platform.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	files, err := st.storeContent(def.Lambda)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot files of lambda: %w", err)
+	}
+	version := application.LambdaVersion{ID: treeID(files), UID: uid, Created: time.Now(), Author: author, Message: message, Files: len(files), Current: true}
+	for _, file := range files {
+		version.Size += file.Size
+	}
+	versions := st.index[uid]
+	if len(versions) > 0 && versions[0].ID == version.ID {
+		// the same files: deploy of manifest or content without changes
+		version = versions[0]
+		if message != "" {
+			version.Message = message
+		}
+		version.Current = true
+	} else if err := internal.AtomicWriteJson(st.tree(version.ID), files); err != nil {
+		return nil, fmt.Errorf("save files of version: %w", err)
+	}
+	updated := []application.LambdaVersion{version}
+	for _, item := range versions {
+		if item.ID != version.ID {
+			item.Current = false
+			updated = append(updated, item)
+		}
+	}
+	st.index[uid] = updated
+	if err := st.prune(); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (st *Store) Versions(uid string) []application.LambdaVersion {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return append([]application.LambdaVersion{}, st.index[uid]...)
+}
+
+func (st *Store) Rollback(ctx context.Context, uid string, rollback application.VersionRollback) (*application.RollbackReport, error) {
+	def, err := st.platform.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	st.lock.Lock()
+	_, err = st.find(uid, rollback.ID)
+	st.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	temp, err := ioutil.TempDir(filepath.Join(st.dir, stagesDir), "rollback-")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	// staged copy is moved to lambda on success
+	defer os.RemoveAll(temp)
+	stage := filepath.Join(temp, "lambda")
+	// files which are not in versions (ex: data of lambda or dependencies ignored by .cgiignore) are kept
+	if err := internal.CopyDir(filepath.Join(st.project, uid), stage); err != nil {
+		return nil, fmt.Errorf("copy lambda to staging: %w", err)
+	}
+	if err := removeContent(def.Lambda, stage); err != nil {
+		return nil, fmt.Errorf("remove files of current version: %w", err)
+	}
+	staged, err := lambda.FromDir(stage)
+	if err != nil {
+		return nil, fmt.Errorf("load staged copy: %w", err)
+	}
+	// content is extracted after setup by platform: upload limits of security profile are applied
+	if err := st.platform.Prepare(staged); err != nil {
+		return nil, fmt.Errorf("prepare staged copy: %w", err)
+	}
+	version, err := st.restore(uid, rollback.ID, staged)
+	if err != nil {
+		return nil, err
+	}
+	report := &application.RollbackReport{Version: version}
+	if !rollback.NoBuild {
+		report.Action, err = buildAction(staged, rollback.Action)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if report.Action != "" {
+		var usage application.Usage
+		if err := st.platform.Do(application.WithUsage(ctx, &usage), staged, report.Action, 0, nil); err != nil {
+			if stderr := strings.TrimSpace(string(usage.Stderr)); stderr != "" {
+				return nil, fmt.Errorf("invoke build %s: %w, stderr: %s", report.Action, err, stderr)
+			}
+			return nil, fmt.Errorf("invoke build %s: %w", report.Action, err)
+		}
+	}
+
+	previous := def.Lambda.Manifest()
+	if err := def.Lambda.Replace(stage); err != nil {
+		return nil, fmt.Errorf("replace files of lambda: %w", err)
+	}
+	if _, err := st.platform.BindAliases(uid, previous.Aliases, def.Lambda.Manifest().Aliases, false); err != nil {
+		report.Warning = "version " + version.ID + " is restored, aliases are not bound: " + err.Error()
+		def.Lambda.SetWarning(report.Warning)
+	}
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	versions := st.index[uid]
+	for i := range versions {
+		versions[i].Current = versions[i].ID == version.ID
+	}
+	if err := internal.AtomicWriteJson(filepath.Join(st.dir, indexFile), st.index); err != nil {
+		return nil, fmt.Errorf("save index of versions: %w", err)
+	}
+	report.Version.Current = true
+	return report, nil
+}
+
+// extract files of version (by ID or unique prefix) to staged copy of lambda. Files are verified by hashes, so
+// damaged version fails before lambda is replaced
+func (st *Store) restore(uid string, id string, staged application.Lambda) (application.LambdaVersion, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	version, err := st.find(uid, id)
+	if err != nil {
+		return version, err
+	}
+	var files []entry
+	if err := internal.ReadJson(st.tree(version.ID), &files); err != nil {
+		return version, fmt.Errorf("read files of version %s: %w", version.ID, err)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(st.archive(files, writer))
+	}()
+	err = staged.SetContent(reader)
+	_ = reader.CloseWithError(err)
+	if err != nil {
+		return version, fmt.Errorf("restore files of version %s: %w", version.ID, err)
+	}
+	return version, nil
+}
+
+// version of lambda by ID or unique prefix of ID. Should be called under lock
+func (st *Store) find(uid string, id string) (application.LambdaVersion, error) {
+	var found []application.LambdaVersion
+	for _, version := range st.index[uid] {
+		if version.ID == id {
+			return version, nil
+		}
+		if id != "" && strings.HasPrefix(version.ID, id) {
+			found = append(found, version)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return application.LambdaVersion{}, fmt.Errorf("%w: %s of %s", application.ErrVersionNotFound, id, uid)
+	case 1:
+		return found[0], nil
+	default:
+		return application.LambdaVersion{}, fmt.Errorf("prefix %s matches %d versions of %s", id, len(found), uid)
+	}
+}
+
+// write files of lambda content to blobs. Should be called under lock
+func (st *Store) storeContent(fn application.Lambda) ([]entry, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(fn.Content(writer))
+	}()
+	defer reader.Close()
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	var files []entry
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		file := entry{Path: strings.Trim(filepath.ToSlash(header.Name), "/"), Mode: header.FileInfo().Mode()}
+		switch {
+		case file.Mode.IsDir():
+		case file.Mode.IsRegular():
+			file.Size = header.Size
+			file.SHA256, err = st.storeBlob(archive)
+			if err != nil {
+				return nil, fmt.Errorf("store %s: %w", file.Path, err)
+			}
+		default:
+			continue // symbolic links are not content of lambda
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// write content to blob named by hash (existent blob is kept). Returns hash
+func (st *Store) storeBlob(content io.Reader) (string, error) {
+	tmp, err := ioutil.TempFile(filepath.Join(st.dir, blobsDir), "snapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if _, err := os.Stat(st.blob(sum)); os.IsNotExist(err) {
+		if err := os.Rename(tmp.Name(), st.blob(sum)); err != nil {
+			return "", err
+		}
+	}
+	return sum, nil
+}
+
+// pack files of version to tar.gz verifying content by hashes. Should be called under lock
+func (st *Store) archive(files []entry, out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	writer := tar.NewWriter(gz)
+	for _, file := range files {
+		header := &tar.Header{Name: file.Path, Mode: int64(file.Mode.Perm()), Size: file.Size, ModTime: time.Now(), Typeflag: tar.TypeReg}
+		if file.Mode.IsDir() {
+			header.Name, header.Typeflag, header.Size = file.Path+"/", tar.TypeDir, 0
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if file.Mode.IsDir() {
+			continue
+		}
+		if err := st.copyBlob(file, writer); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (st *Store) copyBlob(file entry, out io.Writer) error {
+	f, err := os.Open(st.blob(file.SHA256))
+	if err != nil {
+		return fmt.Errorf("open content of %s: %w", file.Path, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(f, file.Size)); err != nil {
+		return fmt.Errorf("read content of %s: %w", file.Path, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != file.SHA256 {
+		return fmt.Errorf("content of %s is damaged: hash is %s instead of %s", file.Path, sum, file.SHA256)
+	}
+	return nil
+}
+
+// remove versions over retention, versions of removed lambdas and the oldest versions over maximum size (except the
+// newest and current versions of lambdas), save index and remove unused trees and blobs. Should be called under lock
+func (st *Store) prune() error {
+	keep := st.Keep
+	if keep == 0 {
+		keep = DefaultKeep
+	}
+	for uid, versions := range st.index {
+		if _, err := st.platform.FindByUID(uid); err != nil {
+			delete(st.index, uid)
+			continue
+		}
+		if keep > 0 && len(versions) > keep {
+			st.index[uid] = versions[:keep]
+		}
+	}
+	used, err := st.usedBlobs()
+	if err != nil {
+		return err
+	}
+	if st.MaxSize > 0 {
+		for size(used) > st.MaxSize && st.dropOldest() {
+			if used, err = st.usedBlobs(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := internal.AtomicWriteJson(filepath.Join(st.dir, indexFile), st.index); err != nil {
+		return fmt.Errorf("save index of versions: %w", err)
+	}
+	trees := make(map[string]bool)
+	for _, versions := range st.index {
+		for _, version := range versions {
+			trees[version.ID+treeExt] = true
+		}
+	}
+	st.removeUnused(treesDir, func(name string) bool { return trees[name] || !strings.HasSuffix(name, treeExt) })
+	// files being written are named by prefix of temporary file
+	st.removeUnused(blobsDir, func(name string) bool { _, ok := used[name]; return ok || len(name) != sha256.Size*2 })
+	return nil
+}
+
+// remove the oldest version which is not the newest or current version of lambda. Returns false if nothing to remove
+func (st *Store) dropOldest() bool {
+	var oldest *application.LambdaVersion
+	for _, versions := range st.index {
+		for i := 1; i < len(versions); i++ {
+			if !versions[i].Current && (oldest == nil || versions[i].Created.Before(oldest.Created)) {
+				oldest = &versions[i]
+			}
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	uid, id := oldest.UID, oldest.ID
+	var kept []application.LambdaVersion
+	for _, version := range st.index[uid] {
+		if version.ID != id {
+			kept = append(kept, version)
+		}
+	}
+	st.index[uid] = kept
+	return true
+}
+
+// sizes of blobs used by files of versions
+func (st *Store) usedBlobs() (map[string]int64, error) {
+	used := make(map[string]int64)
+	for _, versions := range st.index {
+		for _, version := range versions {
+			var files []entry
+			if err := internal.ReadJson(st.tree(version.ID), &files); err != nil {
+				return nil, fmt.Errorf("read files of version %s: %w", version.ID, err)
+			}
+			for _, file := range files {
+				if file.SHA256 != "" {
+					used[file.SHA256] = file.Size
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+func (st *Store) removeUnused(sub string, keep func(name string) bool) {
+	list, err := ioutil.ReadDir(filepath.Join(st.dir, sub))
+	if err != nil {
+		log.Println("[WARN] list stored versions -", err)
+		return
+	}
+	for _, item := range list {
+		if keep(item.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(st.dir, sub, item.Name())); err != nil {
+			log.Println("[WARN] remove stored version", item.Name(), "-", err)
+		}
+	}
+}
+
+func (st *Store) tree(id string) string {
+	return filepath.Join(st.dir, treesDir, id+treeExt)
+}
+
+func (st *Store) blob(hash string) string {
+	return filepath.Join(st.dir, blobsDir, hash)
+}
+
+// remove files of lambda content (except ignored by .cgiignore) and bundle pointer from copy of lambda, so files added
+// after version are not kept by rollback. Manifest is replaced by version, directories with ignored files are kept
+func removeContent(fn application.Lambda, stage string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(fn.Content(writer))
+	}()
+	defer reader.Close()
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	var names []string
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		name := strings.Trim(filepath.ToSlash(header.Name), "/")
+		if name == "" || name == internal.ManifestFile || name == internal.ManifestYAML || strings.HasPrefix(name, "../") || strings.Contains(name, "/../") {
+			continue
+		}
+		names = append(names, name)
+	}
+	// children before parents
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		path := filepath.Join(stage, filepath.FromSlash(name))
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !info.IsDir() {
+			return err
+		}
+	}
+	// bundle is replaced by files of version
+	if err := os.Remove(filepath.Join(stage, internal.BundlePointer)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// requested build action or types.DefaultBuildAction if defined by Makefile of version
+func buildAction(staged application.Lambda, action string) (string, error) {
+	if action != "" {
+		return action, nil
+	}
+	actions, err := staged.Actions()
+	if err != nil {
+		return "", fmt.Errorf("list actions of version: %w", err)
+	}
+	for _, name := range actions {
+		if name == types.DefaultBuildAction {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// hash of names, modes and content of files
+func treeID(files []entry) string {
+	hash := sha256.New()
+	for _, file := range files {
+		_, _ = fmt.Fprintf(hash, "%s\x00%o\x00%s\n", file.Path, uint32(file.Mode), file.SHA256)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func size(blobs map[string]int64) int64 {
+	var total int64
+	for _, size := range blobs {
+		total += size
+	}
+	return total
+}
@@ -0,0 +1,181 @@
+package versions_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/application/versions"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const uid = testutil.UID
+
+func setup(t *testing.T) (string, application.Platform, *versions.Store) {
+	dir, plato := testutil.Platform(t, types.Manifest{Run: []string{"cat", "version.txt"}})
+	write(t, dir, "version.txt", "v1")
+	store, err := versions.New(plato, dir, filepath.Join(dir, internal.VersionsDir))
+	require.NoError(t, err)
+	return dir, plato, store
+}
+
+func write(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, uid, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func ids(list []application.LambdaVersion) []string {
+	var ans []string
+	for _, version := range list {
+		ans = append(ans, version.ID)
+	}
+	return ans
+}
+
+func blobs(t *testing.T, dir string) int {
+	list, err := ioutil.ReadDir(filepath.Join(dir, internal.VersionsDir, "blobs"))
+	require.NoError(t, err)
+	return len(list)
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	dir, _, store := setup(t)
+	write(t, dir, "static/index.html", "<html></html>")
+
+	first, err := store.Snapshot(uid, "admin", "initial")
+	require.NoError(t, err)
+	assert.Equal(t, uid, first.UID)
+	assert.Equal(t, "admin", first.Author)
+	assert.Equal(t, "initial", first.Message)
+	assert.True(t, first.Current)
+	assert.Len(t, first.ID, 64)
+	stored := blobs(t, dir)
+
+	again, err := store.Snapshot(uid, "other", "")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "unchanged lambda is the same version")
+	assert.Equal(t, "initial", again.Message, "message is kept")
+	annotated, err := store.Snapshot(uid, "other", "annotated")
+	require.NoError(t, err)
+	assert.Equal(t, "annotated", annotated.Message)
+	assert.Len(t, store.Versions(uid), 1)
+
+	write(t, dir, "version.txt", "v2")
+	second, err := store.Snapshot(uid, "admin", "")
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, []string{second.ID, first.ID}, ids(store.Versions(uid)))
+	assert.Equal(t, stored+1, blobs(t, dir), "unchanged files are stored once")
+	list := store.Versions(uid)
+	assert.True(t, list[0].Current)
+	assert.False(t, list[1].Current)
+
+	// index is persistent
+	reopened, err := versions.New(reloaded(t, dir), dir, filepath.Join(dir, internal.VersionsDir))
+	require.NoError(t, err)
+	assert.Equal(t, []string{second.ID, first.ID}, ids(reopened.Versions(uid)))
+}
+
+func reloaded(t *testing.T, dir string) application.Platform {
+	plato, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	fn, err := lambda.FromDir(filepath.Join(dir, uid))
+	require.NoError(t, err)
+	require.NoError(t, plato.Add(uid, fn))
+	return plato
+}
+
+func TestStore_prune(t *testing.T) {
+	dir, _, store := setup(t)
+	store.Keep = 2
+	var created []string
+	for _, content := range []string{"first", "second", "third"} {
+		write(t, dir, "version.txt", content)
+		version, err := store.Snapshot(uid, "", "")
+		require.NoError(t, err)
+		created = append(created, version.ID)
+	}
+	assert.Equal(t, []string{created[2], created[1]}, ids(store.Versions(uid)))
+	trees, err := ioutil.ReadDir(filepath.Join(dir, internal.VersionsDir, "trees"))
+	require.NoError(t, err)
+	assert.Len(t, trees, 2, "files of pruned version are removed")
+
+	// size cap keeps the newest version only
+	store.Keep = -1
+	store.MaxSize = 1
+	write(t, dir, "version.txt", strings.Repeat("x", 100))
+	newest, err := store.Snapshot(uid, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{newest.ID}, ids(store.Versions(uid)))
+}
+
+func TestStore_Rollback(t *testing.T) {
+	dir, plato, store := setup(t)
+	write(t, dir, internal.CGIIgnore, "data/*\nbuilt.txt\n")
+	write(t, dir, "Makefile", "build:\n\tcat version.txt > built.txt\n")
+	first, err := store.Snapshot(uid, "admin", "")
+	require.NoError(t, err)
+
+	write(t, dir, "version.txt", "v2")
+	write(t, dir, "extra/added.txt", "added")
+	write(t, dir, "data/state.db", "state")
+	def, err := plato.FindByUID(uid)
+	require.NoError(t, err)
+	manifest := def.Lambda.Manifest()
+	manifest.Description = "second"
+	require.NoError(t, def.Lambda.SetManifest(manifest))
+	second, err := store.Snapshot(uid, "admin", "")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", testutil.Invoke(t, plato, uid))
+
+	report, err := store.Rollback(context.Background(), uid, application.VersionRollback{ID: first.ID[:8]})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, report.Version.ID)
+	assert.Equal(t, "build", report.Action, "default build action of Makefile")
+	assert.Equal(t, "v1", testutil.Invoke(t, plato, uid))
+	assert.Equal(t, "", def.Lambda.Manifest().Description, "manifest of version is restored")
+	assert.NoFileExists(t, filepath.Join(dir, uid, "extra", "added.txt"), "files added after version are removed")
+	assert.FileExists(t, filepath.Join(dir, uid, "data", "state.db"), "ignored files are kept")
+	built, err := ioutil.ReadFile(filepath.Join(dir, uid, "built.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(built))
+	list := store.Versions(uid)
+	assert.Equal(t, []string{second.ID, first.ID}, ids(list))
+	assert.False(t, list[0].Current)
+	assert.True(t, list[1].Current)
+
+	// failed build keeps files of lambda
+	write(t, dir, "Makefile", "build:\n\techo broken build >&2; exit 1\n")
+	broken, err := store.Snapshot(uid, "admin", "")
+	require.NoError(t, err)
+	_, err = store.Rollback(context.Background(), uid, application.VersionRollback{ID: second.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", testutil.Invoke(t, plato, uid))
+	_, err = store.Rollback(context.Background(), uid, application.VersionRollback{ID: broken.ID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken build", "stderr of action")
+	assert.Equal(t, "v2", testutil.Invoke(t, plato, uid), "lambda is not changed")
+	report, err = store.Rollback(context.Background(), uid, application.VersionRollback{ID: broken.ID, NoBuild: true})
+	require.NoError(t, err)
+	assert.Empty(t, report.Action)
+	assert.Equal(t, "v1", testutil.Invoke(t, plato, uid))
+
+	_, err = store.Rollback(context.Background(), uid, application.VersionRollback{ID: "unknown"})
+	assert.True(t, errors.Is(err, application.ErrVersionNotFound))
+	stages, err := ioutil.ReadDir(filepath.Join(dir, internal.VersionsDir, "stages"))
+	require.NoError(t, err)
+	assert.Empty(t, stages, "staged copies are removed")
+}
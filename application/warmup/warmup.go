@@ -0,0 +1,174 @@
+// Package warmup verifies lambdas on server start: availability of every lambda is checked (run executable, checks
+// and marker of manifest) and absent environment of lambdas with warm-up in manifest (ex: venv or node_modules lost by
+// migration of host) is rebuilt by install or build action before the first real request. Failures are only reported.
+package warmup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Default number of lambdas warmed up at the same time
+const DefaultParallel = 4
+
+// Default time limit of action which rebuilds environment
+const DefaultTimeLimit = 10 * time.Minute
+
+// tail of output of failed action in log
+const outputTail = 4096
+
+// actions of manifest or Makefile which rebuild environment in order of preference (install is post-clone action of templates)
+var rebuildActions = []string{"install", "build"}
+
+// Minimal required platform features
+type Platform interface {
+	List() []application.Definition
+	Health(ctx context.Context, lambda application.Lambda) []string
+	Do(ctx context.Context, lambda application.Lambda, action string, timeLimit time.Duration, out io.Writer) error
+	Start(ctx context.Context, lambda application.Lambda)
+}
+
+// New warm-up of lambdas of platform.
+func New(platform Platform) *WarmUp {
+	return &WarmUp{platform: platform}
+}
+
+// WarmUp of lambdas. Report is available while warm-up is running
+type WarmUp struct {
+	Parallel  int           // lambdas warmed up at the same time (zero - DefaultParallel)
+	TimeLimit time.Duration // time limit of action which rebuilds environment (zero - DefaultTimeLimit)
+	platform  Platform
+	lock      sync.Mutex
+	report    application.WarmUpReport
+}
+
+func (wu *WarmUp) Report() application.WarmUpReport {
+	wu.lock.Lock()
+	defer wu.lock.Unlock()
+	report := wu.report
+	report.Lambdas = append([]application.WarmUpResult(nil), wu.report.Lambdas...)
+	return report
+}
+
+// Run warm-up of all lambdas and wait till finished. Canceled context stops starting of the next lambdas
+func (wu *WarmUp) Run(ctx context.Context) {
+	list := wu.platform.List()
+	wu.lock.Lock()
+	wu.report = application.WarmUpReport{Running: true, Started: time.Now()}
+	wu.lock.Unlock()
+
+	parallel := wu.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for _, def := range list {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(def application.Definition) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result := wu.warmUp(ctx, def)
+			wu.lock.Lock()
+			wu.report.Lambdas = append(wu.report.Lambdas, result)
+			wu.lock.Unlock()
+		}(def)
+	}
+	wg.Wait()
+
+	wu.lock.Lock()
+	defer wu.lock.Unlock()
+	wu.report.Running = false
+	wu.report.Finished = time.Now()
+	var unavailable, rebuilt int
+	for _, result := range wu.report.Lambdas {
+		if !result.Available {
+			unavailable++
+		}
+		if result.Action != "" && result.Error == "" {
+			rebuilt++
+		}
+	}
+	log.Println("warm-up of", len(wu.report.Lambdas), "lambdas finished in", wu.report.Finished.Sub(wu.report.Started).Round(time.Millisecond), "- unavailable:", unavailable, "rebuilt:", rebuilt)
+}
+
+// check availability of lambda and rebuild environment if it's absent and enabled by manifest
+func (wu *WarmUp) warmUp(ctx context.Context, def application.Definition) application.WarmUpResult {
+	started := time.Now()
+	result := application.WarmUpResult{UID: def.UID, Name: def.Manifest.Name}
+	problems := wu.platform.Health(ctx, def.Lambda)
+	if len(problems) > 0 && def.Manifest.WarmUp {
+		result.Absent = problems
+		if err := wu.rebuild(ctx, def, &result); err != nil {
+			result.Error = err.Error()
+			log.Println("[ERROR]", "warm-up: rebuild environment of lambda", def.UID, "failed:", err)
+		} else {
+			problems = wu.platform.Health(ctx, def.Lambda)
+		}
+	}
+	result.Problems = problems
+	result.Available = len(problems) == 0
+	result.Duration = types.JsonDuration(time.Since(started))
+	if !result.Available {
+		log.Println("[WARN]", "warm-up: lambda", def.UID, "is unavailable:", strings.Join(problems, "; "))
+	}
+	return result
+}
+
+// run the first defined action of rebuild, startup action (if any) is re-run in rebuilt environment
+func (wu *WarmUp) rebuild(ctx context.Context, def application.Definition, result *application.WarmUpResult) error {
+	actions, err := def.Lambda.Actions()
+	if err != nil {
+		return err
+	}
+	for _, action := range rebuildActions {
+		for _, defined := range actions {
+			if action == defined {
+				result.Action = action
+			}
+		}
+		if result.Action != "" {
+			break
+		}
+	}
+	if result.Action == "" {
+		return errors.New("environment is absent, but neither manifest nor Makefile has install or build action")
+	}
+	timeLimit := wu.TimeLimit
+	if timeLimit <= 0 {
+		timeLimit = DefaultTimeLimit
+	}
+	log.Println("warm-up: rebuild environment of lambda", def.UID, "by", result.Action, "-", strings.Join(result.Absent, "; "))
+	var output bytes.Buffer
+	if err := wu.platform.Do(ctx, def.Lambda, result.Action, timeLimit, &output); err != nil {
+		return fmt.Errorf("action %s: %w: %s", result.Action, err, tail(output.Bytes()))
+	}
+	if def.Manifest.OnStart != nil {
+		wu.platform.Start(ctx, def.Lambda)
+	}
+	return nil
+}
+
+func tail(output []byte) string {
+	if len(output) > outputTail {
+		output = output[len(output)-outputTail:]
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,87 @@
+package warmup_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/application/warmup"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestWarmUp_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	plato, err := platform.New(filepath.Join(dir, "project.json"))
+	require.NoError(t, err)
+	add := func(uid string, makefile string, setup func(manifest *types.Manifest), run ...string) {
+		root := filepath.Join(dir, uid)
+		require.NoError(t, os.MkdirAll(root, 0755))
+		fn, err := lambda.DummyPublic(root, run[0], run[1:]...)
+		require.NoError(t, err)
+		if makefile != "" {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(root, "Makefile"), []byte(makefile), 0644))
+		}
+		manifest := fn.Manifest()
+		setup(&manifest)
+		require.NoError(t, fn.SetManifest(manifest))
+		require.NoError(t, plato.Add(uid, fn))
+	}
+	// interpreter of venv is lost: rebuilt by install
+	add("venv", "install:\n\tmkdir -p venv/bin && printf '#!/bin/sh\\necho ok\\n' > venv/bin/app && chmod +x venv/bin/app\n", func(manifest *types.Manifest) {
+		manifest.WarmUp = true
+	}, "./venv/bin/app")
+	// marker is missing, build is used without install
+	add("marker", "build:\n\tmkdir node_modules\n", func(manifest *types.Manifest) {
+		manifest.WarmUp = true
+		manifest.WarmUpMarker = "node_modules"
+	}, "cat")
+	// failed rebuild is reported
+	add("broken", "install:\n\techo no network >&2; exit 1\n", func(manifest *types.Manifest) {
+		manifest.WarmUp = true
+	}, "./missing")
+	// only verified without warm-up in manifest
+	add("manual", "install:\n\ttouch installed\n", func(manifest *types.Manifest) {}, "./missing")
+	add("ready", "", func(manifest *types.Manifest) {}, "cat")
+
+	warm := warmup.New(plato)
+	warm.Parallel = 2
+	warm.Run(context.Background())
+	report := warm.Report()
+	assert.False(t, report.Running)
+	assert.False(t, report.Finished.Before(report.Started))
+	results := make(map[string]application.WarmUpResult)
+	for _, result := range report.Lambdas {
+		results[result.UID] = result
+	}
+	require.Len(t, results, 5)
+
+	assert.True(t, results["venv"].Available)
+	assert.Equal(t, "install", results["venv"].Action)
+	assert.NotEmpty(t, results["venv"].Absent)
+	assert.Empty(t, results["venv"].Error)
+
+	assert.True(t, results["marker"].Available)
+	assert.Equal(t, "build", results["marker"].Action)
+	assert.DirExists(t, filepath.Join(dir, "marker", "node_modules"))
+
+	assert.False(t, results["broken"].Available)
+	assert.Equal(t, "install", results["broken"].Action)
+	assert.Contains(t, results["broken"].Error, "no network")
+
+	assert.False(t, results["manual"].Available)
+	assert.Empty(t, results["manual"].Action)
+	assert.NoFileExists(t, filepath.Join(dir, "manual", "installed"))
+
+	assert.True(t, results["ready"].Available)
+	assert.Empty(t, results["ready"].Absent)
+}
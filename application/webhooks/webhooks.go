@@ -0,0 +1,390 @@
+// Package webhooks delivers lifecycle events of lambdas (changes, failed invocations, missed schedules) to webhooks of
+// server and of lambdas as signed JSON POSTs. Deliveries are kept in memory by bounded queue (events over the limit
+// are dropped) and retried with exponential backoff. Recent attempts are kept for admin API.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Headers of delivery
+const (
+	EventHeader   = "X-Event"    // type of event
+	EventIDHeader = "X-Event-Id" // ID of event, the same for all attempts
+	// t=<unix time>,v1=<hex of HMAC-SHA256 of "<unix time>.<body>">: the same as stripe preset of webhook signature
+	SignatureHeader = "X-Signature"
+)
+
+// Defaults of options
+const (
+	DefaultQueue   = 1024
+	DefaultBackoff = time.Second
+	DefaultTimeout = 10 * time.Second
+	DefaultHistory = 200
+)
+
+const (
+	workers    = 4
+	maxBackoff = 5 * time.Minute
+	maxReply   = 4096 // read bytes of reply of webhook (connection is reused)
+)
+
+// Minimal required platform features
+type Platform interface {
+	FindByUID(uid string) (*application.Definition, error)
+	Expand(lambda application.Lambda, value string) string
+}
+
+// Options of delivery
+type Options struct {
+	Webhooks []types.LifecycleWebhook // webhooks of server (events of all lambdas)
+	Queue    int                      // maximum of pending deliveries, including waiting for retry (zero - DefaultQueue)
+	Retries  int                      // attempts after the first failed attempt (zero - not retried)
+	Backoff  time.Duration            // delay before the first retry, doubled for every next retry (zero - DefaultBackoff)
+	Timeout  time.Duration            // time limit of single attempt (zero - DefaultTimeout)
+	History  int                      // number of recent attempts kept for API (zero - DefaultHistory)
+}
+
+// Event delivered to webhook as JSON body
+type Event struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"` // see types.Event* constants
+	Time       time.Time              `json:"time"`
+	Lambda     string                 `json:"lambda"`
+	Change     *application.Change    `json:"change,omitempty"`     // administrative change (lambda.* events)
+	Invocation *Invocation            `json:"invocation,omitempty"` // failed invocation (invocation.failed)
+	Schedule   *MissedSchedule        `json:"schedule,omitempty"`   // missed runs (schedule.missed)
+	Deploy     *application.GitStatus `json:"deploy,omitempty"`     // failed deploy from git (deploy.failed)
+}
+
+// Failed invocation. Request, output and stderr are included only for webhooks with payload
+type Invocation struct {
+	RequestID string         `json:"request_id"`
+	Error     string         `json:"error"`
+	Duration  float64        `json:"duration_ms"`
+	Status    int            `json:"status,omitempty"`  // HTTP status of response (zero - nothing is sent)
+	Alias     string         `json:"alias,omitempty"`   // link used by request
+	Queue     string         `json:"queue,omitempty"`   // queue of executed request
+	Attempt   int            `json:"attempt,omitempty"` // attempt of retried execution
+	Request   *types.Request `json:"request,omitempty"` // request without body
+	Output    string         `json:"output,omitempty"`  // prefix of response body
+	Stderr    string         `json:"stderr,omitempty"`  // tail of stderr of lambda process
+}
+
+// Runs of schedule missed while server was down
+type MissedSchedule struct {
+	Name   string    `json:"name"`   // name of schedule (or cron expression with action)
+	Missed int       `json:"missed"` // number of missed runs (not more than types.MaxCatchUpRuns)
+	First  time.Time `json:"first"`  // the first missed run
+	Last   time.Time `json:"last"`   // the last missed run
+	Policy string    `json:"policy"` // policy of missed runs (see types.Missed* constants)
+}
+
+// New notifier of webhooks. Deliveries are started by Start
+func New(platform Platform, options Options) *Notifier {
+	if options.Queue <= 0 {
+		options.Queue = DefaultQueue
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = DefaultBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.History <= 0 {
+		options.History = DefaultHistory
+	}
+	return &Notifier{
+		platform: platform,
+		options:  options,
+		client:   &http.Client{Timeout: options.Timeout},
+		slots:    make(chan struct{}, options.Queue),
+		ready:    make(chan *delivery, options.Queue),
+	}
+}
+
+// Notifier of webhooks by lifecycle events: journal observer (Change), recorder of invocations (Track) and observer of
+// missed runs (Missed)
+type Notifier struct {
+	platform Platform
+	options  Options
+	client   *http.Client
+	slots    chan struct{}  // pending deliveries, including waiting for retry
+	ready    chan *delivery // deliveries ready for attempt
+	lock     sync.Mutex
+	history  []application.WebhookDelivery // the oldest first
+}
+
+type delivery struct {
+	event   Event
+	webhook types.LifecycleWebhook
+	secret  string // resolved secret
+	body    []byte
+	attempt int
+}
+
+// Start workers of deliveries. Pending deliveries are abandoned when context is done
+func (n *Notifier) Start(ctx context.Context) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-n.ready:
+					n.attempt(ctx, d)
+				}
+			}
+		}()
+	}
+}
+
+// Change of lambda recorded by journal (see journal.Journal.Observer): created, removed or updated lambda. Server-wide
+// and other changes are ignored
+func (n *Notifier) Change(change application.Change) {
+	if change.Lambda == "" {
+		return
+	}
+	var kind string
+	switch change.Kind {
+	case application.ChangeCreate:
+		kind = types.EventLambdaCreated
+	case application.ChangeRemove:
+		kind = types.EventLambdaDeleted
+	case application.ChangeDeploy, application.ChangeManifest, application.ChangeSchedule, application.ChangeAlias, application.ChangeState:
+		kind = types.EventLambdaUpdated
+	default:
+		return
+	}
+	n.publish(Event{Type: kind, Time: change.Time, Lambda: change.Lambda, Change: &change})
+}
+
+// Track invocation record: failed invocations are delivered, rejected requests and retried attempts are not
+func (n *Notifier) Track(record stats.Record) {
+	if record.Rejected || !record.Failed() {
+		return
+	}
+	request := record.Request
+	request.Body = nil
+	n.publish(Event{Type: types.EventInvocationFailed, Time: record.End, Lambda: record.UID, Invocation: &Invocation{
+		RequestID: record.Request.ID,
+		Error:     record.Err,
+		Duration:  float64(record.End.Sub(record.Begin)) / float64(time.Millisecond),
+		Status:    record.Status,
+		Alias:     record.Request.Alias,
+		Queue:     record.Queue,
+		Attempt:   record.Attempt,
+		Request:   &request,
+		Output:    string(record.Output),
+		Stderr:    string(record.Stderr),
+	}})
+}
+
+// Missed runs of schedule of lambda during downtime (see platform.MissedRuns)
+func (n *Notifier) Missed(uid string, schedule types.Schedule, runs []time.Time) {
+	if len(runs) == 0 {
+		return
+	}
+	policy := schedule.Missed
+	if policy == "" {
+		policy = types.MissedRun
+	}
+	n.publish(Event{Type: types.EventScheduleMissed, Time: time.Now(), Lambda: uid, Schedule: &MissedSchedule{
+		Name:   schedule.Label(),
+		Missed: len(runs),
+		First:  runs[0],
+		Last:   runs[len(runs)-1],
+		Policy: policy,
+	}})
+}
+
+// DeployFailed publishes failed deploy of lambda from git. Output of build is included only for webhooks with payload
+func (n *Notifier) DeployFailed(uid string, status application.GitStatus) {
+	n.publish(Event{Type: types.EventDeployFailed, Time: status.Checked, Lambda: uid, Deploy: &status})
+}
+
+// Deliveries is recent attempts from the newest (zero limit - all kept attempts)
+func (n *Notifier) Deliveries(limit int) []application.WebhookDelivery {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if limit <= 0 || limit > len(n.history) {
+		limit = len(n.history)
+	}
+	var ans = make([]application.WebhookDelivery, 0, limit)
+	for i := len(n.history) - 1; i >= 0 && len(ans) < limit; i-- {
+		ans = append(ans, n.history[i])
+	}
+	return ans
+}
+
+// queue event for webhooks of server and lambda which accept it. Event is dropped for webhook if queue is full
+func (n *Notifier) publish(event Event) {
+	event.ID = uuid.New().String()
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, webhook := range n.options.Webhooks {
+		if webhook.Accepts(event.Type) {
+			n.enqueue(event, webhook, webhook.Secret)
+		}
+	}
+	if event.Type == types.EventLambdaDeleted {
+		return // lambda and its webhooks are already removed
+	}
+	def, err := n.platform.FindByUID(event.Lambda)
+	if err != nil {
+		return
+	}
+	for _, webhook := range def.Lambda.Manifest().EventWebhooks {
+		if webhook.Accepts(event.Type) {
+			n.enqueue(event, webhook, n.platform.Expand(def.Lambda, webhook.Secret))
+		}
+	}
+}
+
+func (n *Notifier) enqueue(event Event, webhook types.LifecycleWebhook, secret string) {
+	if event.Invocation != nil && !webhook.Payload {
+		invocation := *event.Invocation
+		invocation.Request, invocation.Output, invocation.Stderr = nil, "", ""
+		event.Invocation = &invocation
+	}
+	if event.Deploy != nil && !webhook.Payload {
+		deploy := *event.Deploy
+		deploy.Output = ""
+		event.Deploy = &deploy
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Println("[ERROR]", "webhooks: encode event", event.Type, "-", err)
+		return
+	}
+	select {
+	case n.slots <- struct{}{}:
+	default:
+		log.Println("[WARN]", "webhooks: queue is full, event", event.Type, "of", event.Lambda, "to", webhook.URL, "dropped")
+		n.record(application.WebhookDelivery{
+			Event:  event.ID,
+			Type:   event.Type,
+			Lambda: event.Lambda,
+			URL:    webhook.URL,
+			Time:   time.Now(),
+			Error:  "delivery queue is full, event dropped",
+		})
+		return
+	}
+	n.ready <- &delivery{event: event, webhook: webhook, secret: secret, body: body}
+}
+
+// attempt of delivery, failed delivery is returned to queue after backoff if retries are not exhausted
+func (n *Notifier) attempt(ctx context.Context, d *delivery) {
+	d.attempt++
+	started := time.Now()
+	status, err := n.send(ctx, d)
+	result := application.WebhookDelivery{
+		Event:    d.event.ID,
+		Type:     d.event.Type,
+		Lambda:   d.event.Lambda,
+		URL:      d.webhook.URL,
+		Attempt:  d.attempt,
+		Time:     time.Now(),
+		Status:   status,
+		Duration: float64(time.Since(started)) / float64(time.Millisecond),
+	}
+	if err == nil {
+		n.record(result)
+		<-n.slots
+		return
+	}
+	result.Error = err.Error()
+	if d.attempt > n.options.Retries || !retryable(status) || ctx.Err() != nil {
+		log.Println("[WARN]", "webhooks: event", d.event.Type, "of", d.event.Lambda, "to", d.webhook.URL, "not delivered after", d.attempt, "attempts -", err)
+		n.record(result)
+		<-n.slots
+		return
+	}
+	delay := n.backoff(d.attempt)
+	result.Retry = result.Time.Add(delay)
+	n.record(result)
+	// slot is kept, so ready queue has room for delivery
+	time.AfterFunc(delay, func() { n.ready <- d })
+}
+
+func (n *Notifier) send(ctx context.Context, d *delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, n.options.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhook.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "trusted-cgi")
+	req.Header.Set(EventHeader, d.event.Type)
+	req.Header.Set(EventIDHeader, d.event.ID)
+	if d.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.body, d.secret, time.Now()))
+	}
+	res, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxReply))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// failed attempt could succeed later: no response, server error, timeout or rate limit of receiver
+func retryable(status int) bool {
+	return status == 0 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// delay after failed attempt
+func (n *Notifier) backoff(attempt int) time.Duration {
+	delay := n.options.Backoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func (n *Notifier) record(attempt application.WebhookDelivery) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if len(n.history) >= n.options.History {
+		n.history = append(n.history[:0], n.history[len(n.history)-n.options.History+1:]...)
+	}
+	n.history = append(n.history, attempt)
+}
+
+// Sign body by secret at the moment: value of SignatureHeader
+func Sign(body []byte, secret string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,281 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type mockPlatform struct {
+	def *application.Definition
+}
+
+func (mp *mockPlatform) FindByUID(uid string) (*application.Definition, error) {
+	if mp.def == nil || uid != mp.def.UID {
+		return nil, fmt.Errorf("unknown lambda %s", uid)
+	}
+	return mp.def, nil
+}
+
+func (mp *mockPlatform) Expand(_ application.Lambda, value string) string {
+	return strings.ReplaceAll(value, "${SECRET}", "lambda-secret")
+}
+
+type received struct {
+	headers http.Header
+	body    []byte
+}
+
+type receiver struct {
+	lock     sync.Mutex
+	statuses []int // replies of attempts, the last one is repeated
+	events   []received
+	server   *httptest.Server
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rc := &receiver{statuses: statuses}
+	rc.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		rc.lock.Lock()
+		rc.events = append(rc.events, received{headers: request.Header.Clone(), body: body})
+		status := http.StatusNoContent
+		if len(rc.statuses) > 0 {
+			status = rc.statuses[0]
+			if len(rc.statuses) > 1 {
+				rc.statuses = rc.statuses[1:]
+			}
+		}
+		rc.lock.Unlock()
+		writer.WriteHeader(status)
+	}))
+	t.Cleanup(rc.server.Close)
+	return rc
+}
+
+func (rc *receiver) received() []received {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return append([]received(nil), rc.events...)
+}
+
+func (rc *receiver) wait(t *testing.T, n int) []received {
+	require.Eventually(t, func() bool { return len(rc.received()) >= n }, 5*time.Second, 10*time.Millisecond)
+	return rc.received()
+}
+
+func headers(item received) map[string]string {
+	var ans = make(map[string]string)
+	for name := range item.headers {
+		ans[name] = item.headers.Get(name)
+	}
+	return ans
+}
+
+func decode(t *testing.T, body []byte) Event {
+	var event Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	return event
+}
+
+func TestNotifier_signedDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rc := newReceiver(t)
+	notifier := New(&mockPlatform{}, Options{Webhooks: []types.LifecycleWebhook{
+		{URL: rc.server.URL, Secret: "server-secret", Events: []string{types.EventLambdaCreated, types.EventLambdaDeleted}},
+	}})
+	notifier.Start(ctx)
+
+	notifier.Change(application.Change{Kind: application.ChangeDeploy, Lambda: "fn"}) // not subscribed
+	notifier.Change(application.Change{Kind: application.ChangeSettings})             // not a lambda change
+	notifier.Change(application.Change{Kind: application.ChangeCreate, Lambda: "fn", Time: time.Now()})
+
+	got := rc.wait(t, 1)[0]
+	event := decode(t, got.body)
+	assert.Equal(t, types.EventLambdaCreated, event.Type)
+	assert.Equal(t, "fn", event.Lambda)
+	require.NotNil(t, event.Change)
+	assert.Equal(t, application.ChangeCreate, event.Change.Kind)
+	assert.Equal(t, types.EventLambdaCreated, got.headers.Get(EventHeader))
+	assert.Equal(t, event.ID, got.headers.Get(EventIDHeader))
+
+	// signature could be verified by receiver, for example by stripe preset of webhook signature of lambda
+	verifier := types.WebhookSignature{Preset: types.WebhookStripe, Header: SignatureHeader}
+	assert.NoError(t, verifier.Verify(headers(got), got.body, "server-secret", time.Now()))
+	assert.Error(t, verifier.Verify(headers(got), got.body, "other-secret", time.Now()))
+
+	require.Eventually(t, func() bool { return len(notifier.Deliveries(0)) == 1 }, time.Second, 10*time.Millisecond)
+	delivery := notifier.Deliveries(0)[0]
+	assert.Equal(t, 1, delivery.Attempt)
+	assert.Equal(t, http.StatusNoContent, delivery.Status)
+	assert.Empty(t, delivery.Error)
+	assert.Len(t, rc.received(), 1)
+}
+
+func TestNotifier_retries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rc := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	rejecting := newReceiver(t, http.StatusBadRequest)
+	notifier := New(&mockPlatform{}, Options{
+		Webhooks: []types.LifecycleWebhook{{URL: rc.server.URL}, {URL: rejecting.server.URL}},
+		Retries:  3,
+		Backoff:  time.Millisecond,
+	})
+	notifier.Start(ctx)
+	notifier.Change(application.Change{Kind: application.ChangeRemove, Lambda: "fn"})
+
+	attempts := rc.wait(t, 3)
+	assert.Equal(t, attempts[0].headers.Get(EventIDHeader), attempts[2].headers.Get(EventIDHeader), "the same event")
+	require.Eventually(t, func() bool { return len(notifier.Deliveries(0)) == 4 }, time.Second, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, rejecting.received(), 1, "client errors are not retried")
+
+	var statuses = map[string][]int{}
+	for _, delivery := range notifier.Deliveries(0) {
+		statuses[delivery.URL] = append(statuses[delivery.URL], delivery.Status)
+		if delivery.Status == http.StatusServiceUnavailable {
+			assert.False(t, delivery.Retry.IsZero())
+		}
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusServiceUnavailable}, statuses[rc.server.URL])
+	assert.Equal(t, []int{http.StatusBadRequest}, statuses[rejecting.server.URL])
+	assert.Len(t, notifier.Deliveries(2), 2)
+}
+
+func TestNotifier_queueIsBounded(t *testing.T) {
+	rc := newReceiver(t)
+	notifier := New(&mockPlatform{}, Options{Webhooks: []types.LifecycleWebhook{{URL: rc.server.URL}}, Queue: 2})
+	// not started: nothing is delivered, so queue is filled
+	for i := 0; i < 3; i++ {
+		notifier.Change(application.Change{Kind: application.ChangeCreate, Lambda: "fn"})
+	}
+	deliveries := notifier.Deliveries(0)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, 0, deliveries[0].Attempt)
+	assert.Contains(t, deliveries[0].Error, "queue is full")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifier.Start(ctx)
+	rc.wait(t, 2)
+}
+
+func TestNotifier_lambdaWebhooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := newReceiver(t)
+	own := newReceiver(t)
+	full := newReceiver(t)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fn, err := lambda.DummyPublic(dir, "echo")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.EventWebhooks = []types.LifecycleWebhook{
+		{URL: own.server.URL, Secret: "${SECRET}", Events: []string{types.EventInvocationFailed, types.EventScheduleMissed}},
+		{URL: full.server.URL, Payload: true, Events: []string{types.EventInvocationFailed}},
+	}
+	require.NoError(t, fn.SetManifest(manifest))
+	platform := &mockPlatform{def: &application.Definition{UID: "fn", Manifest: manifest, Lambda: fn}}
+
+	notifier := New(platform, Options{Webhooks: []types.LifecycleWebhook{{URL: server.server.URL}}})
+	notifier.Start(ctx)
+
+	begin := time.Now()
+	notifier.Track(stats.Record{UID: "fn", Err: "rejected", Rejected: true})
+	notifier.Track(stats.Record{UID: "fn", Err: "retried", Retried: true})
+	notifier.Track(stats.Record{UID: "fn", Begin: begin})
+	notifier.Track(stats.Record{
+		UID:     "fn",
+		Err:     "exit status 1",
+		Begin:   begin,
+		End:     begin.Add(1500 * time.Millisecond),
+		Status:  http.StatusBadGateway,
+		Output:  []byte("out"),
+		Stderr:  []byte("oops"),
+		Request: types.Request{ID: "req-1", Method: http.MethodPost, Body: ioutil.NopCloser(strings.NewReader("secret body"))},
+	})
+	notifier.Change(application.Change{Kind: application.ChangeRemove, Lambda: "fn"}) // lambda webhooks are removed too
+
+	event := decode(t, own.wait(t, 1)[0].body)
+	assert.Equal(t, types.EventInvocationFailed, event.Type)
+	require.NotNil(t, event.Invocation)
+	assert.Equal(t, "req-1", event.Invocation.RequestID)
+	assert.Equal(t, "exit status 1", event.Invocation.Error)
+	assert.Equal(t, 1500.0, event.Invocation.Duration)
+	assert.Equal(t, http.StatusBadGateway, event.Invocation.Status)
+	assert.Nil(t, event.Invocation.Request, "payload is not included by default")
+	assert.Empty(t, event.Invocation.Output)
+	assert.Empty(t, event.Invocation.Stderr)
+	verifier := types.WebhookSignature{Preset: types.WebhookStripe, Header: SignatureHeader}
+	assert.NoError(t, verifier.Verify(headers(own.received()[0]), own.received()[0].body, "lambda-secret", time.Now()))
+
+	got := full.wait(t, 1)[0]
+	assert.Empty(t, got.headers.Get(SignatureHeader))
+	event = decode(t, got.body)
+	require.NotNil(t, event.Invocation.Request)
+	assert.Equal(t, http.MethodPost, event.Invocation.Request.Method)
+	assert.Equal(t, "out", event.Invocation.Output)
+	assert.Equal(t, "oops", event.Invocation.Stderr)
+	assert.NotContains(t, string(got.body), "secret body")
+
+	notifier.Missed("fn", types.Schedule{Cron: "@every 1h", Missed: types.MissedSkip}, []time.Time{begin.Add(-2 * time.Hour), begin.Add(-time.Hour)})
+	event = decode(t, own.wait(t, 2)[1].body)
+	assert.Equal(t, types.EventScheduleMissed, event.Type)
+	require.NotNil(t, event.Schedule)
+	assert.Equal(t, 2, event.Schedule.Missed)
+	assert.Equal(t, types.MissedSkip, event.Schedule.Policy)
+	assert.True(t, event.Schedule.First.Before(event.Schedule.Last))
+
+	var kinds []string
+	for _, item := range server.wait(t, 3) {
+		kinds = append(kinds, decode(t, item.body).Type)
+	}
+	assert.ElementsMatch(t, []string{types.EventInvocationFailed, types.EventLambdaDeleted, types.EventScheduleMissed}, kinds)
+	assert.Len(t, own.received(), 2)
+	assert.Len(t, full.received(), 1)
+}
+
+func TestNotifier_deployFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	plain := newReceiver(t)
+	full := newReceiver(t)
+	notifier := New(&mockPlatform{}, Options{Webhooks: []types.LifecycleWebhook{
+		{URL: plain.server.URL, Events: []string{types.EventDeployFailed}},
+		{URL: full.server.URL, Payload: true},
+	}})
+	notifier.Start(ctx)
+	notifier.DeployFailed("fn", application.GitStatus{Repo: "repo", Commit: "aaa", Failed: "bbb", Error: "build failed", Output: "oops", Checked: time.Now()})
+
+	event := decode(t, plain.wait(t, 1)[0].body)
+	assert.Equal(t, types.EventDeployFailed, event.Type)
+	assert.Equal(t, "fn", event.Lambda)
+	require.NotNil(t, event.Deploy)
+	assert.Equal(t, "bbb", event.Deploy.Failed)
+	assert.Equal(t, "build failed", event.Deploy.Error)
+	assert.Empty(t, event.Deploy.Output, "output is not included by default")
+
+	event = decode(t, full.wait(t, 1)[0].body)
+	assert.Equal(t, "oops", event.Deploy.Output)
+}
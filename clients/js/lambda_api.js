@@ -21,14 +21,15 @@ export class LambdaAPI {
 
 
     /**
-    Upload content from .tar.gz archive to app and call Install handler (if defined)
+    Upload content from .tar.gz or .zip archive (detected by content) to app and call Install handler (if defined).
+Unknown format is error with code 415
     **/
-    async upload(token, uid, tarGz){
+    async upload(token, uid, archive){
         return (await this.__call('Upload', {
             "jsonrpc" : "2.0",
             "method" : "LambdaAPI.Upload",
             "id" : this.__next_id(),
-            "params" : [token, uid, tarGz]
+            "params" : [token, uid, archive]
         }));
     }
 
@@ -44,6 +45,54 @@ export class LambdaAPI {
         }));
     }
 
+    /**
+    Upload content as single read-only .zip bundle to app and returns bundle hash
+    **/
+    async uploadBundle(token, uid, zip){
+        return (await this.__call('UploadBundle', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.UploadBundle",
+            "id" : this.__next_id(),
+            "params" : [token, uid, zip]
+        }));
+    }
+
+    /**
+    Hash of app content (hash of bundle for bundled app)
+    **/
+    async contentHash(token, uid){
+        return (await this.__call('ContentHash', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.ContentHash",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Upload content as Upload does and return hash of received archive and content hash of app signed by token
+    **/
+    async uploadVerified(token, uid, archive, nonce){
+        return (await this.__call('UploadVerified', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.UploadVerified",
+            "id" : this.__next_id(),
+            "params" : [token, uid, archive, nonce]
+        }));
+    }
+
+    /**
+    Hash of app content (as ContentHash) signed by token for spot checks
+    **/
+    async signedContentHash(token, uid, nonce){
+        return (await this.__call('SignedContentHash', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SignedContentHash",
+            "id" : this.__next_id(),
+            "params" : [token, uid, nonce]
+        }));
+    }
+
     /**
     Push single file to app
     **/
@@ -105,14 +154,83 @@ export class LambdaAPI {
     }
 
     /**
-    Update application manifest
+    Update application manifest. Invalid manifest is error with code 422 and problems of fields
+(list of field and message) as data. Declared aliases are bound to the lambda, aliases removed from manifest
+are released. Aliases bound to other lambdas are error with code 409 and conflicts as data unless forceAliases
+is set (aliases are moved to the lambda)
     **/
-    async update(token, uid, manifest){
+    async update(token, uid, manifest, forceAliases){
         return (await this.__call('Update', {
             "jsonrpc" : "2.0",
             "method" : "LambdaAPI.Update",
             "id" : this.__next_id(),
-            "params" : [token, uid, manifest]
+            "params" : [token, uid, manifest, forceAliases]
+        }));
+    }
+
+    /**
+    Apply partial change of manifest (merge patch and assignments of fields) to selected lambdas. Every patched
+manifest is validated and applied the same way as by Update (aliases are not forced): failure of one lambda does
+not abort the batch and is reported in its result. Dry run reports changes without apply
+    **/
+    async bulkUpdate(token, request){
+        return (await this.__call('BulkUpdate', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.BulkUpdate",
+            "id" : this.__next_id(),
+            "params" : [token, request]
+        }));
+    }
+
+    /**
+    Environment variables of application from manifest (as is, references are not resolved)
+    **/
+    async environment(token, uid){
+        return (await this.__call('Environment', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Environment",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Set and remove environment variables of application without changing the rest of manifest. Returns updated
+environment. Applied for the next invocation
+    **/
+    async setEnvironment(token, uid, set, unset){
+        return (await this.__call('SetEnvironment', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SetEnvironment",
+            "id" : this.__next_id(),
+            "params" : [token, uid, set, unset]
+        }));
+    }
+
+    /**
+    Variables of environment of invocations of application: runtime defaults and global environment of server merged
+with environment of manifest (manifest wins). References are resolved except references to secrets. Variables of
+server process and request are not included
+    **/
+    async mergedEnvironment(token, uid){
+        return (await this.__call('MergedEnvironment', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.MergedEnvironment",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Set (passwords are hashed by server) and remove users of basic authentication of application without changing
+the rest of manifest. Authentication is removed with the last user. Returns updated usernames
+    **/
+    async setBasicAuth(token, uid, set, unset){
+        return (await this.__call('SetBasicAuth', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SetBasicAuth",
+            "id" : this.__next_id(),
+            "params" : [token, uid, set, unset]
         }));
     }
 
@@ -165,7 +283,32 @@ export class LambdaAPI {
     }
 
     /**
-    Actions available for the app
+    Records of the app in time range from the newest (UID of query is replaced by UID of the app)
+    **/
+    async statsRange(token, uid, query){
+        return (await this.__call('StatsRange', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.StatsRange",
+            "id" : this.__next_id(),
+            "params" : [token, uid, query]
+        }));
+    }
+
+    /**
+    Calls, errors by category and latency percentiles of the app by buckets of time range (UID of query is replaced
+by UID of the app, zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+    **/
+    async statsAggregate(token, uid, query, bucket, group){
+        return (await this.__call('StatsAggregate', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.StatsAggregate",
+            "id" : this.__next_id(),
+            "params" : [token, uid, query, bucket, group]
+        }));
+    }
+
+    /**
+    Actions available for the app: actions of manifest and targets of Makefile
     **/
     async actions(token, uid){
         return (await this.__call('Actions', {
@@ -177,7 +320,7 @@ export class LambdaAPI {
     }
 
     /**
-    Invoke action in the app (if make installed)
+    Invoke action in the app (action of manifest or make target, if make installed)
     **/
     async invoke(token, uid, action){
         return (await this.__call('Invoke', {
@@ -188,6 +331,18 @@ export class LambdaAPI {
         }));
     }
 
+    /**
+    Invoke action in the app with time limit (0 means no limit) and return output with exit code even for failed action
+    **/
+    async invokeAction(token, uid, action, timeLimit){
+        return (await this.__call('InvokeAction', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.InvokeAction",
+            "id" : this.__next_id(),
+            "params" : [token, uid, action, timeLimit]
+        }));
+    }
+
     /**
     Make link/alias for app
     **/
@@ -212,6 +367,257 @@ export class LambdaAPI {
         }));
     }
 
+    /**
+    Effective runtime settings (umask, locale, timezone) of the app
+    **/
+    async doctor(token, uid){
+        return (await this.__call('Doctor', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Doctor",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Captured requests of the app from the newest without bodies (see types.Manifest.Capture)
+    **/
+    async requests(token, uid){
+        return (await this.__call('Requests', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Requests",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Captured request of the app with body
+    **/
+    async capturedRequest(token, uid, id){
+        return (await this.__call('CapturedRequest', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.CapturedRequest",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        }));
+    }
+
+    /**
+    Invoke the app by captured request again and return new result. Truncated requests can not be replayed
+    **/
+    async replay(token, uid, id){
+        return (await this.__call('Replay', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Replay",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        }));
+    }
+
+    /**
+    Enable or disable the app without removing it. Disabled app responds 503 to public requests (by UID and
+aliases), its schedules are paused and queued requests are held till it is enabled. State survives restarts
+    **/
+    async setEnabled(token, uid, enabled){
+        return (await this.__call('SetEnabled', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SetEnabled",
+            "id" : this.__next_id(),
+            "params" : [token, uid, enabled]
+        }));
+    }
+
+    /**
+    Generate slug alias from name of the app (previous slug is kept as alias)
+    **/
+    async regenerateSlug(token, uid){
+        return (await this.__call('RegenerateSlug', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.RegenerateSlug",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Reset fired alert rules of the app (all rules if rule is empty) and re-enable it
+    **/
+    async resetAlerts(token, uid, rule){
+        return (await this.__call('ResetAlerts', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.ResetAlerts",
+            "id" : this.__next_id(),
+            "params" : [token, uid, rule]
+        }));
+    }
+
+    /**
+    Fetch tracked git branch of the app (see types.Manifest.Git) and deploy new commit (force - deploy even the same
+or failed commit). Failed deploy keeps the previous version and is returned as status with error. Error with code
+404 if the app does not track git, 409 if deploy is in progress
+    **/
+    async gitDeploy(token, uid, force){
+        return (await this.__call('GitDeploy', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.GitDeploy",
+            "id" : this.__next_id(),
+            "params" : [token, uid, force]
+        }));
+    }
+
+    /**
+    Short-lived (5 minutes) grant of export of the app by another server (see Export and ProjectAPI.Transfer)
+with hash of exported content. Values of environment variables are exported only if secrets is set
+    **/
+    async grantExport(token, uid, secrets){
+        return (await this.__call('GrantExport', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.GrantExport",
+            "id" : this.__next_id(),
+            "params" : [token, uid, secrets]
+        }));
+    }
+
+    /**
+    Export the app by token of grant (see GrantExport) instead of login token: manifest, aliases and content
+    **/
+    async export(grant, uid){
+        return (await this.__call('Export', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Export",
+            "id" : this.__next_id(),
+            "params" : [grant, uid]
+        }));
+    }
+
+    /**
+    Freeze all files of the app, including dependencies installed by actions and files ignored by .cgiignore, to
+content-addressed artifact kept by server: freeze of the same files returns the same artifact. Old artifacts are
+pruned by retention of server
+    **/
+    async freeze(token, uid){
+        return (await this.__call('Freeze', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Freeze",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Frozen artifacts of the app from the newest
+    **/
+    async frozenArtifacts(token, uid){
+        return (await this.__call('FrozenArtifacts', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.FrozenArtifacts",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Download frozen artifact of the app (empty id - the newest) as .tar.gz archive. Unknown artifact is error with
+code 404
+    **/
+    async downloadFrozen(token, uid, id){
+        return (await this.__call('DownloadFrozen', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.DownloadFrozen",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        }));
+    }
+
+    /**
+    Replace all files of the app by frozen artifact (empty id - the newest) without builds and network: manifest of
+artifact is applied. Unknown artifact is error with code 404
+    **/
+    async thaw(token, uid, id){
+        return (await this.__call('Thaw', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Thaw",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        }));
+    }
+
+    /**
+    Upload content as Upload does and return version of the app annotated by message (upload of the same files
+returns the same version). Versions are also taken by every upload and update of manifest
+    **/
+    async uploadVersion(token, uid, archive, message){
+        return (await this.__call('UploadVersion', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.UploadVersion",
+            "id" : this.__next_id(),
+            "params" : [token, uid, archive, message]
+        }));
+    }
+
+    /**
+    Versions of the app (files and manifest) from the newest. Old versions are pruned by retention of server
+    **/
+    async versions(token, uid){
+        return (await this.__call('Versions', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Versions",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Restore files and manifest of version of the app (ID or unique prefix) and run build action (empty - build if
+defined by Makefile of version) before files are replaced: failed build keeps the app. Unknown version is error
+with code 404
+    **/
+    async rollback(token, uid, rollback){
+        return (await this.__call('Rollback', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Rollback",
+            "id" : this.__next_id(),
+            "params" : [token, uid, rollback]
+        }));
+    }
+
+    /**
+    Log files of the app (full stdout and stderr of invocations if logs are enabled by manifest) from the oldest
+    **/
+    async logFiles(token, uid){
+        return (await this.__call('LogFiles', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.LogFiles",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
+    /**
+    Download log file of the app by name (see LogFiles). Unknown file is error with code 404
+    **/
+    async downloadLog(token, uid, name){
+        return (await this.__call('DownloadLog', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.DownloadLog",
+            "id" : this.__next_id(),
+            "params" : [token, uid, name]
+        }));
+    }
+
+    /**
+    The last lines (zero - 100) of current log file of the app
+    **/
+    async tailLog(token, uid, lines){
+        return (await this.__call('TailLog', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.TailLog",
+            "id" : this.__next_id(),
+            "params" : [token, uid, lines]
+        }));
+    }
+
 
 
     __next_id() {
@@ -56,6 +56,19 @@ export class ProjectAPI {
         }));
     }
 
+    /**
+    Set and remove variables of global environment without changing the rest. Returns updated global environment.
+Applied for the next invocation of every lambda
+    **/
+    async updateEnvironment(token, set, unset){
+        return (await this.__call('UpdateEnvironment', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.UpdateEnvironment",
+            "id" : this.__next_id(),
+            "params" : [token, set, unset]
+        }));
+    }
+
     /**
     Get all templates without filtering
     **/
@@ -104,6 +117,57 @@ export class ProjectAPI {
         }));
     }
 
+    /**
+    Global records in time range (optionally of one lambda) from the newest
+    **/
+    async statsRange(token, query){
+        return (await this.__call('StatsRange', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.StatsRange",
+            "id" : this.__next_id(),
+            "params" : [token, query]
+        }));
+    }
+
+    /**
+    Global calls, errors by category and latency percentiles (optionally of one lambda) by buckets of time range
+(zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+    **/
+    async statsAggregate(token, query, bucket, group){
+        return (await this.__call('StatsAggregate', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.StatsAggregate",
+            "id" : this.__next_id(),
+            "params" : [token, query, bucket, group]
+        }));
+    }
+
+    /**
+    Usage of storage of stats: records, size, the oldest record, retention and the last pruning (behind - pruning
+doesn't keep up with limits)
+    **/
+    async statsUsage(token){
+        return (await this.__call('StatsUsage', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.StatsUsage",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Change retention of stats without restart: server limits and limits of lambdas by UID (zero - not limited).
+Records over new limits are pruned in background
+    **/
+    async setStatsRetention(token, retention){
+        return (await this.__call('SetStatsRetention', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.SetStatsRetention",
+            "id" : this.__next_id(),
+            "params" : [token, retention]
+        }));
+    }
+
     /**
     Create new app (lambda)
     **/
@@ -117,7 +181,8 @@ export class ProjectAPI {
     }
 
     /**
-    Create new app/lambda/function using pre-defined template
+    Create new app/lambda/function using pre-defined template. Invalid manifest is error with code 422 (see Update)
+Resolved outputs of template (ex: example of call) are in outputs field
     **/
     async createFromTemplate(token, templateName){
         return (await this.__call('CreateFromTemplate', {
@@ -129,7 +194,7 @@ export class ProjectAPI {
     }
 
     /**
-    Create new app/lambda/function using remote Git repo
+    Create new app/lambda/function using remote Git repo. Invalid manifest is error with code 422 (see Update)
     **/
     async createFromGit(token, repo){
         return (await this.__call('CreateFromGit', {
@@ -140,6 +205,295 @@ export class ProjectAPI {
         }));
     }
 
+    /**
+    Create new app/lambda/function from template (empty - default manifest) with name and optional slug alias
+Resolved outputs of template are in outputs field
+    **/
+    async createWithOptions(token, options){
+        return (await this.__call('CreateWithOptions', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.CreateWithOptions",
+            "id" : this.__next_id(),
+            "params" : [token, options]
+        }));
+    }
+
+    /**
+    Duplicate lambda on server: files (streamed), manifest and environment are copied to new lambda without shared
+state, optionally without aliases, schedules or references to secrets. Aliases bound to other lambdas (ex: to the
+source) are not declared by copy: they are in warning of copy
+    **/
+    async duplicate(token, uid, options){
+        return (await this.__call('Duplicate', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Duplicate",
+            "id" : this.__next_id(),
+            "params" : [token, uid, options]
+        }));
+    }
+
+    /**
+    Server information: version, capabilities and effective configuration (same as `trusted-cgi info`)
+    **/
+    async capabilities(token){
+        return (await this.__call('Capabilities', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Capabilities",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Capacity report: usage of lambdas aggregated over window (zero - one hour) by stats records, disk usage, queue
+backlogs and naive projection of headroom
+    **/
+    async capacity(token, window){
+        return (await this.__call('Capacity', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Capacity",
+            "id" : this.__next_id(),
+            "params" : [token, window]
+        }));
+    }
+
+    /**
+    Status of replication if server is read-only mirror of primary server (disabled status otherwise)
+    **/
+    async mirror(token){
+        return (await this.__call('Mirror', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Mirror",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Promote read-only mirror to primary: replication is stopped and mutating API is enabled
+    **/
+    async promote(token){
+        return (await this.__call('Promote', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Promote",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Administrative changes (deploys, manifest edits, aliases, policies, users and settings) in time range
+[since, until) (zero until - till now) grouped by lambda. Page is defined by offset and limit (zero - 100) of changes
+    **/
+    async changes(token, since, until, offset, limit){
+        return (await this.__call('Changes', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Changes",
+            "id" : this.__next_id(),
+            "params" : [token, since, until, offset, limit]
+        }));
+    }
+
+    /**
+    Audit log: changes and failed authentications matched by query (time range with zero until - till now, lambda
+by UID or alias, kind) in order of recording. Page is defined by offset and limit (zero - 100)
+    **/
+    async audit(token, query, offset, limit){
+        return (await this.__call('Audit', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Audit",
+            "id" : this.__next_id(),
+            "params" : [token, query, offset, limit]
+        }));
+    }
+
+    /**
+    Active security profile and options of lambdas different from its defaults (including violations of mandatory
+rules by manifests uploaded before the profile)
+    **/
+    async security(token){
+        return (await this.__call('Security', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Security",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Canonical public invocation paths of lambdas (by UID, slug, aliases and linked queues) ordered by UID, for
+generation of reverse-proxy configuration
+    **/
+    async routes(token){
+        return (await this.__call('Routes', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Routes",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    OpenAPI 3.1 document of public routes of lambdas (by UID, slug and aliases) generated by current manifests:
+methods, content types and input schema of requests, output headers of responses. Lambda is UID of the only
+lambda in document (empty - all lambdas). Public URL is base URL of server seen by client (ignored if server has
+configured one, empty - document without servers)
+    **/
+    async openAPI(token, lambda, publicURL){
+        return (await this.__call('OpenAPI', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.OpenAPI",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, publicURL]
+        }));
+    }
+
+    /**
+    Full-text search of lambdas by names, descriptions, aliases and labels: lambdas matching all words of query
+(word matches the same word or beginning of longer word) ordered by relevance with highlighted matched words.
+Number of hits is limited (zero - 20)
+    **/
+    async search(token, query, limit){
+        return (await this.__call('Search', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Search",
+            "id" : this.__next_id(),
+            "params" : [token, query, limit]
+        }));
+    }
+
+    /**
+    Transfer app from another server: export is pulled from source by grant (see LambdaAPI.GrantExport) and content
+is verified by hash of grant. App keeps UID, manifest (including schedules) and aliases which are free, nothing
+is created on failure. Existent UID is error with code 409
+    **/
+    async transfer(token, request){
+        return (await this.__call('Transfer', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Transfer",
+            "id" : this.__next_id(),
+            "params" : [token, request]
+        }));
+    }
+
+    /**
+    Progress of running or recently finished transfer by ID of request
+    **/
+    async transferProgress(token, id){
+        return (await this.__call('TransferProgress', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.TransferProgress",
+            "id" : this.__next_id(),
+            "params" : [token, id]
+        }));
+    }
+
+    /**
+    Secrets of server store ordered by name: metadata and lambdas which environment references them (values are
+never returned). Disabled store is error
+    **/
+    async secrets(token){
+        return (await this.__call('Secrets', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Secrets",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Set value of secret of server store (created if not exists). Value is write-only
+    **/
+    async setSecret(token, name, value){
+        return (await this.__call('SetSecret', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.SetSecret",
+            "id" : this.__next_id(),
+            "params" : [token, name, value]
+        }));
+    }
+
+    /**
+    Remove secret of server store. Lambdas which still reference it are in result: their variables become empty
+    **/
+    async removeSecret(token, name){
+        return (await this.__call('RemoveSecret', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.RemoveSecret",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    Recent attempts of deliveries of lifecycle events to webhooks of server and lambdas from the newest, including
+dropped events (zero attempt). Number of attempts is limited (zero - all kept attempts)
+    **/
+    async webhookDeliveries(token, limit){
+        return (await this.__call('WebhookDeliveries', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.WebhookDeliveries",
+            "id" : this.__next_id(),
+            "params" : [token, limit]
+        }));
+    }
+
+    /**
+    Backup of server as .tar.gz archive: lambdas (content, manifests, aliases, state), policies, queues, API tokens
+(hashes of values, unless excluded), sealed secrets and settings
+    **/
+    async backup(token, options){
+        return (await this.__call('Backup', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Backup",
+            "id" : this.__next_id(),
+            "params" : [token, options]
+        }));
+    }
+
+    /**
+    Restore server from backup archive (see Backup): items which do not exist are created, existent items are
+conflicts handled by options (skip by default, fail is error with code 409 and conflicts in data). Dry run
+returns plan without changes
+    **/
+    async restore(token, archive, options){
+        return (await this.__call('Restore', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Restore",
+            "id" : this.__next_id(),
+            "params" : [token, archive, options]
+        }));
+    }
+
+    /**
+    Import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent lambda
+(by UID). Archive is extracted to staged copy, manifest of archive (or template.json) is applied and post-clone
+action is run there: failed import does not change lambda. Archive over upload limit is error with code 413,
+unknown format - 415, invalid manifest - 422
+    **/
+    async importURL(token, request){
+        return (await this.__call('ImportURL', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.ImportURL",
+            "id" : this.__next_id(),
+            "params" : [token, request]
+        }));
+    }
+
+    /**
+    Reload files edited on disk out of server: project config, security profile, manifests and changed files of all
+lambdas (empty UID) or manifest and files of one lambda. Invalid files are not applied and reported in items,
+the last good state is kept. For setups without watcher of files
+    **/
+    async reload(token, uid){
+        return (await this.__call('Reload', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Reload",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        }));
+    }
+
 
 
     __next_id() {
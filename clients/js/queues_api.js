@@ -80,6 +80,119 @@ export class QueuesAPI {
         }));
     }
 
+    /**
+    Oldest message of queue without consuming it: headers and chain of lambdas which produced message (body is
+not returned)
+    **/
+    async inspect(token, name){
+        return (await this.__call('Inspect', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Inspect",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    Return quarantined (corrupted) messages of queue to the end of queue, returns number of returned messages
+    **/
+    async retryQuarantined(token, name){
+        return (await this.__call('RetryQuarantined', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.RetryQuarantined",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    Remove quarantined (corrupted) messages of queue, returns number of removed messages
+    **/
+    async purgeQuarantined(token, name){
+        return (await this.__call('PurgeQuarantined', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.PurgeQuarantined",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    Pending asynchronous invocations (including in-flight) in order of acceptance, empty if asynchronous
+invocations are disabled
+    **/
+    async async(token){
+        return (await this.__call('Async', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Async",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Remove pending asynchronous invocations by tickets (empty - all pending), in-flight invocations are not
+interrupted. Returns number of removed invocations
+    **/
+    async purgeAsync(token, tickets){
+        return (await this.__call('PurgeAsync', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.PurgeAsync",
+            "id" : this.__next_id(),
+            "params" : [token, tickets]
+        }));
+    }
+
+    /**
+    Dead letters of lambda (queued requests not processed after all attempts) from the newest, body is not
+returned
+    **/
+    async deadLetters(token, lambda){
+        return (await this.__call('DeadLetters', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, lambda]
+        }));
+    }
+
+    /**
+    Dead letter of lambda with body
+    **/
+    async deadLetter(token, lambda, id){
+        return (await this.__call('DeadLetter', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeadLetter",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, id]
+        }));
+    }
+
+    /**
+    Remove dead letters of lambda by IDs (empty - all), returns number of removed letters
+    **/
+    async removeDeadLetters(token, lambda, ids){
+        return (await this.__call('RemoveDeadLetters', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.RemoveDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, ids]
+        }));
+    }
+
+    /**
+    Put dead letters of lambda by IDs (empty - all) back to their queues and remove them, returns number of
+returned letters
+    **/
+    async redriveDeadLetters(token, lambda, ids){
+        return (await this.__call('RedriveDeadLetters', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.RedriveDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, ids]
+        }));
+    }
+
 
 
     __next_id() {
@@ -44,6 +44,81 @@ export class UserAPI {
         }));
     }
 
+    /**
+    Create long-lived API token limited by scope (lambdas and operations) with time to live (zero - never expires).
+Value of token is returned only once
+    **/
+    async createToken(token, name, scope, ttl){
+        return (await this.__call('CreateToken', {
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.CreateToken",
+            "id" : this.__next_id(),
+            "params" : [token, name, scope, ttl]
+        }));
+    }
+
+    /**
+    Issue replacement of API token with the same name, scope and time to live. Replaced token is valid for overlap
+(zero - one hour) to switch automation without downtime
+    **/
+    async rotateToken(token, id, overlap){
+        return (await this.__call('RotateToken', {
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.RotateToken",
+            "id" : this.__next_id(),
+            "params" : [token, id, overlap]
+        }));
+    }
+
+    /**
+    API tokens with scopes and expiration (without values). Tokens expired more than 7 days ago are removed
+    **/
+    async tokens(token){
+        return (await this.__call('Tokens', {
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.Tokens",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Revoke API token by ID
+    **/
+    async revokeToken(token, id){
+        return (await this.__call('RevokeToken', {
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.RevokeToken",
+            "id" : this.__next_id(),
+            "params" : [token, id]
+        }));
+    }
+
+    /**
+    Accounts and client addresses with failed logins, including locked out ones
+    **/
+    async lockouts(token){
+        return (await this.__call('Lockouts', {
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.Lockouts",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Clear lockout and failed logins of account or client address (empty - all), returns number of cleared subjects.
+Could be called by local admin socket without token (ex: admin account is locked out)
+    **/
+    async unlock(token, subject){
+        return (await this.__call('Unlock', {
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.Unlock",
+            "id" : this.__next_id(),
+            "params" : [token, subject]
+        }));
+    }
+
 
 
     __next_id() {
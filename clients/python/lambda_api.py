@@ -2,9 +2,53 @@ from aiohttp import client
 
 from dataclasses import dataclass
 
-from base64 import decodebytes, encodebytes
+from enum import Enum
 from typing import Any, List, Optional
+from base64 import decodebytes, encodebytes
+
+
+class Duration(Enum):
+    MIN_DURATION = -1 << 63
+    MAX_DURATION = 1<<63 - 1
+    NANOSECOND = 1
+    MIN_DURATION = -1 << 63
+    MAX_DURATION = 1<<63 - 1
+
+    def to_json(self) -> int:
+        return self.value
+
+    @staticmethod
+    def from_json(payload: int) -> 'Duration':
+        return Duration(payload)
+
+
+
+@dataclass
+class ContentProof:
+    uid: 'str'
+    archive: 'Optional[str]'
+    hash: 'str'
+    nonce: 'str'
+    signature: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "archive": self.archive,
+            "hash": self.hash,
+            "nonce": self.nonce,
+            "signature": self.signature,
+        }
 
+    @staticmethod
+    def from_json(payload: dict) -> 'ContentProof':
+        return ContentProof(
+                uid=payload['uid'],
+                archive=payload['archive'],
+                hash=payload['hash'],
+                nonce=payload['nonce'],
+                signature=payload['signature'],
+        )
 
 
 @dataclass
@@ -31,12 +75,36 @@ class Definition:
     uid: 'str'
     aliases: 'Any'
     manifest: 'Manifest'
+    modified: 'Optional[Any]'
+    schedules: 'Optional[List[ScheduleStatus]]'
+    queues: 'Optional[List[QueueStatus]]'
+    alerts: 'Optional[List[AlertStatus]]'
+    slug: 'Optional[str]'
+    slug_outdated: 'Optional[bool]'
+    warning: 'Optional[str]'
+    disabled: 'bool'
+    alias_overrides: 'Optional[Any]'
+    git: 'Optional[GitStatus]'
+    disk: 'Optional[DiskUsage]'
+    outputs: 'Optional[Any]'
 
     def to_json(self) -> dict:
         return {
             "uid": self.uid,
             "aliases": self.aliases,
             "manifest": self.manifest.to_json(),
+            "modified": self.modified,
+            "schedules": [x.to_json() for x in self.schedules],
+            "queues": [x.to_json() for x in self.queues],
+            "alerts": [x.to_json() for x in self.alerts],
+            "slug": self.slug,
+            "slug_outdated": self.slug_outdated,
+            "warning": self.warning,
+            "disabled": self.disabled,
+            "alias_overrides": self.alias_overrides,
+            "git": self.git.to_json(),
+            "disk": self.disk.to_json(),
+            "outputs": self.outputs,
         }
 
     @staticmethod
@@ -45,6 +113,18 @@ class Definition:
                 uid=payload['uid'],
                 aliases=payload['aliases'],
                 manifest=Manifest.from_json(payload['manifest']),
+                modified=payload['modified'],
+                schedules=[ScheduleStatus.from_json(x) for x in (payload['schedules'] or [])],
+                queues=[QueueStatus.from_json(x) for x in (payload['queues'] or [])],
+                alerts=[AlertStatus.from_json(x) for x in (payload['alerts'] or [])],
+                slug=payload['slug'],
+                slug_outdated=payload['slug_outdated'],
+                warning=payload['warning'],
+                disabled=payload['disabled'],
+                alias_overrides=payload['alias_overrides'],
+                git=GitStatus.from_json(payload['git']),
+                disk=DiskUsage.from_json(payload['disk']),
+                outputs=payload['outputs'],
         )
 
 
@@ -52,35 +132,183 @@ class Definition:
 class Manifest:
     name: 'Optional[str]'
     description: 'Optional[str]'
+    labels: 'Optional[List[str]]'
     run: 'List[str]'
     output_headers: 'Optional[Any]'
+    parse_headers: 'Optional[bool]'
     input_headers: 'Optional[Any]'
     query: 'Optional[Any]'
+    query_to_body: 'Optional[bool]'
     environment: 'Optional[Any]'
     method: 'Optional[str]'
     method_env: 'Optional[str]'
     path_env: 'Optional[str]'
+    public_url_env: 'Optional[str]'
+    alias_env: 'Optional[str]'
+    deadline_env: 'Optional[str]'
     time_limit: 'Optional[Any]'
     maximum_payload: 'Optional[int]'
     cron: 'Optional[List[Schedule]]'
+    actions: 'Optional[Any]'
+    aliases: 'Optional[List[str]]'
     static: 'Optional[str]'
+    umask: 'Optional[str]'
+    lang: 'Optional[str]'
+    lc_all: 'Optional[str]'
+    tz: 'Optional[str]'
+    sampling: 'Optional[Sampling]'
+    on_start: 'Optional[Startup]'
+    coalesce: 'Optional[Coalescing]'
+    cache: 'Optional[Caching]'
+    rate_limit: 'Optional[RateLimit]'
+    accepted_content_types: 'Optional[List[str]]'
+    allow_no_content_type: 'Optional[bool]'
+    rewrite_ur_lss: 'Optional[Rewrite]'
+    secrets: 'Optional[Secrets]'
+    alerts: 'Optional[List[Alert]]'
+    mutate: 'Optional[Mutation]'
+    on_success: 'Optional[Chaining]'
+    then: 'Optional[List[ChainStep]]'
+    methods: 'Optional[List[str]]'
+    build_limits: 'Optional[BuildLimits]'
+    limits: 'Optional[ResourceLimits]'
+    disk_quota_mb: 'Optional[int]'
+    build_gate: 'Optional[BuildGate]'
+    status_map: 'Optional[Any]'
+    static_dirs: 'Optional[Any]'
+    remove_headers: 'Optional[List[str]]'
+    maximum_response: 'Optional[int]'
+    truncate_policy: 'Optional[str]'
+    soft_limits: 'Optional[SoftLimits]'
+    work_dir: 'Optional[str]'
+    expand_run: 'Optional[bool]'
+    max_concurrency: 'Optional[int]'
+    overflow_policy: 'Optional[str]'
+    cors: 'Optional[CORS]'
+    input_schema: 'Optional[Any]'
+    network: 'Optional[bool]'
+    read_only: 'Optional[bool]'
+    run_as: 'Optional[RunAs]'
+    streaming: 'Optional[str]'
+    stream_time_limit: 'Optional[Any]'
+    stream_idle_timeout: 'Optional[Any]'
+    stream_reauthorize: 'Optional[Any]'
+    container: 'Optional[Container]'
+    mode: 'Optional[str]'
+    workers: 'Optional[int]'
+    grace_period: 'Optional[Any]'
+    payload_as_file: 'Optional[bool]'
+    maximum_file_payload: 'Optional[int]'
+    decode_multipart: 'Optional[bool]'
+    maximum_form_file: 'Optional[int]'
+    decode_form: 'Optional[bool]'
+    raw_form_env: 'Optional[str]'
+    disable_compression: 'Optional[bool]'
+    queue_serial: 'Optional[bool]'
+    retry: 'Optional[Retry]'
+    check: 'Optional[List[List[str]]]'
+    warm_up: 'Optional[bool]'
+    warm_up_marker: 'Optional[str]'
+    capture: 'Optional[Capture]'
+    logs: 'Optional[LogFiles]'
+    allowed_networks: 'Optional[List[str]]'
+    webhook_signature: 'Optional[WebhookSignature]'
+    basic_auth: 'Optional[BasicAuth]'
+    jwt: 'Optional[JWTPolicy]'
+    alias_policies: 'Optional[Any]'
+    event_webhooks: 'Optional[List[LifecycleWebhook]]'
+    git: 'Optional[GitSource]'
 
     def to_json(self) -> dict:
         return {
             "name": self.name,
             "description": self.description,
+            "labels": self.labels,
             "run": self.run,
             "output_headers": self.output_headers,
+            "parse_headers": self.parse_headers,
             "input_headers": self.input_headers,
             "query": self.query,
+            "query_to_body": self.query_to_body,
             "environment": self.environment,
             "method": self.method,
             "method_env": self.method_env,
             "path_env": self.path_env,
+            "public_url_env": self.public_url_env,
+            "alias_env": self.alias_env,
+            "deadline_env": self.deadline_env,
             "time_limit": self.time_limit,
             "maximum_payload": self.maximum_payload,
             "cron": [x.to_json() for x in self.cron],
+            "actions": self.actions,
+            "aliases": self.aliases,
             "static": self.static,
+            "umask": self.umask,
+            "lang": self.lang,
+            "lc_all": self.lc_all,
+            "tz": self.tz,
+            "sampling": self.sampling.to_json(),
+            "on_start": self.on_start.to_json(),
+            "coalesce": self.coalesce.to_json(),
+            "cache": self.cache.to_json(),
+            "rate_limit": self.rate_limit.to_json(),
+            "accepted_content_types": self.accepted_content_types,
+            "allow_no_content_type": self.allow_no_content_type,
+            "rewrite_urls": self.rewrite_ur_lss.to_json(),
+            "secrets": self.secrets.to_json(),
+            "alerts": [x.to_json() for x in self.alerts],
+            "mutate": self.mutate.to_json(),
+            "on_success": self.on_success.to_json(),
+            "then": [x.to_json() for x in self.then],
+            "methods": self.methods,
+            "build_limits": self.build_limits.to_json(),
+            "limits": self.limits.to_json(),
+            "disk_quota_mb": self.disk_quota_mb,
+            "build_gate": self.build_gate.to_json(),
+            "status_map": self.status_map,
+            "static_dirs": self.static_dirs,
+            "remove_headers": self.remove_headers,
+            "maximum_response": self.maximum_response,
+            "truncate_policy": self.truncate_policy,
+            "soft_limits": self.soft_limits.to_json(),
+            "work_dir": self.work_dir,
+            "expand_run": self.expand_run,
+            "max_concurrency": self.max_concurrency,
+            "overflow_policy": self.overflow_policy,
+            "cors": self.cors.to_json(),
+            "input_schema": self.input_schema,
+            "network": self.network,
+            "read_only": self.read_only,
+            "run_as": self.run_as.to_json(),
+            "streaming": self.streaming,
+            "stream_time_limit": self.stream_time_limit,
+            "stream_idle_timeout": self.stream_idle_timeout,
+            "stream_reauthorize": self.stream_reauthorize,
+            "container": self.container.to_json(),
+            "mode": self.mode,
+            "workers": self.workers,
+            "grace_period": self.grace_period,
+            "payload_as_file": self.payload_as_file,
+            "maximum_file_payload": self.maximum_file_payload,
+            "decode_multipart": self.decode_multipart,
+            "maximum_form_file": self.maximum_form_file,
+            "decode_form": self.decode_form,
+            "raw_form_env": self.raw_form_env,
+            "disable_compression": self.disable_compression,
+            "queue_serial": self.queue_serial,
+            "retry": self.retry.to_json(),
+            "check": self.check,
+            "warm_up": self.warm_up,
+            "warm_up_marker": self.warm_up_marker,
+            "capture": self.capture.to_json(),
+            "logs": self.logs.to_json(),
+            "allowed_networks": self.allowed_networks,
+            "webhook_signature": self.webhook_signature.to_json(),
+            "basic_auth": self.basic_auth.to_json(),
+            "jwt": self.jwt.to_json(),
+            "alias_policies": self.alias_policies,
+            "event_webhooks": [x.to_json() for x in self.event_webhooks],
+            "git": self.git.to_json(),
         }
 
     @staticmethod
@@ -88,388 +316,2834 @@ class Manifest:
         return Manifest(
                 name=payload['name'],
                 description=payload['description'],
+                labels=payload['labels'] or [],
                 run=payload['run'] or [],
                 output_headers=payload['output_headers'],
+                parse_headers=payload['parse_headers'],
                 input_headers=payload['input_headers'],
                 query=payload['query'],
+                query_to_body=payload['query_to_body'],
                 environment=payload['environment'],
                 method=payload['method'],
                 method_env=payload['method_env'],
                 path_env=payload['path_env'],
+                public_url_env=payload['public_url_env'],
+                alias_env=payload['alias_env'],
+                deadline_env=payload['deadline_env'],
                 time_limit=payload['time_limit'],
                 maximum_payload=payload['maximum_payload'],
                 cron=[Schedule.from_json(x) for x in (payload['cron'] or [])],
+                actions=payload['actions'],
+                aliases=payload['aliases'] or [],
                 static=payload['static'],
+                umask=payload['umask'],
+                lang=payload['lang'],
+                lc_all=payload['lc_all'],
+                tz=payload['tz'],
+                sampling=Sampling.from_json(payload['sampling']),
+                on_start=Startup.from_json(payload['on_start']),
+                coalesce=Coalescing.from_json(payload['coalesce']),
+                cache=Caching.from_json(payload['cache']),
+                rate_limit=RateLimit.from_json(payload['rate_limit']),
+                accepted_content_types=payload['accepted_content_types'] or [],
+                allow_no_content_type=payload['allow_no_content_type'],
+                rewrite_ur_lss=Rewrite.from_json(payload['rewrite_urls']),
+                secrets=Secrets.from_json(payload['secrets']),
+                alerts=[Alert.from_json(x) for x in (payload['alerts'] or [])],
+                mutate=Mutation.from_json(payload['mutate']),
+                on_success=Chaining.from_json(payload['on_success']),
+                then=[ChainStep.from_json(x) for x in (payload['then'] or [])],
+                methods=payload['methods'] or [],
+                build_limits=BuildLimits.from_json(payload['build_limits']),
+                limits=ResourceLimits.from_json(payload['limits']),
+                disk_quota_mb=payload['disk_quota_mb'],
+                build_gate=BuildGate.from_json(payload['build_gate']),
+                status_map=payload['status_map'],
+                static_dirs=payload['static_dirs'],
+                remove_headers=payload['remove_headers'] or [],
+                maximum_response=payload['maximum_response'],
+                truncate_policy=payload['truncate_policy'],
+                soft_limits=SoftLimits.from_json(payload['soft_limits']),
+                work_dir=payload['work_dir'],
+                expand_run=payload['expand_run'],
+                max_concurrency=payload['max_concurrency'],
+                overflow_policy=payload['overflow_policy'],
+                cors=CORS.from_json(payload['cors']),
+                input_schema=payload['input_schema'],
+                network=payload['network'],
+                read_only=payload['read_only'],
+                run_as=RunAs.from_json(payload['run_as']),
+                streaming=payload['streaming'],
+                stream_time_limit=payload['stream_time_limit'],
+                stream_idle_timeout=payload['stream_idle_timeout'],
+                stream_reauthorize=payload['stream_reauthorize'],
+                container=Container.from_json(payload['container']),
+                mode=payload['mode'],
+                workers=payload['workers'],
+                grace_period=payload['grace_period'],
+                payload_as_file=payload['payload_as_file'],
+                maximum_file_payload=payload['maximum_file_payload'],
+                decode_multipart=payload['decode_multipart'],
+                maximum_form_file=payload['maximum_form_file'],
+                decode_form=payload['decode_form'],
+                raw_form_env=payload['raw_form_env'],
+                disable_compression=payload['disable_compression'],
+                queue_serial=payload['queue_serial'],
+                retry=Retry.from_json(payload['retry']),
+                check=payload['check'] or [],
+                warm_up=payload['warm_up'],
+                warm_up_marker=payload['warm_up_marker'],
+                capture=Capture.from_json(payload['capture']),
+                logs=LogFiles.from_json(payload['logs']),
+                allowed_networks=payload['allowed_networks'] or [],
+                webhook_signature=WebhookSignature.from_json(payload['webhook_signature']),
+                basic_auth=BasicAuth.from_json(payload['basic_auth']),
+                jwt=JWTPolicy.from_json(payload['jwt']),
+                alias_policies=payload['alias_policies'],
+                event_webhooks=[LifecycleWebhook.from_json(x) for x in (payload['event_webhooks'] or [])],
+                git=GitSource.from_json(payload['git']),
         )
 
 
 @dataclass
 class Schedule:
+    name: 'Optional[str]'
     cron: 'str'
-    action: 'str'
+    action: 'Optional[str]'
+    payload: 'Optional[str]'
+    payload_file: 'Optional[str]'
     time_limit: 'Any'
+    disabled: 'Optional[bool]'
+    missed: 'Optional[str]'
+    tz: 'Optional[str]'
 
     def to_json(self) -> dict:
         return {
+            "name": self.name,
             "cron": self.cron,
             "action": self.action,
+            "payload": self.payload,
+            "payload_file": self.payload_file,
             "time_limit": self.time_limit,
+            "disabled": self.disabled,
+            "missed": self.missed,
+            "tz": self.tz,
         }
 
     @staticmethod
     def from_json(payload: dict) -> 'Schedule':
         return Schedule(
+                name=payload['name'],
                 cron=payload['cron'],
                 action=payload['action'],
+                payload=payload['payload'],
+                payload_file=payload['payload_file'],
                 time_limit=payload['time_limit'],
+                disabled=payload['disabled'],
+                missed=payload['missed'],
+                tz=payload['tz'],
         )
 
 
 @dataclass
-class Record:
-    uid: 'str'
-    err: 'Optional[str]'
-    request: 'Request'
-    begin: 'Any'
-    end: 'Any'
+class Sampling:
+    rate: 'Optional[int]'
+    slow: 'Optional[Any]'
 
     def to_json(self) -> dict:
         return {
-            "uid": self.uid,
-            "error": self.err,
-            "request": self.request.to_json(),
-            "begin": self.begin,
-            "end": self.end,
+            "rate": self.rate,
+            "slow": self.slow,
         }
 
     @staticmethod
-    def from_json(payload: dict) -> 'Record':
-        return Record(
-                uid=payload['uid'],
-                err=payload['error'],
-                request=Request.from_json(payload['request']),
-                begin=payload['begin'],
-                end=payload['end'],
+    def from_json(payload: dict) -> 'Sampling':
+        return Sampling(
+                rate=payload['rate'],
+                slow=payload['slow'],
         )
 
 
 @dataclass
-class Request:
-    method: 'str'
-    url: 'str'
-    path: 'str'
-    remote_address: 'str'
-    form: 'Any'
-    headers: 'Any'
+class Startup:
+    action: 'str'
+    time_limit: 'Optional[Any]'
+    blocking: 'Optional[bool]'
+    on_failure: 'Optional[str]'
 
     def to_json(self) -> dict:
         return {
-            "method": self.method,
-            "url": self.url,
-            "path": self.path,
-            "remote_address": self.remote_address,
-            "form": self.form,
+            "action": self.action,
+            "time_limit": self.time_limit,
+            "blocking": self.blocking,
+            "on_failure": self.on_failure,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Startup':
+        return Startup(
+                action=payload['action'],
+                time_limit=payload['time_limit'],
+                blocking=payload['blocking'],
+                on_failure=payload['on_failure'],
+        )
+
+
+@dataclass
+class Coalescing:
+    max_waiters: 'Optional[int]'
+    headers: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "max_waiters": self.max_waiters,
             "headers": self.headers,
         }
 
     @staticmethod
-    def from_json(payload: dict) -> 'Request':
-        return Request(
-                method=payload['method'],
-                url=payload['url'],
-                path=payload['path'],
-                remote_address=payload['remote_address'],
-                form=payload['form'],
+    def from_json(payload: dict) -> 'Coalescing':
+        return Coalescing(
+                max_waiters=payload['max_waiters'],
+                headers=payload['headers'] or [],
+        )
+
+
+@dataclass
+class Caching:
+    ttl: 'Any'
+    max_entries: 'Optional[int]'
+    vary: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "ttl": self.ttl,
+            "max_entries": self.max_entries,
+            "vary": self.vary,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Caching':
+        return Caching(
+                ttl=payload['ttl'],
+                max_entries=payload['max_entries'],
+                vary=payload['vary'] or [],
+        )
+
+
+@dataclass
+class RateLimit:
+    rps: 'float'
+    burst: 'Optional[int]'
+    per_client: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "rps": self.rps,
+            "burst": self.burst,
+            "per_client": self.per_client,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RateLimit':
+        return RateLimit(
+                rps=payload['rps'],
+                burst=payload['burst'],
+                per_client=payload['per_client'],
+        )
+
+
+@dataclass
+class Rewrite:
+    prefix: 'str'
+    max_size: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "prefix": self.prefix,
+            "max_size": self.max_size,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Rewrite':
+        return Rewrite(
+                prefix=payload['prefix'],
+                max_size=payload['max_size'],
+        )
+
+
+@dataclass
+class Secrets:
+    delivery: 'Optional[str]'
+    keys: 'Optional[List[str]]'
+    env: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "delivery": self.delivery,
+            "keys": self.keys,
+            "env": self.env,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Secrets':
+        return Secrets(
+                delivery=payload['delivery'],
+                keys=payload['keys'] or [],
+                env=payload['env'],
+        )
+
+
+@dataclass
+class Alert:
+    name: 'str'
+    error_rate: 'Optional[float]'
+    consecutive_failures: 'Optional[int]'
+    latency_p95: 'Optional[Any]'
+    window: 'Optional[Any]'
+    min_calls: 'Optional[int]'
+    action: 'Optional[str]'
+    cool_off: 'Optional[Any]'
+    notify: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "error_rate": self.error_rate,
+            "consecutive_failures": self.consecutive_failures,
+            "latency_p95": self.latency_p95,
+            "window": self.window,
+            "min_calls": self.min_calls,
+            "action": self.action,
+            "cool_off": self.cool_off,
+            "notify": self.notify,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Alert':
+        return Alert(
+                name=payload['name'],
+                error_rate=payload['error_rate'],
+                consecutive_failures=payload['consecutive_failures'],
+                latency_p95=payload['latency_p95'],
+                window=payload['window'],
+                min_calls=payload['min_calls'],
+                action=payload['action'],
+                cool_off=payload['cool_off'],
+                notify=payload['notify'],
+        )
+
+
+@dataclass
+class Mutation:
+    headers: 'Optional[Any]'
+    body_defaults: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "headers": self.headers,
+            "body_defaults": self.body_defaults,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Mutation':
+        return Mutation(
                 headers=payload['headers'],
+                body_defaults=payload['body_defaults'],
         )
 
 
-class LambdaAPIError(RuntimeError):
-    def __init__(self, method: str, code: int, message: str, data: Any):
-        super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
-        self.code = code
-        self.message = message
-        self.data = data
+@dataclass
+class Chaining:
+    queue: 'str'
+    transform: 'Optional[str]'
+    max_size: 'Optional[int]'
+    max_hops: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "queue": self.queue,
+            "transform": self.transform,
+            "max_size": self.max_size,
+            "max_hops": self.max_hops,
+        }
 
     @staticmethod
-    def from_json(method: str, payload: dict) -> 'LambdaAPIError':
-        return LambdaAPIError(
-            method=method,
-            code=payload['code'],
-            message=payload['message'],
-            data=payload.get('data')
+    def from_json(payload: dict) -> 'Chaining':
+        return Chaining(
+                queue=payload['queue'],
+                transform=payload['transform'],
+                max_size=payload['max_size'],
+                max_hops=payload['max_hops'],
+        )
+
+
+@dataclass
+class ChainStep:
+    _lambda: 'str'
+    condition: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "lambda": self._lambda,
+            "condition": self.condition,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ChainStep':
+        return ChainStep(
+                _lambda=payload['lambda'],
+                condition=payload['condition'],
+        )
+
+
+@dataclass
+class BuildLimits:
+    nice: 'Optional[int]'
+    cpu: 'Optional[float]'
+    memory: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "nice": self.nice,
+            "cpu": self.cpu,
+            "memory": self.memory,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BuildLimits':
+        return BuildLimits(
+                nice=payload['nice'],
+                cpu=payload['cpu'],
+                memory=payload['memory'],
+        )
+
+
+@dataclass
+class ResourceLimits:
+    memory_mb: 'Optional[int]'
+    cpu_percent: 'Optional[int]'
+    open_files: 'Optional[int]'
+    processes: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "memory_mb": self.memory_mb,
+            "cpu_percent": self.cpu_percent,
+            "open_files": self.open_files,
+            "processes": self.processes,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ResourceLimits':
+        return ResourceLimits(
+                memory_mb=payload['memory_mb'],
+                cpu_percent=payload['cpu_percent'],
+                open_files=payload['open_files'],
+                processes=payload['processes'],
+        )
+
+
+@dataclass
+class BuildGate:
+    actions: 'Optional[List[str]]'
+    timeout: 'Optional[Any]'
+    disabled: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "actions": self.actions,
+            "timeout": self.timeout,
+            "disabled": self.disabled,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BuildGate':
+        return BuildGate(
+                actions=payload['actions'] or [],
+                timeout=payload['timeout'],
+                disabled=payload['disabled'],
+        )
+
+
+@dataclass
+class SoftLimits:
+    payload: 'Optional[int]'
+    response: 'Optional[int]'
+    notify: 'Optional[str]'
+    interval: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "payload": self.payload,
+            "response": self.response,
+            "notify": self.notify,
+            "interval": self.interval,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SoftLimits':
+        return SoftLimits(
+                payload=payload['payload'],
+                response=payload['response'],
+                notify=payload['notify'],
+                interval=payload['interval'],
+        )
+
+
+@dataclass
+class CORS:
+    origins: 'Optional[List[str]]'
+    methods: 'Optional[List[str]]'
+    headers: 'Optional[List[str]]'
+    credentials: 'Optional[bool]'
+    max_age: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "origins": self.origins,
+            "methods": self.methods,
+            "headers": self.headers,
+            "credentials": self.credentials,
+            "max_age": self.max_age,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'CORS':
+        return CORS(
+                origins=payload['origins'] or [],
+                methods=payload['methods'] or [],
+                headers=payload['headers'] or [],
+                credentials=payload['credentials'],
+                max_age=payload['max_age'],
+        )
+
+
+@dataclass
+class RunAs:
+    user: 'str'
+    group: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "user": self.user,
+            "group": self.group,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RunAs':
+        return RunAs(
+                user=payload['user'],
+                group=payload['group'],
+        )
+
+
+@dataclass
+class Container:
+    image: 'str'
+    entrypoint: 'Optional[List[str]]'
+    mounts: 'Optional[List[Mount]]'
+    network: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "image": self.image,
+            "entrypoint": self.entrypoint,
+            "mounts": [x.to_json() for x in self.mounts],
+            "network": self.network,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Container':
+        return Container(
+                image=payload['image'],
+                entrypoint=payload['entrypoint'] or [],
+                mounts=[Mount.from_json(x) for x in (payload['mounts'] or [])],
+                network=payload['network'],
+        )
+
+
+@dataclass
+class Mount:
+    source: 'str'
+    target: 'str'
+    writable: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "source": self.source,
+            "target": self.target,
+            "writable": self.writable,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Mount':
+        return Mount(
+                source=payload['source'],
+                target=payload['target'],
+                writable=payload['writable'],
         )
 
 
-class LambdaAPIClient:
-    """
-    API for lambdas
-    """
+@dataclass
+class Retry:
+    attempts: 'int'
+    backoff: 'Optional[Any]'
+    max_backoff: 'Optional[Any]'
+    jitter: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "attempts": self.attempts,
+            "backoff": self.backoff,
+            "max_backoff": self.max_backoff,
+            "jitter": self.jitter,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Retry':
+        return Retry(
+                attempts=payload['attempts'],
+                backoff=payload['backoff'],
+                max_backoff=payload['max_backoff'],
+                jitter=payload['jitter'],
+        )
+
+
+@dataclass
+class Capture:
+    requests: 'int'
+    max_bytes: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "requests": self.requests,
+            "max_bytes": self.max_bytes,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Capture':
+        return Capture(
+                requests=payload['requests'],
+                max_bytes=payload['max_bytes'],
+        )
+
+
+@dataclass
+class LogFiles:
+    enabled: 'bool'
+    max_size_mb: 'Optional[int]'
+    max_files: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "enabled": self.enabled,
+            "max_size_mb": self.max_size_mb,
+            "max_files": self.max_files,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LogFiles':
+        return LogFiles(
+                enabled=payload['enabled'],
+                max_size_mb=payload['max_size_mb'],
+                max_files=payload['max_files'],
+        )
+
+
+@dataclass
+class WebhookSignature:
+    preset: 'Optional[str]'
+    header: 'Optional[str]'
+    algorithm: 'Optional[str]'
+    secret: 'str'
+    encoding: 'Optional[str]'
+    prefix: 'Optional[str]'
+    tolerance: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "preset": self.preset,
+            "header": self.header,
+            "algorithm": self.algorithm,
+            "secret": self.secret,
+            "encoding": self.encoding,
+            "prefix": self.prefix,
+            "tolerance": self.tolerance,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'WebhookSignature':
+        return WebhookSignature(
+                preset=payload['preset'],
+                header=payload['header'],
+                algorithm=payload['algorithm'],
+                secret=payload['secret'],
+                encoding=payload['encoding'],
+                prefix=payload['prefix'],
+                tolerance=payload['tolerance'],
+        )
+
+
+@dataclass
+class BasicAuth:
+    users: 'Any'
+    realm: 'Optional[str]'
+    links: 'Optional[List[str]]'
+    env: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "users": self.users,
+            "realm": self.realm,
+            "links": self.links,
+            "env": self.env,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BasicAuth':
+        return BasicAuth(
+                users=payload['users'],
+                realm=payload['realm'],
+                links=payload['links'] or [],
+                env=payload['env'],
+        )
+
+
+@dataclass
+class JWTPolicy:
+    issuers: 'Optional[List[str]]'
+    audiences: 'Optional[List[str]]'
+    secret: 'Optional[str]'
+    public_key: 'Optional[str]'
+    jwks: 'Optional[str]'
+    refresh: 'Optional[Any]'
+    leeway: 'Optional[Any]'
+    links: 'Optional[List[str]]'
+    subject_env: 'Optional[str]'
+    env: 'Optional[Any]'
+    headers: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "issuers": self.issuers,
+            "audiences": self.audiences,
+            "secret": self.secret,
+            "public_key": self.public_key,
+            "jwks": self.jwks,
+            "refresh": self.refresh,
+            "leeway": self.leeway,
+            "links": self.links,
+            "subject_env": self.subject_env,
+            "env": self.env,
+            "headers": self.headers,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'JWTPolicy':
+        return JWTPolicy(
+                issuers=payload['issuers'] or [],
+                audiences=payload['audiences'] or [],
+                secret=payload['secret'],
+                public_key=payload['public_key'],
+                jwks=payload['jwks'],
+                refresh=payload['refresh'],
+                leeway=payload['leeway'],
+                links=payload['links'] or [],
+                subject_env=payload['subject_env'],
+                env=payload['env'],
+                headers=payload['headers'],
+        )
+
+
+@dataclass
+class LifecycleWebhook:
+    url: 'str'
+    events: 'Optional[List[str]]'
+    secret: 'Optional[str]'
+    payload: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "url": self.url,
+            "events": self.events,
+            "secret": self.secret,
+            "payload": self.payload,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LifecycleWebhook':
+        return LifecycleWebhook(
+                url=payload['url'],
+                events=payload['events'] or [],
+                secret=payload['secret'],
+                payload=payload['payload'],
+        )
+
+
+@dataclass
+class GitSource:
+    repo: 'str'
+    branch: 'Optional[str]'
+    dir: 'Optional[str]'
+    interval: 'Optional[Any]'
+    action: 'Optional[str]'
+    key: 'Optional[str]'
+    ping: 'Optional[WebhookSignature]'
+
+    def to_json(self) -> dict:
+        return {
+            "repo": self.repo,
+            "branch": self.branch,
+            "dir": self.dir,
+            "interval": self.interval,
+            "action": self.action,
+            "key": self.key,
+            "ping": self.ping.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'GitSource':
+        return GitSource(
+                repo=payload['repo'],
+                branch=payload['branch'],
+                dir=payload['dir'],
+                interval=payload['interval'],
+                action=payload['action'],
+                key=payload['key'],
+                ping=WebhookSignature.from_json(payload['ping']),
+        )
+
+
+@dataclass
+class ScheduleStatus:
+    name: 'Optional[str]'
+    cron: 'str'
+    tz: 'Optional[str]'
+    action: 'str'
+    enabled: 'bool'
+    next: 'Optional[Any]'
+    last_run: 'Optional[Any]'
+    last_result: 'Optional[str]'
+    last_error: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "cron": self.cron,
+            "tz": self.tz,
+            "action": self.action,
+            "enabled": self.enabled,
+            "next": self.next,
+            "last_run": self.last_run,
+            "last_result": self.last_result,
+            "last_error": self.last_error,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ScheduleStatus':
+        return ScheduleStatus(
+                name=payload['name'],
+                cron=payload['cron'],
+                tz=payload['tz'],
+                action=payload['action'],
+                enabled=payload['enabled'],
+                next=payload['next'],
+                last_run=payload['last_run'],
+                last_result=payload['last_result'],
+                last_error=payload['last_error'],
+        )
+
+
+@dataclass
+class QueueStatus:
+    name: 'str'
+    depth: 'int'
+    in_flight: 'int'
+    paused: 'bool'
+    oldest: 'Optional[Any]'
+    oldest_age_seconds: 'float'
+    quarantined: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "depth": self.depth,
+            "in_flight": self.in_flight,
+            "paused": self.paused,
+            "oldest": self.oldest,
+            "oldest_age_seconds": self.oldest_age_seconds,
+            "quarantined": self.quarantined,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'QueueStatus':
+        return QueueStatus(
+                name=payload['name'],
+                depth=payload['depth'],
+                in_flight=payload['in_flight'],
+                paused=payload['paused'],
+                oldest=payload['oldest'],
+                oldest_age_seconds=payload['oldest_age_seconds'],
+                quarantined=payload['quarantined'],
+        )
+
+
+@dataclass
+class AlertStatus:
+    name: 'str'
+    action: 'str'
+    state: 'str'
+    since: 'Optional[Any]'
+    reason: 'Optional[str]'
+    until: 'Optional[Any]'
+    events: 'List[AlertEvent]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "action": self.action,
+            "state": self.state,
+            "since": self.since,
+            "reason": self.reason,
+            "until": self.until,
+            "events": [x.to_json() for x in self.events],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'AlertStatus':
+        return AlertStatus(
+                name=payload['name'],
+                action=payload['action'],
+                state=payload['state'],
+                since=payload['since'],
+                reason=payload['reason'],
+                until=payload['until'],
+                events=[AlertEvent.from_json(x) for x in (payload['events'] or [])],
+        )
+
+
+@dataclass
+class AlertEvent:
+    time: 'Any'
+    _from: 'str'
+    to: 'str'
+    reason: 'Optional[str]'
+    by: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "time": self.time,
+            "from": self._from,
+            "to": self.to,
+            "reason": self.reason,
+            "by": self.by,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'AlertEvent':
+        return AlertEvent(
+                time=payload['time'],
+                _from=payload['from'],
+                to=payload['to'],
+                reason=payload['reason'],
+                by=payload['by'],
+        )
+
+
+@dataclass
+class GitStatus:
+    repo: 'str'
+    branch: 'Optional[str]'
+    commit: 'Optional[str]'
+    deployed: 'Optional[Any]'
+    checked: 'Optional[Any]'
+    failed: 'Optional[str]'
+    error: 'Optional[str]'
+    output: 'Optional[str]'
+    running: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "repo": self.repo,
+            "branch": self.branch,
+            "commit": self.commit,
+            "deployed": self.deployed,
+            "checked": self.checked,
+            "failed": self.failed,
+            "error": self.error,
+            "output": self.output,
+            "running": self.running,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'GitStatus':
+        return GitStatus(
+                repo=payload['repo'],
+                branch=payload['branch'],
+                commit=payload['commit'],
+                deployed=payload['deployed'],
+                checked=payload['checked'],
+                failed=payload['failed'],
+                error=payload['error'],
+                output=payload['output'],
+                running=payload['running'],
+        )
+
+
+@dataclass
+class DiskUsage:
+    size: 'int'
+    quota: 'Optional[int]'
+    measured: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "size": self.size,
+            "quota": self.quota,
+            "measured": self.measured,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'DiskUsage':
+        return DiskUsage(
+                size=payload['size'],
+                quota=payload['quota'],
+                measured=payload['measured'],
+        )
+
+
+@dataclass
+class BulkReport:
+    dry_run: 'Optional[bool]'
+    results: 'List[BulkResult]'
+    failed: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "dry_run": self.dry_run,
+            "results": [x.to_json() for x in self.results],
+            "failed": self.failed,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BulkReport':
+        return BulkReport(
+                dry_run=payload['dry_run'],
+                results=[BulkResult.from_json(x) for x in (payload['results'] or [])],
+                failed=payload['failed'],
+        )
+
+
+@dataclass
+class BulkResult:
+    uid: 'str'
+    name: 'Optional[str]'
+    changes: 'Optional[List[FieldChange]]'
+    applied: 'Optional[bool]'
+    error: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "name": self.name,
+            "changes": [x.to_json() for x in self.changes],
+            "applied": self.applied,
+            "error": self.error,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BulkResult':
+        return BulkResult(
+                uid=payload['uid'],
+                name=payload['name'],
+                changes=[FieldChange.from_json(x) for x in (payload['changes'] or [])],
+                applied=payload['applied'],
+                error=payload['error'],
+        )
+
+
+@dataclass
+class FieldChange:
+    field: 'str'
+    _from: 'Optional[str]'
+    to: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "field": self.field,
+            "from": self._from,
+            "to": self.to,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'FieldChange':
+        return FieldChange(
+                field=payload['field'],
+                _from=payload['from'],
+                to=payload['to'],
+        )
+
+
+@dataclass
+class BulkUpdate:
+    patch: 'Optional[Any]'
+    set: 'Optional[List[str]]'
+    ui_dss: 'Optional[List[str]]'
+    label: 'Optional[str]'
+    all: 'Optional[bool]'
+    dry_run: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "patch": self.patch,
+            "set": self.set,
+            "uids": self.ui_dss,
+            "label": self.label,
+            "all": self.all,
+            "dry_run": self.dry_run,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BulkUpdate':
+        return BulkUpdate(
+                patch=payload['patch'],
+                set=payload['set'] or [],
+                ui_dss=payload['uids'] or [],
+                label=payload['label'],
+                all=payload['all'],
+                dry_run=payload['dry_run'],
+        )
+
+
+@dataclass
+class Environment:
+    environment: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "environment": self.environment,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Environment':
+        return Environment(
+                environment=payload['environment'],
+        )
+
+
+@dataclass
+class EnvValue:
+    name: 'str'
+    value: 'str'
+    source: 'str'
+    overridden: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "value": self.value,
+            "source": self.source,
+            "overridden": self.overridden,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'EnvValue':
+        return EnvValue(
+                name=payload['name'],
+                value=payload['value'],
+                source=payload['source'],
+                overridden=payload['overridden'] or [],
+        )
+
+
+@dataclass
+class Passwords:
+    users: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "users": self.users,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Passwords':
+        return Passwords(
+                users=payload['users'],
+        )
+
+
+@dataclass
+class Record:
+    uid: 'str'
+    err: 'Optional[str]'
+    request: 'Request'
+    begin: 'Any'
+    end: 'Any'
+    rate: 'Optional[int]'
+    coalesced: 'Optional[bool]'
+    cached: 'Optional[bool]'
+    rejected: 'Optional[bool]'
+    throttled: 'Optional[bool]'
+    status: 'Optional[int]'
+    payload: 'Optional[int]'
+    size: 'Optional[int]'
+    cpu: 'Optional[Duration]'
+    max_rss: 'Optional[int]'
+    warning: 'Optional[str]'
+    limits: 'Optional[List[str]]'
+    wait: 'Optional[Duration]'
+    overrun: 'Optional[bool]'
+    output: 'Optional[bytes]'
+    stderr: 'Optional[bytes]'
+    queue: 'Optional[str]'
+    queue_wait: 'Optional[Duration]'
+    attempt: 'Optional[int]'
+    retried: 'Optional[bool]'
+    catch_up: 'Optional[bool]'
+    denied: 'Optional[bool]'
+    timeout: 'Optional[bool]'
+    trigger: 'Optional[str]'
+    spawn: 'Optional[Duration]'
+    first_byte: 'Optional[Duration]'
+    runtime: 'Optional[Duration]'
+    write: 'Optional[Duration]'
+    exit_code: 'Optional[int]'
+    signal: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "error": self.err,
+            "request": self.request.to_json(),
+            "begin": self.begin,
+            "end": self.end,
+            "rate": self.rate,
+            "coalesced": self.coalesced,
+            "cached": self.cached,
+            "rejected": self.rejected,
+            "throttled": self.throttled,
+            "status": self.status,
+            "payload": self.payload,
+            "size": self.size,
+            "cpu": self.cpu.to_json(),
+            "max_rss": self.max_rss,
+            "warning": self.warning,
+            "limits": self.limits,
+            "wait": self.wait.to_json(),
+            "overrun": self.overrun,
+            "output": encodebytes(self.output),
+            "stderr": encodebytes(self.stderr),
+            "queue": self.queue,
+            "queue_wait": self.queue_wait.to_json(),
+            "attempt": self.attempt,
+            "retried": self.retried,
+            "catch_up": self.catch_up,
+            "denied": self.denied,
+            "timeout": self.timeout,
+            "trigger": self.trigger,
+            "spawn": self.spawn.to_json(),
+            "first_byte": self.first_byte.to_json(),
+            "runtime": self.runtime.to_json(),
+            "write": self.write.to_json(),
+            "exit_code": self.exit_code,
+            "signal": self.signal,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Record':
+        return Record(
+                uid=payload['uid'],
+                err=payload['error'],
+                request=Request.from_json(payload['request']),
+                begin=payload['begin'],
+                end=payload['end'],
+                rate=payload['rate'],
+                coalesced=payload['coalesced'],
+                cached=payload['cached'],
+                rejected=payload['rejected'],
+                throttled=payload['throttled'],
+                status=payload['status'],
+                payload=payload['payload'],
+                size=payload['size'],
+                cpu=Duration.from_json(payload['cpu']),
+                max_rss=payload['max_rss'],
+                warning=payload['warning'],
+                limits=payload['limits'] or [],
+                wait=Duration.from_json(payload['wait']),
+                overrun=payload['overrun'],
+                output=decodebytes((payload['output'] or '').encode()),
+                stderr=decodebytes((payload['stderr'] or '').encode()),
+                queue=payload['queue'],
+                queue_wait=Duration.from_json(payload['queue_wait']),
+                attempt=payload['attempt'],
+                retried=payload['retried'],
+                catch_up=payload['catch_up'],
+                denied=payload['denied'],
+                timeout=payload['timeout'],
+                trigger=payload['trigger'],
+                spawn=Duration.from_json(payload['spawn']),
+                first_byte=Duration.from_json(payload['first_byte']),
+                runtime=Duration.from_json(payload['runtime']),
+                write=Duration.from_json(payload['write']),
+                exit_code=payload['exit_code'],
+                signal=payload['signal'],
+        )
+
+
+@dataclass
+class Request:
+    id: 'Optional[str]'
+    method: 'str'
+    url: 'str'
+    path: 'str'
+    remote_address: 'str'
+    form: 'Any'
+    headers: 'Any'
+    public_url: 'Optional[str]'
+    alias: 'Optional[str]'
+    chain: 'Optional[List[str]]'
+    queued: 'Optional[Any]'
+    user: 'Optional[str]'
+    claims: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "method": self.method,
+            "url": self.url,
+            "path": self.path,
+            "remote_address": self.remote_address,
+            "form": self.form,
+            "headers": self.headers,
+            "public_url": self.public_url,
+            "alias": self.alias,
+            "chain": self.chain,
+            "queued": self.queued,
+            "user": self.user,
+            "claims": self.claims,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Request':
+        return Request(
+                id=payload['id'],
+                method=payload['method'],
+                url=payload['url'],
+                path=payload['path'],
+                remote_address=payload['remote_address'],
+                form=payload['form'],
+                headers=payload['headers'],
+                public_url=payload['public_url'],
+                alias=payload['alias'],
+                chain=payload['chain'] or [],
+                queued=payload['queued'],
+                user=payload['user'],
+                claims=payload['claims'],
+        )
+
+
+@dataclass
+class Query:
+    uid: 'Optional[str]'
+    since: 'Optional[Any]'
+    until: 'Optional[Any]'
+    limit: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "since": self.since,
+            "until": self.until,
+            "limit": self.limit,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Query':
+        return Query(
+                uid=payload['uid'],
+                since=payload['since'],
+                until=payload['until'],
+                limit=payload['limit'],
+        )
+
+
+@dataclass
+class Aggregation:
+    uid: 'Optional[str]'
+    since: 'Any'
+    until: 'Any'
+    bucket: 'Duration'
+    buckets: 'List[Bucket]'
+    total: 'Bucket'
+    group: 'Optional[str]'
+    groups: 'Optional[List[Group]]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "since": self.since,
+            "until": self.until,
+            "bucket": self.bucket.to_json(),
+            "buckets": [x.to_json() for x in self.buckets],
+            "total": self.total.to_json(),
+            "group": self.group,
+            "groups": [x.to_json() for x in self.groups],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Aggregation':
+        return Aggregation(
+                uid=payload['uid'],
+                since=payload['since'],
+                until=payload['until'],
+                bucket=Duration.from_json(payload['bucket']),
+                buckets=[Bucket.from_json(x) for x in (payload['buckets'] or [])],
+                total=Bucket.from_json(payload['total']),
+                group=payload['group'],
+                groups=[Group.from_json(x) for x in (payload['groups'] or [])],
+        )
+
+
+@dataclass
+class Bucket:
+    begin: 'Any'
+    calls: 'int'
+    errors: 'Errors'
+    latency: 'Latency'
+
+    def to_json(self) -> dict:
+        return {
+            "begin": self.begin,
+            "calls": self.calls,
+            "errors": self.errors.to_json(),
+            "latency": self.latency.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Bucket':
+        return Bucket(
+                begin=payload['begin'],
+                calls=payload['calls'],
+                errors=Errors.from_json(payload['errors']),
+                latency=Latency.from_json(payload['latency']),
+        )
+
+
+@dataclass
+class Errors:
+    script: 'int'
+    timeout: 'int'
+    rejected: 'int'
+    denied: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "script": self.script,
+            "timeout": self.timeout,
+            "rejected": self.rejected,
+            "denied": self.denied,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Errors':
+        return Errors(
+                script=payload['script'],
+                timeout=payload['timeout'],
+                rejected=payload['rejected'],
+                denied=payload['denied'],
+        )
+
+
+@dataclass
+class Latency:
+    min: 'Duration'
+    avg: 'Duration'
+    p50: 'Duration'
+    p95: 'Duration'
+    max: 'Duration'
+
+    def to_json(self) -> dict:
+        return {
+            "min": self.min.to_json(),
+            "avg": self.avg.to_json(),
+            "p50": self.p50.to_json(),
+            "p95": self.p95.to_json(),
+            "max": self.max.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Latency':
+        return Latency(
+                min=Duration.from_json(payload['min']),
+                avg=Duration.from_json(payload['avg']),
+                p50=Duration.from_json(payload['p50']),
+                p95=Duration.from_json(payload['p95']),
+                max=Duration.from_json(payload['max']),
+        )
+
+
+@dataclass
+class Group:
+    key: 'str'
+    buckets: 'List[Bucket]'
+    total: 'Bucket'
+
+    def to_json(self) -> dict:
+        return {
+            "key": self.key,
+            "buckets": [x.to_json() for x in self.buckets],
+            "total": self.total.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Group':
+        return Group(
+                key=payload['key'],
+                buckets=[Bucket.from_json(x) for x in (payload['buckets'] or [])],
+                total=Bucket.from_json(payload['total']),
+        )
+
+
+@dataclass
+class ActionResult:
+    output: 'str'
+    stderr: 'Optional[str]'
+    exit_code: 'int'
+    error: 'Optional[str]'
+    duration: 'Any'
+    queue_position: 'Optional[int]'
+    queued: 'Optional[Any]'
+    limit_exceeded: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "output": self.output,
+            "stderr": self.stderr,
+            "exit_code": self.exit_code,
+            "error": self.error,
+            "duration": self.duration,
+            "queue_position": self.queue_position,
+            "queued": self.queued,
+            "limit_exceeded": self.limit_exceeded,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ActionResult':
+        return ActionResult(
+                output=payload['output'],
+                stderr=payload['stderr'],
+                exit_code=payload['exit_code'],
+                error=payload['error'],
+                duration=payload['duration'],
+                queue_position=payload['queue_position'],
+                queued=payload['queued'],
+                limit_exceeded=payload['limit_exceeded'],
+        )
+
+
+@dataclass
+class Diagnostic:
+    runtime: 'Runtime'
+    secrets: 'SecretsStatus'
+    startup: 'Optional[StartupStatus]'
+    builds: 'BuildLimits'
+    env: 'EnvStatus'
+    container: 'Optional[ContainerStatus]'
+
+    def to_json(self) -> dict:
+        return {
+            "runtime": self.runtime.to_json(),
+            "secrets": self.secrets.to_json(),
+            "startup": self.startup.to_json(),
+            "builds": self.builds.to_json(),
+            "env": self.env.to_json(),
+            "container": self.container.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Diagnostic':
+        return Diagnostic(
+                runtime=Runtime.from_json(payload['runtime']),
+                secrets=SecretsStatus.from_json(payload['secrets']),
+                startup=StartupStatus.from_json(payload['startup']),
+                builds=BuildLimits.from_json(payload['builds']),
+                env=EnvStatus.from_json(payload['env']),
+                container=ContainerStatus.from_json(payload['container']),
+        )
+
+
+@dataclass
+class Runtime:
+    umask: 'Optional[str]'
+    lang: 'Optional[str]'
+    lc_all: 'Optional[str]'
+    tz: 'Optional[str]'
+    env_limits: 'Optional[EnvLimits]'
+
+    def to_json(self) -> dict:
+        return {
+            "umask": self.umask,
+            "lang": self.lang,
+            "lc_all": self.lc_all,
+            "tz": self.tz,
+            "env_limits": self.env_limits.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Runtime':
+        return Runtime(
+                umask=payload['umask'],
+                lang=payload['lang'],
+                lc_all=payload['lc_all'],
+                tz=payload['tz'],
+                env_limits=EnvLimits.from_json(payload['env_limits']),
+        )
+
+
+@dataclass
+class EnvLimits:
+    max_size: 'Optional[int]'
+    max_variable: 'Optional[int]'
+    oversized: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "max_size": self.max_size,
+            "max_variable": self.max_variable,
+            "oversized": self.oversized,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'EnvLimits':
+        return EnvLimits(
+                max_size=payload['max_size'],
+                max_variable=payload['max_variable'],
+                oversized=payload['oversized'],
+        )
+
+
+@dataclass
+class SecretsStatus:
+    delivery: 'str'
+    env: 'Optional[str]'
+    keys: 'List[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "delivery": self.delivery,
+            "env": self.env,
+            "keys": self.keys,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SecretsStatus':
+        return SecretsStatus(
+                delivery=payload['delivery'],
+                env=payload['env'],
+                keys=payload['keys'] or [],
+        )
+
+
+@dataclass
+class StartupStatus:
+    action: 'str'
+    running: 'bool'
+    degraded: 'bool'
+    started: 'Any'
+    finished: 'Optional[Any]'
+    error: 'Optional[str]'
+    output: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "action": self.action,
+            "running": self.running,
+            "degraded": self.degraded,
+            "started": self.started,
+            "finished": self.finished,
+            "error": self.error,
+            "output": self.output,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'StartupStatus':
+        return StartupStatus(
+                action=payload['action'],
+                running=payload['running'],
+                degraded=payload['degraded'],
+                started=payload['started'],
+                finished=payload['finished'],
+                error=payload['error'],
+                output=payload['output'],
+        )
+
+
+@dataclass
+class BuildLimits:
+    cgroup: 'bool'
+
+    def to_json(self) -> dict:
+        return {
+            "cgroup": self.cgroup,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BuildLimits':
+        return BuildLimits(
+                cgroup=payload['cgroup'],
+        )
+
+
+@dataclass
+class EnvStatus:
+    size: 'int'
+    limit: 'int'
+    headroom: 'int'
+    max_variable: 'int'
+    oversized: 'str'
+    largest: 'Optional[List[EnvVariable]]'
+
+    def to_json(self) -> dict:
+        return {
+            "size": self.size,
+            "limit": self.limit,
+            "headroom": self.headroom,
+            "max_variable": self.max_variable,
+            "oversized": self.oversized,
+            "largest": [x.to_json() for x in self.largest],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'EnvStatus':
+        return EnvStatus(
+                size=payload['size'],
+                limit=payload['limit'],
+                headroom=payload['headroom'],
+                max_variable=payload['max_variable'],
+                oversized=payload['oversized'],
+                largest=[EnvVariable.from_json(x) for x in (payload['largest'] or [])],
+        )
+
+
+@dataclass
+class EnvVariable:
+    name: 'str'
+    size: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "size": self.size,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'EnvVariable':
+        return EnvVariable(
+                name=payload['name'],
+                size=payload['size'],
+        )
+
+
+@dataclass
+class ContainerStatus:
+    image: 'str'
+    state: 'str'
+    progress: 'Optional[str]'
+    error: 'Optional[str]'
+    updated: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "image": self.image,
+            "state": self.state,
+            "progress": self.progress,
+            "error": self.error,
+            "updated": self.updated,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ContainerStatus':
+        return ContainerStatus(
+                image=payload['image'],
+                state=payload['state'],
+                progress=payload['progress'],
+                error=payload['error'],
+                updated=payload['updated'],
+        )
+
+
+@dataclass
+class CapturedRequest:
+    id: 'str'
+    _lambda: 'str'
+    captured: 'Any'
+    duration: 'Any'
+    error: 'Optional[str]'
+    size: 'int'
+    truncated: 'Optional[bool]'
+    request: 'Request'
+    body: 'Optional[bytes]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "lambda": self._lambda,
+            "captured": self.captured,
+            "duration": self.duration,
+            "error": self.error,
+            "size": self.size,
+            "truncated": self.truncated,
+            "request": self.request.to_json(),
+            "body": encodebytes(self.body),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'CapturedRequest':
+        return CapturedRequest(
+                id=payload['id'],
+                _lambda=payload['lambda'],
+                captured=payload['captured'],
+                duration=payload['duration'],
+                error=payload['error'],
+                size=payload['size'],
+                truncated=payload['truncated'],
+                request=Request.from_json(payload['request']),
+                body=decodebytes((payload['body'] or '').encode()),
+        )
+
+
+@dataclass
+class ReplayResult:
+    id: 'str'
+    output: 'bytes'
+    stderr: 'Optional[str]'
+    error: 'Optional[str]'
+    duration: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "output": encodebytes(self.output),
+            "stderr": self.stderr,
+            "error": self.error,
+            "duration": self.duration,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ReplayResult':
+        return ReplayResult(
+                id=payload['id'],
+                output=decodebytes((payload['output'] or '').encode()),
+                stderr=payload['stderr'],
+                error=payload['error'],
+                duration=payload['duration'],
+        )
+
+
+@dataclass
+class TransferGrant:
+    uid: 'str'
+    token: 'str'
+    hash: 'str'
+    secrets: 'bool'
+    expires: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "token": self.token,
+            "hash": self.hash,
+            "secrets": self.secrets,
+            "expires": self.expires,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'TransferGrant':
+        return TransferGrant(
+                uid=payload['uid'],
+                token=payload['token'],
+                hash=payload['hash'],
+                secrets=payload['secrets'],
+                expires=payload['expires'],
+        )
+
+
+@dataclass
+class LambdaExport:
+    uid: 'str'
+    manifest: 'Manifest'
+    aliases: 'Optional[List[str]]'
+    content: 'bytes'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "manifest": self.manifest.to_json(),
+            "aliases": self.aliases,
+            "content": encodebytes(self.content),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LambdaExport':
+        return LambdaExport(
+                uid=payload['uid'],
+                manifest=Manifest.from_json(payload['manifest']),
+                aliases=payload['aliases'] or [],
+                content=decodebytes((payload['content'] or '').encode()),
+        )
+
+
+@dataclass
+class FrozenArtifact:
+    id: 'str'
+    uid: 'str'
+    size: 'int'
+    created: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "uid": self.uid,
+            "size": self.size,
+            "created": self.created,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'FrozenArtifact':
+        return FrozenArtifact(
+                id=payload['id'],
+                uid=payload['uid'],
+                size=payload['size'],
+                created=payload['created'],
+        )
+
+
+@dataclass
+class LambdaVersion:
+    id: 'str'
+    uid: 'str'
+    created: 'Any'
+    author: 'Optional[str]'
+    message: 'Optional[str]'
+    size: 'int'
+    files: 'int'
+    current: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "uid": self.uid,
+            "created": self.created,
+            "author": self.author,
+            "message": self.message,
+            "size": self.size,
+            "files": self.files,
+            "current": self.current,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LambdaVersion':
+        return LambdaVersion(
+                id=payload['id'],
+                uid=payload['uid'],
+                created=payload['created'],
+                author=payload['author'],
+                message=payload['message'],
+                size=payload['size'],
+                files=payload['files'],
+                current=payload['current'],
+        )
+
+
+@dataclass
+class RollbackReport:
+    version: 'LambdaVersion'
+    action: 'Optional[str]'
+    warning: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "version": self.version.to_json(),
+            "action": self.action,
+            "warning": self.warning,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RollbackReport':
+        return RollbackReport(
+                version=LambdaVersion.from_json(payload['version']),
+                action=payload['action'],
+                warning=payload['warning'],
+        )
+
+
+@dataclass
+class VersionRollback:
+    id: 'str'
+    action: 'Optional[str]'
+    no_build: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "action": self.action,
+            "no_build": self.no_build,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'VersionRollback':
+        return VersionRollback(
+                id=payload['id'],
+                action=payload['action'],
+                no_build=payload['no_build'],
+        )
+
+
+@dataclass
+class LogFile:
+    name: 'str'
+    size: 'int'
+    modified: 'Any'
+    current: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "size": self.size,
+            "modified": self.modified,
+            "current": self.current,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LogFile':
+        return LogFile(
+                name=payload['name'],
+                size=payload['size'],
+                modified=payload['modified'],
+                current=payload['current'],
+        )
+
+
+class LambdaAPIError(RuntimeError):
+    def __init__(self, method: str, code: int, message: str, data: Any):
+        super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
+        self.code = code
+        self.message = message
+        self.data = data
+
+    @staticmethod
+    def from_json(method: str, payload: dict) -> 'LambdaAPIError':
+        return LambdaAPIError(
+            method=method,
+            code=payload['code'],
+            message=payload['message'],
+            data=payload.get('data')
+        )
+
+
+class LambdaAPIClient:
+    """
+    API for lambdas
+    """
+
+    def __init__(self, base_url: str = 'https://127.0.0.1:3434/u/', session: Optional[client.ClientSession] = None):
+        self.__url = base_url
+        self.__id = 1
+        self.__request = session.request if session is not None else client.request
+
+    def __next_id(self):
+        self.__id += 1
+        return self.__id
+
+    async def upload(self, token: Any, uid: str, archive: bytes) -> bool:
+        """
+        Upload content from .tar.gz or .zip archive (detected by content) to app and call Install handler (if defined).
+Unknown format is error with code 415
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Upload",
+            "id": self.__next_id(),
+            "params": [token, uid, encodebytes(archive), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('upload', payload['error'])
+        return payload['result']
+
+    async def download(self, token: Any, uid: str) -> bytes:
+        """
+        Download content as .tar.gz archive from app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Download",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('download', payload['error'])
+        return decodebytes((payload['result'] or '').encode())
+
+    async def upload_bundle(self, token: Any, uid: str, zip: bytes) -> str:
+        """
+        Upload content as single read-only .zip bundle to app and returns bundle hash
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.UploadBundle",
+            "id": self.__next_id(),
+            "params": [token, uid, encodebytes(zip), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('upload_bundle', payload['error'])
+        return payload['result']
+
+    async def content_hash(self, token: Any, uid: str) -> str:
+        """
+        Hash of app content (hash of bundle for bundled app)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.ContentHash",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('content_hash', payload['error'])
+        return payload['result']
+
+    async def upload_verified(self, token: Any, uid: str, archive: bytes, nonce: str) -> ContentProof:
+        """
+        Upload content as Upload does and return hash of received archive and content hash of app signed by token
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.UploadVerified",
+            "id": self.__next_id(),
+            "params": [token, uid, encodebytes(archive), nonce, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('upload_verified', payload['error'])
+        return ContentProof.from_json(payload['result'])
+
+    async def signed_content_hash(self, token: Any, uid: str, nonce: str) -> ContentProof:
+        """
+        Hash of app content (as ContentHash) signed by token for spot checks
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.SignedContentHash",
+            "id": self.__next_id(),
+            "params": [token, uid, nonce, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('signed_content_hash', payload['error'])
+        return ContentProof.from_json(payload['result'])
+
+    async def push(self, token: Any, uid: str, file: str, content: bytes) -> bool:
+        """
+        Push single file to app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Push",
+            "id": self.__next_id(),
+            "params": [token, uid, file, encodebytes(content), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('push', payload['error'])
+        return payload['result']
+
+    async def pull(self, token: Any, uid: str, file: str) -> bytes:
+        """
+        Pull single file from app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Pull",
+            "id": self.__next_id(),
+            "params": [token, uid, file, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('pull', payload['error'])
+        return decodebytes((payload['result'] or '').encode())
+
+    async def remove(self, token: Any, uid: str) -> bool:
+        """
+        Remove app and call Uninstall handler (if defined)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Remove",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('remove', payload['error'])
+        return payload['result']
+
+    async def files(self, token: Any, uid: str, dir: str) -> List[File]:
+        """
+        Files in func dir
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Files",
+            "id": self.__next_id(),
+            "params": [token, uid, dir, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('files', payload['error'])
+        return [File.from_json(x) for x in (payload['result'] or [])]
+
+    async def info(self, token: Any, uid: str) -> Definition:
+        """
+        Info about application
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Info",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('info', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def update(self, token: Any, uid: str, manifest: Manifest, force_aliases: bool) -> Definition:
+        """
+        Update application manifest. Invalid manifest is error with code 422 and problems of fields
+(list of field and message) as data. Declared aliases are bound to the lambda, aliases removed from manifest
+are released. Aliases bound to other lambdas are error with code 409 and conflicts as data unless forceAliases
+is set (aliases are moved to the lambda)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Update",
+            "id": self.__next_id(),
+            "params": [token, uid, manifest.to_json(), force_aliases, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('update', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def bulk_update(self, token: Any, request: BulkUpdate) -> BulkReport:
+        """
+        Apply partial change of manifest (merge patch and assignments of fields) to selected lambdas. Every patched
+manifest is validated and applied the same way as by Update (aliases are not forced): failure of one lambda does
+not abort the batch and is reported in its result. Dry run reports changes without apply
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.BulkUpdate",
+            "id": self.__next_id(),
+            "params": [token, request.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('bulk_update', payload['error'])
+        return BulkReport.from_json(payload['result'])
+
+    async def environment(self, token: Any, uid: str) -> Environment:
+        """
+        Environment variables of application from manifest (as is, references are not resolved)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Environment",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('environment', payload['error'])
+        return Environment.from_json(payload['result'])
+
+    async def set_environment(self, token: Any, uid: str, set: Environment, unset: List[str]) -> Environment:
+        """
+        Set and remove environment variables of application without changing the rest of manifest. Returns updated
+environment. Applied for the next invocation
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.SetEnvironment",
+            "id": self.__next_id(),
+            "params": [token, uid, set.to_json(), unset, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('set_environment', payload['error'])
+        return Environment.from_json(payload['result'])
+
+    async def merged_environment(self, token: Any, uid: str) -> List[EnvValue]:
+        """
+        Variables of environment of invocations of application: runtime defaults and global environment of server merged
+with environment of manifest (manifest wins). References are resolved except references to secrets. Variables of
+server process and request are not included
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.MergedEnvironment",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('merged_environment', payload['error'])
+        return [EnvValue.from_json(x) for x in (payload['result'] or [])]
+
+    async def set_basic_auth(self, token: Any, uid: str, set: Passwords, unset: List[str]) -> List[str]:
+        """
+        Set (passwords are hashed by server) and remove users of basic authentication of application without changing
+the rest of manifest. Authentication is removed with the last user. Returns updated usernames
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.SetBasicAuth",
+            "id": self.__next_id(),
+            "params": [token, uid, set.to_json(), unset, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('set_basic_auth', payload['error'])
+        return payload['result'] or []
+
+    async def create_file(self, token: Any, uid: str, path: str, dir: bool) -> bool:
+        """
+        Create file or directory inside app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.CreateFile",
+            "id": self.__next_id(),
+            "params": [token, uid, path, dir, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('create_file', payload['error'])
+        return payload['result']
+
+    async def remove_file(self, token: Any, uid: str, path: str) -> bool:
+        """
+        Remove file or directory
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.RemoveFile",
+            "id": self.__next_id(),
+            "params": [token, uid, path, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('remove_file', payload['error'])
+        return payload['result']
+
+    async def rename_file(self, token: Any, uid: str, old_path: str, new_path: str) -> bool:
+        """
+        Rename file or directory
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.RenameFile",
+            "id": self.__next_id(),
+            "params": [token, uid, old_path, new_path, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('rename_file', payload['error'])
+        return payload['result']
+
+    async def stats(self, token: Any, uid: str, limit: int) -> List[Record]:
+        """
+        Stats for the app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Stats",
+            "id": self.__next_id(),
+            "params": [token, uid, limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('stats', payload['error'])
+        return [Record.from_json(x) for x in (payload['result'] or [])]
+
+    async def stats_range(self, token: Any, uid: str, query: Query) -> List[Record]:
+        """
+        Records of the app in time range from the newest (UID of query is replaced by UID of the app)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.StatsRange",
+            "id": self.__next_id(),
+            "params": [token, uid, query.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('stats_range', payload['error'])
+        return [Record.from_json(x) for x in (payload['result'] or [])]
+
+    async def stats_aggregate(self, token: Any, uid: str, query: Query, bucket: Any, group: str) -> Aggregation:
+        """
+        Calls, errors by category and latency percentiles of the app by buckets of time range (UID of query is replaced
+by UID of the app, zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.StatsAggregate",
+            "id": self.__next_id(),
+            "params": [token, uid, query.to_json(), bucket, group, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('stats_aggregate', payload['error'])
+        return Aggregation.from_json(payload['result'])
+
+    async def actions(self, token: Any, uid: str) -> List[str]:
+        """
+        Actions available for the app: actions of manifest and targets of Makefile
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Actions",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('actions', payload['error'])
+        return payload['result'] or []
+
+    async def invoke(self, token: Any, uid: str, action: str) -> str:
+        """
+        Invoke action in the app (action of manifest or make target, if make installed)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Invoke",
+            "id": self.__next_id(),
+            "params": [token, uid, action, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('invoke', payload['error'])
+        return payload['result']
+
+    async def invoke_action(self, token: Any, uid: str, action: str, time_limit: Any) -> ActionResult:
+        """
+        Invoke action in the app with time limit (0 means no limit) and return output with exit code even for failed action
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.InvokeAction",
+            "id": self.__next_id(),
+            "params": [token, uid, action, time_limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('invoke_action', payload['error'])
+        return ActionResult.from_json(payload['result'])
+
+    async def link(self, token: Any, uid: str, alias: str) -> Definition:
+        """
+        Make link/alias for app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Link",
+            "id": self.__next_id(),
+            "params": [token, uid, alias, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('link', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def unlink(self, token: Any, alias: str) -> Definition:
+        """
+        Remove link
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Unlink",
+            "id": self.__next_id(),
+            "params": [token, alias, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('unlink', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def doctor(self, token: Any, uid: str) -> Diagnostic:
+        """
+        Effective runtime settings (umask, locale, timezone) of the app
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Doctor",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('doctor', payload['error'])
+        return Diagnostic.from_json(payload['result'])
+
+    async def requests(self, token: Any, uid: str) -> List[CapturedRequest]:
+        """
+        Captured requests of the app from the newest without bodies (see types.Manifest.Capture)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Requests",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('requests', payload['error'])
+        return [CapturedRequest.from_json(x) for x in (payload['result'] or [])]
 
-    def __init__(self, base_url: str = 'https://127.0.0.1:3434/u/', session: Optional[client.ClientSession] = None):
-        self.__url = base_url
-        self.__id = 1
-        self.__request = session.request if session is not None else client.request
+    async def captured_request(self, token: Any, uid: str, id: str) -> CapturedRequest:
+        """
+        Captured request of the app with body
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.CapturedRequest",
+            "id": self.__next_id(),
+            "params": [token, uid, id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('captured_request', payload['error'])
+        return CapturedRequest.from_json(payload['result'])
 
-    def __next_id(self):
-        self.__id += 1
-        return self.__id
+    async def replay(self, token: Any, uid: str, id: str) -> ReplayResult:
+        """
+        Invoke the app by captured request again and return new result. Truncated requests can not be replayed
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Replay",
+            "id": self.__next_id(),
+            "params": [token, uid, id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('replay', payload['error'])
+        return ReplayResult.from_json(payload['result'])
 
-    async def upload(self, token: Any, uid: str, tar_gz: bytes) -> bool:
+    async def set_enabled(self, token: Any, uid: str, enabled: bool) -> Definition:
         """
-        Upload content from .tar.gz archive to app and call Install handler (if defined)
+        Enable or disable the app without removing it. Disabled app responds 503 to public requests (by UID and
+aliases), its schedules are paused and queued requests are held till it is enabled. State survives restarts
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Upload",
+            "method": "LambdaAPI.SetEnabled",
             "id": self.__next_id(),
-            "params": [token, uid, encodebytes(tar_gz), ]
+            "params": [token, uid, enabled, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('upload', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('set_enabled', payload['error'])
+        return Definition.from_json(payload['result'])
 
-    async def download(self, token: Any, uid: str) -> bytes:
+    async def regenerate_slug(self, token: Any, uid: str) -> Definition:
         """
-        Download content as .tar.gz archive from app
+        Generate slug alias from name of the app (previous slug is kept as alias)
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Download",
+            "method": "LambdaAPI.RegenerateSlug",
             "id": self.__next_id(),
             "params": [token, uid, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('download', payload['error'])
-        return decodebytes((payload['result'] or '').encode())
+            raise LambdaAPIError.from_json('regenerate_slug', payload['error'])
+        return Definition.from_json(payload['result'])
 
-    async def push(self, token: Any, uid: str, file: str, content: bytes) -> bool:
+    async def reset_alerts(self, token: Any, uid: str, rule: str) -> List[AlertStatus]:
         """
-        Push single file to app
+        Reset fired alert rules of the app (all rules if rule is empty) and re-enable it
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Push",
+            "method": "LambdaAPI.ResetAlerts",
             "id": self.__next_id(),
-            "params": [token, uid, file, encodebytes(content), ]
+            "params": [token, uid, rule, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('push', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('reset_alerts', payload['error'])
+        return [AlertStatus.from_json(x) for x in (payload['result'] or [])]
 
-    async def pull(self, token: Any, uid: str, file: str) -> bytes:
+    async def git_deploy(self, token: Any, uid: str, force: bool) -> GitStatus:
         """
-        Pull single file from app
+        Fetch tracked git branch of the app (see types.Manifest.Git) and deploy new commit (force - deploy even the same
+or failed commit). Failed deploy keeps the previous version and is returned as status with error. Error with code
+404 if the app does not track git, 409 if deploy is in progress
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Pull",
+            "method": "LambdaAPI.GitDeploy",
             "id": self.__next_id(),
-            "params": [token, uid, file, ]
+            "params": [token, uid, force, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('pull', payload['error'])
-        return decodebytes((payload['result'] or '').encode())
+            raise LambdaAPIError.from_json('git_deploy', payload['error'])
+        return GitStatus.from_json(payload['result'])
 
-    async def remove(self, token: Any, uid: str) -> bool:
+    async def grant_export(self, token: Any, uid: str, secrets: bool) -> TransferGrant:
         """
-        Remove app and call Uninstall handler (if defined)
+        Short-lived (5 minutes) grant of export of the app by another server (see Export and ProjectAPI.Transfer)
+with hash of exported content. Values of environment variables are exported only if secrets is set
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Remove",
+            "method": "LambdaAPI.GrantExport",
             "id": self.__next_id(),
-            "params": [token, uid, ]
+            "params": [token, uid, secrets, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('remove', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('grant_export', payload['error'])
+        return TransferGrant.from_json(payload['result'])
 
-    async def files(self, token: Any, uid: str, dir: str) -> List[File]:
+    async def export(self, grant: str, uid: str) -> LambdaExport:
         """
-        Files in func dir
+        Export the app by token of grant (see GrantExport) instead of login token: manifest, aliases and content
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Files",
+            "method": "LambdaAPI.Export",
             "id": self.__next_id(),
-            "params": [token, uid, dir, ]
+            "params": [grant, uid, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('files', payload['error'])
-        return [File.from_json(x) for x in (payload['result'] or [])]
+            raise LambdaAPIError.from_json('export', payload['error'])
+        return LambdaExport.from_json(payload['result'])
 
-    async def info(self, token: Any, uid: str) -> Definition:
+    async def freeze(self, token: Any, uid: str) -> FrozenArtifact:
         """
-        Info about application
+        Freeze all files of the app, including dependencies installed by actions and files ignored by .cgiignore, to
+content-addressed artifact kept by server: freeze of the same files returns the same artifact. Old artifacts are
+pruned by retention of server
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Info",
+            "method": "LambdaAPI.Freeze",
             "id": self.__next_id(),
             "params": [token, uid, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('info', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise LambdaAPIError.from_json('freeze', payload['error'])
+        return FrozenArtifact.from_json(payload['result'])
 
-    async def update(self, token: Any, uid: str, manifest: Manifest) -> Definition:
+    async def frozen_artifacts(self, token: Any, uid: str) -> List[FrozenArtifact]:
         """
-        Update application manifest
+        Frozen artifacts of the app from the newest
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Update",
+            "method": "LambdaAPI.FrozenArtifacts",
             "id": self.__next_id(),
-            "params": [token, uid, manifest.to_json(), ]
+            "params": [token, uid, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('update', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise LambdaAPIError.from_json('frozen_artifacts', payload['error'])
+        return [FrozenArtifact.from_json(x) for x in (payload['result'] or [])]
 
-    async def create_file(self, token: Any, uid: str, path: str, dir: bool) -> bool:
+    async def download_frozen(self, token: Any, uid: str, id: str) -> bytes:
         """
-        Create file or directory inside app
+        Download frozen artifact of the app (empty id - the newest) as .tar.gz archive. Unknown artifact is error with
+code 404
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.CreateFile",
+            "method": "LambdaAPI.DownloadFrozen",
             "id": self.__next_id(),
-            "params": [token, uid, path, dir, ]
+            "params": [token, uid, id, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('create_file', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('download_frozen', payload['error'])
+        return decodebytes((payload['result'] or '').encode())
 
-    async def remove_file(self, token: Any, uid: str, path: str) -> bool:
+    async def thaw(self, token: Any, uid: str, id: str) -> FrozenArtifact:
         """
-        Remove file or directory
+        Replace all files of the app by frozen artifact (empty id - the newest) without builds and network: manifest of
+artifact is applied. Unknown artifact is error with code 404
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.RemoveFile",
+            "method": "LambdaAPI.Thaw",
             "id": self.__next_id(),
-            "params": [token, uid, path, ]
+            "params": [token, uid, id, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('remove_file', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('thaw', payload['error'])
+        return FrozenArtifact.from_json(payload['result'])
 
-    async def rename_file(self, token: Any, uid: str, old_path: str, new_path: str) -> bool:
+    async def upload_version(self, token: Any, uid: str, archive: bytes, message: str) -> LambdaVersion:
         """
-        Rename file or directory
+        Upload content as Upload does and return version of the app annotated by message (upload of the same files
+returns the same version). Versions are also taken by every upload and update of manifest
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.RenameFile",
+            "method": "LambdaAPI.UploadVersion",
             "id": self.__next_id(),
-            "params": [token, uid, old_path, new_path, ]
+            "params": [token, uid, encodebytes(archive), message, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('rename_file', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('upload_version', payload['error'])
+        return LambdaVersion.from_json(payload['result'])
 
-    async def stats(self, token: Any, uid: str, limit: int) -> List[Record]:
+    async def versions(self, token: Any, uid: str) -> List[LambdaVersion]:
         """
-        Stats for the app
+        Versions of the app (files and manifest) from the newest. Old versions are pruned by retention of server
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Stats",
+            "method": "LambdaAPI.Versions",
             "id": self.__next_id(),
-            "params": [token, uid, limit, ]
+            "params": [token, uid, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('stats', payload['error'])
-        return [Record.from_json(x) for x in (payload['result'] or [])]
+            raise LambdaAPIError.from_json('versions', payload['error'])
+        return [LambdaVersion.from_json(x) for x in (payload['result'] or [])]
 
-    async def actions(self, token: Any, uid: str) -> List[str]:
+    async def rollback(self, token: Any, uid: str, rollback: VersionRollback) -> RollbackReport:
         """
-        Actions available for the app
+        Restore files and manifest of version of the app (ID or unique prefix) and run build action (empty - build if
+defined by Makefile of version) before files are replaced: failed build keeps the app. Unknown version is error
+with code 404
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Actions",
+            "method": "LambdaAPI.Rollback",
             "id": self.__next_id(),
-            "params": [token, uid, ]
+            "params": [token, uid, rollback.to_json(), ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('actions', payload['error'])
-        return payload['result'] or []
+            raise LambdaAPIError.from_json('rollback', payload['error'])
+        return RollbackReport.from_json(payload['result'])
 
-    async def invoke(self, token: Any, uid: str, action: str) -> str:
+    async def log_files(self, token: Any, uid: str) -> List[LogFile]:
         """
-        Invoke action in the app (if make installed)
+        Log files of the app (full stdout and stderr of invocations if logs are enabled by manifest) from the oldest
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Invoke",
+            "method": "LambdaAPI.LogFiles",
             "id": self.__next_id(),
-            "params": [token, uid, action, ]
+            "params": [token, uid, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('invoke', payload['error'])
-        return payload['result']
+            raise LambdaAPIError.from_json('log_files', payload['error'])
+        return [LogFile.from_json(x) for x in (payload['result'] or [])]
 
-    async def link(self, token: Any, uid: str, alias: str) -> Definition:
+    async def download_log(self, token: Any, uid: str, name: str) -> bytes:
         """
-        Make link/alias for app
+        Download log file of the app by name (see LogFiles). Unknown file is error with code 404
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Link",
+            "method": "LambdaAPI.DownloadLog",
             "id": self.__next_id(),
-            "params": [token, uid, alias, ]
+            "params": [token, uid, name, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('link', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise LambdaAPIError.from_json('download_log', payload['error'])
+        return decodebytes((payload['result'] or '').encode())
 
-    async def unlink(self, token: Any, alias: str) -> Definition:
+    async def tail_log(self, token: Any, uid: str, lines: int) -> str:
         """
-        Remove link
+        The last lines (zero - 100) of current log file of the app
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "LambdaAPI.Unlink",
+            "method": "LambdaAPI.TailLog",
             "id": self.__next_id(),
-            "params": [token, alias, ]
+            "params": [token, uid, lines, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise LambdaAPIError.from_json('unlink', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise LambdaAPIError.from_json('tail_log', payload['error'])
+        return payload['result']
 
     async def _invoke(self, request):
         return await self.__request('POST', self.__url, json=request)
@@ -491,11 +3165,12 @@ class LambdaAPIBatch:
         self.__id += 1
         return self.__id
 
-    def upload(self, token: Any, uid: str, tar_gz: bytes):
+    def upload(self, token: Any, uid: str, archive: bytes):
         """
-        Upload content from .tar.gz archive to app and call Install handler (if defined)
+        Upload content from .tar.gz or .zip archive (detected by content) to app and call Install handler (if defined).
+Unknown format is error with code 415
         """
-        params = [token, uid, encodebytes(tar_gz), ]
+        params = [token, uid, encodebytes(archive), ]
         method = "LambdaAPI.Upload"
         self.__add_request(method, params, lambda payload: payload)
 
@@ -507,6 +3182,38 @@ class LambdaAPIBatch:
         method = "LambdaAPI.Download"
         self.__add_request(method, params, lambda payload: decodebytes((payload or '').encode()))
 
+    def upload_bundle(self, token: Any, uid: str, zip: bytes):
+        """
+        Upload content as single read-only .zip bundle to app and returns bundle hash
+        """
+        params = [token, uid, encodebytes(zip), ]
+        method = "LambdaAPI.UploadBundle"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def content_hash(self, token: Any, uid: str):
+        """
+        Hash of app content (hash of bundle for bundled app)
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.ContentHash"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def upload_verified(self, token: Any, uid: str, archive: bytes, nonce: str):
+        """
+        Upload content as Upload does and return hash of received archive and content hash of app signed by token
+        """
+        params = [token, uid, encodebytes(archive), nonce, ]
+        method = "LambdaAPI.UploadVerified"
+        self.__add_request(method, params, lambda payload: ContentProof.from_json(payload))
+
+    def signed_content_hash(self, token: Any, uid: str, nonce: str):
+        """
+        Hash of app content (as ContentHash) signed by token for spot checks
+        """
+        params = [token, uid, nonce, ]
+        method = "LambdaAPI.SignedContentHash"
+        self.__add_request(method, params, lambda payload: ContentProof.from_json(payload))
+
     def push(self, token: Any, uid: str, file: str, content: bytes):
         """
         Push single file to app
@@ -547,14 +3254,63 @@ class LambdaAPIBatch:
         method = "LambdaAPI.Info"
         self.__add_request(method, params, lambda payload: Definition.from_json(payload))
 
-    def update(self, token: Any, uid: str, manifest: Manifest):
+    def update(self, token: Any, uid: str, manifest: Manifest, force_aliases: bool):
         """
-        Update application manifest
+        Update application manifest. Invalid manifest is error with code 422 and problems of fields
+(list of field and message) as data. Declared aliases are bound to the lambda, aliases removed from manifest
+are released. Aliases bound to other lambdas are error with code 409 and conflicts as data unless forceAliases
+is set (aliases are moved to the lambda)
         """
-        params = [token, uid, manifest.to_json(), ]
+        params = [token, uid, manifest.to_json(), force_aliases, ]
         method = "LambdaAPI.Update"
         self.__add_request(method, params, lambda payload: Definition.from_json(payload))
 
+    def bulk_update(self, token: Any, request: BulkUpdate):
+        """
+        Apply partial change of manifest (merge patch and assignments of fields) to selected lambdas. Every patched
+manifest is validated and applied the same way as by Update (aliases are not forced): failure of one lambda does
+not abort the batch and is reported in its result. Dry run reports changes without apply
+        """
+        params = [token, request.to_json(), ]
+        method = "LambdaAPI.BulkUpdate"
+        self.__add_request(method, params, lambda payload: BulkReport.from_json(payload))
+
+    def environment(self, token: Any, uid: str):
+        """
+        Environment variables of application from manifest (as is, references are not resolved)
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.Environment"
+        self.__add_request(method, params, lambda payload: Environment.from_json(payload))
+
+    def set_environment(self, token: Any, uid: str, set: Environment, unset: List[str]):
+        """
+        Set and remove environment variables of application without changing the rest of manifest. Returns updated
+environment. Applied for the next invocation
+        """
+        params = [token, uid, set.to_json(), unset, ]
+        method = "LambdaAPI.SetEnvironment"
+        self.__add_request(method, params, lambda payload: Environment.from_json(payload))
+
+    def merged_environment(self, token: Any, uid: str):
+        """
+        Variables of environment of invocations of application: runtime defaults and global environment of server merged
+with environment of manifest (manifest wins). References are resolved except references to secrets. Variables of
+server process and request are not included
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.MergedEnvironment"
+        self.__add_request(method, params, lambda payload: [EnvValue.from_json(x) for x in (payload or [])])
+
+    def set_basic_auth(self, token: Any, uid: str, set: Passwords, unset: List[str]):
+        """
+        Set (passwords are hashed by server) and remove users of basic authentication of application without changing
+the rest of manifest. Authentication is removed with the last user. Returns updated usernames
+        """
+        params = [token, uid, set.to_json(), unset, ]
+        method = "LambdaAPI.SetBasicAuth"
+        self.__add_request(method, params, lambda payload: payload or [])
+
     def create_file(self, token: Any, uid: str, path: str, dir: bool):
         """
         Create file or directory inside app
@@ -587,9 +3343,26 @@ class LambdaAPIBatch:
         method = "LambdaAPI.Stats"
         self.__add_request(method, params, lambda payload: [Record.from_json(x) for x in (payload or [])])
 
+    def stats_range(self, token: Any, uid: str, query: Query):
+        """
+        Records of the app in time range from the newest (UID of query is replaced by UID of the app)
+        """
+        params = [token, uid, query.to_json(), ]
+        method = "LambdaAPI.StatsRange"
+        self.__add_request(method, params, lambda payload: [Record.from_json(x) for x in (payload or [])])
+
+    def stats_aggregate(self, token: Any, uid: str, query: Query, bucket: Any, group: str):
+        """
+        Calls, errors by category and latency percentiles of the app by buckets of time range (UID of query is replaced
+by UID of the app, zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+        """
+        params = [token, uid, query.to_json(), bucket, group, ]
+        method = "LambdaAPI.StatsAggregate"
+        self.__add_request(method, params, lambda payload: Aggregation.from_json(payload))
+
     def actions(self, token: Any, uid: str):
         """
-        Actions available for the app
+        Actions available for the app: actions of manifest and targets of Makefile
         """
         params = [token, uid, ]
         method = "LambdaAPI.Actions"
@@ -597,12 +3370,20 @@ class LambdaAPIBatch:
 
     def invoke(self, token: Any, uid: str, action: str):
         """
-        Invoke action in the app (if make installed)
+        Invoke action in the app (action of manifest or make target, if make installed)
         """
         params = [token, uid, action, ]
         method = "LambdaAPI.Invoke"
         self.__add_request(method, params, lambda payload: payload)
 
+    def invoke_action(self, token: Any, uid: str, action: str, time_limit: Any):
+        """
+        Invoke action in the app with time limit (0 means no limit) and return output with exit code even for failed action
+        """
+        params = [token, uid, action, time_limit, ]
+        method = "LambdaAPI.InvokeAction"
+        self.__add_request(method, params, lambda payload: ActionResult.from_json(payload))
+
     def link(self, token: Any, uid: str, alias: str):
         """
         Make link/alias for app
@@ -619,6 +3400,177 @@ class LambdaAPIBatch:
         method = "LambdaAPI.Unlink"
         self.__add_request(method, params, lambda payload: Definition.from_json(payload))
 
+    def doctor(self, token: Any, uid: str):
+        """
+        Effective runtime settings (umask, locale, timezone) of the app
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.Doctor"
+        self.__add_request(method, params, lambda payload: Diagnostic.from_json(payload))
+
+    def requests(self, token: Any, uid: str):
+        """
+        Captured requests of the app from the newest without bodies (see types.Manifest.Capture)
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.Requests"
+        self.__add_request(method, params, lambda payload: [CapturedRequest.from_json(x) for x in (payload or [])])
+
+    def captured_request(self, token: Any, uid: str, id: str):
+        """
+        Captured request of the app with body
+        """
+        params = [token, uid, id, ]
+        method = "LambdaAPI.CapturedRequest"
+        self.__add_request(method, params, lambda payload: CapturedRequest.from_json(payload))
+
+    def replay(self, token: Any, uid: str, id: str):
+        """
+        Invoke the app by captured request again and return new result. Truncated requests can not be replayed
+        """
+        params = [token, uid, id, ]
+        method = "LambdaAPI.Replay"
+        self.__add_request(method, params, lambda payload: ReplayResult.from_json(payload))
+
+    def set_enabled(self, token: Any, uid: str, enabled: bool):
+        """
+        Enable or disable the app without removing it. Disabled app responds 503 to public requests (by UID and
+aliases), its schedules are paused and queued requests are held till it is enabled. State survives restarts
+        """
+        params = [token, uid, enabled, ]
+        method = "LambdaAPI.SetEnabled"
+        self.__add_request(method, params, lambda payload: Definition.from_json(payload))
+
+    def regenerate_slug(self, token: Any, uid: str):
+        """
+        Generate slug alias from name of the app (previous slug is kept as alias)
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.RegenerateSlug"
+        self.__add_request(method, params, lambda payload: Definition.from_json(payload))
+
+    def reset_alerts(self, token: Any, uid: str, rule: str):
+        """
+        Reset fired alert rules of the app (all rules if rule is empty) and re-enable it
+        """
+        params = [token, uid, rule, ]
+        method = "LambdaAPI.ResetAlerts"
+        self.__add_request(method, params, lambda payload: [AlertStatus.from_json(x) for x in (payload or [])])
+
+    def git_deploy(self, token: Any, uid: str, force: bool):
+        """
+        Fetch tracked git branch of the app (see types.Manifest.Git) and deploy new commit (force - deploy even the same
+or failed commit). Failed deploy keeps the previous version and is returned as status with error. Error with code
+404 if the app does not track git, 409 if deploy is in progress
+        """
+        params = [token, uid, force, ]
+        method = "LambdaAPI.GitDeploy"
+        self.__add_request(method, params, lambda payload: GitStatus.from_json(payload))
+
+    def grant_export(self, token: Any, uid: str, secrets: bool):
+        """
+        Short-lived (5 minutes) grant of export of the app by another server (see Export and ProjectAPI.Transfer)
+with hash of exported content. Values of environment variables are exported only if secrets is set
+        """
+        params = [token, uid, secrets, ]
+        method = "LambdaAPI.GrantExport"
+        self.__add_request(method, params, lambda payload: TransferGrant.from_json(payload))
+
+    def export(self, grant: str, uid: str):
+        """
+        Export the app by token of grant (see GrantExport) instead of login token: manifest, aliases and content
+        """
+        params = [grant, uid, ]
+        method = "LambdaAPI.Export"
+        self.__add_request(method, params, lambda payload: LambdaExport.from_json(payload))
+
+    def freeze(self, token: Any, uid: str):
+        """
+        Freeze all files of the app, including dependencies installed by actions and files ignored by .cgiignore, to
+content-addressed artifact kept by server: freeze of the same files returns the same artifact. Old artifacts are
+pruned by retention of server
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.Freeze"
+        self.__add_request(method, params, lambda payload: FrozenArtifact.from_json(payload))
+
+    def frozen_artifacts(self, token: Any, uid: str):
+        """
+        Frozen artifacts of the app from the newest
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.FrozenArtifacts"
+        self.__add_request(method, params, lambda payload: [FrozenArtifact.from_json(x) for x in (payload or [])])
+
+    def download_frozen(self, token: Any, uid: str, id: str):
+        """
+        Download frozen artifact of the app (empty id - the newest) as .tar.gz archive. Unknown artifact is error with
+code 404
+        """
+        params = [token, uid, id, ]
+        method = "LambdaAPI.DownloadFrozen"
+        self.__add_request(method, params, lambda payload: decodebytes((payload or '').encode()))
+
+    def thaw(self, token: Any, uid: str, id: str):
+        """
+        Replace all files of the app by frozen artifact (empty id - the newest) without builds and network: manifest of
+artifact is applied. Unknown artifact is error with code 404
+        """
+        params = [token, uid, id, ]
+        method = "LambdaAPI.Thaw"
+        self.__add_request(method, params, lambda payload: FrozenArtifact.from_json(payload))
+
+    def upload_version(self, token: Any, uid: str, archive: bytes, message: str):
+        """
+        Upload content as Upload does and return version of the app annotated by message (upload of the same files
+returns the same version). Versions are also taken by every upload and update of manifest
+        """
+        params = [token, uid, encodebytes(archive), message, ]
+        method = "LambdaAPI.UploadVersion"
+        self.__add_request(method, params, lambda payload: LambdaVersion.from_json(payload))
+
+    def versions(self, token: Any, uid: str):
+        """
+        Versions of the app (files and manifest) from the newest. Old versions are pruned by retention of server
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.Versions"
+        self.__add_request(method, params, lambda payload: [LambdaVersion.from_json(x) for x in (payload or [])])
+
+    def rollback(self, token: Any, uid: str, rollback: VersionRollback):
+        """
+        Restore files and manifest of version of the app (ID or unique prefix) and run build action (empty - build if
+defined by Makefile of version) before files are replaced: failed build keeps the app. Unknown version is error
+with code 404
+        """
+        params = [token, uid, rollback.to_json(), ]
+        method = "LambdaAPI.Rollback"
+        self.__add_request(method, params, lambda payload: RollbackReport.from_json(payload))
+
+    def log_files(self, token: Any, uid: str):
+        """
+        Log files of the app (full stdout and stderr of invocations if logs are enabled by manifest) from the oldest
+        """
+        params = [token, uid, ]
+        method = "LambdaAPI.LogFiles"
+        self.__add_request(method, params, lambda payload: [LogFile.from_json(x) for x in (payload or [])])
+
+    def download_log(self, token: Any, uid: str, name: str):
+        """
+        Download log file of the app by name (see LogFiles). Unknown file is error with code 404
+        """
+        params = [token, uid, name, ]
+        method = "LambdaAPI.DownloadLog"
+        self.__add_request(method, params, lambda payload: decodebytes((payload or '').encode()))
+
+    def tail_log(self, token: Any, uid: str, lines: int):
+        """
+        The last lines (zero - 100) of current log file of the app
+        """
+        params = [token, uid, lines, ]
+        method = "LambdaAPI.TailLog"
+        self.__add_request(method, params, lambda payload: payload)
+
     def __add_request(self, method: str, params, factory):
         request_id = self.__next_id()
         request = {
@@ -31,6 +31,7 @@ class Policy:
 @dataclass
 class PolicyDefinition:
     allowed_ip: 'Optional[Any]'
+    allowed_networks: 'Optional[List[str]]'
     allowed_origin: 'Optional[Any]'
     public: 'bool'
     tokens: 'Optional[Any]'
@@ -38,6 +39,7 @@ class PolicyDefinition:
     def to_json(self) -> dict:
         return {
             "allowed_ip": self.allowed_ip,
+            "allowed_networks": self.allowed_networks,
             "allowed_origin": self.allowed_origin,
             "public": self.public,
             "tokens": self.tokens,
@@ -47,6 +49,7 @@ class PolicyDefinition:
     def from_json(payload: dict) -> 'PolicyDefinition':
         return PolicyDefinition(
                 allowed_ip=payload['allowed_ip'],
+                allowed_networks=payload['allowed_networks'] or [],
                 allowed_origin=payload['allowed_origin'],
                 public=payload['public'],
                 tokens=payload['tokens'],
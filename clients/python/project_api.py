@@ -2,7 +2,24 @@ from aiohttp import client
 
 from dataclasses import dataclass
 
+from enum import Enum
 from typing import Any, List, Optional
+from base64 import decodebytes, encodebytes
+
+
+class Duration(Enum):
+    MIN_DURATION = -1 << 63
+    MAX_DURATION = 1<<63 - 1
+    NANOSECOND = 1
+    MIN_DURATION = -1 << 63
+    MAX_DURATION = 1<<63 - 1
+
+    def to_json(self) -> int:
+        return self.value
+
+    @staticmethod
+    def from_json(payload: int) -> 'Duration':
+        return Duration(payload)
 
 
 
@@ -11,12 +28,18 @@ class Settings:
     user: 'str'
     public_key: 'Optional[str]'
     environment: 'Optional[Any]'
+    runtime: 'Runtime'
+    builds: 'BuildsConfig'
+    auto_slug: 'bool'
 
     def to_json(self) -> dict:
         return {
             "user": self.user,
             "public_key": self.public_key,
             "environment": self.environment,
+            "runtime": self.runtime.to_json(),
+            "builds": self.builds.to_json(),
+            "auto_slug": self.auto_slug,
         }
 
     @staticmethod
@@ -25,6 +48,103 @@ class Settings:
                 user=payload['user'],
                 public_key=payload['public_key'],
                 environment=payload['environment'],
+                runtime=Runtime.from_json(payload['runtime']),
+                builds=BuildsConfig.from_json(payload['builds']),
+                auto_slug=payload['auto_slug'],
+        )
+
+
+@dataclass
+class Runtime:
+    umask: 'Optional[str]'
+    lang: 'Optional[str]'
+    lc_all: 'Optional[str]'
+    tz: 'Optional[str]'
+    env_limits: 'Optional[EnvLimits]'
+
+    def to_json(self) -> dict:
+        return {
+            "umask": self.umask,
+            "lang": self.lang,
+            "lc_all": self.lc_all,
+            "tz": self.tz,
+            "env_limits": self.env_limits.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Runtime':
+        return Runtime(
+                umask=payload['umask'],
+                lang=payload['lang'],
+                lc_all=payload['lc_all'],
+                tz=payload['tz'],
+                env_limits=EnvLimits.from_json(payload['env_limits']),
+        )
+
+
+@dataclass
+class EnvLimits:
+    max_size: 'Optional[int]'
+    max_variable: 'Optional[int]'
+    oversized: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "max_size": self.max_size,
+            "max_variable": self.max_variable,
+            "oversized": self.oversized,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'EnvLimits':
+        return EnvLimits(
+                max_size=payload['max_size'],
+                max_variable=payload['max_variable'],
+                oversized=payload['oversized'],
+        )
+
+
+@dataclass
+class BuildsConfig:
+    limits: 'BuildLimits'
+    max_concurrent: 'Optional[int]'
+    cgroup: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "limits": self.limits.to_json(),
+            "max_concurrent": self.max_concurrent,
+            "cgroup": self.cgroup,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BuildsConfig':
+        return BuildsConfig(
+                limits=BuildLimits.from_json(payload['limits']),
+                max_concurrent=payload['max_concurrent'],
+                cgroup=payload['cgroup'],
+        )
+
+
+@dataclass
+class BuildLimits:
+    nice: 'Optional[int]'
+    cpu: 'Optional[float]'
+    memory: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "nice": self.nice,
+            "cpu": self.cpu,
+            "memory": self.memory,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BuildLimits':
+        return BuildLimits(
+                nice=payload['nice'],
+                cpu=payload['cpu'],
+                memory=payload['memory'],
         )
 
 
@@ -71,12 +191,36 @@ class Definition:
     uid: 'str'
     aliases: 'Any'
     manifest: 'Manifest'
+    modified: 'Optional[Any]'
+    schedules: 'Optional[List[ScheduleStatus]]'
+    queues: 'Optional[List[QueueStatus]]'
+    alerts: 'Optional[List[AlertStatus]]'
+    slug: 'Optional[str]'
+    slug_outdated: 'Optional[bool]'
+    warning: 'Optional[str]'
+    disabled: 'bool'
+    alias_overrides: 'Optional[Any]'
+    git: 'Optional[GitStatus]'
+    disk: 'Optional[DiskUsage]'
+    outputs: 'Optional[Any]'
 
     def to_json(self) -> dict:
         return {
             "uid": self.uid,
             "aliases": self.aliases,
             "manifest": self.manifest.to_json(),
+            "modified": self.modified,
+            "schedules": [x.to_json() for x in self.schedules],
+            "queues": [x.to_json() for x in self.queues],
+            "alerts": [x.to_json() for x in self.alerts],
+            "slug": self.slug,
+            "slug_outdated": self.slug_outdated,
+            "warning": self.warning,
+            "disabled": self.disabled,
+            "alias_overrides": self.alias_overrides,
+            "git": self.git.to_json(),
+            "disk": self.disk.to_json(),
+            "outputs": self.outputs,
         }
 
     @staticmethod
@@ -85,6 +229,18 @@ class Definition:
                 uid=payload['uid'],
                 aliases=payload['aliases'],
                 manifest=Manifest.from_json(payload['manifest']),
+                modified=payload['modified'],
+                schedules=[ScheduleStatus.from_json(x) for x in (payload['schedules'] or [])],
+                queues=[QueueStatus.from_json(x) for x in (payload['queues'] or [])],
+                alerts=[AlertStatus.from_json(x) for x in (payload['alerts'] or [])],
+                slug=payload['slug'],
+                slug_outdated=payload['slug_outdated'],
+                warning=payload['warning'],
+                disabled=payload['disabled'],
+                alias_overrides=payload['alias_overrides'],
+                git=GitStatus.from_json(payload['git']),
+                disk=DiskUsage.from_json(payload['disk']),
+                outputs=payload['outputs'],
         )
 
 
@@ -92,35 +248,183 @@ class Definition:
 class Manifest:
     name: 'Optional[str]'
     description: 'Optional[str]'
+    labels: 'Optional[List[str]]'
     run: 'List[str]'
     output_headers: 'Optional[Any]'
+    parse_headers: 'Optional[bool]'
     input_headers: 'Optional[Any]'
     query: 'Optional[Any]'
+    query_to_body: 'Optional[bool]'
     environment: 'Optional[Any]'
     method: 'Optional[str]'
     method_env: 'Optional[str]'
     path_env: 'Optional[str]'
+    public_url_env: 'Optional[str]'
+    alias_env: 'Optional[str]'
+    deadline_env: 'Optional[str]'
     time_limit: 'Optional[Any]'
     maximum_payload: 'Optional[int]'
     cron: 'Optional[List[Schedule]]'
+    actions: 'Optional[Any]'
+    aliases: 'Optional[List[str]]'
     static: 'Optional[str]'
+    umask: 'Optional[str]'
+    lang: 'Optional[str]'
+    lc_all: 'Optional[str]'
+    tz: 'Optional[str]'
+    sampling: 'Optional[Sampling]'
+    on_start: 'Optional[Startup]'
+    coalesce: 'Optional[Coalescing]'
+    cache: 'Optional[Caching]'
+    rate_limit: 'Optional[RateLimit]'
+    accepted_content_types: 'Optional[List[str]]'
+    allow_no_content_type: 'Optional[bool]'
+    rewrite_ur_lss: 'Optional[Rewrite]'
+    secrets: 'Optional[Secrets]'
+    alerts: 'Optional[List[Alert]]'
+    mutate: 'Optional[Mutation]'
+    on_success: 'Optional[Chaining]'
+    then: 'Optional[List[ChainStep]]'
+    methods: 'Optional[List[str]]'
+    build_limits: 'Optional[BuildLimits]'
+    limits: 'Optional[ResourceLimits]'
+    disk_quota_mb: 'Optional[int]'
+    build_gate: 'Optional[BuildGate]'
+    status_map: 'Optional[Any]'
+    static_dirs: 'Optional[Any]'
+    remove_headers: 'Optional[List[str]]'
+    maximum_response: 'Optional[int]'
+    truncate_policy: 'Optional[str]'
+    soft_limits: 'Optional[SoftLimits]'
+    work_dir: 'Optional[str]'
+    expand_run: 'Optional[bool]'
+    max_concurrency: 'Optional[int]'
+    overflow_policy: 'Optional[str]'
+    cors: 'Optional[CORS]'
+    input_schema: 'Optional[Any]'
+    network: 'Optional[bool]'
+    read_only: 'Optional[bool]'
+    run_as: 'Optional[RunAs]'
+    streaming: 'Optional[str]'
+    stream_time_limit: 'Optional[Any]'
+    stream_idle_timeout: 'Optional[Any]'
+    stream_reauthorize: 'Optional[Any]'
+    container: 'Optional[Container]'
+    mode: 'Optional[str]'
+    workers: 'Optional[int]'
+    grace_period: 'Optional[Any]'
+    payload_as_file: 'Optional[bool]'
+    maximum_file_payload: 'Optional[int]'
+    decode_multipart: 'Optional[bool]'
+    maximum_form_file: 'Optional[int]'
+    decode_form: 'Optional[bool]'
+    raw_form_env: 'Optional[str]'
+    disable_compression: 'Optional[bool]'
+    queue_serial: 'Optional[bool]'
+    retry: 'Optional[Retry]'
+    check: 'Optional[List[List[str]]]'
+    warm_up: 'Optional[bool]'
+    warm_up_marker: 'Optional[str]'
+    capture: 'Optional[Capture]'
+    logs: 'Optional[LogFiles]'
+    allowed_networks: 'Optional[List[str]]'
+    webhook_signature: 'Optional[WebhookSignature]'
+    basic_auth: 'Optional[BasicAuth]'
+    jwt: 'Optional[JWTPolicy]'
+    alias_policies: 'Optional[Any]'
+    event_webhooks: 'Optional[List[LifecycleWebhook]]'
+    git: 'Optional[GitSource]'
 
     def to_json(self) -> dict:
         return {
             "name": self.name,
             "description": self.description,
+            "labels": self.labels,
             "run": self.run,
             "output_headers": self.output_headers,
+            "parse_headers": self.parse_headers,
             "input_headers": self.input_headers,
             "query": self.query,
+            "query_to_body": self.query_to_body,
             "environment": self.environment,
             "method": self.method,
             "method_env": self.method_env,
             "path_env": self.path_env,
+            "public_url_env": self.public_url_env,
+            "alias_env": self.alias_env,
+            "deadline_env": self.deadline_env,
             "time_limit": self.time_limit,
             "maximum_payload": self.maximum_payload,
             "cron": [x.to_json() for x in self.cron],
+            "actions": self.actions,
+            "aliases": self.aliases,
             "static": self.static,
+            "umask": self.umask,
+            "lang": self.lang,
+            "lc_all": self.lc_all,
+            "tz": self.tz,
+            "sampling": self.sampling.to_json(),
+            "on_start": self.on_start.to_json(),
+            "coalesce": self.coalesce.to_json(),
+            "cache": self.cache.to_json(),
+            "rate_limit": self.rate_limit.to_json(),
+            "accepted_content_types": self.accepted_content_types,
+            "allow_no_content_type": self.allow_no_content_type,
+            "rewrite_urls": self.rewrite_ur_lss.to_json(),
+            "secrets": self.secrets.to_json(),
+            "alerts": [x.to_json() for x in self.alerts],
+            "mutate": self.mutate.to_json(),
+            "on_success": self.on_success.to_json(),
+            "then": [x.to_json() for x in self.then],
+            "methods": self.methods,
+            "build_limits": self.build_limits.to_json(),
+            "limits": self.limits.to_json(),
+            "disk_quota_mb": self.disk_quota_mb,
+            "build_gate": self.build_gate.to_json(),
+            "status_map": self.status_map,
+            "static_dirs": self.static_dirs,
+            "remove_headers": self.remove_headers,
+            "maximum_response": self.maximum_response,
+            "truncate_policy": self.truncate_policy,
+            "soft_limits": self.soft_limits.to_json(),
+            "work_dir": self.work_dir,
+            "expand_run": self.expand_run,
+            "max_concurrency": self.max_concurrency,
+            "overflow_policy": self.overflow_policy,
+            "cors": self.cors.to_json(),
+            "input_schema": self.input_schema,
+            "network": self.network,
+            "read_only": self.read_only,
+            "run_as": self.run_as.to_json(),
+            "streaming": self.streaming,
+            "stream_time_limit": self.stream_time_limit,
+            "stream_idle_timeout": self.stream_idle_timeout,
+            "stream_reauthorize": self.stream_reauthorize,
+            "container": self.container.to_json(),
+            "mode": self.mode,
+            "workers": self.workers,
+            "grace_period": self.grace_period,
+            "payload_as_file": self.payload_as_file,
+            "maximum_file_payload": self.maximum_file_payload,
+            "decode_multipart": self.decode_multipart,
+            "maximum_form_file": self.maximum_form_file,
+            "decode_form": self.decode_form,
+            "raw_form_env": self.raw_form_env,
+            "disable_compression": self.disable_compression,
+            "queue_serial": self.queue_serial,
+            "retry": self.retry.to_json(),
+            "check": self.check,
+            "warm_up": self.warm_up,
+            "warm_up_marker": self.warm_up_marker,
+            "capture": self.capture.to_json(),
+            "logs": self.logs.to_json(),
+            "allowed_networks": self.allowed_networks,
+            "webhook_signature": self.webhook_signature.to_json(),
+            "basic_auth": self.basic_auth.to_json(),
+            "jwt": self.jwt.to_json(),
+            "alias_policies": self.alias_policies,
+            "event_webhooks": [x.to_json() for x in self.event_webhooks],
+            "git": self.git.to_json(),
         }
 
     @staticmethod
@@ -128,311 +432,3337 @@ class Manifest:
         return Manifest(
                 name=payload['name'],
                 description=payload['description'],
+                labels=payload['labels'] or [],
                 run=payload['run'] or [],
                 output_headers=payload['output_headers'],
+                parse_headers=payload['parse_headers'],
                 input_headers=payload['input_headers'],
                 query=payload['query'],
+                query_to_body=payload['query_to_body'],
                 environment=payload['environment'],
                 method=payload['method'],
                 method_env=payload['method_env'],
                 path_env=payload['path_env'],
+                public_url_env=payload['public_url_env'],
+                alias_env=payload['alias_env'],
+                deadline_env=payload['deadline_env'],
                 time_limit=payload['time_limit'],
                 maximum_payload=payload['maximum_payload'],
                 cron=[Schedule.from_json(x) for x in (payload['cron'] or [])],
+                actions=payload['actions'],
+                aliases=payload['aliases'] or [],
                 static=payload['static'],
+                umask=payload['umask'],
+                lang=payload['lang'],
+                lc_all=payload['lc_all'],
+                tz=payload['tz'],
+                sampling=Sampling.from_json(payload['sampling']),
+                on_start=Startup.from_json(payload['on_start']),
+                coalesce=Coalescing.from_json(payload['coalesce']),
+                cache=Caching.from_json(payload['cache']),
+                rate_limit=RateLimit.from_json(payload['rate_limit']),
+                accepted_content_types=payload['accepted_content_types'] or [],
+                allow_no_content_type=payload['allow_no_content_type'],
+                rewrite_ur_lss=Rewrite.from_json(payload['rewrite_urls']),
+                secrets=Secrets.from_json(payload['secrets']),
+                alerts=[Alert.from_json(x) for x in (payload['alerts'] or [])],
+                mutate=Mutation.from_json(payload['mutate']),
+                on_success=Chaining.from_json(payload['on_success']),
+                then=[ChainStep.from_json(x) for x in (payload['then'] or [])],
+                methods=payload['methods'] or [],
+                build_limits=BuildLimits.from_json(payload['build_limits']),
+                limits=ResourceLimits.from_json(payload['limits']),
+                disk_quota_mb=payload['disk_quota_mb'],
+                build_gate=BuildGate.from_json(payload['build_gate']),
+                status_map=payload['status_map'],
+                static_dirs=payload['static_dirs'],
+                remove_headers=payload['remove_headers'] or [],
+                maximum_response=payload['maximum_response'],
+                truncate_policy=payload['truncate_policy'],
+                soft_limits=SoftLimits.from_json(payload['soft_limits']),
+                work_dir=payload['work_dir'],
+                expand_run=payload['expand_run'],
+                max_concurrency=payload['max_concurrency'],
+                overflow_policy=payload['overflow_policy'],
+                cors=CORS.from_json(payload['cors']),
+                input_schema=payload['input_schema'],
+                network=payload['network'],
+                read_only=payload['read_only'],
+                run_as=RunAs.from_json(payload['run_as']),
+                streaming=payload['streaming'],
+                stream_time_limit=payload['stream_time_limit'],
+                stream_idle_timeout=payload['stream_idle_timeout'],
+                stream_reauthorize=payload['stream_reauthorize'],
+                container=Container.from_json(payload['container']),
+                mode=payload['mode'],
+                workers=payload['workers'],
+                grace_period=payload['grace_period'],
+                payload_as_file=payload['payload_as_file'],
+                maximum_file_payload=payload['maximum_file_payload'],
+                decode_multipart=payload['decode_multipart'],
+                maximum_form_file=payload['maximum_form_file'],
+                decode_form=payload['decode_form'],
+                raw_form_env=payload['raw_form_env'],
+                disable_compression=payload['disable_compression'],
+                queue_serial=payload['queue_serial'],
+                retry=Retry.from_json(payload['retry']),
+                check=payload['check'] or [],
+                warm_up=payload['warm_up'],
+                warm_up_marker=payload['warm_up_marker'],
+                capture=Capture.from_json(payload['capture']),
+                logs=LogFiles.from_json(payload['logs']),
+                allowed_networks=payload['allowed_networks'] or [],
+                webhook_signature=WebhookSignature.from_json(payload['webhook_signature']),
+                basic_auth=BasicAuth.from_json(payload['basic_auth']),
+                jwt=JWTPolicy.from_json(payload['jwt']),
+                alias_policies=payload['alias_policies'],
+                event_webhooks=[LifecycleWebhook.from_json(x) for x in (payload['event_webhooks'] or [])],
+                git=GitSource.from_json(payload['git']),
         )
 
 
 @dataclass
 class Schedule:
+    name: 'Optional[str]'
     cron: 'str'
-    action: 'str'
+    action: 'Optional[str]'
+    payload: 'Optional[str]'
+    payload_file: 'Optional[str]'
     time_limit: 'Any'
+    disabled: 'Optional[bool]'
+    missed: 'Optional[str]'
+    tz: 'Optional[str]'
 
     def to_json(self) -> dict:
         return {
+            "name": self.name,
             "cron": self.cron,
             "action": self.action,
+            "payload": self.payload,
+            "payload_file": self.payload_file,
             "time_limit": self.time_limit,
+            "disabled": self.disabled,
+            "missed": self.missed,
+            "tz": self.tz,
         }
 
     @staticmethod
     def from_json(payload: dict) -> 'Schedule':
         return Schedule(
+                name=payload['name'],
                 cron=payload['cron'],
                 action=payload['action'],
+                payload=payload['payload'],
+                payload_file=payload['payload_file'],
                 time_limit=payload['time_limit'],
+                disabled=payload['disabled'],
+                missed=payload['missed'],
+                tz=payload['tz'],
         )
 
 
 @dataclass
-class Template:
+class Sampling:
+    rate: 'Optional[int]'
+    slow: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "rate": self.rate,
+            "slow": self.slow,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Sampling':
+        return Sampling(
+                rate=payload['rate'],
+                slow=payload['slow'],
+        )
+
+
+@dataclass
+class Startup:
+    action: 'str'
+    time_limit: 'Optional[Any]'
+    blocking: 'Optional[bool]'
+    on_failure: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "action": self.action,
+            "time_limit": self.time_limit,
+            "blocking": self.blocking,
+            "on_failure": self.on_failure,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Startup':
+        return Startup(
+                action=payload['action'],
+                time_limit=payload['time_limit'],
+                blocking=payload['blocking'],
+                on_failure=payload['on_failure'],
+        )
+
+
+@dataclass
+class Coalescing:
+    max_waiters: 'Optional[int]'
+    headers: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "max_waiters": self.max_waiters,
+            "headers": self.headers,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Coalescing':
+        return Coalescing(
+                max_waiters=payload['max_waiters'],
+                headers=payload['headers'] or [],
+        )
+
+
+@dataclass
+class Caching:
+    ttl: 'Any'
+    max_entries: 'Optional[int]'
+    vary: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "ttl": self.ttl,
+            "max_entries": self.max_entries,
+            "vary": self.vary,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Caching':
+        return Caching(
+                ttl=payload['ttl'],
+                max_entries=payload['max_entries'],
+                vary=payload['vary'] or [],
+        )
+
+
+@dataclass
+class RateLimit:
+    rps: 'float'
+    burst: 'Optional[int]'
+    per_client: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "rps": self.rps,
+            "burst": self.burst,
+            "per_client": self.per_client,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RateLimit':
+        return RateLimit(
+                rps=payload['rps'],
+                burst=payload['burst'],
+                per_client=payload['per_client'],
+        )
+
+
+@dataclass
+class Rewrite:
+    prefix: 'str'
+    max_size: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "prefix": self.prefix,
+            "max_size": self.max_size,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Rewrite':
+        return Rewrite(
+                prefix=payload['prefix'],
+                max_size=payload['max_size'],
+        )
+
+
+@dataclass
+class Secrets:
+    delivery: 'Optional[str]'
+    keys: 'Optional[List[str]]'
+    env: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "delivery": self.delivery,
+            "keys": self.keys,
+            "env": self.env,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Secrets':
+        return Secrets(
+                delivery=payload['delivery'],
+                keys=payload['keys'] or [],
+                env=payload['env'],
+        )
+
+
+@dataclass
+class Alert:
     name: 'str'
-    description: 'str'
+    error_rate: 'Optional[float]'
+    consecutive_failures: 'Optional[int]'
+    latency_p95: 'Optional[Any]'
+    window: 'Optional[Any]'
+    min_calls: 'Optional[int]'
+    action: 'Optional[str]'
+    cool_off: 'Optional[Any]'
+    notify: 'Optional[str]'
 
     def to_json(self) -> dict:
         return {
             "name": self.name,
-            "description": self.description,
+            "error_rate": self.error_rate,
+            "consecutive_failures": self.consecutive_failures,
+            "latency_p95": self.latency_p95,
+            "window": self.window,
+            "min_calls": self.min_calls,
+            "action": self.action,
+            "cool_off": self.cool_off,
+            "notify": self.notify,
         }
 
     @staticmethod
-    def from_json(payload: dict) -> 'Template':
-        return Template(
+    def from_json(payload: dict) -> 'Alert':
+        return Alert(
                 name=payload['name'],
-                description=payload['description'],
+                error_rate=payload['error_rate'],
+                consecutive_failures=payload['consecutive_failures'],
+                latency_p95=payload['latency_p95'],
+                window=payload['window'],
+                min_calls=payload['min_calls'],
+                action=payload['action'],
+                cool_off=payload['cool_off'],
+                notify=payload['notify'],
         )
 
 
 @dataclass
-class Record:
-    uid: 'str'
-    err: 'Optional[str]'
-    request: 'Request'
-    begin: 'Any'
-    end: 'Any'
+class Mutation:
+    headers: 'Optional[Any]'
+    body_defaults: 'Optional[Any]'
 
     def to_json(self) -> dict:
         return {
-            "uid": self.uid,
-            "error": self.err,
-            "request": self.request.to_json(),
-            "begin": self.begin,
-            "end": self.end,
+            "headers": self.headers,
+            "body_defaults": self.body_defaults,
         }
 
     @staticmethod
-    def from_json(payload: dict) -> 'Record':
-        return Record(
-                uid=payload['uid'],
-                err=payload['error'],
-                request=Request.from_json(payload['request']),
-                begin=payload['begin'],
-                end=payload['end'],
+    def from_json(payload: dict) -> 'Mutation':
+        return Mutation(
+                headers=payload['headers'],
+                body_defaults=payload['body_defaults'],
         )
 
 
 @dataclass
-class Request:
-    method: 'str'
-    url: 'str'
-    path: 'str'
-    remote_address: 'str'
-    form: 'Any'
-    headers: 'Any'
+class Chaining:
+    queue: 'str'
+    transform: 'Optional[str]'
+    max_size: 'Optional[int]'
+    max_hops: 'Optional[int]'
 
     def to_json(self) -> dict:
         return {
-            "method": self.method,
-            "url": self.url,
-            "path": self.path,
-            "remote_address": self.remote_address,
-            "form": self.form,
+            "queue": self.queue,
+            "transform": self.transform,
+            "max_size": self.max_size,
+            "max_hops": self.max_hops,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Chaining':
+        return Chaining(
+                queue=payload['queue'],
+                transform=payload['transform'],
+                max_size=payload['max_size'],
+                max_hops=payload['max_hops'],
+        )
+
+
+@dataclass
+class ChainStep:
+    _lambda: 'str'
+    condition: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "lambda": self._lambda,
+            "condition": self.condition,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ChainStep':
+        return ChainStep(
+                _lambda=payload['lambda'],
+                condition=payload['condition'],
+        )
+
+
+@dataclass
+class ResourceLimits:
+    memory_mb: 'Optional[int]'
+    cpu_percent: 'Optional[int]'
+    open_files: 'Optional[int]'
+    processes: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "memory_mb": self.memory_mb,
+            "cpu_percent": self.cpu_percent,
+            "open_files": self.open_files,
+            "processes": self.processes,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ResourceLimits':
+        return ResourceLimits(
+                memory_mb=payload['memory_mb'],
+                cpu_percent=payload['cpu_percent'],
+                open_files=payload['open_files'],
+                processes=payload['processes'],
+        )
+
+
+@dataclass
+class BuildGate:
+    actions: 'Optional[List[str]]'
+    timeout: 'Optional[Any]'
+    disabled: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "actions": self.actions,
+            "timeout": self.timeout,
+            "disabled": self.disabled,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BuildGate':
+        return BuildGate(
+                actions=payload['actions'] or [],
+                timeout=payload['timeout'],
+                disabled=payload['disabled'],
+        )
+
+
+@dataclass
+class SoftLimits:
+    payload: 'Optional[int]'
+    response: 'Optional[int]'
+    notify: 'Optional[str]'
+    interval: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "payload": self.payload,
+            "response": self.response,
+            "notify": self.notify,
+            "interval": self.interval,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SoftLimits':
+        return SoftLimits(
+                payload=payload['payload'],
+                response=payload['response'],
+                notify=payload['notify'],
+                interval=payload['interval'],
+        )
+
+
+@dataclass
+class CORS:
+    origins: 'Optional[List[str]]'
+    methods: 'Optional[List[str]]'
+    headers: 'Optional[List[str]]'
+    credentials: 'Optional[bool]'
+    max_age: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "origins": self.origins,
+            "methods": self.methods,
             "headers": self.headers,
+            "credentials": self.credentials,
+            "max_age": self.max_age,
         }
 
     @staticmethod
-    def from_json(payload: dict) -> 'Request':
-        return Request(
-                method=payload['method'],
-                url=payload['url'],
-                path=payload['path'],
-                remote_address=payload['remote_address'],
-                form=payload['form'],
-                headers=payload['headers'],
+    def from_json(payload: dict) -> 'CORS':
+        return CORS(
+                origins=payload['origins'] or [],
+                methods=payload['methods'] or [],
+                headers=payload['headers'] or [],
+                credentials=payload['credentials'],
+                max_age=payload['max_age'],
+        )
+
+
+@dataclass
+class RunAs:
+    user: 'str'
+    group: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "user": self.user,
+            "group": self.group,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RunAs':
+        return RunAs(
+                user=payload['user'],
+                group=payload['group'],
+        )
+
+
+@dataclass
+class Container:
+    image: 'str'
+    entrypoint: 'Optional[List[str]]'
+    mounts: 'Optional[List[Mount]]'
+    network: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "image": self.image,
+            "entrypoint": self.entrypoint,
+            "mounts": [x.to_json() for x in self.mounts],
+            "network": self.network,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Container':
+        return Container(
+                image=payload['image'],
+                entrypoint=payload['entrypoint'] or [],
+                mounts=[Mount.from_json(x) for x in (payload['mounts'] or [])],
+                network=payload['network'],
         )
 
 
-class ProjectAPIError(RuntimeError):
-    def __init__(self, method: str, code: int, message: str, data: Any):
-        super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
-        self.code = code
-        self.message = message
-        self.data = data
+@dataclass
+class Mount:
+    source: 'str'
+    target: 'str'
+    writable: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "source": self.source,
+            "target": self.target,
+            "writable": self.writable,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Mount':
+        return Mount(
+                source=payload['source'],
+                target=payload['target'],
+                writable=payload['writable'],
+        )
+
+
+@dataclass
+class Retry:
+    attempts: 'int'
+    backoff: 'Optional[Any]'
+    max_backoff: 'Optional[Any]'
+    jitter: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "attempts": self.attempts,
+            "backoff": self.backoff,
+            "max_backoff": self.max_backoff,
+            "jitter": self.jitter,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Retry':
+        return Retry(
+                attempts=payload['attempts'],
+                backoff=payload['backoff'],
+                max_backoff=payload['max_backoff'],
+                jitter=payload['jitter'],
+        )
+
+
+@dataclass
+class Capture:
+    requests: 'int'
+    max_bytes: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "requests": self.requests,
+            "max_bytes": self.max_bytes,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Capture':
+        return Capture(
+                requests=payload['requests'],
+                max_bytes=payload['max_bytes'],
+        )
+
+
+@dataclass
+class LogFiles:
+    enabled: 'bool'
+    max_size_mb: 'Optional[int]'
+    max_files: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "enabled": self.enabled,
+            "max_size_mb": self.max_size_mb,
+            "max_files": self.max_files,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LogFiles':
+        return LogFiles(
+                enabled=payload['enabled'],
+                max_size_mb=payload['max_size_mb'],
+                max_files=payload['max_files'],
+        )
+
+
+@dataclass
+class WebhookSignature:
+    preset: 'Optional[str]'
+    header: 'Optional[str]'
+    algorithm: 'Optional[str]'
+    secret: 'str'
+    encoding: 'Optional[str]'
+    prefix: 'Optional[str]'
+    tolerance: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "preset": self.preset,
+            "header": self.header,
+            "algorithm": self.algorithm,
+            "secret": self.secret,
+            "encoding": self.encoding,
+            "prefix": self.prefix,
+            "tolerance": self.tolerance,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'WebhookSignature':
+        return WebhookSignature(
+                preset=payload['preset'],
+                header=payload['header'],
+                algorithm=payload['algorithm'],
+                secret=payload['secret'],
+                encoding=payload['encoding'],
+                prefix=payload['prefix'],
+                tolerance=payload['tolerance'],
+        )
+
+
+@dataclass
+class BasicAuth:
+    users: 'Any'
+    realm: 'Optional[str]'
+    links: 'Optional[List[str]]'
+    env: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "users": self.users,
+            "realm": self.realm,
+            "links": self.links,
+            "env": self.env,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BasicAuth':
+        return BasicAuth(
+                users=payload['users'],
+                realm=payload['realm'],
+                links=payload['links'] or [],
+                env=payload['env'],
+        )
+
+
+@dataclass
+class JWTPolicy:
+    issuers: 'Optional[List[str]]'
+    audiences: 'Optional[List[str]]'
+    secret: 'Optional[str]'
+    public_key: 'Optional[str]'
+    jwks: 'Optional[str]'
+    refresh: 'Optional[Any]'
+    leeway: 'Optional[Any]'
+    links: 'Optional[List[str]]'
+    subject_env: 'Optional[str]'
+    env: 'Optional[Any]'
+    headers: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "issuers": self.issuers,
+            "audiences": self.audiences,
+            "secret": self.secret,
+            "public_key": self.public_key,
+            "jwks": self.jwks,
+            "refresh": self.refresh,
+            "leeway": self.leeway,
+            "links": self.links,
+            "subject_env": self.subject_env,
+            "env": self.env,
+            "headers": self.headers,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'JWTPolicy':
+        return JWTPolicy(
+                issuers=payload['issuers'] or [],
+                audiences=payload['audiences'] or [],
+                secret=payload['secret'],
+                public_key=payload['public_key'],
+                jwks=payload['jwks'],
+                refresh=payload['refresh'],
+                leeway=payload['leeway'],
+                links=payload['links'] or [],
+                subject_env=payload['subject_env'],
+                env=payload['env'],
+                headers=payload['headers'],
+        )
+
+
+@dataclass
+class LifecycleWebhook:
+    url: 'str'
+    events: 'Optional[List[str]]'
+    secret: 'Optional[str]'
+    payload: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "url": self.url,
+            "events": self.events,
+            "secret": self.secret,
+            "payload": self.payload,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LifecycleWebhook':
+        return LifecycleWebhook(
+                url=payload['url'],
+                events=payload['events'] or [],
+                secret=payload['secret'],
+                payload=payload['payload'],
+        )
+
+
+@dataclass
+class GitSource:
+    repo: 'str'
+    branch: 'Optional[str]'
+    dir: 'Optional[str]'
+    interval: 'Optional[Any]'
+    action: 'Optional[str]'
+    key: 'Optional[str]'
+    ping: 'Optional[WebhookSignature]'
+
+    def to_json(self) -> dict:
+        return {
+            "repo": self.repo,
+            "branch": self.branch,
+            "dir": self.dir,
+            "interval": self.interval,
+            "action": self.action,
+            "key": self.key,
+            "ping": self.ping.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'GitSource':
+        return GitSource(
+                repo=payload['repo'],
+                branch=payload['branch'],
+                dir=payload['dir'],
+                interval=payload['interval'],
+                action=payload['action'],
+                key=payload['key'],
+                ping=WebhookSignature.from_json(payload['ping']),
+        )
+
+
+@dataclass
+class ScheduleStatus:
+    name: 'Optional[str]'
+    cron: 'str'
+    tz: 'Optional[str]'
+    action: 'str'
+    enabled: 'bool'
+    next: 'Optional[Any]'
+    last_run: 'Optional[Any]'
+    last_result: 'Optional[str]'
+    last_error: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "cron": self.cron,
+            "tz": self.tz,
+            "action": self.action,
+            "enabled": self.enabled,
+            "next": self.next,
+            "last_run": self.last_run,
+            "last_result": self.last_result,
+            "last_error": self.last_error,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ScheduleStatus':
+        return ScheduleStatus(
+                name=payload['name'],
+                cron=payload['cron'],
+                tz=payload['tz'],
+                action=payload['action'],
+                enabled=payload['enabled'],
+                next=payload['next'],
+                last_run=payload['last_run'],
+                last_result=payload['last_result'],
+                last_error=payload['last_error'],
+        )
+
+
+@dataclass
+class QueueStatus:
+    name: 'str'
+    depth: 'int'
+    in_flight: 'int'
+    paused: 'bool'
+    oldest: 'Optional[Any]'
+    oldest_age_seconds: 'float'
+    quarantined: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "depth": self.depth,
+            "in_flight": self.in_flight,
+            "paused": self.paused,
+            "oldest": self.oldest,
+            "oldest_age_seconds": self.oldest_age_seconds,
+            "quarantined": self.quarantined,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'QueueStatus':
+        return QueueStatus(
+                name=payload['name'],
+                depth=payload['depth'],
+                in_flight=payload['in_flight'],
+                paused=payload['paused'],
+                oldest=payload['oldest'],
+                oldest_age_seconds=payload['oldest_age_seconds'],
+                quarantined=payload['quarantined'],
+        )
+
+
+@dataclass
+class AlertStatus:
+    name: 'str'
+    action: 'str'
+    state: 'str'
+    since: 'Optional[Any]'
+    reason: 'Optional[str]'
+    until: 'Optional[Any]'
+    events: 'List[AlertEvent]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "action": self.action,
+            "state": self.state,
+            "since": self.since,
+            "reason": self.reason,
+            "until": self.until,
+            "events": [x.to_json() for x in self.events],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'AlertStatus':
+        return AlertStatus(
+                name=payload['name'],
+                action=payload['action'],
+                state=payload['state'],
+                since=payload['since'],
+                reason=payload['reason'],
+                until=payload['until'],
+                events=[AlertEvent.from_json(x) for x in (payload['events'] or [])],
+        )
+
+
+@dataclass
+class AlertEvent:
+    time: 'Any'
+    _from: 'str'
+    to: 'str'
+    reason: 'Optional[str]'
+    by: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "time": self.time,
+            "from": self._from,
+            "to": self.to,
+            "reason": self.reason,
+            "by": self.by,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'AlertEvent':
+        return AlertEvent(
+                time=payload['time'],
+                _from=payload['from'],
+                to=payload['to'],
+                reason=payload['reason'],
+                by=payload['by'],
+        )
+
+
+@dataclass
+class GitStatus:
+    repo: 'str'
+    branch: 'Optional[str]'
+    commit: 'Optional[str]'
+    deployed: 'Optional[Any]'
+    checked: 'Optional[Any]'
+    failed: 'Optional[str]'
+    error: 'Optional[str]'
+    output: 'Optional[str]'
+    running: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "repo": self.repo,
+            "branch": self.branch,
+            "commit": self.commit,
+            "deployed": self.deployed,
+            "checked": self.checked,
+            "failed": self.failed,
+            "error": self.error,
+            "output": self.output,
+            "running": self.running,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'GitStatus':
+        return GitStatus(
+                repo=payload['repo'],
+                branch=payload['branch'],
+                commit=payload['commit'],
+                deployed=payload['deployed'],
+                checked=payload['checked'],
+                failed=payload['failed'],
+                error=payload['error'],
+                output=payload['output'],
+                running=payload['running'],
+        )
+
+
+@dataclass
+class DiskUsage:
+    size: 'int'
+    quota: 'Optional[int]'
+    measured: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "size": self.size,
+            "quota": self.quota,
+            "measured": self.measured,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'DiskUsage':
+        return DiskUsage(
+                size=payload['size'],
+                quota=payload['quota'],
+                measured=payload['measured'],
+        )
+
+
+@dataclass
+class Template:
+    name: 'str'
+    description: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "description": self.description,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Template':
+        return Template(
+                name=payload['name'],
+                description=payload['description'],
+        )
+
+
+@dataclass
+class Record:
+    uid: 'str'
+    err: 'Optional[str]'
+    request: 'Request'
+    begin: 'Any'
+    end: 'Any'
+    rate: 'Optional[int]'
+    coalesced: 'Optional[bool]'
+    cached: 'Optional[bool]'
+    rejected: 'Optional[bool]'
+    throttled: 'Optional[bool]'
+    status: 'Optional[int]'
+    payload: 'Optional[int]'
+    size: 'Optional[int]'
+    cpu: 'Optional[Duration]'
+    max_rss: 'Optional[int]'
+    warning: 'Optional[str]'
+    limits: 'Optional[List[str]]'
+    wait: 'Optional[Duration]'
+    overrun: 'Optional[bool]'
+    output: 'Optional[bytes]'
+    stderr: 'Optional[bytes]'
+    queue: 'Optional[str]'
+    queue_wait: 'Optional[Duration]'
+    attempt: 'Optional[int]'
+    retried: 'Optional[bool]'
+    catch_up: 'Optional[bool]'
+    denied: 'Optional[bool]'
+    timeout: 'Optional[bool]'
+    trigger: 'Optional[str]'
+    spawn: 'Optional[Duration]'
+    first_byte: 'Optional[Duration]'
+    runtime: 'Optional[Duration]'
+    write: 'Optional[Duration]'
+    exit_code: 'Optional[int]'
+    signal: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "error": self.err,
+            "request": self.request.to_json(),
+            "begin": self.begin,
+            "end": self.end,
+            "rate": self.rate,
+            "coalesced": self.coalesced,
+            "cached": self.cached,
+            "rejected": self.rejected,
+            "throttled": self.throttled,
+            "status": self.status,
+            "payload": self.payload,
+            "size": self.size,
+            "cpu": self.cpu.to_json(),
+            "max_rss": self.max_rss,
+            "warning": self.warning,
+            "limits": self.limits,
+            "wait": self.wait.to_json(),
+            "overrun": self.overrun,
+            "output": encodebytes(self.output),
+            "stderr": encodebytes(self.stderr),
+            "queue": self.queue,
+            "queue_wait": self.queue_wait.to_json(),
+            "attempt": self.attempt,
+            "retried": self.retried,
+            "catch_up": self.catch_up,
+            "denied": self.denied,
+            "timeout": self.timeout,
+            "trigger": self.trigger,
+            "spawn": self.spawn.to_json(),
+            "first_byte": self.first_byte.to_json(),
+            "runtime": self.runtime.to_json(),
+            "write": self.write.to_json(),
+            "exit_code": self.exit_code,
+            "signal": self.signal,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Record':
+        return Record(
+                uid=payload['uid'],
+                err=payload['error'],
+                request=Request.from_json(payload['request']),
+                begin=payload['begin'],
+                end=payload['end'],
+                rate=payload['rate'],
+                coalesced=payload['coalesced'],
+                cached=payload['cached'],
+                rejected=payload['rejected'],
+                throttled=payload['throttled'],
+                status=payload['status'],
+                payload=payload['payload'],
+                size=payload['size'],
+                cpu=Duration.from_json(payload['cpu']),
+                max_rss=payload['max_rss'],
+                warning=payload['warning'],
+                limits=payload['limits'] or [],
+                wait=Duration.from_json(payload['wait']),
+                overrun=payload['overrun'],
+                output=decodebytes((payload['output'] or '').encode()),
+                stderr=decodebytes((payload['stderr'] or '').encode()),
+                queue=payload['queue'],
+                queue_wait=Duration.from_json(payload['queue_wait']),
+                attempt=payload['attempt'],
+                retried=payload['retried'],
+                catch_up=payload['catch_up'],
+                denied=payload['denied'],
+                timeout=payload['timeout'],
+                trigger=payload['trigger'],
+                spawn=Duration.from_json(payload['spawn']),
+                first_byte=Duration.from_json(payload['first_byte']),
+                runtime=Duration.from_json(payload['runtime']),
+                write=Duration.from_json(payload['write']),
+                exit_code=payload['exit_code'],
+                signal=payload['signal'],
+        )
+
+
+@dataclass
+class Request:
+    id: 'Optional[str]'
+    method: 'str'
+    url: 'str'
+    path: 'str'
+    remote_address: 'str'
+    form: 'Any'
+    headers: 'Any'
+    public_url: 'Optional[str]'
+    alias: 'Optional[str]'
+    chain: 'Optional[List[str]]'
+    queued: 'Optional[Any]'
+    user: 'Optional[str]'
+    claims: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "method": self.method,
+            "url": self.url,
+            "path": self.path,
+            "remote_address": self.remote_address,
+            "form": self.form,
+            "headers": self.headers,
+            "public_url": self.public_url,
+            "alias": self.alias,
+            "chain": self.chain,
+            "queued": self.queued,
+            "user": self.user,
+            "claims": self.claims,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Request':
+        return Request(
+                id=payload['id'],
+                method=payload['method'],
+                url=payload['url'],
+                path=payload['path'],
+                remote_address=payload['remote_address'],
+                form=payload['form'],
+                headers=payload['headers'],
+                public_url=payload['public_url'],
+                alias=payload['alias'],
+                chain=payload['chain'] or [],
+                queued=payload['queued'],
+                user=payload['user'],
+                claims=payload['claims'],
+        )
+
+
+@dataclass
+class Query:
+    uid: 'Optional[str]'
+    since: 'Optional[Any]'
+    until: 'Optional[Any]'
+    limit: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "since": self.since,
+            "until": self.until,
+            "limit": self.limit,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Query':
+        return Query(
+                uid=payload['uid'],
+                since=payload['since'],
+                until=payload['until'],
+                limit=payload['limit'],
+        )
+
+
+@dataclass
+class Aggregation:
+    uid: 'Optional[str]'
+    since: 'Any'
+    until: 'Any'
+    bucket: 'Duration'
+    buckets: 'List[Bucket]'
+    total: 'Bucket'
+    group: 'Optional[str]'
+    groups: 'Optional[List[Group]]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "since": self.since,
+            "until": self.until,
+            "bucket": self.bucket.to_json(),
+            "buckets": [x.to_json() for x in self.buckets],
+            "total": self.total.to_json(),
+            "group": self.group,
+            "groups": [x.to_json() for x in self.groups],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Aggregation':
+        return Aggregation(
+                uid=payload['uid'],
+                since=payload['since'],
+                until=payload['until'],
+                bucket=Duration.from_json(payload['bucket']),
+                buckets=[Bucket.from_json(x) for x in (payload['buckets'] or [])],
+                total=Bucket.from_json(payload['total']),
+                group=payload['group'],
+                groups=[Group.from_json(x) for x in (payload['groups'] or [])],
+        )
+
+
+@dataclass
+class Bucket:
+    begin: 'Any'
+    calls: 'int'
+    errors: 'Errors'
+    latency: 'Latency'
+
+    def to_json(self) -> dict:
+        return {
+            "begin": self.begin,
+            "calls": self.calls,
+            "errors": self.errors.to_json(),
+            "latency": self.latency.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Bucket':
+        return Bucket(
+                begin=payload['begin'],
+                calls=payload['calls'],
+                errors=Errors.from_json(payload['errors']),
+                latency=Latency.from_json(payload['latency']),
+        )
+
+
+@dataclass
+class Errors:
+    script: 'int'
+    timeout: 'int'
+    rejected: 'int'
+    denied: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "script": self.script,
+            "timeout": self.timeout,
+            "rejected": self.rejected,
+            "denied": self.denied,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Errors':
+        return Errors(
+                script=payload['script'],
+                timeout=payload['timeout'],
+                rejected=payload['rejected'],
+                denied=payload['denied'],
+        )
+
+
+@dataclass
+class Latency:
+    min: 'Duration'
+    avg: 'Duration'
+    p50: 'Duration'
+    p95: 'Duration'
+    max: 'Duration'
+
+    def to_json(self) -> dict:
+        return {
+            "min": self.min.to_json(),
+            "avg": self.avg.to_json(),
+            "p50": self.p50.to_json(),
+            "p95": self.p95.to_json(),
+            "max": self.max.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Latency':
+        return Latency(
+                min=Duration.from_json(payload['min']),
+                avg=Duration.from_json(payload['avg']),
+                p50=Duration.from_json(payload['p50']),
+                p95=Duration.from_json(payload['p95']),
+                max=Duration.from_json(payload['max']),
+        )
+
+
+@dataclass
+class Group:
+    key: 'str'
+    buckets: 'List[Bucket]'
+    total: 'Bucket'
+
+    def to_json(self) -> dict:
+        return {
+            "key": self.key,
+            "buckets": [x.to_json() for x in self.buckets],
+            "total": self.total.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Group':
+        return Group(
+                key=payload['key'],
+                buckets=[Bucket.from_json(x) for x in (payload['buckets'] or [])],
+                total=Bucket.from_json(payload['total']),
+        )
+
+
+@dataclass
+class Usage:
+    records: 'int'
+    size: 'int'
+    segments: 'int'
+    pending: 'int'
+    oldest: 'Any'
+    newest: 'Any'
+    retention: 'Retention'
+    pruning: 'Pruning'
+
+    def to_json(self) -> dict:
+        return {
+            "records": self.records,
+            "size": self.size,
+            "segments": self.segments,
+            "pending": self.pending,
+            "oldest": self.oldest,
+            "newest": self.newest,
+            "retention": self.retention.to_json(),
+            "pruning": self.pruning.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Usage':
+        return Usage(
+                records=payload['records'],
+                size=payload['size'],
+                segments=payload['segments'],
+                pending=payload['pending'],
+                oldest=payload['oldest'],
+                newest=payload['newest'],
+                retention=Retention.from_json(payload['retention']),
+                pruning=Pruning.from_json(payload['pruning']),
+        )
+
+
+@dataclass
+class Retention:
+    max_age: 'Optional[Any]'
+    max_records: 'Optional[int]'
+    max_size: 'Optional[int]'
+    lambdas: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "max_age": self.max_age,
+            "max_records": self.max_records,
+            "max_size": self.max_size,
+            "lambdas": self.lambdas,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Retention':
+        return Retention(
+                max_age=payload['max_age'],
+                max_records=payload['max_records'],
+                max_size=payload['max_size'],
+                lambdas=payload['lambdas'],
+        )
+
+
+@dataclass
+class Pruning:
+    time: 'Any'
+    duration: 'Any'
+    removed: 'int'
+    early: 'int'
+    total: 'int'
+    behind: 'bool'
+    error: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "time": self.time,
+            "duration": self.duration,
+            "removed": self.removed,
+            "early": self.early,
+            "total": self.total,
+            "behind": self.behind,
+            "error": self.error,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Pruning':
+        return Pruning(
+                time=payload['time'],
+                duration=payload['duration'],
+                removed=payload['removed'],
+                early=payload['early'],
+                total=payload['total'],
+                behind=payload['behind'],
+                error=payload['error'],
+        )
+
+
+@dataclass
+class CreateOptions:
+    template: 'Optional[str]'
+    name: 'Optional[str]'
+    slug: 'Optional[bool]'
+    public_url: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "template": self.template,
+            "name": self.name,
+            "slug": self.slug,
+            "public_url": self.public_url,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'CreateOptions':
+        return CreateOptions(
+                template=payload['template'],
+                name=payload['name'],
+                slug=payload['slug'],
+                public_url=payload['public_url'],
+        )
+
+
+@dataclass
+class DuplicateOptions:
+    name: 'Optional[str]'
+    no_aliases: 'Optional[bool]'
+    no_schedules: 'Optional[bool]'
+    no_secrets: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "no_aliases": self.no_aliases,
+            "no_schedules": self.no_schedules,
+            "no_secrets": self.no_secrets,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'DuplicateOptions':
+        return DuplicateOptions(
+                name=payload['name'],
+                no_aliases=payload['no_aliases'],
+                no_schedules=payload['no_schedules'],
+                no_secrets=payload['no_secrets'],
+        )
+
+
+@dataclass
+class ServerInfo:
+    version: 'str'
+    capabilities: 'List[str]'
+    config: 'List[ConfigValue]'
+    upload: 'Optional[UploadLimits]'
+
+    def to_json(self) -> dict:
+        return {
+            "version": self.version,
+            "capabilities": self.capabilities,
+            "config": [x.to_json() for x in self.config],
+            "upload": self.upload.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ServerInfo':
+        return ServerInfo(
+                version=payload['version'],
+                capabilities=payload['capabilities'] or [],
+                config=[ConfigValue.from_json(x) for x in (payload['config'] or [])],
+                upload=UploadLimits.from_json(payload['upload']),
+        )
+
+
+@dataclass
+class ConfigValue:
+    name: 'str'
+    value: 'str'
+    source: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "value": self.value,
+            "source": self.source,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ConfigValue':
+        return ConfigValue(
+                name=payload['name'],
+                value=payload['value'],
+                source=payload['source'],
+        )
+
+
+@dataclass
+class UploadLimits:
+    files: 'Optional[int]'
+    size: 'Optional[int]'
+    depth: 'Optional[int]'
+    file_size: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "files": self.files,
+            "size": self.size,
+            "depth": self.depth,
+            "file_size": self.file_size,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'UploadLimits':
+        return UploadLimits(
+                files=payload['files'],
+                size=payload['size'],
+                depth=payload['depth'],
+                file_size=payload['file_size'],
+        )
+
+
+@dataclass
+class CapacityReport:
+    generated: 'Any'
+    window: 'Any'
+    covered: 'Any'
+    records: 'int'
+    partial: 'Optional[bool]'
+    running: 'int'
+    peak: 'int'
+    lambdas: 'List[LambdaCapacity]'
+    stores: 'List[StoreUsage]'
+    queues: 'List[QueueStatus]'
+    projection: 'CapacityProjection'
+
+    def to_json(self) -> dict:
+        return {
+            "generated": self.generated,
+            "window": self.window,
+            "covered": self.covered,
+            "records": self.records,
+            "partial": self.partial,
+            "running": self.running,
+            "peak": self.peak,
+            "lambdas": [x.to_json() for x in self.lambdas],
+            "stores": [x.to_json() for x in self.stores],
+            "queues": [x.to_json() for x in self.queues],
+            "projection": self.projection.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'CapacityReport':
+        return CapacityReport(
+                generated=payload['generated'],
+                window=payload['window'],
+                covered=payload['covered'],
+                records=payload['records'],
+                partial=payload['partial'],
+                running=payload['running'],
+                peak=payload['peak'],
+                lambdas=[LambdaCapacity.from_json(x) for x in (payload['lambdas'] or [])],
+                stores=[StoreUsage.from_json(x) for x in (payload['stores'] or [])],
+                queues=[QueueStatus.from_json(x) for x in (payload['queues'] or [])],
+                projection=CapacityProjection.from_json(payload['projection']),
+        )
+
+
+@dataclass
+class LambdaCapacity:
+    uid: 'str'
+    name: 'Optional[str]'
+    invocations: 'int'
+    running: 'int'
+    peak: 'int'
+    busy: 'Any'
+    cpu: 'Any'
+    max_rss: 'int'
+    disk: 'int'
+    backlog: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "name": self.name,
+            "invocations": self.invocations,
+            "running": self.running,
+            "peak": self.peak,
+            "busy": self.busy,
+            "cpu": self.cpu,
+            "max_rss": self.max_rss,
+            "disk": self.disk,
+            "backlog": self.backlog,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LambdaCapacity':
+        return LambdaCapacity(
+                uid=payload['uid'],
+                name=payload['name'],
+                invocations=payload['invocations'],
+                running=payload['running'],
+                peak=payload['peak'],
+                busy=payload['busy'],
+                cpu=payload['cpu'],
+                max_rss=payload['max_rss'],
+                disk=payload['disk'],
+                backlog=payload['backlog'],
+        )
+
+
+@dataclass
+class StoreUsage:
+    name: 'str'
+    path: 'str'
+    size: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "path": self.path,
+            "size": self.size,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'StoreUsage':
+        return StoreUsage(
+                name=payload['name'],
+                path=payload['path'],
+                size=payload['size'],
+        )
+
+
+@dataclass
+class CapacityProjection:
+    cp_uss: 'int'
+    memory: 'int'
+    cpu_load: 'float'
+    peak_memory: 'int'
+    builds_cpu: 'float'
+    builds_memory: 'int'
+    active_lambdas: 'int'
+    more_lambdas: 'int'
+    limit: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "cpus": self.cp_uss,
+            "memory": self.memory,
+            "cpu_load": self.cpu_load,
+            "peak_memory": self.peak_memory,
+            "builds_cpu": self.builds_cpu,
+            "builds_memory": self.builds_memory,
+            "active_lambdas": self.active_lambdas,
+            "more_lambdas": self.more_lambdas,
+            "limit": self.limit,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'CapacityProjection':
+        return CapacityProjection(
+                cp_uss=payload['cpus'],
+                memory=payload['memory'],
+                cpu_load=payload['cpu_load'],
+                peak_memory=payload['peak_memory'],
+                builds_cpu=payload['builds_cpu'],
+                builds_memory=payload['builds_memory'],
+                active_lambdas=payload['active_lambdas'],
+                more_lambdas=payload['more_lambdas'],
+                limit=payload['limit'],
+        )
+
+
+@dataclass
+class MirrorStatus:
+    enabled: 'bool'
+    primary: 'Optional[str]'
+    promoted: 'Optional[Any]'
+    interval: 'Optional[Any]'
+    last_sync: 'Optional[Any]'
+    last_error: 'Optional[str]'
+    lambdas: 'int'
+    syncs: 'int'
+    failures: 'int'
+    pulls: 'int'
+    diverged: 'Optional[List[MirrorDivergence]]'
+
+    def to_json(self) -> dict:
+        return {
+            "enabled": self.enabled,
+            "primary": self.primary,
+            "promoted": self.promoted,
+            "interval": self.interval,
+            "last_sync": self.last_sync,
+            "last_error": self.last_error,
+            "lambdas": self.lambdas,
+            "syncs": self.syncs,
+            "failures": self.failures,
+            "pulls": self.pulls,
+            "diverged": [x.to_json() for x in self.diverged],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'MirrorStatus':
+        return MirrorStatus(
+                enabled=payload['enabled'],
+                primary=payload['primary'],
+                promoted=payload['promoted'],
+                interval=payload['interval'],
+                last_sync=payload['last_sync'],
+                last_error=payload['last_error'],
+                lambdas=payload['lambdas'],
+                syncs=payload['syncs'],
+                failures=payload['failures'],
+                pulls=payload['pulls'],
+                diverged=[MirrorDivergence.from_json(x) for x in (payload['diverged'] or [])],
+        )
+
+
+@dataclass
+class MirrorDivergence:
+    object: 'str'
+    reason: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "object": self.object,
+            "reason": self.reason,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'MirrorDivergence':
+        return MirrorDivergence(
+                object=payload['object'],
+                reason=payload['reason'],
+        )
+
+
+@dataclass
+class ChangesReport:
+    since: 'Any'
+    until: 'Any'
+    total: 'int'
+    offset: 'int'
+    next: 'Optional[int]'
+    groups: 'List[ChangeGroup]'
+
+    def to_json(self) -> dict:
+        return {
+            "since": self.since,
+            "until": self.until,
+            "total": self.total,
+            "offset": self.offset,
+            "next": self.next,
+            "groups": [x.to_json() for x in self.groups],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ChangesReport':
+        return ChangesReport(
+                since=payload['since'],
+                until=payload['until'],
+                total=payload['total'],
+                offset=payload['offset'],
+                next=payload['next'],
+                groups=[ChangeGroup.from_json(x) for x in (payload['groups'] or [])],
+        )
+
+
+@dataclass
+class ChangeGroup:
+    _lambda: 'Optional[str]'
+    name: 'Optional[str]'
+    created: 'Optional[bool]'
+    removed: 'Optional[bool]'
+    summary: 'str'
+    changes: 'List[Change]'
+
+    def to_json(self) -> dict:
+        return {
+            "lambda": self._lambda,
+            "name": self.name,
+            "created": self.created,
+            "removed": self.removed,
+            "summary": self.summary,
+            "changes": [x.to_json() for x in self.changes],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ChangeGroup':
+        return ChangeGroup(
+                _lambda=payload['lambda'],
+                name=payload['name'],
+                created=payload['created'],
+                removed=payload['removed'],
+                summary=payload['summary'],
+                changes=[Change.from_json(x) for x in (payload['changes'] or [])],
+        )
+
+
+@dataclass
+class Change:
+    id: 'int'
+    time: 'Any'
+    actor: 'str'
+    kind: 'str'
+    _lambda: 'Optional[str]'
+    name: 'Optional[str]'
+    summary: 'str'
+    previous: 'Optional[str]'
+    revision: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "time": self.time,
+            "actor": self.actor,
+            "kind": self.kind,
+            "lambda": self._lambda,
+            "name": self.name,
+            "summary": self.summary,
+            "previous": self.previous,
+            "revision": self.revision,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Change':
+        return Change(
+                id=payload['id'],
+                time=payload['time'],
+                actor=payload['actor'],
+                kind=payload['kind'],
+                _lambda=payload['lambda'],
+                name=payload['name'],
+                summary=payload['summary'],
+                previous=payload['previous'],
+                revision=payload['revision'],
+        )
+
+
+@dataclass
+class ChangeQuery:
+    since: 'Any'
+    until: 'Any'
+    _lambda: 'Optional[str]'
+    kind: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "since": self.since,
+            "until": self.until,
+            "lambda": self._lambda,
+            "kind": self.kind,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ChangeQuery':
+        return ChangeQuery(
+                since=payload['since'],
+                until=payload['until'],
+                _lambda=payload['lambda'],
+                kind=payload['kind'],
+        )
+
+
+@dataclass
+class ChangesPage:
+    query: 'ChangeQuery'
+    total: 'int'
+    offset: 'int'
+    next: 'Optional[int]'
+    changes: 'List[Change]'
+
+    def to_json(self) -> dict:
+        return {
+            "query": self.query.to_json(),
+            "total": self.total,
+            "offset": self.offset,
+            "next": self.next,
+            "changes": [x.to_json() for x in self.changes],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ChangesPage':
+        return ChangesPage(
+                query=ChangeQuery.from_json(payload['query']),
+                total=payload['total'],
+                offset=payload['offset'],
+                next=payload['next'],
+                changes=[Change.from_json(x) for x in (payload['changes'] or [])],
+        )
+
+
+@dataclass
+class SecurityReport:
+    profile: 'SecurityProfile'
+    violations: 'int'
+    lambdas: 'List[LambdaDeviations]'
+
+    def to_json(self) -> dict:
+        return {
+            "profile": self.profile.to_json(),
+            "violations": self.violations,
+            "lambdas": [x.to_json() for x in self.lambdas],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SecurityReport':
+        return SecurityReport(
+                profile=SecurityProfile.from_json(payload['profile']),
+                violations=payload['violations'],
+                lambdas=[LambdaDeviations.from_json(x) for x in (payload['lambdas'] or [])],
+        )
+
+
+@dataclass
+class SecurityProfile:
+    name: 'str'
+    deny_network: 'Optional[bool]'
+    read_only: 'Optional[bool]'
+    time_limit: 'Optional[Any]'
+    maximum_payload: 'Optional[int]'
+    maximum_response: 'Optional[int]'
+    max_concurrency: 'Optional[int]'
+    remove_headers: 'Optional[List[str]]'
+    disable_debug: 'Optional[bool]'
+    require_runner: 'Optional[bool]'
+    mandatory: 'Optional[List[str]]'
+    upload: 'UploadLimits'
+    run_as_users: 'Optional[List[str]]'
+    run_as_groups: 'Optional[List[str]]'
+    max_grace_period: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "deny_network": self.deny_network,
+            "read_only": self.read_only,
+            "time_limit": self.time_limit,
+            "maximum_payload": self.maximum_payload,
+            "maximum_response": self.maximum_response,
+            "max_concurrency": self.max_concurrency,
+            "remove_headers": self.remove_headers,
+            "disable_debug": self.disable_debug,
+            "require_runner": self.require_runner,
+            "mandatory": self.mandatory,
+            "upload": self.upload.to_json(),
+            "run_as_users": self.run_as_users,
+            "run_as_groups": self.run_as_groups,
+            "max_grace_period": self.max_grace_period,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SecurityProfile':
+        return SecurityProfile(
+                name=payload['name'],
+                deny_network=payload['deny_network'],
+                read_only=payload['read_only'],
+                time_limit=payload['time_limit'],
+                maximum_payload=payload['maximum_payload'],
+                maximum_response=payload['maximum_response'],
+                max_concurrency=payload['max_concurrency'],
+                remove_headers=payload['remove_headers'] or [],
+                disable_debug=payload['disable_debug'],
+                require_runner=payload['require_runner'],
+                mandatory=payload['mandatory'] or [],
+                upload=UploadLimits.from_json(payload['upload']),
+                run_as_users=payload['run_as_users'] or [],
+                run_as_groups=payload['run_as_groups'] or [],
+                max_grace_period=payload['max_grace_period'],
+        )
+
+
+@dataclass
+class LambdaDeviations:
+    uid: 'str'
+    name: 'Optional[str]'
+    deviations: 'List[Deviation]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "name": self.name,
+            "deviations": [x.to_json() for x in self.deviations],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LambdaDeviations':
+        return LambdaDeviations(
+                uid=payload['uid'],
+                name=payload['name'],
+                deviations=[Deviation.from_json(x) for x in (payload['deviations'] or [])],
+        )
+
+
+@dataclass
+class Deviation:
+    rule: 'str'
+    value: 'str'
+    default: 'str'
+    mandatory: 'bool'
+    violation: 'bool'
+
+    def to_json(self) -> dict:
+        return {
+            "rule": self.rule,
+            "value": self.value,
+            "default": self.default,
+            "mandatory": self.mandatory,
+            "violation": self.violation,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Deviation':
+        return Deviation(
+                rule=payload['rule'],
+                value=payload['value'],
+                default=payload['default'],
+                mandatory=payload['mandatory'],
+                violation=payload['violation'],
+        )
+
+
+@dataclass
+class LambdaRoutes:
+    uid: 'str'
+    name: 'Optional[str]'
+    methods: 'Optional[List[str]]'
+    maximum_payload: 'Optional[int]'
+    routes: 'List[Route]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "name": self.name,
+            "methods": self.methods,
+            "maximum_payload": self.maximum_payload,
+            "routes": [x.to_json() for x in self.routes],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LambdaRoutes':
+        return LambdaRoutes(
+                uid=payload['uid'],
+                name=payload['name'],
+                methods=payload['methods'] or [],
+                maximum_payload=payload['maximum_payload'],
+                routes=[Route.from_json(x) for x in (payload['routes'] or [])],
+        )
+
+
+@dataclass
+class Route:
+    kind: 'str'
+    name: 'str'
+    path: 'str'
+    declared: 'Optional[bool]'
+    outdated: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "kind": self.kind,
+            "name": self.name,
+            "path": self.path,
+            "declared": self.declared,
+            "outdated": self.outdated,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Route':
+        return Route(
+                kind=payload['kind'],
+                name=payload['name'],
+                path=payload['path'],
+                declared=payload['declared'],
+                outdated=payload['outdated'],
+        )
+
+
+@dataclass
+class Document:
+    open_api: 'str'
+    info: 'Info'
+    servers: 'Optional[List[Server]]'
+    tags: 'Optional[List[Tag]]'
+    paths: 'Any'
+    components: 'Optional[Components]'
+
+    def to_json(self) -> dict:
+        return {
+            "openapi": self.open_api,
+            "info": self.info.to_json(),
+            "servers": [x.to_json() for x in self.servers],
+            "tags": [x.to_json() for x in self.tags],
+            "paths": self.paths,
+            "components": self.components.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Document':
+        return Document(
+                open_api=payload['openapi'],
+                info=Info.from_json(payload['info']),
+                servers=[Server.from_json(x) for x in (payload['servers'] or [])],
+                tags=[Tag.from_json(x) for x in (payload['tags'] or [])],
+                paths=payload['paths'],
+                components=Components.from_json(payload['components']),
+        )
+
+
+@dataclass
+class Info:
+    title: 'str'
+    description: 'Optional[str]'
+    version: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "title": self.title,
+            "description": self.description,
+            "version": self.version,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Info':
+        return Info(
+                title=payload['title'],
+                description=payload['description'],
+                version=payload['version'],
+        )
+
+
+@dataclass
+class Server:
+    url: 'str'
+
+    def to_json(self) -> dict:
+        return {
+            "url": self.url,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Server':
+        return Server(
+                url=payload['url'],
+        )
+
+
+@dataclass
+class Tag:
+    name: 'str'
+    description: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "description": self.description,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Tag':
+        return Tag(
+                name=payload['name'],
+                description=payload['description'],
+        )
+
+
+@dataclass
+class Components:
+    security_schemes: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "securitySchemes": self.security_schemes,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Components':
+        return Components(
+                security_schemes=payload['securitySchemes'],
+        )
+
+
+@dataclass
+class SearchResult:
+    query: 'str'
+    total: 'int'
+    incomplete: 'Optional[bool]'
+    hits: 'List[SearchHit]'
+
+    def to_json(self) -> dict:
+        return {
+            "query": self.query,
+            "total": self.total,
+            "incomplete": self.incomplete,
+            "hits": [x.to_json() for x in self.hits],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SearchResult':
+        return SearchResult(
+                query=payload['query'],
+                total=payload['total'],
+                incomplete=payload['incomplete'],
+                hits=[SearchHit.from_json(x) for x in (payload['hits'] or [])],
+        )
+
+
+@dataclass
+class SearchHit:
+    uid: 'str'
+    name: 'Optional[str]'
+    score: 'float'
+    highlights: 'List[SearchHighlight]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "name": self.name,
+            "score": self.score,
+            "highlights": [x.to_json() for x in self.highlights],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SearchHit':
+        return SearchHit(
+                uid=payload['uid'],
+                name=payload['name'],
+                score=payload['score'],
+                highlights=[SearchHighlight.from_json(x) for x in (payload['highlights'] or [])],
+        )
+
+
+@dataclass
+class SearchHighlight:
+    field: 'str'
+    fragments: 'List[SnippetFragment]'
+
+    def to_json(self) -> dict:
+        return {
+            "field": self.field,
+            "fragments": [x.to_json() for x in self.fragments],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SearchHighlight':
+        return SearchHighlight(
+                field=payload['field'],
+                fragments=[SnippetFragment.from_json(x) for x in (payload['fragments'] or [])],
+        )
+
+
+@dataclass
+class SnippetFragment:
+    text: 'str'
+    match: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "text": self.text,
+            "match": self.match,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'SnippetFragment':
+        return SnippetFragment(
+                text=payload['text'],
+                match=payload['match'],
+        )
+
+
+@dataclass
+class TransferReport:
+    definition: 'Definition'
+    skipped: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "definition": self.definition.to_json(),
+            "skipped": self.skipped,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'TransferReport':
+        return TransferReport(
+                definition=Definition.from_json(payload['definition']),
+                skipped=payload['skipped'] or [],
+        )
+
+
+@dataclass
+class TransferRequest:
+    id: 'Optional[str]'
+    source: 'str'
+    grant: 'TransferGrant'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "source": self.source,
+            "grant": self.grant.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'TransferRequest':
+        return TransferRequest(
+                id=payload['id'],
+                source=payload['source'],
+                grant=TransferGrant.from_json(payload['grant']),
+        )
+
+
+@dataclass
+class TransferGrant:
+    uid: 'str'
+    token: 'str'
+    hash: 'str'
+    secrets: 'bool'
+    expires: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "token": self.token,
+            "hash": self.hash,
+            "secrets": self.secrets,
+            "expires": self.expires,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'TransferGrant':
+        return TransferGrant(
+                uid=payload['uid'],
+                token=payload['token'],
+                hash=payload['hash'],
+                secrets=payload['secrets'],
+                expires=payload['expires'],
+        )
+
+
+@dataclass
+class TransferProgress:
+    id: 'str'
+    stage: 'str'
+    size: 'Optional[int]'
+    error: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "stage": self.stage,
+            "size": self.size,
+            "error": self.error,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'TransferProgress':
+        return TransferProgress(
+                id=payload['id'],
+                stage=payload['stage'],
+                size=payload['size'],
+                error=payload['error'],
+        )
+
+
+@dataclass
+class Secret:
+    name: 'str'
+    created: 'Any'
+    updated: 'Any'
+    lambdas: 'Optional[List[str]]'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "created": self.created,
+            "updated": self.updated,
+            "lambdas": self.lambdas,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Secret':
+        return Secret(
+                name=payload['name'],
+                created=payload['created'],
+                updated=payload['updated'],
+                lambdas=payload['lambdas'] or [],
+        )
+
+
+@dataclass
+class WebhookDelivery:
+    event: 'str'
+    type: 'str'
+    _lambda: 'Optional[str]'
+    url: 'str'
+    attempt: 'int'
+    time: 'Any'
+    status: 'Optional[int]'
+    error: 'Optional[str]'
+    retry: 'Optional[Any]'
+    duration: 'float'
+
+    def to_json(self) -> dict:
+        return {
+            "event": self.event,
+            "type": self.type,
+            "lambda": self._lambda,
+            "url": self.url,
+            "attempt": self.attempt,
+            "time": self.time,
+            "status": self.status,
+            "error": self.error,
+            "retry": self.retry,
+            "duration_ms": self.duration,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'WebhookDelivery':
+        return WebhookDelivery(
+                event=payload['event'],
+                type=payload['type'],
+                _lambda=payload['lambda'],
+                url=payload['url'],
+                attempt=payload['attempt'],
+                time=payload['time'],
+                status=payload['status'],
+                error=payload['error'],
+                retry=payload['retry'],
+                duration=payload['duration_ms'],
+        )
+
+
+@dataclass
+class BackupOptions:
+    no_tokens: 'Optional[bool]'
+    frozen: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "no_tokens": self.no_tokens,
+            "frozen": self.frozen,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BackupOptions':
+        return BackupOptions(
+                no_tokens=payload['no_tokens'],
+                frozen=payload['frozen'],
+        )
+
+
+@dataclass
+class RestoreOptions:
+    dry_run: 'Optional[bool]'
+    conflict: 'Optional[str]'
+    no_tokens: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "dry_run": self.dry_run,
+            "conflict": self.conflict,
+            "no_tokens": self.no_tokens,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RestoreOptions':
+        return RestoreOptions(
+                dry_run=payload['dry_run'],
+                conflict=payload['conflict'],
+                no_tokens=payload['no_tokens'],
+        )
+
+
+@dataclass
+class RestoreReport:
+    dry_run: 'bool'
+    header: 'BackupHeader'
+    items: 'List[RestoreItem]'
+
+    def to_json(self) -> dict:
+        return {
+            "dry_run": self.dry_run,
+            "header": self.header.to_json(),
+            "items": [x.to_json() for x in self.items],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RestoreReport':
+        return RestoreReport(
+                dry_run=payload['dry_run'],
+                header=BackupHeader.from_json(payload['header']),
+                items=[RestoreItem.from_json(x) for x in (payload['items'] or [])],
+        )
+
+
+@dataclass
+class BackupHeader:
+    format: 'int'
+    version: 'Optional[str]'
+    created: 'Any'
+    lambdas: 'int'
+    tokens: 'Optional[bool]'
+    secrets: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "format": self.format,
+            "version": self.version,
+            "created": self.created,
+            "lambdas": self.lambdas,
+            "tokens": self.tokens,
+            "secrets": self.secrets,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'BackupHeader':
+        return BackupHeader(
+                format=payload['format'],
+                version=payload['version'],
+                created=payload['created'],
+                lambdas=payload['lambdas'],
+                tokens=payload['tokens'],
+                secrets=payload['secrets'],
+        )
+
+
+@dataclass
+class RestoreItem:
+    kind: 'str'
+    name: 'str'
+    action: 'str'
+    target: 'Optional[str]'
+    conflict: 'Optional[bool]'
+    reason: 'Optional[str]'
+    frozen: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "kind": self.kind,
+            "name": self.name,
+            "action": self.action,
+            "target": self.target,
+            "conflict": self.conflict,
+            "reason": self.reason,
+            "frozen": self.frozen,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RestoreItem':
+        return RestoreItem(
+                kind=payload['kind'],
+                name=payload['name'],
+                action=payload['action'],
+                target=payload['target'],
+                conflict=payload['conflict'],
+                reason=payload['reason'],
+                frozen=payload['frozen'],
+        )
+
+
+@dataclass
+class ArtifactImport:
+    url: 'str'
+    sha_256: 'Optional[str]'
+    uid: 'Optional[str]'
+    action: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "url": self.url,
+            "sha256": self.sha_256,
+            "uid": self.uid,
+            "action": self.action,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ArtifactImport':
+        return ArtifactImport(
+                url=payload['url'],
+                sha_256=payload['sha256'],
+                uid=payload['uid'],
+                action=payload['action'],
+        )
+
+
+@dataclass
+class ArtifactReport:
+    uid: 'str'
+    created: 'Optional[bool]'
+    sha_256: 'str'
+    size: 'int'
+    action: 'Optional[str]'
+    warning: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "created": self.created,
+            "sha256": self.sha_256,
+            "size": self.size,
+            "action": self.action,
+            "warning": self.warning,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ArtifactReport':
+        return ArtifactReport(
+                uid=payload['uid'],
+                created=payload['created'],
+                sha_256=payload['sha256'],
+                size=payload['size'],
+                action=payload['action'],
+                warning=payload['warning'],
+        )
+
+
+@dataclass
+class ReloadReport:
+    items: 'List[ReloadItem]'
+
+    def to_json(self) -> dict:
+        return {
+            "items": [x.to_json() for x in self.items],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ReloadReport':
+        return ReloadReport(
+                items=[ReloadItem.from_json(x) for x in (payload['items'] or [])],
+        )
+
+
+@dataclass
+class ReloadItem:
+    target: 'str'
+    applied: 'Optional[bool]'
+    files: 'Optional[bool]'
+    error: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "target": self.target,
+            "applied": self.applied,
+            "files": self.files,
+            "error": self.error,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'ReloadItem':
+        return ReloadItem(
+                target=payload['target'],
+                applied=payload['applied'],
+                files=payload['files'],
+                error=payload['error'],
+        )
+
+
+class ProjectAPIError(RuntimeError):
+    def __init__(self, method: str, code: int, message: str, data: Any):
+        super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
+        self.code = code
+        self.message = message
+        self.data = data
+
+    @staticmethod
+    def from_json(method: str, payload: dict) -> 'ProjectAPIError':
+        return ProjectAPIError(
+            method=method,
+            code=payload['code'],
+            message=payload['message'],
+            data=payload.get('data')
+        )
+
+
+class ProjectAPIClient:
+    """
+    API for global project
+    """
+
+    def __init__(self, base_url: str = 'https://127.0.0.1:3434/u/', session: Optional[client.ClientSession] = None):
+        self.__url = base_url
+        self.__id = 1
+        self.__request = session.request if session is not None else client.request
+
+    def __next_id(self):
+        self.__id += 1
+        return self.__id
+
+    async def config(self, token: Any) -> Settings:
+        """
+        Get global configuration
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Config",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('config', payload['error'])
+        return Settings.from_json(payload['result'])
+
+    async def set_user(self, token: Any, user: str) -> Settings:
+        """
+        Change effective user
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.SetUser",
+            "id": self.__next_id(),
+            "params": [token, user, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('set_user', payload['error'])
+        return Settings.from_json(payload['result'])
+
+    async def set_environment(self, token: Any, env: Environment) -> Settings:
+        """
+        Change global environment
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.SetEnvironment",
+            "id": self.__next_id(),
+            "params": [token, env.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('set_environment', payload['error'])
+        return Settings.from_json(payload['result'])
+
+    async def update_environment(self, token: Any, set: Environment, unset: List[str]) -> Environment:
+        """
+        Set and remove variables of global environment without changing the rest. Returns updated global environment.
+Applied for the next invocation of every lambda
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.UpdateEnvironment",
+            "id": self.__next_id(),
+            "params": [token, set.to_json(), unset, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('update_environment', payload['error'])
+        return Environment.from_json(payload['result'])
+
+    async def all_templates(self, token: Any) -> List[TemplateStatus]:
+        """
+        Get all templates without filtering
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.AllTemplates",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('all_templates', payload['error'])
+        return [TemplateStatus.from_json(x) for x in (payload['result'] or [])]
+
+    async def list(self, token: Any) -> List[Definition]:
+        """
+        List available apps (lambdas) in a project
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.List",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('list', payload['error'])
+        return [Definition.from_json(x) for x in (payload['result'] or [])]
+
+    async def templates(self, token: Any) -> List[Template]:
+        """
+        Templates with filter by availability including embedded
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Templates",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('templates', payload['error'])
+        return [Template.from_json(x) for x in (payload['result'] or [])]
+
+    async def stats(self, token: Any, limit: int) -> List[Record]:
+        """
+        Global last records
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Stats",
+            "id": self.__next_id(),
+            "params": [token, limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('stats', payload['error'])
+        return [Record.from_json(x) for x in (payload['result'] or [])]
+
+    async def stats_range(self, token: Any, query: Query) -> List[Record]:
+        """
+        Global records in time range (optionally of one lambda) from the newest
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.StatsRange",
+            "id": self.__next_id(),
+            "params": [token, query.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('stats_range', payload['error'])
+        return [Record.from_json(x) for x in (payload['result'] or [])]
+
+    async def stats_aggregate(self, token: Any, query: Query, bucket: Any, group: str) -> Aggregation:
+        """
+        Global calls, errors by category and latency percentiles (optionally of one lambda) by buckets of time range
+(zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.StatsAggregate",
+            "id": self.__next_id(),
+            "params": [token, query.to_json(), bucket, group, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('stats_aggregate', payload['error'])
+        return Aggregation.from_json(payload['result'])
+
+    async def stats_usage(self, token: Any) -> Usage:
+        """
+        Usage of storage of stats: records, size, the oldest record, retention and the last pruning (behind - pruning
+doesn't keep up with limits)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.StatsUsage",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('stats_usage', payload['error'])
+        return Usage.from_json(payload['result'])
+
+    async def set_stats_retention(self, token: Any, retention: Retention) -> Usage:
+        """
+        Change retention of stats without restart: server limits and limits of lambdas by UID (zero - not limited).
+Records over new limits are pruned in background
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.SetStatsRetention",
+            "id": self.__next_id(),
+            "params": [token, retention.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('set_stats_retention', payload['error'])
+        return Usage.from_json(payload['result'])
+
+    async def create(self, token: Any) -> Definition:
+        """
+        Create new app (lambda)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Create",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('create', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def create_from_template(self, token: Any, template_name: str) -> Definition:
+        """
+        Create new app/lambda/function using pre-defined template. Invalid manifest is error with code 422 (see Update)
+Resolved outputs of template (ex: example of call) are in outputs field
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.CreateFromTemplate",
+            "id": self.__next_id(),
+            "params": [token, template_name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('create_from_template', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def create_from_git(self, token: Any, repo: str) -> Definition:
+        """
+        Create new app/lambda/function using remote Git repo. Invalid manifest is error with code 422 (see Update)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.CreateFromGit",
+            "id": self.__next_id(),
+            "params": [token, repo, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('create_from_git', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def create_with_options(self, token: Any, options: CreateOptions) -> Definition:
+        """
+        Create new app/lambda/function from template (empty - default manifest) with name and optional slug alias
+Resolved outputs of template are in outputs field
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.CreateWithOptions",
+            "id": self.__next_id(),
+            "params": [token, options.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('create_with_options', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def duplicate(self, token: Any, uid: str, options: DuplicateOptions) -> Definition:
+        """
+        Duplicate lambda on server: files (streamed), manifest and environment are copied to new lambda without shared
+state, optionally without aliases, schedules or references to secrets. Aliases bound to other lambdas (ex: to the
+source) are not declared by copy: they are in warning of copy
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Duplicate",
+            "id": self.__next_id(),
+            "params": [token, uid, options.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('duplicate', payload['error'])
+        return Definition.from_json(payload['result'])
+
+    async def capabilities(self, token: Any) -> ServerInfo:
+        """
+        Server information: version, capabilities and effective configuration (same as `trusted-cgi info`)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Capabilities",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('capabilities', payload['error'])
+        return ServerInfo.from_json(payload['result'])
+
+    async def capacity(self, token: Any, window: Any) -> CapacityReport:
+        """
+        Capacity report: usage of lambdas aggregated over window (zero - one hour) by stats records, disk usage, queue
+backlogs and naive projection of headroom
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Capacity",
+            "id": self.__next_id(),
+            "params": [token, window, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('capacity', payload['error'])
+        return CapacityReport.from_json(payload['result'])
+
+    async def mirror(self, token: Any) -> MirrorStatus:
+        """
+        Status of replication if server is read-only mirror of primary server (disabled status otherwise)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Mirror",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('mirror', payload['error'])
+        return MirrorStatus.from_json(payload['result'])
+
+    async def promote(self, token: Any) -> MirrorStatus:
+        """
+        Promote read-only mirror to primary: replication is stopped and mutating API is enabled
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Promote",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('promote', payload['error'])
+        return MirrorStatus.from_json(payload['result'])
 
-    @staticmethod
-    def from_json(method: str, payload: dict) -> 'ProjectAPIError':
-        return ProjectAPIError(
-            method=method,
-            code=payload['code'],
-            message=payload['message'],
-            data=payload.get('data')
-        )
+    async def changes(self, token: Any, since: Any, until: Any, offset: int, limit: int) -> ChangesReport:
+        """
+        Administrative changes (deploys, manifest edits, aliases, policies, users and settings) in time range
+[since, until) (zero until - till now) grouped by lambda. Page is defined by offset and limit (zero - 100) of changes
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Changes",
+            "id": self.__next_id(),
+            "params": [token, since, until, offset, limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('changes', payload['error'])
+        return ChangesReport.from_json(payload['result'])
 
+    async def audit(self, token: Any, query: ChangeQuery, offset: int, limit: int) -> ChangesPage:
+        """
+        Audit log: changes and failed authentications matched by query (time range with zero until - till now, lambda
+by UID or alias, kind) in order of recording. Page is defined by offset and limit (zero - 100)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Audit",
+            "id": self.__next_id(),
+            "params": [token, query.to_json(), offset, limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('audit', payload['error'])
+        return ChangesPage.from_json(payload['result'])
 
-class ProjectAPIClient:
-    """
-    API for global project
-    """
+    async def security(self, token: Any) -> SecurityReport:
+        """
+        Active security profile and options of lambdas different from its defaults (including violations of mandatory
+rules by manifests uploaded before the profile)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Security",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('security', payload['error'])
+        return SecurityReport.from_json(payload['result'])
 
-    def __init__(self, base_url: str = 'https://127.0.0.1:3434/u/', session: Optional[client.ClientSession] = None):
-        self.__url = base_url
-        self.__id = 1
-        self.__request = session.request if session is not None else client.request
+    async def routes(self, token: Any) -> List[LambdaRoutes]:
+        """
+        Canonical public invocation paths of lambdas (by UID, slug, aliases and linked queues) ordered by UID, for
+generation of reverse-proxy configuration
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Routes",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('routes', payload['error'])
+        return [LambdaRoutes.from_json(x) for x in (payload['result'] or [])]
 
-    def __next_id(self):
-        self.__id += 1
-        return self.__id
+    async def open_api(self, token: Any, lambda: str, public_url: str) -> Document:
+        """
+        OpenAPI 3.1 document of public routes of lambdas (by UID, slug and aliases) generated by current manifests:
+methods, content types and input schema of requests, output headers of responses. Lambda is UID of the only
+lambda in document (empty - all lambdas). Public URL is base URL of server seen by client (ignored if server has
+configured one, empty - document without servers)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.OpenAPI",
+            "id": self.__next_id(),
+            "params": [token, lambda, public_url, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('open_api', payload['error'])
+        return Document.from_json(payload['result'])
 
-    async def config(self, token: Any) -> Settings:
+    async def search(self, token: Any, query: str, limit: int) -> SearchResult:
         """
-        Get global configuration
+        Full-text search of lambdas by names, descriptions, aliases and labels: lambdas matching all words of query
+(word matches the same word or beginning of longer word) ordered by relevance with highlighted matched words.
+Number of hits is limited (zero - 20)
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.Config",
+            "method": "ProjectAPI.Search",
             "id": self.__next_id(),
-            "params": [token, ]
+            "params": [token, query, limit, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('config', payload['error'])
-        return Settings.from_json(payload['result'])
+            raise ProjectAPIError.from_json('search', payload['error'])
+        return SearchResult.from_json(payload['result'])
 
-    async def set_user(self, token: Any, user: str) -> Settings:
+    async def transfer(self, token: Any, request: TransferRequest) -> TransferReport:
         """
-        Change effective user
+        Transfer app from another server: export is pulled from source by grant (see LambdaAPI.GrantExport) and content
+is verified by hash of grant. App keeps UID, manifest (including schedules) and aliases which are free, nothing
+is created on failure. Existent UID is error with code 409
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.SetUser",
+            "method": "ProjectAPI.Transfer",
             "id": self.__next_id(),
-            "params": [token, user, ]
+            "params": [token, request.to_json(), ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('set_user', payload['error'])
-        return Settings.from_json(payload['result'])
+            raise ProjectAPIError.from_json('transfer', payload['error'])
+        return TransferReport.from_json(payload['result'])
 
-    async def set_environment(self, token: Any, env: Environment) -> Settings:
+    async def transfer_progress(self, token: Any, id: str) -> TransferProgress:
         """
-        Change global environment
+        Progress of running or recently finished transfer by ID of request
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.SetEnvironment",
+            "method": "ProjectAPI.TransferProgress",
             "id": self.__next_id(),
-            "params": [token, env.to_json(), ]
+            "params": [token, id, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('set_environment', payload['error'])
-        return Settings.from_json(payload['result'])
+            raise ProjectAPIError.from_json('transfer_progress', payload['error'])
+        return TransferProgress.from_json(payload['result'])
 
-    async def all_templates(self, token: Any) -> List[TemplateStatus]:
+    async def secrets(self, token: Any) -> List[Secret]:
         """
-        Get all templates without filtering
+        Secrets of server store ordered by name: metadata and lambdas which environment references them (values are
+never returned). Disabled store is error
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.AllTemplates",
+            "method": "ProjectAPI.Secrets",
             "id": self.__next_id(),
             "params": [token, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('all_templates', payload['error'])
-        return [TemplateStatus.from_json(x) for x in (payload['result'] or [])]
+            raise ProjectAPIError.from_json('secrets', payload['error'])
+        return [Secret.from_json(x) for x in (payload['result'] or [])]
 
-    async def list(self, token: Any) -> List[Definition]:
+    async def set_secret(self, token: Any, name: str, value: str) -> Secret:
         """
-        List available apps (lambdas) in a project
+        Set value of secret of server store (created if not exists). Value is write-only
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.List",
+            "method": "ProjectAPI.SetSecret",
             "id": self.__next_id(),
-            "params": [token, ]
+            "params": [token, name, value, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('list', payload['error'])
-        return [Definition.from_json(x) for x in (payload['result'] or [])]
+            raise ProjectAPIError.from_json('set_secret', payload['error'])
+        return Secret.from_json(payload['result'])
 
-    async def templates(self, token: Any) -> List[Template]:
+    async def remove_secret(self, token: Any, name: str) -> Secret:
         """
-        Templates with filter by availability including embedded
+        Remove secret of server store. Lambdas which still reference it are in result: their variables become empty
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.Templates",
+            "method": "ProjectAPI.RemoveSecret",
             "id": self.__next_id(),
-            "params": [token, ]
+            "params": [token, name, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('templates', payload['error'])
-        return [Template.from_json(x) for x in (payload['result'] or [])]
+            raise ProjectAPIError.from_json('remove_secret', payload['error'])
+        return Secret.from_json(payload['result'])
 
-    async def stats(self, token: Any, limit: int) -> List[Record]:
+    async def webhook_deliveries(self, token: Any, limit: int) -> List[WebhookDelivery]:
         """
-        Global last records
+        Recent attempts of deliveries of lifecycle events to webhooks of server and lambdas from the newest, including
+dropped events (zero attempt). Number of attempts is limited (zero - all kept attempts)
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.Stats",
+            "method": "ProjectAPI.WebhookDeliveries",
             "id": self.__next_id(),
             "params": [token, limit, ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('stats', payload['error'])
-        return [Record.from_json(x) for x in (payload['result'] or [])]
+            raise ProjectAPIError.from_json('webhook_deliveries', payload['error'])
+        return [WebhookDelivery.from_json(x) for x in (payload['result'] or [])]
 
-    async def create(self, token: Any) -> Definition:
+    async def backup(self, token: Any, options: BackupOptions) -> bytes:
         """
-        Create new app (lambda)
+        Backup of server as .tar.gz archive: lambdas (content, manifests, aliases, state), policies, queues, API tokens
+(hashes of values, unless excluded), sealed secrets and settings
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.Create",
+            "method": "ProjectAPI.Backup",
             "id": self.__next_id(),
-            "params": [token, ]
+            "params": [token, options.to_json(), ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('create', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise ProjectAPIError.from_json('backup', payload['error'])
+        return decodebytes((payload['result'] or '').encode())
 
-    async def create_from_template(self, token: Any, template_name: str) -> Definition:
+    async def restore(self, token: Any, archive: bytes, options: RestoreOptions) -> RestoreReport:
         """
-        Create new app/lambda/function using pre-defined template
+        Restore server from backup archive (see Backup): items which do not exist are created, existent items are
+conflicts handled by options (skip by default, fail is error with code 409 and conflicts in data). Dry run
+returns plan without changes
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.CreateFromTemplate",
+            "method": "ProjectAPI.Restore",
             "id": self.__next_id(),
-            "params": [token, template_name, ]
+            "params": [token, encodebytes(archive), options.to_json(), ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('create_from_template', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise ProjectAPIError.from_json('restore', payload['error'])
+        return RestoreReport.from_json(payload['result'])
 
-    async def create_from_git(self, token: Any, repo: str) -> Definition:
+    async def import_url(self, token: Any, request: ArtifactImport) -> ArtifactReport:
         """
-        Create new app/lambda/function using remote Git repo
+        Import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent lambda
+(by UID). Archive is extracted to staged copy, manifest of archive (or template.json) is applied and post-clone
+action is run there: failed import does not change lambda. Archive over upload limit is error with code 413,
+unknown format - 415, invalid manifest - 422
         """
         response = await self._invoke({
             "jsonrpc": "2.0",
-            "method": "ProjectAPI.CreateFromGit",
+            "method": "ProjectAPI.ImportURL",
             "id": self.__next_id(),
-            "params": [token, repo, ]
+            "params": [token, request.to_json(), ]
         })
         assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
         payload = await response.json()
         if 'error' in payload:
-            raise ProjectAPIError.from_json('create_from_git', payload['error'])
-        return Definition.from_json(payload['result'])
+            raise ProjectAPIError.from_json('import_url', payload['error'])
+        return ArtifactReport.from_json(payload['result'])
+
+    async def reload(self, token: Any, uid: str) -> ReloadReport:
+        """
+        Reload files edited on disk out of server: project config, security profile, manifests and changed files of all
+lambdas (empty UID) or manifest and files of one lambda. Invalid files are not applied and reported in items,
+the last good state is kept. For setups without watcher of files
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "ProjectAPI.Reload",
+            "id": self.__next_id(),
+            "params": [token, uid, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise ProjectAPIError.from_json('reload', payload['error'])
+        return ReloadReport.from_json(payload['result'])
 
     async def _invoke(self, request):
         return await self.__request('POST', self.__url, json=request)
@@ -478,6 +3808,15 @@ class ProjectAPIBatch:
         method = "ProjectAPI.SetEnvironment"
         self.__add_request(method, params, lambda payload: Settings.from_json(payload))
 
+    def update_environment(self, token: Any, set: Environment, unset: List[str]):
+        """
+        Set and remove variables of global environment without changing the rest. Returns updated global environment.
+Applied for the next invocation of every lambda
+        """
+        params = [token, set.to_json(), unset, ]
+        method = "ProjectAPI.UpdateEnvironment"
+        self.__add_request(method, params, lambda payload: Environment.from_json(payload))
+
     def all_templates(self, token: Any):
         """
         Get all templates without filtering
@@ -510,6 +3849,41 @@ class ProjectAPIBatch:
         method = "ProjectAPI.Stats"
         self.__add_request(method, params, lambda payload: [Record.from_json(x) for x in (payload or [])])
 
+    def stats_range(self, token: Any, query: Query):
+        """
+        Global records in time range (optionally of one lambda) from the newest
+        """
+        params = [token, query.to_json(), ]
+        method = "ProjectAPI.StatsRange"
+        self.__add_request(method, params, lambda payload: [Record.from_json(x) for x in (payload or [])])
+
+    def stats_aggregate(self, token: Any, query: Query, bucket: Any, group: str):
+        """
+        Global calls, errors by category and latency percentiles (optionally of one lambda) by buckets of time range
+(zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+        """
+        params = [token, query.to_json(), bucket, group, ]
+        method = "ProjectAPI.StatsAggregate"
+        self.__add_request(method, params, lambda payload: Aggregation.from_json(payload))
+
+    def stats_usage(self, token: Any):
+        """
+        Usage of storage of stats: records, size, the oldest record, retention and the last pruning (behind - pruning
+doesn't keep up with limits)
+        """
+        params = [token, ]
+        method = "ProjectAPI.StatsUsage"
+        self.__add_request(method, params, lambda payload: Usage.from_json(payload))
+
+    def set_stats_retention(self, token: Any, retention: Retention):
+        """
+        Change retention of stats without restart: server limits and limits of lambdas by UID (zero - not limited).
+Records over new limits are pruned in background
+        """
+        params = [token, retention.to_json(), ]
+        method = "ProjectAPI.SetStatsRetention"
+        self.__add_request(method, params, lambda payload: Usage.from_json(payload))
+
     def create(self, token: Any):
         """
         Create new app (lambda)
@@ -520,7 +3894,8 @@ class ProjectAPIBatch:
 
     def create_from_template(self, token: Any, template_name: str):
         """
-        Create new app/lambda/function using pre-defined template
+        Create new app/lambda/function using pre-defined template. Invalid manifest is error with code 422 (see Update)
+Resolved outputs of template (ex: example of call) are in outputs field
         """
         params = [token, template_name, ]
         method = "ProjectAPI.CreateFromTemplate"
@@ -528,12 +3903,213 @@ class ProjectAPIBatch:
 
     def create_from_git(self, token: Any, repo: str):
         """
-        Create new app/lambda/function using remote Git repo
+        Create new app/lambda/function using remote Git repo. Invalid manifest is error with code 422 (see Update)
         """
         params = [token, repo, ]
         method = "ProjectAPI.CreateFromGit"
         self.__add_request(method, params, lambda payload: Definition.from_json(payload))
 
+    def create_with_options(self, token: Any, options: CreateOptions):
+        """
+        Create new app/lambda/function from template (empty - default manifest) with name and optional slug alias
+Resolved outputs of template are in outputs field
+        """
+        params = [token, options.to_json(), ]
+        method = "ProjectAPI.CreateWithOptions"
+        self.__add_request(method, params, lambda payload: Definition.from_json(payload))
+
+    def duplicate(self, token: Any, uid: str, options: DuplicateOptions):
+        """
+        Duplicate lambda on server: files (streamed), manifest and environment are copied to new lambda without shared
+state, optionally without aliases, schedules or references to secrets. Aliases bound to other lambdas (ex: to the
+source) are not declared by copy: they are in warning of copy
+        """
+        params = [token, uid, options.to_json(), ]
+        method = "ProjectAPI.Duplicate"
+        self.__add_request(method, params, lambda payload: Definition.from_json(payload))
+
+    def capabilities(self, token: Any):
+        """
+        Server information: version, capabilities and effective configuration (same as `trusted-cgi info`)
+        """
+        params = [token, ]
+        method = "ProjectAPI.Capabilities"
+        self.__add_request(method, params, lambda payload: ServerInfo.from_json(payload))
+
+    def capacity(self, token: Any, window: Any):
+        """
+        Capacity report: usage of lambdas aggregated over window (zero - one hour) by stats records, disk usage, queue
+backlogs and naive projection of headroom
+        """
+        params = [token, window, ]
+        method = "ProjectAPI.Capacity"
+        self.__add_request(method, params, lambda payload: CapacityReport.from_json(payload))
+
+    def mirror(self, token: Any):
+        """
+        Status of replication if server is read-only mirror of primary server (disabled status otherwise)
+        """
+        params = [token, ]
+        method = "ProjectAPI.Mirror"
+        self.__add_request(method, params, lambda payload: MirrorStatus.from_json(payload))
+
+    def promote(self, token: Any):
+        """
+        Promote read-only mirror to primary: replication is stopped and mutating API is enabled
+        """
+        params = [token, ]
+        method = "ProjectAPI.Promote"
+        self.__add_request(method, params, lambda payload: MirrorStatus.from_json(payload))
+
+    def changes(self, token: Any, since: Any, until: Any, offset: int, limit: int):
+        """
+        Administrative changes (deploys, manifest edits, aliases, policies, users and settings) in time range
+[since, until) (zero until - till now) grouped by lambda. Page is defined by offset and limit (zero - 100) of changes
+        """
+        params = [token, since, until, offset, limit, ]
+        method = "ProjectAPI.Changes"
+        self.__add_request(method, params, lambda payload: ChangesReport.from_json(payload))
+
+    def audit(self, token: Any, query: ChangeQuery, offset: int, limit: int):
+        """
+        Audit log: changes and failed authentications matched by query (time range with zero until - till now, lambda
+by UID or alias, kind) in order of recording. Page is defined by offset and limit (zero - 100)
+        """
+        params = [token, query.to_json(), offset, limit, ]
+        method = "ProjectAPI.Audit"
+        self.__add_request(method, params, lambda payload: ChangesPage.from_json(payload))
+
+    def security(self, token: Any):
+        """
+        Active security profile and options of lambdas different from its defaults (including violations of mandatory
+rules by manifests uploaded before the profile)
+        """
+        params = [token, ]
+        method = "ProjectAPI.Security"
+        self.__add_request(method, params, lambda payload: SecurityReport.from_json(payload))
+
+    def routes(self, token: Any):
+        """
+        Canonical public invocation paths of lambdas (by UID, slug, aliases and linked queues) ordered by UID, for
+generation of reverse-proxy configuration
+        """
+        params = [token, ]
+        method = "ProjectAPI.Routes"
+        self.__add_request(method, params, lambda payload: [LambdaRoutes.from_json(x) for x in (payload or [])])
+
+    def open_api(self, token: Any, lambda: str, public_url: str):
+        """
+        OpenAPI 3.1 document of public routes of lambdas (by UID, slug and aliases) generated by current manifests:
+methods, content types and input schema of requests, output headers of responses. Lambda is UID of the only
+lambda in document (empty - all lambdas). Public URL is base URL of server seen by client (ignored if server has
+configured one, empty - document without servers)
+        """
+        params = [token, lambda, public_url, ]
+        method = "ProjectAPI.OpenAPI"
+        self.__add_request(method, params, lambda payload: Document.from_json(payload))
+
+    def search(self, token: Any, query: str, limit: int):
+        """
+        Full-text search of lambdas by names, descriptions, aliases and labels: lambdas matching all words of query
+(word matches the same word or beginning of longer word) ordered by relevance with highlighted matched words.
+Number of hits is limited (zero - 20)
+        """
+        params = [token, query, limit, ]
+        method = "ProjectAPI.Search"
+        self.__add_request(method, params, lambda payload: SearchResult.from_json(payload))
+
+    def transfer(self, token: Any, request: TransferRequest):
+        """
+        Transfer app from another server: export is pulled from source by grant (see LambdaAPI.GrantExport) and content
+is verified by hash of grant. App keeps UID, manifest (including schedules) and aliases which are free, nothing
+is created on failure. Existent UID is error with code 409
+        """
+        params = [token, request.to_json(), ]
+        method = "ProjectAPI.Transfer"
+        self.__add_request(method, params, lambda payload: TransferReport.from_json(payload))
+
+    def transfer_progress(self, token: Any, id: str):
+        """
+        Progress of running or recently finished transfer by ID of request
+        """
+        params = [token, id, ]
+        method = "ProjectAPI.TransferProgress"
+        self.__add_request(method, params, lambda payload: TransferProgress.from_json(payload))
+
+    def secrets(self, token: Any):
+        """
+        Secrets of server store ordered by name: metadata and lambdas which environment references them (values are
+never returned). Disabled store is error
+        """
+        params = [token, ]
+        method = "ProjectAPI.Secrets"
+        self.__add_request(method, params, lambda payload: [Secret.from_json(x) for x in (payload or [])])
+
+    def set_secret(self, token: Any, name: str, value: str):
+        """
+        Set value of secret of server store (created if not exists). Value is write-only
+        """
+        params = [token, name, value, ]
+        method = "ProjectAPI.SetSecret"
+        self.__add_request(method, params, lambda payload: Secret.from_json(payload))
+
+    def remove_secret(self, token: Any, name: str):
+        """
+        Remove secret of server store. Lambdas which still reference it are in result: their variables become empty
+        """
+        params = [token, name, ]
+        method = "ProjectAPI.RemoveSecret"
+        self.__add_request(method, params, lambda payload: Secret.from_json(payload))
+
+    def webhook_deliveries(self, token: Any, limit: int):
+        """
+        Recent attempts of deliveries of lifecycle events to webhooks of server and lambdas from the newest, including
+dropped events (zero attempt). Number of attempts is limited (zero - all kept attempts)
+        """
+        params = [token, limit, ]
+        method = "ProjectAPI.WebhookDeliveries"
+        self.__add_request(method, params, lambda payload: [WebhookDelivery.from_json(x) for x in (payload or [])])
+
+    def backup(self, token: Any, options: BackupOptions):
+        """
+        Backup of server as .tar.gz archive: lambdas (content, manifests, aliases, state), policies, queues, API tokens
+(hashes of values, unless excluded), sealed secrets and settings
+        """
+        params = [token, options.to_json(), ]
+        method = "ProjectAPI.Backup"
+        self.__add_request(method, params, lambda payload: decodebytes((payload or '').encode()))
+
+    def restore(self, token: Any, archive: bytes, options: RestoreOptions):
+        """
+        Restore server from backup archive (see Backup): items which do not exist are created, existent items are
+conflicts handled by options (skip by default, fail is error with code 409 and conflicts in data). Dry run
+returns plan without changes
+        """
+        params = [token, encodebytes(archive), options.to_json(), ]
+        method = "ProjectAPI.Restore"
+        self.__add_request(method, params, lambda payload: RestoreReport.from_json(payload))
+
+    def import_url(self, token: Any, request: ArtifactImport):
+        """
+        Import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent lambda
+(by UID). Archive is extracted to staged copy, manifest of archive (or template.json) is applied and post-clone
+action is run there: failed import does not change lambda. Archive over upload limit is error with code 413,
+unknown format - 415, invalid manifest - 422
+        """
+        params = [token, request.to_json(), ]
+        method = "ProjectAPI.ImportURL"
+        self.__add_request(method, params, lambda payload: ArtifactReport.from_json(payload))
+
+    def reload(self, token: Any, uid: str):
+        """
+        Reload files edited on disk out of server: project config, security profile, manifests and changed files of all
+lambdas (empty UID) or manifest and files of one lambda. Invalid files are not applied and reported in items,
+the last good state is kept. For setups without watcher of files
+        """
+        params = [token, uid, ]
+        method = "ProjectAPI.Reload"
+        self.__add_request(method, params, lambda payload: ReloadReport.from_json(payload))
+
     def __add_request(self, method: str, params, factory):
         request_id = self.__next_id()
         request = {
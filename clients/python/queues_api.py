@@ -3,6 +3,7 @@ from aiohttp import client
 from dataclasses import dataclass
 
 from typing import Any, List, Optional
+from base64 import decodebytes, encodebytes
 
 
 
@@ -34,6 +35,163 @@ class Queue:
         )
 
 
+@dataclass
+class QueueMessage:
+    queue: 'str'
+    empty: 'bool'
+    hops: 'int'
+    request: 'Optional[Request]'
+    quarantined: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "queue": self.queue,
+            "empty": self.empty,
+            "hops": self.hops,
+            "request": self.request.to_json(),
+            "quarantined": self.quarantined,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'QueueMessage':
+        return QueueMessage(
+                queue=payload['queue'],
+                empty=payload['empty'],
+                hops=payload['hops'],
+                request=Request.from_json(payload['request']),
+                quarantined=payload['quarantined'],
+        )
+
+
+@dataclass
+class Request:
+    id: 'Optional[str]'
+    method: 'str'
+    url: 'str'
+    path: 'str'
+    remote_address: 'str'
+    form: 'Any'
+    headers: 'Any'
+    public_url: 'Optional[str]'
+    alias: 'Optional[str]'
+    chain: 'Optional[List[str]]'
+    queued: 'Optional[Any]'
+    user: 'Optional[str]'
+    claims: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "method": self.method,
+            "url": self.url,
+            "path": self.path,
+            "remote_address": self.remote_address,
+            "form": self.form,
+            "headers": self.headers,
+            "public_url": self.public_url,
+            "alias": self.alias,
+            "chain": self.chain,
+            "queued": self.queued,
+            "user": self.user,
+            "claims": self.claims,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Request':
+        return Request(
+                id=payload['id'],
+                method=payload['method'],
+                url=payload['url'],
+                path=payload['path'],
+                remote_address=payload['remote_address'],
+                form=payload['form'],
+                headers=payload['headers'],
+                public_url=payload['public_url'],
+                alias=payload['alias'],
+                chain=payload['chain'] or [],
+                queued=payload['queued'],
+                user=payload['user'],
+                claims=payload['claims'],
+        )
+
+
+@dataclass
+class AsyncInvocation:
+    ticket: 'str'
+    method: 'str'
+    path: 'str'
+    size: 'int'
+    accepted: 'Any'
+    in_flight: 'Optional[bool]'
+
+    def to_json(self) -> dict:
+        return {
+            "ticket": self.ticket,
+            "method": self.method,
+            "path": self.path,
+            "size": self.size,
+            "accepted": self.accepted,
+            "in_flight": self.in_flight,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'AsyncInvocation':
+        return AsyncInvocation(
+                ticket=payload['ticket'],
+                method=payload['method'],
+                path=payload['path'],
+                size=payload['size'],
+                accepted=payload['accepted'],
+                in_flight=payload['in_flight'],
+        )
+
+
+@dataclass
+class DeadLetter:
+    id: 'str'
+    _lambda: 'str'
+    queue: 'str'
+    schedule: 'Optional[str]'
+    error: 'str'
+    attempts: 'int'
+    first: 'Any'
+    failed: 'Any'
+    size: 'int'
+    request: 'Request'
+    body: 'Optional[bytes]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "lambda": self._lambda,
+            "queue": self.queue,
+            "schedule": self.schedule,
+            "error": self.error,
+            "attempts": self.attempts,
+            "first": self.first,
+            "failed": self.failed,
+            "size": self.size,
+            "request": self.request.to_json(),
+            "body": encodebytes(self.body),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'DeadLetter':
+        return DeadLetter(
+                id=payload['id'],
+                _lambda=payload['lambda'],
+                queue=payload['queue'],
+                schedule=payload['schedule'],
+                error=payload['error'],
+                attempts=payload['attempts'],
+                first=payload['first'],
+                failed=payload['failed'],
+                size=payload['size'],
+                request=Request.from_json(payload['request']),
+                body=decodebytes((payload['body'] or '').encode()),
+        )
+
+
 class QueuesAPIError(RuntimeError):
     def __init__(self, method: str, code: int, message: str, data: Any):
         super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
@@ -145,6 +303,155 @@ class QueuesAPIClient:
             raise QueuesAPIError.from_json('assign', payload['error'])
         return payload['result']
 
+    async def inspect(self, token: Any, name: str) -> QueueMessage:
+        """
+        Oldest message of queue without consuming it: headers and chain of lambdas which produced message (body is
+not returned)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.Inspect",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('inspect', payload['error'])
+        return QueueMessage.from_json(payload['result'])
+
+    async def retry_quarantined(self, token: Any, name: str) -> int:
+        """
+        Return quarantined (corrupted) messages of queue to the end of queue, returns number of returned messages
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.RetryQuarantined",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('retry_quarantined', payload['error'])
+        return payload['result']
+
+    async def purge_quarantined(self, token: Any, name: str) -> int:
+        """
+        Remove quarantined (corrupted) messages of queue, returns number of removed messages
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.PurgeQuarantined",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('purge_quarantined', payload['error'])
+        return payload['result']
+
+    async def async(self, token: Any) -> List[AsyncInvocation]:
+        """
+        Pending asynchronous invocations (including in-flight) in order of acceptance, empty if asynchronous
+invocations are disabled
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.Async",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('async', payload['error'])
+        return [AsyncInvocation.from_json(x) for x in (payload['result'] or [])]
+
+    async def purge_async(self, token: Any, tickets: List[str]) -> int:
+        """
+        Remove pending asynchronous invocations by tickets (empty - all pending), in-flight invocations are not
+interrupted. Returns number of removed invocations
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.PurgeAsync",
+            "id": self.__next_id(),
+            "params": [token, tickets, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('purge_async', payload['error'])
+        return payload['result']
+
+    async def dead_letters(self, token: Any, lambda: str) -> List[DeadLetter]:
+        """
+        Dead letters of lambda (queued requests not processed after all attempts) from the newest, body is not
+returned
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.DeadLetters",
+            "id": self.__next_id(),
+            "params": [token, lambda, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('dead_letters', payload['error'])
+        return [DeadLetter.from_json(x) for x in (payload['result'] or [])]
+
+    async def dead_letter(self, token: Any, lambda: str, id: str) -> DeadLetter:
+        """
+        Dead letter of lambda with body
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.DeadLetter",
+            "id": self.__next_id(),
+            "params": [token, lambda, id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('dead_letter', payload['error'])
+        return DeadLetter.from_json(payload['result'])
+
+    async def remove_dead_letters(self, token: Any, lambda: str, ids: List[str]) -> int:
+        """
+        Remove dead letters of lambda by IDs (empty - all), returns number of removed letters
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.RemoveDeadLetters",
+            "id": self.__next_id(),
+            "params": [token, lambda, ids, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('remove_dead_letters', payload['error'])
+        return payload['result']
+
+    async def redrive_dead_letters(self, token: Any, lambda: str, ids: List[str]) -> int:
+        """
+        Put dead letters of lambda by IDs (empty - all) back to their queues and remove them, returns number of
+returned letters
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.RedriveDeadLetters",
+            "id": self.__next_id(),
+            "params": [token, lambda, ids, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('redrive_dead_letters', payload['error'])
+        return payload['result']
+
     async def _invoke(self, request):
         return await self.__request('POST', self.__url, json=request)
 
@@ -205,6 +512,83 @@ class QueuesAPIBatch:
         method = "QueuesAPI.Assign"
         self.__add_request(method, params, lambda payload: payload)
 
+    def inspect(self, token: Any, name: str):
+        """
+        Oldest message of queue without consuming it: headers and chain of lambdas which produced message (body is
+not returned)
+        """
+        params = [token, name, ]
+        method = "QueuesAPI.Inspect"
+        self.__add_request(method, params, lambda payload: QueueMessage.from_json(payload))
+
+    def retry_quarantined(self, token: Any, name: str):
+        """
+        Return quarantined (corrupted) messages of queue to the end of queue, returns number of returned messages
+        """
+        params = [token, name, ]
+        method = "QueuesAPI.RetryQuarantined"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def purge_quarantined(self, token: Any, name: str):
+        """
+        Remove quarantined (corrupted) messages of queue, returns number of removed messages
+        """
+        params = [token, name, ]
+        method = "QueuesAPI.PurgeQuarantined"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def async(self, token: Any):
+        """
+        Pending asynchronous invocations (including in-flight) in order of acceptance, empty if asynchronous
+invocations are disabled
+        """
+        params = [token, ]
+        method = "QueuesAPI.Async"
+        self.__add_request(method, params, lambda payload: [AsyncInvocation.from_json(x) for x in (payload or [])])
+
+    def purge_async(self, token: Any, tickets: List[str]):
+        """
+        Remove pending asynchronous invocations by tickets (empty - all pending), in-flight invocations are not
+interrupted. Returns number of removed invocations
+        """
+        params = [token, tickets, ]
+        method = "QueuesAPI.PurgeAsync"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def dead_letters(self, token: Any, lambda: str):
+        """
+        Dead letters of lambda (queued requests not processed after all attempts) from the newest, body is not
+returned
+        """
+        params = [token, lambda, ]
+        method = "QueuesAPI.DeadLetters"
+        self.__add_request(method, params, lambda payload: [DeadLetter.from_json(x) for x in (payload or [])])
+
+    def dead_letter(self, token: Any, lambda: str, id: str):
+        """
+        Dead letter of lambda with body
+        """
+        params = [token, lambda, id, ]
+        method = "QueuesAPI.DeadLetter"
+        self.__add_request(method, params, lambda payload: DeadLetter.from_json(payload))
+
+    def remove_dead_letters(self, token: Any, lambda: str, ids: List[str]):
+        """
+        Remove dead letters of lambda by IDs (empty - all), returns number of removed letters
+        """
+        params = [token, lambda, ids, ]
+        method = "QueuesAPI.RemoveDeadLetters"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def redrive_dead_letters(self, token: Any, lambda: str, ids: List[str]):
+        """
+        Put dead letters of lambda by IDs (empty - all) back to their queues and remove them, returns number of
+returned letters
+        """
+        params = [token, lambda, ids, ]
+        method = "QueuesAPI.RedriveDeadLetters"
+        self.__add_request(method, params, lambda payload: payload)
+
     def __add_request(self, method: str, params, factory):
         request_id = self.__next_id()
         request = {
@@ -1,10 +1,89 @@
 from aiohttp import client
 
+from dataclasses import dataclass
 
-from typing import Any
+from typing import Any, List, Optional
 
 
 
+@dataclass
+class Scope:
+    lambdas: 'Optional[List[str]]'
+    operations: 'List[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "lambdas": self.lambdas,
+            "operations": self.operations,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Scope':
+        return Scope(
+                lambdas=payload['lambdas'] or [],
+                operations=payload['operations'] or [],
+        )
+
+
+@dataclass
+class TokenInfo:
+    id: 'str'
+    name: 'str'
+    scope: 'Scope'
+    created: 'Any'
+    expires: 'Optional[Any]'
+    replaced_by: 'Optional[str]'
+    token: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "name": self.name,
+            "scope": self.scope.to_json(),
+            "created": self.created,
+            "expires": self.expires,
+            "replaced_by": self.replaced_by,
+            "token": self.token,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'TokenInfo':
+        return TokenInfo(
+                id=payload['id'],
+                name=payload['name'],
+                scope=Scope.from_json(payload['scope']),
+                created=payload['created'],
+                expires=payload['expires'],
+                replaced_by=payload['replaced_by'],
+                token=payload['token'],
+        )
+
+
+@dataclass
+class Lockout:
+    subject: 'str'
+    kind: 'str'
+    failures: 'int'
+    locked_until: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "subject": self.subject,
+            "kind": self.kind,
+            "failures": self.failures,
+            "locked_until": self.locked_until,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Lockout':
+        return Lockout(
+                subject=payload['subject'],
+                kind=payload['kind'],
+                failures=payload['failures'],
+                locked_until=payload['locked_until'],
+        )
+
+
 class UserAPIError(RuntimeError):
     def __init__(self, method: str, code: int, message: str, data: Any):
         super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
@@ -68,6 +147,105 @@ class UserAPIClient:
             raise UserAPIError.from_json('change_password', payload['error'])
         return payload['result']
 
+    async def create_token(self, token: Any, name: str, scope: Scope, ttl: Any) -> TokenInfo:
+        """
+        Create long-lived API token limited by scope (lambdas and operations) with time to live (zero - never expires).
+Value of token is returned only once
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "UserAPI.CreateToken",
+            "id": self.__next_id(),
+            "params": [token, name, scope.to_json(), ttl, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise UserAPIError.from_json('create_token', payload['error'])
+        return TokenInfo.from_json(payload['result'])
+
+    async def rotate_token(self, token: Any, id: str, overlap: Any) -> TokenInfo:
+        """
+        Issue replacement of API token with the same name, scope and time to live. Replaced token is valid for overlap
+(zero - one hour) to switch automation without downtime
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "UserAPI.RotateToken",
+            "id": self.__next_id(),
+            "params": [token, id, overlap, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise UserAPIError.from_json('rotate_token', payload['error'])
+        return TokenInfo.from_json(payload['result'])
+
+    async def tokens(self, token: Any) -> List[TokenInfo]:
+        """
+        API tokens with scopes and expiration (without values). Tokens expired more than 7 days ago are removed
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "UserAPI.Tokens",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise UserAPIError.from_json('tokens', payload['error'])
+        return [TokenInfo.from_json(x) for x in (payload['result'] or [])]
+
+    async def revoke_token(self, token: Any, id: str) -> bool:
+        """
+        Revoke API token by ID
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "UserAPI.RevokeToken",
+            "id": self.__next_id(),
+            "params": [token, id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise UserAPIError.from_json('revoke_token', payload['error'])
+        return payload['result']
+
+    async def lockouts(self, token: Any) -> List[Lockout]:
+        """
+        Accounts and client addresses with failed logins, including locked out ones
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "UserAPI.Lockouts",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise UserAPIError.from_json('lockouts', payload['error'])
+        return [Lockout.from_json(x) for x in (payload['result'] or [])]
+
+    async def unlock(self, token: Any, subject: str) -> int:
+        """
+        Clear lockout and failed logins of account or client address (empty - all), returns number of cleared subjects.
+Could be called by local admin socket without token (ex: admin account is locked out)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "UserAPI.Unlock",
+            "id": self.__next_id(),
+            "params": [token, subject, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise UserAPIError.from_json('unlock', payload['error'])
+        return payload['result']
+
     async def _invoke(self, request):
         return await self.__request('POST', self.__url, json=request)
 
@@ -104,6 +282,57 @@ class UserAPIBatch:
         method = "UserAPI.ChangePassword"
         self.__add_request(method, params, lambda payload: payload)
 
+    def create_token(self, token: Any, name: str, scope: Scope, ttl: Any):
+        """
+        Create long-lived API token limited by scope (lambdas and operations) with time to live (zero - never expires).
+Value of token is returned only once
+        """
+        params = [token, name, scope.to_json(), ttl, ]
+        method = "UserAPI.CreateToken"
+        self.__add_request(method, params, lambda payload: TokenInfo.from_json(payload))
+
+    def rotate_token(self, token: Any, id: str, overlap: Any):
+        """
+        Issue replacement of API token with the same name, scope and time to live. Replaced token is valid for overlap
+(zero - one hour) to switch automation without downtime
+        """
+        params = [token, id, overlap, ]
+        method = "UserAPI.RotateToken"
+        self.__add_request(method, params, lambda payload: TokenInfo.from_json(payload))
+
+    def tokens(self, token: Any):
+        """
+        API tokens with scopes and expiration (without values). Tokens expired more than 7 days ago are removed
+        """
+        params = [token, ]
+        method = "UserAPI.Tokens"
+        self.__add_request(method, params, lambda payload: [TokenInfo.from_json(x) for x in (payload or [])])
+
+    def revoke_token(self, token: Any, id: str):
+        """
+        Revoke API token by ID
+        """
+        params = [token, id, ]
+        method = "UserAPI.RevokeToken"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def lockouts(self, token: Any):
+        """
+        Accounts and client addresses with failed logins, including locked out ones
+        """
+        params = [token, ]
+        method = "UserAPI.Lockouts"
+        self.__add_request(method, params, lambda payload: [Lockout.from_json(x) for x in (payload or [])])
+
+    def unlock(self, token: Any, subject: str):
+        """
+        Clear lockout and failed logins of account or client address (empty - all), returns number of cleared subjects.
+Could be called by local admin socket without token (ex: admin account is locked out)
+        """
+        params = [token, subject, ]
+        method = "UserAPI.Unlock"
+        self.__add_request(method, params, lambda payload: payload)
+
     def __add_request(self, method: str, params, factory):
         request_id = self.__next_id()
         request = {
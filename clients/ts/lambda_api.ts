@@ -12,6 +12,14 @@ export class LambdaAPIError extends Error {
 
 export type Token = string;
 
+export interface ContentProof {
+    uid: string
+    archive: string | null
+    hash: string
+    nonce: string
+    signature: string
+}
+
 export interface File {
     name: string
     is_dir: boolean
@@ -21,6 +29,18 @@ export interface Definition {
     uid: string
     aliases: JsonStringSet
     manifest: Manifest
+    modified: Time | null
+    schedules: Array<ScheduleStatus> | null
+    queues: Array<QueueStatus> | null
+    alerts: Array<AlertStatus> | null
+    slug: string | null
+    slug_outdated: boolean | null
+    warning: string | null
+    disabled: boolean
+    alias_overrides: any | null
+    git: GitStatus | null
+    disk: DiskUsage | null
+    outputs: any | null
 }
 
 export interface JsonStringSet {
@@ -29,26 +49,400 @@ export interface JsonStringSet {
 export interface Manifest {
     name: string | null
     description: string | null
+    labels: Array<string> | null
     run: Array<string>
     output_headers: any | null
+    parse_headers: boolean | null
     input_headers: any | null
     query: any | null
+    query_to_body: boolean | null
     environment: any | null
     method: string | null
     method_env: string | null
     path_env: string | null
+    public_url_env: string | null
+    alias_env: string | null
+    deadline_env: string | null
     time_limit: JsonDuration | null
     maximum_payload: number | null
     cron: Array<Schedule> | null
+    actions: any | null
+    aliases: Array<string> | null
     static: string | null
+    umask: string | null
+    lang: string | null
+    lc_all: string | null
+    tz: string | null
+    sampling: Sampling | null
+    on_start: Startup | null
+    coalesce: Coalescing | null
+    cache: Caching | null
+    rate_limit: RateLimit | null
+    accepted_content_types: Array<string> | null
+    allow_no_content_type: boolean | null
+    rewrite_urls: Rewrite | null
+    secrets: Secrets | null
+    alerts: Array<Alert> | null
+    mutate: Mutation | null
+    on_success: Chaining | null
+    then: Array<ChainStep> | null
+    methods: Array<string> | null
+    build_limits: BuildLimits | null
+    limits: ResourceLimits | null
+    disk_quota_mb: number | null
+    build_gate: BuildGate | null
+    status_map: any | null
+    static_dirs: any | null
+    remove_headers: Array<string> | null
+    maximum_response: number | null
+    truncate_policy: string | null
+    soft_limits: SoftLimits | null
+    work_dir: string | null
+    expand_run: boolean | null
+    max_concurrency: number | null
+    overflow_policy: string | null
+    cors: CORS | null
+    input_schema: RawMessage | null
+    network: boolean | null
+    read_only: boolean | null
+    run_as: RunAs | null
+    streaming: string | null
+    stream_time_limit: JsonDuration | null
+    stream_idle_timeout: JsonDuration | null
+    stream_reauthorize: JsonDuration | null
+    container: Container | null
+    mode: string | null
+    workers: number | null
+    grace_period: JsonDuration | null
+    payload_as_file: boolean | null
+    maximum_file_payload: number | null
+    decode_multipart: boolean | null
+    maximum_form_file: number | null
+    decode_form: boolean | null
+    raw_form_env: string | null
+    disable_compression: boolean | null
+    queue_serial: boolean | null
+    retry: Retry | null
+    check: Array<Array<string>> | null
+    warm_up: boolean | null
+    warm_up_marker: string | null
+    capture: Capture | null
+    logs: LogFiles | null
+    allowed_networks: Array<string> | null
+    webhook_signature: WebhookSignature | null
+    basic_auth: BasicAuth | null
+    jwt: JWTPolicy | null
+    alias_policies: any | null
+    event_webhooks: Array<LifecycleWebhook> | null
+    git: GitSource | null
 }
 
 export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
 
 export interface Schedule {
+    name: string | null
     cron: string
-    action: string
+    action: string | null
+    payload: string | null
+    payload_file: string | null
     time_limit: JsonDuration
+    disabled: boolean | null
+    missed: string | null
+    tz: string | null
+}
+
+export interface Sampling {
+    rate: number | null
+    slow: JsonDuration | null
+}
+
+export interface Startup {
+    action: string
+    time_limit: JsonDuration | null
+    blocking: boolean | null
+    on_failure: string | null
+}
+
+export interface Coalescing {
+    max_waiters: number | null
+    headers: Array<string> | null
+}
+
+export interface Caching {
+    ttl: JsonDuration
+    max_entries: number | null
+    vary: Array<string> | null
+}
+
+export interface RateLimit {
+    rps: number
+    burst: number | null
+    per_client: boolean | null
+}
+
+export interface Rewrite {
+    prefix: string
+    max_size: number | null
+}
+
+export interface Secrets {
+    delivery: string | null
+    keys: Array<string> | null
+    env: string | null
+}
+
+export interface Alert {
+    name: string
+    error_rate: number | null
+    consecutive_failures: number | null
+    latency_p95: JsonDuration | null
+    window: JsonDuration | null
+    min_calls: number | null
+    action: string | null
+    cool_off: JsonDuration | null
+    notify: string | null
+}
+
+export interface Mutation {
+    headers: any | null
+    body_defaults: any | null
+}
+
+export interface Chaining {
+    queue: string
+    transform: string | null
+    max_size: number | null
+    max_hops: number | null
+}
+
+export interface ChainStep {
+    lambda: string
+    condition: string | null
+}
+
+export interface BuildLimits {
+    nice: number | null
+    cpu: number | null
+    memory: number | null
+}
+
+export interface ResourceLimits {
+    memory_mb: number | null
+    cpu_percent: number | null
+    open_files: number | null
+    processes: number | null
+}
+
+export interface BuildGate {
+    actions: Array<string> | null
+    timeout: JsonDuration | null
+    disabled: boolean | null
+}
+
+export interface SoftLimits {
+    payload: number | null
+    response: number | null
+    notify: string | null
+    interval: JsonDuration | null
+}
+
+export interface CORS {
+    origins: Array<string> | null
+    methods: Array<string> | null
+    headers: Array<string> | null
+    credentials: boolean | null
+    max_age: JsonDuration | null
+}
+
+export interface RawMessage {
+}
+
+export interface RunAs {
+    user: string
+    group: string | null
+}
+
+export interface Container {
+    image: string
+    entrypoint: Array<string> | null
+    mounts: Array<Mount> | null
+    network: boolean | null
+}
+
+export interface Mount {
+    source: string
+    target: string
+    writable: boolean | null
+}
+
+export interface Retry {
+    attempts: number
+    backoff: JsonDuration | null
+    max_backoff: JsonDuration | null
+    jitter: boolean | null
+}
+
+export interface Capture {
+    requests: number
+    max_bytes: number | null
+}
+
+export interface LogFiles {
+    enabled: boolean
+    max_size_mb: number | null
+    max_files: number | null
+}
+
+export interface WebhookSignature {
+    preset: string | null
+    header: string | null
+    algorithm: string | null
+    secret: string
+    encoding: string | null
+    prefix: string | null
+    tolerance: JsonDuration | null
+}
+
+export interface BasicAuth {
+    users: any
+    realm: string | null
+    links: Array<string> | null
+    env: string | null
+}
+
+export interface JWTPolicy {
+    issuers: Array<string> | null
+    audiences: Array<string> | null
+    secret: string | null
+    public_key: string | null
+    jwks: string | null
+    refresh: JsonDuration | null
+    leeway: JsonDuration | null
+    links: Array<string> | null
+    subject_env: string | null
+    env: any | null
+    headers: any | null
+}
+
+export interface LifecycleWebhook {
+    url: string
+    events: Array<string> | null
+    secret: string | null
+    payload: boolean | null
+}
+
+export interface GitSource {
+    repo: string
+    branch: string | null
+    dir: string | null
+    interval: JsonDuration | null
+    action: string | null
+    key: string | null
+    ping: WebhookSignature | null
+}
+
+export type Time = string; // RFC3339
+
+export interface ScheduleStatus {
+    name: string | null
+    cron: string
+    tz: string | null
+    action: string
+    enabled: boolean
+    next: Time | null
+    last_run: Time | null
+    last_result: string | null
+    last_error: string | null
+}
+
+export interface QueueStatus {
+    name: string
+    depth: number
+    in_flight: number
+    paused: boolean
+    oldest: Time | null
+    oldest_age_seconds: number
+    quarantined: number
+}
+
+export interface AlertStatus {
+    name: string
+    action: string
+    state: string
+    since: Time | null
+    reason: string | null
+    until: Time | null
+    events: Array<AlertEvent>
+}
+
+export interface AlertEvent {
+    time: Time
+    from: string
+    to: string
+    reason: string | null
+    by: string
+}
+
+export interface GitStatus {
+    repo: string
+    branch: string | null
+    commit: string | null
+    deployed: Time | null
+    checked: Time | null
+    failed: string | null
+    error: string | null
+    output: string | null
+    running: boolean | null
+}
+
+export interface DiskUsage {
+    size: number
+    quota: number | null
+    measured: Time
+}
+
+export interface BulkReport {
+    dry_run: boolean | null
+    results: Array<BulkResult>
+    failed: number | null
+}
+
+export interface BulkResult {
+    uid: string
+    name: string | null
+    changes: Array<FieldChange> | null
+    applied: boolean | null
+    error: string | null
+}
+
+export interface FieldChange {
+    field: string
+    from: string | null
+    to: string | null
+}
+
+export interface BulkUpdate {
+    patch: RawMessage | null
+    set: Array<string> | null
+    uids: Array<string> | null
+    label: string | null
+    all: boolean | null
+    dry_run: boolean | null
+}
+
+export interface Environment {
+    environment: any | null
+}
+
+export interface EnvValue {
+    name: string
+    value: string
+    source: string
+    overridden: Array<string> | null
+}
+
+export interface Passwords {
+    users: any | null
 }
 
 export interface Record {
@@ -57,21 +451,254 @@ export interface Record {
     request: Request
     begin: Time
     end: Time
+    rate: number | null
+    coalesced: boolean | null
+    cached: boolean | null
+    rejected: boolean | null
+    throttled: boolean | null
+    status: number | null
+    payload: number | null
+    size: number | null
+    cpu: Duration | null
+    max_rss: number | null
+    warning: string | null
+    limits: Array<string> | null
+    wait: Duration | null
+    overrun: boolean | null
+    output: Array<number> | null
+    stderr: Array<number> | null
+    queue: string | null
+    queue_wait: Duration | null
+    attempt: number | null
+    retried: boolean | null
+    catch_up: boolean | null
+    denied: boolean | null
+    timeout: boolean | null
+    trigger: string | null
+    spawn: Duration | null
+    first_byte: Duration | null
+    runtime: Duration | null
+    write: Duration | null
+    exit_code: number | null
+    signal: string | null
 }
 
 export interface Request {
+    id: string | null
     method: string
     url: string
     path: string
     remote_address: string
     form: any
     headers: any
+    public_url: string | null
+    alias: string | null
+    chain: Array<string> | null
+    queued: Time | null
+    user: string | null
+    claims: any | null
 }
 
-export type Time = string; // RFC3339
+export type Duration = string; // suffixes: ns, us, ms, s, m, h
+
+export interface Query {
+    uid: string | null
+    since: Time | null
+    until: Time | null
+    limit: number
+}
+
+export interface Aggregation {
+    uid: string | null
+    since: Time
+    until: Time
+    bucket: Duration
+    buckets: Array<Bucket>
+    total: Bucket
+    group: string | null
+    groups: Array<Group> | null
+}
+
+export interface Bucket {
+    begin: Time
+    calls: number
+    errors: Errors
+    latency: Latency
+}
+
+export interface Errors {
+    script: number
+    timeout: number
+    rejected: number
+    denied: number
+}
+
+export interface Latency {
+    min: Duration
+    avg: Duration
+    p50: Duration
+    p95: Duration
+    max: Duration
+}
+
+export interface Group {
+    key: string
+    buckets: Array<Bucket>
+    total: Bucket
+}
+
+export interface ActionResult {
+    output: string
+    stderr: string | null
+    exit_code: number
+    error: string | null
+    duration: JsonDuration
+    queue_position: number | null
+    queued: JsonDuration | null
+    limit_exceeded: string | null
+}
+
+export interface Diagnostic {
+    runtime: Runtime
+    secrets: SecretsStatus
+    startup: StartupStatus | null
+    builds: BuildLimits
+    env: EnvStatus
+    container: ContainerStatus | null
+}
+
+export interface Runtime {
+    umask: string | null
+    lang: string | null
+    lc_all: string | null
+    tz: string | null
+    env_limits: EnvLimits | null
+}
+
+export interface EnvLimits {
+    max_size: number | null
+    max_variable: number | null
+    oversized: string | null
+}
+
+export interface SecretsStatus {
+    delivery: string
+    env: string | null
+    keys: Array<string>
+}
+
+export interface StartupStatus {
+    action: string
+    running: boolean
+    degraded: boolean
+    started: Time
+    finished: Time | null
+    error: string | null
+    output: string | null
+}
+
+export interface BuildLimits {
+    cgroup: boolean
+}
+
+export interface EnvStatus {
+    size: number
+    limit: number
+    headroom: number
+    max_variable: number
+    oversized: string
+    largest: Array<EnvVariable> | null
+}
+
+export interface EnvVariable {
+    name: string
+    size: number
+}
+
+export interface ContainerStatus {
+    image: string
+    state: string
+    progress: string | null
+    error: string | null
+    updated: Time
+}
+
+export interface CapturedRequest {
+    id: string
+    lambda: string
+    captured: Time
+    duration: JsonDuration
+    error: string | null
+    size: number
+    truncated: boolean | null
+    request: Request
+    body: Array<number> | null
+}
+
+export interface ReplayResult {
+    id: string
+    output: Array<number>
+    stderr: string | null
+    error: string | null
+    duration: JsonDuration
+}
+
+export interface TransferGrant {
+    uid: string
+    token: string
+    hash: string
+    secrets: boolean
+    expires: Time
+}
+
+export interface LambdaExport {
+    uid: string
+    manifest: Manifest
+    aliases: Array<string> | null
+    content: Array<number>
+}
+
+export interface FrozenArtifact {
+    id: string
+    uid: string
+    size: number
+    created: Time
+}
+
+export interface LambdaVersion {
+    id: string
+    uid: string
+    created: Time
+    author: string | null
+    message: string | null
+    size: number
+    files: number
+    current: boolean | null
+}
+
+export interface RollbackReport {
+    version: LambdaVersion
+    action: string | null
+    warning: string | null
+}
+
+export interface VersionRollback {
+    id: string
+    action: string | null
+    no_build: boolean | null
+}
+
+export interface LogFile {
+    name: string
+    size: number
+    modified: Time
+    current: boolean | null
+}
 
 
 
+export type Duration = string; // suffixes: ns, us, ms, s, m, h
+
 
 // support stuff
 
@@ -234,14 +861,15 @@ export class LambdaAPI {
 
 
     /**
-    Upload content from .tar.gz archive to app and call Install handler (if defined)
+    Upload content from .tar.gz or .zip archive (detected by content) to app and call Install handler (if defined).
+Unknown format is error with code 415
     **/
-    async upload(token: Token, uid: string, tarGz: Array<number>): Promise<boolean> {
+    async upload(token: Token, uid: string, archive: Array<number>): Promise<boolean> {
         return (await this.__call({
             "jsonrpc" : "2.0",
             "method" : "LambdaAPI.Upload",
             "id" : this.__next_id(),
-            "params" : [token, uid, tarGz]
+            "params" : [token, uid, archive]
         })) as boolean;
     }
 
@@ -257,6 +885,54 @@ export class LambdaAPI {
         })) as Array<number>;
     }
 
+    /**
+    Upload content as single read-only .zip bundle to app and returns bundle hash
+    **/
+    async uploadBundle(token: Token, uid: string, zip: Array<number>): Promise<string> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.UploadBundle",
+            "id" : this.__next_id(),
+            "params" : [token, uid, zip]
+        })) as string;
+    }
+
+    /**
+    Hash of app content (hash of bundle for bundled app)
+    **/
+    async contentHash(token: Token, uid: string): Promise<string> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.ContentHash",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as string;
+    }
+
+    /**
+    Upload content as Upload does and return hash of received archive and content hash of app signed by token
+    **/
+    async uploadVerified(token: Token, uid: string, archive: Array<number>, nonce: string): Promise<ContentProof> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.UploadVerified",
+            "id" : this.__next_id(),
+            "params" : [token, uid, archive, nonce]
+        })) as ContentProof;
+    }
+
+    /**
+    Hash of app content (as ContentHash) signed by token for spot checks
+    **/
+    async signedContentHash(token: Token, uid: string, nonce: string): Promise<ContentProof> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SignedContentHash",
+            "id" : this.__next_id(),
+            "params" : [token, uid, nonce]
+        })) as ContentProof;
+    }
+
     /**
     Push single file to app
     **/
@@ -318,17 +994,86 @@ export class LambdaAPI {
     }
 
     /**
-    Update application manifest
+    Update application manifest. Invalid manifest is error with code 422 and problems of fields
+(list of field and message) as data. Declared aliases are bound to the lambda, aliases removed from manifest
+are released. Aliases bound to other lambdas are error with code 409 and conflicts as data unless forceAliases
+is set (aliases are moved to the lambda)
     **/
-    async update(token: Token, uid: string, manifest: Manifest): Promise<Definition> {
+    async update(token: Token, uid: string, manifest: Manifest, forceAliases: boolean): Promise<Definition> {
         return (await this.__call({
             "jsonrpc" : "2.0",
             "method" : "LambdaAPI.Update",
             "id" : this.__next_id(),
-            "params" : [token, uid, manifest]
+            "params" : [token, uid, manifest, forceAliases]
         })) as Definition;
     }
 
+    /**
+    Apply partial change of manifest (merge patch and assignments of fields) to selected lambdas. Every patched
+manifest is validated and applied the same way as by Update (aliases are not forced): failure of one lambda does
+not abort the batch and is reported in its result. Dry run reports changes without apply
+    **/
+    async bulkUpdate(token: Token, request: BulkUpdate): Promise<BulkReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.BulkUpdate",
+            "id" : this.__next_id(),
+            "params" : [token, request]
+        })) as BulkReport;
+    }
+
+    /**
+    Environment variables of application from manifest (as is, references are not resolved)
+    **/
+    async environment(token: Token, uid: string): Promise<Environment> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Environment",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Environment;
+    }
+
+    /**
+    Set and remove environment variables of application without changing the rest of manifest. Returns updated
+environment. Applied for the next invocation
+    **/
+    async setEnvironment(token: Token, uid: string, set: Environment, unset: Array<string>): Promise<Environment> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SetEnvironment",
+            "id" : this.__next_id(),
+            "params" : [token, uid, set, unset]
+        })) as Environment;
+    }
+
+    /**
+    Variables of environment of invocations of application: runtime defaults and global environment of server merged
+with environment of manifest (manifest wins). References are resolved except references to secrets. Variables of
+server process and request are not included
+    **/
+    async mergedEnvironment(token: Token, uid: string): Promise<Array<EnvValue>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.MergedEnvironment",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Array<EnvValue>;
+    }
+
+    /**
+    Set (passwords are hashed by server) and remove users of basic authentication of application without changing
+the rest of manifest. Authentication is removed with the last user. Returns updated usernames
+    **/
+    async setBasicAuth(token: Token, uid: string, set: Passwords, unset: Array<string>): Promise<Array<string>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SetBasicAuth",
+            "id" : this.__next_id(),
+            "params" : [token, uid, set, unset]
+        })) as Array<string>;
+    }
+
     /**
     Create file or directory inside app
     **/
@@ -378,7 +1123,32 @@ export class LambdaAPI {
     }
 
     /**
-    Actions available for the app
+    Records of the app in time range from the newest (UID of query is replaced by UID of the app)
+    **/
+    async statsRange(token: Token, uid: string, query: Query): Promise<Array<Record>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.StatsRange",
+            "id" : this.__next_id(),
+            "params" : [token, uid, query]
+        })) as Array<Record>;
+    }
+
+    /**
+    Calls, errors by category and latency percentiles of the app by buckets of time range (UID of query is replaced
+by UID of the app, zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+    **/
+    async statsAggregate(token: Token, uid: string, query: Query, bucket: JsonDuration, group: string): Promise<Aggregation> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.StatsAggregate",
+            "id" : this.__next_id(),
+            "params" : [token, uid, query, bucket, group]
+        })) as Aggregation;
+    }
+
+    /**
+    Actions available for the app: actions of manifest and targets of Makefile
     **/
     async actions(token: Token, uid: string): Promise<Array<string>> {
         return (await this.__call({
@@ -390,7 +1160,7 @@ export class LambdaAPI {
     }
 
     /**
-    Invoke action in the app (if make installed)
+    Invoke action in the app (action of manifest or make target, if make installed)
     **/
     async invoke(token: Token, uid: string, action: string): Promise<string> {
         return (await this.__call({
@@ -401,6 +1171,18 @@ export class LambdaAPI {
         })) as string;
     }
 
+    /**
+    Invoke action in the app with time limit (0 means no limit) and return output with exit code even for failed action
+    **/
+    async invokeAction(token: Token, uid: string, action: string, timeLimit: JsonDuration): Promise<ActionResult> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.InvokeAction",
+            "id" : this.__next_id(),
+            "params" : [token, uid, action, timeLimit]
+        })) as ActionResult;
+    }
+
     /**
     Make link/alias for app
     **/
@@ -425,6 +1207,257 @@ export class LambdaAPI {
         })) as Definition;
     }
 
+    /**
+    Effective runtime settings (umask, locale, timezone) of the app
+    **/
+    async doctor(token: Token, uid: string): Promise<Diagnostic> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Doctor",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Diagnostic;
+    }
+
+    /**
+    Captured requests of the app from the newest without bodies (see types.Manifest.Capture)
+    **/
+    async requests(token: Token, uid: string): Promise<Array<CapturedRequest>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Requests",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Array<CapturedRequest>;
+    }
+
+    /**
+    Captured request of the app with body
+    **/
+    async capturedRequest(token: Token, uid: string, id: string): Promise<CapturedRequest> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.CapturedRequest",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        })) as CapturedRequest;
+    }
+
+    /**
+    Invoke the app by captured request again and return new result. Truncated requests can not be replayed
+    **/
+    async replay(token: Token, uid: string, id: string): Promise<ReplayResult> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Replay",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        })) as ReplayResult;
+    }
+
+    /**
+    Enable or disable the app without removing it. Disabled app responds 503 to public requests (by UID and
+aliases), its schedules are paused and queued requests are held till it is enabled. State survives restarts
+    **/
+    async setEnabled(token: Token, uid: string, enabled: boolean): Promise<Definition> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.SetEnabled",
+            "id" : this.__next_id(),
+            "params" : [token, uid, enabled]
+        })) as Definition;
+    }
+
+    /**
+    Generate slug alias from name of the app (previous slug is kept as alias)
+    **/
+    async regenerateSlug(token: Token, uid: string): Promise<Definition> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.RegenerateSlug",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Definition;
+    }
+
+    /**
+    Reset fired alert rules of the app (all rules if rule is empty) and re-enable it
+    **/
+    async resetAlerts(token: Token, uid: string, rule: string): Promise<Array<AlertStatus>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.ResetAlerts",
+            "id" : this.__next_id(),
+            "params" : [token, uid, rule]
+        })) as Array<AlertStatus>;
+    }
+
+    /**
+    Fetch tracked git branch of the app (see types.Manifest.Git) and deploy new commit (force - deploy even the same
+or failed commit). Failed deploy keeps the previous version and is returned as status with error. Error with code
+404 if the app does not track git, 409 if deploy is in progress
+    **/
+    async gitDeploy(token: Token, uid: string, force: boolean): Promise<GitStatus> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.GitDeploy",
+            "id" : this.__next_id(),
+            "params" : [token, uid, force]
+        })) as GitStatus;
+    }
+
+    /**
+    Short-lived (5 minutes) grant of export of the app by another server (see Export and ProjectAPI.Transfer)
+with hash of exported content. Values of environment variables are exported only if secrets is set
+    **/
+    async grantExport(token: Token, uid: string, secrets: boolean): Promise<TransferGrant> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.GrantExport",
+            "id" : this.__next_id(),
+            "params" : [token, uid, secrets]
+        })) as TransferGrant;
+    }
+
+    /**
+    Export the app by token of grant (see GrantExport) instead of login token: manifest, aliases and content
+    **/
+    async export(grant: string, uid: string): Promise<LambdaExport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Export",
+            "id" : this.__next_id(),
+            "params" : [grant, uid]
+        })) as LambdaExport;
+    }
+
+    /**
+    Freeze all files of the app, including dependencies installed by actions and files ignored by .cgiignore, to
+content-addressed artifact kept by server: freeze of the same files returns the same artifact. Old artifacts are
+pruned by retention of server
+    **/
+    async freeze(token: Token, uid: string): Promise<FrozenArtifact> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Freeze",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as FrozenArtifact;
+    }
+
+    /**
+    Frozen artifacts of the app from the newest
+    **/
+    async frozenArtifacts(token: Token, uid: string): Promise<Array<FrozenArtifact>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.FrozenArtifacts",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Array<FrozenArtifact>;
+    }
+
+    /**
+    Download frozen artifact of the app (empty id - the newest) as .tar.gz archive. Unknown artifact is error with
+code 404
+    **/
+    async downloadFrozen(token: Token, uid: string, id: string): Promise<Array<number>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.DownloadFrozen",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        })) as Array<number>;
+    }
+
+    /**
+    Replace all files of the app by frozen artifact (empty id - the newest) without builds and network: manifest of
+artifact is applied. Unknown artifact is error with code 404
+    **/
+    async thaw(token: Token, uid: string, id: string): Promise<FrozenArtifact> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Thaw",
+            "id" : this.__next_id(),
+            "params" : [token, uid, id]
+        })) as FrozenArtifact;
+    }
+
+    /**
+    Upload content as Upload does and return version of the app annotated by message (upload of the same files
+returns the same version). Versions are also taken by every upload and update of manifest
+    **/
+    async uploadVersion(token: Token, uid: string, archive: Array<number>, message: string): Promise<LambdaVersion> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.UploadVersion",
+            "id" : this.__next_id(),
+            "params" : [token, uid, archive, message]
+        })) as LambdaVersion;
+    }
+
+    /**
+    Versions of the app (files and manifest) from the newest. Old versions are pruned by retention of server
+    **/
+    async versions(token: Token, uid: string): Promise<Array<LambdaVersion>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Versions",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Array<LambdaVersion>;
+    }
+
+    /**
+    Restore files and manifest of version of the app (ID or unique prefix) and run build action (empty - build if
+defined by Makefile of version) before files are replaced: failed build keeps the app. Unknown version is error
+with code 404
+    **/
+    async rollback(token: Token, uid: string, rollback: VersionRollback): Promise<RollbackReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Rollback",
+            "id" : this.__next_id(),
+            "params" : [token, uid, rollback]
+        })) as RollbackReport;
+    }
+
+    /**
+    Log files of the app (full stdout and stderr of invocations if logs are enabled by manifest) from the oldest
+    **/
+    async logFiles(token: Token, uid: string): Promise<Array<LogFile>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.LogFiles",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as Array<LogFile>;
+    }
+
+    /**
+    Download log file of the app by name (see LogFiles). Unknown file is error with code 404
+    **/
+    async downloadLog(token: Token, uid: string, name: string): Promise<Array<number>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.DownloadLog",
+            "id" : this.__next_id(),
+            "params" : [token, uid, name]
+        })) as Array<number>;
+    }
+
+    /**
+    The last lines (zero - 100) of current log file of the app
+    **/
+    async tailLog(token: Token, uid: string, lines: number): Promise<string> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.TailLog",
+            "id" : this.__next_id(),
+            "params" : [token, uid, lines]
+        })) as string;
+    }
+
 
     private __next_id() {
         this.__id += 1;
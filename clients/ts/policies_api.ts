@@ -18,6 +18,7 @@ export interface Policy {
 
 export interface PolicyDefinition {
     allowed_ip: JsonStringSet | null
+    allowed_networks: Array<string> | null
     allowed_origin: JsonStringSet | null
     public: boolean
     tokens: any | null
@@ -14,6 +14,35 @@ export interface Settings {
     user: string
     public_key: string | null
     environment: any | null
+    runtime: Runtime
+    builds: BuildsConfig
+    auto_slug: boolean
+}
+
+export interface Runtime {
+    umask: string | null
+    lang: string | null
+    lc_all: string | null
+    tz: string | null
+    env_limits: EnvLimits | null
+}
+
+export interface EnvLimits {
+    max_size: number | null
+    max_variable: number | null
+    oversized: string | null
+}
+
+export interface BuildsConfig {
+    limits: BuildLimits
+    max_concurrent: number | null
+    cgroup: string | null
+}
+
+export interface BuildLimits {
+    nice: number | null
+    cpu: number | null
+    memory: number | null
 }
 
 export type Token = string;
@@ -32,6 +61,18 @@ export interface Definition {
     uid: string
     aliases: JsonStringSet
     manifest: Manifest
+    modified: Time | null
+    schedules: Array<ScheduleStatus> | null
+    queues: Array<QueueStatus> | null
+    alerts: Array<AlertStatus> | null
+    slug: string | null
+    slug_outdated: boolean | null
+    warning: string | null
+    disabled: boolean
+    alias_overrides: any | null
+    git: GitStatus | null
+    disk: DiskUsage | null
+    outputs: any | null
 }
 
 export interface JsonStringSet {
@@ -40,26 +81,350 @@ export interface JsonStringSet {
 export interface Manifest {
     name: string | null
     description: string | null
+    labels: Array<string> | null
     run: Array<string>
     output_headers: any | null
+    parse_headers: boolean | null
     input_headers: any | null
     query: any | null
+    query_to_body: boolean | null
     environment: any | null
     method: string | null
     method_env: string | null
     path_env: string | null
+    public_url_env: string | null
+    alias_env: string | null
+    deadline_env: string | null
     time_limit: JsonDuration | null
     maximum_payload: number | null
     cron: Array<Schedule> | null
+    actions: any | null
+    aliases: Array<string> | null
     static: string | null
+    umask: string | null
+    lang: string | null
+    lc_all: string | null
+    tz: string | null
+    sampling: Sampling | null
+    on_start: Startup | null
+    coalesce: Coalescing | null
+    cache: Caching | null
+    rate_limit: RateLimit | null
+    accepted_content_types: Array<string> | null
+    allow_no_content_type: boolean | null
+    rewrite_urls: Rewrite | null
+    secrets: Secrets | null
+    alerts: Array<Alert> | null
+    mutate: Mutation | null
+    on_success: Chaining | null
+    then: Array<ChainStep> | null
+    methods: Array<string> | null
+    build_limits: BuildLimits | null
+    limits: ResourceLimits | null
+    disk_quota_mb: number | null
+    build_gate: BuildGate | null
+    status_map: any | null
+    static_dirs: any | null
+    remove_headers: Array<string> | null
+    maximum_response: number | null
+    truncate_policy: string | null
+    soft_limits: SoftLimits | null
+    work_dir: string | null
+    expand_run: boolean | null
+    max_concurrency: number | null
+    overflow_policy: string | null
+    cors: CORS | null
+    input_schema: RawMessage | null
+    network: boolean | null
+    read_only: boolean | null
+    run_as: RunAs | null
+    streaming: string | null
+    stream_time_limit: JsonDuration | null
+    stream_idle_timeout: JsonDuration | null
+    stream_reauthorize: JsonDuration | null
+    container: Container | null
+    mode: string | null
+    workers: number | null
+    grace_period: JsonDuration | null
+    payload_as_file: boolean | null
+    maximum_file_payload: number | null
+    decode_multipart: boolean | null
+    maximum_form_file: number | null
+    decode_form: boolean | null
+    raw_form_env: string | null
+    disable_compression: boolean | null
+    queue_serial: boolean | null
+    retry: Retry | null
+    check: Array<Array<string>> | null
+    warm_up: boolean | null
+    warm_up_marker: string | null
+    capture: Capture | null
+    logs: LogFiles | null
+    allowed_networks: Array<string> | null
+    webhook_signature: WebhookSignature | null
+    basic_auth: BasicAuth | null
+    jwt: JWTPolicy | null
+    alias_policies: any | null
+    event_webhooks: Array<LifecycleWebhook> | null
+    git: GitSource | null
 }
 
 export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
 
 export interface Schedule {
+    name: string | null
     cron: string
-    action: string
+    action: string | null
+    payload: string | null
+    payload_file: string | null
     time_limit: JsonDuration
+    disabled: boolean | null
+    missed: string | null
+    tz: string | null
+}
+
+export interface Sampling {
+    rate: number | null
+    slow: JsonDuration | null
+}
+
+export interface Startup {
+    action: string
+    time_limit: JsonDuration | null
+    blocking: boolean | null
+    on_failure: string | null
+}
+
+export interface Coalescing {
+    max_waiters: number | null
+    headers: Array<string> | null
+}
+
+export interface Caching {
+    ttl: JsonDuration
+    max_entries: number | null
+    vary: Array<string> | null
+}
+
+export interface RateLimit {
+    rps: number
+    burst: number | null
+    per_client: boolean | null
+}
+
+export interface Rewrite {
+    prefix: string
+    max_size: number | null
+}
+
+export interface Secrets {
+    delivery: string | null
+    keys: Array<string> | null
+    env: string | null
+}
+
+export interface Alert {
+    name: string
+    error_rate: number | null
+    consecutive_failures: number | null
+    latency_p95: JsonDuration | null
+    window: JsonDuration | null
+    min_calls: number | null
+    action: string | null
+    cool_off: JsonDuration | null
+    notify: string | null
+}
+
+export interface Mutation {
+    headers: any | null
+    body_defaults: any | null
+}
+
+export interface Chaining {
+    queue: string
+    transform: string | null
+    max_size: number | null
+    max_hops: number | null
+}
+
+export interface ChainStep {
+    lambda: string
+    condition: string | null
+}
+
+export interface ResourceLimits {
+    memory_mb: number | null
+    cpu_percent: number | null
+    open_files: number | null
+    processes: number | null
+}
+
+export interface BuildGate {
+    actions: Array<string> | null
+    timeout: JsonDuration | null
+    disabled: boolean | null
+}
+
+export interface SoftLimits {
+    payload: number | null
+    response: number | null
+    notify: string | null
+    interval: JsonDuration | null
+}
+
+export interface CORS {
+    origins: Array<string> | null
+    methods: Array<string> | null
+    headers: Array<string> | null
+    credentials: boolean | null
+    max_age: JsonDuration | null
+}
+
+export interface RawMessage {
+}
+
+export interface RunAs {
+    user: string
+    group: string | null
+}
+
+export interface Container {
+    image: string
+    entrypoint: Array<string> | null
+    mounts: Array<Mount> | null
+    network: boolean | null
+}
+
+export interface Mount {
+    source: string
+    target: string
+    writable: boolean | null
+}
+
+export interface Retry {
+    attempts: number
+    backoff: JsonDuration | null
+    max_backoff: JsonDuration | null
+    jitter: boolean | null
+}
+
+export interface Capture {
+    requests: number
+    max_bytes: number | null
+}
+
+export interface LogFiles {
+    enabled: boolean
+    max_size_mb: number | null
+    max_files: number | null
+}
+
+export interface WebhookSignature {
+    preset: string | null
+    header: string | null
+    algorithm: string | null
+    secret: string
+    encoding: string | null
+    prefix: string | null
+    tolerance: JsonDuration | null
+}
+
+export interface BasicAuth {
+    users: any
+    realm: string | null
+    links: Array<string> | null
+    env: string | null
+}
+
+export interface JWTPolicy {
+    issuers: Array<string> | null
+    audiences: Array<string> | null
+    secret: string | null
+    public_key: string | null
+    jwks: string | null
+    refresh: JsonDuration | null
+    leeway: JsonDuration | null
+    links: Array<string> | null
+    subject_env: string | null
+    env: any | null
+    headers: any | null
+}
+
+export interface LifecycleWebhook {
+    url: string
+    events: Array<string> | null
+    secret: string | null
+    payload: boolean | null
+}
+
+export interface GitSource {
+    repo: string
+    branch: string | null
+    dir: string | null
+    interval: JsonDuration | null
+    action: string | null
+    key: string | null
+    ping: WebhookSignature | null
+}
+
+export type Time = string; // RFC3339
+
+export interface ScheduleStatus {
+    name: string | null
+    cron: string
+    tz: string | null
+    action: string
+    enabled: boolean
+    next: Time | null
+    last_run: Time | null
+    last_result: string | null
+    last_error: string | null
+}
+
+export interface QueueStatus {
+    name: string
+    depth: number
+    in_flight: number
+    paused: boolean
+    oldest: Time | null
+    oldest_age_seconds: number
+    quarantined: number
+}
+
+export interface AlertStatus {
+    name: string
+    action: string
+    state: string
+    since: Time | null
+    reason: string | null
+    until: Time | null
+    events: Array<AlertEvent>
+}
+
+export interface AlertEvent {
+    time: Time
+    from: string
+    to: string
+    reason: string | null
+    by: string
+}
+
+export interface GitStatus {
+    repo: string
+    branch: string | null
+    commit: string | null
+    deployed: Time | null
+    checked: Time | null
+    failed: string | null
+    error: string | null
+    output: string | null
+    running: boolean | null
+}
+
+export interface DiskUsage {
+    size: number
+    quota: number | null
+    measured: Time
 }
 
 export interface Template {
@@ -73,22 +438,493 @@ export interface Record {
     request: Request
     begin: Time
     end: Time
+    rate: number | null
+    coalesced: boolean | null
+    cached: boolean | null
+    rejected: boolean | null
+    throttled: boolean | null
+    status: number | null
+    payload: number | null
+    size: number | null
+    cpu: Duration | null
+    max_rss: number | null
+    warning: string | null
+    limits: Array<string> | null
+    wait: Duration | null
+    overrun: boolean | null
+    output: Array<number> | null
+    stderr: Array<number> | null
+    queue: string | null
+    queue_wait: Duration | null
+    attempt: number | null
+    retried: boolean | null
+    catch_up: boolean | null
+    denied: boolean | null
+    timeout: boolean | null
+    trigger: string | null
+    spawn: Duration | null
+    first_byte: Duration | null
+    runtime: Duration | null
+    write: Duration | null
+    exit_code: number | null
+    signal: string | null
 }
 
 export interface Request {
+    id: string | null
     method: string
     url: string
     path: string
     remote_address: string
     form: any
     headers: any
+    public_url: string | null
+    alias: string | null
+    chain: Array<string> | null
+    queued: Time | null
+    user: string | null
+    claims: any | null
 }
 
-export type Time = string; // RFC3339
+export type Duration = string; // suffixes: ns, us, ms, s, m, h
+
+export interface Query {
+    uid: string | null
+    since: Time | null
+    until: Time | null
+    limit: number
+}
+
+export interface Aggregation {
+    uid: string | null
+    since: Time
+    until: Time
+    bucket: Duration
+    buckets: Array<Bucket>
+    total: Bucket
+    group: string | null
+    groups: Array<Group> | null
+}
+
+export interface Bucket {
+    begin: Time
+    calls: number
+    errors: Errors
+    latency: Latency
+}
+
+export interface Errors {
+    script: number
+    timeout: number
+    rejected: number
+    denied: number
+}
+
+export interface Latency {
+    min: Duration
+    avg: Duration
+    p50: Duration
+    p95: Duration
+    max: Duration
+}
+
+export interface Group {
+    key: string
+    buckets: Array<Bucket>
+    total: Bucket
+}
+
+export interface Usage {
+    records: number
+    size: number
+    segments: number
+    pending: number
+    oldest: Time
+    newest: Time
+    retention: Retention
+    pruning: Pruning
+}
+
+export interface Retention {
+    max_age: JsonDuration | null
+    max_records: number | null
+    max_size: number | null
+    lambdas: any | null
+}
+
+export interface Pruning {
+    time: Time
+    duration: JsonDuration
+    removed: number
+    early: number
+    total: number
+    behind: boolean
+    error: string | null
+}
+
+export interface CreateOptions {
+    template: string | null
+    name: string | null
+    slug: boolean | null
+    public_url: string | null
+}
+
+export interface DuplicateOptions {
+    name: string | null
+    no_aliases: boolean | null
+    no_schedules: boolean | null
+    no_secrets: boolean | null
+}
+
+export interface ServerInfo {
+    version: string
+    capabilities: Array<string>
+    config: Array<ConfigValue>
+    upload: UploadLimits | null
+}
+
+export interface ConfigValue {
+    name: string
+    value: string
+    source: string
+}
+
+export interface UploadLimits {
+    files: number | null
+    size: number | null
+    depth: number | null
+    file_size: number | null
+}
+
+export interface CapacityReport {
+    generated: Time
+    window: JsonDuration
+    covered: JsonDuration
+    records: number
+    partial: boolean | null
+    running: number
+    peak: number
+    lambdas: Array<LambdaCapacity>
+    stores: Array<StoreUsage>
+    queues: Array<QueueStatus>
+    projection: CapacityProjection
+}
+
+export interface LambdaCapacity {
+    uid: string
+    name: string | null
+    invocations: number
+    running: number
+    peak: number
+    busy: JsonDuration
+    cpu: JsonDuration
+    max_rss: number
+    disk: number
+    backlog: number
+}
+
+export interface StoreUsage {
+    name: string
+    path: string
+    size: number
+}
+
+export interface CapacityProjection {
+    cpus: number
+    memory: number
+    cpu_load: number
+    peak_memory: number
+    builds_cpu: number
+    builds_memory: number
+    active_lambdas: number
+    more_lambdas: number
+    limit: string | null
+}
+
+export interface MirrorStatus {
+    enabled: boolean
+    primary: string | null
+    promoted: Time | null
+    interval: JsonDuration | null
+    last_sync: Time | null
+    last_error: string | null
+    lambdas: number
+    syncs: number
+    failures: number
+    pulls: number
+    diverged: Array<MirrorDivergence> | null
+}
+
+export interface MirrorDivergence {
+    object: string
+    reason: string
+}
+
+export interface ChangesReport {
+    since: Time
+    until: Time
+    total: number
+    offset: number
+    next: number | null
+    groups: Array<ChangeGroup>
+}
+
+export interface ChangeGroup {
+    lambda: string | null
+    name: string | null
+    created: boolean | null
+    removed: boolean | null
+    summary: string
+    changes: Array<Change>
+}
+
+export interface Change {
+    id: number
+    time: Time
+    actor: string
+    kind: string
+    lambda: string | null
+    name: string | null
+    summary: string
+    previous: string | null
+    revision: string | null
+}
+
+export interface ChangeQuery {
+    since: Time
+    until: Time
+    lambda: string | null
+    kind: string | null
+}
+
+export interface ChangesPage {
+    query: ChangeQuery
+    total: number
+    offset: number
+    next: number | null
+    changes: Array<Change>
+}
+
+export interface SecurityReport {
+    profile: SecurityProfile
+    violations: number
+    lambdas: Array<LambdaDeviations>
+}
+
+export interface SecurityProfile {
+    name: string
+    deny_network: boolean | null
+    read_only: boolean | null
+    time_limit: JsonDuration | null
+    maximum_payload: number | null
+    maximum_response: number | null
+    max_concurrency: number | null
+    remove_headers: Array<string> | null
+    disable_debug: boolean | null
+    require_runner: boolean | null
+    mandatory: Array<string> | null
+    upload: UploadLimits
+    run_as_users: Array<string> | null
+    run_as_groups: Array<string> | null
+    max_grace_period: JsonDuration | null
+}
+
+export interface LambdaDeviations {
+    uid: string
+    name: string | null
+    deviations: Array<Deviation>
+}
+
+export interface Deviation {
+    rule: string
+    value: string
+    default: string
+    mandatory: boolean
+    violation: boolean
+}
+
+export interface LambdaRoutes {
+    uid: string
+    name: string | null
+    methods: Array<string> | null
+    maximum_payload: number | null
+    routes: Array<Route>
+}
+
+export interface Route {
+    kind: string
+    name: string
+    path: string
+    declared: boolean | null
+    outdated: boolean | null
+}
+
+export interface Document {
+    openapi: string
+    info: Info
+    servers: Array<Server> | null
+    tags: Array<Tag> | null
+    paths: any
+    components: Components | null
+}
+
+export interface Info {
+    title: string
+    description: string | null
+    version: string
+}
+
+export interface Server {
+    url: string
+}
+
+export interface Tag {
+    name: string
+    description: string | null
+}
+
+export interface Components {
+    securitySchemes: any | null
+}
+
+export interface SearchResult {
+    query: string
+    total: number
+    incomplete: boolean | null
+    hits: Array<SearchHit>
+}
+
+export interface SearchHit {
+    uid: string
+    name: string | null
+    score: number
+    highlights: Array<SearchHighlight>
+}
+
+export interface SearchHighlight {
+    field: string
+    fragments: Array<SnippetFragment>
+}
+
+export interface SnippetFragment {
+    text: string
+    match: boolean | null
+}
+
+export interface TransferReport {
+    definition: Definition
+    skipped: Array<string> | null
+}
+
+export interface TransferRequest {
+    id: string | null
+    source: string
+    grant: TransferGrant
+}
+
+export interface TransferGrant {
+    uid: string
+    token: string
+    hash: string
+    secrets: boolean
+    expires: Time
+}
+
+export interface TransferProgress {
+    id: string
+    stage: string
+    size: number | null
+    error: string | null
+}
+
+export interface Secret {
+    name: string
+    created: Time
+    updated: Time
+    lambdas: Array<string> | null
+}
+
+export interface WebhookDelivery {
+    event: string
+    type: string
+    lambda: string | null
+    url: string
+    attempt: number
+    time: Time
+    status: number | null
+    error: string | null
+    retry: Time | null
+    duration_ms: number
+}
+
+export interface BackupOptions {
+    no_tokens: boolean | null
+    frozen: boolean | null
+}
+
+export interface RestoreOptions {
+    dry_run: boolean | null
+    conflict: string | null
+    no_tokens: boolean | null
+}
 
+export interface RestoreReport {
+    dry_run: boolean
+    header: BackupHeader
+    items: Array<RestoreItem>
+}
+
+export interface BackupHeader {
+    format: number
+    version: string | null
+    created: Time
+    lambdas: number
+    tokens: boolean | null
+    secrets: boolean | null
+}
+
+export interface RestoreItem {
+    kind: string
+    name: string
+    action: string
+    target: string | null
+    conflict: boolean | null
+    reason: string | null
+    frozen: string | null
+}
+
+export interface ArtifactImport {
+    url: string
+    sha256: string | null
+    uid: string | null
+    action: string | null
+}
+
+export interface ArtifactReport {
+    uid: string
+    created: boolean | null
+    sha256: string
+    size: number
+    action: string | null
+    warning: string | null
+}
+
+export interface ReloadReport {
+    items: Array<ReloadItem>
+}
+
+export interface ReloadItem {
+    target: string
+    applied: boolean | null
+    files: boolean | null
+    error: string | null
+}
 
 
 
+export type Duration = string; // suffixes: ns, us, ms, s, m, h
+
+
 // support stuff
 
 
@@ -285,6 +1121,19 @@ export class ProjectAPI {
         })) as Settings;
     }
 
+    /**
+    Set and remove variables of global environment without changing the rest. Returns updated global environment.
+Applied for the next invocation of every lambda
+    **/
+    async updateEnvironment(token: Token, set: Environment, unset: Array<string>): Promise<Environment> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.UpdateEnvironment",
+            "id" : this.__next_id(),
+            "params" : [token, set, unset]
+        })) as Environment;
+    }
+
     /**
     Get all templates without filtering
     **/
@@ -333,6 +1182,57 @@ export class ProjectAPI {
         })) as Array<Record>;
     }
 
+    /**
+    Global records in time range (optionally of one lambda) from the newest
+    **/
+    async statsRange(token: Token, query: Query): Promise<Array<Record>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.StatsRange",
+            "id" : this.__next_id(),
+            "params" : [token, query]
+        })) as Array<Record>;
+    }
+
+    /**
+    Global calls, errors by category and latency percentiles (optionally of one lambda) by buckets of time range
+(zero bucket - automatic size), optionally also by group: alias or trigger (empty - no groups)
+    **/
+    async statsAggregate(token: Token, query: Query, bucket: JsonDuration, group: string): Promise<Aggregation> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.StatsAggregate",
+            "id" : this.__next_id(),
+            "params" : [token, query, bucket, group]
+        })) as Aggregation;
+    }
+
+    /**
+    Usage of storage of stats: records, size, the oldest record, retention and the last pruning (behind - pruning
+doesn't keep up with limits)
+    **/
+    async statsUsage(token: Token): Promise<Usage> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.StatsUsage",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as Usage;
+    }
+
+    /**
+    Change retention of stats without restart: server limits and limits of lambdas by UID (zero - not limited).
+Records over new limits are pruned in background
+    **/
+    async setStatsRetention(token: Token, retention: Retention): Promise<Usage> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.SetStatsRetention",
+            "id" : this.__next_id(),
+            "params" : [token, retention]
+        })) as Usage;
+    }
+
     /**
     Create new app (lambda)
     **/
@@ -346,7 +1246,8 @@ export class ProjectAPI {
     }
 
     /**
-    Create new app/lambda/function using pre-defined template
+    Create new app/lambda/function using pre-defined template. Invalid manifest is error with code 422 (see Update)
+Resolved outputs of template (ex: example of call) are in outputs field
     **/
     async createFromTemplate(token: Token, templateName: string): Promise<Definition> {
         return (await this.__call({
@@ -358,7 +1259,7 @@ export class ProjectAPI {
     }
 
     /**
-    Create new app/lambda/function using remote Git repo
+    Create new app/lambda/function using remote Git repo. Invalid manifest is error with code 422 (see Update)
     **/
     async createFromGit(token: Token, repo: string): Promise<Definition> {
         return (await this.__call({
@@ -369,6 +1270,295 @@ export class ProjectAPI {
         })) as Definition;
     }
 
+    /**
+    Create new app/lambda/function from template (empty - default manifest) with name and optional slug alias
+Resolved outputs of template are in outputs field
+    **/
+    async createWithOptions(token: Token, options: CreateOptions): Promise<Definition> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.CreateWithOptions",
+            "id" : this.__next_id(),
+            "params" : [token, options]
+        })) as Definition;
+    }
+
+    /**
+    Duplicate lambda on server: files (streamed), manifest and environment are copied to new lambda without shared
+state, optionally without aliases, schedules or references to secrets. Aliases bound to other lambdas (ex: to the
+source) are not declared by copy: they are in warning of copy
+    **/
+    async duplicate(token: Token, uid: string, options: DuplicateOptions): Promise<Definition> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Duplicate",
+            "id" : this.__next_id(),
+            "params" : [token, uid, options]
+        })) as Definition;
+    }
+
+    /**
+    Server information: version, capabilities and effective configuration (same as `trusted-cgi info`)
+    **/
+    async capabilities(token: Token): Promise<ServerInfo> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Capabilities",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as ServerInfo;
+    }
+
+    /**
+    Capacity report: usage of lambdas aggregated over window (zero - one hour) by stats records, disk usage, queue
+backlogs and naive projection of headroom
+    **/
+    async capacity(token: Token, window: JsonDuration): Promise<CapacityReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Capacity",
+            "id" : this.__next_id(),
+            "params" : [token, window]
+        })) as CapacityReport;
+    }
+
+    /**
+    Status of replication if server is read-only mirror of primary server (disabled status otherwise)
+    **/
+    async mirror(token: Token): Promise<MirrorStatus> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Mirror",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as MirrorStatus;
+    }
+
+    /**
+    Promote read-only mirror to primary: replication is stopped and mutating API is enabled
+    **/
+    async promote(token: Token): Promise<MirrorStatus> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Promote",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as MirrorStatus;
+    }
+
+    /**
+    Administrative changes (deploys, manifest edits, aliases, policies, users and settings) in time range
+[since, until) (zero until - till now) grouped by lambda. Page is defined by offset and limit (zero - 100) of changes
+    **/
+    async changes(token: Token, since: Time, until: Time, offset: number, limit: number): Promise<ChangesReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Changes",
+            "id" : this.__next_id(),
+            "params" : [token, since, until, offset, limit]
+        })) as ChangesReport;
+    }
+
+    /**
+    Audit log: changes and failed authentications matched by query (time range with zero until - till now, lambda
+by UID or alias, kind) in order of recording. Page is defined by offset and limit (zero - 100)
+    **/
+    async audit(token: Token, query: ChangeQuery, offset: number, limit: number): Promise<ChangesPage> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Audit",
+            "id" : this.__next_id(),
+            "params" : [token, query, offset, limit]
+        })) as ChangesPage;
+    }
+
+    /**
+    Active security profile and options of lambdas different from its defaults (including violations of mandatory
+rules by manifests uploaded before the profile)
+    **/
+    async security(token: Token): Promise<SecurityReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Security",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as SecurityReport;
+    }
+
+    /**
+    Canonical public invocation paths of lambdas (by UID, slug, aliases and linked queues) ordered by UID, for
+generation of reverse-proxy configuration
+    **/
+    async routes(token: Token): Promise<Array<LambdaRoutes>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Routes",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as Array<LambdaRoutes>;
+    }
+
+    /**
+    OpenAPI 3.1 document of public routes of lambdas (by UID, slug and aliases) generated by current manifests:
+methods, content types and input schema of requests, output headers of responses. Lambda is UID of the only
+lambda in document (empty - all lambdas). Public URL is base URL of server seen by client (ignored if server has
+configured one, empty - document without servers)
+    **/
+    async openAPI(token: Token, lambda: string, publicURL: string): Promise<Document> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.OpenAPI",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, publicURL]
+        })) as Document;
+    }
+
+    /**
+    Full-text search of lambdas by names, descriptions, aliases and labels: lambdas matching all words of query
+(word matches the same word or beginning of longer word) ordered by relevance with highlighted matched words.
+Number of hits is limited (zero - 20)
+    **/
+    async search(token: Token, query: string, limit: number): Promise<SearchResult> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Search",
+            "id" : this.__next_id(),
+            "params" : [token, query, limit]
+        })) as SearchResult;
+    }
+
+    /**
+    Transfer app from another server: export is pulled from source by grant (see LambdaAPI.GrantExport) and content
+is verified by hash of grant. App keeps UID, manifest (including schedules) and aliases which are free, nothing
+is created on failure. Existent UID is error with code 409
+    **/
+    async transfer(token: Token, request: TransferRequest): Promise<TransferReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Transfer",
+            "id" : this.__next_id(),
+            "params" : [token, request]
+        })) as TransferReport;
+    }
+
+    /**
+    Progress of running or recently finished transfer by ID of request
+    **/
+    async transferProgress(token: Token, id: string): Promise<TransferProgress> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.TransferProgress",
+            "id" : this.__next_id(),
+            "params" : [token, id]
+        })) as TransferProgress;
+    }
+
+    /**
+    Secrets of server store ordered by name: metadata and lambdas which environment references them (values are
+never returned). Disabled store is error
+    **/
+    async secrets(token: Token): Promise<Array<Secret>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Secrets",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as Array<Secret>;
+    }
+
+    /**
+    Set value of secret of server store (created if not exists). Value is write-only
+    **/
+    async setSecret(token: Token, name: string, value: string): Promise<Secret> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.SetSecret",
+            "id" : this.__next_id(),
+            "params" : [token, name, value]
+        })) as Secret;
+    }
+
+    /**
+    Remove secret of server store. Lambdas which still reference it are in result: their variables become empty
+    **/
+    async removeSecret(token: Token, name: string): Promise<Secret> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.RemoveSecret",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as Secret;
+    }
+
+    /**
+    Recent attempts of deliveries of lifecycle events to webhooks of server and lambdas from the newest, including
+dropped events (zero attempt). Number of attempts is limited (zero - all kept attempts)
+    **/
+    async webhookDeliveries(token: Token, limit: number): Promise<Array<WebhookDelivery>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.WebhookDeliveries",
+            "id" : this.__next_id(),
+            "params" : [token, limit]
+        })) as Array<WebhookDelivery>;
+    }
+
+    /**
+    Backup of server as .tar.gz archive: lambdas (content, manifests, aliases, state), policies, queues, API tokens
+(hashes of values, unless excluded), sealed secrets and settings
+    **/
+    async backup(token: Token, options: BackupOptions): Promise<Array<number>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Backup",
+            "id" : this.__next_id(),
+            "params" : [token, options]
+        })) as Array<number>;
+    }
+
+    /**
+    Restore server from backup archive (see Backup): items which do not exist are created, existent items are
+conflicts handled by options (skip by default, fail is error with code 409 and conflicts in data). Dry run
+returns plan without changes
+    **/
+    async restore(token: Token, archive: Array<number>, options: RestoreOptions): Promise<RestoreReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Restore",
+            "id" : this.__next_id(),
+            "params" : [token, archive, options]
+        })) as RestoreReport;
+    }
+
+    /**
+    Import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent lambda
+(by UID). Archive is extracted to staged copy, manifest of archive (or template.json) is applied and post-clone
+action is run there: failed import does not change lambda. Archive over upload limit is error with code 413,
+unknown format - 415, invalid manifest - 422
+    **/
+    async importURL(token: Token, request: ArtifactImport): Promise<ArtifactReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.ImportURL",
+            "id" : this.__next_id(),
+            "params" : [token, request]
+        })) as ArtifactReport;
+    }
+
+    /**
+    Reload files edited on disk out of server: project config, security profile, manifests and changed files of all
+lambdas (empty UID) or manifest and files of one lambda. Invalid files are not applied and reported in items,
+the last good state is kept. For setups without watcher of files
+    **/
+    async reload(token: Token, uid: string): Promise<ReloadReport> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Reload",
+            "id" : this.__next_id(),
+            "params" : [token, uid]
+        })) as ReloadReport;
+    }
+
 
     private __next_id() {
         this.__id += 1;
@@ -22,6 +22,55 @@ export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
 
 export type Token = string;
 
+export interface QueueMessage {
+    queue: string
+    empty: boolean
+    hops: number
+    request: Request | null
+    quarantined: number
+}
+
+export interface Request {
+    id: string | null
+    method: string
+    url: string
+    path: string
+    remote_address: string
+    form: any
+    headers: any
+    public_url: string | null
+    alias: string | null
+    chain: Array<string> | null
+    queued: Time | null
+    user: string | null
+    claims: any | null
+}
+
+export type Time = string; // RFC3339
+
+export interface AsyncInvocation {
+    ticket: string
+    method: string
+    path: string
+    size: number
+    accepted: Time
+    in_flight: boolean | null
+}
+
+export interface DeadLetter {
+    id: string
+    lambda: string
+    queue: string
+    schedule: string | null
+    error: string
+    attempts: number
+    first: Time
+    failed: Time
+    size: number
+    request: Request
+    body: Array<number> | null
+}
+
 
 
 
@@ -245,6 +294,119 @@ export class QueuesAPI {
         })) as boolean;
     }
 
+    /**
+    Oldest message of queue without consuming it: headers and chain of lambdas which produced message (body is
+not returned)
+    **/
+    async inspect(token: Token, name: string): Promise<QueueMessage> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Inspect",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as QueueMessage;
+    }
+
+    /**
+    Return quarantined (corrupted) messages of queue to the end of queue, returns number of returned messages
+    **/
+    async retryQuarantined(token: Token, name: string): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.RetryQuarantined",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as number;
+    }
+
+    /**
+    Remove quarantined (corrupted) messages of queue, returns number of removed messages
+    **/
+    async purgeQuarantined(token: Token, name: string): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.PurgeQuarantined",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as number;
+    }
+
+    /**
+    Pending asynchronous invocations (including in-flight) in order of acceptance, empty if asynchronous
+invocations are disabled
+    **/
+    async async(token: Token): Promise<Array<AsyncInvocation>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Async",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as Array<AsyncInvocation>;
+    }
+
+    /**
+    Remove pending asynchronous invocations by tickets (empty - all pending), in-flight invocations are not
+interrupted. Returns number of removed invocations
+    **/
+    async purgeAsync(token: Token, tickets: Array<string>): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.PurgeAsync",
+            "id" : this.__next_id(),
+            "params" : [token, tickets]
+        })) as number;
+    }
+
+    /**
+    Dead letters of lambda (queued requests not processed after all attempts) from the newest, body is not
+returned
+    **/
+    async deadLetters(token: Token, lambda: string): Promise<Array<DeadLetter>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, lambda]
+        })) as Array<DeadLetter>;
+    }
+
+    /**
+    Dead letter of lambda with body
+    **/
+    async deadLetter(token: Token, lambda: string, id: string): Promise<DeadLetter> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeadLetter",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, id]
+        })) as DeadLetter;
+    }
+
+    /**
+    Remove dead letters of lambda by IDs (empty - all), returns number of removed letters
+    **/
+    async removeDeadLetters(token: Token, lambda: string, ids: Array<string>): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.RemoveDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, ids]
+        })) as number;
+    }
+
+    /**
+    Put dead letters of lambda by IDs (empty - all) back to their queues and remove them, returns number of
+returned letters
+    **/
+    async redriveDeadLetters(token: Token, lambda: string, ids: Array<string>): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.RedriveDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, lambda, ids]
+        })) as number;
+    }
+
 
     private __next_id() {
         this.__id += 1;
@@ -12,6 +12,32 @@ export class UserAPIError extends Error {
 
 export type Token = string;
 
+export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
+
+export interface Scope {
+    lambdas: Array<string> | null
+    operations: Array<string>
+}
+
+export interface TokenInfo {
+    id: string
+    name: string
+    scope: Scope
+    created: Time
+    expires: Time | null
+    replaced_by: string | null
+    token: string | null
+}
+
+export type Time = string; // RFC3339
+
+export interface Lockout {
+    subject: string
+    kind: string
+    failures: number
+    locked_until: Time | null
+}
+
 
 
 
@@ -199,6 +225,81 @@ export class UserAPI {
         })) as boolean;
     }
 
+    /**
+    Create long-lived API token limited by scope (lambdas and operations) with time to live (zero - never expires).
+Value of token is returned only once
+    **/
+    async createToken(token: Token, name: string, scope: Scope, ttl: JsonDuration): Promise<TokenInfo> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.CreateToken",
+            "id" : this.__next_id(),
+            "params" : [token, name, scope, ttl]
+        })) as TokenInfo;
+    }
+
+    /**
+    Issue replacement of API token with the same name, scope and time to live. Replaced token is valid for overlap
+(zero - one hour) to switch automation without downtime
+    **/
+    async rotateToken(token: Token, id: string, overlap: JsonDuration): Promise<TokenInfo> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.RotateToken",
+            "id" : this.__next_id(),
+            "params" : [token, id, overlap]
+        })) as TokenInfo;
+    }
+
+    /**
+    API tokens with scopes and expiration (without values). Tokens expired more than 7 days ago are removed
+    **/
+    async tokens(token: Token): Promise<Array<TokenInfo>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.Tokens",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as Array<TokenInfo>;
+    }
+
+    /**
+    Revoke API token by ID
+    **/
+    async revokeToken(token: Token, id: string): Promise<boolean> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.RevokeToken",
+            "id" : this.__next_id(),
+            "params" : [token, id]
+        })) as boolean;
+    }
+
+    /**
+    Accounts and client addresses with failed logins, including locked out ones
+    **/
+    async lockouts(token: Token): Promise<Array<Lockout>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.Lockouts",
+            "id" : this.__next_id(),
+            "params" : [token]
+        })) as Array<Lockout>;
+    }
+
+    /**
+    Clear lockout and failed logins of account or client address (empty - all), returns number of cleared subjects.
+Could be called by local admin socket without token (ex: admin account is locked out)
+    **/
+    async unlock(token: Token, subject: string): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "UserAPI.Unlock",
+            "id" : this.__next_id(),
+            "params" : [token, subject]
+        })) as number;
+    }
+
 
     private __next_id() {
         this.__id += 1;
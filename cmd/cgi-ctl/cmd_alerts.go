@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+type alerts struct {
+	List  alertsList  `command:"ls" description:"show state of alert rules of the lambda"`
+	Reset alertsReset `command:"reset" description:"reset fired alert rules (all by default) and re-enable the lambda"`
+}
+
+type alertsList struct {
+	remoteLink
+	uidLocator
+	Events bool `short:"e" long:"events" env:"EVENTS" description:"show recent state changes of rules"`
+}
+
+func (cmd *alertsList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	def, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("get lambda info: %w", err)
+	}
+	return printAlerts(newAlertsResult(cmd.UID, def.Alerts), cmd.Events)
+}
+
+type alertsReset struct {
+	remoteLink
+	uidLocator
+	Args struct {
+		Rule string `positional-arg-name:"rule" description:"name of alert rule (default - all rules)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *alertsReset) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("resetting alerts of lambda", cmd.UID)
+	list, err := cmd.Lambdas().ResetAlerts(ctx, token, cmd.UID, cmd.Args.Rule)
+	if err != nil {
+		return fmt.Errorf("reset alerts: %w", err)
+	}
+	return printAlerts(newAlertsResult(cmd.UID, list), false)
+}
+
+func printAlerts(result alertsResult, events bool) error {
+	if globalOptions.JSON {
+		return printJSON(result)
+	}
+	if len(result.Alerts) == 0 {
+		log.Println("no alert rules")
+		return nil
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	writeAlerts(out, result.Alerts, "")
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if !events {
+		return nil
+	}
+	fmt.Println()
+	out = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "TIME\tRULE\tFROM\tTO\tBY\tREASON")
+	for _, alert := range result.Alerts {
+		for _, event := range alert.Events {
+			_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\n", formatTime(event.Time), alert.Name, event.From, event.To,
+				event.By, dash(event.Reason))
+		}
+	}
+	return out.Flush()
+}
+
+// table of alert rules with the indent (describe prints alerts as section)
+func writeAlerts(out *tabwriter.Writer, list []application.AlertStatus, indent string) {
+	_, _ = fmt.Fprintln(out, indent+"NAME\tACTION\tSTATE\tSINCE\tUNTIL\tREASON")
+	for _, alert := range list {
+		_, _ = fmt.Fprintf(out, "%s%s\t%s\t%s\t%s\t%s\t%s\n", indent, alert.Name, alert.Action, alert.State,
+			formatTime(alert.Since), formatTime(alert.Until), dash(alert.Reason))
+	}
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -5,20 +5,36 @@ import (
 	"fmt"
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
 	"log"
+	"sort"
+	"strings"
+	"time"
 )
 
 type alias struct {
+	Add    aliasAdd    `command:"add" description:"add aliases to the lambda"`
+	Remove aliasRemove `command:"rm" description:"remove aliases"`
+	List   aliasList   `command:"ls" description:"list aliases of the lambda (or all aliases on the server outside of project)"`
+	Slug   aliasSlug   `command:"slug" description:"generate DNS-safe alias (slug) from name of the lambda, previous slug is kept as alias"`
+}
+
+type aliasNames struct {
+	Aliases []string `positional-arg-name:"name" required:"1" description:"links/aliases names"`
+}
+
+type aliasBase struct {
 	remoteLink
+}
+
+type aliasAdd struct {
+	aliasBase
 	uidLocator
-	Delete bool `short:"d" long:"delete" env:"DELETE" description:"delete links, otherwise add"`
-	Keep   bool `long:"keep" env:"KEEP" description:"do not update (if it exists) local manifest file"`
-	Args   struct {
-		Aliases []string `name:"aliases" positional-arg:"alias" description:"links/aliases names"`
-	} `positional-args:"yes"`
+	Args aliasNames `positional-args:"yes"`
 }
 
-func (cmd *alias) Execute(args []string) error {
+func (cmd *aliasAdd) Execute(args []string) error {
 	ctx, closer := internal.SignalContext()
 	defer closer()
 	if err := cmd.parseUID(); err != nil {
@@ -30,44 +46,193 @@ func (cmd *alias) Execute(args []string) error {
 	if err != nil {
 		return fmt.Errorf("login: %w", err)
 	}
-	if len(cmd.Args.Aliases) == 0 {
-		return cmd.printAliases(ctx, token)
+	var result []aliasLink
+	for _, name := range cmd.Args.Aliases {
+		log.Println("adding alias", name)
+		// server rejects alias bound to other lambda with the name of the conflicting lambda
+		def, err := cmd.Lambdas().Link(ctx, token, cmd.UID, name)
+		if err != nil {
+			return fmt.Errorf("add alias %s: %w", name, err)
+		}
+		result = append(result, aliasLink{Alias: name, UID: cmd.UID, Overrides: def.AliasOverrides[name]})
 	}
-	if cmd.Delete {
-		return cmd.removeAliases(ctx, token)
+	return cmd.print(result)
+}
+
+type aliasRemove struct {
+	aliasBase
+	Args aliasNames `positional-args:"yes"`
+}
+
+func (cmd *aliasRemove) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
 	}
-	for _, alias := range cmd.Args.Aliases {
-		log.Println("adding alias", alias)
-		_, err := cmd.Lambdas().Link(ctx, token, cmd.UID, alias)
+	var result []aliasLink
+	for _, name := range cmd.Args.Aliases {
+		log.Println("removing alias", name)
+		def, err := cmd.Lambdas().Unlink(ctx, token, name)
 		if err != nil {
-			return fmt.Errorf("add alias %s: %w", alias, err)
+			return fmt.Errorf("remove alias %s: %w", name, err)
 		}
+		result = append(result, aliasLink{Alias: name, UID: def.UID})
 	}
-	return nil
+	return cmd.print(result)
 }
 
-func (cmd *alias) printAliases(ctx context.Context, token *api.Token) error {
-	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+type aliasSlug struct {
+	aliasBase
+	uidLocator
+}
+
+func (cmd *aliasSlug) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("lambda", cmd.UID)
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
 	if err != nil {
-		return fmt.Errorf("list aliases: %w", err)
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("generating slug...")
+	def, err := cmd.Lambdas().RegenerateSlug(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("generate slug: %w", err)
 	}
-	if len(info.Aliases) > 0 {
-		for name := range info.Aliases {
-			fmt.Println(name)
+	return cmd.print([]aliasLink{{Alias: def.Slug, UID: def.UID}})
+}
+
+type aliasList struct {
+	aliasBase
+	uidLocator
+	Since string `short:"s" long:"since" env:"SINCE" description:"count requests by aliases since: date (2006-01-02), RFC3339 time or duration ago" default:"24h"`
+}
+
+func (cmd *aliasList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	var cf controlFile
+	projectContext := cmd.UID != "" || cf.Read(controlFilename) == nil
+	if projectContext {
+		if err := cmd.parseUID(); err != nil {
+			return err
 		}
+		log.Println("lambda", cmd.UID)
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	var result []aliasLink
+	if projectContext {
+		result, err = cmd.lambdaAliases(ctx, token)
 	} else {
+		result, err = cmd.allAliases(ctx, token)
+	}
+	if err != nil {
+		return fmt.Errorf("list aliases: %w", err)
+	}
+	if len(result) > 0 {
+		if err := cmd.countCalls(ctx, token, projectContext, result); err != nil {
+			// ex: token without read-stats operation
+			log.Println("[WARN] count requests by aliases:", err)
+		}
+	}
+	if len(result) == 0 && !globalOptions.JSON {
 		log.Println("no available aliases")
+		return nil
+	}
+	return cmd.print(result)
+}
+
+func (cmd *aliasList) lambdaAliases(ctx context.Context, token *api.Token) ([]aliasLink, error) {
+	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return nil, err
+	}
+	var ans = make([]aliasLink, 0, len(info.Aliases))
+	for name := range info.Aliases {
+		ans = append(ans, aliasLink{Alias: name, UID: info.UID, Overrides: info.AliasOverrides[name]})
+	}
+	sortAliases(ans)
+	return ans, nil
+}
+
+func (cmd *aliasList) allAliases(ctx context.Context, token *api.Token) ([]aliasLink, error) {
+	list, err := cmd.Project().List(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	var ans = make([]aliasLink, 0)
+	for _, def := range list {
+		for name := range def.Aliases {
+			ans = append(ans, aliasLink{Alias: name, UID: def.UID, Overrides: def.AliasOverrides[name]})
+		}
+	}
+	sortAliases(ans)
+	return ans, nil
+}
+
+// requests by aliases of links are aggregated by server in one bucket of window
+func (cmd *aliasList) countCalls(ctx context.Context, token *api.Token, projectContext bool, links []aliasLink) error {
+	now := time.Now()
+	since, err := parseMoment(cmd.Since, now, false)
+	if err != nil {
+		return fmt.Errorf("since: %w", err)
+	}
+	query := stats.Query{Since: since, Until: now}
+	bucket := types.JsonDuration(now.Sub(since))
+	var aggregation *stats.Aggregation
+	if projectContext {
+		aggregation, err = cmd.Lambdas().StatsAggregate(ctx, token, cmd.UID, query, bucket, stats.GroupByAlias)
+	} else {
+		aggregation, err = cmd.Project().StatsAggregate(ctx, token, query, bucket, stats.GroupByAlias)
+	}
+	if err != nil {
+		return err
+	}
+	var calls = make(map[string]int64, len(aggregation.Groups))
+	for _, group := range aggregation.Groups {
+		calls[group.Key] = group.Total.Calls
+	}
+	for i := range links {
+		count := calls[links[i].Alias]
+		links[i].Calls = &count
 	}
 	return nil
 }
 
-func (cmd *alias) removeAliases(ctx context.Context, token *api.Token) error {
-	for _, alias := range cmd.Args.Aliases {
-		log.Println("removing alias", alias)
-		_, err := cmd.Lambdas().Unlink(ctx, token, alias)
-		if err != nil {
-			return fmt.Errorf("remove alias %s: %w", alias, err)
+func (cmd *aliasBase) print(links []aliasLink) error {
+	if globalOptions.JSON {
+		if links == nil {
+			links = []aliasLink{}
 		}
+		return printJSON(links)
+	}
+	for _, link := range links {
+		fields := []interface{}{link.Alias, link.UID}
+		if link.Calls != nil {
+			fields = append(fields, fmt.Sprintf("%d calls", *link.Calls))
+		}
+		if len(link.Overrides) > 0 {
+			// policy of alias overrides access settings of lambda
+			fields = append(fields, strings.Join(link.Overrides, ","))
+		}
+		fmt.Println(fields...)
 	}
 	return nil
 }
+
+func sortAliases(links []aliasLink) {
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Alias < links[j].Alias
+	})
+}
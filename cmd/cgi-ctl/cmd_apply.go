@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/cmd/internal"
-	internal2 "github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/types"
 	"log"
 )
@@ -11,6 +12,7 @@ import (
 type apply struct {
 	remoteLink
 	uidLocator
+	manifestSync
 }
 
 func (cmd *apply) Execute(args []string) error {
@@ -22,7 +24,7 @@ func (cmd *apply) Execute(args []string) error {
 	log.Println("lambda", cmd.UID)
 
 	var manifest types.Manifest
-	if err := manifest.LoadFrom(internal2.ManifestFile); err != nil {
+	if err := loadLocalManifest(&manifest); err != nil {
 		return fmt.Errorf("load local manifest: %w", err)
 	}
 
@@ -31,11 +33,32 @@ func (cmd *apply) Execute(args []string) error {
 	if err != nil {
 		return fmt.Errorf("login: %w", err)
 	}
-	log.Println("pushing manifest...")
-	_, err = cmd.Lambdas().Update(ctx, token, cmd.UID, manifest)
+	manifest, err = cmd.push(ctx, token, manifest)
 	if err != nil {
-		return fmt.Errorf("update remote manifest: %w", err)
+		return err
 	}
 	log.Println("done")
-	return nil
+	return printResult(manifestResult{UID: cmd.UID, Manifest: manifest})
+}
+
+// reconcile local manifest with remote one, push the result and remember it as synchronized state
+func (cmd *apply) push(ctx context.Context, token *api.Token, manifest types.Manifest) (types.Manifest, error) {
+	log.Println("checking remote manifest...")
+	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return manifest, fmt.Errorf("get info: %w", err)
+	}
+	manifest, err = cmd.syncLocal(manifest, info.Manifest)
+	if err != nil {
+		return manifest, err
+	}
+	log.Println("pushing manifest...")
+	updated, err := cmd.Lambdas().Update(ctx, token, cmd.UID, manifest, cmd.ForceAliases)
+	if err != nil {
+		return manifest, fmt.Errorf("update remote manifest: %w", err)
+	}
+	if updated.SlugOutdated {
+		log.Println("slug", updated.Slug, "is generated from the previous name, regenerate it by: cgi-ctl alias slug (the old slug is kept as alias)")
+	}
+	return manifest, saveBase(manifest)
 }
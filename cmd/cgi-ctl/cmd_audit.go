@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type auditCmd struct {
+	remoteLink
+	Since  string `short:"s" long:"since" env:"SINCE" description:"beginning of range: date (2006-01-02), RFC3339 time or duration ago (ex: 24h)" default:"24h"`
+	Until  string `long:"until" env:"UNTIL" description:"end of range: date (inclusive), RFC3339 time or duration ago (empty - now)"`
+	Lambda string `long:"lambda" env:"LAMBDA" description:"only entries of lambda by UID or alias (removed lambdas - by UID)"`
+	Kind   string `short:"k" long:"kind" env:"KIND" description:"only entries of kind (ex: deploy, manifest, auth)"`
+	Offset int    `long:"offset" env:"OFFSET" description:"offset of the first entry (see next page in output)"`
+	Limit  int    `short:"n" long:"limit" env:"LIMIT" description:"number of entries on page" default:"100"`
+	All    bool   `short:"a" long:"all" env:"ALL" description:"fetch all pages of range"`
+}
+
+func (cmd *auditCmd) Execute(args []string) error {
+	now := time.Now()
+	query := application.ChangeQuery{Lambda: cmd.Lambda, Kind: cmd.Kind}
+	var err error
+	query.Since, err = parseMoment(cmd.Since, now, false)
+	if err != nil {
+		return fmt.Errorf("parse since: %w", err)
+	}
+	if cmd.Until != "" {
+		query.Until, err = parseMoment(cmd.Until, now, true)
+		if err != nil {
+			return fmt.Errorf("parse until: %w", err)
+		}
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	page, err := cmd.Project().Audit(ctx, token, query, cmd.Offset, cmd.Limit)
+	if err != nil {
+		return fmt.Errorf("get audit log: %w", err)
+	}
+	if cmd.All {
+		// the same query (with end of range set by server) for all pages: entries recorded while fetching are not mixed in
+		for page.Next > 0 {
+			next, err := cmd.Project().Audit(ctx, token, page.Query, page.Next, cmd.Limit)
+			if err != nil {
+				return fmt.Errorf("get audit log from %d: %w", page.Next, err)
+			}
+			page.Changes = append(page.Changes, next.Changes...)
+			page.Next = next.Next
+		}
+	}
+	if globalOptions.JSON {
+		return printJSON(page)
+	}
+	return printAudit(page)
+}
+
+func printAudit(page *application.ChangesPage) error {
+	fmt.Println("entries from", formatTime(page.Query.Since), "till", formatTime(page.Query.Until)+":", page.Total)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "ID\tTIME\tACTOR\tKIND\tLAMBDA\tSUMMARY")
+	for _, change := range page.Changes {
+		_, _ = fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%s\n", change.ID, formatTime(change.Time), dash(change.Actor), change.Kind, dash(change.Lambda), change.Summary)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if page.Next > 0 {
+		fmt.Println()
+		fmt.Println("... next page: --offset", page.Next, "(or --all for the whole range)")
+	}
+	return nil
+}
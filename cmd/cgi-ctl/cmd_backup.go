@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+type backupCmd struct {
+	remoteLink
+	NoTokens bool   `long:"no-tokens" env:"NO_TOKENS" description:"do not include API tokens"`
+	Frozen   bool   `long:"frozen" env:"FROZEN" description:"include the newest frozen artifact of each lambda"`
+	Output   string `short:"o" long:"output" env:"OUTPUT" description:"output file (empty - stdout)"`
+}
+
+func (cmd *backupCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("backup...")
+	archive, err := cmd.Project().Backup(ctx, token, application.BackupOptions{NoTokens: cmd.NoTokens, Frozen: cmd.Frozen})
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if cmd.Output != "" {
+		log.Println("backup of", len(archive), "bytes saved to", cmd.Output)
+		return ioutil.WriteFile(cmd.Output, archive, 0600)
+	}
+	_, err = os.Stdout.Write(archive)
+	return err
+}
+
+type restoreCmd struct {
+	remoteLink
+	To       string `long:"to" env:"TO" description:"destination: name of remote from control file or URL (empty - by --url or control file)"`
+	DryRun   bool   `long:"dry-run" env:"DRY_RUN" description:"show what would be restored without changes"`
+	Conflict string `long:"conflict" env:"CONFLICT" description:"handling of existent items: skip them, fail without changes, or restore lambdas under new UIDs" choice:"skip" choice:"fail" choice:"new-uid" default:"skip"`
+	NoTokens bool   `long:"no-tokens" env:"NO_TOKENS" description:"do not restore API tokens of backup"`
+	Args     struct {
+		Archive string `positional-arg-name:"backup" description:"backup archive (see backup), - for stdin" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *restoreCmd) Execute(args []string) error {
+	var archive []byte
+	var err error
+	if cmd.Args.Archive == "-" {
+		archive, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		archive, err = ioutil.ReadFile(cmd.Args.Archive)
+	}
+	if err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if cmd.To != "" {
+		destination, _, err := transferEndpoint(cmd.To)
+		if err != nil {
+			return fmt.Errorf("destination: %w", err)
+		}
+		cmd.URL, cmd.fixed = destination.URL, true
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login to", cmd.URL, "...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	report, err := cmd.Project().Restore(ctx, token, archive, application.RestoreOptions{DryRun: cmd.DryRun, Conflict: cmd.Conflict, NoTokens: cmd.NoTokens})
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(report)
+	}
+	return printRestore(report)
+}
+
+func printRestore(report *application.RestoreReport) error {
+	if report.DryRun {
+		fmt.Println("dry run: nothing is changed")
+	}
+	fmt.Println("backup of", formatTime(report.Header.Created), "by server", dash(report.Header.Version)+":", report.Header.Lambdas, "lambdas")
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "KIND\tNAME\tACTION\tTARGET\tREASON")
+	for _, item := range report.Items {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", item.Kind, dash(item.Name), item.Action, dash(item.Target), dash(item.Reason))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if conflicts := report.Conflicts(); len(conflicts) > 0 {
+		fmt.Println()
+		fmt.Println(len(conflicts), "items already exist and are skipped (see --conflict)")
+	}
+	return nil
+}
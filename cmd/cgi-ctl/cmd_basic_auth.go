@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"golang.org/x/crypto/ssh/terminal"
+	"log"
+	"os"
+	"strings"
+)
+
+type basicAuth struct {
+	List   basicAuthList   `command:"ls" description:"list users of basic auth of the lambda"`
+	Set    basicAuthSet    `command:"set" description:"add user or change password (hashed by server)"`
+	Remove basicAuthRemove `command:"rm" description:"remove users, basic auth is disabled with the last user"`
+	Hash   basicAuthHash   `command:"hash" description:"print bcrypt hash of password for manifest file"`
+}
+
+type basicAuthResult struct {
+	UID   string   `json:"uid"`
+	Users []string `json:"users"`
+	Realm string   `json:"realm,omitempty"`
+	Links []string `json:"links,omitempty"`
+}
+
+type basicAuthList struct {
+	manifestEditor
+}
+
+func (cmd *basicAuthList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, err := cmd.login(ctx)
+	if err != nil {
+		return err
+	}
+	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("get info: %w", err)
+	}
+	result := basicAuthResult{UID: cmd.UID, Users: []string{}}
+	if auth := info.Manifest.BasicAuth; auth != nil {
+		result.Users = auth.Usernames()
+		result.Realm = auth.RealmName()
+		result.Links = auth.Links
+	}
+	if globalOptions.JSON {
+		return printJSON(result)
+	}
+	if len(result.Users) == 0 {
+		log.Println("basic auth is not enabled")
+		return nil
+	}
+	for _, name := range result.Users {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+type basicAuthSet struct {
+	manifestEditor
+	PasswordStdin bool `long:"password-stdin" env:"PASSWORD_STDIN" description:"read password from the first line of stdin instead of prompt"`
+	Args          struct {
+		User string `positional-arg-name:"user" required:"yes" description:"username"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *basicAuthSet) Execute(args []string) error {
+	password, err := readNewPassword(cmd.PasswordStdin)
+	if err != nil {
+		return err
+	}
+	return setBasicAuth(&cmd.manifestEditor, map[string]string{cmd.Args.User: password}, nil)
+}
+
+type basicAuthRemove struct {
+	manifestEditor
+	Args struct {
+		Users []string `positional-arg-name:"user" required:"1" description:"usernames to remove"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *basicAuthRemove) Execute(args []string) error {
+	return setBasicAuth(&cmd.manifestEditor, nil, cmd.Args.Users)
+}
+
+type basicAuthHash struct {
+	PasswordStdin bool `long:"password-stdin" env:"PASSWORD_STDIN" description:"read password from the first line of stdin instead of prompt"`
+}
+
+func (cmd *basicAuthHash) Execute(args []string) error {
+	password, err := readNewPassword(cmd.PasswordStdin)
+	if err != nil {
+		return err
+	}
+	hash, err := types.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+// set and remove users by single update of remote manifest, local manifest gets hashes from the remote
+func setBasicAuth(cmd *manifestEditor, set map[string]string, unset []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, err := cmd.login(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("updating basic auth...")
+	users, err := cmd.Lambdas().SetBasicAuth(ctx, token, cmd.UID, api.Passwords{Users: set}, unset)
+	if err != nil {
+		return fmt.Errorf("update basic auth: %w", err)
+	}
+	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("get info: %w", err)
+	}
+	err = patchLocal(func(m *types.Manifest) {
+		m.BasicAuth = info.Manifest.BasicAuth
+	})
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		log.Println("basic auth disabled")
+	} else {
+		log.Println("done")
+	}
+	return printResult(basicAuthResult{UID: cmd.UID, Users: users})
+}
+
+// password from prompt (asked twice) or from the first line of stdin
+func readNewPassword(fromStdin bool) (string, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" && err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return line, nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("stdin is not a terminal: use --password-stdin")
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "New Password: ")
+	first, err := AskPass()
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Repeat Password: ")
+	second, err := AskPass()
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return string(first), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type bulkSet struct {
+	remoteLink
+	UIDs   []string `short:"U" long:"uid" env:"UID" env-delim:"," description:"UID of updated lambda (could be repeated)"`
+	Label  string   `long:"label" env:"LABEL" description:"update lambdas with the label"`
+	All    bool     `long:"all" env:"ALL" description:"update all lambdas"`
+	Patch  string   `long:"patch" env:"PATCH" description:"file with JSON merge patch of manifest, - for stdin (applied before assignments)"`
+	DryRun bool     `long:"dry-run" env:"DRY_RUN" description:"show changes of manifests without apply"`
+	Args   struct {
+		Fields []string `positional-arg-name:"field=value" description:"assignments of manifest fields: dot separated path and JSON value (or string)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *bulkSet) Execute(args []string) error {
+	request := api.BulkUpdate{Set: cmd.Args.Fields, UIDs: cmd.UIDs, Label: cmd.Label, All: cmd.All, DryRun: cmd.DryRun}
+	if cmd.Patch != "" {
+		var patch []byte
+		var err error
+		if cmd.Patch == "-" {
+			patch, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			patch, err = ioutil.ReadFile(cmd.Patch)
+		}
+		if err != nil {
+			return fmt.Errorf("read patch: %w", err)
+		}
+		if !json.Valid(patch) {
+			return errors.New("patch is not valid JSON")
+		}
+		request.Patch = patch
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	report, err := cmd.Lambdas().BulkUpdate(ctx, token, request)
+	if err != nil {
+		return fmt.Errorf("bulk update: %w", err)
+	}
+	if globalOptions.JSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printBulkReport(report)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d lambdas are not updated", report.Failed, len(report.Results))
+	}
+	return nil
+}
+
+func printBulkReport(report *api.BulkReport) {
+	if report.DryRun {
+		fmt.Println("dry run: nothing is changed")
+	}
+	for _, result := range report.Results {
+		status := "unchanged"
+		switch {
+		case result.Error != "":
+			status = "failed: " + result.Error
+		case result.Applied:
+			status = "updated"
+		case len(result.Changes) > 0:
+			status = "would be updated"
+		}
+		fmt.Println(result.UID, dash(result.Name)+":", status)
+		for _, change := range result.Changes {
+			switch {
+			case change.From == "":
+				fmt.Println("  +", change.Field+":", change.To)
+			case change.To == "":
+				fmt.Println("  -", change.Field+":", change.From)
+			default:
+				fmt.Println("  ~", change.Field+":", change.From, "->", change.To)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"github.com/alecthomas/units"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type capacityCmd struct {
+	remoteLink
+	Window time.Duration `short:"w" long:"window" env:"WINDOW" description:"window of aggregated usage" default:"1h"`
+	Top    int           `short:"n" long:"top" env:"TOP" description:"number of lambdas in table (zero - all)" default:"20"`
+}
+
+func (cmd *capacityCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	report, err := cmd.Project().Capacity(ctx, token, types.JsonDuration(cmd.Window))
+	if err != nil {
+		return fmt.Errorf("get capacity report: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(report)
+	}
+	return printCapacity(report, cmd.Top)
+}
+
+func printCapacity(report *application.CapacityReport, top int) error {
+	lambdas := report.Lambdas
+	if top > 0 && len(lambdas) > top {
+		lambdas = lambdas[:top]
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "UID\tNAME\tCALLS\tRUNNING\tPEAK\tBUSY\tCPU\tMAX RSS\tDISK\tBACKLOG")
+	for _, item := range lambdas {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%d\n", item.UID, item.Name, item.Invocations,
+			item.Running, item.Peak, formatSeconds(item.Busy), formatSeconds(item.CPU), formatSize(item.MaxRSS),
+			formatSize(item.Disk), item.Backlog)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if len(lambdas) < len(report.Lambdas) {
+		fmt.Println("...", len(report.Lambdas)-len(lambdas), "more lambdas")
+	}
+	fmt.Println()
+
+	out = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "STORE\tPATH\tSIZE")
+	for _, store := range report.Stores {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\n", store.Name, store.Path, formatSize(store.Size))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if len(report.Queues) > 0 {
+		fmt.Println()
+		out = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(out, "QUEUE\tDEPTH\tIN FLIGHT\tOLDEST")
+		for _, q := range report.Queues {
+			_, _ = fmt.Fprintf(out, "%s\t%d\t%d\t%s\n", q.Name, q.Depth, q.InFlight, formatAge(q.OldestAgeSeconds))
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Println()
+
+	projection := report.Projection
+	fmt.Println("window:    ", time.Duration(report.Window), "(covered by records:", time.Duration(report.Covered).Round(time.Second).String()+")")
+	fmt.Println("records:   ", report.Records)
+	fmt.Println("running:   ", report.Running, "peak", report.Peak)
+	fmt.Printf("cpu:        %.2f of %d cores used by invocations, %.2f reserved by builds\n", projection.CPULoad, projection.CPUs, projection.BuildsCPU)
+	fmt.Println("memory:    ", formatSize(projection.PeakMemory), "at peak,", formatSize(projection.BuildsMemory), "reserved by builds, total", formatSize(projection.Memory))
+	if projection.MoreLambdas >= 0 {
+		fmt.Println("headroom:  ", projection.MoreLambdas, "more lambdas like", projection.ActiveLambdas, "active (limited by", projection.Limit+")")
+	} else {
+		fmt.Println("headroom:   unknown (no usage in window)")
+	}
+	if report.Partial {
+		log.Println("disk usage is not measured for some lambdas or stores in time (shown as ?)")
+	}
+	return nil
+}
+
+func formatSeconds(duration types.JsonDuration) string {
+	return time.Duration(duration).Round(time.Millisecond).String()
+}
+
+// size in bytes (negative - not measured, zero - unknown or empty)
+func formatSize(size int64) string {
+	if size < 0 {
+		return "?"
+	}
+	return units.Base2Bytes(size).String()
+}
+
+func formatAge(seconds float64) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
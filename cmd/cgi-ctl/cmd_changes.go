@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/journal"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// layout of date without time in flags
+const dateLayout = "2006-01-02"
+
+type changesCmd struct {
+	remoteLink
+	Since  string `short:"s" long:"since" env:"SINCE" description:"beginning of range: date (2006-01-02), RFC3339 time or duration ago (ex: 24h)" default:"24h"`
+	Until  string `long:"until" env:"UNTIL" description:"end of range: date (inclusive), RFC3339 time or duration ago (empty - now)"`
+	Offset int    `long:"offset" env:"OFFSET" description:"offset of the first change (see next page in output)"`
+	Limit  int    `short:"n" long:"limit" env:"LIMIT" description:"number of changes on page" default:"100"`
+	All    bool   `short:"a" long:"all" env:"ALL" description:"fetch all pages and group changes of whole range"`
+}
+
+func (cmd *changesCmd) Execute(args []string) error {
+	now := time.Now()
+	since, err := parseMoment(cmd.Since, now, false)
+	if err != nil {
+		return fmt.Errorf("parse since: %w", err)
+	}
+	var until time.Time
+	if cmd.Until != "" {
+		until, err = parseMoment(cmd.Until, now, true)
+		if err != nil {
+			return fmt.Errorf("parse until: %w", err)
+		}
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	report, err := cmd.Project().Changes(ctx, token, since, until, cmd.Offset, cmd.Limit)
+	if err != nil {
+		return fmt.Errorf("get changes: %w", err)
+	}
+	if cmd.All {
+		// the same end of range for all pages: changes made while fetching are not mixed in
+		var changes = groupedChanges(report.Groups)
+		for report.Next > 0 {
+			page, err := cmd.Project().Changes(ctx, token, since, report.Until, report.Next, cmd.Limit)
+			if err != nil {
+				return fmt.Errorf("get changes from %d: %w", report.Next, err)
+			}
+			changes = append(changes, groupedChanges(page.Groups)...)
+			report.Next = page.Next
+		}
+		report.Groups = journal.Group(changes)
+	}
+	if globalOptions.JSON {
+		return printJSON(report)
+	}
+	return printChanges(report)
+}
+
+// date (start of day or start of the next day for the end of range), RFC3339 time or duration before now
+func parseMoment(value string, now time.Time, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	ago, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (%s), RFC3339 time or duration", value, dateLayout)
+	}
+	return now.Add(-ago), nil
+}
+
+// changes of groups by ID (groups are ordered by the first change)
+func groupedChanges(groups []application.ChangeGroup) []application.Change {
+	var ans []application.Change
+	for _, g := range groups {
+		ans = append(ans, g.Changes...)
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].ID < ans[j].ID
+	})
+	return ans
+}
+
+func printChanges(report *application.ChangesReport) error {
+	fmt.Println("changes from", formatTime(report.Since), "till", formatTime(report.Until)+":", report.Total)
+	for _, g := range report.Groups {
+		fmt.Println()
+		subject := "server"
+		if g.Lambda != "" {
+			subject = "lambda " + g.Lambda
+			if g.Name != "" {
+				subject += " (" + g.Name + ")"
+			}
+		}
+		fmt.Println(subject+":", g.Summary)
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, change := range g.Changes {
+			_, _ = fmt.Fprintf(out, "  #%d\t%s\t%s\t%s\t%s\n", change.ID, formatTime(change.Time), dash(change.Actor), change.Kind, change.Summary)
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	if report.Next > 0 {
+		fmt.Println()
+		fmt.Println("... next page: --offset", report.Next, "(or --all for the whole range)")
+	}
+	return nil
+}
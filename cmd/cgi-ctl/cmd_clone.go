@@ -6,6 +6,7 @@ import (
 	"github.com/alecthomas/units"
 	"github.com/reddec/trusted-cgi/cmd/internal"
 	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
 	"log"
 	"os"
 	"os/exec"
@@ -15,6 +16,7 @@ type clone struct {
 	remoteLink
 	UID    string `short:"U" long:"uid" env:"UID" description:"Lambda UID" required:"yes"`
 	Output string `short:"o" long:"output" env:"OUTPUT" description:"Output directory (empty - same as UID)" default:""`
+	manifestFormat
 }
 
 func (cmd *clone) Execute(args []string) error {
@@ -40,6 +42,11 @@ func (cmd *clone) Execute(args []string) error {
 		return fmt.Errorf("change dir: %w", err)
 	}
 
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get work dir: %w", err)
+	}
+
 	log.Println("download...")
 	tarball, err := cmd.Lambdas().Download(ctx, token, cmd.UID)
 	if err != nil {
@@ -49,7 +56,7 @@ func (cmd *clone) Execute(args []string) error {
 	log.Println("extract to", cmd.Output, "...")
 	untar := exec.CommandContext(ctx, "tar", "zxf", "-")
 	untar.Stderr = os.Stderr
-	untar.Stdout = os.Stdout
+	untar.Stdout = os.Stderr
 	untar.Stdin = bytes.NewReader(tarball)
 	err = untar.Run()
 	if err != nil {
@@ -57,8 +64,24 @@ func (cmd *clone) Execute(args []string) error {
 	}
 
 	var cf controlFile
-	cf.URL = cmd.URL
-	cf.UID = cmd.UID
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	remote := controlRemote{URL: cmd.URL, UID: cmd.UID}
+	var manifest types.Manifest
+	if err := loadLocalManifest(&manifest); err == nil {
+		if cmd.Format != "" {
+			if err := cmd.save(".", manifest); err != nil {
+				return fmt.Errorf("save manifest: %w", err)
+			}
+		}
+		remote.Base = &manifest
+		remote.Revision, err = manifest.Hash()
+		if err != nil {
+			return fmt.Errorf("hash manifest: %w", err)
+		}
+	}
+	cf.SetRemote(globalOptions.Remote, remote)
 	err = cf.Save(controlFilename)
 	if err != nil {
 		return fmt.Errorf("save control file: %w", err)
@@ -70,5 +93,5 @@ func (cmd *clone) Execute(args []string) error {
 	}
 
 	log.Println("done")
-	return nil
+	return printResult(localResult{UID: cmd.UID, Name: manifest.Name, Dir: wd, URL: cmd.URL})
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+// status of passed configuration check, failed checks have level of validation issue (issueError or issueWarning)
+const checkOK = "ok"
+
+// maximum time of connectivity check
+const doctorTimeout = 10 * time.Second
+
+type configCmd struct {
+	Doctor configDoctor `command:"doctor" description:"check control file, saved credentials, cached token and connectivity to the server and print fixes of problems (non-zero exit code on errors)"`
+}
+
+type configDoctor struct {
+	remoteLink
+}
+
+func (cmd *configDoctor) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	result := configDoctorResult{Checks: []configCheck{}}
+	cmd.checkControlFile(&result)
+	cmd.checkProfile(&result)
+	token := cmd.checkToken(&result)
+	cmd.checkConnectivity(ctx, &result, token)
+	return result.report()
+}
+
+func (cdr *configDoctorResult) add(name, status, message, fix string) {
+	cdr.Checks = append(cdr.Checks, configCheck{Name: name, Status: status, Message: message, Fix: fix})
+	switch status {
+	case issueError:
+		cdr.Errors++
+	case issueWarning:
+		cdr.Warnings++
+	}
+}
+
+func (cdr *configDoctorResult) report() error {
+	cdr.Healthy = cdr.Errors == 0
+	if globalOptions.JSON {
+		if err := printJSON(cdr); err != nil {
+			return err
+		}
+	} else {
+		for _, check := range cdr.Checks {
+			_, _ = fmt.Fprintln(os.Stderr, check.Status+":", check.Name+":", check.Message)
+			if check.Fix != "" {
+				_, _ = fmt.Fprintln(os.Stderr, "  fix:", check.Fix)
+			}
+		}
+	}
+	if !cdr.Healthy {
+		return fmt.Errorf("config doctor: %d error(s), %d warning(s)", cdr.Errors, cdr.Warnings)
+	}
+	log.Println("healthy,", cdr.Warnings, "warning(s)")
+	return nil
+}
+
+// control file in the current directory: schema, format version and selected remote. URL of remote is used by
+// following checks
+func (cmd *configDoctor) checkControlFile(result *configDoctorResult) {
+	const name = "control file"
+	if cmd.Independent {
+		result.add(name, checkOK, "not used (--independent)", "")
+		return
+	}
+	var cf controlFile
+	err := cf.Read(controlFilename)
+	if os.IsNotExist(err) {
+		result.add(name, checkOK, controlFilename+" not found in the current directory, URL "+cmd.URL+" is used", "")
+		return
+	}
+	var invalid *controlFileError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			message := problem.Message
+			if problem.Field != "" {
+				message = problem.Field + ": " + message
+			}
+			result.add(name, issueError, invalid.File+": "+message, "fix the field by schema (see docs of cgi-ctl) or remove "+controlFilename+" and run cgi-ctl link")
+		}
+		return
+	}
+	if err != nil {
+		result.add(name, issueError, err.Error(), "check permissions of "+controlFilename)
+		return
+	}
+	remote := cf.Remote(globalOptions.Remote)
+	if remote == nil && globalOptions.Remote != defaultRemote {
+		result.add(name, issueError, "remote "+globalOptions.Remote+" is not defined in "+controlFilename, "add remote by cgi-ctl remote add "+globalOptions.Remote+" <url>, list remotes by cgi-ctl remote ls")
+		return
+	}
+	if remote == nil {
+		result.add(name, checkOK, "remote "+defaultRemote+" is not defined in "+controlFilename+", URL "+cmd.URL+" is used", "")
+		return
+	}
+	cmd.URL = remote.URL
+	switch newer, _ := checkControlVersion(cf.Version); {
+	case cf.Version == "":
+		result.add(name, checkOK, "remote "+globalOptions.Remote+" is "+remote.URL+", file has no format version (1.0), it will be upgraded to "+controlVersion+" on the next write", "")
+	case newer:
+		result.add(name, issueWarning, "format version "+cf.Version+" is newer than supported "+controlVersion+": unknown fields are ignored and will be dropped on the next write", "upgrade cgi-ctl")
+	default:
+		result.add(name, checkOK, "remote "+globalOptions.Remote+" is "+remote.URL+", format version "+cf.Version, "")
+	}
+}
+
+// saved credentials in user config dir for host of URL. Login of saved credentials is used by following checks
+func (cmd *configDoctor) checkProfile(result *configDoctorResult) {
+	const name = "profile"
+	defer func() {
+		if cmd.Login == "" {
+			cmd.Login = defaultLogin
+		}
+	}()
+	if cmd.Independent {
+		result.add(name, checkOK, "not used (--independent)", "")
+		return
+	}
+	location, err := cmd.configLocation()
+	if err != nil {
+		result.add(name, issueError, "locate saved credentials: "+err.Error(), "check URL (--url or url of remote in "+controlFilename+")")
+		return
+	}
+	cfg, err := cmd.readConfig()
+	if os.IsNotExist(err) {
+		result.add(name, issueWarning, "no saved credentials in "+location, "run cgi-ctl login (or pass password by --password or "+passwordEnv+")")
+		return
+	}
+	if err != nil {
+		result.add(name, issueError, "read "+location+": "+err.Error(), "remove "+location+" and run cgi-ctl login")
+		return
+	}
+	if cmd.Login == "" {
+		cmd.Login = cfg.Login
+	}
+	result.add(name, checkOK, "credentials of "+cfg.Login+" saved in "+location, "")
+}
+
+// cached login token of login and URL. Returns not expired token (nil if there is no usable token)
+func (cmd *configDoctor) checkToken(result *configDoctorResult) *api.Token {
+	const name = "token"
+	key := tokenCacheKey(cmd.URL, cmd.Login)
+	if cmd.NoTokenCache || cmd.Independent {
+		result.add(name, checkOK, "cache is not used (--no-token-cache or --independent), every command logins", "")
+		return nil
+	}
+	location, _ := tokenCacheLocation()
+	cache, err := readTokenCache()
+	if err != nil {
+		result.add(name, issueError, "read token cache "+location+": "+err.Error(), "remove "+location+" and run cgi-ctl login")
+		return nil
+	}
+	entry, ok := cache[key]
+	if !ok || entry.Token == "" {
+		result.add(name, issueWarning, "no cached token for "+key, "run cgi-ctl login -l "+cmd.Login)
+		return nil
+	}
+	if !entry.Expires.IsZero() && time.Until(entry.Expires) < tokenExpiryMargin {
+		result.add(name, issueWarning, "cached token for "+key+" expired at "+entry.Expires.Format(time.RFC3339), "run cgi-ctl login -l "+cmd.Login)
+		return nil
+	}
+	message := "cached token for " + key
+	if !entry.Expires.IsZero() {
+		message += " expires at " + entry.Expires.Format(time.RFC3339)
+	}
+	result.add(name, checkOK, message, "")
+	return &api.Token{Data: entry.Token}
+}
+
+// server is reachable by URL and accepts cached token (if any)
+func (cmd *configDoctor) checkConnectivity(ctx context.Context, result *configDoctorResult, token *api.Token) {
+	const name = "connectivity"
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	unreachable := "check URL (--url or url of remote in " + controlFilename + ") and that trusted-cgi is running"
+	if token != nil {
+		info, err := cmd.Project().Capabilities(ctx, token)
+		switch {
+		case err != nil && strings.Contains(err.Error(), "token validation failed"):
+			result.add(name, issueWarning, "server "+cmd.URL+" rejected cached token", "run cgi-ctl login -l "+cmd.Login)
+		case err != nil:
+			result.add(name, issueError, "server "+cmd.URL+": "+err.Error(), unreachable)
+		default:
+			result.add(name, checkOK, "server "+cmd.URL+" accepted the token, version "+info.Version, "")
+		}
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlJoin(cmd.URL), nil)
+	if err != nil {
+		result.add(name, issueError, "invalid URL "+cmd.URL+": "+err.Error(), unreachable)
+		return
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.add(name, issueError, "server "+cmd.URL+" is not reachable: "+err.Error(), unreachable)
+		return
+	}
+	_ = res.Body.Close()
+	result.add(name, checkOK, "server "+cmd.URL+" is reachable (login is not checked without cached token)", "")
+}
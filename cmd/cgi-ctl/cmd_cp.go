@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type cp struct {
+	remoteLink
+	Name        string `short:"n" long:"name" env:"NAME" description:"name of copy (default - name of source)"`
+	NoAliases   bool   `long:"no-aliases" env:"NO_ALIASES" description:"do not declare aliases of source by copy"`
+	NoSchedules bool   `long:"no-schedules" env:"NO_SCHEDULES" description:"do not copy scheduled actions (cron)"`
+	NoSecrets   bool   `long:"no-secrets" env:"NO_SECRETS" description:"do not copy environment variables which reference secrets of server"`
+	Args        struct {
+		Source string `positional-arg-name:"source-uid" description:"UID of copied lambda" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *cp) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("duplicating", cmd.Args.Source, "...")
+	info, err := cmd.Project().Duplicate(ctx, token, cmd.Args.Source, application.DuplicateOptions{
+		Name:        cmd.Name,
+		NoAliases:   cmd.NoAliases,
+		NoSchedules: cmd.NoSchedules,
+		NoSecrets:   cmd.NoSecrets,
+	})
+	if err != nil {
+		return fmt.Errorf("duplicate: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(info)
+	}
+	fmt.Println("uid:", info.UID)
+	fmt.Println("name:", dash(info.Manifest.Name))
+	if info.Slug != "" {
+		fmt.Println("slug:", info.Slug)
+	}
+	if len(info.Manifest.Aliases) > 0 {
+		fmt.Println("aliases:", strings.Join(info.Manifest.Aliases, ", "))
+	}
+	if info.Warning != "" {
+		fmt.Println("warning:", info.Warning)
+	}
+	return nil
+}
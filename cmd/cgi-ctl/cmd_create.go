@@ -1,20 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/cmd/internal"
 	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type create struct {
 	remoteLink
+	manifestFormat
 	Public      bool   `long:"public" env:"PUBLIC" description:"make public lambda"`
 	Description string `short:"d" long:"description" env:"DESCRIPTION" description:"lambda description"`
+	FromGit     string `long:"from-git" env:"FROM_GIT" description:"create lambda from git repository (URL): shallow clone without .git is uploaded"`
+	Ref         string `long:"ref" env:"REF" description:"branch or tag of git repository (default - default branch)"`
+	Slug        bool   `long:"slug" env:"SLUG" description:"generate DNS-safe alias from name (default - by server setting)"`
 	Args        struct {
-		Dir string `name:"dir" description:"project directory" required:"yes"`
+		Dir string `name:"dir" description:"project directory (default with --from-git - name of repository)"`
 	} `positional-args:"yes"`
 }
 
@@ -22,6 +34,19 @@ func (cmd *create) Execute(args []string) error {
 	ctx, closer := internal.SignalContext()
 	defer closer()
 
+	if cmd.Ref != "" && cmd.FromGit == "" {
+		return fmt.Errorf("--ref requires --from-git")
+	}
+	if cmd.Args.Dir == "" {
+		if cmd.FromGit == "" {
+			return fmt.Errorf("project directory is not set")
+		}
+		cmd.Args.Dir = repoName(cmd.FromGit)
+	}
+	if cmd.FromGit != "" {
+		return cmd.createFromGit(ctx)
+	}
+
 	err := os.MkdirAll(cmd.Args.Dir, 0755)
 	if err != nil {
 		return fmt.Errorf("prepare directory: %w", err)
@@ -32,6 +57,13 @@ func (cmd *create) Execute(args []string) error {
 		return fmt.Errorf("change dir: %w", err)
 	}
 
+	// directory prepared offline (by init) keeps its manifest and files
+	if file, err := localManifestFile(); err != nil {
+		return err
+	} else if _, err := os.Stat(file); err == nil {
+		return cmd.createFromDir(ctx)
+	}
+
 	wd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("get work dir: %w", err)
@@ -44,16 +76,22 @@ func (cmd *create) Execute(args []string) error {
 	}
 
 	log.Println("creating...")
-	info, err := cmd.Project().Create(ctx, token)
+	info, err := cmd.Project().CreateWithOptions(ctx, token, cmd.options(filepath.Base(wd)))
 	if err != nil {
 		return fmt.Errorf("create: %w", err)
 	}
 	log.Println("created", info.UID)
+	slug := info.Slug
+	if slug != "" {
+		log.Println("slug", slug)
+	}
 	log.Println("saving info....")
 
 	var cf controlFile
-	cf.URL = cmd.URL
-	cf.UID = info.UID
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	cf.SetRemote(globalOptions.Remote, controlRemote{URL: cmd.URL, UID: info.UID})
 	err = cf.Save(controlFilename)
 	if err != nil {
 		return fmt.Errorf("save control file: %w", err)
@@ -63,19 +101,236 @@ func (cmd *create) Execute(args []string) error {
 		return fmt.Errorf("update cgiignore file: %w", err)
 	}
 
-	info.Manifest.Name = filepath.Base(wd)
 	info.Manifest.Description = cmd.Description
 
 	log.Println("updating manifest...")
-	info, err = cmd.Lambdas().Update(ctx, token, info.UID, info.Manifest)
+	info, err = cmd.Lambdas().Update(ctx, token, info.UID, info.Manifest, false)
 	if err != nil {
 		return fmt.Errorf("update manifest: %w", err)
 	}
 
-	err = info.Manifest.SaveAs(internal_app.ManifestFile)
+	err = cmd.save(".", info.Manifest)
 	if err != nil {
 		return fmt.Errorf("save manifest: %w", err)
 	}
+	if err := saveBase(info.Manifest); err != nil {
+		return err
+	}
+	log.Println("done")
+	return printResult(localResult{UID: info.UID, Name: info.Manifest.Name, Dir: wd, URL: cmd.URL, Slug: slug})
+}
+
+// options of creation: slug is generated by server setting unless requested by flag
+func (cmd *create) options(name string) api.CreateOptions {
+	options := api.CreateOptions{Name: name}
+	if cmd.Slug {
+		options.Slug = &cmd.Slug
+	}
+	return options
+}
+
+// clone repository and create lambda from files of the repository
+func (cmd *create) createFromGit(ctx context.Context) error {
+	log.Println("cloning", cmd.FromGit, "...")
+	if err := cloneRepo(ctx, cmd.FromGit, cmd.Ref, cmd.Args.Dir); err != nil {
+		return err
+	}
+	if err := os.Chdir(cmd.Args.Dir); err != nil {
+		return fmt.Errorf("change dir: %w", err)
+	}
+	return cmd.createFromDir(ctx)
+}
+
+// create lambda and upload files of the current directory (cloned repository or initialized by init). Lambda is
+// removed if any step after creation failed
+func (cmd *create) createFromDir(ctx context.Context) error {
+	result, err := cmd.createDir(ctx)
+	if err != nil {
+		return err
+	}
+	return printResult(*result)
+}
+
+// create lambda from files of the current directory (see createFromDir) without printing result
+func (cmd *create) createDir(ctx context.Context) (*localResult, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("get work dir: %w", err)
+	}
+	// local manifest has priority over manifest of template reference
+	var manifest types.Manifest
+	localManifest := true
+	if err := loadLocalManifest(&manifest); os.IsNotExist(err) {
+		localManifest = false
+	} else if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var postClone string
+	template, err := templates.Read(templates.MetadataFile)
+	if err == nil {
+		postClone = template.PostClone
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read template reference: %w", err)
+	}
+	if !localManifest && template != nil {
+		manifest = template.Manifest
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	name := manifest.Name
+	if name == "" {
+		name = filepath.Base(wd)
+	}
+
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	log.Println("creating...")
+	info, err := cmd.Project().CreateWithOptions(ctx, token, cmd.options(name))
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+	log.Println("created", info.UID)
+	slug := info.Slug
+	if slug != "" {
+		log.Println("slug", slug)
+	}
+	var created bool
+	defer func() {
+		if created {
+			return
+		}
+		log.Println("removing partially created lambda", info.UID, "...")
+		// the context could be already canceled by signal
+		if _, err := cmd.Lambdas().Remove(context.Background(), token, info.UID); err != nil {
+			log.Println("remove lambda", info.UID+":", err)
+		}
+	}()
+
+	if !localManifest && template == nil {
+		manifest = info.Manifest
+	}
+	manifest.Name = name
+	if cmd.Description != "" {
+		manifest.Description = cmd.Description
+	}
+	if !localManifest || cmd.Format != "" {
+		if err := cmd.save(".", manifest); err != nil {
+			return nil, fmt.Errorf("save manifest: %w", err)
+		}
+	}
+	if err := appendIfNoLineFile(internal_app.CGIIgnore, controlFilename); err != nil {
+		return nil, fmt.Errorf("update cgiignore file: %w", err)
+	}
+	log.Println("archiving...")
+	buffer, err := archiveDir(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.checkUpload(ctx, token, buffer.Bytes()); err != nil {
+		return nil, err
+	}
+	log.Println("uploading...")
+	if _, err := cmd.Lambdas().Upload(ctx, token, info.UID, buffer.Bytes()); err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	log.Println("updating manifest...")
+	info, err = cmd.Lambdas().Update(ctx, token, info.UID, manifest, false)
+	if err != nil {
+		return nil, fmt.Errorf("update manifest: %w", err)
+	}
+	if postClone != "" {
+		if err := cmd.runPostClone(ctx, token, info.UID, postClone); err != nil {
+			return nil, err
+		}
+	}
+
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read control file: %w", err)
+	}
+	cf.SetRemote(globalOptions.Remote, controlRemote{URL: cmd.URL, UID: info.UID})
+	if err := cf.Save(controlFilename); err != nil {
+		return nil, fmt.Errorf("save control file: %w", err)
+	}
+	if err := saveBase(info.Manifest); err != nil {
+		return nil, err
+	}
+	created = true
 	log.Println("done")
+	var outputs map[string]string
+	if template != nil {
+		outputs = cmd.printOutputs(template, info, slug)
+	}
+	return &localResult{UID: info.UID, Name: info.Manifest.Name, Dir: wd, URL: cmd.URL, Slug: slug, Outputs: outputs}, nil
+}
+
+// resolve outputs of template by created lambda (by slug or the first declared alias) and URL of server and print
+// them to stderr. Problems are logged: lambda is already created
+func (cmd *create) printOutputs(template *templates.Template, info *application.Definition, slug string) map[string]string {
+	alias := slug
+	if alias == "" && len(info.Manifest.Aliases) > 0 {
+		alias = info.Manifest.Aliases[0]
+	}
+	outputs, err := template.ResolveOutputs(templates.NewOutputValues(info.UID, alias, strings.TrimSuffix(cmd.URL, "/")))
+	if err != nil {
+		log.Println("outputs of template:", err)
+		return nil
+	}
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Println(name+":", outputs[name])
+	}
+	return outputs
+}
+
+// invoke post-clone action on the server, output of the action is printed to stderr
+func (cmd *create) runPostClone(ctx context.Context, token *api.Token, uid string, action string) error {
+	log.Println("invoking post-clone action", action, "...")
+	result, err := cmd.Lambdas().InvokeAction(ctx, token, uid, action, 0)
+	if err != nil {
+		return fmt.Errorf("invoke post-clone %s: %w", action, err)
+	}
+	_, _ = os.Stderr.WriteString(result.Output)
+	if result.Error != "" {
+		return fmt.Errorf("invoke post-clone %s: %s", action, result.Error)
+	}
+	return nil
+}
+
+// shallow clone of repository without .git directory. Credentials of private repositories are resolved by git
+// (credential helpers, SSH agent)
+func cloneRepo(ctx context.Context, repo, ref, dir string) error {
+	var args = []string{"-c", "advice.detachedHead=false", "clone", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", repo, dir)
+	run := exec.CommandContext(ctx, "git", args...)
+	run.Stdin = os.Stdin
+	run.Stdout = os.Stderr
+	run.Stderr = os.Stderr
+	if err := run.Run(); err != nil {
+		return fmt.Errorf("clone %s: %w", repo, err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		return fmt.Errorf("remove .git: %w", err)
+	}
 	return nil
 }
+
+// directory name of repository: the last element of URL without .git suffix (as git clone does)
+func repoName(repo string) string {
+	name := strings.TrimRight(repo, "/")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".git")
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// default names of workspace file in order of lookup
+var workspaceFiles = []string{"cgi-workspace.yaml", "cgi-workspace.yml", "cgi-workspace.json"}
+
+// Results of deploy of lambda of workspace
+const (
+	deployCreated = "created"
+	deployUpdated = "updated"
+	deployFailed  = "failed"
+	deploySkipped = "skipped" // not deployed after failure with --fail-fast
+)
+
+// Workspace: lambdas in subdirectories of one repository deployed together (see deploy)
+type workspace struct {
+	Lambdas []workspaceLambda `json:"lambdas" yaml:"lambdas"`
+}
+
+// Lambda of workspace. Deployed lambda is found by UID, then by control file of directory (the same server), then by
+// alias. Lambda which is not found is created
+type workspaceLambda struct {
+	Dir     string   `json:"dir" yaml:"dir"`                             // directory of lambda relative to workspace file
+	Name    string   `json:"name,omitempty" yaml:"name,omitempty"`       // name in --only and summary (empty - alias or dir)
+	UID     string   `json:"uid,omitempty" yaml:"uid,omitempty"`         // UID of deployed lambda
+	Alias   string   `json:"alias,omitempty" yaml:"alias,omitempty"`     // alias of deployed lambda, bound to created lambda
+	Actions []string `json:"actions,omitempty" yaml:"actions,omitempty"` // actions invoked after deploy one by one
+}
+
+// Label of lambda in --only and summary
+func (wl *workspaceLambda) Label() string {
+	if wl.Name != "" {
+		return wl.Name
+	}
+	if wl.Alias != "" {
+		return wl.Alias
+	}
+	return filepath.ToSlash(filepath.Clean(wl.Dir))
+}
+
+// read workspace file and check entries
+func readWorkspace(file string) (*workspace, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read workspace: %w", err)
+	}
+	var ws workspace
+	if filepath.Ext(file) == ".json" {
+		err = json.Unmarshal(data, &ws)
+	} else {
+		err = yaml.UnmarshalStrict(data, &ws)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse workspace %s: %w", file, err)
+	}
+	var errs []error
+	var names = make(map[string]bool, len(ws.Lambdas))
+	for i, item := range ws.Lambdas {
+		if item.Dir == "" || !filepath.IsLocal(item.Dir) {
+			errs = append(errs, fmt.Errorf("lambdas[%d]: dir %q should be relative path inside workspace", i, item.Dir))
+			continue
+		}
+		if names[item.Label()] {
+			errs = append(errs, fmt.Errorf("lambdas[%d]: duplicated name %s", i, item.Label()))
+		}
+		names[item.Label()] = true
+		if item.Alias != "" && !application.LinkNameReg.MatchString(item.Alias) {
+			errs = append(errs, fmt.Errorf("lambdas[%d]: invalid alias %q", i, item.Alias))
+		}
+	}
+	if len(ws.Lambdas) == 0 {
+		errs = append(errs, fmt.Errorf("no lambdas in workspace %s", file))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid workspace %s: %w", file, err)
+	}
+	return &ws, nil
+}
+
+type deploy struct {
+	remoteLink
+	manifestSync
+	File     string        `short:"f" long:"file" env:"WORKSPACE" description:"workspace file (default - cgi-workspace.yaml, .yml or .json in the current directory)"`
+	Only     []string      `long:"only" env:"ONLY" env-delim:"," description:"deploy only lambdas by names (comma separated, could be repeated)"`
+	FailFast bool          `long:"fail-fast" env:"FAIL_FAST" description:"stop on the first failed lambda (by default other lambdas are deployed)"`
+	Timeout  time.Duration `short:"t" long:"timeout" env:"TIMEOUT" description:"time limit for each post-deploy action (0 means no limit)"`
+}
+
+func (cmd *deploy) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	file, err := cmd.workspaceFile()
+	if err != nil {
+		return err
+	}
+	ws, err := readWorkspace(file)
+	if err != nil {
+		return err
+	}
+	selected, err := cmd.selected(ws)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return fmt.Errorf("detect workspace dir: %w", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get work dir: %w", err)
+	}
+	defer os.Chdir(wd)
+
+	// all lambdas are deployed to the same server: control files of directories do not change URL
+	cmd.fixed = true
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	report := deployReport{Lambdas: make([]deployItem, 0, len(selected))}
+	for _, item := range selected {
+		if ctx.Err() != nil || (cmd.FailFast && report.Failed > 0) {
+			report.Lambdas = append(report.Lambdas, deployItem{Name: item.Label(), Dir: item.Dir, UID: item.UID, Result: deploySkipped})
+			continue
+		}
+		log.Println("deploying", item.Label(), "...")
+		result := cmd.deploy(ctx, token, filepath.Join(root, item.Dir), item)
+		if result.Error != "" {
+			report.Failed++
+			log.Println("[ERROR]", item.Label(), "-", result.Error)
+		}
+		report.Lambdas = append(report.Lambdas, result)
+	}
+	if globalOptions.JSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else if err := printDeployReport(report); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d lambdas are not deployed", report.Failed, len(report.Lambdas))
+	}
+	return nil
+}
+
+// workspace file by flag or the first existent default file
+func (cmd *deploy) workspaceFile() (string, error) {
+	if cmd.File != "" {
+		return cmd.File, nil
+	}
+	for _, name := range workspaceFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("workspace file not found (%s)", strings.Join(workspaceFiles, ", "))
+}
+
+// lambdas of workspace selected by --only in order of workspace
+func (cmd *deploy) selected(ws *workspace) ([]workspaceLambda, error) {
+	if len(cmd.Only) == 0 {
+		return ws.Lambdas, nil
+	}
+	var only = make(map[string]bool)
+	for _, value := range cmd.Only {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				only[name] = true
+			}
+		}
+	}
+	var ans []workspaceLambda
+	for _, item := range ws.Lambdas {
+		if only[item.Label()] {
+			ans = append(ans, item)
+			delete(only, item.Label())
+		}
+	}
+	for name := range only {
+		return nil, fmt.Errorf("lambda %s is not defined in workspace", name)
+	}
+	return ans, nil
+}
+
+// deploy lambda from the directory: create or upload content and apply manifest, then invoke post-deploy actions
+func (cmd *deploy) deploy(ctx context.Context, token *api.Token, dir string, item workspaceLambda) deployItem {
+	started := time.Now()
+	result := deployItem{Name: item.Label(), Dir: item.Dir}
+	err := cmd.deployDir(ctx, token, dir, item, &result)
+	result.Duration = types.JsonDuration(time.Since(started))
+	if err != nil {
+		result.Result = deployFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (cmd *deploy) deployDir(ctx context.Context, token *api.Token, dir string, item workspaceLambda, result *deployItem) error {
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("change dir: %w", err)
+	}
+	// the same login for all lambdas
+	link := cmd.remoteLink
+	link.APIToken = token.Data
+	uid, err := cmd.locate(ctx, token, item)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		created, err := (&create{remoteLink: link}).createDir(ctx)
+		if err != nil {
+			return err
+		}
+		result.UID, result.Result = created.UID, deployCreated
+		if item.Alias != "" {
+			if _, err := cmd.Lambdas().Link(ctx, token, created.UID, item.Alias); err != nil {
+				return fmt.Errorf("link alias %s: %w", item.Alias, err)
+			}
+		}
+	} else {
+		result.UID = uid
+		if err := cmd.update(ctx, token, link, uid); err != nil {
+			return err
+		}
+		result.Result = deployUpdated
+	}
+	for _, action := range item.Actions {
+		log.Println("invoking post-deploy action", action, "...")
+		invoked, err := cmd.Lambdas().InvokeAction(ctx, token, result.UID, action, types.JsonDuration(cmd.Timeout))
+		if err != nil {
+			return fmt.Errorf("invoke %s: %w", action, err)
+		}
+		_, _ = os.Stderr.WriteString(invoked.Output)
+		if invoked.Error != "" {
+			return fmt.Errorf("action %s failed after %v (exit code %d): %s", action, time.Duration(invoked.Duration), invoked.ExitCode, invoked.Error)
+		}
+		result.Actions = append(result.Actions, action)
+	}
+	return nil
+}
+
+// UID of deployed lambda of the current directory, empty - not deployed
+func (cmd *deploy) locate(ctx context.Context, token *api.Token, item workspaceLambda) (string, error) {
+	if item.UID != "" {
+		return item.UID, nil
+	}
+	remote, err := readRemote()
+	if err != nil {
+		return "", err
+	}
+	if remote != nil && remote.UID != "" && strings.TrimSuffix(remote.URL, "/") == strings.TrimSuffix(cmd.URL, "/") {
+		return remote.UID, nil
+	}
+	if item.Alias == "" {
+		return "", nil
+	}
+	def, err := cmd.FindLambda(ctx, token, item.Alias)
+	var exit *exitError
+	if errors.As(err, &exit) && exit.code == exitNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return def.UID, nil
+}
+
+// upload content and apply manifest of existent lambda, directory is linked to the lambda by control file
+func (cmd *deploy) update(ctx context.Context, token *api.Token, link remoteLink, uid string) error {
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	if remote := cf.Remote(globalOptions.Remote); remote == nil || remote.UID != uid || remote.URL != cmd.URL {
+		cf.SetRemote(globalOptions.Remote, controlRemote{URL: cmd.URL, UID: uid})
+		if err := cf.Save(controlFilename); err != nil {
+			return fmt.Errorf("save control file: %w", err)
+		}
+	}
+	locator := uidLocator{UID: uid}
+	if err := (&upload{remoteLink: link, uidLocator: locator, manifestSync: cmd.manifestSync, Input: "."}).run(nil); err != nil {
+		return err
+	}
+	var manifest types.Manifest
+	if err := loadLocalManifest(&manifest); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("load local manifest: %w", err)
+	}
+	_, err := (&apply{remoteLink: link, uidLocator: locator, manifestSync: cmd.manifestSync}).push(ctx, token, manifest)
+	return err
+}
+
+func printDeployReport(report deployReport) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "NAME\tUID\tRESULT\tACTIONS\tDURATION\tERROR")
+	for _, item := range report.Lambdas {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%v\t%s\n", item.Name, dash(item.UID), item.Result,
+			dash(strings.Join(item.Actions, ",")), time.Duration(item.Duration).Round(time.Millisecond), dash(item.Error))
+	}
+	return out.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeploy_workspace(t *testing.T) {
+	state, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(state)
+	root, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	defer os.Chdir(wd)
+
+	writeFile := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+	writeFile("services/users/manifest.json", `{"name": "users", "run": ["cat"]}`)
+	writeFile("services/users/Makefile", "install:\n\ttouch installed\n")
+	writeFile("services/broken/manifest.json", `{"name": "broken", "run": ["cat"], "time_limit": "-1s"}`)
+	writeFile("services/billing/manifest.json", `{"name": "billing", "run": ["cat"]}`)
+	writeFile("cgi-workspace.yaml", `
+lambdas:
+  - dir: services/users
+    alias: users
+    actions: [install]
+  - dir: services/broken
+    name: broken
+  - dir: services/billing
+    name: billing
+`)
+	require.NoError(t, os.Chdir(root))
+	ctl := startMockCtl(t, state)
+	defer ctl.stop()
+
+	// failed lambda doesn't abort others
+	var report deployReport
+	out, err := ctl.exec("deploy")
+	assert.Error(t, err, "lambda failed")
+	require.NoError(t, json.Unmarshal(out, &report))
+	require.Len(t, report.Lambdas, 3)
+	assert.Equal(t, 1, report.Failed)
+	users := report.Lambdas[0]
+	assert.Equal(t, deployCreated, users.Result)
+	assert.Equal(t, []string{"install"}, users.Actions)
+	assert.FileExists(t, filepath.Join(state, users.UID, "installed"), "post-deploy action")
+	assert.Equal(t, deployFailed, report.Lambdas[1].Result)
+	assert.Contains(t, report.Lambdas[1].Error, "time limit")
+	assert.Equal(t, deployCreated, report.Lambdas[2].Result)
+
+	// deployed lambdas are found by control files
+	writeFile("services/users/app.py", "print(1)")
+	report = deployReport{}
+	require.NoError(t, json.Unmarshal(ctl.run("deploy", "--only", "users,billing"), &report))
+	require.Len(t, report.Lambdas, 2)
+	assert.Equal(t, deployUpdated, report.Lambdas[0].Result)
+	assert.Equal(t, users.UID, report.Lambdas[0].UID)
+	assert.FileExists(t, filepath.Join(state, users.UID, "app.py"), "content is uploaded")
+	assert.Equal(t, deployUpdated, report.Lambdas[1].Result)
+
+	// lambda is found by alias without control file
+	require.NoError(t, os.Remove(filepath.Join(root, "services/users", controlFilename)))
+	report = deployReport{}
+	require.NoError(t, json.Unmarshal(ctl.run("deploy", "--only", "users"), &report))
+	require.Len(t, report.Lambdas, 1)
+	assert.Equal(t, users.UID, report.Lambdas[0].UID)
+
+	report = deployReport{}
+	out, err = ctl.exec("deploy", "--only", "broken", "--only", "billing", "--fail-fast")
+	assert.Error(t, err)
+	require.NoError(t, json.Unmarshal(out, &report))
+	require.Len(t, report.Lambdas, 2)
+	assert.Equal(t, deployFailed, report.Lambdas[0].Result)
+	assert.Equal(t, deploySkipped, report.Lambdas[1].Result)
+
+	_, err = ctl.exec("deploy", "--only", "unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type describe struct {
+	remoteLink
+	uidLocator
+	Args struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias (default - from control file or --uid)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *describe) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	target := cmd.Args.Lambda
+	if target == "" {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+		target = cmd.UID
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	found, err := cmd.FindLambda(ctx, token, target)
+	if err != nil {
+		return err
+	}
+	def, err := cmd.Lambdas().Info(ctx, token, found.UID)
+	if err != nil {
+		return fmt.Errorf("get lambda info: %w", err)
+	}
+	item := newLambdaItem(*def)
+	if globalOptions.JSON {
+		return printJSON(item)
+	}
+	fmt.Println("uid:     ", item.UID)
+	fmt.Println("name:    ", item.Name)
+	fmt.Println("aliases: ", strings.Join(item.Aliases, ", "))
+	fmt.Println("modified:", item.Modified.Local().Format(time.RFC3339))
+	fmt.Println("enabled: ", yesNo(item.Enabled))
+	fmt.Println("disk:    ", formatDisk(item.Disk))
+	if item.Warning != "" {
+		fmt.Println("warning: ", item.Warning)
+	}
+
+	fmt.Println()
+	fmt.Println("schedules:")
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "  CRON\tACTION\tENABLED\tNEXT\tLAST RUN\tLAST RESULT")
+	for _, sched := range item.Schedules {
+		expression := sched.Cron
+		if sched.TZ != "" {
+			expression += " (" + sched.TZ + ")"
+		}
+		_, _ = fmt.Fprintf(out, "  %s\t%s\t%s\t%s\t%s\t%s\n", expression, scheduleTarget(types.Schedule{Name: sched.Name, Action: sched.Action}), yesNo(sched.Enabled),
+			formatTime(sched.Next), formatTime(sched.LastRun), scheduleResult(sched))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("queues:")
+	out = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "  NAME\tDEPTH\tIN-FLIGHT\tPAUSED\tOLDEST AGE")
+	for _, q := range item.Queues {
+		age := "-"
+		if !q.Oldest.IsZero() {
+			age = (time.Duration(q.OldestAgeSeconds) * time.Second).String()
+		}
+		_, _ = fmt.Fprintf(out, "  %s\t%s\t%s\t%s\t%s\n", q.Name, strconv.FormatInt(q.Depth, 10),
+			strconv.FormatInt(q.InFlight, 10), yesNo(q.Paused), age)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("alerts:")
+	out = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	writeAlerts(out, item.Alerts, "  ")
+	return out.Flush()
+}
+
+func scheduleResult(sched application.ScheduleStatus) string {
+	switch sched.LastResult {
+	case "":
+		return "-"
+	case application.ScheduleResultError:
+		return sched.LastResult + ": " + sched.LastError
+	default:
+		return sched.LastResult
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
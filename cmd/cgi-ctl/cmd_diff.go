@@ -0,0 +1,225 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// exit code when local copy differs from the remote lambda
+const exitDiffers = 1
+
+// exit code of diff command on errors
+const exitDiffError = 2
+
+type diff struct {
+	remoteLink
+	uidLocator
+	Unified bool   `long:"unified" env:"UNIFIED" description:"show text diff of modified files"`
+	Input   string `long:"input" env:"INPUT" description:"Directory" default:"."`
+}
+
+func (cmd *diff) Execute([]string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	differs, err := cmd.run(ctx)
+	if err != nil {
+		var exit *exitError
+		if errors.As(err, &exit) && exit.code > exitDiffers {
+			return err
+		}
+		return &exitError{code: exitDiffError, err: err}
+	}
+	if differs {
+		return &exitError{code: exitDiffers, err: fmt.Errorf("local copy differs from the remote lambda")}
+	}
+	return nil
+}
+
+func (cmd *diff) run(ctx context.Context) (bool, error) {
+	if err := os.Chdir(cmd.Input); err != nil {
+		return false, fmt.Errorf("change dir: %w", err)
+	}
+	if err := cmd.parseUID(); err != nil {
+		return false, err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return false, fmt.Errorf("login: %w", err)
+	}
+	log.Println("download...")
+	tarball, err := cmd.Lambdas().Download(ctx, token, cmd.UID)
+	if err != nil {
+		return false, fmt.Errorf("download: %w", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return false, fmt.Errorf("read remote archive: %w", err)
+	}
+	remote, err := readTarFiles(gz)
+	if err != nil {
+		return false, fmt.Errorf("read remote archive: %w", err)
+	}
+	local, err := localFiles(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// manifest is compared field by field
+	remoteManifest, remoteContent := manifestOf(remote)
+	localManifest, localContent := manifestOf(local)
+	manifestChanges, err := diffManifests(remoteManifest, remoteContent, localManifest, localContent)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range []string{internal_app.ManifestFile, internal_app.ManifestYAML} {
+		delete(remote, name)
+		delete(local, name)
+	}
+	// control file is local state of cgi-ctl, not content of lambda
+	delete(remote, controlFilename)
+	delete(local, controlFilename)
+
+	var names = make([]string, 0, len(remote)+len(local))
+	for name := range remote {
+		names = append(names, name)
+	}
+	for name := range local {
+		if _, ok := remote[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var result = diffResult{UID: cmd.UID, Manifest: newManifestChanges(manifestChanges), Files: []fileChange{}}
+	for _, name := range names {
+		theirs, inRemote := remote[name]
+		ours, inLocal := local[name]
+		switch {
+		case !inRemote:
+			result.Files = append(result.Files, fileChange{Name: name, Change: fileAdded})
+		case !inLocal:
+			result.Files = append(result.Files, fileChange{Name: name, Change: fileDeleted})
+		case !bytes.Equal(theirs, ours):
+			change := fileChange{Name: name, Change: fileModified}
+			if cmd.Unified {
+				change.Binary, change.Unified = unified(name, theirs, ours)
+			}
+			result.Files = append(result.Files, change)
+		}
+	}
+	result.Differs = len(result.Manifest) > 0 || len(result.Files) > 0
+	if globalOptions.JSON {
+		return result.Differs, printJSON(result)
+	}
+	if len(manifestChanges) > 0 {
+		fmt.Println("modified:", localManifest)
+		for _, change := range manifestChanges {
+			fmt.Println("   ", change)
+		}
+	}
+	for _, file := range result.Files {
+		fmt.Printf("%-9s %s\n", file.Change+":", file.Name)
+		if file.Binary {
+			fmt.Println("    binary files differ")
+		}
+		fmt.Print(file.Unified)
+	}
+	return result.Differs, nil
+}
+
+func diffManifests(remoteName string, remote []byte, localName string, local []byte) ([]types.Change, error) {
+	var from, to types.Manifest
+	var err error
+	if remote != nil {
+		if from, err = types.DecodeManifest(remoteName, bytes.NewReader(remote)); err != nil {
+			return nil, fmt.Errorf("parse remote manifest: %w", err)
+		}
+	}
+	if local != nil {
+		if to, err = types.DecodeManifest(localName, bytes.NewReader(local)); err != nil {
+			return nil, fmt.Errorf("parse local manifest: %w", err)
+		}
+	}
+	return types.DiffManifest(from, to)
+}
+
+// unified diff of text files (binary is true if any of files is binary)
+func unified(name string, remote, local []byte) (binary bool, text string) {
+	if isBinary(remote) || isBinary(local) {
+		return true, ""
+	}
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(remote)),
+		B:        difflib.SplitLines(string(local)),
+		FromFile: "remote/" + name,
+		ToFile:   "local/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return false, "    failed to make diff: " + err.Error() + "\n"
+	}
+	return false, text
+}
+
+func isBinary(data []byte) bool {
+	const sniffLen = 8000
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
+
+// local files exactly as upload archives them (honoring .cgiignore)
+func localFiles(ctx context.Context) (map[string][]byte, error) {
+	buffer, err := archiveDir(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readTarFiles(buffer)
+	if err != nil {
+		return nil, fmt.Errorf("read local archive: %w", err)
+	}
+	return files, nil
+}
+
+// content of regular files by clean relative path
+func readTarFiles(stream io.Reader) (map[string][]byte, error) {
+	var files = make(map[string][]byte)
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		files[name] = data
+	}
+	return files, nil
+}
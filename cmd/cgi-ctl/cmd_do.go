@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
 	"log"
+	"os"
+	"time"
 )
 
 type do struct {
 	remoteLink
 	uidLocator
-	Args struct {
+	List    bool          `long:"list" env:"LIST" description:"print available actions (actions of the remote manifest and targets of the remote Makefile)"`
+	Timeout time.Duration `short:"t" long:"timeout" env:"TIMEOUT" description:"time limit for each action (0 means no limit)"`
+	Args    struct {
 		Actions []string `positional-arg:"yes" name:"action" description:"action names"`
 	} `positional-args:"yes"`
 }
@@ -27,11 +32,17 @@ func (cmd *do) Execute(args []string) error {
 	}
 	log.Println("lambda", cmd.UID)
 
-	if len(cmd.Args.Actions) == 0 {
+	if cmd.List || len(cmd.Args.Actions) == 0 {
 		list, err := cmd.Lambdas().Actions(ctx, token, cmd.UID)
 		if err != nil {
 			return fmt.Errorf("list actions: %w", err)
 		}
+		if globalOptions.JSON {
+			if list == nil {
+				list = []string{}
+			}
+			return printJSON(list)
+		}
 		if len(list) > 0 {
 			for _, name := range list {
 				fmt.Println(name)
@@ -44,11 +55,31 @@ func (cmd *do) Execute(args []string) error {
 
 	for _, action := range cmd.Args.Actions {
 		log.Println("invoking", action, "...")
-		out, err := cmd.Lambdas().Invoke(ctx, token, cmd.UID, action)
-		log.Println("response:", out)
+		result, err := cmd.Lambdas().InvokeAction(ctx, token, cmd.UID, action, types.JsonDuration(cmd.Timeout))
 		if err != nil {
 			return fmt.Errorf("invoke %s: %w", action, err)
 		}
+		if result.QueuePosition > 0 {
+			log.Println("action", action, "waited in queue of builds at position", result.QueuePosition, "for", time.Duration(result.Queued))
+		}
+		if globalOptions.JSON {
+			if err := printJSON(actionResult{Action: action, ActionResult: *result}); err != nil {
+				return err
+			}
+		} else {
+			_, _ = os.Stdout.WriteString(result.Output)
+		}
+		if result.LimitExceeded != "" {
+			return &exitError{code: 1, err: fmt.Errorf("action %s exceeded %s limit after %v: %s", action, result.LimitExceeded, time.Duration(result.Duration), result.Error)}
+		}
+		if result.Error != "" {
+			code := result.ExitCode
+			if code <= 0 {
+				code = 1
+			}
+			return &exitError{code: code, err: fmt.Errorf("action %s failed after %v: %s", action, time.Duration(result.Duration), result.Error)}
+		}
+		log.Println("action", action, "finished in", time.Duration(result.Duration))
 	}
 	log.Println("done")
 	return nil
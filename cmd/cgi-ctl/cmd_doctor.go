@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type doctor struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *doctor) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("lambda", cmd.UID)
+	diag, err := cmd.Lambdas().Doctor(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("diagnose: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(diag)
+	}
+	fmt.Println("umask:", valueOrDefault(diag.Runtime.Umask))
+	fmt.Println("LANG:", valueOrDefault(diag.Runtime.Lang))
+	fmt.Println("LC_ALL:", valueOrDefault(diag.Runtime.LcAll))
+	fmt.Println("TZ:", valueOrDefault(diag.Runtime.TZ))
+	secrets := diag.Secrets.Delivery
+	if diag.Secrets.Env != "" {
+		secrets += " (" + diag.Secrets.Env + ")"
+	}
+	if len(diag.Secrets.Keys) > 0 {
+		secrets += ": " + strings.Join(diag.Secrets.Keys, ", ")
+	}
+	fmt.Println("secrets:", secrets)
+	fmt.Println("build limits:", buildLimits(diag.Builds))
+	fmt.Println("env size:", envSize(diag.Env))
+	if container := diag.Container; container != nil {
+		fmt.Println("container:", container.Image, container.State, "at", container.Updated.Format(time.RFC3339))
+		if container.Progress != "" {
+			fmt.Println("pull progress:", container.Progress)
+		}
+		if container.Error != "" {
+			fmt.Println("pull error:", container.Error)
+		}
+	}
+	if startup := diag.Startup; startup != nil {
+		var state = "ok"
+		switch {
+		case startup.Running:
+			state = "running"
+		case startup.Degraded:
+			state = "degraded"
+		case startup.Error != "":
+			state = "failed (ignored)"
+		}
+		fmt.Println("on_start:", startup.Action, state, "started", startup.Started.Format(time.RFC3339))
+		if startup.Error != "" {
+			fmt.Println("on_start error:", startup.Error)
+		}
+		if startup.Output != "" {
+			fmt.Println("on_start output:")
+			fmt.Println(startup.Output)
+		}
+	}
+	return nil
+}
+
+func buildLimits(limits application.BuildLimits) string {
+	var parts []string
+	if limits.Nice > 0 {
+		parts = append(parts, "nice "+strconv.Itoa(limits.Nice))
+	}
+	if limits.CPU > 0 {
+		parts = append(parts, "cpu "+strconv.FormatFloat(limits.CPU, 'f', -1, 64))
+	}
+	if limits.Memory > 0 {
+		parts = append(parts, "memory "+strconv.FormatInt(limits.Memory, 10))
+	}
+	if len(parts) == 0 {
+		return "(none)"
+	}
+	ans := strings.Join(parts, ", ")
+	if (limits.CPU > 0 || limits.Memory > 0) && !limits.Cgroup {
+		ans += " (caps are not applied: no cgroup)"
+	}
+	return ans
+}
+
+func envSize(env application.EnvStatus) string {
+	ans := fmt.Sprintf("%d of %d bytes, headroom %d bytes", env.Size, env.Limit, env.Headroom)
+	if env.Headroom < 0 {
+		ans += " (invocations fail)"
+	}
+	ans += fmt.Sprintf("; request variable limit %d bytes (%s)", env.MaxVariable, env.Oversized)
+	if len(env.Largest) > 0 {
+		var parts = make([]string, 0, len(env.Largest))
+		for _, item := range env.Largest {
+			parts = append(parts, item.Name+" "+strconv.FormatInt(item.Size, 10))
+		}
+		ans += "; largest: " + strings.Join(parts, ", ")
+	}
+	return ans
+}
+
+func valueOrDefault(value string) string {
+	if value == "" {
+		return "(inherited)"
+	}
+	return value
+}
@@ -21,6 +21,9 @@ func (cmd *download) Execute(args []string) error {
 	if err := cmd.parseUID(); err != nil {
 		return err
 	}
+	if cmd.Output == "-" && globalOptions.JSON {
+		return fmt.Errorf("stdout is used for JSON output: set output file")
+	}
 	log.Println("login...")
 	token, err := cmd.Token(ctx)
 	if err != nil {
@@ -59,5 +62,5 @@ func (cmd *download) Execute(args []string) error {
 		w += n
 	}
 	log.Println("done")
-	return nil
+	return printResult(downloadResult{UID: cmd.UID, Output: cmd.Output, Size: len(tarball)})
 }
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type env struct {
+	List  envList  `command:"ls" description:"list environment variables of the lambda"`
+	Set   envSet   `command:"set" description:"set environment variables (KEY=value)"`
+	Unset envUnset `command:"unset" description:"remove environment variables"`
+	Load  envLoad  `command:"load" description:"set environment variables from dotenv file"`
+}
+
+type envList struct {
+	manifestEditor
+	ShowSecrets bool `long:"show-secrets" env:"SHOW_SECRETS" description:"do not mask values of *_SECRET and *_TOKEN variables"`
+	Merged      bool `long:"merged" env:"MERGED" description:"show environment of invocations: global and runtime variables merged with variables of the lambda"`
+}
+
+func (cmd *envList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, err := cmd.login(ctx)
+	if err != nil {
+		return err
+	}
+	if cmd.Merged {
+		return cmd.listMerged(ctx, token)
+	}
+	env, err := cmd.Lambdas().Environment(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("get environment: %w", err)
+	}
+	var vars = make(map[string]string, len(env.Environment))
+	for k, v := range env.Environment {
+		if !cmd.ShowSecrets && isSecretEnv(k) {
+			v = "******"
+		}
+		vars[k] = v
+	}
+	if globalOptions.JSON {
+		return printJSON(vars)
+	}
+	var keys = make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Println(k + "=" + dotenvValue(vars[k]))
+	}
+	return nil
+}
+
+func (cmd *envList) listMerged(ctx context.Context, token *api.Token) error {
+	vars, err := cmd.Lambdas().MergedEnvironment(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("get merged environment: %w", err)
+	}
+	for i, v := range vars {
+		if !cmd.ShowSecrets && isSecretEnv(v.Name) {
+			vars[i].Value = "******"
+		}
+	}
+	if globalOptions.JSON {
+		return printJSON(vars)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "NAME\tVALUE\tSOURCE\tOVERRIDES")
+	for _, v := range vars {
+		overrides := strings.Join(v.Overridden, ",")
+		if overrides == "" {
+			overrides = "-"
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", v.Name, dotenvValue(v.Value), v.Source, overrides)
+	}
+	return out.Flush()
+}
+
+type envSet struct {
+	manifestEditor
+	Args struct {
+		Vars []string `positional-arg-name:"KEY=value" required:"1" description:"variables to set"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *envSet) Execute(args []string) error {
+	var vars = make(map[string]string)
+	for _, item := range cmd.Args.Vars {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("variable %q should be in KEY=value format", item)
+		}
+		if err := validateEnvKey(kv[0]); err != nil {
+			return err
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return setEnv(&cmd.manifestEditor, vars, nil)
+}
+
+type envUnset struct {
+	manifestEditor
+	Args struct {
+		Keys []string `positional-arg-name:"KEY" required:"1" description:"variables to remove"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *envUnset) Execute(args []string) error {
+	return setEnv(&cmd.manifestEditor, nil, cmd.Args.Keys)
+}
+
+type envLoad struct {
+	manifestEditor
+	Args struct {
+		File string `positional-arg-name:"file" required:"yes" description:"dotenv file (- means stdin)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *envLoad) Execute(args []string) error {
+	var data []byte
+	var err error
+	if cmd.Args.File == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(cmd.Args.File)
+	}
+	if err != nil {
+		return fmt.Errorf("read dotenv file: %w", err)
+	}
+	vars, err := parseDotenv(data)
+	if err != nil {
+		return fmt.Errorf("parse dotenv file %s: %w", cmd.Args.File, err)
+	}
+	if len(vars) == 0 {
+		log.Println("no variables in", cmd.Args.File)
+		return nil
+	}
+	return setEnv(&cmd.manifestEditor, vars, nil)
+}
+
+// set and remove variables by single update of remote environment (the rest of manifest is not changed)
+func setEnv(cmd *manifestEditor, vars map[string]string, keys []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, err := cmd.login(ctx)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		current, err := cmd.Lambdas().Environment(ctx, token, cmd.UID)
+		if err != nil {
+			return fmt.Errorf("get environment: %w", err)
+		}
+		for _, k := range keys {
+			if _, ok := current.Environment[k]; !ok {
+				log.Println("variable", k, "is not set")
+			}
+		}
+	}
+	log.Println("updating environment...")
+	_, err = cmd.Lambdas().SetEnvironment(ctx, token, cmd.UID, api.Environment{Environment: vars}, keys)
+	if err != nil {
+		return fmt.Errorf("update environment: %w", err)
+	}
+	err = patchLocal(func(m *types.Manifest) {
+		if m.Environment == nil && len(vars) > 0 {
+			m.Environment = make(map[string]string)
+		}
+		for k, v := range vars {
+			m.Environment[k] = v
+		}
+		for _, k := range keys {
+			delete(m.Environment, k)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	log.Println("done")
+	if !globalOptions.JSON {
+		return nil
+	}
+	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("get info: %w", err)
+	}
+	return printResult(manifestResult{UID: cmd.UID, Manifest: info.Manifest})
+}
+
+func isSecretEnv(key string) bool {
+	return types.IsSecretName(key)
+}
+
+func validateEnvKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "= \t\r\n\x00") {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	return nil
+}
+
+// value in dotenv format: quoted only if needed
+func dotenvValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"'\\#$") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// parse dotenv content: KEY=value lines with optional export prefix, comments (#), single-quoted (literal) and
+// double-quoted (escapes, multi-line) values
+func parseDotenv(data []byte) (map[string]string, error) {
+	var ans = make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNum)
+		}
+		key := strings.TrimSpace(kv[0])
+		if err := validateEnvKey(key); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		value := strings.TrimLeft(kv[1], " \t")
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNum)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			start := lineNum
+			raw := value[1:]
+			for !hasClosingQuote(raw) {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated double quote", start)
+				}
+				lineNum++
+				raw += "\n" + scanner.Text()
+			}
+			value = unescapeDotenv(raw)
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = value[:idx]
+			}
+			value = strings.TrimSpace(value)
+		}
+		ans[key] = value
+	}
+	return ans, scanner.Err()
+}
+
+func hasClosingQuote(raw string) bool {
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return true
+		}
+	}
+	return false
+}
+
+// interpret escapes up to the closing double quote
+func unescapeDotenv(raw string) string {
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '"' {
+			break
+		}
+		if c != '\\' || i+1 == len(raw) {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case '"', '\\', '$':
+			out.WriteByte(raw[i])
+		default:
+			out.WriteByte('\\')
+			out.WriteByte(raw[i])
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/alecthomas/units"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type frozenCmd struct {
+	Freeze   frozenFreeze   `command:"freeze" description:"archive built lambda (with installed dependencies) as new frozen artifact"`
+	List     frozenList     `command:"ls" description:"list frozen artifacts of lambda from the newest"`
+	Download frozenDownload `command:"download" description:"download archive of frozen artifact and check its hash"`
+	Thaw     frozenThaw     `command:"thaw" description:"replace files of lambda by frozen artifact without build"`
+}
+
+type frozenFreeze struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *frozenFreeze) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("freezing...")
+	artifact, err := cmd.Lambdas().Freeze(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("freeze: %w", err)
+	}
+	log.Println("frozen", artifact.ID, "of", units.Base2Bytes(artifact.Size))
+	return printResult(artifact)
+}
+
+type frozenList struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *frozenList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	artifacts, err := cmd.Lambdas().FrozenArtifacts(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("list frozen artifacts: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(artifacts)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "ID\tSIZE\tCREATED")
+	for _, artifact := range artifacts {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\n", artifact.ID, units.Base2Bytes(artifact.Size), artifact.Created.Format(time.RFC3339))
+	}
+	return out.Flush()
+}
+
+type frozenDownload struct {
+	remoteLink
+	uidLocator
+	ID     string `long:"id" env:"ID" description:"ID of frozen artifact (empty - the newest)"`
+	Output string `short:"o" long:"output" env:"OUTPUT" description:"output file (empty - <uid>-<id>.tar.gz)"`
+}
+
+func (cmd *frozenDownload) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	id := cmd.ID
+	if id == "" {
+		artifacts, err := cmd.Lambdas().FrozenArtifacts(ctx, token, cmd.UID)
+		if err != nil {
+			return fmt.Errorf("list frozen artifacts: %w", err)
+		}
+		if len(artifacts) == 0 {
+			return fmt.Errorf("lambda %s has no frozen artifacts", cmd.UID)
+		}
+		id = artifacts[0].ID
+	}
+	log.Println("download", id, "...")
+	archive, err := cmd.Lambdas().DownloadFrozen(ctx, token, cmd.UID, id)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	sum := sha256.Sum256(archive)
+	if hash := hex.EncodeToString(sum[:]); hash != id {
+		return fmt.Errorf("downloaded archive is damaged: hash is %s, expected %s", hash, id)
+	}
+	if cmd.Output == "" {
+		cmd.Output = cmd.UID + "-" + id + ".tar.gz"
+	}
+	log.Println("saving", units.Base2Bytes(len(archive)), "to", cmd.Output, "...")
+	if err := ioutil.WriteFile(cmd.Output, archive, 0600); err != nil {
+		return fmt.Errorf("save archive: %w", err)
+	}
+	log.Println("done")
+	return printResult(downloadResult{UID: cmd.UID, Output: cmd.Output, Size: len(archive)})
+}
+
+type frozenThaw struct {
+	remoteLink
+	uidLocator
+	ID string `long:"id" env:"ID" description:"ID of frozen artifact (empty - the newest)"`
+}
+
+func (cmd *frozenThaw) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("thawing...")
+	artifact, err := cmd.Lambdas().Thaw(ctx, token, cmd.UID, cmd.ID)
+	if err != nil {
+		return fmt.Errorf("thaw: %w", err)
+	}
+	log.Println("files of", cmd.UID, "restored from", artifact.ID)
+	return printResult(artifact)
+}
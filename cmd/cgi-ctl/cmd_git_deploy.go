@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type gitDeploy struct {
+	remoteLink
+	uidLocator
+	Force  bool `short:"f" long:"force" env:"FORCE" description:"deploy even the same or failed commit (ex: after change of build environment)"`
+	Status bool `short:"s" long:"status" env:"STATUS" description:"show status of deploys without fetch of branch"`
+}
+
+func (cmd *gitDeploy) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("lambda", cmd.UID)
+	var status *application.GitStatus
+	if cmd.Status {
+		info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+		if err != nil {
+			return fmt.Errorf("get info: %w", err)
+		}
+		if info.Git == nil {
+			return errors.New("lambda does not track git (see git in manifest)")
+		}
+		status = info.Git
+	} else {
+		status, err = cmd.Lambdas().GitDeploy(ctx, token, cmd.UID, cmd.Force)
+		if err != nil {
+			return fmt.Errorf("deploy from git: %w", err)
+		}
+	}
+	if globalOptions.JSON {
+		if err := printJSON(status); err != nil {
+			return err
+		}
+	} else {
+		printGitStatus(status)
+	}
+	if status.Error != "" {
+		return errors.New("deploy failed: " + status.Error)
+	}
+	return nil
+}
+
+func printGitStatus(status *application.GitStatus) {
+	branch := status.Branch
+	if branch == "" {
+		branch = "(default)"
+	}
+	fmt.Println("repo:", status.Repo)
+	fmt.Println("branch:", branch)
+	fmt.Println("commit:", dash(status.Commit))
+	if !status.Deployed.IsZero() {
+		fmt.Println("deployed:", status.Deployed.Format(time.RFC3339))
+	}
+	if !status.Checked.IsZero() {
+		fmt.Println("checked:", status.Checked.Format(time.RFC3339))
+	}
+	if status.Running {
+		fmt.Println("running: yes")
+	}
+	if status.Failed != "" {
+		fmt.Println("failed:", status.Failed)
+	}
+	if status.Error != "" {
+		fmt.Println("error:", status.Error)
+	}
+	if status.Output != "" {
+		fmt.Println("output:")
+		fmt.Println(status.Output)
+	}
+}
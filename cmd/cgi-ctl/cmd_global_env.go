@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"sort"
+	"strings"
+)
+
+type globalEnv struct {
+	List  globalEnvList  `command:"ls" description:"list global environment variables of the server"`
+	Set   globalEnvSet   `command:"set" description:"set global environment variables (KEY=value)"`
+	Unset globalEnvUnset `command:"unset" description:"remove global environment variables"`
+}
+
+type globalEnvList struct {
+	remoteLink
+	ShowSecrets bool `long:"show-secrets" env:"SHOW_SECRETS" description:"do not mask values of *_SECRET and *_TOKEN variables"`
+}
+
+func (cmd *globalEnvList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	settings, err := cmd.Project().Config(ctx, token)
+	if err != nil {
+		return fmt.Errorf("get global environment: %w", err)
+	}
+	var vars = make(map[string]string, len(settings.Environment))
+	for k, v := range settings.Environment {
+		if !cmd.ShowSecrets && isSecretEnv(k) {
+			v = "******"
+		}
+		vars[k] = v
+	}
+	if globalOptions.JSON {
+		return printJSON(vars)
+	}
+	var keys = make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Println(k + "=" + dotenvValue(vars[k]))
+	}
+	return nil
+}
+
+type globalEnvSet struct {
+	remoteLink
+	Args struct {
+		Vars []string `positional-arg-name:"KEY=value" required:"1" description:"variables to set"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *globalEnvSet) Execute(args []string) error {
+	var vars = make(map[string]string)
+	for _, item := range cmd.Args.Vars {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("variable %q should be in KEY=value format", item)
+		}
+		if err := validateEnvKey(kv[0]); err != nil {
+			return err
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return setGlobalEnv(&cmd.remoteLink, vars, nil)
+}
+
+type globalEnvUnset struct {
+	remoteLink
+	Args struct {
+		Keys []string `positional-arg-name:"KEY" required:"1" description:"variables to remove"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *globalEnvUnset) Execute(args []string) error {
+	return setGlobalEnv(&cmd.remoteLink, nil, cmd.Args.Keys)
+}
+
+// set and remove variables by single update of global environment of server
+func setGlobalEnv(cmd *remoteLink, vars map[string]string, keys []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if len(keys) > 0 {
+		settings, err := cmd.Project().Config(ctx, token)
+		if err != nil {
+			return fmt.Errorf("get global environment: %w", err)
+		}
+		for _, k := range keys {
+			if _, ok := settings.Environment[k]; !ok {
+				log.Println("variable", k, "is not set")
+			}
+		}
+	}
+	log.Println("updating global environment...")
+	env, err := cmd.Project().UpdateEnvironment(ctx, token, api.Environment{Environment: vars}, keys)
+	if err != nil {
+		return fmt.Errorf("update global environment: %w", err)
+	}
+	log.Println("done")
+	if !globalOptions.JSON {
+		return nil
+	}
+	return printJSON(env.Environment)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type importCmd struct {
+	remoteLink
+	uidLocator
+	Update bool   `long:"update" env:"UPDATE" description:"replace content of existent lambda (by --uid or control file) instead of creating new lambda"`
+	SHA256 string `long:"sha256" env:"SHA256" description:"expected SHA-256 of archive in hex: import fails on mismatch"`
+	Action string `short:"a" long:"action" env:"ACTION" description:"post-clone action run after extraction (default - post_clone of template.json in archive, if any)"`
+	Args   struct {
+		URL string `positional-arg-name:"url" description:"http or https URL of .tar.gz or .zip archive" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *importCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	request := application.ArtifactImport{URL: cmd.Args.URL, SHA256: cmd.SHA256, Action: cmd.Action}
+	if cmd.Update {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+		request.UID = cmd.UID
+	} else if cmd.UID != "" {
+		return fmt.Errorf("--uid requires --update")
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("importing", request.URL, "...")
+	report, err := cmd.Project().ImportURL(ctx, token, request)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(report)
+	}
+	if report.Created {
+		fmt.Println("created:", report.UID)
+	} else {
+		fmt.Println("updated:", report.UID)
+	}
+	fmt.Println("sha256:", report.SHA256)
+	fmt.Println("size:", report.Size)
+	fmt.Println("action:", dash(report.Action))
+	if report.Warning != "" {
+		fmt.Println("warning:", report.Warning)
+	}
+	return nil
+}
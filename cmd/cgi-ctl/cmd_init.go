@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type initCmd struct {
+	Bare     Bare   `command:"bare" description:"create bare template"`
+	Template string `long:"template" env:"TEMPLATE" description:"name of embedded template (default - minimal manifest)"`
+	manifestFormat
+}
+
+// scaffold lambda from embedded template without server: manifest, files of template and .cgiignore.
+// Control file is created by create
+func (cmd *initCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	// directory is not a positional argument: it would shadow bare subcommand
+	dir := "."
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	} else if len(args) == 1 {
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{internal_app.ManifestFile, internal_app.ManifestYAML} {
+		manifestFile := filepath.Join(dir, name)
+		if _, err := os.Stat(manifestFile); err == nil {
+			return fmt.Errorf("%s already exists", manifestFile)
+		}
+	}
+
+	manifest := defaultManifest()
+	var template *templates.Template
+	if cmd.Template != "" {
+		template, err = embeddedTemplate(cmd.Template)
+		if err != nil {
+			return err
+		}
+		manifest = template.Manifest
+	}
+	if manifest.Name == "" {
+		manifest.Name = filepath.Base(dir)
+	}
+	if err := manifest.Validate(); err != nil {
+		return fmt.Errorf("invalid manifest of template: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("prepare directory: %w", err)
+	}
+	if template != nil {
+		log.Println("writing files of template", cmd.Template, "...")
+		if err := template.Materialize(ctx, dir); err != nil {
+			return fmt.Errorf("write files of template: %w", err)
+		}
+	}
+	if err := cmd.save(dir, manifest); err != nil {
+		return fmt.Errorf("save manifest: %w", err)
+	}
+	// control file will be created by create
+	if err := appendIfNoLineFile(filepath.Join(dir, internal_app.CGIIgnore), controlFilename); err != nil {
+		return fmt.Errorf("update cgiignore file: %w", err)
+	}
+	if template != nil && template.PostClone != "" {
+		log.Println("invoke post-clone action on the server after create: cgi-ctl do", template.PostClone)
+	}
+	return printResult(localResult{Name: manifest.Name, Dir: dir})
+}
+
+// the same as bare template with default options
+func defaultManifest() types.Manifest {
+	return types.Manifest{
+		Run: []string{"/bin/echo", "[\"hello\", \"world\"]"},
+		OutputHeaders: map[string]string{
+			"Content-Type": "application/json",
+		},
+		TimeLimit:      types.JsonDuration(10 * time.Second),
+		MaximumPayload: 8192,
+	}
+}
+
+// embedded template by name (case-insensitive)
+func embeddedTemplate(name string) (*templates.Template, error) {
+	list := templates.ListEmbedded()
+	if t, ok := list[name]; ok {
+		return t, nil
+	}
+	var names = make([]string, 0, len(list))
+	for known, t := range list {
+		if strings.EqualFold(known, name) {
+			return t, nil
+		}
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown template %s, available: %s", name, strings.Join(names, ", "))
+}
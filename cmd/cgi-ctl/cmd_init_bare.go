@@ -16,6 +16,7 @@ type Bare struct {
 	Description string        `short:"d" long:"description" env:"DESCRIPTION" description:"Description" default:"Bare project"`
 	TimeLimit   time.Duration `short:"t" long:"time-limit" env:"TIME_LIMIT" description:"Time limit for execution" default:"10s"`
 	MaxPayload  int64         `short:"p" long:"max-payload" env:"MAX_PAYLOAD" description:"Maximum payload" default:"8192"`
+	manifestFormat
 }
 
 func (b Bare) Execute(args []string) error {
@@ -35,7 +36,7 @@ func (b Bare) Execute(args []string) error {
 		MaximumPayload: b.MaxPayload,
 	}
 
-	err = def.SaveAs(internal_app.ManifestFile)
+	err = b.save(".", def)
 	if err != nil {
 		return err
 	}
@@ -58,7 +59,9 @@ func (b Bare) Execute(args []string) error {
 	if b.Git {
 		gctx, closer := internal.SignalContext()
 		defer closer()
-		return exec.CommandContext(gctx, "git", "init").Run()
+		if err := exec.CommandContext(gctx, "git", "init").Run(); err != nil {
+			return err
+		}
 	}
-	return nil
+	return printResult(localResult{Name: def.Name, Dir: wd})
 }
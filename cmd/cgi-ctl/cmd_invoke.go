@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"github.com/alecthomas/units"
 	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 type invoke struct {
@@ -25,6 +28,8 @@ type invoke struct {
 	Header      map[string]string `short:"H" long:"header" env:"HEADER" description:"custom headers"`
 	Field       map[string]string `short:"f" long:"field" env:"FIELD" description:"set JSON field (input will be ignored)"`
 	Verbose     bool              `short:"v" long:"verbose" env:"VERBOSE" description:"show logs"`
+	Async       bool              `long:"async" env:"ASYNC" description:"invoke asynchronously: submit request and poll for result"`
+	Poll        time.Duration     `long:"poll" env:"POLL" description:"poll interval of asynchronous invocation" default:"1s"`
 }
 
 func (cmd *invoke) Execute(args []string) error {
@@ -36,9 +41,12 @@ func (cmd *invoke) Execute(args []string) error {
 	if err := cmd.parseUID(); err != nil {
 		return err
 	}
-	var cf controlFile
-	if err := cf.Read(controlFilename); err != nil {
-		return fmt.Errorf("read control file: %w", err)
+	cf, err := readRemote()
+	if err != nil {
+		return err
+	}
+	if cf == nil {
+		return fmt.Errorf("read control file: remote %s is not defined", globalOptions.Remote)
 	}
 	ctx, closer := internal.SignalContext()
 	defer closer()
@@ -49,6 +57,9 @@ func (cmd *invoke) Execute(args []string) error {
 	}
 	log.Println("request body size:", units.Base2Bytes(len(body)))
 	url := urlJoin(cf.URL, "a", cmd.UID)
+	if cmd.Async {
+		url = urlJoin(cf.URL, "async", "a", cmd.UID)
+	}
 	req, err := http.NewRequestWithContext(ctx, cmd.getMethod(), url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("prepare request: %w", err)
@@ -65,6 +76,13 @@ func (cmd *invoke) Execute(args []string) error {
 		return fmt.Errorf("do request: %w", err)
 	}
 	defer res.Body.Close()
+	if cmd.Async && res.StatusCode == http.StatusAccepted {
+		res, err = cmd.poll(ctx, cf.URL, res)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+	}
 	log.Println("status code:", res.StatusCode)
 	for k, v := range res.Header {
 		log.Println(k, "=", v)
@@ -74,12 +92,54 @@ func (cmd *invoke) Execute(args []string) error {
 		exitCode = res.StatusCode / 100
 	}
 
-	_, _ = io.Copy(os.Stdout, res.Body)
+	if globalOptions.JSON {
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("read response: %w", err)
+		}
+		_ = printJSON(newResponseResult(res.StatusCode, res.Header, data))
+	} else {
+		_, _ = io.Copy(os.Stdout, res.Body)
+	}
 
 	os.Exit(exitCode)
 	return nil
 }
 
+// poll result of accepted asynchronous invocation until it is ready. Result not found without Retry-After is unknown
+// or expired
+func (cmd *invoke) poll(ctx context.Context, base string, accepted *http.Response) (*http.Response, error) {
+	var ticket types.AsyncTicket
+	if err := json.NewDecoder(accepted.Body).Decode(&ticket); err != nil {
+		return nil, fmt.Errorf("decode ticket: %w", err)
+	}
+	log.Println("ticket", ticket.Ticket, "request", accepted.Header.Get(types.RequestIDHeader))
+	url := urlJoin(base, strings.TrimPrefix(ticket.Result, "/"))
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("prepare request: %w", err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("poll result: %w", err)
+		}
+		if res.StatusCode != http.StatusNotFound {
+			return res, nil
+		}
+		_ = res.Body.Close()
+		if res.Header.Get("Retry-After") == "" {
+			return nil, fmt.Errorf("result of ticket %s is unknown or expired", ticket.Ticket)
+		}
+		log.Println("waiting for result...")
+		select {
+		case <-time.After(cmd.Poll):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
 func (cmd *invoke) getBody(ctx context.Context) ([]byte, error) {
 	if len(cmd.Field) > 0 {
 		return json.MarshalIndent(cmd.Field, "", "  ")
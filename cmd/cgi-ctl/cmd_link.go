@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"log"
+	"os"
+)
+
+type link struct {
+	remoteLink
+	manifestFormat
+	Force        bool `short:"f" long:"force" env:"FORCE" description:"relink directory which already has a control file (the selected remote is replaced)"`
+	PullManifest bool `long:"pull-manifest" env:"PULL_MANIFEST" description:"download manifest of the lambda (other files are not touched)"`
+	Args         struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+// attach current directory to the existing lambda: only control file (and with flag - manifest) is written
+func (cmd *link) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	var cf controlFile
+	if err := cf.Read(controlFilename); err == nil {
+		if !cmd.Force {
+			return fmt.Errorf("directory is already linked by %s, use --force to relink", controlFilename)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get work dir: %w", err)
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	// link by UID: aliases could be changed
+	def, err := cmd.FindLambda(ctx, token, cmd.Args.Lambda)
+	if err != nil {
+		return err
+	}
+	log.Println("linking to", def.UID, "...")
+	if cmd.PullManifest {
+		if err := cmd.save(".", def.Manifest); err != nil {
+			return fmt.Errorf("save manifest: %w", err)
+		}
+	}
+	// manifest of the server is the base of the next apply, so local changes are ours
+	base := def.Manifest
+	revision, err := base.Hash()
+	if err != nil {
+		return fmt.Errorf("hash manifest: %w", err)
+	}
+	cf.SetRemote(globalOptions.Remote, controlRemote{URL: cmd.URL, UID: def.UID, Revision: revision, Base: &base})
+	if err := cf.Save(controlFilename); err != nil {
+		return fmt.Errorf("save control file: %w", err)
+	}
+	if err := appendIfNoLineFile(internal_app.CGIIgnore, controlFilename); err != nil {
+		return fmt.Errorf("update cgiignore file: %w", err)
+	}
+	log.Println("done")
+	return printResult(localResult{UID: def.UID, Name: def.Manifest.Name, Dir: wd, URL: cmd.URL})
+}
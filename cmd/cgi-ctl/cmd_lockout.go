@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+type lockoutCmd struct {
+	List  lockoutList  `command:"ls" description:"list logins and client addresses with failed logins, including locked out ones"`
+	Clear lockoutClear `command:"clear" description:"clear lockout and failed logins of logins or client addresses (without arguments - all)"`
+}
+
+type lockoutList struct {
+	remoteLink
+}
+
+func (cmd *lockoutList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	list, err := cmd.Users().Lockouts(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list lockouts: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(list)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "KIND\tSUBJECT\tFAILURES\tLOCKED UNTIL")
+	for _, item := range list {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%d\t%s\n", item.Kind, item.Subject, item.Failures, formatTime(item.LockedUntil))
+	}
+	return out.Flush()
+}
+
+type lockoutClear struct {
+	remoteLink
+	Args struct {
+		Subjects []string `positional-arg-name:"subject" description:"login or client address"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *lockoutClear) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	subjects := cmd.Args.Subjects
+	if len(subjects) == 0 {
+		subjects = []string{""}
+	}
+	var cleared int
+	for _, subject := range subjects {
+		n, err := cmd.Users().Unlock(ctx, token, subject)
+		if err != nil {
+			return fmt.Errorf("clear lockout %s: %w", subject, err)
+		}
+		cleared += n
+	}
+	log.Println("cleared", cleared, "lockouts")
+	return printResult(cleared)
+}
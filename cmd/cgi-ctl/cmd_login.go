@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+)
+
+type login struct {
+	remoteLink
+	SaveKeyring bool `long:"save-keyring" env:"SAVE_KEYRING" description:"save password to OS keyring for following commands"`
+}
+
+func (cmd *login) Execute([]string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.resolve(); err != nil {
+		return err
+	}
+	if _, err := cmd.login(ctx); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	key := tokenCacheKey(cmd.URL, cmd.Login)
+	if cmd.SaveKeyring {
+		if err := keyringSet(key, cmd.Password); err != nil {
+			return fmt.Errorf("save password to keyring: %w", err)
+		}
+		log.Println("password for", key, "saved to keyring")
+	}
+	log.Println("logged in as", key)
+	return printResult(credentialsResult{Account: key, Token: !cmd.NoTokenCache && !cmd.Ghost, Keyring: cmd.SaveKeyring})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+type logout struct {
+	remoteLink
+	All bool `long:"all" env:"ALL" description:"remove cached tokens of all servers and logins"`
+}
+
+func (cmd *logout) Execute([]string) error {
+	if cmd.All {
+		location, err := tokenCacheLocation()
+		if err != nil {
+			return fmt.Errorf("locate token cache: %w", err)
+		}
+		if err := os.Remove(location); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove token cache: %w", err)
+		}
+		log.Println("all cached tokens removed")
+		return printResult(credentialsResult{Token: true})
+	}
+	if err := cmd.resolve(); err != nil {
+		return err
+	}
+	key := tokenCacheKey(cmd.URL, cmd.Login)
+	var result = credentialsResult{Account: key}
+	if _, err := keyringGet(key); err == nil {
+		if err := keyringDelete(key); err != nil {
+			return fmt.Errorf("remove password from keyring: %w", err)
+		}
+		log.Println("password for", key, "removed from keyring")
+		result.Keyring = true
+	}
+	cache, err := readTokenCache()
+	if err != nil {
+		return fmt.Errorf("read token cache: %w", err)
+	}
+	if _, ok := cache[key]; !ok {
+		log.Println("no cached token for", key)
+		return printResult(result)
+	}
+	delete(cache, key)
+	if err := cache.Save(); err != nil {
+		return fmt.Errorf("save token cache: %w", err)
+	}
+	log.Println("cached token for", key, "removed")
+	result.Token = true
+	return printResult(result)
+}
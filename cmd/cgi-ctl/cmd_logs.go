@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/alecthomas/units"
+	"github.com/google/uuid"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/stats"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type logs struct {
+	remoteLink
+	uidLocator
+	Limit    int           `short:"n" long:"limit" env:"LIMIT" description:"maximum number of records" default:"50"`
+	Since    time.Duration `short:"s" long:"since" env:"SINCE" description:"show records not older than duration (ex: 1h)"`
+	Follow   bool          `short:"f" long:"follow" env:"FOLLOW" description:"poll for new records"`
+	Interval time.Duration `long:"interval" env:"INTERVAL" description:"poll interval for follow mode" default:"3s"`
+	Verbose  bool          `short:"v" long:"verbose" env:"VERBOSE" description:"show timing breakdown, exit of process and tail of stderr of lambda"`
+	Download bool          `long:"download" env:"DOWNLOAD" description:"download log files of lambda (logs of manifest) instead of records"`
+	File     string        `long:"file" env:"FILE" description:"download only log file by name (empty - all log files)"`
+	Output   string        `short:"o" long:"output" env:"OUTPUT" description:"output directory of downloaded log files (empty - <uid>-logs)"`
+	Tail     int           `long:"tail" env:"TAIL" description:"print the last lines of current log file of lambda instead of records"`
+	Args     struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *logs) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if err := cmd.resolveUID(ctx, token); err != nil {
+		return err
+	}
+	log.Println("lambda", cmd.UID)
+	if cmd.Download {
+		return cmd.download(ctx, token)
+	}
+	if cmd.Tail > 0 {
+		tail, err := cmd.Lambdas().TailLog(ctx, token, cmd.UID, cmd.Tail)
+		if err != nil {
+			return fmt.Errorf("tail log: %w", err)
+		}
+		fmt.Print(tail)
+		return nil
+	}
+
+	var last time.Time
+	if cmd.Since > 0 {
+		last = time.Now().Add(-cmd.Since)
+	}
+	for {
+		records, err := cmd.Lambdas().Stats(ctx, token, cmd.UID, cmd.Limit)
+		if err != nil {
+			return fmt.Errorf("get records: %w", err)
+		}
+		// records are sorted from newest to oldest
+		for i := len(records) - 1; i >= 0; i-- {
+			record := records[i]
+			if !record.Begin.After(last) {
+				continue
+			}
+			last = record.Begin
+			if err := cmd.print(record); err != nil {
+				return err
+			}
+		}
+		if !cmd.Follow {
+			return nil
+		}
+		select {
+		case <-time.After(cmd.Interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// save log files (or only file of flag) to output directory: files are replaced, so the current file is updated
+func (cmd *logs) download(ctx context.Context, token *api.Token) error {
+	files, err := cmd.Lambdas().LogFiles(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("list log files: %w", err)
+	}
+	if cmd.File != "" {
+		var found []application.LogFile
+		for _, file := range files {
+			if file.Name == cmd.File {
+				found = append(found, file)
+			}
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("lambda %s has no log file %s", cmd.UID, cmd.File)
+		}
+		files = found
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("lambda %s has no log files (see logs in manifest)", cmd.UID)
+	}
+	if cmd.Output == "" {
+		cmd.Output = cmd.UID + "-logs"
+	}
+	if err := os.MkdirAll(cmd.Output, 0700); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	var saved []downloadResult
+	for _, file := range files {
+		log.Println("download", file.Name, "...")
+		content, err := cmd.Lambdas().DownloadLog(ctx, token, cmd.UID, file.Name)
+		if err != nil {
+			return fmt.Errorf("download %s: %w", file.Name, err)
+		}
+		output := filepath.Join(cmd.Output, file.Name)
+		log.Println("saving", units.Base2Bytes(len(content)), "to", output, "...")
+		if err := ioutil.WriteFile(output, content, 0600); err != nil {
+			return fmt.Errorf("save log file: %w", err)
+		}
+		saved = append(saved, downloadResult{UID: cmd.UID, Output: output, Size: len(content)})
+	}
+	log.Println("done")
+	return printResult(saved)
+}
+
+func (cmd *logs) resolveUID(ctx context.Context, token *api.Token) error {
+	if cmd.Args.Lambda == "" {
+		return cmd.parseUID()
+	}
+	if _, err := uuid.Parse(cmd.Args.Lambda); err == nil {
+		cmd.UID = cmd.Args.Lambda
+		return nil
+	}
+	list, err := cmd.Project().List(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list lambdas: %w", err)
+	}
+	for _, def := range list {
+		if def.Aliases.Has(cmd.Args.Lambda) {
+			cmd.UID = def.UID
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown lambda or alias %s", cmd.Args.Lambda)
+}
+
+func (cmd *logs) print(record stats.Record) error {
+	if globalOptions.JSON {
+		return printJSON(record)
+	}
+	status := "ok"
+	if record.Err != "" {
+		status = "error: " + record.Err
+	}
+	if record.Retried {
+		status = fmt.Sprintf("retried (attempt %d): %s", record.Attempt, record.Err)
+	}
+	if record.CatchUp {
+		status += " (catch-up)"
+	}
+	fmt.Println(record.Begin.Format(time.RFC3339), record.Request.Method, record.Request.URL, record.Request.RemoteAddress,
+		record.End.Sub(record.Begin).Round(time.Millisecond), status)
+	if cmd.Verbose {
+		if timing := formatTiming(record); timing != "" {
+			fmt.Println("  timing:", timing)
+		}
+	}
+	if cmd.Verbose && len(record.Stderr) > 0 {
+		for _, line := range strings.Split(strings.TrimRight(string(record.Stderr), "\n"), "\n") {
+			fmt.Println("  |", line)
+		}
+	}
+	return nil
+}
+
+// breakdown of invocation time: only measured parts, then exit code or signal of process (empty - nothing measured)
+func formatTiming(record stats.Record) string {
+	var parts []string
+	for _, part := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"queue", record.QueueWait},
+		{"wait", record.Wait},
+		{"spawn", record.Spawn},
+		{"first byte", record.FirstByte},
+		{"runtime", record.Runtime},
+		{"write", record.Write},
+	} {
+		if part.value > 0 {
+			parts = append(parts, part.name+" "+formatDuration(part.value))
+		}
+	}
+	if record.Signal != "" {
+		parts = append(parts, "signal "+record.Signal)
+	} else if record.ExitCode != 0 {
+		parts = append(parts, "exit code "+strconv.Itoa(record.ExitCode))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// duration with precision of microseconds below a millisecond
+func formatDuration(value time.Duration) string {
+	if value < time.Millisecond {
+		return value.Round(time.Microsecond).String()
+	}
+	return value.Round(time.Millisecond).String()
+}
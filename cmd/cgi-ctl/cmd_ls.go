@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type list struct {
+	remoteLink
+	Filter []string `short:"f" long:"filter" env:"FILTER" env-delim:"," description:"filter by name=substr or alias=substr (all filters should match)"`
+	Quiet  bool     `short:"q" long:"quiet" env:"QUIET" description:"print only UIDs"`
+}
+
+func (cmd *list) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	filters, err := parseListFilters(cmd.Filter)
+	if err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	defs, err := cmd.Project().List(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list lambdas: %w", err)
+	}
+	var items = make([]lambdaItem, 0, len(defs))
+	for _, def := range defs {
+		if !filters.match(def) {
+			continue
+		}
+		items = append(items, newLambdaItem(def))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].UID < items[j].UID
+	})
+
+	switch {
+	case globalOptions.JSON:
+		return printJSON(items)
+	case cmd.Quiet:
+		for _, item := range items {
+			fmt.Println(item.UID)
+		}
+		return nil
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "UID\tNAME\tALIASES\tMODIFIED\tENABLED\tSCHEDULED\tDISK")
+	for _, item := range items {
+		scheduled := "no"
+		if item.Scheduled {
+			scheduled = "yes"
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", item.UID, item.Name, strings.Join(item.Aliases, ","),
+			item.Modified.Local().Format(time.RFC3339), yesNo(item.Enabled), scheduled, formatDisk(item.Disk))
+	}
+	return out.Flush()
+}
+
+// size of lambda directory with quota (if set), ex: 12MiB/50MiB (24%)
+func formatDisk(usage *application.DiskUsage) string {
+	switch {
+	case usage == nil:
+		return "-"
+	case usage.Quota <= 0:
+		return formatSize(usage.Size)
+	}
+	return formatSize(usage.Size) + "/" + formatSize(usage.Quota) + " (" + fmt.Sprint(usage.Size*100/usage.Quota) + "%)"
+}
+
+type listFilters struct {
+	names   []string
+	aliases []string
+}
+
+func parseListFilters(filters []string) (*listFilters, error) {
+	var ans listFilters
+	for _, filter := range filters {
+		kv := strings.SplitN(filter, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("filter %q should be in field=substr format", filter)
+		}
+		switch kv[0] {
+		case "name":
+			ans.names = append(ans.names, kv[1])
+		case "alias":
+			ans.aliases = append(ans.aliases, kv[1])
+		default:
+			return nil, fmt.Errorf("unknown filter field %s (supported: name, alias)", kv[0])
+		}
+	}
+	return &ans, nil
+}
+
+func (lf *listFilters) match(def application.Definition) bool {
+	for _, name := range lf.names {
+		if !strings.Contains(def.Manifest.Name, name) {
+			return false
+		}
+	}
+	for _, substr := range lf.aliases {
+		var found bool
+		for alias := range def.Aliases {
+			if strings.Contains(alias, substr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
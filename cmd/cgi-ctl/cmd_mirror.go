@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type mirrorCmd struct {
+	Status  mirrorStatus  `command:"status" description:"show status of replication of read-only mirror"`
+	Promote mirrorPromote `command:"promote" description:"promote read-only mirror to primary: stop replication and enable changes"`
+}
+
+type mirrorStatus struct {
+	remoteLink
+}
+
+func (cmd *mirrorStatus) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	status, err := cmd.Project().Mirror(ctx, token)
+	if err != nil {
+		return fmt.Errorf("get mirror status: %w", err)
+	}
+	return printMirror(status)
+}
+
+type mirrorPromote struct {
+	remoteLink
+}
+
+func (cmd *mirrorPromote) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("promoting mirror to primary...")
+	status, err := cmd.Project().Promote(ctx, token)
+	if err != nil {
+		return fmt.Errorf("promote mirror: %w", err)
+	}
+	return printMirror(status)
+}
+
+func printMirror(status *application.MirrorStatus) error {
+	if globalOptions.JSON {
+		return printJSON(status)
+	}
+	if status.Primary == "" {
+		fmt.Println("server is not a mirror")
+		return nil
+	}
+	state := "read-only mirror"
+	if !status.Enabled {
+		state = "promoted to primary at " + status.Promoted.Format(time.RFC3339)
+	}
+	fmt.Println("primary:   ", status.Primary)
+	fmt.Println("state:     ", state)
+	fmt.Println("interval:  ", time.Duration(status.Interval))
+	if status.LastSync.IsZero() {
+		fmt.Println("last sync:  never")
+	} else {
+		fmt.Println("last sync: ", status.LastSync.Format(time.RFC3339))
+	}
+	if status.LastError != "" {
+		fmt.Println("last error:", status.LastError)
+	}
+	fmt.Println("lambdas:   ", status.Lambdas)
+	fmt.Println("syncs:     ", status.Syncs, "failed", status.Failures)
+	fmt.Println("pulls:     ", status.Pulls)
+	if len(status.Diverged) == 0 {
+		return nil
+	}
+	fmt.Println()
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "DIVERGED\tREASON")
+	for _, item := range status.Diverged {
+		_, _ = fmt.Fprintf(out, "%s\t%s\n", item.Object, item.Reason)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/trustedcgi"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// starter fixture: two lambdas with aliases, schedules and invocation records
+//
+//go:embed fixture
+var starterFixture embed.FS
+
+const (
+	fixtureRoot  = "fixture"
+	fixtureStats = "stats.json" // invocation records imported while state has no records
+)
+
+type mockServer struct {
+	State    string `short:"s" long:"state" env:"STATE" description:"directory of fixture state: lambdas, manifests and stats (starter fixture is created if directory is empty)" default:"mock"`
+	Bind     string `short:"b" long:"bind" env:"BIND" description:"binding address" default:"127.0.0.1:3434"`
+	Password string `long:"admin-password" env:"ADMIN_PASSWORD" description:"password of admin if not yet set in state" default:"admin"`
+}
+
+func (cmd *mockServer) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	instance, err := startMock(ctx, cmd.State, cmd.Password)
+	if err != nil {
+		return err
+	}
+	defer instance.Stop()
+	log.Println("mock server on", cmd.Bind, "with state", cmd.State)
+	err = instance.ListenAndServe(cmd.Bind)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Instance of trusted-cgi over fixture state: API handlers, storages and lambdas are the same as on the real server,
+// SSH and scheduler are disabled. Empty (or missing) state is initialized by the starter fixture, records of fixture
+// stats are imported once and are not limited by retention (fixture records have fixed dates)
+func startMock(ctx context.Context, state string, password string) (*trustedcgi.Instance, error) {
+	if err := writeFixture(state); err != nil {
+		return nil, fmt.Errorf("initialize state: %w", err)
+	}
+	instance, err := trustedcgi.Default().Context(ctx).Directory(state).Password(password).SSH(false).Scheduler(false).StatsRetention(0, 0).New()
+	if err != nil {
+		return nil, fmt.Errorf("initialize mock server: %w", err)
+	}
+	if err := importStats(instance, filepath.Join(state, fixtureStats)); err != nil {
+		instance.Stop()
+		return nil, err
+	}
+	return instance, nil
+}
+
+// copy starter fixture to the directory if it is empty or doesn't exist
+func writeFixture(dir string) error {
+	list, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(list) > 0 {
+		return nil
+	}
+	log.Println("writing starter fixture to", dir)
+	return fs.WalkDir(starterFixture, fixtureRoot, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, fixtureRoot)))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := starterFixture.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
+
+// track records of fixture stats file (if exists) if instance has no records yet and dump them
+func importStats(instance *trustedcgi.Instance, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read fixture stats: %w", err)
+	}
+	tracker := instance.Server().Tracker
+	if reader, ok := tracker.(stats.Reader); ok {
+		if last, err := reader.Last(1); err == nil && len(last) > 0 {
+			return nil
+		}
+	}
+	var records []stats.Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("parse fixture stats %s: %w", file, err)
+	}
+	for _, record := range records {
+		tracker.Track(record)
+	}
+	if dumper, ok := tracker.(interface{ Dump() error }); ok {
+		if err := dumper.Dump(); err != nil {
+			return fmt.Errorf("dump imported stats: %w", err)
+		}
+	}
+	log.Println("imported", len(records), "records from", file)
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+type openAPI struct {
+	remoteLink
+	Lambda    string `long:"lambda" env:"LAMBDA" description:"UID or alias of the only lambda in document (empty - all lambdas)"`
+	PublicURL string `long:"public-url" env:"PUBLIC_URL" description:"public base URL of server in document, ignored if server has configured one (empty - remote URL)"`
+	Output    string `short:"o" long:"output" env:"OUTPUT" description:"output file (empty - stdout)"`
+}
+
+func (cmd *openAPI) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	var uid string
+	if cmd.Lambda != "" {
+		def, err := cmd.FindLambda(ctx, token, cmd.Lambda)
+		if err != nil {
+			return err
+		}
+		uid = def.UID
+	}
+	publicURL := cmd.PublicURL
+	if publicURL == "" {
+		publicURL = cmd.URL
+	}
+	doc, err := cmd.Project().OpenAPI(ctx, token, uid, publicURL)
+	if err != nil {
+		return fmt.Errorf("generate OpenAPI document: %w", err)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if cmd.Output != "" {
+		log.Println("document with", len(doc.Paths), "paths saved to", cmd.Output)
+		return ioutil.WriteFile(cmd.Output, data, 0644)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
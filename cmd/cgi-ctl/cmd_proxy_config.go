@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+// Formats of reverse-proxy configuration
+const (
+	proxyNginx   = "nginx"
+	proxyTraefik = "traefik"
+	proxyCaddy   = "caddy"
+)
+
+type proxyConfig struct {
+	remoteLink
+	Format     string `short:"f" long:"format" env:"FORMAT" description:"format of configuration" default:"nginx" choice:"nginx" choice:"traefik" choice:"caddy"`
+	Upstream   string `long:"upstream" env:"UPSTREAM" description:"address of trusted-cgi daemon for proxy: host:port or URL (empty - host of remote URL)"`
+	ServerName string `long:"server-name" env:"SERVER_NAME" description:"public host name of proxy (empty - any host)"`
+	TLS        bool   `long:"tls" env:"TLS" description:"add hints of TLS termination by proxy (certificate placeholders, HTTPS entry point)"`
+	Output     string `short:"o" long:"output" env:"OUTPUT" description:"output file (empty - stdout)"`
+}
+
+func (cmd *proxyConfig) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	upstream := cmd.Upstream
+	if upstream == "" {
+		upstream = cmd.URL
+	}
+	options, err := newProxyOptions(upstream, cmd.ServerName, cmd.TLS)
+	if err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	routes, err := cmd.Project().Routes(ctx, token)
+	if err != nil {
+		return fmt.Errorf("get routes: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(routes)
+	}
+	config, err := renderProxyConfig(cmd.Format, options, routes)
+	if err != nil {
+		return err
+	}
+	if cmd.Output != "" {
+		return ioutil.WriteFile(cmd.Output, []byte(config), 0644)
+	}
+	fmt.Print(config)
+	return nil
+}
+
+// options of generated reverse-proxy configuration
+type proxyOptions struct {
+	Upstream   *url.URL // base URL of daemon (scheme and host)
+	ServerName string   // public host name (empty - any)
+	TLS        bool     // hints of TLS termination
+}
+
+func newProxyOptions(upstream, serverName string, tls bool) (proxyOptions, error) {
+	if !strings.Contains(upstream, "://") {
+		upstream = "http://" + upstream
+	}
+	u, err := url.Parse(upstream)
+	if err != nil || u.Host == "" {
+		return proxyOptions{}, fmt.Errorf("invalid upstream %q: should be host:port or URL", upstream)
+	}
+	return proxyOptions{Upstream: &url.URL{Scheme: u.Scheme, Host: u.Host}, ServerName: serverName, TLS: tls}, nil
+}
+
+// configuration of reverse proxy in format (nginx, traefik or caddy) by routes of lambdas
+func renderProxyConfig(format string, options proxyOptions, routes []application.LambdaRoutes) (string, error) {
+	var out strings.Builder
+	out.WriteString("# generated by cgi-ctl proxy-config: regenerate after changes of lambdas, aliases and queues\n")
+	out.WriteString("# start trusted-cgi with --behind-proxy to respect forwarded headers\n")
+	switch format {
+	case proxyNginx:
+		renderNginx(&out, options, routes)
+	case proxyTraefik:
+		renderTraefik(&out, options, routes)
+	case proxyCaddy:
+		renderCaddy(&out, options, routes)
+	default:
+		return "", fmt.Errorf("unknown format %s", format)
+	}
+	return out.String(), nil
+}
+
+func renderNginx(out *strings.Builder, options proxyOptions, routes []application.LambdaRoutes) {
+	serverName := options.ServerName
+	if serverName == "" {
+		serverName = "_"
+	}
+	certName := options.ServerName
+	if certName == "" {
+		certName = "server"
+	}
+	fmt.Fprintf(out, "upstream trusted_cgi {\n    server %s;\n}\n\n", options.Upstream.Host)
+	out.WriteString("server {\n")
+	out.WriteString("    listen 80;\n")
+	if options.TLS {
+		out.WriteString("    listen 443 ssl;\n")
+		out.WriteString("    # replace by paths of certificate and key\n")
+		fmt.Fprintf(out, "    ssl_certificate /etc/ssl/certs/%s.pem;\n", certName)
+		fmt.Fprintf(out, "    ssl_certificate_key /etc/ssl/private/%s.key;\n", certName)
+	}
+	fmt.Fprintf(out, "    server_name %s;\n\n", serverName)
+	out.WriteString("    proxy_set_header Host $host;\n")
+	out.WriteString("    proxy_set_header X-Real-IP $remote_addr;\n")
+	out.WriteString("    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+	out.WriteString("    proxy_set_header X-Forwarded-Proto $scheme;\n")
+	out.WriteString("    proxy_set_header X-Forwarded-Host $host;\n")
+	for _, lambda := range routes {
+		fmt.Fprintf(out, "\n    # %s\n", lambdaTitle(lambda))
+		for _, route := range lambda.Routes {
+			for _, note := range routeNotes(route) {
+				fmt.Fprintf(out, "    # %s\n", note)
+			}
+			fmt.Fprintf(out, "    location ~ ^%s(/|$) {\n", regexp.QuoteMeta(route.Path))
+			if lambda.MaximumPayload > 0 {
+				fmt.Fprintf(out, "        client_max_body_size %d;\n", lambda.MaximumPayload)
+			}
+			fmt.Fprintf(out, "        proxy_pass %s://trusted_cgi;\n", options.Upstream.Scheme)
+			out.WriteString("    }\n")
+		}
+	}
+	out.WriteString("}\n")
+}
+
+func renderTraefik(out *strings.Builder, options proxyOptions, routes []application.LambdaRoutes) {
+	entryPoint := "web"
+	if options.TLS {
+		entryPoint = "websecure"
+	}
+	out.WriteString("http:\n")
+	if len(routes) == 0 {
+		out.WriteString("  routers: {}\n")
+	} else {
+		out.WriteString("  routers:\n")
+	}
+	for _, lambda := range routes {
+		fmt.Fprintf(out, "    lambda-%s:\n", lambda.UID)
+		fmt.Fprintf(out, "      # %s\n", lambdaTitle(lambda))
+		var rules []string
+		for _, route := range lambda.Routes {
+			for _, note := range routeNotes(route) {
+				fmt.Fprintf(out, "      # %s\n", note)
+			}
+			rules = append(rules, fmt.Sprintf("Path(`%[1]s`) || PathPrefix(`%[1]s/`)", route.Path))
+		}
+		rule := strings.Join(rules, " || ")
+		if options.ServerName != "" {
+			rule = fmt.Sprintf("Host(`%s`) && (%s)", options.ServerName, rule)
+		}
+		fmt.Fprintf(out, "      rule: %q\n", rule)
+		fmt.Fprintf(out, "      entryPoints:\n        - %s\n", entryPoint)
+		out.WriteString("      service: trusted-cgi\n")
+		if lambda.MaximumPayload > 0 {
+			fmt.Fprintf(out, "      middlewares:\n        - lambda-%s-payload\n", lambda.UID)
+		}
+		if options.TLS {
+			out.WriteString("      tls:\n        certResolver: default # replace by name of certificate resolver\n")
+		}
+	}
+	var limited bool
+	for _, lambda := range routes {
+		if lambda.MaximumPayload <= 0 {
+			continue
+		}
+		if !limited {
+			out.WriteString("  middlewares:\n")
+			limited = true
+		}
+		fmt.Fprintf(out, "    lambda-%s-payload:\n      buffering:\n        maxRequestBodyBytes: %d\n", lambda.UID, lambda.MaximumPayload)
+	}
+	out.WriteString("  services:\n")
+	out.WriteString("    trusted-cgi:\n")
+	out.WriteString("      loadBalancer:\n")
+	out.WriteString("        passHostHeader: true\n")
+	out.WriteString("        servers:\n")
+	fmt.Fprintf(out, "          - url: %q\n", options.Upstream.String())
+}
+
+func renderCaddy(out *strings.Builder, options proxyOptions, routes []application.LambdaRoutes) {
+	var site string
+	switch {
+	case options.ServerName != "" && options.TLS:
+		site = options.ServerName
+	case options.ServerName != "":
+		site = "http://" + options.ServerName
+	case options.TLS:
+		site = ":443"
+	default:
+		site = ":80"
+	}
+	fmt.Fprintf(out, "%s {\n", site)
+	if options.TLS && options.ServerName != "" {
+		out.WriteString("\t# certificate is obtained automatically for the host name\n")
+	} else if options.TLS {
+		out.WriteString("\t# replace by tls <certificate> <key>\n\ttls internal\n")
+	}
+	upstream := options.Upstream.Host
+	if options.Upstream.Scheme != "http" {
+		upstream = options.Upstream.String()
+	}
+	for _, lambda := range routes {
+		fmt.Fprintf(out, "\n\t# %s\n", lambdaTitle(lambda))
+		var paths []string
+		for _, route := range lambda.Routes {
+			for _, note := range routeNotes(route) {
+				fmt.Fprintf(out, "\t# %s\n", note)
+			}
+			paths = append(paths, route.Path, route.Path+"/*")
+		}
+		fmt.Fprintf(out, "\t@lambda-%s path %s\n", lambda.UID, strings.Join(paths, " "))
+		fmt.Fprintf(out, "\thandle @lambda-%s {\n", lambda.UID)
+		if lambda.MaximumPayload > 0 {
+			fmt.Fprintf(out, "\t\trequest_body {\n\t\t\tmax_size %d\n\t\t}\n", lambda.MaximumPayload)
+		}
+		fmt.Fprintf(out, "\t\treverse_proxy %s\n", upstream)
+		out.WriteString("\t}\n")
+	}
+	out.WriteString("}\n")
+}
+
+func lambdaTitle(lambda application.LambdaRoutes) string {
+	title := "lambda " + lambda.UID
+	if lambda.Name != "" {
+		title += " (" + lambda.Name + ")"
+	}
+	if len(lambda.Methods) > 0 {
+		title += ", methods " + strings.Join(lambda.Methods, ", ")
+	}
+	return title
+}
+
+// comments of route state
+func routeNotes(route application.Route) []string {
+	var notes []string
+	switch {
+	case route.Kind == application.RouteSlug && route.Outdated:
+		notes = append(notes, "slug "+route.Name+" is outdated: name of lambda changed, slug could be regenerated")
+	case route.Kind == application.RouteAlias && route.Declared:
+		notes = append(notes, "alias "+route.Name+" is declared in manifest")
+	case route.Kind == application.RouteQueue:
+		notes = append(notes, "queue "+route.Name+": requests are processed asynchronously")
+	}
+	return notes
+}
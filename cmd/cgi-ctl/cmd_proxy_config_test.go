@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// generated configurations should not be changed unintentionally: proxies include them as-is
+func TestProxyConfig_golden(t *testing.T) {
+	routes := []application.LambdaRoutes{
+		{
+			UID:     "11111111-1111-1111-1111-111111111111",
+			Name:    "Hello",
+			Methods: []string{"GET", "POST"},
+			Routes: []application.Route{
+				{Kind: application.RouteUID, Name: "11111111-1111-1111-1111-111111111111", Path: "/a/11111111-1111-1111-1111-111111111111"},
+				{Kind: application.RouteSlug, Name: "hi", Path: "/l/hi", Outdated: true},
+				{Kind: application.RouteAlias, Name: "hook.v1", Path: "/l/hook.v1", Declared: true},
+				{Kind: application.RouteQueue, Name: "jobs", Path: "/q/jobs"},
+			},
+		},
+		{
+			UID:            "22222222-2222-2222-2222-222222222222",
+			MaximumPayload: 1048576,
+			Routes: []application.Route{
+				{Kind: application.RouteUID, Name: "22222222-2222-2222-2222-222222222222", Path: "/a/22222222-2222-2222-2222-222222222222"},
+			},
+		},
+	}
+	for _, format := range []string{proxyNginx, proxyTraefik, proxyCaddy} {
+		for name, tls := range map[string]bool{"": false, "_tls": true} {
+			format, name, tls := format, format+name, tls
+			t.Run(name, func(t *testing.T) {
+				options, err := newProxyOptions("127.0.0.1:3434", "cgi.example.com", tls)
+				require.NoError(t, err)
+				config, err := renderProxyConfig(format, options, routes)
+				require.NoError(t, err)
+				golden := filepath.Join("testdata", "proxy-config", name+".golden")
+				if *updateGolden {
+					require.NoError(t, ioutil.WriteFile(golden, []byte(config), 0644))
+				}
+				expected, err := ioutil.ReadFile(golden)
+				require.NoError(t, err)
+				assert.Equal(t, string(expected), config)
+			})
+		}
+	}
+
+	_, err := newProxyOptions("http://", "", false)
+	assert.Error(t, err)
+	_, err = renderProxyConfig("apache", proxyOptions{}, routes)
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+type queueCmd struct {
+	Dead struct {
+		List    deadList    `command:"ls" description:"show dead letters of the lambda from the newest"`
+		Redrive deadRedrive `command:"redrive" description:"put dead letters back to their queues (scheduled invocations are invoked)"`
+		Remove  deadRemove  `command:"rm" description:"remove dead letters"`
+	} `command:"dead" description:"manage dead letters: queued and scheduled requests not processed after all attempts"`
+}
+
+type deadList struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *deadList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	letters, err := cmd.Queues().DeadLetters(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("list dead letters: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(letters)
+	}
+	if len(letters) == 0 {
+		log.Println("no dead letters")
+		return nil
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "ID\tSOURCE\tFAILED\tATTEMPTS\tSIZE\tERROR")
+	for _, letter := range letters {
+		source := letter.Queue
+		if source == "" {
+			source = "schedule " + letter.Schedule
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%d\t%s\t%s\n", letter.ID, source, formatTime(letter.Failed),
+			letter.Attempts, formatSize(letter.Size), dash(letter.Error))
+	}
+	return out.Flush()
+}
+
+// selection of dead letters: by IDs or all
+type deadSelection struct {
+	All  bool `short:"a" long:"all" env:"ALL" description:"All dead letters of the lambda"`
+	Args struct {
+		IDs []string `positional-arg-name:"id" description:"ID of dead letter"`
+	} `positional-args:"yes"`
+}
+
+func (ds *deadSelection) ids() ([]string, error) {
+	if ds.All == (len(ds.Args.IDs) > 0) {
+		return nil, errors.New("IDs of dead letters or --all flag should be set")
+	}
+	return ds.Args.IDs, nil
+}
+
+type deadRedrive struct {
+	remoteLink
+	uidLocator
+	deadSelection
+}
+
+func (cmd *deadRedrive) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	ids, err := cmd.ids()
+	if err != nil {
+		return err
+	}
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("redriving dead letters of lambda", cmd.UID)
+	n, err := cmd.Queues().RedriveDeadLetters(ctx, token, cmd.UID, ids)
+	if err != nil {
+		return fmt.Errorf("redrive dead letters (%d redriven): %w", n, err)
+	}
+	return printDeadResult(cmd.UID, "redriven", n)
+}
+
+type deadRemove struct {
+	remoteLink
+	uidLocator
+	deadSelection
+}
+
+func (cmd *deadRemove) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	ids, err := cmd.ids()
+	if err != nil {
+		return err
+	}
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	n, err := cmd.Queues().RemoveDeadLetters(ctx, token, cmd.UID, ids)
+	if err != nil {
+		return fmt.Errorf("remove dead letters: %w", err)
+	}
+	return printDeadResult(cmd.UID, "removed", n)
+}
+
+func printDeadResult(uid string, action string, n int) error {
+	if globalOptions.JSON {
+		return printJSON(map[string]interface{}{"uid": uid, action: n})
+	}
+	log.Println(action, n, "dead letters")
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type reloadCmd struct {
+	remoteLink
+	Lambda string `short:"L" long:"lambda" env:"LAMBDA" description:"reload manifest and files of lambda (UID or alias) only: files are reloaded regardless of changes"`
+}
+
+func (cmd *reloadCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	var uid string
+	if cmd.Lambda != "" {
+		def, err := cmd.FindLambda(ctx, token, cmd.Lambda)
+		if err != nil {
+			return err
+		}
+		uid = def.UID
+	}
+	log.Println("reloading...")
+	report, err := cmd.Project().Reload(ctx, token, uid)
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+	if globalOptions.JSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printReloadReport(report)
+	}
+	var failed int
+	for _, item := range report.Items {
+		if item.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files are not applied", failed, len(report.Items))
+	}
+	return nil
+}
+
+func printReloadReport(report *application.ReloadReport) {
+	for _, item := range report.Items {
+		var status []string
+		if item.Applied {
+			status = append(status, "applied")
+		}
+		if item.Files {
+			status = append(status, "files reloaded")
+		}
+		if item.Error != "" {
+			status = append(status, "not applied: "+item.Error)
+		}
+		if len(status) == 0 {
+			status = append(status, "unchanged")
+		}
+		fmt.Println(item.Target+":", strings.Join(status, ", "))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+type remote struct {
+	Add    remoteAdd    `command:"add" description:"add named remote to the control file"`
+	Remove remoteRemove `command:"rm" description:"remove named remote from the control file"`
+	List   remoteList   `command:"ls" description:"list remotes from the control file"`
+}
+
+type remoteAdd struct {
+	UID  string `short:"U" long:"uid" env:"UID" description:"Lambda UID on the remote (empty - directory name)"`
+	Args struct {
+		Name string `positional-arg-name:"name" required:"yes" description:"remote name"`
+		URL  string `positional-arg-name:"url" required:"yes" description:"Trusted-CGI endpoint: URL or unix:/path/to/socket"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *remoteAdd) Execute(args []string) error {
+	if _, err := url.Parse(cmd.Args.URL); err != nil {
+		return fmt.Errorf("parse URL: %w", err)
+	}
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	if cf.Remote(cmd.Args.Name) != nil {
+		return fmt.Errorf("remote %s already exists", cmd.Args.Name)
+	}
+	cf.SetRemote(cmd.Args.Name, controlRemote{URL: cmd.Args.URL, UID: cmd.UID})
+	if err := cf.Save(controlFilename); err != nil {
+		return fmt.Errorf("save control file: %w", err)
+	}
+	if err := appendIfNoLineFile(internal_app.CGIIgnore, controlFilename); err != nil {
+		return fmt.Errorf("update cgiignore file: %w", err)
+	}
+	log.Println("remote", cmd.Args.Name, "added")
+	return printResult(remoteItem{Name: cmd.Args.Name, URL: cmd.Args.URL, UID: cmd.UID})
+}
+
+type remoteRemove struct {
+	Args struct {
+		Names []string `positional-arg-name:"name" required:"1" description:"remote names"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *remoteRemove) Execute(args []string) error {
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	var removed = make([]remoteItem, 0, len(cmd.Args.Names))
+	for _, name := range cmd.Args.Names {
+		info := cf.Remote(name)
+		if info == nil {
+			return &exitError{code: exitNotFound, err: fmt.Errorf("remote %s not found", name)}
+		}
+		removed = append(removed, remoteItem{Name: name, URL: info.URL, UID: info.UID})
+		delete(cf.Remotes, name)
+	}
+	if err := cf.Save(controlFilename); err != nil {
+		return fmt.Errorf("save control file: %w", err)
+	}
+	for _, name := range cmd.Args.Names {
+		log.Println("remote", name, "removed")
+	}
+	return printResult(removed)
+}
+
+type remoteList struct{}
+
+func (cmd *remoteList) Execute(args []string) error {
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	var items = make([]remoteItem, 0, len(cf.Remotes))
+	for name, info := range cf.Remotes {
+		items = append(items, remoteItem{Name: name, URL: info.URL, UID: info.UID})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	if globalOptions.JSON {
+		return printJSON(items)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "NAME\tURL\tUID")
+	for _, item := range items {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\n", item.Name, item.URL, item.UID)
+	}
+	return out.Flush()
+}
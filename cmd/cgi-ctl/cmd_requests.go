@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type requestsCmd struct {
+	List   requestsList   `command:"ls" description:"show captured requests of the lambda from the newest"`
+	Replay requestsReplay `command:"replay" description:"invoke the lambda by captured request again and print new response"`
+	Save   requestsSave   `command:"save" description:"save captured request with body to file for local run (see run --request)"`
+}
+
+type requestsList struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *requestsList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	requests, err := cmd.Lambdas().Requests(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("list captured requests: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(requests)
+	}
+	if len(requests) == 0 {
+		log.Println("no captured requests")
+		return nil
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "ID\tCAPTURED\tMETHOD\tPATH\tSIZE\tERROR")
+	for _, request := range requests {
+		size := formatSize(request.Size)
+		if request.Truncated {
+			size += " (truncated)"
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\n", request.ID, formatTime(request.Captured), request.Request.Method,
+			request.Request.Path, size, dash(request.Error))
+	}
+	return out.Flush()
+}
+
+type requestsReplay struct {
+	remoteLink
+	uidLocator
+	Args struct {
+		ID string `positional-arg-name:"id" description:"ID of captured request" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *requestsReplay) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("replaying request", cmd.Args.ID, "of lambda", cmd.UID)
+	result, err := cmd.Lambdas().Replay(ctx, token, cmd.UID, cmd.Args.ID)
+	if err != nil {
+		return fmt.Errorf("replay request: %w", err)
+	}
+	if globalOptions.JSON {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		_, _ = os.Stdout.Write(result.Output)
+	}
+	if result.Error != "" {
+		return &exitError{code: 1, err: fmt.Errorf("replay %s failed after %v: %s", result.ID, time.Duration(result.Duration), result.Error)}
+	}
+	log.Println("replay", result.ID, "finished in", time.Duration(result.Duration))
+	return nil
+}
+
+type requestsSave struct {
+	remoteLink
+	uidLocator
+	Args struct {
+		ID   string `positional-arg-name:"id" description:"ID of captured request" required:"yes"`
+		File string `positional-arg-name:"file" description:"destination JSON file" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *requestsSave) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	request, err := cmd.Lambdas().CapturedRequest(ctx, token, cmd.UID, cmd.Args.ID)
+	if err != nil {
+		return fmt.Errorf("get captured request: %w", err)
+	}
+	if request.Truncated {
+		log.Println("[WARN]", "body of request is truncated to", len(request.Body), "of", request.Size, "bytes")
+	}
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return err
+	}
+	// headers could contain credentials
+	if err := ioutil.WriteFile(cmd.Args.File, data, 0600); err != nil {
+		return fmt.Errorf("save captured request: %w", err)
+	}
+	log.Println("saved to", cmd.Args.File)
+	return printResult(map[string]interface{}{"uid": cmd.UID, "id": request.ID, "file": cmd.Args.File})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// exit code when lambda is not found
+const exitNotFound = 2
+
+type remove struct {
+	remoteLink
+	uidLocator
+	Yes        bool `short:"y" long:"yes" env:"YES" description:"do not ask for confirmation"`
+	PurgeLocal bool `long:"purge-local" env:"PURGE_LOCAL" description:"remove local control file of the lambda"`
+	Args       struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias (default - from control file or --uid)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *remove) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	target := cmd.Args.Lambda
+	if target == "" {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+		target = cmd.UID
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	def, err := cmd.FindLambda(ctx, token, target)
+	if err != nil {
+		return err
+	}
+	var aliases = make([]string, 0, len(def.Aliases))
+	for alias := range def.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	if !cmd.Yes {
+		_, _ = fmt.Fprintln(os.Stderr, "lambda: ", def.Manifest.Name)
+		_, _ = fmt.Fprintln(os.Stderr, "uid:    ", def.UID)
+		_, _ = fmt.Fprintln(os.Stderr, "aliases:", strings.Join(aliases, ", "))
+		_, _ = fmt.Fprint(os.Stderr, "Remove lambda permanently? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return fmt.Errorf("cancelled")
+		}
+	}
+
+	log.Println("removing", def.UID, "...")
+	_, err = cmd.Lambdas().Remove(ctx, token, def.UID)
+	if err != nil {
+		return fmt.Errorf("remove: %w", err)
+	}
+	for _, alias := range aliases {
+		log.Println("alias", alias, "freed")
+	}
+	if cmd.PurgeLocal {
+		if err := purgeRemote(def.UID); err != nil {
+			return err
+		}
+	}
+	log.Println("done")
+	return printResult(removeResult{UID: def.UID, Name: def.Manifest.Name, Aliases: aliases, PurgedLocal: cmd.PurgeLocal})
+}
+
+// forget remote (selected by --remote) tracking the lambda, control file is removed when no remotes left
+func purgeRemote(uid string) error {
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil {
+		return nil
+	}
+	remote := cf.Remote(globalOptions.Remote)
+	if remote == nil || remote.UID != uid {
+		return nil
+	}
+	delete(cf.Remotes, globalOptions.Remote)
+	if len(cf.Remotes) > 0 {
+		if err := cf.Save(controlFilename); err != nil {
+			return fmt.Errorf("save control file: %w", err)
+		}
+		log.Println("remote", globalOptions.Remote, "removed from control file")
+		return nil
+	}
+	if err := os.Remove(controlFilename); err != nil {
+		return fmt.Errorf("remove control file: %w", err)
+	}
+	log.Println("control file removed")
+	return nil
+}
+
+// error with custom exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
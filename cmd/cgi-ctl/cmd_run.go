@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+type run struct {
+	Data     string            `short:"d" long:"data" env:"DATA" description:"request body (stdin will be used if neither data nor data file defined)"`
+	DataFile string            `short:"D" long:"data-file" env:"DATA_FILE" description:"file that will be used as request body (- is stdin)"`
+	Method   string            `short:"X" long:"method" env:"METHOD" description:"request method" default:"POST"`
+	Path     string            `long:"path" env:"PATH_INFO" description:"request path" default:"/"`
+	Header   map[string]string `short:"H" long:"header" env:"HEADER" description:"request headers"`
+	Env      map[string]string `short:"e" long:"env" env:"ENV" description:"global environment (as in project settings)"`
+	Request  string            `short:"R" long:"request" env:"REQUEST" description:"JSON file of captured request (see requests save): method, path, headers and body of request instead of flags"`
+	Listen   string            `short:"L" long:"listen" env:"LISTEN" description:"start local HTTP server on the address (ex: :8080) instead of single run"`
+}
+
+func (cmd *run) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if cmd.Listen != "" {
+		return cmd.serve(ctx)
+	}
+	fn, err := lambda.FromDir(".")
+	if err != nil {
+		return fmt.Errorf("load lambda: %w", err)
+	}
+	req, err := cmd.getRequest()
+	if err != nil {
+		return err
+	}
+	if globalOptions.JSON {
+		var output bytes.Buffer
+		var outputHeaders = make(http.Header)
+		for k, v := range fn.Manifest().OutputHeaders {
+			outputHeaders.Set(k, v)
+		}
+		if err := fn.Invoke(ctx, req, &output, cmd.Env); err != nil {
+			return err
+		}
+		return printJSON(newResponseResult(0, outputHeaders, output.Bytes()))
+	}
+	for k, v := range fn.Manifest().OutputHeaders {
+		_, _ = fmt.Fprintln(os.Stderr, k+":", v)
+	}
+	return fn.Invoke(ctx, req, os.Stdout, cmd.Env)
+}
+
+func (cmd *run) serve(ctx context.Context) error {
+	srv := http.Server{
+		Addr: cmd.Listen,
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			// reload lambda for each request to pick up changes in manifest
+			fn, err := lambda.FromDir(".")
+			if err != nil {
+				log.Println("load lambda:", err)
+				http.Error(writer, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for k, v := range fn.Manifest().OutputHeaders {
+				writer.Header().Set(k, v)
+			}
+			writer.WriteHeader(http.StatusOK)
+			started := time.Now()
+			err = fn.Invoke(request.Context(), *types.FromHTTP(request, false), writer, cmd.Env)
+			log.Println(request.Method, request.RequestURI, time.Since(started))
+			if err != nil {
+				log.Println("invoke:", err)
+			}
+		}),
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	log.Println("listening on", cmd.Listen)
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (cmd *run) getRequest() (types.Request, error) {
+	if cmd.Request != "" {
+		return loadCapturedRequest(cmd.Request)
+	}
+	body, err := cmd.getBody()
+	if err != nil {
+		return types.Request{}, fmt.Errorf("get body: %w", err)
+	}
+	var headers = make(map[string]string)
+	for k, v := range cmd.Header {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	return types.Request{
+		Method:        cmd.Method,
+		URL:           cmd.Path,
+		Path:          cmd.Path,
+		RemoteAddress: "127.0.0.1",
+		Form:          map[string]string{},
+		Headers:       headers,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+// request saved by requests save command
+func loadCapturedRequest(file string) (types.Request, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return types.Request{}, fmt.Errorf("load request: %w", err)
+	}
+	var captured application.CapturedRequest
+	if err := json.Unmarshal(data, &captured); err != nil {
+		return types.Request{}, fmt.Errorf("parse request %s: %w", file, err)
+	}
+	if captured.Truncated {
+		log.Println("[WARN]", "body of request is truncated to", len(captured.Body), "of", captured.Size, "bytes")
+	}
+	req := captured.Request
+	if req.Form == nil {
+		req.Form = map[string]string{}
+	}
+	if req.Headers == nil {
+		req.Headers = map[string]string{}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(captured.Body))
+	return req, nil
+}
+
+func (cmd *run) getBody() ([]byte, error) {
+	if cmd.Data != "" {
+		return []byte(cmd.Data), nil
+	}
+	if cmd.DataFile == "" || cmd.DataFile == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(cmd.DataFile)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"github.com/robfig/cron"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type schedule struct {
+	List   scheduleList   `command:"ls" description:"list scheduled actions"`
+	Add    scheduleAdd    `command:"add" description:"add scheduled action or invocation"`
+	Remove scheduleRemove `command:"rm" description:"remove schedules by index, action or name"`
+	Apply  scheduleApply  `command:"apply" description:"reconcile scheduled actions with local file"`
+}
+
+// entry in local schedules file
+type scheduleEntry struct {
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`                 // unique name of entry
+	Cron        string `json:"cron" yaml:"cron"`                                     // crontab expression (with seconds)
+	Action      string `json:"action,omitempty" yaml:"action,omitempty"`             // action to invoke (empty - invoke lambda)
+	Payload     string `json:"payload,omitempty" yaml:"payload,omitempty"`           // body of invocation
+	PayloadFile string `json:"payload_file,omitempty" yaml:"payload_file,omitempty"` // file inside lambda with body of invocation
+	TimeLimit   string `json:"time_limit,omitempty" yaml:"time_limit,omitempty"`     // time limit to execute
+	TZ          string `json:"tz,omitempty" yaml:"tz,omitempty"`                     // IANA time zone of cron expression
+}
+
+func (se *scheduleEntry) toSchedule() (types.Schedule, error) {
+	if err := validateCron(se.Cron); err != nil {
+		return types.Schedule{}, err
+	}
+	if err := validateTZ(se.TZ); err != nil {
+		return types.Schedule{}, err
+	}
+	var timeLimit time.Duration
+	if se.TimeLimit != "" {
+		v, err := time.ParseDuration(se.TimeLimit)
+		if err != nil {
+			return types.Schedule{}, fmt.Errorf("parse time limit for %s: %w", scheduleTarget(types.Schedule{Name: se.Name, Action: se.Action}), err)
+		}
+		timeLimit = v
+	}
+	return types.Schedule{
+		Name:        se.Name,
+		Cron:        se.Cron,
+		Action:      se.Action,
+		Payload:     se.Payload,
+		PayloadFile: se.PayloadFile,
+		TimeLimit:   types.JsonDuration(timeLimit),
+		TZ:          se.TZ,
+	}, nil
+}
+
+// action or invocation of lambda with name of entry (if set)
+func scheduleTarget(item types.Schedule) string {
+	target := item.Action
+	if item.Invocation() {
+		target = "invoke"
+	}
+	if item.Name != "" {
+		target += " (" + item.Name + ")"
+	}
+	return target
+}
+
+// schedules are matched by name or, for unnamed entries, by content
+func scheduleKey(item types.Schedule) string {
+	if item.Name != "" {
+		return "name\x00" + item.Name
+	}
+	return item.Cron + "\x00" + item.Action + "\x00" + item.Payload + "\x00" + item.PayloadFile
+}
+
+func validateCron(expression string) error {
+	if _, err := cron.Parse(expression); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", expression, err)
+	}
+	return nil
+}
+
+func validateTZ(name string) error {
+	if _, err := (types.Schedule{TZ: name}).Location(); err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	return nil
+}
+
+type scheduleList struct {
+	manifestEditor
+}
+
+func (cmd *scheduleList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	_, manifest, err := cmd.remoteManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if globalOptions.JSON {
+		var list = make([]types.Schedule, 0, len(manifest.Cron))
+		return printJSON(append(list, manifest.Cron...))
+	}
+	if len(manifest.Cron) == 0 {
+		log.Println("no scheduled actions")
+	}
+	for i, entry := range manifest.Cron {
+		if entry.TZ != "" {
+			fmt.Println(i, strconv.Quote(entry.Cron), entry.TZ, scheduleTarget(entry), time.Duration(entry.TimeLimit))
+			continue
+		}
+		fmt.Println(i, strconv.Quote(entry.Cron), scheduleTarget(entry), time.Duration(entry.TimeLimit))
+	}
+	return nil
+}
+
+type scheduleAdd struct {
+	manifestEditor
+	TimeLimit   time.Duration `short:"t" long:"time-limit" env:"TIME_LIMIT" description:"time limit for action or invocation"`
+	Name        string        `long:"name" env:"SCHEDULE_NAME" description:"unique name of schedule"`
+	Payload     string        `long:"payload" env:"PAYLOAD" description:"payload of scheduled invocation (without action)"`
+	PayloadFile string        `long:"payload-file" env:"PAYLOAD_FILE" description:"file inside lambda with payload of scheduled invocation (without action)"`
+	TZ          string        `long:"tz" env:"TZ_NAME" description:"IANA time zone of cron expression (ex: Europe/Berlin), empty - local time of server"`
+	Args        struct {
+		Cron   string `positional-arg-name:"cron" required:"yes" description:"cron expression with seconds (ex: '0 */5 * * * *')"`
+		Action string `positional-arg-name:"action" description:"action (Makefile target) to invoke, empty - invoke lambda"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *scheduleAdd) Execute(args []string) error {
+	// validate before any remote call
+	if err := validateCron(cmd.Args.Cron); err != nil {
+		return err
+	}
+	if err := validateTZ(cmd.TZ); err != nil {
+		return err
+	}
+	if cmd.Args.Action != "" && (cmd.Payload != "" || cmd.PayloadFile != "") {
+		return fmt.Errorf("payload is supported only for scheduled invocations (without action)")
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, manifest, err := cmd.remoteManifest(ctx)
+	if err != nil {
+		return err
+	}
+	manifest.Cron = append(manifest.Cron, types.Schedule{
+		Name:        cmd.Name,
+		Cron:        cmd.Args.Cron,
+		Action:      cmd.Args.Action,
+		Payload:     cmd.Payload,
+		PayloadFile: cmd.PayloadFile,
+		TimeLimit:   types.JsonDuration(cmd.TimeLimit),
+		TZ:          cmd.TZ,
+	})
+	return cmd.save(ctx, token, manifest, func(local *types.Manifest) {
+		local.Cron = manifest.Cron
+	})
+}
+
+type scheduleRemove struct {
+	manifestEditor
+	Args struct {
+		Entries []string `positional-arg-name:"index-action-or-name" required:"1" description:"index (see ls), action or name of schedule"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *scheduleRemove) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, manifest, err := cmd.remoteManifest(ctx)
+	if err != nil {
+		return err
+	}
+	var remove = make(map[int]bool)
+	for _, entry := range cmd.Args.Entries {
+		if index, err := strconv.Atoi(entry); err == nil {
+			if index < 0 || index >= len(manifest.Cron) {
+				return fmt.Errorf("schedule index %d out of range", index)
+			}
+			remove[index] = true
+			continue
+		}
+		var found bool
+		for i, item := range manifest.Cron {
+			if item.Action == entry || item.Name == entry {
+				remove[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no schedules for action or name %s", entry)
+		}
+	}
+	var left []types.Schedule
+	for i, item := range manifest.Cron {
+		if remove[i] {
+			log.Println("removing", strconv.Quote(item.Cron), scheduleTarget(item))
+			continue
+		}
+		left = append(left, item)
+	}
+	manifest.Cron = left
+	return cmd.save(ctx, token, manifest, func(local *types.Manifest) {
+		local.Cron = manifest.Cron
+	})
+}
+
+type scheduleApply struct {
+	manifestEditor
+	File   string `short:"f" long:"file" env:"FILE" description:"schedules file (.json or .yaml), by default schedules.json, schedules.yaml or schedules.yml"`
+	DryRun bool   `long:"dry-run" env:"DRY_RUN" description:"only print planned changes"`
+}
+
+func (cmd *scheduleApply) Execute(args []string) error {
+	desired, err := cmd.readFile()
+	if err != nil {
+		return err
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	token, manifest, err := cmd.remoteManifest(ctx)
+	if err != nil {
+		return err
+	}
+	var current = make(map[string]types.Schedule)
+	for _, item := range manifest.Cron {
+		current[scheduleKey(item)] = item
+	}
+	var wanted = make(map[string]bool)
+	var result = scheduleApplyResult{UID: cmd.UID, Changes: []scheduleChange{}}
+	for _, item := range desired {
+		wanted[scheduleKey(item)] = true
+		old, exists := current[scheduleKey(item)]
+		if !exists {
+			result.Changes = append(result.Changes, scheduleChange{Change: scheduleAdded, Schedule: item})
+		} else if old.Cron != item.Cron || old.Action != item.Action || old.Payload != item.Payload || old.PayloadFile != item.PayloadFile || old.TimeLimit != item.TimeLimit || old.TZ != item.TZ {
+			oldLimit := old.TimeLimit
+			result.Changes = append(result.Changes, scheduleChange{Change: scheduleModified, Schedule: item, OldTimeLimit: &oldLimit})
+		}
+	}
+	for _, item := range manifest.Cron {
+		if !wanted[scheduleKey(item)] {
+			result.Changes = append(result.Changes, scheduleChange{Change: scheduleRemoved, Schedule: item})
+		}
+	}
+	if !globalOptions.JSON {
+		printScheduleChanges(result.Changes)
+	}
+	if len(result.Changes) == 0 {
+		log.Println("nothing to change")
+		return printResult(result)
+	}
+	if cmd.DryRun {
+		return printResult(result)
+	}
+	manifest.Cron = desired
+	err = cmd.push(ctx, token, manifest, func(local *types.Manifest) {
+		local.Cron = manifest.Cron
+	})
+	if err != nil {
+		return err
+	}
+	result.Applied = true
+	return printResult(result)
+}
+
+func printScheduleChanges(changes []scheduleChange) {
+	for _, item := range changes {
+		switch item.Change {
+		case scheduleAdded:
+			fmt.Println("+", strconv.Quote(item.Cron), scheduleTarget(item.Schedule), time.Duration(item.TimeLimit))
+		case scheduleModified:
+			fmt.Println("~", strconv.Quote(item.Cron), scheduleTarget(item.Schedule), time.Duration(*item.OldTimeLimit), "->", time.Duration(item.TimeLimit))
+		case scheduleRemoved:
+			fmt.Println("-", strconv.Quote(item.Cron), scheduleTarget(item.Schedule), time.Duration(item.TimeLimit))
+		}
+	}
+}
+
+func (cmd *scheduleApply) readFile() ([]types.Schedule, error) {
+	file := cmd.File
+	if file == "" {
+		for _, name := range []string{"schedules.json", "schedules.yaml", "schedules.yml"} {
+			if _, err := os.Stat(name); err == nil {
+				file = name
+				break
+			}
+		}
+		if file == "" {
+			return nil, fmt.Errorf("schedules file not found")
+		}
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read schedules: %w", err)
+	}
+	var entries []scheduleEntry
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &entries)
+	default:
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse schedules %s: %w", file, err)
+	}
+	var ans = make([]types.Schedule, 0, len(entries))
+	for _, entry := range entries {
+		item, err := entry.toSchedule()
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, item)
+	}
+	return ans, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type search struct {
+	remoteLink
+	Limit int  `short:"n" long:"limit" env:"LIMIT" description:"maximum number of lambdas" default:"20"`
+	Quiet bool `short:"q" long:"quiet" env:"QUIET" description:"print only UIDs"`
+	Args  struct {
+		Query []string `positional-arg-name:"query" required:"yes" description:"words of name, description, aliases or labels (all words should match)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *search) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if cmd.Limit < 0 {
+		return fmt.Errorf("limit should not be negative")
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	result, err := cmd.Project().Search(ctx, token, strings.Join(cmd.Args.Query, " "), cmd.Limit)
+	if err != nil {
+		return fmt.Errorf("search lambdas: %w", err)
+	}
+	if result.Incomplete {
+		log.Println("[WARN]", "search index is over memory budget of server: some lambdas are indexed partially")
+	}
+	switch {
+	case globalOptions.JSON:
+		return printJSON(result)
+	case cmd.Quiet:
+		for _, hit := range result.Hits {
+			fmt.Println(hit.UID)
+		}
+		return nil
+	}
+	for i, hit := range result.Hits {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s  %s  (score %g)\n", hit.UID, hit.Name, hit.Score)
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, highlight := range hit.Highlights {
+			_, _ = fmt.Fprintf(out, "    %s:\t%s\n", highlight.Field, formatFragments(highlight.Fragments))
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(result.Hits) < result.Total {
+		fmt.Println()
+		fmt.Println("...", result.Total-len(result.Hits), "more lambdas")
+	}
+	return nil
+}
+
+// text with matched words in brackets
+func formatFragments(fragments []application.SnippetFragment) string {
+	var text strings.Builder
+	for _, fragment := range fragments {
+		if fragment.Match {
+			text.WriteString("[" + fragment.Text + "]")
+		} else {
+			text.WriteString(fragment.Text)
+		}
+	}
+	return text.String()
+}
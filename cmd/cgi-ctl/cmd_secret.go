@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"golang.org/x/crypto/ssh/terminal"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type secretCmd struct {
+	List   secretList   `command:"ls" description:"list secrets of the server (names, times and lambdas which reference them, never values)"`
+	Set    secretSet    `command:"set" description:"add or replace value of secret (encrypted by server)"`
+	Remove secretRemove `command:"rm" description:"remove secrets, lambdas which still reference them get empty values"`
+}
+
+type secretList struct {
+	remoteLink
+}
+
+func (cmd *secretList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	list, err := cmd.Project().Secrets(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list secrets: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(list)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "NAME\tUPDATED\tLAMBDAS")
+	for _, secret := range list {
+		lambdas := strings.Join(secret.Lambdas, ",")
+		if lambdas == "" {
+			lambdas = "-"
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\n", secret.Name, secret.Updated.Format(time.RFC3339), lambdas)
+	}
+	return out.Flush()
+}
+
+type secretSet struct {
+	remoteLink
+	ValueStdin bool `long:"value-stdin" env:"VALUE_STDIN" description:"read value from stdin (till EOF, without trailing new line) instead of prompt"`
+	Args       struct {
+		Name string `positional-arg-name:"name" required:"yes" description:"name of secret, referenced in environment as @secret:<name>"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *secretSet) Execute(args []string) error {
+	value, err := readSecretValue(cmd.ValueStdin)
+	if err != nil {
+		return err
+	}
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	secret, err := cmd.Project().SetSecret(ctx, token, cmd.Args.Name, value)
+	if err != nil {
+		return fmt.Errorf("set secret: %w", err)
+	}
+	log.Println("done")
+	return printResult(secret)
+}
+
+type secretRemove struct {
+	remoteLink
+	Args struct {
+		Names []string `positional-arg-name:"name" required:"1" description:"names of secrets to remove"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *secretRemove) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	var removed []string
+	for _, name := range cmd.Args.Names {
+		secret, err := cmd.Project().RemoveSecret(ctx, token, name)
+		if err != nil {
+			return fmt.Errorf("remove secret %s: %w", name, err)
+		}
+		if len(secret.Lambdas) > 0 {
+			log.Println("warning: secret", name, "is still referenced by lambdas:", strings.Join(secret.Lambdas, ", "))
+		}
+		removed = append(removed, name)
+	}
+	log.Println("done")
+	return printResult(removed)
+}
+
+// value from prompt or from stdin (single trailing new line is removed)
+func readSecretValue(fromStdin bool) (string, error) {
+	if fromStdin {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("read value: %w", err)
+		}
+		value := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		return value, nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("stdin is not a terminal: use --value-stdin")
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Value: ")
+	value, err := AskPass()
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
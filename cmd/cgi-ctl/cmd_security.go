@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type securityCmd struct {
+	remoteLink
+}
+
+func (cmd *securityCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	report, err := cmd.Project().Security(ctx, token)
+	if err != nil {
+		return fmt.Errorf("get security report: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(report)
+	}
+	return printSecurity(report)
+}
+
+func printSecurity(report *application.SecurityReport) error {
+	profile := report.Profile
+	fmt.Println("security profile:", profile.Name)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, rule := range types.Rules {
+		var flag string
+		if profile.IsMandatory(rule) {
+			flag = "mandatory"
+		}
+		_, _ = fmt.Fprintf(out, "  %s\t%s\t%s\n", rule, profileDefault(profile, rule), flag)
+	}
+	_, _ = fmt.Fprintf(out, "  runner\t%s\t\n", choose(profile.RequireRunner, "required", "optional"))
+	_, _ = fmt.Fprintf(out, "  debug\t%s\t\n", choose(profile.DisableDebug, "disabled", "allowed"))
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("lambdas with deviations:", len(report.Lambdas), "violations:", report.Violations)
+	for _, lambda := range report.Lambdas {
+		fmt.Println()
+		subject := "lambda " + lambda.UID
+		if lambda.Name != "" {
+			subject += " (" + lambda.Name + ")"
+		}
+		fmt.Println(subject + ":")
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, deviation := range lambda.Deviations {
+			var flag string
+			if deviation.Violation {
+				flag = "VIOLATION (default is used)"
+			} else if deviation.Mandatory {
+				flag = "mandatory"
+			}
+			_, _ = fmt.Fprintf(out, "  %s\t%s\t(default: %s)\t%s\n", deviation.Rule, deviation.Value, deviation.Default, flag)
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// default value of rule in profile
+func profileDefault(profile types.SecurityProfile, rule string) string {
+	switch rule {
+	case types.RuleNetwork:
+		return choose(profile.DenyNetwork, "denied", "allowed")
+	case types.RuleReadOnly:
+		return choose(profile.ReadOnly, "read-only", "writable")
+	case types.RuleTimeLimit:
+		return unlimited(int64(profile.TimeLimit), time.Duration(profile.TimeLimit).String())
+	case types.RuleMaximumPayload:
+		return unlimited(profile.MaximumPayload, fmt.Sprint(profile.MaximumPayload, " bytes"))
+	case types.RuleMaximumResponse:
+		return unlimited(profile.MaximumResponse, fmt.Sprint(profile.MaximumResponse, " bytes"))
+	case types.RuleMaxConcurrency:
+		return unlimited(int64(profile.MaxConcurrency), fmt.Sprint(profile.MaxConcurrency))
+	case types.RuleRemoveHeaders:
+		return dash(strings.Join(profile.RemoveHeaders, ", "))
+	}
+	return "-"
+}
+
+func choose(value bool, yes, no string) string {
+	if value {
+		return yes
+	}
+	return no
+}
+
+func unlimited(value int64, text string) string {
+	if value == 0 {
+		return "unlimited"
+	}
+	return text
+}
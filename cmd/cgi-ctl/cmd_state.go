@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+)
+
+type enable struct {
+	lambdaState
+}
+
+func (cmd *enable) Execute(args []string) error {
+	return cmd.set(true)
+}
+
+type disable struct {
+	lambdaState
+}
+
+func (cmd *disable) Execute(args []string) error {
+	return cmd.set(false)
+}
+
+type lambdaState struct {
+	remoteLink
+	uidLocator
+	Args struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias (default - from control file or --uid)"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *lambdaState) set(enabled bool) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	target := cmd.Args.Lambda
+	if target == "" {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+		target = cmd.UID
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	def, err := cmd.FindLambda(ctx, token, target)
+	if err != nil {
+		return err
+	}
+	def, err = cmd.Lambdas().SetEnabled(ctx, token, def.UID, enabled)
+	if err != nil {
+		return fmt.Errorf("set state: %w", err)
+	}
+	if def.Disabled {
+		log.Println("lambda", def.UID, "disabled")
+	} else {
+		log.Println("lambda", def.UID, "enabled")
+	}
+	return printResult(stateResult{UID: def.UID, Name: def.Manifest.Name, Enabled: !def.Disabled})
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/alecthomas/units"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Sort orders of stats --all
+const (
+	statsSortCalls  = "calls"
+	statsSortErrors = "errors"
+)
+
+type statsCmd struct {
+	remoteLink
+	uidLocator
+	Since    string        `short:"s" long:"since" env:"SINCE" description:"beginning of window: date (2006-01-02), RFC3339 time or duration ago (ex: 1h)" default:"1h"`
+	Until    string        `long:"until" env:"UNTIL" description:"end of window: date (inclusive), RFC3339 time or duration ago (empty - now)"`
+	Limit    int           `short:"n" long:"limit" env:"LIMIT" description:"maximum number of records to fetch" default:"1000"`
+	Records  int           `short:"r" long:"records" env:"RECORDS" description:"number of recent records to show" default:"10"`
+	All      bool          `short:"a" long:"all" env:"ALL" description:"aggregate records of all lambdas"`
+	Sort     string        `long:"sort" env:"SORT" description:"sort lambdas (with --all) by number of calls or by error rate" choice:"calls" choice:"errors" default:"calls"`
+	Bucket   time.Duration `short:"b" long:"bucket" env:"BUCKET" description:"aggregate window on server by buckets of time (ex: 5m): errors by category and latency percentiles"`
+	Group    string        `short:"g" long:"group" env:"GROUP" description:"aggregate window on server also by alias (uid-direct - not by alias) or by trigger (http, queue, schedule, chain)" choice:"alias" choice:"trigger"`
+	Watch    bool          `short:"w" long:"watch" env:"WATCH" description:"refresh summary periodically"`
+	Interval time.Duration `long:"interval" env:"INTERVAL" description:"refresh interval for watch mode" default:"3s"`
+
+	Export    statsExport    `command:"export" description:"stream invocation records of the window as CSV or NDJSON"`
+	Retention statsRetention `command:"retention" description:"show usage of stats storage and change retention of records without restart"`
+}
+
+func (cmd *statsCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	// lambda is not a positional argument: it would shadow export subcommand
+	var lambda string
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	} else if len(args) == 1 {
+		lambda = args[0]
+	}
+	if !cmd.All && lambda == "" {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if !cmd.All && lambda != "" {
+		def, err := cmd.FindLambda(ctx, token, lambda)
+		if err != nil {
+			return err
+		}
+		cmd.UID = def.UID
+	}
+	for {
+		if err := cmd.show(ctx, token); err != nil {
+			return err
+		}
+		if !cmd.Watch {
+			return nil
+		}
+		select {
+		case <-time.After(cmd.Interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (cmd *statsCmd) show(ctx context.Context, token *api.Token) error {
+	now := time.Now()
+	since, err := parseMoment(cmd.Since, now, false)
+	if err != nil {
+		return fmt.Errorf("since: %w", err)
+	}
+	query := stats.Query{Since: since, Limit: cmd.Limit}
+	if cmd.Until != "" {
+		if query.Until, err = parseMoment(cmd.Until, now, true); err != nil {
+			return fmt.Errorf("until: %w", err)
+		}
+	}
+	if cmd.Bucket > 0 || cmd.Group != "" {
+		return cmd.showBuckets(ctx, token, query)
+	}
+	if cmd.All {
+		result, err := cmd.allStats(ctx, token, query)
+		if err != nil {
+			return err
+		}
+		if globalOptions.JSON {
+			return printJSON(result)
+		}
+		return printAllStats(result)
+	}
+	records, err := cmd.Lambdas().StatsRange(ctx, token, cmd.UID, query)
+	if err != nil {
+		return fmt.Errorf("get records: %w", err)
+	}
+	records = recordsSince(records, since)
+	result := statsResult{UID: cmd.UID, Since: since, Until: optionalTime(query.Until), Summary: summarize(records), Records: []statsRecord{}}
+	if !cmd.Watch {
+		recent := records
+		if len(recent) > cmd.Records {
+			recent = recent[len(recent)-cmd.Records:]
+		}
+		for _, record := range recent {
+			result.Records = append(result.Records, newStatsRecord(record))
+		}
+	}
+	if globalOptions.JSON {
+		return printJSON(result)
+	}
+	return printStats(result)
+}
+
+func (cmd *statsCmd) allStats(ctx context.Context, token *api.Token, query stats.Query) (*statsAllResult, error) {
+	records, err := cmd.Project().StatsRange(ctx, token, query)
+	if err != nil {
+		return nil, fmt.Errorf("get records: %w", err)
+	}
+	records = recordsSince(records, query.Since)
+	list, err := cmd.Project().List(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("list lambdas: %w", err)
+	}
+	var names = make(map[string]string, len(list))
+	for _, def := range list {
+		names[def.UID] = def.Manifest.Name
+	}
+	var byUID = make(map[string][]stats.Record)
+	for _, record := range records {
+		byUID[record.UID] = append(byUID[record.UID], record)
+	}
+	result := &statsAllResult{Since: query.Since, Until: optionalTime(query.Until), Summary: summarize(records), Lambdas: make([]lambdaStats, 0, len(byUID))}
+	for uid, items := range byUID {
+		result.Lambdas = append(result.Lambdas, lambdaStats{UID: uid, Name: names[uid], statsSummary: summarize(items)})
+	}
+	sort.Slice(result.Lambdas, func(i, j int) bool {
+		a, b := result.Lambdas[i], result.Lambdas[j]
+		if cmd.Sort == statsSortErrors && a.ErrorRate != b.ErrorRate {
+			return a.ErrorRate > b.ErrorRate
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.UID < b.UID
+	})
+	return result, nil
+}
+
+// aggregation is not limited by number of records: records are aggregated by server
+func (cmd *statsCmd) showBuckets(ctx context.Context, token *api.Token, query stats.Query) error {
+	var aggregation *stats.Aggregation
+	var err error
+	if cmd.All {
+		aggregation, err = cmd.Project().StatsAggregate(ctx, token, query, types.JsonDuration(cmd.Bucket), cmd.Group)
+	} else {
+		aggregation, err = cmd.Lambdas().StatsAggregate(ctx, token, cmd.UID, query, types.JsonDuration(cmd.Bucket), cmd.Group)
+	}
+	if err != nil {
+		return fmt.Errorf("aggregate records: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(aggregation)
+	}
+	return printBuckets(aggregation)
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func printWindow(since time.Time, until *time.Time) {
+	fmt.Println("since:     ", since.Local().Format(time.RFC3339))
+	if until != nil {
+		fmt.Println("until:     ", until.Local().Format(time.RFC3339))
+	}
+}
+
+// records not older than the moment, from oldest to newest (server returns newest first)
+func recordsSince(records []stats.Record, since time.Time) []stats.Record {
+	var ans = make([]stats.Record, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		if !records[i].Begin.Before(since) {
+			ans = append(ans, records[i])
+		}
+	}
+	return ans
+}
+
+// summary of records: sampled records are weighted by sampling rate
+func summarize(records []stats.Record) statsSummary {
+	var summary statsSummary
+	var total, min, max, queueTotal, queueMax time.Duration
+	for i, record := range records {
+		weight := record.Weight()
+		duration := record.End.Sub(record.Begin)
+		summary.Count += weight
+		if record.Failed() {
+			summary.Errors += weight
+		}
+		total += duration * time.Duration(weight)
+		if i == 0 || duration < min {
+			min = duration
+		}
+		if duration > max {
+			max = duration
+		}
+		if record.Queue != "" {
+			summary.Queued += weight
+			queueTotal += record.QueueWait * time.Duration(weight)
+			if record.QueueWait > queueMax {
+				queueMax = record.QueueWait
+			}
+		}
+	}
+	if summary.Count > 0 {
+		summary.ErrorRate = float64(summary.Errors) / float64(summary.Count)
+		summary.MinMs = milliseconds(min)
+		summary.AvgMs = milliseconds(total / time.Duration(summary.Count))
+		summary.MaxMs = milliseconds(max)
+	}
+	if summary.Queued > 0 {
+		summary.AvgQueueWaitMs = milliseconds(queueTotal / time.Duration(summary.Queued))
+		summary.MaxQueueWaitMs = milliseconds(queueMax)
+	}
+	return summary
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func printStats(result statsResult) error {
+	if len(result.Records) > 0 {
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(out, "TIME\tTRIGGER\tALIAS\tDURATION\tWAIT\tSTATUS\tPAYLOAD\tSIZE\tREQUEST")
+		for _, record := range result.Records {
+			status := record.Status
+			if record.Error != "" {
+				status += ": " + record.Error
+			}
+			_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%v\t%v\t%s\n", record.Begin.Local().Format(time.RFC3339), record.Trigger,
+				record.Alias, formatMs(record.DurationMs), formatMs(record.WaitMs), status, units.Base2Bytes(record.Payload), units.Base2Bytes(record.Size), record.RequestID)
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	printWindow(result.Since, result.Until)
+	printSummary(result.Summary)
+	return nil
+}
+
+func printAllStats(result *statsAllResult) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "UID\tNAME\tCALLS\tERRORS\tERROR RATE\tMIN\tAVG\tMAX")
+	for _, item := range result.Lambdas {
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", item.UID, item.Name, item.Count, item.Errors,
+			item.ErrorRate*100, formatMs(item.MinMs), formatMs(item.AvgMs), formatMs(item.MaxMs))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	printWindow(result.Since, result.Until)
+	printSummary(result.Summary)
+	return nil
+}
+
+func printBuckets(aggregation *stats.Aggregation) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "TIME\tCALLS\tSCRIPT\tTIMEOUT\tREJECTED\tDENIED\tERROR RATE\tP50\tP95\tMAX")
+	for _, bucket := range aggregation.Buckets {
+		_, _ = fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", bucket.Begin.Local().Format(time.RFC3339), bucket.Calls,
+			bucket.Errors.Script, bucket.Errors.Timeout, bucket.Errors.Rejected, bucket.Errors.Denied, errorRate(bucket)*100,
+			formatMs(milliseconds(bucket.Latency.P50)), formatMs(milliseconds(bucket.Latency.P95)), formatMs(milliseconds(bucket.Latency.Max)))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	if len(aggregation.Groups) > 0 {
+		if err := printGroups(aggregation); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	total := aggregation.Total
+	printWindow(aggregation.Since, &aggregation.Until)
+	fmt.Println("bucket:    ", aggregation.Bucket)
+	fmt.Println("calls:     ", total.Calls)
+	fmt.Printf("errors:     %d (%.1f%%): script %d, timeout %d, rejected %d, denied %d\n", total.Errors.Sum(), errorRate(total)*100,
+		total.Errors.Script, total.Errors.Timeout, total.Errors.Rejected, total.Errors.Denied)
+	fmt.Println("latency:   ", "min", formatMs(milliseconds(total.Latency.Min)), "avg", formatMs(milliseconds(total.Latency.Avg)),
+		"p50", formatMs(milliseconds(total.Latency.P50)), "p95", formatMs(milliseconds(total.Latency.P95)), "max", formatMs(milliseconds(total.Latency.Max)))
+	return nil
+}
+
+// totals of groups, buckets of groups are only in JSON output
+func printGroups(aggregation *stats.Aggregation) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(out, "%s\tCALLS\tERRORS\tERROR RATE\tP50\tP95\tMAX\n", strings.ToUpper(aggregation.Group))
+	for _, group := range aggregation.Groups {
+		total := group.Total
+		_, _ = fmt.Fprintf(out, "%s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", group.Key, total.Calls, total.Errors.Sum(), errorRate(total)*100,
+			formatMs(milliseconds(total.Latency.P50)), formatMs(milliseconds(total.Latency.P95)), formatMs(milliseconds(total.Latency.Max)))
+	}
+	return out.Flush()
+}
+
+func errorRate(bucket stats.Bucket) float64 {
+	if bucket.Calls == 0 {
+		return 0
+	}
+	return float64(bucket.Errors.Sum()) / float64(bucket.Calls)
+}
+
+func printSummary(summary statsSummary) {
+	fmt.Println("calls:     ", summary.Count)
+	fmt.Printf("errors:     %d (%.1f%%)\n", summary.Errors, summary.ErrorRate*100)
+	fmt.Println("duration:  ", "min", formatMs(summary.MinMs), "avg", formatMs(summary.AvgMs), "max", formatMs(summary.MaxMs))
+	if summary.Queued > 0 {
+		fmt.Println("queue wait:", "avg", formatMs(summary.AvgQueueWaitMs), "max", formatMs(summary.MaxQueueWaitMs), "of", summary.Queued, "queued")
+	}
+}
+
+func formatMs(value float64) string {
+	return time.Duration(value * float64(time.Millisecond)).Round(time.Millisecond).String()
+}
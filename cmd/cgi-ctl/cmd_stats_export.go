@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alecthomas/units"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+type statsExport struct {
+	remoteLink
+	uidLocator
+	Format string `short:"f" long:"format" env:"FORMAT" description:"format of records: CSV with header row or one JSON object per line" choice:"csv" choice:"ndjson" default:"csv"`
+	Since  string `short:"s" long:"since" env:"SINCE" description:"beginning of window: date (2006-01-02), RFC3339 time or duration ago (empty - the oldest kept record)"`
+	Until  string `long:"until" env:"UNTIL" description:"end of window: date (inclusive), RFC3339 time or duration ago (empty - now)"`
+	All    bool   `short:"a" long:"all" env:"ALL" description:"export records of all lambdas"`
+	Output string `short:"o" long:"output" env:"OUTPUT" description:"output file (empty - stdout)"`
+	Args   struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *statsExport) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if !cmd.All && cmd.Args.Lambda == "" {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+	}
+	params := url.Values{"format": {cmd.Format}}
+	now := time.Now()
+	for name, value := range map[string]string{"since": cmd.Since, "until": cmd.Until} {
+		if value == "" {
+			continue
+		}
+		moment, err := parseMoment(value, now, name == "until")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		params.Set(name, moment.Format(time.RFC3339Nano))
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if !cmd.All && cmd.Args.Lambda != "" {
+		def, err := cmd.FindLambda(ctx, token, cmd.Args.Lambda)
+		if err != nil {
+			return err
+		}
+		cmd.UID = def.UID
+	}
+	if !cmd.All {
+		params.Set("uid", cmd.UID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlJoin(cmd.URL, "api", "v1", "stats", "export")+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("prepare request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Data)
+	log.Println("exporting...")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		data, _ := ioutil.ReadAll(res.Body)
+		if json.Unmarshal(data, &failure) != nil || failure.Message == "" {
+			failure.Message = string(data)
+		}
+		return fmt.Errorf("export: %s: %s", res.Status, failure.Message)
+	}
+
+	var out io.Writer = os.Stdout
+	if cmd.Output != "" {
+		f, err := os.Create(cmd.Output)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	// incomplete export is aborted by server: body is not ended
+	size, err := io.Copy(out, res.Body)
+	if err != nil {
+		return fmt.Errorf("export interrupted after %v: %w", units.Base2Bytes(size), err)
+	}
+	log.Println("exported", units.Base2Bytes(size))
+	if cmd.Output == "" {
+		return nil
+	}
+	return printResult(downloadResult{UID: cmd.UID, Output: cmd.Output, Size: int(size)})
+}
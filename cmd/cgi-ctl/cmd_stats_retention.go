@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"github.com/alecthomas/units"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type statsRetention struct {
+	remoteLink
+	MaxAge     *time.Duration `long:"max-age" env:"MAX_AGE" description:"remove records older than the age (ex: 720h, zero - not limited)"`
+	MaxRecords *int           `long:"max-records" env:"MAX_RECORDS" description:"remove the oldest records over the number (zero - not limited)"`
+	MaxSize    *int64         `long:"max-size" env:"MAX_SIZE" description:"remove the oldest records over size on disk in bytes (zero - not limited, not for lambda)"`
+	Lambda     string         `short:"L" long:"lambda" env:"LAMBDA" description:"change limits of lambda (UID or alias) instead of server: maximum age overrides age of server"`
+	Reset      bool           `long:"reset" env:"RESET" description:"remove limits of lambda (with --lambda): limits of server are applied"`
+}
+
+func (cmd *statsRetention) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if cmd.Lambda == "" && cmd.Reset {
+		return fmt.Errorf("--reset requires --lambda")
+	}
+	if cmd.Lambda != "" && cmd.MaxSize != nil {
+		return fmt.Errorf("--max-size is limit of server only")
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	usage, err := cmd.Project().StatsUsage(ctx, token)
+	if err != nil {
+		return fmt.Errorf("get usage of stats: %w", err)
+	}
+	retention := usage.Retention
+	changed := cmd.Reset || cmd.MaxAge != nil || cmd.MaxRecords != nil || cmd.MaxSize != nil
+	if changed && cmd.Lambda != "" {
+		def, err := cmd.FindLambda(ctx, token, cmd.Lambda)
+		if err != nil {
+			return err
+		}
+		limits := retention.Lambdas[def.UID]
+		if cmd.MaxAge != nil {
+			limits.MaxAge = types.JsonDuration(*cmd.MaxAge)
+		}
+		if cmd.MaxRecords != nil {
+			limits.MaxRecords = *cmd.MaxRecords
+		}
+		if retention.Lambdas == nil {
+			retention.Lambdas = make(map[string]stats.LambdaRetention)
+		}
+		retention.Lambdas[def.UID] = limits
+		if cmd.Reset || limits == (stats.LambdaRetention{}) {
+			delete(retention.Lambdas, def.UID)
+		}
+	} else if changed {
+		if cmd.MaxAge != nil {
+			retention.MaxAge = types.JsonDuration(*cmd.MaxAge)
+		}
+		if cmd.MaxRecords != nil {
+			retention.MaxRecords = *cmd.MaxRecords
+		}
+		if cmd.MaxSize != nil {
+			retention.MaxSize = *cmd.MaxSize
+		}
+	}
+	if changed {
+		log.Println("changing retention...")
+		usage, err = cmd.Project().SetStatsRetention(ctx, token, retention)
+		if err != nil {
+			return fmt.Errorf("change retention of stats: %w", err)
+		}
+	}
+	if globalOptions.JSON {
+		return printJSON(usage)
+	}
+	return printStatsUsage(usage)
+}
+
+func printStatsUsage(usage *stats.Usage) error {
+	fmt.Println("records:   ", usage.Records, "("+fmt.Sprint(usage.Pending), "waiting for write)")
+	fmt.Println("size:      ", units.Base2Bytes(usage.Size), "in", usage.Segments, "segments")
+	fmt.Println("oldest:    ", formatTime(usage.Oldest))
+	fmt.Println("newest:    ", formatTime(usage.Newest))
+	retention := usage.Retention
+	fmt.Println("retention: ", "age", formatLimit(int64(retention.MaxAge), time.Duration(retention.MaxAge).String()),
+		"records", formatLimit(int64(retention.MaxRecords), fmt.Sprint(retention.MaxRecords)),
+		"size", formatLimit(retention.MaxSize, units.Base2Bytes(retention.MaxSize).String()))
+	pruning := usage.Pruning
+	if pruning.Time.IsZero() {
+		fmt.Println("pruning:    not pruned since start")
+	} else {
+		fmt.Println("pruning:   ", formatTime(pruning.Time), "removed", pruning.Removed, "("+fmt.Sprint(pruning.Early), "newer than age) in",
+			time.Duration(pruning.Duration).Round(time.Millisecond), "- total", pruning.Total, "since start")
+	}
+	if pruning.Error != "" {
+		fmt.Println("error:     ", pruning.Error)
+	}
+	if pruning.Behind {
+		log.Println("pruning doesn't keep up: limits are exceeded after the last pruning")
+	}
+	if len(retention.Lambdas) == 0 {
+		return nil
+	}
+	fmt.Println()
+	var uids []string
+	for uid := range retention.Lambdas {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "LAMBDA\tMAX AGE\tMAX RECORDS")
+	for _, uid := range uids {
+		limits := retention.Lambdas[uid]
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\n", uid, formatLimit(int64(limits.MaxAge), time.Duration(limits.MaxAge).String()),
+			formatLimit(int64(limits.MaxRecords), fmt.Sprint(limits.MaxRecords)))
+	}
+	return out.Flush()
+}
+
+// limit of retention (zero - not limited)
+func formatLimit(limit int64, value string) string {
+	if limit == 0 {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type tokenCmd struct {
+	Create tokenCreate `command:"create" description:"create API token limited to lambdas and operations, value is printed once"`
+	Rotate tokenRotate `command:"rotate" description:"issue replacement of API token with the same scope, the old token is valid for overlap"`
+	List   tokenList   `command:"ls" description:"list API tokens with scopes and expiration"`
+	Revoke tokenRevoke `command:"rm" description:"revoke API tokens"`
+}
+
+type tokenCreate struct {
+	remoteLink
+	Name    string   `short:"n" long:"name" env:"NAME" description:"name of token (ex: name of CI pipeline)" required:"yes"`
+	Lambdas []string `long:"lambda" env:"LAMBDA" env-delim:"," description:"UID or alias of allowed lambda (could be repeated, empty - all lambdas)"`
+	Ops     []string `long:"ops" env:"OPS" env-delim:"," description:"allowed operations: upload, invoke, read-stats, manage-schedule, admin (comma-separated or repeated)" required:"yes"`
+	TTL     string   `long:"ttl" env:"TTL" description:"time to live of token with days (ex: 30d, 12h, empty - never expires)"`
+}
+
+func (cmd *tokenCreate) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	ttl, err := parseDays(cmd.TTL)
+	if err != nil {
+		return fmt.Errorf("parse ttl: %w", err)
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	var scope api.Scope
+	for _, op := range cmd.Ops {
+		for _, name := range strings.Split(op, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				scope.Operations = append(scope.Operations, name)
+			}
+		}
+	}
+	for _, name := range cmd.Lambdas {
+		def, err := cmd.FindLambda(ctx, token, name)
+		if err != nil {
+			return err
+		}
+		scope.Lambdas = append(scope.Lambdas, def.UID)
+	}
+	info, err := cmd.Users().CreateToken(ctx, token, cmd.Name, scope, types.JsonDuration(ttl))
+	if err != nil {
+		return fmt.Errorf("create token: %w", err)
+	}
+	log.Println("token", info.ID, "created with scope", formatScope(info.Scope))
+	return printToken(info)
+}
+
+type tokenRotate struct {
+	remoteLink
+	Overlap time.Duration `long:"overlap" env:"OVERLAP" description:"time when the old token is still valid (zero - one hour)"`
+	Args    struct {
+		ID string `positional-arg-name:"id" required:"yes" description:"ID of token"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *tokenRotate) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	info, err := cmd.Users().RotateToken(ctx, token, cmd.Args.ID, types.JsonDuration(cmd.Overlap))
+	if err != nil {
+		return fmt.Errorf("rotate token: %w", err)
+	}
+	log.Println("token", cmd.Args.ID, "replaced by", info.ID, "with scope", formatScope(info.Scope))
+	return printToken(info)
+}
+
+// value of issued token to stdout
+func printToken(info *api.TokenInfo) error {
+	if !info.Expires.IsZero() {
+		log.Println("token expires at", formatTime(info.Expires))
+	}
+	if globalOptions.JSON {
+		return printJSON(info)
+	}
+	log.Println("value of token is shown only once, pass it by --token flag or CGI_CTL_TOKEN environment variable")
+	fmt.Println(info.Token)
+	return nil
+}
+
+type tokenList struct {
+	remoteLink
+}
+
+func (cmd *tokenList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	list, err := cmd.Users().Tokens(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list tokens: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(list)
+	}
+	if len(list) == 0 {
+		log.Println("no API tokens")
+		return nil
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	now := time.Now()
+	_, _ = fmt.Fprintln(out, "ID\tNAME\tCREATED\tEXPIRES\tOPERATIONS\tLAMBDAS")
+	for _, info := range list {
+		lambdas := "all"
+		if len(info.Scope.Lambdas) > 0 {
+			lambdas = strings.Join(info.Scope.Lambdas, ",")
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\n", info.ID, info.Name, formatTime(info.Created),
+			formatExpiry(info, now), strings.Join(info.Scope.Operations, ","), lambdas)
+	}
+	return out.Flush()
+}
+
+// time of expiration with time left or expired mark
+func formatExpiry(info api.TokenInfo, now time.Time) string {
+	if info.Expires.IsZero() {
+		return "never"
+	}
+	var state string
+	if left := info.Expires.Sub(now); left <= 0 {
+		state = "expired"
+	} else if left < 48*time.Hour {
+		state = "in " + left.Round(time.Minute).String()
+	} else {
+		state = fmt.Sprintf("in %dd", int(left.Hours()/24))
+	}
+	if info.ReplacedBy != "" {
+		state += ", rotated"
+	}
+	return formatTime(info.Expires) + " (" + state + ")"
+}
+
+// duration with optional days prefix (ex: 30d, 1d12h)
+func parseDays(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	var days time.Duration
+	if idx := strings.Index(value, "d"); idx > 0 {
+		n, err := strconv.Atoi(value[:idx])
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days %q", value[:idx])
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		if value = value[idx+1:]; value == "" {
+			return days, nil
+		}
+	}
+	rest, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return days + rest, nil
+}
+
+type tokenRevoke struct {
+	remoteLink
+	Args struct {
+		IDs []string `positional-arg-name:"id" required:"1" description:"ID of token"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *tokenRevoke) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, id := range cmd.Args.IDs {
+		if _, err := cmd.Users().RevokeToken(ctx, token, id); err != nil {
+			return fmt.Errorf("revoke token %s: %w", id, err)
+		}
+		log.Println("token", id, "revoked")
+	}
+	return printResult(map[string]interface{}{"revoked": cmd.Args.IDs})
+}
+
+func formatScope(scope api.Scope) string {
+	lambdas := "all lambdas"
+	if len(scope.Lambdas) > 0 {
+		lambdas = strings.Join(scope.Lambdas, ", ")
+	}
+	return strings.Join(scope.Operations, ", ") + " on " + lambdas
+}
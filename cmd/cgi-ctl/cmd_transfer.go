@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type transfer struct {
+	From      string        `long:"from" env:"FROM" description:"Source server: name of remote from control file or URL" required:"yes"`
+	To        string        `long:"to" env:"TO" description:"Destination server: name of remote from control file or URL" required:"yes"`
+	SourceURL string        `long:"source-url" env:"SOURCE_URL" description:"URL of source server reachable from destination (empty - URL of source)"`
+	Secrets   bool          `long:"secrets" env:"SECRETS" description:"Transfer values of environment variables (otherwise names only with empty values)"`
+	Interval  time.Duration `long:"interval" env:"INTERVAL" description:"poll interval of progress" default:"1s"`
+	Args      struct {
+		Lambda string `positional-arg-name:"uid-or-alias" description:"lambda UID or alias on source (empty - UID of source remote)"`
+	} `positional-args:"yes"`
+}
+
+// transferred lambda (transfer)
+type transferResult struct {
+	UID         string   `json:"uid"`
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Hash        string   `json:"hash"`              // SHA-256 of transferred content archive
+	Skipped     []string `json:"skipped,omitempty"` // aliases bound to other lambdas on destination
+}
+
+func (cmd *transfer) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	source, remoteUID, err := transferEndpoint(cmd.From)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	destination, _, err := transferEndpoint(cmd.To)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	if strings.TrimRight(source.URL, "/") == strings.TrimRight(destination.URL, "/") {
+		return fmt.Errorf("source and destination are the same server %s", source.URL)
+	}
+	name := cmd.Args.Lambda
+	if name == "" {
+		name = remoteUID
+	}
+	if name == "" {
+		return fmt.Errorf("lambda UID or alias is required: remote %s has no UID", cmd.From)
+	}
+
+	log.Println("login to source", source.URL, "...")
+	sourceToken, err := source.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login to source: %w", err)
+	}
+	log.Println("login to destination", destination.URL, "...")
+	destinationToken, err := destination.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login to destination: %w", err)
+	}
+	def, err := source.FindLambda(ctx, sourceToken, name)
+	if err != nil {
+		return err
+	}
+	log.Println("grant export of lambda", def.UID, "...")
+	grant, err := source.Lambdas().GrantExport(ctx, sourceToken, def.UID, cmd.Secrets)
+	if err != nil {
+		return fmt.Errorf("grant export: %w", err)
+	}
+	sourceURL := cmd.SourceURL
+	if sourceURL == "" {
+		sourceURL = source.URL
+	}
+
+	id := uuid.New().String()
+	following, stop := context.WithCancel(ctx)
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		cmd.follow(following, destination, destinationToken, id)
+	}()
+	report, err := destination.Project().Transfer(ctx, destinationToken, api.TransferRequest{ID: id, Source: sourceURL, Grant: *grant})
+	stop()
+	<-followed
+	if err != nil {
+		return fmt.Errorf("transfer: %w", err)
+	}
+	for _, alias := range report.Skipped {
+		log.Println("alias", alias, "is bound to another lambda on destination, skipped")
+	}
+	log.Println("lambda", def.UID, "transferred to", destination.URL)
+	return printResult(transferResult{
+		UID:         def.UID,
+		Name:        report.Definition.Manifest.Name,
+		Source:      source.URL,
+		Destination: destination.URL,
+		Hash:        grant.Hash,
+		Skipped:     report.Skipped,
+	})
+}
+
+// print stages of transfer on destination till context is done
+func (cmd *transfer) follow(ctx context.Context, destination *remoteLink, token *api.Token, id string) {
+	interval := cmd.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var stage string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		progress, err := destination.Project().TransferProgress(ctx, token, id)
+		if err != nil || progress.Stage == stage {
+			continue // not started yet or not changed
+		}
+		stage = progress.Stage
+		if progress.Size > 0 {
+			log.Println(stage, "...", progress.Size, "bytes received")
+		} else {
+			log.Println(stage, "...")
+		}
+	}
+}
+
+// link to server by name of remote from control file or by URL. Returns UID of lambda of remote (if any)
+func transferEndpoint(name string) (*remoteLink, string, error) {
+	var cf controlFile
+	if err := cf.Read(controlFilename); err != nil && !os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("read control file: %w", err)
+	}
+	if remote := cf.Remote(name); remote != nil {
+		return &remoteLink{URL: remote.URL, fixed: true}, remote.UID, nil
+	}
+	if info, err := url.Parse(serverURL(name)); err != nil || info.Scheme == "" || info.Host == "" {
+		return nil, "", fmt.Errorf("%s is neither remote of control file nor URL", name)
+	}
+	return &remoteLink{URL: name, fixed: true}, "", nil
+}
@@ -3,13 +3,13 @@ package main
 import (
 	"fmt"
 	"github.com/reddec/trusted-cgi/cmd/internal"
-	internal2 "github.com/reddec/trusted-cgi/internal"
 	"log"
 )
 
 type updateManifest struct {
 	remoteLink
 	uidLocator
+	manifestFormat
 }
 
 func (cmd *updateManifest) Execute(args []string) error {
@@ -30,10 +30,13 @@ func (cmd *updateManifest) Execute(args []string) error {
 		return fmt.Errorf("get info: %w", err)
 	}
 	log.Println("saving...")
-	err = info.Manifest.SaveAs(internal2.ManifestFile)
+	err = cmd.save(".", info.Manifest)
 	if err != nil {
 		return fmt.Errorf("update manifest file: %w", err)
 	}
+	if err := saveBase(info.Manifest); err != nil {
+		return err
+	}
 	log.Println("done")
-	return nil
+	return printResult(manifestResult{UID: cmd.UID, Manifest: info.Manifest})
 }
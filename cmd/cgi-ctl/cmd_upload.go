@@ -2,11 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/alecthomas/units"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
 	"github.com/reddec/trusted-cgi/cmd/internal"
 	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 )
@@ -14,12 +22,40 @@ import (
 type upload struct {
 	remoteLink
 	uidLocator
-	Input string `long:"input" env:"INPUT" description:"Directory" default:"."`
+	manifestSync
+	Input   string `long:"input" env:"INPUT" description:"Directory" default:"."`
+	Archive string `long:"archive" env:"ARCHIVE" description:"Upload existing .tar.gz or .zip archive instead of directory content"`
+	Events  bool   `long:"events" env:"EVENTS" description:"emit newline-delimited JSON events to stdout (implied by --json)"`
+	Message string `short:"m" long:"message" env:"MESSAGE" description:"annotate version of uploaded content by message (see versions ls), uploaded content is not verified"`
+	// upload is verified by signed content hash if server supports it
+	RequireVerification bool `long:"require-verification" env:"REQUIRE_VERIFICATION" description:"fail if server doesn't support verification of uploaded content"`
 }
 
 func (cmd *upload) Execute([]string) error {
+	var events *internal.Events
+	if cmd.Events || globalOptions.JSON {
+		events = internal.NewEvents(os.Stdout, "upload")
+		outputWritten = true // errors are reported as events
+	}
+	if err := cmd.run(events); err != nil {
+		return events.Error(cmd.UID, err)
+	}
+	events.Done(cmd.UID)
+	return nil
+}
+
+func (cmd *upload) run(events *internal.Events) error {
 	ctx, closer := internal.SignalContext()
 	defer closer()
+	var prepared []byte
+	if cmd.Archive != "" {
+		// read before changing dir: path is relative to the current directory
+		data, err := ioutil.ReadFile(cmd.Archive)
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		prepared = data
+	}
 	err := os.Chdir(cmd.Input)
 	if err != nil {
 		return fmt.Errorf("change dir: %w", err)
@@ -27,31 +63,185 @@ func (cmd *upload) Execute([]string) error {
 	if err := cmd.parseUID(); err != nil {
 		return err
 	}
-	var buffer = &bytes.Buffer{}
 	log.SetOutput(os.Stderr)
-	log.Println("archiving...")
-	var args = []string{"zcf", "-"}
-	if _, err := os.Stat(internal_app.CGIIgnore); err == nil {
-		args = append(args, "--exclude-from", internal_app.CGIIgnore)
-	}
-	args = append(args, ".")
-	run := exec.CommandContext(ctx, "tar", args...)
-	run.Stdout = buffer
-	run.Stderr = os.Stderr
-	err = run.Run()
-	if err != nil {
-		return fmt.Errorf("archive: %w", err)
-	}
 	log.Println("login...")
+	events.Progress("login", cmd.UID, 0, 0)
 	token, err := cmd.Token(ctx)
 	if err != nil {
 		return fmt.Errorf("login: %w", err)
 	}
+	var manifest types.Manifest
+	// manifest of prepared archive is uploaded as-is
+	hasManifest := prepared == nil && loadLocalManifest(&manifest) == nil
+	if hasManifest {
+		log.Println("checking remote manifest...")
+		events.Progress("sync", cmd.UID, 0, 0)
+		info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+		if err != nil {
+			return fmt.Errorf("get info: %w", err)
+		}
+		manifest, err = cmd.syncLocal(manifest, info.Manifest)
+		if err != nil {
+			return err
+		}
+		if err := manifest.Validate(); err != nil {
+			return fmt.Errorf("invalid manifest: %w", err)
+		}
+		if err := cmd.bindAliases(ctx, token, manifest); err != nil {
+			return err
+		}
+	}
+	buffer := bytes.NewBuffer(prepared)
+	if prepared == nil {
+		log.Println("archiving...")
+		events.Progress("archive", cmd.UID, 0, 0)
+		buffer, err = archiveDir(ctx, true)
+		if err != nil {
+			return err
+		}
+	}
+	events.Progress("archive", cmd.UID, int64(buffer.Len()), int64(buffer.Len()))
+	if err := cmd.checkUpload(ctx, token, buffer.Bytes()); err != nil {
+		return err
+	}
 	log.Println("upload", cmd.UID, units.Base2Bytes(buffer.Len()), "...")
-	_, err = cmd.Lambdas().Upload(ctx, token, cmd.UID, buffer.Bytes())
+	if events != nil {
+		// request body is encoded archive, so progress is reported in bytes of request
+		defaultTransport := http.DefaultClient.Transport
+		http.DefaultClient.Transport = &progressTransport{base: defaultTransport, report: func(sent, total int64) {
+			events.Progress("transfer", cmd.UID, sent, total)
+		}}
+		defer func() { http.DefaultClient.Transport = defaultTransport }()
+	}
+	if cmd.Message != "" {
+		return cmd.uploadVersion(ctx, token, buffer.Bytes(), manifest, hasManifest)
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	proof, err := cmd.Lambdas().UploadVerified(ctx, token, cmd.UID, buffer.Bytes(), nonce)
+	if err = verificationError(err); errors.Is(err, errNoVerification) {
+		if cmd.RequireVerification {
+			return fmt.Errorf("upload: %w", err)
+		}
+		log.Println("[WARN] server doesn't support verification, uploaded content is not verified")
+		events.Warning(cmd.UID, "server doesn't support verification, uploaded content is not verified")
+		proof = nil
+		_, err = cmd.Lambdas().Upload(ctx, token, cmd.UID, buffer.Bytes())
+	}
 	if err != nil {
 		return fmt.Errorf("upload: %w", err)
 	}
+	if proof != nil {
+		events.Progress("verify", cmd.UID, 0, 0)
+		if err := checkProof(proof, token, cmd.UID, nonce, api.ArchiveHash(buffer.Bytes())); err != nil {
+			return err
+		}
+		log.Println("verified: server received the same archive, content hash", proof.Hash)
+		if err := updateRemote(func(remote *controlRemote) { remote.Content = proof.Hash }); err != nil {
+			return err
+		}
+	}
+	if hasManifest {
+		if err := saveBase(manifest); err != nil {
+			return err
+		}
+	}
 	log.Println("done")
 	return nil
 }
+
+// upload archive as new version annotated by message
+func (cmd *upload) uploadVersion(ctx context.Context, token *api.Token, archive []byte, manifest types.Manifest, hasManifest bool) error {
+	if cmd.RequireVerification {
+		return fmt.Errorf("upload: annotated upload (--message) is not verified, it could not be used with --require-verification")
+	}
+	version, err := cmd.Lambdas().UploadVersion(ctx, token, cmd.UID, archive, cmd.Message)
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	log.Println("uploaded as version", version.ID)
+	if hasManifest {
+		if err := saveBase(manifest); err != nil {
+			return err
+		}
+	}
+	log.Println("done")
+	return nil
+}
+
+// check that aliases declared in manifest are not bound to other lambdas before upload. With --force-aliases
+// manifest is applied before upload, so the aliases are moved
+func (cmd *upload) bindAliases(ctx context.Context, token *api.Token, manifest types.Manifest) error {
+	conflicts, err := cmd.AliasConflicts(ctx, token, cmd.UID, manifest.Aliases)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	conflict := &application.AliasConflictError{Conflicts: conflicts}
+	if !cmd.ForceAliases {
+		return fmt.Errorf("%w: use --force-aliases to move them", conflict)
+	}
+	log.Println("moving aliases:", conflict)
+	if _, err := cmd.Lambdas().Update(ctx, token, cmd.UID, manifest, true); err != nil {
+		return fmt.Errorf("move aliases: %w", err)
+	}
+	return nil
+}
+
+// reports progress of request body transfer
+type progressTransport struct {
+	base   http.RoundTripper // nil - http.DefaultTransport
+	report func(sent, total int64)
+}
+
+func (pt *progressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.ContentLength > 0 {
+		total := req.ContentLength
+		req = req.Clone(req.Context())
+		req.Body = internal.NewProgressReader(req.Body, total, func(sent int64) {
+			pt.report(sent, total)
+		})
+	}
+	if pt.base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return pt.base.RoundTrip(req)
+}
+
+// check archive by upload limits of server before transfer
+func (rl *remoteLink) checkUpload(ctx context.Context, token *api.Token, archive []byte) error {
+	info, err := rl.Project().Capabilities(ctx, token)
+	if err != nil {
+		log.Println("upload limits are not checked:", err)
+		return nil
+	}
+	if info.Upload == nil {
+		// server without upload limits in capabilities
+		return nil
+	}
+	return lambda.CheckArchive(bytes.NewReader(archive), *info.Upload)
+}
+
+// archive current directory as upload does (honoring .cgiignore)
+func archiveDir(ctx context.Context, compress bool) (*bytes.Buffer, error) {
+	var args = []string{"cf", "-"}
+	if compress {
+		args[0] = "zcf"
+	}
+	if _, err := os.Stat(internal_app.CGIIgnore); err == nil {
+		args = append(args, "--exclude-from", internal_app.CGIIgnore)
+	}
+	args = append(args, ".")
+	var buffer bytes.Buffer
+	run := exec.CommandContext(ctx, "tar", args...)
+	run.Stdout = &buffer
+	run.Stderr = os.Stderr
+	if err := run.Run(); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	return &buffer, nil
+}
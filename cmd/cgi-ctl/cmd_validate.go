@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Levels of validation issues
+const (
+	issueError   = "error"
+	issueWarning = "warning"
+)
+
+// rule of Makefile with prerequisites (recipes, comments and variable assignments are not matched)
+var makeRulePattern = regexp.MustCompile(`^([^\s:#=]+)\s*:([^=].*)?$`)
+
+type validate struct{}
+
+func (cmd *validate) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	file, err := localManifestFile()
+	if err != nil {
+		return err
+	}
+	result := validateResult{Issues: []validationIssue{}, manifest: file}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	manifest, err := types.DecodeManifest(file, bytes.NewReader(content))
+	if err != nil {
+		result.add(issueError, result.manifest, "", fmt.Sprintf("parse manifest: %v", err))
+		return result.report()
+	}
+	if err := manifest.Validate(); err != nil {
+		var invalid *types.ValidationError
+		if !errors.As(err, &invalid) {
+			result.add(issueError, result.manifest, "", err.Error())
+		} else {
+			for _, problem := range invalid.Fields {
+				result.add(issueError, result.manifest, problem.Field, problem.Message)
+			}
+		}
+	}
+	uploaded, err := localFiles(ctx)
+	if err != nil {
+		return err
+	}
+	result.checkRun(manifest, uploaded)
+	if err := result.checkActions(manifest, uploaded); err != nil {
+		return err
+	}
+	result.checkLegacyAliases(content)
+	if manifest.TimeLimit <= 0 {
+		result.add(issueWarning, result.manifest, "time_limit", "time limit is not set: invocation could run forever")
+	}
+	return result.report()
+}
+
+func (vr *validateResult) add(level, file, field, message string) {
+	vr.Issues = append(vr.Issues, validationIssue{Level: level, File: file, Field: field, Message: message})
+	if level == issueError {
+		vr.Errors++
+	} else {
+		vr.Warnings++
+	}
+}
+
+// print issues and return error if there are errors
+func (vr *validateResult) report() error {
+	vr.Valid = vr.Errors == 0
+	if globalOptions.JSON {
+		if err := printJSON(vr); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range vr.Issues {
+			location := issue.File
+			if issue.Field != "" {
+				location += ": " + issue.Field
+			}
+			_, _ = fmt.Fprintln(os.Stderr, issue.Level+":", location+":", issue.Message)
+		}
+	}
+	if !vr.Valid {
+		return fmt.Errorf("validation failed: %d error(s), %d warning(s)", vr.Errors, vr.Warnings)
+	}
+	log.Println("valid,", vr.Warnings, "warning(s)")
+	return nil
+}
+
+// executable of run command should exist: absolute path on server, relative path in the project
+func (vr *validateResult) checkRun(manifest types.Manifest, uploaded map[string][]byte) {
+	// command of container is in image (empty - command of image)
+	if manifest.Container != nil {
+		return
+	}
+	if len(manifest.Run) == 0 || manifest.Run[0] == "" {
+		vr.add(issueError, vr.manifest, "run", "run command is not defined")
+		return
+	}
+	binary := manifest.Run[0]
+	switch {
+	case filepath.IsAbs(binary):
+		if _, err := os.Stat(binary); err != nil {
+			vr.add(issueWarning, vr.manifest, "run", fmt.Sprintf("%s does not exist locally: it should exist on the server", binary))
+		}
+	case strings.ContainsRune(binary, '/'):
+		name := path.Clean(filepath.ToSlash(binary))
+		stat, err := os.Stat(name)
+		if err != nil {
+			vr.add(issueError, vr.manifest, "run", fmt.Sprintf("%s does not exist in the project", binary))
+			return
+		}
+		if _, ok := uploaded[name]; !ok {
+			vr.add(issueWarning, internal_app.CGIIgnore, "", fmt.Sprintf("%s (run command) is excluded from upload", name))
+		} else if runtime.GOOS != "windows" && stat.Mode()&0111 == 0 { // permissions are not known on Windows
+			vr.add(issueWarning, vr.manifest, "run", fmt.Sprintf("%s is not executable", binary))
+		}
+	default:
+		if _, err := os.Stat(binary); err == nil {
+			vr.add(issueWarning, vr.manifest, "run", fmt.Sprintf("%s is looked up in PATH, use ./%s to run the file of the project", binary, binary))
+		}
+	}
+}
+
+// payload file of scheduled invocation should be uploaded
+func (vr *validateResult) checkPayloadFile(plan types.Schedule, uploaded map[string][]byte) {
+	if plan.PayloadFile == "" {
+		return
+	}
+	name := path.Clean(filepath.ToSlash(plan.PayloadFile))
+	if _, err := os.Stat(name); err != nil {
+		vr.add(issueError, vr.manifest, "cron", fmt.Sprintf("payload file %s does not exist in the project", plan.PayloadFile))
+	} else if _, ok := uploaded[name]; !ok {
+		vr.add(issueWarning, internal_app.CGIIgnore, "", fmt.Sprintf("%s (payload file) is excluded from upload", plan.PayloadFile))
+	}
+}
+
+// scheduled and startup actions should be defined in manifest or in Makefile, Makefile and files required by its
+// rules should be uploaded
+func (vr *validateResult) checkActions(manifest types.Manifest, uploaded map[string][]byte) error {
+	var used [][2]string // field and action of Makefile
+	for _, plan := range manifest.Cron {
+		if plan.Invocation() {
+			vr.checkPayloadFile(plan, uploaded)
+			continue
+		}
+		used = append(used, [2]string{"cron", plan.Action})
+	}
+	if manifest.OnStart != nil && manifest.OnStart.Action != "" {
+		used = append(used, [2]string{"on_start", manifest.OnStart.Action})
+	}
+	var external = used[:0]
+	for _, item := range used {
+		if _, ok := manifest.Actions[item[1]]; !ok {
+			external = append(external, item)
+		}
+	}
+	used = external
+	makefile, err := ioutil.ReadFile("Makefile")
+	if os.IsNotExist(err) {
+		for _, item := range used {
+			vr.add(issueError, vr.manifest, item[0], fmt.Sprintf("action %s is not defined in manifest and Makefile is not defined", item[1]))
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read Makefile: %w", err)
+	}
+	if _, ok := uploaded["Makefile"]; !ok {
+		vr.add(issueWarning, internal_app.CGIIgnore, "", "Makefile is excluded from upload")
+	}
+	fn, err := lambda.FromDir(".")
+	if err != nil {
+		return fmt.Errorf("load lambda: %w", err)
+	}
+	actions, err := fn.Actions()
+	if err != nil {
+		return fmt.Errorf("get actions: %w", err)
+	}
+	var defined = make(map[string]bool)
+	for _, action := range actions {
+		defined[action] = true
+	}
+	for _, item := range used {
+		if !defined[item[1]] {
+			vr.add(issueError, vr.manifest, item[0], fmt.Sprintf("action %s is not defined in manifest or Makefile", item[1]))
+		}
+	}
+	var excluded = make(map[string]string) // file -> target
+	scanner := bufio.NewScanner(bytes.NewReader(makefile))
+	for scanner.Scan() {
+		matches := makeRulePattern.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		for _, prerequisite := range strings.Fields(matches[2]) {
+			name := path.Clean(prerequisite)
+			if stat, err := os.Stat(name); err != nil || stat.IsDir() {
+				continue
+			}
+			if _, ok := uploaded[name]; !ok {
+				if _, seen := excluded[name]; !seen {
+					excluded[name] = matches[1]
+				}
+			}
+		}
+	}
+	var names = make([]string, 0, len(excluded))
+	for name := range excluded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vr.add(issueWarning, internal_app.CGIIgnore, "", fmt.Sprintf("%s (required by Makefile target %s) is excluded from upload", name, excluded[name]))
+	}
+	return nil
+}
+
+// aliases in manifest are legacy (migrated to links by server), but they still should be valid link names
+func (vr *validateResult) checkLegacyAliases(content []byte) {
+	var legacy struct {
+		Aliases types.JsonStringSet `json:"aliases"`
+	}
+	if types.IsYAML(vr.manifest) {
+		return // aliases were defined only in JSON manifests of old versions
+	}
+	if err := json.Unmarshal(content, &legacy); err != nil {
+		vr.add(issueError, vr.manifest, "aliases", fmt.Sprintf("parse aliases: %v", err))
+		return
+	}
+	if len(legacy.Aliases) == 0 {
+		return
+	}
+	var aliases = make([]string, 0, len(legacy.Aliases))
+	for alias := range legacy.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if !application.LinkNameReg.MatchString(alias) {
+			vr.add(issueError, vr.manifest, "aliases", fmt.Sprintf("invalid alias %q", alias))
+		}
+	}
+	vr.add(issueWarning, vr.manifest, "aliases", "aliases in manifest are legacy and migrated to links by the server, use cgi-ctl alias")
+}
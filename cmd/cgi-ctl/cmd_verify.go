@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/reddec/jsonrpc2"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+// server doesn't verify content: UploadVerified or SignedContentHash is not supported
+var errNoVerification = errors.New("server doesn't support content verification (upgrade trusted-cgi)")
+
+type verify struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *verify) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	proof, err := cmd.Lambdas().SignedContentHash(ctx, token, cmd.UID, nonce)
+	if err != nil {
+		return fmt.Errorf("get signed content hash: %w", verificationError(err))
+	}
+	if err := checkProof(proof, token, cmd.UID, nonce, ""); err != nil {
+		return err
+	}
+	result := verifyResult{UID: cmd.UID, Hash: proof.Hash}
+	remote, err := readRemote()
+	if err != nil {
+		return err
+	}
+	if remote != nil && remote.UID == cmd.UID {
+		result.Expected = remote.Content
+	}
+	result.Changed = result.Expected != "" && result.Expected != result.Hash
+	if globalOptions.JSON {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println(result.Hash)
+	}
+	switch {
+	case result.Expected == "":
+		log.Println("content hash is signed by server, verified upload is not recorded in control file")
+	case result.Changed:
+		return fmt.Errorf("content of lambda %s changed since the last verified upload: %s, expected %s", cmd.UID, result.Hash, result.Expected)
+	default:
+		log.Println("content matches the last verified upload")
+	}
+	return nil
+}
+
+// random nonce of signed content hash, so old replies could not be replayed
+func newNonce() (string, error) {
+	var data [16]byte
+	if _, err := rand.Read(data[:]); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	return hex.EncodeToString(data[:]), nil
+}
+
+// check signature and fields of proof. Empty archive hash - proof is not of upload
+func checkProof(proof *api.ContentProof, token *api.Token, uid, nonce, archive string) error {
+	if proof == nil || !proof.Verify(token) {
+		return errors.New("verification failed: invalid signature of content hash, reply is not from the server or modified in transit")
+	}
+	if proof.UID != uid || proof.Nonce != nonce {
+		return fmt.Errorf("verification failed: content hash is signed for lambda %s and nonce %s, expected %s and %s (replayed reply)", proof.UID, proof.Nonce, uid, nonce)
+	}
+	if proof.Archive != archive {
+		return fmt.Errorf("verification failed: server received archive %s, sent %s: archive is modified in transit, upload again by trusted link", proof.Archive, archive)
+	}
+	return nil
+}
+
+// errNoVerification if method is not supported by server
+func verificationError(err error) error {
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.MethodNotFound {
+		return errNoVerification
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Modes of corrupting proxy between cgi-ctl and server
+const (
+	proxyPass      = ""
+	proxyArchive   = "archive"   // replace uploaded archive by another valid archive
+	proxyReply     = "reply"     // replace content hash in reply of server
+	proxyDowngrade = "downgrade" // answer that verification is not supported
+)
+
+func TestUpload_verification(t *testing.T) {
+	state, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(state)
+	local, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(local)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	defer os.Chdir(wd) // upload changes dir to input
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance, err := startMock(ctx, state, "admin")
+	require.NoError(t, err)
+	defer instance.Stop()
+	backend := httptest.NewServer(instance.Handler())
+	defer backend.Close()
+	proxy := &corruptingProxy{t: t, backend: backend.URL}
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+	ctl := &mockCtl{t: t, remote: []string{"-u", front.URL, "-p", "admin", "--independent", "--ghost", "--no-token-cache", "--json"}}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(local, "hello.txt"), []byte("hello"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(local, ".cgiignore"), []byte(controlFilename+"\n"), 0644))
+	var cf controlFile
+	cf.SetRemote(defaultRemote, controlRemote{URL: front.URL, UID: fixtureHello})
+	require.NoError(t, cf.Save(filepath.Join(local, controlFilename)))
+	upload := func(args ...string) error {
+		defer os.Chdir(wd)
+		_, err := ctl.exec(append([]string{"upload", "--input", local}, args...)...)
+		return err
+	}
+	verify := func() (verifyResult, error) {
+		require.NoError(t, os.Chdir(local))
+		defer os.Chdir(wd)
+		var result verifyResult
+		out, err := ctl.exec("verify")
+		if err == nil {
+			require.NoError(t, json.Unmarshal(out, &result))
+		}
+		return result, err
+	}
+
+	// verified upload records content hash, spot check matches it
+	require.NoError(t, upload())
+	require.NoError(t, cf.Read(filepath.Join(local, controlFilename)))
+	recorded := cf.Remote(defaultRemote).Content
+	assert.NotEmpty(t, recorded)
+	result, err := verify()
+	require.NoError(t, err)
+	assert.Equal(t, verifyResult{UID: fixtureHello, Hash: recorded, Expected: recorded}, result)
+
+	// archive tampered in transit
+	proxy.set(proxyArchive)
+	err = upload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "archive is modified in transit")
+	content, err := ioutil.ReadFile(filepath.Join(state, fixtureHello, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "tampered", string(content), "proxy really replaced archive")
+
+	// forged reply
+	proxy.set(proxyReply)
+	err = upload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid signature")
+	_, err = verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid signature")
+
+	// server without verification: allowed unless required
+	proxy.set(proxyDowngrade)
+	err = upload("--require-verification")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errNoVerification)
+	assert.NoError(t, upload())
+
+	// content changed on server after the last verified upload
+	proxy.set(proxyPass)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(state, fixtureHello, "hello.txt"), []byte("changed"), 0644))
+	_, err = verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "changed since the last verified upload")
+}
+
+// proxy of JSON-RPC requests which corrupts requests or replies by mode
+type corruptingProxy struct {
+	t       *testing.T
+	backend string
+	lock    sync.Mutex
+	mode    string
+}
+
+func (cp *corruptingProxy) set(mode string) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.mode = mode
+}
+
+func (cp *corruptingProxy) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	cp.lock.Lock()
+	mode := cp.mode
+	cp.lock.Unlock()
+	body, err := ioutil.ReadAll(request.Body)
+	require.NoError(cp.t, err)
+	var call struct {
+		Version string        `json:"jsonrpc"`
+		Method  string        `json:"method"`
+		ID      interface{}   `json:"id"`
+		Params  []interface{} `json:"params"`
+	}
+	_ = json.Unmarshal(body, &call)
+	verified := call.Method == "LambdaAPI.UploadVerified" || call.Method == "LambdaAPI.SignedContentHash"
+	switch {
+	case mode == proxyDowngrade && verified:
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+			"jsonrpc": "2.0", "id": call.ID, "error": map[string]interface{}{"code": -32601, "message": "Method not found"},
+		})
+		return
+	case mode == proxyArchive && call.Method == "LambdaAPI.UploadVerified":
+		call.Params[2] = base64.StdEncoding.EncodeToString(tarGz(cp.t, map[string]string{"hello.txt": "tampered"}))
+		body, err = json.Marshal(call)
+		require.NoError(cp.t, err)
+	}
+	res, err := http.Post(cp.backend+request.URL.Path, request.Header.Get("Content-Type"), bytes.NewReader(body))
+	require.NoError(cp.t, err)
+	defer res.Body.Close()
+	reply, err := ioutil.ReadAll(res.Body)
+	require.NoError(cp.t, err)
+	if mode == proxyReply && verified {
+		var response map[string]interface{}
+		require.NoError(cp.t, json.Unmarshal(reply, &response))
+		if proof, ok := response["result"].(map[string]interface{}); ok {
+			proof["hash"] = strings.Repeat("0", 64)
+		}
+		reply, err = json.Marshal(response)
+		require.NoError(cp.t, err)
+	}
+	writer.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+	writer.WriteHeader(res.StatusCode)
+	_, _ = writer.Write(reply)
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := archive.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+	return buffer.Bytes()
+}
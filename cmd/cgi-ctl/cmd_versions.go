@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/units"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type versionsCmd struct {
+	List versionsList `command:"ls" description:"list versions of lambda from the newest"`
+}
+
+type versionsList struct {
+	remoteLink
+	uidLocator
+}
+
+func (cmd *versionsList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	list, err := cmd.Lambdas().Versions(ctx, token, cmd.UID)
+	if err != nil {
+		return fmt.Errorf("list versions: %w", err)
+	}
+	if globalOptions.JSON {
+		return printJSON(list)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "ID\tCREATED\tAUTHOR\tSIZE\tFILES\tCURRENT\tMESSAGE")
+	for _, version := range list {
+		var current string
+		if version.Current {
+			current = "*"
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", shortVersion(version.ID), version.Created.Format(time.RFC3339), version.Author, units.Base2Bytes(version.Size), version.Files, current, version.Message)
+	}
+	return out.Flush()
+}
+
+type rollbackCmd struct {
+	remoteLink
+	uidLocator
+	Action  string `long:"action" env:"ACTION" description:"build action run before files are replaced (default - build if defined by Makefile of version)"`
+	NoBuild bool   `long:"no-build" env:"NO_BUILD" description:"restore files without build action"`
+	Args    struct {
+		ID string `positional-arg-name:"id" description:"ID of version or its unique prefix (see versions ls)" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *rollbackCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if cmd.NoBuild && cmd.Action != "" {
+		return fmt.Errorf("--action could not be used with --no-build")
+	}
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("rolling back...")
+	report, err := cmd.Lambdas().Rollback(ctx, token, cmd.UID, application.VersionRollback{ID: cmd.Args.ID, Action: cmd.Action, NoBuild: cmd.NoBuild})
+	if err != nil {
+		return fmt.Errorf("rollback: %w", err)
+	}
+	if report.Action != "" {
+		log.Println("built by", report.Action)
+	}
+	if report.Warning != "" {
+		log.Println("[WARN]", report.Warning)
+	}
+	log.Println("files of", cmd.UID, "restored from version", report.Version.ID)
+	return printResult(report)
+}
+
+// prefix of version ID which is enough to refer the version
+func shortVersion(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type watch struct {
+	remoteLink
+	uidLocator
+	Input    string        `long:"input" env:"INPUT" description:"Directory" default:"."`
+	Debounce time.Duration `short:"d" long:"debounce" env:"DEBOUNCE" description:"wait for no changes before sync" default:"500ms"`
+	Run      []string      `short:"r" long:"run" env:"RUN" env-delim:"," description:"action to invoke after each successful sync (could be repeated)"`
+	Events   bool          `long:"events" env:"EVENTS" description:"emit newline-delimited JSON events to stdout (instead of sync lines, implied by --json)"`
+}
+
+func (cmd *watch) Execute([]string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	var events *internal.Events
+	var output io.Writer = os.Stdout
+	if cmd.Events || globalOptions.JSON {
+		events = internal.NewEvents(os.Stdout, "watch")
+		output = os.Stderr
+	}
+	if err := os.Chdir(cmd.Input); err != nil {
+		return fmt.Errorf("change dir: %w", err)
+	}
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watchTree(watcher, "."); err != nil {
+		return err
+	}
+	// local copy is expected to be uploaded before watch
+	state, err := watchSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("watching", cmd.UID, "-", len(state), "files")
+
+	var debounce = time.NewTimer(0)
+	<-debounce.C
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						log.Println("[WARN]", err)
+						events.Warning(cmd.UID, err.Error())
+					}
+				}
+			}
+			debounce.Reset(cmd.Debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("[WARN] watcher:", err)
+			events.Warning(cmd.UID, "watcher: "+err.Error())
+		case <-debounce.C:
+			next, err := watchSnapshot(ctx)
+			if err != nil {
+				log.Println("[ERROR]", err)
+				continue
+			}
+			changes := diffSnapshots(state, next)
+			if changes.empty() {
+				continue // only ignored files changed
+			}
+			events.Progress("change", cmd.UID, 0, 0)
+			events.Progress("upload", cmd.UID, 0, 0)
+			if err := cmd.sync(ctx, token, next, changes); err != nil {
+				_, _ = fmt.Fprintln(output, time.Now().Format(time.RFC3339), "sync failed:", err)
+				_ = events.Error(cmd.UID, err)
+				continue
+			}
+			state = next
+			_, _ = fmt.Fprintln(output, time.Now().Format(time.RFC3339), "synced", changes)
+			for _, action := range cmd.Run {
+				cmd.runAction(ctx, token, action, output)
+			}
+			events.Done(cmd.UID)
+		}
+	}
+}
+
+// upload only changed files, or full content if partial upload failed (ex: bundled lambda)
+func (cmd *watch) sync(ctx context.Context, token *api.Token, files watchFiles, changes *watchChanges) error {
+	if err := cmd.syncPartial(ctx, token, files, changes); err != nil {
+		log.Println("partial sync failed:", err, "- uploading all files")
+		tarball, err := archiveDir(ctx, true)
+		if err != nil {
+			return err
+		}
+		if err := cmd.checkUpload(ctx, token, tarball.Bytes()); err != nil {
+			return err
+		}
+		if _, err := cmd.Lambdas().Upload(ctx, token, cmd.UID, tarball.Bytes()); err != nil {
+			return fmt.Errorf("upload: %w", err)
+		}
+	}
+	if changes.manifest {
+		name, content := manifestOf(files)
+		return cmd.applyManifest(ctx, token, name, content)
+	}
+	return nil
+}
+
+func (cmd *watch) syncPartial(ctx context.Context, token *api.Token, files watchFiles, changes *watchChanges) error {
+	for _, dir := range changes.dirs {
+		if _, err := cmd.Lambdas().CreateFile(ctx, token, cmd.UID, dir, true); err != nil {
+			return fmt.Errorf("create dir %s: %w", dir, err)
+		}
+	}
+	for _, name := range changes.updated {
+		if _, err := cmd.Lambdas().Push(ctx, token, cmd.UID, name, files[name]); err != nil {
+			return fmt.Errorf("push %s: %w", name, err)
+		}
+	}
+	for _, name := range changes.removed {
+		if _, err := cmd.Lambdas().RemoveFile(ctx, token, cmd.UID, name); err != nil {
+			return fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (cmd *watch) applyManifest(ctx context.Context, token *api.Token, name string, content []byte) error {
+	if content == nil {
+		return nil
+	}
+	manifest, err := types.DecodeManifest(name, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("parse manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return fmt.Errorf("validate manifest: %w", err)
+	}
+	if _, err := cmd.Lambdas().Update(ctx, token, cmd.UID, manifest, false); err != nil {
+		return fmt.Errorf("apply manifest: %w", err)
+	}
+	return saveBase(manifest)
+}
+
+func (cmd *watch) runAction(ctx context.Context, token *api.Token, action string, output io.Writer) {
+	result, err := cmd.Lambdas().InvokeAction(ctx, token, cmd.UID, action, 0)
+	if err != nil {
+		_, _ = fmt.Fprintln(output, time.Now().Format(time.RFC3339), "action", action, "failed:", err)
+		return
+	}
+	_, _ = io.WriteString(output, result.Output)
+	if result.Error != "" {
+		_, _ = fmt.Fprintln(output, time.Now().Format(time.RFC3339), "action", action, "failed:", result.Error)
+		return
+	}
+	_, _ = fmt.Fprintln(output, time.Now().Format(time.RFC3339), "action", action, "finished in", time.Duration(result.Duration))
+}
+
+// content of files which will be uploaded (honoring .cgiignore) except control file
+type watchFiles map[string][]byte
+
+func watchSnapshot(ctx context.Context) (watchFiles, error) {
+	files, err := localFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	delete(files, controlFilename)
+	return files, nil
+}
+
+type watchChanges struct {
+	dirs     []string // new directories
+	updated  []string // new or modified files (except manifest)
+	removed  []string
+	manifest bool // manifest changed
+}
+
+func (wc *watchChanges) empty() bool {
+	return len(wc.updated) == 0 && len(wc.removed) == 0 && !wc.manifest
+}
+
+func (wc *watchChanges) String() string {
+	var parts []string
+	if len(wc.updated) > 0 {
+		parts = append(parts, fmt.Sprint(len(wc.updated), " updated (", strings.Join(wc.updated, ", "), ")"))
+	}
+	if len(wc.removed) > 0 {
+		parts = append(parts, fmt.Sprint(len(wc.removed), " removed (", strings.Join(wc.removed, ", "), ")"))
+	}
+	if wc.manifest {
+		parts = append(parts, "manifest applied")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func diffSnapshots(prev, next watchFiles) *watchChanges {
+	var ans watchChanges
+	var knownDirs = make(map[string]bool)
+	for name := range prev {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			knownDirs[dir] = true
+		}
+	}
+	for name, content := range next {
+		old, ok := prev[name]
+		if ok && bytes.Equal(old, content) {
+			continue
+		}
+		if internal_app.IsManifest(name) {
+			ans.manifest = true // applied separately with validation
+			continue
+		}
+		ans.updated = append(ans.updated, name)
+		for dir := path.Dir(name); dir != "." && !knownDirs[dir]; dir = path.Dir(dir) {
+			knownDirs[dir] = true
+			ans.dirs = append(ans.dirs, dir)
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			ans.removed = append(ans.removed, name)
+		}
+	}
+	sort.Strings(ans.dirs) // parents first
+	sort.Strings(ans.updated)
+	sort.Strings(ans.removed)
+	return &ans
+}
+
+// watch directory and all sub-directories
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+type webhooksCmd struct {
+	remoteLink
+	Limit  int    `short:"n" long:"limit" env:"LIMIT" description:"number of attempts (zero - all kept by server)" default:"50"`
+	Lambda string `long:"lambda" env:"LAMBDA" description:"only attempts of events of lambda by UID"`
+	Failed bool   `long:"failed" env:"FAILED" description:"only failed attempts and dropped events"`
+}
+
+func (cmd *webhooksCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	deliveries, err := cmd.Project().WebhookDeliveries(ctx, token, cmd.Limit)
+	if err != nil {
+		return fmt.Errorf("get webhook deliveries: %w", err)
+	}
+	var filtered = make([]application.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if cmd.Lambda != "" && delivery.Lambda != cmd.Lambda {
+			continue
+		}
+		if cmd.Failed && delivery.Error == "" {
+			continue
+		}
+		filtered = append(filtered, delivery)
+	}
+	if globalOptions.JSON {
+		return printJSON(filtered)
+	}
+	return printDeliveries(filtered)
+}
+
+func printDeliveries(deliveries []application.WebhookDelivery) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(out, "TIME\tEVENT\tLAMBDA\tURL\tATTEMPT\tSTATUS\tRETRY\tERROR")
+	for _, delivery := range deliveries {
+		attempt, status := "dropped", "-"
+		if delivery.Attempt > 0 {
+			attempt = strconv.Itoa(delivery.Attempt)
+		}
+		if delivery.Status > 0 {
+			status = strconv.Itoa(delivery.Status)
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", formatTime(delivery.Time), delivery.Type, dash(delivery.Lambda), delivery.URL, attempt, status, formatTime(delivery.Retry), dash(delivery.Error))
+	}
+	return out.Flush()
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/types"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Version of control file format (major.minor). Newer minor version of file is read with warning (unknown fields are
+// ignored), newer major version is refused. File without version is 1.0 and upgraded on the next write
+const controlVersion = "1.1"
+
+// JSON Schema of control file (see controlFile)
+//
+//go:embed control_file.schema.json
+var controlSchemaSource string
+
+var controlSchema = jsonschema.MustCompileString("cgictl:///control_file.schema.json", controlSchemaSource)
+
+// tracked lambda on the remote platform
+type controlRemote struct {
+	UID      string          `json:"uid,omitempty"`
+	URL      string          `json:"url"`
+	Revision string          `json:"revision,omitempty"` // hash of the last synchronized manifest
+	Base     *types.Manifest `json:"base,omitempty"`     // last synchronized manifest (base for three-way merge)
+	Content  string          `json:"content,omitempty"`  // content hash of lambda after the last verified upload (since 1.1)
+}
+
+// Control file keeps remotes by name. Legacy single-remote format (root fields) is read as the default remote and
+// always written as a copy of the default remote, so older versions of cgi-ctl still could use the file.
+type controlFile struct {
+	Version string `json:"version"` // format version (see controlVersion), empty - 1.0
+	controlRemote
+	Remotes map[string]*controlRemote `json:"remotes,omitempty"`
+}
+
+// Problem of control file found by schema validation
+type controlProblem struct {
+	Field   string // dotted path of invalid field, empty - the whole document
+	Message string
+}
+
+// Control file which could not be parsed or doesn't match the schema
+type controlFileError struct {
+	File     string
+	Problems []controlProblem // ordered by field
+}
+
+func (cfe *controlFileError) Error() string {
+	var lines = make([]string, 0, len(cfe.Problems))
+	for _, problem := range cfe.Problems {
+		line := cfe.File + ": "
+		if problem.Field != "" {
+			line += problem.Field + ": "
+		}
+		lines = append(lines, line+problem.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (dc *controlFile) Remote(name string) *controlRemote {
+	return dc.Remotes[name]
+}
+
+func (dc *controlFile) SetRemote(name string, remote controlRemote) {
+	if dc.Remotes == nil {
+		dc.Remotes = make(map[string]*controlRemote)
+	}
+	dc.Remotes[name] = &remote
+}
+
+// Save control file in the current format version
+func (dc *controlFile) Save(filename string) error {
+	dc.Version = controlVersion
+	dc.controlRemote = controlRemote{}
+	if remote := dc.Remote(defaultRemote); remote != nil {
+		dc.controlRemote = *remote
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dc)
+}
+
+// Read control file and validate it by schema. Missed file is reported as is (os.IsNotExist), invalid content - as
+// controlFileError
+func (dc *controlFile) Read(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	newer, err := dc.validate(filename, data)
+	if err != nil {
+		return err
+	}
+	if newer {
+		log.Println("[WARN]", filename, "has newer format version", dc.Version, "than supported", controlVersion+": unknown fields are ignored and will be dropped on the next write, upgrade cgi-ctl")
+	}
+	if err := json.Unmarshal(data, dc); err != nil {
+		return &controlFileError{File: filename, Problems: []controlProblem{{Message: err.Error()}}}
+	}
+	if dc.Remotes == nil && (dc.URL != "" || dc.UID != "") { // legacy format
+		dc.SetRemote(defaultRemote, dc.controlRemote)
+	}
+	return nil
+}
+
+// validate content by schema and check format version. Returns true if file has newer minor version
+func (dc *controlFile) validate(filename string, data []byte) (bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return false, &controlFileError{File: filename, Problems: []controlProblem{{Message: jsonSyntaxMessage(data, err)}}}
+	}
+	var newer bool
+	if root, ok := doc.(map[string]interface{}); ok {
+		if version, ok := root["version"].(string); ok {
+			dc.Version = version
+			var err error
+			newer, err = checkControlVersion(version)
+			if err != nil {
+				return false, &controlFileError{File: filename, Problems: []controlProblem{{Field: "version", Message: err.Error()}}}
+			}
+		}
+	}
+	err := controlSchema.Validate(doc)
+	if err == nil {
+		return newer, nil
+	}
+	var invalid *jsonschema.ValidationError
+	if !errors.As(err, &invalid) {
+		return false, &controlFileError{File: filename, Problems: []controlProblem{{Message: err.Error()}}}
+	}
+	var problems []controlProblem
+	var leaves func(ve *jsonschema.ValidationError)
+	leaves = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) > 0 {
+			for _, cause := range ve.Causes {
+				leaves(cause)
+			}
+			return
+		}
+		if newer && strings.HasSuffix(ve.KeywordLocation, "/additionalProperties") {
+			return // fields of newer version
+		}
+		problems = append(problems, controlProblem{Field: dottedPath(ve.InstanceLocation), Message: ve.Message})
+	}
+	leaves(invalid)
+	if len(problems) == 0 {
+		return newer, nil
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Field < problems[j].Field
+	})
+	return false, &controlFileError{File: filename, Problems: problems}
+}
+
+// check format version of control file. Returns true if minor version is newer than supported, fails on newer major
+// version
+func checkControlVersion(version string) (bool, error) {
+	major, minor, err := parseControlVersion(version)
+	if err != nil {
+		return false, err
+	}
+	currentMajor, currentMinor, _ := parseControlVersion(controlVersion)
+	if major > currentMajor {
+		return false, fmt.Errorf("format version %s is not supported (supported %s): upgrade cgi-ctl", version, controlVersion)
+	}
+	return major == currentMajor && minor > currentMinor, nil
+}
+
+func parseControlVersion(version string) (int, int, error) {
+	majorText, minorText, ok := strings.Cut(version, ".")
+	major, errMajor := strconv.Atoi(majorText)
+	minor, errMinor := strconv.Atoi(minorText)
+	if !ok || errMajor != nil || errMinor != nil || major < 0 || minor < 0 {
+		return 0, 0, fmt.Errorf("invalid format version %q: should be major.minor", version)
+	}
+	return major, minor, nil
+}
+
+// JSON pointer as dotted path: /remotes/prod/url -> remotes.prod.url
+func dottedPath(pointer string) string {
+	if pointer == "" {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+	}
+	return strings.Join(parts, ".")
+}
+
+// error of JSON decoding with line and column of syntax error
+func jsonSyntaxMessage(data []byte, err error) string {
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) {
+		return "invalid JSON: " + err.Error()
+	}
+	before := data[:min(max(int(syntax.Offset)-1, 0), len(data))] // offset is after the invalid character
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("invalid JSON at line %d, column %d: %v", line, column, err)
+}
+
+// update remote selected by --remote flag in the local control file (if exists and remote is defined)
+func updateRemote(update func(remote *controlRemote)) error {
+	var cf controlFile
+	if err := cf.Read(controlFilename); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read control file: %w", err)
+	}
+	remote := cf.Remote(globalOptions.Remote)
+	if remote == nil {
+		return nil
+	}
+	update(remote)
+	if err := cf.Save(controlFilename); err != nil {
+		return fmt.Errorf("save control file: %w", err)
+	}
+	return nil
+}
+
+// remote selected by --remote flag from the local control file. Returns nil if there is no control file or if the
+// default remote is not defined, fails if other remote is selected but not defined.
+func readRemote() (*controlRemote, error) {
+	var cf controlFile
+	if err := cf.Read(controlFilename); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("parse control file: %w", err)
+	}
+	remote := cf.Remote(globalOptions.Remote)
+	if remote == nil && globalOptions.Remote != defaultRemote {
+		return nil, fmt.Errorf("remote %s is not defined in control file", globalOptions.Remote)
+	}
+	return remote, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlFile_Read(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	read := func(content string) (controlFile, error) {
+		filename := filepath.Join(tmpDir, controlFilename)
+		require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+		var cf controlFile
+		return cf, cf.Read(filename)
+	}
+	problems := func(t *testing.T, err error) []controlProblem {
+		var invalid *controlFileError
+		require.True(t, errors.As(err, &invalid), "unexpected error: %v", err)
+		return invalid.Problems
+	}
+
+	t.Run("legacy file without version is upgraded on write", func(t *testing.T) {
+		cf, err := read(`{"uid": "a1b2", "url": "http://127.0.0.1:3434/"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "", cf.Version)
+		require.NotNil(t, cf.Remote(defaultRemote))
+		assert.Equal(t, "a1b2", cf.Remote(defaultRemote).UID)
+
+		filename := filepath.Join(tmpDir, "saved.json")
+		require.NoError(t, cf.Save(filename))
+		var saved controlFile
+		require.NoError(t, saved.Read(filename))
+		assert.Equal(t, controlVersion, saved.Version)
+		assert.Equal(t, "http://127.0.0.1:3434/", saved.Remote(defaultRemote).URL)
+	})
+
+	t.Run("wrong types and unknown fields are reported by field", func(t *testing.T) {
+		_, err := read(`{"version": "1.0", "remotes": {"prod": {"url": 8080, "token": "x"}}, "uid": true}`)
+		require.Error(t, err)
+		assert.Equal(t, []controlProblem{
+			{Field: "remotes.prod", Message: "additionalProperties 'token' not allowed"},
+			{Field: "remotes.prod.url", Message: "expected string, but got number"},
+			{Field: "uid", Message: "expected string, but got boolean"},
+		}, problems(t, err))
+		assert.Contains(t, err.Error(), controlFilename+": remotes.prod.url: expected string, but got number")
+	})
+
+	t.Run("syntax error has position", func(t *testing.T) {
+		_, err := read("{\n  \"url\": \"http://127.0.0.1:3434/\",\n}")
+		require.Error(t, err)
+		list := problems(t, err)
+		require.Len(t, list, 1)
+		assert.Contains(t, list[0].Message, "line 3, column 1")
+	})
+
+	t.Run("newer minor version is read without unknown fields", func(t *testing.T) {
+		cf, err := read(`{"version": "1.7", "remotes": {"origin": {"url": "http://127.0.0.1:3434/", "labels": ["prod"]}}, "hooks": {}}`)
+		require.NoError(t, err)
+		assert.Equal(t, "1.7", cf.Version)
+		assert.Equal(t, "http://127.0.0.1:3434/", cf.Remote(defaultRemote).URL)
+	})
+
+	t.Run("newer minor version still checks types", func(t *testing.T) {
+		_, err := read(`{"version": "1.7", "url": 1}`)
+		require.Error(t, err)
+		assert.Equal(t, []controlProblem{{Field: "url", Message: "expected string, but got number"}}, problems(t, err))
+	})
+
+	t.Run("newer major version is refused", func(t *testing.T) {
+		_, err := read(`{"version": "2.0", "url": "http://127.0.0.1:3434/"}`)
+		require.Error(t, err)
+		list := problems(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "version", list[0].Field)
+		assert.Contains(t, list[0].Message, "upgrade cgi-ctl")
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		_, err := read(`{"version": "one"}`)
+		require.Error(t, err)
+		assert.Equal(t, "version", problems(t, err)[0].Field)
+	})
+
+	t.Run("missed file", func(t *testing.T) {
+		var cf controlFile
+		err := cf.Read(filepath.Join(tmpDir, "missed.json"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/api/client"
+	"github.com/reddec/trusted-cgi/application"
+	"golang.org/x/crypto/ssh/terminal"
 	"io"
 	"log"
 	"net/url"
@@ -18,15 +20,28 @@ import (
 const (
 	configSection   = "trusted-cgi-ctl"
 	controlFilename = ".cgictl.json"
+	defaultRemote   = "origin"
+	defaultLogin    = "admin"
+	passwordEnv     = "CGI_CTL_PASSWORD"
 )
 
+// options which are applicable for all commands
+var globalOptions struct {
+	Remote string `long:"remote" env:"REMOTE" description:"Name of remote from control file" default:"origin"`
+	JSON   bool   `long:"json" env:"JSON" description:"Machine-readable output: JSON document (or JSON lines) to stdout, errors as JSON"`
+}
+
 type remoteLink struct {
-	Login       string `short:"l" long:"login" env:"LOGIN" description:"Login name" default:"admin"`
-	Password    string `short:"p" long:"password" env:"PASSWORD" description:"Password" default:"admin"`
-	AskPass     bool   `short:"P" long:"ask-pass" env:"ASK_PASS" description:"Get password from stdin"`
-	URL         string `short:"u" long:"url" env:"URL" description:"Trusted-CGI endpoint" default:"http://127.0.0.1:3434/"`
-	Ghost       bool   `long:"ghost" env:"GHOST" description:"Disable save credentials to user config dir"`
-	Independent bool   `long:"independent" env:"INDEPENDENT" description:"Disable read credentials from user config dir"`
+	Login        string        `short:"l" long:"login" env:"CGI_CTL_LOGIN" description:"Login name (empty - saved login or admin)"`
+	Password     string        `short:"p" long:"password" env:"CGI_CTL_PASSWORD" description:"Password (empty - from OS keyring, saved credentials or prompt)"`
+	AskPass      bool          `short:"P" long:"ask-pass" env:"ASK_PASS" description:"Always ask password from terminal"`
+	URL          string        `short:"u" long:"url" env:"CGI_CTL_URL" description:"Trusted-CGI endpoint: URL or unix:/path/to/socket" default:"http://127.0.0.1:3434/"`
+	Ghost        bool          `long:"ghost" env:"GHOST" description:"Disable save credentials to user config dir"`
+	Independent  bool          `long:"independent" env:"INDEPENDENT" description:"Disable read credentials from user config dir and OS keyring"`
+	NoTokenCache bool          `long:"no-token-cache" env:"NO_TOKEN_CACHE" description:"Disable use of cached login token (always login)"`
+	APIToken     string        `long:"token" env:"CGI_CTL_TOKEN" description:"API token (see token create) instead of login and password"`
+	saved        *domainConfig // saved credentials (see resolve)
+	fixed        bool          // URL is chosen by command: URL of control file is not used
 }
 
 func (rl *remoteLink) Users() *client.UserAPIClient {
@@ -41,68 +56,180 @@ func (rl *remoteLink) Project() *client.ProjectAPIClient {
 	return &client.ProjectAPIClient{BaseURL: urlJoin(rl.URL, "u", "")}
 }
 
+func (rl *remoteLink) Queues() *client.QueuesAPIClient {
+	return &client.QueuesAPIClient{BaseURL: urlJoin(rl.URL, "u", "")}
+}
+
+// find lambda by UID or alias. Fails if nothing found (with exitNotFound code) or if name matches several lambdas
+func (rl *remoteLink) FindLambda(ctx context.Context, token *api.Token, name string) (*application.Definition, error) {
+	list, err := rl.Project().List(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("list lambdas: %w", err)
+	}
+	var found []application.Definition
+	for _, def := range list {
+		if def.UID == name || def.Aliases.Has(name) {
+			found = append(found, def)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, &exitError{code: exitNotFound, err: fmt.Errorf("lambda or alias %s not found", name)}
+	case 1:
+		return &found[0], nil
+	}
+	var uids = make([]string, 0, len(found))
+	for _, def := range found {
+		uids = append(uids, def.UID)
+	}
+	return nil, fmt.Errorf("%s is ambiguous - matches lambdas %s", name, strings.Join(uids, ", "))
+}
+
+// Aliases bound to lambdas other than the lambda with UID
+func (rl *remoteLink) AliasConflicts(ctx context.Context, token *api.Token, uid string, aliases []string) ([]application.AliasConflict, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	list, err := rl.Project().List(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("list lambdas: %w", err)
+	}
+	var conflicts []application.AliasConflict
+	for _, alias := range aliases {
+		for _, def := range list {
+			if def.UID != uid && def.Aliases.Has(alias) {
+				conflicts = append(conflicts, application.AliasConflict{Alias: alias, UID: def.UID, Name: def.Manifest.Name})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// Login token. Cached token is used (if not expired), otherwise login is performed and the token is cached.
+// Cached token rejected by server is replaced automatically in the first failed request
 func (rl *remoteLink) Token(ctx context.Context) (*api.Token, error) {
+	if err := rl.resolve(); err != nil {
+		return nil, err
+	}
+	if rl.APIToken != "" {
+		return &api.Token{Data: rl.APIToken}, nil
+	}
+	if token := rl.cachedToken(); token != nil {
+		rl.useCachedToken(token)
+		return token, nil
+	}
+	return rl.login(ctx)
+}
+
+// read URL from control file and saved credentials from user config dir (if not independent)
+func (rl *remoteLink) resolve() error {
 	if !rl.Independent {
-		var cf controlFile
 		// check local control file for URL
-		if err := cf.Read(controlFilename); err == nil {
-			rl.URL = cf.URL
+		remote, err := readRemote()
+		if err != nil {
+			return err
+		}
+		if remote != nil && !rl.fixed {
+			rl.URL = remote.URL
 		}
 
 		cfg, err := rl.readConfig()
 		if err != nil && !os.IsNotExist(err) {
 			log.Println("failed read config:", err)
-			return nil, err
+			return err
 		} else if err == nil {
-			rl.Password = string(cfg.Password)
-			rl.Login = cfg.Login
-			rl.AskPass = false
+			rl.saved = cfg
+			if rl.Login == "" {
+				rl.Login = cfg.Login
+			}
 		}
 	}
+	if rl.Login == "" {
+		rl.Login = defaultLogin
+	}
+	return nil
+}
+
+// Resolve password by precedence: flag, environment, OS keyring, saved credentials, prompt (only if stdin is
+// terminal). Returns true if password should be saved to user config dir (entered or given by flag)
+func (rl *remoteLink) resolvePassword() (bool, error) {
 	if rl.AskPass {
-		_, _ = fmt.Fprintf(os.Stderr, "Enter Password: ")
-		bytePassword, err := AskPass()
-		if err != nil {
-			return nil, err
+		return true, rl.askPassword()
+	}
+	if rl.Password != "" {
+		return os.Getenv(passwordEnv) != rl.Password, nil
+	}
+	if !rl.Independent {
+		if password, err := keyringGet(tokenCacheKey(rl.URL, rl.Login)); err == nil {
+			rl.Password = password
+			return false, nil
 		}
-		rl.Password = strings.TrimSpace(string(bytePassword))
-		_, _ = fmt.Fprintln(os.Stderr)
+	}
+	if rl.saved != nil && rl.saved.Login == rl.Login {
+		rl.Password = string(rl.saved.Password)
+		return true, nil
+	}
+	return true, rl.askPassword()
+}
+
+func (rl *remoteLink) askPassword() error {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("password for %s is required, but stdin is not a terminal: use %s environment variable, --password flag or save password by login --save-keyring", rl.Login, passwordEnv)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Enter Password: ")
+	bytePassword, err := AskPass()
+	if err != nil {
+		return err
+	}
+	rl.Password = strings.TrimSpace(string(bytePassword))
+	_, _ = fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+func (rl *remoteLink) login(ctx context.Context) (*api.Token, error) {
+	save, err := rl.resolvePassword()
+	if err != nil {
+		return nil, err
 	}
 	token, err := rl.Users().Login(ctx, rl.Login, rl.Password)
 	if err != nil {
 		return nil, err
 	}
-	if !rl.Ghost {
+	if save && !rl.Ghost {
 		err = rl.writeConfig()
 	}
+	rl.cacheToken(token)
 	return token, err
 }
 
-func (rl *remoteLink) readConfig() (*domainConfig, error) {
+// file of saved credentials for host of URL in user config dir
+func (rl *remoteLink) configLocation() (string, error) {
 	cfg, err := os.UserConfigDir()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	info, err := url.Parse(rl.URL)
+	info, err := url.Parse(serverURL(rl.URL))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	filename := strings.ReplaceAll(info.Host, ":", "_")
-	var dc domainConfig
-	return &dc, dc.Read(filepath.Join(cfg, configSection, filename))
+	return filepath.Join(cfg, configSection, filename), nil
 }
 
-func (rl *remoteLink) writeConfig() error {
-	cfg, err := os.UserConfigDir()
+func (rl *remoteLink) readConfig() (*domainConfig, error) {
+	location, err := rl.configLocation()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	info, err := url.Parse(rl.URL)
+	var dc domainConfig
+	return &dc, dc.Read(location)
+}
+
+func (rl *remoteLink) writeConfig() error {
+	tp, err := rl.configLocation()
 	if err != nil {
 		return err
 	}
-	filename := strings.ReplaceAll(info.Host, ":", "_")
-	tp := filepath.Join(cfg, configSection, filename)
 	dc := &domainConfig{
 		Login:    rl.Login,
 		Password: []byte(rl.Password),
@@ -139,31 +266,6 @@ func (dc *domainConfig) Read(filename string) error {
 	return json.NewDecoder(f).Decode(dc)
 }
 
-type controlFile struct {
-	UID string `json:"uid,omitempty"`
-	URL string `json:"url"`
-}
-
-func (dc *controlFile) Save(filename string) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	return enc.Encode(dc)
-}
-
-func (dc *controlFile) Read(filename string) error {
-	f, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return json.NewDecoder(f).Decode(dc)
-}
-
 func appendIfNoLine(writer io.ReadWriter, line string) error {
 	scanner := bufio.NewScanner(writer)
 	for scanner.Scan() {
@@ -185,6 +287,7 @@ func appendIfNoLineFile(filename string, line string) error {
 }
 
 func urlJoin(base string, path ...string) string {
+	base = serverURL(base)
 	if len(path) == 0 {
 		return base
 	}
@@ -202,12 +305,13 @@ func (ul *uidLocator) parseUID() error {
 	if ul.UID != "" {
 		return nil
 	}
-	var cf controlFile
-	if err := cf.Read(controlFilename); err == nil && cf.UID != "" {
-		ul.UID = cf.UID
+	remote, err := readRemote()
+	if err != nil {
+		return err
+	}
+	if remote != nil && remote.UID != "" {
+		ul.UID = remote.UID
 		return nil
-	} else if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("parse control file: %w", err)
 	}
 	wd, err := os.Getwd()
 	if err != nil {
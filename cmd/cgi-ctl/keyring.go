@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// service name of passwords in OS keyring, account is the same as key of token cache (login@url)
+const keyringService = "cgi-ctl"
+
+var errKeyringNotFound = errors.New("password not found in keyring")
+
+// OS keyring is used by command line tools of the platform: secret-tool (libsecret) on Linux and BSD,
+// security on macOS. Password is never passed by command line arguments. Any error of lookup means that
+// password is not available.
+func keyringGet(account string) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := keyringRun("", "security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(out, "\n"), nil
+	case "windows":
+		return "", errKeyringNotFound
+	}
+	out, err := keyringRun("", "secret-tool", "lookup", "service", keyringService, "account", account)
+	if err != nil {
+		return "", err
+	}
+	if out == "" {
+		return "", errKeyringNotFound
+	}
+	return out, nil
+}
+
+func keyringSet(account, password string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		// interactive mode reads command from stdin, password is hex encoded to avoid quoting
+		command := "add-generic-password -U -s " + strconv.Quote(keyringService) + " -a " + strconv.Quote(account) + " -X " + hex.EncodeToString([]byte(password)) + "\n"
+		_, err := keyringRun(command, "security", "-i")
+		return err
+	case "windows":
+		return fmt.Errorf("OS keyring is not supported on %s", runtime.GOOS)
+	}
+	_, err := keyringRun(password, "secret-tool", "store", "--label", "cgi-ctl "+account, "service", keyringService, "account", account)
+	return err
+}
+
+func keyringDelete(account string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := keyringRun("", "security", "delete-generic-password", "-s", keyringService, "-a", account)
+		return err
+	case "windows":
+		return nil
+	}
+	_, err := keyringRun("", "secret-tool", "clear", "service", keyringService, "account", account)
+	return err
+}
+
+// run keyring tool and return its output
+func keyringRun(input string, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("OS keyring is not available: %s not found", name)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
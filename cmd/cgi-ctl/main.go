@@ -9,29 +9,85 @@ import (
 const version = "dev"
 
 type Config struct {
-	Init struct {
-		Bare Bare `command:"bare" description:"create bare template"`
-	} `command:"init" description:"initialize function in a current directory"`
+	Init     initCmd  `command:"init" subcommands-optional:"yes" description:"initialize function in a directory from embedded template without server" long-description:"Initialize function in the directory (the only argument, default - current directory) from embedded template or with minimal manifest. Server is not required, control file is created by create."`
 	Download download `command:"download" description:"download lambda content to the local tarball or stdout"`
 	Upload   upload   `command:"upload" description:"upload content to lambda to the remote platform"`
+	Deploy   deploy   `command:"deploy" description:"deploy lambdas of workspace (subdirectories of one repository): create missing, upload content, apply manifests and run post-deploy actions"`
 	Clone    clone    `command:"clone" description:"clone lambda to local FS and keep URL for future tracking"`
+	Link     link     `command:"link" description:"link current directory to the existing lambda (by UID or alias) without downloading content"`
 	Do       do       `command:"do" description:"invoke actions (without actions it will print all available actions)"`
 	Create   create   `command:"create" description:"create new lambda on the remote platform and initialize local environment"`
-	Alias    alias    `command:"alias" description:"list, created or remove alias for the lambda"`
+	Alias    alias    `command:"alias" description:"list, add or remove aliases for the lambda"`
+	List     list     `command:"ls" description:"list lambdas on the remote platform"`
+	Search   search   `command:"search" description:"search lambdas on the remote platform by words of names, descriptions, aliases and labels"`
+	Remove   remove   `command:"rm" description:"remove lambda from the remote platform (by UID or alias)"`
+	Enable   enable   `command:"enable" description:"enable disabled lambda (by UID or alias): resume schedules and release held queued requests"`
+	Disable  disable  `command:"disable" description:"take lambda (by UID or alias) offline without removing: public requests get 503, schedules are paused, queued requests are held"`
+	Describe describe `command:"describe" description:"show lambda details with live status of schedules, queues and alerts"`
+	Diff     diff     `command:"diff" description:"compare local directory with the deployed lambda (exit code 1 if differs)"`
+	Watch    watch    `command:"watch" description:"watch local directory and upload changed files automatically"`
+	Remote   remote   `command:"remote" description:"list, add or remove named remotes of the local lambda"`
+	Login    login    `command:"login" description:"login to the remote platform, optionally save password to OS keyring"`
+	Logout   logout   `command:"logout" description:"remove cached login token and password saved in OS keyring"`
+	Token    tokenCmd `command:"token" description:"create, list or revoke API tokens limited to lambdas and operations (ex: for CI)"`
 	Invoke   invoke   `command:"invoke" description:"invoke remote lambda"`
 	Update   struct {
 		Manifest updateManifest `command:"manifest" description:"pull and save remote manifest file"`
 	} `command:"update" description:"update parts of the lambda"`
-	Apply apply `command:"apply" description:"push manifest to the remote platform"`
+	Apply    apply       `command:"apply" description:"push manifest to the remote platform"`
+	Doctor   doctor      `command:"doctor" description:"show effective runtime settings (umask, locale, timezone) of the lambda"`
+	Schedule schedule    `command:"schedule" description:"list, add, remove or apply scheduled actions (cron)"`
+	Env      env         `command:"env" description:"list, set, unset or load environment variables of the lambda"`
+	Global   globalEnv   `command:"global-env" description:"list, set or unset global environment variables of the server (lambda variables win on conflict)"`
+	Secret   secretCmd   `command:"secret" description:"list, set or remove secrets of the server referenced by environment as @secret:<name> (values are write-only)"`
+	Lockout  lockoutCmd  `command:"lockout" description:"list or clear lockouts of logins and client addresses after failed logins"`
+	Auth     basicAuth   `command:"basic-auth" description:"list, set or remove users of basic auth of the lambda (passwords are stored hashed)"`
+	Logs     logs        `command:"logs" description:"show recent invocation records of the lambda"`
+	Stats    statsCmd    `command:"stats" subcommands-optional:"yes" description:"show invocation metrics (duration, errors) of the lambda or of all lambdas"`
+	Capacity capacityCmd `command:"capacity" description:"show capacity report of the server: usage of lambdas, disk usage, queue backlogs and headroom"`
+	Changes  changesCmd  `command:"changes" description:"show administrative changes (deploys, manifests, aliases, policies, users, settings) in time range grouped by lambda"`
+	Audit    auditCmd    `command:"audit" description:"show audit log of the server: changes and failed authentications in order of recording, filtered by lambda and kind"`
+	Security securityCmd `command:"security" description:"show security profile of the server and lambdas which deviate from its defaults"`
+	Proxy    proxyConfig `command:"proxy-config" description:"generate reverse-proxy configuration (nginx, traefik or caddy) by routes of lambdas"`
+	OpenAPI  openAPI     `command:"openapi" description:"generate OpenAPI 3.1 document of public routes of lambdas (methods, content types, input schemas, output headers)"`
+	Run      run         `command:"run" description:"run lambda from the current directory locally without server" long-description:"Run lambda from the current directory the same way as the server does: the same environment variables, time limit and payload limit. The lambda is executed under the current user (credentials of the project settings are not applied), the global environment should be passed by --env flag, policies, queues and stats are not available."`
+	Validate validate    `command:"validate" description:"check manifest and files of the local copy without server (non-zero exit code on errors)"`
+	Alerts   alerts      `command:"alerts" description:"show or reset alert rules (error rate, failures, latency) of the lambda"`
+	Queue    queueCmd    `command:"queue" description:"manage dead letters of queues linked to the lambda"`
+	Requests requestsCmd `command:"requests" description:"list, replay or save requests captured by the server (see capture in manifest)"`
+	Mirror   mirrorCmd   `command:"mirror" description:"show status of read-only mirror or promote it to primary"`
+	Webhooks webhooksCmd `command:"webhooks" description:"show recent deliveries of lifecycle events to webhooks of the server and lambdas"`
+	Backup   backupCmd   `command:"backup" description:"download backup of the whole server as single archive (lambdas, policies, queues, tokens, sealed secrets, settings)"`
+	Restore  restoreCmd  `command:"restore" description:"restore server from backup archive: items which do not exist are created"`
+	Reload   reloadCmd   `command:"reload" description:"apply manifests, project config, security profile and code of lambdas edited on disk of the server"`
+	Git      gitDeploy   `command:"git-deploy" description:"fetch tracked git branch of the lambda and deploy new commit, or show status of deploys"`
+	Import   importCmd   `command:"import" description:"import lambda from .tar.gz or .zip archive by URL (ex: release artifact): new lambda or content of existent one"`
+	Cp       cp          `command:"cp" description:"duplicate lambda on the server: files, manifest and environment are copied to new lambda"`
+	Bulk     bulkSet     `command:"bulk-set" description:"apply partial change of manifest (merge patch or field=value) to many lambdas: by UIDs, label or all"`
+	Frozen   frozenCmd   `command:"frozen" description:"freeze built lambda to versioned artifact, list, download or restore artifacts without build and network"`
+	Versions versionsCmd `command:"versions" description:"list versions of lambda (files and manifest) taken by uploads and updates of manifest"`
+	Rollback rollbackCmd `command:"rollback" description:"restore files and manifest of lambda from version and run build action before files are replaced"`
+	Verify   verify      `command:"verify" description:"check content hash of lambda signed by server against the last verified upload"`
+	Transfer transfer    `command:"transfer" description:"transfer lambda from one server to another directly (destination pulls content from source)"`
+	Config   configCmd   `command:"config" description:"check configuration of cgi-ctl (control file, saved credentials, cached token, connectivity)"`
+	Mock     mockServer  `command:"mock-server" description:"serve admin API and lambdas from local fixture state for offline development" long-description:"Serve admin API and lambdas from the state directory by the same handlers as the real server. Mutations (manifests, aliases, uploads, settings) are persisted to the state directory. Empty state directory is initialized by the starter fixture. SSH and scheduler are disabled."`
 }
 
 func main() {
 	var config Config
 	log.SetOutput(os.Stderr)
-	parser := flags.NewParser(&config, flags.Default)
-	parser.LongDescription = "Easy CGI-like server for development (helper tool)\nAuthor: Baryshnikov Aleksandr <dev@baryshnikov.net>\nVersion: " + version
-	_, err := parser.Parse()
-	if err != nil {
-		os.Exit(1)
+	useSockets()
+	if _, err := newParser(&config).Parse(); err != nil {
+		os.Exit(reportError(err))
 	}
 }
+
+func newParser(config *Config) *flags.Parser {
+	// errors are printed by reportError (as JSON document in JSON mode)
+	parser := flags.NewParser(config, flags.Default&^flags.PrintErrors)
+	if _, err := parser.AddGroup("Global options", "", &globalOptions); err != nil {
+		panic(err)
+	}
+	parser.LongDescription = "Easy CGI-like server for development (helper tool)\nAuthor: Baryshnikov Aleksandr <dev@baryshnikov.net>\nVersion: " + version
+	return parser
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	internal_app "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Formats of local manifest file
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// format of written local manifest file
+type manifestFormat struct {
+	Format string `long:"format" env:"MANIFEST_FORMAT" description:"Format of local manifest file (default - format of existing file or json)" choice:"json" choice:"yaml"`
+}
+
+// save manifest to the directory in selected format (default - format of existing file). File in other format is
+// removed, so the directory keeps only one manifest
+func (mf manifestFormat) save(dir string, manifest types.Manifest) error {
+	var file string
+	switch mf.Format {
+	case formatJSON:
+		file = filepath.Join(dir, internal_app.ManifestFile)
+	case formatYAML:
+		file = filepath.Join(dir, internal_app.ManifestYAML)
+	default:
+		found, err := internal_app.FindManifest(dir)
+		if err != nil {
+			return err
+		}
+		file = found
+	}
+	if err := manifest.SaveAs(file); err != nil {
+		return err
+	}
+	for _, name := range []string{internal_app.ManifestFile, internal_app.ManifestYAML} {
+		other := filepath.Join(dir, name)
+		if other == file {
+			continue
+		}
+		if err := os.Remove(other); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// local manifest file in the current directory: manifest.yaml if exists, otherwise manifest.json
+func localManifestFile() (string, error) {
+	return internal_app.FindManifest(".")
+}
+
+// read local manifest in any format
+func loadLocalManifest(manifest *types.Manifest) error {
+	file, err := localManifestFile()
+	if err != nil {
+		return err
+	}
+	return manifest.LoadFrom(file)
+}
+
+// save local manifest in format of existing file
+func saveLocalManifest(manifest types.Manifest) error {
+	return manifestFormat{}.save(".", manifest)
+}
+
+// name and content of manifest from files of lambda (content is nil if manifest not exists)
+func manifestOf(files map[string][]byte) (string, []byte) {
+	if content, ok := files[internal_app.ManifestYAML]; ok {
+		return internal_app.ManifestYAML, content
+	}
+	return internal_app.ManifestFile, files[internal_app.ManifestFile]
+}
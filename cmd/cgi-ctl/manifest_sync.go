@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/types"
+	"log"
+)
+
+type manifestSync struct {
+	Ours         bool `long:"ours" env:"OURS" description:"on manifest conflict keep local values"`
+	Theirs       bool `long:"theirs" env:"THEIRS" description:"on manifest conflict keep remote values"`
+	ForceAliases bool `long:"force-aliases" env:"FORCE_ALIASES" description:"move aliases declared in manifest from other lambdas"`
+}
+
+// reconcile local manifest with remote manifest which could be changed since the last synchronization
+func (ms *manifestSync) reconcile(local, remote types.Manifest) (types.Manifest, error) {
+	if ms.Ours && ms.Theirs {
+		return local, fmt.Errorf("--ours and --theirs are mutually exclusive")
+	}
+	cf, err := readRemote()
+	if err != nil || cf == nil || cf.Revision == "" {
+		// nothing tracked yet
+		return local, nil
+	}
+	remoteHash, err := remote.Hash()
+	if err != nil {
+		return local, fmt.Errorf("hash remote manifest: %w", err)
+	}
+	localHash, err := local.Hash()
+	if err != nil {
+		return local, fmt.Errorf("hash local manifest: %w", err)
+	}
+	if remoteHash == cf.Revision || remoteHash == localHash {
+		return local, nil
+	}
+	if localHash == cf.Revision {
+		log.Println("remote manifest changed since last sync, local manifest is not changed - using remote")
+		return remote, nil
+	}
+	log.Println("both remote and local manifests changed since last sync")
+	if cf.Base == nil {
+		switch {
+		case ms.Ours:
+			return local, nil
+		case ms.Theirs:
+			return remote, nil
+		}
+		return local, fmt.Errorf("manifests diverged and base manifest is unknown: resolve manually or use --ours/--theirs")
+	}
+	first, second := local, remote
+	if ms.Theirs {
+		first, second = remote, local
+	}
+	merged, conflicts, err := types.MergeManifest(*cf.Base, first, second)
+	if err != nil {
+		return local, fmt.Errorf("merge manifests: %w", err)
+	}
+	if len(conflicts) > 0 && !ms.Ours && !ms.Theirs {
+		for _, conflict := range conflicts {
+			log.Println("conflict", conflict)
+		}
+		return local, fmt.Errorf("manifest conflicts in %d field(s): use --theirs to prefer remote values or --ours to prefer local values (after manual resolution)", len(conflicts))
+	}
+	log.Println("remote changes merged into local manifest")
+	return merged, nil
+}
+
+// remember manifest as the last synchronized state (base for three-way merge) of the selected remote in the
+// control file (if exists)
+func saveBase(manifest types.Manifest) error {
+	hash, err := manifest.Hash()
+	if err != nil {
+		return fmt.Errorf("hash manifest: %w", err)
+	}
+	return updateRemote(func(remote *controlRemote) {
+		remote.Revision = hash
+		remote.Base = &manifest
+	})
+}
+
+// reconcile local manifest with remote and save result to the local manifest file if it was changed
+func (ms *manifestSync) syncLocal(local, remote types.Manifest) (types.Manifest, error) {
+	result, err := ms.reconcile(local, remote)
+	if err != nil {
+		return local, err
+	}
+	resultHash, err := result.Hash()
+	if err != nil {
+		return local, fmt.Errorf("hash manifest: %w", err)
+	}
+	localHash, err := local.Hash()
+	if err != nil {
+		return local, fmt.Errorf("hash manifest: %w", err)
+	}
+	if resultHash == localHash {
+		return result, nil
+	}
+	if err := saveLocalManifest(result); err != nil {
+		return local, fmt.Errorf("update local manifest: %w", err)
+	}
+	return result, nil
+}
+
+// base for commands which change parts of the remote manifest
+type manifestEditor struct {
+	remoteLink
+	uidLocator
+}
+
+// login for the selected lambda
+func (cmd *manifestEditor) login(ctx context.Context) (*api.Token, error) {
+	if err := cmd.parseUID(); err != nil {
+		return nil, err
+	}
+	log.Println("lambda", cmd.UID)
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	return token, nil
+}
+
+// login and get remote manifest
+func (cmd *manifestEditor) remoteManifest(ctx context.Context) (*api.Token, *types.Manifest, error) {
+	token, err := cmd.login(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := cmd.Lambdas().Info(ctx, token, cmd.UID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get info: %w", err)
+	}
+	return token, &info.Manifest, nil
+}
+
+// push changed manifest (see push) and print it in JSON mode
+func (cmd *manifestEditor) save(ctx context.Context, token *api.Token, manifest *types.Manifest, patch func(local *types.Manifest)) error {
+	if err := cmd.push(ctx, token, manifest, patch); err != nil {
+		return err
+	}
+	return printResult(manifestResult{UID: cmd.UID, Manifest: *manifest})
+}
+
+// push changed manifest by single update and apply the same change (patch) to the local manifest and to the base
+// (if exist), so other local changes are kept and not treated as conflicts later
+func (cmd *manifestEditor) push(ctx context.Context, token *api.Token, manifest *types.Manifest, patch func(local *types.Manifest)) error {
+	log.Println("pushing manifest...")
+	_, err := cmd.Lambdas().Update(ctx, token, cmd.UID, *manifest, false)
+	if err != nil {
+		return fmt.Errorf("update remote manifest: %w", err)
+	}
+	if err := patchLocal(patch); err != nil {
+		return err
+	}
+	log.Println("done")
+	return nil
+}
+
+// apply change of remote manifest to the local manifest and to the base (if exist)
+func patchLocal(patch func(local *types.Manifest)) error {
+	var local types.Manifest
+	if err := loadLocalManifest(&local); err == nil {
+		patch(&local)
+		if err := saveLocalManifest(local); err != nil {
+			return fmt.Errorf("update local manifest: %w", err)
+		}
+	}
+	if cf, err := readRemote(); err == nil && cf != nil && cf.Base != nil {
+		patch(cf.Base)
+		if err := saveBase(*cf.Base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// UIDs of lambdas in starter fixture
+const (
+	fixtureHello  = "886a1139-e519-4290-b5ce-4c8ade11984d"
+	fixtureReport = "5c00ee5d-e772-4622-91c2-7ff68f6d0297"
+)
+
+// commands are executed against mock server with starter fixture: the same admin API as on the real server
+func TestMockServer_conformance(t *testing.T) {
+	state, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(state)
+
+	ctl := startMockCtl(t, state)
+	var items []lambdaItem
+	ctl.decode(&items, "ls")
+	require.Len(t, items, 2)
+	assert.Equal(t, "hello", items[0].Name)
+	assert.Equal(t, []string{"hello"}, items[0].Aliases)
+	assert.Equal(t, fixtureReport, items[1].UID)
+
+	var described lambdaItem
+	ctl.decode(&described, "describe", "hello")
+	require.Len(t, described.Schedules, 1)
+	assert.True(t, described.Schedules[0].Enabled)
+	assert.Equal(t, "refresh", described.Schedules[0].Action)
+
+	records := ctl.records("logs", "hello")
+	require.Len(t, records, 3, "records of fixture")
+	assert.Equal(t, "exit status 1", records[2].Err)
+
+	var scheduled manifestResult
+	ctl.decode(&scheduled, "schedule", "add", "@daily", "--name", "digest", "--payload", `{"period":"week"}`, "-U", fixtureHello)
+	require.Len(t, scheduled.Manifest.Cron, 2)
+	var env manifestResult
+	ctl.decode(&env, "env", "set", "LEVEL=debug", "-U", fixtureReport)
+	assert.Equal(t, "debug", env.Manifest.Environment["LEVEL"])
+	var links []aliasLink
+	ctl.decode(&links, "alias", "add", "greet", "-U", fixtureHello)
+	assert.Equal(t, []aliasLink{{Alias: "greet", UID: fixtureHello}}, links)
+
+	var changes application.ChangesReport
+	ctl.decode(&changes, "changes")
+	require.Len(t, changes.Groups, 3, "lambdas and password of admin")
+	assert.Equal(t, fixtureHello, changes.Groups[0].Lambda)
+	assert.Equal(t, "1 schedule change, 1 alias change by admin", changes.Groups[0].Summary)
+	assert.Equal(t, "1 manifest edit by admin", changes.Groups[1].Summary)
+
+	var security application.SecurityReport
+	ctl.decode(&security, "security")
+	assert.Equal(t, types.ProfileDefault, security.Profile.Name)
+
+	var found application.SearchResult
+	ctl.decode(&found, "search", "json", "rep")
+	require.Len(t, found.Hits, 1)
+	assert.Equal(t, fixtureReport, found.Hits[0].UID)
+	ctl.decode(&found, "search", "greet")
+	require.Len(t, found.Hits, 1, "added alias is indexed")
+	assert.Equal(t, fixtureHello, found.Hits[0].UID)
+
+	// mutations are persisted to the state: visible after restart, fixture stats are not imported twice
+	ctl.stop()
+	var manifest types.Manifest
+	require.NoError(t, manifest.LoadFrom(filepath.Join(state, fixtureHello, "manifest.json")))
+	assert.Equal(t, "digest", manifest.Cron[1].Name)
+
+	ctl = startMockCtl(t, state)
+	defer ctl.stop()
+	items = nil
+	ctl.decode(&items, "ls")
+	require.Len(t, items, 2)
+	assert.Equal(t, []string{"greet", "hello"}, items[0].Aliases)
+	var vars map[string]string
+	ctl.decode(&vars, "env", "ls", "-U", fixtureReport)
+	assert.Equal(t, map[string]string{"MODE": "dev", "LEVEL": "debug"}, vars)
+	assert.Len(t, ctl.records("logs", "hello"), 3)
+}
+
+func TestMockServer_existingState(t *testing.T) {
+	state, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(state)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(state, "project.json"), []byte(`{"user": ""}`), 0644))
+
+	ctl := startMockCtl(t, state)
+	defer ctl.stop()
+	var items []lambdaItem
+	ctl.decode(&items, "ls")
+	assert.Empty(t, items, "starter fixture is written only to empty state")
+}
+
+type mockCtl struct {
+	t      *testing.T
+	remote []string
+	stop   func()
+}
+
+func startMockCtl(t *testing.T, state string) *mockCtl {
+	ctx, cancel := context.WithCancel(context.Background())
+	instance, err := startMock(ctx, state, "admin")
+	require.NoError(t, err)
+	srv := httptest.NewServer(instance.Handler())
+	return &mockCtl{
+		t:      t,
+		remote: []string{"-u", srv.URL, "-p", "admin", "--independent", "--ghost", "--no-token-cache", "--json"},
+		stop: func() {
+			srv.Close()
+			cancel()
+			instance.Stop()
+		},
+	}
+}
+
+// run command in JSON mode and return stdout
+func (ctl *mockCtl) run(args ...string) []byte {
+	out, err := ctl.exec(args...)
+	require.NoError(ctl.t, err, "cgi-ctl %v", args)
+	return out
+}
+
+// run command in JSON mode and return stdout and error of command
+func (ctl *mockCtl) exec(args ...string) ([]byte, error) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(ctl.t, err)
+	os.Stdout = w
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(&out, r)
+	}()
+	globalOptions.JSON = false
+	var config Config
+	_, err = newParser(&config).ParseArgs(append(args, ctl.remote...))
+	os.Stdout = stdout
+	_ = w.Close()
+	<-done
+	return out.Bytes(), err
+}
+
+func (ctl *mockCtl) decode(value interface{}, args ...string) {
+	require.NoError(ctl.t, json.Unmarshal(ctl.run(args...), value), "cgi-ctl %v", args)
+}
+
+// records printed as JSON lines
+func (ctl *mockCtl) records(args ...string) []stats.Record {
+	var ans []stats.Record
+	scanner := bufio.NewScanner(bytes.NewReader(ctl.run(args...)))
+	for scanner.Scan() {
+		var record stats.Record
+		require.NoError(ctl.t, json.Unmarshal(scanner.Bytes(), &record))
+		ans = append(ans, record)
+	}
+	return ans
+}
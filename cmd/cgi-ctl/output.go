@@ -0,0 +1,423 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// Machine-readable output (--json). Commands print exactly one JSON document to stdout, log-like commands (logs,
+// do with actions, upload and watch events) print one JSON object per line. Progress is always printed to stderr.
+// All documents are defined below, changes of them are breaking changes for scripts (see testdata/output).
+
+// set when command printed its JSON output, so later error is not printed as second document
+var outputWritten bool
+
+// print JSON document (or one line of JSON lines output) to stdout
+func printJSON(value interface{}) error {
+	outputWritten = true
+	return json.NewEncoder(os.Stdout).Encode(value)
+}
+
+// print result document of command without text output (progress only)
+func printResult(value interface{}) error {
+	if !globalOptions.JSON {
+		return nil
+	}
+	return printJSON(value)
+}
+
+// print error of command and return exit code. In JSON mode error is printed to stdout as a document, if
+// command already printed its output (or JSON events) error is printed to stderr as text
+func reportError(err error) int {
+	var flagsErr *flags.Error
+	if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+		fmt.Println(err)
+		return 0
+	}
+	code := 1
+	var exit *exitError
+	if errors.As(err, &exit) {
+		code = exit.code
+	}
+	if globalOptions.JSON && !outputWritten {
+		_ = printJSON(errorOutput{Error: err.Error(), Code: code})
+		return code
+	}
+	_, _ = fmt.Fprintln(os.Stderr, err)
+	return code
+}
+
+// error of command, code is the same as exit code
+type errorOutput struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// lambda (ls, describe)
+type lambdaItem struct {
+	UID       string                       `json:"uid"`
+	Name      string                       `json:"name"`
+	Aliases   []string                     `json:"aliases"`
+	Modified  time.Time                    `json:"modified"`
+	Enabled   bool                         `json:"enabled"`
+	Scheduled bool                         `json:"scheduled"`
+	Schedules []application.ScheduleStatus `json:"schedules"`
+	Queues    []application.QueueStatus    `json:"queues"`
+	Alerts    []application.AlertStatus    `json:"alerts"`
+	Warning   string                       `json:"warning,omitempty"`
+	Disk      *application.DiskUsage       `json:"disk,omitempty"`
+}
+
+func newLambdaItem(def application.Definition) lambdaItem {
+	var aliases = make([]string, 0, len(def.Aliases))
+	for alias := range def.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	item := lambdaItem{
+		UID:       def.UID,
+		Name:      def.Manifest.Name,
+		Aliases:   aliases,
+		Modified:  def.Modified,
+		Enabled:   !def.Disabled,
+		Scheduled: len(def.Manifest.Cron) > 0,
+		Schedules: def.Schedules,
+		Queues:    def.Queues,
+		Alerts:    def.Alerts,
+		Warning:   def.Warning,
+		Disk:      def.Disk,
+	}
+	if item.Schedules == nil {
+		item.Schedules = []application.ScheduleStatus{}
+	}
+	if item.Queues == nil {
+		item.Queues = []application.QueueStatus{}
+	}
+	if item.Alerts == nil {
+		item.Alerts = []application.AlertStatus{}
+	}
+	return item
+}
+
+// local copy of lambda (init bare, create, clone, update manifest)
+type localResult struct {
+	UID  string `json:"uid,omitempty"`
+	Name string `json:"name"`
+	Dir  string `json:"dir"`
+	URL  string `json:"url,omitempty"`
+	Slug string `json:"slug,omitempty"` // alias generated from name (create)
+	// resolved outputs of template reference (create), ex: example of call
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// state of lambda (enable, disable)
+type stateResult struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// removed lambda (rm)
+type removeResult struct {
+	UID         string   `json:"uid"`
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases"` // freed aliases
+	PurgedLocal bool     `json:"purged_local"`
+}
+
+// alias binding (alias add, rm and ls print list of bindings)
+type aliasLink struct {
+	Alias     string   `json:"alias"`
+	UID       string   `json:"uid"`
+	Overrides []string `json:"overrides,omitempty"` // access settings of lambda overridden by policy of alias
+	Calls     *int64   `json:"calls,omitempty"`     // requests by alias in window of alias ls (nil - stats are not available)
+}
+
+// remote of control file (remote ls prints list, remote add - added remote, remote rm - list of removed remotes)
+type remoteItem struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	UID  string `json:"uid"`
+}
+
+// downloaded tarball (download)
+type downloadResult struct {
+	UID    string `json:"uid"`
+	Output string `json:"output"`
+	Size   int    `json:"size"`
+}
+
+// pushed or pulled manifest (apply, update manifest, env set/unset/load, schedule add/rm)
+type manifestResult struct {
+	UID      string         `json:"uid"`
+	Manifest types.Manifest `json:"manifest"`
+}
+
+// comparison of local copy with remote lambda (diff), exit code is the same as in text mode
+type diffResult struct {
+	UID      string           `json:"uid"`
+	Differs  bool             `json:"differs"`
+	Manifest []manifestChange `json:"manifest"` // changes of manifest by fields
+	Files    []fileChange     `json:"files"`
+}
+
+type manifestChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"` // old (remote) value, nil - added
+	To    interface{} `json:"to,omitempty"`   // new (local) value, nil - removed
+}
+
+func newManifestChanges(changes []types.Change) []manifestChange {
+	var ans = make([]manifestChange, 0, len(changes))
+	for _, change := range changes {
+		ans = append(ans, manifestChange{Field: change.Field, From: change.From, To: change.To})
+	}
+	return ans
+}
+
+// Change types of files
+const (
+	fileAdded    = "added"
+	fileDeleted  = "deleted"
+	fileModified = "modified"
+)
+
+type fileChange struct {
+	Name    string `json:"name"`
+	Change  string `json:"change"`            // added, deleted or modified
+	Binary  bool   `json:"binary,omitempty"`  // modified binary file (only with --unified)
+	Unified string `json:"unified,omitempty"` // unified diff of modified text file (only with --unified)
+}
+
+// result of action (do prints one object per action, action list is printed as array of names)
+type actionResult struct {
+	Action string `json:"action"`
+	api.ActionResult
+}
+
+// result of deploy of workspace (deploy), lambdas in order of workspace
+type deployReport struct {
+	Lambdas []deployItem `json:"lambdas"`
+	Failed  int          `json:"failed"` // number of failed lambdas
+}
+
+// result of deploy of lambda of workspace
+type deployItem struct {
+	Name     string             `json:"name"`
+	Dir      string             `json:"dir"`
+	UID      string             `json:"uid,omitempty"`
+	Result   string             `json:"result"`            // created, updated, failed or skipped (after failure with --fail-fast)
+	Actions  []string           `json:"actions,omitempty"` // post-deploy actions invoked successfully
+	Duration types.JsonDuration `json:"duration"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// planned or applied changes of scheduled actions (schedule apply)
+type scheduleApplyResult struct {
+	UID     string           `json:"uid"`
+	Applied bool             `json:"applied"` // false for dry run or if nothing changed
+	Changes []scheduleChange `json:"changes"`
+}
+
+// Change types of schedule
+const (
+	scheduleAdded    = "added"
+	scheduleModified = "modified"
+	scheduleRemoved  = "removed"
+)
+
+type scheduleChange struct {
+	Change string `json:"change"` // added, modified (time limit or, for named entry, content) or removed
+	types.Schedule
+	OldTimeLimit *types.JsonDuration `json:"old_time_limit,omitempty"` // only for modified
+}
+
+// response of lambda (invoke, run), body is base64 encoded if it's not valid UTF-8 text
+type responseResult struct {
+	Status     int         `json:"status,omitempty"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"body_base64,omitempty"`
+}
+
+func newResponseResult(status int, headers http.Header, body []byte) responseResult {
+	ans := responseResult{Status: status, Headers: headers}
+	if ans.Headers == nil {
+		ans.Headers = http.Header{}
+	}
+	if utf8.Valid(body) {
+		ans.Body = string(body)
+	} else {
+		ans.BodyBase64 = body
+	}
+	return ans
+}
+
+// credentials (login, logout)
+type credentialsResult struct {
+	Account string `json:"account,omitempty"` // login@url, empty for logout --all
+	Token   bool   `json:"token"`             // login token cached (login) or removed (logout)
+	Keyring bool   `json:"keyring"`           // password saved to (login) or removed from (logout) OS keyring
+}
+
+// invocation record (stats)
+type statsRecord struct {
+	Begin      time.Time `json:"begin"`
+	Trigger    string    `json:"trigger"` // http, queue, schedule, chain or unknown (recorded by previous versions)
+	Alias      string    `json:"alias"`   // alias of request, uid-direct (not by alias) or unknown
+	DurationMs float64   `json:"duration_ms"`
+	WaitMs     float64   `json:"wait_ms,omitempty"`       // waiting for free slot of concurrency limit (part of duration)
+	Queue      string    `json:"queue,omitempty"`         // queue of executed request (queued execution)
+	QueueMs    float64   `json:"queue_wait_ms,omitempty"` // time in queue before execution (not part of duration)
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	RequestID  string    `json:"request_id,omitempty"` // request ID (X-Request-Id, REQUEST_ID of lambda)
+	Status     string    `json:"status"`               // ok, error, retried, rejected, throttled, truncated or coalesced
+	Attempt    int       `json:"attempt,omitempty"`    // number of attempt of retried execution from 1
+	CatchUp    bool      `json:"catch_up,omitempty"`   // scheduled run missed during downtime
+	Error      string    `json:"error,omitempty"`
+	Payload    int64     `json:"payload"` // size of request body in bytes
+	Size       int64     `json:"size"`    // size of response body in bytes
+	Weight     int       `json:"weight"`  // number of invocations represented by the record (sampling)
+}
+
+func newStatsRecord(record stats.Record) statsRecord {
+	status := "ok"
+	switch {
+	case record.Throttled:
+		status = "throttled"
+	case record.Denied:
+		status = "denied"
+	case record.Rejected:
+		status = "rejected"
+	case record.Retried:
+		status = "retried"
+	case record.Err != "":
+		status = "error"
+	case record.Overrun:
+		status = "truncated"
+	case record.Coalesced:
+		status = "coalesced"
+	}
+	return statsRecord{
+		Begin:      record.Begin,
+		Trigger:    record.Source(),
+		Alias:      record.Entry(),
+		DurationMs: milliseconds(record.End.Sub(record.Begin)),
+		WaitMs:     milliseconds(record.Wait),
+		Queue:      record.Queue,
+		QueueMs:    milliseconds(record.QueueWait),
+		Method:     record.Request.Method,
+		URL:        record.Request.URL,
+		RequestID:  record.Request.ID,
+		Status:     status,
+		Attempt:    record.Attempt,
+		CatchUp:    record.CatchUp,
+		Error:      record.Err,
+		Payload:    record.Payload,
+		Size:       record.Size,
+		Weight:     record.Weight(),
+	}
+}
+
+// summary of invocations, sampled records are weighted by sampling rate
+type statsSummary struct {
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"` // from 0 to 1
+	MinMs     float64 `json:"min_ms"`
+	AvgMs     float64 `json:"avg_ms"`
+	MaxMs     float64 `json:"max_ms"`
+	// queued executions and time of their requests in queue before execution
+	Queued         int     `json:"queued,omitempty"`
+	AvgQueueWaitMs float64 `json:"avg_queue_wait_ms,omitempty"`
+	MaxQueueWaitMs float64 `json:"max_queue_wait_ms,omitempty"`
+}
+
+// stats of lambda (stats), records are from oldest to newest; watch mode prints one document per refresh
+// without records
+type statsResult struct {
+	UID     string        `json:"uid"`
+	Since   time.Time     `json:"since"`
+	Until   *time.Time    `json:"until,omitempty"` // end of window (nil - now)
+	Summary statsSummary  `json:"summary"`
+	Records []statsRecord `json:"records"`
+}
+
+// stats of all lambdas (stats --all), lambdas are sorted by --sort
+type statsAllResult struct {
+	Since   time.Time     `json:"since"`
+	Until   *time.Time    `json:"until,omitempty"` // end of window (nil - now)
+	Summary statsSummary  `json:"summary"`
+	Lambdas []lambdaStats `json:"lambdas"`
+}
+
+type lambdaStats struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+	statsSummary
+}
+
+// result of offline checks of the local copy (validate), exit code is non-zero if there are errors
+type validateResult struct {
+	Valid    bool              `json:"valid"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Issues   []validationIssue `json:"issues"`
+	manifest string            // name of validated manifest file
+}
+
+type validationIssue struct {
+	Level   string `json:"level"` // error or warning
+	File    string `json:"file"`  // file to fix: manifest.json (or manifest.yaml), Makefile or .cgiignore
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// content hash of lambda signed by server (verify). Expected is content hash after the last verified upload
+// recorded in control file, exit code is non-zero if changed
+type verifyResult struct {
+	UID      string `json:"uid"`
+	Hash     string `json:"hash"`
+	Expected string `json:"expected,omitempty"`
+	Changed  bool   `json:"changed"`
+}
+
+// result of configuration checks (config doctor), exit code is non-zero if there are errors
+type configDoctorResult struct {
+	Healthy  bool          `json:"healthy"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Checks   []configCheck `json:"checks"`
+}
+
+type configCheck struct {
+	Name    string `json:"name"`   // control file, profile, token or connectivity
+	Status  string `json:"status"` // ok, warning or error
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"` // how to fix the problem
+}
+
+// state of alert rules of lambda (alerts ls, alerts reset)
+type alertsResult struct {
+	UID    string                    `json:"uid"`
+	Alerts []application.AlertStatus `json:"alerts"`
+}
+
+func newAlertsResult(uid string, list []application.AlertStatus) alertsResult {
+	if list == nil {
+		list = []application.AlertStatus{}
+	}
+	return alertsResult{UID: uid, Alerts: list}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files of JSON output")
+
+// documents of --json output should not be changed unintentionally: scripts depend on them
+func TestOutput_golden(t *testing.T) {
+	modified := time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)
+	oldLimit := types.JsonDuration(time.Minute)
+	records := []stats.Record{
+		{UID: "a1b2", Request: types.Request{Method: "POST", URL: "/a/a1b2"}, Begin: modified, End: modified.Add(100 * time.Millisecond), Payload: 12, Size: 2048, Wait: 40 * time.Millisecond, Trigger: stats.TriggerHTTP},
+		{UID: "a1b2", Request: types.Request{Method: "GET", URL: "/a/a1b2"}, Begin: modified, End: modified.Add(300 * time.Millisecond), Err: "run failed: exit status 1", Rate: 1},
+		{UID: "a1b2", Begin: modified, End: modified.Add(200 * time.Millisecond), Rate: 2},
+	}
+	cases := map[string]interface{}{
+		"error": errorOutput{Error: "lambda not found", Code: exitNotFound},
+		"lambda": newLambdaItem(application.Definition{
+			UID:      "a1b2",
+			Aliases:  types.JsonStringSet{"hello": true, "api": true},
+			Manifest: types.Manifest{Name: "hello", Cron: []types.Schedule{{Cron: "@hourly", Action: "update"}}},
+			Modified: modified,
+		}),
+		"local":    localResult{UID: "a1b2", Name: "hello", Dir: "/home/user/hello", URL: "http://127.0.0.1:3434/"},
+		"remove":   removeResult{UID: "a1b2", Name: "hello", Aliases: []string{"api"}, PurgedLocal: true},
+		"state":    stateResult{UID: "a1b2", Name: "hello", Enabled: false},
+		"aliases":  []aliasLink{{Alias: "api", UID: "a1b2"}},
+		"remotes":  []remoteItem{{Name: "origin", URL: "http://127.0.0.1:3434/", UID: "a1b2"}},
+		"download": downloadResult{UID: "a1b2", Output: "a1b2.tar.gz", Size: 1024},
+		"manifest": manifestResult{UID: "a1b2", Manifest: types.Manifest{Name: "hello", Environment: map[string]string{"MODE": "prod"}}},
+		"diff": diffResult{
+			UID:     "a1b2",
+			Differs: true,
+			Manifest: newManifestChanges([]types.Change{
+				{Field: "name", From: "hello", To: "world"},
+				{Field: "environment.MODE", To: "prod"},
+			}),
+			Files: []fileChange{
+				{Name: "app.py", Change: fileModified, Unified: "--- remote/app.py\n+++ local/app.py\n"},
+				{Name: "logo.png", Change: fileModified, Binary: true},
+				{Name: "new.txt", Change: fileAdded},
+				{Name: "old.txt", Change: fileDeleted},
+			},
+		},
+		"action": actionResult{Action: "update", ActionResult: api.ActionResult{
+			Output:   "done\n",
+			ExitCode: 2,
+			Error:    "exit status 2",
+			Duration: types.JsonDuration(1500 * time.Millisecond),
+		}},
+		"deploy": deployReport{Failed: 1, Lambdas: []deployItem{
+			{Name: "users", Dir: "services/users", UID: "a1b2", Result: deployCreated, Actions: []string{"install"}, Duration: types.JsonDuration(2 * time.Second)},
+			{Name: "billing", Dir: "services/billing", UID: "c3d4", Result: deployFailed, Duration: types.JsonDuration(time.Second), Error: "upload: quota exceeded"},
+			{Name: "reports", Dir: "services/reports", Result: deploySkipped},
+		}},
+		"schedule_apply": scheduleApplyResult{UID: "a1b2", Applied: true, Changes: []scheduleChange{
+			{Change: scheduleAdded, Schedule: types.Schedule{Cron: "@daily", Action: "backup"}},
+			{Change: scheduleModified, Schedule: types.Schedule{Cron: "@hourly", Action: "update", TimeLimit: types.JsonDuration(2 * time.Minute)}, OldTimeLimit: &oldLimit},
+			{Change: scheduleRemoved, Schedule: types.Schedule{Cron: "@weekly", Action: "cleanup"}},
+		}},
+		"response":        newResponseResult(http.StatusOK, http.Header{"Content-Type": {"application/json"}}, []byte(`{"hello":"world"}`)),
+		"response_binary": newResponseResult(0, nil, []byte{0xff, 0x00, 0xfe}),
+		"credentials":     credentialsResult{Account: "admin@http://127.0.0.1:3434", Token: true, Keyring: true},
+		"stats": statsResult{
+			UID:     "a1b2",
+			Since:   modified,
+			Summary: summarize(records),
+			Records: []statsRecord{newStatsRecord(records[0]), newStatsRecord(records[1])},
+		},
+		"stats_all": statsAllResult{
+			Since:   modified,
+			Summary: summarize(records),
+			Lambdas: []lambdaStats{{UID: "a1b2", Name: "hello", statsSummary: summarize(records)}},
+		},
+		"alerts": newAlertsResult("a1b2", []application.AlertStatus{
+			{Name: "crash", Action: types.AlertDisable, State: application.AlertFiring, Since: modified, Reason: "3 consecutive failures", Events: []application.AlertEvent{
+				{Time: modified, From: application.AlertOK, To: application.AlertFiring, Reason: "3 consecutive failures", By: "rule"},
+			}},
+			{Name: "slow", Action: types.AlertNotify, State: application.AlertOK, Events: []application.AlertEvent{}},
+		}),
+		"config_doctor": configDoctorResult{Healthy: false, Errors: 1, Warnings: 1, Checks: []configCheck{
+			{Name: "control file", Status: issueError, Message: ".cgictl.json: remotes.prod.url: expected string, but got number", Fix: "fix the field by schema (see docs of cgi-ctl) or remove .cgictl.json and run cgi-ctl link"},
+			{Name: "profile", Status: checkOK, Message: "credentials of admin saved in /home/user/.config/trusted-cgi-ctl/127.0.0.1_3434"},
+			{Name: "token", Status: issueWarning, Message: "no cached token for admin@http://127.0.0.1:3434", Fix: "run cgi-ctl login -l admin"},
+			{Name: "connectivity", Status: checkOK, Message: "server http://127.0.0.1:3434/ is reachable (login is not checked without cached token)"},
+		}},
+		"verify": verifyResult{UID: "e0ed902f-4a9c-4c29-870d-f343f330b6ab", Hash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", Expected: "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752", Changed: true},
+		"search": application.SearchResult{Query: "jira sync", Total: 1, Hits: []application.SearchHit{{
+			UID: "e0ed902f-4a9c-4c29-870d-f343f330b6ab", Name: "Jira sync", Score: 7.55,
+			Highlights: []application.SearchHighlight{
+				{Field: "name", Fragments: []application.SnippetFragment{{Text: "Jira", Match: true}, {Text: " "}, {Text: "sync", Match: true}}},
+				{Field: "label", Fragments: []application.SnippetFragment{{Text: "jira", Match: true}}},
+			},
+		}}},
+		"validate": validateResult{Valid: false, Errors: 1, Warnings: 1, Issues: []validationIssue{
+			{Level: issueError, File: "manifest.json", Message: `invalid output header name "Bad Header"`},
+			{Level: issueWarning, File: "manifest.json", Field: "time_limit", Message: "time limit is not set: invocation could run forever"},
+		}},
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.MarshalIndent(value, "", "  ")
+			require.NoError(t, err)
+			data = append(data, '\n')
+			golden := filepath.Join("testdata", "output", name+".golden")
+			if *updateGolden {
+				require.NoError(t, ioutil.WriteFile(golden, data, 0644))
+			}
+			expected, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(data))
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	begin := time.Now()
+	summary := summarize([]stats.Record{
+		{Begin: begin, End: begin.Add(100 * time.Millisecond)},
+		{Begin: begin, End: begin.Add(400 * time.Millisecond), Err: "failed"},
+		{Begin: begin, End: begin.Add(200 * time.Millisecond), Rate: 2}, // sampled: represents two invocations
+	})
+	assert.Equal(t, statsSummary{Count: 4, Errors: 1, ErrorRate: 0.25, MinMs: 100, AvgMs: 225, MaxMs: 400}, summary)
+	assert.Equal(t, statsSummary{}, summarize(nil))
+}
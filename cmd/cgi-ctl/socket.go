@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// prefix of URL of server by unix socket (ex: unix:/run/trusted-cgi.sock), the same form as listener of server
+const socketPrefix = "unix:"
+
+// suffix of placeholder host of unix socket in HTTP URL
+const socketHost = ".unix"
+
+// Host header of requests by unix socket
+const socketHeaderHost = "localhost"
+
+// HTTP URL of server URL: URL of unix socket is replaced by URL of placeholder host (encoded path of socket) which is
+// dialed by the socket (see useSockets), other URLs are kept
+func serverURL(base string) string {
+	path, ok := strings.CutPrefix(base, socketPrefix)
+	if !ok {
+		return base
+	}
+	return "http://" + hex.EncodeToString([]byte(strings.TrimSuffix(path, "/"))) + socketHost + "/"
+}
+
+// path of unix socket of placeholder host of address (host or host:port)
+func socketOf(address string) (string, bool) {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	encoded, ok := strings.CutSuffix(address, socketHost)
+	if !ok {
+		return "", false
+	}
+	path, err := hex.DecodeString(encoded)
+	if err != nil || len(path) == 0 {
+		return "", false
+	}
+	return string(path), true
+}
+
+// send requests to placeholder hosts of unix sockets by the sockets: default transport is replaced, so every client
+// of command (API clients, invocations, re-login) could use URL of unix socket
+func useSockets() {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if path, ok := socketOf(address); ok {
+			return dial(ctx, "unix", path)
+		}
+		return dial(ctx, network, address)
+	}
+	http.DefaultTransport = &socketTransport{base: transport}
+}
+
+// transport which hides placeholder host of unix socket from server
+type socketTransport struct {
+	base http.RoundTripper
+}
+
+func (st *socketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := socketOf(req.URL.Host); ok {
+		req = req.Clone(req.Context())
+		req.Host = socketHeaderHost
+	}
+	return st.base.RoundTrip(req)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerURL_socket(t *testing.T) {
+	assert.Equal(t, "http://127.0.0.1:3434/", serverURL("http://127.0.0.1:3434/"))
+	socketURL := serverURL("unix:/run/trusted-cgi.sock")
+	assert.Equal(t, socketURL+"u/", urlJoin("unix:/run/trusted-cgi.sock", "u", ""))
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trusted-cgi.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(request.Host + request.URL.Path))
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	useSockets()
+	res, err := http.Get(urlJoin("unix:"+path, "a", "uid"))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost/a/uid", string(body), "placeholder host is hidden from server")
+}
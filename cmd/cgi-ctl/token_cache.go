@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/reddec/jsonrpc2"
+	"github.com/reddec/trusted-cgi/api"
+)
+
+const (
+	tokenCacheSection = "cgi-ctl"
+	tokenCacheFile    = "tokens.json"
+	// cached token is not used if it expires sooner
+	tokenExpiryMargin = 30 * time.Second
+)
+
+// login token cached between invocations
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires,omitempty"` // zero - unknown
+}
+
+// cached tokens by server URL and login (see tokenCacheKey)
+type tokenCache map[string]cachedToken
+
+func tokenCacheKey(serverURL, login string) string {
+	return login + "@" + strings.TrimSuffix(serverURL, "/")
+}
+
+func tokenCacheLocation() (string, error) {
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cfg, tokenCacheSection, tokenCacheFile), nil
+}
+
+// read cache, missed file is empty cache
+func readTokenCache() (tokenCache, error) {
+	location, err := tokenCacheLocation()
+	if err != nil {
+		return nil, err
+	}
+	var cache = make(tokenCache)
+	data, err := ioutil.ReadFile(location)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cache, json.Unmarshal(data, &cache)
+}
+
+func (tc tokenCache) Save() error {
+	location, err := tokenCacheLocation()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(location), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tc, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to temp file with atomic swap, so concurrent invocations don't see partial file
+	tmp, err := ioutil.TempFile(filepath.Dir(location), tokenCacheFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), location)
+}
+
+// cached token if it's not expired
+func (rl *remoteLink) cachedToken() *api.Token {
+	if rl.NoTokenCache || rl.Independent {
+		return nil
+	}
+	cache, err := readTokenCache()
+	if err != nil {
+		log.Println("[WARN] read token cache:", err)
+		return nil
+	}
+	entry, ok := cache[tokenCacheKey(rl.URL, rl.Login)]
+	if !ok || entry.Token == "" {
+		return nil
+	}
+	if !entry.Expires.IsZero() && time.Until(entry.Expires) < tokenExpiryMargin {
+		return nil
+	}
+	return &api.Token{Data: entry.Token}
+}
+
+// update (token is not nil) or remove (token is nil) cached token
+func (rl *remoteLink) cacheToken(token *api.Token) {
+	if rl.NoTokenCache || rl.Ghost {
+		return
+	}
+	cache, err := readTokenCache()
+	if err != nil {
+		log.Println("[WARN] read token cache:", err)
+		return
+	}
+	key := tokenCacheKey(rl.URL, rl.Login)
+	if token == nil {
+		if _, ok := cache[key]; !ok {
+			return
+		}
+		delete(cache, key)
+	} else {
+		cache[key] = cachedToken{Token: token.Data, Expires: tokenExpiry(token)}
+	}
+	if err := cache.Save(); err != nil {
+		log.Println("[WARN] save token cache:", err)
+	}
+}
+
+// expiration time from exp claim of JWT (signature is verified by server)
+func tokenExpiry(token *api.Token) time.Time {
+	var claims jwt.StandardClaims
+	if _, _, err := new(jwt.Parser).ParseUnverified(token.Data, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// Transport for API requests made with cached token. If server rejects the token, transport logins again,
+// replaces token in the request (and in all future requests) and repeats the request. Re-login is done once.
+type reloginTransport struct {
+	base     http.RoundTripper
+	link     *remoteLink
+	token    *api.Token
+	relogged bool
+}
+
+// use cached token for all API requests (made via default HTTP client)
+func (rl *remoteLink) useCachedToken(token *api.Token) {
+	http.DefaultClient.Transport = &reloginTransport{base: http.DefaultClient.Transport, link: rl, token: token}
+}
+
+func (rt *reloginTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.relogged || req.GetBody == nil {
+		return rt.roundTrip(req)
+	}
+	res, err := rt.roundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+	reply, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(reply))
+	if !isTokenRejected(reply) {
+		return res, nil
+	}
+	rt.relogged = true
+	log.Println("cached token rejected, login...")
+	rt.link.cacheToken(nil)
+	fresh, err := rt.link.login(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ioutil.ReadAll(body)
+	_ = body.Close()
+	if err != nil {
+		return nil, err
+	}
+	oldValue, _ := json.Marshal(rt.token.Data)
+	newValue, _ := json.Marshal(fresh.Data)
+	payload = bytes.Replace(payload, oldValue, newValue, 1)
+	rt.token.Data = fresh.Data // commands keep pointer to the token
+	retry := req.Clone(req.Context())
+	retry.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	retry.ContentLength = int64(len(payload))
+	return rt.roundTrip(retry)
+}
+
+func (rt *reloginTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if rt.base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return rt.base.RoundTrip(req)
+}
+
+// check that JSON-RPC reply is error of token validation (see user service)
+func isTokenRejected(reply []byte) bool {
+	var response jsonrpc2.Response
+	if json.Unmarshal(reply, &response) != nil || response.Error == nil {
+		return false
+	}
+	code := response.Error.Code
+	return (code == 401 || code == 403 || code == 1403) && strings.HasPrefix(response.Error.Message, "token validation failed")
+}
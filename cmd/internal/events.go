@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Types of events
+const (
+	EventProgress = "progress"
+	EventWarning  = "warning"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+// Machine-readable event of long operation. Serialized as one JSON object per line
+type Event struct {
+	Type      string    `json:"type"`
+	Operation string    `json:"operation"`         // operation name (upload)
+	Stage     string    `json:"stage,omitempty"`   // operation specific stage
+	Lambda    string    `json:"lambda,omitempty"`  // lambda UID
+	Bytes     int64     `json:"bytes,omitempty"`   // processed bytes
+	Total     int64     `json:"total,omitempty"`   // total bytes (if known)
+	Percent   *float64  `json:"percent,omitempty"` // progress in percents (if known)
+	Message   string    `json:"message,omitempty"` // warning or error message
+	Time      time.Time `json:"time"`
+}
+
+// Concurrent-safe emitter of events. Nil emitter or emitter without output does nothing
+type Events struct {
+	lock      sync.Mutex
+	out       io.Writer
+	operation string
+}
+
+// New emitter of events of operation as newline-delimited JSON. Nil output disables events
+func NewEvents(out io.Writer, operation string) *Events {
+	return &Events{out: out, operation: operation}
+}
+
+// Progress of stage. Percent is calculated only for positive total
+func (ev *Events) Progress(stage string, lambda string, bytes int64, total int64) {
+	event := Event{Type: EventProgress, Stage: stage, Lambda: lambda, Bytes: bytes, Total: total}
+	if total > 0 {
+		percent := float64(bytes) * 100 / float64(total)
+		event.Percent = &percent
+	}
+	ev.emit(event)
+}
+
+// Warning of operation (operation continues)
+func (ev *Events) Warning(lambda string, message string) {
+	ev.emit(Event{Type: EventWarning, Lambda: lambda, Message: message})
+}
+
+// Done operation
+func (ev *Events) Done(lambda string) {
+	ev.emit(Event{Type: EventDone, Lambda: lambda})
+}
+
+// Error of operation (operation stopped). Returns the same error for convenience
+func (ev *Events) Error(lambda string, err error) error {
+	ev.emit(Event{Type: EventError, Lambda: lambda, Message: err.Error()})
+	return err
+}
+
+func (ev *Events) emit(event Event) {
+	if ev == nil || ev.out == nil {
+		return
+	}
+	event.Operation = ev.operation
+	event.Time = time.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	ev.lock.Lock()
+	defer ev.lock.Unlock()
+	_, _ = ev.out.Write(append(data, '\n'))
+}
+
+// Wrap reader and report number of read bytes. Report is called at most once per percent of total
+// (or per read if total is unknown) and always at the end of stream or when total reached
+func NewProgressReader(reader io.ReadCloser, total int64, report func(done int64)) io.ReadCloser {
+	return &progressReader{reader: reader, total: total, report: report}
+}
+
+type progressReader struct {
+	reader   io.ReadCloser
+	total    int64
+	done     int64
+	reported int64
+	report   func(done int64)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	pr.done += int64(n)
+	finished := err == io.EOF || (pr.total > 0 && pr.done >= pr.total)
+	if pr.done != pr.reported && (finished || pr.done-pr.reported > pr.total/100) {
+		pr.reported = pr.done
+		pr.report(pr.done)
+	}
+	return n, err
+}
+
+func (pr *progressReader) Close() error {
+	return pr.reader.Close()
+}
@@ -0,0 +1,94 @@
+package internal_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+func readEvents(t *testing.T, data []byte) []internal.Event {
+	var events []internal.Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event internal.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "each line should be JSON object")
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEvents_upload(t *testing.T) {
+	var out bytes.Buffer
+	events := internal.NewEvents(&out, "upload")
+
+	events.Progress("login", "uid1", 0, 0)
+	payload := bytes.Repeat([]byte("x"), 1000)
+	reader := internal.NewProgressReader(ioutil.NopCloser(bytes.NewReader(payload)), int64(len(payload)), func(done int64) {
+		events.Progress("transfer", "uid1", done, int64(len(payload)))
+	})
+	buf := make([]byte, 300)
+	for {
+		_, err := reader.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	events.Warning("uid1", "slow network")
+	events.Done("uid1")
+
+	list := readEvents(t, out.Bytes())
+	var types []string
+	for _, event := range list {
+		types = append(types, event.Type+":"+event.Stage)
+		assert.Equal(t, "upload", event.Operation)
+		assert.Equal(t, "uid1", event.Lambda)
+		assert.False(t, event.Time.IsZero())
+	}
+	assert.Equal(t, []string{
+		"progress:login",
+		"progress:transfer",
+		"progress:transfer",
+		"progress:transfer",
+		"progress:transfer",
+		"warning:",
+		"done:",
+	}, types)
+
+	assert.Nil(t, list[0].Percent, "percent is unknown without total")
+	last := list[4]
+	assert.Equal(t, int64(1000), last.Bytes)
+	if assert.NotNil(t, last.Percent) {
+		assert.Equal(t, 100.0, *last.Percent)
+	}
+	assert.Equal(t, "slow network", list[5].Message)
+}
+
+func TestEvents_error(t *testing.T) {
+	var out bytes.Buffer
+	events := internal.NewEvents(&out, "upload")
+	err := events.Error("uid1", errors.New("archive failed"))
+	assert.EqualError(t, err, "archive failed")
+
+	list := readEvents(t, out.Bytes())
+	require.Len(t, list, 1)
+	assert.Equal(t, internal.EventError, list[0].Type)
+	assert.Equal(t, "archive failed", list[0].Message)
+}
+
+func TestEvents_disabled(t *testing.T) {
+	var events *internal.Events
+	// no panic on nil emitter
+	events.Progress("login", "uid1", 0, 0)
+	events.Done("uid1")
+	assert.Error(t, events.Error("uid1", errors.New("failed")))
+}
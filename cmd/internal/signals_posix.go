@@ -0,0 +1,11 @@
+//go:build !windows
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// Ctrl+C in terminal and termination by service manager (kill is not handled by process)
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
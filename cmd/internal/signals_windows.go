@@ -0,0 +1,10 @@
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// Console events are mapped by Go runtime: Ctrl+C and Ctrl+Break - interrupt, close of console window, logoff and
+// shutdown - SIGTERM
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
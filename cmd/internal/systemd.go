@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the first file descriptor passed by systemd socket activation (after stdin, stdout and stderr)
+const listenFdsStart = 3
+
+// name of socket without FileDescriptorName= in socket unit
+const unknownSocket = "unknown"
+
+// Messages of notify protocol of systemd
+const (
+	NotifyReady    = "READY=1"
+	NotifyStopping = "STOPPING=1"
+	NotifyWatchdog = "WATCHDOG=1"
+)
+
+// SystemdListeners are listeners passed by systemd socket activation (LISTEN_FDS) by names of sockets (LISTEN_FDNAMES,
+// "unknown" if not named). Nil if the process is not activated by socket. Variables of activation are unset, so they
+// are not inherited by processes of lambdas
+func SystemdListeners() (map[string][]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	var ans = make(map[string][]net.Listener)
+	for i := 0; i < count; i++ {
+		name := unknownSocket
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		fd := listenFdsStart + i
+		file := os.NewFile(uintptr(fd), name)
+		// listener is a duplicate closed on exec: inherited descriptor is closed and not leaked to lambdas
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, list := range ans {
+				for _, l := range list {
+					_ = l.Close()
+				}
+			}
+			return nil, fmt.Errorf("socket %s (fd %d) of systemd: %w", name, fd, err)
+		}
+		ans[name] = append(ans[name], listener)
+	}
+	return ans, nil
+}
+
+// SystemdNotify sends state (ex: NotifyReady) to service manager by notify socket (NOTIFY_SOCKET). Nothing is sent
+// if the process is not started by systemd with Type=notify
+func SystemdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	if strings.HasPrefix(path, "@") {
+		// abstract namespace
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("notify systemd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notify systemd: %w", err)
+	}
+	return nil
+}
+
+// SystemdWatchdog is timeout of watchdog of service manager (WatchdogSec= of service unit). Zero if watchdog is
+// disabled or is not for this process
+func SystemdWatchdog() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
@@ -0,0 +1,70 @@
+package internal_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+func TestSystemdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	assert.NoError(t, internal.SystemdNotify(internal.NotifyReady), "not started by systemd")
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	require.NoError(t, internal.SystemdNotify(internal.NotifyReady))
+	require.NoError(t, internal.SystemdNotify(internal.NotifyStopping))
+	var buffer = make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "READY=1", string(buffer[:n]))
+	n, err = conn.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "STOPPING=1", string(buffer[:n]))
+}
+
+func TestSystemdWatchdog(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	assert.Zero(t, internal.SystemdWatchdog())
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	assert.Equal(t, 30*time.Second, internal.SystemdWatchdog())
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	assert.Equal(t, 30*time.Second, internal.SystemdWatchdog())
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	assert.Zero(t, internal.SystemdWatchdog(), "watchdog of another process")
+}
+
+func TestSystemdListeners_notActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := internal.SystemdListeners()
+	require.NoError(t, err)
+	assert.Nil(t, listeners, "sockets of another process")
+	assert.Equal(t, "1", os.Getenv("LISTEN_FDS"), "variables of another process are kept")
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+	listeners, err = internal.SystemdListeners()
+	require.NoError(t, err)
+	assert.Nil(t, listeners)
+	assert.Empty(t, os.Getenv("LISTEN_PID"), "variables of activation are unset")
+}
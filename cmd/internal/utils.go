@@ -6,11 +6,12 @@ import (
 	"os/signal"
 )
 
+// Context canceled by the first signal of shutdown (see shutdownSignals)
 func SignalContext() (context.Context, func()) {
 	gctx, closer := context.WithCancel(context.Background())
 	go func() {
 		c := make(chan os.Signal, 2)
-		signal.Notify(c, os.Kill, os.Interrupt)
+		signal.Notify(c, shutdownSignals...)
 		for range c {
 			closer()
 			break
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	internal2 "github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/server"
+)
+
+const redacted = "<redacted>"
+
+type Info struct {
+	JSON bool `long:"json" env:"JSON" description:"print information as JSON"`
+}
+
+// print server information without starting listeners
+func (cmd *Info) print(info *api.ServerInfo) error {
+	if cmd.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	fmt.Println("version:", info.Version)
+	fmt.Println("capabilities:", strings.Join(info.Capabilities, ", "))
+	for _, value := range info.Config {
+		fmt.Println(value.Name, "=", strconv.Quote(value.Value), "("+value.Source+")")
+	}
+	return nil
+}
+
+// server information based on parsed flags and project file
+func serverInfo(parser *flags.Parser, config Config) *api.ServerInfo {
+	var values []api.ConfigValue
+	for _, group := range parser.Groups() {
+		values = append(values, groupValues(group)...)
+	}
+	values = append(values, projectValues(filepath.Join(config.Dir, internal2.ProjectManifest))...)
+	return &api.ServerInfo{
+		Version:      version,
+		Capabilities: capabilities(config),
+		Config:       values,
+	}
+}
+
+func groupValues(group *flags.Group) []api.ConfigValue {
+	var ans []api.ConfigValue
+	for _, opt := range group.Options() {
+		if opt.LongName == "help" {
+			continue
+		}
+		name := opt.LongNameWithNamespace()
+		value := fmt.Sprint(opt.Value())
+		if strings.Contains(name, "password") || name == "secrets.key" || name == "webhooks.secret" {
+			value = redacted
+		}
+		ans = append(ans, api.ConfigValue{
+			Name:   name,
+			Value:  value,
+			Source: optionSource(opt),
+		})
+	}
+	for _, sub := range group.Groups() {
+		ans = append(ans, groupValues(sub)...)
+	}
+	return ans
+}
+
+func optionSource(opt *flags.Option) string {
+	if opt.IsSet() && !opt.IsSetDefault() {
+		return "flag"
+	}
+	if key := opt.EnvKeyWithNamespace(); key != "" {
+		if _, ok := os.LookupEnv(key); ok {
+			return "env"
+		}
+	}
+	return "default"
+}
+
+// values from project file (read-only), environment values are redacted
+func projectValues(file string) []api.ConfigValue {
+	var project application.Config
+	if err := project.ReadFile(file); err != nil {
+		return nil
+	}
+	source := "file:" + file
+	if abs, err := filepath.Abs(file); err == nil {
+		source = "file:" + abs
+	}
+	var ans = []api.ConfigValue{
+		{Name: "project.user", Value: project.User, Source: source},
+		{Name: "project.runtime.umask", Value: project.Runtime.Umask, Source: source},
+		{Name: "project.runtime.lang", Value: project.Runtime.Lang, Source: source},
+		{Name: "project.runtime.lc_all", Value: project.Runtime.LcAll, Source: source},
+		{Name: "project.runtime.tz", Value: project.Runtime.TZ, Source: source},
+		{Name: "project.runtime.env_limits.max_size", Value: strconv.FormatInt(project.Runtime.Env().SizeLimit(), 10), Source: source},
+		{Name: "project.runtime.env_limits.max_variable", Value: strconv.FormatInt(project.Runtime.Env().VariableLimit(), 10), Source: source},
+		{Name: "project.runtime.env_limits.oversized", Value: project.Runtime.Env().Oversized, Source: source},
+		{Name: "project.builds.max_concurrent", Value: strconv.Itoa(project.Builds.MaxConcurrent), Source: source},
+		{Name: "project.builds.cgroup", Value: project.Builds.Cgroup, Source: source},
+		{Name: "project.builds.limits.nice", Value: strconv.Itoa(project.Builds.Limits.Nice), Source: source},
+		{Name: "project.builds.limits.cpu", Value: strconv.FormatFloat(project.Builds.Limits.CPU, 'f', -1, 64), Source: source},
+		{Name: "project.builds.limits.memory", Value: strconv.FormatInt(project.Builds.Limits.Memory, 10), Source: source},
+	}
+	var names = make([]string, 0, len(project.Environment))
+	for name := range project.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ans = append(ans, api.ConfigValue{Name: "project.environment." + name, Value: redacted, Source: source})
+	}
+	return ans
+}
+
+func capabilities(config Config) []string {
+	var ans = []string{"bundles", "sampling", "runtime-defaults", "queues:" + config.Queues.Kind, "profile:" + config.SecurityProfile}
+	if !config.Dev && !config.DisableChroot {
+		ans = append(ans, "chroot")
+	}
+	if config.Dev {
+		ans = append(ans, "dev")
+	}
+	if config.SSHKey != "" {
+		ans = append(ans, "ssh")
+	}
+	if config.TLS {
+		ans = append(ans, "tls")
+	}
+	if config.AutoTLS {
+		ans = append(ans, "auto-tls")
+	}
+	if config.AdminSocket != "" {
+		ans = append(ans, "admin-socket")
+	}
+	for _, address := range config.Listen {
+		if _, ok := server.SocketPath(address); ok {
+			ans = append(ans, "unix-socket")
+			break
+		}
+	}
+	if config.Metrics {
+		ans = append(ans, "metrics")
+	}
+	if config.BehindProxy {
+		ans = append(ans, "behind-proxy")
+	}
+	if len(config.TrustedProxies) > 0 {
+		ans = append(ans, "trusted-proxies")
+	}
+	if config.Secrets.Enabled() {
+		ans = append(ans, "secrets")
+	}
+	if config.SFTP.Bind != "" {
+		ans = append(ans, "sftp")
+	}
+	if config.Mirror.Primary != "" {
+		ans = append(ans, "mirror")
+	}
+	if config.WatchFiles {
+		ans = append(ans, "watch-files")
+	}
+	if config.WatchScan > 0 {
+		ans = append(ans, "watch-scan")
+	}
+	if config.ContainerEngine != "" {
+		ans = append(ans, "containers:"+config.ContainerEngine)
+	}
+	if config.VersionsKeep != 0 {
+		ans = append(ans, "versions")
+	}
+	if config.WarmUp.Enabled {
+		ans = append(ans, "warm-up")
+	}
+	if config.JSONLog.Output != "" {
+		ans = append(ans, "json-log")
+	}
+	if config.Async.Depth > 0 {
+		ans = append(ans, "async")
+	}
+	if config.Queues.DeadLetters != "" {
+		ans = append(ans, "dead-letters")
+	}
+	if len(config.Webhooks.URL) > 0 {
+		ans = append(ans, "webhooks")
+	}
+	return ans
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/reddec/trusted-cgi/server"
+)
+
+// names of sockets of systemd socket activation (FileDescriptorName= of socket unit), sockets of other names are
+// listeners of the same endpoints as --bind and --listen
+const (
+	adminSocketName = "admin" // admin API without token (see --admin-socket)
+	plainSocketName = "plain" // plain HTTP listener with TLS (see --http-bind)
+)
+
+// listener of HTTP server: TCP (TLS if enabled) or unix socket (plain HTTP)
+type listener struct {
+	net.Listener
+	address string
+	socket  bool
+}
+
+// listeners passed by systemd (if any) or opened TCP listener of bind address (if set) and additional listeners.
+// Opened listeners are closed on error
+func (qs *HttpServer) listen(inherited []net.Listener) ([]listener, error) {
+	if net.ParseIP(qs.SocketAddress) == nil {
+		return nil, fmt.Errorf("invalid client IP of unix sockets (--socket-address): %q", qs.SocketAddress)
+	}
+	if len(inherited) > 0 {
+		var ans = make([]listener, 0, len(inherited))
+		for _, l := range inherited {
+			address := l.Addr().String()
+			socket := l.Addr().Network() == "unix"
+			if socket {
+				address = server.SocketPrefix + address
+			}
+			ans = append(ans, listener{Listener: l, address: address + " (systemd)", socket: socket})
+		}
+		return ans, nil
+	}
+	addresses := qs.Listen
+	if qs.Bind != "" {
+		addresses = append([]string{qs.Bind}, addresses...)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no listeners: set bind address (--bind) or listener (--listen)")
+	}
+	options, err := server.ParseSocketOptions(qs.SocketMode, qs.SocketOwner)
+	if err != nil {
+		return nil, err
+	}
+	var ans []listener
+	for _, address := range addresses {
+		var l listener
+		if path, ok := server.SocketPath(address); ok {
+			l.Listener, err = server.ListenSocket(path, options)
+			l.socket = true
+		} else {
+			l.Listener, err = net.Listen("tcp", address)
+		}
+		if err != nil {
+			for _, opened := range ans {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("listen %s: %w", address, err)
+		}
+		l.address = address
+		ans = append(ans, l)
+	}
+	return ans, nil
+}
+
+// serve requests of listener till server shutdown (http.ErrServerClosed)
+func (qs *HttpServer) serve(srv, sockets *http.Server, manager *autocert.Manager, l listener) error {
+	log.Println("REST server is on", l.address)
+	switch {
+	case l.socket:
+		return sockets.Serve(l)
+	case manager != nil:
+		// certificates are provided by manager
+		return srv.ServeTLS(l, "", "")
+	case qs.TLS:
+		return srv.ServeTLS(l, qs.CertFile, qs.KeyFile)
+	default:
+		return srv.Serve(l)
+	}
+}
@@ -1,29 +1,62 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata" // time zones of schedules regardless of zoneinfo of host
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/api/services"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/alerts"
+	"github.com/reddec/trusted-cgi/application/artifact"
+	"github.com/reddec/trusted-cgi/application/backup"
+	"github.com/reddec/trusted-cgi/application/capacity"
+	"github.com/reddec/trusted-cgi/application/capture"
 	"github.com/reddec/trusted-cgi/application/cases"
+	"github.com/reddec/trusted-cgi/application/containers"
+	"github.com/reddec/trusted-cgi/application/deadletter"
+	"github.com/reddec/trusted-cgi/application/freezer"
+	"github.com/reddec/trusted-cgi/application/gitdeploy"
+	"github.com/reddec/trusted-cgi/application/journal"
+	"github.com/reddec/trusted-cgi/application/mirror"
 	"github.com/reddec/trusted-cgi/application/platform"
 	"github.com/reddec/trusted-cgi/application/policy"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/reload"
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/application/search"
+	"github.com/reddec/trusted-cgi/application/secrets"
+	"github.com/reddec/trusted-cgi/application/statuspage"
+	"github.com/reddec/trusted-cgi/application/versions"
+	"github.com/reddec/trusted-cgi/application/warmup"
+	"github.com/reddec/trusted-cgi/application/webhooks"
 	"github.com/reddec/trusted-cgi/cmd/internal"
 	internal2 "github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/indir"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
 	"github.com/reddec/trusted-cgi/server"
-	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/server/sftpd"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/disklog"
+	"github.com/reddec/trusted-cgi/stats/impl/jsonlog"
+	"github.com/reddec/trusted-cgi/stats/impl/prometheus"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 const version = "dev"
@@ -35,25 +68,85 @@ type Config struct {
 	Templates string   `long:"templates" env:"TEMPLATES" description:"Templates directory" default:".templates"`
 	Queues    Queues   `group:"queues" namespace:"queues" env-namespace:"QUEUES"`
 	Policies  Policies `group:"policies" namespace:"policies" env-namespace:"POLICIES"`
+	SFTP      SFTP     `group:"sftp" namespace:"sftp" env-namespace:"SFTP"`
+	Mirror    Mirror   `group:"mirror" namespace:"mirror" env-namespace:"MIRROR"`
+	JSONLog   JSONLog  `group:"json-log" namespace:"json-log" env-namespace:"JSON_LOG"`
+	Async     Async    `group:"async" namespace:"async" env-namespace:"ASYNC"`
+	Secrets   Secrets  `group:"secrets" namespace:"secrets" env-namespace:"SECRETS"`
+	Argon2    Argon2   `group:"argon2" namespace:"argon2" env-namespace:"ARGON2"`
+	Login     Login    `group:"login" namespace:"login" env-namespace:"LOGIN"`
+	Webhooks  Webhooks `group:"webhooks" namespace:"webhooks" env-namespace:"WEBHOOKS"`
+	WarmUp    WarmUp   `group:"warm-up" namespace:"warm-up" env-namespace:"WARM_UP"`
 	//
 	InitialAdminPassword string        `long:"initial-admin-password" env:"INITIAL_ADMIN_PASSWORD" description:"Initial admin password" default:"admin"`
 	InitialChrootUser    string        `long:"initial-chroot-user" env:"INITIAL_CHROOT_USER" description:"Initial user for service" default:""`
 	DisableChroot        bool          `long:"disable-chroot" env:"DISABLE_CHROOT" description:"Disable use different user for spawn"`
 	SSHKey               string        `long:"ssh-key" env:"SSH_KEY" description:"Path to ssh key. If not empty and not exists - it will be generated" default:".id_rsa"`
 	Dev                  bool          `long:"dev" env:"DEV" description:"Enabled dev mode (disables chroot)"`
-	BehindProxy          bool          `long:"behind-proxy" env:"BEHIND_PROXY" description:"Respect X-Real-Ip and X-Forwarded-For"`
-	StatsCache           uint          `long:"stats-cache" env:"STATS_CACHE" description:"Maximum cache for stats" default:"8192"`
-	StatsFile            string        `long:"stats-file" env:"STATS_FILE" description:"Binary file for statistics dump" default:".stats"`
-	StatsInterval        time.Duration `long:"stats-interval" env:"STATS_INTERVAL" description:"Interval for dumping stats to file" default:"30s"`
+	BehindProxy          bool          `long:"behind-proxy" env:"BEHIND_PROXY" description:"Respect X-Real-Ip, X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host"`
+	TrustedProxies       []string      `long:"trusted-proxy" env:"TRUSTED_PROXIES" env-delim:"," description:"Network (CIDR) of proxy which forwarded headers are respected, forwarded headers of other peers are ignored (could be repeated)"`
+	ForwardedHeaders     []string      `long:"forwarded-header" env:"FORWARDED_HEADERS" env-delim:"," description:"Header of client address of trusted proxies: X-Real-Ip, X-Forwarded-For or Forwarded, the first present header in order of flags is used (could be repeated, empty - X-Forwarded-For)"`
+	PublicURL            string        `long:"public-url" env:"PUBLIC_URL" description:"Public base URL of server for lambdas (empty - detected by request)"`
+	RemoveHeaders        []string      `long:"remove-header" env:"REMOVE_HEADERS" env-delim:"," description:"Response header removed from all responses, also set by lambdas (could be repeated)"`
+	StatsDir             string        `long:"stats-dir" env:"STATS_DIR" description:"Directory of persistent stats (invocation records)" default:".stats.d"`
+	StatsMaxAge          time.Duration `long:"stats-max-age" env:"STATS_MAX_AGE" description:"Stats records older than the age are removed (zero - not limited, replaced by retention changed at runtime)" default:"720h"`
+	StatsMaxSize         int64         `long:"stats-max-size" env:"STATS_MAX_SIZE" description:"Size of stats in bytes: the oldest records over it are removed (zero - not limited, replaced by retention changed at runtime)" default:"268435456"`
+	StatsMaxRecords      int           `long:"stats-max-records" env:"STATS_MAX_RECORDS" description:"Number of stats records: the oldest records over it are removed (zero - not limited, replaced by retention changed at runtime)" default:"0"`
+	StatsCache           uint          `long:"stats-cache" env:"STATS_CACHE" description:"Maximum number of stats records waiting for write to disk: the oldest are dropped over it" default:"8192"`
+	StatsFile            string        `long:"stats-file" env:"STATS_FILE" description:"Legacy binary dump of stats: imported to stats directory once and renamed to .migrated" default:".stats"`
+	StatsInterval        time.Duration `long:"stats-interval" env:"STATS_INTERVAL" description:"Maximum delay of writing stats records to disk (records are also written by batches)" default:"5s"`
+	ChangesFile          string        `long:"changes-file" env:"CHANGES_FILE" description:"File of journal of administrative changes (deploys, manifests, aliases, policies, users, settings) and failed authentications" default:".changes.jsonl"`
+	ChangesMaxSize       int64         `long:"changes-max-size" env:"CHANGES_MAX_SIZE" description:"Size of journal file in bytes to rotate (zero - not rotated)" default:"104857600"`
+	ChangesBackups       int           `long:"changes-backups" env:"CHANGES_BACKUPS" description:"Number of rotated journal files to keep" default:"5"`
+	Captures             string        `long:"captures" env:"CAPTURES" description:"Directory of captured requests of lambdas with capture in manifest (empty - not captured)" default:".captures"`
+	StatusPages          string        `long:"status-pages" env:"STATUS_PAGES" description:"JSON file of public status pages of lambdas selected by labels (empty - disabled)"`
+	SearchIndexFile      string        `long:"search-index-file" env:"SEARCH_INDEX_FILE" description:"File of full-text index of lambdas (rebuilt if missing or corrupted)" default:".search-index.json"`
+	SearchMemory         int64         `long:"search-memory" env:"SEARCH_MEMORY" description:"Memory budget of full-text index in bytes: over budget lambdas are indexed partially" default:"8388608"`
 	SchedulerInterval    time.Duration `long:"scheduler-interval" env:"SCHEDULER_INTERVAL" description:"Interval to check cron records" default:"30s"`
+	SchedulerState       string        `long:"scheduler-state" env:"SCHEDULER_STATE" description:"File of last fire times of cron records to catch up runs missed during downtime (empty - missed runs are not known)" default:".scheduler.json"`
+	Metrics              bool          `long:"metrics" env:"METRICS" description:"Expose exact invocation counters in Prometheus format on /metrics"`
+	SecurityProfile      string        `long:"security-profile" env:"SECURITY_PROFILE" description:"Security profile of lambdas: defaults and mandatory rules (custom - from security profile file)" default:"default" choice:"default" choice:"strict" choice:"custom"`
+	SecurityProfileFile  string        `long:"security-profile-file" env:"SECURITY_PROFILE_FILE" description:"JSON file of custom security profile" default:"security.json"`
+	MaxGracePeriod       time.Duration `long:"max-grace-period" env:"MAX_GRACE_PERIOD" description:"Maximum time between SIGTERM and SIGKILL of terminated lambdas, overrides security profile (zero - by profile)"`
+	MaximumResponse      int64         `long:"maximum-response" env:"MAXIMUM_RESPONSE" description:"Default maximum response in bytes of lambdas without own limit, overrides default of security profile (zero - by profile)"`
+	UploadMaxFiles       int           `long:"upload-max-files" env:"UPLOAD_MAX_FILES" description:"Maximum number of files and directories in uploaded content, overrides security profile (zero - by profile)"`
+	UploadMaxSize        int64         `long:"upload-max-size" env:"UPLOAD_MAX_SIZE" description:"Maximum total size in bytes of extracted uploaded content, overrides security profile (zero - by profile)"`
+	UploadMaxDepth       int           `long:"upload-max-depth" env:"UPLOAD_MAX_DEPTH" description:"Maximum depth of paths in uploaded content, overrides security profile (zero - by profile)"`
+	UploadMaxFileSize    int64         `long:"upload-max-file-size" env:"UPLOAD_MAX_FILE_SIZE" description:"Maximum size in bytes of single file in uploaded content, overrides security profile (zero - by profile)"`
+	RunAsUsers           []string      `long:"run-as-user" env:"RUN_AS_USERS" env-delim:"," description:"Account allowed as run_as user of lambdas in addition to security profile (could be repeated)"`
+	RunAsGroups          []string      `long:"run-as-group" env:"RUN_AS_GROUPS" env-delim:"," description:"Group allowed as run_as group of lambdas in addition to security profile (could be repeated)"`
+	WatchFiles           bool          `long:"watch-files" env:"WATCH_FILES" description:"Apply manifests of lambdas, project config and custom security profile edited on disk without SIGHUP"`
+	WatchDebounce        time.Duration `long:"watch-debounce" env:"WATCH_DEBOUNCE" description:"Time without changes of edited file before it is applied" default:"500ms"`
+	WatchScan            time.Duration `long:"watch-scan" env:"WATCH_SCAN" description:"Period of scan of files edited on disk by sizes and modification times, including subdirectories of lambdas (zero - disabled, fallback if watcher of files fails)"`
+	FrozenKeep           int           `long:"frozen-keep" env:"FROZEN_KEEP" description:"Number of frozen artifacts kept for each lambda, older artifacts are pruned by the next freeze (negative - all)" default:"5"`
+	VersionsKeep         int           `long:"versions-keep" env:"VERSIONS_KEEP" description:"Number of versions (files and manifest) kept for each lambda, taken by uploads and updates of manifests (zero - versions are disabled, negative - all)" default:"10"`
+	VersionsMaxSize      int64         `long:"versions-max-size" env:"VERSIONS_MAX_SIZE" description:"Maximum total size in bytes of stored files of versions, the oldest versions are pruned over it (zero - unlimited)" default:"1073741824"`
+	ContainerEngine      string        `long:"container-engine" env:"CONTAINER_ENGINE" description:"CLI of Docker compatible engine (docker or podman) for lambdas with container in manifest, uses socket of engine (ex: DOCKER_HOST), empty - disabled" default:"docker"`
+	//
+	Info    Info    `command:"info" description:"print version, capabilities and effective configuration without starting server"`
+	Migrate Migrate `command:"migrate" description:"migrate legacy state (aliases and access restrictions in manifests) to links and policies without starting server"`
 }
 
 type HttpServer struct {
 	GracefulShutdown time.Duration `long:"graceful-shutdown" env:"GRACEFUL_SHUTDOWN" description:"Interval before server shutdown" default:"15s" json:"graceful_shutdown"`
-	Bind             string        `long:"bind" env:"BIND" description:"Address to where bind HTTP server" default:"127.0.0.1:3434" json:"bind"`
+	DrainTimeout     time.Duration `long:"drain-timeout" env:"DRAIN_TIMEOUT" description:"Time for in-flight executions to finish on shutdown (new requests are rejected by 503), then lambdas are terminated" default:"30s" json:"drain_timeout"`
+	Bind             string        `long:"bind" env:"BIND" description:"Address to where bind HTTP server (empty - only listeners by --listen)" default:"127.0.0.1:3434" json:"bind"`
+	Listen           []string      `long:"listen" env:"LISTEN" env-delim:"," description:"Additional listener of the same endpoints: unix:/path/to/socket (plain HTTP) or TCP address (could be repeated)" json:"listen"`
+	SocketMode       string        `long:"socket-mode" env:"SOCKET_MODE" description:"Mode (octal) of unix sockets of listeners" default:"0660" json:"socket_mode"`
+	SocketOwner      string        `long:"socket-owner" env:"SOCKET_OWNER" description:"Owner of unix sockets of listeners: user, user:group or :group by name or ID (empty - owner of server process)" json:"socket_owner"`
+	SocketAddress    string        `long:"socket-address" env:"SOCKET_ADDRESS" description:"Client IP of requests by unix sockets, add it to trusted proxies to respect forwarded headers of local proxy" default:"127.0.0.1" json:"socket_address"`
 	TLS              bool          `long:"tls" env:"TLS" description:"Enable HTTPS serving with TLS" json:"tls"`
 	CertFile         string        `long:"cert-file" env:"CERT_FILE" description:"Path to certificate for TLS" default:"server.crt" json:"crt_file"`
 	KeyFile          string        `long:"key-file" env:"KEY_FILE" description:"Path to private key for TLS" default:"server.key" json:"key_file"`
+	AutoTLS          bool          `long:"auto-tls" env:"AUTO_TLS" description:"Enable HTTPS serving with certificates obtained and renewed automatically from ACME (Let's Encrypt) by HTTP-01 challenge" json:"auto_tls"`
+	Domains          []string      `long:"domain" env:"DOMAINS" env-delim:"," description:"Domain of automatic TLS certificate (could be repeated)" json:"domains"`
+	ACMEEmail        string        `long:"acme-email" env:"ACME_EMAIL" description:"Contact email of ACME account for notifications about certificates" json:"acme_email"`
+	ACMEDirectory    string        `long:"acme-directory" env:"ACME_DIRECTORY" description:"Directory URL of ACME server (empty - Let's Encrypt production)" json:"acme_directory"`
+	ACMESkipCheck    bool          `long:"acme-skip-check" env:"ACME_SKIP_CHECK" description:"Do not check on start that domains reach the server by HTTP on port 80" json:"acme_skip_check"`
+	CertCache        string        `long:"cert-cache" env:"CERT_CACHE" description:"Directory of cached automatic TLS certificates and ACME account key" default:".certs" json:"cert_cache"`
+	AdminSocket      string        `long:"admin-socket" env:"ADMIN_SOCKET" description:"Unix socket of admin API without token, accessible only by owner of server process (empty - disabled)" json:"admin_socket"`
+	HTTPBind         string        `long:"http-bind" env:"HTTP_BIND" description:"Address of plain HTTP listener with TLS: ACME challenges and requests redirected to HTTPS or served as is (empty - :80 with automatic TLS, disabled otherwise)" json:"http_bind"`
+	RedirectHTTP     bool          `long:"redirect-http" env:"REDIRECT_HTTP" description:"Redirect requests of plain HTTP listener to HTTPS instead of serving them" json:"redirect_http"`
 }
 
 type Queues struct {
@@ -61,12 +154,203 @@ type Queues struct {
 	Kind      string `long:"kind" env:"KIND" description:"Queue kind" default:"directory" choice:"directory" choice:"memory"`
 	Directory string `long:"directory" env:"DIRECTORY" description:"Directory for queues if kind is directory" default:".queues"`
 	Depth     int    `long:"depth" env:"DEPTH" description:"Depth for in-memory queue" default:"100"`
+	Workers   int    `long:"workers" env:"WORKERS" description:"Maximum number of concurrent executions of queued requests from all queues (zero - unlimited)"`
+	HighWater int64  `long:"high-water" env:"HIGH_WATER" description:"Depth of any queue over which server is not ready by /readyz (zero - not checked)"`
+	//
+	DeadLetters          string        `long:"dead-letters" env:"DEAD_LETTERS" description:"Directory of dead letters: requests not processed after all attempts (empty - dropped)" default:".dead-letters"`
+	DeadLettersLimit     int           `long:"dead-letters-limit" env:"DEAD_LETTERS_LIMIT" description:"Maximum number of dead letters per lambda, the oldest are removed (zero - unlimited)" default:"100"`
+	DeadLettersRetention time.Duration `long:"dead-letters-retention" env:"DEAD_LETTERS_RETENTION" description:"Time to keep dead letters (zero - forever)" default:"168h"`
 }
 
 type Policies struct {
 	Config string `long:"config" env:"CONFIG" description:"Path to policies configuration file" default:"policies.json"`
 }
 
+type SFTP struct {
+	Bind    string `long:"bind" env:"BIND" description:"Address to where bind SFTP server for deploys (empty - disabled)"`
+	HostKey string `long:"host-key" env:"HOST_KEY" description:"Path to SFTP host key. If not exists - it will be generated" default:".sftp_host_key"`
+	Keys    string `long:"keys" env:"KEYS" description:"Path to configuration file of SFTP deployers keys" default:"sftp.json"`
+}
+
+type Mirror struct {
+	Primary  string        `long:"primary" env:"PRIMARY" description:"Base URL of primary server: server is read-only mirror of primary (empty - disabled)"`
+	Login    string        `long:"login" env:"LOGIN" description:"Admin login on primary server" default:"admin"`
+	Password string        `long:"password" env:"PASSWORD" description:"Admin password on primary server"`
+	Interval time.Duration `long:"interval" env:"INTERVAL" description:"Interval between synchronizations with primary" default:"30s"`
+	State    string        `long:"state" env:"STATE" description:"Location of mirror state (promotion to primary)" default:".mirror.json"`
+	Notify   string        `long:"notify" env:"NOTIFY" description:"Notification action <lambda UID or link>:<action> invoked when mirror diverges from primary or becomes consistent"`
+}
+
+type Async struct {
+	Depth      int           `long:"depth" env:"DEPTH" description:"Maximum number of pending asynchronous invocations (zero - disabled)" default:"100"`
+	Workers    int           `long:"workers" env:"WORKERS" description:"Number of concurrent asynchronous invocations" default:"4"`
+	TTL        time.Duration `long:"ttl" env:"TTL" description:"Time to keep results of asynchronous invocations" default:"1h"`
+	MaxPayload int64         `long:"max-payload" env:"MAX_PAYLOAD" description:"Maximum size of request body of asynchronous invocation in bytes (zero - limited only by lambda)" default:"10485760"`
+	Dir        string        `long:"dir" env:"DIR" description:"Directory of pending asynchronous invocations to survive restart (empty - kept only in memory)" default:".async"`
+}
+
+// queue of asynchronous invocations (nil if disabled) with restored pending invocations
+func (cfg *Async) Open() (*server.AsyncQueue, error) {
+	if cfg.Depth <= 0 {
+		return nil, nil
+	}
+	return server.NewAsyncQueue(server.AsyncConfig{Depth: cfg.Depth, Workers: cfg.Workers, TTL: cfg.TTL, MaxPayload: cfg.MaxPayload, Dir: cfg.Dir})
+}
+
+type Secrets struct {
+	File    string `long:"file" env:"FILE" description:"Encrypted store of secrets referenced by environment of lambdas as @secret:<name>" default:".secrets.json"`
+	Key     string `long:"key" env:"KEY" description:"Key (passphrase) of encryption of secrets store (empty - from key file)"`
+	KeyFile string `long:"key-file" env:"KEY_FILE" description:"File with key of encryption of secrets store (without key and key file - store is disabled)"`
+}
+
+func (cfg *Secrets) Enabled() bool {
+	return cfg.Key != "" || cfg.KeyFile != ""
+}
+
+// store of secrets (nil if disabled) opened by key from flag or file
+func (cfg *Secrets) Open() (*secrets.Store, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	key := []byte(cfg.Key)
+	if len(key) == 0 {
+		data, err := ioutil.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read key of secrets: %w", err)
+		}
+		key = bytes.TrimRight(data, "\r\n")
+	}
+	store, err := secrets.Open(cfg.File, key)
+	if err != nil {
+		return nil, fmt.Errorf("open secrets: %w", err)
+	}
+	return store, nil
+}
+
+type Argon2 struct {
+	Time    uint32 `long:"time" env:"TIME" description:"Number of passes of argon2id hashing of admin password" default:"3"`
+	Memory  uint32 `long:"memory" env:"MEMORY" description:"Memory in KiB of argon2id hashing of admin password (per login)" default:"65536"`
+	Threads uint8  `long:"threads" env:"THREADS" description:"Parallelism of argon2id hashing of admin password" default:"4"`
+}
+
+func (cfg *Argon2) Hashing() services.PasswordHashing {
+	return services.PasswordHashing{Time: cfg.Time, Memory: cfg.Memory, Threads: cfg.Threads}
+}
+
+type Login struct {
+	DelayAfter int           `long:"delay-after" env:"DELAY_AFTER" description:"Failed logins of account or client address before next attempts are delayed" default:"3"`
+	Delay      time.Duration `long:"delay" env:"DELAY" description:"Initial delay of login after failures, doubled by every failure (zero - not delayed)" default:"500ms"`
+	MaxDelay   time.Duration `long:"max-delay" env:"MAX_DELAY" description:"Maximum delay of login after failures" default:"8s"`
+	Attempts   int           `long:"attempts" env:"ATTEMPTS" description:"Failed logins of account or client address before lockout (zero - never locked out)" default:"10"`
+	Lockout    time.Duration `long:"lockout" env:"LOCKOUT" description:"Time of lockout, failures older than lockout are forgotten" default:"15m"`
+}
+
+func (cfg *Login) Limits() services.LoginLimits {
+	return services.LoginLimits{DelayAfter: cfg.DelayAfter, Delay: cfg.Delay, MaxDelay: cfg.MaxDelay, Attempts: cfg.Attempts, Lockout: cfg.Lockout}
+}
+
+type WarmUp struct {
+	Enabled   bool          `long:"enabled" env:"ENABLED" description:"Verify availability of lambdas on start and rebuild absent environment of lambdas with warm_up in manifest (in background)"`
+	Parallel  int           `long:"parallel" env:"PARALLEL" description:"Number of lambdas warmed up at the same time" default:"4"`
+	TimeLimit time.Duration `long:"time-limit" env:"TIME_LIMIT" description:"Time limit of action which rebuilds environment of lambda" default:"10m"`
+}
+
+type Webhooks struct {
+	URL     []string      `long:"url" env:"URL" env-delim:"," description:"URL of webhook of lifecycle events of all lambdas (could be repeated)"`
+	Event   []string      `long:"event" env:"EVENT" env-delim:"," description:"Type of delivered event: lambda.created, lambda.updated, lambda.deleted, invocation.failed, schedule.missed, deploy.failed (empty - all, could be repeated)"`
+	Secret  string        `long:"secret" env:"SECRET" description:"Secret of HMAC signature of deliveries (empty - not signed)"`
+	Payload bool          `long:"payload" env:"PAYLOAD" description:"Include request, output and stderr of failed invocations"`
+	Queue   int           `long:"queue" env:"QUEUE" description:"Maximum of pending deliveries, events over the limit are dropped" default:"1024"`
+	Retries int           `long:"retries" env:"RETRIES" description:"Retries of failed delivery" default:"5"`
+	Backoff time.Duration `long:"backoff" env:"BACKOFF" description:"Delay before the first retry, doubled for every next retry" default:"1s"`
+	Timeout time.Duration `long:"timeout" env:"TIMEOUT" description:"Time limit of single attempt of delivery" default:"10s"`
+}
+
+// options of notifier with validated webhooks of server (webhooks of lambdas are delivered by the same options)
+func (cfg *Webhooks) Options() (webhooks.Options, error) {
+	var options = webhooks.Options{Queue: cfg.Queue, Retries: cfg.Retries, Backoff: cfg.Backoff, Timeout: cfg.Timeout}
+	for _, u := range cfg.URL {
+		webhook := types.LifecycleWebhook{URL: u, Events: cfg.Event, Secret: cfg.Secret, Payload: cfg.Payload}
+		if err := webhook.Validate(); err != nil {
+			return options, fmt.Errorf("webhook %s: %w", u, err)
+		}
+		options.Webhooks = append(options.Webhooks, webhook)
+	}
+	return options, nil
+}
+
+type JSONLog struct {
+	Output  string `long:"output" env:"OUTPUT" description:"Structured log of invocations and administrative changes in JSON lines: file or - for stdout (empty - disabled)"`
+	MaxSize int64  `long:"max-size" env:"MAX_SIZE" description:"Size of log file in bytes to rotate (zero - not rotated)" default:"104857600"`
+	Backups int    `long:"backups" env:"BACKUPS" description:"Number of rotated log files to keep" default:"5"`
+}
+
+// structured log (nil if disabled) and function to close its file
+func (cfg *JSONLog) Open() (*jsonlog.Logger, func(), error) {
+	switch cfg.Output {
+	case "":
+		return nil, func() {}, nil
+	case "-":
+		return jsonlog.New(os.Stdout), func() {}, nil
+	}
+	file, err := jsonlog.OpenFile(cfg.Output, cfg.MaxSize, cfg.Backups)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open json log: %w", err)
+	}
+	return jsonlog.New(file), func() { _ = file.Close() }, nil
+}
+
+// mirror of primary (nil if disabled), replication is started in background unless mirror is promoted
+func (cfg *Mirror) Start(ctx context.Context, useCases mirror.Cases, policies application.Policies, dir string) (*mirror.Mirror, error) {
+	if cfg.Primary == "" {
+		return nil, nil
+	}
+	standby, err := mirror.New(useCases, policies, dir, mirror.NewPrimary(cfg.Primary, cfg.Login, cfg.Password), cfg.State)
+	if err != nil {
+		return nil, err
+	}
+	standby.Interval = cfg.Interval
+	standby.Notify = cfg.Notify
+	if standby.Primary() == "" {
+		log.Println("[WARN]", "mirror of", cfg.Primary, "is promoted to primary (see", cfg.State+"), replication is disabled")
+		return standby, nil
+	}
+	log.Println("read-only mirror of", cfg.Primary)
+	go standby.Run(ctx)
+	return standby, nil
+}
+
+// serve SFTP deploy endpoint in background if enabled
+func (cfg *SFTP) Serve(ctx context.Context, platform sftpd.Platform, changes application.Journal) error {
+	if cfg.Bind == "" {
+		return nil
+	}
+	hostKey, err := sftpd.HostKey(cfg.HostKey)
+	if err != nil {
+		return fmt.Errorf("load SFTP host key: %w", err)
+	}
+	keys, err := sftpd.LoadConfig(cfg.Keys)
+	if err != nil {
+		return fmt.Errorf("load SFTP keys: %w", err)
+	}
+	srv, err := sftpd.New(platform, hostKey, *keys)
+	if err != nil {
+		return err
+	}
+	srv.Journal = changes
+	listener, err := net.Listen("tcp", cfg.Bind)
+	if err != nil {
+		return err
+	}
+	log.Println("SFTP server is on", cfg.Bind)
+	go func() {
+		if err := srv.Serve(ctx, listener); err != nil {
+			log.Println("[ERROR]", "SFTP server stopped:", err)
+		}
+	}()
+	return nil
+}
+
 func (q *Queues) Factory() (queuemanager.QueueFactory, error) {
 	switch q.Kind {
 	case "directory":
@@ -82,47 +366,207 @@ func (q *Queues) Factory() (queuemanager.QueueFactory, error) {
 	}
 }
 
-func (qs *HttpServer) Serve(globalCtx context.Context, handler http.Handler) error {
+// options of queue manager. Store of dead letters (if enabled) is pruned in background till context done
+func (q *Queues) Options(ctx context.Context) queuemanager.Options {
+	options := queuemanager.Options{Workers: q.Workers}
+	if q.DeadLetters != "" {
+		store := deadletter.New(q.DeadLetters, q.DeadLettersLimit, q.DeadLettersRetention)
+		go store.Run(ctx)
+		options.DeadLetters = store
+	}
+	return options
+}
 
+// Serve handler till global context is done, then server is drained (see drain) and shut down. Listeners passed by
+// systemd socket activation are used instead of binding of the same kind (see SystemdListeners), readiness and
+// shutdown are reported to systemd (if started by it)
+func (qs *HttpServer) Serve(globalCtx context.Context, handler http.Handler, drain func(timeout time.Duration)) error {
+	manager, err := qs.certManager()
+	if err != nil {
+		return err
+	}
+	activated, err := internal.SystemdListeners()
+	if err != nil {
+		return err
+	}
+	var inherited []net.Listener // public listeners of systemd
+	for name, list := range activated {
+		if name != adminSocketName && name != plainSocketName {
+			inherited = append(inherited, list...)
+		}
+	}
+	listeners, err := qs.listen(inherited)
+	if err != nil {
+		return err
+	}
+	closeListeners := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
 	srv := http.Server{
 		Addr:    qs.Bind,
 		Handler: handler,
 	}
+	if manager != nil {
+		srv.TLSConfig = manager.TLSConfig()
+	}
+	// requests by unix sockets are plain HTTP from local peers (ex: reverse proxy) with synthetic address of client
+	sockets := http.Server{Handler: server.Socket(handler, qs.SocketAddress)}
+	var plain *http.Server // nil if disabled
+	plainListeners := activated[plainSocketName]
+	if addr := qs.plainBind(); addr != "" && len(plainListeners) == 0 {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			closeListeners()
+			return fmt.Errorf("listen plain HTTP: %w", err)
+		}
+		plainListeners = append(plainListeners, listener)
+	}
+	if len(plainListeners) > 0 {
+		token, err := newProbeToken()
+		if err != nil {
+			closeListeners()
+			return fmt.Errorf("generate probe token: %w", err)
+		}
+		plain = &http.Server{Handler: qs.plainHandler(manager, handler, token)}
+		for _, listener := range plainListeners {
+			go func(listener net.Listener) {
+				if err := plain.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Println("[ERROR]", "plain HTTP server:", err)
+				}
+			}(listener)
+			log.Println("plain HTTP server is on", listener.Addr())
+		}
+		if manager != nil && !qs.ACMESkipCheck {
+			if err := checkDomains(globalCtx, qs.Domains, token); err != nil {
+				_ = plain.Close()
+				closeListeners()
+				return fmt.Errorf("check domains of automatic TLS: %w", err)
+			}
+		}
+	}
 
+	var local *http.Server // nil if disabled
+	adminListeners := activated[adminSocketName]
+	if qs.AdminSocket != "" && len(adminListeners) == 0 {
+		listener, err := server.ListenAdminSocket(qs.AdminSocket)
+		if err != nil {
+			if plain != nil {
+				_ = plain.Close()
+			}
+			closeListeners()
+			return err
+		}
+		adminListeners = append(adminListeners, listener)
+	}
+	if len(adminListeners) > 0 {
+		local = &http.Server{Handler: server.Local(server.Socket(handler, qs.SocketAddress))}
+		for _, listener := range adminListeners {
+			go func(listener net.Listener) {
+				if err := local.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Println("[ERROR]", "admin socket:", err)
+				}
+			}(listener)
+			log.Println("admin socket is on", listener.Addr())
+		}
+	}
+
+	shutdown := make(chan struct{})
 	go func() {
+		defer close(shutdown)
 		<-globalCtx.Done()
+		if err := internal.SystemdNotify(internal.NotifyStopping); err != nil {
+			log.Println("[WARN]", err)
+		}
+		// listener is kept opened while draining: new requests are rejected by 503 instead of refused connections
+		drain(qs.DrainTimeout)
 		ctx, cancel := context.WithTimeout(context.Background(), qs.GracefulShutdown)
 		defer cancel()
+		if plain != nil {
+			_ = plain.Shutdown(ctx)
+		}
+		if local != nil {
+			_ = local.Shutdown(ctx)
+		}
 		srv.Shutdown(ctx)
+		sockets.Shutdown(ctx)
 	}()
-	log.Println("REST server is on", qs.Bind)
-	if qs.TLS {
-		return srv.ListenAndServeTLS(qs.CertFile, qs.KeyFile)
+	done := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l listener) {
+			done <- qs.serve(&srv, &sockets, manager, l)
+		}(l)
 	}
-	return srv.ListenAndServe()
+	if err := internal.SystemdNotify(internal.NotifyReady); err != nil {
+		log.Println("[WARN]", err)
+	}
+	// server is stopped by the first stopped listener, shutdown closes all listeners
+	err = <-done
+	if errors.Is(err, http.ErrServerClosed) {
+		// wait for running invocations: lambdas are terminated gracefully (see grace_period)
+		<-shutdown
+	}
+	return err
 }
 
 func main() {
 	var config Config
 	parser := flags.NewParser(&config, flags.Default)
 	parser.LongDescription = "Easy CGI-like server for development\nAuthor: Baryshnikov Aleksandr <dev@baryshnikov.net>\nVersion: " + version
+	parser.SubcommandsOptional = true
 	_, err := parser.Parse()
 	if err != nil {
 		os.Exit(1)
 	}
+	info := serverInfo(parser, config)
+	if parser.Active != nil && parser.Active.Name == "info" {
+		if err := config.Info.print(info); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if parser.Active != nil && parser.Active.Name == "migrate" {
+		if err := config.Migrate.run(config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	gctx, closer := internal.SignalContext()
 	defer closer()
-	err = run(gctx, config)
+	err = run(gctx, config, info)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run(ctx context.Context, config Config) error {
-	tracker, err := memlog.NewDumped(config.StatsFile, config.StatsCache)
+func run(ctx context.Context, config Config, info *api.ServerInfo) error {
+	log.Println("trusted-cgi", info.Version, "capabilities:", strings.Join(info.Capabilities, ", "))
+	// executions (invocations, queued requests, scheduled runs) outlive global context till server is drained
+	exec, kill := context.WithCancel(context.Background())
+	defer kill()
+	trustedProxies, err := types.ParseNetworks(config.TrustedProxies)
 	if err != nil {
+		return fmt.Errorf("trusted proxies: %w", err)
+	}
+	forwardedBy, err := types.ParseForwardedHeaders(config.ForwardedHeaders)
+	if err != nil {
+		return err
+	}
+	tracker, err := disklog.Open(config.StatsDir, stats.Retention{
+		MaxAge:     types.JsonDuration(config.StatsMaxAge),
+		MaxRecords: config.StatsMaxRecords,
+		MaxSize:    config.StatsMaxSize,
+	})
+	if err != nil {
+		return err
+	}
+	tracker.Pending = int(config.StatsCache)
+	if imported, err := tracker.Import(config.StatsFile); err != nil {
 		return err
+	} else if imported > 0 {
+		log.Println("imported", imported, "records of legacy stats from", config.StatsFile)
 	}
 
 	var defCfg application.Config
@@ -143,21 +587,45 @@ func run(ctx context.Context, config Config) error {
 	if err != nil {
 		return err
 	}
+	if err := applyProfile(basePlatform, config); err != nil {
+		return err
+	}
+	secretsStore, err := config.Secrets.Open()
+	if err != nil {
+		return err
+	}
+	if secretsStore != nil {
+		basePlatform.SetSecrets(secretsStore)
+	}
+	if config.ContainerEngine != "" {
+		basePlatform.SetContainers(containers.New(config.ContainerEngine))
+	}
 
 	queueFactory, err := config.Queues.Factory()
 	if err != nil {
 		return err
 	}
 
-	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(config.Queues.Config), basePlatform, queueFactory)
+	queueManager, err := queuemanager.New(exec, queuemanager.FileConfig(config.Queues.Config), basePlatform, queueFactory, config.Queues.Options(ctx))
 	if err != nil {
 		return err
 	}
+	basePlatform.SetQueues(queueManager)
 
 	useCases, err := cases.New(basePlatform, queueManager, policies, config.Dir, config.Templates)
 	if err != nil {
 		return err
 	}
+	if config.SchedulerState != "" {
+		schedulerState, err := scheduler.LoadState(config.SchedulerState)
+		if err != nil {
+			return err
+		}
+		useCases.SetSchedulerState(schedulerState)
+	}
+	if config.Captures != "" {
+		useCases.SetCaptures(capture.New(config.Captures))
+	}
 
 	if config.SSHKey != "" {
 		err = useCases.SetOrCreatePrivateSSHKeyFile(config.SSHKey)
@@ -166,39 +634,247 @@ func run(ctx context.Context, config Config) error {
 		}
 	}
 
-	projectApi := services.NewProjectSrv(useCases, tracker)
-	lambdaApi := services.NewLambdaSrv(useCases, tracker)
-	queuesApi := services.NewQueuesSrv(queueManager)
-	policiesApi := services.NewPoliciesSrv(policies)
-	userApi, err := services.CreateUserSrv(config.Config, config.InitialAdminPassword)
+	standby, err := config.Mirror.Start(ctx, useCases, policies, config.Dir)
 	if err != nil {
 		return err
 	}
+	var replication application.Mirror // nil interface if disabled
+	if standby != nil {
+		replication = standby
+	}
 
-	go runScheduler(ctx, config.SchedulerInterval, useCases)
+	changes, err := journal.New(config.ChangesFile)
+	if err != nil {
+		return err
+	}
+	changes.MaxSize, changes.Backups = config.ChangesMaxSize, config.ChangesBackups
+	structured, closeLog, err := config.JSONLog.Open()
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	webhookOptions, err := config.Webhooks.Options()
+	if err != nil {
+		return err
+	}
+	notifier := webhooks.New(basePlatform, webhookOptions)
+	notifier.Start(ctx)
+	basePlatform.SetMissedRuns(notifier)
+	changes.Observer = notifier.Change
+	if structured != nil {
+		changes.Observer = func(change application.Change) {
+			structured.Change(change)
+			notifier.Change(change)
+		}
+	}
 
-	defer tracker.Dump()
+	alertRules := alerts.New(ctx, basePlatform)
+	stores := []capacity.Store{{Name: "stats", Path: config.StatsDir}, {Name: "changes", Path: config.ChangesFile}, {Name: "templates", Path: config.Templates}, {Name: "logs", Path: filepath.Join(config.Dir, internal2.LogsDir)}}
+	if config.Queues.Kind == "directory" {
+		stores = append(stores, capacity.Store{Name: "queues", Path: config.Queues.Directory})
+	}
+	if config.Queues.DeadLetters != "" {
+		stores = append(stores, capacity.Store{Name: "dead-letters", Path: config.Queues.DeadLetters})
+	}
+	if config.Captures != "" {
+		stores = append(stores, capacity.Store{Name: "captures", Path: config.Captures})
+	}
+	if config.Async.Depth > 0 && config.Async.Dir != "" {
+		stores = append(stores, capacity.Store{Name: "async", Path: config.Async.Dir})
+	}
+	capacityReporter := capacity.New(basePlatform, queueManager, tracker, config.Dir, stores...)
+	searchIndex := search.New(basePlatform, config.SearchIndexFile, config.SearchMemory)
+	projectApi := services.NewProjectSrv(useCases, tracker, alertRules, info, capacityReporter, nil, replication, changes, searchIndex, config.PublicURL)
+	lambdaApi := services.NewLambdaSrv(useCases, tracker, alertRules, nil, changes)
+	asyncQueue, err := config.Async.Open()
+	if err != nil {
+		return err
+	}
+	var asyncInvocations application.AsyncQueue // nil interface if disabled
+	if asyncQueue != nil {
+		asyncInvocations = asyncQueue
+	}
+	queuesApi := services.NewQueuesSrv(queueManager, asyncInvocations)
+	policiesApi := services.NewPoliciesSrv(policies, changes)
+	userApi, err := services.CreateUserSrv(config.Config, config.InitialAdminPassword, config.Argon2.Hashing(), changes)
+	if err != nil {
+		return err
+	}
+	if err := userApi.SetLoginLimits(config.Login.Limits()); err != nil {
+		return fmt.Errorf("login limits: %w", err)
+	}
+	projectApi.SetWebhooks(notifier)
+	backups := backup.New(useCases, policies)
+	backups.Tokens, backups.Version = userApi, version
+	projectApi.SetBackups(backups)
+	projectApi.SetArtifacts(artifact.New(basePlatform, config.Dir))
+	frozen, err := freezer.New(basePlatform, filepath.Join(config.Dir, internal2.FrozenDir))
+	if err != nil {
+		return err
+	}
+	frozen.Keep = config.FrozenKeep
+	backups.Freezer = frozen
+	lambdaApi.SetFreezer(frozen)
+	if config.VersionsKeep != 0 {
+		store, err := versions.New(basePlatform, config.Dir, filepath.Join(config.Dir, internal2.VersionsDir))
+		if err != nil {
+			return err
+		}
+		store.Keep, store.MaxSize = config.VersionsKeep, config.VersionsMaxSize
+		lambdaApi.SetVersions(store)
+	}
+	deployer, err := gitdeploy.New(basePlatform, config.Dir)
+	if err != nil {
+		return err
+	}
+	deployer.Journal, deployer.Observer = changes, notifier
+	if config.SSHKey != "" {
+		// git runs in clone of repository
+		if deployer.SSHKey, err = filepath.Abs(config.SSHKey); err != nil {
+			return err
+		}
+	}
+	lambdaApi.SetGitDeploys(deployer)
+
+	var warm *warmup.WarmUp
+	if config.WarmUp.Enabled {
+		warm = warmup.New(basePlatform)
+		warm.Parallel, warm.TimeLimit = config.WarmUp.Parallel, config.WarmUp.TimeLimit
+		go warm.Run(exec)
+	}
+	useCases.StartLambdas(exec)
+	if replication == nil || replication.Primary() == "" {
+		useCases.CatchUpScheduledActions(exec)
+		// content of mirror is replicated from primary
+		deployer.Start(exec)
+	}
+	scheduled := make(chan struct{})
+	go func() {
+		defer close(scheduled)
+		runScheduler(ctx, exec, config.SchedulerInterval, useCases, replication)
+	}()
+
+	reloader := reload.New(basePlatform, config.Dir)
+	reloader.Journal = changes
+	if config.SecurityProfile == types.ProfileCustom {
+		reloader.ProfileFile = config.SecurityProfileFile
+		reloader.LoadProfile = func() (types.SecurityProfile, error) {
+			return loadProfile(config)
+		}
+	}
+	projectApi.SetReloader(reloader)
+	go reloadOnSignal(ctx, reloader)
+	go watchFiles(ctx, reloader, config)
+
+	if replication != nil && replication.Primary() != "" && config.SFTP.Bind != "" {
+		log.Println("[WARN]", "SFTP server is disabled on read-only mirror")
+	} else if err := config.SFTP.Serve(ctx, basePlatform, changes); err != nil {
+		return err
+	}
+
+	defer tracker.Close()
 	go dumpTracker(ctx, config.StatsInterval, tracker)
 
 	srv := &server.Server{
-		Policies:     policies,
-		Platform:     basePlatform,
-		Cases:        useCases,
-		Queues:       queueManager,
-		Dev:          config.Dev,
-		BehindProxy:  config.BehindProxy,
-		Tracker:      tracker,
-		TokenHandler: userApi,
-		ProjectAPI:   projectApi,
-		LambdaAPI:    lambdaApi,
-		UserAPI:      userApi,
-		QueuesAPI:    queuesApi,
-		PoliciesAPI:  policiesApi,
-	}
-
-	handler := srv.Handler(ctx)
-	log.Println("running on", config.Bind)
-	return config.Serve(ctx, handler)
+		Policies:       policies,
+		Platform:       basePlatform,
+		Cases:          useCases,
+		Queues:         queueManager,
+		Alerts:         alertRules,
+		Mirror:         replication,
+		Dev:            config.Dev,
+		BehindProxy:    config.BehindProxy,
+		TrustedProxies: trustedProxies,
+		ForwardedBy:    forwardedBy,
+		PublicURL:      config.PublicURL,
+		RemoveHeaders:  config.RemoveHeaders,
+		Async:          asyncQueue,
+		DataDir:        config.Dir,
+		QueueHighWater: config.Queues.HighWater,
+		Tracker:        tracker,
+		Stats:          tracker,
+		TokenHandler:   userApi,
+		ProjectAPI:     projectApi,
+		LambdaAPI:      lambdaApi,
+		UserAPI:        userApi,
+		QueuesAPI:      queuesApi,
+		PoliciesAPI:    policiesApi,
+		GitDeploys:     deployer,
+	}
+	srv.Webhooks = notifier
+	if warm != nil {
+		srv.WarmUp = warm
+	}
+	if structured != nil {
+		srv.InvocationLog = structured
+	}
+	if config.StatusPages != "" {
+		pages, err := statuspage.Load(config.StatusPages)
+		if err != nil {
+			return err
+		}
+		statusPages := statuspage.New(basePlatform, tracker, alertRules, pages)
+		statusPages.Start(ctx)
+		srv.StatusPages = statusPages
+	}
+	if config.Metrics {
+		metrics := prometheus.New()
+		metrics.Scheduler = useCases
+		metrics.Mirror = replication
+		metrics.Streams = srv
+		srv.Metrics = metrics
+	}
+	queueManager.SetRecorder(srv)
+	basePlatform.SetRecorder(srv)
+
+	handler := srv.Handler(exec)
+	if timeout := internal.SystemdWatchdog(); timeout > 0 {
+		go watchdog(exec, timeout, srv)
+	}
+	defer func() {
+		// workers are stopped before the last dump of stats: records of drained executions are kept
+		kill()
+		queueManager.Wait()
+	}()
+	return config.Serve(ctx, handler, func(timeout time.Duration) {
+		drain(timeout, srv, scheduled, kill)
+	})
+}
+
+// ping watchdog of systemd twice per timeout while server is alive (see liveness probe): service is restarted by
+// systemd if checks fail for the whole timeout. Pings are sent while server is drained
+func watchdog(ctx context.Context, timeout time.Duration, srv *server.Server) {
+	log.Println("systemd watchdog is enabled, timeout", timeout)
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		if problems := srv.Alive(); len(problems) > 0 {
+			log.Println("[WARN]", "watchdog is not notified:", strings.Join(problems, "; "))
+		} else if err := internal.SystemdNotify(internal.NotifyWatchdog); err != nil {
+			log.Println("[WARN]", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drain server before shutdown: new requests are rejected, queued requests and schedules are not started. In-flight
+// executions have timeout to finish, then running lambdas are terminated (see grace_period of manifest)
+func drain(timeout time.Duration, srv *server.Server, scheduled <-chan struct{}, kill func()) {
+	defer kill()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	log.Println("shutdown: draining in-flight executions up to", timeout)
+	inFlight, running := srv.Drain(ctx)
+	select {
+	case <-scheduled:
+	case <-ctx.Done():
+		log.Println("[WARN]", "shutdown: scheduled runs are not finished in", timeout)
+	}
+	log.Println("shutdown:", inFlight-running, "of", inFlight, "in-flight invocations drained,", running, "killed")
 }
 
 func dumpTracker(ctx context.Context, each time.Duration, tracker interface {
@@ -219,7 +895,45 @@ func dumpTracker(ctx context.Context, each time.Duration, tracker interface {
 	}
 }
 
-func runScheduler(ctx context.Context, each time.Duration, runner application.Cases) {
+// reload files edited on disk on SIGHUP
+func reloadOnSignal(ctx context.Context, reloader *reload.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+			log.Println("reloading files by SIGHUP")
+			reloader.All(reload.SourceSignal)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// files of lambdas are remembered for reload, then files are watched and/or scanned (if enabled)
+func watchFiles(ctx context.Context, reloader *reload.Reloader, config Config) {
+	reloader.Snapshot()
+	if config.WatchScan > 0 {
+		go func() {
+			_ = reloader.Scan(ctx, config.WatchScan)
+		}()
+	}
+	if !config.WatchFiles {
+		return
+	}
+	if err := reloader.Watch(ctx, config.WatchDebounce); err != nil {
+		log.Println("[ERROR]", "watcher of files stopped:", err)
+		if config.WatchScan <= 0 {
+			log.Println("[WARN]", "files are scanned every", reload.DefaultScanInterval, "instead of watcher")
+			_ = reloader.Scan(ctx, 0)
+		}
+	}
+}
+
+// scheduled actions are skipped while server is read-only mirror (mirror is optional)
+// scheduler is stopped by context, scheduled actions are executed by exec context
+func runScheduler(ctx, exec context.Context, each time.Duration, runner application.Cases, standby application.Mirror) {
 	t := time.NewTicker(each)
 	defer t.Stop()
 	for {
@@ -228,6 +942,10 @@ func runScheduler(ctx context.Context, each time.Duration, runner application.Ca
 		case <-ctx.Done():
 			return
 		}
-		runner.RunScheduledActions(ctx)
+		if standby != nil && standby.Primary() != "" {
+			runner.SkipScheduledActions()
+			continue
+		}
+		runner.RunScheduledActions(exec)
 	}
 }
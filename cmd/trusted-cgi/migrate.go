@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/cases"
+	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/application/policy"
+	internal2 "github.com/reddec/trusted-cgi/internal"
+)
+
+type Migrate struct {
+	DryRun bool `long:"dry-run" env:"DRY_RUN" description:"show migration plan without changes"`
+	JSON   bool `long:"json" env:"JSON" description:"print report as JSON"`
+}
+
+// migrate legacy state of lambdas without starting server. Fails if some lambdas could not be migrated
+func (cmd *Migrate) run(config Config) error {
+	policies, err := policy.New(policy.FileConfig(config.Policies.Config))
+	if err != nil {
+		return err
+	}
+	basePlatform, err := platform.New(filepath.Join(config.Dir, internal2.ProjectManifest))
+	if err != nil {
+		return err
+	}
+	// queues are not used by migration
+	useCases, err := cases.Load(basePlatform, nil, policies, config.Dir, config.Templates)
+	if err != nil {
+		return err
+	}
+	report, err := useCases.Migrate(cmd.DryRun)
+	if err != nil {
+		return err
+	}
+	if err := cmd.print(report); err != nil {
+		return err
+	}
+	if report.After.LegacyLambdas > 0 {
+		return fmt.Errorf("%d lambda(s) could not be migrated", report.After.LegacyLambdas)
+	}
+	return nil
+}
+
+func (cmd *Migrate) print(report *application.MigrationReport) error {
+	if cmd.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, lambda := range report.Lambdas {
+		status := "migrated"
+		switch {
+		case !lambda.Migratable():
+			status = "not migratable"
+		case report.DryRun:
+			status = "will be migrated"
+		}
+		fmt.Println(lambda.UID, "-", status)
+		for _, issue := range lambda.Issues {
+			fmt.Println("    [!]", issue.Item+":", issue.Reason)
+		}
+		if !lambda.Migratable() {
+			continue
+		}
+		for _, alias := range lambda.Aliases {
+			fmt.Println("    link alias", alias)
+		}
+		if lambda.Policy != "" {
+			fmt.Println("    apply policy", lambda.Policy)
+		}
+		fmt.Println("    backup manifest to", lambda.Backup)
+	}
+	after := "after"
+	if report.DryRun {
+		after = "expected"
+	}
+	fmt.Printf("%-16s %8s %8s\n", "", "before", after)
+	fmt.Printf("%-16s %8d %8d\n", "legacy lambdas", report.Before.LegacyLambdas, report.After.LegacyLambdas)
+	fmt.Printf("%-16s %8d %8d\n", "legacy aliases", report.Before.LegacyAliases, report.After.LegacyAliases)
+	fmt.Printf("%-16s %8d %8d\n", "legacy policies", report.Before.LegacyPolicies, report.After.LegacyPolicies)
+	fmt.Printf("%-16s %8d %8d\n", "links", report.Before.Links, report.After.Links)
+	fmt.Printf("%-16s %8d %8d\n", "policies", report.Before.Policies, report.After.Policies)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// apply security profile to platform: profile without debug features refuses dev mode and disabled chroot, initial
+// chroot user is used if profile requires user and it is not set yet. Server-wide default cap of response replaces
+// default of profile
+func applyProfile(pl application.Platform, config Config) error {
+	profile, err := loadProfile(config)
+	if err != nil {
+		return err
+	}
+	if cfg := pl.Config(); profile.RequireRunner && cfg.User == "" && config.InitialChrootUser != "" {
+		cfg.User = config.InitialChrootUser
+		if err := pl.SetConfig(cfg); err != nil {
+			return fmt.Errorf("set initial chroot user: %w", err)
+		}
+	}
+	if err := pl.SetProfile(profile); err != nil {
+		return fmt.Errorf("%w (see --initial-chroot-user)", err)
+	}
+	if profile.Name != types.ProfileDefault {
+		log.Println("security profile", profile.Name)
+	}
+	return nil
+}
+
+// security profile by flags (also used for reload of custom profile)
+func loadProfile(config Config) (types.SecurityProfile, error) {
+	profile, err := types.LoadProfile(config.SecurityProfile, config.SecurityProfileFile)
+	if err != nil {
+		return profile, err
+	}
+	if config.MaximumResponse < 0 {
+		return profile, fmt.Errorf("maximum response should not be negative")
+	}
+	if config.MaximumResponse > 0 {
+		profile.MaximumResponse = config.MaximumResponse
+	}
+	if config.MaxGracePeriod < 0 {
+		return profile, fmt.Errorf("max grace period should not be negative")
+	}
+	if config.MaxGracePeriod > 0 {
+		profile.MaxGracePeriod = types.JsonDuration(config.MaxGracePeriod)
+	}
+	if config.UploadMaxFiles < 0 || config.UploadMaxSize < 0 || config.UploadMaxDepth < 0 || config.UploadMaxFileSize < 0 {
+		return profile, fmt.Errorf("upload limits should not be negative")
+	}
+	if config.UploadMaxFiles > 0 {
+		profile.Upload.Files = config.UploadMaxFiles
+	}
+	if config.UploadMaxSize > 0 {
+		profile.Upload.Size = config.UploadMaxSize
+	}
+	if config.UploadMaxDepth > 0 {
+		profile.Upload.Depth = config.UploadMaxDepth
+	}
+	if config.UploadMaxFileSize > 0 {
+		profile.Upload.FileSize = config.UploadMaxFileSize
+	}
+	profile.RunAsUsers = append(profile.RunAsUsers, config.RunAsUsers...)
+	profile.RunAsGroups = append(profile.RunAsGroups, config.RunAsGroups...)
+	if profile.DisableDebug && (config.Dev || config.DisableChroot) {
+		return profile, fmt.Errorf("security profile %s forbids dev mode and disabled chroot", profile.Name)
+	}
+	return profile, nil
+}
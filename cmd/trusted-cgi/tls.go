@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// path of plain HTTP listener which responds by token of the server (see checkDomains)
+const probePath = "/.well-known/trusted-cgi-probe"
+
+// time of check of single domain
+const probeTimeout = 10 * time.Second
+
+// manager of certificates obtained from ACME (nil if automatic TLS is disabled). Certificates are cached in directory
+// and renewed by manager before expiration
+func (qs *HttpServer) certManager() (*autocert.Manager, error) {
+	if !qs.AutoTLS {
+		return nil, nil
+	}
+	if qs.TLS {
+		return nil, fmt.Errorf("automatic TLS could not be used with certificate files (--tls)")
+	}
+	if len(qs.Domains) == 0 {
+		return nil, fmt.Errorf("automatic TLS requires at least one domain (--domain)")
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(qs.CertCache),
+		HostPolicy: autocert.HostWhitelist(qs.Domains...),
+		Email:      qs.ACMEEmail,
+	}
+	if qs.ACMEDirectory != "" {
+		manager.Client = &acme.Client{DirectoryURL: qs.ACMEDirectory}
+	}
+	return manager, nil
+}
+
+// address of plain HTTP listener in addition to TLS listener (empty - disabled). It is required with automatic TLS for
+// HTTP-01 challenges
+func (qs *HttpServer) plainBind() string {
+	switch {
+	case !qs.TLS && !qs.AutoTLS:
+		return ""
+	case qs.HTTPBind == "" && qs.AutoTLS:
+		return ":80"
+	default:
+		return qs.HTTPBind
+	}
+}
+
+// handler of plain HTTP listener: ACME challenges (if manager is set), probe of domains by token, then redirect to
+// HTTPS or the same handler as TLS listener
+func (qs *HttpServer) plainHandler(manager *autocert.Manager, handler http.Handler, token string) http.Handler {
+	fallback := handler
+	if qs.RedirectHTTP {
+		fallback = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodGet && request.Method != http.MethodHead {
+				http.Error(writer, "use HTTPS", http.StatusBadRequest)
+				return
+			}
+			target := "https://" + httpsHost(request.Host, qs.Bind) + request.URL.RequestURI()
+			http.Redirect(writer, request, target, http.StatusFound)
+		})
+	}
+	if manager != nil {
+		fallback = manager.HTTPHandler(fallback)
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == probePath {
+			_, _ = io.WriteString(writer, token)
+			return
+		}
+		fallback.ServeHTTP(writer, request)
+	})
+}
+
+// host of request with port of TLS listener (omitted for 443)
+func httpsHost(host, bind string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	_, port, err := net.SplitHostPort(bind)
+	if err != nil || port == "443" || port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// check that every domain resolves and reaches plain HTTP listener of this server on port 80 (as ACME server does
+// for HTTP-01 challenge): otherwise certificates are never issued and every TLS handshake would fail
+func checkDomains(ctx context.Context, domains []string, token string) error {
+	client := &http.Client{
+		Timeout: probeTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var errs []error
+	for _, domain := range domains {
+		if err := checkDomain(ctx, client, domain, token); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkDomain(ctx context.Context, client *http.Client, domain string, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("domain %s does not resolve: %w", domain, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+domain+probePath, nil)
+	if err != nil {
+		return fmt.Errorf("domain %s: %w", domain, err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("domain %s (%s) does not reach this server by HTTP on port 80 (required by ACME HTTP-01 challenge): %w", domain, strings.Join(addrs, ", "), err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, int64(len(token))+1))
+	if res.StatusCode != http.StatusOK || string(data) != token {
+		return fmt.Errorf("domain %s (%s) is served by another server on port 80 (status %d)", domain, strings.Join(addrs, ", "), res.StatusCode)
+	}
+	return nil
+}
+
+func newProbeToken() (string, error) {
+	var data [16]byte
+	if _, err := io.ReadFull(rand.Reader, data[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data[:]), nil
+}
@@ -4,24 +4,30 @@ go 1.21
 
 require (
 	github.com/alecthomas/units v0.0.0-20231202071711-9a357b53e9c9
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/google/uuid v1.5.0
 	github.com/jessevdk/go-flags v1.5.0
+	github.com/pkg/sftp v1.13.6
 	github.com/reddec/dfq v0.0.0-20200905054932-718696ac508f
 	github.com/reddec/jsonrpc2 v0.1.21
 	github.com/robfig/cron v1.2.0
-	github.com/stretchr/testify v1.5.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/stretchr/testify v1.8.0
 	github.com/tinylib/msgp v1.1.9
 	golang.org/x/crypto v0.17.0
+	golang.org/x/net v0.19.0
+	golang.org/x/sys v0.15.0
 )
 
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/net v0.19.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0
 	golang.org/x/term v0.15.0 // indirect
-	gopkg.in/yaml.v2 v2.2.8 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,20 @@
+package internal
+
+import "os/exec"
+
+// Command of availability checks (templates and manifests) which finds executables in PATH
+const LookupCommand = "which"
+
+// Lookup of executables by check `which <name>...` is done by exec.LookPath instead of running which, because which
+// is not available on all platforms (Windows). Returns false for other checks: they should be run as commands
+func LookupCheck(check []string) (bool, error) {
+	if len(check) < 2 || check[0] != LookupCommand {
+		return false, nil
+	}
+	for _, name := range check[1:] {
+		if _, err := exec.LookPath(name); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
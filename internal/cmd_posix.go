@@ -4,6 +4,7 @@ package internal
 
 import (
 	"github.com/reddec/trusted-cgi/types"
+	"os"
 	"os/exec"
 	"syscall"
 )
@@ -20,3 +21,32 @@ func SetCreds(cmd *exec.Cmd, creds *types.Credential) {
 		Gid: uint32(creds.Group),
 	}
 }
+
+// Signal which killed finished process (empty - process exited)
+func ExitSignal(state *os.ProcessState) string {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
+
+// File could be executed: any execute permission
+func IsExecutable(info os.FileInfo) bool {
+	return !info.IsDir() && info.Mode().Perm()&0111 != 0
+}
+
+// Server could run processes as other users (runs as root)
+func CanSwitchUser() bool {
+	return os.Geteuid() == 0
+}
+
+// Set file mode creation mask for the process. There is no portable way to set umask for the child only,
+// so command is wrapped by shell which sets umask and replaces itself by original command.
+// Umask should be validated before.
+func SetUmask(cmd *exec.Cmd, umask string) {
+	if umask == "" || cmd.Err != nil {
+		return
+	}
+	cmd.Args = append([]string{"/bin/sh", "-c", "umask " + umask + " && exec \"$0\" \"$@\"", cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+}
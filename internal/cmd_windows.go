@@ -2,9 +2,44 @@ package internal
 
 import (
 	"github.com/reddec/trusted-cgi/types"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
 func SetCreds(cmd *exec.Cmd, creds *types.Credential) {
 
 }
+
+// Processes are not killed by signals
+func ExitSignal(state *os.ProcessState) string {
+	return ""
+}
+
+// File could be executed: there are no execute permissions, extension of file is one of PATHEXT (ex: .exe, .bat)
+func IsExecutable(info os.FileInfo) bool {
+	if info.IsDir() {
+		return false
+	}
+	extensions := os.Getenv("PATHEXT")
+	if extensions == "" {
+		extensions = ".com;.exe;.bat;.cmd"
+	}
+	ext := filepath.Ext(info.Name())
+	for _, candidate := range filepath.SplitList(extensions) {
+		if ext != "" && strings.EqualFold(ext, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Switching users is not supported
+func CanSwitchUser() bool {
+	return false
+}
+
+func SetUmask(cmd *exec.Cmd, umask string) {
+
+}
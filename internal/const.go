@@ -4,5 +4,14 @@ const (
 	ProjectManifest = "project.json"  // project manifest file (configuration for the platform)
 	CGIIgnore       = ".cgiignore"    // file with tar --exclude-from patterns for upload/download filter
 	ManifestFile    = "manifest.json" // lambda configuration
+	ManifestYAML    = "manifest.yaml" // lambda configuration in YAML (alternative to ManifestFile)
+	BundlePointer   = ".bundle.json"  // pointer to the active bundle (hash) of bundled lambda
+	BundlesDir      = ".bundles"      // shared storage for bundles and extracted bundles in project directory
+	ScratchDir      = ".scratch"      // temporary files of invocations (ex: spooled payload) in project directory
+	GitDeployDir    = ".git-deploy"   // clones and staged copies of lambdas deployed from git in project directory
+	ImportDir       = ".imports"      // staged copies of lambdas imported from archives by URL in project directory
+	FrozenDir       = ".frozen"       // frozen artifacts of lambdas (see freezer) in project directory
+	VersionsDir     = ".versions"     // versions of lambdas and staged copies of rollbacks (see versions) in project directory
+	LogsDir         = ".logs"         // log files of invocations of lambdas (see manifest logs) in project directory
 	SSHKeySize      = 3072            // generated SSH size for git client
 )
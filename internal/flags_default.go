@@ -1,7 +1,12 @@
-//+build !linux
+//go:build !linux && !windows
 
 package internal
 
-import "os/exec"
+import (
+	"os/exec"
+	"time"
+)
 
 func SetFlags(cmd *exec.Cmd) {}
+
+func SetGracePeriod(cmd *exec.Cmd, grace time.Duration) {}
@@ -3,13 +3,34 @@ package internal
 import (
 	"os/exec"
 	"syscall"
+	"time"
 )
 
-// Set parent ground and death signal to be sure that nested processes will be closed
+// Set parent group and death signal to be sure that nested processes will be closed
 func SetFlags(cmd *exec.Cmd) {
 	if cmd.SysProcAttr == nil {
 		cmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
 	cmd.SysProcAttr.Setpgid = true
 	cmd.SysProcAttr.Pdeathsig = syscall.SIGINT
+	// kill whole group on context cancel (time limit), otherwise children keep output open
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// Terminate process group (see SetFlags) gracefully on context cancel (time limit, client is gone, server shutdown):
+// SIGTERM to the whole group, SIGKILL to the group after grace period, so children which ignore SIGTERM don't leak.
+// Zero grace period - SIGKILL immediately
+func SetGracePeriod(cmd *exec.Cmd, grace time.Duration) {
+	if grace <= 0 {
+		return
+	}
+	cmd.Cancel = func() error {
+		group := -cmd.Process.Pid
+		time.AfterFunc(grace, func() {
+			_ = syscall.Kill(group, syscall.SIGKILL)
+		})
+		return syscall.Kill(group, syscall.SIGTERM)
+	}
 }
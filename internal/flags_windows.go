@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Start process in new process group, so console events are sent only to the group (see SetGracePeriod), and kill
+// whole tree of processes on context cancel (time limit): children are not killed with the parent on Windows,
+// otherwise they keep output open
+func SetFlags(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_NEW_PROCESS_GROUP
+	cmd.Cancel = func() error {
+		return killTree(cmd.Process)
+	}
+}
+
+// Terminate tree of processes (see SetFlags) gracefully on context cancel: Ctrl+Break event to the process group
+// (console analog of SIGTERM), the tree is killed after grace period. Process without console (ex: server runs as
+// service) can't receive events, so it's killed immediately. Zero grace period - kill immediately
+func SetGracePeriod(cmd *exec.Cmd, grace time.Duration) {
+	if grace <= 0 {
+		return
+	}
+	cmd.Cancel = func() error {
+		process := cmd.Process
+		if err := windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(process.Pid)); err != nil {
+			return killTree(process)
+		}
+		time.AfterFunc(grace, func() {
+			_ = killTree(process)
+		})
+		return nil
+	}
+}
+
+// kill process and its descendants (found by snapshot of processes before kill)
+func killTree(process *os.Process) error {
+	children, snapshotErr := descendants(process.Pid)
+	err := process.Kill()
+	for _, pid := range children {
+		_ = terminate(pid)
+	}
+	if err != nil {
+		return err
+	}
+	return snapshotErr
+}
+
+// descendants of process by parents of running processes
+func descendants(root int) ([]int, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snapshot)
+	parents := make(map[int]int)
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		parents[int(entry.ProcessID)] = int(entry.ParentProcessID)
+	}
+	return processTree(root, parents), nil
+}
+
+func terminate(pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	return windows.TerminateProcess(process, 1)
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Both manifest.json and manifest.yaml are defined in the same directory.
+var ErrAmbiguousManifest = errors.New("ambiguous manifest: both " + ManifestFile + " and " + ManifestYAML + " exist, keep only one")
+
+// FindManifest returns path of lambda manifest in the directory: manifest.yaml if exists, otherwise manifest.json
+// (even if not exists). If both files exist, path of manifest.yaml is returned with ErrAmbiguousManifest.
+func FindManifest(dir string) (string, error) {
+	yamlFile := filepath.Join(dir, ManifestYAML)
+	if _, err := os.Stat(yamlFile); err != nil {
+		return filepath.Join(dir, ManifestFile), nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+		return yamlFile, ErrAmbiguousManifest
+	}
+	return yamlFile, nil
+}
+
+// IsManifest checks that name (relative to lambda root) is manifest file in any format.
+func IsManifest(name string) bool {
+	return name == ManifestFile || name == ManifestYAML
+}
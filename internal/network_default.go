@@ -0,0 +1,15 @@
+//go:build !linux
+
+package internal
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Network isolation is not supported: command fails to start
+func DenyNetwork(cmd *exec.Cmd) {
+	if cmd.Err == nil {
+		cmd.Err = errors.New("network isolation is not supported on this platform")
+	}
+}
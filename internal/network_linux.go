@@ -0,0 +1,15 @@
+package internal
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Run process in new network namespace: only loopback interface (down), so process has no network access.
+// Requires CAP_SYS_ADMIN (server runs as root to switch users)
+func DenyNetwork(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+}
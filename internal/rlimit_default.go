@@ -0,0 +1,17 @@
+//go:build !linux
+
+package internal
+
+import "errors"
+
+const RlimitSupported = false
+
+type Rlimits struct {
+	Data      int64
+	OpenFiles int
+	Processes int
+}
+
+func SetRlimits(pid int, limits Rlimits) error {
+	return errors.New("rlimits are supported only on Linux")
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Rlimits are supported
+const RlimitSupported = true
+
+// Rlimits of process, zero values are not applied
+type Rlimits struct {
+	Data      int64 // maximum size of data segment (heap and private mappings) in bytes
+	OpenFiles int   // maximum number of open files
+	Processes int   // maximum number of processes of real user
+}
+
+// Apply rlimits (soft and hard, so process can't raise them) to running process. Children forked before the call
+// keep previous limits
+func SetRlimits(pid int, limits Rlimits) error {
+	if limits.Data > 0 {
+		if err := prlimit(pid, syscall.RLIMIT_DATA, uint64(limits.Data)); err != nil {
+			return fmt.Errorf("set data limit: %w", err)
+		}
+	}
+	if limits.OpenFiles > 0 {
+		if err := prlimit(pid, syscall.RLIMIT_NOFILE, uint64(limits.OpenFiles)); err != nil {
+			return fmt.Errorf("set open files limit: %w", err)
+		}
+	}
+	if limits.Processes > 0 {
+		if err := prlimit(pid, rlimitNProc, uint64(limits.Processes)); err != nil {
+			return fmt.Errorf("set processes limit: %w", err)
+		}
+	}
+	return nil
+}
+
+const rlimitNProc = 0x6 // RLIMIT_NPROC is not defined by syscall package
+
+func prlimit(pid int, resource int, value uint64) error {
+	limit := syscall.Rlimit{Cur: value, Max: value}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Package testutil contains helpers of tests: in-process server and platform with lambda in temporary directory,
+// requests to them. Resources are released by cleanup of test.
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/trustedcgi"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// UID of lambda of platform (see Platform)
+const UID = "11111111-1111-1111-1111-111111111111"
+
+// TempDir is temporary directory removed at the end of test
+func TempDir(t testing.TB) string {
+	dir, err := ioutil.TempDir("", "trusted-cgi-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+// Instance of server with default configuration in temporary directory (see Instance.Location) without SSH. Instance
+// is stopped at the end of test
+func Instance(t testing.TB) *trustedcgi.Instance {
+	inst, err := trustedcgi.Default().Directory(TempDir(t)).SSH(false).New()
+	require.NoError(t, err)
+	t.Cleanup(inst.Stop)
+	return inst
+}
+
+// Call handler by request with body. Headers of request are pairs of name and value
+func Call(handler http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Add(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Platform in temporary directory with one lambda by UID (files of lambda are in <dir>/<UID>). Returns directory of
+// platform
+func Platform(t testing.TB, manifest types.Manifest) (string, application.Platform) {
+	dir := TempDir(t)
+	root := filepath.Join(dir, UID)
+	require.NoError(t, os.MkdirAll(root, 0755))
+	require.NoError(t, manifest.SaveAs(filepath.Join(root, internal.ManifestFile)))
+	fn, err := lambda.FromDir(root)
+	require.NoError(t, err)
+	plato, err := platform.New(filepath.Join(dir, internal.ProjectManifest))
+	require.NoError(t, err)
+	require.NoError(t, plato.Add(UID, fn))
+	return dir, plato
+}
+
+// Invoke lambda by UID with empty request. Returns output of lambda
+func Invoke(t testing.TB, plato application.Platform, uid string) string {
+	var out bytes.Buffer
+	require.NoError(t, plato.InvokeByUID(context.Background(), uid, types.Request{Body: ioutil.NopCloser(&bytes.Buffer{})}, &out))
+	return out.String()
+}
@@ -0,0 +1,33 @@
+package internal
+
+import "sort"
+
+// Descendants of root process (without root) by parents of processes (PID -> parent PID): processes closer to root
+// first, siblings by PID. Processes on platforms without process groups (Windows) are killed by the tree
+func processTree(root int, parents map[int]int) []int {
+	children := make(map[int][]int)
+	for pid, parent := range parents {
+		if pid != parent {
+			children[parent] = append(children[parent], pid)
+		}
+	}
+	for _, pids := range children {
+		sort.Ints(pids)
+	}
+	var tree []int
+	visited := map[int]bool{root: true}
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			tree = append(tree, child)
+			queue = append(queue, child)
+		}
+	}
+	return tree
+}
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessTree(t *testing.T) {
+	// snapshot of processes: PID -> parent PID
+	parents := map[int]int{
+		0:  0,
+		4:  0,
+		10: 4,
+		11: 10,
+		12: 10,
+		13: 12,
+		20: 4,
+		21: 20,
+	}
+	assert.Equal(t, []int{11, 12, 13}, processTree(10, parents))
+	assert.Equal(t, []int{13}, processTree(12, parents))
+	assert.Empty(t, processTree(11, parents))
+	assert.Equal(t, []int{4, 10, 20, 11, 12, 21, 13}, processTree(0, parents), "closer to root first")
+}
@@ -0,0 +1,14 @@
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// Maximum resident set size of finished process in bytes (zero - unknown)
+func MaxRSS(state *os.ProcessState) int64 {
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return usage.Maxrss * 1024 // in kilobytes
+	}
+	return 0
+}
@@ -2,22 +2,52 @@ package indir
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/reddec/dfq"
 	"github.com/reddec/trusted-cgi/types"
 	"github.com/tinylib/msgp/msgp"
 	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Sub-directory of queue for elements which could not be decoded
+const CorruptDir = "corrupt"
+
+// extension of element files of the backend
+const dataSuffix = ".data"
+
 func New(directory string) (*inDirQueue, error) {
+	queue := &inDirQueue{directory: directory}
+	// backend can't be opened with foreign element files
+	if err := queue.quarantineInvalidNames(); err != nil {
+		return nil, err
+	}
 	back, err := dfq.Open(directory)
 	if err != nil {
 		return nil, err
 	}
-	return &inDirQueue{backend: back}, nil
+	queue.backend = back
+	files, err := queue.quarantinedFiles()
+	if err != nil {
+		return nil, err
+	}
+	queue.quarantined = int64(len(files))
+	return queue, nil
 }
 
 type inDirQueue struct {
-	backend dfq.Queue
+	backend     dfq.Queue
+	directory   string
+	lock        sync.Mutex // quarantine operations
+	quarantined int64
 }
 
 func (queue *inDirQueue) Put(ctx context.Context, request *types.Request) error {
@@ -36,25 +66,193 @@ func (queue *inDirQueue) Put(ctx context.Context, request *types.Request) error
 	})
 }
 
+// Peek oldest element. Elements which could not be decoded are moved to quarantine and skipped.
 func (queue *inDirQueue) Peek(ctx context.Context) (*types.Request, error) {
-	in, err := queue.backend.Wait(ctx)
+	for {
+		in, err := queue.backend.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reader := msgp.NewReader(in)
+		var head types.Request
+		err = head.DecodeMsg(reader)
+		if err == nil {
+			return head.WithBody(&readCloser{reader: reader.R, closer: in}), nil
+		}
+		_ = in.Close()
+		f, ok := in.(*os.File)
+		if !ok {
+			return nil, err
+		}
+		if err := queue.quarantine(f.Name(), err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (queue *inDirQueue) Commit(ctx context.Context) error {
+	return queue.backend.Commit()
+}
+
+func (queue *inDirQueue) Len() int64 {
+	return queue.backend.Len()
+}
+
+// Modification time of the current (oldest) element file
+func (queue *inDirQueue) Oldest() time.Time {
+	in, err := queue.backend.Peek()
+	if err != nil {
+		return time.Time{}
+	}
+	defer in.Close()
+	f, ok := in.(*os.File)
+	if !ok {
+		return time.Time{}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Oldest element decoded without body
+func (queue *inDirQueue) Head() (*types.Request, error) {
+	in, err := queue.backend.Peek()
+	if errors.Is(err, dfq.ErrEmptyQueue) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	reader := msgp.NewReader(in)
+	defer in.Close()
 	var head types.Request
-	err = head.DecodeMsg(reader)
-	if err != nil {
-		_ = in.Close()
+	if err := head.DecodeMsg(msgp.NewReader(in)); err != nil {
 		return nil, err
 	}
-	return head.WithBody(&readCloser{reader: reader.R, closer: in}), nil
+	return &head, nil
 }
 
-func (queue *inDirQueue) Commit(ctx context.Context) error {
+func (queue *inDirQueue) Quarantined() int64 {
+	return atomic.LoadInt64(&queue.quarantined)
+}
+
+// Return quarantined elements to the end of queue in order of quarantine. Elements are quarantined again if they are
+// still corrupted.
+func (queue *inDirQueue) RetryQuarantined() (int, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	files, err := queue.quarantinedFiles()
+	if err != nil {
+		return 0, err
+	}
+	for i, file := range files {
+		if err := queue.restore(file); err != nil {
+			return i, fmt.Errorf("retry quarantined %s: %w", filepath.Base(file), err)
+		}
+		atomic.AddInt64(&queue.quarantined, -1)
+	}
+	return len(files), nil
+}
+
+func (queue *inDirQueue) PurgeQuarantined() (int, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	files, err := queue.quarantinedFiles()
+	if err != nil {
+		return 0, err
+	}
+	for i, file := range files {
+		if err := os.Remove(file); err != nil {
+			return i, fmt.Errorf("purge quarantined: %w", err)
+		}
+		atomic.AddInt64(&queue.quarantined, -1)
+	}
+	return len(files), nil
+}
+
+// put quarantined file to the end of queue
+func (queue *inDirQueue) restore(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	err = queue.backend.Put(f)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// move element file to quarantine directory and commit it. Name of quarantined file is prefixed by time to keep order
+// and to avoid collisions with elements of the same ID
+func (queue *inDirQueue) quarantine(file string, reason error) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if err := queue.moveToQuarantine(file); err != nil {
+		return fmt.Errorf("quarantine corrupted element %s (%v): %w", filepath.Base(file), reason, err)
+	}
+	log.Println("[WARN]", "queue", queue.directory, "element", filepath.Base(file), "moved to quarantine:", reason)
 	return queue.backend.Commit()
 }
 
+func (queue *inDirQueue) moveToQuarantine(file string) error {
+	dir := filepath.Join(queue.directory, CorruptDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, strconv.FormatInt(time.Now().UnixNano(), 10)+"-"+filepath.Base(file))
+	if err := os.Rename(file, target); err != nil {
+		return err
+	}
+	atomic.AddInt64(&queue.quarantined, 1)
+	return nil
+}
+
+// quarantine element files with names which are not sequence numbers (backend refuses to open such directory)
+func (queue *inDirQueue) quarantineInvalidNames() error {
+	list, err := os.ReadDir(queue.directory)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range list {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, dataSuffix) {
+			continue
+		}
+		if _, err := strconv.ParseInt(strings.TrimSuffix(name, dataSuffix), 10, 64); err == nil {
+			continue
+		}
+		if err := queue.moveToQuarantine(filepath.Join(queue.directory, name)); err != nil {
+			return fmt.Errorf("quarantine element %s with invalid name: %w", name, err)
+		}
+		log.Println("[WARN]", "queue", queue.directory, "element", name, "with invalid name moved to quarantine")
+	}
+	return nil
+}
+
+// quarantined files from the oldest
+func (queue *inDirQueue) quarantinedFiles() ([]string, error) {
+	list, err := os.ReadDir(filepath.Join(queue.directory, CorruptDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files = make([]string, 0, len(list))
+	for _, entry := range list {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(queue.directory, CorruptDir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
 func (queue *inDirQueue) Destroy() error {
 	return queue.backend.Destroy()
 }
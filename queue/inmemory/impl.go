@@ -8,8 +8,14 @@ import (
 	"io/ioutil"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+type pending struct {
+	at   time.Time // put time
+	head types.Request
+}
+
 type item struct {
 	payload types.Request
 	data    []byte
@@ -39,6 +45,10 @@ type memoryQueue struct {
 	}
 	rlock   sync.Mutex
 	closing int32
+	state   struct {
+		lock    sync.Mutex
+		pending []pending // stored elements without body (FIFO)
+	}
 }
 
 func (queue *memoryQueue) Put(ctx context.Context, request *types.Request) error {
@@ -61,6 +71,11 @@ func (queue *memoryQueue) Put(ctx context.Context, request *types.Request) error
 		payload: *request,
 		data:    data,
 	}:
+		queue.state.lock.Lock()
+		head := *request
+		head.Body = nil
+		queue.state.pending = append(queue.state.pending, pending{at: time.Now(), head: head})
+		queue.state.lock.Unlock()
 		return nil
 	}
 }
@@ -95,10 +110,42 @@ func (queue *memoryQueue) Commit(ctx context.Context) error {
 	}
 	queue.rlock.Lock()
 	defer queue.rlock.Unlock()
+	if queue.peeked.available {
+		queue.state.lock.Lock()
+		if len(queue.state.pending) > 0 {
+			queue.state.pending = queue.state.pending[1:]
+		}
+		queue.state.lock.Unlock()
+	}
 	queue.peeked.available = false
 	return nil
 }
 
+func (queue *memoryQueue) Len() int64 {
+	queue.state.lock.Lock()
+	defer queue.state.lock.Unlock()
+	return int64(len(queue.state.pending))
+}
+
+func (queue *memoryQueue) Oldest() time.Time {
+	queue.state.lock.Lock()
+	defer queue.state.lock.Unlock()
+	if len(queue.state.pending) == 0 {
+		return time.Time{}
+	}
+	return queue.state.pending[0].at
+}
+
+func (queue *memoryQueue) Head() (*types.Request, error) {
+	queue.state.lock.Lock()
+	defer queue.state.lock.Unlock()
+	if len(queue.state.pending) == 0 {
+		return nil, nil
+	}
+	head := queue.state.pending[0].head
+	return &head, nil
+}
+
 func (queue *memoryQueue) Done() <-chan struct{} { return queue.closed }
 
 func (queue *memoryQueue) Close() {
@@ -3,6 +3,7 @@ package queue
 import (
 	"context"
 	"github.com/reddec/trusted-cgi/types"
+	"time"
 )
 
 // Thread-safe FIFO queue designed for one multiple concurrent writers and single consumer.
@@ -17,3 +18,28 @@ type Queue interface {
 	// Clean all internal allocated resource
 	Destroy() error
 }
+
+// Optional queue extension with cheap (maintained, without scanning of elements) statistics
+type Stats interface {
+	// Number of stored elements including peeked but not committed one
+	Len() int64
+	// Time when the oldest element was added. Zero if queue is empty or time is unknown
+	Oldest() time.Time
+}
+
+// Optional queue extension for elements which could not be decoded (ex: truncated by power loss). Such elements are
+// moved to quarantine instead of blocking the queue
+type Quarantine interface {
+	// Number of quarantined elements
+	Quarantined() int64
+	// Return quarantined elements to the end of queue. Returns number of returned elements
+	RetryQuarantined() (int, error)
+	// Remove quarantined elements. Returns number of removed elements
+	PurgeQuarantined() (int, error)
+}
+
+// Optional queue extension for inspection of elements without consuming
+type Inspector interface {
+	// Oldest element without body (nil if queue is empty)
+	Head() (*types.Request, error)
+}
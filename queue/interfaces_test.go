@@ -3,6 +3,7 @@ package queue_test
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/indir"
@@ -10,7 +11,9 @@ import (
 	"github.com/reddec/trusted-cgi/types"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func testPutPeek(ctx context.Context, t *testing.T, queue queue.Queue) *types.Request {
@@ -27,6 +30,7 @@ func testPutPeek(ctx context.Context, t *testing.T, queue queue.Queue) *types.Re
 		Headers: map[string]string{
 			"Content-Type": "text/plain",
 		},
+		Chain: []string{"producer"},
 		Body: ioutil.NopCloser(bytes.NewBufferString(payload)),
 	}
 	err := queue.Put(ctx, req)
@@ -65,7 +69,9 @@ func TestInMemory(t *testing.T) {
 		return
 	}
 	// put again
-	testPutPeek(ctx, t, q)
+	req = testPutPeek(ctx, t, q)
+	testStats(t, q, 1)
+	testInspect(t, q, req)
 
 	q.Close()
 
@@ -103,5 +109,90 @@ func TestInDir(t *testing.T) {
 		return
 	}
 	// put again
-	testPutPeek(ctx, t, q)
+	req = testPutPeek(ctx, t, q)
+	testStats(t, q, 1)
+	testInspect(t, q, req)
+}
+
+func TestInDir_quarantine(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	q, err := indir.New(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 4; i++ {
+		req := &types.Request{Method: "POST", URL: fmt.Sprint("/", i), Body: ioutil.NopCloser(bytes.NewBufferString("hello"))}
+		if !assert.NoError(t, q.Put(ctx, req)) {
+			return
+		}
+	}
+	valid, err := ioutil.ReadFile(filepath.Join(dir, "2.data"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// zero bytes, bad JSON instead of message, truncation
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "0.data"), nil, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "1.data"), []byte(`{"method":"POST",`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "2.data"), valid[:len(valid)/3], 0644))
+
+	v, err := q.Peek(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = v.Body.Close()
+	assert.Equal(t, "/3", v.URL)
+	var quarantine queue.Quarantine = q
+	assert.Equal(t, int64(3), quarantine.Quarantined())
+	assert.NoError(t, q.Commit(ctx))
+
+	// element with foreign name doesn't prevent opening
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.data"), valid, 0644))
+	q, err = indir.New(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	quarantine = q
+	assert.Equal(t, int64(4), quarantine.Quarantined())
+
+	// fixed element is processed after retry, still corrupted elements are quarantined again
+	n, err := quarantine.RetryQuarantined()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, int64(0), quarantine.Quarantined())
+	v, err = q.Peek(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = v.Body.Close()
+	assert.Equal(t, "/2", v.URL, "element with foreign name is valid")
+	assert.Equal(t, int64(3), quarantine.Quarantined())
+
+	n, err = quarantine.PurgeQuarantined()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int64(0), quarantine.Quarantined())
+}
+
+func testInspect(t *testing.T, q queue.Queue, expected *types.Request) {
+	inspector, ok := q.(queue.Inspector)
+	if !assert.True(t, ok, "queue should provide inspection") {
+		return
+	}
+	head, err := inspector.Head()
+	if !assert.NoError(t, err) || !assert.NotNil(t, head) {
+		return
+	}
+	assert.Nil(t, head.Body)
+	assert.Equal(t, expected.WithBody(nil), head)
+}
+
+func testStats(t *testing.T, q queue.Queue, expected int64) {
+	stats, ok := q.(queue.Stats)
+	if !assert.True(t, ok, "queue should provide stats") {
+		return
+	}
+	assert.Equal(t, expected, stats.Len())
+	assert.False(t, stats.Oldest().IsZero())
+	assert.True(t, time.Since(stats.Oldest()) < time.Minute)
 }
@@ -0,0 +1,162 @@
+// Package sdk is a helper for lambdas written in Go: request is read from environment and stdin (see manifest
+// fields input_headers, method_env, deadline_env), response is written to stdout as body or as CGI header block with
+// status and body (parse_headers). The package doesn't depend on the server and could be imported by lambdas only.
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Conventional names of environment variables (the same as in manifest of Go template)
+const (
+	MethodEnv    = "REQUEST_METHOD"   // HTTP method (method_env)
+	DeadlineEnv  = "REQUEST_DEADLINE" // deadline of invocation (deadline_env)
+	ModeEnv      = "SDK_MODE"         // response mode: ModeHeaders or ModePlain (default)
+	HeaderPrefix = "HTTP_"            // prefix of variables with request headers (input_headers), ex: HTTP_CONTENT_TYPE
+)
+
+// Response modes
+const (
+	ModePlain   = "plain"   // only body, status is defined by exit code (status_map)
+	ModeHeaders = "headers" // CGI header block with Status before body (parse_headers)
+)
+
+// Request to lambda. Headers, method and deadline are available only if mapped in manifest.
+type Request struct {
+	Method   string      // HTTP method, empty if not mapped
+	PathInfo string      // path inside lambda (after /a/<uid> or /l/<link>)
+	Query    url.Values  // query parameters
+	Headers  http.Header // request headers mapped to HTTP_<NAME> variables
+	Body     io.Reader   // request body
+}
+
+// Decode JSON body
+func (r *Request) JSON(value interface{}) error {
+	return json.NewDecoder(r.Body).Decode(value)
+}
+
+// Response of lambda. Status and headers are sent only in headers mode, exit code defines status in plain mode.
+type Response struct {
+	Status   int         // HTTP status (headers mode), zero - 200
+	ExitCode int         // exit code (plain mode), should be mapped in status_map
+	Headers  http.Header // headers (headers mode)
+	Body     []byte      // body
+}
+
+// JSON response with status
+func JSON(status int, value interface{}) (*Response, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode response: %w", err)
+	}
+	return &Response{
+		Status:  status,
+		Headers: http.Header{"Content-Type": []string{"application/json"}},
+		Body:    data,
+	}, nil
+}
+
+// Handler of request. Error is reported as 500 with the message as body and exit code 1.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Handle request from process environment and stdin, write response to stdout and exit. Should be called once from
+// main.
+func Handle(handler Handler) {
+	os.Exit(Serve(context.Background(), os.Environ(), os.Stdin, os.Stdout, handler))
+}
+
+// Serve request from environment (KEY=VALUE) and input, write response to output. Returns exit code of process.
+func Serve(ctx context.Context, environ []string, input io.Reader, output io.Writer, handler Handler) int {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	if value := env[DeadlineEnv]; value != "" {
+		deadline, err := time.Parse(time.RFC3339Nano, value)
+		if err == nil {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+	}
+	query, _ := url.ParseQuery(env["QUERY_STRING"])
+	req := &Request{
+		Method:   env[MethodEnv],
+		PathInfo: env["PATH_INFO"],
+		Query:    query,
+		Headers:  headers(env),
+		Body:     input,
+	}
+	code := 0
+	res, err := handler(ctx, req)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "[ERROR]", err)
+		res = &Response{Status: http.StatusInternalServerError, ExitCode: 1, Body: []byte(err.Error())}
+		code = 1
+	} else if res == nil {
+		res = &Response{}
+	}
+	if env[ModeEnv] != ModeHeaders {
+		_, _ = output.Write(res.Body)
+		if code == 0 {
+			code = res.ExitCode
+		}
+		return code
+	}
+	if err := writeHeaders(output, res); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "[ERROR] write response:", err)
+		return 1
+	}
+	return code
+}
+
+// request headers by variables with HTTP_ prefix: HTTP_CONTENT_TYPE is Content-Type. HTTP_PROXY is proxy setting
+// of environment, not a header
+func headers(env map[string]string) http.Header {
+	var ans = make(http.Header)
+	for k, v := range env {
+		if name := strings.TrimPrefix(k, HeaderPrefix); name != k && name != "" && name != "PROXY" {
+			ans.Set(strings.ReplaceAll(name, "_", "-"), v)
+		}
+	}
+	return ans
+}
+
+// write CGI header block (with Status) and body
+func writeHeaders(output io.Writer, res *Response) error {
+	var block bytes.Buffer
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	_, _ = fmt.Fprintf(&block, "Status: %d %s\r\n", status, http.StatusText(status))
+	var names = make([]string, 0, len(res.Headers))
+	for name := range res.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range res.Headers[name] {
+			if strings.ContainsAny(name+value, "\r\n") {
+				return fmt.Errorf("invalid header %s", name)
+			}
+			_, _ = fmt.Fprintf(&block, "%s: %s\r\n", name, value)
+		}
+	}
+	block.WriteString("\r\n")
+	block.Write(res.Body)
+	_, err := output.Write(block.Bytes())
+	return err
+}
@@ -0,0 +1,111 @@
+package sdk_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/internal/testutil"
+	"github.com/reddec/trusted-cgi/sdk"
+	"github.com/reddec/trusted-cgi/templates"
+)
+
+func TestServe(t *testing.T) {
+	ctx := context.Background()
+	deadline := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	environ := []string{
+		"QUERY_STRING=name=reddec&tag=a&tag=b",
+		"PATH_INFO=/users/1",
+		"HTTP_CONTENT_TYPE=application/json",
+		"HTTP_PROXY=http://proxy",
+		sdk.MethodEnv + "=POST",
+		sdk.DeadlineEnv + "=" + deadline.Format(time.RFC3339Nano),
+	}
+	var received *sdk.Request
+	handler := func(ctx context.Context, req *sdk.Request) (*sdk.Response, error) {
+		received = req
+		actual, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.True(t, deadline.Equal(actual))
+		return &sdk.Response{Status: http.StatusCreated, ExitCode: 2, Headers: http.Header{"X-Id": []string{"1"}}, Body: []byte("created")}, nil
+	}
+
+	var out bytes.Buffer
+	code := sdk.Serve(ctx, environ, bytes.NewBufferString("{}"), &out, handler)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "created", out.String(), "plain mode by default")
+	assert.Equal(t, "POST", received.Method)
+	assert.Equal(t, "/users/1", received.PathInfo)
+	assert.Equal(t, []string{"a", "b"}, received.Query["tag"])
+	assert.Equal(t, http.Header{"Content-Type": []string{"application/json"}}, received.Headers)
+
+	out.Reset()
+	code = sdk.Serve(ctx, append(environ, sdk.ModeEnv+"="+sdk.ModeHeaders), http.NoBody, &out, handler)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "Status: 201 Created\r\nX-Id: 1\r\n\r\ncreated", out.String())
+
+	out.Reset()
+	code = sdk.Serve(ctx, []string{sdk.ModeEnv + "=" + sdk.ModeHeaders}, http.NoBody, &out, func(ctx context.Context, req *sdk.Request) (*sdk.Response, error) {
+		assert.Empty(t, req.Method, "not mapped")
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		return nil, errors.New("failed")
+	})
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "Status: 500 Internal Server Error\r\n\r\nfailed", out.String())
+
+	out.Reset()
+	code = sdk.Serve(ctx, []string{sdk.ModeEnv + "=" + sdk.ModeHeaders}, http.NoBody, &out, func(ctx context.Context, req *sdk.Request) (*sdk.Response, error) {
+		return &sdk.Response{Headers: http.Header{"X-Bad": []string{"a\r\nStatus: 200"}}}, nil
+	})
+	assert.Equal(t, 1, code)
+	assert.Empty(t, out.String(), "header injection")
+}
+
+// Compiled example of Go template under in-process server (see testutil.Instance)
+func TestTemplate(t *testing.T) {
+	compiler, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not available")
+	}
+	ctx := context.Background()
+	inst := testutil.Instance(t)
+
+	template := *templates.ListEmbedded()["Go"]
+	template.PostClone = "" // dependencies are not downloaded, example is compiled from the module
+	uid, err := inst.Server().Cases.CreateFromTemplate(ctx, template)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(inst.Location, uid, "main.go"))
+	require.NoError(t, err)
+	build := exec.Command(compiler, "build", "-o", filepath.Join(inst.Location, uid, "app"), "github.com/reddec/trusted-cgi/templates/assets/go")
+	build.Stderr = os.Stderr
+	require.NoError(t, build.Run())
+	handler := inst.Handler()
+
+	invoke := func(method, path, body string) *httptest.ResponseRecorder {
+		return testutil.Call(handler, method, path, body, "Content-Type", "application/json")
+	}
+
+	rec := invoke(http.MethodPost, "/a/"+uid, `{"name":"reddec"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"greeting":"hello, reddec"}`, rec.Body.String())
+
+	rec = invoke(http.MethodGet, "/a/"+uid+"?name=query", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"greeting":"hello, query"}`, rec.Body.String())
+
+	rec = invoke(http.MethodPost, "/a/"+uid, `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"error":"name is required"}`, rec.Body.String())
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type localKey struct{}
+
+// Local marks requests of handler as requests of local admin: API methods are invoked without token. Handler should
+// be served only by listener accessible by owner of server files (see ListenAdminSocket)
+func Local(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		handler.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), localKey{}, true)))
+	})
+}
+
+func isLocal(request *http.Request) bool {
+	local, _ := request.Context().Value(localKey{}).(bool)
+	return local
+}
+
+// ListenAdminSocket listens unix socket accessible only by owner of the process (see ListenSocket)
+func ListenAdminSocket(path string) (net.Listener, error) {
+	return ListenSocket(path, SocketOptions{Mode: 0600, UID: -1, GID: -1})
+}
@@ -0,0 +1,454 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Default time to keep results of asynchronous invocations
+const DefaultAsyncTTL = time.Hour
+
+// interval of removing expired results of asynchronous invocations
+const asyncSweepInterval = time.Minute
+
+// Asynchronous invocations (see types.AsyncPrefix): accepted requests are invoked in background by the same rules as
+// synchronous ones, results are kept for polling by ticket
+type AsyncConfig struct {
+	Depth      int           // maximum number of pending invocations, submissions over depth are rejected by 503
+	Workers    int           // number of concurrent background invocations (zero - 1)
+	TTL        time.Duration // time to keep result after completion (zero - DefaultAsyncTTL)
+	MaxPayload int64         // maximum size of request body kept in queue (zero - limited only by lambda)
+	Dir        string        // directory of pending invocations to survive restart (empty - kept only in memory)
+}
+
+func (cfg AsyncConfig) workers() int {
+	if cfg.Workers <= 0 {
+		return 1
+	}
+	return cfg.Workers
+}
+
+func (cfg AsyncConfig) ttl() time.Duration {
+	if cfg.TTL <= 0 {
+		return DefaultAsyncTTL
+	}
+	return cfg.TTL
+}
+
+// NewAsyncQueue creates bounded queue of asynchronous invocations (see Server.Async). Pending invocations persisted
+// in directory of config (including interrupted in-flight ones) are queued again, corrupted ones are moved aside.
+// Results are kept only in memory.
+func NewAsyncQueue(config AsyncConfig) (*AsyncQueue, error) {
+	aq := &AsyncQueue{
+		config:   config,
+		jobs:     make(chan *asyncJob, config.Depth),
+		results:  make(map[string]*asyncResult),
+		draining: make(chan struct{}),
+	}
+	if config.Dir == "" {
+		return aq, nil
+	}
+	aq.store = &asyncStore{dir: config.Dir}
+	items, err := aq.store.load()
+	if err != nil {
+		return nil, fmt.Errorf("load asynchronous invocations: %w", err)
+	}
+	for _, item := range items {
+		aq.results[item.Ticket] = &asyncResult{info: item.info()}
+		aq.restored = append(aq.restored, item)
+	}
+	if len(items) > 0 {
+		log.Println("async:", len(items), "pending invocations restored from", config.Dir)
+	}
+	return aq, nil
+}
+
+// AsyncQueue is bounded queue of asynchronous invocations and their results by tickets
+type AsyncQueue struct {
+	config   AsyncConfig
+	store    *asyncStore // nil if not persisted
+	jobs     chan *asyncJob
+	restored []*asyncItem // loaded from store, queued on start
+	lock     sync.Mutex
+	results  map[string]*asyncResult // pending and completed invocations
+	swept    time.Time
+	draining chan struct{} // closed by drain: new invocations are not started
+	drained  sync.Once
+}
+
+type asyncJob struct {
+	ticket  string
+	request *http.Request // with buffered body
+}
+
+type asyncResult struct {
+	info    application.AsyncInvocation // of pending invocation
+	done    bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time // of completed result
+}
+
+// start workers which serve accepted requests by handler until context is done. Restored invocations are queued
+// first (also over depth)
+func (aq *AsyncQueue) start(ctx context.Context, handler http.Handler) {
+	restored := aq.restored
+	aq.restored = nil
+	go func() {
+		for _, item := range restored {
+			select {
+			case aq.jobs <- &asyncJob{ticket: item.Ticket, request: item.request(ctx)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for i := 0; i < aq.config.workers(); i++ {
+		go func() {
+			for {
+				select {
+				case job := <-aq.jobs:
+					if aq.isDraining() {
+						// persisted invocation is restored on start
+						return
+					}
+					if !aq.begin(job.ticket) {
+						continue
+					}
+					result := serveAsync(handler, job)
+					if ctx.Err() != nil {
+						// interrupted by shutdown: persisted invocation is restored on start
+						return
+					}
+					aq.complete(job.ticket, result)
+				case <-ctx.Done():
+					return
+				case <-aq.draining:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// stop starting of new invocations, in-flight invocations are not interrupted
+func (aq *AsyncQueue) drain() {
+	aq.drained.Do(func() {
+		close(aq.draining)
+	})
+}
+
+func (aq *AsyncQueue) isDraining() bool {
+	select {
+	case <-aq.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// put request to queue: persisted (if enabled) before it is accepted. False if queue is full
+func (aq *AsyncQueue) push(job *asyncJob, body []byte) (bool, error) {
+	item := newAsyncItem(job.ticket, job.request, body)
+	if aq.store != nil {
+		if err := aq.store.save(item); err != nil {
+			return false, fmt.Errorf("persist asynchronous invocation: %w", err)
+		}
+	}
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+	select {
+	case aq.jobs <- job:
+		aq.results[job.ticket] = &asyncResult{info: item.info()}
+		return true, nil
+	default:
+		aq.discard(job.ticket)
+		return false, nil
+	}
+}
+
+// mark invocation in-flight, false if invocation is purged
+func (aq *AsyncQueue) begin(ticket string) bool {
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+	res, ok := aq.results[ticket]
+	if !ok || res.done {
+		return false
+	}
+	res.info.InFlight = true
+	if aq.store != nil {
+		if err := aq.store.begin(ticket); err != nil {
+			log.Println("[WARN]", "async: mark invocation", ticket, "in-flight -", err)
+		}
+	}
+	return true
+}
+
+func (aq *AsyncQueue) complete(ticket string, result *asyncResult) {
+	now := time.Now()
+	result.done = true
+	result.expires = now.Add(aq.config.ttl())
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+	aq.discard(ticket)
+	aq.results[ticket] = result
+	if now.Sub(aq.swept) < asyncSweepInterval {
+		return
+	}
+	aq.swept = now
+	for id, res := range aq.results {
+		if res.done && now.After(res.expires) {
+			delete(aq.results, id)
+		}
+	}
+}
+
+// remove persisted invocation (if enabled)
+func (aq *AsyncQueue) discard(ticket string) {
+	if aq.store == nil {
+		return
+	}
+	if err := aq.store.remove(ticket); err != nil {
+		log.Println("[WARN]", "async: remove invocation", ticket, "-", err)
+	}
+}
+
+// result by ticket, false if ticket is unknown or result expired
+func (aq *AsyncQueue) result(ticket string) (*asyncResult, bool) {
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+	res, ok := aq.results[ticket]
+	if !ok || (res.done && time.Now().After(res.expires)) {
+		return nil, false
+	}
+	return res, true
+}
+
+// Pending invocations (including in-flight) in order of acceptance
+func (aq *AsyncQueue) Pending() []application.AsyncInvocation {
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+	var list = make([]application.AsyncInvocation, 0)
+	for _, res := range aq.results {
+		if !res.done {
+			list = append(list, res.info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Accepted.Before(list[j].Accepted)
+	})
+	return list
+}
+
+// Purge pending invocations by tickets (empty - all pending), in-flight invocations are not interrupted. Purged
+// tickets become unknown. Returns number of purged invocations
+func (aq *AsyncQueue) Purge(tickets []string) (int, error) {
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+	var selected = make(map[string]bool, len(tickets))
+	for _, ticket := range tickets {
+		selected[ticket] = true
+	}
+	var purged int
+	for ticket, res := range aq.results {
+		if res.done || res.info.InFlight || (len(tickets) > 0 && !selected[ticket]) {
+			continue
+		}
+		if aq.store != nil {
+			if err := aq.store.remove(ticket); err != nil {
+				return purged, fmt.Errorf("purge invocation %s: %w", ticket, err)
+			}
+		}
+		// job in queue is skipped by worker
+		delete(aq.results, ticket)
+		purged++
+	}
+	return purged, nil
+}
+
+// serve request in background, response is kept in memory. Aborted response (see http.ErrAbortHandler) is not a
+// complete result, so it is replaced by 502
+func serveAsync(handler http.Handler, job *asyncJob) (result *asyncResult) {
+	writer := &resultWriter{header: make(http.Header)}
+	defer func() {
+		if r := recover(); r != nil {
+			if r != http.ErrAbortHandler {
+				log.Println("[ERROR]", "async invocation", job.ticket, "panic:", r)
+			}
+			result = &asyncResult{status: http.StatusBadGateway, header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, body: []byte("response aborted\n")}
+		}
+	}()
+	handler.ServeHTTP(writer, job.request)
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+	return &asyncResult{status: writer.status, header: writer.header, body: writer.body.Bytes()}
+}
+
+// response writer of asynchronous invocation
+type resultWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *resultWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *resultWriter) WriteHeader(status int) {
+	if rw.status == 0 && status >= http.StatusOK {
+		rw.status = status
+	}
+}
+
+func (rw *resultWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.body.Write(p)
+}
+
+// Flush is no-op: result is complete only after invocation
+func (rw *resultWriter) Flush() {}
+
+// accept request for asynchronous invocation (path without async prefix): target should exist and allow request by
+// policy, body is read (up to limit of lambda and queue) before ticket is returned by 202. The rest of checks is
+// applied by invocation in background, so rejections (rate limit, method, ...) are results
+func (srv *Server) submitAsync(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		sections := strings.SplitN(strings.Trim(request.URL.Path, "/"), "/", 3)
+		var find func(name string) (*application.Definition, error)
+		var alias string
+		if len(sections) >= 2 {
+			switch "/" + sections[0] + "/" {
+			case types.LambdaPrefix:
+				find = srv.Platform.FindByUID
+			case types.LinkPrefix:
+				find, alias = srv.Platform.FindByLink, sections[1]
+			}
+		}
+		if find == nil {
+			http.NotFound(writer, request)
+			return
+		}
+		lambda, err := find(sections[1])
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		}
+		manifest := lambda.Lambda.Effective().ForAlias(alias)
+		limit := srv.Async.config.MaxPayload
+		if max := manifest.RequestLimit(request.Header.Get("Content-Type")); max > 0 && (limit <= 0 || max < limit) {
+			limit = max
+		}
+		// body is read after checks of access: 100-continue is not sent to rejected request
+		if limit > 0 && request.ContentLength > limit {
+			err := fmt.Errorf("%w: request of %d bytes exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, request.ContentLength, limit)
+			http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		req := srv.fromHTTP(request)
+		req.Alias = alias
+		if err := checkNetwork(req, manifest); err != nil {
+			http.Error(writer, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := checkBasicAuth(req, manifest); err != nil {
+			challenge(writer, manifest.BasicAuth)
+			return
+		}
+		if err := srv.checkJWT(req, lambda, manifest); err != nil {
+			bearerChallenge(writer, err)
+			return
+		}
+		if err := srv.Policies.Inspect(lambda.UID, req); err != nil {
+			http.Error(writer, err.Error(), http.StatusForbidden)
+			return
+		}
+		body, err := readPayload(request.Body, limit)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err := srv.checkSignature(req, body, lambda, manifest); err != nil {
+			http.Error(writer, err.Error(), signatureStatus(err))
+			return
+		}
+
+		id := requestID(request)
+		// invocation outlives request, result is kept uncompressed for any client
+		job := &asyncJob{ticket: uuid.New().String(), request: request.Clone(ctx)}
+		job.request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		job.request.Header.Set(types.RequestIDHeader, id)
+		job.request.Header.Del("Accept-Encoding")
+		writer.Header().Set(types.RequestIDHeader, id)
+		accepted, err := srv.Async.push(job, body)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !accepted {
+			writer.Header().Set("Retry-After", "1")
+			http.Error(writer, "queue of asynchronous invocations is full", http.StatusServiceUnavailable)
+			return
+		}
+		location := types.ResultPath(job.ticket)
+		writer.Header().Set("Location", location)
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(writer).Encode(types.AsyncTicket{Ticket: job.ticket, Result: location})
+	})
+}
+
+// serve result of asynchronous invocation by ticket (path without result prefix): 404 while pending (with
+// Retry-After) or if ticket is unknown or expired, otherwise status, headers and body of response
+func (srv *Server) asyncResult(writer http.ResponseWriter, request *http.Request) {
+	res, ok := srv.Async.result(strings.Trim(request.URL.Path, "/"))
+	if !ok {
+		http.Error(writer, "unknown or expired ticket", http.StatusNotFound)
+		return
+	}
+	if !res.done {
+		writer.Header().Set("Retry-After", "1")
+		http.Error(writer, "result is not ready", http.StatusNotFound)
+		return
+	}
+	for k, v := range res.header {
+		writer.Header()[k] = v
+	}
+	writer.WriteHeader(res.status)
+	_, _ = writer.Write(res.body)
+}
+
+// read body up to limit (zero - unlimited)
+func readPayload(body io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return ioutil.ReadAll(body)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: request exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, limit)
+	}
+	return data, nil
+}
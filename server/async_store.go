@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+const (
+	asyncPendingSuffix  = ".json"     // accepted invocation
+	asyncInFlightSuffix = ".inflight" // invocation picked by worker
+	asyncPartialSuffix  = ".tmp"      // invocation being written (not accepted yet)
+	asyncCorruptDir     = "corrupted" // invocations which could not be restored
+)
+
+// directory of pending asynchronous invocations: one file per invocation named by ticket, suffix is state
+type asyncStore struct {
+	dir string
+}
+
+// invocation persisted in store
+type asyncItem struct {
+	Ticket     string      `json:"ticket"`
+	Accepted   time.Time   `json:"accepted"`
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`         // path (without async prefix) and query
+	RequestURI string      `json:"request_uri"` // original request line (with async prefix)
+	Host       string      `json:"host"`
+	RemoteAddr string      `json:"remote_addr"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	req        *http.Request
+}
+
+func newAsyncItem(ticket string, request *http.Request, body []byte) *asyncItem {
+	return &asyncItem{
+		Ticket:     ticket,
+		Accepted:   time.Now(),
+		Method:     request.Method,
+		URL:        request.URL.RequestURI(),
+		RequestURI: request.RequestURI,
+		Host:       request.Host,
+		RemoteAddr: request.RemoteAddr,
+		Header:     request.Header,
+		Body:       body,
+	}
+}
+
+func (item *asyncItem) info() application.AsyncInvocation {
+	path := item.URL
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return application.AsyncInvocation{
+		Ticket:   item.Ticket,
+		Method:   item.Method,
+		Path:     path,
+		Size:     int64(len(item.Body)),
+		Accepted: item.Accepted,
+	}
+}
+
+// restore request of invocation (see asyncStore.load)
+func (item *asyncItem) request(ctx context.Context) *http.Request {
+	return item.req.WithContext(ctx)
+}
+
+// write invocation to file and sync it, invocation is visible only after complete write
+func (as *asyncStore) save(item *asyncItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(as.dir, 0700); err != nil {
+		return err
+	}
+	partial := as.file(item.Ticket, asyncPartialSuffix)
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, as.file(item.Ticket, asyncPendingSuffix))
+}
+
+// mark invocation in-flight
+func (as *asyncStore) begin(ticket string) error {
+	return os.Rename(as.file(ticket, asyncPendingSuffix), as.file(ticket, asyncInFlightSuffix))
+}
+
+// remove invocation in any state
+func (as *asyncStore) remove(ticket string) error {
+	for _, suffix := range []string{asyncPendingSuffix, asyncInFlightSuffix} {
+		if err := os.Remove(as.file(ticket, suffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// pending invocations in order of acceptance: in-flight invocations (interrupted by restart) are pending again,
+// partially written are removed, invocations which could not be restored are moved to corrupted directory
+func (as *asyncStore) load() ([]*asyncItem, error) {
+	entries, err := os.ReadDir(as.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []*asyncItem
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		file := filepath.Join(as.dir, name)
+		switch {
+		case strings.HasSuffix(name, asyncPartialSuffix):
+			if err := os.Remove(file); err != nil {
+				return nil, err
+			}
+			continue
+		case strings.HasSuffix(name, asyncInFlightSuffix):
+			pending := strings.TrimSuffix(file, asyncInFlightSuffix) + asyncPendingSuffix
+			if err := os.Rename(file, pending); err != nil {
+				return nil, err
+			}
+			file, name = pending, filepath.Base(pending)
+		case !strings.HasSuffix(name, asyncPendingSuffix):
+			continue
+		}
+		item, err := readAsyncItem(file, strings.TrimSuffix(name, asyncPendingSuffix))
+		if err != nil {
+			if err := as.quarantine(file, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Accepted.Before(items[j].Accepted)
+	})
+	return items, nil
+}
+
+func readAsyncItem(file string, ticket string) (*asyncItem, error) {
+	if _, err := uuid.Parse(ticket); err != nil {
+		return nil, fmt.Errorf("invalid ticket: %w", err)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var item asyncItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	if item.Ticket != ticket {
+		return nil, errors.New("ticket does not match name of file")
+	}
+	req, err := http.NewRequest(item.Method, item.URL, bytes.NewReader(item.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = item.Header
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Host, req.RemoteAddr, req.RequestURI = item.Host, item.RemoteAddr, item.RequestURI
+	item.req = req
+	return &item, nil
+}
+
+// move file to corrupted directory. Name is prefixed by time to avoid collisions
+func (as *asyncStore) quarantine(file string, reason error) error {
+	dir := filepath.Join(as.dir, asyncCorruptDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, strconv.FormatInt(time.Now().UnixNano(), 10)+"-"+filepath.Base(file))
+	if err := os.Rename(file, target); err != nil {
+		return fmt.Errorf("move corrupted invocation %s (%v): %w", filepath.Base(file), reason, err)
+	}
+	log.Println("[WARN]", "async: invocation", filepath.Base(file), "moved to", target+":", reason)
+	return nil
+}
+
+func (as *asyncStore) file(ticket string, suffix string) string {
+	return filepath.Join(as.dir, ticket+suffix)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var errUnauthorized = errors.New("basic auth: invalid credentials")
+
+// reject request with 401 and challenge if credentials are missing or invalid (see types.Manifest.BasicAuth). Name of
+// authenticated user is kept in request and passed to lambda
+func (srv *Server) allowByBasicAuth(req *types.Request, writer http.ResponseWriter, manifest types.Manifest, record *stats.Record) error {
+	if err := checkBasicAuth(req, manifest); err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		record.Rejected = true
+		challenge(writer, manifest.BasicAuth)
+		return err
+	}
+	record.Request.User = req.User
+	return nil
+}
+
+func checkBasicAuth(req *types.Request, manifest types.Manifest) error {
+	auth := manifest.BasicAuth
+	if auth == nil || !auth.Protects(req.Alias) {
+		return nil
+	}
+	username, password, ok := parseBasicAuth(req.Headers["Authorization"])
+	if !ok || !auth.Authenticate(username, password) {
+		return errUnauthorized
+	}
+	req.User = username
+	return nil
+}
+
+func challenge(writer http.ResponseWriter, auth *types.BasicAuth) {
+	writer.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(auth.RealmName())+`, charset="UTF-8"`)
+	http.Error(writer, errUnauthorized.Error(), http.StatusUnauthorized)
+}
+
+// credentials of Authorization header, as http.Request.BasicAuth
+func parseBasicAuth(header string) (username, password string, ok bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// response header with result of cache lookup (see Manifest.Cache): HIT or MISS
+const CacheHeader = "X-Cache"
+
+// cache of successful responses per lambda (see Manifest.Cache). Responses of lambda are dropped as soon as revision
+// of lambda is changed
+type responseCache struct {
+	lock    sync.Mutex
+	lambdas map[string]*lambdaCache
+}
+
+// responses of one revision of lambda in order of use (the most recent is the first)
+type lambdaCache struct {
+	revision uint64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type cachedResponse struct {
+	key     string
+	output  []byte // output of lambda
+	expires time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{lambdas: make(map[string]*lambdaCache)}
+}
+
+// cached output of lambda by key of request (false - not cached, expired or lambda is changed)
+func (rc *responseCache) get(uid string, revision uint64, key string) ([]byte, bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	lc, ok := rc.lambdas[uid]
+	if !ok {
+		return nil, false
+	}
+	if lc.revision != revision {
+		delete(rc.lambdas, uid)
+		return nil, false
+	}
+	item, ok := lc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := item.Value.(*cachedResponse)
+	if time.Now().After(entry.expires) {
+		lc.remove(item)
+		return nil, false
+	}
+	lc.order.MoveToFront(item)
+	return entry.output, true
+}
+
+// save output of lambda by key of request, the least recently used responses over the limit are evicted
+func (rc *responseCache) put(uid string, revision uint64, key string, output []byte, caching types.Caching) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	lc, ok := rc.lambdas[uid]
+	if !ok || lc.revision != revision {
+		lc = &lambdaCache{revision: revision, order: list.New(), entries: make(map[string]*list.Element)}
+		rc.lambdas[uid] = lc
+	}
+	if item, ok := lc.entries[key]; ok {
+		lc.remove(item)
+	}
+	lc.entries[key] = lc.order.PushFront(&cachedResponse{key: key, output: output, expires: time.Now().Add(time.Duration(caching.TTL))})
+	for lc.order.Len() > caching.Limit() {
+		lc.remove(lc.order.Back())
+	}
+}
+
+func (lc *lambdaCache) remove(item *list.Element) {
+	lc.order.Remove(item)
+	delete(lc.entries, item.Value.(*cachedResponse).key)
+}
+
+// request asks for fresh response: Cache-Control (or Pragma) no-cache
+func bypassCache(req *types.Request) bool {
+	return strings.Contains(req.Headers["Cache-Control"], "no-cache") || strings.Contains(req.Headers["Pragma"], "no-cache")
+}
+
+// output is successful response which could be cached: no error, 2xx status and no private response headers
+func cacheable(manifest types.Manifest, output []byte, err error) bool {
+	if err != nil {
+		return false
+	}
+	if !manifest.ParseHeaders {
+		return true
+	}
+	status, header, _, err := parseHeaderBlock(output)
+	if err != nil {
+		return true // sent as is with 200
+	}
+	if status != 0 && (status < 200 || status > 299) {
+		return false
+	}
+	control := header.Get("Cache-Control")
+	return !strings.Contains(control, "no-store") && !strings.Contains(control, "private")
+}
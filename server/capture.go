@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// capture request passed to lambda if lambda opted in (see types.Manifest.Capture). Request is saved with result of
+// invocation by returned function
+func (srv *Server) capture(req *types.Request, lambda *application.Definition, manifest types.Manifest, record *stats.Record) (*types.Request, func()) {
+	if manifest.Capture == nil || srv.Cases == nil || srv.Cases.Captures() == nil {
+		return req, func() {}
+	}
+	captures := srv.Cases.Captures()
+	body := &capturingReader{ReadCloser: req.Body, limit: manifest.Capture.Limit()}
+	request := *req
+	request.Body = nil
+	return req.WithBody(body), func() {
+		end := record.End
+		if end.IsZero() {
+			end = time.Now()
+		}
+		captured := application.CapturedRequest{
+			Lambda:    lambda.UID,
+			Captured:  record.Begin,
+			Duration:  types.JsonDuration(end.Sub(record.Begin)),
+			Error:     record.Err,
+			Size:      body.n,
+			Truncated: body.n > body.limit,
+			Request:   request,
+		}
+		if err := captures.Add(captured, body.data.Bytes(), manifest.Capture.Requests); err != nil {
+			log.Println("[ERROR]", "failed capture request of lambda", lambda.UID, ":", err)
+		}
+	}
+}
+
+// keeps body read by lambda up to limit
+type capturingReader struct {
+	io.ReadCloser
+	limit int64
+	n     int64
+	data  bytes.Buffer
+}
+
+func (cr *capturingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	if keep := cr.limit - int64(cr.data.Len()); keep > 0 {
+		cr.data.Write(p[:min(int64(n), keep)])
+	}
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+// maximum size of header block in the beginning of lambda output (see Manifest.ParseHeaders)
+const maxHeaderBlock = 64 * 1024
+
+var errIncompleteHeaders = errors.New("header block is not terminated by blank line")
+
+// parse CGI header block in the beginning of output: headers terminated by blank line with optional Status
+// pseudo-header (redirect by Location without Status is 302). Returns status (zero if not set by headers), headers
+// and the rest of output
+func parseHeaderBlock(output []byte) (int, http.Header, []byte, error) {
+	end := headerBlockEnd(output)
+	if end < 0 {
+		return 0, nil, nil, errIncompleteHeaders
+	}
+	mime, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(output[:end]))).ReadMIMEHeader()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if len(mime) == 0 {
+		return 0, nil, nil, fmt.Errorf("no headers")
+	}
+	header := http.Header(mime)
+	var status int
+	if value := header.Get("Status"); value != "" {
+		code, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+		status, err = strconv.Atoi(code)
+		if err != nil || status < 100 || status > 999 {
+			return 0, nil, nil, fmt.Errorf("invalid status %q", value)
+		}
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+	return status, header, output[end:], nil
+}
+
+// position after blank line which terminates header block, -1 if not found
+func headerBlockEnd(output []byte) int {
+	var offset int
+	for {
+		idx := bytes.IndexByte(output[offset:], '\n')
+		if idx < 0 {
+			return -1
+		}
+		line := output[offset : offset+idx]
+		offset += idx + 1
+		if len(line) == 0 || (len(line) == 1 && line[0] == '\r') {
+			return offset
+		}
+	}
+}
+
+// apply header block of buffered output; malformed block is kept as body with warning in the record. Status from
+// headers wins over the default status
+func (lr *lambdaResponse) parseHeaders(output []byte, record *stats.Record, status int) (int, []byte) {
+	explicit, header, body, err := parseHeaderBlock(output)
+	if err != nil {
+		if len(output) > 0 {
+			record.Warning = "malformed response headers: " + err.Error()
+		}
+		return status, output
+	}
+	lr.merge(header)
+	if explicit != 0 {
+		status = explicit
+	}
+	return status, body
+}
+
+// writer which reads header block from the beginning of output, then sends status and headers and passes the rest
+// to writer opened for the status. Close should be called to flush output without (complete) header block
+type headerWriter struct {
+	response *lambdaResponse
+	record   *stats.Record
+	open     func(status int) io.WriteCloser
+	buffer   bytes.Buffer
+	output   io.WriteCloser // not nil if header block is processed
+}
+
+func (lr *lambdaResponse) headerWriter(record *stats.Record, open func(status int) io.WriteCloser) io.WriteCloser {
+	return &headerWriter{response: lr, record: record, open: open}
+}
+
+func (hw *headerWriter) Write(data []byte) (int, error) {
+	if hw.output != nil {
+		return hw.output.Write(data)
+	}
+	hw.buffer.Write(data)
+	status, header, body, err := parseHeaderBlock(hw.buffer.Bytes())
+	if errors.Is(err, errIncompleteHeaders) && hw.buffer.Len() <= maxHeaderBlock {
+		return len(data), nil
+	}
+	if err != nil {
+		hw.record.Warning = "malformed response headers: " + err.Error()
+		status, body = http.StatusOK, hw.buffer.Bytes()
+	} else {
+		hw.response.merge(header)
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	hw.output = hw.open(status)
+	if _, err := hw.output.Write(body); err != nil {
+		return 0, err
+	}
+	hw.buffer.Reset()
+	return len(data), nil
+}
+
+func (hw *headerWriter) Close() error {
+	if hw.output == nil {
+		status, body := hw.response.parseHeaders(hw.buffer.Bytes(), hw.record, http.StatusOK)
+		hw.output = hw.open(status)
+		if _, err := hw.output.Write(body); err != nil {
+			_ = hw.output.Close()
+			return err
+		}
+	}
+	return hw.output.Close()
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// request header to bypass coalescing (any non-empty value)
+const NoCoalesceHeader = "X-No-Coalesce"
+
+// coalescer of concurrent identical invocations (single flight)
+type coalescer struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{} // closed when invocation finished
+	waiters int
+	body    []byte
+	err     error
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{flights: make(map[string]*flight)}
+}
+
+// invoke function once for concurrent calls with the same key and share buffered output. If there are already
+// maxWaiters (if positive) waiters, function is invoked separately. Returns true if output is shared from another call.
+func (c *coalescer) do(key string, maxWaiters int, invoke func(out io.Writer) error) ([]byte, bool, error) {
+	c.lock.Lock()
+	if f, ok := c.flights[key]; ok {
+		if maxWaiters <= 0 || f.waiters < maxWaiters {
+			f.waiters++
+			c.lock.Unlock()
+			<-f.done
+			return f.body, true, f.err
+		}
+		c.lock.Unlock()
+		var out bytes.Buffer
+		err := invoke(&out)
+		return out.Bytes(), false, err
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.lock.Unlock()
+
+	var out bytes.Buffer
+	f.err = invoke(&out)
+	f.body = out.Bytes()
+
+	c.lock.Lock()
+	delete(c.flights, key)
+	c.lock.Unlock()
+	close(f.done)
+	return f.body, false, f.err
+}
+
+// key of request: lambda, method, URL (with query), selected headers and body
+func coalesceKey(uid string, req *types.Request, headers []string, body []byte) string {
+	hash := sha256.New()
+	for _, v := range []string{uid, req.Method, req.URL, req.PublicURL} {
+		hash.Write([]byte(v))
+		hash.Write([]byte{0})
+	}
+	for _, name := range headers {
+		hash.Write([]byte(name + "=" + req.Headers[http.CanonicalHeaderKey(name)]))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
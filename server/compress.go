@@ -0,0 +1,232 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Output of lambda shorter than compressThreshold is sent as is: compression doesn't pay off
+const compressThreshold = 1024
+
+// compressible media types besides text/* and types with +json or +xml suffix
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"application/x-ndjson":   true,
+	"image/svg+xml":          true,
+}
+
+var (
+	gzipEncoders = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	zlibEncoders = sync.Pool{New: func() interface{} { return zlib.NewWriter(nil) }}
+)
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// writer of public handler which compresses output of lambda by Accept-Encoding of request (gzip or deflate).
+// Compression is disabled until it is enabled for lambda (see enableCompression). Output without length is held
+// till compressThreshold to decide: output flushed before the threshold is sent as is. Responses compressed by
+// lambda (Content-Encoding is set), partial content, responses without body and not compressible content types are
+// passed through untouched
+type compressWriter struct {
+	http.ResponseWriter
+	accept   string // Accept-Encoding of request
+	enabled  bool
+	status   int    // status of response held till compression is decided (zero - not written)
+	held     []byte // output held till compression is decided
+	decided  bool
+	encoding string
+	encoder  encoder // nil - output is not compressed
+}
+
+func newCompressWriter(writer http.ResponseWriter, request *http.Request) *compressWriter {
+	return &compressWriter{ResponseWriter: writer, accept: request.Header.Get("Accept-Encoding")}
+}
+
+// enable compression of lambda output if not disabled by manifest. Writer (or any writer wrapped by it) should be
+// compressWriter, otherwise compression is not available
+func enableCompression(writer http.ResponseWriter, manifest types.Manifest) {
+	for {
+		switch w := writer.(type) {
+		case *compressWriter:
+			w.enabled = !manifest.DisableCompression
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			writer = w.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.enabled || cw.decided || status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	header := cw.Header()
+	if !bodyAllowed(status) || header.Get("Content-Encoding") != "" {
+		cw.decide(0)
+	} else if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+		cw.decide(length)
+	}
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if !cw.enabled {
+		return cw.ResponseWriter.Write(data)
+	}
+	if cw.status == 0 && !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.held = append(cw.held, data...)
+		if len(cw.held) >= compressThreshold {
+			if err := cw.decide(len(cw.held)); err != nil {
+				return 0, err
+			}
+		}
+		return len(data), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+// FlushError for http.ResponseController: compressed output is flushed to client as well
+func (cw *compressWriter) FlushError() error {
+	if cw.enabled && !cw.decided && cw.status != 0 {
+		if err := cw.decide(len(cw.held)); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close sends held output and finishes compressed stream
+func (cw *compressWriter) Close() error {
+	if cw.enabled && !cw.decided && cw.status != 0 {
+		if err := cw.decide(len(cw.held)); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+	err := cw.encoder.Close()
+	cw.release()
+	return err
+}
+
+// choose encoding by size and headers of response, send status and held output
+func (cw *compressWriter) decide(size int) error {
+	cw.decided = true
+	header := cw.Header()
+	if _, ok := header["Content-Type"]; !ok && len(cw.held) > 0 && bodyAllowed(cw.status) {
+		// the same as net/http sniffing, content type is required to decide
+		header.Set("Content-Type", http.DetectContentType(cw.held))
+	}
+	varies := bodyAllowed(cw.status) && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+		!strings.Contains(header.Get("Cache-Control"), "no-transform") && compressible(header.Get("Content-Type"))
+	if varies {
+		if !strings.Contains(strings.ToLower(strings.Join(header.Values("Vary"), ",")), "accept-encoding") {
+			header.Add("Vary", "Accept-Encoding")
+		}
+		cw.encoding = negotiateEncoding(cw.accept)
+	}
+	if varies && cw.encoding != "" && size >= compressThreshold {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		if cw.encoding == "gzip" {
+			cw.encoder = gzipEncoders.Get().(encoder)
+		} else {
+			cw.encoder = zlibEncoders.Get().(encoder)
+		}
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	held := cw.held
+	cw.held = nil
+	if len(held) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(held)
+	} else {
+		_, err = cw.ResponseWriter.Write(held)
+	}
+	return err
+}
+
+func (cw *compressWriter) release() {
+	if cw.encoding == "gzip" {
+		gzipEncoders.Put(cw.encoder)
+	} else {
+		zlibEncoders.Put(cw.encoder)
+	}
+	cw.encoder = nil
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType != "text/event-stream" && (strings.HasPrefix(mediaType, "text/") || compressibleTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml"))
+}
+
+// preferred supported encoding of Accept-Encoding by quality: gzip or deflate (empty - compression is not accepted)
+func negotiateEncoding(accept string) string {
+	var best string
+	var bestQuality float64
+	quality := map[string]float64{}
+	for _, item := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		quality[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	for _, name := range []string{"gzip", "deflate"} {
+		q, ok := quality[name]
+		if !ok {
+			q = quality["*"]
+		}
+		if q > bestQuality {
+			best, bestQuality = name, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,190 @@
+package server_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestHandler_compression(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	content := []byte(`{"items":[` + strings.Repeat(`{"name":"item"},`, 200) + `{}]}`)
+	create := func(manifest types.Manifest) string {
+		if manifest.Run == nil {
+			manifest.Run = []string{"/bin/sh", "-c", "cat data"}
+		}
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(srv.Dir, uid, "data"), content, 0755))
+		return uid
+	}
+	request := func(method, uid, encoding string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, httpServer.URL+"/a/"+uid, nil)
+		require.NoError(t, err)
+		// explicit encoding: response is not decoded by client
+		req.Header.Set("Accept-Encoding", encoding)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+	gunzip := func(data []byte) []byte {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		plain, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		return plain
+	}
+
+	jsonHeaders := map[string]string{"Content-Type": "application/json"}
+	streamed := create(types.Manifest{OutputHeaders: jsonHeaders})
+	res, body := request(http.MethodGet, streamed, "gzip, deflate")
+	assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", res.Header.Get("Vary"))
+	assert.Less(t, len(body), len(content))
+	assert.Equal(t, content, gunzip(body))
+
+	res, body = request(http.MethodGet, streamed, "gzip;q=0.5, deflate")
+	assert.Equal(t, "deflate", res.Header.Get("Content-Encoding"))
+	reader, err := zlib.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	plain, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, plain)
+
+	res, body = request(http.MethodGet, streamed, "identity")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", res.Header.Get("Vary"), "cached response depends on encoding")
+	assert.Equal(t, content, body)
+
+	// buffered output: HEAD has the same headers as GET
+	buffered := create(types.Manifest{OutputHeaders: jsonHeaders, StatusMap: map[int]int{1: http.StatusBadRequest}})
+	res, body = request(http.MethodGet, buffered, "gzip")
+	assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+	assert.NotEqual(t, int64(len(content)), res.ContentLength, "length of compressed body")
+	assert.Equal(t, content, gunzip(body))
+	head, _ := request(http.MethodHead, buffered, "gzip")
+	assert.Equal(t, "gzip", head.Header.Get("Content-Encoding"))
+	assert.Equal(t, res.Header.Get("Content-Type"), head.Header.Get("Content-Type"))
+
+	// short output
+	short := create(types.Manifest{Run: []string{"/bin/sh", "-c", `printf '{"ok":true}'`}, OutputHeaders: jsonHeaders})
+	res, body = request(http.MethodGet, short, "gzip")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	assert.Equal(t, `{"ok":true}`, string(body))
+
+	// not compressible content type
+	binary := create(types.Manifest{OutputHeaders: map[string]string{"Content-Type": "application/octet-stream"}})
+	res, body = request(http.MethodGet, binary, "gzip")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	assert.Equal(t, content, body)
+
+	// opt-out
+	disabled := create(types.Manifest{OutputHeaders: jsonHeaders, DisableCompression: true})
+	res, body = request(http.MethodGet, disabled, "gzip")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	assert.Empty(t, res.Header.Get("Vary"))
+	assert.Equal(t, content, body)
+
+	// compressed by lambda
+	var compressed bytes.Buffer
+	zipped := gzip.NewWriter(&compressed)
+	_, _ = zipped.Write(content)
+	require.NoError(t, zipped.Close())
+	precompressed := create(types.Manifest{Run: []string{"/bin/sh", "-c", `printf 'Content-Type: application/json\r\nContent-Encoding: gzip\r\n\r\n'; cat data.gz`}, ParseHeaders: true})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srv.Dir, precompressed, "data.gz"), compressed.Bytes(), 0755))
+	res, body = request(http.MethodGet, precompressed, "gzip")
+	assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+	assert.Equal(t, compressed.Bytes(), body, "passed through untouched")
+
+	// server-sent events are not compressed
+	events := create(types.Manifest{Run: []string{"/bin/sh", "-c", "cat data; echo; echo"}, Streaming: types.StreamingSSE})
+	res, _ = request(http.MethodGet, events, "gzip")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+
+	// progressive output is flushed through compressor
+	progressive := create(types.Manifest{Run: []string{"/bin/sh", "-c", "cat data; sleep 2; echo done"}, OutputHeaders: jsonHeaders})
+	req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/a/"+progressive, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	started := time.Now()
+	stream, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	assert.Equal(t, "gzip", stream.Header.Get("Content-Encoding"))
+	unzipped, err := gzip.NewReader(stream.Body)
+	require.NoError(t, err)
+	first := make([]byte, len(content))
+	_, err = io.ReadFull(unzipped, first)
+	require.NoError(t, err)
+	assert.Equal(t, content, first)
+	assert.Less(t, time.Since(started), 2*time.Second, "first part is received before lambda is finished")
+	rest, err := ioutil.ReadAll(unzipped)
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(rest))
+}
+
+// 1MB JSON response as is and compressed: transferred bytes per response are reported as metric
+func BenchmarkHandler_compression(b *testing.B) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(b, err)
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	var content bytes.Buffer
+	content.WriteString("[")
+	for content.Len() < 1024*1024 {
+		content.WriteString(`{"id":12345,"name":"benchmark item","tags":["json","compression"],"active":true},`)
+	}
+	content.WriteString("{}]")
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:           []string{"/bin/sh", "-c", "cat data.json"},
+		OutputHeaders: map[string]string{"Content-Type": "application/json"},
+	}})
+	require.NoError(b, err)
+	require.NoError(b, ioutil.WriteFile(filepath.Join(srv.Dir, uid, "data.json"), content.Bytes(), 0755))
+
+	for _, encoding := range []string{"identity", "gzip", "deflate"} {
+		b.Run(encoding, func(b *testing.B) {
+			var transferred int64
+			for i := 0; i < b.N; i++ {
+				req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/a/"+uid, nil)
+				require.NoError(b, err)
+				req.Header.Set("Accept-Encoding", encoding)
+				res, err := http.DefaultClient.Do(req)
+				require.NoError(b, err)
+				n, err := io.Copy(ioutil.Discard, res.Body)
+				_ = res.Body.Close()
+				require.NoError(b, err)
+				transferred += n
+			}
+			b.ReportMetric(float64(transferred)/float64(b.N), "bytes/response")
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// suggested delay of retry for requests rejected by concurrency limit
+const overflowRetryAfter = time.Second
+
+var errConcurrencyLimit = errors.New("concurrency limit of lambda reached")
+
+// per-lambda semaphores of concurrent invocations (see Manifest.MaxConcurrency). Excess requests are waiting in FIFO
+// queue.
+type concurrency struct {
+	lock    sync.Mutex
+	lambdas map[string]*slots
+}
+
+type slots struct {
+	running int
+	queue   []chan struct{}
+}
+
+func newConcurrency() *concurrency {
+	return &concurrency{lambdas: make(map[string]*slots)}
+}
+
+// acquire slot of lambda. Without wait or if context is done before slot is free, errConcurrencyLimit is returned.
+// Limit is passed on each call, so changed manifest is applied to the next requests.
+func (c *concurrency) acquire(ctx context.Context, uid string, limit int, wait bool) error {
+	c.lock.Lock()
+	s, ok := c.lambdas[uid]
+	if !ok {
+		s = &slots{}
+		c.lambdas[uid] = s
+	}
+	if len(s.queue) == 0 && s.running < limit {
+		s.running++
+		c.lock.Unlock()
+		return nil
+	}
+	if !wait {
+		c.lock.Unlock()
+		return errConcurrencyLimit
+	}
+	ready := make(chan struct{})
+	s.queue = append(s.queue, ready)
+	c.lock.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, item := range s.queue {
+		if item == ready {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return errConcurrencyLimit
+		}
+	}
+	// slot granted concurrently with cancel
+	c.releaseLocked(uid, s, limit)
+	return errConcurrencyLimit
+}
+
+// release slot of lambda and pass it to the first waiting request
+func (c *concurrency) release(uid string, limit int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.releaseLocked(uid, c.lambdas[uid], limit)
+}
+
+func (c *concurrency) releaseLocked(uid string, s *slots, limit int) {
+	s.running--
+	for len(s.queue) > 0 && s.running < limit {
+		close(s.queue[0])
+		s.queue = s.queue[1:]
+		s.running++
+	}
+	if s.running == 0 && len(s.queue) == 0 {
+		delete(c.lambdas, uid)
+	}
+}
+
+// acquire slot of lambda by concurrency limit (see Manifest.MaxConcurrency) and record time of waiting. Waiting is
+// bounded by time limit of lambda. Returned function releases the slot
+func (srv *Server) acquireSlot(ctx context.Context, lambda *application.Definition, manifest types.Manifest, record *stats.Record) (func(), error) {
+	limit := manifest.MaxConcurrency
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	if limit := manifest.InvocationLimit(); limit > 0 {
+		cctx, cancel := context.WithTimeout(ctx, limit)
+		defer cancel()
+		ctx = cctx
+	}
+	started := time.Now()
+	err := srv.slots.acquire(ctx, lambda.UID, limit, manifest.OverflowPolicy != types.OverflowReject)
+	record.Wait = time.Since(started)
+	if err != nil {
+		return nil, err
+	}
+	return func() { srv.slots.release(lambda.UID, limit) }, nil
+}
+
+// reject request with 429 if lambda has no free slot
+func (srv *Server) rejectOverflow(writer http.ResponseWriter, record *stats.Record, err error) {
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	writer.Header().Set("Retry-After", strconv.Itoa(int(overflowRetryAfter.Seconds())))
+	http.Error(writer, err.Error(), http.StatusTooManyRequests)
+}
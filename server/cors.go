@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// public routes of lambdas (path after prefix starts by UID or link, byLink): lambdas without CORS policy are opened
+// for any origin (see openedHandler). Lambdas with policy answer preflight by server without invocation and get
+// Access-Control-* headers only for allowed origin
+func corsHandler(find func(name string) (*application.Definition, error), byLink bool, handler http.Handler) http.Handler {
+	opened := openedHandler(handler)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		name := strings.SplitN(strings.Trim(request.URL.Path, "/"), "/", 2)[0]
+		lambda, err := find(name)
+		if err != nil {
+			opened.ServeHTTP(writer, request)
+			return
+		}
+		manifest := lambda.Lambda.Effective()
+		if byLink {
+			// preflight answers methods of alias
+			manifest = manifest.ForAlias(name)
+		}
+		if !manifest.CORS.Enabled() {
+			opened.ServeHTTP(writer, request)
+			return
+		}
+		preflight := request.Method == http.MethodOptions
+		setCORSHeaders(writer.Header(), request, manifest, preflight)
+		if preflight {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+// set Access-Control-* headers by CORS policy of lambda if origin of request is allowed. Preflight response also gets
+// allowed methods, headers and max age
+func setCORSHeaders(header http.Header, request *http.Request, manifest types.Manifest, preflight bool) {
+	policy := manifest.CORS
+	header.Add("Vary", "Origin")
+	origin := request.Header.Get("Origin")
+	allowed, any := policy.Allow(origin)
+	if !allowed {
+		return
+	}
+	if any {
+		header.Set("Access-Control-Allow-Origin", types.AnyOrigin)
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if policy.Credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		return
+	}
+	methods := policy.Methods
+	if len(methods) == 0 {
+		methods = manifest.AllowedMethods()
+	}
+	if len(methods) == 0 {
+		methods = []string{request.Header.Get("Access-Control-Request-Method")}
+	}
+	header.Set("Access-Control-Allow-Methods", strings.ToUpper(strings.Join(methods, ", ")))
+	if len(policy.Headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+	}
+	if age := time.Duration(policy.MaxAge); age > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(age/time.Second)))
+	}
+}
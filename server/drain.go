@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// interval of checks of in-flight invocations while server is draining
+const drainCheckInterval = 100 * time.Millisecond
+
+// Optional extension of queues: stop processing of new queued requests, in-flight executions are not interrupted
+type drainableQueues interface {
+	Drain()
+}
+
+// Drain server before shutdown: new requests (except metrics and probes) are rejected by 503, new asynchronous and queued
+// (if supported by queues) requests are not started, streams are closed. Waits till in-flight invocations are finished or context is
+// done. Returns number of invocations in progress when draining started and number of invocations still running
+func (srv *Server) Drain(ctx context.Context) (inFlight, running int) {
+	atomic.StoreInt32(&srv.draining, 1)
+	if srv.streams != nil {
+		// streams are endless: closed by the last event of shutdown instead of waiting till deadline
+		srv.streams.shutdown()
+	}
+	if srv.Async != nil {
+		srv.Async.drain()
+	}
+	if queues, ok := srv.Queues.(drainableQueues); ok {
+		queues.Drain()
+	}
+	inFlight = srv.running()
+	t := time.NewTicker(drainCheckInterval)
+	defer t.Stop()
+	for running = inFlight; running > 0; running = srv.running() {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return inFlight, running
+		}
+	}
+	return inFlight, 0
+}
+
+// number of invocations in progress
+func (srv *Server) running() int {
+	var n int
+	for _, count := range srv.Platform.Running() {
+		n += count
+	}
+	return n
+}
+
+// metrics and probes are served till the end: readiness reports draining by itself
+func drainExempt(path string) bool {
+	return path == "/metrics" || path == livenessPath || path == readinessPath
+}
+
+// reject new requests by 503 while server is draining, clients are asked to reconnect
+func (srv *Server) drainable(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.LoadInt32(&srv.draining) == 1 && !drainExempt(request.URL.Path) {
+			writer.Header().Set("Connection", "close")
+			writer.Header().Set("Retry-After", "1")
+			http.Error(writer, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// trigger deploy of lambda from git by ping of repository (push webhook of git hosting) signed as declared by
+// GitSource.Ping. Body is only verified: deploy fetches the tracked branch itself
+func (srv *Server) gitPing(writer http.ResponseWriter, request *http.Request) {
+	uid := strings.Trim(request.URL.Path, "/")
+	def, err := srv.Platform.FindByUID(uid)
+	if err != nil || def.Manifest.Git == nil || def.Manifest.Git.Ping == nil {
+		http.Error(writer, "unknown lambda or ping is not enabled", http.StatusNotFound)
+		return
+	}
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, types.DefaultSchemaPayload+1))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > types.DefaultSchemaPayload {
+		http.Error(writer, "ping exceeds maximum payload", http.StatusRequestEntityTooLarge)
+		return
+	}
+	ping := def.Manifest.Git.Ping
+	secret := srv.Platform.Expand(def.Lambda, ping.Secret)
+	if secret == "" {
+		// misconfiguration should not open deploys
+		http.Error(writer, errNoSignatureSecret.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := ping.Verify(srv.fromHTTP(request).Headers, body, secret, time.Now()); err != nil {
+		http.Error(writer, err.Error(), signatureStatus(err))
+		return
+	}
+	if srv.Mirror != nil && srv.Mirror.Primary() != "" {
+		// content of mirror is replicated from primary
+		http.Error(writer, "read-only mirror of "+srv.Mirror.Primary()+": ping primary server", http.StatusConflict)
+		return
+	}
+	srv.GitDeploys.Trigger(uid)
+	writer.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// Paths of probes: liveness and readiness are public and cheap (no processes are started), report of lambdas
+// requires token of API
+const (
+	livenessPath      = "/healthz"
+	readinessPath     = "/readyz"
+	lambdasHealthPath = "/health/lambdas"
+	warmUpHealthPath  = "/health/warmup"
+)
+
+// Result of probe
+type healthStatus struct {
+	Status   string   `json:"status"` // ok or fail
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Availability of lambda (see application.Lambda.Health)
+type lambdaHealth struct {
+	UID       string   `json:"uid"`
+	Name      string   `json:"name,omitempty"`
+	Available bool     `json:"available"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
+// probes are not invocations: they are not recorded and not rejected while server is draining (readiness reports it)
+func (srv *Server) installHealth(mux *http.ServeMux) {
+	mux.HandleFunc(livenessPath, srv.liveness)
+	mux.HandleFunc(readinessPath, srv.readiness)
+	mux.Handle(lambdasHealthPath, openedHandler(srv.withToken(http.HandlerFunc(srv.lambdasHealth))))
+	mux.Handle(warmUpHealthPath, openedHandler(srv.withToken(http.HandlerFunc(srv.warmUpHealth))))
+}
+
+// process is alive and data directory is writable
+func (srv *Server) liveness(writer http.ResponseWriter, request *http.Request) {
+	writeHealth(writer, srv.Alive())
+}
+
+// Alive checks the same as liveness probe (ex: for watchdog of service manager). Empty if alive
+func (srv *Server) Alive() []string {
+	var problems []string
+	if srv.DataDir != "" {
+		if err := checkWritable(srv.DataDir); err != nil {
+			problems = append(problems, fmt.Sprintf("data dir is not writable: %v", err))
+		}
+	}
+	return problems
+}
+
+// server accepts requests and queues are not over high-water mark
+func (srv *Server) readiness(writer http.ResponseWriter, request *http.Request) {
+	var problems []string
+	if atomic.LoadInt32(&srv.draining) == 1 {
+		problems = append(problems, "server is shutting down")
+	}
+	if srv.Queues != nil && srv.QueueHighWater > 0 {
+		for _, q := range srv.Queues.List() {
+			status, err := srv.Queues.Status(q.Name)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("queue %s: %v", q.Name, err))
+			} else if status.Depth > srv.QueueHighWater {
+				problems = append(problems, fmt.Sprintf("queue %s: depth %d is over %d", q.Name, status.Depth, srv.QueueHighWater))
+			}
+		}
+	}
+	writeHealth(writer, problems)
+}
+
+// availability of every lambda, checks are run in parallel. Service unavailable if any of lambdas is unavailable
+func (srv *Server) lambdasHealth(writer http.ResponseWriter, request *http.Request) {
+	list := srv.Platform.List()
+	report := make([]lambdaHealth, len(list))
+	var wg sync.WaitGroup
+	for i, def := range list {
+		report[i] = lambdaHealth{UID: def.UID, Name: def.Manifest.Name}
+		wg.Add(1)
+		go func(item *lambdaHealth, lambda application.Lambda) {
+			defer wg.Done()
+			item.Problems = srv.Platform.Health(request.Context(), lambda)
+			item.Available = len(item.Problems) == 0
+		}(&report[i], def.Lambda)
+	}
+	wg.Wait()
+	code := http.StatusOK
+	for _, item := range report {
+		if !item.Available {
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(report)
+}
+
+// report of warm-up on start (running or finished). Service unavailable if any of warmed up lambdas is unavailable
+func (srv *Server) warmUpHealth(writer http.ResponseWriter, request *http.Request) {
+	if srv.WarmUp == nil {
+		http.Error(writer, "warm-up is disabled", http.StatusNotFound)
+		return
+	}
+	report := srv.WarmUp.Report()
+	code := http.StatusOK
+	for _, item := range report.Lambdas {
+		if !item.Available {
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(report)
+}
+
+// request should have valid token of API in Authorization header (with or without Bearer prefix)
+func (srv *Server) withToken(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		value := strings.TrimSpace(strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer "))
+		if value == "" || srv.TokenHandler == nil {
+			http.Error(writer, "token required", http.StatusUnauthorized)
+			return
+		}
+		if err := srv.TokenHandler.ValidateToken(request.Context(), &api.Token{Data: value}); err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+func writeHealth(writer http.ResponseWriter, problems []string) {
+	status := healthStatus{Status: "ok", Problems: problems}
+	code := http.StatusOK
+	if len(problems) > 0 {
+		status.Status = "fail"
+		code = http.StatusServiceUnavailable
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(status)
+}
+
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".healthz-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	err = f.Close()
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	return err
+}
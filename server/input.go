@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// maximum problems of payload in error of record (all problems are sent in response)
+const recordedProblems = 3
+
+// reject request with 422 if JSON body doesn't match input schema of lambda (without invocation), problems are sent
+// as JSON array. Validated body is buffered: not more than maximum payload (or DefaultSchemaPayload if not set),
+// bigger body is rejected with 413
+func (srv *Server) validateInput(req *types.Request, writer http.ResponseWriter, lambda *application.Definition, manifest types.Manifest, record *stats.Record) (*types.Request, error) {
+	schema := lambda.Lambda.InputSchema()
+	if !schema.Applies(req) {
+		return req, nil
+	}
+	limit := manifest.MaximumPayload
+	if limit <= 0 {
+		limit = types.DefaultSchemaPayload
+	}
+	// one byte over the limit is enough to detect overflow
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, limit+1))
+	_ = req.Body.Close()
+	if err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		err = fmt.Errorf("%w: validated request exceeds %d bytes", application.ErrPayloadTooLarge, limit)
+		record.End = time.Now()
+		record.Err = err.Error()
+		record.Rejected = true
+		http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil, err
+	}
+	problems := schema.Validate(body)
+	if len(problems) == 0 {
+		return req.WithBody(ioutil.NopCloser(bytes.NewReader(body))), nil
+	}
+	var messages []string
+	for i, problem := range problems {
+		if i == recordedProblems {
+			messages = append(messages, fmt.Sprintf("and %d more", len(problems)-i))
+			break
+		}
+		messages = append(messages, strings.TrimSpace(problem.Path+" "+problem.Message))
+	}
+	err = fmt.Errorf("%w: %s", types.ErrInvalidPayload, strings.Join(messages, ", "))
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(writer).Encode(problems)
+	return nil, err
+}
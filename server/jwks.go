@@ -0,0 +1,206 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minimal interval between fetches of the same key set: unknown key IDs in tokens should not flood provider
+const jwksRetryInterval = 10 * time.Second
+
+// maximum size of fetched key set
+const jwksMaxSize = 1 << 20
+
+var errJWKSUnavailable = errors.New("JWKS is unavailable")
+
+// cached JSON Web Key Sets by URL (see JWTPolicy.JWKS). Set is fetched again after refresh interval or for unknown
+// key ID (keys rotated by provider), but not more often than jwksRetryInterval. Failed fetch keeps previous keys
+type keySets struct {
+	client *http.Client
+	lock   sync.Mutex
+	sets   map[string]*keySet
+}
+
+type keySet struct {
+	lock    sync.Mutex             // serializes fetches
+	keys    map[string]interface{} // public keys by key ID, nil - never fetched
+	fetched time.Time              // time of last successful fetch
+	attempt time.Time              // time of last fetch
+}
+
+func newKeySets() *keySets {
+	return &keySets{client: &http.Client{Timeout: 10 * time.Second}, sets: make(map[string]*keySet)}
+}
+
+// public key by ID (empty - single key of set)
+func (ks *keySets) key(url string, kid string, refresh time.Duration) (interface{}, error) {
+	ks.lock.Lock()
+	set, ok := ks.sets[url]
+	if !ok {
+		set = &keySet{}
+		ks.sets[url] = set
+	}
+	ks.lock.Unlock()
+
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	now := time.Now()
+	key, found := set.find(kid)
+	if (!found || now.Sub(set.fetched) >= refresh) && now.Sub(set.attempt) >= jwksRetryInterval {
+		set.attempt = now
+		keys, err := ks.fetch(url)
+		if err != nil {
+			log.Println("[WARN]", "fetch JWKS", url, ":", err)
+		} else {
+			set.keys, set.fetched = keys, now
+			key, found = set.find(kid)
+		}
+	}
+	if set.keys == nil {
+		return nil, fmt.Errorf("%w: %s", errJWKSUnavailable, url)
+	}
+	if !found {
+		return nil, fmt.Errorf("key %q is not found in JWKS", kid)
+	}
+	return key, nil
+}
+
+func (set *keySet) find(kid string) (interface{}, bool) {
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key, true
+		}
+	}
+	key, ok := set.keys[kid]
+	return key, ok
+}
+
+func (ks *keySets) fetch(url string) (map[string]interface{}, error) {
+	res, err := ks.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, jwksMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+// JSON Web Key (RFC 7517) of public key for signatures
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// public keys of set by ID. Keys for encryption and of unsupported types are skipped
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+	var keys = make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Println("[WARN]", "skip key", jwk.Kid, "of JWKS:", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeNumber(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeNumber(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := decodeNumber(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeNumber(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid size of Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+	}
+}
+
+func decodeNumber(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty number")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
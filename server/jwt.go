@@ -0,0 +1,162 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var errNoJWTKey = errors.New("key of JWT is not defined")
+
+// reject request with 401 and challenge if bearer token is missing or invalid (see types.Manifest.JWT). Subject and
+// selected claims of verified token are kept in request and passed to lambda
+func (srv *Server) allowByJWT(req *types.Request, writer http.ResponseWriter, lambda *application.Definition, manifest types.Manifest, record *stats.Record) error {
+	if err := srv.checkJWT(req, lambda, manifest); err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		record.Rejected = true
+		bearerChallenge(writer, err)
+		return err
+	}
+	if manifest.JWT != nil {
+		record.Request.User = req.User
+		record.Request.Claims = req.Claims
+	}
+	return nil
+}
+
+func (srv *Server) checkJWT(req *types.Request, lambda *application.Definition, manifest types.Manifest) error {
+	policy := manifest.JWT
+	if policy == nil || !policy.Protects(req.Alias) {
+		return nil
+	}
+	raw, ok := bearerToken(req.Headers["Authorization"])
+	if !ok {
+		return fmt.Errorf("%w: bearer token is not set", types.ErrTokenInvalid)
+	}
+	claims, err := policy.Verify(raw, srv.jwtKey(lambda, policy), time.Now())
+	if err != nil {
+		return err
+	}
+	req.User = types.ClaimString(claims, "sub")
+	req.Claims = make(map[string]string)
+	for _, claim := range policy.Selected() {
+		if value := types.ClaimString(claims, claim); value != "" {
+			req.Claims[claim] = value
+		}
+	}
+	if len(policy.Headers) > 0 && req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	for claim, header := range policy.Headers {
+		// headers of client with the same name are never passed
+		header = http.CanonicalHeaderKey(header)
+		if value, ok := req.Claims[claim]; ok {
+			req.Headers[header] = value
+		} else {
+			delete(req.Headers, header)
+		}
+	}
+	return nil
+}
+
+// key of token by source of policy. Key type restricts algorithms of token, so public key could not be used as
+// HMAC secret
+func (srv *Server) jwtKey(lambda *application.Definition, policy *types.JWTPolicy) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if policy.Secret != "" {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("algorithm %s is not accepted", token.Method.Alg())
+			}
+			secret := srv.Platform.Expand(lambda.Lambda, policy.Secret)
+			if secret == "" {
+				// misconfiguration should not open lambda
+				return nil, errNoJWTKey
+			}
+			return []byte(secret), nil
+		}
+		var key interface{}
+		var err error
+		if policy.PublicKey != "" {
+			key, err = parsePublicKey(srv.Platform.Expand(lambda.Lambda, policy.PublicKey))
+		} else {
+			kid, _ := token.Header["kid"].(string)
+			key, err = srv.keySets.key(policy.JWKS, kid, policy.RefreshInterval())
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !acceptsMethod(token.Method, key) {
+			return nil, fmt.Errorf("algorithm %s is not accepted", token.Method.Alg())
+		}
+		return key, nil
+	}
+}
+
+func acceptsMethod(method jwt.SigningMethod, key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+// PEM encoded PKIX (or PKCS1 RSA) public key
+func parsePublicKey(text string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, fmt.Errorf("%w: public key is not PEM encoded", errNoJWTKey)
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parse public key: %v", errNoJWTKey, err)
+	}
+	return key, nil
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+// invalid token is problem of client (RFC 6750), missing key or unavailable JWKS - of server
+func bearerChallenge(writer http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoJWTKey):
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	case errors.Is(err, errJWKSUnavailable):
+		http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+	default:
+		writer.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(writer, err.Error(), http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// time limit of notification action about soft limit
+const limitNotifyTimeLimit = time.Minute
+
+// rate limiter of notifications about soft limits: one notification per lambda and limit in interval
+type limitNotices struct {
+	lock sync.Mutex
+	last map[string]time.Time // lambda UID and name of limit to time of the last notification
+}
+
+func newLimitNotices() *limitNotices {
+	return &limitNotices{last: make(map[string]time.Time)}
+}
+
+// notification is allowed (and marked as sent)
+func (ln *limitNotices) allow(uid, limit string, interval time.Duration, now time.Time) bool {
+	key := uid + "/" + limit
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+	if last, ok := ln.last[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	ln.last[key] = now
+	return true
+}
+
+// annotate record by warnings of soft limits (see Manifest.SoftLimits) and invoke notification action in background
+func (srv *Server) checkSoftLimits(ctx context.Context, lambda *application.Definition, manifest types.Manifest, record *stats.Record, payload, response int64) {
+	warnings := manifest.LimitWarnings(payload, response)
+	if len(warnings) == 0 {
+		return
+	}
+	var messages = make([]string, 0, len(warnings)+1)
+	if record.Warning != "" {
+		messages = append(messages, record.Warning)
+	}
+	for _, warning := range warnings {
+		record.Limits = append(record.Limits, warning.Limit)
+		messages = append(messages, warning.String())
+	}
+	record.Warning = strings.Join(messages, "; ")
+	action := manifest.SoftLimits.Notify
+	if action == "" {
+		return
+	}
+	now := time.Now()
+	requestID := record.Request.ID // record is not used after request
+	for _, warning := range warnings {
+		if !srv.limitNotices.allow(lambda.UID, warning.Limit, manifest.SoftLimits.NotifyInterval(), now) {
+			continue
+		}
+		var env = make(map[string]string)
+		for k, v := range srv.Platform.Config().Environment {
+			env[k] = v
+		}
+		env["LIMIT_NAME"] = warning.Limit
+		env["LIMIT_SIZE"] = strconv.FormatInt(warning.Size, 10)
+		env["LIMIT_THRESHOLD"] = strconv.FormatInt(warning.Threshold, 10)
+		env["LIMIT_MAXIMUM"] = strconv.FormatInt(warning.Maximum, 10)
+		env["LIMIT_LAMBDA"] = lambda.UID
+		env[types.RequestIDEnv] = requestID
+		fn := lambda.Lambda
+		// notification outlives request (context of request is cancelled when client is gone) and its usage is not
+		// usage of request
+		notifyCtx := application.WithUsage(context.WithoutCancel(ctx), nil)
+		go func(warning types.LimitWarning) {
+			err := fn.Do(notifyCtx, action, limitNotifyTimeLimit, env, ioutil.Discard)
+			if err != nil {
+				log.Println("[ERROR]", "notify soft limit", warning.Limit, "of lambda", lambda.UID, "request", requestID, "by action", action+":", err)
+			}
+		}(warning)
+	}
+}
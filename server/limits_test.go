@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/prometheus"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestHandler_softLimits(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.Metrics = prometheus.New()
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:             []string{"cat", "-"},
+		MaximumPayload:  10,
+		MaximumResponse: 10,
+		SoftLimits:      &types.SoftLimits{Payload: 50, Response: 50, Notify: "notify"},
+		Sampling:        &types.Sampling{Rate: 100},
+	}})
+	require.NoError(t, err)
+	notices := filepath.Join(srv.Dir, uid, "notices.txt")
+	require.NoError(t, os.WriteFile(filepath.Join(srv.Dir, uid, "Makefile"), []byte("notify:\n\techo $$LIMIT_NAME $$LIMIT_SIZE $$LIMIT_THRESHOLD $$LIMIT_MAXIMUM >> notices.txt\n"), 0644))
+
+	for _, payload := range []string{"abc", "hello!", "hello!!", "xyz"} {
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString(payload))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "requests in warning band are served")
+		assert.Equal(t, payload, rr.Body.String())
+	}
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	require.NoError(t, err)
+	require.Len(t, records, 3, "warnings are kept regardless of sampling")
+	assert.Equal(t, []string{types.LimitPayload, types.LimitResponse}, records[0].Limits)
+	assert.Contains(t, records[0].Warning, "payload 7 bytes is above warning threshold 5 bytes (maximum 10)")
+	assert.Contains(t, records[0].Warning, "response 7 bytes is above warning threshold 5 bytes (maximum 10)")
+	assert.Empty(t, records[2].Limits)
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/metrics", http.NoBody)
+	require.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Contains(t, rr.Body.String(), `trusted_cgi_limit_warnings_total{uid="`+uid+`",limit="payload"} 2`)
+	assert.Contains(t, rr.Body.String(), `trusted_cgi_limit_warnings_total{uid="`+uid+`",limit="response"} 2`)
+
+	var content []byte
+	assert.Eventually(t, func() bool {
+		content, _ = os.ReadFile(notices)
+		return bytes.Count(content, []byte("\n")) >= 2
+	}, 5*time.Second, 50*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	content, _ = os.ReadFile(notices)
+	assert.ElementsMatch(t, []string{"payload 6 5 10", "response 6 5 10"}, strings.Split(strings.TrimSpace(string(content)), "\n"), "notifications are rate limited")
+}
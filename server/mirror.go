@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/reddec/jsonrpc2"
+)
+
+// JSON-RPC methods allowed on read-only mirror: reading methods, login and control of mirror. Other methods
+// (including new methods) are rejected
+var readOnlyMethods = map[string]bool{
+	"UserAPI.Login":                true,
+	"UserAPI.Tokens":               true,
+	"UserAPI.Lockouts":             true,
+	"UserAPI.Unlock":               true, // failed logins are tracked by each server
+	"LambdaAPI.Download":           true,
+	"LambdaAPI.ContentHash":        true,
+	"LambdaAPI.SignedContentHash":  true,
+	"LambdaAPI.Pull":               true,
+	"LambdaAPI.Files":              true,
+	"LambdaAPI.FrozenArtifacts":    true,
+	"LambdaAPI.DownloadFrozen":     true,
+	"LambdaAPI.Versions":           true,
+	"LambdaAPI.Info":               true,
+	"LambdaAPI.Environment":        true,
+	"LambdaAPI.MergedEnvironment":  true,
+	"LambdaAPI.Stats":              true,
+	"LambdaAPI.StatsRange":         true,
+	"LambdaAPI.StatsAggregate":     true,
+	"LambdaAPI.Actions":            true,
+	"LambdaAPI.Doctor":             true,
+	"LambdaAPI.Requests":           true,
+	"LambdaAPI.CapturedRequest":    true,
+	"LambdaAPI.LogFiles":           true,
+	"LambdaAPI.DownloadLog":        true,
+	"LambdaAPI.TailLog":            true,
+	"LambdaAPI.GrantExport":        true,
+	"LambdaAPI.Export":             true,
+	"ProjectAPI.Config":            true,
+	"ProjectAPI.AllTemplates":      true,
+	"ProjectAPI.List":              true,
+	"ProjectAPI.Templates":         true,
+	"ProjectAPI.Stats":             true,
+	"ProjectAPI.StatsRange":        true,
+	"ProjectAPI.StatsAggregate":    true,
+	"ProjectAPI.StatsUsage":        true,
+	"ProjectAPI.SetStatsRetention": true, // stats are not replicated: retention of mirror is managed locally
+	"ProjectAPI.Capabilities":      true,
+	"ProjectAPI.OpenAPI":           true,
+	"ProjectAPI.Capacity":          true,
+	"ProjectAPI.Mirror":            true,
+	"ProjectAPI.Promote":           true,
+	"ProjectAPI.Changes":           true,
+	"ProjectAPI.Audit":             true,
+	"ProjectAPI.Security":          true,
+	"ProjectAPI.Routes":            true,
+	"ProjectAPI.TransferProgress":  true,
+	"ProjectAPI.Secrets":           true, // secrets are not replicated: store of mirror is managed locally
+	"ProjectAPI.WebhookDeliveries": true,
+	"ProjectAPI.Backup":            true,
+	"ProjectAPI.SetSecret":         true,
+	"ProjectAPI.RemoveSecret":      true,
+	"QueuesAPI.Linked":             true,
+	"QueuesAPI.List":               true,
+	"QueuesAPI.Inspect":            true,
+	"QueuesAPI.Async":              true,
+	"QueuesAPI.DeadLetters":        true,
+	"QueuesAPI.DeadLetter":         true,
+	"PoliciesAPI.List":             true,
+}
+
+type rpcCall struct {
+	Method string          `json:"method"`
+	ID     json.RawMessage `json:"id"`
+}
+
+// reject API calls which are not allowed on read-only mirror by 409 (Conflict) with URL of primary server in
+// X-Primary header and JSON-RPC error. Batch is rejected entirely if any call is not allowed
+func (srv *Server) readOnly(handler http.Handler) http.Handler {
+	if srv.Mirror == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		primary := srv.Mirror.Primary()
+		if primary == "" || request.Method == http.MethodOptions {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		data, err := ioutil.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		call, ok := mutatingCall(data)
+		if !ok {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		id := call.ID
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+		writer.Header().Set("X-Primary", primary)
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"error":   readOnlyError(call.Method, primary),
+		})
+	})
+}
+
+func readOnlyError(method, primary string) *jsonrpc2.Error {
+	return &jsonrpc2.Error{
+		Code:    http.StatusConflict,
+		Message: "server is read-only mirror, call " + method + " on primary " + primary,
+		Data:    map[string]string{"primary": primary},
+	}
+}
+
+// first call (of single request or batch) which is not allowed on read-only mirror. Malformed requests are passed
+// to router as is
+func mutatingCall(data []byte) (rpcCall, bool) {
+	var batch []rpcCall
+	if err := json.Unmarshal(data, &batch); err != nil {
+		var single rpcCall
+		if err := json.Unmarshal(data, &single); err != nil {
+			return single, false
+		}
+		batch = []rpcCall{single}
+	}
+	for _, call := range batch {
+		if call.Method != "" && !readOnlyMethods[call.Method] {
+			return call, true
+		}
+	}
+	return rpcCall{}, false
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// request by HTTP with address of client: forwarded by trusted proxies (if defined) or by any peer if server is behind
+// proxy, otherwise address of connection. The address is seen by policies, rate limits, lockouts, stats and lambdas
+func (srv *Server) fromHTTP(request *http.Request) *types.Request {
+	req := types.FromHTTP(request, srv.BehindProxy && len(srv.TrustedProxies) == 0)
+	if len(srv.TrustedProxies) > 0 {
+		req.RemoteAddress = types.ForwardedAddress(request, srv.TrustedProxies, srv.ForwardedBy...)
+	}
+	return req
+}
+
+// forwarded headers of request are respected: request from trusted proxy (if defined) or server is behind proxy
+func (srv *Server) forwarded(request *http.Request) bool {
+	if len(srv.TrustedProxies) > 0 {
+		return srv.TrustedProxies.ContainsAddress(request.RemoteAddr)
+	}
+	return srv.BehindProxy
+}
+
+// reject request with 403 if client is not in allowed networks of lambda (checked before policy)
+func (srv *Server) allowByNetwork(req *types.Request, writer http.ResponseWriter, manifest types.Manifest, record *stats.Record) error {
+	err := checkNetwork(req, manifest)
+	if err == nil {
+		return nil
+	}
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	record.Denied = true
+	http.Error(writer, err.Error(), http.StatusForbidden)
+	return err
+}
+
+func checkNetwork(req *types.Request, manifest types.Manifest) error {
+	if len(manifest.AllowedNetworks) == 0 {
+		return nil
+	}
+	networks, err := types.ParseNetworks(manifest.AllowedNetworks)
+	if err != nil {
+		// manifest is validated on apply: broken manifest (ex: edited on disk) closes access
+		return application.ErrNetworkRestricted
+	}
+	if !networks.ContainsAddress(req.RemoteAddress) {
+		return application.ErrNetworkRestricted
+	}
+	return nil
+}
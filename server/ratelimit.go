@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// interval of removing idle buckets of rate limits
+const rateSweepInterval = time.Minute
+
+var errRateLimit = errors.New("rate limit of lambda exceeded")
+
+// token buckets of rate limits (see Manifest.RateLimit) by lambda and client. Bucket idle for time of complete refill
+// is the same as new one, so such buckets are removed on sweep.
+type rateLimiter struct {
+	lock    sync.Mutex
+	buckets map[rateKey]*bucket
+	swept   time.Time
+}
+
+type rateKey struct {
+	uid    string
+	alias  string // alias with own rate limit (see types.AliasPolicy), empty - limit of lambda
+	client string // empty - bucket of lambda
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time // time of last refill
+	full   time.Time // time when bucket is refilled completely
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[rateKey]*bucket), swept: time.Now()}
+}
+
+// take token from bucket. If bucket is empty, returns time till the next token. Limit is passed on each call, so
+// changed manifest is applied to the next requests.
+func (rl *rateLimiter) take(key rateKey, limit types.RateLimit, now time.Time) (bool, time.Duration) {
+	capacity := limit.Capacity()
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	if now.Sub(rl.swept) >= rateSweepInterval {
+		rl.sweepLocked(now)
+	}
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		rl.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed.Seconds()*limit.RPS)
+		b.last = now
+	}
+	if b.tokens > capacity {
+		b.tokens = capacity // capacity reduced by changed manifest
+	}
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.RPS * float64(time.Second))
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) / limit.RPS * float64(time.Second)))
+	return true, 0
+}
+
+func (rl *rateLimiter) sweepLocked(now time.Time) {
+	rl.swept = now
+	for key, b := range rl.buckets {
+		if !now.Before(b.full) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// reject request with 429 if rate limit of lambda (or of client) is exceeded. Client is identified by IP of remote
+// address (X-Real-Ip or X-Forwarded-For if server is behind proxy)
+func (srv *Server) allowByRate(req *types.Request, writer http.ResponseWriter, lambda *application.Definition, manifest types.Manifest, record *stats.Record) error {
+	if manifest.RateLimit == nil {
+		return nil
+	}
+	key := rateKey{uid: lambda.UID}
+	if policy := manifest.AliasPolicy(req.Alias); policy != nil && policy.RateLimit != nil {
+		// limit of alias has own bucket
+		key.alias = req.Alias
+	}
+	if manifest.RateLimit.PerClient {
+		key.client = clientIP(req.RemoteAddress)
+	}
+	ok, wait := srv.rates.take(key, *manifest.RateLimit, time.Now())
+	if ok {
+		return nil
+	}
+	record.End = time.Now()
+	record.Err = errRateLimit.Error()
+	record.Rejected = true
+	record.Throttled = true
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+	http.Error(writer, errRateLimit.Error(), http.StatusTooManyRequests)
+	return errRateLimit
+}
+
+// IP of remote address without port
+func clientIP(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// headers defined by body framing: values from manifest output headers are ignored, otherwise they may
+// not match the real body (length of lambda output is unknown in advance)
+var framingHeaders = []string{"Content-Length", "Transfer-Encoding"}
+
+// lambdaResponse is the single place where response of lambda is assembled: manifest output headers, status
+// and body framing. Body rules for HEAD and statuses without body (1xx, 204, 304) are applied the same way for
+// streamed and buffered responses:
+//
+//   - streamed - length is unknown, framing is chosen by net/http (Content-Length for short output, chunked otherwise);
+//     HEAD output goes to net/http as well, so headers are the same as for GET
+//   - buffered - Content-Length is the exact length of body, Content-Type is detected (if not set) by body,
+//     for HEAD body is not written
+type lambdaResponse struct {
+	writer http.ResponseWriter
+	head   bool
+	sent   bool // status is sent
+}
+
+func newLambdaResponse(writer http.ResponseWriter, req *types.Request, manifest types.Manifest) *lambdaResponse {
+	header := writer.Header()
+	for k, v := range manifest.OutputHeaders {
+		header.Set(k, v)
+	}
+	for _, name := range framingHeaders {
+		header.Del(name)
+	}
+	return &lambdaResponse{writer: writer, head: req.Method == http.MethodHead}
+}
+
+// set headers of lambda output over manifest output headers (framing headers are ignored)
+func (lr *lambdaResponse) merge(headers http.Header) {
+	header := lr.writer.Header()
+	for k, v := range headers {
+		header[k] = v
+	}
+	for _, name := range framingHeaders {
+		header.Del(name)
+	}
+}
+
+// send status and return writer for body of unknown length
+func (lr *lambdaResponse) stream(status int) io.Writer {
+	lr.sent = true
+	lr.writer.WriteHeader(status)
+	if !bodyAllowed(status) {
+		return ioutil.Discard
+	}
+	return lr.writer
+}
+
+// flush streamed body to client
+func (lr *lambdaResponse) flush() {
+	if lr.sent {
+		_ = http.NewResponseController(lr.writer).Flush()
+	}
+}
+
+// headers of Server-Sent Events stream (see Manifest.Streaming): content type is forced, caching, compression and
+// buffering by proxies are disabled
+func (lr *lambdaResponse) eventStream() {
+	header := lr.writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Encoding")
+}
+
+// send status and buffered body
+func (lr *lambdaResponse) send(status int, body []byte) {
+	lr.sent = true
+	if !bodyAllowed(status) {
+		lr.writer.WriteHeader(status)
+		return
+	}
+	header := lr.writer.Header()
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if _, ok := header["Content-Type"]; !ok {
+		// the same as net/http sniffing, but also for HEAD where body is not written
+		header.Set("Content-Type", http.DetectContentType(body))
+	}
+	lr.writer.WriteHeader(status)
+	if !lr.head {
+		_, _ = lr.writer.Write(body)
+	}
+}
+
+// writer which opens response on the first write (or on close without output), so invocation failed before output
+// could still be answered by error status
+type lazyWriter struct {
+	open   func() io.WriteCloser
+	output io.WriteCloser
+}
+
+func (lw *lazyWriter) Write(data []byte) (int, error) {
+	if lw.output == nil {
+		lw.output = lw.open()
+	}
+	return lw.output.Write(data)
+}
+
+func (lw *lazyWriter) Close() error {
+	if lw.output == nil {
+		lw.output = lw.open()
+	}
+	return lw.output.Close()
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// writer which removes headers just before status is sent, so headers are removed regardless of which code path
+// (manifest, output of lambda, static files, errors) set them
+type headerRemover struct {
+	http.ResponseWriter
+	names []string
+	sent  bool
+}
+
+func removeHeaders(writer http.ResponseWriter, names []string) http.ResponseWriter {
+	if len(names) == 0 {
+		return writer
+	}
+	return &headerRemover{ResponseWriter: writer, names: names}
+}
+
+func (hr *headerRemover) WriteHeader(status int) {
+	hr.remove()
+	hr.ResponseWriter.WriteHeader(status)
+}
+
+func (hr *headerRemover) Write(data []byte) (int, error) {
+	hr.remove()
+	return hr.ResponseWriter.Write(data)
+}
+
+// Unwrap for http.ResponseController
+func (hr *headerRemover) Unwrap() http.ResponseWriter {
+	return hr.ResponseWriter
+}
+
+func (hr *headerRemover) remove() {
+	if hr.sent {
+		return
+	}
+	hr.sent = true
+	header := hr.ResponseWriter.Header()
+	for _, name := range hr.names {
+		header.Del(name)
+	}
+}
@@ -0,0 +1,323 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Conformance of body framing headers for all combinations of features, methods and body sizes
+// (short output fits net/http buffer, long output doesn't).
+func TestHandler_responseFraming(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	features := map[string]func(manifest *types.Manifest){
+		"plain": func(manifest *types.Manifest) {},
+		"coalesced": func(manifest *types.Manifest) {
+			manifest.Coalesce = &types.Coalescing{}
+		},
+		"static": func(manifest *types.Manifest) {
+			manifest.Static = "static"
+		},
+		"manifest framing headers": func(manifest *types.Manifest) {
+			manifest.OutputHeaders = map[string]string{"Content-Length": "1", "Transfer-Encoding": "chunked"}
+		},
+		"manifest framing headers and coalesced": func(manifest *types.Manifest) {
+			manifest.OutputHeaders = map[string]string{"Content-Length": "1", "Transfer-Encoding": "chunked"}
+			manifest.Coalesce = &types.Coalescing{}
+		},
+	}
+	sizes := []int{100, 64 * 1024}
+
+	for name, feature := range features {
+		for _, size := range sizes {
+			content := bytes.Repeat([]byte("x"), size)
+			manifest := types.Manifest{
+				Run: []string{"/bin/sh", "-c", "cat data"},
+			}
+			feature(&manifest)
+			uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+			require.NoError(t, err)
+			dir := filepath.Join(srv.Dir, uid)
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "data"), content, 0755))
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "static"), 0755))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "static", "index.html"), content, 0755))
+
+			t.Run(name+"/"+strconv.Itoa(size), func(t *testing.T) {
+				url := httpServer.URL + "/a/" + uid + "/"
+				get := doRequest(t, http.MethodGet, url)
+				assert.Equal(t, http.StatusOK, get.status)
+				assert.Equal(t, size, len(get.body))
+				assertFraming(t, get)
+
+				head := doRequest(t, http.MethodHead, url)
+				assert.Equal(t, http.StatusOK, head.status)
+				assert.Empty(t, head.body, "HEAD response should not have body")
+				assertFraming(t, head)
+				assert.Equal(t, get.contentLength, head.contentLength, "HEAD should advertise the same length as GET")
+				assert.Equal(t, get.header.Get("Content-Type"), head.header.Get("Content-Type"))
+			})
+		}
+	}
+
+	t.Run("queue no content", func(t *testing.T) {
+		uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+		require.NoError(t, err)
+		require.NoError(t, srv.Server.Queues.Add(application.Queue{Name: "framing", Target: uid}))
+		for _, method := range []string{http.MethodPost, http.MethodHead} {
+			res := doRequest(t, method, httpServer.URL+"/q/framing")
+			assert.Equal(t, http.StatusNoContent, res.status)
+			assert.Empty(t, res.body)
+			assert.Empty(t, res.header.Get("Content-Length"), "204 should not have Content-Length")
+			assert.Empty(t, res.transferEncoding, "204 should not have Transfer-Encoding")
+		}
+	})
+}
+
+type framingResult struct {
+	status           int
+	header           http.Header
+	contentLength    int64
+	transferEncoding []string
+	body             []byte
+}
+
+func doRequest(t *testing.T, method string, url string) framingResult {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	// framing of output as is: client adds gzip to GET, but not to HEAD (see TestHandler_compression)
+	req.Header.Set("Accept-Encoding", "identity")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	return framingResult{
+		status:           res.StatusCode,
+		header:           res.Header,
+		contentLength:    res.ContentLength,
+		transferEncoding: res.TransferEncoding,
+		body:             body,
+	}
+}
+
+func assertFraming(t *testing.T, res framingResult) {
+	if len(res.transferEncoding) > 0 {
+		assert.Empty(t, res.header.Get("Content-Length"), "Content-Length and Transfer-Encoding should not be used together")
+	}
+	assert.Empty(t, res.header.Get("Content-Encoding"), "body is not encoded")
+	if res.contentLength >= 0 && len(res.body) > 0 {
+		assert.Equal(t, int64(len(res.body)), res.contentLength, "Content-Length should match body")
+	}
+}
+
+func TestHandler_parseHeaders(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	create := func(output string, feature func(manifest *types.Manifest)) string {
+		manifest := types.Manifest{
+			Run:           []string{"cat", "data"},
+			OutputHeaders: map[string]string{"Content-Type": "text/plain", "X-Static": "yes"},
+			ParseHeaders:  true,
+		}
+		feature(&manifest)
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(srv.Dir, uid, "data"), []byte(output), 0755))
+		return uid
+	}
+	invoke := func(uid string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/a/"+uid, http.NoBody)
+		require.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	lastRecord := func(uid string) stats.Record {
+		records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		return records[0]
+	}
+
+	features := map[string]func(manifest *types.Manifest){
+		"streamed":  func(manifest *types.Manifest) {},
+		"coalesced": func(manifest *types.Manifest) { manifest.Coalesce = &types.Coalescing{} },
+		"rewrite":   func(manifest *types.Manifest) { manifest.RewriteURLs = &types.Rewrite{Prefix: "http://internal"} },
+	}
+	for name, feature := range features {
+		t.Run(name, func(t *testing.T) {
+			uid := create("Status: 404 Not Found\r\nContent-Type: application/json\r\nSet-Cookie: a=1\r\nSet-Cookie: b=2\r\nContent-Length: 1\r\n\r\n{\"url\":\"http://internal/x\"}", feature)
+			rr := invoke(uid)
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"), "lambda headers override manifest")
+			assert.Equal(t, "yes", rr.Header().Get("X-Static"))
+			assert.Equal(t, []string{"a=1", "b=2"}, rr.Header().Values("Set-Cookie"))
+			assert.Empty(t, rr.Header().Get("Status"))
+			assert.NotEqual(t, "1", rr.Header().Get("Content-Length"), "framing headers are ignored")
+			if name == "rewrite" {
+				assert.Equal(t, `{"url":"http://example.com/x"}`, rr.Body.String())
+			} else {
+				assert.Equal(t, `{"url":"http://internal/x"}`, rr.Body.String())
+			}
+			assert.Empty(t, lastRecord(uid).Warning)
+
+			uid = create("Location: /next\n\n", feature)
+			rr = invoke(uid)
+			assert.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, "/next", rr.Header().Get("Location"))
+
+			uid = create("hello world\n\nnot headers", feature)
+			rr = invoke(uid)
+			assert.Equal(t, http.StatusOK, rr.Code, "malformed headers are kept as body")
+			assert.Equal(t, "hello world\n\nnot headers", rr.Body.String())
+			assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
+			assert.Contains(t, lastRecord(uid).Warning, "malformed response headers")
+
+			uid = create("Status: 201\nno blank line", feature)
+			rr = invoke(uid)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "Status: 201\nno blank line", rr.Body.String())
+			assert.Contains(t, lastRecord(uid).Warning, "not terminated")
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		uid := create("Status: 404\n\nbody", func(manifest *types.Manifest) { manifest.ParseHeaders = false })
+		rr := invoke(uid)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "Status: 404\n\nbody", rr.Body.String())
+		assert.Empty(t, lastRecord(uid).Warning)
+	})
+}
+
+func TestHandler_statusMap(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	create := func(script string, feature func(manifest *types.Manifest)) string {
+		manifest := types.Manifest{
+			Run:       []string{"/bin/sh", "-c", script},
+			StatusMap: map[int]int{2: http.StatusBadRequest, 3: http.StatusNotFound},
+		}
+		feature(&manifest)
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+		require.NoError(t, err)
+		return uid
+	}
+	invoke := func(uid string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/a/"+uid, http.NoBody)
+		require.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	features := map[string]func(manifest *types.Manifest){
+		"plain":     func(manifest *types.Manifest) {},
+		"coalesced": func(manifest *types.Manifest) { manifest.Coalesce = &types.Coalescing{} },
+	}
+	for name, feature := range features {
+		t.Run(name, func(t *testing.T) {
+			rr := invoke(create("printf 'bad input'; exit 2", feature))
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, "bad input", rr.Body.String())
+
+			rr = invoke(create("printf 'failed'; exit 1", feature))
+			assert.Equal(t, http.StatusInternalServerError, rr.Code, "unmapped exit code")
+			assert.Equal(t, "failed", rr.Body.String())
+
+			rr = invoke(create("printf 'ok'", feature))
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "ok", rr.Body.String())
+		})
+	}
+
+	t.Run("status header wins", func(t *testing.T) {
+		parse := func(manifest *types.Manifest) { manifest.ParseHeaders = true }
+		rr := invoke(create("printf 'Status: 409\\n\\nconflict'; exit 3", parse))
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, "conflict", rr.Body.String())
+
+		rr = invoke(create("printf 'Content-Type: text/plain\\n\\nmissing'; exit 3", parse))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "missing", rr.Body.String())
+	})
+}
+
+func TestHandler_removeHeaders(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.RemoveHeaders = []string{"Server"}
+	handler := srv.Server.Handler(ctx)
+
+	output := "Server: runtime/1.0\r\nX-Powered-By: PHP\r\nX-Keep: yes\r\n\r\nbody"
+	features := map[string]func(manifest *types.Manifest){
+		"streamed":   func(manifest *types.Manifest) {},
+		"coalesced":  func(manifest *types.Manifest) { manifest.Coalesce = &types.Coalescing{} },
+		"status map": func(manifest *types.Manifest) { manifest.StatusMap = map[int]int{2: http.StatusBadRequest} },
+		"static": func(manifest *types.Manifest) {
+			manifest.StaticDirs = map[string]string{"/": "."}
+		},
+		"rejected": func(manifest *types.Manifest) { manifest.Methods = []string{http.MethodPut} },
+	}
+	for name, feature := range features {
+		t.Run(name, func(t *testing.T) {
+			manifest := types.Manifest{
+				Run:           []string{"cat", "data"},
+				ParseHeaders:  true,
+				OutputHeaders: map[string]string{"X-Powered-By": "manifest", "Server": "manifest", "X-Keep": "yes"},
+				RemoveHeaders: []string{"x-powered-by"},
+			}
+			feature(&manifest)
+			uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+			require.NoError(t, err)
+			require.NoError(t, ioutil.WriteFile(filepath.Join(srv.Dir, uid, "data"), []byte(output), 0644))
+
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				req, err := http.NewRequest(method, "https://example.com/a/"+uid+"/data", http.NoBody)
+				require.NoError(t, err)
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				assert.NotEmpty(t, rr.Body.String())
+				assert.Empty(t, rr.Header().Values("X-Powered-By"), method)
+				assert.Empty(t, rr.Header().Values("Server"), method)
+			}
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/a/unknown", http.NoBody)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Empty(t, rr.Header().Values("Server"))
+}
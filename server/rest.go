@@ -0,0 +1,688 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// prefix of REST admin API
+const restPrefix = "/api/v1/"
+
+// REST route of admin API. Segment "*" matches any segment, last segment "**" matches the rest of path (at least one
+// segment)
+type restRoute struct {
+	method  string
+	pattern string
+	handle  func(rc *restCall, args []string) (int, interface{}, error)
+}
+
+// REST routes are thin mappings onto JSON-RPC methods: validation of tokens and scopes, validation of arguments and
+// audit are done by the same methods (and interceptors) as for JSON-RPC calls
+var restRoutes = []restRoute{
+	{http.MethodPost, "login", restLogin},
+	{http.MethodGet, "lambdas", restListLambdas},
+	{http.MethodPost, "lambdas", restCreateLambda},
+	{http.MethodGet, "lambdas/*", restLambdaInfo},
+	{http.MethodDelete, "lambdas/*", restRemoveLambda},
+	{http.MethodGet, "lambdas/*/manifest", restManifest},
+	{http.MethodPut, "lambdas/*/manifest", restUpdateManifest},
+	{http.MethodGet, "lambdas/*/files", restListFiles},
+	{http.MethodGet, "lambdas/*/files/**", restPullFile},
+	{http.MethodPut, "lambdas/*/files/**", restPushFile},
+	{http.MethodDelete, "lambdas/*/files/**", restRemoveFile},
+	{http.MethodGet, "lambdas/*/aliases", restListAliases},
+	{http.MethodPut, "lambdas/*/aliases/*", restLinkAlias},
+	{http.MethodDelete, "lambdas/*/aliases/*", restUnlinkAlias},
+	{http.MethodGet, "lambdas/*/schedules", restListSchedules},
+	{http.MethodPut, "lambdas/*/schedules", restSetSchedules},
+	{http.MethodGet, "tokens", restListTokens},
+	{http.MethodPost, "tokens", restCreateToken},
+	{http.MethodDelete, "tokens/*", restRevokeToken},
+	{http.MethodPost, "tokens/*/rotate", restRotateToken},
+	{http.MethodGet, "backup", restBackup},
+	{http.MethodPost, "restore", restRestore},
+	{http.MethodGet, "stats/export", restExportStats},
+	{http.MethodGet, "lambdas/*/stats/export", restExportLambdaStats},
+	{http.MethodGet, "lambdas/*/logs", restListLogs},
+	{http.MethodGet, "lambdas/*/logs/tail", restTailLog}, // names of log files are timestamps
+	{http.MethodGet, "lambdas/*/logs/*", restDownloadLog},
+}
+
+// error body of REST API: HTTP status, code and message of JSON-RPC error
+type restError struct {
+	Status  int         `json:"status"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// raw content of file (not JSON)
+type rawContent []byte
+
+// streamed content (not JSON): written after status, chunked by size of output buffer. Failed write aborts response,
+// so incomplete content is not ended as complete
+type streamContent struct {
+	contentType string
+	write       func(out io.Writer) error
+}
+
+// REST call: request with token of Authorization header, invokes methods of JSON-RPC router
+type restCall struct {
+	ctx     context.Context
+	srv     *Server
+	router  *jsonrpc2.Router
+	writer  http.ResponseWriter
+	request *http.Request
+	token   *api.Token
+}
+
+// REST handler (prefix should be stripped) of the same router as JSON-RPC handler
+func (srv *Server) restAPI(ctx context.Context, router *jsonrpc2.Router) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		rc := &restCall{
+			ctx:     api.WithCaller(ctx, srv.callerOf(request)),
+			srv:     srv,
+			router:  router,
+			writer:  writer,
+			request: request,
+		}
+		route, args, allowed := matchRoute(request.Method, request.URL.Path)
+		if route == nil && len(allowed) > 0 {
+			writer.Header().Set("Allow", strings.Join(allowed, ", "))
+			rc.fail(&jsonrpc2.Error{Code: http.StatusMethodNotAllowed, Message: "method " + request.Method + " is not allowed"})
+			return
+		}
+		if route == nil {
+			rc.fail(&jsonrpc2.Error{Code: http.StatusNotFound, Message: "unknown route " + request.URL.Path})
+			return
+		}
+		token, err := restToken(request)
+		if err != nil {
+			rc.fail(err)
+			return
+		}
+		rc.token = token
+		status, result, err := route.handle(rc, args)
+		if err != nil {
+			rc.fail(err)
+			return
+		}
+		rc.reply(status, result)
+	})
+}
+
+// route by method and path, arguments are matched segments. Methods of path are returned if method is not allowed
+func matchRoute(method, path string) (*restRoute, []string, []string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var allowed []string
+	for i := range restRoutes {
+		route := &restRoutes[i]
+		args, ok := matchPattern(strings.Split(route.pattern, "/"), segments)
+		if !ok {
+			continue
+		}
+		if route.method == method {
+			return route, args, nil
+		}
+		allowed = append(allowed, route.method)
+	}
+	return nil, nil, allowed
+}
+
+func matchPattern(pattern, segments []string) ([]string, bool) {
+	var args []string
+	for i, part := range pattern {
+		if part == "**" && i == len(pattern)-1 && i < len(segments) {
+			return append(args, strings.Join(segments[i:], "/")), true
+		}
+		if i >= len(segments) || segments[i] == "" {
+			return nil, false
+		}
+		switch part {
+		case "*":
+			args = append(args, segments[i])
+		case segments[i]:
+		default:
+			return nil, false
+		}
+	}
+	return args, len(pattern) == len(segments)
+}
+
+// token from Authorization header (nil if not set). Schemes other than Bearer are rejected
+func restToken(request *http.Request) (*api.Token, error) {
+	header := request.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	token, ok := bearerToken(header)
+	if !ok {
+		return nil, &jsonrpc2.Error{Code: http.StatusUnauthorized, Message: "unsupported authorization, expected Bearer token"}
+	}
+	return &api.Token{Data: token}, nil
+}
+
+// invoke JSON-RPC method with token (except login) and arguments, result is decoded to out (if not nil). Methods
+// which are not allowed on read-only mirror are rejected in the same way as JSON-RPC calls
+func (rc *restCall) call(method string, out interface{}, args ...interface{}) error {
+	if rc.srv.Mirror != nil {
+		if primary := rc.srv.Mirror.Primary(); primary != "" && !readOnlyMethods[method] {
+			rc.writer.Header().Set("X-Primary", primary)
+			return readOnlyError(method, primary)
+		}
+	}
+	params := args
+	if !tokenlessMethods[method] {
+		params = append([]interface{}{rc.token}, args...)
+	}
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	responses, _ := rc.router.InvokeContext(rc.ctx, bytes.NewReader(body))
+	if len(responses) != 1 {
+		return errors.New("no response of method " + method)
+	}
+	if res := responses[0]; res.Error != nil {
+		return res.Error
+	} else if out == nil {
+		return nil
+	} else if data, err := json.Marshal(res.Result); err != nil {
+		return err
+	} else {
+		return json.Unmarshal(data, out)
+	}
+}
+
+// decode JSON body of request. Empty body is allowed only if body is optional
+func (rc *restCall) decode(value interface{}, optional bool) error {
+	data, err := ioutil.ReadAll(rc.request.Body)
+	if err != nil {
+		return &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "read body: " + err.Error()}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if optional {
+			return nil
+		}
+		return &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "body is required"}
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "invalid body: " + err.Error()}
+	}
+	return nil
+}
+
+// write result of route: nothing, raw content or JSON. Results with sets (definitions, manifests) should be pointers
+// to be encoded as JSON-RPC results
+func (rc *restCall) reply(status int, result interface{}) {
+	switch v := result.(type) {
+	case nil:
+		rc.writer.WriteHeader(status)
+	case rawContent:
+		rc.writer.Header().Set("Content-Type", "application/octet-stream")
+		rc.writer.Header().Set("Content-Length", strconv.Itoa(len(v)))
+		rc.writer.WriteHeader(status)
+		_, _ = rc.writer.Write(v)
+	case streamContent:
+		rc.writer.Header().Set("Content-Type", v.contentType)
+		rc.writer.WriteHeader(status)
+		if err := v.write(rc.writer); err != nil {
+			log.Println("[ERROR]", "rest: stream", rc.request.URL.Path, "-", err)
+			panic(http.ErrAbortHandler)
+		}
+	default:
+		rc.writer.Header().Set("Content-Type", "application/json")
+		rc.writer.WriteHeader(status)
+		_ = json.NewEncoder(rc.writer).Encode(v)
+	}
+}
+
+func (rc *restCall) fail(err error) {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &jsonrpc2.Error{Code: jsonrpc2.InternalError, Message: err.Error()}
+	}
+	status := restStatus(rpcErr)
+	rc.writer.Header().Set("Content-Type", "application/json")
+	rc.writer.WriteHeader(status)
+	_ = json.NewEncoder(rc.writer).Encode(restError{Status: status, Code: rpcErr.Code, Message: rpcErr.Message, Data: rpcErr.Data})
+}
+
+// HTTP status of JSON-RPC error: codes of errors of methods which are HTTP statuses are used as is, other errors of
+// methods are classified by message
+func restStatus(err *jsonrpc2.Error) int {
+	if err.Code >= 400 && err.Code < 600 {
+		return err.Code
+	}
+	switch err.Code {
+	case jsonrpc2.ParseError, jsonrpc2.InvalidRequest, jsonrpc2.InvalidParams:
+		return http.StatusBadRequest
+	case jsonrpc2.AppError:
+	default:
+		return http.StatusInternalServerError
+	}
+	message := strings.ToLower(err.Message)
+	switch {
+	case strings.HasPrefix(message, "token "):
+		return http.StatusUnauthorized
+	case strings.Contains(message, "unknown"), strings.Contains(message, "unkown"),
+		strings.Contains(message, "not found"), strings.Contains(message, "no such file"):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func restLogin(rc *restCall, _ []string) (int, interface{}, error) {
+	var credentials struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	if err := rc.decode(&credentials, false); err != nil {
+		return 0, nil, err
+	}
+	var token api.Token
+	err := rc.call("UserAPI.Login", &token, credentials.Login, credentials.Password)
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.AppError {
+		// invalid credentials (lockout has own status)
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusUnauthorized, Message: rpcErr.Message}
+	} else if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, map[string]string{"token": token.Data}, nil
+}
+
+func restListLambdas(rc *restCall, _ []string) (int, interface{}, error) {
+	var list []application.Definition
+	if err := rc.call("ProjectAPI.List", &list); err != nil {
+		return 0, nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UID < list[j].UID
+	})
+	return http.StatusOK, list, nil
+}
+
+func restCreateLambda(rc *restCall, _ []string) (int, interface{}, error) {
+	var options api.CreateOptions
+	if err := rc.decode(&options, true); err != nil {
+		return 0, nil, err
+	}
+	var def application.Definition
+	if err := rc.call("ProjectAPI.CreateWithOptions", &def, options); err != nil {
+		return 0, nil, err
+	}
+	rc.writer.Header().Set("Location", restPrefix+"lambdas/"+def.UID)
+	return http.StatusCreated, &def, nil
+}
+
+func restLambdaInfo(rc *restCall, args []string) (int, interface{}, error) {
+	def, err := rc.info(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, def, nil
+}
+
+func restRemoveLambda(rc *restCall, args []string) (int, interface{}, error) {
+	return http.StatusNoContent, nil, rc.call("LambdaAPI.Remove", nil, args[0])
+}
+
+func restManifest(rc *restCall, args []string) (int, interface{}, error) {
+	def, err := rc.info(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, &def.Manifest, nil
+}
+
+func restUpdateManifest(rc *restCall, args []string) (int, interface{}, error) {
+	var manifest types.Manifest
+	if err := rc.decode(&manifest, false); err != nil {
+		return 0, nil, err
+	}
+	force, _ := strconv.ParseBool(rc.request.URL.Query().Get("force_aliases"))
+	var def application.Definition
+	if err := rc.call("LambdaAPI.Update", &def, args[0], manifest, force); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, &def.Manifest, nil
+}
+
+func restListFiles(rc *restCall, args []string) (int, interface{}, error) {
+	var files []types.File
+	if err := rc.call("LambdaAPI.Files", &files, args[0], rc.request.URL.Query().Get("dir")); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, files, nil
+}
+
+func restPullFile(rc *restCall, args []string) (int, interface{}, error) {
+	var content []byte
+	if err := rc.call("LambdaAPI.Pull", &content, args[0], args[1]); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, rawContent(content), nil
+}
+
+func restPushFile(rc *restCall, args []string) (int, interface{}, error) {
+	content, err := ioutil.ReadAll(rc.request.Body)
+	if err != nil {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "read body: " + err.Error()}
+	}
+	return http.StatusNoContent, nil, rc.call("LambdaAPI.Push", nil, args[0], args[1], content)
+}
+
+func restRemoveFile(rc *restCall, args []string) (int, interface{}, error) {
+	return http.StatusNoContent, nil, rc.call("LambdaAPI.RemoveFile", nil, args[0], args[1])
+}
+
+func restListAliases(rc *restCall, args []string) (int, interface{}, error) {
+	def, err := rc.info(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, aliasesOf(def), nil
+}
+
+func restLinkAlias(rc *restCall, args []string) (int, interface{}, error) {
+	var def application.Definition
+	if err := rc.call("LambdaAPI.Link", &def, args[0], args[1]); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, aliasesOf(&def), nil
+}
+
+// alias is removed only if it is linked to the lambda of route
+func restUnlinkAlias(rc *restCall, args []string) (int, interface{}, error) {
+	def, err := rc.info(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if !def.Aliases[args[1]] {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusNotFound, Message: "alias " + args[1] + " is not linked to lambda " + args[0]}
+	}
+	var updated application.Definition
+	if err := rc.call("LambdaAPI.Unlink", &updated, args[1]); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, aliasesOf(&updated), nil
+}
+
+func restListSchedules(rc *restCall, args []string) (int, interface{}, error) {
+	def, err := rc.info(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schedulesOf(def.Manifest), nil
+}
+
+// schedules replace schedules of current manifest, so token with manage-schedule operation is enough
+func restSetSchedules(rc *restCall, args []string) (int, interface{}, error) {
+	var schedules []types.Schedule
+	if err := rc.decode(&schedules, false); err != nil {
+		return 0, nil, err
+	}
+	def, err := rc.info(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	manifest := def.Manifest
+	manifest.Cron = schedules
+	var updated application.Definition
+	if err := rc.call("LambdaAPI.Update", &updated, args[0], manifest, false); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schedulesOf(updated.Manifest), nil
+}
+
+func restListTokens(rc *restCall, _ []string) (int, interface{}, error) {
+	var tokens []api.TokenInfo
+	if err := rc.call("UserAPI.Tokens", &tokens); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, tokens, nil
+}
+
+func restCreateToken(rc *restCall, _ []string) (int, interface{}, error) {
+	var params struct {
+		Name  string             `json:"name"`
+		Scope api.Scope          `json:"scope"`
+		TTL   types.JsonDuration `json:"ttl"`
+	}
+	if err := rc.decode(&params, false); err != nil {
+		return 0, nil, err
+	}
+	var info api.TokenInfo
+	if err := rc.call("UserAPI.CreateToken", &info, params.Name, params.Scope, params.TTL); err != nil {
+		return 0, nil, err
+	}
+	rc.writer.Header().Set("Location", restPrefix+"tokens/"+info.ID)
+	return http.StatusCreated, &info, nil
+}
+
+func restRevokeToken(rc *restCall, args []string) (int, interface{}, error) {
+	return http.StatusNoContent, nil, rc.call("UserAPI.RevokeToken", nil, args[0])
+}
+
+func restRotateToken(rc *restCall, args []string) (int, interface{}, error) {
+	var params struct {
+		Overlap types.JsonDuration `json:"overlap"`
+	}
+	if err := rc.decode(&params, true); err != nil {
+		return 0, nil, err
+	}
+	var info api.TokenInfo
+	if err := rc.call("UserAPI.RotateToken", &info, args[0], params.Overlap); err != nil {
+		return 0, nil, err
+	}
+	rc.writer.Header().Set("Location", restPrefix+"tokens/"+info.ID)
+	return http.StatusCreated, &info, nil
+}
+
+func restBackup(rc *restCall, args []string) (int, interface{}, error) {
+	var options application.BackupOptions
+	options.NoTokens, _ = strconv.ParseBool(rc.request.URL.Query().Get("no_tokens"))
+	var archive []byte
+	if err := rc.call("ProjectAPI.Backup", &archive, options); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, rawContent(archive), nil
+}
+
+func restRestore(rc *restCall, args []string) (int, interface{}, error) {
+	archive, err := ioutil.ReadAll(rc.request.Body)
+	if err != nil {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "read body: " + err.Error()}
+	}
+	query := rc.request.URL.Query()
+	options := application.RestoreOptions{Conflict: query.Get("conflict")}
+	options.DryRun, _ = strconv.ParseBool(query.Get("dry_run"))
+	options.NoTokens, _ = strconv.ParseBool(query.Get("no_tokens"))
+	var report application.RestoreReport
+	if err := rc.call("ProjectAPI.Restore", &report, archive, options); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, &report, nil
+}
+
+func restExportStats(rc *restCall, args []string) (int, interface{}, error) {
+	uid := rc.request.URL.Query().Get("uid")
+	method := "ProjectAPI.StatsRange"
+	if uid != "" {
+		method = "LambdaAPI.StatsRange"
+	}
+	if err := rc.authorize(method, uid); err != nil {
+		return 0, nil, err
+	}
+	return rc.exportStats(uid)
+}
+
+func restExportLambdaStats(rc *restCall, args []string) (int, interface{}, error) {
+	if err := rc.authorize("LambdaAPI.StatsRange", args[0]); err != nil {
+		return 0, nil, err
+	}
+	if _, err := rc.srv.Platform.FindByUID(args[0]); err != nil {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return rc.exportStats(args[0])
+}
+
+func restListLogs(rc *restCall, args []string) (int, interface{}, error) {
+	var files []application.LogFile
+	if err := rc.call("LambdaAPI.LogFiles", &files, args[0]); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, files, nil
+}
+
+func restTailLog(rc *restCall, args []string) (int, interface{}, error) {
+	var lines int
+	if text := rc.request.URL.Query().Get("lines"); text != "" {
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return 0, nil, &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "invalid lines: " + err.Error()}
+		}
+		lines = value
+	}
+	var tail string
+	if err := rc.call("LambdaAPI.TailLog", &tail, args[0], lines); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, streamContent{contentType: "text/plain; charset=utf-8", write: func(out io.Writer) error {
+		_, err := io.WriteString(out, tail)
+		return err
+	}}, nil
+}
+
+// log file is streamed from disk without buffering (could be up to maximum size of log file of manifest)
+func restDownloadLog(rc *restCall, args []string) (int, interface{}, error) {
+	if err := rc.authorize("LambdaAPI.DownloadLog", args[0]); err != nil {
+		return 0, nil, err
+	}
+	def, err := rc.srv.Platform.FindByUID(args[0])
+	if err != nil {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	files, err := def.Lambda.LogFiles()
+	if err != nil {
+		return 0, nil, err
+	}
+	var found bool
+	for _, file := range files {
+		found = found || file.Name == args[1]
+	}
+	if !found {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusNotFound, Message: application.ErrLogNotFound.Error() + ": " + args[1]}
+	}
+	return http.StatusOK, streamContent{contentType: "text/plain; charset=utf-8", write: func(out io.Writer) error {
+		return def.Lambda.ReadLog(args[1], out)
+	}}, nil
+}
+
+// records (of lambda, empty UID - of all lambdas) in time range of query from the newest are streamed from storage
+// without buffering: format (csv or ndjson, default csv), since and until (RFC3339)
+func (rc *restCall) exportStats(uid string) (int, interface{}, error) {
+	if rc.srv.Stats == nil {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusNotImplemented, Message: "export of stats is not available"}
+	}
+	params := rc.request.URL.Query()
+	query := stats.Query{UID: uid}
+	for name, value := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if text := params.Get(name); text != "" {
+			moment, err := time.Parse(time.RFC3339, text)
+			if err != nil {
+				return 0, nil, &jsonrpc2.Error{Code: http.StatusBadRequest, Message: "invalid " + name + ": " + err.Error()}
+			}
+			*value = moment
+		}
+	}
+	format := params.Get("format")
+	if format == "" {
+		format = stats.ExportCSV
+	}
+	contentType := "text/csv; charset=utf-8"
+	if format == stats.ExportNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	if _, err := stats.NewExporter(format, ioutil.Discard); err != nil {
+		return 0, nil, &jsonrpc2.Error{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return http.StatusOK, streamContent{contentType: contentType, write: func(out io.Writer) error {
+		exporter, err := stats.NewExporter(format, out)
+		if err != nil {
+			return err
+		}
+		err = rc.srv.Stats.Scan(query, func(record *stats.Record) bool {
+			err = exporter.Write(record)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		return exporter.Flush()
+	}}, nil
+}
+
+// validate token and its scope for JSON-RPC method of lambda (empty UID - of all lambdas) in the same way as
+// interceptor of methods. For routes which are not mapped onto methods (streamed content)
+func (rc *restCall) authorize(method string, uid string) error {
+	if err := rc.srv.TokenHandler.ValidateToken(rc.ctx, rc.token); err != nil {
+		var rpcErr *jsonrpc2.Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &jsonrpc2.Error{Code: jsonrpc2.AppError, Message: err.Error()}
+		}
+		return rpcErr
+	}
+	if rc.token == nil || rc.token.Scope == nil {
+		return nil
+	}
+	scope, known := methodScopes[method]
+	if !known {
+		scope = methodScope{op: api.OpAdmin}
+	}
+	return permissionError(rc.token.Scope.Allows(scope.op, uid))
+}
+
+func (rc *restCall) info(uid string) (*application.Definition, error) {
+	var def application.Definition
+	if err := rc.call("LambdaAPI.Info", &def, uid); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// sorted aliases of lambda
+func aliasesOf(def *application.Definition) []string {
+	var ans = make([]string, 0, len(def.Aliases))
+	for alias := range def.Aliases {
+		ans = append(ans, alias)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+func schedulesOf(manifest types.Manifest) []types.Schedule {
+	if manifest.Cron == nil {
+		return []types.Schedule{}
+	}
+	return manifest.Cron
+}
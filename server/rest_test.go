@@ -0,0 +1,391 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type restClient struct {
+	t       *testing.T
+	handler http.Handler
+	token   string
+}
+
+func (rc *restClient) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload io.Reader = http.NoBody
+	switch v := body.(type) {
+	case nil:
+	case []byte:
+		payload = bytes.NewReader(v)
+	default:
+		data, err := json.Marshal(v)
+		require.NoError(rc.t, err)
+		payload = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, "https://example.com/api/v1/"+path, payload)
+	if rc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rc.token)
+	}
+	rr := httptest.NewRecorder()
+	rc.handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func (rc *restClient) json(method, path string, body interface{}, status int, out interface{}) {
+	rr := rc.do(method, path, body)
+	require.Equal(rc.t, status, rr.Code, rr.Body.String())
+	if out != nil {
+		require.NoError(rc.t, json.Unmarshal(rr.Body.Bytes(), out), rr.Body.String())
+	}
+}
+
+type restFailure struct {
+	Status  int    `json:"status"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (rc *restClient) fail(method, path string, body interface{}, status int) restFailure {
+	var failure restFailure
+	rc.json(method, path, body, status, &failure)
+	assert.Equal(rc.t, status, failure.Status)
+	assert.NotEmpty(rc.t, failure.Message)
+	return failure
+}
+
+func TestREST_lambdas(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	client := &restClient{t: t, handler: srv.Server.Handler(ctx)}
+
+	// authentication by the same tokens as JSON-RPC
+	failure := client.fail(http.MethodGet, "lambdas", nil, http.StatusUnauthorized)
+	assert.Contains(t, failure.Message, "token not provided")
+	client.fail(http.MethodPost, "login", map[string]string{"login": "admin", "password": "wrong"}, http.StatusUnauthorized)
+	var login struct {
+		Token string `json:"token"`
+	}
+	client.json(http.MethodPost, "login", map[string]string{"login": "admin", "password": "admin"}, http.StatusOK, &login)
+	require.NotEmpty(t, login.Token)
+	client.token = login.Token
+
+	var def application.Definition
+	rr := client.do(http.MethodPost, "lambdas", map[string]string{"name": "report"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &def))
+	assert.Equal(t, "/api/v1/lambdas/"+def.UID, rr.Header().Get("Location"))
+	uid := def.UID
+
+	var list []application.Definition
+	client.json(http.MethodGet, "lambdas", nil, http.StatusOK, &list)
+	require.Len(t, list, 1)
+	assert.Equal(t, uid, list[0].UID)
+	client.json(http.MethodGet, "lambdas/"+uid, nil, http.StatusOK, &def)
+	assert.Equal(t, "report", def.Manifest.Name)
+	client.fail(http.MethodGet, "lambdas/unknown", nil, http.StatusNotFound)
+	rr = client.do(http.MethodPatch, "lambdas/"+uid, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "GET, DELETE", rr.Header().Get("Allow"))
+	client.fail(http.MethodGet, "unknown", nil, http.StatusNotFound)
+
+	// manifest
+	var manifest types.Manifest
+	client.json(http.MethodGet, "lambdas/"+uid+"/manifest", nil, http.StatusOK, &manifest)
+	manifest.Description = "daily report"
+	client.json(http.MethodPut, "lambdas/"+uid+"/manifest", manifest, http.StatusOK, &manifest)
+	assert.Equal(t, "daily report", manifest.Description)
+	manifest.AllowedNetworks = []string{"not-a-network"}
+	client.fail(http.MethodPut, "lambdas/"+uid+"/manifest", manifest, http.StatusUnprocessableEntity)
+	client.fail(http.MethodPut, "lambdas/"+uid+"/manifest", nil, http.StatusBadRequest)
+
+	// files
+	rr = client.do(http.MethodPut, "lambdas/"+uid+"/files/input.txt", []byte("hello"))
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = client.do(http.MethodGet, "lambdas/"+uid+"/files/input.txt", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "hello", rr.Body.String())
+	assert.Equal(t, "application/octet-stream", rr.Header().Get("Content-Type"))
+	var files []types.File
+	client.json(http.MethodGet, "lambdas/"+uid+"/files?dir=/", nil, http.StatusOK, &files)
+	assert.Contains(t, files, types.File{Name: "input.txt"})
+	rr = client.do(http.MethodDelete, "lambdas/"+uid+"/files/input.txt", nil)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	client.fail(http.MethodGet, "lambdas/"+uid+"/files/input.txt", nil, http.StatusNotFound)
+
+	// aliases
+	var aliases []string
+	client.json(http.MethodPut, "lambdas/"+uid+"/aliases/daily", nil, http.StatusOK, &aliases)
+	assert.Contains(t, aliases, "daily")
+	client.json(http.MethodGet, "lambdas/"+uid+"/aliases", nil, http.StatusOK, &aliases)
+	assert.Contains(t, aliases, "daily")
+	client.json(http.MethodDelete, "lambdas/"+uid+"/aliases/daily", nil, http.StatusOK, &aliases)
+	assert.NotContains(t, aliases, "daily")
+	client.fail(http.MethodDelete, "lambdas/"+uid+"/aliases/daily", nil, http.StatusNotFound)
+
+	// schedules replace only schedules of manifest
+	var schedules []types.Schedule
+	client.json(http.MethodGet, "lambdas/"+uid+"/schedules", nil, http.StatusOK, &schedules)
+	assert.Empty(t, schedules)
+	client.json(http.MethodPut, "lambdas/"+uid+"/schedules", []types.Schedule{{Cron: "@every 1h"}}, http.StatusOK, &schedules)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "@every 1h", schedules[0].Cron)
+	client.json(http.MethodGet, "lambdas/"+uid+"/manifest", nil, http.StatusOK, &manifest)
+	assert.Equal(t, "daily report", manifest.Description)
+	assert.Len(t, manifest.Cron, 1)
+	client.fail(http.MethodPut, "lambdas/"+uid+"/schedules", []types.Schedule{{Cron: "not a cron"}}, http.StatusUnprocessableEntity)
+
+	rr = client.do(http.MethodDelete, "lambdas/"+uid, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Empty(t, srv.Server.Platform.List())
+}
+
+func TestREST_tokens(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	allowed, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	other, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	client := &restClient{t: t, handler: handler, token: admin.Data}
+
+	var info api.TokenInfo
+	rr := client.do(http.MethodPost, "tokens", map[string]interface{}{
+		"name":  "cron",
+		"scope": api.Scope{Lambdas: []string{allowed}, Operations: []string{api.OpManageSchedule}},
+		"ttl":   "1h",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &info))
+	assert.Equal(t, "/api/v1/tokens/"+info.ID, rr.Header().Get("Location"))
+	require.NotEmpty(t, info.Token)
+	client.fail(http.MethodPost, "tokens", map[string]interface{}{"scope": api.Scope{}}, http.StatusUnprocessableEntity)
+
+	var tokens []api.TokenInfo
+	client.json(http.MethodGet, "tokens", nil, http.StatusOK, &tokens)
+	require.Len(t, tokens, 1)
+	assert.Empty(t, tokens[0].Token)
+
+	// scoped token: the same scopes as for JSON-RPC
+	scoped := &restClient{t: t, handler: handler, token: info.Token}
+	var list []application.Definition
+	scoped.json(http.MethodGet, "lambdas", nil, http.StatusOK, &list)
+	require.Len(t, list, 1)
+	assert.Equal(t, allowed, list[0].UID)
+	scoped.json(http.MethodPut, "lambdas/"+allowed+"/schedules", []types.Schedule{{Cron: "@every 1h"}}, http.StatusOK, nil)
+	scoped.fail(http.MethodPut, "lambdas/"+other+"/schedules", []types.Schedule{{Cron: "@every 1h"}}, http.StatusForbidden)
+	scoped.fail(http.MethodGet, "lambdas/"+allowed+"/files", nil, http.StatusForbidden)
+	scoped.fail(http.MethodGet, "tokens", nil, http.StatusForbidden)
+	(&restClient{t: t, handler: handler, token: "invalid"}).fail(http.MethodGet, "lambdas", nil, http.StatusForbidden)
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/api/v1/lambdas", http.NoBody)
+	req.Header.Set("Authorization", "Basic YWRtaW46YWRtaW4=")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	var rotated api.TokenInfo
+	client.json(http.MethodPost, "tokens/"+info.ID+"/rotate", map[string]string{"overlap": "1m"}, http.StatusCreated, &rotated)
+	assert.NotEqual(t, info.ID, rotated.ID)
+	client.fail(http.MethodPost, "tokens/unknown/rotate", nil, http.StatusNotFound)
+
+	rr = client.do(http.MethodDelete, "tokens/"+rotated.ID, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	client.fail(http.MethodDelete, "tokens/"+rotated.ID, nil, http.StatusNotFound)
+}
+
+func TestREST_readOnly(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	srv.Server.Mirror = &staticMirror{primary: "https://primary.example.com/"}
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	client := &restClient{t: t, handler: srv.Server.Handler(ctx), token: admin.Data}
+
+	client.json(http.MethodGet, "lambdas/"+uid+"/schedules", nil, http.StatusOK, nil)
+	rr := client.do(http.MethodPut, "lambdas/"+uid+"/schedules", []types.Schedule{{Cron: "@every 1h"}})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "https://primary.example.com/", rr.Header().Get("X-Primary"))
+	assert.Contains(t, rr.Body.String(), "LambdaAPI.Update")
+	rr = client.do(http.MethodDelete, "lambdas/"+uid, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Len(t, srv.Server.Platform.List(), 1, "rejected call should not be invoked")
+}
+
+func TestREST_backup(t *testing.T) {
+	ctx := context.Background()
+	clients := make([]*restClient, 2)
+	servers := make([]*testServer, 2)
+	for i := range clients {
+		srv, err := createTestServer()
+		require.NoError(t, err)
+		defer os.RemoveAll(srv.Dir)
+		admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+		require.NoError(t, err)
+		servers[i], clients[i] = srv, &restClient{t: t, handler: srv.Server.Handler(ctx), token: admin.Data}
+	}
+	source, destination := clients[0], clients[1]
+	uid, err := servers[0].AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+
+	rr := source.do(http.MethodGet, "backup?no_tokens=true", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	archive := rr.Body.Bytes()
+
+	var report application.RestoreReport
+	destination.json(http.MethodPost, "restore?dry_run=true", archive, http.StatusOK, &report)
+	assert.True(t, report.DryRun)
+	assert.False(t, report.Header.Tokens)
+	require.NotEmpty(t, report.Items)
+	assert.Equal(t, application.RestoreLambda, report.Items[0].Kind)
+	assert.Equal(t, uid, report.Items[0].Target)
+	assert.Empty(t, servers[1].Server.Platform.List())
+
+	destination.json(http.MethodPost, "restore", archive, http.StatusOK, &report)
+	assert.False(t, report.DryRun)
+	_, err = servers[1].Server.Platform.FindByUID(uid)
+	assert.NoError(t, err)
+
+	destination.fail(http.MethodPost, "restore?conflict=fail", archive, http.StatusConflict)
+	destination.fail(http.MethodPost, "restore", []byte("not an archive"), http.StatusBadRequest)
+}
+
+func TestREST_exportStats(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	allowed, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	other, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	for _, uid := range []string{allowed, other, allowed} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello, world")))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	client := &restClient{t: t, handler: handler, token: admin.Data}
+
+	rr := client.do(http.MethodGet, "stats/export", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, "time", rows[0][0])
+	assert.Equal(t, "request_id", rows[0][len(rows[0])-1])
+
+	rr = client.do(http.MethodGet, "lambdas/"+allowed+"/stats/export?format=ndjson", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	var records []stats.Exported
+	decoder := json.NewDecoder(rr.Body)
+	for decoder.More() {
+		var record stats.Exported
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+	assert.Equal(t, allowed, records[0].UID)
+	assert.Equal(t, stats.TriggerHTTP, records[0].Trigger)
+	assert.Equal(t, int64(12), records[0].Payload)
+
+	client.fail(http.MethodGet, "stats/export?format=xml", nil, http.StatusBadRequest)
+	client.fail(http.MethodGet, "stats/export?since=yesterday", nil, http.StatusBadRequest)
+	client.fail(http.MethodGet, "lambdas/unknown/stats/export", nil, http.StatusNotFound)
+	(&restClient{t: t, handler: handler}).fail(http.MethodGet, "stats/export", nil, http.StatusUnauthorized)
+
+	// scoped token exports only records of allowed lambdas
+	var info api.TokenInfo
+	client.json(http.MethodPost, "tokens", map[string]interface{}{
+		"name":  "metrics",
+		"scope": api.Scope{Lambdas: []string{allowed}, Operations: []string{api.OpReadStats}},
+	}, http.StatusCreated, &info)
+	scoped := &restClient{t: t, handler: handler, token: info.Token}
+	scoped.json(http.MethodGet, "lambdas/"+allowed+"/stats/export", nil, http.StatusOK, nil)
+	scoped.json(http.MethodGet, "stats/export?uid="+allowed, nil, http.StatusOK, nil)
+	scoped.fail(http.MethodGet, "lambdas/"+other+"/stats/export", nil, http.StatusForbidden)
+	scoped.fail(http.MethodGet, "stats/export", nil, http.StatusForbidden)
+}
+
+func TestREST_logs(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:  []string{"cat", "-"},
+		Logs: &types.LogFiles{Enabled: true},
+	}})
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello\nworld")))
+	require.Equal(t, http.StatusOK, rr.Code)
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	client := &restClient{t: t, handler: handler, token: admin.Data}
+
+	var files []application.LogFile
+	client.json(http.MethodGet, "lambdas/"+uid+"/logs", nil, http.StatusOK, &files)
+	require.Len(t, files, 1)
+	assert.True(t, files[0].Current)
+
+	rr = client.do(http.MethodGet, "lambdas/"+uid+"/logs/"+files[0].Name, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), " stdout hello\n")
+	assert.Contains(t, rr.Body.String(), " stdout world\n", "incomplete line is written after invocation")
+
+	rr = client.do(http.MethodGet, "lambdas/"+uid+"/logs/tail?lines=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.True(t, strings.HasSuffix(rr.Body.String(), " stdout world\n"))
+	assert.Equal(t, 1, strings.Count(rr.Body.String(), "\n"))
+
+	client.fail(http.MethodGet, "lambdas/"+uid+"/logs/tail?lines=many", nil, http.StatusBadRequest)
+	client.fail(http.MethodGet, "lambdas/"+uid+"/logs/unknown.log", nil, http.StatusNotFound)
+	client.fail(http.MethodGet, "lambdas/unknown/logs/"+files[0].Name, nil, http.StatusNotFound)
+	(&restClient{t: t, handler: handler}).fail(http.MethodGet, "lambdas/"+uid+"/logs/"+files[0].Name, nil, http.StatusUnauthorized)
+
+	// log files are read with invoke operation
+	var info api.TokenInfo
+	client.json(http.MethodPost, "tokens", map[string]interface{}{
+		"name":  "stats",
+		"scope": api.Scope{Lambdas: []string{uid}, Operations: []string{api.OpReadStats}},
+	}, http.StatusCreated, &info)
+	scoped := &restClient{t: t, handler: handler, token: info.Token}
+	scoped.fail(http.MethodGet, "lambdas/"+uid+"/logs", nil, http.StatusForbidden)
+	scoped.fail(http.MethodGet, "lambdas/"+uid+"/logs/"+files[0].Name, nil, http.StatusForbidden)
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// content types which are never rewritten even if they are text: body should be delivered as soon as possible
+var streamingTypes = []string{"text/event-stream", "application/x-ndjson"}
+
+// public base URL of server for request (without trailing slash): configured URL or, if not set, scheme and host
+// of request (from X-Forwarded-Proto and X-Forwarded-Host if server is behind proxy)
+func (srv *Server) publicURL(request *http.Request) string {
+	if srv.PublicURL != "" {
+		return strings.TrimSuffix(srv.PublicURL, "/")
+	}
+	scheme := "http"
+	if request.TLS != nil {
+		scheme = "https"
+	}
+	host := request.Host
+	if srv.forwarded(request) {
+		if proto := firstValue(request.Header.Get("X-Forwarded-Proto")); proto != "" {
+			scheme = strings.ToLower(proto)
+		}
+		if forwarded := firstValue(request.Header.Get("X-Forwarded-Host")); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host
+}
+
+// first value of comma-separated header (added by chain of proxies)
+func firstValue(value string) string {
+	return strings.TrimSpace(strings.SplitN(value, ",", 2)[0])
+}
+
+// rewriting is possible only for text content types, unknown (empty) type is detected by body later
+func rewritable(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, streaming := range streamingTypes {
+		if mediaType == streaming {
+			return false
+		}
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
+// replace internal prefix by public base URL in small text body, returns body as is if it's not rewritable
+func rewriteBody(rule *types.Rewrite, publicURL string, contentType string, body []byte) []byte {
+	if rule == nil || int64(len(body)) > rule.Limit() {
+		return body
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	if !rewritable(contentType) {
+		return body
+	}
+	prefix := strings.TrimSuffix(rule.Prefix, "/")
+	return bytes.ReplaceAll(body, []byte(prefix), []byte(publicURL))
+}
+
+// Writer buffers output of lambda up to size limit of rewriting. Bigger output is streamed as is.
+type rewriteWriter struct {
+	response  *lambdaResponse
+	rule      *types.Rewrite
+	publicURL string
+	status    int
+	buffer    bytes.Buffer
+	output    io.Writer // not nil if limit exceeded and output is streamed
+}
+
+// writer which rewrites URLs in response (if possible), Close should be called to send buffered response
+func (lr *lambdaResponse) rewrite(status int, rule *types.Rewrite, publicURL string) io.WriteCloser {
+	if !rewritable(lr.writer.Header().Get("Content-Type")) {
+		return nopWriteCloser{lr.stream(status)}
+	}
+	return &rewriteWriter{response: lr, rule: rule, publicURL: publicURL, status: status}
+}
+
+func (rw *rewriteWriter) Write(data []byte) (int, error) {
+	if rw.output != nil {
+		return rw.output.Write(data)
+	}
+	if int64(rw.buffer.Len()+len(data)) <= rw.rule.Limit() {
+		return rw.buffer.Write(data)
+	}
+	rw.output = rw.response.stream(rw.status)
+	if _, err := rw.output.Write(rw.buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	rw.buffer.Reset()
+	return rw.output.Write(data)
+}
+
+func (rw *rewriteWriter) Close() error {
+	if rw.output != nil {
+		return nil
+	}
+	contentType := rw.response.writer.Header().Get("Content-Type")
+	rw.response.send(rw.status, rewriteBody(rw.rule, rw.publicURL, contentType, rw.buffer.Bytes()))
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
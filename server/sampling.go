@@ -0,0 +1,34 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// sampler of detailed invocation records: keeps 1-in-N successful records per lambda
+type sampler struct {
+	lock     sync.Mutex
+	counters map[string]uint64
+}
+
+// decide should record be kept and set sampling rate for kept record
+func (s *sampler) keep(uid string, sampling *types.Sampling, record *stats.Record) bool {
+	if sampling == nil || sampling.Rate <= 1 || record.Err != "" || len(record.Limits) > 0 {
+		return true
+	}
+	if sampling.Slow > 0 && record.End.Sub(record.Begin) >= time.Duration(sampling.Slow) {
+		return true
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	n := s.counters[uid]
+	s.counters[uid] = n + 1
+	if n%uint64(sampling.Rate) != 0 {
+		return false
+	}
+	record.Rate = sampling.Rate
+	return true
+}
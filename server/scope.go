@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/reddec/jsonrpc2"
+
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Scope of JSON-RPC method required from scoped token
+type methodScope struct {
+	op     string // required operation, empty - any operation for the lambda
+	lambda string // name of the second argument (after token) with UID of lambda, empty - method for all lambdas
+}
+
+// Scopes of JSON-RPC methods for scoped tokens. Other methods (including new methods) require admin operation for
+// all lambdas
+var methodScopes = map[string]methodScope{
+	"LambdaAPI.Upload":             {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.UploadBundle":       {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.UploadVerified":     {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Download":           {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.ContentHash":        {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.SignedContentHash":  {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Push":               {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Pull":               {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Files":              {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.CreateFile":         {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.RemoveFile":         {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.RenameFile":         {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.GitDeploy":          {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Freeze":             {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.FrozenArtifacts":    {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.DownloadFrozen":     {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Thaw":               {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.UploadVersion":      {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Versions":           {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Rollback":           {op: api.OpUpload, lambda: "uid"},
+	"LambdaAPI.Actions":            {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.Invoke":             {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.InvokeAction":       {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.Requests":           {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.CapturedRequest":    {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.Replay":             {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.LogFiles":           {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.DownloadLog":        {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.TailLog":            {op: api.OpInvoke, lambda: "uid"},
+	"LambdaAPI.Stats":              {op: api.OpReadStats, lambda: "uid"},
+	"LambdaAPI.StatsRange":         {op: api.OpReadStats, lambda: "uid"},
+	"LambdaAPI.StatsAggregate":     {op: api.OpReadStats, lambda: "uid"},
+	"LambdaAPI.Doctor":             {op: api.OpReadStats, lambda: "uid"},
+	"QueuesAPI.Linked":             {op: api.OpReadStats, lambda: "lambda"},
+	"QueuesAPI.DeadLetters":        {op: api.OpReadStats, lambda: "lambda"},
+	"QueuesAPI.DeadLetter":         {op: api.OpReadStats, lambda: "lambda"},
+	"ProjectAPI.Stats":             {op: api.OpReadStats},
+	"ProjectAPI.StatsRange":        {op: api.OpReadStats},
+	"ProjectAPI.StatsAggregate":    {op: api.OpReadStats},
+	"LambdaAPI.Update":             {op: api.OpManageSchedule, lambda: "uid"}, // other changes than schedules require admin
+	"LambdaAPI.Info":               {lambda: "uid"},
+	"ProjectAPI.List":              {}, // any scoped token, filtered by scope
+	"ProjectAPI.Capabilities":      {}, // any scoped token
+	"ProjectAPI.OpenAPI":           {lambda: "lambda"},
+	"LambdaAPI.Remove":             {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.Environment":        {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.SetEnvironment":     {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.MergedEnvironment":  {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.SetBasicAuth":       {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.Link":               {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.SetEnabled":         {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.RegenerateSlug":     {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.ResetAlerts":        {op: api.OpAdmin, lambda: "uid"},
+	"LambdaAPI.GrantExport":        {op: api.OpAdmin, lambda: "uid"},
+	"QueuesAPI.RemoveDeadLetters":  {op: api.OpAdmin, lambda: "lambda"},
+	"QueuesAPI.RedriveDeadLetters": {op: api.OpAdmin, lambda: "lambda"},
+	"PoliciesAPI.Apply":            {op: api.OpAdmin, lambda: "lambda"},
+	"PoliciesAPI.Clear":            {op: api.OpAdmin, lambda: "lambda"},
+}
+
+// JSON-RPC methods without login token
+var tokenlessMethods = map[string]bool{
+	"UserAPI.Login":    true,
+	"LambdaAPI.Export": true, // by token of grant
+}
+
+// check scope of token before invocation of method. Calls without token are passed to method as is: they are
+// rejected by validation of token in handler. Calls with invalid token are rejected here by the same error, so failed
+// authentication is validated (and recorded) once. Calls with arguments which could not be decoded are rejected
+// before the check: they are rejected by handler anyway
+func (srv *Server) checkScope(ic *jsonrpc2.MethodInterceptorContext) (interface{}, error) {
+	method := ic.Request.Method
+	if tokenlessMethods[method] || srv.TokenHandler == nil {
+		return ic.Next()
+	}
+	var token *api.Token
+	if err := argument(ic.Request.Params, ic.IsPositional, "token", 0, &token); err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return ic.Next()
+	}
+	if err := srv.TokenHandler.ValidateToken(ic.Context, token); err != nil {
+		return nil, err
+	}
+	if token.Scope == nil {
+		return ic.Next()
+	}
+	scope, known := methodScopes[method]
+	if !known {
+		scope = methodScope{op: api.OpAdmin}
+	}
+	var uid string
+	if scope.lambda != "" {
+		if err := argument(ic.Request.Params, ic.IsPositional, scope.lambda, 1, &uid); err != nil {
+			return nil, err
+		}
+		if uid == "" {
+			return nil, permissionError(&api.ScopeError{Operation: scope.op})
+		}
+	}
+	if scope.op != "" || scope.lambda != "" {
+		if err := token.Scope.Allows(scope.op, uid); err != nil {
+			return nil, permissionError(err)
+		}
+	}
+	if method == "LambdaAPI.Update" {
+		if err := srv.checkManifestScope(ic, token.Scope, uid); err != nil {
+			return nil, err
+		}
+	}
+	result, err := ic.Next()
+	if list, ok := result.([]application.Definition); ok && method == "ProjectAPI.List" {
+		var filtered = make([]application.Definition, 0, len(list))
+		for _, def := range list {
+			if token.Scope.HasLambda(def.UID) {
+				filtered = append(filtered, def)
+			}
+		}
+		result = filtered
+	}
+	return result, err
+}
+
+// token without admin operation could change only schedules in manifest
+func (srv *Server) checkManifestScope(ic *jsonrpc2.MethodInterceptorContext, scope *api.Scope, uid string) error {
+	if scope.Allows(api.OpAdmin, uid) == nil {
+		return nil
+	}
+	var manifest types.Manifest
+	if err := argument(ic.Request.Params, ic.IsPositional, "manifest", 2, &manifest); err != nil {
+		return err
+	}
+	def, err := srv.Platform.FindByUID(uid)
+	if err != nil {
+		return err
+	}
+	current := def.Lambda.Manifest()
+	current.Cron, manifest.Cron = nil, nil
+	if !reflect.DeepEqual(canonicalManifest(current), canonicalManifest(manifest)) {
+		return permissionError(&api.ScopeError{Operation: api.OpAdmin, Lambda: uid})
+	}
+	return nil
+}
+
+// manifest as generic JSON to compare manifests regardless of empty and nil values
+func canonicalManifest(manifest types.Manifest) interface{} {
+	var value interface{}
+	data, _ := json.Marshal(manifest)
+	_ = json.Unmarshal(data, &value)
+	return value
+}
+
+// argument of method by name (named parameters) or by position (positional parameters). Named parameters are decoded
+// to field of struct like by generated handlers, so name is matched the same way (case-insensitive, the last wins) and
+// missing argument is zero value
+func argument(params json.RawMessage, positional bool, name string, position int, value interface{}) error {
+	if positional {
+		var args []json.RawMessage
+		if err := json.Unmarshal(params, &args); err != nil {
+			return err
+		}
+		if position >= len(args) {
+			return errors.New("missing argument " + name)
+		}
+		return json.Unmarshal(args[position], value)
+	}
+	target := reflect.ValueOf(value).Elem()
+	args := reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: target.Type(),
+		Tag:  reflect.StructTag(`json:"` + name + `"`),
+	}}))
+	if err := json.Unmarshal(params, args.Interface()); err != nil {
+		return err
+	}
+	target.Set(args.Elem().Field(0))
+	return nil
+}
+
+func permissionError(err error) error {
+	var scopeErr *api.ScopeError
+	if !errors.As(err, &scopeErr) {
+		return err
+	}
+	return &jsonrpc2.Error{
+		Code:    http.StatusForbidden,
+		Message: "permission denied: " + scopeErr.Error(),
+		Data:    map[string]string{"scope": scopeErr.Operation, "lambda": scopeErr.Lambda},
+	}
+}
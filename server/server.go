@@ -1,8 +1,16 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,27 +29,73 @@ type TokenHandler interface {
 }
 
 type Server struct {
-	Policies     application.Policies
-	Platform     application.Platform
-	Cases        application.Cases
-	Queues       application.Queues
-	Dev          bool
-	BehindProxy  bool
-	Tracker      stats.Recorder
-	TokenHandler TokenHandler
-	ProjectAPI   api.ProjectAPI
-	LambdaAPI    api.LambdaAPI
-	UserAPI      api.UserAPI
-	QueuesAPI    api.QueuesAPI
-	PoliciesAPI  api.PoliciesAPI
+	Policies       application.Policies
+	Platform       application.Platform
+	Cases          application.Cases
+	Queues         application.Queues
+	Alerts         application.Alerts // optional alert rules of lambdas
+	Dev            bool
+	BehindProxy    bool
+	TrustedProxies types.Networks     // optional proxies which forwarded headers are respected, instead of any peer if BehindProxy
+	ForwardedBy    []string           // forwarded headers of client address of trusted proxies in order of preference (empty - types.DefaultForwardedHeaders)
+	PublicURL      string             // public base URL of server (empty - detected by request)
+	RemoveHeaders  []string           // response headers removed from all responses (see Manifest.RemoveHeaders)
+	Tracker        stats.Recorder     // detailed (sampled) invocation records
+	Stats          stats.Reader       // optional reader of invocation records for streamed export (see restPrefix)
+	Metrics        Metrics            // optional exact counters, exposed on /metrics
+	InvocationLog  stats.Recorder     // optional structured log of every invocation (without sampling)
+	Webhooks       stats.Recorder     // optional notifier of failed invocations by lifecycle webhooks
+	StatusPages    http.Handler       // optional public status pages of lambdas, exposed on /status/ (without prefix)
+	Hooks          *application.Hooks // optional lifecycle hooks of embedder
+	Mirror         application.Mirror // optional replication of primary: read-only mirror rejects mutating API
+	Async          *AsyncQueue        // optional asynchronous invocations (see NewAsyncQueue)
+	WarmUp         application.WarmUp // optional warm-up of lambdas on start, reported on /health/warmup
+	DataDir        string             // optional data directory checked for writes by liveness probe
+	QueueHighWater int64              // depth of any queue over which server is not ready (zero - not checked)
+	TokenHandler   TokenHandler
+	ProjectAPI     api.ProjectAPI
+	LambdaAPI      api.LambdaAPI
+	UserAPI        api.UserAPI
+	QueuesAPI      api.QueuesAPI
+	PoliciesAPI    api.PoliciesAPI
+	GitDeploys     application.GitDeploys // optional deploys from git triggered by ping of repository on GitPrefix
+	flights        *coalescer
+	cache          *responseCache
+	slots          *concurrency
+	rates          *rateLimiter
+	limitNotices   *limitNotices
+	streams        *streams
+	keySets        *keySets
+	draining       int32 // atomic: new requests are rejected (see Drain)
+}
+
+// Path of public status pages
+const statusPrefix = "/status/"
+
+// Metrics tracks every invocation (without sampling) and exposes them by HTTP
+type Metrics interface {
+	stats.Recorder
+	http.Handler
 }
 
 func (srv *Server) Handler(ctx context.Context) http.Handler {
 	mux := http.NewServeMux()
 	srv.installAPI(ctx, mux)
 	srv.installPublicRoutes(ctx, mux)
+	srv.installHealth(mux)
+	if srv.Metrics != nil {
+		mux.Handle("/metrics", srv.Metrics)
+	}
+	if srv.StatusPages != nil {
+		mux.Handle(statusPrefix, http.StripPrefix(statusPrefix, srv.StatusPages))
+	}
 	srv.installUI(mux)
-	return mux
+	if len(srv.RemoveHeaders) == 0 {
+		return srv.drainable(mux)
+	}
+	return srv.drainable(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mux.ServeHTTP(removeHeaders(writer, srv.RemoveHeaders), request)
+	}))
 }
 
 func (srv *Server) installAPI(ctx context.Context, mux *http.ServeMux) {
@@ -51,8 +105,26 @@ func (srv *Server) installAPI(ctx context.Context, mux *http.ServeMux) {
 	handlers.RegisterProjectAPI(&router, srv.ProjectAPI, srv.TokenHandler)
 	handlers.RegisterQueuesAPI(&router, srv.QueuesAPI, srv.TokenHandler)
 	handlers.RegisterPoliciesAPI(&router, srv.PoliciesAPI, srv.TokenHandler)
+	router.InterceptMethods(srv.checkScope)
 
-	mux.Handle("/u/", chooseHandler(srv.Dev, jsonrpc2.HandlerRestContext(ctx, &router)))
+	mux.Handle("/u/", chooseHandler(srv.Dev, srv.readOnly(srv.withCaller(ctx, &router))))
+	mux.Handle(restPrefix, chooseHandler(srv.Dev, http.StripPrefix(strings.TrimSuffix(restPrefix, "/"), srv.restAPI(ctx, &router))))
+}
+
+// JSON-RPC handler with caller of request (client address, local admin socket) in context of methods
+func (srv *Server) withCaller(ctx context.Context, router *jsonrpc2.Router) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		jsonrpc2.HandlerRestContext(api.WithCaller(ctx, srv.callerOf(request)), router)(writer, request)
+	})
+}
+
+// caller of API request: client address or local admin socket
+func (srv *Server) callerOf(request *http.Request) api.Caller {
+	caller := api.Caller{Local: isLocal(request)}
+	if !caller.Local {
+		caller.Address = clientIP(srv.fromHTTP(request).RemoteAddress)
+	}
+	return caller
 }
 
 func (srv *Server) installUI(mux *http.ServeMux) {
@@ -60,96 +132,710 @@ func (srv *Server) installUI(mux *http.ServeMux) {
 }
 
 func (srv *Server) installPublicRoutes(ctx context.Context, mux *http.ServeMux) {
-	mux.Handle("/a/", openedHandler(http.StripPrefix("/a/", srv.withRequest(ctx, srv.handleLambda))))
-	mux.Handle("/l/", openedHandler(http.StripPrefix("/l/", srv.withRequest(ctx, srv.handleLink))))
-	mux.Handle("/q/", openedHandler(http.StripPrefix("/q/", srv.withRequest(ctx, srv.handleQueue))))
+	records := &sampler{counters: make(map[string]uint64)}
+	srv.flights = newCoalescer()
+	srv.cache = newResponseCache()
+	srv.slots = newConcurrency()
+	srv.rates = newRateLimiter()
+	srv.limitNotices = newLimitNotices()
+	srv.streams = newStreams(ctx)
+	srv.keySets = newKeySets()
+	mux.Handle(types.LambdaPrefix, http.StripPrefix(types.LambdaPrefix, corsHandler(srv.Platform.FindByUID, false, srv.withRequest(ctx, records, stats.TriggerHTTP, srv.handleLambda))))
+	mux.Handle(types.LinkPrefix, http.StripPrefix(types.LinkPrefix, corsHandler(srv.Platform.FindByLink, true, srv.withRequest(ctx, records, stats.TriggerHTTP, srv.handleLink))))
+	mux.Handle(types.QueuePrefix, openedHandler(http.StripPrefix(types.QueuePrefix, srv.withRequest(ctx, records, stats.TriggerQueue, srv.handleQueue))))
+	if srv.GitDeploys != nil {
+		mux.Handle(types.GitPrefix, http.StripPrefix(types.GitPrefix, http.HandlerFunc(srv.gitPing)))
+	}
+	if srv.Async != nil {
+		// accepted requests are served by the same routes without async prefix
+		srv.Async.start(ctx, mux)
+		mux.Handle(types.AsyncPrefix, openedHandler(http.StripPrefix(strings.TrimSuffix(types.AsyncPrefix, "/"), srv.submitAsync(ctx))))
+		mux.Handle(types.ResultPrefix, openedHandler(http.StripPrefix(types.ResultPrefix, http.HandlerFunc(srv.asyncResult))))
+	}
 }
-func (srv *Server) handleQueue(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+func (srv *Server) handleQueue(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) *types.Sampling {
 	q, err := srv.Queues.Get(uid)
 	if err != nil {
 		record.Err = err.Error()
 		http.Error(writer, err.Error(), http.StatusNotFound)
-		return
+		return nil
 	}
 	err = srv.Policies.Inspect(q.Target, req)
 
 	if err != nil {
 		record.Err = err.Error()
+		record.Rejected = true
+		record.Denied = errors.Is(err, application.ErrNetworkRestricted)
 		http.Error(writer, err.Error(), http.StatusForbidden)
-		return
+		return nil
+	}
+	// body over max element size is rejected while it's stored
+	if err := rejectLength(req, writer, q.MaxElementSize, record); err != nil {
+		return nil
+	}
+	if target, err := srv.Platform.FindByUID(q.Target); err == nil {
+		if err := srv.allowByNetwork(req, writer, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if err := srv.allowByBasicAuth(req, writer, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if err := srv.allowByJWT(req, writer, target, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if err := srv.acceptMethod(req, writer, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if err := srv.acceptContentType(req, writer, target, record); err != nil {
+			return nil
+		}
+		if err := srv.allowByRate(req, writer, target, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if err := srv.beforeInvoke(ctx, req, writer, target, q.Name, record); err != nil {
+			return nil
+		}
+		if err := srv.acceptPayload(req, writer, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if req, err = srv.verifySignature(req, writer, target, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		// queued request is the mutated one
+		if req, err = srv.mutateRequest(req, writer, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
+		if req, err = srv.validateInput(req, writer, target, target.Lambda.Effective(), record); err != nil {
+			return nil
+		}
 	}
 
 	err = srv.Queues.Put(uid, req)
-	if err != nil {
+	if errors.Is(err, application.ErrPayloadTooLarge) {
+		record.Err = err.Error()
+		record.Rejected = true
+		http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil
+	} else if err != nil {
 		record.Err = err.Error()
 		http.Error(writer, err.Error(), http.StatusInternalServerError)
-		return
+		return nil
 	}
 	writer.WriteHeader(http.StatusNoContent)
+	return nil
 }
-func (srv *Server) handleLambda(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+func (srv *Server) handleLambda(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) *types.Sampling {
 	lambda, err := srv.Platform.FindByUID(uid)
 
 	if err != nil {
 		record.Err = err.Error()
 		http.Error(writer, err.Error(), http.StatusNotFound)
-		return
+		return nil
 	}
 
-	srv.runLambda(ctx, req, writer, lambda, record)
+	return srv.runLambda(ctx, req, writer, lambda, record)
 }
 
-func (srv *Server) handleLink(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+func (srv *Server) handleLink(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) *types.Sampling {
 	lambda, err := srv.Platform.FindByLink(uid)
 
 	if err != nil {
 		record.Err = err.Error()
 		http.Error(writer, err.Error(), http.StatusNotFound)
-		return
+		return nil
 	}
+	req.Alias = uid
+	record.Request.Alias = uid
 
-	srv.runLambda(ctx, req, writer, lambda, record)
+	return srv.runLambda(ctx, req, writer, lambda, record)
 }
 
-func (srv *Server) runLambda(ctx context.Context, req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) {
+func (srv *Server) runLambda(ctx context.Context, req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) *types.Sampling {
+	if err := allowEnabled(writer, lambda, record); err != nil {
+		return nil
+	}
+	// access settings of lambda could be overridden by policy of alias
+	manifest := lambda.Lambda.Effective().ForAlias(req.Alias)
+	writer = removeHeaders(writer, manifest.RemoveHeaders)
+	static := isStatic(req, manifest)
+	if !static {
+		if err := srv.acceptMethod(req, writer, manifest, record); err != nil {
+			return nil
+		}
+	}
+	if err := srv.allowByNetwork(req, writer, manifest, record); err != nil {
+		return nil
+	}
+	if err := srv.allowByBasicAuth(req, writer, manifest, record); err != nil {
+		return nil
+	}
+	if err := srv.allowByJWT(req, writer, lambda, manifest, record); err != nil {
+		return nil
+	}
 	err := srv.Policies.Inspect(lambda.UID, req)
 	if err != nil {
 		record.End = time.Now()
 		record.Err = err.Error()
+		record.Rejected = true
+		record.Denied = errors.Is(err, application.ErrNetworkRestricted)
 		http.Error(writer, err.Error(), http.StatusForbidden)
-		return
+		return nil
+	}
+	if static {
+		srv.serveStatic(req, writer, lambda, record)
+		return lambda.Lambda.Effective().Sampling
+	}
+	if err := srv.acceptContentType(req, writer, lambda, record); err != nil {
+		return nil
+	}
+	if err := srv.allowByAlerts(writer, lambda, record); err != nil {
+		return nil
+	}
+	if err := srv.allowByRate(req, writer, lambda, manifest, record); err != nil {
+		return nil
+	}
+	if err := srv.beforeInvoke(ctx, req, writer, lambda, "", record); err != nil {
+		return nil
+	}
+	if err := srv.acceptPayload(req, writer, manifest, record); err != nil {
+		return nil
+	}
+	if req, err = srv.verifySignature(req, writer, lambda, manifest, record); err != nil {
+		return nil
+	}
+	if manifest.SoftLimits != nil {
+		payload := &countingReader{ReadCloser: req.Body}
+		sent := &countingWriter{ResponseWriter: writer}
+		req, writer = req.WithBody(payload), sent
+		defer func() { srv.checkSoftLimits(ctx, lambda, manifest, record, payload.n, sent.n) }()
+	}
+	if req, err = srv.mutateRequest(req, writer, manifest, record); err != nil {
+		return nil
+	}
+	if req, err = srv.validateInput(req, writer, lambda, manifest, record); err != nil {
+		return nil
+	}
+	req, captured := srv.capture(req, lambda, manifest, record)
+	defer captured()
+	enableCompression(writer, manifest)
+	response := newLambdaResponse(writer, req, manifest)
+	ctx = application.WithUsage(ctx, &application.Usage{})
+
+	if manifest.Cache != nil || (manifest.Coalesce != nil && req.Headers[NoCoalesceHeader] == "") {
+		srv.runBuffered(ctx, req, response, lambda, manifest, record)
+		return manifest.Sampling
+	}
+	release, err := srv.acquireSlot(ctx, lambda, manifest, record)
+	if err != nil {
+		srv.rejectOverflow(writer, record, err)
+		return nil
+	}
+	defer release()
+	// status by exit code requires complete output
+	if len(manifest.StatusMap) > 0 {
+		var out bytes.Buffer
+		err = srv.Platform.Invoke(ctx, lambda.Lambda, *req, &out)
+		record.End = time.Now()
+		recordUsage(ctx, record)
+		if err != nil {
+			record.Err = err.Error()
+		}
+		srv.sendBuffered(req, response, manifest, record, out.Bytes(), err)
+		return manifest.Sampling
+	}
+
+	open := func(status int) io.WriteCloser {
+		if manifest.SSE() {
+			response.eventStream()
+		}
+		if manifest.MaximumResponse > 0 && manifest.TruncateResponse() {
+			// overrun after start of response is reported by trailer
+			response.writer.Header().Set("Trailer", TruncatedHeader)
+		}
+		if manifest.RewriteURLs != nil {
+			return response.rewrite(status, manifest.RewriteURLs, req.PublicURL)
+		}
+		return nopWriteCloser{response.stream(status)}
+	}
+	var output io.WriteCloser
+	if manifest.ParseHeaders {
+		output = response.headerWriter(record, open)
+	} else {
+		output = &lazyWriter{open: func() io.WriteCloser { return open(http.StatusOK) }}
+	}
+	// limited output is held at the beginning to answer overrun of short output by status, events are never held
+	var hold int
+	if manifest.MaximumResponse > 0 && !manifest.SSE() {
+		hold = int(min(manifest.MaximumResponse, holdWindow))
+	}
+	stream := newStreamWriter(output, response.flush, hold)
+	stream.lines = manifest.SSE()
+	invocation, session := ctx, (*streamSession)(nil)
+	if manifest.SSE() {
+		invocation, session = srv.streams.start(ctx, lambda.UID, manifest, stream, func() error {
+			return srv.Policies.Inspect(lambda.UID, req)
+		})
+	}
+	err = srv.Platform.Invoke(invocation, lambda.Lambda, *req, stream)
+	var closed string // reason of stream closed by server
+	if session != nil {
+		closed = session.stop()
+	}
+	started := stream.halt()
+	record.End = time.Now()
+	recordUsage(ctx, record)
+	if closed != "" {
+		// lambda is interrupted by server: reason is recorded instead of error of process
+		err = nil
+		record.Err = "stream closed by server: " + closed
+	} else if err != nil {
+		record.Err = err.Error()
+	}
+	status, rejected := rejectionStatus(err)
+	overrun := errors.Is(err, application.ErrResponseTooLarge)
+	if !started && (rejected || overrun) {
+		srv.sendBuffered(req, response, manifest, record, stream.Held(), err)
+		return manifest.Sampling
+	}
+	if rejected && !response.sent {
+		// output (if any) is dropped: body crossed the limit before response is started
+		http.Error(writer, err.Error(), status)
+		return manifest.Sampling
+	}
+	if overrun {
+		record.Overrun = true
+		if !manifest.TruncateResponse() {
+			if !response.sent {
+				http.Error(writer, err.Error(), http.StatusBadGateway)
+				return manifest.Sampling
+			}
+			// client should not take cut output as complete response (record is tracked anyway)
+			panic(http.ErrAbortHandler)
+		}
+		// truncated output is served, overrun is not a failure of invocation
+		record.Err = ""
+		record.Warning = err.Error()
+		response.writer.Header().Set(TruncatedHeader, "true")
+	}
+	_ = stream.Close()
+	return manifest.Sampling
+}
+
+// reject request with 405 if method is not allowed by lambda (request body is not read)
+func (srv *Server) acceptMethod(req *types.Request, writer http.ResponseWriter, manifest types.Manifest, record *stats.Record) error {
+	err := manifest.AcceptMethod(req.Method)
+	if err == nil {
+		return nil
+	}
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	writer.Header().Set("Allow", strings.Join(append(manifest.AllowedMethods(), http.MethodOptions), ", "))
+	http.Error(writer, err.Error(), http.StatusMethodNotAllowed)
+	return err
+}
+
+// reject request with 415 if Content-Type is not accepted by lambda (request body is not read)
+func (srv *Server) acceptContentType(req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) error {
+	manifest := lambda.Lambda.Effective()
+	err := manifest.AcceptContentType(req)
+	if err == nil {
+		return nil
+	}
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	http.Error(writer, err.Error(), http.StatusUnsupportedMediaType)
+	return err
+}
+
+// lambda is taken offline by administrator (see application.Platform.SetEnabled)
+var errDisabled = errors.New("lambda is disabled")
+
+// reject request (including static files) with 503 and short JSON if lambda is disabled
+func allowEnabled(writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) error {
+	if !lambda.Disabled {
+		return nil
+	}
+	record.End = time.Now()
+	record.Err = errDisabled.Error()
+	record.Rejected = true
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(writer).Encode(map[string]string{"error": errDisabled.Error(), "uid": lambda.UID})
+	return errDisabled
+}
+
+// reject request with 503 if lambda is disabled by alert or circuit is open
+func (srv *Server) allowByAlerts(writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) error {
+	if srv.Alerts == nil {
+		return nil
 	}
-	for k, v := range lambda.Lambda.Manifest().OutputHeaders {
-		writer.Header().Set(k, v)
+	until, err := srv.Alerts.Allow(lambda.UID)
+	if err == nil {
+		return nil
 	}
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	if wait := time.Until(until); wait > 0 {
+		writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+	http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+	return err
+}
 
-	writer.WriteHeader(http.StatusOK)
+// reject request with 413 if declared length of body exceeds maximum payload (or file payload for body spooled or
+// decoded to files) of lambda (request body is not read). Body without length (chunked) is checked while it is
+// streamed to lambda
+func (srv *Server) acceptPayload(req *types.Request, writer http.ResponseWriter, manifest types.Manifest, record *stats.Record) error {
+	return rejectLength(req, writer, manifest.RequestLimit(req.Headers["Content-Type"]), record)
+}
+
+// reject request with 413 if declared length of body exceeds limit (zero - unlimited)
+func rejectLength(req *types.Request, writer http.ResponseWriter, limit int64, record *stats.Record) error {
+	if limit <= 0 {
+		return nil
+	}
+	length, err := strconv.ParseInt(req.Headers["Content-Length"], 10, 64)
+	if err != nil || length <= limit {
+		return nil
+	}
+	err = fmt.Errorf("%w: request of %d bytes exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, length, limit)
+	record.End = time.Now()
+	record.Err = err.Error()
+	record.Rejected = true
+	http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+	return err
+}
 
-	err = srv.Platform.Invoke(ctx, lambda.Lambda, *req, writer)
+// reject request by hook of embedder (after built-in checks)
+func (srv *Server) beforeInvoke(ctx context.Context, req *types.Request, writer http.ResponseWriter, lambda *application.Definition, queue string, record *stats.Record) error {
+	rejection := srv.Hooks.BeforeInvoke(ctx, application.InvokeEvent{
+		UID:      lambda.UID,
+		Queue:    queue,
+		Request:  req,
+		Manifest: lambda.Lambda.Manifest(),
+	})
+	if rejection == nil {
+		return nil
+	}
+	record.End = time.Now()
+	record.Err = rejection.Error()
+	record.Rejected = true
+	if rejection.RetryAfter > 0 {
+		writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rejection.RetryAfter.Seconds()))))
+	}
+	http.Error(writer, rejection.Error(), rejection.StatusCode())
+	return rejection
+}
+
+// apply request mutation of lambda (after access checks), mutated headers are recorded. Responds 400 on failure
+func (srv *Server) mutateRequest(req *types.Request, writer http.ResponseWriter, manifest types.Manifest, record *stats.Record) (*types.Request, error) {
+	if manifest.Mutate == nil {
+		return req, nil
+	}
+	mutated, err := manifest.Mutate.Apply(req, manifest.MaximumPayload)
+	if err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	record.Request.Headers = mutated.Headers
+	return mutated, nil
+}
+
+// invoke lambda once for concurrent identical requests; response is buffered and shared
+// invoke lambda with buffered body: response is served from cache (see Manifest.Cache) or shared with concurrent
+// identical requests (see Manifest.Coalesce)
+func (srv *Server) runBuffered(ctx context.Context, req *types.Request, response *lambdaResponse, lambda *application.Definition, manifest types.Manifest, record *stats.Record) {
+	var input io.Reader = req.Body
+	if manifest.MaximumPayload > 0 {
+		// one byte over the limit is enough to detect overflow
+		input = io.LimitReader(input, manifest.MaximumPayload+1)
+	}
+	body, err := ioutil.ReadAll(input)
+	_ = req.Body.Close()
+	if err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		http.Error(response.writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if manifest.MaximumPayload > 0 && int64(len(body)) > manifest.MaximumPayload {
+		err = fmt.Errorf("%w: request exceeds maximum payload (%d bytes)", application.ErrPayloadTooLarge, manifest.MaximumPayload)
+		record.End = time.Now()
+		record.Err = err.Error()
+		record.Rejected = true
+		http.Error(response.writer, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var cacheKey string
+	revision := lambda.Lambda.Revision() // revision before invocation: response of changed lambda is not cached
+	if manifest.Cache != nil {
+		cacheKey = coalesceKey(lambda.UID, req, manifest.Cache.Vary, body)
+		// response of request with no-cache is refreshed
+		if out, ok := srv.cache.get(lambda.UID, revision, cacheKey); ok && !bypassCache(req) {
+			record.End = time.Now()
+			record.Cached = true
+			response.writer.Header().Set(CacheHeader, "HIT")
+			srv.sendBuffered(req, response, manifest, record, out, nil)
+			return
+		}
+		response.writer.Header().Set(CacheHeader, "MISS")
+	}
+	invoke := func(ctx context.Context, out io.Writer) error {
+		// slot is acquired only by invoking request, waiters of shared response are not limited
+		release, err := srv.acquireSlot(ctx, lambda, manifest, record)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return srv.Platform.Invoke(ctx, lambda.Lambda, *req.WithBody(ioutil.NopCloser(bytes.NewReader(body))), out)
+	}
+	var out []byte
+	var shared bool
+	if manifest.Coalesce != nil && req.Headers[NoCoalesceHeader] == "" {
+		key := coalesceKey(lambda.UID, req, manifest.Coalesce.Headers, body)
+		out, shared, err = srv.flights.do(key, manifest.Coalesce.MaxWaiters, func(out io.Writer) error {
+			// shared invocation is not killed by disconnect of the first client
+			return invoke(context.WithoutCancel(ctx), out)
+		})
+	} else {
+		var buffer bytes.Buffer
+		err = invoke(ctx, &buffer)
+		out = buffer.Bytes()
+	}
+	if errors.Is(err, errConcurrencyLimit) {
+		srv.rejectOverflow(response.writer, record, err)
+		return
+	}
 	record.End = time.Now()
+	record.Coalesced = shared
+	recordUsage(ctx, record)
 	if err != nil {
 		record.Err = err.Error()
 	}
+	if manifest.Cache != nil && cacheable(manifest, out, err) {
+		srv.cache.put(lambda.UID, revision, cacheKey, out, *manifest.Cache)
+	}
+	srv.sendBuffered(req, response, manifest, record, out, err)
+}
+
+// send complete output of invocation: status by exit code (see Manifest.StatusMap), then headers from output.
+// Overrun of maximum response is 502 or, by truncate policy, output cut at the limit with TruncatedHeader
+func (srv *Server) sendBuffered(req *types.Request, response *lambdaResponse, manifest types.Manifest, record *stats.Record, out []byte, err error) {
+	if status, ok := rejectionStatus(err); ok {
+		http.Error(response.writer, err.Error(), status)
+		return
+	}
+	if errors.Is(err, application.ErrResponseTooLarge) {
+		record.Overrun = true
+		if !manifest.TruncateResponse() {
+			http.Error(response.writer, err.Error(), http.StatusBadGateway)
+			return
+		}
+		// truncated output is served, overrun is not a failure of invocation
+		record.Err = ""
+		record.Warning = err.Error()
+		response.writer.Header().Set(TruncatedHeader, "true")
+		err = nil
+	}
+	status := http.StatusOK
+	if err != nil && len(manifest.StatusMap) > 0 {
+		status = exitStatus(manifest.StatusMap, err)
+	}
+	if manifest.ParseHeaders {
+		status, out = response.parseHeaders(out, record, status)
+	}
+	if manifest.RewriteURLs != nil {
+		out = rewriteBody(manifest.RewriteURLs, req.PublicURL, response.writer.Header().Get("Content-Type"), out)
+	}
+	response.send(status, out)
 }
 
-type resourceHandler func(ctx context.Context, req *types.Request, writer http.ResponseWriter, rec *stats.Record, uid string)
+// HTTP status of invocation rejected without response: body over maximum payload (413), malformed form
+// (400), lambda is being built longer than timeout of build gate (503) or lambda is over disk quota (507)
+func rejectionStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, application.ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge, true
+	case errors.Is(err, application.ErrDiskQuota):
+		return http.StatusInsufficientStorage, true
+	case errors.Is(err, application.ErrMalformedForm):
+		return http.StatusBadRequest, true
+	case errors.Is(err, application.ErrBuilding):
+		return http.StatusServiceUnavailable, true
+	}
+	return 0, false
+}
+
+// HTTP status of failed invocation by exit code of process (or code of worker reply), 500 if process is not exited
+// or code is not mapped
+func exitStatus(statuses map[int]int, err error) int {
+	var exitErr interface{ ExitCode() int } // *exec.ExitError or failed reply of worker
+	if errors.As(err, &exitErr) {
+		if status, ok := statuses[exitErr.ExitCode()]; ok {
+			return status
+		}
+	}
+	return http.StatusInternalServerError
+}
 
-func (srv *Server) withRequest(ctx context.Context, next resourceHandler) http.Handler {
+// response header of output cut at maximum response (see Manifest.TruncatePolicy)
+const TruncatedHeader = "X-Truncated"
+
+// copy resource usage of invoked process to record. Shared (coalesced) response has no usage: process is invoked
+// by another request
+func recordUsage(ctx context.Context, record *stats.Record) {
+	if usage := application.UsageFrom(ctx); usage != nil {
+		record.CPU = usage.CPU
+		record.MaxRSS = usage.MaxRSS
+		record.Stderr = usage.Stderr
+		record.Timeout = usage.TimedOut
+		usage.Timing(record)
+	}
+}
+
+// handler for resource, returns sampling configuration for detailed record (nil - keep all)
+type resourceHandler func(ctx context.Context, req *types.Request, writer http.ResponseWriter, rec *stats.Record, uid string) *types.Sampling
+
+func (srv *Server) withRequest(ctx context.Context, records *sampler, trigger string, next resourceHandler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		sections := strings.SplitN(strings.Trim(request.URL.Path, "/"), "/", 2)
 		uid := sections[0]
-		req := types.FromHTTP(request, srv.BehindProxy)
+		body := &countingReader{ReadCloser: request.Body}
+		request.Body = body
+		// output is counted before compression
+		compressed := newCompressWriter(writer, request)
+		output := &countingWriter{ResponseWriter: compressed, prefix: stats.OutputPrefix}
+		req := srv.fromHTTP(request)
+		req.PublicURL = srv.publicURL(request)
+		req.ID = requestID(request)
+		writer.Header().Set(types.RequestIDHeader, req.ID)
 		var record = stats.Record{
 			UID:     uid,
 			Request: *req,
 			Begin:   time.Now(),
+			Trigger: trigger,
 		}
-		next(ctx, req, writer, &record, uid)
-		record.End = time.Now()
-		srv.Tracker.Track(record)
+		// lambda is killed as soon as client is gone
+		invocation, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(request.Context(), cancel)()
+		var sampling *types.Sampling
+		// tracked also if response is aborted (panic by http.ErrAbortHandler)
+		defer func() {
+			record.End = time.Now()
+			record.Payload = body.n
+			record.Size = output.n
+			record.Output = output.head
+			record.Status = output.status
+			record.Write = output.spent
+			srv.observe(record)
+			if records.keep(uid, sampling, &record) {
+				srv.Tracker.Track(record)
+			}
+			if record.Err != "" && !record.Rejected {
+				srv.Hooks.Error(ctx, application.ErrorEvent{UID: uid, Err: errors.New(record.Err)})
+			}
+			srv.Hooks.AfterInvoke(ctx, record)
+		}()
+		sampling = next(invocation, req, output, &record, uid)
+		closing := time.Now()
+		_ = compressed.Close() // the rest of compressed response is written
+		output.spent += time.Since(closing)
 	})
 }
 
+// Track record of invocation made outside of HTTP handlers (ex: execution of queued request): record is counted by
+// metrics, structured log and alert rules and kept by tracker without sampling
+func (srv *Server) Track(record stats.Record) {
+	srv.observe(record)
+	srv.Tracker.Track(record)
+}
+
+// every record (before sampling) for metrics, structured log and alert rules
+func (srv *Server) observe(record stats.Record) {
+	if srv.Metrics != nil {
+		srv.Metrics.Track(record)
+	}
+	if srv.InvocationLog != nil {
+		srv.InvocationLog.Track(record)
+	}
+	if srv.Alerts != nil {
+		srv.Alerts.Track(record)
+	}
+	if srv.Webhooks != nil {
+		srv.Webhooks.Track(record)
+	}
+}
+
+// request ID from header (if valid) or new one
+func requestID(request *http.Request) string {
+	if id := request.Header.Get(types.RequestIDHeader); types.ValidRequestID(id) {
+		return id
+	}
+	return types.NewRequestID("")
+}
+
+// counts read bytes of request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// counts written bytes of response body, keeps prefix of body (zero prefix - not kept) and status of response
+type countingWriter struct {
+	http.ResponseWriter
+	n      int64
+	prefix int
+	head   []byte
+	status int
+	spent  time.Duration // blocked by writes and flushes to client
+}
+
+func (cw *countingWriter) WriteHeader(status int) {
+	if cw.status == 0 && status >= http.StatusOK {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	started := time.Now()
+	n, err := cw.ResponseWriter.Write(p)
+	cw.spent += time.Since(started)
+	if rest := cw.prefix - len(cw.head); rest > 0 {
+		cw.head = append(cw.head, p[:min(rest, n)]...)
+	}
+	cw.n += int64(n)
+	return n, err
+}
+
+// FlushError for http.ResponseController: flush to slow client is part of write time
+func (cw *countingWriter) FlushError() error {
+	started := time.Now()
+	err := http.NewResponseController(cw.ResponseWriter).Flush()
+	cw.spent += time.Since(started)
+	return err
+}
+
+// Unwrap for http.ResponseController
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
 func chooseHandler(dev bool, handler http.Handler) http.Handler {
 	if dev {
 		return openedHandler(handler)
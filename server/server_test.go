@@ -1,27 +1,62 @@
 package server_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/reddec/jsonrpc2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/api/services"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/alerts"
+	"github.com/reddec/trusted-cgi/application/artifact"
+	"github.com/reddec/trusted-cgi/application/backup"
+	"github.com/reddec/trusted-cgi/application/capture"
 	"github.com/reddec/trusted-cgi/application/cases"
 	"github.com/reddec/trusted-cgi/application/platform"
 	"github.com/reddec/trusted-cgi/application/policy"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/secrets"
+	"github.com/reddec/trusted-cgi/application/warmup"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
 	"github.com/reddec/trusted-cgi/server"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/jsonlog"
 	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/stats/impl/prometheus"
 	"github.com/reddec/trusted-cgi/templates"
 	"github.com/reddec/trusted-cgi/types"
 )
@@ -60,34 +95,43 @@ func createTestServer() (*testServer, error) {
 		return inmemory.New(1024), nil
 	}
 
-	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(tmpDir, "queues.json")), basePlatform, queueFactory)
+	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(tmpDir, "queues.json")), basePlatform, queueFactory, queuemanager.Options{})
 	if err != nil {
 		return nil, err
 	}
+	basePlatform.SetQueues(queueManager)
 
 	useCases, err := cases.New(basePlatform, queueManager, policies, tmpDir, filepath.Join(tmpDir, ".templates"))
 	if err != nil {
 		return nil, err
 	}
+	useCases.SetCaptures(capture.New(filepath.Join(tmpDir, ".captures")))
 
 	tracker := memlog.New(1000)
 
-	projectApi := services.NewProjectSrv(useCases, tracker)
-	lambdaApi := services.NewLambdaSrv(useCases, tracker)
-	queuesApi := services.NewQueuesSrv(queueManager)
-	policiesApi := services.NewPoliciesSrv(policies)
-	userApi, err := services.CreateUserSrv(filepath.Join(tmpDir, "server.json"), "admin")
+	alertRules := alerts.New(ctx, basePlatform)
+	projectApi := services.NewProjectSrv(useCases, tracker, alertRules, nil, nil, nil, nil, nil, nil, "")
+	lambdaApi := services.NewLambdaSrv(useCases, tracker, alertRules, nil, nil)
+	queuesApi := services.NewQueuesSrv(queueManager, nil)
+	policiesApi := services.NewPoliciesSrv(policies, nil)
+	userApi, err := services.CreateUserSrv(filepath.Join(tmpDir, "server.json"), "admin", services.DefaultPasswordHashing, nil)
 	if err != nil {
 		return nil, err
 	}
+	backups := backup.New(useCases, policies)
+	backups.Tokens = userApi
+	projectApi.SetBackups(backups)
+	projectApi.SetArtifacts(artifact.New(basePlatform, tmpDir))
 
 	srv := server.Server{
 		Policies:     policies,
 		Platform:     basePlatform,
 		Cases:        useCases,
 		Queues:       queueManager,
+		Alerts:       alertRules,
 		Dev:          true,
 		Tracker:      tracker,
+		Stats:        tracker,
 		TokenHandler: userApi,
 		ProjectAPI:   projectApi,
 		LambdaAPI:    lambdaApi,
@@ -122,6 +166,16 @@ func TestHandlerByUID(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Equal(t, "hello", rr.Body.String())
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 1) {
+		return
+	}
+	assert.Equal(t, int64(5), records[0].Payload)
+	assert.Equal(t, int64(5), records[0].Size)
+	assert.Equal(t, stats.TriggerHTTP, records[0].Source())
+	assert.Equal(t, stats.AliasDirect, records[0].Entry())
 }
 
 func TestHandlerByUID_forbidden(t *testing.T) {
@@ -230,6 +284,39 @@ func TestHandlerByQueue(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, rr.Code)
 }
 
+func TestHandlerByQueue_maxElementSize(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	// paused queue keeps messages
+	require.NoError(t, srv.Server.Queues.Add(application.Queue{Name: "my-queue", MaxElementSize: 8}))
+
+	// chunked body: length is not known in advance
+	put := func(body string, chunked bool) int {
+		var reader io.Reader = strings.NewReader(body)
+		if chunked {
+			reader = struct{ io.Reader }{reader}
+		}
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/q/my-queue", reader)
+		if chunked {
+			req.ContentLength = -1
+		}
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusRequestEntityTooLarge, put("too large body", false))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, put("too large body", true), "body is not truncated")
+	assert.Equal(t, http.StatusNoContent, put("12345678", true))
+
+	status, err := srv.Server.Queues.Status("my-queue")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), status.Depth, "only accepted element is stored")
+}
+
 func TestHandlerByQueue_forbidden(t *testing.T) {
 	ctx := context.Background()
 	srv, err := createTestServer()
@@ -262,3 +349,2618 @@ func TestHandlerByQueue_forbidden(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusForbidden, rr.Code)
 }
+
+func TestHandler_sampling(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	metrics := prometheus.New()
+	metrics.Scheduler = srv.Server.Cases.(prometheus.SchedulerCounters)
+	srv.Server.Metrics = metrics
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:      []string{"cat", "-"},
+			Sampling: &types.Sampling{Rate: 3},
+		},
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 6; i++ {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, 3, record.Weight())
+	}
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/metrics", nil)
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Contains(t, rr.Body.String(), `trusted_cgi_invocations_total{uid="`+uid+`"} 6`)
+	assert.Contains(t, rr.Body.String(), "trusted_cgi_clock_jumps_total 0\n")
+	assert.Contains(t, rr.Body.String(), "trusted_cgi_schedule_reevaluations_total 0\n")
+}
+
+func TestHandler_coalescing(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:      []string{"/bin/sh", "-c", "echo x >> calls; sleep 0.5; cat"},
+			Coalesce: &types.Coalescing{MaxWaiters: 3},
+		},
+	})
+	assert.NoError(t, err)
+
+	invoke := func(bypass bool) string {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		assert.NoError(t, err)
+		if bypass {
+			req.Header.Set(server.NoCoalesceHeader, "1")
+		}
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "hello", invoke(false))
+		}()
+	}
+	wg.Wait()
+	// leader, 3 waiters and one separate invocation (waiters limit)
+	calls, err := ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(calls, []byte("\n")))
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	assert.NoError(t, err)
+	var coalesced int
+	for _, record := range records {
+		if record.Coalesced {
+			coalesced++
+		}
+	}
+	assert.Equal(t, 3, coalesced)
+
+	assert.Equal(t, "hello", invoke(true))
+	calls, err = ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, bytes.Count(calls, []byte("\n")))
+}
+
+func TestHandler_cache(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	// output is number of invocation and body
+	manifest := types.Manifest{
+		Run:   []string{"/bin/sh", "-c", "echo x >> calls; wc -l < calls | tr -d ' '; cat"},
+		Cache: &types.Caching{TTL: types.JsonDuration(time.Hour), MaxEntries: 2, Vary: []string{"X-Lang"}},
+	}
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+	require.NoError(t, err)
+	invoke := func(uid, body string, headers ...string) (string, string) {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		handler.ServeHTTP(rr, req)
+		return rr.Header().Get(server.CacheHeader), rr.Body.String()
+	}
+	assertInvoke := func(cache, out string, body string, headers ...string) {
+		t.Helper()
+		gotCache, gotOut := invoke(uid, body, headers...)
+		assert.Equal(t, cache, gotCache)
+		assert.Equal(t, out, gotOut)
+	}
+
+	assertInvoke("MISS", "1\na", "a")
+	assertInvoke("HIT", "1\na", "a")
+	assertInvoke("MISS", "2\nb", "b")
+	assertInvoke("MISS", "3\na", "a", "X-Lang", "de")
+	// refreshed by client
+	assertInvoke("MISS", "4\na", "a", "Cache-Control", "no-cache")
+	assertInvoke("HIT", "4\na", "a")
+	// the least recently used is evicted
+	assertInvoke("MISS", "5\nb", "b")
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	require.NoError(t, err)
+	var cached int
+	for _, record := range records {
+		if record.Cached {
+			cached++
+		}
+	}
+	assert.Equal(t, 2, cached)
+
+	// changed files
+	def, err := srv.Server.Platform.FindByUID(uid)
+	require.NoError(t, err)
+	require.NoError(t, def.Lambda.WriteFile("data.txt", bytes.NewBufferString("data")))
+	assertInvoke("MISS", "6\na", "a")
+
+	// changed manifest
+	manifest.Cache.TTL = types.JsonDuration(200 * time.Millisecond)
+	require.NoError(t, def.Lambda.SetManifest(manifest))
+	assertInvoke("MISS", "7\na", "a")
+	assertInvoke("HIT", "7\na", "a")
+	time.Sleep(300 * time.Millisecond)
+	assertInvoke("MISS", "8\na", "a")
+
+	// not successful responses are not cached
+	failed, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:          []string{"/bin/sh", "-c", `printf 'Status: 404\r\n\r\nnot found'`},
+		ParseHeaders: true,
+		Cache:        &types.Caching{TTL: types.JsonDuration(time.Hour)},
+	}})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		status, out := invoke(failed, "")
+		assert.Equal(t, "MISS", status)
+		assert.Equal(t, "not found", out)
+	}
+}
+
+func TestHandler_requestID(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run: []string{"/bin/sh", "-c", "printf %s \"$REQUEST_ID\""},
+	}})
+	require.NoError(t, err)
+	invoke := func(id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		if id != "" {
+			req.Header.Set(types.RequestIDHeader, id)
+		}
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr
+	}
+
+	rr := invoke("")
+	generated := rr.Header().Get(types.RequestIDHeader)
+	assert.NotEmpty(t, generated)
+	assert.Equal(t, generated, rr.Body.String())
+
+	rr = invoke("trace-42")
+	assert.Equal(t, "trace-42", rr.Header().Get(types.RequestIDHeader))
+	assert.Equal(t, "trace-42", rr.Body.String())
+
+	// unsafe ID is replaced
+	rr = invoke("bad id")
+	replaced := rr.Header().Get(types.RequestIDHeader)
+	assert.NotEqual(t, "bad id", replaced)
+	assert.Equal(t, replaced, rr.Body.String())
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	require.NoError(t, err)
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.Request.ID)
+	}
+	assert.ElementsMatch(t, []string{generated, "trace-42", replaced}, ids)
+}
+
+func TestHandler_async(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.Async, err = server.NewAsyncQueue(server.AsyncConfig{Depth: 1, TTL: time.Minute})
+	require.NoError(t, err)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:     []string{"/bin/sh", "-c", "sleep 0.3; printf 'done %s' \"$(cat)\""},
+		Aliases: []string{"slow"},
+	}})
+	require.NoError(t, err)
+	submit := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com"+types.AsyncPath(path), bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	result := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+		require.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := submit(types.LinkPath("slow"))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	var ticket types.AsyncTicket
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ticket))
+	assert.Equal(t, types.ResultPath(ticket.Ticket), ticket.Result)
+	assert.Equal(t, ticket.Result, rr.Header().Get("Location"))
+	requestID := rr.Header().Get(types.RequestIDHeader)
+	assert.NotEmpty(t, requestID)
+
+	// the first is invoked, the second is pending, the third is over depth
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusAccepted, submit(types.LambdaPath(uid)).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, submit(types.LambdaPath(uid)).Code)
+
+	assert.Equal(t, http.StatusNotFound, result(ticket.Result).Code)
+	assert.Eventually(t, func() bool {
+		rr = result(ticket.Result)
+		return rr.Code != http.StatusNotFound
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "done hello", rr.Body.String())
+	assert.Equal(t, requestID, rr.Header().Get(types.RequestIDHeader))
+
+	assert.Equal(t, http.StatusNotFound, result(types.ResultPath("unknown")).Code)
+	assert.Equal(t, http.StatusNotFound, submit(types.LambdaPath("unknown")).Code)
+	assert.Equal(t, http.StatusNotFound, submit(types.QueuePath("unknown")).Code)
+}
+
+func TestHandler_asyncRestore(t *testing.T) {
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	dir := filepath.Join(srv.Dir, ".async")
+	config := server.AsyncConfig{Depth: 5, Dir: dir}
+	uid, err := srv.Server.Cases.CreateFromTemplate(context.Background(), templates.Template{Manifest: types.Manifest{
+		Run: []string{"/bin/sh", "-c", "sleep 0.3; cat"},
+	}})
+	require.NoError(t, err)
+	request := func(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, "https://example.com"+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	submit := func(handler http.Handler, body string) types.AsyncTicket {
+		rr := request(handler, http.MethodPost, types.AsyncPath(types.LambdaPath(uid)), body)
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		var ticket types.AsyncTicket
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ticket))
+		return ticket
+	}
+
+	// the first is in-flight, the second is pending when server is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.Server.Async, err = server.NewAsyncQueue(config)
+	require.NoError(t, err)
+	handler := srv.Server.Handler(ctx)
+	first, second := submit(handler, "first"), submit(handler, "second")
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	time.Sleep(300 * time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "garbage.json"), []byte("{}"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, uuid.New().String()+".json"), []byte("{"), 0600))
+
+	restored, err := server.NewAsyncQueue(config)
+	require.NoError(t, err)
+	pending := restored.Pending()
+	require.Len(t, pending, 2)
+	assert.Equal(t, first.Ticket, pending[0].Ticket)
+	assert.Equal(t, "/a/"+uid, pending[0].Path)
+	assert.Equal(t, int64(5), pending[0].Size)
+	assert.Equal(t, second.Ticket, pending[1].Ticket)
+	corrupted, err := ioutil.ReadDir(filepath.Join(dir, "corrupted"))
+	require.NoError(t, err)
+	assert.Len(t, corrupted, 2)
+	purged, err := restored.Purge([]string{second.Ticket})
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	srv.Server.Async = restored
+	handler = srv.Server.Handler(ctx)
+	var rr *httptest.ResponseRecorder
+	assert.Eventually(t, func() bool {
+		rr = request(handler, http.MethodGet, first.Result, "")
+		return rr.Code != http.StatusNotFound
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "first", rr.Body.String())
+	rr = request(handler, http.MethodGet, second.Result, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Header().Get("Retry-After"))
+	assert.Empty(t, restored.Pending())
+	files, err := filepath.Glob(filepath.Join(dir, "*.*"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestHandler_stderr(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run: []string{"/bin/sh", "-c", "echo debug >&2; echo result"},
+	}})
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+	require.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "result\n", rr.Body.String())
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "debug\n", string(records[0].Stderr))
+
+	// failed post-clone is explained by stderr
+	_, err = srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest:  types.Manifest{Run: []string{"cat"}},
+		PostClone: "missing",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stderr: make: *** No rule to make target")
+}
+
+func TestHandler_invocationLog(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	var out bytes.Buffer
+	srv.Server.InvocationLog = jsonlog.New(&out)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	for _, target := range []string{uid, "missing"} {
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+target, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		req.Header.Set(types.RequestIDHeader, "trace-"+target)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var lines []jsonlog.Invocation
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var line jsonlog.Invocation
+		require.NoError(t, decoder.Decode(&line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, jsonlog.TypeInvocation, lines[0].Type)
+	assert.Equal(t, uid, lines[0].UID)
+	assert.Equal(t, "trace-"+uid, lines[0].RequestID)
+	assert.Equal(t, http.StatusOK, lines[0].Status)
+	assert.Equal(t, jsonlog.ResultOK, lines[0].Result)
+	assert.Equal(t, int64(5), lines[0].Payload)
+	assert.Equal(t, int64(5), lines[0].Size)
+	assert.Equal(t, "missing", lines[1].UID)
+	assert.Equal(t, http.StatusNotFound, lines[1].Status)
+	assert.Equal(t, jsonlog.ResultError, lines[1].Result)
+}
+
+func TestHandler_rateLimit(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	invoke := func(uid, address string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		req.RemoteAddr = address
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	shared, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:       []string{"/bin/cat"},
+		RateLimit: &types.RateLimit{RPS: 0.1, Burst: 2},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, invoke(shared, "10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusOK, invoke(shared, "10.0.0.2:1000").Code)
+	rr := invoke(shared, "10.0.0.3:1000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	retry, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.True(t, retry > 0 && retry <= 10, retry)
+
+	perClient, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:       []string{"/bin/cat"},
+		RateLimit: &types.RateLimit{RPS: 0.1, PerClient: true},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, invoke(perClient, "10.0.0.1:1000").Code)
+	// the same client from another port
+	assert.Equal(t, http.StatusTooManyRequests, invoke(perClient, "10.0.0.1:2000").Code)
+	assert.Equal(t, http.StatusOK, invoke(perClient, "10.0.0.2:1000").Code)
+
+	// refilled
+	fast, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:       []string{"/bin/cat"},
+		RateLimit: &types.RateLimit{RPS: 2, Burst: 1},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, invoke(fast, "10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, invoke(fast, "10.0.0.1:1000").Code)
+	time.Sleep(600 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, invoke(fast, "10.0.0.1:1000").Code)
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(shared, 100)
+	require.NoError(t, err)
+	var throttled int
+	for _, record := range records {
+		if record.Throttled {
+			assert.True(t, record.Rejected)
+			throttled++
+		}
+	}
+	assert.Equal(t, 1, throttled)
+}
+
+func TestHandler_rateLimitBehindProxy(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.BehindProxy = true
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:       []string{"/bin/cat"},
+		RateLimit: &types.RateLimit{RPS: 0.1, PerClient: true},
+	}})
+	require.NoError(t, err)
+	invoke := func(forwarded string) int {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		req.RemoteAddr = "127.0.0.1:1000" // proxy
+		req.Header.Set("X-Forwarded-For", forwarded)
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, invoke("10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, invoke("10.0.0.1, 127.0.0.1"))
+	assert.Equal(t, http.StatusOK, invoke("10.0.0.2"))
+}
+
+func TestHandler_clientAddress(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.TrustedProxies, err = types.ParseNetworks([]string{"127.0.0.1", "10.0.0.0/8"})
+	require.NoError(t, err)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run: []string{"/bin/sh", "-c", `printf "$REMOTE_ADDR"`},
+	}})
+	require.NoError(t, err)
+	invoke := func(peer string, headers ...string) string {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString(""))
+		require.NoError(t, err)
+		req.RemoteAddr = peer
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Add(headers[i], headers[i+1])
+		}
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+	assert.Equal(t, "203.0.113.9", invoke("203.0.113.9:1000"))
+	// spoofing by untrusted peer: all forwarded headers are ignored
+	assert.Equal(t, "203.0.113.9", invoke("203.0.113.9:1000", "X-Forwarded-For", "10.1.1.1"))
+	assert.Equal(t, "203.0.113.9", invoke("203.0.113.9:1000", "X-Real-Ip", "10.1.1.1"))
+	assert.Equal(t, "203.0.113.9", invoke("203.0.113.9:1000", "Forwarded", "for=10.1.1.1"))
+	// rightmost untrusted hop, spoofed hops added by client are ignored
+	assert.Equal(t, "198.51.100.7", invoke("127.0.0.1:1000", "X-Forwarded-For", "198.51.100.7, 10.0.0.2"))
+	assert.Equal(t, "198.51.100.7", invoke("127.0.0.1:1000", "X-Forwarded-For", "10.1.1.1, 198.51.100.7"))
+	// spoofing by client behind proxy which sets only X-Forwarded-For (ex: nginx): other headers are passed as is
+	assert.Equal(t, "198.51.100.7", invoke("127.0.0.1:1000", "X-Real-Ip", "10.1.1.1", "Forwarded", "for=10.1.1.1", "X-Forwarded-For", "198.51.100.7"))
+	assert.Equal(t, "127.0.0.1", invoke("127.0.0.1:1000", "X-Real-Ip", "10.1.1.1"))
+	assert.Equal(t, "127.0.0.1", invoke("127.0.0.1:1000", "X-Forwarded-For", "10.1.1.1, 198.51.100.7:80"), "not an IP")
+
+	// only Forwarded is set by proxy: X-Real-Ip of client is not respected
+	srv.Server.ForwardedBy = []string{types.HeaderForwarded}
+	assert.Equal(t, "2001:db8::7", invoke("127.0.0.1:1000", "Forwarded", `for=1.1.1.1, for="[2001:db8::7]:4711";proto=https, for=10.0.0.2`))
+	assert.Equal(t, "198.51.100.8", invoke("127.0.0.1:1000", "X-Real-Ip", "10.1.1.1", "Forwarded", "for=198.51.100.8"))
+	assert.Equal(t, "127.0.0.1", invoke("127.0.0.1:1000", "X-Real-Ip", "10.1.1.1"))
+
+	// lockouts see the same address
+	srv.Server.ForwardedBy = nil
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/u/", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"UserAPI.Login","params":["admin","wrong"]}`))
+	require.NoError(t, err)
+	req.RemoteAddr = "127.0.0.1:1000"
+	req.Header.Set("X-Forwarded-For", "10.1.1.1, 198.51.100.7")
+	handler.ServeHTTP(rr, req)
+	token, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	lockouts, err := srv.Server.UserAPI.Lockouts(ctx, token)
+	require.NoError(t, err)
+	var subjects []string
+	for _, lockout := range lockouts {
+		subjects = append(subjects, lockout.Subject)
+	}
+	assert.Contains(t, subjects, "198.51.100.7")
+}
+
+func TestHandler_allowedNetworks(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.TrustedProxies, err = types.ParseNetworks([]string{"127.0.0.1"})
+	require.NoError(t, err)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:             []string{"/bin/cat"},
+		AllowedNetworks: []string{"10.0.0.0/8", "2001:db8::/32"},
+	}})
+	require.NoError(t, err)
+	invoke := func(peer string, forwarded string) int {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		req.RemoteAddr = peer
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, invoke("10.1.2.3:1000", ""))
+	assert.Equal(t, http.StatusOK, invoke("[2001:db8::1]:1000", ""))
+	assert.Equal(t, http.StatusForbidden, invoke("192.168.1.1:1000", ""))
+	// forwarded by trusted proxy
+	assert.Equal(t, http.StatusOK, invoke("127.0.0.1:1000", "10.0.0.1"))
+	assert.Equal(t, http.StatusForbidden, invoke("127.0.0.1:1000", "10.0.0.1, 192.168.1.1"))
+	// forwarded headers of other peers are ignored
+	assert.Equal(t, http.StatusForbidden, invoke("192.168.1.1:1000", "10.0.0.1"))
+
+	// policy narrows manifest
+	_, err = srv.Server.Policies.Create("internal", application.PolicyDefinition{Public: true, AllowedNetworks: []string{"10.0.0.0/16"}})
+	require.NoError(t, err)
+	require.NoError(t, srv.Server.Policies.Apply(uid, "internal"))
+	assert.Equal(t, http.StatusOK, invoke("10.0.1.1:1000", ""))
+	assert.Equal(t, http.StatusForbidden, invoke("10.1.1.1:1000", ""))
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	require.NoError(t, err)
+	var denied int
+	for _, record := range records {
+		if record.Denied {
+			assert.True(t, record.Rejected)
+			denied++
+		}
+	}
+	assert.Equal(t, 4, denied)
+}
+
+func TestHandler_aliasPolicies(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	hash, err := types.HashPassword("secret")
+	require.NoError(t, err)
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:             []string{"/bin/sh", "-c", `printf %s "$REMOTE_USER"`},
+		Aliases:         []string{"internal-report", "public-report"},
+		AllowedNetworks: []string{"10.0.0.0/8"},
+		AliasPolicies: map[string]*types.AliasPolicy{
+			"public-report": {
+				Unset:     []string{types.AliasSettingNetworks},
+				Methods:   []string{"GET"},
+				BasicAuth: &types.BasicAuth{Users: map[string]string{"customer": hash}},
+				RateLimit: &types.RateLimit{RPS: 0.001, Burst: 1},
+			},
+		},
+	}})
+	require.NoError(t, err)
+	invoke := func(method, path, peer, username string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, "https://example.com"+path, http.NoBody)
+		require.NoError(t, err)
+		req.RemoteAddr = peer
+		if username != "" {
+			req.SetBasicAuth(username, "secret")
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	// settings of lambda by UID and by alias without policy
+	assert.Equal(t, http.StatusOK, invoke(http.MethodPost, "/a/"+uid, "10.1.2.3:1000", "").Code)
+	assert.Equal(t, http.StatusForbidden, invoke(http.MethodPost, "/a/"+uid, "192.0.2.1:1000", "").Code)
+	assert.Equal(t, http.StatusOK, invoke(http.MethodPost, "/l/internal-report", "10.1.2.3:1000", "").Code)
+	assert.Equal(t, http.StatusForbidden, invoke(http.MethodGet, "/l/internal-report", "192.0.2.1:1000", "customer").Code)
+
+	// policy of alias
+	assert.Equal(t, http.StatusUnauthorized, invoke(http.MethodGet, "/l/public-report", "192.0.2.1:1000", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, invoke(http.MethodPost, "/l/public-report", "192.0.2.1:1000", "customer").Code)
+	rr := invoke(http.MethodGet, "/l/public-report", "192.0.2.1:1000", "customer")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "customer", rr.Body.String())
+	assert.Equal(t, http.StatusTooManyRequests, invoke(http.MethodGet, "/l/public-report", "192.0.2.1:1000", "customer").Code)
+	// rate limit of alias does not limit lambda
+	assert.Equal(t, http.StatusOK, invoke(http.MethodPost, "/a/"+uid, "10.1.2.3:1000", "").Code)
+
+	info, err := srv.Server.LambdaAPI.Info(ctx, nil, uid)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"public-report": {"allowed_networks", "methods", "basic_auth", "rate_limit"}}, info.AliasOverrides)
+	// policy of released alias is not in effect
+	_, err = srv.Server.Platform.Unlink("public-report")
+	require.NoError(t, err)
+	info, err = srv.Server.LambdaAPI.Info(ctx, nil, uid)
+	require.NoError(t, err)
+	assert.Empty(t, info.AliasOverrides)
+}
+
+func TestHandler_webhookSignature(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:              []string{"/bin/cat"},
+		Environment:      map[string]string{"HOOK_SECRET": "top-secret"},
+		WebhookSignature: &types.WebhookSignature{Preset: types.WebhookGitHub, Secret: "${HOOK_SECRET}"},
+	}})
+	require.NoError(t, err)
+	unset, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:              []string{"/bin/cat"},
+		WebhookSignature: &types.WebhookSignature{Preset: types.WebhookGitHub, Secret: "${UNKNOWN_SECRET}"},
+	}})
+	require.NoError(t, err)
+	invoke := func(uid string, body string, secret string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := invoke(uid, "hello", "top-secret")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "hello", rr.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, invoke(uid, "hello", "other-secret").Code)
+	assert.Equal(t, http.StatusInternalServerError, invoke(unset, "hello", "").Code)
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Rejected)
+}
+
+type pingedDeploys struct {
+	lock      sync.Mutex
+	triggered []string
+}
+
+func (pd *pingedDeploys) Sync(ctx context.Context, uid string, force bool) (*application.GitStatus, error) {
+	return nil, application.ErrNotTracked
+}
+
+func (pd *pingedDeploys) Status(uid string) *application.GitStatus { return nil }
+
+func (pd *pingedDeploys) Trigger(uid string) {
+	pd.lock.Lock()
+	defer pd.lock.Unlock()
+	pd.triggered = append(pd.triggered, uid)
+}
+
+func TestHandler_gitPing(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	deploys := &pingedDeploys{}
+	srv.Server.GitDeploys = deploys
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:         []string{"/bin/cat"},
+		Environment: map[string]string{"PING_SECRET": "top-secret"},
+		Git: &types.GitSource{Repo: "https://example.com/fn.git", Ping: &types.WebhookSignature{
+			Preset: types.WebhookGitHub, Secret: "${PING_SECRET}",
+		}},
+	}})
+	require.NoError(t, err)
+	untracked, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	ping := func(method string, uid string, secret string) int {
+		rr := httptest.NewRecorder()
+		body := `{"ref":"refs/heads/main"}`
+		req, err := http.NewRequest(method, "https://example.com"+types.GitPath(uid), bytes.NewBufferString(body))
+		require.NoError(t, err)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusAccepted, ping(http.MethodPost, uid, "top-secret"))
+	assert.Equal(t, http.StatusUnauthorized, ping(http.MethodPost, uid, "other-secret"))
+	assert.Equal(t, http.StatusMethodNotAllowed, ping(http.MethodGet, uid, "top-secret"))
+	assert.Equal(t, http.StatusNotFound, ping(http.MethodPost, untracked, "top-secret"))
+	assert.Equal(t, []string{uid}, deploys.triggered)
+}
+
+func TestHandler_basicAuth(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run: []string{"/bin/sh", "-c", `printf %s "$REMOTE_USER"`},
+	}})
+	require.NoError(t, err)
+	users, err := srv.Server.LambdaAPI.SetBasicAuth(ctx, nil, uid, api.Passwords{Users: map[string]string{"alice": "secret"}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, users)
+	info, err := srv.Server.LambdaAPI.Info(ctx, nil, uid)
+	require.NoError(t, err)
+	require.NotNil(t, info.Manifest.BasicAuth)
+	assert.NotEqual(t, "secret", info.Manifest.BasicAuth.Users["alice"])
+
+	invoke := func(path string, username, password string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com"+path, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := invoke("/a/"+uid, "", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `Basic realm="trusted-cgi", charset="UTF-8"`, rr.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, invoke("/a/"+uid, "alice", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, invoke("/a/"+uid, "bob", "secret").Code)
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Rejected)
+
+	rr = invoke("/a/"+uid, "alice", "secret")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "alice", rr.Body.String())
+
+	// protect only public link
+	_, err = srv.Server.Platform.Link(uid, "public")
+	require.NoError(t, err)
+	manifest := info.Manifest
+	manifest.BasicAuth.Links = []string{"public"}
+	_, err = srv.Server.LambdaAPI.Update(ctx, nil, uid, manifest, false)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, invoke("/l/public", "", "").Code)
+	rr = invoke("/a/"+uid, "", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "", rr.Body.String())
+
+	users, err = srv.Server.LambdaAPI.SetBasicAuth(ctx, nil, uid, api.Passwords{}, []string{"alice"})
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	assert.Equal(t, http.StatusOK, invoke("/l/public", "", "").Code)
+}
+
+func TestHandler_jwt(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+	now := time.Now()
+
+	invoke := func(uid string, token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		req.Header.Set("X-User-Email", "spoofed@example.com")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("secret", func(t *testing.T) {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+			Run:          []string{"/bin/sh", "-c", `printf '%s %s %s' "$REMOTE_USER" "$ROLE" "$EMAIL"`},
+			Environment:  map[string]string{"JWT_SECRET": "top-secret"},
+			InputHeaders: map[string]string{"X-User-Email": "EMAIL"},
+			JWT: &types.JWTPolicy{
+				Secret:    "${JWT_SECRET}",
+				Issuers:   []string{"https://sso.example.com"},
+				Audiences: []string{"cgi"},
+				Leeway:    types.JsonDuration(10 * time.Second),
+				Env:       map[string]string{"role": "ROLE"},
+				Headers:   map[string]string{"email": "X-User-Email"},
+			},
+		}})
+		require.NoError(t, err)
+		sign := func(claims jwt.MapClaims, secret string) string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+			require.NoError(t, err)
+			return token
+		}
+		claims := jwt.MapClaims{"sub": "alice", "iss": "https://sso.example.com", "aud": "cgi", "role": "admin", "email": "alice@example.com", "exp": now.Add(time.Minute).Unix()}
+
+		rr := invoke(uid, sign(claims, "top-secret"))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "alice admin alice@example.com", rr.Body.String())
+
+		rr = invoke(uid, "")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rr.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, http.StatusUnauthorized, invoke(uid, sign(claims, "other-secret")).Code)
+		records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.True(t, records[0].Rejected)
+
+		// missing claim removes header of client
+		delete(claims, "email")
+		rr = invoke(uid, sign(claims, "top-secret"))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "alice admin ", rr.Body.String())
+
+		claims["exp"] = now.Add(-5 * time.Second).Unix()
+		assert.Equal(t, http.StatusOK, invoke(uid, sign(claims, "top-secret")).Code, "in leeway")
+		claims["exp"] = now.Add(-time.Minute).Unix()
+		assert.Equal(t, http.StatusUnauthorized, invoke(uid, sign(claims, "top-secret")).Code, "expired")
+		claims["exp"] = now.Add(time.Minute).Unix()
+		claims["nbf"] = now.Add(time.Minute).Unix()
+		assert.Equal(t, http.StatusUnauthorized, invoke(uid, sign(claims, "top-secret")).Code, "not valid yet")
+	})
+	t.Run("public key", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+			Run: []string{"/bin/sh", "-c", `printf %s "$REMOTE_USER"`},
+			JWT: &types.JWTPolicy{PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+		}})
+		require.NoError(t, err)
+		token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "bob"}).SignedString(key)
+		require.NoError(t, err)
+		rr := invoke(uid, token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "bob", rr.Body.String())
+
+		// public key as HMAC secret
+		confused, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "bob"}).SignedString(der)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, invoke(uid, confused).Code)
+	})
+	t.Run("jwks", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		var fetches int32
+		jwks := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			atomic.AddInt32(&fetches, 1)
+			_ = json.NewEncoder(writer).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		}))
+		defer jwks.Close()
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+			Run: []string{"/bin/sh", "-c", `printf %s "$REMOTE_USER"`},
+			JWT: &types.JWTPolicy{JWKS: jwks.URL},
+		}})
+		require.NoError(t, err)
+		sign := func(kid string) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "carol", "exp": now.Add(time.Minute).Unix()})
+			token.Header["kid"] = kid
+			signed, err := token.SignedString(key)
+			require.NoError(t, err)
+			return signed
+		}
+		rr := invoke(uid, sign("k1"))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "carol", rr.Body.String())
+		assert.Equal(t, http.StatusOK, invoke(uid, sign("k1")).Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "cached")
+		// unknown key does not fetch set again within retry interval
+		assert.Equal(t, http.StatusUnauthorized, invoke(uid, sign("k2")).Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+}
+
+func TestHandler_secrets(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	_, err = srv.Server.ProjectAPI.SetSecret(ctx, nil, "stripe_key", "sk_live_123")
+	assert.Error(t, err, "store is disabled")
+	store, err := secrets.Open(filepath.Join(srv.Dir, ".secrets.json"), []byte("passphrase"))
+	require.NoError(t, err)
+	srv.Server.Platform.SetSecrets(store)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:         []string{"/bin/sh", "-c", `printf %s "$API_KEY|$AUTH"`},
+		Environment: map[string]string{"API_KEY": "@secret:stripe_key", "AUTH": "Bearer ${API_KEY}"},
+	}})
+	require.NoError(t, err)
+	secret, err := srv.Server.ProjectAPI.SetSecret(ctx, nil, "stripe_key", "sk_${live}_123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{uid}, secret.Lambdas)
+
+	invoke := func() string {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+	assert.Equal(t, "sk_${live}_123|Bearer sk_${live}_123", invoke(), "value of secret is not expanded")
+
+	list, err := srv.Server.ProjectAPI.Secrets(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	data, err := json.Marshal(list)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk_")
+	info, err := srv.Server.LambdaAPI.Info(ctx, nil, uid)
+	require.NoError(t, err)
+	assert.Equal(t, "@secret:stripe_key", info.Manifest.Environment["API_KEY"])
+
+	removed, err := srv.Server.ProjectAPI.RemoveSecret(ctx, nil, "stripe_key")
+	require.NoError(t, err)
+	assert.Equal(t, []string{uid}, removed.Lambdas, "still referenced")
+	assert.Equal(t, "|Bearer ", invoke())
+	_, err = srv.Server.ProjectAPI.RemoveSecret(ctx, nil, "stripe_key")
+	assert.Error(t, err)
+}
+
+func TestHandler_concurrency(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	create := func(policy string) string {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				Run:            []string{"/bin/sh", "-c", "sleep 0.3; echo ok"},
+				MaxConcurrency: 1,
+				OverflowPolicy: policy,
+			},
+		})
+		assert.NoError(t, err)
+		return uid
+	}
+	invoke := func(uid string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, http.NoBody)
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	waiting := create("")
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, invoke(waiting).Code)
+		}()
+	}
+	wg.Wait()
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(waiting, 100)
+	assert.NoError(t, err)
+	var waited int
+	for _, record := range records {
+		if record.Wait >= 200*time.Millisecond {
+			waited++
+		}
+	}
+	assert.Equal(t, 2, waited, "requests are invoked one by one")
+
+	rejecting := create(types.OverflowReject)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, http.StatusOK, invoke(rejecting).Code)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	rr := invoke(rejecting)
+	wg.Wait()
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, invoke(rejecting).Code, "slot is released")
+}
+
+func TestHandler_acceptedContentTypes(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	metrics := prometheus.New()
+	srv.Server.Metrics = metrics
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:                  []string{"/bin/sh", "-c", "echo call >> calls; cat -"},
+			AcceptedContentTypes: []string{"application/json", "text/*"},
+		},
+	})
+	assert.NoError(t, err)
+
+	invoke := func(method string, contentType string, body string) int {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, "https://example.com/a/"+uid, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, invoke(http.MethodPost, "application/json; charset=utf-8", "{}"))
+	assert.Equal(t, http.StatusOK, invoke(http.MethodPost, "Text/Plain", "hello"))
+	assert.Equal(t, http.StatusOK, invoke(http.MethodGet, "", ""), "GET without body should not be checked")
+	assert.Equal(t, http.StatusUnsupportedMediaType, invoke(http.MethodPost, "multipart/form-data; boundary=x", "--x--"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, invoke(http.MethodPost, "", "hello"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, invoke(http.MethodGet, "", "hello"), "GET with body should be checked")
+
+	calls, err := ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, bytes.Count(calls, []byte("\n")), "rejected requests should not invoke lambda")
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 100)
+	assert.NoError(t, err)
+	var rejected int
+	for _, record := range records {
+		if record.Rejected {
+			rejected++
+		}
+	}
+	assert.Equal(t, 3, rejected)
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/metrics", nil)
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Contains(t, rr.Body.String(), `trusted_cgi_rejections_total{uid="`+uid+`"} 3`)
+}
+
+func TestHandler_allowedMethods(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:     []string{"/bin/sh", "-c", "echo call >> calls"},
+			Methods: []string{"post", "GET"},
+		},
+	})
+	assert.NoError(t, err)
+
+	invoke := func(method string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, "https://example.com/a/"+uid, http.NoBody)
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, invoke(http.MethodPost).Code)
+	assert.Equal(t, http.StatusOK, invoke(http.MethodGet).Code)
+	assert.Equal(t, http.StatusOK, invoke(http.MethodHead).Code, "HEAD is allowed with GET")
+	assert.Equal(t, http.StatusNoContent, invoke(http.MethodOptions).Code, "preflight is answered by server")
+	rr := invoke(http.MethodDelete)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", rr.Header().Get("Allow"))
+
+	calls, err := ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, bytes.Count(calls, []byte("\n")), "rejected requests should not invoke lambda")
+}
+
+func TestHandler_cors(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:     []string{"/bin/sh", "-c", "echo call >> calls"},
+			Methods: []string{"POST"},
+			CORS: &types.CORS{
+				Origins:     []string{"https://app.example.com"},
+				Headers:     []string{"Content-Type", "X-Token"},
+				Credentials: true,
+				MaxAge:      types.JsonDuration(10 * time.Minute),
+			},
+		},
+	})
+	assert.NoError(t, err)
+	opened, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{Run: []string{"echo"}},
+	})
+	assert.NoError(t, err)
+
+	invoke := func(method, uid, origin string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, "https://example.com/a/"+uid, http.NoBody)
+		assert.NoError(t, err)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := invoke(http.MethodOptions, uid, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "POST", rr.Header().Get("Access-Control-Allow-Methods"), "allowed methods of lambda")
+	assert.Equal(t, "Content-Type, X-Token", rr.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rr.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+
+	rr = invoke(http.MethodOptions, uid, "https://evil.example.com")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"), "origin is not allowed")
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Methods"))
+
+	rr = invoke(http.MethodPost, uid, "https://app.example.com")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Methods"), "preflight headers only for preflight")
+
+	rr = invoke(http.MethodPost, uid, "https://evil.example.com")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+
+	calls, err := ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(calls, []byte("\n")), "preflight should not invoke lambda")
+
+	// lambda without policy keeps default behaviour
+	rr = invoke(http.MethodOptions, opened, "https://evil.example.com")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestHandler_expectContinue(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	const limit = 1024
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:            []string{"cat", "-"},
+			Methods:        []string{http.MethodPost},
+			MaximumPayload: limit,
+			DecodeForm:     true,
+		},
+	})
+	require.NoError(t, err)
+
+	// headers of request with Expect: 100-continue, returns the first status line
+	send := func(method string, headers string) (net.Conn, *bufio.Reader, string) {
+		conn, err := net.Dial("tcp", httpServer.Listener.Addr().String())
+		require.NoError(t, err)
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		_, err = fmt.Fprintf(conn, "%s /a/%s HTTP/1.1\r\nHost: example.com\r\nExpect: 100-continue\r\n%s\r\n", method, uid, headers)
+		require.NoError(t, err)
+		reader := bufio.NewReader(conn)
+		status, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		return conn, reader, strings.TrimSpace(status)
+	}
+	// send chunked body after 100 Continue and read final response
+	finish := func(conn net.Conn, reader *bufio.Reader, body string) (int, string) {
+		_, err := reader.ReadString('\n') // end of interim response
+		require.NoError(t, err)
+		_, err = fmt.Fprintf(conn, "%x\r\n%s\r\n0\r\n\r\n", len(body), body)
+		require.NoError(t, err)
+		res, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(data)
+	}
+
+	conn, _, status := send(http.MethodPost, "Content-Type: text/plain\r\nContent-Length: 2048\r\n")
+	_ = conn.Close()
+	assert.Equal(t, "HTTP/1.1 413 Request Entity Too Large", status, "declared length is rejected without 100")
+
+	conn, _, status = send(http.MethodPut, "Content-Type: text/plain\r\nTransfer-Encoding: chunked\r\n")
+	_ = conn.Close()
+	assert.Equal(t, "HTTP/1.1 405 Method Not Allowed", status, "rejected request doesn't get 100")
+
+	conn, reader, status := send(http.MethodPost, "Content-Type: text/plain\r\nTransfer-Encoding: chunked\r\n")
+	require.Equal(t, "HTTP/1.1 100 Continue", status)
+	code, out := finish(conn, reader, "hello")
+	_ = conn.Close()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "hello", out)
+
+	conn, reader, status = send(http.MethodPost, "Content-Type: text/plain\r\nTransfer-Encoding: chunked\r\n")
+	require.Equal(t, "HTTP/1.1 100 Continue", status)
+	code, _ = finish(conn, reader, strings.Repeat("x", limit+1))
+	_ = conn.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	// chunked form is passed to lambda (not consumed by parsing of request)
+	conn, reader, status = send(http.MethodPost, "Content-Type: application/x-www-form-urlencoded\r\nTransfer-Encoding: chunked\r\n")
+	require.Equal(t, "HTTP/1.1 100 Continue", status)
+	code, out = finish(conn, reader, "a=1&b=x+y")
+	_ = conn.Close()
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"a":"1","b":"x y"}`, out)
+
+	conn, reader, status = send(http.MethodPost, "Content-Type: application/x-www-form-urlencoded\r\nTransfer-Encoding: chunked\r\n")
+	require.Equal(t, "HTTP/1.1 100 Continue", status)
+	code, _ = finish(conn, reader, "a="+strings.Repeat("x", limit))
+	_ = conn.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+}
+
+func TestHandler_inputSchema(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:            []string{"/bin/sh", "-c", "cat >> calls; echo >> calls"},
+			MaximumPayload: 64,
+			InputSchema:    []byte(`{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`),
+		},
+	})
+	assert.NoError(t, err)
+
+	invoke := func(contentType, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, invoke("application/json", `{"name": "reddec"}`).Code)
+	rr := invoke("application/json", `{"name": 42}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var problems []types.PayloadError
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problems))
+	assert.Equal(t, []types.PayloadError{{Path: "/name", Message: "expected string, but got number"}}, problems)
+	assert.Equal(t, http.StatusUnprocessableEntity, invoke("application/json", `{"name": `).Code)
+	assert.Equal(t, http.StatusOK, invoke("text/plain", `{"name": 42}`).Code, "other content types are not validated")
+	rr = invoke("application/json", `{"name": "`+strings.Repeat("x", 64)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "maximum payload is checked first")
+
+	calls, err := ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"name\": \"reddec\"}\n{\"name\": 42}\n", string(calls), "invalid requests should not invoke lambda")
+}
+
+type staticMirror struct {
+	primary string
+}
+
+func (sm *staticMirror) Primary() string { return sm.primary }
+
+func (sm *staticMirror) Status() application.MirrorStatus {
+	return application.MirrorStatus{Enabled: sm.primary != "", Primary: sm.primary}
+}
+
+func (sm *staticMirror) Promote() (application.MirrorStatus, error) {
+	sm.primary = ""
+	return sm.Status(), nil
+}
+
+func TestHandler_readOnly(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	mirror := &staticMirror{primary: "https://primary.example.com/"}
+	srv.Server.Mirror = mirror
+	handler := srv.Server.Handler(ctx)
+
+	call := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/u/", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call(`{"jsonrpc":"2.0","id":1,"method":"UserAPI.Login","params":["admin","admin"]}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"result"`)
+
+	rr = call(`{"jsonrpc":"2.0","id":2,"method":"ProjectAPI.Create","params":[""]}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "https://primary.example.com/", rr.Header().Get("X-Primary"))
+	assert.Contains(t, rr.Body.String(), "ProjectAPI.Create")
+	assert.Empty(t, srv.Server.Platform.List(), "rejected call should not be invoked")
+
+	rr = call(`[{"jsonrpc":"2.0","id":3,"method":"ProjectAPI.List","params":[""]},{"jsonrpc":"2.0","id":4,"method":"LambdaAPI.Remove","params":["",""]}]`)
+	assert.Equal(t, http.StatusConflict, rr.Code, "batch with mutating call should be rejected")
+
+	_, _ = mirror.Promote()
+	rr = call(`{"jsonrpc":"2.0","id":5,"method":"ProjectAPI.Create","params":[""]}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "promoted mirror should accept changes")
+}
+
+func TestHandler_hooks(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+
+	var events []string
+	var tracked int
+	srv.Server.Hooks = &application.Hooks{
+		OnBeforeInvoke: func(ctx context.Context, event application.InvokeEvent) *application.Rejection {
+			events = append(events, "before "+event.Request.Headers["X-User"])
+			if event.Request.Headers["X-User"] == "greedy" {
+				return &application.Rejection{Status: http.StatusTooManyRequests, Reason: "quota exceeded", RetryAfter: 1500 * time.Millisecond}
+			}
+			return nil
+		},
+		OnAfterInvoke: func(ctx context.Context, record stats.Record) {
+			records, err := srv.Server.Tracker.(stats.Reader).LastByUID(record.UID, 100)
+			assert.NoError(t, err)
+			assert.Len(t, records, tracked+1, "record should be tracked before hook")
+			tracked = len(records)
+			events = append(events, "after "+record.Err)
+		},
+		OnError: func(ctx context.Context, event application.ErrorEvent) {
+			events = append(events, "error "+event.Hook)
+		},
+	}
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "/bin/sh", "-c", "echo call >> calls")
+	assert.NoError(t, err)
+	_, err = srv.Server.Policies.Create("temp", application.PolicyDefinition{Public: false, Tokens: map[string]string{"secret": "test"}})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Server.Policies.Apply(uid, "temp"))
+
+	invoke := func(user, token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, http.NoBody)
+		assert.NoError(t, err)
+		req.Header.Set("X-User", user)
+		req.Header.Set("Authorization", token)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusForbidden, invoke("anonymous", "").Code)
+	assert.Equal(t, []string{"after token restricted"}, events, "policies are checked before hook")
+	events = nil
+
+	rr := invoke("greedy", "secret")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+	assert.Equal(t, []string{"before greedy", "after quota exceeded"}, events)
+	events = nil
+
+	assert.Equal(t, http.StatusOK, invoke("user", "secret").Code)
+	assert.Equal(t, []string{"before user", "after "}, events)
+	events = nil
+
+	calls, err := ioutil.ReadFile(filepath.Join(srv.Dir, uid, "calls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bytes.Count(calls, []byte("\n")), "rejected requests should not invoke lambda")
+
+	// failed hook doesn't reject request and doesn't crash server
+	srv.Server.Hooks.OnBeforeInvoke = func(ctx context.Context, event application.InvokeEvent) *application.Rejection {
+		panic("oops")
+	}
+	assert.Equal(t, http.StatusOK, invoke("user", "secret").Code)
+	assert.Equal(t, []string{"error OnBeforeInvoke", "after "}, events)
+}
+
+func TestHandler_chain(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:       []string{"cat", "-"},
+			OnSuccess: &types.Chaining{Queue: "chained"},
+		},
+	})
+	assert.NoError(t, err)
+	// paused queue keeps messages for inspection
+	assert.NoError(t, srv.Server.Queues.Add(application.Queue{Name: "chained"}))
+
+	message, err := srv.Server.Queues.Inspect("chained")
+	assert.NoError(t, err)
+	assert.True(t, message.Empty)
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "hello", rr.Body.String())
+
+	message, err = srv.Server.Queues.Inspect("chained")
+	assert.NoError(t, err)
+	assert.False(t, message.Empty)
+	assert.Equal(t, 1, message.Hops)
+	assert.Equal(t, []string{uid}, message.Request.Chain)
+}
+
+func TestHandler_publicURL(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:          []string{"/bin/sh", "-c", `printf '%s %s' "$PUBLIC_URL" "$ALIAS"`},
+			PublicURLEnv: "PUBLIC_URL",
+			AliasEnv:     "ALIAS",
+		},
+	})
+	assert.NoError(t, err)
+	_, err = srv.Server.Platform.Link(uid, "shop")
+	assert.NoError(t, err)
+
+	invoke := func(path string, headers map[string]string) string {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "http://internal:3434/"+path, http.NoBody)
+		assert.NoError(t, err)
+		req.Host = "example.com"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+	proxied := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example.org, proxy.local"}
+
+	assert.Equal(t, "http://example.com shop", invoke("l/shop", nil))
+	assert.Equal(t, "http://example.com ", invoke("a/"+uid, nil), "alias should be empty for request by UID")
+	assert.Equal(t, "http://example.com shop", invoke("l/shop", proxied), "forwarded headers should be ignored without proxy")
+
+	srv.Server.BehindProxy = true
+	assert.Equal(t, "https://public.example.org shop", invoke("l/shop", proxied))
+	assert.Equal(t, "https://example.com shop", invoke("l/shop", map[string]string{"X-Forwarded-Proto": "HTTPS"}))
+	assert.Equal(t, "http://public.example.org shop", invoke("l/shop", map[string]string{"X-Forwarded-Host": "public.example.org"}))
+
+	srv.Server.PublicURL = "https://cgi.example.net/base/"
+	assert.Equal(t, "https://cgi.example.net/base shop", invoke("l/shop", proxied), "configured URL should have priority")
+}
+
+func TestHandler_rewriteURLs(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.BehindProxy = true
+	handler := srv.Server.Handler(ctx)
+
+	const output = `<a href="http://internal:3434/l/shop/item">item</a>`
+	invoke := func(contentType string, repeat int, headers map[string]string) *httptest.ResponseRecorder {
+		manifest := types.Manifest{
+			Run:         []string{"/bin/sh", "-c", `for i in $(seq ` + strconv.Itoa(repeat) + `); do printf '%s' '` + output + `'; done`},
+			RewriteURLs: &types.Rewrite{Prefix: "http://internal:3434/", MaxSize: 256},
+		}
+		if contentType != "" {
+			manifest.OutputHeaders = map[string]string{"Content-Type": contentType}
+		}
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "http://internal:3434/a/"+uid, http.NoBody)
+		assert.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr
+	}
+	proxied := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example.org"}
+	const rewritten = `<a href="https://public.example.org/l/shop/item">item</a>`
+
+	rr := invoke("text/html; charset=utf-8", 1, proxied)
+	assert.Equal(t, rewritten, rr.Body.String())
+	assert.Equal(t, strconv.Itoa(len(rewritten)), rr.Header().Get("Content-Length"))
+	assert.Equal(t, rewritten, invoke("", 1, proxied).Body.String(), "detected text type should be rewritten")
+	assert.Equal(t, `<a href="http://example.org/l/shop/item">item</a>`, invoke("text/html", 1, map[string]string{"X-Forwarded-Host": "example.org"}).Body.String())
+
+	assert.Equal(t, output, invoke("application/octet-stream", 1, proxied).Body.String(), "binary should not be rewritten")
+	assert.Equal(t, output, invoke("text/event-stream", 1, proxied).Body.String(), "streaming should not be rewritten")
+	assert.Equal(t, strings.Repeat(output, 10), invoke("text/html", 10, proxied).Body.String(), "big response should be sent as is")
+}
+
+func TestHandler_alerts(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run: []string{"false"},
+			Alerts: []types.Alert{
+				{Name: "crash", ConsecutiveFailures: 2, Action: types.AlertBreak, CoolOff: types.JsonDuration(time.Hour)},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	invoke := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	invoke()
+	invoke()
+	rr := invoke()
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+
+	status := srv.Server.Alerts.Status(uid)
+	if assert.Len(t, status, 1) {
+		assert.Equal(t, application.AlertFiring, status[0].State)
+	}
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.True(t, records[0].Rejected)
+	}
+
+	_, err = srv.Server.Alerts.Reset(uid, "crash", "admin")
+	assert.NoError(t, err)
+	assert.NotEqual(t, http.StatusServiceUnavailable, invoke().Code)
+}
+
+func TestHandler_mutate(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:          []string{"/bin/sh", "-c", "echo \"$EVENT $SOURCE\"; cat -"},
+			InputHeaders: map[string]string{"X-Event": "EVENT", "X-Source": "SOURCE"},
+			Mutate: &types.Mutation{
+				Headers:      map[string]string{"x-event": "push", "X-Source": "{{.Form.source}}"},
+				BodyDefaults: map[string]interface{}{"ref": "main", "repo": map[string]interface{}{"private": false}},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	invoke := func(query string, contentType string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid+query, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Event", "ping")
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := invoke("?source=github", "application/json", `{"repo": {"name": "app"}, "ref": "dev"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "push github\n"+`{"ref":"dev","repo":{"name":"app","private":false}}`, rr.Body.String())
+
+	rr = invoke("", "text/plain", `{"repo": {}}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "push \n"+`{"repo": {}}`, rr.Body.String(), "only JSON body is patched")
+
+	rr = invoke("?source=a%0Ab", "application/json", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "invalid rendered header")
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 3)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "push", records[2].Request.Headers["X-Event"], "mutated request is recorded")
+	}
+}
+
+func TestHandler_maximumPayload(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	const limit = 1024
+	create := func(statusMap map[int]int) string {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				Run:            []string{"/bin/sh", "-c", `body=$(cat); printf %s "$body"`},
+				MaximumPayload: limit,
+				StatusMap:      statusMap,
+			},
+		})
+		assert.NoError(t, err)
+		return uid
+	}
+	// chunked body: length is not known in advance
+	invoke := func(uid string, size int, chunked bool) (int, string) {
+		var body io.Reader = strings.NewReader(strings.Repeat("x", size))
+		if chunked {
+			body = struct{ io.Reader }{body}
+		}
+		res, err := http.Post(httpServer.URL+"/a/"+uid, "text/plain", body)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		assert.NoError(t, err)
+		return res.StatusCode, string(data)
+	}
+	lastRecord := func(uid string) stats.Record {
+		records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+		assert.NoError(t, err)
+		if !assert.Len(t, records, 1) {
+			return stats.Record{}
+		}
+		return records[0]
+	}
+
+	streamed := create(nil)
+	status, out := invoke(streamed, limit, true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, strings.Repeat("x", limit), out, "body at the limit is passed as is")
+	assert.Equal(t, "chunked", lastRecord(streamed).Request.Headers["Transfer-Encoding"])
+
+	status, out = invoke(streamed, 8*1024*1024, true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	assert.Contains(t, out, "payload too large")
+	record := lastRecord(streamed)
+	assert.LessOrEqual(t, record.Payload, int64(limit+1), "body is not read after the limit")
+	assert.False(t, record.Rejected, "lambda is invoked")
+
+	status, _ = invoke(streamed, limit+1, false)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	record = lastRecord(streamed)
+	assert.True(t, record.Rejected, "rejected by Content-Length without invocation")
+	assert.Equal(t, int64(0), record.Payload)
+
+	buffered := create(map[int]int{1: http.StatusBadRequest})
+	status, _ = invoke(buffered, 64*1024, true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	status, out = invoke(buffered, 10, true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "xxxxxxxxxx", out)
+
+	// body spooled to file is limited by maximum file payload
+	spooled, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:                []string{"/bin/sh", "-c", `wc -c < "$REQUEST_BODY_FILE"`},
+			MaximumPayload:     limit,
+			PayloadAsFile:      true,
+			MaximumFilePayload: 16 * limit,
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	status, out = invoke(spooled, 16*limit, false)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "16384", strings.TrimSpace(out))
+	status, _ = invoke(spooled, 16*limit+1, false)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	assert.True(t, lastRecord(spooled).Rejected, "rejected by Content-Length without invocation")
+
+	// multipart body decoded to files is limited by maximum file payload, other bodies - by maximum payload
+	decoded, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:                []string{"/bin/sh", "-c", "cat"},
+			MaximumPayload:     limit,
+			DecodeMultipart:    true,
+			MaximumFilePayload: 16 * limit,
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	post := func(contentType string, body string) (int, string) {
+		res, err := http.Post(httpServer.URL+"/a/"+decoded, contentType, strings.NewReader(body))
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		assert.NoError(t, err)
+		return res.StatusCode, string(data)
+	}
+	file := "--xyz\r\nContent-Disposition: form-data; name=\"doc\"; filename=\"a.txt\"\r\n\r\n" + strings.Repeat("x", 8*limit) + "\r\n--xyz--\r\n"
+	status, out = post("multipart/form-data; boundary=xyz", file)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, out, `"size":8192`)
+	status, _ = invoke(decoded, limit+1, false)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	status, _ = post("multipart/form-data; boundary=xyz", file[:100])
+	assert.Equal(t, http.StatusBadRequest, status, "malformed form")
+}
+
+func TestHandler_maximumResponse(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	create := func(policy string) string {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				// endless output, closed stdout is ignored
+				Run:             []string{"/bin/sh", "-c", `trap "" PIPE; while true; do echo xxxxxxxxx; done`},
+				MaximumResponse: 25,
+				TruncatePolicy:  policy,
+			},
+		})
+		assert.NoError(t, err)
+		return uid
+	}
+	invoke := func(uid string) (*http.Response, string) {
+		res, err := http.Post(httpServer.URL+"/a/"+uid, "text/plain", nil)
+		if !assert.NoError(t, err) {
+			return &http.Response{}, ""
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		assert.NoError(t, err)
+		return res, string(data)
+	}
+	lastRecord := func(uid string) stats.Record {
+		records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+		assert.NoError(t, err)
+		if !assert.Len(t, records, 1) {
+			return stats.Record{}
+		}
+		return records[0]
+	}
+
+	failed := create("")
+	started := time.Now()
+	res, out := invoke(failed)
+	assert.Less(t, int64(time.Since(started)), int64(2*time.Second), "process is killed")
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	assert.Contains(t, out, "response exceeds maximum response (25 bytes)")
+	record := lastRecord(failed)
+	assert.True(t, record.Overrun)
+	assert.NotEmpty(t, record.Err)
+
+	truncated := create(types.TruncateCut)
+	res, out = invoke(truncated)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "true", res.Header.Get(server.TruncatedHeader))
+	assert.Equal(t, "xxxxxxxxx\nxxxxxxxxx\nxxxxx", out)
+	record = lastRecord(truncated)
+	assert.True(t, record.Overrun)
+	assert.Empty(t, record.Err)
+	assert.Contains(t, record.Warning, "response too large")
+}
+
+func TestHandler_streaming(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	create := func(script string, maximumResponse int64, policy string) string {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				Run:             []string{"/bin/sh", "-c", script},
+				MaximumResponse: maximumResponse,
+				TruncatePolicy:  policy,
+			},
+		})
+		assert.NoError(t, err)
+		return uid
+	}
+
+	// output is delivered while lambda is running, also with limited response
+	progressive := create("echo first; sleep 1; echo second", 1024*1024, "")
+	started := time.Now()
+	res, err := http.Post(httpServer.URL+"/a/"+progressive, "text/plain", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "first\n", line)
+	assert.Less(t, int64(time.Since(started)), int64(800*time.Millisecond), "output is not buffered till exit")
+	rest, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", string(rest))
+	_ = res.Body.Close()
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(progressive, 1)
+	if assert.NoError(t, err) && assert.Len(t, records, 1) {
+		assert.Equal(t, "first\nsecond\n", string(records[0].Output), "prefix of body is recorded")
+	}
+
+	// overrun after start of response: truncated output is marked by trailer, failed response is aborted
+	endless := `echo first; sleep 0.3; trap "" PIPE; while true; do echo xxxxxxxxx; done`
+	res, err = http.Post(httpServer.URL+"/a/"+create(endless, 1000, types.TruncateCut), "text/plain", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	data, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Len(t, data, 1000)
+	assert.Equal(t, "true", res.Trailer.Get(server.TruncatedHeader))
+	_ = res.Body.Close()
+
+	res, err = http.Post(httpServer.URL+"/a/"+create(endless, 1000, ""), "text/plain", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = ioutil.ReadAll(res.Body)
+	assert.Error(t, err, "cut output is not complete response")
+	_ = res.Body.Close()
+
+	// process is killed when client is gone
+	marker := filepath.Join(srv.Dir, "finished")
+	slow := create("echo started; sleep 1; touch "+marker, 0, "")
+	cctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(cctx, http.MethodPost, httpServer.URL+"/a/"+slow, nil)
+	assert.NoError(t, err)
+	res, err = http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		cancel()
+		return
+	}
+	line, err = bufio.NewReader(res.Body).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "started\n", line)
+	cancel()
+	_ = res.Body.Close()
+	time.Sleep(1500 * time.Millisecond)
+	assert.NoFileExists(t, marker)
+}
+
+func TestHandler_sse(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	create := func(script string, streamTimeLimit time.Duration) string {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				Run:             []string{"/bin/sh", "-c", script},
+				OutputHeaders:   map[string]string{"Content-Type": "text/plain"},
+				TimeLimit:       types.JsonDuration(300 * time.Millisecond),
+				MaximumResponse: 1024 * 1024,
+				Streaming:       types.StreamingSSE,
+				StreamTimeLimit: types.JsonDuration(streamTimeLimit),
+			},
+		})
+		assert.NoError(t, err)
+		return uid
+	}
+
+	// events are delivered while lambda is running, stream time limit replaces shorter time limit
+	events := create(`printf 'data: first\n\n'; sleep 1; printf 'data: second\n\n'`, 5*time.Second)
+	started := time.Now()
+	res, err := http.Get(httpServer.URL + "/a/" + events)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "data: first\n", line)
+	assert.Less(t, int64(time.Since(started)), int64(800*time.Millisecond), "event is not buffered")
+	rest, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "\ndata: second\n\n", string(rest))
+	_ = res.Body.Close()
+
+	// stream is closed by stream time limit
+	endless := create(`printf 'data: tick\n\n'; sleep 10`, 500*time.Millisecond)
+	started = time.Now()
+	res, err = http.Get(httpServer.URL + "/a/" + endless)
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "data: tick\n\nevent: close\ndata: lifetime\n\n", string(data))
+	assert.Less(t, int64(time.Since(started)), int64(5*time.Second), "process is killed by stream time limit")
+	_ = res.Body.Close()
+
+	// process is killed when client is gone
+	marker := filepath.Join(srv.Dir, "finished")
+	slow := create(`printf 'data: started\n\n'; sleep 1; touch `+marker, 5*time.Second)
+	cctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(cctx, http.MethodGet, httpServer.URL+"/a/"+slow, nil)
+	assert.NoError(t, err)
+	res, err = http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		cancel()
+		return
+	}
+	line, err = bufio.NewReader(res.Body).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "data: started\n", line)
+	cancel()
+	_ = res.Body.Close()
+	time.Sleep(1500 * time.Millisecond)
+	assert.NoFileExists(t, marker)
+}
+
+func TestHandler_sseClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	httpServer := httptest.NewServer(srv.Server.Handler(ctx))
+	defer httpServer.Close()
+
+	create := func(script string, idle, reauthorize time.Duration) string {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				Run:               []string{"/bin/sh", "-c", script},
+				TimeLimit:         types.JsonDuration(10 * time.Second),
+				Streaming:         types.StreamingSSE,
+				StreamIdleTimeout: types.JsonDuration(idle),
+				StreamReauthorize: types.JsonDuration(reauthorize),
+			},
+		})
+		assert.NoError(t, err)
+		return uid
+	}
+	open := func(uid, token string) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/a/"+uid, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", token)
+		res, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		reader := bufio.NewReader(res.Body)
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "data: tick\n", line)
+		return res, reader
+	}
+	rest := func(res *http.Response, reader *bufio.Reader) string {
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	// lambda which emits nothing is closed by idle timeout, output resets timer
+	idle := create(`printf 'data: tick\n\n'; sleep 0.2; printf 'data: tock\n\n'; sleep 10`, 400*time.Millisecond, 0)
+	started := time.Now()
+	res, reader := open(idle, "")
+	assert.Equal(t, "\ndata: tock\n\nevent: close\ndata: idle\n\n", rest(res, reader))
+	assert.Less(t, int64(time.Since(started)), int64(5*time.Second))
+
+	// stream is closed as soon as token is revoked
+	reauth := create(`while true; do printf 'data: tick\n\n'; sleep 0.1; done`, 0, 100*time.Millisecond)
+	_, err = srv.Server.Policies.Create("temp", application.PolicyDefinition{Tokens: map[string]string{"secret": "test"}})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Server.Policies.Apply(reauth, "temp"))
+	res, reader = open(reauth, "secret")
+	assert.Equal(t, map[string]int{reauth: 1, idle: 0}, srv.Server.OpenStreams())
+	assert.NoError(t, srv.Server.Policies.Update("temp", application.PolicyDefinition{Tokens: map[string]string{"other": "test"}}))
+	body := rest(res, reader)
+	assert.True(t, strings.HasSuffix(body, "\nevent: close\ndata: unauthorized\n\n"), body)
+
+	// streams are closed by the last event on shutdown
+	endless := create(`printf 'data: tick\n\n'; sleep 10`, 0, 0)
+	res, reader = open(endless, "")
+	cancel()
+	assert.Equal(t, "\nevent: close\ndata: shutdown\n\n", rest(res, reader))
+	assert.Equal(t, map[string]int{reauth: 0, idle: 0, endless: 0}, srv.Server.OpenStreams())
+}
+
+func TestServer_Drain(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "/bin/sh", "-c", "sleep 0.5; cat")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	inFlight := httptest.NewRecorder()
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		handler.ServeHTTP(inFlight, req)
+	}()
+	require.Eventually(t, func() bool {
+		return len(srv.Server.Platform.Running()) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// not finished before deadline
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	n, running := srv.Server.Drain(short)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, running)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello")))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "close", rr.Header().Get("Connection"))
+
+	n, running = srv.Server.Drain(ctx)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 0, running)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, inFlight.Code)
+	assert.Equal(t, "hello", inFlight.Body.String())
+}
+
+func TestHandler_health(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	srv.Server.DataDir = srv.Dir
+	handler := srv.Server.Handler(ctx)
+
+	available, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	missing, err := srv.AddDummyLambda(ctx, "./missing")
+	require.NoError(t, err)
+	failed, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{Run: []string{"cat", "-"}},
+		Check:    [][]string{{"false"}},
+	})
+	require.NoError(t, err)
+
+	get := func(path string, token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/healthz", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+	rr = get("/readyz", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, get("/health/lambdas", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/health/lambdas", "invalid").Code)
+
+	token, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	rr = get("/health/lambdas", token.Data)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var report []struct {
+		UID       string   `json:"uid"`
+		Available bool     `json:"available"`
+		Problems  []string `json:"problems"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	problems := map[string][]string{}
+	for _, item := range report {
+		assert.Equal(t, len(item.Problems) == 0, item.Available)
+		problems[item.UID] = item.Problems
+	}
+	require.Len(t, problems, 3)
+	assert.Empty(t, problems[available])
+	require.Len(t, problems[missing], 1)
+	assert.Contains(t, problems[missing][0], "run command ./missing")
+	require.Len(t, problems[failed], 1)
+	assert.Contains(t, problems[failed][0], "check false")
+
+	assert.Equal(t, http.StatusNotFound, get("/health/warmup", token.Data).Code, "warm-up is disabled")
+	warm := warmup.New(srv.Server.Platform)
+	warm.Run(ctx)
+	srv.Server.WarmUp = warm
+	assert.Equal(t, http.StatusUnauthorized, get("/health/warmup", "").Code)
+	rr = get("/health/warmup", token.Data)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var warmed application.WarmUpReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &warmed))
+	assert.False(t, warmed.Running)
+	assert.Len(t, warmed.Lambdas, 3)
+
+	// probes are not invocations
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(available, 1)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	srv.Server.Drain(ctx)
+	rr = get("/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.JSONEq(t, `{"status":"fail","problems":["server is shutting down"]}`, rr.Body.String())
+	assert.Equal(t, http.StatusOK, get("/healthz", "").Code)
+}
+
+func TestHandler_disabled(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	_, err = srv.Server.Platform.Link(uid, "offline")
+	require.NoError(t, err)
+	_, err = srv.Server.Platform.SetEnabled(uid, false)
+	require.NoError(t, err)
+
+	for _, path := range []string{"/a/" + uid, "/l/offline"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com"+path, bytes.NewBufferString("hello")))
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code, path)
+		assert.JSONEq(t, `{"error":"lambda is disabled","uid":"`+uid+`"}`, rr.Body.String(), path)
+	}
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Rejected)
+
+	_, err = srv.Server.Platform.SetEnabled(uid, true)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/l/offline", bytes.NewBufferString("hello")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "hello", rr.Body.String())
+}
+
+func TestHandler_capture(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	def, err := srv.Server.Platform.FindByUID(uid)
+	require.NoError(t, err)
+	manifest := def.Lambda.Manifest()
+	manifest.Capture = &types.Capture{Requests: 2, MaxBytes: 8}
+	require.NoError(t, def.Lambda.SetManifest(manifest))
+
+	for _, body := range []string{"first", "second", "too long body"} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString(body))
+		req.Header.Set("X-Body", body)
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, body, rr.Body.String())
+	}
+
+	requests, err := srv.Server.LambdaAPI.Requests(ctx, nil, uid)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.True(t, requests[0].Truncated)
+	assert.Equal(t, int64(len("too long body")), requests[0].Size)
+	assert.Equal(t, "second", requests[1].Request.Headers["X-Body"])
+	assert.Empty(t, requests[1].Body)
+
+	_, err = srv.Server.LambdaAPI.Replay(ctx, nil, uid, requests[0].ID)
+	assert.Error(t, err, "truncated request is not replayed")
+
+	captured, err := srv.Server.LambdaAPI.CapturedRequest(ctx, nil, uid, requests[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(captured.Body))
+
+	result, err := srv.Server.LambdaAPI.Replay(ctx, nil, uid, requests[1].ID)
+	require.NoError(t, err)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "second", string(result.Output))
+	assert.Equal(t, types.ReplayRequestPrefix+captured.Request.ID, result.ID)
+
+	// captured requests are removed with lambda
+	require.NoError(t, srv.Server.Cases.Remove(uid))
+	requests, err = srv.Server.Cases.Captures().List(uid)
+	require.NoError(t, err)
+	assert.Empty(t, requests)
+}
+
+func TestHandler_scopedToken(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	allowed, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	other, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	_, err = srv.Server.UserAPI.CreateToken(ctx, admin, "broken", api.Scope{Operations: []string{"deploy"}}, 0)
+	assert.Error(t, err, "unknown operation")
+	uploader, err := srv.Server.UserAPI.CreateToken(ctx, admin, "ci", api.Scope{Lambdas: []string{allowed}, Operations: []string{api.OpUpload}}, 0)
+	require.NoError(t, err)
+	scheduler, err := srv.Server.UserAPI.CreateToken(ctx, admin, "cron", api.Scope{Lambdas: []string{allowed}, Operations: []string{api.OpManageSchedule}}, 0)
+	require.NoError(t, err)
+
+	call := func(method string, params ...interface{}) (json.RawMessage, *jsonrpc2.Error) {
+		body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/u/", bytes.NewReader(body)))
+		var reply struct {
+			Result json.RawMessage `json:"result"`
+			Error  *jsonrpc2.Error `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &reply), rr.Body.String())
+		return reply.Result, reply.Error
+	}
+
+	_, rpcErr := call("LambdaAPI.Files", uploader.Token, allowed, "")
+	assert.Nil(t, rpcErr)
+	_, rpcErr = call("LambdaAPI.Files", uploader.Token, other, "")
+	require.NotNil(t, rpcErr)
+	assert.Equal(t, http.StatusForbidden, rpcErr.Code)
+	assert.Contains(t, rpcErr.Message, "for lambda "+other)
+	_, rpcErr = call("LambdaAPI.Stats", uploader.Token, allowed, 10)
+	require.NotNil(t, rpcErr)
+	assert.Contains(t, rpcErr.Message, "token has no scope read-stats for lambda "+allowed)
+	_, rpcErr = call("UserAPI.Tokens", uploader.Token)
+	require.NotNil(t, rpcErr)
+	assert.Contains(t, rpcErr.Message, "token has no scope admin for all lambdas")
+
+	// list of lambdas is limited by scope
+	result, rpcErr := call("ProjectAPI.List", uploader.Token)
+	require.Nil(t, rpcErr)
+	var list []application.Definition
+	require.NoError(t, json.Unmarshal(result, &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, allowed, list[0].UID)
+
+	// schedules could be changed without admin operation, but not the rest of manifest
+	def, err := srv.Server.Platform.FindByUID(allowed)
+	require.NoError(t, err)
+	manifest := def.Lambda.Manifest()
+	manifest.Cron = []types.Schedule{{Cron: "@every 1h"}}
+	_, rpcErr = call("LambdaAPI.Update", scheduler.Token, allowed, manifest, false)
+	assert.Nil(t, rpcErr)
+	manifest.Name = "renamed"
+	_, rpcErr = call("LambdaAPI.Update", scheduler.Token, allowed, manifest, false)
+	require.NotNil(t, rpcErr)
+	assert.Contains(t, rpcErr.Message, "token has no scope admin for lambda "+allowed)
+
+	// login tokens are not scoped
+	_, rpcErr = call("LambdaAPI.Files", admin.Data, other, "")
+	assert.Nil(t, rpcErr)
+	tokens, err := srv.Server.UserAPI.Tokens(ctx, admin)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "ci", tokens[0].Name)
+	assert.Equal(t, []string{allowed}, tokens[0].Scope.Lambdas)
+	assert.Empty(t, tokens[0].Token)
+
+	_, err = srv.Server.UserAPI.RevokeToken(ctx, admin, uploader.ID)
+	require.NoError(t, err)
+	_, rpcErr = call("LambdaAPI.Files", uploader.Token, allowed, "")
+	require.NotNil(t, rpcErr)
+	assert.Contains(t, rpcErr.Message, "unknown API token")
+}
+
+func TestHandler_scopedTokenArguments(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	allowed, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	other, err := srv.AddDummyLambda(ctx, "cat", "-")
+	require.NoError(t, err)
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	uploader, err := srv.Server.UserAPI.CreateToken(ctx, admin, "ci", api.Scope{Lambdas: []string{allowed}, Operations: []string{api.OpUpload}}, 0)
+	require.NoError(t, err)
+
+	call := func(method string, params string) *jsonrpc2.Error {
+		body := `{"jsonrpc": "2.0", "id": 1, "method": "` + method + `", "params": ` + params + `}`
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/u/", strings.NewReader(body)))
+		var reply struct {
+			Error *jsonrpc2.Error `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &reply), rr.Body.String())
+		return reply.Error
+	}
+	forbidden := func(method string, params string) {
+		rpcErr := call(method, params)
+		if assert.NotNil(t, rpcErr, params) {
+			assert.Equal(t, http.StatusForbidden, rpcErr.Code, params)
+		}
+	}
+
+	// names of arguments are matched like by handlers: case-insensitive, the last wins
+	forbidden("LambdaAPI.Remove", `{"Token": "`+uploader.Token+`", "uid": "`+other+`"}`)
+	forbidden("LambdaAPI.Remove", `{"token": "`+uploader.Token+`", "UID": "`+other+`"}`)
+	forbidden("LambdaAPI.Files", `{"token": "`+uploader.Token+`", "uid": "`+allowed+`", "Uid": "`+other+`", "dir": ""}`)
+	forbidden("UserAPI.Tokens", `{"TOKEN": "`+uploader.Token+`"}`)
+	assert.Nil(t, call("LambdaAPI.Files", `{"Token": "`+uploader.Token+`", "UID": "`+allowed+`", "dir": ""}`))
+
+	// missing lambda is not in scope, malformed arguments are rejected
+	forbidden("LambdaAPI.Files", `{"token": "`+uploader.Token+`", "dir": ""}`)
+	assert.NotNil(t, call("LambdaAPI.Remove", `{"token": "`+uploader.Token+`", "uid": 1}`))
+	assert.NotNil(t, call("LambdaAPI.Remove", `{"token": 1, "uid": "`+other+`"}`))
+	assert.NotNil(t, call("LambdaAPI.Remove", `{"uid": "`+other+`"}`))
+	assert.NotNil(t, call("LambdaAPI.Remove", `"`+uploader.Token+`"`))
+	assert.NotNil(t, call("LambdaAPI.Remove", `["`+uploader.Token+`"]`))
+	assert.NotNil(t, call("LambdaAPI.Remove", `["`+uploader.Token+`", 1]`))
+
+	_, err = srv.Server.Platform.FindByUID(other)
+	assert.NoError(t, err, "lambda out of scope is not removed")
+	_, err = srv.Server.Platform.FindByUID(allowed)
+	assert.NoError(t, err)
+}
+
+func TestHandler_tokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	admin, err := srv.Server.UserAPI.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	scope := api.Scope{Operations: []string{api.OpReadStats}}
+	_, err = srv.Server.UserAPI.CreateToken(ctx, admin, "negative", scope, types.JsonDuration(-time.Second))
+	assert.Error(t, err)
+	shortLived, err := srv.Server.UserAPI.CreateToken(ctx, admin, "short", scope, types.JsonDuration(200*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, shortLived.Expires.IsZero())
+	rotated, err := srv.Server.UserAPI.CreateToken(ctx, admin, "ci", scope, types.JsonDuration(time.Hour))
+	require.NoError(t, err)
+
+	call := func(token string) *jsonrpc2.Error {
+		body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "ProjectAPI.Stats", "params": []interface{}{token, 1}})
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/u/", bytes.NewReader(body)))
+		var reply struct {
+			Error *jsonrpc2.Error `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &reply), rr.Body.String())
+		return reply.Error
+	}
+
+	replacement, err := srv.Server.UserAPI.RotateToken(ctx, admin, rotated.ID, types.JsonDuration(200*time.Millisecond))
+	require.NoError(t, err)
+	assert.NotEqual(t, rotated.Token, replacement.Token)
+	assert.Equal(t, "ci", replacement.Name)
+	assert.Equal(t, rotated.Scope, replacement.Scope)
+	assert.WithinDuration(t, replacement.Created.Add(time.Hour), replacement.Expires, time.Second, "the same time to live")
+
+	// overlap: both tokens are valid
+	assert.Nil(t, call(shortLived.Token))
+	assert.Nil(t, call(rotated.Token))
+	assert.Nil(t, call(replacement.Token))
+
+	time.Sleep(300 * time.Millisecond)
+	for _, token := range []string{shortLived.Token, rotated.Token} {
+		rpcErr := call(token)
+		require.NotNil(t, rpcErr)
+		assert.Equal(t, http.StatusUnauthorized, rpcErr.Code)
+		assert.Contains(t, rpcErr.Message, "token expired")
+	}
+	assert.Nil(t, call(replacement.Token))
+
+	tokens, err := srv.Server.UserAPI.Tokens(ctx, admin)
+	require.NoError(t, err)
+	require.Len(t, tokens, 3, "expired tokens are kept for grace period")
+	assert.Equal(t, replacement.ID, tokens[1].ReplacedBy)
+	_, err = srv.Server.UserAPI.RotateToken(ctx, admin, rotated.ID, 0)
+	assert.Error(t, err, "expired token can not be rotated")
+}
+
+func TestHandler_timing(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	metrics := prometheus.New()
+	srv.Server.Metrics = metrics
+	handler := srv.Server.Handler(ctx)
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{Run: []string{"/bin/sh", "-c", "sleep 0.1; echo hello; exit 2"}},
+	})
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, nil))
+
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Greater(t, record.Spawn, time.Duration(0))
+	assert.GreaterOrEqual(t, record.FirstByte, 100*time.Millisecond)
+	assert.GreaterOrEqual(t, record.Runtime, record.FirstByte)
+	assert.Greater(t, record.Write, time.Duration(0), "output is written to client")
+	assert.Equal(t, 2, record.ExitCode)
+	assert.Empty(t, record.Signal)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "https://example.com/metrics", nil))
+	assert.Contains(t, rr.Body.String(), `trusted_cgi_spawn_seconds_bucket{uid="`+uid+`",le="+Inf"} 1`)
+	assert.Contains(t, rr.Body.String(), `trusted_cgi_spawn_seconds_count{uid="`+uid+`"} 1`)
+}
+
+func TestDiskQuota(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{Run: []string{"cat", "-"}, DiskQuotaMB: 1},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srv.Dir, uid, "data.bin"), bytes.Repeat([]byte("x"), 1100000), 0644))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello")))
+	assert.Equal(t, http.StatusInsufficientStorage, rr.Code)
+	records, err := srv.Server.Tracker.(stats.Reader).LastByUID(uid, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].Err, "disk quota exceeded")
+}
@@ -0,0 +1,556 @@
+package sftpd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// SFTP session of deployer. Virtual root is read-only and contains permitted lambdas as directories,
+// content of lambda is staged in temporary directory on first access
+type session struct {
+	platform Platform
+	journal  application.Journal // optional
+	key      *Key
+	dir      string
+	lock     sync.Mutex
+	stages   map[string]*stage // by name of virtual directory
+	counter  int
+}
+
+// staged copy of lambda content
+type stage struct {
+	name     string          // name of virtual directory
+	uid      string          // UID of lambda
+	root     string          // local directory with staged files
+	original map[string]bool // files (slash separated) of lambda before staging
+	changed  bool
+}
+
+func newSession(platform Platform, journal application.Journal, key *Key) (*session, error) {
+	dir, err := ioutil.TempDir("", "sftp-")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	return &session{platform: platform, journal: journal, key: key, dir: dir, stages: map[string]*stage{}}, nil
+}
+
+// deploy changed stages and remove staging directory
+func (sess *session) close() {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+	var names = make([]string, 0, len(sess.stages))
+	for name := range sess.stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st := sess.stages[name]
+		if !st.changed {
+			continue
+		}
+		if err := sess.deploy(st, "session close"); err != nil {
+			log.Println("[ERROR]", "sftp", sess.key.Name, "deploy", st.name+":", err)
+		}
+	}
+	if err := os.RemoveAll(sess.dir); err != nil {
+		log.Println("[ERROR]", "sftp", sess.key.Name, "remove staging directory:", err)
+	}
+}
+
+// lambda by name of virtual directory if permitted
+func (sess *session) permitted(name string) (*application.Definition, error) {
+	for _, entry := range sess.key.Lambdas {
+		if entry != name && entry != AllLambdas {
+			continue
+		}
+		if def, err := sess.platform.FindByUID(name); err == nil {
+			return def, nil
+		}
+		if entry != name {
+			continue
+		}
+		if def, err := sess.platform.FindByLink(name); err == nil {
+			return def, nil
+		}
+	}
+	return nil, os.ErrNotExist // do not disclose existence of lambdas which are not permitted
+}
+
+// names of permitted lambdas (virtual directories)
+func (sess *session) names() []string {
+	var seen = make(map[string]bool)
+	for _, entry := range sess.key.Lambdas {
+		if entry == AllLambdas {
+			for _, def := range sess.platform.List() {
+				seen[def.UID] = true
+			}
+		} else if _, err := sess.permitted(entry); err == nil {
+			seen[entry] = true
+		}
+	}
+	var ans = make([]string, 0, len(seen))
+	for name := range seen {
+		ans = append(ans, name)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// resolve virtual path to stage and slash separated path relative to root of lambda. Stage is nil for virtual root.
+// Path is cleaned as absolute, so it could not go outside of the virtual root
+func (sess *session) resolve(virtual string) (*stage, string, error) {
+	clean := path.Clean("/" + virtual)
+	if clean == "/" {
+		return nil, "", nil
+	}
+	parts := strings.SplitN(clean[1:], "/", 2)
+	st, err := sess.stage(parts[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if len(parts) == 1 {
+		return st, "", nil
+	}
+	return st, parts[1], nil
+}
+
+// resolve virtual path to file inside lambda
+func (sess *session) resolveFile(virtual string) (*stage, string, error) {
+	st, rel, err := sess.resolve(virtual)
+	if err != nil {
+		return nil, "", err
+	}
+	if st == nil || rel == "" {
+		return nil, "", os.ErrPermission
+	}
+	return st, rel, nil
+}
+
+func (sess *session) stage(name string) (*stage, error) {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+	if st, ok := sess.stages[name]; ok {
+		return st, nil
+	}
+	def, err := sess.permitted(name)
+	if err != nil {
+		return nil, err
+	}
+	sess.counter++
+	// names of directories are not used as is: link could be any valid file name (including dots)
+	root := filepath.Join(sess.dir, strconv.Itoa(sess.counter))
+	var content bytes.Buffer
+	if err := def.Lambda.Content(&content); err != nil {
+		return nil, fmt.Errorf("get content of %s: %w", name, err)
+	}
+	original, err := extract(&content, root)
+	if err != nil {
+		return nil, fmt.Errorf("stage content of %s: %w", name, err)
+	}
+	st := &stage{name: name, uid: def.UID, root: root, original: original}
+	sess.stages[name] = st
+	return st, nil
+}
+
+func (sess *session) touch(st *stage) {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+	st.changed = true
+}
+
+// deploy staged content as upload by API: validate manifest, bind declared aliases, replace content, remove deleted
+// files and run startup action. Stage is dropped only on success. Session lock should be held
+func (sess *session) deploy(st *stage, trigger string) error {
+	def, err := sess.platform.FindByUID(st.uid)
+	if err != nil {
+		return err
+	}
+	manifest, err := validateManifest(st.root, sess.platform.Profile())
+	if err != nil {
+		log.Println("[AUDIT]", "sftp", sess.key.Name, "deploy", st.name, "rejected:", err)
+		return err
+	}
+	content, err := pack(st.root)
+	if err != nil {
+		return fmt.Errorf("pack staged files: %w", err)
+	}
+	previous := def.Lambda.Manifest()
+	if _, err := sess.platform.BindAliases(st.uid, previous.Aliases, manifest.Aliases, false); err != nil {
+		log.Println("[AUDIT]", "sftp", sess.key.Name, "deploy", st.name, "rejected:", err)
+		return err
+	}
+	if err := def.Lambda.SetContent(content); err != nil {
+		_, _ = sess.platform.BindAliases(st.uid, manifest.Aliases, previous.Aliases, false)
+		return fmt.Errorf("set content: %w", err)
+	}
+	var removed int
+	for file := range st.original {
+		if _, err := os.Lstat(filepath.Join(st.root, filepath.FromSlash(file))); !os.IsNotExist(err) {
+			continue
+		}
+		if err := def.Lambda.RemoveFile(file); err != nil {
+			log.Println("[ERROR]", "sftp", sess.key.Name, "deploy", st.name, "remove", file+":", err)
+			continue
+		}
+		removed++
+	}
+	sess.platform.Start(context.Background(), def.Lambda)
+	log.Println("[AUDIT]", "sftp", sess.key.Name, "deployed lambda", st.uid, "by", trigger, "-", removed, "files removed")
+	if sess.journal != nil {
+		sess.journal.Record(application.Change{
+			Actor:   sess.key.Name,
+			Kind:    application.ChangeDeploy,
+			Lambda:  def.UID,
+			Name:    def.Manifest.Name,
+			Summary: fmt.Sprintf("content deployed over SFTP by %s, %d files removed", trigger, removed),
+		})
+	}
+	delete(sess.stages, st.name)
+	return os.RemoveAll(st.root)
+}
+
+// deploy of lambda triggered by the sentinel file
+func (sess *session) trigger(st *stage) error {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+	if sess.stages[st.name] != st {
+		return nil // already deployed
+	}
+	return sess.deploy(st, "sentinel")
+}
+
+func (sess *session) audit(op string, st *stage, rel string, extra ...interface{}) {
+	args := append([]interface{}{"[AUDIT]", "sftp", sess.key.Name, op, st.name + "/" + rel}, extra...)
+	log.Println(args...)
+}
+
+func (sess *session) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	st, rel, err := sess.resolveFile(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(st.root, filepath.FromSlash(rel)))
+}
+
+func (sess *session) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	st, rel, err := sess.resolveFile(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if pflags := r.Pflags(); pflags.Trunc {
+		flags |= os.O_TRUNC
+	} else if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(filepath.Join(st.root, filepath.FromSlash(rel)), flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	sess.touch(st)
+	return &upload{File: f, sess: sess, stage: st, rel: rel}, nil
+}
+
+func (sess *session) Filecmd(r *sftp.Request) error {
+	st, rel, err := sess.resolveFile(r.Filepath)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(st.root, filepath.FromSlash(rel))
+	switch r.Method {
+	case "Setstat":
+		if r.AttrFlags().Permissions {
+			if err := os.Chmod(target, r.Attributes().FileMode().Perm()); err != nil {
+				return err
+			}
+		}
+		if r.AttrFlags().Size {
+			if err := os.Truncate(target, int64(r.Attributes().Size)); err != nil {
+				return err
+			}
+		}
+		sess.touch(st)
+		return nil
+	case "Rename", "PosixRename":
+		dst, dstRel, err := sess.resolveFile(r.Target)
+		if err != nil {
+			return err
+		}
+		if dst != st {
+			return os.ErrPermission // files could not be moved between lambdas
+		}
+		if err := os.Rename(target, filepath.Join(st.root, filepath.FromSlash(dstRel))); err != nil {
+			return err
+		}
+		sess.touch(st)
+		sess.audit("rename", st, rel, "to", dstRel)
+		if dstRel == SentinelFile {
+			return sess.trigger(st)
+		}
+		return nil
+	case "Rmdir":
+		info, err := os.Lstat(target)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", rel)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+		sess.touch(st)
+		sess.audit("rmdir", st, rel)
+		return nil
+	case "Remove":
+		info, err := os.Lstat(target)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", rel)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+		sess.touch(st)
+		sess.audit("remove", st, rel)
+		return nil
+	case "Mkdir":
+		if err := os.Mkdir(target, 0755); err != nil {
+			return err
+		}
+		sess.touch(st)
+		sess.audit("mkdir", st, rel)
+		return nil
+	default:
+		// links are not supported: staged content contains only regular files and directories
+		return sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (sess *session) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	st, rel, err := sess.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	switch r.Method {
+	case "List":
+		if st == nil {
+			var list []os.FileInfo
+			for _, name := range sess.names() {
+				list = append(list, virtualDir(name))
+			}
+			return listerAt(list), nil
+		}
+		list, err := ioutil.ReadDir(filepath.Join(st.root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		return listerAt(list), nil
+	case "Stat", "Lstat":
+		if st == nil {
+			return listerAt{virtualDir("/")}, nil
+		}
+		if rel == "" {
+			return listerAt{virtualDir(st.name)}, nil
+		}
+		info, err := os.Lstat(filepath.Join(st.root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+// uploaded file: audited on close, the sentinel file in root of lambda triggers deploy
+type upload struct {
+	*os.File
+	sess  *session
+	stage *stage
+	rel   string
+}
+
+func (up *upload) Close() error {
+	var size int64
+	if info, err := up.File.Stat(); err == nil {
+		size = info.Size()
+	}
+	if err := up.File.Close(); err != nil {
+		return err
+	}
+	up.sess.audit("put", up.stage, up.rel, size, "bytes")
+	if up.rel == SentinelFile {
+		return up.sess.trigger(up.stage)
+	}
+	return nil
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// read-only directory of virtual root
+type virtualDir string
+
+func (vd virtualDir) Name() string       { return string(vd) }
+func (vd virtualDir) Size() int64        { return 0 }
+func (vd virtualDir) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (vd virtualDir) ModTime() time.Time { return time.Time{} }
+func (vd virtualDir) IsDir() bool        { return true }
+func (vd virtualDir) Sys() interface{}   { return nil }
+
+func validateManifest(dir string, profile types.SecurityProfile) (types.Manifest, error) {
+	var manifest types.Manifest
+	file, err := internal.FindManifest(dir)
+	if err != nil {
+		return manifest, err
+	}
+	if err := manifest.LoadFrom(file); err != nil {
+		return manifest, fmt.Errorf("read manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if err := profile.Check(manifest); err != nil {
+		return manifest, fmt.Errorf("manifest violates security profile: %w", err)
+	}
+	return manifest, nil
+}
+
+// extract tar.gz content to the directory: only regular files and directories, names are cleaned as absolute
+// to stay inside the directory. Returns extracted files (slash separated)
+func extract(tarGz io.Reader, dir string) (map[string]bool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(tarGz)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	var files = make(map[string]bool)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean("/" + header.Name)[1:]
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, err
+			}
+			if err := extractFile(reader, target, header.FileInfo().Mode().Perm()); err != nil {
+				return nil, err
+			}
+			files[name] = true
+		}
+	}
+	return files, nil
+}
+
+func extractFile(reader io.Reader, target string, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, reader); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// pack staged files (except the sentinel file) as tar.gz
+func pack(dir string) (*bytes.Buffer, error) {
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	writer := tar.NewWriter(gz)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if rel == "." || rel == SentinelFile {
+			return nil
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(writer, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,208 @@
+// Package sftpd serves content of lambdas over SFTP for deploy pipelines which could use only SFTP (or scp in SFTP
+// mode). Every permitted lambda is a virtual directory. Writes land in the staging copy of the lambda and are deployed
+// as upload by API when the sentinel file is created (renamed) in the root of the lambda or when the session is closed.
+package sftpd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Creation (by rename or upload) of the file in the root of lambda directory deploys staged files
+const SentinelFile = ".deploy"
+
+// Any lambda (by UID)
+const AllLambdas = "*"
+
+// Key of deployer
+type Key struct {
+	Name      string   `json:"name"`       // identity of deployer in audit log
+	PublicKey string   `json:"public_key"` // public key in authorized_keys format
+	Lambdas   []string `json:"lambdas"`    // permitted lambdas by UID or link (directory has the same name), * - all by UID
+}
+
+// Config of deployers
+type Config struct {
+	Keys []Key `json:"keys"`
+}
+
+// Load configuration of deployers from JSON file
+func LoadConfig(file string) (*Config, error) {
+	var cfg Config
+	if err := internal.ReadJson(file, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Minimal required platform features
+type Platform interface {
+	List() []application.Definition
+	FindByUID(uid string) (*application.Definition, error)
+	FindByLink(link string) (*application.Definition, error)
+	Start(ctx context.Context, lambda application.Lambda)
+	Profile() types.SecurityProfile
+	BindAliases(uid string, previous, current []string, force bool) (*application.Definition, error)
+}
+
+// New SFTP server. Deployers are authorized only by keys
+func New(platform Platform, hostKey ssh.Signer, config Config) (*Server, error) {
+	srv := &Server{platform: platform, deployers: map[string]*Key{}}
+	for i := range config.Keys {
+		key := &config.Keys[i]
+		public, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("parse public key of %s: %w", key.Name, err)
+		}
+		srv.deployers[string(public.Marshal())] = key
+	}
+	srv.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, public ssh.PublicKey) (*ssh.Permissions, error) {
+			_, ok := srv.deployers[string(public.Marshal())]
+			if !ok {
+				return nil, fmt.Errorf("unknown key of %s", conn.User())
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"key": string(public.Marshal())}}, nil
+		},
+	}
+	srv.config.AddHostKey(hostKey)
+	return srv, nil
+}
+
+type Server struct {
+	Journal   application.Journal // optional journal of changes, deploys are recorded on behalf of key name, failed authentications by address
+	platform  Platform
+	config    *ssh.ServerConfig
+	deployers map[string]*Key // by marshaled public key
+}
+
+// Serve connections until context canceled. Staged changes of open sessions are deployed when sessions are closed
+func (srv *Server) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (srv *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	server, channels, requests, err := ssh.NewServerConn(conn, srv.config)
+	if err != nil {
+		log.Println("[ERROR]", "sftp handshake with", conn.RemoteAddr(), "failed:", err)
+		var authErr *ssh.ServerAuthError
+		if errors.As(err, &authErr) && srv.Journal != nil {
+			srv.Journal.Record(application.Change{Kind: application.ChangeAuth, Summary: fmt.Sprint("sftp authentication from ", conn.RemoteAddr(), " failed")})
+		}
+		return
+	}
+	defer server.Close()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	key := srv.deployers[server.Permissions.Extensions["key"]]
+	log.Println("[AUDIT]", "sftp", key.Name, "logged in from", conn.RemoteAddr())
+	go ssh.DiscardRequests(requests)
+	defer log.Println("[AUDIT]", "sftp", key.Name, "logged out")
+	var sessions sync.WaitGroup
+	defer sessions.Wait() // staged changes are deployed when session is closed
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			log.Println("[ERROR]", "sftp accept channel of", key.Name+":", err)
+			continue
+		}
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			srv.serveChannel(key, channel, channelRequests)
+		}()
+	}
+}
+
+// only SFTP subsystem is supported: no shell, exec or legacy scp
+func (srv *Server) serveChannel(key *Key, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		_ = req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		sess, err := newSession(srv.platform, srv.Journal, key)
+		if err != nil {
+			log.Println("[ERROR]", "sftp session of", key.Name+":", err)
+			return
+		}
+		server := sftp.NewRequestServer(channel, sftp.Handlers{FileGet: sess, FilePut: sess, FileCmd: sess, FileList: sess})
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+			log.Println("[ERROR]", "sftp session of", key.Name+":", err)
+		}
+		sess.close()
+		// clients (scp in SFTP mode) treat missing status as failure, server closes the channel
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+		_ = server.Close()
+		return
+	}
+}
+
+// Load host key from file (OpenSSH or PEM format) or generate ed25519 key if file does not exist
+func HostKey(file string) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate host key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "trusted-cgi")
+	if err != nil {
+		return nil, fmt.Errorf("encode host key: %w", err)
+	}
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, fmt.Errorf("save host key: %w", err)
+	}
+	return ssh.NewSignerFromKey(private)
+}
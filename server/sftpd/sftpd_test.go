@@ -0,0 +1,251 @@
+package sftpd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/lambda"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type mockPlatform struct {
+	lock    sync.Mutex
+	defs    []application.Definition
+	started int
+}
+
+func (mp *mockPlatform) List() []application.Definition {
+	return mp.defs
+}
+
+func (mp *mockPlatform) FindByUID(uid string) (*application.Definition, error) {
+	for i := range mp.defs {
+		if mp.defs[i].UID == uid {
+			return &mp.defs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown lambda %s", uid)
+}
+
+func (mp *mockPlatform) FindByLink(link string) (*application.Definition, error) {
+	for i := range mp.defs {
+		if mp.defs[i].Aliases[link] {
+			return &mp.defs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown link %s", link)
+}
+
+func (mp *mockPlatform) Start(ctx context.Context, lambda application.Lambda) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.started++
+}
+
+func (mp *mockPlatform) Profile() types.SecurityProfile {
+	return types.SecurityProfile{}
+}
+
+func (mp *mockPlatform) BindAliases(uid string, previous, current []string, force bool) (*application.Definition, error) {
+	return mp.FindByUID(uid)
+}
+
+func (mp *mockPlatform) starts() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.started
+}
+
+type testEnv struct {
+	platform *mockPlatform
+	dirs     map[string]string // by UID
+	addr     string
+	signer   ssh.Signer
+}
+
+func newTestEnv(t *testing.T, lambdas ...string) *testEnv {
+	env := &testEnv{platform: &mockPlatform{}, dirs: map[string]string{}}
+	for _, uid := range []string{"a1b2", "c3d4"} {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = os.RemoveAll(dir) })
+		fn, err := lambda.DummyPublic(dir, "echo")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644))
+		env.dirs[uid] = dir
+		env.platform.defs = append(env.platform.defs, application.Definition{
+			UID:     uid,
+			Aliases: types.JsonStringSet{"link-" + uid: true},
+			Lambda:  fn,
+		})
+	}
+
+	env.signer = newSigner(t)
+	hostKey, err := HostKey(filepath.Join(t.TempDir(), "host_key"))
+	require.NoError(t, err)
+
+	srv, err := New(env.platform, hostKey, Config{Keys: []Key{{
+		Name:      "ci",
+		PublicKey: string(ssh.MarshalAuthorizedKey(env.signer.PublicKey())),
+		Lambdas:   lambdas,
+	}}})
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	env.addr = listener.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = srv.Serve(ctx, listener)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return env
+}
+
+func (env *testEnv) connect(t *testing.T, signer ssh.Signer) (*sftp.Client, error) {
+	conn, err := ssh.Dial("tcp", env.addr, &ssh.ClientConfig{
+		User:            "deploy",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return sftp.NewClient(conn)
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	return signer
+}
+
+func put(t *testing.T, client *sftp.Client, file string, content string) {
+	f, err := client.Create(file)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func names(list []os.FileInfo) []string {
+	var ans []string
+	for _, info := range list {
+		ans = append(ans, info.Name())
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+func TestServer_list(t *testing.T) {
+	env := newTestEnv(t, "link-a1b2", "unknown")
+	client, err := env.connect(t, env.signer)
+	require.NoError(t, err)
+
+	list, err := client.ReadDir("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link-a1b2"}, names(list), "only permitted lambdas")
+
+	list, err = client.ReadDir("/link-a1b2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"manifest.json", "old.txt"}, names(list))
+
+	_, err = client.ReadDir("/c3d4")
+	assert.Error(t, err, "not permitted")
+	_, err = client.ReadDir("/link-a1b2/../c3d4")
+	assert.Error(t, err, "not permitted")
+	_, err = client.Create("/file.txt")
+	assert.Error(t, err, "virtual root is read-only")
+
+	_, err = env.connect(t, newSigner(t))
+	assert.Error(t, err, "unknown key")
+}
+
+func TestServer_deployBySentinel(t *testing.T) {
+	env := newTestEnv(t, AllLambdas)
+	client, err := env.connect(t, env.signer)
+	require.NoError(t, err)
+
+	list, err := client.ReadDir("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1b2", "c3d4"}, names(list))
+
+	require.NoError(t, client.Mkdir("/a1b2/static"))
+	put(t, client, "/a1b2/static/index.html", "hello")
+	put(t, client, "/a1b2/../../a1b2/main.sh", "echo world")
+	require.NoError(t, client.Remove("/a1b2/old.txt"))
+	require.NoFileExists(t, filepath.Join(env.dirs["a1b2"], "static", "index.html"), "staged only")
+
+	put(t, client, "/a1b2/.deploy.tmp", "")
+	require.NoError(t, client.Rename("/a1b2/.deploy.tmp", "/a1b2/"+SentinelFile))
+
+	data, err := ioutil.ReadFile(filepath.Join(env.dirs["a1b2"], "static", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.FileExists(t, filepath.Join(env.dirs["a1b2"], "main.sh"), "traversal is contained in lambda")
+	assert.NoFileExists(t, filepath.Join(env.dirs["a1b2"], "old.txt"))
+	assert.NoFileExists(t, filepath.Join(env.dirs["a1b2"], SentinelFile))
+	assert.NoFileExists(t, filepath.Join(env.dirs["c3d4"], "main.sh"))
+	assert.Equal(t, 1, env.platform.starts())
+
+	_, err = client.Stat("/a1b2/" + SentinelFile)
+	assert.Error(t, err, "stage is reset after deploy")
+	require.NoError(t, client.Close())
+	assert.Equal(t, 1, env.platform.starts(), "nothing to deploy on close")
+}
+
+func TestServer_deployOnClose(t *testing.T) {
+	env := newTestEnv(t, "c3d4")
+	client, err := env.connect(t, env.signer)
+	require.NoError(t, err)
+	put(t, client, "/c3d4/new.txt", "new")
+	assert.Error(t, client.Symlink("/c3d4/new.txt", "/c3d4/link"), "links are not supported")
+	require.NoError(t, client.Close())
+
+	assert.Eventually(t, func() bool {
+		return env.platform.starts() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	data, err := ioutil.ReadFile(filepath.Join(env.dirs["c3d4"], "new.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+}
+
+func TestServer_invalidManifest(t *testing.T) {
+	env := newTestEnv(t, "a1b2")
+	client, err := env.connect(t, env.signer)
+	require.NoError(t, err)
+	put(t, client, "/a1b2/manifest.json", `{"run": ["echo"], "alerts": [{"name": "crash"}]}`)
+	put(t, client, "/a1b2/new.txt", "new")
+	f, err := client.Create("/a1b2/" + SentinelFile)
+	require.NoError(t, err)
+	assert.Error(t, f.Close(), "deploy rejected")
+	require.NoError(t, client.Close())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, env.platform.starts())
+	assert.NoFileExists(t, filepath.Join(env.dirs["a1b2"], "new.txt"))
+}
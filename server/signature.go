@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+var errNoSignatureSecret = errors.New("secret of webhook signature is empty")
+
+// reject request with 401 if signature of raw body does not match (see types.Manifest.WebhookSignature). Body is
+// buffered (not more than maximum payload or types.DefaultSchemaPayload) and passed to lambda as is
+func (srv *Server) verifySignature(req *types.Request, writer http.ResponseWriter, lambda *application.Definition, manifest types.Manifest, record *stats.Record) (*types.Request, error) {
+	if manifest.WebhookSignature == nil {
+		return req, nil
+	}
+	limit := manifest.MaximumPayload
+	if limit <= 0 {
+		limit = types.DefaultSchemaPayload
+	}
+	// one byte over the limit is enough to detect overflow
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, limit+1))
+	_ = req.Body.Close()
+	if err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		err = fmt.Errorf("%w: signed request exceeds %d bytes", application.ErrPayloadTooLarge, limit)
+		record.End = time.Now()
+		record.Err = err.Error()
+		record.Rejected = true
+		http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil, err
+	}
+	if err := srv.checkSignature(req, body, lambda, manifest); err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		record.Rejected = true
+		http.Error(writer, err.Error(), signatureStatus(err))
+		return nil, err
+	}
+	return req.WithBody(ioutil.NopCloser(bytes.NewReader(body))), nil
+}
+
+func (srv *Server) checkSignature(req *types.Request, body []byte, lambda *application.Definition, manifest types.Manifest) error {
+	if manifest.WebhookSignature == nil {
+		return nil
+	}
+	secret := srv.Platform.Expand(lambda.Lambda, manifest.WebhookSignature.Secret)
+	if secret == "" {
+		// misconfiguration should not open lambda
+		return errNoSignatureSecret
+	}
+	return manifest.WebhookSignature.Verify(req.Headers, body, secret, time.Now())
+}
+
+// mismatch is problem of client, empty secret - of server
+func signatureStatus(err error) int {
+	if errors.Is(err, types.ErrSignatureMismatch) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
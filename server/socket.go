@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// SocketPrefix marks address of listener as path of unix socket (ex: unix:/run/trusted-cgi.sock)
+const SocketPrefix = "unix:"
+
+// SocketPath is path of unix socket of listener address (false - address is not a unix socket)
+func SocketPath(address string) (string, bool) {
+	if !strings.HasPrefix(address, SocketPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, SocketPrefix), true
+}
+
+// Socket replaces address of connection of requests by unix socket (peer of socket has no address) by synthetic
+// address of client (IP): it is used by policies, rate limits, lockouts and stats as any other address. Forwarded
+// headers of local proxy are respected if synthetic address is in trusted proxies (or server is behind proxy)
+func Socket(handler http.Handler, address string) http.Handler {
+	remote := net.JoinHostPort(address, "0")
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		request.RemoteAddr = remote
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+// SocketOptions of created unix socket
+type SocketOptions struct {
+	Mode os.FileMode // permissions of socket file
+	UID  int         // owner of socket file (-1 - not changed)
+	GID  int         // group of socket file (-1 - not changed)
+}
+
+// ParseSocketOptions parses octal mode (ex: 0660) and owner of socket: user, user:group or :group by name or ID
+// (empty - owner of the process)
+func ParseSocketOptions(mode string, owner string) (SocketOptions, error) {
+	var options = SocketOptions{UID: -1, GID: -1}
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return options, fmt.Errorf("invalid mode of socket %q: octal permissions expected (ex: 0660)", mode)
+	}
+	options.Mode = os.FileMode(perm)
+	if owner == "" {
+		return options, nil
+	}
+	owner, group, _ := strings.Cut(owner, ":")
+	if owner != "" {
+		options.UID, err = lookupID(owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return options, fmt.Errorf("owner of socket: %w", err)
+		}
+	}
+	if group != "" {
+		options.GID, err = lookupID(group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return options, fmt.Errorf("group of socket: %w", err)
+		}
+	}
+	return options, nil
+}
+
+// numeric ID or ID of name
+func lookupID(value string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	id, err := lookup(value)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
+
+// ListenSocket listens unix socket with mode and owner of options. Stale socket of previous run is replaced, other
+// files are kept. Socket file is removed when listener is closed
+func ListenSocket(path string, options SocketOptions) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("socket %s: file exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("socket %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen socket %s: %w", path, err)
+	}
+	if err := os.Chmod(path, options.Mode); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("set mode of socket %s: %w", path, err)
+	}
+	if options.UID >= 0 || options.GID >= 0 {
+		if err := os.Chown(path, options.UID, options.GID); err != nil {
+			_ = listener.Close()
+			return nil, fmt.Errorf("set owner of socket %s: %w", path, err)
+		}
+	}
+	return listener, nil
+}
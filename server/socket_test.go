@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/server"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestListenSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trusted-cgi.sock")
+	options, err := server.ParseSocketOptions("0640", "")
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+	_, err = server.ListenSocket(path, options)
+	assert.Error(t, err, "regular file is not replaced")
+	require.NoError(t, os.Remove(path))
+
+	// socket of killed process is left
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listener, err := server.ListenSocket(path, options)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	trusted, err := types.ParseNetworks([]string{"127.0.0.1"})
+	require.NoError(t, err)
+	var addresses = make(chan string, 1)
+	srv := &http.Server{Handler: server.Socket(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		addresses <- types.ForwardedAddress(request, trusted)
+	}), "127.0.0.1")}
+	go srv.Serve(listener)
+
+	client := http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", path)
+	}}}
+	res, err := client.Get("http://localhost/")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, "127.0.0.1:0", <-addresses, "synthetic address of client")
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, "203.0.113.1", <-addresses, "forwarded by trusted synthetic address")
+
+	require.NoError(t, srv.Close())
+	assert.NoFileExists(t, path, "socket is removed on close")
+}
+
+func TestParseSocketOptions(t *testing.T) {
+	options, err := server.ParseSocketOptions("0660", "")
+	require.NoError(t, err)
+	assert.Equal(t, server.SocketOptions{Mode: 0660, UID: -1, GID: -1}, options)
+
+	options, err = server.ParseSocketOptions("600", "1000:33")
+	require.NoError(t, err)
+	assert.Equal(t, server.SocketOptions{Mode: 0600, UID: 1000, GID: 33}, options)
+
+	options, err = server.ParseSocketOptions("0660", ":33")
+	require.NoError(t, err)
+	assert.Equal(t, server.SocketOptions{Mode: 0660, UID: -1, GID: 33}, options)
+
+	_, err = server.ParseSocketOptions("0999", "")
+	assert.Error(t, err)
+	_, err = server.ParseSocketOptions("0660", "no-such-user-of-test")
+	assert.Error(t, err)
+}
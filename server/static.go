@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// request of static file of lambda (see Manifest.StaticDirs). Other methods are passed to lambda
+func isStatic(req *types.Request, manifest types.Manifest) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	_, _, ok := manifest.StaticFile(req.PathInfo())
+	return ok
+}
+
+// serve static file of lambda without invocation: Content-Type by extension (or content), ETag and Last-Modified by
+// file, conditional and range requests. Not servable files are 404
+func (srv *Server) serveStatic(req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) {
+	request := &http.Request{Method: req.Method, Header: make(http.Header, len(req.Headers))}
+	for k, v := range req.Headers {
+		request.Header.Set(k, v)
+	}
+	err := lambda.Lambda.ServeStatic(req.PathInfo(), func(content io.ReadSeeker, info fs.FileInfo) error {
+		writer.Header().Set("ETag", `"`+strconv.FormatInt(info.ModTime().UnixNano(), 36)+"-"+strconv.FormatInt(info.Size(), 36)+`"`)
+		http.ServeContent(writer, request, info.Name(), info.ModTime(), content)
+		return nil
+	})
+	record.End = time.Now()
+	if errors.Is(err, fs.ErrNotExist) {
+		record.Err = "static file not found"
+		record.Rejected = true
+		http.NotFound(writer, request)
+	} else if err != nil {
+		record.Err = err.Error()
+		http.Error(writer, "failed to serve static file", http.StatusInternalServerError)
+	}
+}
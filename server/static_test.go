@@ -0,0 +1,82 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestHandler_staticDirs(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	require.NoError(t, err)
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Run:        []string{"/bin/sh", "-c", "printf dynamic"},
+		Methods:    []string{http.MethodPost},
+		StaticDirs: map[string]string{"/assets/": "public"},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(srv.Dir, uid, "public"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srv.Dir, uid, "public", "app.css"), []byte("body {}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srv.Dir, uid, "public", ".secret"), []byte("secret"), 0644))
+	_, err = srv.Server.Platform.Link(uid, "site")
+	require.NoError(t, err)
+
+	invoke := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "https://example.com"+path, http.NoBody)
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, path := range []string{"/a/" + uid + "/assets/app.css", "/l/site/assets/app.css"} {
+		rr := invoke(http.MethodGet, path, nil)
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+		assert.Equal(t, "body {}", rr.Body.String())
+		assert.Contains(t, rr.Header().Get("Content-Type"), "text/css")
+		assert.NotEmpty(t, rr.Header().Get("Last-Modified"))
+		etag := rr.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		rr = invoke(http.MethodGet, path, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+	}
+
+	rr := invoke(http.MethodGet, "/a/"+uid+"/assets/.secret", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = invoke(http.MethodGet, "/a/"+uid+"/assets/missing.css", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = invoke(http.MethodGet, "/a/"+uid+"/other", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code, "not mapped path is passed to lambda")
+	rr = invoke(http.MethodPost, "/a/"+uid+"/assets/app.css", nil)
+	assert.Equal(t, "dynamic", rr.Body.String(), "only GET and HEAD are static")
+
+	t.Run("policy", func(t *testing.T) {
+		policy, err := srv.Server.Policies.Create("private", application.PolicyDefinition{Tokens: map[string]string{"secret": "test"}})
+		require.NoError(t, err)
+		require.NoError(t, srv.Server.Policies.Apply(uid, policy.ID))
+		rr := invoke(http.MethodGet, "/l/site/assets/app.css", nil)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		rr = invoke(http.MethodGet, "/l/site/assets/app.css", map[string]string{"Authorization": "secret"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "body {}", rr.Body.String())
+	})
+}
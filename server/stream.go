@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// Streamed output of lambda is flushed to client not later than flushInterval after it is written
+const flushInterval = 100 * time.Millisecond
+
+// Limited output (see Manifest.MaximumResponse) is held before response is started while it is not bigger than
+// holdWindow and not older than flushInterval, so overrun of short output is still answered by status
+const holdWindow = 64 * 1024
+
+// writer of streamed output of lambda: response is started by the first write (limited output - after hold window)
+// and flushed periodically, so progressive output is delivered while it is produced. Timer flushes from own
+// goroutine, so writes and flushes are serialized
+type streamWriter struct {
+	lock    sync.Mutex
+	output  io.WriteCloser // body of response (opens response on the first write)
+	flush   func()         // flush sent body to client
+	hold    int            // maximum size of held output (zero - response is started by the first write)
+	lines   bool           // flush after every written line (event streams), partial line is flushed by timer
+	held    bytes.Buffer   // output before response is started
+	started bool
+	halted  bool // invocation is finished: timer doesn't start response anymore
+	pending bool // output is written but not flushed
+	timer   *time.Timer
+	onWrite func()  // optional notification about output of lambda
+	tail    [2]byte // the last bytes of sent output: the last event of server is separated from partial event
+}
+
+func newStreamWriter(output io.WriteCloser, flush func(), hold int) *streamWriter {
+	return &streamWriter{output: output, flush: flush, hold: hold}
+}
+
+func (sw *streamWriter) Write(data []byte) (int, error) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if sw.halted {
+		return 0, io.ErrClosedPipe
+	}
+	sw.schedule()
+	if !sw.started && sw.held.Len()+len(data) <= sw.hold {
+		if sw.onWrite != nil {
+			sw.onWrite()
+		}
+		return sw.held.Write(data)
+	}
+	if err := sw.start(); err != nil {
+		return 0, err
+	}
+	n, err := sw.output.Write(data)
+	sw.remember(data[:n])
+	if sw.onWrite != nil {
+		sw.onWrite()
+	}
+	if err == nil && sw.lines && bytes.IndexByte(data, '\n') >= 0 {
+		sw.pending = false
+		sw.flush()
+	}
+	return n, err
+}
+
+// stop timer after invocation. Returns true if response is started, otherwise output is still held (see Held) and
+// response could be answered by status
+func (sw *streamWriter) halt() bool {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	sw.halted = true
+	if sw.timer != nil {
+		sw.timer.Stop()
+		sw.timer = nil
+	}
+	return sw.started
+}
+
+// send the last event of server and halt the stream, so the following output of lambda is rejected. Partial event of
+// lambda is terminated before the event. Returns false if the stream is already halted
+func (sw *streamWriter) finish(event []byte) bool {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if sw.halted {
+		return false
+	}
+	sw.halted = true
+	if sw.timer != nil {
+		sw.timer.Stop()
+		sw.timer = nil
+	}
+	if err := sw.start(); err != nil {
+		return true
+	}
+	switch {
+	case sw.tail == [2]byte{} || sw.tail == [2]byte{'\n', '\n'}:
+	case sw.tail[1] == '\n':
+		event = append([]byte("\n"), event...)
+	default:
+		event = append([]byte("\n\n"), event...)
+	}
+	if _, err := sw.output.Write(event); err == nil {
+		sw.pending = false
+		sw.flush()
+	}
+	return true
+}
+
+// Held output of not started response
+func (sw *streamWriter) Held() []byte {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	return sw.held.Bytes()
+}
+
+// Close response after halt: held output is sent
+func (sw *streamWriter) Close() error {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if err := sw.start(); err != nil {
+		_ = sw.output.Close()
+		return err
+	}
+	return sw.output.Close()
+}
+
+// start response by held output. Should be called under lock
+func (sw *streamWriter) start() error {
+	if sw.started {
+		return nil
+	}
+	sw.started = true
+	if sw.held.Len() == 0 {
+		return nil
+	}
+	_, err := sw.output.Write(sw.held.Bytes())
+	sw.remember(sw.held.Bytes())
+	sw.held.Reset()
+	return err
+}
+
+// keep the last bytes of sent output. Should be called under lock
+func (sw *streamWriter) remember(data []byte) {
+	switch len(data) {
+	case 0:
+	case 1:
+		sw.tail = [2]byte{sw.tail[1], data[0]}
+	default:
+		sw.tail = [2]byte{data[len(data)-2], data[len(data)-1]}
+	}
+}
+
+// flush (and start response) by timer. Should be called under lock
+func (sw *streamWriter) schedule() {
+	sw.pending = true
+	if sw.timer == nil {
+		sw.timer = time.AfterFunc(flushInterval, sw.tick)
+	}
+}
+
+func (sw *streamWriter) tick() {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	sw.timer = nil
+	if sw.halted || !sw.pending {
+		return
+	}
+	sw.pending = false
+	if sw.start() == nil {
+		sw.flush()
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// registry of open streaming (sse) invocations per lambda. Streams are closed by the last event of server on shutdown
+// (server context is done or server is draining), by stream time limit, by idle timeout and by lost access (see
+// Manifest.StreamReauthorize).
+type streams struct {
+	ctx      context.Context // server context
+	shutdown func()          // close all streams
+	lock     sync.Mutex
+	open     map[string]int
+}
+
+func newStreams(ctx context.Context) *streams {
+	ctx, cancel := context.WithCancel(ctx)
+	return &streams{ctx: ctx, shutdown: cancel, open: make(map[string]int)}
+}
+
+// OpenStreams is number of open streaming invocations per lambda (lambdas with closed streams have zero).
+func (srv *Server) OpenStreams() map[string]int {
+	if srv.streams == nil {
+		return nil
+	}
+	srv.streams.lock.Lock()
+	defer srv.streams.lock.Unlock()
+	ans := make(map[string]int, len(srv.streams.open))
+	for uid, n := range srv.streams.open {
+		ans[uid] = n
+	}
+	return ans
+}
+
+// register stream of invocation and start watching it. Returned context of invocation is cancelled when stream is
+// closed by server. Authorize re-evaluates access to lambda.
+func (s *streams) start(ctx context.Context, uid string, manifest types.Manifest, writer *streamWriter, authorize func() error) (context.Context, *streamSession) {
+	s.lock.Lock()
+	s.open[uid]++
+	s.lock.Unlock()
+
+	session := &streamSession{
+		registry: s,
+		parent:   ctx,
+		uid:      uid,
+		writer:   writer,
+		begin:    time.Now(),
+		lifetime: manifest.InvocationLimit(),
+		done:     make(chan struct{}),
+	}
+	ctx, session.cancel = context.WithCancelCause(ctx)
+	session.stops = append(session.stops, context.AfterFunc(s.ctx, func() {
+		session.close(types.StreamClosedShutdown)
+	}))
+	if session.lifetime > 0 {
+		timer := time.AfterFunc(session.lifetime, func() {
+			session.close(types.StreamClosedLifetime)
+		})
+		session.stops = append(session.stops, timer.Stop)
+	}
+	if idle := time.Duration(manifest.StreamIdleTimeout); idle > 0 {
+		timer := time.AfterFunc(idle, func() {
+			session.close(types.StreamClosedIdle)
+		})
+		writer.onWrite = func() { timer.Reset(idle) }
+		session.stops = append(session.stops, timer.Stop)
+	}
+	if interval := time.Duration(manifest.StreamReauthorize); interval > 0 {
+		go session.reauthorize(interval, authorize)
+	}
+	return ctx, session
+}
+
+// streaming invocation watched by server
+type streamSession struct {
+	registry *streams
+	parent   context.Context // context of request: done when client is gone or on shutdown
+	uid      string
+	writer   *streamWriter
+	cancel   context.CancelCauseFunc
+	begin    time.Time
+	lifetime time.Duration // zero - not limited
+	stops    []func() bool
+	done     chan struct{} // closed after invocation
+	lock     sync.Mutex
+	reason   string // reason of close by server (empty - stream is not closed by server)
+}
+
+func (ss *streamSession) reauthorize(interval time.Duration, authorize func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ss.done:
+			return
+		case <-ticker.C:
+			if err := authorize(); err != nil {
+				ss.close(types.StreamClosedUnauthorized)
+				return
+			}
+		}
+	}
+}
+
+// close stream by server: the last event with reason is sent to client and invocation is cancelled (lambda is
+// stopped). Only the first reason is applied
+func (ss *streamSession) close(reason string) {
+	ss.lock.Lock()
+	if ss.reason != "" {
+		ss.lock.Unlock()
+		return
+	}
+	ss.reason = reason
+	ss.lock.Unlock()
+	ss.writer.finish([]byte("event: " + types.StreamCloseEvent + "\ndata: " + reason + "\n\n"))
+	ss.cancel(fmt.Errorf("stream closed by server: %s", reason))
+}
+
+// stop watching after invocation and unregister stream. Stream finished by time limit or by shutdown (lambda could
+// exit before server notices) is closed by the last event as well. Returns reason of close by server (empty - stream
+// is finished by lambda or by client)
+func (ss *streamSession) stop() string {
+	close(ss.done)
+	for _, stop := range ss.stops {
+		stop()
+	}
+	if ss.registry.ctx.Err() != nil {
+		ss.close(types.StreamClosedShutdown)
+	} else if ss.parent.Err() == nil && ss.lifetime > 0 && time.Since(ss.begin) >= ss.lifetime {
+		ss.close(types.StreamClosedLifetime)
+	}
+	ss.cancel(nil)
+
+	ss.registry.lock.Lock()
+	ss.registry.open[ss.uid]--
+	ss.registry.lock.Unlock()
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	return ss.reason
+}
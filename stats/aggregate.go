@@ -0,0 +1,275 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultAggregationRange = 24 * time.Hour // range of aggregation without start
+	DefaultBuckets          = 60             // number of buckets of aggregation without size of bucket
+	MaxBuckets              = 1000           // bucket is widened to keep number of buckets under the limit
+)
+
+// Dimensions of grouping of aggregation
+const (
+	GroupByAlias   = "alias"   // by Record.Entry
+	GroupByTrigger = "trigger" // by Record.Source
+)
+
+// Aggregated records of time range by buckets of time. Counters are re-weighted by sampling rate (see Record.Weight)
+type Aggregation struct {
+	UID     string        `json:"uid,omitempty"`    // records of lambda (empty - of all lambdas)
+	Since   time.Time     `json:"since"`            // start of range (inclusive)
+	Until   time.Time     `json:"until"`            // end of range (exclusive)
+	Bucket  time.Duration `json:"bucket"`           // size of bucket
+	Buckets []Bucket      `json:"buckets"`          // all buckets of range from the oldest, also empty
+	Total   Bucket        `json:"total"`            // all records of range
+	Group   string        `json:"group,omitempty"`  // dimension of grouping (see GroupBy* constants)
+	Groups  []Group       `json:"groups,omitempty"` // records by value of dimension from the most called
+}
+
+// Aggregated records with the same value of dimension
+type Group struct {
+	Key     string   `json:"key"`     // alias (or AliasDirect) or trigger, TriggerUnknown for old records
+	Buckets []Bucket `json:"buckets"` // the same buckets as of aggregation
+	Total   Bucket   `json:"total"`   // all records of group
+}
+
+// Aggregated records ended in bucket of time
+type Bucket struct {
+	Begin   time.Time `json:"begin"`   // start of bucket (inclusive), bucket is ended by start of the next one
+	Calls   int64     `json:"calls"`   // all requests, also rejected
+	Errors  Errors    `json:"errors"`  // failed requests by category
+	Latency Latency   `json:"latency"` // duration of requests which are not rejected
+}
+
+// Failed requests by category. Failed attempt followed by retry is not an error
+type Errors struct {
+	Script   int64 `json:"script"`   // invocation failed (exit code, crash, limits), except timeout
+	Timeout  int64 `json:"timeout"`  // invocation killed by time limit of manifest
+	Rejected int64 `json:"rejected"` // request rejected without invocation: rate limit (429), concurrency, policy
+	Denied   int64 `json:"denied"`   // request rejected by allowed networks of client (403)
+}
+
+// Sum of all categories
+func (e Errors) Sum() int64 {
+	return e.Script + e.Timeout + e.Rejected + e.Denied
+}
+
+// Latency of requests. Percentiles are estimated by histogram with relative error about 5%
+type Latency struct {
+	Min time.Duration `json:"min"`
+	Avg time.Duration `json:"avg"`
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	Max time.Duration `json:"max"`
+}
+
+// Aggregate records of reader matched by query (limit is ignored) by buckets of size. Zero end of range is now,
+// zero start is DefaultAggregationRange before the end. Zero size of bucket splits range to DefaultBuckets, size is
+// rounded up to seconds. Buckets are aligned by size (see time.Time.Truncate), so the first and the last buckets
+// could be partial. Records are scanned, not loaded: memory is limited by number of buckets (and of groups).
+// Non-empty group splits records also by dimension (see GroupBy* constants)
+func Aggregate(reader Reader, query Query, bucket time.Duration, group string) (*Aggregation, error) {
+	var key func(record *Record) string
+	switch group {
+	case "":
+	case GroupByAlias:
+		key = (*Record).Entry
+	case GroupByTrigger:
+		key = (*Record).Source
+	default:
+		return nil, fmt.Errorf("unknown group %q: expected %s or %s", group, GroupByAlias, GroupByTrigger)
+	}
+	if query.Until.IsZero() {
+		query.Until = time.Now()
+	}
+	if query.Since.IsZero() {
+		query.Since = query.Until.Add(-DefaultAggregationRange)
+	}
+	span := query.Until.Sub(query.Since)
+	if span <= 0 {
+		return &Aggregation{UID: query.UID, Since: query.Since, Until: query.Until, Bucket: bucket, Buckets: []Bucket{}, Group: group}, nil
+	}
+	if bucket <= 0 {
+		bucket = span / DefaultBuckets
+	}
+	if minimal := (span + MaxBuckets - 1) / MaxBuckets; bucket < minimal {
+		bucket = minimal
+	}
+	if bucket%time.Second != 0 {
+		bucket = bucket.Truncate(time.Second) + time.Second
+	}
+
+	first := query.Since.Truncate(bucket)
+	count := int(query.Until.Sub(first)+bucket-1) / int(bucket)
+	all := newSeries(count)
+	var groups map[string]*series
+	if key != nil {
+		groups = make(map[string]*series)
+	}
+	err := reader.Scan(query, func(record *Record) bool {
+		index := int(record.End.Sub(first) / bucket)
+		all.add(index, record)
+		if groups == nil {
+			return true
+		}
+		name := key(record)
+		grouped, ok := groups[name]
+		if !ok {
+			grouped = newSeries(count)
+			groups[name] = grouped
+		}
+		grouped.add(index, record)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	ans := &Aggregation{
+		UID:    query.UID,
+		Since:  query.Since,
+		Until:  query.Until,
+		Bucket: bucket,
+		Group:  group,
+	}
+	ans.Buckets, ans.Total = all.result(first, bucket, query.Since)
+	for name, grouped := range groups {
+		item := Group{Key: name}
+		item.Buckets, item.Total = grouped.result(first, bucket, query.Since)
+		ans.Groups = append(ans.Groups, item)
+	}
+	sort.Slice(ans.Groups, func(i, j int) bool {
+		a, b := ans.Groups[i], ans.Groups[j]
+		if a.Total.Calls != b.Total.Calls {
+			return a.Total.Calls > b.Total.Calls
+		}
+		return a.Key < b.Key
+	})
+	return ans, nil
+}
+
+// buckets and total of records
+type series struct {
+	buckets []accumulator
+	total   accumulator
+}
+
+func newSeries(buckets int) *series {
+	return &series{buckets: make([]accumulator, buckets)}
+}
+
+func (s *series) add(index int, record *Record) {
+	s.buckets[index].add(record)
+	s.total.add(record)
+}
+
+func (s *series) result(first time.Time, bucket time.Duration, since time.Time) ([]Bucket, Bucket) {
+	buckets := make([]Bucket, len(s.buckets))
+	for i := range s.buckets {
+		buckets[i] = s.buckets[i].result(first.Add(time.Duration(i) * bucket))
+	}
+	return buckets, s.total.result(since)
+}
+
+// latencies are counted by geometric bins from histogramBase: bin i (from 1) contains durations up to
+// histogramBase * histogramRatio^i, the last bin contains all longer durations (~11 hours)
+const (
+	histogramBase  = time.Microsecond
+	histogramRatio = 1.1
+	histogramBins  = 256
+)
+
+type accumulator struct {
+	calls    int64
+	errors   Errors
+	measured int64   // weight of records with latency
+	sum      float64 // weighted sum of latencies
+	min, max time.Duration
+	bins     *[histogramBins]int64 // allocated by the first latency
+}
+
+func (acc *accumulator) add(record *Record) {
+	weight := int64(record.Weight())
+	acc.calls += weight
+	switch {
+	case record.Denied:
+		acc.errors.Denied += weight
+	case record.Rejected:
+		acc.errors.Rejected += weight
+	case record.Failed() && record.Timeout:
+		acc.errors.Timeout += weight
+	case record.Failed():
+		acc.errors.Script += weight
+	}
+	if record.Rejected {
+		return
+	}
+	latency := record.End.Sub(record.Begin)
+	if latency < 0 {
+		latency = 0
+	}
+	if acc.bins == nil {
+		acc.bins = new([histogramBins]int64)
+		acc.min, acc.max = latency, latency
+	}
+	if latency < acc.min {
+		acc.min = latency
+	}
+	if latency > acc.max {
+		acc.max = latency
+	}
+	acc.measured += weight
+	acc.sum += float64(latency) * float64(weight)
+	acc.bins[histogramBin(latency)] += weight
+}
+
+func (acc *accumulator) result(begin time.Time) Bucket {
+	bucket := Bucket{Begin: begin, Calls: acc.calls, Errors: acc.errors}
+	if acc.measured == 0 {
+		return bucket
+	}
+	bucket.Latency = Latency{
+		Min: acc.min,
+		Avg: time.Duration(acc.sum / float64(acc.measured)),
+		P50: acc.quantile(0.5),
+		P95: acc.quantile(0.95),
+		Max: acc.max,
+	}
+	return bucket
+}
+
+// estimation of quantile by middle (geometric) of bin, limited by observed minimum and maximum
+func (acc *accumulator) quantile(q float64) time.Duration {
+	rank := int64(math.Ceil(q * float64(acc.measured)))
+	var seen int64
+	for i, n := range acc.bins {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		estimated := time.Duration(float64(histogramBase) * math.Pow(histogramRatio, float64(i)-0.5))
+		if estimated < acc.min {
+			return acc.min
+		}
+		if estimated > acc.max {
+			return acc.max
+		}
+		return estimated
+	}
+	return acc.max
+}
+
+func histogramBin(latency time.Duration) int {
+	if latency <= histogramBase {
+		return 0
+	}
+	bin := int(math.Ceil(math.Log(float64(latency)/float64(histogramBase)) / math.Log(histogramRatio)))
+	if bin >= histogramBins {
+		return histogramBins - 1
+	}
+	return bin
+}
@@ -0,0 +1,96 @@
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+)
+
+func TestAggregate(t *testing.T) {
+	tracker := memlog.New(1000)
+	since := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	add := func(offset, latency time.Duration, modify func(record *stats.Record)) {
+		record := stats.Record{UID: "a", Begin: since.Add(offset - latency), End: since.Add(offset)}
+		if modify != nil {
+			modify(&record)
+		}
+		tracker.Track(record)
+	}
+	// the first minute: 100 calls from 1ms to 100ms
+	for i := 1; i <= 100; i++ {
+		add(time.Second, time.Duration(i)*time.Millisecond, nil)
+	}
+	// the second minute: failures by category, sampled record represents 10 calls
+	add(time.Minute+time.Second, 5*time.Second, func(record *stats.Record) { record.Err = "killed"; record.Timeout = true })
+	add(time.Minute+time.Second, time.Second, func(record *stats.Record) { record.Err = "exit status 1"; record.Rate = 10 })
+	add(time.Minute+time.Second, time.Second, func(record *stats.Record) { record.Err = "exit status 1"; record.Retried = true })
+	add(time.Minute+time.Second, 0, func(record *stats.Record) { record.Err = "rate limit"; record.Rejected, record.Throttled = true, true })
+	add(time.Minute+time.Second, 0, func(record *stats.Record) { record.Err = "denied"; record.Rejected, record.Denied = true, true })
+	// out of range and of another lambda
+	add(3*time.Minute, time.Millisecond, nil)
+	add(time.Second, time.Millisecond, func(record *stats.Record) { record.UID = "b" })
+
+	aggregation, err := stats.Aggregate(tracker, stats.Query{UID: "a", Since: since, Until: since.Add(3 * time.Minute)}, time.Minute, "")
+	require.NoError(t, err)
+	require.Len(t, aggregation.Buckets, 3)
+	assert.Equal(t, time.Minute, aggregation.Bucket)
+	first := aggregation.Buckets[0]
+	assert.Equal(t, since, first.Begin.UTC())
+	assert.Equal(t, int64(100), first.Calls)
+	assert.Zero(t, first.Errors.Sum())
+	assert.Equal(t, time.Millisecond, first.Latency.Min)
+	assert.Equal(t, 100*time.Millisecond, first.Latency.Max)
+	assert.Equal(t, 50500*time.Microsecond, first.Latency.Avg)
+	assert.InEpsilon(t, float64(50*time.Millisecond), float64(first.Latency.P50), 0.05)
+	assert.InEpsilon(t, float64(95*time.Millisecond), float64(first.Latency.P95), 0.05)
+
+	second := aggregation.Buckets[1]
+	assert.Equal(t, int64(14), second.Calls)
+	assert.Equal(t, stats.Errors{Script: 10, Timeout: 1, Rejected: 1, Denied: 1}, second.Errors)
+	assert.Equal(t, time.Second, second.Latency.Min, "rejected requests have no latency")
+	assert.Equal(t, 5*time.Second, second.Latency.Max)
+
+	assert.Zero(t, aggregation.Buckets[2].Calls, "empty buckets are kept")
+	assert.Equal(t, int64(114), aggregation.Total.Calls)
+	assert.Equal(t, int64(13), aggregation.Total.Errors.Sum())
+
+	// grouped by alias: records without trigger are tracked by previous versions
+	add(2*time.Minute, time.Millisecond, func(record *stats.Record) { record.Trigger = stats.TriggerHTTP })
+	add(2*time.Minute, time.Millisecond, func(record *stats.Record) {
+		record.Trigger, record.Request.Alias, record.Rate = stats.TriggerHTTP, "shop", 3
+	})
+	add(2*time.Minute, time.Millisecond, func(record *stats.Record) { record.Trigger = stats.TriggerSchedule })
+	aggregation, err = stats.Aggregate(tracker, stats.Query{UID: "a", Since: since, Until: since.Add(3 * time.Minute)}, time.Minute, stats.GroupByAlias)
+	require.NoError(t, err)
+	assert.Equal(t, int64(119), aggregation.Total.Calls)
+	require.Len(t, aggregation.Groups, 3)
+	assert.Equal(t, stats.TriggerUnknown, aggregation.Groups[0].Key)
+	assert.Equal(t, int64(114), aggregation.Groups[0].Total.Calls)
+	assert.Equal(t, "shop", aggregation.Groups[1].Key)
+	assert.Equal(t, int64(3), aggregation.Groups[1].Total.Calls)
+	assert.Equal(t, stats.AliasDirect, aggregation.Groups[2].Key)
+	assert.Equal(t, int64(2), aggregation.Groups[2].Buckets[2].Calls)
+	require.Len(t, aggregation.Groups[2].Buckets, 3)
+
+	aggregation, err = stats.Aggregate(tracker, stats.Query{UID: "a", Since: since, Until: since.Add(3 * time.Minute)}, time.Minute, stats.GroupByTrigger)
+	require.NoError(t, err)
+	require.Len(t, aggregation.Groups, 3)
+	assert.Equal(t, []string{stats.TriggerUnknown, stats.TriggerHTTP, stats.TriggerSchedule},
+		[]string{aggregation.Groups[0].Key, aggregation.Groups[1].Key, aggregation.Groups[2].Key})
+	assert.Equal(t, int64(4), aggregation.Groups[1].Total.Calls)
+
+	_, err = stats.Aggregate(tracker, stats.Query{}, time.Minute, "method")
+	assert.Error(t, err)
+
+	// number of buckets is limited
+	aggregation, err = stats.Aggregate(tracker, stats.Query{Since: since, Until: since.Add(2000 * time.Second)}, time.Second, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, aggregation.Bucket)
+	assert.Len(t, aggregation.Buckets, 1000)
+	assert.Equal(t, int64(121), aggregation.Total.Calls)
+}
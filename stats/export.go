@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Formats of export
+const (
+	ExportCSV    = "csv"    // header row, then one row per record
+	ExportNDJSON = "ndjson" // one JSON object per line
+)
+
+// Exported record. Fields are stable: new fields could be added to the end, existing are not renamed or removed
+type Exported struct {
+	Time       time.Time `json:"time"` // end of invocation
+	UID        string    `json:"uid"`
+	Alias      string    `json:"alias"`   // see Record.Entry
+	Trigger    string    `json:"trigger"` // see Record.Source
+	Status     int       `json:"status"`  // HTTP status of response (zero - nothing is sent)
+	DurationMs float64   `json:"duration_ms"`
+	Payload    int64     `json:"payload"` // size of request body in bytes
+	Size       int64     `json:"size"`    // size of response body in bytes
+	Error      string    `json:"error"`
+	RequestID  string    `json:"request_id"`
+}
+
+// columns of CSV export: names of fields of Exported in JSON
+var exportColumns = []string{"time", "uid", "alias", "trigger", "status", "duration_ms", "payload", "size", "error", "request_id"}
+
+// NewExported is exported view of record
+func NewExported(record *Record) Exported {
+	return Exported{
+		Time:       record.End,
+		UID:        record.UID,
+		Alias:      record.Entry(),
+		Trigger:    record.Source(),
+		Status:     record.Status,
+		DurationMs: float64(record.End.Sub(record.Begin)) / float64(time.Millisecond),
+		Payload:    record.Payload,
+		Size:       record.Size,
+		Error:      record.Err,
+		RequestID:  record.Request.ID,
+	}
+}
+
+// Exporter writes records in format of export. Output is buffered: Flush should be called after the last record
+type Exporter interface {
+	Write(record *Record) error
+	Flush() error
+}
+
+// NewExporter of records to output in format (see Export* constants). CSV exporter writes header row at once
+func NewExporter(format string, out io.Writer) (Exporter, error) {
+	switch format {
+	case ExportCSV:
+		writer := csv.NewWriter(out)
+		if err := writer.Write(exportColumns); err != nil {
+			return nil, err
+		}
+		return &csvExporter{writer: writer}, nil
+	case ExportNDJSON:
+		buffered := bufio.NewWriter(out)
+		return &ndjsonExporter{buffer: buffered, encoder: json.NewEncoder(buffered)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format of export %q: expected %s or %s", format, ExportCSV, ExportNDJSON)
+	}
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+	row    []string
+}
+
+func (ce *csvExporter) Write(record *Record) error {
+	item := NewExported(record)
+	ce.row = append(ce.row[:0],
+		item.Time.Format(time.RFC3339Nano),
+		item.UID,
+		item.Alias,
+		item.Trigger,
+		strconv.Itoa(item.Status),
+		strconv.FormatFloat(item.DurationMs, 'f', -1, 64),
+		strconv.FormatInt(item.Payload, 10),
+		strconv.FormatInt(item.Size, 10),
+		item.Error,
+		item.RequestID,
+	)
+	return ce.writer.Write(ce.row)
+}
+
+func (ce *csvExporter) Flush() error {
+	ce.writer.Flush()
+	return ce.writer.Error()
+}
+
+type ndjsonExporter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (ne *ndjsonExporter) Write(record *Record) error {
+	return ne.encoder.Encode(NewExported(record))
+}
+
+func (ne *ndjsonExporter) Flush() error {
+	return ne.buffer.Flush()
+}
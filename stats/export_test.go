@@ -0,0 +1,68 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestNewExporter(t *testing.T) {
+	end := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	record := stats.Record{
+		UID:     "a",
+		Begin:   end.Add(-1500 * time.Microsecond),
+		End:     end,
+		Status:  500,
+		Payload: 12,
+		Size:    3,
+		Err:     "exit status 1, stderr:\n\"failed\"",
+		Request: types.Request{ID: "req-1", Alias: "hello"},
+		Trigger: stats.TriggerHTTP,
+	}
+
+	var buffer bytes.Buffer
+	exporter, err := stats.NewExporter(stats.ExportCSV, &buffer)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(&record))
+	require.NoError(t, exporter.Flush())
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"time", "uid", "alias", "trigger", "status", "duration_ms", "payload", "size", "error", "request_id"},
+		{"2024-05-03T10:00:00Z", "a", "hello", "http", "500", "1.5", "12", "3", record.Err, "req-1"},
+	}, rows, "quoted error should be read back as is")
+
+	buffer.Reset()
+	exporter, err = stats.NewExporter(stats.ExportNDJSON, &buffer)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(&record))
+	require.NoError(t, exporter.Write(&stats.Record{UID: "b", End: end}))
+	require.NoError(t, exporter.Flush())
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[1], &fields))
+	assert.Len(t, fields, 10, "empty fields are exported too")
+	assert.Equal(t, "unknown", fields["trigger"], "record of previous version")
+
+	_, err = stats.NewExporter("xml", &buffer)
+	assert.Error(t, err)
+}
+
+func TestRecord_Entry(t *testing.T) {
+	assert.Equal(t, "shop", (&stats.Record{Trigger: stats.TriggerHTTP, Request: types.Request{Alias: "shop"}}).Entry())
+	assert.Equal(t, stats.AliasDirect, (&stats.Record{Trigger: stats.TriggerHTTP}).Entry())
+	assert.Equal(t, stats.AliasDirect, (&stats.Record{Trigger: stats.TriggerSchedule}).Entry())
+	assert.Equal(t, "shop", (&stats.Record{Request: types.Request{Alias: "shop"}}).Entry(), "alias is recorded by previous versions")
+	assert.Equal(t, stats.TriggerUnknown, (&stats.Record{}).Entry())
+	assert.Equal(t, stats.TriggerUnknown, (&stats.Record{}).Source())
+	assert.Equal(t, stats.TriggerQueue, (&stats.Record{Trigger: stats.TriggerQueue}).Source())
+}
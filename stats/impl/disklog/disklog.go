@@ -0,0 +1,496 @@
+// Package disklog keeps invocation records on disk, so history survives restarts. Records are appended by batches
+// (see Store.Dump) to segments of directory in insertion order:
+//
+//	<sequence>.seg    frames of records: length (uint32 LE), CRC-32 of record, record in MessagePack
+//
+// The newest segment is active, others are sealed. Sealed segments are pruned in background by retention (see
+// stats.Retention, kept in retention.json of directory): the oldest records over limits are removed, partially pruned
+// segment is rewritten, small neighbours are merged. Torn frame at the end of segment (crash during write) is
+// truncated on open.
+package disklog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+)
+
+const (
+	segmentExt    = ".seg"
+	retentionFile = "retention.json"
+	segmentSize   = 1 << 20          // size of active segment to seal it
+	frameHeader   = 8                // length and checksum
+	maxFrame      = 16 << 20         // maximum size of encoded record
+	batchSize     = 256              // number of pending records to write them without waiting for Dump
+	pruneInterval = 10 * time.Minute // interval of pruning without sealed segments and changes of retention
+)
+
+// DefaultPending is maximum number of records waiting for write by default
+const DefaultPending = 8192
+
+// Open store of records in directory. Retention is used till it is changed at runtime (see Store.SetRetention):
+// changed retention is kept in directory and replaces retention of arguments. Records are written and pruned in
+// background; Close writes pending records.
+func Open(dir string, retention stats.Retention) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create directory of stats: %w", err)
+	}
+	st := &Store{
+		Pending:   DefaultPending,
+		dir:       dir,
+		retention: retention.Copy(),
+		notify:    make(chan struct{}, 1),
+		prune:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	if err := st.loadRetention(); err != nil {
+		return nil, err
+	}
+	if err := st.load(); err != nil {
+		return nil, err
+	}
+	st.stopped.Add(2)
+	go st.writeBatches()
+	go st.pruneSegments()
+	st.schedulePrune() // retention is applied at start
+	return st, nil
+}
+
+// Store of records on disk
+type Store struct {
+	Pending int // maximum number of records waiting for write: the oldest are dropped over it (ex: disk is stalled)
+	dir     string
+
+	pendingLock sync.Mutex
+	pending     []stats.Record
+	dropped     int
+
+	lock      sync.RWMutex // segments, files, retention and result of pruning
+	segments  []*segment   // from the oldest, the last is active
+	active    *os.File
+	retention stats.Retention
+	pruning   stats.Pruning
+
+	pruneLock sync.Mutex // sealed segments are changed only by pruning
+
+	notify  chan struct{}
+	prune   chan struct{}
+	done    chan struct{}
+	closed  sync.Once
+	stopped sync.WaitGroup
+}
+
+// metadata of segment to skip it by query without reading
+type segment struct {
+	seq   uint64
+	size  int64
+	count int
+	first time.Time      // the earliest end of record
+	last  time.Time      // the latest end of record
+	uids  map[string]int // number of records by lambda
+}
+
+func (sg *segment) add(record *stats.Record, size int64) {
+	if sg.count == 0 || record.End.Before(sg.first) {
+		sg.first = record.End
+	}
+	if sg.count == 0 || record.End.After(sg.last) {
+		sg.last = record.End
+	}
+	sg.count++
+	sg.size += size
+	sg.uids[record.UID]++
+}
+
+func (sg *segment) matches(query *stats.Query) bool {
+	if sg.count == 0 || (query.UID != "" && sg.uids[query.UID] == 0) {
+		return false
+	}
+	return query.Overlaps(sg.first, sg.last)
+}
+
+// Track record: it is written by the next batch
+func (st *Store) Track(record stats.Record) {
+	st.pendingLock.Lock()
+	st.pending = append(st.pending, record)
+	if limit := st.Pending; limit > 0 && len(st.pending) > limit {
+		st.dropped += len(st.pending) - limit
+		st.pending = st.pending[len(st.pending)-limit:]
+	}
+	full := len(st.pending) >= batchSize
+	st.pendingLock.Unlock()
+	if full {
+		select {
+		case st.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dump writes pending records to disk. Sealed segment schedules pruning
+func (st *Store) Dump() error {
+	// batch is moved under lock of segments: readers see it either pending or written
+	st.lock.Lock()
+	st.pendingLock.Lock()
+	batch, dropped := st.pending, st.dropped
+	st.pending, st.dropped = nil, 0
+	st.pendingLock.Unlock()
+	err := st.unsafeWrite(batch)
+	st.lock.Unlock()
+	st.pendingLock.Lock()
+	if st.pending == nil {
+		st.pending = batch[:0] // reused by the next batch
+	}
+	st.pendingLock.Unlock()
+	if dropped > 0 {
+		log.Println("[WARN]", "stats:", dropped, "records are dropped: writes are slower than invocations")
+	}
+	if err != nil {
+		return fmt.Errorf("write stats: %w", err)
+	}
+	return nil
+}
+
+// Close writes pending records and stops background writes. Records tracked after close are not written
+func (st *Store) Close() error {
+	st.closed.Do(func() { close(st.done) })
+	st.stopped.Wait()
+	err := st.Dump()
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.active != nil {
+		if cerr := st.active.Close(); err == nil {
+			err = cerr
+		}
+		st.active = nil
+	}
+	return err
+}
+
+// Import records of legacy dump of memlog once: imported dump is renamed to <file>.migrated. Missing dump is not an
+// error. Returns number of imported records
+func (st *Store) Import(file string) (int, error) {
+	records, err := memlog.ReadDump(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("read legacy stats: %w", err)
+	}
+	st.lock.Lock()
+	err = st.unsafeWrite(records)
+	st.lock.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("import legacy stats: %w", err)
+	}
+	if err := os.Rename(file, file+".migrated"); err != nil {
+		return len(records), fmt.Errorf("rename imported legacy stats: %w", err)
+	}
+	return len(records), nil
+}
+
+func (st *Store) LastByUID(uid string, limit int) ([]stats.Record, error) {
+	return st.Range(stats.Query{UID: uid, Limit: limit})
+}
+
+func (st *Store) Last(limit int) ([]stats.Record, error) {
+	return st.Range(stats.Query{Limit: limit})
+}
+
+func (st *Store) Range(query stats.Query) ([]stats.Record, error) {
+	var ans = make([]stats.Record, 0)
+	if query.Limit <= 0 {
+		return ans, nil
+	}
+	err := st.Scan(query, func(record *stats.Record) bool {
+		ans = append(ans, *record)
+		return len(ans) < query.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+// Scan records from the newest to the oldest: pending records, then segments which could contain matched records.
+// Only one segment is kept in memory at once and segments are locked only while read, so slow handler (ex: export to
+// client) doesn't block writes. Records appended during scan are not visited, segments pruned during scan are read as
+// they are after pruning
+func (st *Store) Scan(query stats.Query, handler func(record *stats.Record) bool) error {
+	// pending records are moved to segments under lock: snapshot of both has every record once
+	st.lock.RLock()
+	var pending []stats.Record
+	st.pendingLock.Lock()
+	for i := len(st.pending) - 1; i >= 0; i-- {
+		if query.Matches(&st.pending[i]) {
+			pending = append(pending, st.pending[i])
+		}
+	}
+	st.pendingLock.Unlock()
+	var snapshot []segment
+	for _, sg := range st.segments {
+		if sg.matches(&query) {
+			snapshot = append(snapshot, segment{seq: sg.seq, size: sg.size, count: sg.count})
+		}
+	}
+	st.lock.RUnlock()
+
+	for i := range pending {
+		if !handler(&pending[i]) {
+			return nil
+		}
+	}
+	for i := len(snapshot) - 1; i >= 0; i-- {
+		records, err := st.readSnapshot(&snapshot[i])
+		if err != nil {
+			return err
+		}
+		for j := len(records) - 1; j >= 0; j-- {
+			if query.Matches(&records[j]) && !handler(&records[j]) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// records of segment by snapshot of its size: records appended after snapshot are not read. Segment removed by
+// pruning has no records
+func (st *Store) readSnapshot(snapshot *segment) ([]stats.Record, error) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	i := sort.Search(len(st.segments), func(i int) bool { return st.segments[i].seq >= snapshot.seq })
+	if i == len(st.segments) || st.segments[i].seq != snapshot.seq {
+		return nil, nil
+	}
+	if current := st.segments[i]; current.size < snapshot.size {
+		return st.read(current)
+	}
+	return st.read(snapshot)
+}
+
+func (st *Store) writeBatches() {
+	defer st.stopped.Done()
+	for {
+		select {
+		case <-st.notify:
+		case <-st.done:
+			return
+		}
+		if err := st.Dump(); err != nil {
+			log.Println("[ERROR]", "stats:", err)
+		}
+	}
+}
+
+// append records to active segment, active segment is sealed by size. Partially written active segment is restored
+// by records in file. Should be called under lock
+func (st *Store) unsafeWrite(records []stats.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	err := st.unsafeAppend(records)
+	if err == nil || st.active == nil {
+		return err
+	}
+	sg := st.segments[len(st.segments)-1]
+	if restored, serr := st.scan(sg.seq); serr == nil {
+		st.segments[len(st.segments)-1] = restored
+	} else {
+		log.Println("[ERROR]", "stats: restore segment", sg.seq, "-", serr)
+	}
+	return err
+}
+
+func (st *Store) unsafeAppend(records []stats.Record) error {
+	if st.active == nil {
+		if err := st.unsafeCreate(); err != nil {
+			return err
+		}
+	}
+	sg := st.segments[len(st.segments)-1]
+	out := bufio.NewWriter(st.active)
+	var buffer []byte
+	for i := range records {
+		frame, err := encode(buffer[:0], &records[i])
+		if err != nil {
+			log.Println("[ERROR]", "stats: encode record of", records[i].UID, "-", err)
+			continue
+		}
+		buffer = frame
+		if _, err := out.Write(frame); err != nil {
+			return err
+		}
+		sg.add(&records[i], int64(len(frame)))
+		if sg.size < segmentSize {
+			continue
+		}
+		// burst of records: sealed segment could be pruned
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		if err := st.unsafeCreate(); err != nil {
+			return err
+		}
+		st.schedulePrune()
+		sg = st.segments[len(st.segments)-1]
+		out = bufio.NewWriter(st.active)
+	}
+	return out.Flush()
+}
+
+// close active segment and start new active segment. Should be called under lock
+func (st *Store) unsafeCreate() error {
+	var seq uint64 = 1
+	if n := len(st.segments); n > 0 {
+		seq = st.segments[n-1].seq + 1
+	}
+	f, err := os.OpenFile(st.file(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("create segment of stats: %w", err)
+	}
+	if st.active != nil {
+		_ = st.active.Close()
+	}
+	st.active = f
+	st.segments = append(st.segments, &segment{seq: seq, uids: make(map[string]int)})
+	return nil
+}
+
+// load metadata of segments and truncate torn frames
+func (st *Store) load() error {
+	files, err := ioutil.ReadDir(st.dir)
+	if err != nil {
+		return fmt.Errorf("list segments of stats: %w", err)
+	}
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasPrefix(name, "compact-") || strings.HasPrefix(name, "retention-") {
+			_ = os.Remove(filepath.Join(st.dir, name)) // interrupted pruning or save of retention
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 16, 64)
+		if err != nil || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		sg, err := st.scan(seq)
+		if err != nil {
+			return fmt.Errorf("read segment %s of stats: %w", name, err)
+		}
+		st.segments = append(st.segments, sg)
+	}
+	sort.Slice(st.segments, func(i, j int) bool {
+		return st.segments[i].seq < st.segments[j].seq
+	})
+	if len(st.segments) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(st.file(st.segments[len(st.segments)-1].seq), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open active segment of stats: %w", err)
+	}
+	st.active = f
+	return nil
+}
+
+// metadata of segment by its records, torn or damaged tail is truncated
+func (st *Store) scan(seq uint64) (*segment, error) {
+	sg := &segment{seq: seq, uids: make(map[string]int)}
+	f, err := os.Open(st.file(seq))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	err = readFrames(bufio.NewReader(f), func(record *stats.Record, size int64) {
+		sg.add(record, size)
+	})
+	if err == nil {
+		return sg, nil
+	}
+	log.Println("[WARN]", "stats: segment", seq, "is truncated to", sg.size, "bytes -", err)
+	if err := os.Truncate(st.file(seq), sg.size); err != nil {
+		return nil, err
+	}
+	return sg, nil
+}
+
+// records of segment in insertion order. Should be called under lock of store or of pruning (for sealed segment)
+func (st *Store) read(sg *segment) ([]stats.Record, error) {
+	f, err := os.Open(st.file(sg.seq))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records = make([]stats.Record, 0, sg.count)
+	err = readFrames(bufio.NewReader(io.LimitReader(f, sg.size)), func(record *stats.Record, _ int64) {
+		records = append(records, *record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read segment %d of stats: %w", sg.seq, err)
+	}
+	return records, nil
+}
+
+func (st *Store) file(seq uint64) string {
+	return filepath.Join(st.dir, fmt.Sprintf("%016x", seq)+segmentExt)
+}
+
+var errDamaged = errors.New("damaged record")
+
+// append frame of record to buffer
+func encode(buffer []byte, record *stats.Record) ([]byte, error) {
+	frame, err := record.MarshalMsg(append(buffer, make([]byte, frameHeader)...))
+	if err != nil {
+		return nil, err
+	}
+	payload := frame[len(buffer)+frameHeader:]
+	binary.LittleEndian.PutUint32(frame[len(buffer):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[len(buffer)+4:], crc32.ChecksumIEEE(payload))
+	return frame, nil
+}
+
+// read frames till end of stream. Incomplete or damaged frame is an error
+func readFrames(in io.Reader, handler func(record *stats.Record, size int64)) error {
+	var header [frameHeader]byte
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(in, header[:]); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if size > maxFrame {
+			return fmt.Errorf("%w: size %d", errDamaged, size)
+		}
+		if cap(payload) < int(size) {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := io.ReadFull(in, payload); err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+			return fmt.Errorf("%w: checksum mismatch", errDamaged)
+		}
+		var record stats.Record
+		if _, err := record.UnmarshalMsg(payload); err != nil {
+			return fmt.Errorf("%w: %v", errDamaged, err)
+		}
+		handler(&record, int64(frameHeader+len(payload)))
+	}
+}
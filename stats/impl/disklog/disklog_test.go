@@ -0,0 +1,314 @@
+package disklog_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/disklog"
+	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func tempDir(t testing.TB) string {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func record(uid string, end time.Time, n int) stats.Record {
+	return stats.Record{
+		UID:     uid,
+		Request: types.Request{ID: strconv.Itoa(n), Method: "POST", Path: uid, Headers: map[string]string{"Content-Type": "application/json"}},
+		Begin:   end.Add(-time.Millisecond),
+		End:     end,
+		Status:  200,
+		Output:  bytes.Repeat([]byte("x"), stats.OutputPrefix),
+	}
+}
+
+func ids(records []stats.Record) []string {
+	var list []string
+	for _, item := range records {
+		list = append(list, item.Request.ID)
+	}
+	return list
+}
+
+func segments(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	return files
+}
+
+func TestStore_persistent(t *testing.T) {
+	dir := tempDir(t)
+	store, err := disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	begin := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		store.Track(record([]string{"a", "b"}[i%2], begin.Add(time.Duration(i)*time.Minute), i))
+	}
+	// pending records are read before write
+	last, err := store.Last(2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5", "4"}, ids(last))
+	require.NoError(t, store.Dump())
+	store.Track(record("a", begin.Add(10*time.Minute), 6))
+	require.NoError(t, store.Close())
+
+	store, err = disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+	last, err = store.Last(100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"6", "5", "4", "3", "2", "1", "0"}, ids(last))
+	assert.Equal(t, begin.Add(10*time.Minute), last[0].End.UTC())
+	assert.Len(t, last[0].Output, stats.OutputPrefix)
+	byUID, err := store.LastByUID("a", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"6", "4"}, ids(byUID))
+	ranged, err := store.Range(stats.Query{UID: "b", Since: begin.Add(time.Minute), Until: begin.Add(5 * time.Minute), Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "1"}, ids(ranged))
+	none, err := store.Range(stats.Query{UID: "unknown", Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+	none, err = store.Last(0)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestStore_tornWrite(t *testing.T) {
+	dir := tempDir(t)
+	store, err := disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	now := time.Now()
+	store.Track(record("a", now, 0))
+	store.Track(record("a", now, 1))
+	require.NoError(t, store.Close())
+	files := segments(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{200, 0, 0, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+	store.Track(record("a", now, 2))
+	require.NoError(t, store.Dump())
+	last, err := store.Last(10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1", "0"}, ids(last))
+}
+
+func TestStore_retention(t *testing.T) {
+	dir := tempDir(t)
+	store, err := disklog.Open(dir, stats.Retention{MaxAge: types.JsonDuration(time.Hour)})
+	require.NoError(t, err)
+	defer store.Close()
+	now := time.Now()
+	// ~1KiB records: segment is sealed after ~900 records
+	for i := 0; i < 2000; i++ {
+		store.Track(record("a", now.Add(-2*time.Hour), i))
+	}
+	for i := 2000; i < 3000; i++ {
+		store.Track(record("a", now, i))
+	}
+	require.NoError(t, store.Dump())
+	require.NoError(t, store.Prune())
+	records, err := store.Last(10000)
+	require.NoError(t, err)
+	assert.Len(t, records, 1000, "expired records are removed")
+	assert.Equal(t, "2999", records[0].Request.ID)
+	assert.Equal(t, "2000", records[len(records)-1].Request.ID)
+	// expired records are removed from segments: reopened without retention
+	require.NoError(t, store.Close())
+	store, err = disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+	records, err = store.Last(10000)
+	require.NoError(t, err)
+	assert.Len(t, records, 1000)
+	assert.Equal(t, "2000", records[len(records)-1].Request.ID)
+
+	sized := tempDir(t)
+	store, err = disklog.Open(sized, stats.Retention{MaxSize: 3 << 20 / 2})
+	require.NoError(t, err)
+	defer store.Close()
+	for i := 0; i < 4000; i++ {
+		store.Track(record("a", now, i))
+	}
+	require.NoError(t, store.Dump())
+	require.NoError(t, store.Prune())
+	records, err = store.Last(10000)
+	require.NoError(t, err)
+	assert.Less(t, len(records), 4000)
+	assert.Equal(t, "3999", records[0].Request.ID, "the newest records are kept")
+	var total int64
+	for _, file := range segments(t, sized) {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		total += info.Size()
+	}
+	assert.LessOrEqual(t, total, int64(3<<20/2+1<<20), "active segment is not pruned")
+}
+
+func TestStore_limits(t *testing.T) {
+	dir := tempDir(t)
+	// records are written without limits: pruning of sealed segments in background while records are written removes
+	// other records than pruning of all records at once
+	store, err := disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < 3000; i++ {
+		store.Track(record([]string{"a", "b"}[i%2], now, i))
+	}
+	require.NoError(t, store.Close())
+	store, err = disklog.Open(dir, stats.Retention{
+		MaxAge:     types.JsonDuration(time.Hour),
+		MaxRecords: 1500,
+		Lambdas:    map[string]stats.LambdaRetention{"b": {MaxRecords: 100}},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Prune())
+	records, err := store.Last(10000)
+	require.NoError(t, err)
+	// 1500 the oldest records over number of server, then the oldest records of lambda over its number
+	assert.Len(t, records, 850)
+	assert.Equal(t, "2999", records[0].Request.ID)
+	byUID, err := store.LastByUID("b", 10000)
+	require.NoError(t, err)
+	assert.Len(t, byUID, 100, "limit of lambda")
+	assert.Equal(t, "2999", byUID[0].Request.ID)
+
+	usage := store.Usage()
+	assert.Equal(t, 850, usage.Records)
+	assert.Equal(t, 0, usage.Pending)
+	assert.Equal(t, int64(2150), usage.Pruning.Total)
+	assert.False(t, usage.Pruning.Behind)
+	assert.Empty(t, usage.Pruning.Error)
+	var total int64
+	for _, file := range segments(t, dir) {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		total += info.Size()
+	}
+	assert.Equal(t, total, usage.Size)
+	assert.Equal(t, now.UnixNano(), usage.Oldest.UnixNano())
+	assert.Equal(t, now.UnixNano(), usage.Newest.UnixNano())
+}
+
+func TestStore_SetRetention(t *testing.T) {
+	dir := tempDir(t)
+	store, err := disklog.Open(dir, stats.Retention{})
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < 2000; i++ {
+		store.Track(record("a", now.Add(-2*time.Hour), i))
+	}
+	for i := 2000; i < 3000; i++ {
+		store.Track(record("b", now.Add(-2*time.Hour), i))
+	}
+	require.NoError(t, store.Dump())
+	assert.Error(t, store.SetRetention(stats.Retention{MaxRecords: -1}))
+	// records of lambda with age over age of server are kept
+	retention := stats.Retention{
+		MaxAge:  types.JsonDuration(time.Hour),
+		Lambdas: map[string]stats.LambdaRetention{"b": {MaxAge: types.JsonDuration(24 * time.Hour)}},
+	}
+	require.NoError(t, store.SetRetention(retention))
+	require.NoError(t, store.Prune())
+	records, err := store.Last(10000)
+	require.NoError(t, err)
+	assert.Len(t, records, 1000)
+	assert.Equal(t, "2000", records[len(records)-1].Request.ID)
+	require.NoError(t, store.Close())
+
+	// changed retention replaces retention of arguments
+	store, err = disklog.Open(dir, stats.Retention{MaxAge: types.JsonDuration(time.Minute)})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, retention, store.Retention())
+	require.NoError(t, store.Prune())
+	records, err = store.Last(10000)
+	require.NoError(t, err)
+	assert.Len(t, records, 1000)
+}
+
+func TestStore_Import(t *testing.T) {
+	dir := tempDir(t)
+	dump := filepath.Join(dir, ".stats")
+	legacy, err := memlog.NewDumped(dump, 10)
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		legacy.Track(record("a", now, i))
+	}
+	require.NoError(t, legacy.Dump())
+
+	store, err := disklog.Open(filepath.Join(dir, "stats"), stats.Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+	n, err := store.Import(dump)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoFileExists(t, dump)
+	assert.FileExists(t, dump+".migrated")
+	n, err = store.Import(dump)
+	require.NoError(t, err)
+	assert.Zero(t, n, "imported once")
+	records, err := store.Last(10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1", "0"}, ids(records))
+}
+
+// overhead of request path: records are written by background batches
+func BenchmarkStore_Track(b *testing.B) {
+	store, err := disklog.Open(tempDir(b), stats.Retention{})
+	require.NoError(b, err)
+	defer store.Close()
+	item := record("a", time.Now(), 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Track(item)
+		if i%1000 == 999 {
+			// invocations are slower than tight loop: pending records are not dropped
+			b.StopTimer()
+			require.NoError(b, store.Dump())
+			b.StartTimer()
+		}
+	}
+}
+
+// cost of write of record to disk by batch (encoding, checksum, append)
+func BenchmarkStore_Dump(b *testing.B) {
+	store, err := disklog.Open(tempDir(b), stats.Retention{})
+	require.NoError(b, err)
+	defer store.Close()
+	store.Pending = 0
+	item := record("a", time.Now(), 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Track(item)
+		if i%1000 == 999 {
+			require.NoError(b, store.Dump())
+		}
+	}
+	require.NoError(b, store.Dump())
+}
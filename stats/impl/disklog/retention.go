@@ -0,0 +1,439 @@
+package disklog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Retention of records
+func (st *Store) Retention() stats.Retention {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.retention.Copy()
+}
+
+// SetRetention saves retention to directory and schedules pruning by it
+func (st *Store) SetRetention(retention stats.Retention) error {
+	if err := retention.Validate(); err != nil {
+		return err
+	}
+	retention = retention.Copy()
+	data, err := json.MarshalIndent(retention, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(st.dir, "retention-*")
+	if err != nil {
+		return fmt.Errorf("save retention of stats: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(st.dir, retentionFile))
+	}
+	if err != nil {
+		return fmt.Errorf("save retention of stats: %w", err)
+	}
+	st.lock.Lock()
+	st.retention = retention
+	st.lock.Unlock()
+	st.schedulePrune()
+	return nil
+}
+
+// Usage of disk by records and the last pruning
+func (st *Store) Usage() stats.Usage {
+	// pending records are moved to segments under lock: snapshot of both has every record once
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	usage := stats.Usage{
+		Segments:  len(st.segments),
+		Retention: st.retention.Copy(),
+		Pruning:   st.pruning,
+	}
+	st.pendingLock.Lock()
+	for i := range st.pending {
+		observe(&usage, st.pending[i].End, st.pending[i].End)
+	}
+	usage.Pending = len(st.pending)
+	st.pendingLock.Unlock()
+	usage.Records = usage.Pending
+	for _, sg := range st.segments {
+		usage.Records += sg.count
+		usage.Size += sg.size
+		if sg.count > 0 {
+			observe(&usage, sg.first, sg.last)
+		}
+	}
+	return usage
+}
+
+// extend range of records of usage
+func observe(usage *stats.Usage, first, last time.Time) {
+	if usage.Oldest.IsZero() || first.Before(usage.Oldest) {
+		usage.Oldest = first
+	}
+	if last.After(usage.Newest) {
+		usage.Newest = last
+	}
+}
+
+// Prune segments by retention: the oldest records over limits are removed, then small neighbours are merged.
+// Segments are read and written without lock of store, only replacement of files is locked: writes and reads of
+// records are not blocked. Active segment with records over limits is sealed. Records newer than maximum age removed
+// by limits of number or size are logged
+func (st *Store) Prune() error {
+	st.pruneLock.Lock()
+	defer st.pruneLock.Unlock()
+	started := time.Now()
+	result, err := st.unsafePrune(started)
+	if err == nil {
+		err = st.unsafeMerge()
+	}
+	if result.Early > 0 {
+		log.Println("[WARN]", "stats:", result.Early, "records newer than maximum age are removed by limits of number or size")
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	result.Time = time.Now()
+	result.Duration = types.JsonDuration(result.Time.Sub(started))
+	result.Total = st.pruning.Total + int64(result.Removed)
+	result.Behind = st.unsafeBehind(result.Time)
+	if err != nil {
+		result.Error = err.Error()
+		err = fmt.Errorf("prune stats: %w", err)
+	}
+	st.pruning = result
+	return err
+}
+
+func (st *Store) schedulePrune() {
+	select {
+	case st.prune <- struct{}{}:
+	default:
+	}
+}
+
+func (st *Store) pruneSegments() {
+	defer st.stopped.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-st.prune:
+		case <-st.done:
+			return
+		}
+		if err := st.Prune(); err != nil {
+			log.Println("[ERROR]", "stats:", err)
+		}
+	}
+}
+
+// remove records over limits from the oldest sealed segments. Should be called under lock of pruning
+func (st *Store) unsafePrune(now time.Time) (stats.Pruning, error) {
+	var result stats.Pruning
+	st.lock.Lock()
+	limits := newPruner(st.retention.Copy(), now, st.segments)
+	if n := len(st.segments); n > 0 && st.active != nil && limits.exceeds(st.segments[n-1], st.segments[:n-1]) {
+		if err := st.unsafeCreate(); err != nil {
+			st.lock.Unlock()
+			return result, err
+		}
+	}
+	var sealed []*segment
+	if n := len(st.segments); n > 0 {
+		sealed = append(sealed, st.segments[:n-1]...)
+	}
+	st.lock.Unlock()
+
+	for _, sg := range sealed {
+		if !limits.pressure() && !sg.first.Before(limits.latest) {
+			break
+		}
+		records, err := st.read(sg)
+		if err != nil {
+			return result, err
+		}
+		kept := records[:0]
+		var buffer []byte
+		for i := range records {
+			frame, err := encode(buffer[:0], &records[i])
+			if err != nil {
+				return result, err
+			}
+			buffer = frame
+			keep, early := limits.keep(&records[i], int64(len(frame)))
+			if keep {
+				kept = append(kept, records[i])
+				continue
+			}
+			result.Removed++
+			if early {
+				result.Early++
+			}
+		}
+		if len(kept) == len(records) {
+			continue
+		}
+		if err := st.replace([]*segment{sg}, kept); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// merge small neighbours of sealed segments. Should be called under lock of pruning
+func (st *Store) unsafeMerge() error {
+	for {
+		st.lock.RLock()
+		var pair []*segment
+		for i := 0; i+1 < len(st.segments)-1; i++ {
+			if st.segments[i].size+st.segments[i+1].size <= segmentSize {
+				pair = []*segment{st.segments[i], st.segments[i+1]}
+				break
+			}
+		}
+		st.lock.RUnlock()
+		if pair == nil {
+			return nil
+		}
+		var records []stats.Record
+		for _, sg := range pair {
+			items, err := st.read(sg)
+			if err != nil {
+				return err
+			}
+			records = append(records, items...)
+		}
+		if err := st.replace(pair, records); err != nil {
+			return err
+		}
+	}
+}
+
+// replace consecutive sealed segments by single segment with records (no records - segments are removed). Segment is
+// written without lock of store. Should be called under lock of pruning
+func (st *Store) replace(segments []*segment, records []stats.Record) error {
+	merged := &segment{seq: segments[0].seq, uids: make(map[string]int)}
+	var tmpName string
+	if len(records) > 0 {
+		tmp, err := ioutil.TempFile(st.dir, "compact-*")
+		if err != nil {
+			return err
+		}
+		tmpName = tmp.Name()
+		defer os.Remove(tmpName)
+		out := bufio.NewWriter(tmp)
+		var buffer []byte
+		for i := range records {
+			frame, err := encode(buffer[:0], &records[i])
+			if err != nil {
+				_ = tmp.Close()
+				return err
+			}
+			buffer = frame
+			if _, err := out.Write(frame); err != nil {
+				_ = tmp.Close()
+				return err
+			}
+			merged.add(&records[i], int64(len(frame)))
+		}
+		if err := out.Flush(); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+	}
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	from := sort.Search(len(st.segments), func(i int) bool { return st.segments[i].seq >= merged.seq })
+	to := from + len(segments)
+	if to > len(st.segments)-1 || st.segments[from] != segments[0] {
+		return fmt.Errorf("segment %d is not sealed", merged.seq)
+	}
+	if tmpName != "" {
+		if err := os.Rename(tmpName, st.file(merged.seq)); err != nil {
+			return err
+		}
+	}
+	for _, sg := range st.segments[from:to] {
+		if tmpName != "" && sg.seq == merged.seq {
+			continue
+		}
+		if err := os.Remove(st.file(sg.seq)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var replaced []*segment
+	if tmpName != "" {
+		replaced = []*segment{merged}
+	}
+	st.segments = append(append(st.segments[:from:from], replaced...), st.segments[to:]...)
+	return nil
+}
+
+// limits are still exceeded after pruning: records over limits are in active segment or pruning is failed. Should be
+// called under lock
+func (st *Store) unsafeBehind(now time.Time) bool {
+	limits := newPruner(st.retention, now, st.segments)
+	if limits.pressure() {
+		return true
+	}
+	// the earliest record of the oldest segment is expired, whichever lambda it belongs to
+	for _, sg := range st.segments {
+		if sg.count == 0 {
+			continue
+		}
+		for uid := range sg.uids {
+			if cutoff := limits.cutoff(uid); cutoff.IsZero() || !sg.first.Before(cutoff) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// saved retention replaces retention of arguments
+func (st *Store) loadRetention() error {
+	data, err := ioutil.ReadFile(filepath.Join(st.dir, retentionFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read retention of stats: %w", err)
+	}
+	var retention stats.Retention
+	if err := json.Unmarshal(data, &retention); err != nil {
+		return fmt.Errorf("parse retention of stats: %w", err)
+	}
+	if err := retention.Validate(); err != nil {
+		return fmt.Errorf("retention of stats: %w", err)
+	}
+	st.retention = retention
+	return nil
+}
+
+// limits of pruning: remains of records and bytes over limits, which are reduced by removed records
+type pruner struct {
+	retention stats.Retention
+	now       time.Time
+	excess    int            // records over maximum number of server
+	oversize  int64          // bytes over maximum size of server
+	lambdas   map[string]int // records over maximum number of lambda
+	latest    time.Time      // the latest cutoff by age: newer segments have no expired records
+}
+
+func newPruner(retention stats.Retention, now time.Time, segments []*segment) *pruner {
+	p := &pruner{retention: retention, now: now, lambdas: make(map[string]int)}
+	var count int
+	var size int64
+	var byUID = make(map[string]int)
+	for _, sg := range segments {
+		count += sg.count
+		size += sg.size
+		for uid, n := range sg.uids {
+			byUID[uid] += n
+		}
+	}
+	if retention.MaxRecords > 0 && count > retention.MaxRecords {
+		p.excess = count - retention.MaxRecords
+	}
+	if retention.MaxSize > 0 && size > retention.MaxSize {
+		p.oversize = size - retention.MaxSize
+	}
+	p.latest = p.cutoff("")
+	for uid, limits := range retention.Lambdas {
+		if limits.MaxRecords > 0 && byUID[uid] > limits.MaxRecords {
+			p.lambdas[uid] = byUID[uid] - limits.MaxRecords
+		}
+		if cutoff := p.cutoff(uid); cutoff.After(p.latest) {
+			p.latest = cutoff
+		}
+	}
+	return p
+}
+
+// records of lambda ended before cutoff are expired (zero - not limited by age)
+func (p *pruner) cutoff(uid string) time.Time {
+	age := p.retention.Age(uid)
+	if age <= 0 {
+		return time.Time{}
+	}
+	return p.now.Add(-age)
+}
+
+// limits of number or size are exceeded
+func (p *pruner) pressure() bool {
+	if p.excess > 0 || p.oversize > 0 {
+		return true
+	}
+	for _, n := range p.lambdas {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// active segment should be sealed: its records could be over limits after pruning of sealed segments
+func (p *pruner) exceeds(active *segment, sealed []*segment) bool {
+	if active.count == 0 {
+		return false
+	}
+	if active.first.Before(p.latest) {
+		return true
+	}
+	var count int
+	var size int64
+	var byUID = make(map[string]int)
+	for _, sg := range sealed {
+		count += sg.count
+		size += sg.size
+		for uid := range p.lambdas {
+			byUID[uid] += sg.uids[uid]
+		}
+	}
+	if p.excess > count || p.oversize > size {
+		return true
+	}
+	for uid, n := range p.lambdas {
+		if n > byUID[uid] {
+			return true
+		}
+	}
+	return false
+}
+
+// record is kept by limits, otherwise it is removed and limits are reduced. Early removal is removal of record newer
+// than maximum age (records of lambdas without maximum age are never early)
+func (p *pruner) keep(record *stats.Record, size int64) (keep bool, early bool) {
+	cutoff := p.cutoff(record.UID)
+	expired := !cutoff.IsZero() && record.End.Before(cutoff)
+	if !expired && p.excess <= 0 && p.oversize <= 0 && p.lambdas[record.UID] <= 0 {
+		return true, false
+	}
+	p.excess--
+	p.oversize -= size
+	if _, ok := p.lambdas[record.UID]; ok {
+		p.lambdas[record.UID]--
+	}
+	return false, !expired && !cutoff.IsZero()
+}
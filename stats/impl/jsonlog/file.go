@@ -0,0 +1,105 @@
+package jsonlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// OpenFile opens log file for appending with rotation by size: file which would exceed maximum size (zero - not
+// rotated) by the next write is renamed to file.1 (previous file.1 to file.2 and so on), the oldest over number of
+// backups are removed
+func OpenFile(file string, maxSize int64, backups int) (*RotatingFile, error) {
+	rf := &RotatingFile{file: file, maxSize: maxSize, backups: backups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// RotatingFile is log file rotated by size. Lines are not split between files
+type RotatingFile struct {
+	file    string
+	maxSize int64
+	backups int
+	lock    sync.Mutex
+	out     *os.File
+	size    int64
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.out == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, fmt.Errorf("rotate %s: %w", rf.file, err)
+		}
+	}
+	n, err := rf.out.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close log file
+func (rf *RotatingFile) Close() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.out == nil {
+		return nil
+	}
+	err := rf.out.Close()
+	rf.out = nil
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(rf.file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rf.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	rf.out = f
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.out.Close(); err != nil {
+		return err
+	}
+	rf.out = nil
+	if rf.backups <= 0 {
+		if err := os.Remove(rf.file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return rf.open()
+	}
+	if err := os.Remove(rf.backup(rf.backups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := rf.backups - 1; i > 0; i-- {
+		if err := os.Rename(rf.backup(i), rf.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(rf.file, rf.backup(1)); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+func (rf *RotatingFile) backup(n int) string {
+	return rf.file + "." + strconv.Itoa(n)
+}
@@ -0,0 +1,150 @@
+// Package jsonlog writes structured log of invocations and administrative changes: one JSON object per line, for
+// shipping to log collectors (Loki, ELK). Fields of lines are stable: new fields could be added, existing are not
+// renamed or removed.
+package jsonlog
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+// Types of lines
+const (
+	TypeInvocation = "invocation"
+	TypeChange     = "change"
+)
+
+// Results of invocations
+const (
+	ResultOK        = "ok"
+	ResultError     = "error"
+	ResultRetried   = "retried"   // failed attempt followed by another attempt (see Attempt)
+	ResultRejected  = "rejected"  // rejected without invocation (policy, method, content type, payload, ...)
+	ResultThrottled = "throttled" // rejected by rate limit
+	ResultDenied    = "denied"    // rejected by allowed networks of client
+)
+
+// Invocation line: request to lambda (by UID, by link or to queue) or execution of queued request
+type Invocation struct {
+	Time       time.Time `json:"time"` // end of request
+	Type       string    `json:"type"` // TypeInvocation
+	UID        string    `json:"uid"`  // UID of lambda or name of queue
+	Alias      string    `json:"alias,omitempty"`
+	Trigger    string    `json:"trigger,omitempty"` // see stats.Trigger* constants
+	RequestID  string    `json:"request_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"` // HTTP status of response (zero - nothing is sent)
+	Result     string    `json:"result"` // see Result* constants
+	DurationMs float64   `json:"duration_ms"`
+	Payload    int64     `json:"payload"` // size of request body in bytes
+	Size       int64     `json:"size"`    // size of response body in bytes
+	Error      string    `json:"error,omitempty"`
+	Queue      string    `json:"queue,omitempty"`         // queue of executed request (queued execution)
+	QueueMs    float64   `json:"queue_wait_ms,omitempty"` // time of request in queue before execution
+	Attempt    int       `json:"attempt,omitempty"`       // number of attempt of retried execution from 1
+	CatchUp    bool      `json:"catch_up,omitempty"`      // scheduled run missed during downtime
+}
+
+// Change line: administrative change recorded in journal (see application.Change)
+type Change struct {
+	Time    time.Time `json:"time"` // moment of change
+	Type    string    `json:"type"` // TypeChange
+	ID      int64     `json:"id"`   // sequence number in journal
+	Actor   string    `json:"actor"`
+	Kind    string    `json:"kind"` // see application.Change* constants
+	Lambda  string    `json:"lambda,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Summary string    `json:"summary"`
+}
+
+// New logger of lines to writer. Writes are serialized, failures are logged
+func New(writer io.Writer) *Logger {
+	return &Logger{writer: writer}
+}
+
+// Logger of invocations (stats.Recorder) and changes (see journal.Journal.Observer)
+type Logger struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+// Track invocation (every record, without sampling)
+func (l *Logger) Track(record stats.Record) {
+	l.write(NewInvocation(record))
+}
+
+// Change records administrative change
+func (l *Logger) Change(change application.Change) {
+	l.write(NewChange(change))
+}
+
+func (l *Logger) write(line interface{}) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		log.Println("[ERROR]", "json log: encode line -", err)
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		log.Println("[ERROR]", "json log: write line -", err)
+	}
+}
+
+// NewInvocation is line of invocation record
+func NewInvocation(record stats.Record) Invocation {
+	result := ResultOK
+	switch {
+	case record.Throttled:
+		result = ResultThrottled
+	case record.Denied:
+		result = ResultDenied
+	case record.Rejected:
+		result = ResultRejected
+	case record.Retried:
+		result = ResultRetried
+	case record.Err != "":
+		result = ResultError
+	}
+	return Invocation{
+		Time:       record.End,
+		Type:       TypeInvocation,
+		UID:        record.UID,
+		Alias:      record.Request.Alias,
+		Trigger:    record.Trigger,
+		RequestID:  record.Request.ID,
+		Method:     record.Request.Method,
+		Path:       record.Request.Path,
+		Status:     record.Status,
+		Result:     result,
+		DurationMs: float64(record.End.Sub(record.Begin)) / float64(time.Millisecond),
+		Payload:    record.Payload,
+		Size:       record.Size,
+		Error:      record.Err,
+		Queue:      record.Queue,
+		QueueMs:    float64(record.QueueWait) / float64(time.Millisecond),
+		Attempt:    record.Attempt,
+		CatchUp:    record.CatchUp,
+	}
+}
+
+// NewChange is line of administrative change
+func NewChange(change application.Change) Change {
+	return Change{
+		Time:    change.Time,
+		Type:    TypeChange,
+		ID:      change.ID,
+		Actor:   change.Actor,
+		Kind:    change.Kind,
+		Lambda:  change.Lambda,
+		Name:    change.Name,
+		Summary: change.Summary,
+	}
+}
@@ -0,0 +1,92 @@
+package jsonlog_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/jsonlog"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// format of lines is part of interface: parsers of log collectors depend on it
+func TestLogger_format(t *testing.T) {
+	var out bytes.Buffer
+	logger := jsonlog.New(&out)
+	begin := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	logger.Track(stats.Record{
+		UID:     "a1b2",
+		Err:     "run failed: exit status 1",
+		Request: types.Request{ID: "trace-42", Method: "POST", URL: "/a1b2/x?q=1", Path: "a1b2/x", Alias: "shop", Headers: map[string]string{"Authorization": "secret"}},
+		Begin:   begin,
+		End:     begin.Add(1500 * time.Microsecond),
+		Status:  500,
+		Payload: 12,
+		Size:    34,
+		Output:  []byte("secret output"),
+		Trigger: stats.TriggerHTTP,
+	})
+	logger.Track(stats.Record{UID: "a1b2", Request: types.Request{Method: "GET", Path: "a1b2"}, Begin: begin, End: begin, Status: 429, Rejected: true, Throttled: true, Err: "rate limit of lambda exceeded"})
+	logger.Track(stats.Record{UID: "a1b2", Request: types.Request{Method: "GET", Path: "a1b2"}, Begin: begin, End: begin, Status: 403, Rejected: true, Err: "forbidden"})
+	logger.Track(stats.Record{UID: "a1b2", Request: types.Request{Method: "GET", Path: "a1b2"}, Begin: begin, End: begin.Add(time.Second), Status: 200})
+	logger.Change(application.Change{ID: 7, Time: begin, Actor: "admin", Kind: application.ChangeManifest, Lambda: "a1b2", Name: "shop", Summary: "manifest changed: time_limit", Previous: "abc", Revision: "def"})
+	logger.Change(application.Change{ID: 8, Time: begin, Actor: "admin", Kind: application.ChangeUser, Summary: "password changed"})
+
+	assert.Equal(t, strings.Join([]string{
+		`{"time":"2024-05-03T10:00:00.0015Z","type":"invocation","uid":"a1b2","alias":"shop","trigger":"http","request_id":"trace-42","method":"POST","path":"a1b2/x","status":500,"result":"error","duration_ms":1.5,"payload":12,"size":34,"error":"run failed: exit status 1"}`,
+		`{"time":"2024-05-03T10:00:00Z","type":"invocation","uid":"a1b2","method":"GET","path":"a1b2","status":429,"result":"throttled","duration_ms":0,"payload":0,"size":0,"error":"rate limit of lambda exceeded"}`,
+		`{"time":"2024-05-03T10:00:00Z","type":"invocation","uid":"a1b2","method":"GET","path":"a1b2","status":403,"result":"rejected","duration_ms":0,"payload":0,"size":0,"error":"forbidden"}`,
+		`{"time":"2024-05-03T10:00:01Z","type":"invocation","uid":"a1b2","method":"GET","path":"a1b2","status":200,"result":"ok","duration_ms":1000,"payload":0,"size":0}`,
+		`{"time":"2024-05-03T10:00:00Z","type":"change","id":7,"actor":"admin","kind":"manifest","lambda":"a1b2","name":"shop","summary":"manifest changed: time_limit"}`,
+		`{"time":"2024-05-03T10:00:00Z","type":"change","id":8,"actor":"admin","kind":"user","summary":"password changed"}`,
+	}, "\n")+"\n", out.String())
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "logs", "invocations.jsonl")
+
+	rf, err := jsonlog.OpenFile(file, 10, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = rf.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, rf.Close())
+	assertFile := func(name, content string) {
+		t.Helper()
+		data, err := ioutil.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+	assertFile(file, "fourth\n")
+	assertFile(file+".1", "third\n")
+	assertFile(file+".2", "second\n")
+	assert.NoFileExists(t, file+".3")
+
+	// appended after reopen, line longer than limit is not split
+	rf, err = jsonlog.OpenFile(file, 10, 0)
+	require.NoError(t, err)
+	_, err = rf.Write([]byte("x\n"))
+	require.NoError(t, err)
+	assertFile(file, "fourth\nx\n")
+	_, err = rf.Write([]byte("very long line\n"))
+	require.NoError(t, err)
+	require.NoError(t, rf.Close())
+	assertFile(file, "very long line\n")
+	assertFile(file+".1", "third\n")
+
+	_, err = rf.Write([]byte("closed\n"))
+	assert.Error(t, err)
+}
@@ -27,36 +27,49 @@ type dumped struct {
 }
 
 func (d *dumped) readDump() error {
-	f, err := os.Open(d.filename)
+	records, err := ReadDump(d.filename)
 	if err != nil {
 		return err
 	}
+	for _, item := range records {
+		d.mem.Track(item)
+	}
+	return nil
+}
+
+// ReadDump reads records of dump (also of legacy format) in insertion order
+func ReadDump(filename string) ([]stats.Record, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 
 	reader := msgp.NewReader(f)
 	n, err := reader.ReadArrayHeader()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var records = make([]stats.Record, 0, n)
 	for i := 0; i < int(n); i++ {
 		var item stats.Record
 		if legacy, err := isLegacyRecord(reader); err != nil {
-			return err
+			return nil, err
 		} else if legacy {
 			v, err := fromLegacy(reader)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			item = *v
 		} else {
 			err = item.DecodeMsg(reader)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
-		d.mem.Track(item)
+		records = append(records, item)
 	}
-	return nil
+	return records, nil
 }
 
 // Make atomic (fs by rename) dump
@@ -112,3 +125,11 @@ func (d *dumped) LastByUID(uid string, limit int) ([]stats.Record, error) {
 func (d *dumped) Last(limit int) ([]stats.Record, error) {
 	return d.mem.Last(limit)
 }
+
+func (d *dumped) Range(query stats.Query) ([]stats.Record, error) {
+	return d.mem.Range(query)
+}
+
+func (d *dumped) Scan(query stats.Query, handler func(record *stats.Record) bool) error {
+	return d.mem.Scan(query, handler)
+}
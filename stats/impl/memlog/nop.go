@@ -49,3 +49,24 @@ func (s *statLogger) Last(limit int) ([]stats.Record, error) {
 
 	return chunk, nil
 }
+
+func (s *statLogger) Range(query stats.Query) ([]stats.Record, error) {
+	var ans = make([]stats.Record, 0)
+	clone := s.buffer.Flatten()
+	for i := len(clone) - 1; i >= 0 && len(ans) < query.Limit; i-- {
+		if query.Matches(&clone[i]) {
+			ans = append(ans, clone[i])
+		}
+	}
+	return ans, nil
+}
+
+func (s *statLogger) Scan(query stats.Query, handler func(record *stats.Record) bool) error {
+	clone := s.buffer.Flatten()
+	for i := len(clone) - 1; i >= 0; i-- {
+		if query.Matches(&clone[i]) && !handler(&clone[i]) {
+			break
+		}
+	}
+	return nil
+}
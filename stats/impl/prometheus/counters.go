@@ -0,0 +1,232 @@
+// Package prometheus exposes exact (non-sampled) invocation counters in Prometheus text format.
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func New() *Counters {
+	return &Counters{byUID: make(map[string]*counter)}
+}
+
+// Counters of invocations per UID. Every tracked record is counted regardless of sampling.
+type Counters struct {
+	Scheduler SchedulerCounters // optional counters of scheduler
+	Mirror    MirrorStatus      // optional status of replication
+	Streams   StreamCounters    // optional open streaming invocations
+	lock      sync.Mutex
+	byUID     map[string]*counter
+}
+
+// Source of scheduler counters
+type SchedulerCounters interface {
+	// Detected wall-clock jumps and schedules re-evaluated after jumps
+	SchedulerCounters() (clockJumps, reevaluations uint64)
+}
+
+// Source of open streaming invocations
+type StreamCounters interface {
+	// Number of open streams per lambda
+	OpenStreams() map[string]int
+}
+
+// Source of replication status
+type MirrorStatus interface {
+	Status() application.MirrorStatus
+}
+
+type counter struct {
+	invocations uint64
+	errors      uint64
+	rejections  uint64
+	throttled   uint64 // rejections by rate limit
+	denied      uint64 // rejections by allowed networks of client
+	seconds     float64
+	payloadWarn uint64 // invocations above soft limit of payload
+	sizeWarn    uint64 // invocations above soft limit of response
+	waitSeconds float64 // time spent waiting for free slot of concurrency limit
+	queueWait   float64 // time spent by executed requests in queue
+
+	spawns     [len(spawnBuckets) + 1]uint64 // invocations by bucket of spawn time, the last - over all buckets
+	spawnTotal float64                       // time spent to start processes and workers
+}
+
+// upper bounds of buckets of spawn time in seconds: warm worker is not spawned (zero)
+var spawnBuckets = [...]float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+func (c *Counters) Track(record stats.Record) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cnt, ok := c.byUID[record.UID]
+	if !ok {
+		cnt = &counter{}
+		c.byUID[record.UID] = cnt
+	}
+	cnt.invocations++
+	if record.Failed() {
+		cnt.errors++
+	}
+	if record.Rejected {
+		cnt.rejections++
+	}
+	if record.Throttled {
+		cnt.throttled++
+	}
+	if record.Denied {
+		cnt.denied++
+	}
+	if record.End.After(record.Begin) {
+		cnt.seconds += record.End.Sub(record.Begin).Seconds()
+	}
+	cnt.waitSeconds += record.Wait.Seconds()
+	cnt.queueWait += record.QueueWait.Seconds()
+	if record.Runtime > 0 {
+		// process or worker is invoked (not cached, coalesced or rejected)
+		spawn := record.Spawn.Seconds()
+		bucket := sort.SearchFloat64s(spawnBuckets[:], spawn)
+		cnt.spawns[bucket]++
+		cnt.spawnTotal += spawn
+	}
+	for _, limit := range record.Limits {
+		switch limit {
+		case types.LimitPayload:
+			cnt.payloadWarn++
+		case types.LimitResponse:
+			cnt.sizeWarn++
+		}
+	}
+}
+
+// ServeHTTP writes counters in Prometheus text exposition format.
+func (c *Counters) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	c.lock.Lock()
+	var uids = make([]string, 0, len(c.byUID))
+	var snapshot = make(map[string]counter, len(c.byUID))
+	for uid, cnt := range c.byUID {
+		uids = append(uids, uid)
+		snapshot[uid] = *cnt
+	}
+	c.lock.Unlock()
+	sort.Strings(uids)
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_invocations_total Total number of invocations.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_invocations_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_invocations_total{uid=%s} %d\n", strconv.Quote(uid), snapshot[uid].invocations)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_invocation_errors_total Total number of failed invocations.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_invocation_errors_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_invocation_errors_total{uid=%s} %d\n", strconv.Quote(uid), snapshot[uid].errors)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_rejections_total Total number of requests rejected without invocation.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_rejections_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_rejections_total{uid=%s} %d\n", strconv.Quote(uid), snapshot[uid].rejections)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_throttled_total Total number of requests rejected by rate limit.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_throttled_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_throttled_total{uid=%s} %d\n", strconv.Quote(uid), snapshot[uid].throttled)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_denied_total Total number of requests rejected by allowed networks of client.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_denied_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_denied_total{uid=%s} %d\n", strconv.Quote(uid), snapshot[uid].denied)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_invocation_seconds_total Total time spent in invocations.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_invocation_seconds_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_invocation_seconds_total{uid=%s} %g\n", strconv.Quote(uid), snapshot[uid].seconds)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_concurrency_wait_seconds_total Total time requests waited for free slot of concurrency limit.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_concurrency_wait_seconds_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_concurrency_wait_seconds_total{uid=%s} %g\n", strconv.Quote(uid), snapshot[uid].waitSeconds)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_queue_wait_seconds_total Total time executed requests waited in queue.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_queue_wait_seconds_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_queue_wait_seconds_total{uid=%s} %g\n", strconv.Quote(uid), snapshot[uid].queueWait)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_spawn_seconds Time to start lambda process or new worker (zero - warm worker).")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_spawn_seconds histogram")
+	for _, uid := range uids {
+		cnt := snapshot[uid]
+		var count uint64
+		for i, bound := range spawnBuckets {
+			count += cnt.spawns[i]
+			_, _ = fmt.Fprintf(writer, "trusted_cgi_spawn_seconds_bucket{uid=%s,le=\"%g\"} %d\n", strconv.Quote(uid), bound, count)
+		}
+		count += cnt.spawns[len(spawnBuckets)]
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_spawn_seconds_bucket{uid=%s,le=\"+Inf\"} %d\n", strconv.Quote(uid), count)
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_spawn_seconds_sum{uid=%s} %g\n", strconv.Quote(uid), cnt.spawnTotal)
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_spawn_seconds_count{uid=%s} %d\n", strconv.Quote(uid), count)
+	}
+	_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_limit_warnings_total Total number of invocations above warning threshold of soft limit.")
+	_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_limit_warnings_total counter")
+	for _, uid := range uids {
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_limit_warnings_total{uid=%s,limit=%q} %d\n", strconv.Quote(uid), types.LimitPayload, snapshot[uid].payloadWarn)
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_limit_warnings_total{uid=%s,limit=%q} %d\n", strconv.Quote(uid), types.LimitResponse, snapshot[uid].sizeWarn)
+	}
+	if c.Scheduler != nil {
+		jumps, reevaluations := c.Scheduler.SchedulerCounters()
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_clock_jumps_total Total number of detected wall-clock jumps.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_clock_jumps_total counter")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_clock_jumps_total %d\n", jumps)
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_schedule_reevaluations_total Total number of schedules re-evaluated after clock jumps.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_schedule_reevaluations_total counter")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_schedule_reevaluations_total %d\n", reevaluations)
+	}
+	if c.Streams != nil {
+		open := c.Streams.OpenStreams()
+		streamed := make([]string, 0, len(open))
+		for uid := range open {
+			streamed = append(streamed, uid)
+		}
+		sort.Strings(streamed)
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_open_streams Number of open streaming (SSE) connections.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_open_streams gauge")
+		for _, uid := range streamed {
+			_, _ = fmt.Fprintf(writer, "trusted_cgi_open_streams{uid=%s} %d\n", strconv.Quote(uid), open[uid])
+		}
+	}
+	if c.Mirror != nil {
+		status := c.Mirror.Status()
+		var enabled int
+		if status.Enabled {
+			enabled = 1
+		}
+		var lastSync float64
+		if !status.LastSync.IsZero() {
+			lastSync = float64(status.LastSync.UnixNano()) / 1e9
+		}
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_mirror_enabled Server is read-only mirror of primary (1) or promoted (0).")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_mirror_enabled gauge")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_mirror_enabled %d\n", enabled)
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_mirror_syncs_total Total number of synchronizations with primary.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_mirror_syncs_total counter")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_mirror_syncs_total %d\n", status.Syncs)
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_mirror_sync_failures_total Total number of failed synchronizations with primary.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_mirror_sync_failures_total counter")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_mirror_sync_failures_total %d\n", status.Failures)
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_mirror_pulls_total Total number of lambda contents pulled from primary.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_mirror_pulls_total counter")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_mirror_pulls_total %d\n", status.Pulls)
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_mirror_diverged Number of objects which differ from primary after the last synchronization.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_mirror_diverged gauge")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_mirror_diverged %d\n", len(status.Diverged))
+		_, _ = fmt.Fprintln(writer, "# HELP trusted_cgi_mirror_last_sync_timestamp_seconds Time of the last successful synchronization.")
+		_, _ = fmt.Fprintln(writer, "# TYPE trusted_cgi_mirror_last_sync_timestamp_seconds gauge")
+		_, _ = fmt.Fprintf(writer, "trusted_cgi_mirror_last_sync_timestamp_seconds %g\n", lastSync)
+	}
+}
@@ -9,11 +9,90 @@ import (
 
 // Tracking record
 type Record struct {
-	UID     string        `json:"uid" msg:"uid,omitempty"`             // app UID
-	Err     string        `json:"error,omitempty" msg:"err,omitempty"` // optional error
-	Request types.Request `json:"request" msg:"req,omitempty"`         // incoming request
-	Begin   time.Time     `json:"begin" msg:"beg,omitempty"`           // started time
-	End     time.Time     `json:"end" msg:"end,omitempty"`             // ended time
+	UID       string        `json:"uid" msg:"uid,omitempty"`                       // app UID
+	Err       string        `json:"error,omitempty" msg:"err,omitempty"`           // optional error
+	Request   types.Request `json:"request" msg:"req,omitempty"`                   // incoming request
+	Begin     time.Time     `json:"begin" msg:"beg,omitempty"`                     // started time
+	End       time.Time     `json:"end" msg:"end,omitempty"`                       // ended time
+	Rate      int           `json:"rate,omitempty" msg:"rate,omitempty"`           // sampling rate: record represents Rate invocations (zero is same as 1)
+	Coalesced bool          `json:"coalesced,omitempty" msg:"coalesced,omitempty"` // response shared from concurrent identical invocation
+	Cached    bool          `json:"cached,omitempty" msg:"cached,omitempty"`       // response served from cache without invocation
+	Rejected  bool          `json:"rejected,omitempty" msg:"rejected,omitempty"`   // request rejected without invocation (policy or content type)
+	Throttled bool          `json:"throttled,omitempty" msg:"throttled,omitempty"` // request rejected by rate limit (also rejected)
+	Status    int           `json:"status,omitempty" msg:"status,omitempty"`       // HTTP status of response (zero - nothing is sent)
+	Payload   int64         `json:"payload,omitempty" msg:"payload,omitempty"`     // size of read request body in bytes
+	Size      int64         `json:"size,omitempty" msg:"size,omitempty"`           // size of response body in bytes
+	CPU       time.Duration `json:"cpu,omitempty" msg:"cpu,omitempty"`             // CPU time (user and system) of lambda process
+	MaxRSS    int64         `json:"max_rss,omitempty" msg:"rss,omitempty"`         // maximum resident set size of lambda process in bytes
+	Warning   string        `json:"warning,omitempty" msg:"warn,omitempty"`        // non-fatal problem of invocation (ex: malformed response headers)
+	Limits    []string      `json:"limits,omitempty" msg:"limits,omitempty"`       // soft limits exceeded by invocation (payload, response)
+	Wait      time.Duration `json:"wait,omitempty" msg:"wait,omitempty"`           // time waited for free slot of concurrency limit
+	Overrun   bool          `json:"overrun,omitempty" msg:"overrun,omitempty"`     // output exceeded maximum response, process killed
+	Output    []byte        `json:"output,omitempty" msg:"out,omitempty"`          // prefix of response body (not more than OutputPrefix bytes)
+	Stderr    []byte        `json:"stderr,omitempty" msg:"stderr,omitempty"`       // tail of stderr of lambda process (not more than application.StderrTail bytes)
+	Queue     string        `json:"queue,omitempty" msg:"queue,omitempty"`         // queue of executed request (empty - not queued execution)
+	QueueWait time.Duration `json:"queue_wait,omitempty" msg:"qwait,omitempty"`    // time between put of request to queue and start of execution (not part of duration)
+	Attempt   int           `json:"attempt,omitempty" msg:"attempt,omitempty"`     // number of attempt of retried execution from 1 (zero - not retried)
+	Retried   bool          `json:"retried,omitempty" msg:"retried,omitempty"`     // failed attempt followed by another attempt: not counted as error
+	CatchUp   bool          `json:"catch_up,omitempty" msg:"catchup,omitempty"`    // scheduled run missed while server was down
+	Denied    bool          `json:"denied,omitempty" msg:"denied,omitempty"`       // request rejected by allowed networks of client (also rejected)
+	Timeout   bool          `json:"timeout,omitempty" msg:"timeout,omitempty"`     // invocation killed by time limit of manifest
+	Trigger   string        `json:"trigger,omitempty" msg:"trigger,omitempty"`     // source of invocation (see Trigger* constants, empty - recorded before triggers)
+	Spawn     time.Duration `json:"spawn,omitempty" msg:"spawn,omitempty"`         // time to start lambda process or new worker (zero - warm worker or not invoked)
+	FirstByte time.Duration `json:"first_byte,omitempty" msg:"ttfb,omitempty"`     // time from start of process (or of request to worker) to the first byte of output
+	Runtime   time.Duration `json:"runtime,omitempty" msg:"runtime,omitempty"`     // time from start of process to its exit (worker - to its reply)
+	Write     time.Duration `json:"write,omitempty" msg:"write,omitempty"`         // time blocked by writing response to client (streamed output - also during runtime)
+	ExitCode  int           `json:"exit_code,omitempty" msg:"exit,omitempty"`      // exit code of process or code of worker reply (zero - success or not exited)
+	Signal    string        `json:"signal,omitempty" msg:"signal,omitempty"`       // signal which killed process (ex: by time limit or overrun)
+}
+
+// Triggers of invocations
+const (
+	TriggerHTTP     = "http"     // request to lambda by UID or alias
+	TriggerQueue    = "queue"    // request put to queue or execution of queued request
+	TriggerSchedule = "schedule" // scheduled action or invocation
+	TriggerChain    = "chain"    // next step of chain (see types.Manifest.Then)
+	TriggerUnknown  = "unknown"  // record without trigger (tracked by previous versions)
+)
+
+// Alias of request to lambda by UID (see Record.Entry)
+const AliasDirect = "uid-direct"
+
+// Maximum size of response body prefix kept in record
+const OutputPrefix = 1024
+
+// Number of invocations represented by the record (for re-weighting aggregates of sampled records)
+func (z *Record) Weight() int {
+	if z.Rate <= 0 {
+		return 1
+	}
+	return z.Rate
+}
+
+// Source of invocation: trigger or TriggerUnknown for old records
+func (z *Record) Source() string {
+	if z.Trigger == "" {
+		return TriggerUnknown
+	}
+	return z.Trigger
+}
+
+// Entry of invocation: alias of request, AliasDirect for invocation not by alias (request by UID, schedule, queue)
+// or TriggerUnknown for old records without alias
+func (z *Record) Entry() string {
+	switch {
+	case z.Request.Alias != "":
+		return z.Request.Alias
+	case z.Trigger == "":
+		return TriggerUnknown
+	default:
+		return AliasDirect
+	}
+}
+
+// Failed invocation: error without following retry
+func (z *Record) Failed() bool {
+	return z.Err != "" && !z.Retried
 }
 
 // Recorder for apps requests
@@ -28,6 +107,10 @@ type Reader interface {
 	LastByUID(uid string, limit int) ([]Record, error)
 	// Last all records
 	Last(limit int) ([]Record, error)
+	// Range of records matched by query (lambda, time range) with limit
+	Range(query Query) ([]Record, error)
+	// Scan records matched by query (limit is ignored) till handler returns false. Record is valid only during call
+	Scan(query Query, handler func(record *Record) bool) error
 }
 
 type Stats interface {
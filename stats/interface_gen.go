@@ -56,6 +56,199 @@ func (z *Record) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "End")
 				return
 			}
+		case "rate":
+			z.Rate, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "Rate")
+				return
+			}
+		case "coalesced":
+			z.Coalesced, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Coalesced")
+				return
+			}
+		case "cached":
+			z.Cached, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Cached")
+				return
+			}
+		case "rejected":
+			z.Rejected, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Rejected")
+				return
+			}
+		case "throttled":
+			z.Throttled, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Throttled")
+				return
+			}
+		case "status":
+			z.Status, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "Status")
+				return
+			}
+		case "payload":
+			z.Payload, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Payload")
+				return
+			}
+		case "size":
+			z.Size, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Size")
+				return
+			}
+		case "cpu":
+			z.CPU, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "CPU")
+				return
+			}
+		case "rss":
+			z.MaxRSS, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "MaxRSS")
+				return
+			}
+		case "warn":
+			z.Warning, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Warning")
+				return
+			}
+		case "limits":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Limits")
+				return
+			}
+			if cap(z.Limits) >= int(zb0002) {
+				z.Limits = (z.Limits)[:zb0002]
+			} else {
+				z.Limits = make([]string, zb0002)
+			}
+			for za0001 := range z.Limits {
+				z.Limits[za0001], err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Limits", za0001)
+					return
+				}
+			}
+		case "wait":
+			z.Wait, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "Wait")
+				return
+			}
+		case "overrun":
+			z.Overrun, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Overrun")
+				return
+			}
+		case "out":
+			z.Output, err = dc.ReadBytes(z.Output)
+			if err != nil {
+				err = msgp.WrapError(err, "Output")
+				return
+			}
+		case "stderr":
+			z.Stderr, err = dc.ReadBytes(z.Stderr)
+			if err != nil {
+				err = msgp.WrapError(err, "Stderr")
+				return
+			}
+		case "queue":
+			z.Queue, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Queue")
+				return
+			}
+		case "qwait":
+			z.QueueWait, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "QueueWait")
+				return
+			}
+		case "attempt":
+			z.Attempt, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "Attempt")
+				return
+			}
+		case "retried":
+			z.Retried, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Retried")
+				return
+			}
+		case "catchup":
+			z.CatchUp, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "CatchUp")
+				return
+			}
+		case "denied":
+			z.Denied, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Denied")
+				return
+			}
+		case "timeout":
+			z.Timeout, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Timeout")
+				return
+			}
+		case "trigger":
+			z.Trigger, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Trigger")
+				return
+			}
+		case "spawn":
+			z.Spawn, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "Spawn")
+				return
+			}
+		case "ttfb":
+			z.FirstByte, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "FirstByte")
+				return
+			}
+		case "runtime":
+			z.Runtime, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "Runtime")
+				return
+			}
+		case "write":
+			z.Write, err = dc.ReadDuration()
+			if err != nil {
+				err = msgp.WrapError(err, "Write")
+				return
+			}
+		case "exit":
+			z.ExitCode, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "ExitCode")
+				return
+			}
+		case "signal":
+			z.Signal, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Signal")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -70,8 +263,9 @@ func (z *Record) DecodeMsg(dc *msgp.Reader) (err error) {
 // EncodeMsg implements msgp.Encodable
 func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 	// omitempty: check for empty values
-	zb0001Len := uint32(5)
-	var zb0001Mask uint8 /* 5 bits */
+	zb0001Len := uint32(35)
+	var zb0001Mask uint64 /* 35 bits */
+	_ = zb0001Mask
 	if z.UID == "" {
 		zb0001Len--
 		zb0001Mask |= 0x1
@@ -84,73 +278,560 @@ func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 		zb0001Len--
 		zb0001Mask |= 0x8
 	}
-	if z.End == (time.Time{}) {
-		zb0001Len--
-		zb0001Mask |= 0x10
+	if z.End == (time.Time{}) {
+		zb0001Len--
+		zb0001Mask |= 0x10
+	}
+	if z.Rate == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20
+	}
+	if z.Coalesced == false {
+		zb0001Len--
+		zb0001Mask |= 0x40
+	}
+	if z.Cached == false {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.Rejected == false {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.Throttled == false {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Status == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	if z.Payload == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x800
+	}
+	if z.Size == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x1000
+	}
+	if z.CPU == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x2000
+	}
+	if z.MaxRSS == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x4000
+	}
+	if z.Warning == "" {
+		zb0001Len--
+		zb0001Mask |= 0x8000
+	}
+	if z.Limits == nil {
+		zb0001Len--
+		zb0001Mask |= 0x10000
+	}
+	if z.Wait == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20000
+	}
+	if z.Overrun == false {
+		zb0001Len--
+		zb0001Mask |= 0x40000
+	}
+	if z.Output == nil {
+		zb0001Len--
+		zb0001Mask |= 0x80000
+	}
+	if z.Stderr == nil {
+		zb0001Len--
+		zb0001Mask |= 0x100000
+	}
+	if z.Queue == "" {
+		zb0001Len--
+		zb0001Mask |= 0x200000
+	}
+	if z.QueueWait == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x400000
+	}
+	if z.Attempt == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x800000
+	}
+	if z.Retried == false {
+		zb0001Len--
+		zb0001Mask |= 0x1000000
+	}
+	if z.CatchUp == false {
+		zb0001Len--
+		zb0001Mask |= 0x2000000
+	}
+	if z.Denied == false {
+		zb0001Len--
+		zb0001Mask |= 0x4000000
+	}
+	if z.Timeout == false {
+		zb0001Len--
+		zb0001Mask |= 0x8000000
+	}
+	if z.Trigger == "" {
+		zb0001Len--
+		zb0001Mask |= 0x10000000
+	}
+	if z.Spawn == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20000000
+	}
+	if z.FirstByte == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x40000000
+	}
+	if z.Runtime == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x80000000
+	}
+	if z.Write == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x100000000
+	}
+	if z.ExitCode == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x200000000
+	}
+	if z.Signal == "" {
+		zb0001Len--
+		zb0001Mask |= 0x400000000
+	}
+	// variable map header, size zb0001Len
+	err = en.WriteMapHeader(zb0001Len)
+	if err != nil {
+		return
+	}
+	if zb0001Len == 0 {
+		return
+	}
+	if (zb0001Mask & 0x1) == 0 { // if not empty
+		// write "uid"
+		err = en.Append(0xa3, 0x75, 0x69, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.UID)
+		if err != nil {
+			err = msgp.WrapError(err, "UID")
+			return
+		}
+	}
+	if (zb0001Mask & 0x2) == 0 { // if not empty
+		// write "err"
+		err = en.Append(0xa3, 0x65, 0x72, 0x72)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Err)
+		if err != nil {
+			err = msgp.WrapError(err, "Err")
+			return
+		}
+	}
+	// write "req"
+	err = en.Append(0xa3, 0x72, 0x65, 0x71)
+	if err != nil {
+		return
+	}
+	err = z.Request.EncodeMsg(en)
+	if err != nil {
+		err = msgp.WrapError(err, "Request")
+		return
+	}
+	if (zb0001Mask & 0x8) == 0 { // if not empty
+		// write "beg"
+		err = en.Append(0xa3, 0x62, 0x65, 0x67)
+		if err != nil {
+			return
+		}
+		err = en.WriteTime(z.Begin)
+		if err != nil {
+			err = msgp.WrapError(err, "Begin")
+			return
+		}
+	}
+	if (zb0001Mask & 0x10) == 0 { // if not empty
+		// write "end"
+		err = en.Append(0xa3, 0x65, 0x6e, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteTime(z.End)
+		if err != nil {
+			err = msgp.WrapError(err, "End")
+			return
+		}
+	}
+	if (zb0001Mask & 0x20) == 0 { // if not empty
+		// write "rate"
+		err = en.Append(0xa4, 0x72, 0x61, 0x74, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt(z.Rate)
+		if err != nil {
+			err = msgp.WrapError(err, "Rate")
+			return
+		}
+	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
+		// write "coalesced"
+		err = en.Append(0xa9, 0x63, 0x6f, 0x61, 0x6c, 0x65, 0x73, 0x63, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Coalesced)
+		if err != nil {
+			err = msgp.WrapError(err, "Coalesced")
+			return
+		}
+	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// write "cached"
+		err = en.Append(0xa6, 0x63, 0x61, 0x63, 0x68, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Cached)
+		if err != nil {
+			err = msgp.WrapError(err, "Cached")
+			return
+		}
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// write "rejected"
+		err = en.Append(0xa8, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Rejected)
+		if err != nil {
+			err = msgp.WrapError(err, "Rejected")
+			return
+		}
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// write "throttled"
+		err = en.Append(0xa9, 0x74, 0x68, 0x72, 0x6f, 0x74, 0x74, 0x6c, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Throttled)
+		if err != nil {
+			err = msgp.WrapError(err, "Throttled")
+			return
+		}
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// write "status"
+		err = en.Append(0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt(z.Status)
+		if err != nil {
+			err = msgp.WrapError(err, "Status")
+			return
+		}
+	}
+	if (zb0001Mask & 0x800) == 0 { // if not empty
+		// write "payload"
+		err = en.Append(0xa7, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt64(z.Payload)
+		if err != nil {
+			err = msgp.WrapError(err, "Payload")
+			return
+		}
+	}
+	if (zb0001Mask & 0x1000) == 0 { // if not empty
+		// write "size"
+		err = en.Append(0xa4, 0x73, 0x69, 0x7a, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt64(z.Size)
+		if err != nil {
+			err = msgp.WrapError(err, "Size")
+			return
+		}
+	}
+	if (zb0001Mask & 0x2000) == 0 { // if not empty
+		// write "cpu"
+		err = en.Append(0xa3, 0x63, 0x70, 0x75)
+		if err != nil {
+			return
+		}
+		err = en.WriteDuration(z.CPU)
+		if err != nil {
+			err = msgp.WrapError(err, "CPU")
+			return
+		}
+	}
+	if (zb0001Mask & 0x4000) == 0 { // if not empty
+		// write "rss"
+		err = en.Append(0xa3, 0x72, 0x73, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt64(z.MaxRSS)
+		if err != nil {
+			err = msgp.WrapError(err, "MaxRSS")
+			return
+		}
+	}
+	if (zb0001Mask & 0x8000) == 0 { // if not empty
+		// write "warn"
+		err = en.Append(0xa4, 0x77, 0x61, 0x72, 0x6e)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Warning)
+		if err != nil {
+			err = msgp.WrapError(err, "Warning")
+			return
+		}
+	}
+	if (zb0001Mask & 0x10000) == 0 { // if not empty
+		// write "limits"
+		err = en.Append(0xa6, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteArrayHeader(uint32(len(z.Limits)))
+		if err != nil {
+			err = msgp.WrapError(err, "Limits")
+			return
+		}
+		for za0001 := range z.Limits {
+			err = en.WriteString(z.Limits[za0001])
+			if err != nil {
+				err = msgp.WrapError(err, "Limits", za0001)
+				return
+			}
+		}
+	}
+	if (zb0001Mask & 0x20000) == 0 { // if not empty
+		// write "wait"
+		err = en.Append(0xa4, 0x77, 0x61, 0x69, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteDuration(z.Wait)
+		if err != nil {
+			err = msgp.WrapError(err, "Wait")
+			return
+		}
+	}
+	if (zb0001Mask & 0x40000) == 0 { // if not empty
+		// write "overrun"
+		err = en.Append(0xa7, 0x6f, 0x76, 0x65, 0x72, 0x72, 0x75, 0x6e)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Overrun)
+		if err != nil {
+			err = msgp.WrapError(err, "Overrun")
+			return
+		}
+	}
+	if (zb0001Mask & 0x80000) == 0 { // if not empty
+		// write "out"
+		err = en.Append(0xa3, 0x6f, 0x75, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteBytes(z.Output)
+		if err != nil {
+			err = msgp.WrapError(err, "Output")
+			return
+		}
+	}
+	if (zb0001Mask & 0x100000) == 0 { // if not empty
+		// write "stderr"
+		err = en.Append(0xa6, 0x73, 0x74, 0x64, 0x65, 0x72, 0x72)
+		if err != nil {
+			return
+		}
+		err = en.WriteBytes(z.Stderr)
+		if err != nil {
+			err = msgp.WrapError(err, "Stderr")
+			return
+		}
+	}
+	if (zb0001Mask & 0x200000) == 0 { // if not empty
+		// write "queue"
+		err = en.Append(0xa5, 0x71, 0x75, 0x65, 0x75, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Queue)
+		if err != nil {
+			err = msgp.WrapError(err, "Queue")
+			return
+		}
+	}
+	if (zb0001Mask & 0x400000) == 0 { // if not empty
+		// write "qwait"
+		err = en.Append(0xa5, 0x71, 0x77, 0x61, 0x69, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteDuration(z.QueueWait)
+		if err != nil {
+			err = msgp.WrapError(err, "QueueWait")
+			return
+		}
+	}
+	if (zb0001Mask & 0x800000) == 0 { // if not empty
+		// write "attempt"
+		err = en.Append(0xa7, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt(z.Attempt)
+		if err != nil {
+			err = msgp.WrapError(err, "Attempt")
+			return
+		}
+	}
+	if (zb0001Mask & 0x1000000) == 0 { // if not empty
+		// write "retried"
+		err = en.Append(0xa7, 0x72, 0x65, 0x74, 0x72, 0x69, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Retried)
+		if err != nil {
+			err = msgp.WrapError(err, "Retried")
+			return
+		}
+	}
+	if (zb0001Mask & 0x2000000) == 0 { // if not empty
+		// write "catchup"
+		err = en.Append(0xa7, 0x63, 0x61, 0x74, 0x63, 0x68, 0x75, 0x70)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.CatchUp)
+		if err != nil {
+			err = msgp.WrapError(err, "CatchUp")
+			return
+		}
+	}
+	if (zb0001Mask & 0x4000000) == 0 { // if not empty
+		// write "denied"
+		err = en.Append(0xa6, 0x64, 0x65, 0x6e, 0x69, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Denied)
+		if err != nil {
+			err = msgp.WrapError(err, "Denied")
+			return
+		}
 	}
-	// variable map header, size zb0001Len
-	err = en.Append(0x80 | uint8(zb0001Len))
-	if err != nil {
-		return
+	if (zb0001Mask & 0x8000000) == 0 { // if not empty
+		// write "timeout"
+		err = en.Append(0xa7, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Timeout)
+		if err != nil {
+			err = msgp.WrapError(err, "Timeout")
+			return
+		}
 	}
-	if zb0001Len == 0 {
-		return
+	if (zb0001Mask & 0x10000000) == 0 { // if not empty
+		// write "trigger"
+		err = en.Append(0xa7, 0x74, 0x72, 0x69, 0x67, 0x67, 0x65, 0x72)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Trigger)
+		if err != nil {
+			err = msgp.WrapError(err, "Trigger")
+			return
+		}
 	}
-	if (zb0001Mask & 0x1) == 0 { // if not empty
-		// write "uid"
-		err = en.Append(0xa3, 0x75, 0x69, 0x64)
+	if (zb0001Mask & 0x20000000) == 0 { // if not empty
+		// write "spawn"
+		err = en.Append(0xa5, 0x73, 0x70, 0x61, 0x77, 0x6e)
 		if err != nil {
 			return
 		}
-		err = en.WriteString(z.UID)
+		err = en.WriteDuration(z.Spawn)
 		if err != nil {
-			err = msgp.WrapError(err, "UID")
+			err = msgp.WrapError(err, "Spawn")
 			return
 		}
 	}
-	if (zb0001Mask & 0x2) == 0 { // if not empty
-		// write "err"
-		err = en.Append(0xa3, 0x65, 0x72, 0x72)
+	if (zb0001Mask & 0x40000000) == 0 { // if not empty
+		// write "ttfb"
+		err = en.Append(0xa4, 0x74, 0x74, 0x66, 0x62)
 		if err != nil {
 			return
 		}
-		err = en.WriteString(z.Err)
+		err = en.WriteDuration(z.FirstByte)
 		if err != nil {
-			err = msgp.WrapError(err, "Err")
+			err = msgp.WrapError(err, "FirstByte")
 			return
 		}
 	}
-	// write "req"
-	err = en.Append(0xa3, 0x72, 0x65, 0x71)
-	if err != nil {
-		return
+	if (zb0001Mask & 0x80000000) == 0 { // if not empty
+		// write "runtime"
+		err = en.Append(0xa7, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteDuration(z.Runtime)
+		if err != nil {
+			err = msgp.WrapError(err, "Runtime")
+			return
+		}
 	}
-	err = z.Request.EncodeMsg(en)
-	if err != nil {
-		err = msgp.WrapError(err, "Request")
-		return
+	if (zb0001Mask & 0x100000000) == 0 { // if not empty
+		// write "write"
+		err = en.Append(0xa5, 0x77, 0x72, 0x69, 0x74, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteDuration(z.Write)
+		if err != nil {
+			err = msgp.WrapError(err, "Write")
+			return
+		}
 	}
-	if (zb0001Mask & 0x8) == 0 { // if not empty
-		// write "beg"
-		err = en.Append(0xa3, 0x62, 0x65, 0x67)
+	if (zb0001Mask & 0x200000000) == 0 { // if not empty
+		// write "exit"
+		err = en.Append(0xa4, 0x65, 0x78, 0x69, 0x74)
 		if err != nil {
 			return
 		}
-		err = en.WriteTime(z.Begin)
+		err = en.WriteInt(z.ExitCode)
 		if err != nil {
-			err = msgp.WrapError(err, "Begin")
+			err = msgp.WrapError(err, "ExitCode")
 			return
 		}
 	}
-	if (zb0001Mask & 0x10) == 0 { // if not empty
-		// write "end"
-		err = en.Append(0xa3, 0x65, 0x6e, 0x64)
+	if (zb0001Mask & 0x400000000) == 0 { // if not empty
+		// write "signal"
+		err = en.Append(0xa6, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c)
 		if err != nil {
 			return
 		}
-		err = en.WriteTime(z.End)
+		err = en.WriteString(z.Signal)
 		if err != nil {
-			err = msgp.WrapError(err, "End")
+			err = msgp.WrapError(err, "Signal")
 			return
 		}
 	}
@@ -161,8 +842,9 @@ func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 func (z *Record) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
 	// omitempty: check for empty values
-	zb0001Len := uint32(5)
-	var zb0001Mask uint8 /* 5 bits */
+	zb0001Len := uint32(35)
+	var zb0001Mask uint64 /* 35 bits */
+	_ = zb0001Mask
 	if z.UID == "" {
 		zb0001Len--
 		zb0001Mask |= 0x1
@@ -179,8 +861,128 @@ func (z *Record) MarshalMsg(b []byte) (o []byte, err error) {
 		zb0001Len--
 		zb0001Mask |= 0x10
 	}
+	if z.Rate == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20
+	}
+	if z.Coalesced == false {
+		zb0001Len--
+		zb0001Mask |= 0x40
+	}
+	if z.Cached == false {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.Rejected == false {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.Throttled == false {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Status == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	if z.Payload == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x800
+	}
+	if z.Size == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x1000
+	}
+	if z.CPU == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x2000
+	}
+	if z.MaxRSS == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x4000
+	}
+	if z.Warning == "" {
+		zb0001Len--
+		zb0001Mask |= 0x8000
+	}
+	if z.Limits == nil {
+		zb0001Len--
+		zb0001Mask |= 0x10000
+	}
+	if z.Wait == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20000
+	}
+	if z.Overrun == false {
+		zb0001Len--
+		zb0001Mask |= 0x40000
+	}
+	if z.Output == nil {
+		zb0001Len--
+		zb0001Mask |= 0x80000
+	}
+	if z.Stderr == nil {
+		zb0001Len--
+		zb0001Mask |= 0x100000
+	}
+	if z.Queue == "" {
+		zb0001Len--
+		zb0001Mask |= 0x200000
+	}
+	if z.QueueWait == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x400000
+	}
+	if z.Attempt == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x800000
+	}
+	if z.Retried == false {
+		zb0001Len--
+		zb0001Mask |= 0x1000000
+	}
+	if z.CatchUp == false {
+		zb0001Len--
+		zb0001Mask |= 0x2000000
+	}
+	if z.Denied == false {
+		zb0001Len--
+		zb0001Mask |= 0x4000000
+	}
+	if z.Timeout == false {
+		zb0001Len--
+		zb0001Mask |= 0x8000000
+	}
+	if z.Trigger == "" {
+		zb0001Len--
+		zb0001Mask |= 0x10000000
+	}
+	if z.Spawn == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20000000
+	}
+	if z.FirstByte == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x40000000
+	}
+	if z.Runtime == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x80000000
+	}
+	if z.Write == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x100000000
+	}
+	if z.ExitCode == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x200000000
+	}
+	if z.Signal == "" {
+		zb0001Len--
+		zb0001Mask |= 0x400000000
+	}
 	// variable map header, size zb0001Len
-	o = append(o, 0x80|uint8(zb0001Len))
+	o = msgp.AppendMapHeader(o, zb0001Len)
 	if zb0001Len == 0 {
 		return
 	}
@@ -211,6 +1013,159 @@ func (z *Record) MarshalMsg(b []byte) (o []byte, err error) {
 		o = append(o, 0xa3, 0x65, 0x6e, 0x64)
 		o = msgp.AppendTime(o, z.End)
 	}
+	if (zb0001Mask & 0x20) == 0 { // if not empty
+		// string "rate"
+		o = append(o, 0xa4, 0x72, 0x61, 0x74, 0x65)
+		o = msgp.AppendInt(o, z.Rate)
+	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
+		// string "coalesced"
+		o = append(o, 0xa9, 0x63, 0x6f, 0x61, 0x6c, 0x65, 0x73, 0x63, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.Coalesced)
+	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// string "cached"
+		o = append(o, 0xa6, 0x63, 0x61, 0x63, 0x68, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.Cached)
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// string "rejected"
+		o = append(o, 0xa8, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.Rejected)
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// string "throttled"
+		o = append(o, 0xa9, 0x74, 0x68, 0x72, 0x6f, 0x74, 0x74, 0x6c, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.Throttled)
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// string "status"
+		o = append(o, 0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
+		o = msgp.AppendInt(o, z.Status)
+	}
+	if (zb0001Mask & 0x800) == 0 { // if not empty
+		// string "payload"
+		o = append(o, 0xa7, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64)
+		o = msgp.AppendInt64(o, z.Payload)
+	}
+	if (zb0001Mask & 0x1000) == 0 { // if not empty
+		// string "size"
+		o = append(o, 0xa4, 0x73, 0x69, 0x7a, 0x65)
+		o = msgp.AppendInt64(o, z.Size)
+	}
+	if (zb0001Mask & 0x2000) == 0 { // if not empty
+		// string "cpu"
+		o = append(o, 0xa3, 0x63, 0x70, 0x75)
+		o = msgp.AppendDuration(o, z.CPU)
+	}
+	if (zb0001Mask & 0x4000) == 0 { // if not empty
+		// string "rss"
+		o = append(o, 0xa3, 0x72, 0x73, 0x73)
+		o = msgp.AppendInt64(o, z.MaxRSS)
+	}
+	if (zb0001Mask & 0x8000) == 0 { // if not empty
+		// string "warn"
+		o = append(o, 0xa4, 0x77, 0x61, 0x72, 0x6e)
+		o = msgp.AppendString(o, z.Warning)
+	}
+	if (zb0001Mask & 0x10000) == 0 { // if not empty
+		// string "limits"
+		o = append(o, 0xa6, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x73)
+		o = msgp.AppendArrayHeader(o, uint32(len(z.Limits)))
+		for za0001 := range z.Limits {
+			o = msgp.AppendString(o, z.Limits[za0001])
+		}
+	}
+	if (zb0001Mask & 0x20000) == 0 { // if not empty
+		// string "wait"
+		o = append(o, 0xa4, 0x77, 0x61, 0x69, 0x74)
+		o = msgp.AppendDuration(o, z.Wait)
+	}
+	if (zb0001Mask & 0x40000) == 0 { // if not empty
+		// string "overrun"
+		o = append(o, 0xa7, 0x6f, 0x76, 0x65, 0x72, 0x72, 0x75, 0x6e)
+		o = msgp.AppendBool(o, z.Overrun)
+	}
+	if (zb0001Mask & 0x80000) == 0 { // if not empty
+		// string "out"
+		o = append(o, 0xa3, 0x6f, 0x75, 0x74)
+		o = msgp.AppendBytes(o, z.Output)
+	}
+	if (zb0001Mask & 0x100000) == 0 { // if not empty
+		// string "stderr"
+		o = append(o, 0xa6, 0x73, 0x74, 0x64, 0x65, 0x72, 0x72)
+		o = msgp.AppendBytes(o, z.Stderr)
+	}
+	if (zb0001Mask & 0x200000) == 0 { // if not empty
+		// string "queue"
+		o = append(o, 0xa5, 0x71, 0x75, 0x65, 0x75, 0x65)
+		o = msgp.AppendString(o, z.Queue)
+	}
+	if (zb0001Mask & 0x400000) == 0 { // if not empty
+		// string "qwait"
+		o = append(o, 0xa5, 0x71, 0x77, 0x61, 0x69, 0x74)
+		o = msgp.AppendDuration(o, z.QueueWait)
+	}
+	if (zb0001Mask & 0x800000) == 0 { // if not empty
+		// string "attempt"
+		o = append(o, 0xa7, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74)
+		o = msgp.AppendInt(o, z.Attempt)
+	}
+	if (zb0001Mask & 0x1000000) == 0 { // if not empty
+		// string "retried"
+		o = append(o, 0xa7, 0x72, 0x65, 0x74, 0x72, 0x69, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.Retried)
+	}
+	if (zb0001Mask & 0x2000000) == 0 { // if not empty
+		// string "catchup"
+		o = append(o, 0xa7, 0x63, 0x61, 0x74, 0x63, 0x68, 0x75, 0x70)
+		o = msgp.AppendBool(o, z.CatchUp)
+	}
+	if (zb0001Mask & 0x4000000) == 0 { // if not empty
+		// string "denied"
+		o = append(o, 0xa6, 0x64, 0x65, 0x6e, 0x69, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.Denied)
+	}
+	if (zb0001Mask & 0x8000000) == 0 { // if not empty
+		// string "timeout"
+		o = append(o, 0xa7, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74)
+		o = msgp.AppendBool(o, z.Timeout)
+	}
+	if (zb0001Mask & 0x10000000) == 0 { // if not empty
+		// string "trigger"
+		o = append(o, 0xa7, 0x74, 0x72, 0x69, 0x67, 0x67, 0x65, 0x72)
+		o = msgp.AppendString(o, z.Trigger)
+	}
+	if (zb0001Mask & 0x20000000) == 0 { // if not empty
+		// string "spawn"
+		o = append(o, 0xa5, 0x73, 0x70, 0x61, 0x77, 0x6e)
+		o = msgp.AppendDuration(o, z.Spawn)
+	}
+	if (zb0001Mask & 0x40000000) == 0 { // if not empty
+		// string "ttfb"
+		o = append(o, 0xa4, 0x74, 0x74, 0x66, 0x62)
+		o = msgp.AppendDuration(o, z.FirstByte)
+	}
+	if (zb0001Mask & 0x80000000) == 0 { // if not empty
+		// string "runtime"
+		o = append(o, 0xa7, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65)
+		o = msgp.AppendDuration(o, z.Runtime)
+	}
+	if (zb0001Mask & 0x100000000) == 0 { // if not empty
+		// string "write"
+		o = append(o, 0xa5, 0x77, 0x72, 0x69, 0x74, 0x65)
+		o = msgp.AppendDuration(o, z.Write)
+	}
+	if (zb0001Mask & 0x200000000) == 0 { // if not empty
+		// string "exit"
+		o = append(o, 0xa4, 0x65, 0x78, 0x69, 0x74)
+		o = msgp.AppendInt(o, z.ExitCode)
+	}
+	if (zb0001Mask & 0x400000000) == 0 { // if not empty
+		// string "signal"
+		o = append(o, 0xa6, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c)
+		o = msgp.AppendString(o, z.Signal)
+	}
 	return
 }
 
@@ -262,6 +1217,199 @@ func (z *Record) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "End")
 				return
 			}
+		case "rate":
+			z.Rate, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Rate")
+				return
+			}
+		case "coalesced":
+			z.Coalesced, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Coalesced")
+				return
+			}
+		case "cached":
+			z.Cached, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Cached")
+				return
+			}
+		case "rejected":
+			z.Rejected, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Rejected")
+				return
+			}
+		case "throttled":
+			z.Throttled, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Throttled")
+				return
+			}
+		case "status":
+			z.Status, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Status")
+				return
+			}
+		case "payload":
+			z.Payload, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Payload")
+				return
+			}
+		case "size":
+			z.Size, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Size")
+				return
+			}
+		case "cpu":
+			z.CPU, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "CPU")
+				return
+			}
+		case "rss":
+			z.MaxRSS, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "MaxRSS")
+				return
+			}
+		case "warn":
+			z.Warning, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Warning")
+				return
+			}
+		case "limits":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Limits")
+				return
+			}
+			if cap(z.Limits) >= int(zb0002) {
+				z.Limits = (z.Limits)[:zb0002]
+			} else {
+				z.Limits = make([]string, zb0002)
+			}
+			for za0001 := range z.Limits {
+				z.Limits[za0001], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Limits", za0001)
+					return
+				}
+			}
+		case "wait":
+			z.Wait, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Wait")
+				return
+			}
+		case "overrun":
+			z.Overrun, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Overrun")
+				return
+			}
+		case "out":
+			z.Output, bts, err = msgp.ReadBytesBytes(bts, z.Output)
+			if err != nil {
+				err = msgp.WrapError(err, "Output")
+				return
+			}
+		case "stderr":
+			z.Stderr, bts, err = msgp.ReadBytesBytes(bts, z.Stderr)
+			if err != nil {
+				err = msgp.WrapError(err, "Stderr")
+				return
+			}
+		case "queue":
+			z.Queue, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Queue")
+				return
+			}
+		case "qwait":
+			z.QueueWait, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "QueueWait")
+				return
+			}
+		case "attempt":
+			z.Attempt, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Attempt")
+				return
+			}
+		case "retried":
+			z.Retried, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Retried")
+				return
+			}
+		case "catchup":
+			z.CatchUp, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "CatchUp")
+				return
+			}
+		case "denied":
+			z.Denied, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Denied")
+				return
+			}
+		case "timeout":
+			z.Timeout, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Timeout")
+				return
+			}
+		case "trigger":
+			z.Trigger, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Trigger")
+				return
+			}
+		case "spawn":
+			z.Spawn, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Spawn")
+				return
+			}
+		case "ttfb":
+			z.FirstByte, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "FirstByte")
+				return
+			}
+		case "runtime":
+			z.Runtime, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Runtime")
+				return
+			}
+		case "write":
+			z.Write, bts, err = msgp.ReadDurationBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Write")
+				return
+			}
+		case "exit":
+			z.ExitCode, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ExitCode")
+				return
+			}
+		case "signal":
+			z.Signal, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Signal")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -276,6 +1424,10 @@ func (z *Record) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Record) Msgsize() (s int) {
-	s = 1 + 4 + msgp.StringPrefixSize + len(z.UID) + 4 + msgp.StringPrefixSize + len(z.Err) + 4 + z.Request.Msgsize() + 4 + msgp.TimeSize + 4 + msgp.TimeSize
+	s = 3 + 4 + msgp.StringPrefixSize + len(z.UID) + 4 + msgp.StringPrefixSize + len(z.Err) + 4 + z.Request.Msgsize() + 4 + msgp.TimeSize + 4 + msgp.TimeSize + 5 + msgp.IntSize + 10 + msgp.BoolSize + 7 + msgp.BoolSize + 9 + msgp.BoolSize + 10 + msgp.BoolSize + 7 + msgp.IntSize + 8 + msgp.Int64Size + 5 + msgp.Int64Size + 4 + msgp.DurationSize + 4 + msgp.Int64Size + 5 + msgp.StringPrefixSize + len(z.Warning) + 7 + msgp.ArrayHeaderSize
+	for za0001 := range z.Limits {
+		s += msgp.StringPrefixSize + len(z.Limits[za0001])
+	}
+	s += 5 + msgp.DurationSize + 8 + msgp.BoolSize + 4 + msgp.BytesPrefixSize + len(z.Output) + 7 + msgp.BytesPrefixSize + len(z.Stderr) + 6 + msgp.StringPrefixSize + len(z.Queue) + 6 + msgp.DurationSize + 8 + msgp.IntSize + 8 + msgp.BoolSize + 8 + msgp.BoolSize + 7 + msgp.BoolSize + 8 + msgp.BoolSize + 8 + msgp.StringPrefixSize + len(z.Trigger) + 6 + msgp.DurationSize + 5 + msgp.DurationSize + 8 + msgp.DurationSize + 6 + msgp.DurationSize + 5 + msgp.IntSize + 7 + msgp.StringPrefixSize + len(z.Signal)
 	return
 }
@@ -0,0 +1,30 @@
+package stats
+
+import "time"
+
+// Query of records by lambda and time range. Time of record is the end of invocation
+type Query struct {
+	UID   string    `json:"uid,omitempty"`   // records of lambda (empty - of all lambdas)
+	Since time.Time `json:"since,omitempty"` // records ended at or after the moment (zero - not limited)
+	Until time.Time `json:"until,omitempty"` // records ended before the moment (zero - not limited)
+	Limit int       `json:"limit"`           // maximum number of records
+}
+
+// Matches record by lambda and time range
+func (q *Query) Matches(record *Record) bool {
+	if q.UID != "" && record.UID != q.UID {
+		return false
+	}
+	if !q.Since.IsZero() && record.End.Before(q.Since) {
+		return false
+	}
+	return q.Until.IsZero() || record.End.Before(q.Until)
+}
+
+// Overlaps time range of records from the first to the last moment
+func (q *Query) Overlaps(first, last time.Time) bool {
+	if !q.Since.IsZero() && last.Before(q.Since) {
+		return false
+	}
+	return q.Until.IsZero() || first.Before(q.Until)
+}
@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Retention of kept records: the oldest records over limits are removed by pruning (zero limit - not limited)
+type Retention struct {
+	MaxAge     types.JsonDuration         `json:"max_age,omitempty"`     // records older than the age are removed
+	MaxRecords int                        `json:"max_records,omitempty"` // the oldest records over the number are removed
+	MaxSize    int64                      `json:"max_size,omitempty"`    // the oldest records over size on disk in bytes are removed
+	Lambdas    map[string]LambdaRetention `json:"lambdas,omitempty"`     // retention of lambdas by UID
+}
+
+// Retention of records of lambda. Maximum age overrides age of server, limits of server are applied as well
+type LambdaRetention struct {
+	MaxAge     types.JsonDuration `json:"max_age,omitempty"`     // records of lambda older than the age are removed
+	MaxRecords int                `json:"max_records,omitempty"` // the oldest records of lambda over the number are removed
+}
+
+// Validate limits: negative limits are not allowed
+func (r Retention) Validate() error {
+	if r.MaxAge < 0 || r.MaxRecords < 0 || r.MaxSize < 0 {
+		return fmt.Errorf("limits of retention should not be negative")
+	}
+	for uid, limits := range r.Lambdas {
+		if limits.MaxAge < 0 || limits.MaxRecords < 0 {
+			return fmt.Errorf("limits of retention of lambda %s should not be negative", uid)
+		}
+	}
+	return nil
+}
+
+// Age of records of lambda: age of lambda, age of server otherwise (zero - not limited)
+func (r Retention) Age(uid string) time.Duration {
+	if limits, ok := r.Lambdas[uid]; ok && limits.MaxAge > 0 {
+		return time.Duration(limits.MaxAge)
+	}
+	return time.Duration(r.MaxAge)
+}
+
+// Copy of retention which doesn't share lambdas
+func (r Retention) Copy() Retention {
+	if r.Lambdas == nil {
+		return r
+	}
+	lambdas := make(map[string]LambdaRetention, len(r.Lambdas))
+	for uid, limits := range r.Lambdas {
+		lambdas[uid] = limits
+	}
+	r.Lambdas = lambdas
+	return r
+}
+
+// Usage of storage of records
+type Usage struct {
+	Records   int       `json:"records"`   // kept records, also waiting for write
+	Size      int64     `json:"size"`      // size of records on disk in bytes
+	Segments  int       `json:"segments"`  // files of records
+	Pending   int       `json:"pending"`   // records waiting for write to disk
+	Oldest    time.Time `json:"oldest"`    // end of the oldest record (zero - no records)
+	Newest    time.Time `json:"newest"`    // end of the newest record (zero - no records)
+	Retention Retention `json:"retention"` // current retention
+	Pruning   Pruning   `json:"pruning"`   // the last pruning
+}
+
+// Result of pruning of records
+type Pruning struct {
+	Time     time.Time          `json:"time"`            // end of pruning (zero - not pruned since start)
+	Duration types.JsonDuration `json:"duration"`        // duration of pruning
+	Removed  int                `json:"removed"`         // records removed by pruning
+	Early    int                `json:"early"`           // removed records newer than maximum age: by limits of number or size
+	Total    int64              `json:"total"`           // records removed since start
+	Behind   bool               `json:"behind"`          // limits are exceeded after pruning: pruning doesn't keep up
+	Error    string             `json:"error,omitempty"` // failure of pruning
+}
+
+// Retainer is storage of records with retention changed at runtime
+type Retainer interface {
+	// Retention of records
+	Retention() Retention
+	// SetRetention saves retention and starts pruning by it
+	SetRetention(retention Retention) error
+	// Usage of storage
+	Usage() Usage
+}
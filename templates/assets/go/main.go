@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/reddec/trusted-cgi/sdk"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func main() {
+	sdk.Handle(func(ctx context.Context, req *sdk.Request) (*sdk.Response, error) {
+		var request greeting
+		if req.Method == http.MethodGet {
+			request.Name = req.Query.Get("name")
+		} else if err := req.JSON(&request); err != nil {
+			return sdk.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request: " + err.Error()})
+		}
+		if request.Name == "" {
+			return sdk.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return sdk.JSON(http.StatusOK, map[string]string{"greeting": "hello, " + request.Name})
+	})
+}
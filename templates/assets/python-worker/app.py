@@ -0,0 +1,43 @@
+import base64
+import json
+import os
+import sys
+
+
+def load_secrets():
+    # secret variables: by descriptor or file (see secrets in manifest) or from environment (default)
+    if 'SECRETS_FD' in os.environ:
+        with os.fdopen(int(os.environ['SECRETS_FD'])) as f:
+            return json.load(f)
+    if 'SECRETS_FILE' in os.environ:
+        with open(os.environ['SECRETS_FILE']) as f:
+            return json.load(f)
+    return {k: v for k, v in os.environ.items() if k.upper().endswith(('_SECRET', '_TOKEN'))}
+
+
+# heavy initialization (imports, models, connections) is done once per worker process
+secrets = load_secrets()
+
+
+def handle(request, body):
+    # request: method, url, path, query, remote_address, headers, env (variables of request)
+    return ['hello', 'world']
+
+
+def main():
+    # worker protocol: one JSON request per line on stdin, one JSON reply per line on stdout
+    for line in sys.stdin:
+        request = json.loads(line)
+        code = 0
+        try:
+            output = json.dumps(handle(request, base64.b64decode(request['body']))).encode()
+        except Exception as e:
+            print(e, file=sys.stderr)
+            output, code = b'{"error": "internal error"}', 1
+        reply = {'id': request['id'], 'output': base64.b64encode(output).decode(), 'code': code}
+        sys.stdout.write(json.dumps(reply) + '\n')
+        sys.stdout.flush()
+
+
+if __name__ == '__main__':
+    main()
@@ -1,6 +1,20 @@
+import os
 import sys
 import json
 
+
+def load_secrets():
+    # secret variables: by descriptor or file (see secrets in manifest) or from environment (default)
+    if 'SECRETS_FD' in os.environ:
+        with os.fdopen(int(os.environ['SECRETS_FD'])) as f:
+            return json.load(f)
+    if 'SECRETS_FILE' in os.environ:
+        with open(os.environ['SECRETS_FILE']) as f:
+            return json.load(f)
+    return {k: v for k, v in os.environ.items() if k.upper().endswith(('_SECRET', '_TOKEN'))}
+
+
+secrets = load_secrets()
 request = json.load(sys.stdin)
 response = ['hello', 'world']
 json.dump(response, sys.stdout)
\ No newline at end of file
@@ -0,0 +1,205 @@
+package templates
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+// Metadata file (the same format as single-file template, but without files) of directory and archive templates
+const MetadataFile = "template.json"
+
+// Files provider of template. Files are resolved only when a lambda is created from the template, so listing of
+// templates don't keep content of files in memory.
+type Files interface {
+	// write all files to the destination directory
+	Materialize(ctx context.Context, dest string) error
+}
+
+// FSFiles provides all files from file system (embedded or directory). Metadata file in the root is skipped.
+func FSFiles(fsys fs.FS) Files {
+	return &fsFiles{fsys: fsys}
+}
+
+type fsFiles struct {
+	fsys fs.FS
+}
+
+func (ff *fsFiles) Materialize(ctx context.Context, dest string) error {
+	return fs.WalkDir(ff.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || name == MetadataFile {
+			return nil
+		}
+		f, err := ff.fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeFile(dest, name, f)
+	})
+}
+
+// ArchiveFiles provides files from tar.gz archive. Metadata file in the root is skipped.
+func ArchiveFiles(filename string) Files {
+	return &archiveFiles{filename: filename}
+}
+
+type archiveFiles struct {
+	filename string
+}
+
+func (af *archiveFiles) Materialize(ctx context.Context, dest string) error {
+	return walkArchive(af.filename, func(name string, content io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if name == MetadataFile {
+			return nil
+		}
+		return writeFile(dest, name, content)
+	})
+}
+
+// GitFiles provides files from remote repository (shallow clone of default branch) without .git directory.
+func GitFiles(repo string) Files {
+	return &gitFiles{repo: repo}
+}
+
+type gitFiles struct {
+	repo string
+}
+
+func (gf *gitFiles) Materialize(ctx context.Context, dest string) error {
+	tmpDir, err := ioutil.TempDir("", "template-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	var buffer bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", gf.repo, tmpDir)
+	cmd.Stderr = &buffer
+	internal.SetFlags(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("clone %s: %s: %w", gf.repo, buffer.String(), err)
+	}
+	if err := os.RemoveAll(filepath.Join(tmpDir, ".git")); err != nil {
+		return err
+	}
+	return FSFiles(os.DirFS(tmpDir)).Materialize(ctx, dest)
+}
+
+// template from directory: metadata file and files
+func readDir(dir string) (*Template, error) {
+	t, err := Read(filepath.Join(dir, MetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	t.Provider = FSFiles(os.DirFS(dir))
+	return t, nil
+}
+
+// returned by handler of walkArchive to stop iteration
+var errStopWalk = errors.New("stop walk")
+
+// template from tar.gz archive: only metadata file is decoded, reading stops after it
+func readArchive(filename string) (*Template, error) {
+	var t *Template
+	err := walkArchive(filename, func(name string, content io.Reader) error {
+		if name != MetadataFile {
+			return nil
+		}
+		t = &Template{}
+		if err := json.NewDecoder(content).Decode(t); err != nil {
+			return err
+		}
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("invalid outputs: %w", err)
+		}
+		return errStopWalk
+	})
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%s not found in archive", MetadataFile)
+	}
+	t.Provider = ArchiveFiles(filename)
+	return t, nil
+}
+
+// iterate over regular files in tar.gz archive, names are cleaned and relative
+func walkArchive(filename string, handler func(name string, content io.Reader) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := handler(cleanName(header.Name), reader); err != nil {
+			return err
+		}
+	}
+}
+
+// write file to the destination directory, name is cleaned so it can't point outside of the directory
+func writeFile(dest string, name string, content io.Reader) error {
+	name = cleanName(name)
+	if name == "" {
+		return fmt.Errorf("empty file name")
+	}
+	destFile := filepath.Join(dest, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(destFile), 0755); err != nil {
+		return fmt.Errorf("create file %s directory: %w", name, err)
+	}
+	f, err := os.OpenFile(destFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("create file %s: %w", name, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, content); err != nil {
+		return fmt.Errorf("write file %s content: %w", name, err)
+	}
+	return f.Close()
+}
+
+// relative slash-separated name without parent references. Backslashes are separators regardless of platform, so
+// templates written on Windows are materialized the same way on any platform
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
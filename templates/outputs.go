@@ -0,0 +1,105 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"text/template"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Limits of template outputs
+const (
+	MaxOutputs    = 16   // number of outputs of template
+	MaxOutputSize = 4096 // size in bytes of output template and of resolved value
+)
+
+// Values available in templates of outputs
+type OutputValues struct {
+	UID       string // UID of created lambda
+	Alias     string // alias of lambda (slug or first declared alias), empty if lambda has no aliases
+	PublicURL string // public base URL of server without trailing slash (empty - not configured)
+	URL       string // public URL of lambda: by alias if lambda has it, by UID otherwise
+}
+
+// NewOutputValues by created lambda. Lambda URL is by alias if set, by UID otherwise
+func NewOutputValues(uid, alias, publicURL string) OutputValues {
+	url := publicURL + types.LambdaPath(uid)
+	if alias != "" {
+		url = publicURL + types.LinkPath(alias)
+	}
+	return OutputValues{UID: uid, Alias: alias, PublicURL: publicURL, URL: url}
+}
+
+// Validate outputs of template: names are not empty, templates are not bigger than MaxOutputSize and could be
+// resolved (only fields of OutputValues are referenced)
+func (t *Template) Validate() error {
+	if len(t.Outputs) > MaxOutputs {
+		return fmt.Errorf("too many outputs (%d), maximum is %d", len(t.Outputs), MaxOutputs)
+	}
+	var errs []error
+	for _, name := range outputNames(t.Outputs) {
+		if _, err := resolveOutput(name, t.Outputs[name], OutputValues{}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ResolveOutputs of template by values of created lambda. Nil if template has no outputs
+func (t *Template) ResolveOutputs(values OutputValues) (map[string]string, error) {
+	if len(t.Outputs) == 0 {
+		return nil, nil
+	}
+	var ans = make(map[string]string, len(t.Outputs))
+	for _, name := range outputNames(t.Outputs) {
+		value, err := resolveOutput(name, t.Outputs[name], values)
+		if err != nil {
+			return nil, err
+		}
+		ans[name] = value
+	}
+	return ans, nil
+}
+
+func resolveOutput(name, value string, values OutputValues) (string, error) {
+	if name == "" {
+		return "", errors.New("empty name of output")
+	}
+	if len(value) > MaxOutputSize {
+		return "", fmt.Errorf("output %s is too long, maximum is %d bytes", name, MaxOutputSize)
+	}
+	parsed, err := template.New(name).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid template of output %s: %w", name, err)
+	}
+	out := &limitedBuffer{limit: MaxOutputSize}
+	if err := parsed.Execute(out, values); err != nil {
+		return "", fmt.Errorf("resolve output %s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+func outputNames(outputs map[string]string) []string {
+	var names = make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buffer which fails writes over limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if lb.Len()+len(p) > lb.limit {
+		return 0, fmt.Errorf("resolved output exceeds %d bytes", lb.limit)
+	}
+	return lb.Buffer.Write(p)
+}
@@ -27,20 +27,52 @@ func Read(filename string) (*Template, error) {
 	}
 	defer f.Close()
 	var t = &Template{}
-
-	return t, json.NewDecoder(f).Decode(t)
+	if err := json.NewDecoder(f).Decode(t); err != nil {
+		return t, err
+	}
+	if err := t.Validate(); err != nil {
+		return t, fmt.Errorf("invalid outputs: %w", err)
+	}
+	if t.Repo != "" {
+		t.Provider = GitFiles(t.Repo)
+	}
+	return t, nil
 }
 
 type Template struct {
 	Description string            `json:"description" yaml:"description"`
 	Manifest    types.Manifest    `json:"manifest" yaml:"manifest"`               // manifest to copy
-	PostClone   string            `json:"post_clone,omitempty" yaml:"post_clone"` // action (make target) name that should be invoked after clone
+	PostClone   string            `json:"post_clone,omitempty" yaml:"post_clone"` // action (of manifest or make target) name that should be invoked after clone
 	Check       [][]string        `json:"check,omitempty" yaml:"check,omitempty"` // check availability (one line - one check)
-	Files       map[string]string `json:"files,omitempty"`
+	Files       map[string]string `json:"files,omitempty"`                        // inline files (single-file template)
+	Repo        string            `json:"repo,omitempty" yaml:"repo,omitempty"`   // remote repository with files, cloned when lambda is created
+	Provider    Files             `json:"-" yaml:"-"`                             // lazy files (written after inline files)
+	// templated strings (text/template by OutputValues) resolved after clone and returned with created lambda, ex:
+	// example of call, path of docs, required environment variables
+	Outputs map[string]string `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// Materialize writes inline and lazy files of template to the directory.
+func (t *Template) Materialize(ctx context.Context, dest string) error {
+	for fileName, content := range t.Files {
+		if err := writeFile(dest, fileName, strings.NewReader(content)); err != nil {
+			return err
+		}
+	}
+	if t.Provider == nil {
+		return nil
+	}
+	return t.Provider.Materialize(ctx, dest)
 }
 
 func (t *Template) IsAvailable(ctx context.Context) bool {
 	for _, check := range t.Check {
+		if lookup, err := internal.LookupCheck(check); lookup {
+			if err != nil {
+				return false
+			}
+			continue
+		}
 		cmd := exec.CommandContext(ctx, check[0], check[1:]...)
 		internal.SetFlags(cmd)
 		if cmd.Run() != nil {
@@ -63,8 +95,14 @@ func List(templatesDir string) (map[string]*Template, error) {
 	return merged, nil
 }
 
+// List templates from directory. Supported formats:
+//
+//   - <name>.json - single-file template with inline files
+//   - <name>/template.json - directory template: metadata and files of template in the same directory
+//   - <name>.tar.gz (or .tgz) - archived directory template
+//
+// Only metadata of directory and archived templates is loaded, files are read by Template.Materialize.
 func ListDir(dir string) (map[string]*Template, error) {
-	const suffix = ".json"
 	items, err := ioutil.ReadDir(dir)
 	if os.IsNotExist(err) {
 		return map[string]*Template{}, nil
@@ -73,30 +111,51 @@ func ListDir(dir string) (map[string]*Template, error) {
 	}
 	var ans = make(map[string]*Template)
 	for _, item := range items {
-		name := item.Name()
-		if item.IsDir() || !strings.HasSuffix(name, suffix) {
-			continue
-		}
-		name = name[:len(name)-len(suffix)]
-		t, err := Read(filepath.Join(dir, item.Name()))
+		name, t, err := readItem(dir, item)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("read template %s: %w", item.Name(), err)
+		}
+		if t != nil {
+			ans[name] = t
 		}
-		ans[name] = t
 	}
 	return ans, nil
 }
 
+// read template by directory item, returns nil template for unknown items
+func readItem(dir string, item os.FileInfo) (string, *Template, error) {
+	name := item.Name()
+	location := filepath.Join(dir, name)
+	if item.IsDir() {
+		if _, err := os.Stat(filepath.Join(location, MetadataFile)); err != nil {
+			return "", nil, nil
+		}
+		t, err := readDir(location)
+		return name, t, err
+	}
+	for _, suffix := range []string{".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, suffix) {
+			t, err := readArchive(location)
+			return strings.TrimSuffix(name, suffix), t, err
+		}
+	}
+	if strings.HasSuffix(name, ".json") {
+		t, err := Read(location)
+		return strings.TrimSuffix(name, ".json"), t, err
+	}
+	return "", nil, nil
+}
+
 func ListEmbedded() map[string]*Template {
 	return map[string]*Template{
 		"Python": {
 			Description: "Python basic function",
+			Outputs:     exampleOutputs(),
 			Check: [][]string{
-				{"which", "make"},
 				{"which", "python3"},
 				{"python3", "-m", "venv", "--help"},
 			},
-			Files: mustEmbed("assets/python"),
+			Provider: mustEmbed("assets/python"),
 			Manifest: types.Manifest{
 				Name: "Example Python Function",
 				Description: `### Usage
@@ -106,6 +165,35 @@ func ListEmbedded() map[string]*Template {
 Replace url to the real
 `,
 				Run:            []string{"./venv/bin/python3", "app.py"},
+				Actions:        pythonActions(),
+				TimeLimit:      types.JsonDuration(time.Second),
+				MaximumPayload: 8192,
+				OutputHeaders: map[string]string{
+					"Content-Type": "application/json",
+				},
+			},
+			PostClone: "install",
+		},
+		"Python worker": {
+			Description: "Python function in warm worker process (initialized once, served requests one by one)",
+			Outputs:     exampleOutputs(),
+			Check: [][]string{
+				{"which", "python3"},
+				{"python3", "-m", "venv", "--help"},
+			},
+			Provider: mustEmbed("assets/python-worker"),
+			Manifest: types.Manifest{
+				Name: "Example Python Worker",
+				Description: `### Usage
+
+    curl --data-binary '{"name": "reddec"}' -H 'Content-Type: application/json' "http://example.com/a/xyz"
+
+Replace url to the real
+`,
+				Run:            []string{"./venv/bin/python3", "app.py"},
+				Actions:        pythonActions(),
+				Mode:           types.ModeWorker,
+				Workers:        2,
 				TimeLimit:      types.JsonDuration(time.Second),
 				MaximumPayload: 8192,
 				OutputHeaders: map[string]string{
@@ -116,15 +204,14 @@ Replace url to the real
 		},
 		"Node JS": {
 			Description: "Node JS basic function",
+			Outputs:     exampleOutputs(),
 			Check: [][]string{
-				{"which", "make"},
 				{"which", "node"},
 				{"which", "npm"},
 			},
 			Files: map[string]string{
 				"app.js":       nodeJsScript,
 				"package.json": nodeJsManifest,
-				"Makefile":     nodeJsMake,
 				".cgiignore":   "node_modules",
 			},
 			Manifest: types.Manifest{
@@ -141,11 +228,53 @@ Replace url to the real
 				OutputHeaders: map[string]string{
 					"Content-Type": "application/json",
 				},
+				Actions: map[string][]types.Step{
+					"install": {{Run: []string{"npm", "install", "."}}},
+				},
+			},
+			PostClone: "install",
+		},
+		"Go": {
+			Description: "Go function with SDK",
+			Outputs:     exampleOutputs(),
+			Check: [][]string{
+				{"which", "go"},
+			},
+			Provider: mustEmbed("assets/go"),
+			Manifest: types.Manifest{
+				Name: "Example Go Function",
+				Description: `### Usage
+
+    curl --data-binary '{"name": "reddec"}' -H 'Content-Type: application/json' "http://example.com/a/xyz"
+
+Replace url to the real
+`,
+				Run: []string{"./app"},
+				Actions: map[string][]types.Step{
+					"install": {
+						{Run: []string{"sh", "-c", "test -f go.mod || go mod init lambda"}},
+						{Run: []string{"go", "get", "github.com/reddec/trusted-cgi/sdk"}},
+						{Run: []string{"go", "build", "-o", "app", "."}},
+					},
+				},
+				TimeLimit:      types.JsonDuration(time.Second),
+				MaximumPayload: 8192,
+				ParseHeaders:   true,
+				// conventional variables of SDK (the SDK is not linked to the server)
+				MethodEnv:   "REQUEST_METHOD",
+				DeadlineEnv: "REQUEST_DEADLINE",
+				InputHeaders: map[string]string{
+					"Content-Type": "HTTP_CONTENT_TYPE",
+				},
+				Environment: map[string]string{
+					"SDK_MODE": "headers",
+				},
 			},
 			PostClone: "install",
 		},
 		"PHP": {
 			Description: "PHP basic function",
+			Outputs:     exampleOutputs(),
 			Manifest: types.Manifest{
 				Name: "Example PHP Function",
 				Description: `### Usage
@@ -170,6 +299,7 @@ Replace url to the real
 		},
 		"Nim": {
 			Description: "Nim lang basic function",
+			Outputs:     exampleOutputs(),
 			Manifest: types.Manifest{
 				Name: "Fast python-like function",
 				Description: `### Usage
@@ -184,22 +314,44 @@ Replace url to the real
 				OutputHeaders: map[string]string{
 					"Content-Type": "application/json",
 				},
+				Actions: map[string][]types.Step{
+					"build": {
+						{Run: []string{"nimble", "build"}},
+						{Run: []string{"mkdir", "-p", "bin"}},
+						{Run: []string{"mv", "-f", "lambda", "bin/"}},
+					},
+				},
 			},
 			PostClone: "build",
 			Check: [][]string{
-				{"which", "make"},
 				{"which", "nim"},
 				{"which", "nimble"},
 			},
 			Files: map[string]string{
 				"src/lambda.nim": nimScript,
 				"lambda.nimble":  nimbleManifest,
-				"Makefile":       nimMake,
 			},
 		},
 	}
 }
 
+// install action of embedded Python templates: virtual environment with requirements
+func pythonActions() map[string][]types.Step {
+	return map[string][]types.Step{
+		"install": {
+			{Run: []string{"python3", "-m", "venv", "venv"}},
+			{Run: []string{"./venv/bin/pip", "install", "-r", "requirements.txt"}},
+		},
+	}
+}
+
+// outputs of embedded templates: example of call by public URL of lambda
+func exampleOutputs() map[string]string {
+	return map[string]string{
+		"call": `curl --data-binary '{"name": "reddec"}' -H 'Content-Type: application/json' "{{.URL}}"`,
+	}
+}
+
 const pythonScript = `
 import sys
 import json
@@ -209,13 +361,22 @@ response = ['hello', 'world']
 json.dump(response, sys.stdout)
 `
 
-const pythonMake = `
-install:
-	python3 -m venv venv
-	./venv/bin/pip install -r requirements.txt
-`
-
 const nodeJsScript = `
+const fs = require('fs');
+
+// secret variables: by descriptor or file (see secrets in manifest) or from environment (default)
+function loadSecrets() {
+    if (process.env.SECRETS_FD) {
+        return JSON.parse(fs.readFileSync(Number(process.env.SECRETS_FD), 'utf8'));
+    }
+    if (process.env.SECRETS_FILE) {
+        return JSON.parse(fs.readFileSync(process.env.SECRETS_FILE, 'utf8'));
+    }
+    return Object.fromEntries(Object.entries(process.env).filter(([k]) => /_(SECRET|TOKEN)$/i.test(k)));
+}
+
+const secrets = loadSecrets();
+
 async function run(request) {
      return ["hello", "world"];
 }
@@ -235,11 +396,6 @@ process.stdin.on('end', function () {
 });
 `
 
-const nodeJsMake = `
-install:
-	npm install .
-`
-
 const nodeJsManifest = `{
   "name": "",
   "version": "1.0.0",
@@ -284,37 +440,10 @@ bin           = @["lambda"]
 requires "nim >= 1.2.0"
 `
 
-const nimMake = `
-build:
-	nimble build
-	mkdir -p bin
-	mv -f lambda bin/
-`
-
-func mustEmbed(root string) map[string]string {
+func mustEmbed(root string) Files {
 	sub, err := fs.Sub(assets, root)
 	if err != nil {
 		panic(err)
 	}
-	var out = make(map[string]string)
-	err = fs.WalkDir(sub, ".", func(asset string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		content, err := fs.ReadFile(sub, asset)
-		if err != nil {
-			return fmt.Errorf("read %q: %w", asset, err)
-		}
-		p := strings.Trim(asset, "/.")
-
-		out[p] = string(content)
-		return nil
-	})
-	if err != nil {
-		panic(err)
-	}
-	return out
+	return FSFiles(sub)
 }
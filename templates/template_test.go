@@ -0,0 +1,190 @@
+package templates_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/templates"
+)
+
+const metadata = `{"description":"lazy","manifest":{"run":["cat"]}}`
+
+func writeFile(t testing.TB, filename string, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+}
+
+func writeArchive(t testing.TB, filename string, files map[string]string) {
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	archive := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := archive.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+}
+
+func TestListDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "inline.json"), `{"description":"inline","files":{"app.py":"print(1)"}}`)
+	writeFile(t, filepath.Join(dir, "directory", templates.MetadataFile), metadata)
+	writeFile(t, filepath.Join(dir, "directory", "src", "app.py"), "print(2)")
+	writeFile(t, filepath.Join(dir, "unknown", "app.py"), "print(3)")
+	writeArchive(t, filepath.Join(dir, "archive.tar.gz"), map[string]string{
+		"./" + templates.MetadataFile: metadata,
+		"src/app.py":                  "print(4)",
+		"../escape.py":                "print(5)",
+	})
+
+	list, err := templates.ListDir(dir)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, map[string]string{"app.py": "print(1)"}, list["inline"].Files)
+
+	for name, expected := range map[string]map[string]string{
+		"inline":    {"app.py": "print(1)"},
+		"directory": {"src/app.py": "print(2)"},
+		"archive":   {"src/app.py": "print(4)", "escape.py": "print(5)"},
+	} {
+		tpl := list[name]
+		require.NotNil(t, tpl, name)
+		if name != "inline" {
+			assert.Equal(t, "lazy", tpl.Description)
+			assert.Empty(t, tpl.Files, "files should not be loaded by listing")
+		}
+		dest, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dest)
+		require.NoError(t, tpl.Materialize(context.Background(), dest))
+		var found = make(map[string]string)
+		require.NoError(t, filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			content, err := ioutil.ReadFile(path)
+			rel, _ := filepath.Rel(dest, path)
+			found[filepath.ToSlash(rel)] = string(content)
+			return err
+		}))
+		assert.Equal(t, expected, found, name)
+	}
+}
+
+// memory used by listing of large templates: single-file templates keep all files in memory, directory and archive
+// templates - only metadata
+func TestTemplate_outputs(t *testing.T) {
+	tpl := templates.Template{Outputs: map[string]string{
+		"call": "curl {{.URL}}",
+		"docs": "{{.PublicURL}}/a/{{.UID}}/docs",
+	}}
+	require.NoError(t, tpl.Validate())
+	outputs, err := tpl.ResolveOutputs(templates.NewOutputValues("xyz", "hello", "https://example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"call": "curl https://example.com/l/hello", "docs": "https://example.com/a/xyz/docs"}, outputs)
+	outputs, err = tpl.ResolveOutputs(templates.NewOutputValues("xyz", "", "https://example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "curl https://example.com/a/xyz", outputs["call"], "lambda without alias by UID")
+
+	tpl.Outputs["broken"] = "{{.URL"
+	tpl.Outputs["unknown"] = "{{.Token}}"
+	tpl.Outputs["long"] = strings.Repeat("x", templates.MaxOutputSize+1)
+	err = tpl.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid template of output broken")
+		assert.Contains(t, err.Error(), "resolve output unknown")
+		assert.Contains(t, err.Error(), "output long is too long")
+	}
+	tpl.Outputs = map[string]string{}
+	for i := 0; i <= templates.MaxOutputs; i++ {
+		tpl.Outputs[strconv.Itoa(i)] = "value"
+	}
+	assert.Error(t, tpl.Validate(), "too many outputs")
+
+	for name, embedded := range templates.ListEmbedded() {
+		assert.NoError(t, embedded.Validate(), name)
+	}
+
+	// invalid outputs are rejected at load time
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFile(t, filepath.Join(dir, "broken.json"), `{"manifest":{"run":["cat"]},"outputs":{"call":"{{.URL"}}`)
+	_, err = templates.ListDir(dir)
+	assert.Error(t, err)
+}
+
+func BenchmarkListDir(b *testing.B) {
+	const (
+		count = 8
+		size  = 1024 * 1024
+	)
+	content := strings.Repeat("x", size)
+	layouts := map[string]func(dir string, name string){
+		"single-file": func(dir string, name string) {
+			data, err := json.Marshal(templates.Template{Description: name, Files: map[string]string{"data.txt": content}})
+			require.NoError(b, err)
+			writeFile(b, filepath.Join(dir, name+".json"), string(data))
+		},
+		"directory": func(dir string, name string) {
+			writeFile(b, filepath.Join(dir, name, templates.MetadataFile), metadata)
+			writeFile(b, filepath.Join(dir, name, "data.txt"), content)
+		},
+		"archive": func(dir string, name string) {
+			writeArchive(b, filepath.Join(dir, name+".tar.gz"), map[string]string{templates.MetadataFile: metadata, "data.txt": content})
+		},
+	}
+	for layout, create := range layouts {
+		b.Run(layout, func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "")
+			require.NoError(b, err)
+			defer os.RemoveAll(dir)
+			for i := 0; i < count; i++ {
+				create(dir, "template-"+strconv.Itoa(i))
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				list, err := templates.ListDir(dir)
+				if err != nil || len(list) != count {
+					b.Fatal("unexpected listing", err)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplate_portable(t *testing.T) {
+	tpl := templates.Template{
+		Files: map[string]string{`src\app.py`: "print(1)"},
+		// executables are looked up without which
+		Check: [][]string{{"which", os.Args[0]}},
+	}
+	assert.True(t, tpl.IsAvailable(context.Background()))
+	tpl.Check = append(tpl.Check, []string{"which", "trusted-cgi-missing-tool"})
+	assert.False(t, tpl.IsAvailable(context.Background()))
+
+	dest, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dest)
+	require.NoError(t, tpl.Materialize(context.Background(), dest))
+	assert.FileExists(t, filepath.Join(dest, "src", "app.py"), "backslash is separator")
+}
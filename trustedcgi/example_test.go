@@ -0,0 +1,89 @@
+package trustedcgi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/trustedcgi"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// quota of invocations per client (by X-Client header) in fixed window
+type quota struct {
+	limit  int
+	window time.Duration
+	lock   sync.Mutex
+	start  time.Time
+	used   map[string]int
+}
+
+func (q *quota) before(ctx context.Context, event application.InvokeEvent) *application.Rejection {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if now := time.Now(); now.Sub(q.start) > q.window {
+		q.start = now
+		q.used = make(map[string]int)
+	}
+	client := event.Request.Headers["X-Client"]
+	if q.used[client] >= q.limit {
+		return &application.Rejection{
+			Status:     http.StatusTooManyRequests,
+			Reason:     "quota exceeded for " + client,
+			RetryAfter: q.window - time.Since(q.start),
+		}
+	}
+	q.used[client]++
+	return nil
+}
+
+func ExampleConfig_Hooks() {
+	dir, err := ioutil.TempDir("", "trusted-cgi-*")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	limits := &quota{limit: 2, window: time.Minute}
+	instance, err := trustedcgi.Default().Directory(dir).SSH(false).Hooks(&application.Hooks{
+		OnBeforeInvoke: limits.before,
+		OnAfterInvoke: func(ctx context.Context, record stats.Record) {
+			if record.Rejected {
+				fmt.Println("rejected:", record.Err)
+			}
+		},
+	}).New()
+	if err != nil {
+		panic(err)
+	}
+	defer instance.Stop()
+
+	uid, err := instance.Server().Cases.CreateFromTemplate(instance.Context(), templates.Template{
+		Manifest: types.Manifest{Run: []string{"cat", "-"}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	handler := instance.Handler()
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/a/"+uid, bytes.NewBufferString("hello"))
+		req.Header.Set("X-Client", "alice")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		fmt.Println(rec.Code)
+	}
+	// Output:
+	// 200
+	// 200
+	// rejected: quota exceeded for alice
+	// 429
+}
@@ -3,22 +3,45 @@ package trustedcgi
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/api/services"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/alerts"
+	"github.com/reddec/trusted-cgi/application/artifact"
+	"github.com/reddec/trusted-cgi/application/backup"
+	"github.com/reddec/trusted-cgi/application/capacity"
+	"github.com/reddec/trusted-cgi/application/capture"
 	"github.com/reddec/trusted-cgi/application/cases"
+	"github.com/reddec/trusted-cgi/application/deadletter"
+	"github.com/reddec/trusted-cgi/application/freezer"
+	"github.com/reddec/trusted-cgi/application/gitdeploy"
+	"github.com/reddec/trusted-cgi/application/journal"
 	"github.com/reddec/trusted-cgi/application/platform"
 	"github.com/reddec/trusted-cgi/application/policy"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/reload"
+	"github.com/reddec/trusted-cgi/application/scheduler"
+	"github.com/reddec/trusted-cgi/application/search"
+	"github.com/reddec/trusted-cgi/application/secrets"
+	"github.com/reddec/trusted-cgi/application/versions"
+	"github.com/reddec/trusted-cgi/application/warmup"
+	"github.com/reddec/trusted-cgi/application/webhooks"
+	"github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/indir"
 	"github.com/reddec/trusted-cgi/server"
-	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/disklog"
+	"github.com/reddec/trusted-cgi/stats/impl/jsonlog"
+	"github.com/reddec/trusted-cgi/stats/impl/prometheus"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 const (
@@ -27,13 +50,27 @@ const (
 	defServerFile           = "server.json"
 	defProjectFile          = "project.json"
 	defStatsFile            = ".stats"
+	defStatsDir             = ".stats.d"
+	defSchedulerStateFile   = ".scheduler.json"
+	defChangesFile          = ".changes.jsonl"
+	defChangesMaxSize       = 100 * 1024 * 1024
+	defChangesBackups       = 5
+	defSearchIndexFile      = ".search-index.json"
+	defSecretsFile          = ".secrets.json"
 	defTemplatesDir         = ".templates"
 	defQueuesDir            = ".queues"
+	defAsyncDir             = ".async"
+	defDeadLettersDir       = ".dead-letters"
+	defCapturesDir          = ".captures"
+	defDeadLettersLimit     = 100
+	defDeadLettersRetention = 7 * 24 * time.Hour
 	defSshKey               = ".id_rsa"
 	defGracefulShutdown     = 10 * time.Second // time to wait for HTTP connections shutdown (if ListenAndServe were used)
 	defCfgPassword          = "admin"
 	defCfgStatsDepth        = 8192
-	defCfgDumpInterval      = 30 * time.Second
+	defCfgStatsMaxAge       = 30 * 24 * time.Hour
+	defCfgStatsMaxSize      = 256 * 1024 * 1024
+	defCfgDumpInterval      = 5 * time.Second
 	defCfgSchedulerInterval = 30 * time.Second
 )
 
@@ -42,9 +79,14 @@ func Default() *Config {
 	return &Config{
 		dir:               ".",
 		password:          defCfgPassword,
+		passwordHashing:   services.DefaultPasswordHashing,
+		loginLimits:       services.DefaultLoginLimits,
 		statsDepth:        defCfgStatsDepth,
+		statsMaxAge:       defCfgStatsMaxAge,
+		statsMaxSize:      defCfgStatsMaxSize,
 		dumpInterval:      defCfgDumpInterval,
 		schedulerInterval: defCfgSchedulerInterval,
+		scheduler:         true,
 		ssh:               true,
 	}
 }
@@ -54,10 +96,30 @@ type Config struct {
 	ctx               context.Context
 	password          string
 	statsDepth        uint
+	statsMaxAge       time.Duration
+	statsMaxSize      int64
+	statsMaxRecords   int
 	dumpInterval      time.Duration
 	schedulerInterval time.Duration
 	dir               string
+	scheduler         bool
 	ssh               bool
+	metrics           bool
+	jsonLog           io.Writer
+	async             server.AsyncConfig
+	queueWorkers      int
+	hooks             *application.Hooks
+	profile           *types.SecurityProfile
+	secretsKey        []byte
+	passwordHashing   services.PasswordHashing
+	loginLimits       services.LoginLimits
+	webhooks          webhooks.Options
+	containers        application.Containers
+	frozenKeep        int
+	versionsKeep      int
+	versionsMaxSize   int64
+	warmUp            int
+	warmUpTimeLimit   time.Duration
 }
 
 // Directory for project files.
@@ -78,12 +140,127 @@ func (cfg *Config) Password(password string) *Config {
 	return cfg
 }
 
+// PasswordHashing is argon2id parameters of hash of admin password. By default - services.DefaultPasswordHashing.
+func (cfg *Config) PasswordHashing(hashing services.PasswordHashing) *Config {
+	cfg.passwordHashing = hashing
+	return cfg
+}
+
+// LoginLimits is protection of login against brute-force (delay and lockout). By default - services.DefaultLoginLimits.
+func (cfg *Config) LoginLimits(limits services.LoginLimits) *Config {
+	cfg.loginLimits = limits
+	return cfg
+}
+
+// Webhooks of lifecycle events of all lambdas and options of deliveries (also for webhooks of lambdas). By default -
+// only webhooks of lambdas with default options.
+func (cfg *Config) Webhooks(options webhooks.Options) *Config {
+	cfg.webhooks = options
+	return cfg
+}
+
 // SSH support enable or disable. By default - enabled.
 func (cfg *Config) SSH(enable bool) *Config {
 	cfg.ssh = enable
 	return cfg
 }
 
+// Scheduler of cron entries of manifests. By default - enabled.
+func (cfg *Config) Scheduler(enable bool) *Config {
+	cfg.scheduler = enable
+	return cfg
+}
+
+// Metrics (exact invocation counters in Prometheus format) on /metrics endpoint. By default - disabled.
+func (cfg *Config) Metrics(enable bool) *Config {
+	cfg.metrics = enable
+	return cfg
+}
+
+// Structured log of invocations and administrative changes in JSON lines (see jsonlog). By default - disabled.
+func (cfg *Config) JSONLog(writer io.Writer) *Config {
+	cfg.jsonLog = writer
+	return cfg
+}
+
+// Asynchronous invocations with polling of results (see server.AsyncConfig), pending invocations are kept in
+// .async of project directory if directory is not set. By default - disabled.
+func (cfg *Config) Async(config server.AsyncConfig) *Config {
+	cfg.async = config
+	return cfg
+}
+
+// Maximum number of concurrent executions of queued requests from all queues (zero - unlimited). By default -
+// unlimited.
+func (cfg *Config) QueueWorkers(workers int) *Config {
+	cfg.queueWorkers = workers
+	return cfg
+}
+
+// Hooks of lifecycle (invocations, deployments, manifest changes, errors). By default - not set.
+func (cfg *Config) Hooks(hooks *application.Hooks) *Config {
+	cfg.hooks = hooks
+	return cfg
+}
+
+// Security profile of lambdas: defaults and mandatory rules (see types.StrictProfile). By default - not restricted.
+func (cfg *Config) SecurityProfile(profile types.SecurityProfile) *Config {
+	cfg.profile = &profile
+	return cfg
+}
+
+// Key of encrypted store of secrets (.secrets.json in project directory) referenced by environment of lambdas as
+// @secret:<name>. By default - secrets are disabled.
+func (cfg *Config) Secrets(key []byte) *Config {
+	cfg.secretsKey = key
+	return cfg
+}
+
+// Containers engine of lambdas with container in manifest (see containers.New). By default - lambdas with container
+// could not be invoked.
+func (cfg *Config) Containers(engine application.Containers) *Config {
+	cfg.containers = engine
+	return cfg
+}
+
+// Retention of stats records in .stats.d of project directory: records older than maximum age and the oldest records
+// over maximum size are removed (zero - not limited). By default - 30 days and 256MiB. Retention changed at runtime
+// (ProjectAPI.SetStatsRetention) replaces it.
+func (cfg *Config) StatsRetention(maxAge time.Duration, maxSize int64) *Config {
+	cfg.statsMaxAge = maxAge
+	cfg.statsMaxSize = maxSize
+	return cfg
+}
+
+// Maximum number of stats records: the oldest records over it are removed (zero - not limited). By default - not
+// limited.
+func (cfg *Config) StatsMaxRecords(maxRecords int) *Config {
+	cfg.statsMaxRecords = maxRecords
+	return cfg
+}
+
+// Number of frozen artifacts kept for each lambda (negative - all). By default - freezer.DefaultKeep.
+func (cfg *Config) FrozenKeep(keep int) *Config {
+	cfg.frozenKeep = keep
+	return cfg
+}
+
+// Versions of lambdas taken by uploads and updates of manifests: number of versions kept for each lambda (zero -
+// disabled, negative - all) and maximum total size of stored files in bytes (zero - unlimited). By default - disabled.
+func (cfg *Config) Versions(keep int, maxSize int64) *Config {
+	cfg.versionsKeep = keep
+	cfg.versionsMaxSize = maxSize
+	return cfg
+}
+
+// Warm-up of lambdas on start in background (reported on /health/warmup): number of lambdas warmed up at the same time
+// (zero - disabled) and time limit of action which rebuilds environment (zero - default). By default - disabled.
+func (cfg *Config) WarmUp(parallel int, timeLimit time.Duration) *Config {
+	cfg.warmUp = parallel
+	cfg.warmUpTimeLimit = timeLimit
+	return cfg
+}
+
 // New instance of trusted-cgi using defaults storages and implementations.
 // Also initializes SSH key (if enabled). Starts supporting go-routines that will be stopped when context will be canceled.
 // The Done() channel can be used to determinate sub-routine termination.
@@ -103,6 +280,21 @@ func (cfg *Config) New() (*Instance, error) {
 	if err != nil {
 		return nil, fmt.Errorf("initialize base platform: %w", err)
 	}
+	if cfg.profile != nil {
+		if err := basePlatform.SetProfile(*cfg.profile); err != nil {
+			return nil, fmt.Errorf("apply security profile: %w", err)
+		}
+	}
+	if len(cfg.secretsKey) > 0 {
+		store, err := secrets.Open(filepath.Join(cfg.dir, defSecretsFile), cfg.secretsKey)
+		if err != nil {
+			return nil, fmt.Errorf("initialize secrets: %w", err)
+		}
+		basePlatform.SetSecrets(store)
+	}
+	if cfg.containers != nil {
+		basePlatform.SetContainers(cfg.containers)
+	}
 
 	queueFactory := func(name string) (queue.Queue, error) {
 		return indir.New(filepath.Join(cfg.dir, defQueuesDir, name))
@@ -110,17 +302,26 @@ func (cfg *Config) New() (*Instance, error) {
 
 	ctx, cancel := context.WithCancel(globalContext)
 
-	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(cfg.dir, defQueuesFile)), basePlatform, queueFactory)
+	deadLetters := deadletter.New(filepath.Join(cfg.dir, defDeadLettersDir), defDeadLettersLimit, defDeadLettersRetention)
+	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(cfg.dir, defQueuesFile)), basePlatform, queueFactory, queuemanager.Options{DeadLetters: deadLetters, Workers: cfg.queueWorkers})
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("initialize queues: %w", err)
 	}
+	basePlatform.SetQueues(queueManager)
 
 	useCases, err := cases.New(basePlatform, queueManager, policies, cfg.dir, filepath.Join(cfg.dir, defTemplatesDir))
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("initialize use-cases: %w", err)
 	}
+	schedulerState, err := scheduler.LoadState(filepath.Join(cfg.dir, defSchedulerStateFile))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	useCases.SetSchedulerState(schedulerState)
+	useCases.SetCaptures(capture.New(filepath.Join(cfg.dir, defCapturesDir)))
 
 	if cfg.ssh {
 		err = useCases.SetOrCreatePrivateSSHKeyFile(filepath.Join(cfg.dir, defSshKey))
@@ -130,34 +331,167 @@ func (cfg *Config) New() (*Instance, error) {
 		}
 	}
 
-	tracker, err := memlog.NewDumped(filepath.Join(cfg.dir, defStatsFile), cfg.statsDepth)
+	tracker, err := disklog.Open(filepath.Join(cfg.dir, defStatsDir), stats.Retention{
+		MaxAge:     types.JsonDuration(cfg.statsMaxAge),
+		MaxRecords: cfg.statsMaxRecords,
+		MaxSize:    cfg.statsMaxSize,
+	})
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("initalize stats: %w", err)
 	}
+	tracker.Pending = int(cfg.statsDepth)
+	if _, err := tracker.Import(filepath.Join(cfg.dir, defStatsFile)); err != nil {
+		cancel()
+		_ = tracker.Close()
+		return nil, fmt.Errorf("import legacy stats: %w", err)
+	}
+
+	changes, err := journal.New(filepath.Join(cfg.dir, defChangesFile))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("initialize journal of changes: %w", err)
+	}
+	changes.MaxSize, changes.Backups = defChangesMaxSize, defChangesBackups
+	for i := range cfg.webhooks.Webhooks {
+		if err := cfg.webhooks.Webhooks[i].Validate(); err != nil {
+			cancel()
+			return nil, fmt.Errorf("webhook %s: %w", cfg.webhooks.Webhooks[i].URL, err)
+		}
+	}
+	notifier := webhooks.New(basePlatform, cfg.webhooks)
+	notifier.Start(ctx)
+	basePlatform.SetMissedRuns(notifier)
+	changes.Observer = notifier.Change
+	var structured *jsonlog.Logger
+	if cfg.jsonLog != nil {
+		structured = jsonlog.New(cfg.jsonLog)
+		changes.Observer = func(change application.Change) {
+			structured.Change(change)
+			notifier.Change(change)
+		}
+	}
 
-	projectApi := services.NewProjectSrv(useCases, tracker)
-	lambdaApi := services.NewLambdaSrv(useCases, tracker)
-	queuesApi := services.NewQueuesSrv(queueManager)
-	policiesApi := services.NewPoliciesSrv(policies)
-	userApi, err := services.CreateUserSrv(filepath.Join(cfg.dir, defServerFile), cfg.password)
+	alertRules := alerts.New(ctx, basePlatform)
+	capacityReporter := capacity.New(basePlatform, queueManager, tracker, cfg.dir,
+		capacity.Store{Name: "stats", Path: filepath.Join(cfg.dir, defStatsDir)},
+		capacity.Store{Name: "changes", Path: filepath.Join(cfg.dir, defChangesFile)},
+		capacity.Store{Name: "templates", Path: filepath.Join(cfg.dir, defTemplatesDir)},
+		capacity.Store{Name: "queues", Path: filepath.Join(cfg.dir, defQueuesDir)},
+		capacity.Store{Name: "dead-letters", Path: filepath.Join(cfg.dir, defDeadLettersDir)},
+		capacity.Store{Name: "captures", Path: filepath.Join(cfg.dir, defCapturesDir)},
+		capacity.Store{Name: "logs", Path: filepath.Join(cfg.dir, internal.LogsDir)})
+	searchIndex := search.New(basePlatform, filepath.Join(cfg.dir, defSearchIndexFile), search.DefaultBudget)
+	projectApi := services.NewProjectSrv(useCases, tracker, alertRules, cfg.info(), capacityReporter, cfg.hooks, nil, changes, searchIndex, "")
+	lambdaApi := services.NewLambdaSrv(useCases, tracker, alertRules, cfg.hooks, changes)
+	var asyncQueue *server.AsyncQueue
+	var asyncInvocations application.AsyncQueue // nil interface if disabled
+	if cfg.async.Depth > 0 {
+		asyncConfig := cfg.async
+		if asyncConfig.Dir == "" {
+			asyncConfig.Dir = filepath.Join(cfg.dir, defAsyncDir)
+		}
+		asyncQueue, err = server.NewAsyncQueue(asyncConfig)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("initialize asynchronous invocations: %w", err)
+		}
+		asyncInvocations = asyncQueue
+	}
+	queuesApi := services.NewQueuesSrv(queueManager, asyncInvocations)
+	policiesApi := services.NewPoliciesSrv(policies, changes)
+	userApi, err := services.CreateUserSrv(filepath.Join(cfg.dir, defServerFile), cfg.password, cfg.passwordHashing, changes)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("initialize admin API (user): %w", err)
 	}
+	if err := userApi.SetLoginLimits(cfg.loginLimits); err != nil {
+		cancel()
+		return nil, fmt.Errorf("login limits: %w", err)
+	}
+	projectApi.SetWebhooks(notifier)
+	backups := backup.New(useCases, policies)
+	backups.Tokens, backups.Version = userApi, "library"
+	projectApi.SetBackups(backups)
+	projectApi.SetArtifacts(artifact.New(basePlatform, cfg.dir))
+	reloader := reload.New(basePlatform, cfg.dir)
+	reloader.Journal = changes
+	reloader.Snapshot()
+	projectApi.SetReloader(reloader)
+	frozen, err := freezer.New(basePlatform, filepath.Join(cfg.dir, internal.FrozenDir))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("initialize frozen artifacts: %w", err)
+	}
+	frozen.Keep = cfg.frozenKeep
+	backups.Freezer = frozen
+	lambdaApi.SetFreezer(frozen)
+	if cfg.versionsKeep != 0 {
+		store, err := versions.New(basePlatform, cfg.dir, filepath.Join(cfg.dir, internal.VersionsDir))
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("initialize versions: %w", err)
+		}
+		store.Keep, store.MaxSize = cfg.versionsKeep, cfg.versionsMaxSize
+		lambdaApi.SetVersions(store)
+	}
+	deployer, err := gitdeploy.New(basePlatform, cfg.dir)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("initialize deploys from git: %w", err)
+	}
+	deployer.Journal, deployer.Hooks, deployer.Observer = changes, cfg.hooks, notifier
+	if cfg.ssh {
+		// git runs in clone of repository
+		if deployer.SSHKey, err = filepath.Abs(filepath.Join(cfg.dir, defSshKey)); err != nil {
+			cancel()
+			return nil, fmt.Errorf("initialize deploys from git: %w", err)
+		}
+	}
+	deployer.Start(ctx)
+	lambdaApi.SetGitDeploys(deployer)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		dumpTracker(ctx, cfg.dumpInterval, tracker)
+		if err := tracker.Close(); err != nil {
+			log.Println("[ERROR] failed to write statistics:", err)
+		}
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		runScheduler(ctx, cfg.schedulerInterval, useCases)
+		deadLetters.Run(ctx)
 	}()
 
+	var warm *warmup.WarmUp
+	if cfg.warmUp > 0 {
+		warm = warmup.New(basePlatform)
+		warm.Parallel, warm.TimeLimit = cfg.warmUp, cfg.warmUpTimeLimit
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			warm.Run(ctx)
+		}()
+	}
+	useCases.StartLambdas(ctx)
+	// scheduler is stopped by shutdown before executions
+	intake, stopIntake := context.WithCancel(ctx)
+	scheduled := make(chan struct{})
+	if cfg.scheduler {
+		useCases.CatchUpScheduledActions(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(scheduled)
+			runScheduler(intake, ctx, cfg.schedulerInterval, useCases)
+		}()
+	} else {
+		close(scheduled)
+	}
+
 	done := make(chan struct{})
 	go func() {
 		wg.Wait()
@@ -169,29 +503,96 @@ func (cfg *Config) New() (*Instance, error) {
 		Platform:     basePlatform,
 		Cases:        useCases,
 		Queues:       queueManager,
+		Alerts:       alertRules,
 		Tracker:      tracker,
+		Stats:        tracker,
+		Hooks:        cfg.hooks,
+		Async:        asyncQueue,
+		DataDir:      cfg.dir,
 		TokenHandler: userApi,
 		ProjectAPI:   projectApi,
 		LambdaAPI:    lambdaApi,
 		UserAPI:      userApi,
 		QueuesAPI:    queuesApi,
 		PoliciesAPI:  policiesApi,
+		Webhooks:     notifier,
+		GitDeploys:   deployer,
+	}
+	if structured != nil {
+		srv.InvocationLog = structured
+	}
+	if warm != nil {
+		srv.WarmUp = warm
 	}
+	if cfg.metrics {
+		metrics := prometheus.New()
+		metrics.Scheduler = useCases
+		metrics.Streams = srv
+		srv.Metrics = metrics
+	}
+	queueManager.SetRecorder(srv)
+	basePlatform.SetRecorder(srv)
 	return &Instance{
-		Location: cfg.dir,
-		server:   srv,
-		ctx:      ctx,
-		done:     done,
-		cancel:   cancel,
+		Location:   cfg.dir,
+		server:     srv,
+		ctx:        ctx,
+		done:       done,
+		cancel:     cancel,
+		stopIntake: stopIntake,
+		scheduled:  scheduled,
 	}, nil
 }
 
+// server information based on configuration: options changed from defaults are marked as "option"
+func (cfg *Config) info() *api.ServerInfo {
+	def := Default()
+	value := func(name string, current, defValue interface{}) api.ConfigValue {
+		source := "default"
+		if current != defValue {
+			source = "option"
+		}
+		return api.ConfigValue{Name: name, Value: fmt.Sprint(current), Source: source}
+	}
+	password := value("password", cfg.password, def.password)
+	password.Value = "<redacted>"
+	var capabilities = []string{"bundles", "sampling", "runtime-defaults", "queues:directory", "dead-letters"}
+	if cfg.ssh {
+		capabilities = append(capabilities, "ssh")
+	}
+	if cfg.metrics {
+		capabilities = append(capabilities, "metrics")
+	}
+	if len(cfg.secretsKey) > 0 {
+		capabilities = append(capabilities, "secrets")
+	}
+	return &api.ServerInfo{
+		Version:      "library",
+		Capabilities: capabilities,
+		Config: []api.ConfigValue{
+			value("dir", cfg.dir, def.dir),
+			password,
+			value("stats-depth", cfg.statsDepth, def.statsDepth),
+			value("stats-max-age", cfg.statsMaxAge, def.statsMaxAge),
+			value("stats-max-size", cfg.statsMaxSize, def.statsMaxSize),
+			value("stats-max-records", cfg.statsMaxRecords, def.statsMaxRecords),
+			value("dump-interval", cfg.dumpInterval, def.dumpInterval),
+			value("scheduler-interval", cfg.schedulerInterval, def.schedulerInterval),
+			value("scheduler", cfg.scheduler, def.scheduler),
+			value("ssh", cfg.ssh, def.ssh),
+			value("metrics", cfg.metrics, def.metrics),
+			value("queue-workers", cfg.queueWorkers, def.queueWorkers),
+		},
+	}
+}
+
 type Instance struct {
-	Location string         // location as-is it used during initialization
-	server   *server.Server // initialize server with all dependencies
-	ctx      context.Context
-	cancel   func()
-	done     chan struct{}
+	Location   string         // location as-is it used during initialization
+	server     *server.Server // initialize server with all dependencies
+	ctx        context.Context
+	cancel     func()
+	done       chan struct{}
+	stopIntake func()
+	scheduled  chan struct{} // closed when scheduler is stopped
 }
 
 // Cancel underlying context and waits for finish.
@@ -200,6 +601,22 @@ func (instance *Instance) Stop() {
 	<-instance.done
 }
 
+// Shutdown gracefully: new requests are rejected by 503, queued requests and schedules are not started, in-flight
+// executions have timeout to finish, then instance is stopped (running lambdas are terminated). Returns number of
+// in-flight invocations drained and killed.
+func (instance *Instance) Shutdown(timeout time.Duration) (drained, killed int) {
+	ctx, cancel := context.WithTimeout(instance.ctx, timeout)
+	defer cancel()
+	instance.stopIntake()
+	inFlight, running := instance.server.Drain(ctx)
+	select {
+	case <-instance.scheduled:
+	case <-ctx.Done():
+	}
+	instance.Stop()
+	return inFlight - running, running
+}
+
 // Returns channel that will be closed once all sub-routine (tracker dump and scheduler) finished.
 func (instance *Instance) Done() <-chan struct{} {
 	return instance.done
@@ -259,7 +676,6 @@ func dumpTracker(ctx context.Context, each time.Duration, tracker interface {
 }) {
 	t := time.NewTicker(each)
 	defer t.Stop()
-	defer tracker.Dump()
 	for {
 		select {
 		case <-t.C:
@@ -273,7 +689,8 @@ func dumpTracker(ctx context.Context, each time.Duration, tracker interface {
 	}
 }
 
-func runScheduler(ctx context.Context, each time.Duration, runner application.Cases) {
+// scheduler is stopped by context, scheduled actions are executed by exec context
+func runScheduler(ctx, exec context.Context, each time.Duration, runner application.Cases) {
 	t := time.NewTicker(each)
 	defer t.Stop()
 	for {
@@ -282,6 +699,6 @@ func runScheduler(ctx context.Context, each time.Duration, runner application.Ca
 		case <-ctx.Done():
 			return
 		}
-		runner.RunScheduledActions(ctx)
+		runner.RunScheduledActions(exec)
 	}
 }
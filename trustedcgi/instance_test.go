@@ -1,16 +1,28 @@
 package trustedcgi_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/reddec/jsonrpc2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	apiTypes "github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/api/client"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/templates"
 	"github.com/reddec/trusted-cgi/trustedcgi"
 	"github.com/reddec/trusted-cgi/types"
@@ -57,3 +69,794 @@ func TestDefault_run(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, rec.Code)
 	})
 }
+
+func TestDefault_invalidManifest(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+
+	_, err = inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{""}}})
+	var invalid *types.ValidationError
+	assert.True(t, errors.As(err, &invalid))
+
+	uid, err := inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	api := httptest.NewServer(inst.Handler())
+	defer api.Close()
+	token, err := (&client.UserAPIClient{BaseURL: api.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+
+	_, err = (&client.LambdaAPIClient{BaseURL: api.URL + "/u/"}).Update(ctx, token, uid, types.Manifest{
+		Run:            []string{"cat", "-"},
+		MaximumPayload: -1,
+		OutputHeaders:  map[string]string{"Bad Header": "x"},
+	}, false)
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 422, rpcErr.Code)
+	data, err := json.Marshal(rpcErr.Data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"field": "maximum_payload", "message": "maximum payload should not be negative"},
+		{"field": "output_headers.Bad Header", "message": "invalid output header name \"Bad Header\""}
+	]`, string(data))
+}
+
+func TestDefault_declaredAliases(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+
+	manifest := types.Manifest{Run: []string{"cat", "-"}, Aliases: []string{"hook"}}
+	old, err := inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+	require.NoError(t, err)
+	_, err = inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: manifest})
+	assert.Error(t, err, "declared alias is bound to another lambda")
+	redeployed, err := inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+
+	api := httptest.NewServer(inst.Handler())
+	defer api.Close()
+	token, err := (&client.UserAPIClient{BaseURL: api.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	lambdas := &client.LambdaAPIClient{BaseURL: api.URL + "/u/"}
+
+	_, err = lambdas.Update(ctx, token, redeployed, manifest, false)
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 409, rpcErr.Code)
+	assert.Contains(t, rpcErr.Message, "alias hook is bound to lambda "+old)
+
+	def, err := lambdas.Update(ctx, token, redeployed, manifest, true)
+	require.NoError(t, err)
+	assert.True(t, def.Aliases.Has("hook"))
+	req := httptest.NewRequest(http.MethodPost, "/l/hook", bytes.NewBufferString("hello"))
+	rec := httptest.NewRecorder()
+	inst.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// alias removed from manifest is released
+	def, err = lambdas.Update(ctx, token, redeployed, types.Manifest{Run: []string{"cat", "-"}}, false)
+	require.NoError(t, err)
+	assert.Empty(t, def.Aliases)
+	_, err = inst.Server().Platform.FindByLink("hook")
+	assert.Error(t, err)
+}
+
+func TestDefault_templateOutputs(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(inst.Location, ".templates"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(inst.Location, ".templates", "echo.json"), []byte(`{
+		"manifest": {"run": ["cat", "-"], "aliases": ["echo"]},
+		"outputs": {"call": "curl -d hello {{.URL}}", "uid": "{{.UID}}"}
+	}`), 0644))
+
+	api := httptest.NewServer(inst.Handler())
+	defer api.Close()
+	token, err := (&client.UserAPIClient{BaseURL: api.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	project := &client.ProjectAPIClient{BaseURL: api.URL + "/u/"}
+
+	def, err := project.CreateWithOptions(ctx, token, apiTypes.CreateOptions{Template: "echo", PublicURL: "https://example.com/"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"call": "curl -d hello https://example.com/l/echo", "uid": def.UID}, def.Outputs)
+
+	listed, err := project.List(ctx, token)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Empty(t, listed[0].Outputs, "outputs only in response of creation")
+
+	def, err = project.Create(ctx, token)
+	require.NoError(t, err)
+	assert.Empty(t, def.Outputs)
+}
+
+func TestDefault_transfer(t *testing.T) {
+	source, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(source)
+	destination, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(destination)
+	ctx := source.Context()
+	sourceAPI := httptest.NewServer(source.Handler())
+	defer sourceAPI.Close()
+	destinationAPI := httptest.NewServer(destination.Handler())
+	defer destinationAPI.Close()
+	login := func(url string) *apiTypes.Token {
+		token, err := (&client.UserAPIClient{BaseURL: url + "/u/"}).Login(ctx, "admin", "admin")
+		require.NoError(t, err)
+		return token
+	}
+	sourceToken, destinationToken := login(sourceAPI.URL), login(destinationAPI.URL)
+	lambdas := &client.LambdaAPIClient{BaseURL: sourceAPI.URL + "/u/"}
+	project := &client.ProjectAPIClient{BaseURL: destinationAPI.URL + "/u/"}
+
+	uid, err := source.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Name:        "shop",
+		Run:         []string{"cat", "data.txt"},
+		Environment: map[string]string{"API_KEY": "secret"},
+		Cron:        []types.Schedule{{Cron: "@daily", Action: "sync"}},
+		Aliases:     []string{"shop"},
+	}})
+	require.NoError(t, err)
+	def, err := source.Server().Platform.FindByUID(uid)
+	require.NoError(t, err)
+	require.NoError(t, def.Lambda.WriteFile("data.txt", bytes.NewBufferString("goods")))
+	_, err = source.Server().Platform.Link(uid, "taken")
+	require.NoError(t, err)
+	other, err := destination.Server().Cases.Create(ctx)
+	require.NoError(t, err)
+	_, err = destination.Server().Platform.Link(other, "taken")
+	require.NoError(t, err)
+
+	grant, err := lambdas.GrantExport(ctx, sourceToken, uid, false)
+	require.NoError(t, err)
+	_, err = (&client.ProjectAPIClient{BaseURL: sourceAPI.URL + "/u/"}).List(ctx, &apiTypes.Token{Data: grant.Token})
+	assert.Error(t, err, "grant is not login token")
+
+	damaged := *grant
+	damaged.Hash = apiTypes.ArchiveHash([]byte("other"))
+	_, err = project.Transfer(ctx, destinationToken, apiTypes.TransferRequest{ID: "damaged", Source: sourceAPI.URL, Grant: damaged})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "differs from hash of grant")
+	_, err = destination.Server().Platform.FindByUID(uid)
+	assert.Error(t, err, "nothing is created on failure")
+	_, err = os.Stat(filepath.Join(destination.Location, uid))
+	assert.True(t, os.IsNotExist(err))
+	progress, err := project.TransferProgress(ctx, destinationToken, "damaged")
+	require.NoError(t, err)
+	assert.Equal(t, apiTypes.TransferFailed, progress.Stage)
+
+	report, err := project.Transfer(ctx, destinationToken, apiTypes.TransferRequest{ID: "ok", Source: sourceAPI.URL, Grant: *grant})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"taken"}, report.Skipped)
+	assert.Equal(t, uid, report.Definition.UID)
+	transferred := report.Definition.Manifest
+	assert.Equal(t, "shop", transferred.Name)
+	assert.Equal(t, map[string]string{"API_KEY": ""}, transferred.Environment, "secrets are not granted")
+	assert.Len(t, transferred.Cron, 1)
+	progress, err = project.TransferProgress(ctx, destinationToken, "ok")
+	require.NoError(t, err)
+	assert.Equal(t, apiTypes.TransferDone, progress.Stage)
+	assert.Positive(t, progress.Size)
+
+	req := httptest.NewRequest(http.MethodPost, "/l/shop", nil)
+	rec := httptest.NewRecorder()
+	destination.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, "goods", rec.Body.String())
+
+	// transferred UID is not overwritten
+	grant, err = lambdas.GrantExport(ctx, sourceToken, uid, true)
+	require.NoError(t, err)
+	_, err = project.Transfer(ctx, destinationToken, apiTypes.TransferRequest{Source: sourceAPI.URL, Grant: *grant})
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 409, rpcErr.Code)
+
+	// both servers record transfer in journal
+	for _, side := range []struct {
+		url   string
+		token *apiTypes.Token
+	}{{sourceAPI.URL, sourceToken}, {destinationAPI.URL, destinationToken}} {
+		changes, err := (&client.ProjectAPIClient{BaseURL: side.url + "/u/"}).Changes(ctx, side.token, time.Time{}, time.Time{}, 0, 0)
+		require.NoError(t, err)
+		var kinds []string
+		for _, group := range changes.Groups {
+			for _, change := range group.Changes {
+				kinds = append(kinds, change.Kind)
+			}
+		}
+		assert.Contains(t, kinds, "transfer", side.url)
+	}
+}
+
+func TestDefault_audit(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	users := &client.UserAPIClient{BaseURL: server.URL + "/u/"}
+	project := &client.ProjectAPIClient{BaseURL: server.URL + "/u/"}
+
+	_, err = users.Login(ctx, "root", "guess")
+	require.Error(t, err)
+	_, err = project.List(ctx, &apiTypes.Token{Data: "forged"})
+	require.Error(t, err)
+	token, err := users.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	created, err := project.Create(ctx, token)
+	require.NoError(t, err)
+
+	page, err := project.Audit(ctx, token, application.ChangeQuery{Kind: application.ChangeAuth}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "root", page.Changes[0].Actor)
+	assert.Equal(t, "login of root failed: invalid password or login", page.Changes[0].Summary)
+	assert.Equal(t, "invalid login token rejected", page.Changes[1].Summary)
+	assert.False(t, page.Query.Until.IsZero(), "end of range is set by server")
+
+	page, err = project.Audit(ctx, token, application.ChangeQuery{Lambda: created.UID}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, application.ChangeCreate, page.Changes[0].Kind)
+	assert.Equal(t, "admin", page.Changes[0].Actor)
+}
+
+func TestDefault_backup(t *testing.T) {
+	create := func(key string) (*trustedcgi.Instance, *httptest.Server) {
+		dir, err := ioutil.TempDir("", "trusted-cgi-*")
+		require.NoError(t, err)
+		inst, err := trustedcgi.Default().Directory(dir).SSH(false).Secrets([]byte(key)).New()
+		require.NoError(t, err)
+		t.Cleanup(func() { destroy(inst) })
+		api := httptest.NewServer(inst.Handler())
+		t.Cleanup(api.Close)
+		return inst, api
+	}
+	source, sourceAPI := create("key")
+	destination, destinationAPI := create("key")
+	ctx := source.Context()
+	login := func(url string) *apiTypes.Token {
+		token, err := (&client.UserAPIClient{BaseURL: url + "/u/"}).Login(ctx, "admin", "admin")
+		require.NoError(t, err)
+		return token
+	}
+	sourceToken, destinationToken := login(sourceAPI.URL), login(destinationAPI.URL)
+	project := &client.ProjectAPIClient{BaseURL: destinationAPI.URL + "/u/"}
+	invoke := func(inst *trustedcgi.Instance, path string) string {
+		rec := httptest.NewRecorder()
+		inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Body.String()
+	}
+
+	// populate source
+	srv := source.Server()
+	_, err := srv.Platform.Secrets().Set("db", "passw0rd")
+	require.NoError(t, err)
+	config := srv.Platform.Config()
+	config.Environment = map[string]string{"REGION": "eu"}
+	require.NoError(t, srv.Platform.SetConfig(config))
+	uid, err := srv.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{
+		Name:        "shop",
+		Run:         []string{"sh", "-c", "cat data.txt; echo -n $DB$REGION"},
+		Environment: map[string]string{"DB": "@secret:db"},
+		Cron:        []types.Schedule{{Cron: "@daily", Action: "sync"}},
+		Aliases:     []string{"shop"},
+	}})
+	require.NoError(t, err)
+	def, err := srv.Platform.FindByUID(uid)
+	require.NoError(t, err)
+	require.NoError(t, def.Lambda.WriteFile("data.txt", bytes.NewBufferString("goods")))
+	_, err = srv.Platform.Link(uid, "store")
+	require.NoError(t, err)
+	disabled, err := srv.Cases.Create(ctx)
+	require.NoError(t, err)
+	_, err = srv.Platform.SetEnabled(disabled, false)
+	require.NoError(t, err)
+	_, err = srv.Policies.Create("internal", application.PolicyDefinition{AllowedNetworks: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+	require.NoError(t, srv.Policies.Apply(disabled, "internal"))
+	require.NoError(t, srv.Queues.Add(application.Queue{Name: "orders", Target: uid, Retry: 2}))
+	scoped, err := (&client.UserAPIClient{BaseURL: sourceAPI.URL + "/u/"}).CreateToken(ctx, sourceToken, "ci", apiTypes.Scope{Operations: []string{apiTypes.OpUpload}}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "goodspassw0rdeu", invoke(source, "/l/shop"))
+
+	archive, err := (&client.ProjectAPIClient{BaseURL: sourceAPI.URL + "/u/"}).Backup(ctx, sourceToken, application.BackupOptions{})
+	require.NoError(t, err)
+
+	// dry run changes nothing
+	report, err := project.Restore(ctx, destinationToken, archive, application.RestoreOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, application.BackupFormat, report.Header.Format)
+	assert.Equal(t, 2, report.Header.Lambdas)
+	assert.True(t, report.Header.Tokens)
+	assert.True(t, report.Header.Secrets)
+	assert.Empty(t, report.Conflicts())
+	assert.Empty(t, destination.Server().Platform.List())
+
+	report, err = project.Restore(ctx, destinationToken, archive, application.RestoreOptions{})
+	require.NoError(t, err)
+	assert.False(t, report.DryRun)
+	var created = make(map[string][]string)
+	for _, item := range report.Items {
+		assert.Equal(t, application.RestoreCreate, item.Action, item)
+		created[item.Kind] = append(created[item.Kind], item.Name)
+	}
+	assert.ElementsMatch(t, []string{uid, disabled}, created[application.RestoreLambda])
+	assert.ElementsMatch(t, []string{"shop", "store"}, created[application.RestoreAlias])
+	assert.Equal(t, []string{"environment.REGION"}, created[application.RestoreSetting])
+	assert.Equal(t, []string{"db"}, created[application.RestoreSecret])
+	assert.Equal(t, []string{"internal"}, created[application.RestorePolicy])
+	assert.Equal(t, []string{"orders"}, created[application.RestoreQueue])
+	assert.Equal(t, []string{scoped.ID}, created[application.RestoreToken])
+
+	// restored server serves the same
+	dst := destination.Server()
+	assert.Equal(t, "goodspassw0rdeu", invoke(destination, "/l/shop"))
+	assert.Equal(t, "goodspassw0rdeu", invoke(destination, "/l/store"))
+	restored, err := dst.Platform.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Schedule{{Cron: "@daily", Action: "sync"}}, restored.Manifest.Cron)
+	assert.True(t, dst.Platform.Config().Disabled[disabled])
+	policy, err := dst.Policies.Get("internal")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8"}, policy.Definition.AllowedNetworks)
+	assert.True(t, policy.Lambdas.Has(disabled))
+	queue, err := dst.Queues.Get("orders")
+	require.NoError(t, err)
+	assert.Equal(t, uid, queue.Target)
+	assert.Equal(t, 2, queue.Retry)
+	_, err = (&client.LambdaAPIClient{BaseURL: destinationAPI.URL + "/u/"}).Files(ctx, &apiTypes.Token{Data: scoped.Token}, uid, "")
+	assert.NoError(t, err, "API token of source works on destination")
+
+	// repeated restore: everything exists
+	report, err = project.Restore(ctx, destinationToken, archive, application.RestoreOptions{})
+	require.NoError(t, err)
+	assert.Len(t, report.Conflicts(), len(report.Items))
+	assert.NotEmpty(t, report.Items)
+
+	_, err = project.Restore(ctx, destinationToken, archive, application.RestoreOptions{Conflict: application.ConflictFail})
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 409, rpcErr.Code)
+
+	report, err = project.Restore(ctx, destinationToken, archive, application.RestoreOptions{Conflict: application.ConflictNewUID, NoTokens: true})
+	require.NoError(t, err)
+	var renamed []string
+	for _, item := range report.Items {
+		assert.NotEqual(t, application.RestoreToken, item.Kind)
+		if item.Kind == application.RestoreLambda {
+			assert.Equal(t, application.RestoreRename, item.Action)
+			assert.NotEqual(t, item.Name, item.Target)
+			renamed = append(renamed, item.Target)
+		}
+	}
+	require.Len(t, renamed, 2)
+	assert.Len(t, dst.Platform.List(), 4)
+
+	// secrets of backup are sealed by key of source
+	other, otherAPI := create("other key")
+	report, err = (&client.ProjectAPIClient{BaseURL: otherAPI.URL + "/u/"}).Restore(ctx, login(otherAPI.URL), archive, application.RestoreOptions{NoTokens: true})
+	require.NoError(t, err)
+	for _, item := range report.Items {
+		if item.Kind == application.RestoreSecret {
+			assert.Equal(t, application.RestoreSkip, item.Action)
+			assert.NotEmpty(t, item.Reason)
+		}
+	}
+	assert.Empty(t, other.Server().Platform.Secrets().List())
+	assert.Len(t, other.Server().Platform.List(), 2)
+}
+
+func TestDefault_bulkUpdate(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	users := &client.UserAPIClient{BaseURL: server.URL + "/u/"}
+	project := &client.ProjectAPIClient{BaseURL: server.URL + "/u/"}
+	lambdas := &client.LambdaAPIClient{BaseURL: server.URL + "/u/"}
+	token, err := users.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+
+	var uids []string
+	for _, labels := range [][]string{{"billing"}, {"billing", "sync"}, nil} {
+		created, err := project.Create(ctx, token)
+		require.NoError(t, err)
+		manifest := created.Manifest
+		manifest.Run, manifest.Labels = []string{"cat"}, labels
+		_, err = lambdas.Update(ctx, token, created.UID, manifest, false)
+		require.NoError(t, err)
+		uids = append(uids, created.UID)
+	}
+
+	report, err := lambdas.BulkUpdate(ctx, token, apiTypes.BulkUpdate{
+		Label:  "billing",
+		Patch:  json.RawMessage(`{"output_headers":{"X-Frame-Options":"DENY"}}`),
+		Set:    []string{"time_limit=30s"},
+		DryRun: true,
+	})
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Results, 2)
+	for _, result := range report.Results {
+		assert.False(t, result.Applied)
+		assert.Empty(t, result.Error)
+		assert.Contains(t, result.Changes, apiTypes.FieldChange{Field: "output_headers", To: `{"X-Frame-Options":"DENY"}`})
+	}
+	info, err := lambdas.Info(ctx, token, uids[0])
+	require.NoError(t, err)
+	assert.Empty(t, info.Manifest.OutputHeaders, "dry run does not change manifest")
+
+	unknown := uuid.New().String()
+	report, err = lambdas.BulkUpdate(ctx, token, apiTypes.BulkUpdate{UIDs: []string{uids[0], unknown, uids[2]}, Set: []string{"time_limit=30s"}})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 3)
+	assert.Equal(t, 1, report.Failed, "failure does not abort batch")
+	for _, result := range report.Results {
+		if result.UID == unknown {
+			assert.NotEmpty(t, result.Error)
+			continue
+		}
+		assert.True(t, result.Applied)
+		info, err := lambdas.Info(ctx, token, result.UID)
+		require.NoError(t, err)
+		assert.Equal(t, types.JsonDuration(30*time.Second), info.Manifest.TimeLimit)
+		page, err := project.Audit(ctx, token, application.ChangeQuery{Lambda: result.UID, Kind: application.ChangeManifest}, 0, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, page.Changes)
+		assert.Equal(t, "manifest changed: time_limit", page.Changes[len(page.Changes)-1].Summary)
+	}
+
+	report, err = lambdas.BulkUpdate(ctx, token, apiTypes.BulkUpdate{All: true, Set: []string{"time_limit=-1s"}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Failed, "invalid manifest is not applied")
+
+	_, err = lambdas.BulkUpdate(ctx, token, apiTypes.BulkUpdate{All: true, Label: "sync", Set: []string{"time_limit=1s"}})
+	assert.Error(t, err, "ambiguous selection")
+	_, err = lambdas.BulkUpdate(ctx, token, apiTypes.BulkUpdate{All: true, Set: []string{"time_limt=1s"}})
+	assert.Error(t, err, "unknown field")
+}
+
+func TestDefault_globalEnvironment(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	users := &client.UserAPIClient{BaseURL: server.URL + "/u/"}
+	project := &client.ProjectAPIClient{BaseURL: server.URL + "/u/"}
+	lambdas := &client.LambdaAPIClient{BaseURL: server.URL + "/u/"}
+	token, err := users.Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+
+	created, err := project.Create(ctx, token)
+	require.NoError(t, err)
+	_, err = lambdas.SetEnvironment(ctx, token, created.UID, apiTypes.Environment{Environment: map[string]string{"API_URL": "https://staging"}}, nil)
+	require.NoError(t, err)
+
+	env, err := project.UpdateEnvironment(ctx, token, apiTypes.Environment{Environment: map[string]string{"API_URL": "https://api", "HTTP_PROXY": "http://proxy:3128", "OLD": "x"}}, nil)
+	require.NoError(t, err)
+	assert.Len(t, env.Environment, 3)
+	env, err = project.UpdateEnvironment(ctx, token, apiTypes.Environment{}, []string{"OLD"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_URL": "https://api", "HTTP_PROXY": "http://proxy:3128"}, env.Environment)
+	settings, err := project.Config(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, env.Environment, settings.Environment)
+
+	merged, err := lambdas.MergedEnvironment(ctx, token, created.UID)
+	require.NoError(t, err)
+	var byName = make(map[string]application.EnvValue)
+	for _, v := range merged {
+		byName[v.Name] = v
+	}
+	assert.Equal(t, application.EnvValue{Name: "API_URL", Value: "https://staging", Source: application.EnvSourceLambda, Overridden: []string{application.EnvSourceGlobal}}, byName["API_URL"])
+	assert.Equal(t, application.EnvValue{Name: "HTTP_PROXY", Value: "http://proxy:3128", Source: application.EnvSourceGlobal}, byName["HTTP_PROXY"])
+
+	_, err = project.UpdateEnvironment(ctx, token, apiTypes.Environment{Environment: map[string]string{"BAD=NAME": "x"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestDefault_frozen(t *testing.T) {
+	create := func() (*trustedcgi.Instance, *httptest.Server, *apiTypes.Token) {
+		inst, err := createTemp()
+		require.NoError(t, err)
+		t.Cleanup(func() { destroy(inst) })
+		server := httptest.NewServer(inst.Handler())
+		t.Cleanup(server.Close)
+		token, err := (&client.UserAPIClient{BaseURL: server.URL + "/u/"}).Login(inst.Context(), "admin", "admin")
+		require.NoError(t, err)
+		return inst, server, token
+	}
+	source, sourceAPI, sourceToken := create()
+	ctx := source.Context()
+	lambdas := &client.LambdaAPIClient{BaseURL: sourceAPI.URL + "/u/"}
+	invoke := func(inst *trustedcgi.Instance, uid string) string {
+		rec := httptest.NewRecorder()
+		inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a/"+uid, nil))
+		return rec.Body.String()
+	}
+
+	srv := source.Server()
+	uid, err := srv.Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "node_modules/dep.js"}}})
+	require.NoError(t, err)
+	def, err := srv.Platform.FindByUID(uid)
+	require.NoError(t, err)
+	// installed dependencies are not part of content of lambda
+	require.NoError(t, def.Lambda.WriteFile(".cgiignore", bytes.NewBufferString("node_modules\n")))
+	require.NoError(t, os.MkdirAll(filepath.Join(source.Location, uid, "node_modules"), 0755))
+	require.NoError(t, def.Lambda.WriteFile("node_modules/dep.js", bytes.NewBufferString("v1")))
+
+	artifact, err := lambdas.Freeze(ctx, sourceToken, uid)
+	require.NoError(t, err)
+	archive, err := lambdas.DownloadFrozen(ctx, sourceToken, uid, artifact.ID)
+	require.NoError(t, err)
+	assert.Len(t, archive, int(artifact.Size))
+
+	require.NoError(t, def.Lambda.WriteFile("node_modules/dep.js", bytes.NewBufferString("v2")))
+	assert.Equal(t, "v2", invoke(source, uid))
+	thawed, err := lambdas.Thaw(ctx, sourceToken, uid, "")
+	require.NoError(t, err)
+	assert.Equal(t, artifact.ID, thawed.ID)
+	assert.Equal(t, "v1", invoke(source, uid))
+	artifacts, err := lambdas.FrozenArtifacts(ctx, sourceToken, uid)
+	require.NoError(t, err)
+	assert.Equal(t, []application.FrozenArtifact{*artifact}, artifacts)
+
+	_, err = lambdas.Thaw(ctx, sourceToken, uid, "unknown")
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 404, rpcErr.Code)
+
+	// backup with frozen artifacts restores dependencies
+	backup, err := (&client.ProjectAPIClient{BaseURL: sourceAPI.URL + "/u/"}).Backup(ctx, sourceToken, application.BackupOptions{Frozen: true})
+	require.NoError(t, err)
+	destination, destinationAPI, destinationToken := create()
+	report, err := (&client.ProjectAPIClient{BaseURL: destinationAPI.URL + "/u/"}).Restore(ctx, destinationToken, backup, application.RestoreOptions{})
+	require.NoError(t, err)
+	for _, item := range report.Items {
+		if item.Kind == application.RestoreLambda {
+			assert.Equal(t, artifact.ID, item.Frozen, item)
+			assert.Empty(t, item.Reason)
+		}
+	}
+	assert.Equal(t, "v1", invoke(destination, uid))
+	restored, err := (&client.LambdaAPIClient{BaseURL: destinationAPI.URL + "/u/"}).FrozenArtifacts(ctx, destinationToken, uid)
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, artifact.ID, restored[0].ID)
+}
+
+func TestDefault_persistentStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trusted-cgi-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	inst, err := trustedcgi.Default().Directory(dir).SSH(false).New()
+	require.NoError(t, err)
+	uid, err := inst.Server().Cases.CreateFromTemplate(inst.Context(), templates.Template{Manifest: types.Manifest{Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	started := time.Now()
+	for _, body := range []string{"first", "second"} {
+		rec := httptest.NewRecorder()
+		inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a/"+uid, bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	inst.Stop()
+
+	// records are kept by restart
+	inst, err = trustedcgi.Default().Directory(dir).SSH(false).New()
+	require.NoError(t, err)
+	defer inst.Stop()
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	ctx := inst.Context()
+	token, err := (&client.UserAPIClient{BaseURL: server.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	lambdas := &client.LambdaAPIClient{BaseURL: server.URL + "/u/"}
+	records, err := lambdas.Stats(ctx, token, uid, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "second", string(records[0].Output))
+
+	records, err = lambdas.StatsRange(ctx, token, uid, stats.Query{Since: started, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "second", string(records[0].Output))
+	records, err = (&client.ProjectAPIClient{BaseURL: server.URL + "/u/"}).StatsRange(ctx, token, stats.Query{Until: started, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestDefault_aggregateStats(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+	cases := inst.Server().Cases
+	echo, err := cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	slow, err := cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"sleep", "5"}, TimeLimit: types.JsonDuration(100 * time.Millisecond)}})
+	require.NoError(t, err)
+	for _, uid := range []string{echo, echo, slow} {
+		rec := httptest.NewRecorder()
+		inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a/"+uid, bytes.NewBufferString("hello")))
+	}
+
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	token, err := (&client.UserAPIClient{BaseURL: server.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	query := stats.Query{Since: time.Now().Add(-time.Hour)}
+	aggregation, err := (&client.LambdaAPIClient{BaseURL: server.URL + "/u/"}).StatsAggregate(ctx, token, slow, query, types.JsonDuration(time.Hour), "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), aggregation.Total.Calls)
+	assert.Equal(t, stats.Errors{Timeout: 1}, aggregation.Total.Errors, "killed by time limit")
+	assert.GreaterOrEqual(t, aggregation.Total.Latency.Min, 100*time.Millisecond)
+
+	aggregation, err = (&client.ProjectAPIClient{BaseURL: server.URL + "/u/"}).StatsAggregate(ctx, token, query, 0, stats.GroupByAlias)
+	require.NoError(t, err)
+	require.Len(t, aggregation.Groups, 1)
+	assert.Equal(t, stats.AliasDirect, aggregation.Groups[0].Key, "requests by UID")
+	assert.Equal(t, int64(3), aggregation.Groups[0].Total.Calls)
+	assert.InDelta(t, stats.DefaultBuckets, len(aggregation.Buckets), 1, "aligned buckets could be partial")
+	assert.Equal(t, int64(3), aggregation.Total.Calls)
+	assert.Equal(t, int64(1), aggregation.Total.Errors.Sum())
+	var calls int64
+	for _, bucket := range aggregation.Buckets {
+		calls += bucket.Calls
+	}
+	assert.Equal(t, int64(3), calls)
+}
+
+func TestDefault_statsRetention(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+	uid, err := inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a/"+uid, bytes.NewBufferString("hello")))
+	}
+
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	token, err := (&client.UserAPIClient{BaseURL: server.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	project := &client.ProjectAPIClient{BaseURL: server.URL + "/u/"}
+	usage, err := project.StatsUsage(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, 2, usage.Records)
+	assert.Equal(t, types.JsonDuration(30*24*time.Hour), usage.Retention.MaxAge, "retention of configuration")
+	assert.False(t, usage.Newest.IsZero())
+
+	_, err = project.SetStatsRetention(ctx, token, stats.Retention{MaxRecords: -1})
+	assert.Error(t, err)
+	retention := stats.Retention{MaxAge: types.JsonDuration(time.Hour), Lambdas: map[string]stats.LambdaRetention{uid: {MaxRecords: 1}}}
+	usage, err = project.SetStatsRetention(ctx, token, retention)
+	require.NoError(t, err)
+	assert.Equal(t, retention, usage.Retention)
+	usage, err = project.StatsUsage(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, retention, usage.Retention, "changed without restart")
+}
+
+func TestDefault_reload(t *testing.T) {
+	inst, err := createTemp()
+	require.NoError(t, err)
+	defer destroy(inst)
+	ctx := inst.Context()
+	uid, err := inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Name: "before", Run: []string{"cat", "-"}}})
+	require.NoError(t, err)
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	token, err := (&client.UserAPIClient{BaseURL: server.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	project := &client.ProjectAPIClient{BaseURL: server.URL + "/u/"}
+	manifestFile := filepath.Join(inst.Location, uid, "manifest.json")
+
+	// edited on disk
+	require.NoError(t, ioutil.WriteFile(manifestFile, []byte(`{"name":"after","run":["cat","-"]}`), 0644))
+	report, err := project.Reload(ctx, token, "")
+	require.NoError(t, err)
+	var found bool
+	for _, item := range report.Items {
+		if item.Target == uid {
+			found = true
+			assert.True(t, item.Applied)
+			assert.Empty(t, item.Error)
+		}
+	}
+	assert.True(t, found)
+	def, err := inst.Server().Platform.FindByUID(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "after", def.Manifest.Name)
+
+	// malformed manifest keeps serving the last good one
+	require.NoError(t, ioutil.WriteFile(manifestFile, []byte(`{"name":`), 0644))
+	report, err = project.Reload(ctx, token, uid)
+	require.NoError(t, err)
+	require.Len(t, report.Items, 1)
+	assert.NotEmpty(t, report.Items[0].Error)
+	info, err := (&client.LambdaAPIClient{BaseURL: server.URL + "/u/"}).Info(ctx, token, uid)
+	require.NoError(t, err)
+	assert.Equal(t, "after", info.Manifest.Name)
+	assert.Contains(t, info.Warning, "manifest file is not applied")
+	rec := httptest.NewRecorder()
+	inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a/"+uid, bytes.NewBufferString("hello")))
+	assert.Equal(t, "hello", rec.Body.String())
+}
+
+func TestDefault_versions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trusted-cgi-*")
+	require.NoError(t, err)
+	inst, err := trustedcgi.Default().Directory(dir).SSH(false).Versions(5, 0).New()
+	require.NoError(t, err)
+	defer destroy(inst)
+	server := httptest.NewServer(inst.Handler())
+	defer server.Close()
+	ctx := inst.Context()
+	token, err := (&client.UserAPIClient{BaseURL: server.URL + "/u/"}).Login(ctx, "admin", "admin")
+	require.NoError(t, err)
+	lambdas := &client.LambdaAPIClient{BaseURL: server.URL + "/u/"}
+	invoke := func(uid string) string {
+		rec := httptest.NewRecorder()
+		inst.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a/"+uid, nil))
+		return rec.Body.String()
+	}
+	archive := func(content string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "version.txt", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		require.NoError(t, gz.Close())
+		return buf.Bytes()
+	}
+
+	uid, err := inst.Server().Cases.CreateFromTemplate(ctx, templates.Template{Manifest: types.Manifest{Run: []string{"cat", "version.txt"}}})
+	require.NoError(t, err)
+	first, err := lambdas.UploadVersion(ctx, token, uid, archive("v1"), "first release")
+	require.NoError(t, err)
+	assert.Equal(t, "first release", first.Message)
+	assert.Equal(t, "admin", first.Author)
+	_, err = lambdas.Upload(ctx, token, uid, archive("v2"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", invoke(uid))
+
+	list, err := lambdas.Versions(ctx, token, uid)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.True(t, list[0].Current)
+	assert.Equal(t, first.ID, list[1].ID)
+
+	report, err := lambdas.Rollback(ctx, token, uid, application.VersionRollback{ID: first.ID[:12]})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, report.Version.ID)
+	assert.Equal(t, "v1", invoke(uid))
+	list, err = lambdas.Versions(ctx, token, uid)
+	require.NoError(t, err)
+	assert.True(t, list[1].Current, "restored version is current")
+
+	_, err = lambdas.Rollback(ctx, token, uid, application.VersionRollback{ID: "unknown"})
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 404, rpcErr.Code)
+}
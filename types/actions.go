@@ -0,0 +1,54 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Name of action of manifest: the same characters as target of Makefile
+var ActionNameReg = regexp.MustCompile(`^[\w/-]{1,255}$`)
+
+// Step of action of manifest (see Manifest.Actions): command run in working directory of lambda with environment and
+// account of lambda. Steps of action are run one by one, the first failed step fails the action
+type Step struct {
+	Run     []string     `json:"run"`               // command to run (argv, without shell)
+	Timeout JsonDuration `json:"timeout,omitempty"` // time limit of step (zero - only time limit of action)
+}
+
+// Limit of step by time limit of action (zero - not limited): the least of them
+func (st Step) Limit(timeLimit time.Duration) time.Duration {
+	if st.Timeout > 0 && (timeLimit <= 0 || time.Duration(st.Timeout) < timeLimit) {
+		return time.Duration(st.Timeout)
+	}
+	return timeLimit
+}
+
+func (mf *Manifest) validateActions(errs *fieldErrors) {
+	var names = make([]string, 0, len(mf.Actions))
+	for name := range mf.Actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := "actions." + name
+		if !ActionNameReg.MatchString(name) {
+			errs.addf(field, "invalid action name %q: should match %s", name, ActionNameReg.String())
+			continue
+		}
+		steps := mf.Actions[name]
+		if len(steps) == 0 {
+			errs.addf(field, "action %s has no steps", name)
+		}
+		for i, step := range steps {
+			stepField := fmt.Sprintf("%s[%d]", field, i)
+			if len(step.Run) == 0 || step.Run[0] == "" {
+				errs.addf(stepField+".run", "empty command of step %d of action %s", i, name)
+			}
+			if step.Timeout < 0 {
+				errs.addf(stepField+".timeout", "timeout of step %d of action %s should not be negative", i, name)
+			}
+		}
+	}
+}
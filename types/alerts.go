@@ -0,0 +1,88 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reactions of alert rule
+const (
+	AlertNotify  = "notify"  // only notification (default)
+	AlertDegrade = "degrade" // mark lambda as degraded, requests are served as usual
+	AlertDisable = "disable" // reject requests with 503 until re-enabled
+	AlertBreak   = "break"   // circuit breaker: reject requests during cool-off, then let one probe request through
+)
+
+// Defaults of alert rule
+const (
+	DefaultAlertWindow   = 5 * time.Minute
+	DefaultAlertMinCalls = 10
+	DefaultAlertCoolOff  = time.Minute // circuit breaker only
+)
+
+// Alert rule of lambda evaluated by invocations. Rule fires when any of conditions is met, sends notification
+// (by action) and optionally takes an action. Fired rule is reset automatically after cool-off or manually.
+type Alert struct {
+	Name                string       `json:"name"`                           // unique name of rule
+	ErrorRate           float64      `json:"error_rate,omitempty"`           // share of failed invocations in window (0-1], zero - disabled
+	ConsecutiveFailures int          `json:"consecutive_failures,omitempty"` // number of failed invocations in a row, zero - disabled
+	LatencyP95          JsonDuration `json:"latency_p95,omitempty"`          // 95th percentile of duration in window, zero - disabled
+	Window              JsonDuration `json:"window,omitempty"`               // sliding window of error rate and latency (default 5m)
+	MinCalls            int          `json:"min_calls,omitempty"`            // minimal number of invocations in window to evaluate error rate and latency (default 10)
+	Action              string       `json:"action,omitempty"`               // notify (default), degrade, disable or break
+	CoolOff             JsonDuration `json:"cool_off,omitempty"`             // reset after the period, zero - manual reset only (1m for break)
+	Notify              string       `json:"notify,omitempty"`               // action (Makefile target) to invoke on state changes
+}
+
+// Effective reaction
+func (al *Alert) Reaction() string {
+	if al.Action == "" {
+		return AlertNotify
+	}
+	return al.Action
+}
+
+// Effective sliding window
+func (al *Alert) SlidingWindow() time.Duration {
+	if al.Window <= 0 {
+		return DefaultAlertWindow
+	}
+	return time.Duration(al.Window)
+}
+
+// Effective minimal number of invocations in window
+func (al *Alert) MinimalCalls() int {
+	if al.MinCalls <= 0 {
+		return DefaultAlertMinCalls
+	}
+	return al.MinCalls
+}
+
+// Effective cool-off period, zero - manual reset only
+func (al *Alert) CoolOffPeriod() time.Duration {
+	if al.CoolOff <= 0 && al.Reaction() == AlertBreak {
+		return DefaultAlertCoolOff
+	}
+	return time.Duration(al.CoolOff)
+}
+
+func (al *Alert) validate() error {
+	if al.Name == "" {
+		return fmt.Errorf("alert name is not defined")
+	}
+	if al.ErrorRate == 0 && al.ConsecutiveFailures == 0 && al.LatencyP95 == 0 {
+		return fmt.Errorf("alert %s has no conditions", al.Name)
+	}
+	if al.ErrorRate < 0 || al.ErrorRate > 1 {
+		return fmt.Errorf("error rate of alert %s should be between 0 and 1", al.Name)
+	}
+	if al.ConsecutiveFailures < 0 || al.LatencyP95 < 0 || al.Window < 0 || al.MinCalls < 0 || al.CoolOff < 0 {
+		return fmt.Errorf("thresholds of alert %s should not be negative", al.Name)
+	}
+	switch al.Reaction() {
+	case AlertNotify, AlertDegrade, AlertDisable, AlertBreak:
+	default:
+		return fmt.Errorf("unknown action %s of alert %s", al.Action, al.Name)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Access settings of manifest which could be overridden or unset by policy of alias (see AliasPolicy)
+const (
+	AliasSettingNetworks  = "allowed_networks"
+	AliasSettingMethods   = "methods"
+	AliasSettingBasicAuth = "basic_auth"
+	AliasSettingJWT       = "jwt"
+	AliasSettingRateLimit = "rate_limit"
+)
+
+var aliasSettings = []string{AliasSettingNetworks, AliasSettingMethods, AliasSettingBasicAuth, AliasSettingJWT, AliasSettingRateLimit}
+
+// AliasPolicy overrides access settings of lambda for requests arrived by the alias (link): set fields replace the
+// same fields of manifest, settings listed in unset are not applied, other settings are inherited. Requests by UID
+// and by other aliases are not affected
+type AliasPolicy struct {
+	AllowedNetworks []string   `json:"allowed_networks,omitempty"` // networks of clients (CIDR) instead of allowed_networks of lambda
+	Methods         []string   `json:"methods,omitempty"`          // allowed HTTP methods instead of methods of lambda
+	BasicAuth       *BasicAuth `json:"basic_auth,omitempty"`       // basic authentication instead of basic_auth of lambda
+	JWT             *JWTPolicy `json:"jwt,omitempty"`              // verification of JWT instead of jwt of lambda
+	RateLimit       *RateLimit `json:"rate_limit,omitempty"`       // rate limit with own bucket instead of rate_limit of lambda
+	Unset           []string   `json:"unset,omitempty"`            // settings of lambda not applied to requests by alias
+}
+
+// Overridden settings of lambda (set or unset by policy) in order of aliasSettings
+func (ap *AliasPolicy) Overrides() []string {
+	var ans []string
+	for _, setting := range aliasSettings {
+		if ap.overrides(setting) {
+			ans = append(ans, setting)
+		}
+	}
+	return ans
+}
+
+func (ap *AliasPolicy) overrides(setting string) bool {
+	return ap.unsets(setting) || ap.sets(setting)
+}
+
+func (ap *AliasPolicy) sets(setting string) bool {
+	switch setting {
+	case AliasSettingNetworks:
+		return len(ap.AllowedNetworks) > 0
+	case AliasSettingMethods:
+		return len(ap.Methods) > 0
+	case AliasSettingBasicAuth:
+		return ap.BasicAuth != nil
+	case AliasSettingJWT:
+		return ap.JWT != nil
+	case AliasSettingRateLimit:
+		return ap.RateLimit != nil
+	}
+	return false
+}
+
+func (ap *AliasPolicy) unsets(setting string) bool {
+	for _, name := range ap.Unset {
+		if name == setting {
+			return true
+		}
+	}
+	return false
+}
+
+// apply policy to copy of manifest
+func (ap *AliasPolicy) apply(mf Manifest) Manifest {
+	if ap.overrides(AliasSettingNetworks) {
+		mf.AllowedNetworks = ap.AllowedNetworks
+	}
+	if ap.overrides(AliasSettingMethods) {
+		mf.Method, mf.Methods = "", ap.Methods
+	}
+	if ap.overrides(AliasSettingBasicAuth) {
+		mf.BasicAuth = ap.BasicAuth
+	}
+	if ap.overrides(AliasSettingJWT) {
+		mf.JWT = ap.JWT
+	}
+	if ap.overrides(AliasSettingRateLimit) {
+		mf.RateLimit = ap.RateLimit
+	}
+	return mf
+}
+
+// ForAlias is manifest of requests arrived by the alias: access settings are overridden by policy of the alias (if
+// any). Empty alias (request by UID) - manifest as is
+func (mf Manifest) ForAlias(alias string) Manifest {
+	policy := mf.AliasPolicy(alias)
+	if policy == nil {
+		return mf
+	}
+	return policy.apply(mf)
+}
+
+// AliasPolicy of the alias or nil
+func (mf *Manifest) AliasPolicy(alias string) *AliasPolicy {
+	if alias == "" {
+		return nil
+	}
+	return mf.AliasPolicies[alias]
+}
+
+// AliasOverrides is overridden settings by aliases which have policy (only listed aliases are returned)
+func (mf *Manifest) AliasOverrides(aliases JsonStringSet) map[string][]string {
+	var ans map[string][]string
+	for alias, policy := range mf.AliasPolicies {
+		if policy == nil || !aliases.Has(alias) {
+			continue
+		}
+		if ans == nil {
+			ans = make(map[string][]string)
+		}
+		ans[alias] = policy.Overrides()
+	}
+	return ans
+}
+
+func (mf *Manifest) validateAliasPolicies(errs *fieldErrors) {
+	var aliases = make([]string, 0, len(mf.AliasPolicies))
+	for alias := range mf.AliasPolicies {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		field := "alias_policies." + alias
+		if !AliasNameReg.MatchString(alias) {
+			errs.addf(field, "invalid alias %q: should match %s", alias, AliasNameReg.String())
+			continue
+		}
+		policy := mf.AliasPolicies[alias]
+		if policy == nil {
+			errs.addf(field, "empty policy of alias %s", alias)
+			continue
+		}
+		errs.add(field, policy.validate())
+		if effective := policy.apply(*mf); effective.JWT != nil && effective.BasicAuth != nil {
+			errs.addf(field+".jwt", "basic auth and JWT could not be used together by alias %s", alias)
+		}
+	}
+}
+
+func (ap *AliasPolicy) validate() error {
+	var errs []error
+	for _, name := range ap.Unset {
+		known := false
+		for _, setting := range aliasSettings {
+			known = known || setting == name
+		}
+		if !known {
+			errs = append(errs, fmt.Errorf("unknown setting %q to unset", name))
+		}
+	}
+	for _, setting := range aliasSettings {
+		if ap.unsets(setting) && ap.sets(setting) {
+			errs = append(errs, fmt.Errorf("setting %s is both set and unset", setting))
+		}
+	}
+	if _, err := ParseNetworks(ap.AllowedNetworks); err != nil {
+		errs = append(errs, fmt.Errorf("allowed networks: %w", err))
+	}
+	if err := validateMethods(ap.Methods); err != nil {
+		errs = append(errs, err)
+	}
+	if ap.BasicAuth != nil {
+		if len(ap.BasicAuth.Links) > 0 {
+			errs = append(errs, fmt.Errorf("links of basic auth are not used by policy of alias"))
+		}
+		if err := ap.BasicAuth.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("basic auth: %w", err))
+		}
+	}
+	if ap.JWT != nil {
+		if len(ap.JWT.Links) > 0 {
+			errs = append(errs, fmt.Errorf("links of JWT are not used by policy of alias"))
+		}
+		if err := ap.JWT.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("jwt: %w", err))
+		}
+	}
+	if ap.RateLimit != nil {
+		if !(ap.RateLimit.RPS > 0) || math.IsInf(ap.RateLimit.RPS, 0) {
+			errs = append(errs, fmt.Errorf("rate limit RPS should be positive"))
+		}
+		if ap.RateLimit.Burst < 0 {
+			errs = append(errs, fmt.Errorf("rate limit burst should not be negative"))
+		}
+	}
+	return errors.Join(errs...)
+}
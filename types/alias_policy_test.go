@@ -0,0 +1,72 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestManifest_ForAlias(t *testing.T) {
+	auth := &types.BasicAuth{Users: map[string]string{"alice": "$2a$10$Wc5p1aFYwynzcIOrXgcGi.JQdccyMHcLJH9/HOaQ202YEIHdLexgK"}}
+	mf := types.Manifest{
+		Run:             []string{"./report"},
+		Method:          "POST",
+		AllowedNetworks: []string{"10.0.0.0/8"},
+		RateLimit:       &types.RateLimit{RPS: 10},
+		AliasPolicies: map[string]*types.AliasPolicy{
+			"public": {
+				Unset:     []string{types.AliasSettingNetworks},
+				Methods:   []string{"GET"},
+				BasicAuth: auth,
+			},
+		},
+	}
+	require.NoError(t, mf.Validate())
+
+	assert.Equal(t, mf, mf.ForAlias(""))
+	assert.Equal(t, mf, mf.ForAlias("internal"))
+	public := mf.ForAlias("public")
+	assert.Empty(t, public.AllowedNetworks)
+	assert.Equal(t, []string{"GET", "HEAD"}, public.AllowedMethods())
+	assert.Same(t, auth, public.BasicAuth)
+	assert.Equal(t, mf.RateLimit, public.RateLimit, "not overridden settings should be inherited")
+	assert.Equal(t, []string{"10.0.0.0/8"}, mf.AllowedNetworks, "manifest should not be changed")
+
+	overrides := mf.AliasOverrides(types.JsonStringSet{"public": true, "internal": true})
+	assert.Equal(t, map[string][]string{"public": {"allowed_networks", "methods", "basic_auth"}}, overrides)
+	assert.Nil(t, mf.AliasOverrides(types.JsonStringSet{"internal": true}), "policy of not bound alias is not in effect")
+}
+
+func TestAliasPolicy_validate(t *testing.T) {
+	valid := func(policy types.AliasPolicy) types.Manifest {
+		return types.Manifest{Run: []string{"./report"}, AliasPolicies: map[string]*types.AliasPolicy{"public": &policy}}
+	}
+	for name, policy := range map[string]types.AliasPolicy{
+		"unknown unset":   {Unset: []string{"cors"}},
+		"set and unset":   {Unset: []string{types.AliasSettingMethods}, Methods: []string{"GET"}},
+		"networks":        {AllowedNetworks: []string{"not-a-network"}},
+		"methods":         {Methods: []string{""}},
+		"basic auth":      {BasicAuth: &types.BasicAuth{}},
+		"basic auth link": {BasicAuth: &types.BasicAuth{Users: map[string]string{"alice": "$2a$10$Wc5p1aFYwynzcIOrXgcGi.JQdccyMHcLJH9/HOaQ202YEIHdLexgK"}, Links: []string{"public"}}},
+		"jwt":             {JWT: &types.JWTPolicy{}},
+		"rate limit":      {RateLimit: &types.RateLimit{RPS: 0}},
+	} {
+		mf := valid(policy)
+		assert.Error(t, mf.Validate(), name)
+	}
+
+	mf := valid(types.AliasPolicy{RateLimit: &types.RateLimit{RPS: 1}})
+	assert.NoError(t, mf.Validate())
+	mf.AliasPolicies["in valid"] = &types.AliasPolicy{}
+	assert.Error(t, mf.Validate())
+
+	// basic auth of lambda and JWT of alias
+	mf = valid(types.AliasPolicy{JWT: &types.JWTPolicy{Secret: "secret"}})
+	mf.BasicAuth = &types.BasicAuth{Users: map[string]string{"alice": "$2a$10$Wc5p1aFYwynzcIOrXgcGi.JQdccyMHcLJH9/HOaQ202YEIHdLexgK"}}
+	assert.Error(t, mf.Validate())
+	mf.AliasPolicies["public"].Unset = []string{types.AliasSettingBasicAuth}
+	assert.NoError(t, mf.Validate())
+}
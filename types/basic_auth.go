@@ -0,0 +1,117 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Default variable with name of user authenticated by server (CGI convention)
+const DefaultBasicAuthEnv = "REMOTE_USER"
+
+// Default realm of challenge of basic authentication
+const DefaultBasicAuthRealm = "trusted-cgi"
+
+// hash of random password: checked for unknown users, so response time does not reveal existing users
+const unknownUserHash = "$2a$10$Wc5p1aFYwynzcIOrXgcGi.JQdccyMHcLJH9/HOaQ202YEIHdLexgK"
+
+// BasicAuth is protection of lambda by username and password (HTTP basic authentication): requests without valid
+// credentials are answered by 401 with challenge before invocation. Passwords are kept only as bcrypt hashes
+type BasicAuth struct {
+	Users map[string]string `json:"users"`           // username => bcrypt hash of password (see HashPassword)
+	Realm string            `json:"realm,omitempty"` // realm of challenge (default DefaultBasicAuthRealm)
+	Links []string          `json:"links,omitempty"` // protect only requests by the links (aliases), empty - all requests
+	Env   string            `json:"env,omitempty"`   // variable with name of authenticated user (default DefaultBasicAuthEnv)
+}
+
+// HashPassword is bcrypt hash of password for BasicAuth.Users
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Variable with name of authenticated user
+func (ba *BasicAuth) Variable() string {
+	if ba.Env == "" {
+		return DefaultBasicAuthEnv
+	}
+	return ba.Env
+}
+
+// Effective realm of challenge
+func (ba *BasicAuth) RealmName() string {
+	if ba.Realm == "" {
+		return DefaultBasicAuthRealm
+	}
+	return ba.Realm
+}
+
+// Protects request which arrived by the link (empty - by UID)
+func (ba *BasicAuth) Protects(alias string) bool {
+	if len(ba.Links) == 0 {
+		return true
+	}
+	for _, link := range ba.Links {
+		if link == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate user by password
+func (ba *BasicAuth) Authenticate(username, password string) bool {
+	hash, ok := ba.Users[username]
+	if !ok {
+		hash = unknownUserHash
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil && ok
+}
+
+// Sorted names of users
+func (ba *BasicAuth) Usernames() []string {
+	var names = make([]string, 0, len(ba.Users))
+	for name := range ba.Users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (ba *BasicAuth) validate() error {
+	var errs []error
+	if len(ba.Users) == 0 {
+		errs = append(errs, fmt.Errorf("no users of basic auth"))
+	}
+	for _, name := range ba.Usernames() {
+		if name == "" || strings.ContainsAny(name, ":\r\n\x00") {
+			errs = append(errs, fmt.Errorf("invalid username %q", name))
+			continue
+		}
+		if _, err := bcrypt.Cost([]byte(ba.Users[name])); err != nil {
+			// plain passwords are never stored
+			errs = append(errs, fmt.Errorf("password of user %s is not a bcrypt hash", name))
+		}
+	}
+	if strings.ContainsAny(ba.Realm, "\"\r\n") {
+		errs = append(errs, fmt.Errorf("invalid realm %q", ba.Realm))
+	}
+	for _, link := range ba.Links {
+		if link == "" {
+			errs = append(errs, fmt.Errorf("empty link of basic auth"))
+		}
+	}
+	if strings.ContainsAny(ba.Env, "= \t\r\n\x00") {
+		errs = append(errs, fmt.Errorf("invalid variable name %q", ba.Env))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default time of waiting by the build gate: build waits for in-flight invocations, invocations wait for build
+const DefaultBuildGateTimeout = 30 * time.Second
+
+// Default action which replaces files of lambda (also default post-clone action of templates)
+const DefaultBuildAction = "build"
+
+// Serialization of build actions with invocations of lambda. Build starts only after in-flight invocations are
+// finished and new processes of lambda are not spawned till build is done, so invocation never executes file which
+// is being replaced
+type BuildGate struct {
+	Actions  []string     `json:"actions,omitempty"`  // make targets which replace files of lambda, empty - DefaultBuildAction
+	Timeout  JsonDuration `json:"timeout,omitempty"`  // maximum waiting of build for invocations and of invocations for build, zero - DefaultBuildGateTimeout
+	Disabled bool         `json:"disabled,omitempty"` // builds run concurrently with invocations
+}
+
+// Gated action: build which should not run concurrently with invocations (see BuildGate)
+func (mf *Manifest) Gated(action string) bool {
+	gate := mf.BuildGate
+	if gate == nil {
+		return action == DefaultBuildAction
+	}
+	if gate.Disabled {
+		return false
+	}
+	if len(gate.Actions) == 0 {
+		return action == DefaultBuildAction
+	}
+	for _, name := range gate.Actions {
+		if name == action {
+			return true
+		}
+	}
+	return false
+}
+
+// GateTimeout of build gate, DefaultBuildGateTimeout if not set
+func (mf *Manifest) GateTimeout() time.Duration {
+	if mf.BuildGate == nil || mf.BuildGate.Timeout <= 0 {
+		return DefaultBuildGateTimeout
+	}
+	return time.Duration(mf.BuildGate.Timeout)
+}
+
+func (bg BuildGate) validate() error {
+	var errs []error
+	if bg.Timeout < 0 {
+		errs = append(errs, errors.New("timeout should not be negative"))
+	}
+	for i, name := range bg.Actions {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("actions[%d]: empty name of action", i))
+		}
+	}
+	return errors.Join(errs...)
+}
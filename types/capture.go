@@ -0,0 +1,35 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default maximum size of captured request body
+const DefaultCaptureBytes = 64 * 1024
+
+// Capture of the newest requests by HTTP with headers and payloads for debugging and replay. Payloads could contain
+// sensitive data, so requests are captured only if lambda opted in
+type Capture struct {
+	Requests int   `json:"requests"`            // number of the newest requests kept
+	MaxBytes int64 `json:"max_bytes,omitempty"` // bodies over the limit are kept truncated and could not be replayed (zero - DefaultCaptureBytes)
+}
+
+// Limit of captured body
+func (c *Capture) Limit() int64 {
+	if c.MaxBytes <= 0 {
+		return DefaultCaptureBytes
+	}
+	return c.MaxBytes
+}
+
+func (c *Capture) validate() error {
+	var errs []error
+	if c.Requests < 1 {
+		errs = append(errs, fmt.Errorf("number of captured requests should be positive"))
+	}
+	if c.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("maximum size of captured body should not be negative"))
+	}
+	return errors.Join(errs...)
+}
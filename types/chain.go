@@ -0,0 +1,155 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+)
+
+// Limits of output chaining
+const (
+	DefaultChainSize = 1024 * 1024 // maximum size of output to enqueue if size is not set
+	DefaultChainHops = 8           // maximum length of chain if hops are not set
+)
+
+// Request is not enqueued because of length of chain
+var ErrChainHops = errors.New("too many hops in chain")
+
+// Chaining of successful output of lambda (invocations by HTTP, from queue and scheduled actions) to queue of another
+// lambda. Message carries chain of lambdas (Request.Chain) which bounds loops.
+type Chaining struct {
+	Queue     string `json:"queue"`               // target queue
+	Transform string `json:"transform,omitempty"` // Go template of message body over output (ex: {{.JSON.id}}), empty - output as is
+	MaxSize   int64  `json:"max_size,omitempty"`  // maximum size of output and message (zero - DefaultChainSize), bigger output is not enqueued
+	MaxHops   int    `json:"max_hops,omitempty"`  // maximum length of chain (zero - DefaultChainHops)
+}
+
+// Conditions of chain step
+const (
+	ThenOnSuccess = "on-success" // invocation succeeded (default)
+	ThenOnError   = "on-error"   // invocation failed
+	ThenAlways    = "always"     // any result
+)
+
+// Headers of request of chain step
+const (
+	ChainSourceHeader = "X-Chain-Source" // UID of lambda which output is payload
+	ChainStatusHeader = "X-Chain-Status" // result of source invocation: success or error
+)
+
+// Step of chain (see Manifest.Then): output of invocation is payload of invocation of target lambda if result of
+// invocation matches condition. Target is invoked in background with the same request ID
+type ChainStep struct {
+	Lambda    string `json:"lambda"`              // UID or alias of target lambda
+	Condition string `json:"condition,omitempty"` // ThenOnSuccess (default), ThenOnError or ThenAlways
+}
+
+// Matches result of invocation (nil - succeeded)
+func (cs *ChainStep) Matches(err error) bool {
+	switch cs.Condition {
+	case ThenAlways:
+		return true
+	case ThenOnError:
+		return err != nil
+	default:
+		return err == nil
+	}
+}
+
+func (cs *ChainStep) validate() error {
+	if cs.Lambda == "" {
+		return fmt.Errorf("target lambda of step is not set")
+	}
+	switch cs.Condition {
+	case "", ThenOnSuccess, ThenOnError, ThenAlways:
+		return nil
+	default:
+		return fmt.Errorf("unknown condition %q, expected %s, %s or %s", cs.Condition, ThenOnSuccess, ThenOnError, ThenAlways)
+	}
+}
+
+// Data of transform template
+type ChainOutput struct {
+	UID     string      // lambda UID
+	Output  string      // output as is
+	JSON    interface{} // decoded output if it is valid JSON
+	Request *Request    // source request (nil for scheduled actions), body is not available
+}
+
+// maximum size of output and message
+func (ch *Chaining) Limit() int64 {
+	if ch.MaxSize <= 0 {
+		return DefaultChainSize
+	}
+	return ch.MaxSize
+}
+
+// maximum length of chain
+func (ch *Chaining) Hops() int {
+	if ch.MaxHops <= 0 {
+		return DefaultChainHops
+	}
+	return ch.MaxHops
+}
+
+func (ch *Chaining) validate() error {
+	if ch.Queue == "" {
+		return fmt.Errorf("queue of chaining is not set")
+	}
+	if ch.MaxSize < 0 || ch.MaxHops < 0 {
+		return fmt.Errorf("limits of chaining should not be negative")
+	}
+	if _, err := ch.template(); err != nil {
+		return fmt.Errorf("invalid transform of chaining: %w", err)
+	}
+	return nil
+}
+
+func (ch *Chaining) template() (*template.Template, error) {
+	return template.New("transform").Option("missingkey=zero").Parse(ch.Transform)
+}
+
+// Message for queue by output of lambda invoked by source request (nil for scheduled actions). Chain of source is
+// extended by lambda UID; ErrChainHops is returned if chain is too long.
+func (ch *Chaining) Message(uid string, source *Request, output []byte) (*Request, error) {
+	var chain []string
+	var id string
+	if source != nil {
+		chain = source.Chain
+		id = source.ID
+	}
+	if len(chain) >= ch.Hops() {
+		return nil, fmt.Errorf("%w: %d", ErrChainHops, len(chain))
+	}
+	body := output
+	if ch.Transform != "" {
+		tpl, err := ch.template()
+		if err != nil {
+			return nil, fmt.Errorf("parse transform: %w", err)
+		}
+		var data = ChainOutput{UID: uid, Output: string(output), Request: source}
+		_ = json.Unmarshal(output, &data.JSON)
+		var out bytes.Buffer
+		if err := tpl.Execute(&out, data); err != nil {
+			return nil, fmt.Errorf("transform output: %w", err)
+		}
+		body = out.Bytes()
+	}
+	if int64(len(body)) > ch.Limit() {
+		return nil, fmt.Errorf("message is too big (%d bytes), maximum is %d bytes", len(body), ch.Limit())
+	}
+	return &Request{
+		ID:      id,
+		Method:  http.MethodPost,
+		URL:     QueuePath(ch.Queue),
+		Path:    ch.Queue,
+		Form:    map[string]string{},
+		Headers: map[string]string{"Content-Type": http.DetectContentType(body)},
+		Chain:   append(append([]string{}, chain...), uid),
+		Body:    ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
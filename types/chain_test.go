@@ -0,0 +1,50 @@
+package types_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestChaining_Message(t *testing.T) {
+	chain := types.Chaining{Queue: "next", Transform: `{"id":{{.JSON.id}},"via":"{{.UID}}","user":"{{.Request.Headers.User}}"}`}
+	source := &types.Request{ID: "abc", Headers: map[string]string{"User": "bob"}, Chain: []string{"first"}}
+	msg, err := chain.Message("second", source, []byte(`{"id":42}`))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(msg.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":42,"via":"second","user":"bob"}`, string(body))
+	assert.Equal(t, []string{"first", "second"}, msg.Chain)
+	assert.Equal(t, []string{"first"}, source.Chain, "source is not changed")
+	assert.Equal(t, "next", msg.Path)
+	assert.Equal(t, "abc", msg.ID, "request ID of source is kept")
+
+	chain = types.Chaining{Queue: "next", MaxHops: 1, MaxSize: 4}
+	_, err = chain.Message("second", source, []byte("ok"))
+	assert.True(t, errors.Is(err, types.ErrChainHops))
+	msg, err = chain.Message("first", nil, []byte("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, msg.Chain, "scheduled action starts chain")
+	_, err = chain.Message("first", nil, []byte("too big"))
+	assert.Error(t, err)
+}
+
+func TestChaining_validate(t *testing.T) {
+	mf := types.Manifest{Name: "test", Run: []string{"echo"}, OnSuccess: &types.Chaining{Transform: "{{.Output"}}
+	err := mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "queue of chaining is not set")
+	}
+	mf.OnSuccess.Queue = "next"
+	err = mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid transform of chaining")
+	}
+	mf.OnSuccess.Transform = "{{.Output}}"
+	assert.NoError(t, mf.Validate())
+}
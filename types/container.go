@@ -0,0 +1,76 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Path of lambda directory in container (mounted read-only)
+const ContainerDir = "/lambda"
+
+// Container of invocations (see Manifest.Container): invocation is run by Docker compatible engine in container of
+// image instead of native process. Run command of manifest is command of container (empty - command of image)
+type Container struct {
+	Image      string   `json:"image"`                // image reference (ex: python:3.12-slim)
+	Entrypoint []string `json:"entrypoint,omitempty"` // overrides entrypoint of image (empty - entrypoint of image)
+	Mounts     []Mount  `json:"mounts,omitempty"`     // additional mounts of lambda directories
+	Network    bool     `json:"network,omitempty"`    // access to network, also allowed by network of manifest (by default - no network)
+}
+
+// Mount of directory inside lambda to container
+type Mount struct {
+	Source   string `json:"source"`             // relative path inside lambda directory
+	Target   string `json:"target"`             // absolute path in container
+	Writable bool   `json:"writable,omitempty"` // by default mount is read-only
+}
+
+func (ct *Container) validate() error {
+	var errs []error
+	if strings.TrimSpace(ct.Image) == "" {
+		errs = append(errs, fmt.Errorf("image of container is not set"))
+	} else if strings.ContainsAny(ct.Image, " \t\r\n") || strings.HasPrefix(ct.Image, "-") {
+		errs = append(errs, fmt.Errorf("invalid image reference %q", ct.Image))
+	}
+	for i, arg := range ct.Entrypoint {
+		if arg == "" {
+			errs = append(errs, fmt.Errorf("empty argument %d of entrypoint", i))
+		}
+	}
+	for _, mount := range ct.Mounts {
+		if !filepath.IsLocal(mount.Source) {
+			errs = append(errs, fmt.Errorf("source %q of mount should be relative path inside lambda", mount.Source))
+		}
+		if !path.IsAbs(mount.Target) || path.Clean(mount.Target) == "/" || strings.ContainsAny(mount.Target, ":,") {
+			errs = append(errs, fmt.Errorf("target %q of mount should be absolute path in container", mount.Target))
+		} else if clean := path.Clean(mount.Target); clean == ContainerDir || strings.HasPrefix(clean, ContainerDir+"/") {
+			errs = append(errs, fmt.Errorf("target %q of mount should not be inside %s", mount.Target, ContainerDir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate settings which are not compatible with container
+func (mf *Manifest) validateContainer(errs *fieldErrors) {
+	if mf.Container == nil {
+		return
+	}
+	errs.add("container", mf.Container.validate())
+	if mf.Worker() {
+		errs.addf("container", "container is not compatible with worker mode")
+	}
+	if mf.PayloadAsFile {
+		errs.addf("payload_as_file", "payload as file is not compatible with container: file is not visible in container")
+	}
+	if mf.DecodeMultipart {
+		errs.addf("decode_multipart", "decoding of multipart is not compatible with container: files are not visible in container")
+	}
+	if mf.RawFormEnv != "" {
+		errs.addf("raw_form_env", "raw form file is not compatible with container: file is not visible in container")
+	}
+	if mf.Secrets.Mode() != SecretsEnv {
+		errs.addf("secrets", "only %s delivery of secrets is compatible with container", SecretsEnv)
+	}
+}
@@ -0,0 +1,32 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestContainer_validate(t *testing.T) {
+	mf := types.Manifest{Name: "test", Container: &types.Container{Image: "alpine:3", Mounts: []types.Mount{{Source: "data", Target: "/data"}}}}
+	assert.NoError(t, mf.Validate())
+
+	cases := map[string]types.Container{
+		"invalid image reference":      {Image: "alpine --privileged"},
+		"should be relative path":      {Image: "alpine", Mounts: []types.Mount{{Source: "../etc", Target: "/data"}}},
+		"should not be inside /lambda": {Image: "alpine", Mounts: []types.Mount{{Source: "data", Target: "/lambda/data"}}},
+	}
+	for message, container := range cases {
+		container := container
+		mf.Container = &container
+		err := mf.Validate()
+		if assert.Error(t, err, message) {
+			assert.Contains(t, err.Error(), message)
+		}
+	}
+
+	mf.Container = &types.Container{Image: "alpine"}
+	mf.PayloadAsFile = true
+	assert.Error(t, mf.Validate(), "payload file is not visible in container")
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Request rejected because of Content-Type (see Manifest.AcceptedContentTypes)
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Normalize media type of accepted content types: lower case without parameters. Wildcard subtype (type/*) is
+// allowed, wildcard type only as */*.
+func NormalizeMediaType(value string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(mediaType, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("media type should be in type/subtype format")
+	}
+	if parts[0] == "*" && parts[1] != "*" {
+		return "", fmt.Errorf("wildcard type is allowed only as */*")
+	}
+	return mediaType, nil
+}
+
+// Check Content-Type of request against accepted content types. GET and HEAD requests without body are not checked.
+func (mf *Manifest) AcceptContentType(req *Request) error {
+	if len(mf.AcceptedContentTypes) == 0 {
+		return nil
+	}
+	if (req.Method == http.MethodGet || req.Method == http.MethodHead) && !req.hasBody() {
+		return nil
+	}
+	value := req.Headers["Content-Type"]
+	if value == "" {
+		if mf.AllowNoContentType {
+			return nil
+		}
+		return fmt.Errorf("%w: Content-Type is not set", ErrUnsupportedContentType)
+	}
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, value)
+	}
+	for _, accepted := range mf.AcceptedContentTypes {
+		if matchMediaType(strings.ToLower(accepted), mediaType) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedContentType, mediaType)
+}
+
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+		return strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(prefix, "/")
+	}
+	return false
+}
+
+// request has body by headers (incoming request always has non-nil body)
+func (z *Request) hasBody() bool {
+	length := z.Headers["Content-Length"]
+	return (length != "" && length != "0") || z.Headers["Transfer-Encoding"] != ""
+}
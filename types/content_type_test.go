@@ -0,0 +1,25 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestNormalizeMediaType(t *testing.T) {
+	value, err := types.NormalizeMediaType("Application/JSON; charset=utf-8")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", value)
+
+	value, err = types.NormalizeMediaType("text/*")
+	require.NoError(t, err)
+	assert.Equal(t, "text/*", value)
+
+	for _, invalid := range []string{"", "json", "*/json", "application/"} {
+		_, err := types.NormalizeMediaType(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
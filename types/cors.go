@@ -0,0 +1,96 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Any origin in CORS policy
+const AnyOrigin = "*"
+
+// CORS policy of lambda for browser front-ends. Preflight requests (OPTIONS) are answered by server without
+// invocation, Access-Control-* headers are attached to responses only if Origin of request is allowed.
+// Empty policy (without origins) keeps default behaviour of server: any origin is allowed.
+type CORS struct {
+	Origins     []string     `json:"origins,omitempty"`     // allowed origins (scheme://host[:port]), * - any, * in host matches subdomains (ex: https://*.example.com)
+	Methods     []string     `json:"methods,omitempty"`     // allowed methods of preflight (empty - allowed methods of lambda or requested method)
+	Headers     []string     `json:"headers,omitempty"`     // allowed request headers of preflight (empty - only CORS-safelisted headers)
+	Credentials bool         `json:"credentials,omitempty"` // allow credentials (cookies, authorization), not allowed with any origin
+	MaxAge      JsonDuration `json:"max_age,omitempty"`     // time to cache preflight response by browser (zero - browser default)
+}
+
+// Policy is defined (has origins)
+func (c *CORS) Enabled() bool {
+	return c != nil && len(c.Origins) > 0
+}
+
+// Origin of request is allowed by policy (case-insensitive). Second value is true if origin is allowed as any origin.
+func (c *CORS) Allow(origin string) (allowed bool, any bool) {
+	if origin == "" || !c.Enabled() {
+		return false, false
+	}
+	for _, pattern := range c.Origins {
+		if pattern == AnyOrigin {
+			return true, true
+		}
+		if matchOrigin(pattern, origin) {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
+
+// at most one wildcard, matches at least one symbol
+func matchOrigin(pattern, origin string) bool {
+	pattern, origin = strings.ToLower(pattern), strings.ToLower(origin)
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return pattern == origin
+	}
+	return len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+func (c *CORS) validate() error {
+	var errs []error
+	if len(c.Origins) == 0 && (len(c.Methods) > 0 || len(c.Headers) > 0 || c.Credentials || c.MaxAge != 0) {
+		errs = append(errs, errors.New("cors origins are not defined"))
+	}
+	for _, origin := range c.Origins {
+		if origin == AnyOrigin {
+			if c.Credentials {
+				errs = append(errs, errors.New("credentials are not allowed for any origin (*): browsers reject it, list origins explicitly"))
+			}
+			continue
+		}
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateMethods(c.Methods); err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range c.Headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			errs = append(errs, fmt.Errorf("invalid name of allowed header %q", name))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("cors max age should not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateOrigin(origin string) error {
+	if strings.Count(origin, "*") > 1 {
+		return fmt.Errorf("invalid origin %q: only one wildcard is allowed", origin)
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "x", 1))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid origin %q: should be scheme://host[:port]", origin)
+	}
+	return nil
+}
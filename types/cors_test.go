@@ -0,0 +1,67 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestCORS_Allow(t *testing.T) {
+	var empty *types.CORS
+	allowed, _ := empty.Allow("https://example.com")
+	assert.False(t, allowed)
+
+	policy := &types.CORS{Origins: []string{"https://app.example.com", "https://*.example.org"}}
+	allowed, any := policy.Allow("https://APP.example.com")
+	assert.True(t, allowed)
+	assert.False(t, any)
+	allowed, _ = policy.Allow("https://api.v2.example.org")
+	assert.True(t, allowed, "wildcard matches subdomains")
+	allowed, _ = policy.Allow("https://example.org")
+	assert.False(t, allowed)
+	allowed, _ = policy.Allow("http://app.example.com")
+	assert.False(t, allowed, "scheme is part of origin")
+	allowed, _ = policy.Allow("")
+	assert.False(t, allowed)
+
+	policy.Origins = append(policy.Origins, types.AnyOrigin)
+	allowed, any = policy.Allow("https://other.example.net")
+	assert.True(t, allowed)
+	assert.True(t, any)
+}
+
+func TestManifest_ValidateCORS(t *testing.T) {
+	mf := types.Manifest{Run: []string{"echo"}, CORS: &types.CORS{}}
+	assert.NoError(t, mf.Validate(), "empty block keeps default behaviour")
+
+	mf.CORS = &types.CORS{Origins: []string{"*"}, Credentials: true}
+	err := mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cors: credentials are not allowed for any origin")
+	}
+
+	mf.CORS = &types.CORS{
+		Origins: []string{"https://app.example.com", "example.com", "https://*.*.example.com", "https://app.example.com/path"},
+		Methods: []string{"BAD METHOD"},
+		Headers: []string{"X Bad"},
+		MaxAge:  -1,
+	}
+	err = mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid origin "example.com"`)
+		assert.Contains(t, err.Error(), `invalid origin "https://*.*.example.com": only one wildcard is allowed`)
+		assert.Contains(t, err.Error(), `invalid origin "https://app.example.com/path"`)
+		assert.Contains(t, err.Error(), `invalid method "BAD METHOD"`)
+		assert.Contains(t, err.Error(), `invalid name of allowed header "X Bad"`)
+		assert.Contains(t, err.Error(), "cors max age should not be negative")
+		assert.NotContains(t, err.Error(), "https://app.example.com\"")
+	}
+
+	mf.CORS = &types.CORS{Methods: []string{"GET"}}
+	assert.Error(t, mf.Validate(), "policy without origins")
+
+	mf.CORS = &types.CORS{Origins: []string{"https://*.example.com", "http://localhost:8080"}, Credentials: true}
+	assert.NoError(t, mf.Validate())
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default limits of process environment: well under the kernel limits (ARG_MAX is 2 MiB with default stack size,
+// single argument or variable is limited by 128 KiB).
+const (
+	DefaultMaxEnvSize     = 512 * 1024
+	DefaultMaxEnvVariable = 64 * 1024
+)
+
+// Policies of oversized variables from request
+const (
+	OversizedDeny     = "deny"     // reject invocation
+	OversizedTruncate = "truncate" // cut variable to the limit
+)
+
+// Limits of arguments and environment of invoked process. Empty values mean not defined (defaults are used).
+type EnvLimits struct {
+	MaxSize     int64  `json:"max_size,omitempty"`     // maximum total size of arguments and environment in bytes
+	MaxVariable int64  `json:"max_variable,omitempty"` // maximum size of single variable from request (NAME=value) in bytes
+	Oversized   string `json:"oversized,omitempty"`    // policy of variable from request bigger than maximum: deny (default) or truncate
+}
+
+// Override returns copy of limits with non-empty values from other limits.
+func (el EnvLimits) Override(other EnvLimits) EnvLimits {
+	if other.MaxSize != 0 {
+		el.MaxSize = other.MaxSize
+	}
+	if other.MaxVariable != 0 {
+		el.MaxVariable = other.MaxVariable
+	}
+	if other.Oversized != "" {
+		el.Oversized = other.Oversized
+	}
+	return el
+}
+
+// Size limit of arguments and environment (DefaultMaxEnvSize if not defined).
+func (el EnvLimits) SizeLimit() int64 {
+	if el.MaxSize == 0 {
+		return DefaultMaxEnvSize
+	}
+	return el.MaxSize
+}
+
+// Size limit of single variable (DefaultMaxEnvVariable if not defined).
+func (el EnvLimits) VariableLimit() int64 {
+	if el.MaxVariable == 0 {
+		return DefaultMaxEnvVariable
+	}
+	return el.MaxVariable
+}
+
+// Oversized variables from request should be truncated instead of rejecting invocation.
+func (el EnvLimits) Truncate() bool {
+	return el.Oversized == OversizedTruncate
+}
+
+// Validate limits: sizes are not negative, policy is deny or truncate.
+func (el EnvLimits) Validate() error {
+	var errs []error
+	if el.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("maximum environment size %d is negative", el.MaxSize))
+	}
+	if el.MaxVariable < 0 {
+		errs = append(errs, fmt.Errorf("maximum variable size %d is negative", el.MaxVariable))
+	}
+	switch el.Oversized {
+	case "", OversizedDeny, OversizedTruncate:
+	default:
+		errs = append(errs, fmt.Errorf("unknown policy of oversized variables %q (expected %s or %s)", el.Oversized, OversizedDeny, OversizedTruncate))
+	}
+	return errors.Join(errs...)
+}
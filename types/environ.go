@@ -0,0 +1,208 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExpandEnvironment returns copy of lambda environment with resolved references ${NAME} in values. Reference to
+// another variable of the environment is resolved to its expanded value, other references are resolved by lookup
+// (server environment and secrets), unknown - to empty string. $${ is escaped ${ (not a reference).
+// Variables in cycle of references are resolved to empty string (see ValidateEnvironment).
+func ExpandEnvironment(env map[string]string, lookup func(name string) (string, bool)) map[string]string {
+	if len(env) == 0 {
+		return env
+	}
+	var ans = make(map[string]string, len(env))
+	var visiting = make(map[string]bool)
+	var resolve func(name string) string
+	resolve = func(name string) string {
+		if value, ok := ans[name]; ok {
+			return value
+		}
+		raw, ok := env[name]
+		if !ok {
+			if lookup != nil {
+				value, _ := lookup(name)
+				return value
+			}
+			return ""
+		}
+		if visiting[name] {
+			return ""
+		}
+		visiting[name] = true
+		value, _ := expandValue(raw, resolve)
+		visiting[name] = false
+		ans[name] = value
+		return value
+	}
+	for name := range env {
+		resolve(name)
+	}
+	return ans
+}
+
+// ExpandValue returns value with resolved references ${NAME} by lookup, unknown - to empty string. $${ is escaped ${
+func ExpandValue(value string, lookup func(name string) (string, bool)) string {
+	expanded, _ := expandValue(value, func(name string) string {
+		resolved, _ := lookup(name)
+		return resolved
+	})
+	return expanded
+}
+
+// ValidateEnvironment checks names of variables, syntax of references (including names of referenced secrets) and
+// absence of cycles of references.
+func ValidateEnvironment(env map[string]string) error {
+	var names = make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	var refs = make(map[string][]string, len(env))
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			errs = append(errs, fmt.Errorf("invalid environment variable name %q", name))
+			continue
+		}
+		_, err := expandValue(env[name], func(ref string) string {
+			if _, ok := env[ref]; ok {
+				refs[name] = append(refs[name], ref)
+			}
+			return ""
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %s: %w", name, err))
+		}
+		if secret, ok := SecretReference(env[name]); ok && !SecretNameReg.MatchString(secret) {
+			errs = append(errs, fmt.Errorf("environment variable %s: invalid name of secret %q", name, secret))
+		}
+	}
+	// depth-first search of cycles: 1 - in progress, 2 - done
+	var state = make(map[string]int)
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case 1:
+			return true
+		case 2:
+			return false
+		}
+		state[name] = 1
+		for _, ref := range refs[name] {
+			if visit(ref) {
+				return true
+			}
+		}
+		state[name] = 2
+		return false
+	}
+	for _, name := range names {
+		if state[name] == 0 && visit(name) {
+			errs = append(errs, fmt.Errorf("environment variable %s: cycle of references", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replace references ${NAME} in value by resolved values, $${ by ${
+func expandValue(value string, resolve func(name string) string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var out strings.Builder
+	for {
+		idx := strings.Index(value, "${")
+		if idx < 0 {
+			out.WriteString(value)
+			return out.String(), nil
+		}
+		if idx > 0 && value[idx-1] == '$' {
+			// escaped: $${ -> ${
+			out.WriteString(value[:idx-1])
+			out.WriteString("${")
+			value = value[idx+2:]
+			continue
+		}
+		out.WriteString(value[:idx])
+		end := strings.IndexByte(value[idx:], '}')
+		if end < 0 {
+			return out.String() + value[idx:], fmt.Errorf("unterminated reference %q", value[idx:])
+		}
+		name := value[idx+2 : idx+end]
+		if !isEnvReference(name) {
+			return out.String() + value[idx:], fmt.Errorf("invalid reference ${%s}", name)
+		}
+		out.WriteString(resolve(name))
+		value = value[idx+end+1:]
+	}
+}
+
+// ExpandArgument returns argument of run command (or work dir, see Manifest.ExpandRun) with resolved references
+// ${NAME} and ${NAME:-default} by lookup: default is used if variable is unset or empty. $$ is escaped $, $ without {
+// is kept as is. Unset variable without default is an error, so argument is never silently empty
+func ExpandArgument(value string, lookup func(name string) (string, bool)) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+	var out strings.Builder
+	for {
+		idx := strings.IndexByte(value, '$')
+		if idx < 0 {
+			out.WriteString(value)
+			return out.String(), nil
+		}
+		out.WriteString(value[:idx])
+		value = value[idx:]
+		switch {
+		case strings.HasPrefix(value, "$$"):
+			out.WriteByte('$')
+			value = value[2:]
+		case strings.HasPrefix(value, "${"):
+			end := strings.IndexByte(value, '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference %q", value)
+			}
+			name, fallback, hasDefault := strings.Cut(value[2:end], ":-")
+			if !isEnvReference(name) {
+				return "", fmt.Errorf("invalid reference %s", value[:end+1])
+			}
+			resolved, ok := lookup(name)
+			if hasDefault && resolved == "" {
+				resolved = fallback
+			} else if !ok {
+				return "", fmt.Errorf("variable %s is not set", name)
+			}
+			out.WriteString(resolved)
+			value = value[end+1:]
+		default:
+			out.WriteByte('$')
+			value = value[1:]
+		}
+	}
+}
+
+// ValidateArgument checks syntax of references in argument (see ExpandArgument)
+func ValidateArgument(value string) error {
+	_, err := ExpandArgument(value, func(name string) (string, bool) {
+		return "", true
+	})
+	return err
+}
+
+// name of referenced variable: latin letters, digits and underscore, not starting with digit
+func isEnvReference(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
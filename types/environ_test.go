@@ -0,0 +1,85 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestExpandEnvironment(t *testing.T) {
+	global := map[string]string{"DB_SECRET": "pass", "HOST": "global"}
+	lookup := func(name string) (string, bool) {
+		v, ok := global[name]
+		return v, ok
+	}
+	env := types.ExpandEnvironment(map[string]string{
+		"HOST":    "db",
+		"DB_URL":  "postgres://app:${DB_SECRET}@${HOST}/${DB_NAME}",
+		"DB_NAME": "app",
+		"ESCAPED": "$${HOST} costs $5",
+		"UNKNOWN": "[${MISSING}]",
+		"PLAIN":   "a$b",
+	}, lookup)
+	assert.Equal(t, "postgres://app:pass@db/app", env["DB_URL"], "lambda variables override global")
+	assert.Equal(t, "${HOST} costs $5", env["ESCAPED"])
+	assert.Equal(t, "[]", env["UNKNOWN"])
+	assert.Equal(t, "a$b", env["PLAIN"])
+	assert.Equal(t, "db", env["HOST"])
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	assert.NoError(t, types.ValidateEnvironment(map[string]string{"A": "${B}", "B": "${C}", "ESCAPED": "$${A"}))
+
+	err := types.ValidateEnvironment(map[string]string{"A": "${B}", "B": "${A}"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cycle of references")
+	}
+	err = types.ValidateEnvironment(map[string]string{"A": "${B", "C": "${1D}", "SELF": "${SELF}"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "environment variable A: unterminated reference")
+		assert.Contains(t, err.Error(), "environment variable C: invalid reference ${1D}")
+		assert.Contains(t, err.Error(), "environment variable SELF: cycle of references")
+	}
+	err = types.ValidateEnvironment(map[string]string{"KEY": "@secret:stripe_key", "BAD": "@secret:../key"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `environment variable BAD: invalid name of secret "../key"`)
+		assert.NotContains(t, err.Error(), "KEY")
+	}
+	// cycle is resolved to empty string
+	env := types.ExpandEnvironment(map[string]string{"A": "a${B}", "B": "b${A}"}, nil)
+	assert.Len(t, env["A"]+env["B"], 3)
+}
+
+func TestExpandArgument(t *testing.T) {
+	env := map[string]string{"PYTHON": "python3.11", "EMPTY": "", "TOKEN": "secret"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	for arg, expected := range map[string]string{
+		"app.py":              "app.py",
+		"${PYTHON}":           "python3.11",
+		"${MISSING:-python3}": "python3",
+		"${EMPTY:-default}":   "default",
+		"${PYTHON:-python3}":  "python3.11",
+		"${MISSING:-}":        "",
+		"--token=${TOKEN}":    "--token=secret",
+		"$$5 and $${TOKEN}":   "$5 and ${TOKEN}",
+		"a$b$":                "a$b$",
+		"${EMPTY}":            "",
+	} {
+		actual, err := types.ExpandArgument(arg, lookup)
+		assert.NoError(t, err, arg)
+		assert.Equal(t, expected, actual, arg)
+	}
+	_, err := types.ExpandArgument("--token=${API_TOKEN}", lookup)
+	assert.EqualError(t, err, "variable API_TOKEN is not set")
+	_, err = types.ExpandArgument("${MISSING-python3}", lookup)
+	assert.Error(t, err, "only :- is default")
+
+	assert.NoError(t, types.ValidateArgument("${MISSING} $$ ${A:-b}"))
+	assert.EqualError(t, types.ValidateArgument("${PYTHON"), `unterminated reference "${PYTHON"`)
+	assert.EqualError(t, types.ValidateArgument("${1A:-x}"), "invalid reference ${1A:-x}")
+}
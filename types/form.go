@@ -0,0 +1,60 @@
+package types
+
+import "mime"
+
+// Default limit of form-urlencoded body decoded to JSON if maximum payload is not set (see Manifest.DecodeForm)
+const DefaultFormPayload = 1024 * 1024
+
+// FormFile is file part of multipart body decoded to JSON (see Manifest.DecodeMultipart)
+type FormFile struct {
+	Path        string `json:"path"`                   // absolute path of temporary file with content of part
+	Filename    string `json:"filename"`               // original name of file from request
+	ContentType string `json:"content_type,omitempty"` // content type of part
+	Size        int64  `json:"size"`                   // size of file in bytes
+}
+
+// FormFileLimit is limit of one file part of decoded multipart body: maximum form file or limit of all files
+func (mf *Manifest) FormFileLimit() int64 {
+	if mf.MaximumFormFile > 0 && mf.MaximumFormFile < mf.FilePayloadLimit() {
+		return mf.MaximumFormFile
+	}
+	return mf.FilePayloadLimit()
+}
+
+// RequestLimit is limit of request body by content type: multipart body decoded to files is limited as file payload
+// (zero - unlimited)
+func (mf *Manifest) RequestLimit(contentType string) int64 {
+	if _, ok := MultipartBoundary(contentType); ok && mf.DecodeMultipart {
+		return mf.FilePayloadLimit()
+	}
+	if IsURLEncodedForm(contentType) && mf.DecodeForm {
+		return mf.FormPayloadLimit()
+	}
+	if mf.PayloadAsFile {
+		return mf.FilePayloadLimit()
+	}
+	return mf.MaximumPayload
+}
+
+// FormPayloadLimit is limit of original form-urlencoded body decoded to JSON: maximum payload or default
+func (mf *Manifest) FormPayloadLimit() int64 {
+	if mf.MaximumPayload > 0 {
+		return mf.MaximumPayload
+	}
+	return DefaultFormPayload
+}
+
+// IsURLEncodedForm checks that content type is application/x-www-form-urlencoded
+func IsURLEncodedForm(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+// MultipartBoundary of multipart/form-data content type (false - other content type or no boundary)
+func MultipartBoundary(contentType string) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", false
+	}
+	return params["boundary"], true
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Minimal interval of polling of git source
+const MinGitInterval = 10 * time.Second
+
+// GitSource is branch of repository tracked by lambda: when the commit of branch changes, files of lambda are replaced
+// by files of repository (honoring .cgiignore of repository), manifest of repository is applied and build action is
+// run. Build is done in staging directory, so lambda serves the previous version till the new one is built
+type GitSource struct {
+	Repo     string       `json:"repo"`               // URL of repository (https, ssh or local path)
+	Branch   string       `json:"branch,omitempty"`   // tracked branch (empty - default branch of repository)
+	Dir      string       `json:"dir,omitempty"`      // subdirectory of repository with lambda (empty - root)
+	Interval JsonDuration `json:"interval,omitempty"` // polling of branch (zero - only by ping and API)
+	Action   string       `json:"action,omitempty"`   // build action (make target) run before deploy (empty - no build)
+	Key      string       `json:"key,omitempty"`      // name of secret of server store with private SSH deploy key (empty - key of server)
+	// verification of ping requests (POST /git/<uid>) by webhook provider of repository (nil - ping is disabled)
+	Ping *WebhookSignature `json:"ping,omitempty"`
+}
+
+// Reference of tracked branch for fetch (HEAD for default branch)
+func (gs *GitSource) Ref() string {
+	if gs.Branch == "" {
+		return "HEAD"
+	}
+	return gs.Branch
+}
+
+// Subdirectory of repository without leading and trailing slashes (empty - root)
+func (gs *GitSource) Subdir() string {
+	return strings.Trim(path.Clean("/"+gs.Dir), "/")
+}
+
+func (gs *GitSource) validate() error {
+	var errs []error
+	if strings.TrimSpace(gs.Repo) == "" {
+		errs = append(errs, errors.New("repository is required"))
+	} else if strings.HasPrefix(gs.Repo, "-") {
+		errs = append(errs, fmt.Errorf("invalid repository %q", gs.Repo))
+	}
+	if strings.HasPrefix(gs.Branch, "-") || strings.ContainsAny(gs.Branch, " ~^:?*[\\") || strings.Contains(gs.Branch, "..") {
+		errs = append(errs, fmt.Errorf("invalid branch %q", gs.Branch))
+	}
+	for _, part := range strings.Split(gs.Dir, "/") {
+		if part == ".." {
+			errs = append(errs, fmt.Errorf("directory %q should be inside repository", gs.Dir))
+			break
+		}
+	}
+	if gs.Interval < 0 {
+		errs = append(errs, errors.New("interval should not be negative"))
+	} else if gs.Interval > 0 && time.Duration(gs.Interval) < MinGitInterval {
+		errs = append(errs, fmt.Errorf("interval should be at least %v", MinGitInterval))
+	}
+	if strings.HasPrefix(gs.Action, "-") {
+		errs = append(errs, fmt.Errorf("invalid action %q", gs.Action))
+	}
+	if gs.Ping != nil {
+		if err := gs.Ping.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("ping: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Default limit of body validated by input schema if maximum payload is not set
+const DefaultSchemaPayload = 1024 * 1024
+
+// Request rejected because body doesn't match input schema (see Manifest.InputSchema)
+var ErrInvalidPayload = errors.New("payload doesn't match input schema")
+
+// location of input schema resource: references to other documents are not allowed
+const inputSchemaURL = "manifest:///input_schema.json"
+
+// Compiled JSON Schema of request payload (see Manifest.InputSchema)
+type InputSchema struct {
+	schema *jsonschema.Schema
+}
+
+// Problem of request payload by input schema.
+type PayloadError struct {
+	Path    string `json:"path"`    // JSON pointer of invalid value, empty - the whole document
+	Message string `json:"message"` // description of problem
+}
+
+// CompileInputSchema of manifest. Nil if manifest has no input schema. Remote references are not allowed
+func (mf *Manifest) CompileInputSchema() (*InputSchema, error) {
+	if len(mf.InputSchema) == 0 {
+		return nil, nil
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("references to other documents are not allowed: %s", s)
+	}
+	if err := compiler.AddResource(inputSchemaURL, bytes.NewReader(mf.InputSchema)); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(inputSchemaURL)
+	if err != nil {
+		return nil, err
+	}
+	return &InputSchema{schema: schema}, nil
+}
+
+// Validate JSON document. Returns problems of payload ordered by path, empty if document is valid
+func (is *InputSchema) Validate(data []byte) []PayloadError {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return []PayloadError{{Message: "invalid JSON: " + err.Error()}}
+	}
+	if decoder.More() {
+		return []PayloadError{{Message: "invalid JSON: unexpected data after document"}}
+	}
+	err := is.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var invalid *jsonschema.ValidationError
+	if !errors.As(err, &invalid) {
+		return []PayloadError{{Message: err.Error()}}
+	}
+	var ans []PayloadError
+	var leaves func(ve *jsonschema.ValidationError)
+	leaves = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			ans = append(ans, PayloadError{Path: ve.InstanceLocation, Message: ve.Message})
+		}
+		for _, cause := range ve.Causes {
+			leaves(cause)
+		}
+	}
+	leaves(invalid)
+	// causes of properties are in random order
+	sort.SliceStable(ans, func(i, j int) bool {
+		return ans[i].Path < ans[j].Path
+	})
+	return ans
+}
+
+// Applies to request: body with JSON Content-Type. GET and HEAD requests without body are not validated
+func (is *InputSchema) Applies(req *Request) bool {
+	if is == nil || !IsJSON(req.Headers["Content-Type"]) {
+		return false
+	}
+	return !((req.Method == http.MethodGet || req.Method == http.MethodHead) && !req.hasBody())
+}
+
+// IsJSON checks that content type is JSON: application/json or any type with +json suffix (ex:
+// application/problem+json)
+func IsJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
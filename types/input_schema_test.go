@@ -0,0 +1,76 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "items"],
+	"properties": {
+		"id": {"type": "integer"},
+		"items": {"type": "array", "items": {"$ref": "#/$defs/item"}, "minItems": 1}
+	},
+	"$defs": {
+		"item": {"type": "string", "maxLength": 8}
+	}
+}`
+
+func TestManifest_CompileInputSchema(t *testing.T) {
+	var mf types.Manifest
+	schema, err := mf.CompileInputSchema()
+	require.NoError(t, err)
+	assert.Nil(t, schema)
+
+	mf.InputSchema = []byte(orderSchema)
+	schema, err = mf.CompileInputSchema()
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.Validate([]byte(`{"id": 1, "items": ["book"]}`)))
+	problems := schema.Validate([]byte(`{"id": 1.5, "items": ["notebooks"]}`))
+	assert.Equal(t, []types.PayloadError{
+		{Path: "/id", Message: "expected integer, but got number"},
+		{Path: "/items/0", Message: "length must be <= 8, but got 9"},
+	}, problems)
+	problems = schema.Validate([]byte(`{"id": 1`))
+	require.Len(t, problems, 1)
+	assert.Empty(t, problems[0].Path)
+	assert.Contains(t, problems[0].Message, "invalid JSON")
+	assert.Len(t, schema.Validate([]byte(`{"id": 1, "items": ["a"]} {}`)), 1, "single document")
+}
+
+func TestManifest_ValidateInputSchema(t *testing.T) {
+	mf := types.Manifest{Run: []string{"cat"}, InputSchema: []byte(`{"type": "unknown"}`)}
+	err := mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "input_schema: invalid input schema")
+	}
+	mf.InputSchema = []byte(`{"$ref": "https://example.com/schema.json"}`)
+	err = mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "references to other documents are not allowed")
+	}
+	mf.InputSchema = []byte(orderSchema)
+	assert.NoError(t, mf.Validate())
+}
+
+func TestInputSchema_Applies(t *testing.T) {
+	mf := types.Manifest{InputSchema: []byte(orderSchema)}
+	schema, err := mf.CompileInputSchema()
+	require.NoError(t, err)
+	request := func(method, contentType, length string) *types.Request {
+		return &types.Request{Method: method, Headers: map[string]string{"Content-Type": contentType, "Content-Length": length}}
+	}
+	assert.True(t, schema.Applies(request("POST", "application/json; charset=utf-8", "10")))
+	assert.True(t, schema.Applies(request("PUT", "application/merge-patch+json", "10")))
+	assert.False(t, schema.Applies(request("POST", "text/plain", "10")), "other content types are not validated")
+	assert.False(t, schema.Applies(request("GET", "application/json", "")), "GET without body")
+	assert.True(t, schema.Applies(request("GET", "application/json", "10")))
+	var empty *types.InputSchema
+	assert.False(t, empty.Applies(request("POST", "application/json", "10")))
+}
@@ -0,0 +1,220 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Default variable with subject (sub claim) of verified token
+const DefaultJWTSubjectEnv = "REMOTE_USER"
+
+// Default time of caching of JWKS
+const DefaultJWKSRefresh = 10 * time.Minute
+
+// ErrTokenInvalid means that bearer token is missing, not signed by accepted key or its claims are not accepted
+var ErrTokenInvalid = errors.New("invalid bearer token")
+
+// JWTPolicy is verification of JWT in Authorization: Bearer header. Requests without valid token are rejected with
+// 401 before invocation. Exactly one source of key should be defined: secret (HS*), PEM public key (RS*, PS*, ES*,
+// EdDSA) or URL of JSON Web Key Set
+type JWTPolicy struct {
+	Issuers    []string          `json:"issuers,omitempty"`     // accepted issuers (iss), empty - any
+	Audiences  []string          `json:"audiences,omitempty"`   // accepted audiences (aud), token should have one of them; empty - any
+	Secret     string            `json:"secret,omitempty"`      // HMAC secret, could reference variable of environment as ${NAME}
+	PublicKey  string            `json:"public_key,omitempty"`  // PEM encoded public key, could reference variable of environment as ${NAME}
+	JWKS       string            `json:"jwks,omitempty"`        // URL of JSON Web Key Set
+	Refresh    JsonDuration      `json:"refresh,omitempty"`     // time of caching of JWKS (zero - DefaultJWKSRefresh)
+	Leeway     JsonDuration      `json:"leeway,omitempty"`      // tolerance of clock skew for exp, nbf and iat
+	Links      []string          `json:"links,omitempty"`       // protect only requests by the links (aliases), empty - all requests
+	SubjectEnv string            `json:"subject_env,omitempty"` // variable with subject (default DefaultJWTSubjectEnv)
+	Env        map[string]string `json:"env,omitempty"`         // claim => variable of environment
+	Headers    map[string]string `json:"headers,omitempty"`     // claim => request header (replaces header of client)
+}
+
+// Variable with subject of verified token
+func (jp *JWTPolicy) Variable() string {
+	if jp.SubjectEnv == "" {
+		return DefaultJWTSubjectEnv
+	}
+	return jp.SubjectEnv
+}
+
+// Effective time of caching of JWKS
+func (jp *JWTPolicy) RefreshInterval() time.Duration {
+	if jp.Refresh <= 0 {
+		return DefaultJWKSRefresh
+	}
+	return time.Duration(jp.Refresh)
+}
+
+// Protects request which arrived by the link (empty - by UID)
+func (jp *JWTPolicy) Protects(alias string) bool {
+	if len(jp.Links) == 0 {
+		return true
+	}
+	for _, link := range jp.Links {
+		if link == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// Names of selected claims: passed by variables or headers
+func (jp *JWTPolicy) Selected() []string {
+	var names []string
+	var seen = make(map[string]bool)
+	for _, group := range []map[string]string{jp.Env, jp.Headers} {
+		for claim := range group {
+			if !seen[claim] {
+				seen[claim] = true
+				names = append(names, claim)
+			}
+		}
+	}
+	return names
+}
+
+// Verify bearer token by key function (see jwt.Keyfunc) and check registered claims at the moment with leeway
+func (jp *JWTPolicy) Verify(raw string, key jwt.Keyfunc, now time.Time) (jwt.MapClaims, error) {
+	parser := jwt.Parser{SkipClaimsValidation: true, UseJSONNumber: true}
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(raw, claims, key); err != nil {
+		var verr *jwt.ValidationError
+		if errors.As(err, &verr) && verr.Inner != nil {
+			// error of key function is kept for caller
+			return nil, fmt.Errorf("%w: %w", ErrTokenInvalid, verr.Inner)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+	leeway := time.Duration(jp.Leeway)
+	if exp, ok, err := numericClaim(claims, "exp"); err != nil {
+		return nil, err
+	} else if ok && !now.Before(exp.Add(leeway)) {
+		return nil, fmt.Errorf("%w: token is expired", ErrTokenInvalid)
+	}
+	if nbf, ok, err := numericClaim(claims, "nbf"); err != nil {
+		return nil, err
+	} else if ok && now.Add(leeway).Before(nbf) {
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrTokenInvalid)
+	}
+	if iat, ok, err := numericClaim(claims, "iat"); err != nil {
+		return nil, err
+	} else if ok && now.Add(leeway).Before(iat) {
+		return nil, fmt.Errorf("%w: token is issued in future", ErrTokenInvalid)
+	}
+	if len(jp.Issuers) > 0 && !contains(jp.Issuers, ClaimString(claims, "iss")) {
+		return nil, fmt.Errorf("%w: issuer is not accepted", ErrTokenInvalid)
+	}
+	if len(jp.Audiences) > 0 && !jp.acceptsAudience(claims["aud"]) {
+		return nil, fmt.Errorf("%w: audience is not accepted", ErrTokenInvalid)
+	}
+	return claims, nil
+}
+
+func (jp *JWTPolicy) acceptsAudience(value interface{}) bool {
+	switch aud := value.(type) {
+	case string:
+		return contains(jp.Audiences, aud)
+	case []interface{}:
+		for _, item := range aud {
+			if s, ok := item.(string); ok && contains(jp.Audiences, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClaimString is value of claim as text: strings as is, other values as JSON. Empty if claim is not set
+func ClaimString(claims jwt.MapClaims, name string) string {
+	value, ok := claims[name]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: claim %s is not a number", ErrTokenInvalid, name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: claim %s is not a number", ErrTokenInvalid, name)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (jp *JWTPolicy) validate() error {
+	var errs []error
+	var sources int
+	for _, source := range []string{jp.Secret, jp.PublicKey, jp.JWKS} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		errs = append(errs, fmt.Errorf("exactly one of secret, public key or JWKS should be defined"))
+	}
+	for name, value := range map[string]string{"secret": jp.Secret, "public key": jp.PublicKey} {
+		if _, err := expandValue(value, func(string) string { return "" }); err != nil {
+			errs = append(errs, fmt.Errorf("%s of JWT: %w", name, err))
+		}
+	}
+	if jp.JWKS != "" {
+		if u, err := url.Parse(jp.JWKS); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("JWKS should be absolute http(s) URL"))
+		}
+	}
+	if jp.Refresh < 0 || jp.Leeway < 0 {
+		errs = append(errs, fmt.Errorf("refresh and leeway of JWT should not be negative"))
+	}
+	for _, link := range jp.Links {
+		if link == "" {
+			errs = append(errs, fmt.Errorf("empty link of JWT"))
+		}
+	}
+	if strings.ContainsAny(jp.SubjectEnv, "= \t\r\n\x00") {
+		errs = append(errs, fmt.Errorf("invalid variable name %q", jp.SubjectEnv))
+	}
+	for claim, variable := range jp.Env {
+		if variable == "" || strings.ContainsAny(variable, "= \t\r\n\x00") {
+			errs = append(errs, fmt.Errorf("invalid variable name %q of claim %s", variable, claim))
+		}
+	}
+	for claim, header := range jp.Headers {
+		if header == "" || http.CanonicalHeaderKey(header) == "Authorization" || strings.ContainsAny(header, ": \t\r\n") {
+			errs = append(errs, fmt.Errorf("invalid header %q of claim %s", header, claim))
+		}
+	}
+	return errors.Join(errs...)
+}
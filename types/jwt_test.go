@@ -0,0 +1,86 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestJWTPolicy_Verify(t *testing.T) {
+	secret := []byte("top-secret")
+	key := func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}
+	now := time.Now()
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		require.NoError(t, err)
+		return token
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		policy := types.JWTPolicy{Issuers: []string{"https://sso.example.com"}, Audiences: []string{"cgi"}}
+		claims, err := policy.Verify(sign(jwt.MapClaims{
+			"sub":    "alice",
+			"iss":    "https://sso.example.com",
+			"aud":    []string{"other", "cgi"},
+			"exp":    now.Add(time.Minute).Unix(),
+			"groups": []string{"admin"},
+		}), key, now)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", types.ClaimString(claims, "sub"))
+		assert.Equal(t, `["admin"]`, types.ClaimString(claims, "groups"))
+		assert.Equal(t, "", types.ClaimString(claims, "email"))
+	})
+	t.Run("expired", func(t *testing.T) {
+		token := sign(jwt.MapClaims{"sub": "alice", "exp": now.Add(-30 * time.Second).Unix()})
+		policy := types.JWTPolicy{}
+		_, err := policy.Verify(token, key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		policy.Leeway = types.JsonDuration(time.Minute)
+		_, err = policy.Verify(token, key, now)
+		assert.NoError(t, err)
+		_, err = policy.Verify(token, key, now.Add(time.Minute))
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+	})
+	t.Run("not valid yet", func(t *testing.T) {
+		token := sign(jwt.MapClaims{"sub": "alice", "nbf": now.Add(30 * time.Second).Unix(), "iat": now.Add(30 * time.Second).Unix()})
+		policy := types.JWTPolicy{}
+		_, err := policy.Verify(token, key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		policy.Leeway = types.JsonDuration(time.Minute)
+		_, err = policy.Verify(token, key, now)
+		assert.NoError(t, err)
+		_, err = policy.Verify(token, key, now.Add(-time.Minute))
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+	})
+	t.Run("claims", func(t *testing.T) {
+		policy := types.JWTPolicy{Issuers: []string{"https://sso.example.com"}, Audiences: []string{"cgi"}}
+		_, err := policy.Verify(sign(jwt.MapClaims{"iss": "https://evil.example.com", "aud": "cgi"}), key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		_, err = policy.Verify(sign(jwt.MapClaims{"iss": "https://sso.example.com", "aud": "other"}), key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		_, err = policy.Verify(sign(jwt.MapClaims{"iss": "https://sso.example.com"}), key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		_, err = policy.Verify(sign(jwt.MapClaims{"iss": "https://sso.example.com", "aud": "cgi", "exp": "tomorrow"}), key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+	})
+	t.Run("signature", func(t *testing.T) {
+		policy := types.JWTPolicy{}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"}).SignedString([]byte("other-secret"))
+		require.NoError(t, err)
+		_, err = policy.Verify(token, key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		_, err = policy.Verify("not-a-token", key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+		token, err = jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "alice"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = policy.Verify(token, key, now)
+		assert.ErrorIs(t, err, types.ErrTokenInvalid)
+	})
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Types of lifecycle events delivered by webhooks
+const (
+	EventLambdaCreated    = "lambda.created"    // lambda created (empty, from template, from git or imported)
+	EventLambdaUpdated    = "lambda.updated"    // content, manifest, schedules, aliases or state of lambda changed
+	EventLambdaDeleted    = "lambda.deleted"    // lambda removed
+	EventInvocationFailed = "invocation.failed" // invocation failed (not rejected, not retried attempt)
+	EventScheduleMissed   = "schedule.missed"   // scheduled runs were missed while server was down
+	EventDeployFailed     = "deploy.failed"     // fetch or build of commit of tracked git branch failed
+)
+
+// All types of lifecycle events
+var LifecycleEvents = []string{EventLambdaCreated, EventLambdaUpdated, EventLambdaDeleted, EventInvocationFailed, EventScheduleMissed, EventDeployFailed}
+
+// LifecycleWebhook is receiver of lifecycle events as signed JSON POSTs. Webhook of server receives events of all
+// lambdas, webhook of lambda (see Manifest.EventWebhooks) - only events of the lambda
+type LifecycleWebhook struct {
+	URL     string   `json:"url"`               // http or https URL of receiver
+	Events  []string `json:"events,omitempty"`  // types of events (empty - all)
+	Secret  string   `json:"secret,omitempty"`  // secret of HMAC signature (empty - not signed), could reference variable of environment as ${NAME}
+	Payload bool     `json:"payload,omitempty"` // include request, output and stderr of failed invocation
+}
+
+// Accepts type of event
+func (lw LifecycleWebhook) Accepts(event string) bool {
+	if len(lw.Events) == 0 {
+		return true
+	}
+	for _, name := range lw.Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (lw *LifecycleWebhook) Validate() error {
+	var errs []error
+	if u, err := url.Parse(lw.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("URL of webhook should be absolute http or https URL: %q", lw.URL))
+	}
+	for _, name := range lw.Events {
+		if !knownEvent(name) {
+			errs = append(errs, fmt.Errorf("unknown event %s of webhook", name))
+		}
+	}
+	if _, err := expandValue(lw.Secret, func(string) string { return "" }); err != nil {
+		errs = append(errs, fmt.Errorf("secret of webhook: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func knownEvent(name string) bool {
+	for _, event := range LifecycleEvents {
+		if event == name {
+			return true
+		}
+	}
+	return false
+}
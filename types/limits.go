@@ -0,0 +1,50 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Maximum niceness of process
+const MaxNice = 19
+
+// Resource limits of actions (make targets). Empty values mean not defined.
+type BuildLimits struct {
+	Nice   int     `json:"nice,omitempty"`   // niceness of action processes (1-19)
+	CPU    float64 `json:"cpu,omitempty"`    // CPU cap in cores (ex: 0.5), requires cgroup v2
+	Memory int64   `json:"memory,omitempty"` // memory cap in bytes, requires cgroup v2
+}
+
+// Override returns copy of limits with non-empty values from other limits.
+func (bl BuildLimits) Override(other BuildLimits) BuildLimits {
+	if other.Nice != 0 {
+		bl.Nice = other.Nice
+	}
+	if other.CPU != 0 {
+		bl.CPU = other.CPU
+	}
+	if other.Memory != 0 {
+		bl.Memory = other.Memory
+	}
+	return bl
+}
+
+// Cgroup required to apply limits (CPU or memory cap defined).
+func (bl BuildLimits) Cgroup() bool {
+	return bl.CPU > 0 || bl.Memory > 0
+}
+
+// Validate limits: niceness in range 0-19, caps are not negative.
+func (bl BuildLimits) Validate() error {
+	var errs []error
+	if bl.Nice < 0 || bl.Nice > MaxNice {
+		errs = append(errs, fmt.Errorf("nice %d is out of range (0-%d)", bl.Nice, MaxNice))
+	}
+	if bl.CPU < 0 {
+		errs = append(errs, fmt.Errorf("cpu cap %v is negative", bl.CPU))
+	}
+	if bl.Memory < 0 {
+		errs = append(errs, fmt.Errorf("memory cap %d is negative", bl.Memory))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default maximum size of log file of lambda in MiB
+const DefaultLogFileMB = 10
+
+// Default number of log files of lambda (including current file)
+const DefaultLogFiles = 5
+
+// Persistent log files of lambda: full stdout and stderr of invocations (line by line with time, request ID and
+// stream) are kept in files rotated by size out of lambda content. Output could contain sensitive data, so output is
+// kept only if lambda opted in
+type LogFiles struct {
+	Enabled   bool `json:"enabled"`
+	MaxSizeMB int  `json:"max_size_mb,omitempty"` // size of file after which the next file is started (zero - DefaultLogFileMB)
+	MaxFiles  int  `json:"max_files,omitempty"`   // number of the newest files kept, including current (zero - DefaultLogFiles)
+}
+
+// Maximum size of log file in bytes
+func (lf *LogFiles) MaxSize() int64 {
+	if lf.MaxSizeMB <= 0 {
+		return DefaultLogFileMB * 1024 * 1024
+	}
+	return int64(lf.MaxSizeMB) * 1024 * 1024
+}
+
+// Number of kept log files
+func (lf *LogFiles) Files() int {
+	if lf.MaxFiles <= 0 {
+		return DefaultLogFiles
+	}
+	return lf.MaxFiles
+}
+
+func (lf *LogFiles) validate() error {
+	var errs []error
+	if lf.MaxSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("maximum size of log file should not be negative"))
+	}
+	if lf.MaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("number of log files should not be negative"))
+	}
+	return errors.Join(errs...)
+}
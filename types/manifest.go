@@ -2,76 +2,789 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron"
+	"golang.org/x/net/http/httpguts"
 )
 
+// Alias (link) name limitations
+var AliasNameReg = regexp.MustCompile("^[a-zA-Z0-9._-]{1,255}$")
+
+// Label limitations: up to 64 characters without spaces and commas
+var LabelReg = regexp.MustCompile(`^[^\s,]{1,64}$`)
+
 type Manifest struct {
 	Name           string            `json:"name,omitempty"`            // information field
 	Description    string            `json:"description,omitempty"`     // information field
+	Labels         []string          `json:"labels,omitempty"`          // information field: free-form labels for search (ex: jira, sync)
 	Run            []string          `json:"run"`                       // command to run
 	OutputHeaders  map[string]string `json:"output_headers,omitempty"`  // output headers
+	ParseHeaders   bool              `json:"parse_headers,omitempty"`   // parse CGI headers (and Status) from the beginning of output
 	InputHeaders   map[string]string `json:"input_headers,omitempty"`   // headers to map from request to environment
 	Query          map[string]string `json:"query,omitempty"`           // map query or form parameters to environment
-	Environment    map[string]string `json:"environment,omitempty"`     // custom environment
-	Method         string            `json:"method,omitempty"`          // restrict invoke only to the HTTP method
+	QueryToBody    bool              `json:"query_to_body,omitempty"`   // pass query parameters as JSON object to stdin if body is empty
+	Environment    map[string]string `json:"environment,omitempty"`     // custom environment (values could reference other variables as ${NAME})
+	Method         string            `json:"method,omitempty"`          // restrict invoke only to the HTTP method (deprecated, see Methods)
 	MethodEnv      string            `json:"method_env,omitempty"`      // map method name to environment
 	PathEnv        string            `json:"path_env,omitempty"`        // map requested path to environment
+	PublicURLEnv   string            `json:"public_url_env,omitempty"`  // map public base URL of server to environment
+	AliasEnv       string            `json:"alias_env,omitempty"`       // map link (alias) under which request arrived to environment
+	DeadlineEnv    string            `json:"deadline_env,omitempty"`    // map deadline of invocation (RFC 3339, if time limited) to environment
 	TimeLimit      JsonDuration      `json:"time_limit,omitempty"`      // time limit to run (zero is infinity)
 	MaximumPayload int64             `json:"maximum_payload,omitempty"` // limit incoming payload (zero is unlimited)
-	Cron           []Schedule        `json:"cron,omitempty"`            // crontab expression and action name to invoke
+	Cron           []Schedule        `json:"cron,omitempty"`            // crontab expression and action name or payload to invoke
+	Actions        map[string][]Step `json:"actions,omitempty"`         // actions by name: steps to run instead of Makefile target of the same name
+	Aliases        []string          `json:"aliases,omitempty"`         // aliases (links) bound to lambda when manifest is applied
 	Static         string            `json:"static,omitempty"`          // relative path to static folder
+	Umask          string            `json:"umask,omitempty"`           // file mode creation mask in octal (overrides server default)
+	Lang           string            `json:"lang,omitempty"`            // default LANG (overrides server default)
+	LcAll          string            `json:"lc_all,omitempty"`          // default LC_ALL (overrides server default)
+	TZ             string            `json:"tz,omitempty"`              // default TZ (overrides server default)
+	Sampling       *Sampling         `json:"sampling,omitempty"`        // sampling of detailed invocation records (stats)
+	OnStart        *Startup          `json:"on_start,omitempty"`        // action to run once on server start and after upload
+	Coalesce       *Coalescing       `json:"coalesce,omitempty"`        // share response of concurrent identical requests
+	Cache          *Caching          `json:"cache,omitempty"`           // serve successful responses of identical requests from memory
+	RateLimit      *RateLimit        `json:"rate_limit,omitempty"`      // limit rate of requests per lambda or per client (token bucket)
+	// accepted media types of request body (type/* matches any subtype), empty - any. Requests with other
+	// Content-Type are rejected with 415 without invocation
+	AcceptedContentTypes []string  `json:"accepted_content_types,omitempty"`
+	AllowNoContentType   bool      `json:"allow_no_content_type,omitempty"` // accept requests with body but without Content-Type (if accepted_content_types set)
+	RewriteURLs          *Rewrite  `json:"rewrite_urls,omitempty"`          // replace internal prefix of absolute URLs in text responses by public base URL
+	Secrets              *Secrets  `json:"secrets,omitempty"`               // delivery of secret variables (by default as environment)
+	Alerts               []Alert   `json:"alerts,omitempty"`                // alert rules by error rate, failures in a row or latency
+	Mutate               *Mutation `json:"mutate,omitempty"`                // set headers and fill defaults of JSON body before invocation
+	OnSuccess            *Chaining `json:"on_success,omitempty"`            // enqueue successful output to queue of another lambda
+	// invoke other lambdas (UID or alias) with output of invocation as payload, see ChainStep
+	Then []ChainStep `json:"then,omitempty"`
+	// allowed HTTP methods (case-insensitive), other requests are rejected with 405 without invocation. Empty - any
+	Methods []string `json:"methods,omitempty"`
+	// resource limits of actions (post-clone, on_start, scheduled and manual), overrides server defaults
+	BuildLimits *BuildLimits `json:"build_limits,omitempty"`
+	// resource limits (memory, CPU, open files, processes) of invocations, workers and actions
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	// limit of size of lambda directory in MiB (zero - not limited): uploads and actions which exceed the quota are
+	// rejected, invocations fail while the directory is over the quota
+	DiskQuotaMB int64 `json:"disk_quota_mb,omitempty"`
+	// serialization of build actions with invocations, not set - action build is serialized with default timeout
+	BuildGate *BuildGate `json:"build_gate,omitempty"`
+	// HTTP status by non-zero exit code of process, output is sent as body. Response is buffered till exit.
+	// Unmapped non-zero exit code is 500, Status header (see ParseHeaders) wins over the mapping
+	StatusMap map[int]int `json:"status_map,omitempty"`
+	// static files served by server without invocation for GET and HEAD: URL prefix inside lambda (ex: /assets/) to
+	// directory inside lambda. Hidden files and manifest are never served
+	StaticDirs map[string]string `json:"static_dirs,omitempty"`
+	// response headers removed after all others (also headers of output and static files), ex: X-Powered-By
+	RemoveHeaders []string `json:"remove_headers,omitempty"`
+	// limit output of lambda (including CGI headers), process is killed if exceeded (zero is unlimited). Output of
+	// limited lambda is streamed, only the beginning is held to answer overrun of short output by status
+	MaximumResponse int64 `json:"maximum_response,omitempty"`
+	// policy of output over maximum_response: fail (default) - respond 502, truncate - send output cut at the limit
+	TruncatePolicy string `json:"truncate_policy,omitempty"`
+	// warning thresholds of maximum_payload and maximum_response: bigger invocations are served but reported
+	SoftLimits *SoftLimits `json:"soft_limits,omitempty"`
+	// working directory of invocations and actions relative to the lambda directory (empty - lambda directory)
+	WorkDir string `json:"work_dir,omitempty"`
+	// expand references ${NAME} and ${NAME:-default} in run and work_dir by environment of lambda (server, global and
+	// manifest variables) at invocation: unset variable without default fails the invocation. $$ is escaped $
+	ExpandRun bool `json:"expand_run,omitempty"`
+	// maximum concurrent invocations by HTTP (zero - unlimited), excess requests are handled by overflow policy
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// policy of requests over max_concurrency: wait for free slot (default, bounded by time limit) or reject with 429
+	OverflowPolicy string `json:"overflow_policy,omitempty"`
+	// CORS policy for browser front-ends: preflight is answered by server, Access-Control-* headers are attached only
+	// for allowed origins. Empty - any origin is allowed
+	CORS *CORS `json:"cors,omitempty"`
+	// JSON Schema of request body with JSON Content-Type: invalid requests are rejected with 422 and problems as JSON
+	// array without invocation. Other content types are not validated
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+	// network access of invocations and actions (nil - by security profile of server, allowed by default)
+	Network *bool `json:"network,omitempty"`
+	// content of lambda is not writable by invocations and actions, requires user of server to run lambdas (nil - by
+	// security profile of server, writable by default)
+	ReadOnly *bool `json:"read_only,omitempty"`
+	// account to run invocations and actions instead of user of server, allowed by security profile (nil - user of
+	// server). Files of lambda belong to the account
+	RunAs *RunAs `json:"run_as,omitempty"`
+	// streaming mode of output: empty - regular response, sse - Server-Sent Events passthrough (text/event-stream,
+	// flushed after every line, connection is kept open till the process exits)
+	Streaming string `json:"streaming,omitempty"`
+	// time limit of streaming (sse) invocation instead of time_limit (zero - time_limit)
+	StreamTimeLimit JsonDuration `json:"stream_time_limit,omitempty"`
+	// close streaming (sse) invocation if lambda emits nothing for the interval (zero - not limited)
+	StreamIdleTimeout JsonDuration `json:"stream_idle_timeout,omitempty"`
+	// interval of re-evaluation of access policy during streaming (sse) invocation: stream is closed as soon as
+	// access is lost (ex: token revoked, zero - checked only before invocation)
+	StreamReauthorize JsonDuration `json:"stream_reauthorize,omitempty"`
+	// run invocations in container by Docker compatible engine instead of native process (nil - native process)
+	Container *Container `json:"container,omitempty"`
+	// invocation mode: empty or exec - process per request, worker - long-running processes receive requests as
+	// newline-delimited JSON over stdin and reply by stdout (restarted if exited or not replied in time limit)
+	Mode string `json:"mode,omitempty"`
+	// maximum number of worker processes (zero - one), requests over the pool wait for free worker
+	Workers int `json:"workers,omitempty"`
+	// time between SIGTERM and SIGKILL of process group when invocation or action is terminated: time limit, client
+	// is gone, server shutdown (zero - DefaultGracePeriod). Bounded by security profile
+	GracePeriod JsonDuration `json:"grace_period,omitempty"`
+	// spool request body to temporary file (path in REQUEST_BODY_FILE) instead of stdin, file is removed after
+	// the process exits. Body is limited by maximum_file_payload instead of maximum_payload
+	PayloadAsFile bool `json:"payload_as_file,omitempty"`
+	// limit of request body spooled to file (zero - DefaultMaximumFilePayload)
+	MaximumFilePayload int64 `json:"maximum_file_payload,omitempty"`
+	// decode multipart/form-data body to JSON object on stdin: text fields as strings, file parts as temporary files
+	// (see FormFile) limited by maximum_file_payload in total. Other bodies are passed as is
+	DecodeMultipart bool `json:"decode_multipart,omitempty"`
+	// limit of one file part of decoded multipart body (zero - maximum_file_payload)
+	MaximumFormFile int64 `json:"maximum_form_file,omitempty"`
+	// decode application/x-www-form-urlencoded body to JSON object on stdin: values as strings, values of repeated
+	// keys as arrays. Original body is limited by maximum_payload. Other bodies are passed as is
+	DecodeForm bool `json:"decode_form,omitempty"`
+	// map path of temporary file with original body of decoded form to environment (ex: to check signature)
+	RawFormEnv string `json:"raw_form_env,omitempty"`
+	// send output as is regardless of Accept-Encoding of request (by default output is compressed by gzip or deflate)
+	DisableCompression bool `json:"disable_compression,omitempty"`
+	// at most one queued execution of lambda at a time (from all linked queues), executions of other lambdas proceed
+	// in parallel. Invocations by HTTP are not affected (see MaxConcurrency)
+	QueueSerial bool `json:"queue_serial,omitempty"`
+	// retry policy of failed queued and scheduled invocations with exponential backoff, exhausted requests are kept
+	// as dead letters. Invocations by HTTP are never retried (nil - single attempt, queues use their own retries)
+	Retry *Retry `json:"retry,omitempty"`
+	// availability checks (one line - one command, like checks of templates) run in working directory by health
+	// report of lambdas: non-zero exit code means that lambda is unavailable (ex: ["python3", "-c", "import requests"])
+	Check [][]string `json:"check,omitempty"`
+	// rebuild environment by warm-up on server start (see --warm-up.enabled): install action of manifest or Makefile
+	// (or build if install is not defined) is run if lambda is unavailable by checks (ex: interpreter of venv is missing)
+	WarmUp bool `json:"warm_up,omitempty"`
+	// file or directory inside lambda (ex: node_modules) which absence means that environment is absent: lambda is
+	// unavailable until it's created (empty - only checks)
+	WarmUpMarker string `json:"warm_up_marker,omitempty"`
+	// keep the newest requests by HTTP with headers and payloads for debugging and replay by API (nil - not kept)
+	Capture *Capture `json:"capture,omitempty"`
+	// keep full stdout and stderr of invocations in log files rotated by size (nil - output is not kept)
+	Logs *LogFiles `json:"logs,omitempty"`
+	// networks of clients (CIDR, IPv4 or IPv6) allowed to invoke lambda: requests from other addresses are rejected
+	// with 403 before invocation. Applied together with networks of policy. Empty - any client
+	AllowedNetworks []string `json:"allowed_networks,omitempty"`
+	// verification of HMAC (or token) of webhook provider over raw body: requests with invalid signature are rejected
+	// with 401 before invocation (nil - not verified)
+	WebhookSignature *WebhookSignature `json:"webhook_signature,omitempty"`
+	// username and password (HTTP basic authentication) of requests by HTTP: requests without valid credentials are
+	// answered by 401 with challenge before invocation (nil - not required)
+	BasicAuth *BasicAuth `json:"basic_auth,omitempty"`
+	// verification of JWT in Authorization: Bearer header of requests by HTTP: requests without valid token are
+	// rejected with 401 before invocation (nil - not required)
+	JWT *JWTPolicy `json:"jwt,omitempty"`
+	// access settings (allowed networks, methods, basic auth, JWT, rate limit) of requests by alias: alias => policy
+	// which overrides settings of lambda. Requests by UID and by aliases without policy use settings of lambda
+	AliasPolicies map[string]*AliasPolicy `json:"alias_policies,omitempty"`
+	// receivers of lifecycle events of lambda (changes, failed invocations, missed schedules) in addition to
+	// webhooks of server
+	EventWebhooks []LifecycleWebhook `json:"event_webhooks,omitempty"`
+	// branch of repository tracked by lambda: files are replaced by new commits of branch (nil - not tracked)
+	Git *GitSource `json:"git,omitempty"`
 }
 
+// Scheduled action (make target) or, without action, scheduled invocation of lambda with payload. Schedules are
+// declared only in manifest: scheduler always runs the current set of entries
 type Schedule struct {
-	Cron      string       `json:"cron"`       // crontab expression
-	Action    string       `json:"action"`     // action to invoke
-	TimeLimit JsonDuration `json:"time_limit"` // time limit to execute
+	Name        string       `json:"name,omitempty"`         // optional unique name of entry
+	Cron        string       `json:"cron"`                   // crontab expression
+	Action      string       `json:"action,omitempty"`       // action to invoke (empty - invoke lambda)
+	Payload     string       `json:"payload,omitempty"`      // body of invocation
+	PayloadFile string       `json:"payload_file,omitempty"` // file inside lambda with body of invocation
+	TimeLimit   JsonDuration `json:"time_limit"`             // time limit to execute
+	Disabled    bool         `json:"disabled,omitempty"`     // temporary disable schedule
+	Missed      string       `json:"missed,omitempty"`       // policy of missed runs: run (default), skip, run_once or run_all
+	TZ          string       `json:"tz,omitempty"`           // IANA time zone of cron expression (ex: Europe/Berlin), empty - local time of server
+}
+
+// Header with name (or cron expression) of schedule in scheduled invocation
+const ScheduleHeader = "X-Schedule"
+
+// Header of catch-up invocation (and variable of catch-up action) with occurrence missed during downtime (RFC3339)
+const (
+	CatchUpHeader = "X-Catch-Up"
+	CatchUpEnv    = "CATCH_UP"
+)
+
+// Maximum number of catch-up runs of schedule with run_all policy
+const MaxCatchUpRuns = 24
+
+// Policies of missed scheduled runs. Runs missed by forward clock jump, suspend of the host or DST spring forward are
+// run once as soon as detected (except skip). Runs missed while server was down are skipped (except catch-up
+// policies)
+const (
+	MissedRun     = "run"      // run once as soon as jump detected, skip runs missed during downtime
+	MissedSkip    = "skip"     // wait for the next fire time
+	MissedRunOnce = "run_once" // as run, plus single catch-up run on start if any run was missed during downtime
+	MissedRunAll  = "run_all"  // as run, plus catch-up run on start per run missed during downtime (up to MaxCatchUpRuns)
+)
+
+// Missed runs should be skipped
+func (sc Schedule) SkipMissed() bool {
+	return sc.Missed == MissedSkip
+}
+
+// Location of cron expression: time zone of entry or local time of server. Loaded locations are cached
+func (sc Schedule) Location() (*time.Location, error) {
+	if sc.TZ == "" {
+		return time.Local, nil
+	}
+	if location, ok := locations.Load(sc.TZ); ok {
+		return location.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(sc.TZ)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(sc.TZ, location)
+	return location, nil
+}
+
+var locations sync.Map // name -> *time.Location
+
+// Maximum number of catch-up runs missed during downtime by policy (zero - not caught up)
+func (sc Schedule) CatchUpLimit() int {
+	switch sc.Missed {
+	case MissedRunOnce:
+		return 1
+	case MissedRunAll:
+		return MaxCatchUpRuns
+	default:
+		return 0
+	}
+}
+
+// Entry invokes the lambda instead of action
+func (sc Schedule) Invocation() bool {
+	return sc.Action == ""
+}
+
+// Name of entry or, if not set, cron expression with action
+func (sc Schedule) Label() string {
+	if sc.Name != "" {
+		return sc.Name
+	}
+	if sc.Invocation() {
+		return sc.Cron
+	}
+	return sc.Cron + " " + sc.Action
+}
+
+// Policies of requests over concurrency limit
+const (
+	OverflowWait   = "wait"   // wait for free slot not longer than time limit
+	OverflowReject = "reject" // reject immediately with 429 Too Many Requests
+)
+
+// Policies of output over maximum response
+const (
+	TruncateFail = "fail"     // respond 502 Bad Gateway
+	TruncateCut  = "truncate" // send output cut at the limit with X-Truncated header
+)
+
+// Output over maximum response should be sent truncated
+func (mf *Manifest) TruncateResponse() bool {
+	return mf.TruncatePolicy == TruncateCut
+}
+
+// Failure policies of startup action
+const (
+	OnFailureIgnore   = "ignore"   // log error and serve as usual
+	OnFailureDegraded = "degraded" // mark lambda as degraded (blocking lambda rejects requests)
+)
+
+// Startup action executed once when the server starts and after each upload.
+type Startup struct {
+	Action    string       `json:"action"`               // action to invoke
+	TimeLimit JsonDuration `json:"time_limit,omitempty"` // time limit to execute (zero is infinity)
+	Blocking  bool         `json:"blocking,omitempty"`   // requests are waiting until action finished
+	OnFailure string       `json:"on_failure,omitempty"` // failure policy: ignore (default) or degraded
+}
+
+// Coalescing of concurrent identical requests: requests with the same key wait for the first invocation and share
+// its response. Key is method, URL, body and selected headers.
+type Coalescing struct {
+	MaxWaiters int      `json:"max_waiters,omitempty"` // maximum requests waiting for one invocation (zero - unlimited), others are invoked separately
+	Headers    []string `json:"headers,omitempty"`     // request headers included to the key
+}
+
+// Default limit of cached responses of lambda (see Caching.MaxEntries)
+const DefaultCacheEntries = 100
+
+// Caching of successful (2xx) responses: identical requests are served from memory without invocation till TTL is
+// expired or lambda is changed. Key is method, URL, body and selected headers. The least recently used responses are
+// evicted over the limit.
+type Caching struct {
+	TTL        JsonDuration `json:"ttl"`                   // time to keep response
+	MaxEntries int          `json:"max_entries,omitempty"` // maximum cached responses (zero - DefaultCacheEntries)
+	Vary       []string     `json:"vary,omitempty"`        // request headers included to the key
+}
+
+// Limit of cached responses: maximum entries or default
+func (c *Caching) Limit() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return DefaultCacheEntries
 }
 
+// Rate limit of requests by token bucket: bucket of Burst tokens is refilled by RPS tokens per second, request without
+// token is rejected with 429. Bucket is shared by all clients of lambda or (PerClient) kept per client IP.
+type RateLimit struct {
+	RPS       float64 `json:"rps"`                  // average allowed requests per second
+	Burst     int     `json:"burst,omitempty"`      // maximum requests at once (zero - RPS rounded up, at least 1)
+	PerClient bool    `json:"per_client,omitempty"` // separate bucket per client IP (by X-Forwarded-For behind proxy)
+}
+
+// Capacity of bucket: burst or RPS rounded up (at least 1)
+func (rl *RateLimit) Capacity() float64 {
+	if rl.Burst > 0 {
+		return float64(rl.Burst)
+	}
+	return math.Max(1, math.Ceil(rl.RPS))
+}
+
+// Default limit of response size for URL rewriting
+const DefaultRewriteSize = 1024 * 1024
+
+// Rewrite of absolute URLs in response: internal prefix is replaced by public base URL of request. Only text
+// responses not bigger than limit are rewritten, streaming and binary responses are sent as is.
+type Rewrite struct {
+	Prefix  string `json:"prefix"`             // internal prefix of URLs (ex: http://127.0.0.1:3434)
+	MaxSize int64  `json:"max_size,omitempty"` // maximum size of response to rewrite (zero - DefaultRewriteSize)
+}
+
+// Effective size limit of rewritten response
+func (rw *Rewrite) Limit() int64 {
+	if rw.MaxSize == 0 {
+		return DefaultRewriteSize
+	}
+	return rw.MaxSize
+}
+
+// Sampling of detailed invocation records. Errors, slow invocations and soft limit warnings are always kept.
+type Sampling struct {
+	Rate int          `json:"rate,omitempty"` // keep 1-in-N successful invocations (0 or 1 - keep all)
+	Slow JsonDuration `json:"slow,omitempty"` // always keep invocations slower than threshold (zero - disabled)
+}
+
+// Validate manifest. All found problems are reported as *ValidationError with JSON paths of fields.
 func (mf *Manifest) Validate() error {
-	for _, entry := range mf.Cron {
+	var errs fieldErrors
+	for i, arg := range mf.Run {
+		if arg == "" {
+			errs.addf(fmt.Sprintf("run[%d]", i), "empty argument of run command")
+		} else if mf.ExpandRun {
+			errs.add(fmt.Sprintf("run[%d]", i), ValidateArgument(arg))
+		}
+	}
+	if mf.ExpandRun {
+		errs.add("work_dir", ValidateArgument(mf.WorkDir))
+	}
+	errs.add("umask", mf.Runtime().Validate())
+	if mf.TimeLimit < 0 {
+		errs.addf("time_limit", "time limit should not be negative")
+	}
+	if mf.GracePeriod < 0 {
+		errs.addf("grace_period", "grace period should not be negative")
+	}
+	if mf.MaximumPayload < 0 {
+		errs.addf("maximum_payload", "maximum payload should not be negative")
+	}
+	if mf.DiskQuotaMB < 0 {
+		errs.addf("disk_quota_mb", "disk quota should not be negative")
+	}
+	if mf.MaximumFilePayload < 0 {
+		errs.addf("maximum_file_payload", "maximum file payload should not be negative")
+	}
+	if mf.MaximumFormFile < 0 {
+		errs.addf("maximum_form_file", "maximum form file should not be negative")
+	}
+	if mf.MaximumResponse < 0 {
+		errs.addf("maximum_response", "maximum response should not be negative")
+	}
+	switch mf.TruncatePolicy {
+	case "", TruncateFail, TruncateCut:
+	default:
+		errs.addf("truncate_policy", "unknown truncate policy %s", mf.TruncatePolicy)
+	}
+	if mf.SoftLimits != nil {
+		errs.add("soft_limits", mf.SoftLimits.validate(mf))
+	}
+	if mf.Retry != nil {
+		errs.add("retry", mf.Retry.validate())
+	}
+	if mf.Capture != nil {
+		errs.add("capture", mf.Capture.validate())
+	}
+	if mf.Logs != nil {
+		errs.add("logs", mf.Logs.validate())
+	}
+	if _, err := ParseNetworks(mf.AllowedNetworks); err != nil {
+		errs.add("allowed_networks", err)
+	}
+	if mf.WebhookSignature != nil {
+		errs.add("webhook_signature", mf.WebhookSignature.validate())
+	}
+	if mf.BasicAuth != nil {
+		errs.add("basic_auth", mf.BasicAuth.validate())
+	}
+	if mf.Git != nil {
+		errs.add("git", mf.Git.validate())
+	}
+	if mf.JWT != nil {
+		errs.add("jwt", mf.JWT.validate())
+	}
+	if mf.JWT != nil && mf.BasicAuth != nil {
+		// both use Authorization header
+		errs.addf("jwt", "basic auth and JWT could not be used together")
+	}
+	for i, check := range mf.Check {
+		if len(check) == 0 || check[0] == "" {
+			errs.addf(fmt.Sprintf("check[%d]", i), "empty command of check")
+		}
+	}
+	if mf.Sampling != nil && mf.Sampling.Rate < 0 {
+		errs.addf("sampling.rate", "sampling rate should not be negative")
+	}
+	if mf.OnStart != nil {
+		if mf.OnStart.Action == "" {
+			errs.addf("on_start.action", "on_start action is not defined")
+		}
+		if mf.OnStart.TimeLimit < 0 {
+			errs.addf("on_start.time_limit", "on_start time limit should not be negative")
+		}
+		switch mf.OnStart.OnFailure {
+		case "", OnFailureIgnore, OnFailureDegraded:
+		default:
+			errs.addf("on_start.on_failure", "unknown on_start failure policy %s", mf.OnStart.OnFailure)
+		}
+	}
+	if mf.Coalesce != nil && mf.Coalesce.MaxWaiters < 0 {
+		errs.addf("coalesce.max_waiters", "coalesce max waiters should not be negative")
+	}
+	if mf.Cache != nil {
+		if mf.Cache.TTL <= 0 {
+			errs.addf("cache.ttl", "cache TTL should be positive")
+		}
+		if mf.Cache.MaxEntries < 0 {
+			errs.addf("cache.max_entries", "cache max entries should not be negative")
+		}
+	}
+	if mf.RateLimit != nil {
+		if !(mf.RateLimit.RPS > 0) || math.IsInf(mf.RateLimit.RPS, 0) {
+			errs.addf("rate_limit.rps", "rate limit RPS should be positive")
+		}
+		if mf.RateLimit.Burst < 0 {
+			errs.addf("rate_limit.burst", "rate limit burst should not be negative")
+		}
+	}
+	if mf.RewriteURLs != nil {
+		if u, err := url.Parse(mf.RewriteURLs.Prefix); err != nil || u.Scheme == "" || u.Host == "" {
+			errs.addf("rewrite_urls.prefix", "rewrite prefix should be absolute URL")
+		}
+		if mf.RewriteURLs.MaxSize < 0 {
+			errs.addf("rewrite_urls.max_size", "rewrite max size should not be negative")
+		}
+	}
+	if mf.MaxConcurrency < 0 {
+		errs.addf("max_concurrency", "max concurrency should not be negative")
+	}
+	switch mf.OverflowPolicy {
+	case "", OverflowWait, OverflowReject:
+	default:
+		errs.addf("overflow_policy", "unknown overflow policy %s", mf.OverflowPolicy)
+	}
+	switch mf.Streaming {
+	case "", StreamingSSE:
+	default:
+		errs.addf("streaming", "unknown streaming mode %s", mf.Streaming)
+	}
+	if mf.StreamTimeLimit < 0 {
+		errs.addf("stream_time_limit", "stream time limit should not be negative")
+	} else if mf.StreamTimeLimit > 0 && !mf.SSE() {
+		errs.addf("stream_time_limit", "stream time limit requires streaming mode %s", StreamingSSE)
+	}
+	if mf.StreamIdleTimeout < 0 {
+		errs.addf("stream_idle_timeout", "stream idle timeout should not be negative")
+	} else if mf.StreamIdleTimeout > 0 && !mf.SSE() {
+		errs.addf("stream_idle_timeout", "stream idle timeout requires streaming mode %s", StreamingSSE)
+	}
+	if mf.StreamReauthorize < 0 {
+		errs.addf("stream_reauthorize", "interval of re-authorization should not be negative")
+	} else if mf.StreamReauthorize > 0 && !mf.SSE() {
+		errs.addf("stream_reauthorize", "re-authorization requires streaming mode %s", StreamingSSE)
+	}
+	if mf.SSE() {
+		// buffered responses could not be streamed
+		if len(mf.StatusMap) > 0 {
+			errs.addf("streaming", "streaming is not compatible with status_map: response is buffered till exit")
+		}
+		if mf.Coalesce != nil {
+			errs.addf("streaming", "streaming is not compatible with coalesce: shared response is buffered")
+		}
+		if mf.Cache != nil {
+			errs.addf("streaming", "streaming is not compatible with cache: cached response is buffered")
+		}
+		if mf.RewriteURLs != nil {
+			errs.addf("streaming", "streaming is not compatible with rewrite_urls: rewritten response is buffered")
+		}
+	}
+	switch mf.Mode {
+	case "", ModeExec, ModeWorker:
+	default:
+		errs.addf("mode", "unknown invocation mode %s", mf.Mode)
+	}
+	if mf.Workers < 0 {
+		errs.addf("workers", "workers should not be negative")
+	} else if mf.Workers > 0 && !mf.Worker() {
+		errs.addf("workers", "workers require invocation mode %s", ModeWorker)
+	}
+	if mf.Worker() && mf.SSE() {
+		errs.addf("mode", "worker mode is not compatible with streaming: reply of worker is one message")
+	}
+	if mf.Worker() && mf.PayloadAsFile {
+		errs.addf("payload_as_file", "payload as file is not compatible with worker mode: body is passed in message")
+	}
+	if mf.Worker() && mf.DecodeMultipart {
+		errs.addf("decode_multipart", "decoding of multipart is not compatible with worker mode: body is passed in message")
+	}
+	if mf.PayloadAsFile && mf.DecodeMultipart {
+		errs.addf("decode_multipart", "decoding of multipart is not compatible with payload as file")
+	}
+	if mf.PayloadAsFile && mf.DecodeForm {
+		errs.addf("decode_form", "decoding of form is not compatible with payload as file")
+	}
+	if mf.RawFormEnv != "" && !mf.DecodeForm {
+		errs.addf("raw_form_env", "raw form file requires decode_form")
+	}
+	if mf.Worker() && mf.RawFormEnv != "" {
+		errs.addf("raw_form_env", "raw form file is not compatible with worker mode: environment is not set per request")
+	}
+	mf.validateContainer(&errs)
+	errs.add("methods", validateMethods(mf.Methods))
+	if mf.RunAs != nil {
+		errs.add("run_as", mf.RunAs.validate())
+	}
+	if mf.CORS != nil {
+		errs.add("cors", mf.CORS.validate())
+	}
+	if _, err := mf.CompileInputSchema(); err != nil {
+		errs.addf("input_schema", "invalid input schema: %w", err)
+	}
+	errs.add("status_map", validateStatusMap(mf.StatusMap))
+	errs.add("static_dirs", validateStaticDirs(mf.StaticDirs))
+	if mf.WorkDir != "" && !filepath.IsLocal(mf.WorkDir) {
+		errs.addf("work_dir", "work dir %q should be relative path inside lambda", mf.WorkDir)
+	}
+	if mf.WarmUpMarker != "" && !filepath.IsLocal(mf.WarmUpMarker) {
+		errs.addf("warm_up_marker", "warm-up marker %q should be relative path inside lambda", mf.WarmUpMarker)
+	}
+	for i, name := range mf.RemoveHeaders {
+		if !httpguts.ValidHeaderFieldName(name) {
+			errs.addf(fmt.Sprintf("remove_headers[%d]", i), "invalid name of removed header %q", name)
+		}
+	}
+	var labels = make(map[string]bool, len(mf.Labels))
+	for i, label := range mf.Labels {
+		if !LabelReg.MatchString(label) {
+			errs.addf(fmt.Sprintf("labels[%d]", i), "invalid label %q: should be up to 64 characters without spaces and commas", label)
+		} else if labels[label] {
+			errs.addf(fmt.Sprintf("labels[%d]", i), "duplicated label %q", label)
+		}
+		labels[label] = true
+	}
+	errs.add("environment", ValidateEnvironment(mf.Environment))
+	if mf.BuildLimits != nil {
+		errs.add("build_limits", mf.BuildLimits.Validate())
+	}
+	if mf.Limits != nil {
+		errs.add("limits", mf.Limits.Validate())
+	}
+	if mf.BuildGate != nil {
+		errs.add("build_gate", mf.BuildGate.validate())
+	}
+	if mf.Mutate != nil {
+		errs.add("mutate", mf.Mutate.validate())
+	}
+	if mf.OnSuccess != nil {
+		errs.add("on_success", mf.OnSuccess.validate())
+	}
+	if mf.Secrets != nil {
+		errs.add("secrets", mf.Secrets.validate())
+	}
+	for i, step := range mf.Then {
+		errs.add(fmt.Sprintf("then[%d]", i), step.validate())
+	}
+	for i, value := range mf.AcceptedContentTypes {
+		mediaType, err := NormalizeMediaType(value)
+		if err != nil {
+			errs.addf(fmt.Sprintf("accepted_content_types[%d]", i), "accepted content type %q: %w", value, err)
+			continue
+		}
+		mf.AcceptedContentTypes[i] = mediaType
+	}
+	for _, name := range headerNames(mf.OutputHeaders) {
+		if !httpguts.ValidHeaderFieldName(name) {
+			errs.addf("output_headers."+name, "invalid output header name %q", name)
+		} else if !httpguts.ValidHeaderFieldValue(mf.OutputHeaders[name]) {
+			errs.addf("output_headers."+name, "invalid value of output header %s", name)
+		}
+	}
+	for _, name := range headerNames(mf.InputHeaders) {
+		if !httpguts.ValidHeaderFieldName(name) {
+			errs.addf("input_headers."+name, "invalid input header name %q", name)
+		}
+	}
+	var schedules = make(map[string]bool, len(mf.Cron))
+	for i, entry := range mf.Cron {
+		field := fmt.Sprintf("cron[%d]", i)
+		subject := "action " + entry.Action
+		if entry.Invocation() {
+			subject = "invocation " + entry.Label()
+		}
 		if _, err := cron.Parse(entry.Cron); err != nil {
-			return fmt.Errorf("bad cront expression for action %s (%s): %w", entry.Action, entry.Cron, err)
+			errs.addf(field+".cron", "bad cront expression for %s (%s): %w", subject, entry.Cron, err)
+		}
+		if _, err := entry.Location(); err != nil {
+			errs.addf(field+".tz", "unknown time zone %s for %s: %w", entry.TZ, subject, err)
+		}
+		switch entry.Missed {
+		case "", MissedRun, MissedSkip, MissedRunOnce, MissedRunAll:
+		default:
+			errs.addf(field+".missed", "unknown missed runs policy %s for %s", entry.Missed, subject)
+		}
+		if entry.TimeLimit < 0 {
+			errs.addf(field+".time_limit", "time limit of scheduled %s should not be negative", subject)
+		}
+		if entry.Name != "" {
+			if schedules[entry.Name] {
+				errs.addf(field+".name", "duplicated schedule name %s", entry.Name)
+			}
+			schedules[entry.Name] = true
+		}
+		if !entry.Invocation() && (entry.Payload != "" || entry.PayloadFile != "") {
+			errs.addf(field+".payload", "payload of scheduled action %s is not supported: remove action to invoke lambda", entry.Action)
+		}
+		if entry.Payload != "" && entry.PayloadFile != "" {
+			errs.addf(field+".payload_file", "payload and payload file of scheduled %s are mutually exclusive", subject)
+		}
+		if entry.PayloadFile != "" && !filepath.IsLocal(entry.PayloadFile) {
+			errs.addf(field+".payload_file", "payload file %s should be relative path inside lambda", entry.PayloadFile)
 		}
 	}
-	return nil
+	var aliases = make(map[string]bool, len(mf.Aliases))
+	for i, alias := range mf.Aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		if !AliasNameReg.MatchString(alias) {
+			errs.addf(field, "invalid alias %q: should match %s", alias, AliasNameReg.String())
+		} else if aliases[alias] {
+			errs.addf(field, "duplicated alias %s", alias)
+		}
+		aliases[alias] = true
+	}
+	mf.validateActions(&errs)
+	mf.validateAliasPolicies(&errs)
+	for i := range mf.EventWebhooks {
+		errs.add(fmt.Sprintf("event_webhooks[%d]", i), mf.EventWebhooks[i].Validate())
+	}
+	var alerts = make(map[string]bool, len(mf.Alerts))
+	for i := range mf.Alerts {
+		alert := &mf.Alerts[i]
+		field := fmt.Sprintf("alerts[%d]", i)
+		if err := alert.validate(); err != nil {
+			errs.add(field, err)
+		} else if alerts[alert.Name] {
+			errs.addf(field+".name", "duplicated alert %s", alert.Name)
+		}
+		alerts[alert.Name] = true
+	}
+	return errs.err()
 }
 
+// names of headers in lexical order
+func headerNames(values map[string]string) []string {
+	var keys = make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Runtime settings defined by manifest.
+func (mf *Manifest) Runtime() Runtime {
+	return Runtime{
+		Umask: mf.Umask,
+		Lang:  mf.Lang,
+		LcAll: mf.LcAll,
+		TZ:    mf.TZ,
+	}
+}
+
+// SaveAs writes manifest to file in YAML (see IsYAML) or JSON format.
 func (mf *Manifest) SaveAs(filename string) error {
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	if IsYAML(filename) {
+		return mf.encodeYAML(f)
+	}
 	enc := json.NewEncoder(f)
 	enc.SetIndent("", "  ")
 	return enc.Encode(mf)
 }
 
+// LoadFrom reads manifest from file in YAML (see IsYAML) or JSON format.
 func (mf *Manifest) LoadFrom(filename string) error {
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	return json.NewDecoder(f).Decode(mf)
+	decoded, err := DecodeManifest(filename, f)
+	if err != nil {
+		return err
+	}
+	*mf = decoded
+	return nil
 }
 
+// JsonDuration is marshalled as Go duration string (ex: 1m30s). Legacy integer of nanoseconds is accepted as well.
 type JsonDuration time.Duration
 
-func (j *JsonDuration) MarshalJSON() ([]byte, error) {
-	return json.Marshal(time.Duration(*j).String())
+func (j JsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(j).String())
 }
 
-func (j *JsonDuration) UnmarshalJSON(bytes []byte) error {
+func (j *JsonDuration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var nanoseconds int64
+	if err := json.Unmarshal(data, &nanoseconds); err == nil {
+		*j = JsonDuration(nanoseconds)
+		return nil
+	}
 	var str string
-	err := json.Unmarshal(bytes, &str)
-	if err != nil {
-		return err
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("duration should be string (ex: 1m30s) or integer of nanoseconds: %s", data)
+	}
+	if str == "" {
+		*j = 0
+		return nil
 	}
 	v, err := time.ParseDuration(str)
 	if err != nil {
@@ -80,3 +793,21 @@ func (j *JsonDuration) UnmarshalJSON(bytes []byte) error {
 	*j = JsonDuration(v)
 	return nil
 }
+
+func validateStatusMap(statuses map[int]int) error {
+	var codes = make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	var errs []error
+	for _, code := range codes {
+		if code < 1 || code > 255 {
+			errs = append(errs, fmt.Errorf("status map: exit code %d should be between 1 and 255", code))
+		}
+		if status := statuses[code]; status < 200 || status > 599 {
+			errs = append(errs, fmt.Errorf("status map: invalid HTTP status %d for exit code %d", status, code))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,587 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest_Validate(t *testing.T) {
+	manifest := Manifest{
+		Run:           []string{"./app"},
+		OutputHeaders: map[string]string{"Content-Type": "text/plain"},
+		Cron:          []Schedule{{Cron: "@hourly", Action: "update", Missed: MissedSkip}, {Cron: "@daily", Action: "report", Missed: MissedRunAll}},
+	}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.OutputHeaders["Bad Header"] = "x"
+	manifest.OutputHeaders["X-Value"] = "line\nbreak"
+	manifest.Cron = append(manifest.Cron, Schedule{Cron: "bad", Action: "backup"}, Schedule{Cron: "@daily", Action: "clean", Missed: "later"})
+	err := manifest.Validate()
+	var joined interface{ Unwrap() []error }
+	if assert.True(t, errors.As(err, &joined)) {
+		assert.Len(t, joined.Unwrap(), 4, "all problems are reported")
+	}
+	assert.Contains(t, err.Error(), `invalid output header name "Bad Header"`)
+	assert.Contains(t, err.Error(), "invalid value of output header X-Value")
+}
+
+func TestManifest_ValidateFields(t *testing.T) {
+	manifest := Manifest{
+		Run:            []string{"./app", ""},
+		MaximumPayload: -1,
+		DiskQuotaMB:    -1,
+		InputHeaders:   map[string]string{"X Id": "ID"},
+		OutputHeaders:  map[string]string{"Bad Header": "x"},
+		Cron:           []Schedule{{Cron: "@hourly", Action: "update"}, {Cron: "every minute", Action: "backup"}},
+		StatusMap:      map[int]int{0: 400},
+	}
+	err := manifest.Validate()
+	var invalid *ValidationError
+	if !assert.True(t, errors.As(err, &invalid)) {
+		return
+	}
+	var fields []string
+	for _, problem := range invalid.Fields {
+		fields = append(fields, problem.Field)
+	}
+	assert.Equal(t, []string{"run[1]", "maximum_payload", "disk_quota_mb", "status_map", "output_headers.Bad Header", "input_headers.X Id", "cron[1].cron"}, fields)
+	assert.Contains(t, err.Error(), "maximum_payload: maximum payload should not be negative")
+
+	data, err := json.Marshal(invalid)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `{"field":"run[1]","message":"empty argument of run command"}`)
+}
+
+func TestManifest_ValidateAliases(t *testing.T) {
+	manifest := Manifest{Aliases: []string{"shop", "shop.v2"}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.Aliases = append(manifest.Aliases, "bad/alias", "shop")
+	err := manifest.Validate()
+	var invalid *ValidationError
+	if !assert.True(t, errors.As(err, &invalid)) {
+		return
+	}
+	assert.Len(t, invalid.Fields, 2)
+	assert.Contains(t, err.Error(), `aliases[2]: invalid alias "bad/alias"`)
+	assert.Contains(t, err.Error(), "aliases[3]: duplicated alias shop")
+}
+
+func TestManifest_ValidateSoftLimits(t *testing.T) {
+	manifest := Manifest{MaximumPayload: 100, MaximumResponse: 200, SoftLimits: &SoftLimits{Payload: 80, Response: 90}}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, []LimitWarning{{Limit: LimitResponse, Size: 180, Threshold: 180, Maximum: 200}}, manifest.LimitWarnings(79, 180))
+
+	manifest = Manifest{MaximumResponse: -1, SoftLimits: &SoftLimits{Payload: 50, Response: 100, Interval: -1}}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "maximum_response: maximum response should not be negative")
+		assert.Contains(t, err.Error(), "soft_limits: payload warning requires maximum payload")
+		assert.Contains(t, err.Error(), "soft_limits: response warning should be percent of maximum response between 1 and 99")
+		assert.Contains(t, err.Error(), "soft_limits: notification interval should not be negative")
+	}
+}
+
+func TestManifest_ValidateRetry(t *testing.T) {
+	retry := &Retry{Attempts: 5, Backoff: JsonDuration(time.Second), MaxBackoff: JsonDuration(5 * time.Second)}
+	manifest := Manifest{Retry: retry}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, 5, retry.Total())
+	assert.Equal(t, time.Second, retry.Delay(1))
+	assert.Equal(t, 4*time.Second, retry.Delay(3))
+	assert.Equal(t, 5*time.Second, retry.Delay(4))
+	retry.Jitter = true
+	for i := 0; i < 10; i++ {
+		delay := retry.Delay(2)
+		assert.True(t, delay >= time.Second && delay <= 2*time.Second, delay)
+	}
+	assert.Equal(t, 1, (*Retry)(nil).Total())
+
+	manifest.Retry = &Retry{Attempts: -1, Backoff: JsonDuration(time.Minute), MaxBackoff: JsonDuration(time.Second)}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "retry: number of attempts should not be negative")
+		assert.Contains(t, err.Error(), "retry: backoff should not be greater than maximum backoff")
+	}
+}
+
+func TestManifest_ValidateCheck(t *testing.T) {
+	manifest := Manifest{Check: [][]string{{"which", "python3"}}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.Check = append(manifest.Check, nil, []string{""})
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "check[1]: empty command of check")
+		assert.Contains(t, err.Error(), "check[2]: empty command of check")
+	}
+}
+
+func TestManifest_ValidateActions(t *testing.T) {
+	manifest := Manifest{Run: []string{"./app"}, Actions: map[string][]Step{
+		"install": {{Run: []string{"npm", "install", "."}, Timeout: JsonDuration(time.Minute)}},
+	}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.Actions["bad name"] = []Step{{Run: []string{"true"}}}
+	manifest.Actions["empty"] = nil
+	manifest.Actions["build"] = []Step{{Run: []string{"true"}}, {Run: []string{""}, Timeout: -1}}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `actions.bad name: invalid action name "bad name"`)
+		assert.Contains(t, err.Error(), "actions.empty: action empty has no steps")
+		assert.Contains(t, err.Error(), "actions.build[1].run: empty command of step 1 of action build")
+		assert.Contains(t, err.Error(), "actions.build[1].timeout: timeout of step 1 of action build should not be negative")
+	}
+}
+
+func TestManifest_ValidateBuildLimits(t *testing.T) {
+	manifest := Manifest{BuildLimits: &BuildLimits{Nice: 10, CPU: 0.5, Memory: 1 << 20}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.BuildLimits = &BuildLimits{Nice: 20, CPU: -1}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "nice 20 is out of range")
+		assert.Contains(t, err.Error(), "cpu cap -1 is negative")
+	}
+}
+
+func TestManifest_ValidateLimits(t *testing.T) {
+	manifest := Manifest{Limits: &ResourceLimits{MemoryMB: 256, CPUPercent: 50, OpenFiles: 1024, Processes: 32}}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, int64(256<<20), manifest.Limits.Memory())
+	assert.Equal(t, 0.5, manifest.Limits.CPU())
+
+	manifest.Limits = &ResourceLimits{MemoryMB: -1, Processes: -2}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "limits: memory limit -1 MiB is negative")
+		assert.Contains(t, err.Error(), "processes limit -2 is negative")
+	}
+}
+
+func TestManifest_ValidatePayloadAsFile(t *testing.T) {
+	manifest := Manifest{MaximumPayload: 1024, PayloadAsFile: true}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, int64(DefaultMaximumFilePayload), manifest.PayloadLimit())
+	manifest.MaximumFilePayload = 1 << 30
+	assert.Equal(t, int64(1<<30), manifest.PayloadLimit())
+	manifest.PayloadAsFile = false
+	assert.Equal(t, int64(1024), manifest.PayloadLimit())
+
+	manifest = Manifest{PayloadAsFile: true, MaximumFilePayload: -1, Mode: ModeWorker}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "maximum_file_payload: maximum file payload should not be negative")
+		assert.Contains(t, err.Error(), "payload_as_file: payload as file is not compatible with worker mode")
+	}
+}
+
+func TestManifest_ValidateDecodeMultipart(t *testing.T) {
+	manifest := Manifest{MaximumPayload: 1024, DecodeMultipart: true, MaximumFormFile: 1 << 20}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, int64(1<<20), manifest.FormFileLimit())
+	assert.Equal(t, int64(DefaultMaximumFilePayload), manifest.RequestLimit("multipart/form-data; boundary=xyz"))
+	assert.Equal(t, int64(1024), manifest.RequestLimit("application/json"))
+	assert.Equal(t, int64(1024), manifest.RequestLimit("multipart/form-data"), "no boundary")
+	manifest.MaximumFilePayload = 1024
+	assert.Equal(t, int64(1024), manifest.FormFileLimit(), "bounded by limit of all files")
+
+	manifest = Manifest{DecodeMultipart: true, PayloadAsFile: true, MaximumFormFile: -1, Mode: ModeWorker}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "maximum_form_file: maximum form file should not be negative")
+		assert.Contains(t, err.Error(), "decode_multipart: decoding of multipart is not compatible with worker mode")
+		assert.Contains(t, err.Error(), "decode_multipart: decoding of multipart is not compatible with payload as file")
+	}
+}
+
+func TestManifest_ValidateDecodeForm(t *testing.T) {
+	manifest := Manifest{DecodeForm: true, RawFormEnv: "RAW_BODY"}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, int64(DefaultFormPayload), manifest.RequestLimit("application/x-www-form-urlencoded; charset=utf-8"))
+	assert.Equal(t, int64(0), manifest.RequestLimit("application/json"))
+	manifest.MaximumPayload = 1024
+	assert.Equal(t, int64(1024), manifest.RequestLimit("application/x-www-form-urlencoded"))
+
+	manifest = Manifest{DecodeForm: true, PayloadAsFile: true, RawFormEnv: "RAW_BODY", Mode: ModeWorker}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "decode_form: decoding of form is not compatible with payload as file")
+		assert.Contains(t, err.Error(), "raw_form_env: raw form file is not compatible with worker mode")
+	}
+
+	manifest = Manifest{RawFormEnv: "RAW_BODY"}
+	err = manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "raw_form_env: raw form file requires decode_form")
+	}
+}
+
+func TestManifest_ValidateWorkDir(t *testing.T) {
+	manifest := Manifest{WorkDir: "app/bin"}
+	assert.NoError(t, manifest.Validate())
+
+	for _, dir := range []string{"/tmp", "../app", "app/../../etc"} {
+		manifest.WorkDir = dir
+		err := manifest.Validate()
+		if assert.Error(t, err, dir) {
+			assert.Contains(t, err.Error(), "work_dir: work dir")
+		}
+	}
+}
+
+func TestManifest_ValidateExpandRun(t *testing.T) {
+	manifest := Manifest{Run: []string{"${PYTHON:-python3}", "${BROKEN", "$$5"}, WorkDir: "${DIR"}
+	assert.NoError(t, manifest.Validate(), "not expanded without opt-in")
+
+	manifest.ExpandRun = true
+	err := manifest.Validate()
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Fields, 2)
+	assert.Equal(t, "run[1]", invalid.Fields[0].Field)
+	assert.Equal(t, "work_dir", invalid.Fields[1].Field)
+}
+
+func TestManifest_ValidateConcurrency(t *testing.T) {
+	manifest := Manifest{MaxConcurrency: 2, OverflowPolicy: OverflowReject}
+	assert.NoError(t, manifest.Validate())
+
+	manifest = Manifest{MaxConcurrency: -1, OverflowPolicy: "drop"}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "max_concurrency: max concurrency should not be negative")
+		assert.Contains(t, err.Error(), "overflow_policy: unknown overflow policy drop")
+	}
+}
+
+func TestManifest_ValidateTruncatePolicy(t *testing.T) {
+	manifest := Manifest{MaximumResponse: 1024, TruncatePolicy: TruncateCut}
+	assert.NoError(t, manifest.Validate())
+	assert.True(t, manifest.TruncateResponse())
+
+	manifest = Manifest{TruncatePolicy: "drop"}
+	assert.EqualError(t, manifest.Validate(), "truncate_policy: unknown truncate policy drop")
+}
+
+func TestManifest_ValidateStreaming(t *testing.T) {
+	manifest := Manifest{TimeLimit: JsonDuration(time.Second), Streaming: StreamingSSE, StreamTimeLimit: JsonDuration(time.Hour)}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, time.Hour, manifest.InvocationLimit())
+	manifest.StreamTimeLimit = 0
+	assert.Equal(t, time.Second, manifest.InvocationLimit(), "time limit is used if stream time limit is not set")
+
+	manifest = Manifest{TimeLimit: JsonDuration(time.Second), StreamTimeLimit: JsonDuration(time.Hour)}
+	assert.EqualError(t, manifest.Validate(), "stream_time_limit: stream time limit requires streaming mode sse")
+	assert.Equal(t, time.Second, manifest.InvocationLimit(), "regular lambda is not affected")
+
+	manifest = Manifest{Streaming: StreamingSSE, StreamIdleTimeout: JsonDuration(time.Minute), StreamReauthorize: JsonDuration(time.Minute)}
+	assert.NoError(t, manifest.Validate())
+	manifest = Manifest{StreamIdleTimeout: JsonDuration(time.Minute), StreamReauthorize: -1}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "stream_idle_timeout: stream idle timeout requires streaming mode sse")
+		assert.Contains(t, err.Error(), "stream_reauthorize: interval of re-authorization should not be negative")
+	}
+
+	manifest = Manifest{Streaming: "ws"}
+	assert.EqualError(t, manifest.Validate(), "streaming: unknown streaming mode ws")
+
+	manifest = Manifest{Streaming: StreamingSSE, StatusMap: map[int]int{2: 400}, Coalesce: &Coalescing{}, Cache: &Caching{TTL: JsonDuration(time.Minute)}}
+	err = manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "streaming is not compatible with status_map")
+		assert.Contains(t, err.Error(), "streaming is not compatible with coalesce")
+		assert.Contains(t, err.Error(), "streaming is not compatible with cache")
+	}
+}
+
+func TestManifest_ValidateCache(t *testing.T) {
+	manifest := Manifest{Cache: &Caching{TTL: JsonDuration(time.Minute)}}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, DefaultCacheEntries, manifest.Cache.Limit())
+	manifest.Cache.MaxEntries = 10
+	assert.Equal(t, 10, manifest.Cache.Limit())
+
+	manifest = Manifest{Cache: &Caching{MaxEntries: -1}}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cache.ttl: cache TTL should be positive")
+		assert.Contains(t, err.Error(), "cache.max_entries: cache max entries should not be negative")
+	}
+}
+
+func TestManifest_ValidateWorker(t *testing.T) {
+	manifest := Manifest{Mode: ModeWorker, Workers: 4}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, 4, manifest.PoolSize())
+	manifest.Workers = 0
+	assert.Equal(t, 1, manifest.PoolSize(), "one worker by default")
+
+	manifest = Manifest{Workers: 2}
+	assert.EqualError(t, manifest.Validate(), "workers: workers require invocation mode worker")
+
+	manifest = Manifest{Mode: "fork"}
+	assert.EqualError(t, manifest.Validate(), "mode: unknown invocation mode fork")
+
+	manifest = Manifest{Mode: ModeWorker, Workers: -1, Streaming: StreamingSSE}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "workers should not be negative")
+		assert.Contains(t, err.Error(), "worker mode is not compatible with streaming")
+	}
+}
+
+func TestManifest_ValidateGracePeriod(t *testing.T) {
+	manifest := Manifest{}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, DefaultGracePeriod, manifest.Grace())
+	manifest.GracePeriod = JsonDuration(10 * time.Second)
+	assert.Equal(t, 10*time.Second, manifest.Grace())
+
+	manifest.GracePeriod = JsonDuration(-time.Second)
+	assert.EqualError(t, manifest.Validate(), "grace_period: grace period should not be negative")
+}
+
+func TestManifest_ValidateStatusMap(t *testing.T) {
+	manifest := Manifest{StatusMap: map[int]int{2: 400, 3: 404}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.StatusMap = map[int]int{0: 400, 4: 99}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "exit code 0 should be between 1 and 255")
+		assert.Contains(t, err.Error(), "invalid HTTP status 99 for exit code 4")
+	}
+}
+
+func TestJsonDuration(t *testing.T) {
+	for input, expected := range map[string]time.Duration{
+		`"1s"`:        time.Second,
+		`"1m30s"`:     90 * time.Second,
+		`"500ms"`:     500 * time.Millisecond,
+		`"-2s"`:       -2 * time.Second,
+		`"0s"`:        0,
+		`"0"`:         0,
+		`""`:          0,
+		`1000000000`:  time.Second,
+		`0`:           0,
+		`-1000000000`: -time.Second,
+	} {
+		var value JsonDuration
+		if assert.NoError(t, json.Unmarshal([]byte(input), &value), input) {
+			assert.Equal(t, expected, time.Duration(value), input)
+		}
+	}
+	for _, input := range []string{`"1 second"`, `1.5`, `true`, `{}`, `"1x"`} {
+		var value JsonDuration
+		assert.Error(t, json.Unmarshal([]byte(input), &value), input)
+	}
+
+	for value, expected := range map[time.Duration]string{
+		0:                       `"0s"`,
+		90 * time.Second:        `"1m30s"`,
+		500 * time.Millisecond:  `"500ms"`,
+		-time.Second:            `"-1s"`,
+		time.Hour + time.Minute: `"1h1m0s"`,
+	} {
+		data, err := json.Marshal(JsonDuration(value))
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, string(data))
+		}
+		var decoded JsonDuration
+		if assert.NoError(t, json.Unmarshal(data, &decoded)) {
+			assert.Equal(t, value, time.Duration(decoded), "round trip")
+		}
+	}
+
+	var manifest Manifest
+	assert.NoError(t, json.Unmarshal([]byte(`{"run":["./app"],"time_limit":1000000000,"cron":[{"cron":"@daily","action":"clean","time_limit":null}]}`), &manifest))
+	assert.Equal(t, JsonDuration(time.Second), manifest.TimeLimit, "legacy manifest")
+	assert.Zero(t, manifest.Cron[0].TimeLimit)
+	data, err := json.Marshal(manifest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"time_limit":"1s"`)
+}
+
+func TestManifest_ValidateScheduledInvocation(t *testing.T) {
+	manifest := Manifest{
+		Run: []string{"./app"},
+		Cron: []Schedule{
+			{Name: "report", Cron: "@daily", Payload: `{"kind":"daily"}`},
+			{Cron: "@weekly", PayloadFile: "payloads/weekly.json"},
+			{Cron: "@hourly", Action: "clean"},
+		},
+	}
+	assert.NoError(t, manifest.Validate())
+	assert.True(t, manifest.Cron[0].Invocation())
+	assert.False(t, manifest.Cron[2].Invocation())
+
+	manifest.Cron = []Schedule{
+		{Name: "report", Cron: "@daily"},
+		{Name: "report", Cron: "@weekly"},
+		{Cron: "@hourly", Action: "clean", Payload: "{}"},
+		{Cron: "@hourly", Payload: "{}", PayloadFile: "payload.json"},
+		{Cron: "@hourly", PayloadFile: "../payload.json"},
+		{Name: "broken", Cron: "bad"},
+	}
+	err := manifest.Validate()
+	var validation *ValidationError
+	if !assert.True(t, errors.As(err, &validation)) {
+		return
+	}
+	assert.Contains(t, err.Error(), "duplicated schedule name report")
+	assert.Contains(t, err.Error(), "payload of scheduled action clean is not supported")
+	assert.Contains(t, err.Error(), "payload and payload file of scheduled invocation @hourly are mutually exclusive")
+	assert.Contains(t, err.Error(), "payload file ../payload.json should be relative path inside lambda")
+	assert.Contains(t, err.Error(), "bad cront expression for invocation broken")
+}
+
+func TestManifest_ValidateScheduleZone(t *testing.T) {
+	manifest := Manifest{
+		Run:  []string{"./app"},
+		Cron: []Schedule{{Name: "export", Cron: "0 30 2 * * *", TZ: "Europe/Berlin"}, {Cron: "@hourly"}},
+	}
+	require.NoError(t, manifest.Validate())
+	location, err := manifest.Cron[0].Location()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", location.String())
+	location, err = manifest.Cron[1].Location()
+	require.NoError(t, err)
+	assert.Equal(t, time.Local, location)
+
+	manifest.Cron[0].TZ = "Mars/Olympus"
+	err = manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cron[0].tz: unknown time zone Mars/Olympus for invocation export")
+	}
+}
+
+func TestManifest_ValidateNegativeTimeLimit(t *testing.T) {
+	manifest := Manifest{
+		Run:       []string{"./app"},
+		TimeLimit: JsonDuration(-time.Second),
+		OnStart:   &Startup{Action: "warmup", TimeLimit: JsonDuration(-time.Second)},
+		Cron:      []Schedule{{Cron: "@daily", Action: "clean", TimeLimit: JsonDuration(-time.Second)}},
+	}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "time limit should not be negative")
+		assert.Contains(t, err.Error(), "on_start time limit should not be negative")
+		assert.Contains(t, err.Error(), "time limit of scheduled action clean should not be negative")
+	}
+}
+
+func TestManifest_ValidateRateLimit(t *testing.T) {
+	manifest := Manifest{RateLimit: &RateLimit{RPS: 0.5}}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, 1.0, manifest.RateLimit.Capacity())
+	manifest.RateLimit.RPS = 2.5
+	assert.Equal(t, 3.0, manifest.RateLimit.Capacity())
+	manifest.RateLimit.Burst = 10
+	assert.Equal(t, 10.0, manifest.RateLimit.Capacity())
+
+	manifest = Manifest{RateLimit: &RateLimit{Burst: -1}}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rate_limit.rps: rate limit RPS should be positive")
+		assert.Contains(t, err.Error(), "rate_limit.burst: rate limit burst should not be negative")
+	}
+}
+
+func TestManifest_ValidateAllowedNetworks(t *testing.T) {
+	manifest := Manifest{AllowedNetworks: []string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.10"}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.AllowedNetworks = append(manifest.AllowedNetworks, "10.0.0/8")
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "allowed_networks: invalid CIDR address: 10.0.0/8")
+	}
+}
+
+func TestManifest_ValidateWebhookSignature(t *testing.T) {
+	manifest := Manifest{WebhookSignature: &WebhookSignature{Preset: WebhookGitHub, Secret: "${HOOK_SECRET}"}}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.WebhookSignature = &WebhookSignature{Algorithm: "md5", Encoding: "base32", Secret: "${HOOK"}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "webhook_signature: header of webhook signature is not defined")
+		assert.Contains(t, err.Error(), "webhook_signature: unknown webhook signature algorithm md5")
+		assert.Contains(t, err.Error(), "webhook_signature: unknown webhook signature encoding base32")
+		assert.Contains(t, err.Error(), "webhook_signature: secret of webhook signature: unterminated reference")
+	}
+}
+
+func TestManifest_ValidateGit(t *testing.T) {
+	manifest := Manifest{Git: &GitSource{Repo: "git@github.com:acme/fn.git", Branch: "release/v1", Dir: "fn", Interval: JsonDuration(time.Minute)}}
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, "release/v1", manifest.Git.Ref())
+	assert.Equal(t, "fn", manifest.Git.Subdir())
+	assert.Equal(t, "HEAD", (&GitSource{}).Ref())
+	assert.Equal(t, "", (&GitSource{Dir: "/"}).Subdir())
+
+	manifest.Git = &GitSource{Repo: "--upload-pack=sh", Branch: "main..dev", Dir: "../other", Interval: JsonDuration(time.Second), Action: "-n", Ping: &WebhookSignature{}}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `git: invalid repository "--upload-pack=sh"`)
+		assert.Contains(t, err.Error(), `git: invalid branch "main..dev"`)
+		assert.Contains(t, err.Error(), `git: directory "../other" should be inside repository`)
+		assert.Contains(t, err.Error(), "git: interval should be at least 10s")
+		assert.Contains(t, err.Error(), `git: invalid action "-n"`)
+		assert.Contains(t, err.Error(), "git: ping: header of webhook signature is not defined")
+	}
+	manifest.Git = &GitSource{}
+	assert.Error(t, manifest.Validate())
+}
+
+func TestManifest_ValidateBasicAuth(t *testing.T) {
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+	manifest := Manifest{BasicAuth: &BasicAuth{Users: map[string]string{"alice": hash}}}
+	assert.NoError(t, manifest.Validate())
+	assert.True(t, manifest.BasicAuth.Authenticate("alice", "secret"))
+	assert.False(t, manifest.BasicAuth.Authenticate("alice", "wrong"))
+	assert.False(t, manifest.BasicAuth.Authenticate("bob", "secret"))
+
+	manifest.BasicAuth = &BasicAuth{Users: map[string]string{"alice": "secret", "a:b": hash}, Realm: `"`}
+	err = manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "basic_auth: password of user alice is not a bcrypt hash")
+		assert.Contains(t, err.Error(), `basic_auth: invalid username "a:b"`)
+		assert.Contains(t, err.Error(), "basic_auth: invalid realm")
+	}
+	manifest.BasicAuth = &BasicAuth{}
+	assert.Error(t, manifest.Validate())
+	_, err = HashPassword("")
+	assert.Error(t, err)
+}
+
+func TestManifest_ValidateJWT(t *testing.T) {
+	manifest := Manifest{JWT: &JWTPolicy{Secret: "${JWT_SECRET}", Env: map[string]string{"email": "USER_EMAIL"}, Headers: map[string]string{"sub": "X-User"}}}
+	assert.NoError(t, manifest.Validate())
+	manifest.JWT = &JWTPolicy{JWKS: "https://sso.example.com/.well-known/jwks.json"}
+	assert.NoError(t, manifest.Validate())
+
+	manifest.JWT = &JWTPolicy{Secret: "secret", JWKS: "/jwks.json", Leeway: -1, Headers: map[string]string{"sub": "Authorization"}}
+	err := manifest.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "jwt: exactly one of secret, public key or JWKS should be defined")
+		assert.Contains(t, err.Error(), "jwt: JWKS should be absolute http(s) URL")
+		assert.Contains(t, err.Error(), "jwt: refresh and leeway of JWT should not be negative")
+		assert.Contains(t, err.Error(), `jwt: invalid header "Authorization" of claim sub`)
+	}
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+	manifest = Manifest{JWT: &JWTPolicy{Secret: "secret"}, BasicAuth: &BasicAuth{Users: map[string]string{"alice": hash}}}
+	assert.Error(t, manifest.Validate())
+}
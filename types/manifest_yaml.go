@@ -0,0 +1,142 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsYAML checks that file (by extension .yaml or .yml) should be in YAML format.
+func IsYAML(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// MarshalYAML encodes manifest with the same field names, order and values as JSON (durations as strings, keys of
+// status map as strings).
+func (mf Manifest) MarshalYAML() (interface{}, error) {
+	data, err := json.Marshal(mf)
+	if err != nil {
+		return nil, err
+	}
+	// YAML is superset of JSON: parsed document keeps order of fields
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	node := doc.Content[0]
+	blockStyle(node)
+	return node, nil
+}
+
+// UnmarshalYAML decodes manifest by JSON rules: YAML document is converted to JSON and decoded as usual.
+func (mf *Manifest) UnmarshalYAML(node *yaml.Node) error {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	value, err := jsonValue(value)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var decoded Manifest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*mf = decoded
+	return nil
+}
+
+// SaveAsYAML writes manifest to file in YAML format regardless of extension.
+func (mf *Manifest) SaveAsYAML(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return mf.encodeYAML(f)
+}
+
+func (mf *Manifest) encodeYAML(out io.Writer) error {
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(mf); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// DecodeManifest reads manifest from stream in format defined by extension of file name (see IsYAML).
+func DecodeManifest(filename string, input io.Reader) (Manifest, error) {
+	var mf Manifest
+	var err error
+	if IsYAML(filename) {
+		err = yaml.NewDecoder(input).Decode(&mf)
+	} else {
+		err = json.NewDecoder(input).Decode(&mf)
+	}
+	return mf, err
+}
+
+// block style for all nodes of JSON document
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// convert decoded YAML value to value acceptable by JSON: keys of mappings (ex: status codes) are strings
+func jsonValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = item
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		var ans = make(map[string]interface{}, len(v))
+		for key, item := range v {
+			switch key.(type) {
+			case string, int, int64, uint64, float64, bool:
+			default:
+				return nil, fmt.Errorf("unsupported key %v", key)
+			}
+			name := fmt.Sprint(key)
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			ans[name] = item
+		}
+		return ans, nil
+	case []interface{}:
+		for i, item := range v {
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			v[i] = item
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
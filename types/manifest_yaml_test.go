@@ -0,0 +1,96 @@
+package types_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestManifest_YAMLRoundTripTemplates(t *testing.T) {
+	for name, template := range templates.ListEmbedded() {
+		t.Run(name, func(t *testing.T) {
+			assertRoundTrip(t, template.Manifest)
+		})
+	}
+}
+
+func TestManifest_YAMLRoundTripFull(t *testing.T) {
+	var manifest types.Manifest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"name": "full",
+		"description": "multi\nline: description",
+		"run": ["./app", "--flag", "123", "true", "null"],
+		"output_headers": {"Content-Type": "application/json", "X-Id": "007"},
+		"input_headers": {"X-Token": "TOKEN"},
+		"environment": {"PORT": "8080", "EMPTY": "", "YES": "yes"},
+		"time_limit": "1m30s",
+		"maximum_payload": 8192,
+		"cron": [{"cron": "@every 5s", "action": "tick", "time_limit": "2s", "missed": "skip"}],
+		"umask": "0022",
+		"sampling": {"rate": 10, "slow": "500ms"},
+		"on_start": {"action": "warm", "time_limit": "1h0m0s", "blocking": true},
+		"build_limits": {"nice": 10, "cpu": 0.5, "memory": 268435456},
+		"status_map": {"3": 404, "7": 409},
+		"static_dirs": {"/assets/": "public"},
+		"remove_headers": ["X-Powered-By"],
+		"maximum_response": 1048576,
+		"soft_limits": {"payload": 80, "notify": "notify", "interval": "10m0s"}
+	}`), &manifest))
+	assertRoundTrip(t, manifest)
+
+	data, err := yaml.Marshal(&manifest)
+	require.NoError(t, err)
+	text := string(data)
+	assert.True(t, strings.HasPrefix(text, "name: full\n"), "order of fields is the same as in JSON")
+	assert.Contains(t, text, "time_limit: 1m30s\n")
+	assert.Contains(t, text, `"3": 404`)
+	assert.Contains(t, text, `PORT: "8080"`)
+}
+
+func TestManifest_LoadFromYAML(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "manifest.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+run: [cat, "-"]
+time_limit: 5s
+status_map:
+  3: 404
+environment:
+  MODE: fast
+`), 0644))
+
+	var manifest types.Manifest
+	require.NoError(t, manifest.LoadFrom(file))
+	assert.Equal(t, []string{"cat", "-"}, manifest.Run)
+	assert.Equal(t, types.JsonDuration(5*time.Second), manifest.TimeLimit)
+	assert.Equal(t, map[int]int{3: 404}, manifest.StatusMap)
+	assert.Equal(t, map[string]string{"MODE": "fast"}, manifest.Environment)
+
+	require.NoError(t, os.WriteFile(file, []byte("run: [cat]\ntime_limit: soon\n"), 0644))
+	assert.Error(t, manifest.LoadFrom(file))
+}
+
+// manifest saved as YAML and loaded back should be the same JSON
+func assertRoundTrip(t *testing.T, manifest types.Manifest) {
+	original, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "manifest.yaml")
+	require.NoError(t, manifest.SaveAsYAML(file))
+	var restored types.Manifest
+	require.NoError(t, restored.LoadFrom(file))
+
+	converted, err := json.Marshal(restored)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(original), string(converted))
+}
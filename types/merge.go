@@ -0,0 +1,272 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Conflict of three-way merge: both sides changed the same field to different values.
+type Conflict struct {
+	Field  string      // path to the field (ex: output_headers.Content-Type)
+	Ours   interface{} // local value (nil - removed)
+	Theirs interface{} // remote value (nil - removed)
+}
+
+func (c Conflict) String() string {
+	return c.Field + ": ours " + conflictValue(c.Ours) + ", theirs " + conflictValue(c.Theirs)
+}
+
+// Hash of manifest content (SHA-256 of JSON representation). Used as revision to detect changes.
+func (mf *Manifest) Hash() (string, error) {
+	data, err := json.Marshal(mf)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// MergeManifest performs three-way structural merge of manifests by JSON representation. Objects (like output_headers)
+// are merged key by key, arrays (like run) and scalars are replaced as a whole. Fields changed by both sides to
+// different values are reported as conflicts and keep our value in the merged manifest.
+func MergeManifest(base, ours, theirs Manifest) (Manifest, []Conflict, error) {
+	docs, err := manifestDocs(&base, &ours, &theirs)
+	if err != nil {
+		return Manifest{}, nil, err
+	}
+	var conflicts []Conflict
+	merged := mergeValue("", docs[0], docs[1], docs[2], &conflicts)
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return Manifest{}, nil, fmt.Errorf("encode merged manifest: %w", err)
+	}
+	var ans Manifest
+	if err := json.Unmarshal(data, &ans); err != nil {
+		return Manifest{}, nil, fmt.Errorf("decode merged manifest: %w", err)
+	}
+	return ans, conflicts, nil
+}
+
+// Change of field between two manifests.
+type Change struct {
+	Field string      // path to the field (ex: output_headers.Content-Type)
+	From  interface{} // old value (nil - added)
+	To    interface{} // new value (nil - removed)
+}
+
+func (c Change) String() string {
+	switch {
+	case c.From == nil:
+		return "+ " + c.Field + ": " + conflictValue(c.To)
+	case c.To == nil:
+		return "- " + c.Field + ": " + conflictValue(c.From)
+	default:
+		return "~ " + c.Field + ": " + conflictValue(c.From) + " -> " + conflictValue(c.To)
+	}
+}
+
+// DiffManifest compares manifests by JSON representation the same way as MergeManifest: objects key by key,
+// arrays and scalars as a whole. Changes are sorted by field.
+func DiffManifest(from, to Manifest) ([]Change, error) {
+	docs, err := manifestDocs(&from, &to)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffValue("", docs[0], docs[1], &changes)
+	return changes, nil
+}
+
+// PatchManifest applies JSON merge patch (RFC 7396) to JSON representation of manifest: objects are merged key by key,
+// null removes field, arrays and scalars replace value as a whole. Unknown fields of patch are error.
+func PatchManifest(manifest Manifest, patch json.RawMessage) (Manifest, error) {
+	var changes interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return Manifest{}, fmt.Errorf("decode patch: %w", err)
+	}
+	if _, ok := changes.(map[string]interface{}); !ok {
+		return Manifest{}, fmt.Errorf("patch should be JSON object")
+	}
+	docs, err := manifestDocs(&manifest)
+	if err != nil {
+		return Manifest{}, err
+	}
+	data, err := json.Marshal(patchValue(docs[0], changes))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("encode patched manifest: %w", err)
+	}
+	var ans Manifest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ans); err != nil {
+		return Manifest{}, fmt.Errorf("decode patched manifest: %w", err)
+	}
+	return ans, nil
+}
+
+// FieldPatch is JSON merge patch (see PatchManifest) by assignments of fields: path=value, where path is dot
+// separated (ex: output_headers.X-Frame-Options=DENY). Value is JSON (ex: time_limit="30s", labels=["a","b"],
+// cache=null) or string if it's not valid JSON.
+func FieldPatch(assignments []string) (json.RawMessage, error) {
+	var patch = make(map[string]interface{})
+	for _, assignment := range assignments {
+		path, raw, ok := strings.Cut(assignment, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("assignment %q should be field=value", assignment)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+		keys := strings.Split(path, ".")
+		obj := patch
+		for i, key := range keys[:len(keys)-1] {
+			next, ok := obj[key].(map[string]interface{})
+			if !ok {
+				if _, set := obj[key]; set {
+					return nil, fmt.Errorf("assignment %q: field %s is already set", assignment, strings.Join(keys[:i+1], "."))
+				}
+				next = make(map[string]interface{})
+				obj[key] = next
+			}
+			obj = next
+		}
+		obj[keys[len(keys)-1]] = value
+	}
+	return json.Marshal(patch)
+}
+
+func patchValue(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	obj, ok := target.(map[string]interface{})
+	if !ok {
+		obj = make(map[string]interface{})
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(obj, k)
+		} else {
+			obj[k] = patchValue(obj[k], v)
+		}
+	}
+	return obj
+}
+
+func diffValue(path string, from, to interface{}, changes *[]Change) {
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+	fromObj, fromOk := from.(map[string]interface{})
+	toObj, toOk := to.(map[string]interface{})
+	if !fromOk || !toOk {
+		*changes = append(*changes, Change{Field: path, From: present(from), To: present(to)})
+		return
+	}
+	for _, k := range sortedKeys(fromObj, toObj) {
+		diffValue(joinField(path, k), field(fromObj, k), field(toObj, k), changes)
+	}
+}
+
+// JSON documents of manifests
+func manifestDocs(manifests ...*Manifest) ([]interface{}, error) {
+	var docs = make([]interface{}, len(manifests))
+	for i, mf := range manifests {
+		data, err := json.Marshal(mf)
+		if err != nil {
+			return nil, fmt.Errorf("encode manifest: %w", err)
+		}
+		if err := json.Unmarshal(data, &docs[i]); err != nil {
+			return nil, fmt.Errorf("decode manifest: %w", err)
+		}
+	}
+	return docs, nil
+}
+
+func sortedKeys(objects ...map[string]interface{}) []string {
+	var keys = make(map[string]bool)
+	for _, obj := range objects {
+		for k := range obj {
+			keys[k] = true
+		}
+	}
+	var names = make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// marker of absent object key
+type absent struct{}
+
+func mergeValue(path string, base, ours, theirs interface{}, conflicts *[]Conflict) interface{} {
+	switch {
+	case reflect.DeepEqual(ours, theirs):
+		return ours
+	case reflect.DeepEqual(base, ours):
+		return theirs
+	case reflect.DeepEqual(base, theirs):
+		return ours
+	}
+	oursObj, oursOk := ours.(map[string]interface{})
+	theirsObj, theirsOk := theirs.(map[string]interface{})
+	baseObj, baseOk := base.(map[string]interface{})
+	if _, missing := base.(absent); missing {
+		baseOk = true
+	}
+	if !oursOk || !theirsOk || !baseOk {
+		*conflicts = append(*conflicts, Conflict{Field: path, Ours: present(ours), Theirs: present(theirs)})
+		return ours
+	}
+	var ans = make(map[string]interface{})
+	for _, k := range sortedKeys(baseObj, oursObj, theirsObj) {
+		v := mergeValue(joinField(path, k), field(baseObj, k), field(oursObj, k), field(theirsObj, k), conflicts)
+		if _, missing := v.(absent); !missing {
+			ans[k] = v
+		}
+	}
+	return ans
+}
+
+func field(obj map[string]interface{}, key string) interface{} {
+	v, ok := obj[key]
+	if !ok {
+		return absent{}
+	}
+	return v
+}
+
+func present(v interface{}) interface{} {
+	if _, missing := v.(absent); missing {
+		return nil
+	}
+	return v
+}
+
+func joinField(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func conflictValue(v interface{}) string {
+	if v == nil {
+		return "<removed>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(string(data))
+}
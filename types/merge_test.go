@@ -0,0 +1,174 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func baseManifest() types.Manifest {
+	return types.Manifest{
+		Name:          "base",
+		Run:           []string{"cat", "-"},
+		OutputHeaders: map[string]string{"Content-Type": "text/plain", "X-Base": "1"},
+		TimeLimit:     types.JsonDuration(time.Second),
+	}
+}
+
+func TestMergeManifest_nonOverlapping(t *testing.T) {
+	base := baseManifest()
+
+	ours := baseManifest()
+	ours.Run = []string{"python3", "app.py"}
+	ours.OutputHeaders["X-Ours"] = "1"
+	delete(ours.OutputHeaders, "X-Base")
+
+	theirs := baseManifest()
+	theirs.Description = "remote"
+	theirs.OutputHeaders["X-Theirs"] = "2"
+	theirs.InputHeaders = map[string]string{"Authorization": "AUTH"}
+
+	merged, conflicts, err := types.MergeManifest(base, ours, theirs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, conflicts)
+	assert.Equal(t, []string{"python3", "app.py"}, merged.Run)
+	assert.Equal(t, "remote", merged.Description)
+	assert.Equal(t, map[string]string{"Content-Type": "text/plain", "X-Ours": "1", "X-Theirs": "2"}, merged.OutputHeaders)
+	assert.Equal(t, map[string]string{"Authorization": "AUTH"}, merged.InputHeaders)
+	assert.Equal(t, types.JsonDuration(time.Second), merged.TimeLimit)
+}
+
+func TestMergeManifest_sameChange(t *testing.T) {
+	base := baseManifest()
+	ours := baseManifest()
+	ours.Run = []string{"jq", "."}
+	theirs := baseManifest()
+	theirs.Run = []string{"jq", "."}
+
+	merged, conflicts, err := types.MergeManifest(base, ours, theirs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, conflicts)
+	assert.Equal(t, []string{"jq", "."}, merged.Run)
+}
+
+func TestMergeManifest_conflicts(t *testing.T) {
+	base := baseManifest()
+
+	ours := baseManifest()
+	ours.Run = []string{"cat"}
+	ours.OutputHeaders["Content-Type"] = "application/json"
+	ours.OutputHeaders["X-Ours"] = "1"
+
+	theirs := baseManifest()
+	theirs.Run = []string{"cat", "-", "-"}
+	theirs.OutputHeaders["Content-Type"] = "text/html"
+	delete(theirs.OutputHeaders, "X-Base")
+	ours.OutputHeaders["X-Base"] = "2"
+
+	merged, conflicts, err := types.MergeManifest(base, ours, theirs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Len(t, conflicts, 3) {
+		return
+	}
+	assert.Equal(t, "output_headers.Content-Type", conflicts[0].Field)
+	assert.Equal(t, "application/json", conflicts[0].Ours)
+	assert.Equal(t, "text/html", conflicts[0].Theirs)
+	assert.Equal(t, "output_headers.X-Base", conflicts[1].Field)
+	assert.Nil(t, conflicts[1].Theirs)
+	assert.Equal(t, "output_headers.X-Base: ours \"2\", theirs <removed>", conflicts[1].String())
+	assert.Equal(t, "run", conflicts[2].Field)
+	// conflicts keep our value
+	assert.Equal(t, []string{"cat"}, merged.Run)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "X-Base": "2", "X-Ours": "1"}, merged.OutputHeaders)
+}
+
+func TestManifest_Hash(t *testing.T) {
+	a := baseManifest()
+	b := baseManifest()
+	hashA, err := a.Hash()
+	if !assert.NoError(t, err) {
+		return
+	}
+	hashB, err := b.Hash()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, hashA, hashB)
+	b.OutputHeaders["X-Other"] = "1"
+	hashB, err = b.Hash()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, hashA, hashB)
+}
+
+func TestDiffManifest(t *testing.T) {
+	from := baseManifest()
+	to := baseManifest()
+	to.Run = []string{"jq", "."}
+	to.OutputHeaders["Content-Type"] = "application/json"
+	delete(to.OutputHeaders, "X-Base")
+	to.InputHeaders = map[string]string{"Authorization": "AUTH"}
+
+	changes, err := types.DiffManifest(from, to)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	assert.Equal(t, []string{
+		`+ input_headers: {"Authorization":"AUTH"}`,
+		`~ output_headers.Content-Type: "text/plain" -> "application/json"`,
+		`- output_headers.X-Base: "1"`,
+		`~ run: ["cat","-"] -> ["jq","."]`,
+	}, lines)
+
+	changes, err = types.DiffManifest(from, baseManifest())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, changes)
+}
+
+func TestPatchManifest(t *testing.T) {
+	patch, err := types.FieldPatch([]string{"time_limit=30s", "output_headers.X-Frame-Options=DENY", "output_headers.X-Base=null", `labels=["billing"]`, "parse_headers=true"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	patched, err := types.PatchManifest(baseManifest(), patch)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, types.JsonDuration(30*time.Second), patched.TimeLimit)
+	assert.Equal(t, map[string]string{"Content-Type": "text/plain", "X-Frame-Options": "DENY"}, patched.OutputHeaders)
+	assert.Equal(t, []string{"billing"}, patched.Labels)
+	assert.True(t, patched.ParseHeaders)
+	assert.Equal(t, "base", patched.Name, "other fields are kept")
+
+	patched, err = types.PatchManifest(baseManifest(), []byte(`{"output_headers":null,"run":["jq","."]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, patched.OutputHeaders)
+	assert.Equal(t, []string{"jq", "."}, patched.Run)
+
+	_, err = types.PatchManifest(baseManifest(), []byte(`{"time_limt":"1s"}`))
+	assert.Error(t, err, "unknown field")
+	_, err = types.PatchManifest(baseManifest(), []byte(`["run"]`))
+	assert.Error(t, err, "not an object")
+	_, err = types.FieldPatch([]string{"time_limit"})
+	assert.Error(t, err, "without value")
+	_, err = types.FieldPatch([]string{"cache=1", "cache.ttl=1"})
+	assert.Error(t, err, "field is already set")
+}
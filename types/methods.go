@@ -0,0 +1,66 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Request rejected because of HTTP method (see Manifest.Methods)
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// AllowedMethods of lambda in upper case and sorted: methods and legacy method, HEAD is allowed with GET, GET and HEAD
+// are always allowed for lambda with static files. Empty - any method is allowed. OPTIONS (CORS preflight) is
+// answered by server (see Manifest.CORS) and never reaches lambda.
+func (mf *Manifest) AllowedMethods() []string {
+	if len(mf.Methods) == 0 && mf.Method == "" {
+		return nil
+	}
+	var set = make(map[string]bool)
+	for _, method := range append([]string{mf.Method}, mf.Methods...) {
+		if method != "" {
+			set[strings.ToUpper(method)] = true
+		}
+	}
+	if mf.Static != "" {
+		set[http.MethodGet] = true
+	}
+	if set[http.MethodGet] {
+		set[http.MethodHead] = true
+	}
+	var ans = make([]string, 0, len(set))
+	for method := range set {
+		ans = append(ans, method)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// Check method of request against allowed methods (case-insensitive).
+func (mf *Manifest) AcceptMethod(method string) error {
+	allowed := mf.AllowedMethods()
+	if len(allowed) == 0 {
+		return nil
+	}
+	method = strings.ToUpper(method)
+	for _, m := range allowed {
+		if m == method {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrMethodNotAllowed, method)
+}
+
+func validateMethods(methods []string) error {
+	var errs []error
+	for _, method := range methods {
+		if method == "" || !httpguts.ValidHeaderFieldName(method) {
+			errs = append(errs, fmt.Errorf("invalid method %q", method))
+		}
+	}
+	return errors.Join(errs...)
+}
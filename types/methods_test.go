@@ -0,0 +1,38 @@
+package types_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestManifest_AllowedMethods(t *testing.T) {
+	var mf types.Manifest
+	assert.Empty(t, mf.AllowedMethods())
+	assert.NoError(t, mf.AcceptMethod("DELETE"), "empty list allows any method")
+
+	mf.Methods = []string{"put", "Post"}
+	assert.Equal(t, []string{"POST", "PUT"}, mf.AllowedMethods())
+	assert.NoError(t, mf.AcceptMethod("post"))
+	assert.True(t, errors.Is(mf.AcceptMethod("GET"), types.ErrMethodNotAllowed))
+
+	mf.Method = "get" // legacy
+	assert.Equal(t, []string{"GET", "HEAD", "POST", "PUT"}, mf.AllowedMethods())
+
+	mf = types.Manifest{Static: "static", Methods: []string{"POST"}}
+	assert.Equal(t, []string{"GET", "HEAD", "POST"}, mf.AllowedMethods())
+}
+
+func TestManifest_ValidateMethods(t *testing.T) {
+	mf := types.Manifest{Name: "test", Run: []string{"echo"}, Methods: []string{"GET", "BAD METHOD", ""}}
+	err := mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid method "BAD METHOD"`)
+		assert.Contains(t, err.Error(), `invalid method ""`)
+	}
+	mf.Methods = mf.Methods[:1]
+	assert.NoError(t, mf.Validate())
+}
@@ -0,0 +1,159 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Limits of request mutation
+const (
+	MaxMutationHeaders       = 32          // maximum number of injected headers
+	MaxMutationValue         = 4096        // maximum size of header value template
+	MaxBodyDefaults          = 64 * 1024   // maximum size of body defaults (JSON)
+	DefaultMutationBodyLimit = 1024 * 1024 // maximum size of JSON body to patch if maximum payload is not set
+)
+
+// Mutation of request before invocation (after access checks): headers are set or overridden and missing keys of
+// JSON body are filled by defaults. Mutated request is passed to lambda, queued and recorded in stats.
+type Mutation struct {
+	Headers      map[string]string      `json:"headers,omitempty"`       // headers to set, values are Go templates over request (ex: {{.RemoteAddress}})
+	BodyDefaults map[string]interface{} `json:"body_defaults,omitempty"` // defaults of JSON object body: only missing keys are filled (recursively)
+}
+
+func (mt *Mutation) validate() error {
+	if len(mt.Headers) > MaxMutationHeaders {
+		return fmt.Errorf("too many headers to mutate (%d), maximum is %d", len(mt.Headers), MaxMutationHeaders)
+	}
+	var names = make([]string, 0, len(mt.Headers))
+	for name := range mt.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := mt.Headers[name]
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid name of mutated header %q", name)
+		}
+		if len(value) > MaxMutationValue {
+			return fmt.Errorf("value of mutated header %s is too long, maximum is %d bytes", name, MaxMutationValue)
+		}
+		if _, err := template.New(name).Option("missingkey=zero").Parse(value); err != nil {
+			return fmt.Errorf("invalid template of mutated header %s: %w", name, err)
+		}
+	}
+	if mt.BodyDefaults != nil {
+		data, err := json.Marshal(mt.BodyDefaults)
+		if err != nil {
+			return fmt.Errorf("invalid body defaults: %w", err)
+		}
+		if len(data) > MaxBodyDefaults {
+			return fmt.Errorf("body defaults are too big (%d bytes), maximum is %d bytes", len(data), MaxBodyDefaults)
+		}
+	}
+	return nil
+}
+
+// Apply mutation to the request. Returns new request, original request is not changed. Templates are evaluated
+// over original request. Only JSON object body (by Content-Type) not bigger than limit is patched, other bodies are
+// passed as is.
+func (mt *Mutation) Apply(req *Request, limit int64) (*Request, error) {
+	cp := *req
+	cp.Headers = make(map[string]string, len(req.Headers)+len(mt.Headers))
+	for name, value := range req.Headers {
+		cp.Headers[name] = value
+	}
+	for name, text := range mt.Headers {
+		tpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse template of header %s: %w", name, err)
+		}
+		var value bytes.Buffer
+		if err := tpl.Execute(&value, req); err != nil {
+			return nil, fmt.Errorf("render header %s: %w", name, err)
+		}
+		if !httpguts.ValidHeaderFieldValue(value.String()) {
+			return nil, fmt.Errorf("rendered value of header %s is invalid", name)
+		}
+		cp.Headers[textproto.CanonicalMIMEHeaderKey(name)] = value.String()
+	}
+	if len(mt.BodyDefaults) == 0 || req.Body == nil || !isJSON(cp.Headers["Content-Type"]) {
+		return &cp, nil
+	}
+	if limit <= 0 {
+		limit = DefaultMutationBodyLimit
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		// too big to patch: pass read part and the rest as is
+		cp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(data), req.Body), Closer: req.Body}
+		return &cp, nil
+	}
+	_ = req.Body.Close()
+	cp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	var body map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return &cp, nil // not a JSON object
+	}
+	if !fillDefaults(body, mt.BodyDefaults) {
+		return &cp, nil
+	}
+	patched, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encode body: %w", err)
+	}
+	cp.Body = ioutil.NopCloser(bytes.NewReader(patched))
+	cp.Headers["Content-Length"] = strconv.Itoa(len(patched))
+	delete(cp.Headers, "Transfer-Encoding")
+	return &cp, nil
+}
+
+// fill missing keys of object by defaults, nested objects are filled recursively. Returns true if object changed
+func fillDefaults(object map[string]interface{}, defaults map[string]interface{}) bool {
+	var changed bool
+	for key, value := range defaults {
+		current, ok := object[key]
+		if !ok {
+			object[key] = value
+			changed = true
+			continue
+		}
+		nestedDefaults, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if nested, ok := current.(map[string]interface{}); ok && fillDefaults(nested, nestedDefaults) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// application/json or any type with +json suffix
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,63 @@
+package types_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestMutation_Apply(t *testing.T) {
+	mutation := &types.Mutation{
+		Headers:      map[string]string{"x-token": "static", "X-Client": "{{.RemoteAddress}} {{index .Headers \"User-Agent\"}}"},
+		BodyDefaults: map[string]interface{}{"count": 1, "meta": map[string]interface{}{"source": "hook"}},
+	}
+	require.NoError(t, (&types.Manifest{Mutate: mutation}).Validate())
+
+	req := &types.Request{
+		RemoteAddress: "10.0.0.1",
+		Headers:       map[string]string{"User-Agent": "curl", "Content-Type": "application/vnd.api+json", "X-Token": "user"},
+		Body:          ioutil.NopCloser(strings.NewReader(`{"count": 12345678901234567890, "meta": null}`)),
+	}
+	mutated, err := mutation.Apply(req, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "static", mutated.Headers["X-Token"])
+	assert.Equal(t, "10.0.0.1 curl", mutated.Headers["X-Client"])
+	assert.Equal(t, "user", req.Headers["X-Token"], "original request is not changed")
+	body, err := ioutil.ReadAll(mutated.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"count": 12345678901234567890, "meta": null}`, string(body), "nothing to fill, body is not re-encoded")
+
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"meta": {"id": 1}}`))
+	mutated, err = mutation.Apply(req, 0)
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(mutated.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"count":1,"meta":{"id":1,"source":"hook"}}`, string(body))
+	assert.Equal(t, "43", mutated.Headers["Content-Length"])
+
+	for _, raw := range []string{`[1, 2]`, `not json`, `{"meta": {"id": 1}, "padding": "more than limit"}`} {
+		req.Body = ioutil.NopCloser(strings.NewReader(raw))
+		mutated, err = mutation.Apply(req, 32)
+		require.NoError(t, err)
+		body, err = ioutil.ReadAll(mutated.Body)
+		require.NoError(t, err)
+		assert.Equal(t, raw, string(body), "passed as is")
+	}
+}
+
+func TestMutation_validate(t *testing.T) {
+	for _, mutation := range []types.Mutation{
+		{Headers: map[string]string{"Bad Name": "x"}},
+		{Headers: map[string]string{"X-Value": "{{.Unclosed"}},
+		{Headers: map[string]string{"X-Value": strings.Repeat("x", types.MaxMutationValue+1)}},
+		{BodyDefaults: map[string]interface{}{"big": strings.Repeat("x", types.MaxBodyDefaults)}},
+	} {
+		mutation := mutation
+		assert.Error(t, (&types.Manifest{Mutate: &mutation}).Validate())
+	}
+}
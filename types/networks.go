@@ -0,0 +1,172 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Networks is list of IPv4 and IPv6 networks in CIDR notation
+type Networks []*net.IPNet
+
+// ParseNetworks parses list of networks in CIDR notation (ex: 10.0.0.0/8, 2001:db8::/32). Single address without
+// prefix length is treated as network of one address. Error names the first invalid network
+func ParseNetworks(cidrs []string) (Networks, error) {
+	var list = make(Networks, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 8*net.IPv4len
+				}
+				list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, network)
+	}
+	return list, nil
+}
+
+// Contains IP in any of networks
+func (n Networks) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsAddress checks IP of address (host:port or just host) in any of networks
+func (n Networks) ContainsAddress(address string) bool {
+	return n.Contains(AddressIP(address))
+}
+
+// AddressIP is IP of address with or without port (nil - not an IP)
+func AddressIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	// zone of IPv6 link-local address is not part of IP
+	address, _, _ = strings.Cut(address, "%")
+	return net.ParseIP(strings.TrimSpace(address))
+}
+
+// Headers of address of client set by proxy (canonical names)
+const (
+	HeaderXRealIP       = "X-Real-Ip"       // address of client
+	HeaderXForwardedFor = "X-Forwarded-For" // chain of addresses: client and proxies
+	HeaderForwarded     = "Forwarded"       // chain of elements with for= parameter (RFC 7239)
+)
+
+// DefaultForwardedHeaders is order of preference of forwarded headers by default: only the chain, so address of
+// client is the last hop added by proxies. X-Real-Ip and Forwarded are passed as is by proxies which don't set them,
+// so they are respected only if enabled
+var DefaultForwardedHeaders = []string{HeaderXForwardedFor}
+
+// ParseForwardedHeaders checks names of forwarded headers (case-insensitive) and returns canonical names. Error names
+// the first unknown header
+func ParseForwardedHeaders(names []string) ([]string, error) {
+	var ans = make([]string, 0, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		switch name {
+		case HeaderXRealIP, HeaderXForwardedFor, HeaderForwarded:
+			ans = append(ans, name)
+		default:
+			return nil, fmt.Errorf("unknown forwarded header %q: %s, %s or %s expected", name, HeaderXRealIP, HeaderXForwardedFor, HeaderForwarded)
+		}
+	}
+	return ans, nil
+}
+
+// ForwardedAddress is address of client by forwarded headers if the request is from one of trusted proxies,
+// otherwise address of connection. The first present header of preference is used (empty - DefaultForwardedHeaders),
+// so headers of lower preference could not override it. Chains (X-Forwarded-For and Forwarded) are walked from the
+// nearest proxy: the first address which is not a trusted proxy is the client. Value which is not an IP (on the way
+// from the nearest proxy) is not used: address of connection is returned
+func ForwardedAddress(r *http.Request, proxies Networks, headers ...string) string {
+	if !proxies.ContainsAddress(r.RemoteAddr) {
+		return r.RemoteAddr
+	}
+	if len(headers) == 0 {
+		headers = DefaultForwardedHeaders
+	}
+	for _, header := range headers {
+		var chain []string
+		switch header {
+		case HeaderXRealIP:
+			if value := r.Header.Get(HeaderXRealIP); value != "" {
+				chain = []string{value}
+			}
+		case HeaderXForwardedFor:
+			for _, value := range r.Header.Values(HeaderXForwardedFor) {
+				chain = append(chain, strings.Split(value, ",")...)
+			}
+		case HeaderForwarded:
+			chain = forwardedFor(r.Header.Values(HeaderForwarded))
+		}
+		if len(chain) == 0 {
+			continue
+		}
+		if ip := clientOfChain(chain, proxies); ip != nil {
+			return ip.String()
+		}
+		return r.RemoteAddr
+	}
+	return r.RemoteAddr
+}
+
+// the first hop from the end of chain which is not a trusted proxy (all hops are proxies - the first hop). Hop which is
+// not an IP breaks the chain (nil - no client)
+func clientOfChain(chain []string, proxies Networks) net.IP {
+	var client net.IP
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(chain[i])
+		if hop == "" {
+			continue
+		}
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			return nil
+		}
+		client = ip
+		if !proxies.Contains(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// nodes of for= parameters of Forwarded header values: quotes, brackets of IPv6 and ports are removed, obfuscated and
+// unknown nodes are kept as is (they are not IP)
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var node string
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(pair, "=")
+				if strings.EqualFold(strings.TrimSpace(key), "for") {
+					node = strings.Trim(strings.TrimSpace(value), `"`)
+				}
+			}
+			if host, _, err := net.SplitHostPort(node); err == nil {
+				node = host
+			}
+			chain = append(chain, strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+		}
+	}
+	return chain
+}
@@ -0,0 +1,83 @@
+package types_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestNetworks_ContainsAddress(t *testing.T) {
+	networks, err := types.ParseNetworks([]string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.10"})
+	require.NoError(t, err)
+	assert.True(t, networks.ContainsAddress("10.1.2.3:8080"))
+	assert.True(t, networks.ContainsAddress("10.1.2.3"))
+	assert.True(t, networks.ContainsAddress("[2001:db8::1]:443"))
+	assert.True(t, networks.ContainsAddress("2001:db8::1"))
+	assert.True(t, networks.ContainsAddress("192.168.1.10:1"))
+	assert.True(t, networks.ContainsAddress("::ffff:10.0.0.1"))
+	assert.False(t, networks.ContainsAddress("192.168.1.11:1"))
+	assert.False(t, networks.ContainsAddress("[2001:db9::1]:443"))
+	assert.False(t, networks.ContainsAddress("example.com:80"))
+	assert.False(t, networks.ContainsAddress(""))
+
+	_, err = types.ParseNetworks([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestForwardedAddress(t *testing.T) {
+	proxies, err := types.ParseNetworks([]string{"127.0.0.1", "10.0.0.0/8"})
+	require.NoError(t, err)
+	request := func(peer string, headers ...string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		req.RemoteAddr = peer
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Add(headers[i], headers[i+1])
+		}
+		return req
+	}
+	// not a trusted proxy
+	assert.Equal(t, "1.2.3.4:100", types.ForwardedAddress(request("1.2.3.4:100", "X-Forwarded-For", "5.6.7.8"), proxies))
+	// X-Real-Ip of client is passed as is by proxy which sets only X-Forwarded-For: it is not respected by default
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(request("127.0.0.1:100", "X-Real-Ip", "5.6.7.8"), proxies))
+	assert.Equal(t, "9.9.9.9", types.ForwardedAddress(request("127.0.0.1:100", "X-Real-Ip", "5.6.7.8", "X-Forwarded-For", "9.9.9.9"), proxies))
+	assert.Equal(t, "5.6.7.8", types.ForwardedAddress(request("127.0.0.1:100", "X-Real-Ip", "5.6.7.8"), proxies, types.HeaderXRealIP))
+	// spoofed address in front of chain is ignored
+	assert.Equal(t, "5.6.7.8", types.ForwardedAddress(request("127.0.0.1:100", "X-Forwarded-For", "9.9.9.9, 5.6.7.8, 10.0.0.2"), proxies))
+	assert.Equal(t, "2001:db8::1", types.ForwardedAddress(request("127.0.0.1:100", "X-Forwarded-For", "2001:db8::1", "X-Forwarded-For", "10.0.0.2"), proxies))
+	// all hops are proxies
+	assert.Equal(t, "10.0.0.3", types.ForwardedAddress(request("127.0.0.1:100", "X-Forwarded-For", "10.0.0.3, 10.0.0.2"), proxies))
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(request("127.0.0.1:100"), proxies))
+
+	// values are IP
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(request("127.0.0.1:100", "X-Forwarded-For", "5.6.7.8, <script>, 10.0.0.2"), proxies))
+	assert.Equal(t, "5.6.7.8", types.ForwardedAddress(request("127.0.0.1:100", "X-Forwarded-For", "<script>, 5.6.7.8"), proxies))
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(request("127.0.0.1:100", "X-Real-Ip", "example.com"), proxies, types.HeaderXRealIP))
+
+	// RFC 7239
+	assert.Equal(t, "1.2.3.4:100", types.ForwardedAddress(request("1.2.3.4:100", "Forwarded", "for=5.6.7.8"), proxies, types.HeaderForwarded))
+	assert.Equal(t, "5.6.7.8", types.ForwardedAddress(request("127.0.0.1:100", "Forwarded", `for=9.9.9.9, For="5.6.7.8:4711";proto=https`, "Forwarded", "for=10.0.0.2;by=10.0.0.1"), proxies, types.HeaderForwarded))
+	assert.Equal(t, "2001:db8::1", types.ForwardedAddress(request("127.0.0.1:100", "Forwarded", `for="[2001:db8::1]:4711"`), proxies, types.HeaderForwarded))
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(request("127.0.0.1:100", "Forwarded", "for=unknown"), proxies, types.HeaderForwarded), "unknown client is not an IP")
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(request("127.0.0.1:100", "Forwarded", "for=5.6.7.8"), proxies), "not respected by default")
+	// preference of headers: the first present header wins
+	both := request("127.0.0.1:100", "X-Real-Ip", "9.9.9.9", "X-Forwarded-For", "5.6.7.8")
+	assert.Equal(t, "5.6.7.8", types.ForwardedAddress(both, proxies))
+	assert.Equal(t, "9.9.9.9", types.ForwardedAddress(both, proxies, types.HeaderXRealIP, types.HeaderXForwardedFor))
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(both, proxies, types.HeaderForwarded), "other headers are ignored")
+	invalid := request("127.0.0.1:100", "X-Real-Ip", "unknown", "X-Forwarded-For", "5.6.7.8")
+	assert.Equal(t, "127.0.0.1:100", types.ForwardedAddress(invalid, proxies, types.HeaderXRealIP, types.HeaderXForwardedFor), "invalid header is not replaced by others")
+}
+
+func TestParseForwardedHeaders(t *testing.T) {
+	headers, err := types.ParseForwardedHeaders([]string{"forwarded", " x-forwarded-for", "X-REAL-IP"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{types.HeaderForwarded, types.HeaderXForwardedFor, types.HeaderXRealIP}, headers)
+
+	_, err = types.ParseForwardedHeaders([]string{"X-Client-Ip"})
+	assert.Error(t, err)
+}
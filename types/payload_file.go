@@ -0,0 +1,24 @@
+package types
+
+// Default limit of request body spooled to file (see Manifest.MaximumFilePayload)
+const DefaultMaximumFilePayload = 64 * 1024 * 1024
+
+// Environment variable with path of file with request body (see Manifest.PayloadAsFile)
+const PayloadFileEnv = "REQUEST_BODY_FILE"
+
+// FilePayloadLimit is limit of request body spooled to file: maximum file payload or default
+func (mf *Manifest) FilePayloadLimit() int64 {
+	if mf.MaximumFilePayload > 0 {
+		return mf.MaximumFilePayload
+	}
+	return DefaultMaximumFilePayload
+}
+
+// PayloadLimit is effective limit of request body: file payload limit if body is spooled to file (or decoded to
+// files), otherwise maximum payload (zero - unlimited)
+func (mf *Manifest) PayloadLimit() int64 {
+	if mf.PayloadAsFile || mf.DecodeMultipart {
+		return mf.FilePayloadLimit()
+	}
+	return mf.MaximumPayload
+}
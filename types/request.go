@@ -3,31 +3,47 @@ package types
 import (
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //go:generate msgp
 type Request struct {
+	ID            string            `json:"id,omitempty" msg:"id,omitempty"` // request ID (see RequestIDHeader)
 	Method        string            `json:"method" msg:"method"`
 	URL           string            `json:"url" msg:"url"`
 	Path          string            `json:"path" msg:"path"`
 	RemoteAddress string            `json:"remote_address" msg:"remote_address"`
 	Form          map[string]string `json:"form" msg:"form"`
 	Headers       map[string]string `json:"headers" msg:"headers"`
+	PublicURL     string            `json:"public_url,omitempty" msg:"public_url,omitempty"` // public base URL of server (without trailing slash)
+	Alias         string            `json:"alias,omitempty" msg:"alias,omitempty"`           // link (alias) under which request arrived
+	Chain         []string          `json:"chain,omitempty" msg:"chain,omitempty"`           // UIDs of lambdas which output produced request (see Manifest.OnSuccess and Manifest.Then)
+	Queued        time.Time         `json:"queued,omitempty" msg:"queued,omitempty"`         // time when request was put to queue (zero - not queued)
+	User          string            `json:"user,omitempty" msg:"user,omitempty"`             // user authenticated by server (see Manifest.BasicAuth and Manifest.JWT)
+	Claims        map[string]string `json:"claims,omitempty" msg:"claims,omitempty"`         // selected claims of verified bearer token (see Manifest.JWT)
 	Body          io.ReadCloser     `json:"-" msg:"-"`
 }
 
-// Create request from HTTP request
+// Create request from HTTP request. Form is filled by query of URL only: body is not read, so it is limited (and
+// 100-continue is sent) only when the request is accepted
 func FromHTTP(r *http.Request, behindProxy bool) *Request {
-	_ = r.ParseForm()
 	var vals = make(map[string]string)
-	for k, v := range r.Form {
+	for k, v := range r.URL.Query() {
 		vals[k] = v[0]
 	}
 	var headers = make(map[string]string)
 	for k, v := range r.Header {
 		headers[k] = v[0]
 	}
+	// net/http moves framing headers to request fields
+	if _, ok := headers["Content-Length"]; !ok && r.ContentLength > 0 {
+		headers["Content-Length"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+	if len(r.TransferEncoding) > 0 {
+		headers["Transfer-Encoding"] = strings.Join(r.TransferEncoding, ", ")
+	}
 	var address string
 	if behindProxy {
 		address = getRequestAddress(r)
@@ -55,6 +71,21 @@ func (z *Request) WithBody(reader io.ReadCloser) *Request {
 	return &cp
 }
 
+// Raw query string of requested URL (without ?)
+func (z *Request) Query() string {
+	_, query, _ := strings.Cut(z.URL, "?")
+	return query
+}
+
+// Path remainder after lambda (queue) UID or link, with leading slash. Empty if nothing left
+func (z *Request) PathInfo() string {
+	_, rest, ok := strings.Cut(strings.TrimPrefix(z.Path, "/"), "/")
+	if !ok {
+		return ""
+	}
+	return "/" + rest
+}
+
 func getRequestAddress(r *http.Request) string {
 	address := getFirstHeader(r, r.RemoteAddr, "X-Real-Ip", "X-Forwarded-For")
 	address = strings.TrimSpace(strings.SplitN(address, ",", 2)[0])
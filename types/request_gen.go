@@ -3,6 +3,8 @@ package types
 // Code generated by github.com/tinylib/msgp DO NOT EDIT.
 
 import (
+	"time"
+
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -24,6 +26,12 @@ func (z *Request) DecodeMsg(dc *msgp.Reader) (err error) {
 			return
 		}
 		switch msgp.UnsafeString(field) {
+		case "id":
+			z.ID, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
 		case "method":
 			z.Method, err = dc.ReadString()
 			if err != nil {
@@ -108,6 +116,79 @@ func (z *Request) DecodeMsg(dc *msgp.Reader) (err error) {
 				}
 				z.Headers[za0003] = za0004
 			}
+		case "public_url":
+			z.PublicURL, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "PublicURL")
+				return
+			}
+		case "alias":
+			z.Alias, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Alias")
+				return
+			}
+		case "chain":
+			var zb0004 uint32
+			zb0004, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Chain")
+				return
+			}
+			if cap(z.Chain) >= int(zb0004) {
+				z.Chain = (z.Chain)[:zb0004]
+			} else {
+				z.Chain = make([]string, zb0004)
+			}
+			for za0005 := range z.Chain {
+				z.Chain[za0005], err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Chain", za0005)
+					return
+				}
+			}
+		case "queued":
+			z.Queued, err = dc.ReadTime()
+			if err != nil {
+				err = msgp.WrapError(err, "Queued")
+				return
+			}
+		case "user":
+			z.User, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "User")
+				return
+			}
+		case "claims":
+			var zb0005 uint32
+			zb0005, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Claims")
+				return
+			}
+			if z.Claims == nil {
+				z.Claims = make(map[string]string, zb0005)
+			} else if len(z.Claims) > 0 {
+				for key := range z.Claims {
+					delete(z.Claims, key)
+				}
+			}
+			for zb0005 > 0 {
+				zb0005--
+				var za0006 string
+				var za0007 string
+				za0006, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Claims")
+					return
+				}
+				za0007, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Claims", za0006)
+					return
+				}
+				z.Claims[za0006] = za0007
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -121,9 +202,60 @@ func (z *Request) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Request) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 6
+	// omitempty: check for empty values
+	zb0001Len := uint32(13)
+	var zb0001Mask uint16 /* 13 bits */
+	_ = zb0001Mask
+	if z.ID == "" {
+		zb0001Len--
+		zb0001Mask |= 0x1
+	}
+	if z.PublicURL == "" {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.Alias == "" {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.Chain == nil {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Queued == (time.Time{}) {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	if z.User == "" {
+		zb0001Len--
+		zb0001Mask |= 0x800
+	}
+	if z.Claims == nil {
+		zb0001Len--
+		zb0001Mask |= 0x1000
+	}
+	// variable map header, size zb0001Len
+	err = en.Append(0x80 | uint8(zb0001Len))
+	if err != nil {
+		return
+	}
+	if zb0001Len == 0 {
+		return
+	}
+	if (zb0001Mask & 0x1) == 0 { // if not empty
+		// write "id"
+		err = en.Append(0xa2, 0x69, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.ID)
+		if err != nil {
+			err = msgp.WrapError(err, "ID")
+			return
+		}
+	}
 	// write "method"
-	err = en.Append(0x86, 0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
+	err = en.Append(0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
 	if err != nil {
 		return
 	}
@@ -206,15 +338,147 @@ func (z *Request) EncodeMsg(en *msgp.Writer) (err error) {
 			return
 		}
 	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// write "public_url"
+		err = en.Append(0xaa, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x75, 0x72, 0x6c)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.PublicURL)
+		if err != nil {
+			err = msgp.WrapError(err, "PublicURL")
+			return
+		}
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// write "alias"
+		err = en.Append(0xa5, 0x61, 0x6c, 0x69, 0x61, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Alias)
+		if err != nil {
+			err = msgp.WrapError(err, "Alias")
+			return
+		}
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// write "chain"
+		err = en.Append(0xa5, 0x63, 0x68, 0x61, 0x69, 0x6e)
+		if err != nil {
+			return
+		}
+		err = en.WriteArrayHeader(uint32(len(z.Chain)))
+		if err != nil {
+			err = msgp.WrapError(err, "Chain")
+			return
+		}
+		for za0005 := range z.Chain {
+			err = en.WriteString(z.Chain[za0005])
+			if err != nil {
+				err = msgp.WrapError(err, "Chain", za0005)
+				return
+			}
+		}
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// write "queued"
+		err = en.Append(0xa6, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteTime(z.Queued)
+		if err != nil {
+			err = msgp.WrapError(err, "Queued")
+			return
+		}
+	}
+	if (zb0001Mask & 0x800) == 0 { // if not empty
+		// write "user"
+		err = en.Append(0xa4, 0x75, 0x73, 0x65, 0x72)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.User)
+		if err != nil {
+			err = msgp.WrapError(err, "User")
+			return
+		}
+	}
+	if (zb0001Mask & 0x1000) == 0 { // if not empty
+		// write "claims"
+		err = en.Append(0xa6, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteMapHeader(uint32(len(z.Claims)))
+		if err != nil {
+			err = msgp.WrapError(err, "Claims")
+			return
+		}
+		for za0006, za0007 := range z.Claims {
+			err = en.WriteString(za0006)
+			if err != nil {
+				err = msgp.WrapError(err, "Claims")
+				return
+			}
+			err = en.WriteString(za0007)
+			if err != nil {
+				err = msgp.WrapError(err, "Claims", za0006)
+				return
+			}
+		}
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Request) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 6
+	// omitempty: check for empty values
+	zb0001Len := uint32(13)
+	var zb0001Mask uint16 /* 13 bits */
+	_ = zb0001Mask
+	if z.ID == "" {
+		zb0001Len--
+		zb0001Mask |= 0x1
+	}
+	if z.PublicURL == "" {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.Alias == "" {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.Chain == nil {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Queued == (time.Time{}) {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	if z.User == "" {
+		zb0001Len--
+		zb0001Mask |= 0x800
+	}
+	if z.Claims == nil {
+		zb0001Len--
+		zb0001Mask |= 0x1000
+	}
+	// variable map header, size zb0001Len
+	o = append(o, 0x80|uint8(zb0001Len))
+	if zb0001Len == 0 {
+		return
+	}
+	if (zb0001Mask & 0x1) == 0 { // if not empty
+		// string "id"
+		o = append(o, 0xa2, 0x69, 0x64)
+		o = msgp.AppendString(o, z.ID)
+	}
 	// string "method"
-	o = append(o, 0x86, 0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
+	o = append(o, 0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
 	o = msgp.AppendString(o, z.Method)
 	// string "url"
 	o = append(o, 0xa3, 0x75, 0x72, 0x6c)
@@ -239,6 +503,43 @@ func (z *Request) MarshalMsg(b []byte) (o []byte, err error) {
 		o = msgp.AppendString(o, za0003)
 		o = msgp.AppendString(o, za0004)
 	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// string "public_url"
+		o = append(o, 0xaa, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x75, 0x72, 0x6c)
+		o = msgp.AppendString(o, z.PublicURL)
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// string "alias"
+		o = append(o, 0xa5, 0x61, 0x6c, 0x69, 0x61, 0x73)
+		o = msgp.AppendString(o, z.Alias)
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// string "chain"
+		o = append(o, 0xa5, 0x63, 0x68, 0x61, 0x69, 0x6e)
+		o = msgp.AppendArrayHeader(o, uint32(len(z.Chain)))
+		for za0005 := range z.Chain {
+			o = msgp.AppendString(o, z.Chain[za0005])
+		}
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// string "queued"
+		o = append(o, 0xa6, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64)
+		o = msgp.AppendTime(o, z.Queued)
+	}
+	if (zb0001Mask & 0x800) == 0 { // if not empty
+		// string "user"
+		o = append(o, 0xa4, 0x75, 0x73, 0x65, 0x72)
+		o = msgp.AppendString(o, z.User)
+	}
+	if (zb0001Mask & 0x1000) == 0 { // if not empty
+		// string "claims"
+		o = append(o, 0xa6, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x73)
+		o = msgp.AppendMapHeader(o, uint32(len(z.Claims)))
+		for za0006, za0007 := range z.Claims {
+			o = msgp.AppendString(o, za0006)
+			o = msgp.AppendString(o, za0007)
+		}
+	}
 	return
 }
 
@@ -260,6 +561,12 @@ func (z *Request) UnmarshalMsg(bts []byte) (o []byte, err error) {
 			return
 		}
 		switch msgp.UnsafeString(field) {
+		case "id":
+			z.ID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
 		case "method":
 			z.Method, bts, err = msgp.ReadStringBytes(bts)
 			if err != nil {
@@ -344,6 +651,79 @@ func (z *Request) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				}
 				z.Headers[za0003] = za0004
 			}
+		case "public_url":
+			z.PublicURL, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "PublicURL")
+				return
+			}
+		case "alias":
+			z.Alias, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Alias")
+				return
+			}
+		case "chain":
+			var zb0004 uint32
+			zb0004, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Chain")
+				return
+			}
+			if cap(z.Chain) >= int(zb0004) {
+				z.Chain = (z.Chain)[:zb0004]
+			} else {
+				z.Chain = make([]string, zb0004)
+			}
+			for za0005 := range z.Chain {
+				z.Chain[za0005], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Chain", za0005)
+					return
+				}
+			}
+		case "queued":
+			z.Queued, bts, err = msgp.ReadTimeBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Queued")
+				return
+			}
+		case "user":
+			z.User, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "User")
+				return
+			}
+		case "claims":
+			var zb0005 uint32
+			zb0005, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Claims")
+				return
+			}
+			if z.Claims == nil {
+				z.Claims = make(map[string]string, zb0005)
+			} else if len(z.Claims) > 0 {
+				for key := range z.Claims {
+					delete(z.Claims, key)
+				}
+			}
+			for zb0005 > 0 {
+				var za0006 string
+				var za0007 string
+				zb0005--
+				za0006, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Claims")
+					return
+				}
+				za0007, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Claims", za0006)
+					return
+				}
+				z.Claims[za0006] = za0007
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -358,7 +738,7 @@ func (z *Request) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Request) Msgsize() (s int) {
-	s = 1 + 7 + msgp.StringPrefixSize + len(z.Method) + 4 + msgp.StringPrefixSize + len(z.URL) + 5 + msgp.StringPrefixSize + len(z.Path) + 15 + msgp.StringPrefixSize + len(z.RemoteAddress) + 5 + msgp.MapHeaderSize
+	s = 1 + 3 + msgp.StringPrefixSize + len(z.ID) + 7 + msgp.StringPrefixSize + len(z.Method) + 4 + msgp.StringPrefixSize + len(z.URL) + 5 + msgp.StringPrefixSize + len(z.Path) + 15 + msgp.StringPrefixSize + len(z.RemoteAddress) + 5 + msgp.MapHeaderSize
 	if z.Form != nil {
 		for za0001, za0002 := range z.Form {
 			_ = za0002
@@ -372,5 +752,16 @@ func (z *Request) Msgsize() (s int) {
 			s += msgp.StringPrefixSize + len(za0003) + msgp.StringPrefixSize + len(za0004)
 		}
 	}
+	s += 11 + msgp.StringPrefixSize + len(z.PublicURL) + 6 + msgp.StringPrefixSize + len(z.Alias) + 6 + msgp.ArrayHeaderSize
+	for za0005 := range z.Chain {
+		s += msgp.StringPrefixSize + len(z.Chain[za0005])
+	}
+	s += 7 + msgp.TimeSize + 5 + msgp.StringPrefixSize + len(z.User) + 7 + msgp.MapHeaderSize
+	if z.Claims != nil {
+		for za0006, za0007 := range z.Claims {
+			_ = za0007
+			s += msgp.StringPrefixSize + len(za0006) + msgp.StringPrefixSize + len(za0007)
+		}
+	}
 	return
 }
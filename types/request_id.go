@@ -0,0 +1,42 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader     = "X-Request-Id" // header of request ID: reused from request (if valid) and set in response
+	RequestIDEnv        = "REQUEST_ID"   // environment variable with request ID of invocation
+	CronRequestPrefix   = "cron-"        // prefix of request ID of scheduled invocation
+	QueueRequestPrefix  = "queue-"       // prefix of request ID of invocation from queue
+	ReplayRequestPrefix = "replay-"      // prefix of request ID of replay of captured request
+)
+
+// incoming request ID is logged and passed to environment as is, so only safe characters are accepted
+var requestIDReg = regexp.MustCompile(`^[a-zA-Z0-9._:@+=/-]{1,128}$`)
+
+// NewRequestID generates unique request ID with prefix of origin (empty for HTTP requests)
+func NewRequestID(prefix string) string {
+	return prefix + uuid.New().String()
+}
+
+// ValidRequestID checks that incoming request ID (see RequestIDHeader) is not empty, not too long and has only safe
+// characters
+func ValidRequestID(id string) bool {
+	return requestIDReg.MatchString(id)
+}
+
+// OriginRequestID is request ID of invocation from another origin: ID with prefix of origin (once) to correlate with
+// request which produced it, or new ID if request has no ID
+func OriginRequestID(prefix string, id string) string {
+	if id == "" {
+		return NewRequestID(prefix)
+	}
+	if strings.HasPrefix(id, prefix) {
+		return id
+	}
+	return prefix + id
+}
@@ -0,0 +1,28 @@
+package types_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestRequestID(t *testing.T) {
+	id := types.NewRequestID(types.CronRequestPrefix)
+	assert.True(t, strings.HasPrefix(id, "cron-"))
+	assert.True(t, types.ValidRequestID(id))
+	assert.NotEqual(t, id, types.NewRequestID(types.CronRequestPrefix))
+
+	assert.True(t, types.ValidRequestID("abc-123_x.y:z"))
+	assert.False(t, types.ValidRequestID(""))
+	assert.False(t, types.ValidRequestID("abc\nfake log line"))
+	assert.False(t, types.ValidRequestID("a b"))
+	assert.False(t, types.ValidRequestID(strings.Repeat("a", 129)))
+
+	assert.Equal(t, "queue-abc", types.OriginRequestID(types.QueueRequestPrefix, "abc"))
+	assert.Equal(t, "queue-abc", types.OriginRequestID(types.QueueRequestPrefix, "queue-abc"), "prefix is added once")
+	assert.Equal(t, "queue-cron-abc", types.OriginRequestID(types.QueueRequestPrefix, "cron-abc"))
+	assert.True(t, strings.HasPrefix(types.OriginRequestID(types.QueueRequestPrefix, ""), "queue-"))
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Names of resource limits of lambda processes (fields of ResourceLimits)
+const (
+	ResourceMemory    = "memory_mb"
+	ResourceCPU       = "cpu_percent"
+	ResourceOpenFiles = "open_files"
+	ResourceProcesses = "processes"
+)
+
+// Resource limits of lambda processes: invocations, workers and actions (post-clone, on_start, scheduled and manual).
+// Empty values mean not defined. Limits are enforced by cgroup v2 (if server has delegated cgroup) and by rlimits of
+// the process, see docs of manifest for details.
+type ResourceLimits struct {
+	MemoryMB   int64 `json:"memory_mb,omitempty"`   // memory in MiB, process over the limit is killed (cgroup) or fails to allocate (rlimit)
+	CPUPercent int   `json:"cpu_percent,omitempty"` // CPU in percents of one core (ex: 50, 200 - two cores), requires cgroup v2
+	OpenFiles  int   `json:"open_files,omitempty"`  // maximum number of open files of each process
+	Processes  int   `json:"processes,omitempty"`   // maximum number of processes (cgroup) or processes of user (rlimit)
+}
+
+// Memory limit in bytes (zero - not defined)
+func (rl ResourceLimits) Memory() int64 {
+	return rl.MemoryMB * 1024 * 1024
+}
+
+// DiskQuota is limit of size of lambda directory in bytes (zero - not limited)
+func (mf *Manifest) DiskQuota() int64 {
+	return mf.DiskQuotaMB * 1024 * 1024
+}
+
+// CPU limit in cores (zero - not defined)
+func (rl ResourceLimits) CPU() float64 {
+	return float64(rl.CPUPercent) / 100
+}
+
+// Names of defined limits in order of fields
+func (rl ResourceLimits) Defined() []string {
+	var ans []string
+	if rl.MemoryMB > 0 {
+		ans = append(ans, ResourceMemory)
+	}
+	if rl.CPUPercent > 0 {
+		ans = append(ans, ResourceCPU)
+	}
+	if rl.OpenFiles > 0 {
+		ans = append(ans, ResourceOpenFiles)
+	}
+	if rl.Processes > 0 {
+		ans = append(ans, ResourceProcesses)
+	}
+	return ans
+}
+
+// Validate limits: values are not negative.
+func (rl ResourceLimits) Validate() error {
+	var errs []error
+	if rl.MemoryMB < 0 {
+		errs = append(errs, fmt.Errorf("memory limit %d MiB is negative", rl.MemoryMB))
+	}
+	if rl.CPUPercent < 0 {
+		errs = append(errs, fmt.Errorf("cpu limit %d%% is negative", rl.CPUPercent))
+	}
+	if rl.OpenFiles < 0 {
+		errs = append(errs, fmt.Errorf("open files limit %d is negative", rl.OpenFiles))
+	}
+	if rl.Processes < 0 {
+		errs = append(errs, fmt.Errorf("processes limit %d is negative", rl.Processes))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Default delay before the first retry
+const DefaultRetryBackoff = time.Second
+
+// Retry policy of failed (non-zero exit, time limit) queued and scheduled invocations. Delay before every next retry
+// is doubled. Invocations by HTTP are never retried
+type Retry struct {
+	Attempts   int          `json:"attempts"`              // total number of attempts including the first one (zero or 1 - no retries)
+	Backoff    JsonDuration `json:"backoff,omitempty"`     // delay before the first retry (zero - 1s)
+	MaxBackoff JsonDuration `json:"max_backoff,omitempty"` // upper bound of delay (zero - not bounded)
+	Jitter     bool         `json:"jitter,omitempty"`      // randomize delay between half and full value
+}
+
+// Total number of attempts
+func (rt *Retry) Total() int {
+	if rt == nil || rt.Attempts < 1 {
+		return 1
+	}
+	return rt.Attempts
+}
+
+// Delay after failed attempt (1 - the first one) before the next attempt
+func (rt *Retry) Delay(attempt int) time.Duration {
+	delay := DefaultRetryBackoff
+	if rt.Backoff > 0 {
+		delay = time.Duration(rt.Backoff)
+	}
+	for i := 1; i < attempt && (rt.MaxBackoff <= 0 || delay < time.Duration(rt.MaxBackoff)); i++ {
+		delay *= 2
+	}
+	if rt.MaxBackoff > 0 && delay > time.Duration(rt.MaxBackoff) {
+		delay = time.Duration(rt.MaxBackoff)
+	}
+	if rt.Jitter && delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	return delay
+}
+
+func (rt *Retry) validate() error {
+	var errs []error
+	if rt.Attempts < 0 {
+		errs = append(errs, fmt.Errorf("number of attempts should not be negative"))
+	}
+	if rt.Backoff < 0 {
+		errs = append(errs, fmt.Errorf("backoff should not be negative"))
+	}
+	if rt.MaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("maximum backoff should not be negative"))
+	} else if rt.MaxBackoff > 0 && rt.Backoff > rt.MaxBackoff {
+		errs = append(errs, fmt.Errorf("backoff should not be greater than maximum backoff"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,52 @@
+package types
+
+import "strings"
+
+// Public prefixes of invocation paths. The first element after prefix is UID of lambda, alias (link) or queue name,
+// the rest of path is passed to lambda
+const (
+	LambdaPrefix = "/a/"      // invoke lambda by UID
+	LinkPrefix   = "/l/"      // invoke lambda by alias (link)
+	QueuePrefix  = "/q/"      // put request to queue
+	AsyncPrefix  = "/async/"  // invoke lambda in background: followed by LambdaPrefix or LinkPrefix path
+	ResultPrefix = "/result/" // result of asynchronous invocation by ticket
+)
+
+// Public prefix of ping of repository which triggers deploy of lambda by UID from git (see GitSource.Ping)
+const GitPrefix = "/git/"
+
+// GitPath is path of ping of repository of lambda
+func GitPath(uid string) string {
+	return GitPrefix + uid
+}
+
+// LambdaPath is invocation path of lambda by UID
+func LambdaPath(uid string) string {
+	return LambdaPrefix + uid
+}
+
+// LinkPath is invocation path of lambda by alias
+func LinkPath(alias string) string {
+	return LinkPrefix + alias
+}
+
+// QueuePath is path of queue
+func QueuePath(queue string) string {
+	return QueuePrefix + queue
+}
+
+// AsyncPath is path of asynchronous invocation of lambda by invocation path (see LambdaPath and LinkPath)
+func AsyncPath(path string) string {
+	return AsyncPrefix + strings.TrimPrefix(path, "/")
+}
+
+// ResultPath is path of result of asynchronous invocation
+func ResultPath(ticket string) string {
+	return ResultPrefix + ticket
+}
+
+// Ticket of accepted asynchronous invocation
+type AsyncTicket struct {
+	Ticket string `json:"ticket"` // unguessable ID of invocation
+	Result string `json:"result"` // path of result (see ResultPath)
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Name of user or group of system account
+var AccountNameReg = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,31}$`)
+
+// System account to run processes of lambda (invocations and actions) instead of user of server (see Config.User).
+// Accounts should be allowed by security profile, server should run as root to switch users
+type RunAs struct {
+	User  string `json:"user"`            // name of user
+	Group string `json:"group,omitempty"` // name of group (empty - primary group of user)
+}
+
+func (ra *RunAs) String() string {
+	if ra.Group == "" {
+		return ra.User
+	}
+	return ra.User + ":" + ra.Group
+}
+
+func (ra *RunAs) validate() error {
+	var errs []error
+	if !AccountNameReg.MatchString(ra.User) {
+		errs = append(errs, fmt.Errorf("invalid user %q: should match %s", ra.User, AccountNameReg.String()))
+	}
+	if ra.Group != "" && !AccountNameReg.MatchString(ra.Group) {
+		errs = append(errs, fmt.Errorf("invalid group %q: should match %s", ra.Group, AccountNameReg.String()))
+	}
+	return errors.Join(errs...)
+}
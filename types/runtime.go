@@ -0,0 +1,80 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Runtime settings of child process. Empty values mean not defined.
+type Runtime struct {
+	Umask string `json:"umask,omitempty"`  // file mode creation mask in octal (ex: 0022)
+	Lang  string `json:"lang,omitempty"`   // LANG environment variable
+	LcAll string `json:"lc_all,omitempty"` // LC_ALL environment variable
+	TZ    string `json:"tz,omitempty"`     // TZ environment variable
+	// limits of arguments and environment of invocations (nil - defaults, see EnvLimits)
+	EnvLimits *EnvLimits `json:"env_limits,omitempty"`
+}
+
+// Override returns copy of runtime with non-empty values from other runtime.
+func (rt Runtime) Override(other Runtime) Runtime {
+	if other.Umask != "" {
+		rt.Umask = other.Umask
+	}
+	if other.Lang != "" {
+		rt.Lang = other.Lang
+	}
+	if other.LcAll != "" {
+		rt.LcAll = other.LcAll
+	}
+	if other.TZ != "" {
+		rt.TZ = other.TZ
+	}
+	if other.EnvLimits != nil {
+		limits := rt.Env().Override(*other.EnvLimits)
+		rt.EnvLimits = &limits
+	}
+	return rt
+}
+
+// Environment variables (LANG, LC_ALL, TZ) defined by runtime.
+func (rt Runtime) Environment() map[string]string {
+	var env = make(map[string]string)
+	if rt.Lang != "" {
+		env["LANG"] = rt.Lang
+	}
+	if rt.LcAll != "" {
+		env["LC_ALL"] = rt.LcAll
+	}
+	if rt.TZ != "" {
+		env["TZ"] = rt.TZ
+	}
+	return env
+}
+
+// Env limits defined by runtime (empty if not defined).
+func (rt Runtime) Env() EnvLimits {
+	if rt.EnvLimits == nil {
+		return EnvLimits{}
+	}
+	return *rt.EnvLimits
+}
+
+// Validate runtime settings: umask should be octal number not greater than 0777, env limits are valid.
+func (rt Runtime) Validate() error {
+	if rt.EnvLimits != nil {
+		if err := rt.EnvLimits.Validate(); err != nil {
+			return fmt.Errorf("env limits: %w", err)
+		}
+	}
+	if rt.Umask == "" {
+		return nil
+	}
+	v, err := strconv.ParseUint(rt.Umask, 8, 32)
+	if err != nil {
+		return fmt.Errorf("umask %s is not octal number: %w", rt.Umask, err)
+	}
+	if v > 0777 {
+		return fmt.Errorf("umask %s is out of range (0000-0777)", rt.Umask)
+	}
+	return nil
+}
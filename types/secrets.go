@@ -0,0 +1,114 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Delivery modes of secrets
+const (
+	SecretsEnv  = "env"  // environment variables (default)
+	SecretsFD   = "fd"   // JSON object in inherited pipe, number of descriptor in environment variable
+	SecretsFile = "file" // JSON object in temporary file (tmpfs if available) readable only by lambda, path in environment variable
+)
+
+// Default variables with descriptor number or file path of secrets
+const (
+	DefaultSecretsFDEnv   = "SECRETS_FD"
+	DefaultSecretsFileEnv = "SECRETS_FILE"
+)
+
+// Delivery of secret variables (from global and lambda environment) to invocation. Secrets delivered by
+// descriptor or file are removed from environment, so they are not visible in /proc/<pid>/environ and not
+// inherited by child processes of lambda.
+type Secrets struct {
+	Delivery string   `json:"delivery,omitempty"` // env (default), fd or file
+	Keys     []string `json:"keys,omitempty"`     // secret variables, empty - variables with _SECRET or _TOKEN suffix
+	Env      string   `json:"env,omitempty"`      // variable with descriptor number or file path (default SECRETS_FD or SECRETS_FILE)
+}
+
+// Effective delivery mode
+func (sc *Secrets) Mode() string {
+	if sc == nil || sc.Delivery == "" {
+		return SecretsEnv
+	}
+	return sc.Delivery
+}
+
+// Variable with descriptor number or file path, empty for env mode
+func (sc *Secrets) Variable() string {
+	switch sc.Mode() {
+	case SecretsFD:
+		if sc.Env != "" {
+			return sc.Env
+		}
+		return DefaultSecretsFDEnv
+	case SecretsFile:
+		if sc.Env != "" {
+			return sc.Env
+		}
+		return DefaultSecretsFileEnv
+	}
+	return ""
+}
+
+// Is variable secret
+func (sc *Secrets) IsSecret(key string) bool {
+	if sc == nil || len(sc.Keys) == 0 {
+		return IsSecretName(key)
+	}
+	for _, name := range sc.Keys {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *Secrets) validate() error {
+	switch sc.Mode() {
+	case SecretsEnv, SecretsFD, SecretsFile:
+	default:
+		return fmt.Errorf("unknown secrets delivery %s", sc.Delivery)
+	}
+	if strings.ContainsAny(sc.Env, "= \t\r\n\x00") {
+		return fmt.Errorf("invalid secrets variable name %q", sc.Env)
+	}
+	return nil
+}
+
+// Default rule of secret variables: name with _SECRET or _TOKEN suffix (case-insensitive)
+func IsSecretName(key string) bool {
+	key = strings.ToUpper(key)
+	return strings.HasSuffix(key, "_SECRET") || strings.HasSuffix(key, "_TOKEN")
+}
+
+// Prefix of value of environment variable which references secret of server store (ex: @secret:stripe_key)
+const SecretPrefix = "@secret:"
+
+// Secret name limitations
+var SecretNameReg = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+
+// SecretReference is name of secret referenced by value of environment variable
+func SecretReference(value string) (string, bool) {
+	if !strings.HasPrefix(value, SecretPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, SecretPrefix), true
+}
+
+// SecretReferences is names of secrets referenced by environment (sorted, without duplicates)
+func SecretReferences(env map[string]string) []string {
+	var names []string
+	var seen = make(map[string]bool)
+	for _, value := range env {
+		if name, ok := SecretReference(value); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,329 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Names of security profiles
+const (
+	ProfileDefault = "default" // no defaults and restrictions: manifests define everything
+	ProfileStrict  = "strict"  // hardened defaults for untrusted code, all rules are mandatory
+	ProfileCustom  = "custom"  // defaults and mandatory rules from file
+)
+
+// Rules of security profile: per-lambda options with default values of profile
+const (
+	RuleNetwork         = "network"          // processes of lambda have no network access (see Manifest.Network)
+	RuleReadOnly        = "read_only"        // content of lambda is not writable by processes (see Manifest.ReadOnly)
+	RuleTimeLimit       = "time_limit"       // default and maximum time limit of invocation
+	RuleMaximumPayload  = "maximum_payload"  // default and maximum payload of invocation
+	RuleMaximumResponse = "maximum_response" // default and maximum response of invocation
+	RuleMaxConcurrency  = "max_concurrency"  // default and maximum concurrent invocations
+	RuleRemoveHeaders   = "remove_headers"   // response headers removed for lambda
+)
+
+// All rules of security profile in order of report
+var Rules = []string{RuleNetwork, RuleReadOnly, RuleTimeLimit, RuleMaximumPayload, RuleMaximumResponse, RuleMaxConcurrency, RuleRemoveHeaders}
+
+// Security profile of server: default values of per-lambda safety options and rules which manifests can not relax.
+// Manifest value overrides default of profile unless rule is mandatory: mandatory limits could be only lowered,
+// mandatory isolation could not be disabled and mandatory removed headers are added to headers of manifest.
+// Zero value is the default profile.
+type SecurityProfile struct {
+	Name            string       `json:"name"`
+	DenyNetwork     bool         `json:"deny_network,omitempty"`     // processes have no network access by default
+	ReadOnly        bool         `json:"read_only,omitempty"`        // content is not writable by processes by default
+	TimeLimit       JsonDuration `json:"time_limit,omitempty"`       // default time limit (zero - not set)
+	MaximumPayload  int64        `json:"maximum_payload,omitempty"`  // default maximum payload (zero - not set)
+	MaximumResponse int64        `json:"maximum_response,omitempty"` // default maximum response (zero - not set)
+	MaxConcurrency  int          `json:"max_concurrency,omitempty"`  // default max concurrency (zero - not set)
+	RemoveHeaders   []string     `json:"remove_headers,omitempty"`   // default removed response headers
+	DisableDebug    bool         `json:"disable_debug,omitempty"`    // server refuses to start in dev mode or with disabled chroot
+	RequireRunner   bool         `json:"require_runner,omitempty"`   // lambdas should run as dedicated user (see Config.User)
+	Mandatory       []string     `json:"mandatory,omitempty"`        // rules which manifests can not relax
+	Upload          UploadLimits `json:"upload"`                     // limits of uploaded content
+	RunAsUsers      []string     `json:"run_as_users,omitempty"`     // users allowed in manifests to run lambdas (see Manifest.RunAs)
+	RunAsGroups     []string     `json:"run_as_groups,omitempty"`    // groups allowed in manifests to run lambdas
+	MaxGracePeriod  JsonDuration `json:"max_grace_period,omitempty"` // maximum grace period of termination (zero - DefaultMaxGracePeriod)
+}
+
+// Strict profile for untrusted code
+func StrictProfile() SecurityProfile {
+	return SecurityProfile{
+		Name:            ProfileStrict,
+		DenyNetwork:     true,
+		ReadOnly:        true,
+		TimeLimit:       JsonDuration(30 * time.Second),
+		MaximumPayload:  1024 * 1024,
+		MaximumResponse: 10 * 1024 * 1024,
+		MaxConcurrency:  4,
+		RemoveHeaders:   []string{"Server", "X-Powered-By"},
+		DisableDebug:    true,
+		RequireRunner:   true,
+		Mandatory:       append([]string{}, Rules...),
+	}
+}
+
+// Profile by name: default, strict or custom from JSON file
+func LoadProfile(name string, file string) (SecurityProfile, error) {
+	switch name {
+	case "", ProfileDefault:
+		return SecurityProfile{Name: ProfileDefault}, nil
+	case ProfileStrict:
+		return StrictProfile(), nil
+	case ProfileCustom:
+	default:
+		return SecurityProfile{}, fmt.Errorf("unknown security profile %s", name)
+	}
+	if file == "" {
+		return SecurityProfile{}, fmt.Errorf("file of custom security profile is not set")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return SecurityProfile{}, fmt.Errorf("read security profile: %w", err)
+	}
+	var profile SecurityProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return SecurityProfile{}, fmt.Errorf("parse security profile %s: %w", file, err)
+	}
+	profile.Name = ProfileCustom
+	return profile, profile.Validate()
+}
+
+// Validate profile: known mandatory rules, non-negative limits and valid header names
+func (sp SecurityProfile) Validate() error {
+	var errs fieldErrors
+	for i, rule := range sp.Mandatory {
+		if !isRule(rule) {
+			errs.addf(fmt.Sprintf("mandatory[%d]", i), "unknown rule %s", rule)
+		}
+	}
+	if sp.TimeLimit < 0 {
+		errs.addf("time_limit", "time limit should not be negative")
+	}
+	if sp.MaximumPayload < 0 {
+		errs.addf("maximum_payload", "maximum payload should not be negative")
+	}
+	if sp.MaximumResponse < 0 {
+		errs.addf("maximum_response", "maximum response should not be negative")
+	}
+	if sp.MaxConcurrency < 0 {
+		errs.addf("max_concurrency", "max concurrency should not be negative")
+	}
+	if sp.MaxGracePeriod < 0 {
+		errs.addf("max_grace_period", "max grace period should not be negative")
+	}
+	for i, name := range sp.RemoveHeaders {
+		if !httpguts.ValidHeaderFieldName(name) {
+			errs.addf(fmt.Sprintf("remove_headers[%d]", i), "invalid name of removed header %q", name)
+		}
+	}
+	errs.add("upload", sp.Upload.validate())
+	for i, name := range sp.RunAsUsers {
+		if !AccountNameReg.MatchString(name) {
+			errs.addf(fmt.Sprintf("run_as_users[%d]", i), "invalid user %q", name)
+		}
+	}
+	for i, name := range sp.RunAsGroups {
+		if !AccountNameReg.MatchString(name) {
+			errs.addf(fmt.Sprintf("run_as_groups[%d]", i), "invalid group %q", name)
+		}
+	}
+	return errs.err()
+}
+
+// Rule is mandatory for manifests
+func (sp SecurityProfile) IsMandatory(rule string) bool {
+	for _, name := range sp.Mandatory {
+		if name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Effective manifest: unset options are filled by defaults of profile, mandatory rules are enforced
+func (sp SecurityProfile) Apply(mf Manifest) Manifest {
+	network := !sp.DenyNetwork
+	if mf.Network != nil && !(sp.DenyNetwork && sp.IsMandatory(RuleNetwork)) {
+		network = *mf.Network
+	}
+	readOnly := sp.ReadOnly
+	if mf.ReadOnly != nil && !(sp.ReadOnly && sp.IsMandatory(RuleReadOnly)) {
+		readOnly = *mf.ReadOnly
+	}
+	mf.Network, mf.ReadOnly = &network, &readOnly
+	mf.TimeLimit = JsonDuration(limitOf(int64(mf.TimeLimit), int64(sp.TimeLimit), sp.IsMandatory(RuleTimeLimit)))
+	if mf.StreamTimeLimit > 0 && sp.IsMandatory(RuleTimeLimit) && sp.TimeLimit > 0 && mf.StreamTimeLimit > sp.TimeLimit {
+		// mandatory time limit bounds streaming as well
+		mf.StreamTimeLimit = sp.TimeLimit
+	}
+	mf.GracePeriod = JsonDuration(min(mf.Grace(), sp.GraceLimit()))
+	mf.MaximumPayload = limitOf(mf.MaximumPayload, sp.MaximumPayload, sp.IsMandatory(RuleMaximumPayload))
+	mf.MaximumResponse = limitOf(mf.MaximumResponse, sp.MaximumResponse, sp.IsMandatory(RuleMaximumResponse))
+	mf.MaxConcurrency = int(limitOf(int64(mf.MaxConcurrency), int64(sp.MaxConcurrency), sp.IsMandatory(RuleMaxConcurrency)))
+	if len(mf.RemoveHeaders) == 0 {
+		mf.RemoveHeaders = sp.RemoveHeaders
+	} else if sp.IsMandatory(RuleRemoveHeaders) {
+		mf.RemoveHeaders = append(append([]string{}, mf.RemoveHeaders...), missingHeaders(mf.RemoveHeaders, sp.RemoveHeaders)...)
+	}
+	return mf
+}
+
+// Check that manifest doesn't relax mandatory rules and runs lambda only by allowed account. Violations are reported
+// as *ValidationError with fields of manifest and names of violated rules
+func (sp SecurityProfile) Check(mf Manifest) error {
+	var errs fieldErrors
+	for _, deviation := range sp.Deviations(mf) {
+		if deviation.Violation {
+			errs.addf(deviation.Rule, "%s relaxes %s: mandatory rule %s of security profile %s", deviation.Value, deviation.Default, deviation.Rule, sp.Name)
+		}
+	}
+	errs.add("run_as", sp.AllowRunAs(mf.RunAs))
+	return errs.err()
+}
+
+// AllowRunAs checks that account is allowed to run lambdas (nil account is always allowed)
+func (sp SecurityProfile) AllowRunAs(ra *RunAs) error {
+	if ra == nil {
+		return nil
+	}
+	var errs []error
+	if !hasName(sp.RunAsUsers, ra.User) {
+		errs = append(errs, fmt.Errorf("user %s is not allowed to run lambdas by security profile %s (see run_as_users)", ra.User, sp.Name))
+	}
+	if ra.Group != "" && !hasName(sp.RunAsGroups, ra.Group) {
+		errs = append(errs, fmt.Errorf("group %s is not allowed to run lambdas by security profile %s (see run_as_groups)", ra.Group, sp.Name))
+	}
+	return errors.Join(errs...)
+}
+
+func hasName(names []string, name string) bool {
+	for _, item := range names {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Options of manifest which differ from defaults of profile, in order of rules
+func (sp SecurityProfile) Deviations(mf Manifest) []Deviation {
+	var ans []Deviation
+	add := func(rule string, value, def string, violation bool) {
+		ans = append(ans, Deviation{
+			Rule:      rule,
+			Value:     value,
+			Default:   def,
+			Mandatory: sp.IsMandatory(rule),
+			Violation: violation && sp.IsMandatory(rule),
+		})
+	}
+	if mf.Network != nil && *mf.Network == sp.DenyNetwork {
+		add(RuleNetwork, networkText(*mf.Network), networkText(!sp.DenyNetwork), *mf.Network)
+	}
+	if mf.ReadOnly != nil && *mf.ReadOnly != sp.ReadOnly {
+		add(RuleReadOnly, readOnlyText(*mf.ReadOnly), readOnlyText(sp.ReadOnly), !*mf.ReadOnly)
+	}
+	if mf.TimeLimit != 0 && mf.TimeLimit != sp.TimeLimit {
+		add(RuleTimeLimit, durationText(mf.TimeLimit), durationText(sp.TimeLimit), sp.TimeLimit > 0 && mf.TimeLimit > sp.TimeLimit)
+	}
+	if mf.MaximumPayload != 0 && mf.MaximumPayload != sp.MaximumPayload {
+		add(RuleMaximumPayload, bytesText(mf.MaximumPayload), bytesText(sp.MaximumPayload), sp.MaximumPayload > 0 && mf.MaximumPayload > sp.MaximumPayload)
+	}
+	if mf.MaximumResponse != 0 && mf.MaximumResponse != sp.MaximumResponse {
+		add(RuleMaximumResponse, bytesText(mf.MaximumResponse), bytesText(sp.MaximumResponse), sp.MaximumResponse > 0 && mf.MaximumResponse > sp.MaximumResponse)
+	}
+	if mf.MaxConcurrency != 0 && mf.MaxConcurrency != sp.MaxConcurrency {
+		add(RuleMaxConcurrency, countText(int64(mf.MaxConcurrency)), countText(int64(sp.MaxConcurrency)), sp.MaxConcurrency > 0 && mf.MaxConcurrency > sp.MaxConcurrency)
+	}
+	// removed headers could not be relaxed: mandatory headers are always added
+	if len(mf.RemoveHeaders) > 0 && len(sp.RemoveHeaders) > 0 && !sp.IsMandatory(RuleRemoveHeaders) && len(missingHeaders(mf.RemoveHeaders, sp.RemoveHeaders)) > 0 {
+		add(RuleRemoveHeaders, strings.Join(mf.RemoveHeaders, ", "), strings.Join(sp.RemoveHeaders, ", "), false)
+	}
+	return ans
+}
+
+// Option of manifest which differs from default of security profile
+type Deviation struct {
+	Rule      string `json:"rule"`
+	Value     string `json:"value"`     // value in manifest
+	Default   string `json:"default"`   // value of profile
+	Mandatory bool   `json:"mandatory"` // rule is mandatory
+	Violation bool   `json:"violation"` // manifest relaxes mandatory rule, value of profile is used instead
+}
+
+// effective limit by manifest and profile values (zero - unlimited). Mandatory limit of profile could be only lowered
+func limitOf(value, def int64, mandatory bool) int64 {
+	if value == 0 || (mandatory && def > 0 && value > def) {
+		return def
+	}
+	return value
+}
+
+// headers of profile not listed in headers of manifest (case-insensitive)
+func missingHeaders(headers, required []string) []string {
+	var listed = make(map[string]bool, len(headers))
+	for _, name := range headers {
+		listed[textproto.CanonicalMIMEHeaderKey(name)] = true
+	}
+	var ans []string
+	for _, name := range required {
+		if !listed[textproto.CanonicalMIMEHeaderKey(name)] {
+			ans = append(ans, name)
+		}
+	}
+	return ans
+}
+
+func isRule(name string) bool {
+	for _, rule := range Rules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func networkText(allowed bool) string {
+	if allowed {
+		return "network allowed"
+	}
+	return "network denied"
+}
+
+func readOnlyText(readOnly bool) string {
+	if readOnly {
+		return "read-only content"
+	}
+	return "writable content"
+}
+
+func durationText(value JsonDuration) string {
+	if value == 0 {
+		return "unlimited"
+	}
+	return time.Duration(value).String()
+}
+
+func bytesText(value int64) string {
+	if value == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(value, " bytes")
+}
+
+func countText(value int64) string {
+	if value == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(value)
+}
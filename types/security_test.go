@@ -0,0 +1,129 @@
+package types_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestSecurityProfile_Apply(t *testing.T) {
+	allowed := true
+	manifest := types.Manifest{
+		Network:        &allowed,
+		TimeLimit:      types.JsonDuration(time.Minute),
+		MaximumPayload: 1024,
+		RemoveHeaders:  []string{"x-powered-by", "X-Debug"},
+	}
+
+	strict := types.StrictProfile()
+	effective := strict.Apply(manifest)
+	assert.False(t, *effective.Network, "mandatory isolation")
+	assert.True(t, *effective.ReadOnly)
+	assert.Equal(t, strict.TimeLimit, effective.TimeLimit, "mandatory limit is not raised")
+	assert.Equal(t, int64(1024), effective.MaximumPayload, "mandatory limit is lowered")
+	assert.Equal(t, strict.MaximumResponse, effective.MaximumResponse)
+	assert.Equal(t, []string{"x-powered-by", "X-Debug", "Server"}, effective.RemoveHeaders)
+
+	relaxed := strict
+	relaxed.Mandatory = nil
+	effective = relaxed.Apply(manifest)
+	assert.True(t, *effective.Network)
+	assert.Equal(t, types.JsonDuration(time.Minute), effective.TimeLimit)
+	assert.Equal(t, []string{"x-powered-by", "X-Debug"}, effective.RemoveHeaders)
+
+	effective = types.SecurityProfile{}.Apply(types.Manifest{})
+	assert.True(t, *effective.Network, "default profile")
+	assert.False(t, *effective.ReadOnly)
+	assert.Zero(t, effective.TimeLimit)
+}
+
+func TestSecurityProfile_ApplyGracePeriod(t *testing.T) {
+	effective := types.SecurityProfile{}.Apply(types.Manifest{})
+	assert.Equal(t, types.JsonDuration(types.DefaultGracePeriod), effective.GracePeriod)
+
+	effective = types.SecurityProfile{}.Apply(types.Manifest{GracePeriod: types.JsonDuration(time.Hour)})
+	assert.Equal(t, types.JsonDuration(types.DefaultMaxGracePeriod), effective.GracePeriod, "capped by default")
+
+	profile := types.SecurityProfile{MaxGracePeriod: types.JsonDuration(time.Second)}
+	effective = profile.Apply(types.Manifest{})
+	assert.Equal(t, types.JsonDuration(time.Second), effective.GracePeriod, "default is capped too")
+
+	profile.MaxGracePeriod = types.JsonDuration(-time.Second)
+	assert.EqualError(t, profile.Validate(), "max_grace_period: max grace period should not be negative")
+}
+
+func TestSecurityProfile_Check(t *testing.T) {
+	allowed := true
+	manifest := types.Manifest{
+		Network:        &allowed,
+		TimeLimit:      types.JsonDuration(time.Minute),
+		MaximumPayload: 1024,
+	}
+	strict := types.StrictProfile()
+
+	deviations := strict.Deviations(manifest)
+	require.Len(t, deviations, 3)
+	assert.Equal(t, types.Deviation{Rule: types.RuleNetwork, Value: "network allowed", Default: "network denied", Mandatory: true, Violation: true}, deviations[0])
+	assert.Equal(t, types.RuleTimeLimit, deviations[1].Rule)
+	assert.True(t, deviations[1].Violation)
+	assert.Equal(t, types.RuleMaximumPayload, deviations[2].Rule)
+	assert.False(t, deviations[2].Violation, "lower limit is allowed")
+
+	err := strict.Check(manifest)
+	var invalid *types.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Fields, 2)
+	assert.Equal(t, "network", invalid.Fields[0].Field)
+	assert.Equal(t, "time_limit: 1m0s relaxes 30s: mandatory rule time_limit of security profile strict", invalid.Fields[1].Error())
+
+	assert.NoError(t, types.SecurityProfile{Name: types.ProfileDefault}.Check(manifest))
+}
+
+func TestSecurityProfile_AllowRunAs(t *testing.T) {
+	profile := types.SecurityProfile{Name: types.ProfileCustom, RunAsUsers: []string{"app"}, RunAsGroups: []string{"web"}}
+	assert.NoError(t, profile.AllowRunAs(nil))
+	assert.NoError(t, profile.AllowRunAs(&types.RunAs{User: "app"}))
+	assert.NoError(t, profile.AllowRunAs(&types.RunAs{User: "app", Group: "web"}))
+	assert.ErrorContains(t, profile.AllowRunAs(&types.RunAs{User: "root"}), "user root is not allowed to run lambdas by security profile custom")
+	assert.ErrorContains(t, profile.AllowRunAs(&types.RunAs{User: "app", Group: "root"}), "group root is not allowed")
+
+	err := profile.Check(types.Manifest{RunAs: &types.RunAs{User: "root"}})
+	var invalid *types.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "run_as", invalid.Fields[0].Field)
+
+	profile.RunAsUsers = append(profile.RunAsUsers, "bad name")
+	assert.ErrorContains(t, profile.Validate(), "run_as_users[1]")
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "security.json")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"deny_network": true, "time_limit": "10s", "mandatory": ["network"]}`), 0600))
+	profile, err := types.LoadProfile(types.ProfileCustom, file)
+	require.NoError(t, err)
+	assert.Equal(t, types.ProfileCustom, profile.Name)
+	assert.True(t, profile.IsMandatory(types.RuleNetwork))
+	assert.Equal(t, types.JsonDuration(10*time.Second), profile.TimeLimit)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"mandatory": ["sandbox"]}`), 0600))
+	_, err = types.LoadProfile(types.ProfileCustom, file)
+	assert.ErrorContains(t, err, "mandatory[0]: unknown rule sandbox")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"upload": {"files": 100, "depth": -1}}`), 0600))
+	_, err = types.LoadProfile(types.ProfileCustom, file)
+	assert.ErrorContains(t, err, "upload: maximum depth should not be negative")
+
+	_, err = types.LoadProfile("paranoid", "")
+	assert.Error(t, err)
+}
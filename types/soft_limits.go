@@ -0,0 +1,87 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default minimal interval between notifications about the same soft limit
+const DefaultSoftLimitInterval = time.Hour
+
+// Names of limits
+const (
+	LimitPayload  = "payload"  // request payload (maximum_payload)
+	LimitResponse = "response" // output of lambda (maximum_response)
+)
+
+// Soft limits: warning thresholds in percents of hard limits (maximum_payload, maximum_response). Invocations
+// bigger than threshold are served as usual, but annotated in stats, counted in metrics and reported by notification
+// action. Hard limits still apply.
+type SoftLimits struct {
+	Payload  int          `json:"payload,omitempty"`  // warn at percent of maximum_payload (1-99), zero - disabled
+	Response int          `json:"response,omitempty"` // warn at percent of maximum_response (1-99), zero - disabled
+	Notify   string       `json:"notify,omitempty"`   // action (Makefile target) to invoke on warning, optional
+	Interval JsonDuration `json:"interval,omitempty"` // minimal interval between notifications of the same limit (zero - 1h)
+}
+
+// Minimal interval between notifications of the same limit
+func (sl *SoftLimits) NotifyInterval() time.Duration {
+	if sl.Interval <= 0 {
+		return DefaultSoftLimitInterval
+	}
+	return time.Duration(sl.Interval)
+}
+
+// Soft limit warning of invocation
+type LimitWarning struct {
+	Limit     string // name of limit, see Limit* constants
+	Size      int64  // actual size in bytes
+	Threshold int64  // warning threshold in bytes
+	Maximum   int64  // hard limit in bytes
+}
+
+func (lw LimitWarning) String() string {
+	return fmt.Sprintf("%s %d bytes is above warning threshold %d bytes (maximum %d)", lw.Limit, lw.Size, lw.Threshold, lw.Maximum)
+}
+
+// Warnings of soft limits by size of payload and response. Sizes above hard limit are reported as well.
+func (mf *Manifest) LimitWarnings(payload, response int64) []LimitWarning {
+	if mf.SoftLimits == nil {
+		return nil
+	}
+	var ans []LimitWarning
+	if threshold := softThreshold(mf.SoftLimits.Payload, mf.MaximumPayload); threshold > 0 && payload >= threshold {
+		ans = append(ans, LimitWarning{Limit: LimitPayload, Size: payload, Threshold: threshold, Maximum: mf.MaximumPayload})
+	}
+	if threshold := softThreshold(mf.SoftLimits.Response, mf.MaximumResponse); threshold > 0 && response >= threshold {
+		ans = append(ans, LimitWarning{Limit: LimitResponse, Size: response, Threshold: threshold, Maximum: mf.MaximumResponse})
+	}
+	return ans
+}
+
+// threshold in bytes by percent of hard limit, zero - disabled
+func softThreshold(percent int, maximum int64) int64 {
+	if percent <= 0 || maximum <= 0 {
+		return 0
+	}
+	return maximum * int64(percent) / 100
+}
+
+func (sl *SoftLimits) validate(mf *Manifest) error {
+	var errs []error
+	if sl.Payload < 0 || sl.Payload > 99 {
+		errs = append(errs, fmt.Errorf("payload warning should be percent of maximum payload between 1 and 99"))
+	} else if sl.Payload > 0 && mf.MaximumPayload <= 0 {
+		errs = append(errs, fmt.Errorf("payload warning requires maximum payload"))
+	}
+	if sl.Response < 0 || sl.Response > 99 {
+		errs = append(errs, fmt.Errorf("response warning should be percent of maximum response between 1 and 99"))
+	} else if sl.Response > 0 && mf.MaximumResponse <= 0 {
+		errs = append(errs, fmt.Errorf("response warning requires maximum response"))
+	}
+	if sl.Interval < 0 {
+		errs = append(errs, fmt.Errorf("notification interval should not be negative"))
+	}
+	return errors.Join(errs...)
+}
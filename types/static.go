@@ -0,0 +1,76 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StaticFile resolves path of request inside lambda (see Request.PathInfo) to configured directory and file inside
+// lambda by the longest matched prefix of StaticDirs. Directory is resolved to index.html. Returns false if path is
+// not mapped, file is empty if path contains hidden (dot) segments.
+func (mf *Manifest) StaticFile(requestPath string) (dir string, file string, ok bool) {
+	var matched string
+	var found bool
+	for prefix := range mf.StaticDirs {
+		if !hasPathPrefix(requestPath, prefix) {
+			continue
+		}
+		if !found || len(prefix) > len(matched) {
+			matched, found = prefix, true
+		}
+	}
+	if !found {
+		return "", "", false
+	}
+	dir = filepath.ToSlash(filepath.Clean(mf.StaticDirs[matched]))
+	rest := strings.TrimPrefix(requestPath, strings.TrimSuffix(matched, "/"))
+	for _, segment := range strings.Split(rest, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return dir, "", true
+		}
+	}
+	if rest == "" || strings.HasSuffix(rest, "/") {
+		rest += "/index.html"
+	}
+	return dir, path.Join(dir, rest), true
+}
+
+// prefix matches whole segments: /assets and /assets/ match /assets/app.js, but not /assets2
+func hasPathPrefix(requestPath, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(requestPath, prefix) {
+		return false
+	}
+	rest := requestPath[len(prefix):]
+	return rest == "" || rest[0] == '/'
+}
+
+func validateStaticDirs(dirs map[string]string) error {
+	var prefixes = make([]string, 0, len(dirs))
+	for prefix := range dirs {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	var errs []error
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("static prefix %q should start with /", prefix))
+		}
+		dir := dirs[prefix]
+		if dir == "" || !filepath.IsLocal(dir) {
+			errs = append(errs, fmt.Errorf("static dir %q of prefix %s should be relative path inside lambda", dir, prefix))
+			continue
+		}
+		for _, segment := range strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/") {
+			if segment != "." && strings.HasPrefix(segment, ".") {
+				errs = append(errs, fmt.Errorf("static dir %q of prefix %s should not be hidden", dir, prefix))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,46 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestManifest_StaticFile(t *testing.T) {
+	mf := types.Manifest{StaticDirs: map[string]string{"/": "www", "/assets/": "public/", "/assets/img": "images"}}
+	for path, expected := range map[string]string{
+		"":                   "www/index.html",
+		"/page.html":         "www/page.html",
+		"/assets":            "public/index.html",
+		"/assets/app.js":     "public/app.js",
+		"/assets2/app.js":    "www/assets2/app.js",
+		"/assets/img/a.png":  "images/a.png",
+		"/assets/css/":       "public/css/index.html",
+		"/assets/.env":       "",
+		"/assets/../secrets": "",
+	} {
+		_, file, ok := mf.StaticFile(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected, file, path)
+	}
+
+	mf.StaticDirs = map[string]string{"/assets": "public"}
+	_, _, ok := mf.StaticFile("/index.html")
+	assert.False(t, ok)
+}
+
+func TestManifest_ValidateStaticDirs(t *testing.T) {
+	mf := types.Manifest{StaticDirs: map[string]string{"/": ".", "/assets/": "public/assets"}}
+	assert.NoError(t, mf.Validate())
+
+	mf.StaticDirs = map[string]string{"assets": "public", "/up": "../other", "/hidden": ".git", "/abs": "/etc"}
+	err := mf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `static prefix "assets" should start with /`)
+		assert.Contains(t, err.Error(), `static dir "../other" of prefix /up should be relative path inside lambda`)
+		assert.Contains(t, err.Error(), `static dir "/etc" of prefix /abs should be relative path inside lambda`)
+		assert.Contains(t, err.Error(), `static dir ".git" of prefix /hidden should not be hidden`)
+	}
+}
@@ -0,0 +1,33 @@
+package types
+
+import "time"
+
+// Streaming modes of lambda output (see Manifest.Streaming)
+const (
+	StreamingSSE = "sse" // Server-Sent Events: output is passed through as text/event-stream and flushed by lines
+)
+
+// Name of the last event of stream closed by server, data of the event is reason (StreamClosed*)
+const StreamCloseEvent = "close"
+
+// Reasons of stream closed by server
+const (
+	StreamClosedLifetime     = "lifetime"     // stream time limit is reached
+	StreamClosedIdle         = "idle"         // lambda emitted nothing for stream idle timeout
+	StreamClosedUnauthorized = "unauthorized" // access policy denies request on re-authorization
+	StreamClosedShutdown     = "shutdown"     // server is shutting down
+)
+
+// Output of lambda is streamed as Server-Sent Events
+func (mf *Manifest) SSE() bool {
+	return mf.Streaming == StreamingSSE
+}
+
+// InvocationLimit is time limit of invocation: stream time limit for streaming lambda (if set), otherwise time limit.
+// Zero is infinity
+func (mf *Manifest) InvocationLimit() time.Duration {
+	if mf.SSE() && mf.StreamTimeLimit > 0 {
+		return time.Duration(mf.StreamTimeLimit)
+	}
+	return time.Duration(mf.TimeLimit)
+}
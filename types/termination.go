@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// Default time between SIGTERM and SIGKILL of terminated invocation (see Manifest.GracePeriod)
+const DefaultGracePeriod = 2 * time.Second
+
+// Default maximum grace period of lambdas (see SecurityProfile.MaxGracePeriod)
+const DefaultMaxGracePeriod = 30 * time.Second
+
+// Grace is time between SIGTERM and SIGKILL of terminated process: grace period or default
+func (mf *Manifest) Grace() time.Duration {
+	if mf.GracePeriod > 0 {
+		return time.Duration(mf.GracePeriod)
+	}
+	return DefaultGracePeriod
+}
+
+// GraceLimit is maximum grace period of lambdas: max grace period or default
+func (sp SecurityProfile) GraceLimit() time.Duration {
+	if sp.MaxGracePeriod > 0 {
+		return time.Duration(sp.MaxGracePeriod)
+	}
+	return DefaultMaxGracePeriod
+}
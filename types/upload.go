@@ -0,0 +1,131 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Default limits of uploaded content: protection from archive bombs
+const (
+	DefaultUploadFiles = 10000   // maximum number of files and directories
+	DefaultUploadSize  = 1 << 30 // maximum total size of extracted files
+	DefaultUploadDepth = 64      // maximum number of elements in path of file
+)
+
+// Upload rejected by limits of uploaded content (see UploadLimitError)
+var ErrUploadLimit = errors.New("upload limit exceeded")
+
+// Names of upload limits
+const (
+	LimitFiles    = "files"
+	LimitSize     = "size"
+	LimitDepth    = "depth"
+	LimitFileSize = "file_size"
+)
+
+// Limits of uploaded archives (content of lambdas). Zero value of field is default limit
+type UploadLimits struct {
+	Files    int   `json:"files,omitempty"`     // maximum number of entries (files and directories), zero - DefaultUploadFiles
+	Size     int64 `json:"size,omitempty"`      // maximum total size of extracted files, zero - DefaultUploadSize
+	Depth    int   `json:"depth,omitempty"`     // maximum number of elements in path, zero - DefaultUploadDepth
+	FileSize int64 `json:"file_size,omitempty"` // maximum size of single file, zero - same as total size
+}
+
+// Effective limits: unset fields are filled by defaults
+func (ul UploadLimits) Effective() UploadLimits {
+	if ul.Files == 0 {
+		ul.Files = DefaultUploadFiles
+	}
+	if ul.Size == 0 {
+		ul.Size = DefaultUploadSize
+	}
+	if ul.Depth == 0 {
+		ul.Depth = DefaultUploadDepth
+	}
+	if ul.FileSize == 0 || ul.FileSize > ul.Size {
+		ul.FileSize = ul.Size
+	}
+	return ul
+}
+
+func (ul UploadLimits) validate() error {
+	var errs []error
+	if ul.Files < 0 {
+		errs = append(errs, errors.New("maximum number of files should not be negative"))
+	}
+	if ul.Size < 0 {
+		errs = append(errs, errors.New("maximum size should not be negative"))
+	}
+	if ul.Depth < 0 {
+		errs = append(errs, errors.New("maximum depth should not be negative"))
+	}
+	if ul.FileSize < 0 {
+		errs = append(errs, errors.New("maximum size of file should not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Counter of archive entries by effective limits. Entries are checked before extraction, so upload is rejected on
+// first entry over limit
+func (ul UploadLimits) Counter() *UploadCounter {
+	return &UploadCounter{limits: ul.Effective()}
+}
+
+// Usage of upload limits by entries of archive
+type UploadCounter struct {
+	limits UploadLimits
+	files  int
+	size   int64
+}
+
+// Entry of archive (slash-separated name relative to root): checks number of entries and depth of path
+func (uc *UploadCounter) Entry(name string) error {
+	uc.files++
+	if uc.files > uc.limits.Files {
+		return &UploadLimitError{Limit: LimitFiles, Path: name, Value: int64(uc.files), Maximum: int64(uc.limits.Files)}
+	}
+	if depth := len(strings.Split(name, "/")); depth > uc.limits.Depth {
+		return &UploadLimitError{Limit: LimitDepth, Path: name, Value: int64(depth), Maximum: int64(uc.limits.Depth)}
+	}
+	return nil
+}
+
+// File of archive with declared size: checks size of file and total size of files
+func (uc *UploadCounter) File(name string, size int64) error {
+	if size > uc.limits.FileSize {
+		return &UploadLimitError{Limit: LimitFileSize, Path: name, Value: size, Maximum: uc.limits.FileSize}
+	}
+	uc.size += size
+	if uc.size > uc.limits.Size {
+		return &UploadLimitError{Limit: LimitSize, Path: name, Value: uc.size, Maximum: uc.limits.Size}
+	}
+	return nil
+}
+
+// Upload rejected by limit: which limit is exceeded, by which entry and by how much
+type UploadLimitError struct {
+	Limit   string // name of limit: files, size, depth or file_size
+	Path    string // entry which exceeded the limit
+	Value   int64  // value with the entry (number of all entries for limit of files)
+	Maximum int64  // limit
+}
+
+func (ule *UploadLimitError) Error() string {
+	var what string
+	switch ule.Limit {
+	case LimitFiles:
+		what = fmt.Sprintf("archive has %d files and directories, maximum is %d (%d over)", ule.Value, ule.Maximum, ule.Value-ule.Maximum)
+	case LimitDepth:
+		what = fmt.Sprintf("path %s has depth %d, maximum is %d (%d over)", ule.Path, ule.Value, ule.Maximum, ule.Value-ule.Maximum)
+	case LimitFileSize:
+		what = fmt.Sprintf("file %s has %d bytes, maximum is %d (%d over)", ule.Path, ule.Value, ule.Maximum, ule.Value-ule.Maximum)
+	default:
+		what = fmt.Sprintf("extracted files have at least %d bytes with %s, maximum is %d (%d over)", ule.Value, ule.Path, ule.Maximum, ule.Value-ule.Maximum)
+	}
+	return ErrUploadLimit.Error() + " (" + ule.Limit + "): " + what + "; exclude files from upload by .cgiignore"
+}
+
+func (ule *UploadLimitError) Unwrap() error {
+	return ErrUploadLimit
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problem of manifest field.
+type FieldError struct {
+	Field   string `json:"field"`   // JSON path of field, ex: run[0], output_headers.X-Id, cron[1].cron
+	Message string `json:"message"` // description of problem
+}
+
+func (fe FieldError) Error() string {
+	if fe.Field == "" {
+		return fe.Message
+	}
+	return fe.Field + ": " + fe.Message
+}
+
+// Invalid manifest: all problems of fields in order of manifest definition.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (ve *ValidationError) Error() string {
+	var lines = make([]string, 0, len(ve.Fields))
+	for _, field := range ve.Fields {
+		lines = append(lines, field.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Problems of fields as separate errors.
+func (ve *ValidationError) Unwrap() []error {
+	var errs = make([]error, 0, len(ve.Fields))
+	for _, field := range ve.Fields {
+		errs = append(errs, field)
+	}
+	return errs
+}
+
+// collector of field problems
+type fieldErrors []FieldError
+
+// add error of field, joined errors are added as separate problems of the same field
+func (fe *fieldErrors) add(field string, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, item := range joined.Unwrap() {
+			fe.add(field, item)
+		}
+		return
+	}
+	*fe = append(*fe, FieldError{Field: field, Message: err.Error()})
+}
+
+func (fe *fieldErrors) addf(field string, format string, args ...interface{}) {
+	fe.add(field, fmt.Errorf(format, args...))
+}
+
+func (fe fieldErrors) err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fe}
+}
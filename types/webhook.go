@@ -0,0 +1,208 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Presets of webhook signatures by conventions of providers
+const (
+	WebhookGitHub = "github" // X-Hub-Signature-256: sha256=<hex of HMAC-SHA256 of body>
+	WebhookGitLab = "gitlab" // X-Gitlab-Token: secret as is (GitLab does not sign payloads)
+	WebhookStripe = "stripe" // Stripe-Signature: t=<unix time>,v1=<hex of HMAC-SHA256 of "<unix time>.<body>">
+)
+
+// Algorithms of webhook signatures
+const (
+	SignatureSHA1   = "hmac-sha1"
+	SignatureSHA256 = "hmac-sha256"
+	SignatureSHA512 = "hmac-sha512"
+	SignatureToken  = "token" // header contains secret as is
+)
+
+// Encodings of webhook signatures
+const (
+	SignatureHex    = "hex"
+	SignatureBase64 = "base64"
+)
+
+// Default maximum age of signed timestamp of stripe preset
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// ErrSignatureMismatch means that request is not signed by secret of webhook signature
+var ErrSignatureMismatch = errors.New("webhook signature mismatch")
+
+// WebhookSignature is verification of HMAC of request body by secret shared with webhook provider. Requests without
+// valid signature are rejected with 401 before invocation. Fields which are not set are taken from preset
+type WebhookSignature struct {
+	Preset    string       `json:"preset,omitempty"`    // github, gitlab or stripe
+	Header    string       `json:"header,omitempty"`    // request header with signature (ex: X-Hub-Signature-256)
+	Algorithm string       `json:"algorithm,omitempty"` // hmac-sha256 (default), hmac-sha1, hmac-sha512 or token
+	Secret    string       `json:"secret"`              // shared secret, could reference variable of environment as ${NAME}
+	Encoding  string       `json:"encoding,omitempty"`  // encoding of signature: hex (default) or base64
+	Prefix    string       `json:"prefix,omitempty"`    // prefix of signature in header (ex: sha256=)
+	Tolerance JsonDuration `json:"tolerance,omitempty"` // maximum age of signed timestamp of stripe preset (zero - DefaultSignatureTolerance)
+}
+
+// Effective signature settings: preset defaults for fields which are not set
+func (ws WebhookSignature) Effective() WebhookSignature {
+	var preset WebhookSignature
+	switch ws.Preset {
+	case WebhookGitHub:
+		preset = WebhookSignature{Header: "X-Hub-Signature-256", Algorithm: SignatureSHA256, Encoding: SignatureHex, Prefix: "sha256="}
+	case WebhookGitLab:
+		preset = WebhookSignature{Header: "X-Gitlab-Token", Algorithm: SignatureToken}
+	case WebhookStripe:
+		preset = WebhookSignature{Header: "Stripe-Signature", Algorithm: SignatureSHA256, Encoding: SignatureHex}
+	}
+	if ws.Header == "" {
+		ws.Header = preset.Header
+	}
+	if ws.Algorithm == "" {
+		ws.Algorithm = preset.Algorithm
+	}
+	if ws.Algorithm == "" {
+		ws.Algorithm = SignatureSHA256
+	}
+	if ws.Encoding == "" {
+		ws.Encoding = preset.Encoding
+	}
+	if ws.Encoding == "" {
+		ws.Encoding = SignatureHex
+	}
+	if ws.Prefix == "" {
+		ws.Prefix = preset.Prefix
+	}
+	if ws.Tolerance == 0 {
+		ws.Tolerance = JsonDuration(DefaultSignatureTolerance)
+	}
+	return ws
+}
+
+// Verify signature of body in headers of request (see Request.Headers) by secret with resolved references.
+// Comparison is constant-time
+func (ws WebhookSignature) Verify(headers map[string]string, body []byte, secret string, now time.Time) error {
+	ws = ws.Effective()
+	value := strings.TrimSpace(headers[http.CanonicalHeaderKey(ws.Header)])
+	if value == "" {
+		return fmt.Errorf("%w: header %s is not set", ErrSignatureMismatch, ws.Header)
+	}
+	if ws.Algorithm == SignatureToken {
+		if subtle.ConstantTimeCompare([]byte(value), []byte(secret)) != 1 {
+			return ErrSignatureMismatch
+		}
+		return nil
+	}
+	if ws.Preset == WebhookStripe {
+		return ws.verifyStripe(value, body, secret, now)
+	}
+	if !strings.HasPrefix(value, ws.Prefix) {
+		return ErrSignatureMismatch
+	}
+	if !ws.matches(strings.TrimPrefix(value, ws.Prefix), body, secret) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// header is list of key=value: t is signed timestamp, v1 - signatures (one per active secret)
+func (ws WebhookSignature) verifyStripe(value string, body []byte, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, item := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "t":
+			timestamp = val
+		case "v1":
+			signatures = append(signatures, val)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrSignatureMismatch)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > time.Duration(ws.Tolerance) || age < -time.Duration(ws.Tolerance) {
+		return fmt.Errorf("%w: timestamp is outside of tolerance", ErrSignatureMismatch)
+	}
+	payload := make([]byte, 0, len(timestamp)+1+len(body))
+	payload = append(append(append(payload, timestamp...), '.'), body...)
+	for _, signature := range signatures {
+		if ws.matches(signature, payload, secret) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+func (ws WebhookSignature) matches(signature string, payload []byte, secret string) bool {
+	var decoded []byte
+	var err error
+	switch ws.Encoding {
+	case SignatureBase64:
+		decoded, err = base64.StdEncoding.DecodeString(signature)
+	default:
+		decoded, err = hex.DecodeString(signature)
+	}
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(ws.hash(), []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(decoded, mac.Sum(nil))
+}
+
+func (ws WebhookSignature) hash() func() hash.Hash {
+	switch ws.Algorithm {
+	case SignatureSHA1:
+		return sha1.New
+	case SignatureSHA512:
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+func (ws *WebhookSignature) validate() error {
+	var errs []error
+	switch ws.Preset {
+	case "", WebhookGitHub, WebhookGitLab, WebhookStripe:
+	default:
+		errs = append(errs, fmt.Errorf("unknown webhook signature preset %s", ws.Preset))
+	}
+	effective := ws.Effective()
+	if effective.Header == "" {
+		errs = append(errs, fmt.Errorf("header of webhook signature is not defined"))
+	}
+	switch effective.Algorithm {
+	case SignatureSHA1, SignatureSHA256, SignatureSHA512, SignatureToken:
+	default:
+		errs = append(errs, fmt.Errorf("unknown webhook signature algorithm %s", ws.Algorithm))
+	}
+	switch effective.Encoding {
+	case SignatureHex, SignatureBase64:
+	default:
+		errs = append(errs, fmt.Errorf("unknown webhook signature encoding %s", ws.Encoding))
+	}
+	if ws.Secret == "" {
+		errs = append(errs, fmt.Errorf("secret of webhook signature is not defined"))
+	} else if _, err := expandValue(ws.Secret, func(string) string { return "" }); err != nil {
+		errs = append(errs, fmt.Errorf("secret of webhook signature: %w", err))
+	}
+	if ws.Tolerance < 0 {
+		errs = append(errs, fmt.Errorf("tolerance of webhook signature should not be negative"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,61 @@
+package types_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func TestWebhookSignature_Verify(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	sign := func(payload []byte) []byte {
+		mac := hmac.New(sha256.New, []byte("top-secret"))
+		mac.Write(payload)
+		return mac.Sum(nil)
+	}
+	now := time.Now()
+
+	t.Run("github", func(t *testing.T) {
+		sig := types.WebhookSignature{Preset: types.WebhookGitHub}
+		headers := map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(sign(body))}
+		assert.NoError(t, sig.Verify(headers, body, "top-secret", now))
+		assert.ErrorIs(t, sig.Verify(headers, body, "other-secret", now), types.ErrSignatureMismatch)
+		assert.ErrorIs(t, sig.Verify(headers, []byte(`{}`), "top-secret", now), types.ErrSignatureMismatch)
+		assert.ErrorIs(t, sig.Verify(map[string]string{}, body, "top-secret", now), types.ErrSignatureMismatch)
+		headers["X-Hub-Signature-256"] = hex.EncodeToString(sign(body))
+		assert.ErrorIs(t, sig.Verify(headers, body, "top-secret", now), types.ErrSignatureMismatch)
+	})
+	t.Run("gitlab", func(t *testing.T) {
+		sig := types.WebhookSignature{Preset: types.WebhookGitLab}
+		assert.NoError(t, sig.Verify(map[string]string{"X-Gitlab-Token": "top-secret"}, body, "top-secret", now))
+		assert.ErrorIs(t, sig.Verify(map[string]string{"X-Gitlab-Token": "top"}, body, "top-secret", now), types.ErrSignatureMismatch)
+	})
+	t.Run("stripe", func(t *testing.T) {
+		sig := types.WebhookSignature{Preset: types.WebhookStripe}
+		stamp := strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+		valid := hex.EncodeToString(sign([]byte(stamp + "." + string(body))))
+		headers := map[string]string{"Stripe-Signature": "t=" + stamp + ",v1=deadbeef,v1=" + valid}
+		assert.NoError(t, sig.Verify(headers, body, "top-secret", now))
+		assert.ErrorIs(t, sig.Verify(headers, body, "top-secret", now.Add(10*time.Minute)), types.ErrSignatureMismatch)
+		headers["Stripe-Signature"] = "t=" + stamp + ",v1=" + hex.EncodeToString(sign(body))
+		assert.ErrorIs(t, sig.Verify(headers, body, "top-secret", now), types.ErrSignatureMismatch)
+	})
+	t.Run("custom", func(t *testing.T) {
+		mac := hmac.New(sha1.New, []byte("top-secret"))
+		mac.Write(body)
+		sig := types.WebhookSignature{Header: "x-signature", Algorithm: types.SignatureSHA1, Encoding: types.SignatureBase64}
+		headers := map[string]string{"X-Signature": base64.StdEncoding.EncodeToString(mac.Sum(nil))}
+		assert.NoError(t, sig.Verify(headers, body, "top-secret", now))
+		headers["X-Signature"] = base64.StdEncoding.EncodeToString(sign(body))
+		assert.ErrorIs(t, sig.Verify(headers, body, "top-secret", now), types.ErrSignatureMismatch)
+	})
+}
@@ -0,0 +1,17 @@
+package types
+
+// Invocation modes of lambda (see Manifest.Mode)
+const (
+	ModeExec   = "exec"   // process per request (default)
+	ModeWorker = "worker" // pool of long-running processes, requests are passed as JSON lines over stdin and stdout
+)
+
+// Lambda is invoked by pool of long-running worker processes
+func (mf *Manifest) Worker() bool {
+	return mf.Mode == ModeWorker
+}
+
+// PoolSize is maximum number of worker processes (at least one)
+func (mf *Manifest) PoolSize() int {
+	return max(mf.Workers, 1)
+}